@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+var (
+	analyzeFormat string
+	analyzeFailOn string
+)
+
+var analyzeCmd = &cobra.Command{
+	Use:   "analyze <setup.msi>",
+	Short: "List the custom actions of an MSI and flag the risky ones",
+	Long: `Read the CustomAction table of an MSI and rate each custom action that runs
+code (EXE, DLL, script or nested MSI), to help a security review of vendor
+MSIs before they are deployed to the whole fleet.
+
+High risk:
+  - deferred EXE or script actions that run as LocalSystem (no impersonation)
+  - EXE actions starting interpreters or system tools (powershell, cmd,
+    mshta, rundll32, certutil, ...)
+  - actions referencing a URL
+Medium risk:
+  - deferred actions that impersonate the installing user, whose changes to
+    the machine fail when the install runs without elevation
+  - immediate EXE and script actions, scripts, nested MSIs and actions that
+    hide their target from the log
+
+--fail-on returns an error when an action at or above the given risk is
+found, so the review can gate a pipeline.
+
+Examples:
+  intunewin analyze ./source/agent.msi
+  intunewin analyze ./source/agent.msi --format json
+  intunewin analyze ./source/agent.msi --fail-on high`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runAnalyze(args[0])
+	},
+}
+
+func init() {
+	analyzeCmd.Flags().StringVar(&analyzeFormat, "format", "text", "Output format: text or json")
+	analyzeCmd.Flags().StringVar(&analyzeFailOn, "fail-on", "", "Fail when a custom action has this risk or higher: high, medium or low")
+	rootCmd.AddCommand(analyzeCmd)
+}
+
+func runAnalyze(path string) error {
+	if analyzeFormat != "text" && analyzeFormat != "json" {
+		return fmt.Errorf("invalid format: %s (supported: text, json)", analyzeFormat)
+	}
+	var failOn string
+	if analyzeFailOn != "" {
+		level, err := packager.ParseRiskLevel(analyzeFailOn)
+		if err != nil {
+			return err
+		}
+		failOn = level
+	}
+
+	analysis, err := packager.AnalyzeMsi(path)
+	if err != nil {
+		return err
+	}
+
+	if analyzeFormat == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(analysis); err != nil {
+			return fmt.Errorf("failed to encode analysis: %w", err)
+		}
+	} else if err := printAnalysis(analysis); err != nil {
+		return err
+	}
+
+	if failOn != "" {
+		if risky := analysis.Risky(failOn); len(risky) > 0 {
+			return fmt.Errorf("%d custom action(s) with %s risk or higher", len(risky), failOn)
+		}
+	}
+	return nil
+}
+
+// printAnalysis prints the MSI metadata and a table of its custom actions
+func printAnalysis(analysis *packager.MsiAnalysis) error {
+	info := analysis.Info
+	fmt.Printf("Product:      %s\n", valueOrDash(info.ProductName))
+	fmt.Printf("Version:      %s\n", valueOrDash(info.ProductVersion))
+	fmt.Printf("Publisher:    %s\n", valueOrDash(info.Publisher))
+	fmt.Printf("Product code: %s\n", valueOrDash(info.ProductCode))
+	fmt.Printf("Context:      %s\n", valueOrDash(info.ExecutionContext))
+	fmt.Println()
+
+	if len(analysis.CustomActions) == 0 {
+		fmt.Println("No custom actions run code.")
+		return nil
+	}
+
+	counts := map[string]int{}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RISK\tACTION\tKIND\tEXECUTION\tREASONS")
+	for _, ca := range analysis.CustomActions {
+		counts[ca.Risk]++
+		execution := ca.Execution
+		if ca.NoImpersonate {
+			execution += " (system)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", ca.Risk, ca.Action, ca.Kind, execution, strings.Join(ca.Reasons, "; "))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d custom action(s): %d high, %d medium, %d low risk\n",
+		len(analysis.CustomActions), counts[packager.RiskHigh], counts[packager.RiskMedium], counts[packager.RiskLow])
+	return nil
+}
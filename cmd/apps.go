@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/graph"
+)
+
+var (
+	// Graph credential flags (fall back to the standard Azure environment variables)
+	tenantID     string
+	clientID     string
+	clientSecret string
+	whatIf       bool
+	graphURL     string
+	cloudName    string
+	httpTimeout  time.Duration
+	maxRetries   int
+
+	// apps assign flags
+	assignAppID    string
+	assignGroups   []string
+	assignIntent   string
+	assignDeadline string
+)
+
+var appsCmd = &cobra.Command{
+	Use:   "apps",
+	Short: "Manage Win32 apps in Microsoft Intune via Graph",
+	Long: `Manage Win32 apps in Microsoft Intune using Microsoft Graph.
+
+Authentication uses an Azure AD app registration (client credentials).
+Credentials can be passed as flags or via the AZURE_TENANT_ID,
+AZURE_CLIENT_ID and AZURE_CLIENT_SECRET environment variables.
+
+With --what-if, the Graph requests that would change the tenant are
+printed (with keys and signatures redacted) instead of being sent, like
+a Terraform plan. Read requests are still made to resolve names.`,
+}
+
+var appsAssignCmd = &cobra.Command{
+	Use:   "assign",
+	Short: "Assign an existing Win32 app to Azure AD groups",
+	Long: `Create assignments for an existing Win32 app.
+
+Groups can be given by object ID or display name. Existing assignments
+of the app are kept.
+
+Examples:
+  # Make an app required for a group with an install deadline
+  intunewin apps assign --app-id <id> --assign-group "Pilot Devices" --intent required --deadline 2026-11-01T18:00
+
+  # Make an app available to several groups
+  intunewin apps assign --app-id <id> --assign-group "All Users" --assign-group "Contractors" --intent available`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runAppsAssign(cmd)
+	},
+}
+
+func init() {
+	addGraphFlags(appsCmd.PersistentFlags())
+	addTimeoutFlag(appsCmd.PersistentFlags())
+
+	appsAssignCmd.Flags().StringVar(&assignAppID, "app-id", "", "ID of the Win32 app to assign")
+	addAssignFlags(appsAssignCmd.Flags())
+
+	appsCmd.AddCommand(appsAssignCmd)
+	rootCmd.AddCommand(appsCmd)
+}
+
+// addGraphFlags adds the Graph credential, what-if and retry flags
+func addGraphFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&tenantID, "tenant-id", "", "Azure AD tenant ID (env: AZURE_TENANT_ID)")
+	flags.StringVar(&clientID, "client-id", "", "App registration client ID (env: AZURE_CLIENT_ID)")
+	flags.StringVar(&clientSecret, "client-secret", "", "App registration client secret (env: AZURE_CLIENT_SECRET)")
+	flags.BoolVar(&whatIf, "what-if", false, "Print the Graph requests that would change the tenant instead of sending them")
+	flags.StringVar(&graphURL, "graph-url", "", "Send token and Graph requests to this server instead, e.g. a mock-graph server (env "+graphURLEnv+")")
+	flags.StringVar(&cloudName, "cloud", "", "Azure cloud of the tenant: public, usgovhigh (GCC High), dod or china (21Vianet) (default: cloud of the profile, else public)")
+	flags.DurationVar(&httpTimeout, "http-timeout", graph.DefaultHTTPOptions.Timeout, "Time limit of each attempt of a Graph request (0 for no limit)")
+	flags.IntVar(&maxRetries, "max-retries", graph.DefaultHTTPOptions.MaxRetries, "Times a throttled (429, 503) or failed Graph request is retried")
+}
+
+// addAssignFlags adds the flags assigning an app to groups
+func addAssignFlags(flags *pflag.FlagSet) {
+	flags.StringArrayVar(&assignGroups, "assign-group", nil, "Azure AD group name or object ID (repeatable)")
+	flags.StringVar(&assignIntent, "intent", "required", "Assignment intent: required, available or uninstall")
+	flags.StringVar(&assignDeadline, "deadline", "", "Install deadline for required assignments (e.g., 2026-11-01T18:00)")
+}
+
+// flagAssignments returns the assignments of --assign-group with --intent and --deadline
+// Groups are left as names or IDs for the caller to resolve
+func flagAssignments(cmd *cobra.Command) ([]graph.Assignment, error) {
+	if len(assignGroups) == 0 {
+		if cmd.Flags().Changed("intent") || cmd.Flags().Changed("deadline") {
+			return nil, fmt.Errorf("--intent and --deadline need an --assign-group")
+		}
+		return nil, nil
+	}
+
+	intent, err := graph.ParseIntent(assignIntent)
+	if err != nil {
+		return nil, err
+	}
+	var deadline *time.Time
+	if assignDeadline != "" {
+		d, err := parseDeadline(assignDeadline)
+		if err != nil {
+			return nil, err
+		}
+		deadline = &d
+	}
+
+	assignments := make([]graph.Assignment, 0, len(assignGroups))
+	for _, group := range assignGroups {
+		assignments = append(assignments, graph.Assignment{GroupID: group, Intent: intent, Deadline: deadline})
+	}
+	return assignments, nil
+}
+
+// newGraphClient creates a Graph client from flags, falling back to environment variables
+// and then to the config profile
+func newGraphClient() (*graph.Client, error) {
+	profile, err := activeProfile()
+	if err != nil {
+		return nil, invalidInput(err)
+	}
+
+	creds := graph.Credentials{
+		TenantID:     firstNonEmpty(tenantID, os.Getenv("AZURE_TENANT_ID"), profile.TenantID),
+		ClientID:     firstNonEmpty(clientID, os.Getenv("AZURE_CLIENT_ID"), profile.ClientID),
+		ClientSecret: firstNonEmpty(clientSecret, os.Getenv("AZURE_CLIENT_SECRET")),
+	}
+	if err := creds.Validate(); err != nil {
+		return nil, invalidInput(fmt.Errorf("graph credentials: %w", err))
+	}
+	if maxRetries < 0 || httpTimeout < 0 {
+		return nil, invalidInput(fmt.Errorf("--max-retries and --http-timeout cannot be negative"))
+	}
+	cloud, err := graph.ParseCloud(firstNonEmpty(cloudName, profile.Cloud))
+	if err != nil {
+		return nil, invalidInput(err)
+	}
+	client := graph.NewClient(creds)
+	client.SetCloud(cloud)
+	client.SetHTTPOptions(graphHTTPOptions(graph.HTTPOptions{
+		Timeout:    httpTimeout,
+		MaxRetries: maxRetries,
+		MaxDelay:   graph.DefaultHTTPOptions.MaxDelay,
+	}))
+	if server := firstNonEmpty(graphURL, os.Getenv(graphURLEnv)); server != "" {
+		client.SetServer(server)
+	}
+	if whatIf {
+		fmt.Println("What-if mode: no changes will be made to the tenant")
+		fmt.Println()
+		client.SetWhatIf(os.Stdout)
+	}
+	return client, nil
+}
+
+func runAppsAssign(cmd *cobra.Command) error {
+	if assignAppID == "" {
+		return invalidInput(fmt.Errorf("--app-id is required"))
+	}
+	if len(assignGroups) == 0 {
+		return invalidInput(fmt.Errorf("at least one --assign-group is required"))
+	}
+
+	assignments, err := flagAssignments(cmd)
+	if err != nil {
+		return invalidInput(err)
+	}
+
+	client, err := newGraphClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	for i := range assignments {
+		groupID, err := client.ResolveGroupID(ctx, assignments[i].GroupID)
+		if err != nil {
+			return uploadFailed(err)
+		}
+		assignments[i].GroupID = groupID
+	}
+
+	if err := client.AssignApp(ctx, assignAppID, assignments); err != nil {
+		return uploadFailed(fmt.Errorf("assignment failed: %w", err))
+	}
+
+	fmt.Printf("Assigned app %s (%s) to %d group(s)\n", assignAppID, assignments[0].Intent, len(assignments))
+	for i, group := range assignGroups {
+		fmt.Printf("  %s (%s)\n", group, assignments[i].GroupID)
+	}
+	return nil
+}
+
+// parseDeadline parses an RFC 3339 timestamp or a local date/time
+func parseDeadline(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid deadline: %s (expected e.g. 2026-11-01T18:00)", s)
+}
+
+// firstNonEmpty returns the first non-empty string
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/graph"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+var (
+	contentAppID         string
+	activateVersion      string
+	activateDetectFile   string
+	activateKeepMetadata bool
+)
+
+var appsContentCmd = &cobra.Command{
+	Use:   "content-versions",
+	Short: "List and roll back the content versions of a Win32 app",
+	Long: `Each upload of a Win32 app's content, by apps create, publish or bump,
+adds a content version to the app. Intune installs the committed version,
+and keeps the previous ones: list them, and activate a previous one to
+roll back a bad release without uploading it again.`,
+}
+
+var appsContentListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the content versions of a Win32 app",
+	Long: `List the content versions of a Win32 app, oldest first, with their upload
+date, size, and the setup file and MSI version of their package. The
+active (committed) version is marked with *.
+
+Examples:
+  intunewin apps content-versions list --app-id "7-Zip"`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runAppsContentList()
+	},
+}
+
+var appsContentActivateCmd = &cobra.Command{
+	Use:   "activate <content-version>",
+	Short: "Make a previous content version the active content of a Win32 app",
+	Long: `Make a content version the committed content of a Win32 app, e.g. to roll
+back to the previous release after a bad bump.
+
+The version metadata of the app is restored from the package of the
+content version, like bump does: the display version, setup file and, for
+MSIs, the MSI information, product code in the uninstall command line and
+product code detection rules. For other packages, pass the display version
+of the content version with --version, and --detect-file if the detection
+rules check the version. With --keep-metadata, only the content changes.
+
+Examples:
+  intunewin apps content-versions activate 1 --app-id "7-Zip" --what-if
+  intunewin apps content-versions activate 3 --app-id "CAD Pro" --version 2024.2 \
+    --detect-file "C:\Program Files\CadPro\2024\cadpro.exe"`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runAppsContentActivate(args[0])
+	},
+}
+
+func init() {
+	appsContentCmd.PersistentFlags().StringVar(&contentAppID, "app-id", "", "ID or display name of the Win32 app")
+
+	appsContentActivateCmd.Flags().StringVar(&activateVersion, "version", "", "Display version of the content version (default: MSI product version)")
+	appsContentActivateCmd.Flags().StringVar(&activateDetectFile, "detect-file", "", "Replace the detection rules with a check for this file (full path)")
+	appsContentActivateCmd.Flags().BoolVar(&activateKeepMetadata, "keep-metadata", false, "Only change the content, not the version metadata of the app")
+
+	appsContentCmd.AddCommand(appsContentListCmd)
+	appsContentCmd.AddCommand(appsContentActivateCmd)
+	appsCmd.AddCommand(appsContentCmd)
+}
+
+func runAppsContentList() error {
+	if contentAppID == "" {
+		return invalidInput(fmt.Errorf("--app-id is required"))
+	}
+	client, err := newGraphClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	appID, err := client.ResolveAppID(ctx, contentAppID)
+	if err != nil {
+		return err
+	}
+	versions, err := client.ListContentVersions(ctx, appID)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		fmt.Println("The app has no content versions")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tACTIVE\tUPLOADED\tSIZE\tSETUP FILE\tMSI VERSION\tSTATE")
+	for _, v := range versions {
+		active := ""
+		if v.Active {
+			active = "*"
+		}
+		file := v.CommittedFile()
+		if file == nil {
+			state := "empty"
+			if len(v.Files) > 0 {
+				state = "not committed"
+			}
+			fmt.Fprintf(w, "%s\t%s\t-\t-\t-\t-\t%s\n", v.ID, active, state)
+			continue
+		}
+		uploaded := "-"
+		if !file.CreatedDateTime.IsZero() {
+			uploaded = file.CreatedDateTime.Local().Format("2006-01-02 15:04")
+		}
+		setupFile, msiVersion := "-", "-"
+		if appInfo := contentManifest(file); appInfo != nil {
+			setupFile = valueOrDash(appInfo.SetupFile)
+			if appInfo.MsiInfo != nil {
+				msiVersion = valueOrDash(appInfo.MsiInfo.MsiProductVersion)
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\tcommitted\n",
+			v.ID, active, uploaded, packager.FormatSize(file.Size), setupFile, msiVersion)
+	}
+	return w.Flush()
+}
+
+func runAppsContentActivate(versionID string) error {
+	if contentAppID == "" {
+		return invalidInput(fmt.Errorf("--app-id is required"))
+	}
+	if activateKeepMetadata && (activateVersion != "" || activateDetectFile != "") {
+		return invalidInput(fmt.Errorf("--keep-metadata cannot be combined with --version or --detect-file"))
+	}
+	client, err := newGraphClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	appID, err := client.ResolveAppID(ctx, contentAppID)
+	if err != nil {
+		return uploadFailed(err)
+	}
+	current, err := client.GetWin32App(ctx, appID)
+	if err != nil {
+		return uploadFailed(err)
+	}
+	versions, err := client.ListContentVersions(ctx, appID)
+	if err != nil {
+		return uploadFailed(err)
+	}
+	var target *graph.ContentVersion
+	for i := range versions {
+		if versions[i].ID == versionID {
+			target = &versions[i]
+		}
+	}
+	if target == nil {
+		return invalidInput(fmt.Errorf("app %q has no content version %s (see apps content-versions list)", current.DisplayName, versionID))
+	}
+	if target.Active {
+		fmt.Printf("Content version %s is already active for %q\n", versionID, current.DisplayName)
+		return nil
+	}
+	file := target.CommittedFile()
+	if file == nil {
+		return invalidInput(fmt.Errorf("content version %s was never committed and cannot be activated", versionID))
+	}
+
+	// The metadata is worked out before anything changes, so a bad flag changes nothing
+	var update *graph.AppVersion
+	var notes []string
+	if !activateKeepMetadata {
+		appInfo := contentManifest(file)
+		switch {
+		case appInfo != nil:
+			version, versionNotes, err := bumpAppVersion(current, appInfo, "", activateVersion, activateDetectFile)
+			if err != nil && activateVersion == "" {
+				notes = append(notes, "Kept the version metadata: the package is not an MSI; pass --version to restore it")
+				break
+			}
+			if err != nil {
+				return invalidInput(err)
+			}
+			update, notes = &version, versionNotes
+		case activateVersion != "" || activateDetectFile != "":
+			return invalidInput(fmt.Errorf("content version %s has no package manifest to restore the metadata from: pass --keep-metadata", versionID))
+		default:
+			notes = append(notes, "Kept the version metadata: content version "+versionID+" has no package manifest")
+		}
+	}
+
+	fmt.Printf("Activating content version %s of %q (%s)\n", versionID, current.DisplayName, appID)
+	if err := client.ActivateContentVersion(ctx, appID, versionID); err != nil {
+		return uploadFailed(err)
+	}
+	fmt.Printf("  Content version %s is now active\n", versionID)
+
+	if update != nil {
+		if err := client.UpdateAppVersion(ctx, appID, *update); err != nil {
+			return uploadFailed(fmt.Errorf("content version activated, but %w", err))
+		}
+		fmt.Printf("  Updated version from %s to %s, setup file and command lines\n", valueOrDash(current.DisplayVersion), update.DisplayVersion)
+	}
+	for _, note := range notes {
+		fmt.Printf("  %s\n", note)
+	}
+	return nil
+}
+
+// contentManifest returns the Detection.xml uploaded with a content file, nil if it has
+// none or it cannot be read
+func contentManifest(file *graph.ContentFile) *packager.ApplicationInfo {
+	if len(file.Manifest) == 0 {
+		return nil
+	}
+	appInfo, err := packager.ParseDetectionXML(file.Manifest)
+	if err != nil {
+		return nil
+	}
+	return appInfo
+}
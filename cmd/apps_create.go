@@ -0,0 +1,404 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/bundle"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/graph"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/spec"
+)
+
+var (
+	createPackage          string
+	createName             string
+	createVersion          string
+	createPublisher        string
+	createDescription      string
+	createInstallCommand   string
+	createUninstallCommand string
+	createDetectFile       string
+	createArchitectures    string
+	createOnConflict       string
+	createSpec             string
+	createIcon             string
+	createNoIcon           bool
+)
+
+var appsCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a Win32 app in Intune from a .intunewin package",
+	Long: `Create the Win32 app record for a .intunewin package.
+
+Name, version, publisher and MSI detection are read from the package's
+Detection.xml and can be overridden with flags. The package content is
+uploaded once the app is created.
+
+Instead of flags, the app can be described in a YAML app spec (see
+'validate-spec --print-schema app'). A spec can also list assignments,
+superseded apps and dependencies, which are applied after the content is
+uploaded. Flags override values from the spec; --assign-group adds
+assignments to those of the spec.
+
+Before creating the app the tenant is checked for an app with the same
+display name. --on-conflict decides what happens then:
+  fail    stop without changing anything (default)
+  suffix  append the version to the display name (e.g., "7-Zip 24.01")
+  update  update the existing app instead of creating a duplicate
+
+Examples:
+  # MSI package: commands and detection are derived from the MSI
+  intunewin apps create --package ./output/7z2401-x64.intunewin --on-conflict suffix
+
+  # EXE package
+  intunewin apps create --package ./output/setup.intunewin --name "My App" --version 2.0 \
+    --install-command "setup.exe /S" --uninstall-command "uninstall.exe /S" \
+    --detect-file "C:\Program Files\My App\app.exe"
+
+  # Make it required for a group, with an install deadline
+  intunewin apps create --package ./output/7z2401-x64.intunewin --assign-group "Pilot Devices" \
+    --intent required --deadline 2026-11-01T18:00
+
+  # From an app spec
+  intunewin apps create --spec 7zip.yaml
+
+Return codes of the spec, or Intune's defaults (0, 1707, 3010, 1641, 1618),
+are merged with the returnCodes of the config profile and --return-code:
+  intunewin apps create --package ./output/setup.intunewin --return-code 3010=success --return-code 1=failed`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runAppsCreate(cmd)
+	},
+}
+
+func init() {
+	addAppFlags(appsCreateCmd.Flags())
+	addAssignFlags(appsCreateCmd.Flags())
+	appsCmd.AddCommand(appsCreateCmd)
+}
+
+// addAppFlags adds the flags describing the app to create from a package
+func addAppFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&createPackage, "package", "", "Path to the .intunewin package")
+	flags.StringVar(&createName, "name", "", "Display name (default: name from Detection.xml)")
+	flags.StringVar(&createVersion, "version", "", "Display version (default: MSI product version)")
+	flags.StringVar(&createPublisher, "publisher", "", "Publisher (default: MSI publisher)")
+	flags.StringVar(&createDescription, "description", "", "Description (default: display name)")
+	flags.StringVar(&createInstallCommand, "install-command", "", "Install command line (default for MSI: msiexec /i; for scripts, registry files, MSP and MSU: their silent command)")
+	flags.StringVar(&createUninstallCommand, "uninstall-command", "", "Uninstall command line (default for MSI: msiexec /x)")
+	flags.StringVar(&createDetectFile, "detect-file", "", "Full path of a file whose existence detects the app (non-MSI)")
+	flags.StringVar(&createArchitectures, "architectures", "x64", "Applicable architectures (e.g., x64 or x86,x64)")
+	flags.StringVar(&createOnConflict, "on-conflict", "fail", "When an app with the same name exists: fail, suffix or update")
+	flags.StringVar(&createSpec, "spec", "", "YAML app spec describing the app")
+	flags.StringVar(&createIcon, "icon", "", "PNG, JPEG or ICO file, or EXE, with the icon shown in the Company Portal (default: icon of an EXE setup file)")
+	flags.BoolVar(&createNoIcon, "no-icon", false, "Create the app without an icon instead of extracting the icon of an EXE setup file")
+	flags.StringArrayVar(&createReturnCodes, "return-code", nil, "Installer exit code and the result Intune reports, e.g. 3010=softReboot (repeatable; types: success, softReboot, hardReboot, retry, failed)")
+}
+
+func runAppsCreate(cmd *cobra.Command) error {
+	appSpec, err := appSpecFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	assignments, err := flagAssignments(cmd)
+	if err != nil {
+		return invalidInput(err)
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	return createAppFromSpec(ctx, appSpec, assignments)
+}
+
+// appSpecFromFlags loads the app spec of --spec, if any, and applies the app flags over it
+func appSpecFromFlags(cmd *cobra.Command) (*spec.AppSpec, error) {
+	appSpec := &spec.AppSpec{}
+	if createSpec != "" {
+		loaded, err := spec.LoadAppSpec(createSpec)
+		if err != nil {
+			return nil, inputError(err)
+		}
+		appSpec = loaded
+	}
+
+	// Flags override the spec
+	appSpec.Package = firstNonEmpty(createPackage, appSpec.Package)
+	if appSpec.Package == "" {
+		return nil, invalidInput(fmt.Errorf("--package or --spec is required"))
+	}
+	appSpec.Name = firstNonEmpty(createName, appSpec.Name)
+	appSpec.Version = firstNonEmpty(createVersion, appSpec.Version)
+	appSpec.Publisher = firstNonEmpty(createPublisher, appSpec.Publisher)
+	appSpec.Description = firstNonEmpty(createDescription, appSpec.Description)
+	appSpec.InstallCommand = firstNonEmpty(createInstallCommand, appSpec.InstallCommand)
+	appSpec.UninstallCommand = firstNonEmpty(createUninstallCommand, appSpec.UninstallCommand)
+	appSpec.Icon = firstNonEmpty(createIcon, appSpec.Icon)
+	if appSpec.OnConflict == "" || cmd.Flags().Changed("on-conflict") {
+		appSpec.OnConflict = createOnConflict
+	}
+	if err := applyPackageRequirements(appSpec); err != nil {
+		return nil, inputError(err)
+	}
+	if err := applyReturnCodes(appSpec); err != nil {
+		return nil, invalidInput(err)
+	}
+	if appSpec.Architectures == "" || cmd.Flags().Changed("architectures") {
+		appSpec.Architectures = createArchitectures
+	}
+	if createDetectFile != "" {
+		appSpec.Detection = &spec.DetectionSpec{File: createDetectFile}
+	}
+	return appSpec, nil
+}
+
+// createAppFromSpec creates the app described by a spec from its package and uploads its
+// content, then adds the relationships and assignments of the spec and the extra
+// assignments given
+func createAppFromSpec(ctx context.Context, appSpec *spec.AppSpec, assignments []graph.Assignment) error {
+	plan, err := planApp(appSpec)
+	if err != nil {
+		return err
+	}
+
+	// The package is read before the app is created, so a bad package changes nothing
+	b, err := bundle.OpenPackage(appSpec.Package)
+	if err != nil {
+		return inputError(err)
+	}
+	defer b.Close()
+	content, err := b.Content()
+	if err != nil {
+		return inputError(err)
+	}
+	defer content.Close()
+	upload, err := b.Upload(content)
+	if err != nil {
+		return inputError(err)
+	}
+
+	client, err := newGraphClient()
+	if err != nil {
+		return err
+	}
+
+	result, err := client.CreateWin32App(ctx, plan.app, plan.policy)
+	if err != nil {
+		return uploadFailed(err)
+	}
+	printCreatedApp(result)
+	if err := client.UploadContent(ctx, result.App.ID, upload); err != nil {
+		return uploadFailed(fmt.Errorf("app %s created, but its content was not uploaded: %w", result.App.ID, err))
+	}
+	fmt.Printf("  Uploaded %s of content\n", packager.FormatSize(upload.SizeEncrypted))
+
+	return linkApp(ctx, client, result.App.ID, plan.relationships, append(plan.assignments, assignments...))
+}
+
+// appPlan is the app an app spec creates, with its conflict policy, relationships and
+// assignments
+type appPlan struct {
+	app           graph.Win32App
+	policy        graph.ConflictPolicy
+	relationships []graph.Relationship
+	assignments   []graph.Assignment
+}
+
+// planApp builds the app of a spec from the Detection.xml and icon of its package
+func planApp(appSpec *spec.AppSpec) (*appPlan, error) {
+	policy, err := graph.ParseConflictPolicy(firstNonEmpty(appSpec.OnConflict, string(graph.ConflictFail)))
+	if err != nil {
+		return nil, invalidInput(err)
+	}
+
+	relationships, assignments, err := specLinks(appSpec)
+	if err != nil {
+		return nil, invalidInput(err)
+	}
+
+	appInfo, err := packager.ReadDetectionXML(appSpec.Package)
+	if err != nil {
+		return nil, inputError(err)
+	}
+
+	app, err := specWin32App(appSpec, appInfo)
+	if err != nil {
+		return nil, invalidInput(err)
+	}
+	if app.LargeIcon, err = appIcon(appSpec); err != nil {
+		return nil, inputError(err)
+	}
+	return &appPlan{app: app, policy: policy, relationships: relationships, assignments: assignments}, nil
+}
+
+// printCreatedApp reports the app created or updated by CreateWin32App
+func printCreatedApp(result *graph.CreateResult) {
+	if result.Updated {
+		fmt.Printf("Updated existing app %q (%s)\n", result.App.DisplayName, result.App.ID)
+	} else {
+		fmt.Printf("Created app %q (%s)\n", result.App.DisplayName, result.App.ID)
+	}
+}
+
+// linkApp resolves the targets of relationships and the groups of assignments by name or
+// ID, and adds them to an app
+func linkApp(ctx context.Context, client *graph.Client, appID string, relationships []graph.Relationship, assignments []graph.Assignment) error {
+	if len(relationships) > 0 {
+		for i := range relationships {
+			targetID, err := client.ResolveAppID(ctx, relationships[i].TargetID)
+			if err != nil {
+				return uploadFailed(err)
+			}
+			relationships[i].TargetID = targetID
+		}
+		if err := client.AddRelationships(ctx, appID, relationships); err != nil {
+			return uploadFailed(err)
+		}
+		fmt.Printf("  Added %d relationship(s)\n", len(relationships))
+	}
+
+	if len(assignments) > 0 {
+		for i := range assignments {
+			groupID, err := client.ResolveGroupID(ctx, assignments[i].GroupID)
+			if err != nil {
+				return uploadFailed(err)
+			}
+			assignments[i].GroupID = groupID
+		}
+		if err := client.AssignApp(ctx, appID, assignments); err != nil {
+			return uploadFailed(fmt.Errorf("assignment failed: %w", err))
+		}
+		fmt.Printf("  Assigned to %d group(s)\n", len(assignments))
+	}
+	return nil
+}
+
+// appIcon returns the icon of an app: the icon file of its spec, or else the icon of the
+// EXE setup file of its package, which is decrypted to read it
+// Failing to extract an icon only warns; the app is created with the default icon
+func appIcon(appSpec *spec.AppSpec) ([]byte, error) {
+	if appSpec.Icon != "" {
+		return packager.LoadIcon(appSpec.Icon)
+	}
+	if createNoIcon {
+		return nil, nil
+	}
+	icon, err := packager.PackageIcon(appSpec.Package)
+	if err != nil {
+		if !errors.Is(err, packager.ErrNoIcon) {
+			slog.Warn("could not extract app icon", "package", appSpec.Package, "error", err)
+		}
+		return nil, nil
+	}
+	return icon, nil
+}
+
+// specWin32App builds the Win32 app of a spec, taking the name, setup file and MSI
+// details of the package from its Detection.xml
+func specWin32App(appSpec *spec.AppSpec, appInfo *packager.ApplicationInfo) (graph.Win32App, error) {
+	var detectFile string
+	var detectionRules []map[string]any
+	if appSpec.Detection != nil {
+		detectFile = appSpec.Detection.File
+		detectionRules = appSpec.Detection.Rules
+	}
+
+	app := graph.Win32App{
+		DisplayName:           firstNonEmpty(appSpec.Name, appInfo.Name),
+		DisplayVersion:        appSpec.Version,
+		Description:           appSpec.Description,
+		Publisher:             appSpec.Publisher,
+		FileName:              filepath.Base(appSpec.Package),
+		SetupFile:             appInfo.SetupFile,
+		InstallCommandLine:    appSpec.InstallCommand,
+		UninstallCommandLine:  appSpec.UninstallCommand,
+		Architectures:         appSpec.Architectures,
+		DetectionFile:         detectFile,
+		DetectionRules:        detectionRules,
+		InformationURL:        appSpec.InformationURL,
+		PrivacyInformationURL: appSpec.PrivacyURL,
+		Developer:             appSpec.Developer,
+		Owner:                 appSpec.Owner,
+		Notes:                 appSpec.Notes,
+		RunAsAccount:          appSpec.RunAs,
+		RestartBehavior:       appSpec.RestartBehavior,
+	}
+
+	if msi := appInfo.MsiInfo; msi != nil && msi.MsiProductCode != "" {
+		app.DisplayVersion = firstNonEmpty(app.DisplayVersion, msi.MsiProductVersion)
+		app.Publisher = firstNonEmpty(app.Publisher, msi.MsiPublisher)
+		app.InstallCommandLine = firstNonEmpty(app.InstallCommandLine, fmt.Sprintf(`msiexec /i "%s" /qn`, appInfo.SetupFile))
+		app.UninstallCommandLine = firstNonEmpty(app.UninstallCommandLine, fmt.Sprintf("msiexec /x %s /qn", msi.MsiProductCode))
+		if detectFile == "" {
+			app.MsiProductCode = msi.MsiProductCode
+			app.MsiProductVersion = msi.MsiProductVersion
+			app.MsiUpgradeCode = msi.MsiUpgradeCode
+		}
+	}
+
+	if appInfo.MsiInfo == nil {
+		app.InstallCommandLine = firstNonEmpty(app.InstallCommandLine, packager.SetupInstallCommand(appInfo.SetupFile))
+	}
+
+	if req := appSpec.Requirements; req != nil {
+		app.Requirements = &graph.Requirements{
+			MinimumWindowsRelease:  req.MinimumWindowsRelease,
+			MinimumFreeDiskSpaceMB: req.MinimumFreeDiskSpaceMB,
+			MinimumMemoryMB:        req.MinimumMemoryMB,
+			MinimumProcessors:      req.MinimumProcessors,
+			MinimumCPUSpeedMHz:     req.MinimumCPUSpeedMHz,
+			Rules:                  req.Rules,
+		}
+	}
+	for _, rc := range appSpec.ReturnCodes {
+		t, err := graph.ParseReturnCodeType(rc.Type)
+		if err != nil {
+			return app, err
+		}
+		app.ReturnCodes = append(app.ReturnCodes, graph.ReturnCode{Code: rc.Code, Type: t})
+	}
+	return app, nil
+}
+
+// specLinks converts the relationships and assignments of an app spec
+// Targets and groups are left as names or IDs for the caller to resolve
+func specLinks(appSpec *spec.AppSpec) ([]graph.Relationship, []graph.Assignment, error) {
+	var relationships []graph.Relationship
+	for _, s := range appSpec.Supersedes {
+		t, err := graph.ParseSupersedenceType(firstNonEmpty(s.Type, string(graph.SupersedenceUpdate)))
+		if err != nil {
+			return nil, nil, err
+		}
+		relationships = append(relationships, graph.Relationship{TargetID: s.App, Supersedence: t})
+	}
+	for _, d := range appSpec.DependsOn {
+		t, err := graph.ParseDependencyType(firstNonEmpty(d.Type, string(graph.DependencyAutoInstall)))
+		if err != nil {
+			return nil, nil, err
+		}
+		relationships = append(relationships, graph.Relationship{TargetID: d.App, Dependency: t})
+	}
+
+	var assignments []graph.Assignment
+	for _, a := range appSpec.Assignments {
+		intent, err := graph.ParseIntent(firstNonEmpty(a.Intent, string(graph.IntentRequired)))
+		if err != nil {
+			return nil, nil, err
+		}
+		assignment := graph.Assignment{GroupID: a.Group, Intent: intent}
+		if a.Deadline != "" {
+			d, err := parseDeadline(a.Deadline)
+			if err != nil {
+				return nil, nil, err
+			}
+			assignment.Deadline = &d
+		}
+		assignments = append(assignments, assignment)
+	}
+	return relationships, assignments, nil
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+var (
+	downloadAppID  string
+	downloadKeys   string
+	downloadOutput string
+)
+
+var appsDownloadCmd = &cobra.Command{
+	Use:   "download",
+	Short: "Download a Win32 app's content and restore its source folder",
+	Long: `Download the committed content of a Win32 app, decrypt it and restore
+the original source folder.
+
+Graph does not return the encryption keys of uploaded content, so the
+Detection.xml of the original package must be supplied with --keys, either
+as the .intunewin package itself or as an extracted Detection.xml. A key
+export saved with --export-keys (.json) is accepted too, decrypted with the
+passphrase in INTUNEWIN_KEYS_PASSPHRASE.
+Intune only exposes content download URIs where the tenant permits it.
+
+Examples:
+  intunewin apps download --id <app-id> --keys ./output/7z2401-x64.intunewin --output ./restored
+  intunewin apps download --id <app-id> --keys ./keys/7z2401-x64.json --output ./restored`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runAppsDownload()
+	},
+}
+
+func init() {
+	appsDownloadCmd.Flags().StringVar(&downloadAppID, "id", "", "ID of the Win32 app")
+	appsDownloadCmd.Flags().StringVar(&downloadKeys, "keys", "", "Original .intunewin package, Detection.xml or key export holding the encryption keys")
+	appsDownloadCmd.Flags().StringVarP(&downloadOutput, "output", "o", "", "Folder to restore the source files into")
+
+	appsCmd.AddCommand(appsDownloadCmd)
+}
+
+func runAppsDownload() error {
+	if downloadAppID == "" {
+		return fmt.Errorf("--id is required")
+	}
+	if downloadKeys == "" {
+		return fmt.Errorf("--keys is required (Graph does not return content encryption keys)")
+	}
+	if downloadOutput == "" {
+		return fmt.Errorf("--output is required")
+	}
+
+	appInfo, err := readDetectionXMLFrom(downloadKeys)
+	if err != nil {
+		return err
+	}
+
+	client, err := newGraphClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	file, err := client.CommittedContentFile(ctx, downloadAppID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Downloading %s (%s)...\n", file.Name, packager.FormatSize(file.SizeEncrypted))
+	encrypted, err := client.DownloadContentFile(ctx, file)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(downloadOutput, 0755); err != nil {
+		return fmt.Errorf("failed to create output folder: %w", err)
+	}
+
+	count, err := packager.RestoreContent(encrypted, appInfo.EncryptionInfo, downloadOutput)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored %d file(s) of %s to %s\n", count, appInfo.Name, downloadOutput)
+	fmt.Printf("Setup file: %s\n", filepath.Join(downloadOutput, appInfo.SetupFile))
+	return nil
+}
+
+// readDetectionXMLFrom reads Detection.xml from a .intunewin package, an extracted Detection.xml
+// file or a key export (.json)
+func readDetectionXMLFrom(path string) (*packager.ApplicationInfo, error) {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		keys, err := readKeyExport(path)
+		if err != nil {
+			return nil, err
+		}
+		return keys.ApplicationInfo(), nil
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".xml") {
+		return packager.ReadDetectionXML(path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Detection.xml: %w", err)
+	}
+	return packager.ParseDetectionXML(data)
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var listFilter string
+
+var appsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List Win32 apps in the tenant",
+	Long: `List Win32 apps in Intune with their version, MSI product code,
+creation date and number of assignments.
+
+Examples:
+  # Check whether 7-Zip is already in the tenant
+  intunewin apps list --filter "7-Zip"`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runAppsList()
+	},
+}
+
+func init() {
+	appsListCmd.Flags().StringVar(&listFilter, "filter", "", "Only show apps whose name contains this text")
+
+	appsCmd.AddCommand(appsListCmd)
+}
+
+func runAppsList() error {
+	client, err := newGraphClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	apps, err := client.ListApps(ctx, listFilter)
+	if err != nil {
+		return err
+	}
+	if len(apps) == 0 {
+		fmt.Println("No Win32 apps found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVERSION\tPRODUCT CODE\tCREATED\tASSIGNMENTS\tID")
+	for _, app := range apps {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n",
+			app.DisplayName,
+			valueOrDash(app.DisplayVersion),
+			valueOrDash(app.ProductCode()),
+			app.CreatedDateTime.Local().Format("2006-01-02"),
+			len(app.Assignments),
+			app.ID,
+		)
+	}
+	return w.Flush()
+}
+
+// valueOrDash returns s, or "-" when s is empty
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
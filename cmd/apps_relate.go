@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/graph"
+)
+
+var (
+	relateAppID            string
+	relateSupersedes       []string
+	relateSupersedenceType string
+	relateDependsOn        []string
+	relateDependencyType   string
+)
+
+var appsRelateCmd = &cobra.Command{
+	Use:   "relate",
+	Short: "Add supersedence and dependency relationships to a Win32 app",
+	Long: `Declare that a Win32 app supersedes older apps or depends on other apps.
+
+Apps can be given by ID or display name. Existing relationships of the app
+are kept.
+
+Examples:
+  # The new 7-Zip release updates the previous one in place
+  intunewin apps relate --app-id <new-id> --supersedes "7-Zip 23.01"
+
+  # Replace (uninstall first) and depend on the VC++ runtime
+  intunewin apps relate --app-id <new-id> --supersedes <old-id> --supersedence-type replace --depends-on "VC++ 2015-2022 x64"`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runAppsRelate()
+	},
+}
+
+func init() {
+	appsRelateCmd.Flags().StringVar(&relateAppID, "app-id", "", "ID of the app that supersedes or depends on others")
+	appsRelateCmd.Flags().StringArrayVar(&relateSupersedes, "supersedes", nil, "App name or ID superseded by this app (repeatable)")
+	appsRelateCmd.Flags().StringVar(&relateSupersedenceType, "supersedence-type", "update", "Supersedence type: update or replace")
+	appsRelateCmd.Flags().StringArrayVar(&relateDependsOn, "depends-on", nil, "App name or ID this app depends on (repeatable)")
+	appsRelateCmd.Flags().StringVar(&relateDependencyType, "dependency-type", "autoInstall", "Dependency type: autoInstall or detect")
+
+	appsCmd.AddCommand(appsRelateCmd)
+}
+
+func runAppsRelate() error {
+	if relateAppID == "" {
+		return invalidInput(fmt.Errorf("--app-id is required"))
+	}
+	if len(relateSupersedes) == 0 && len(relateDependsOn) == 0 {
+		return invalidInput(fmt.Errorf("at least one --supersedes or --depends-on is required"))
+	}
+
+	supersedenceType, err := graph.ParseSupersedenceType(relateSupersedenceType)
+	if err != nil {
+		return invalidInput(err)
+	}
+	dependencyType, err := graph.ParseDependencyType(relateDependencyType)
+	if err != nil {
+		return invalidInput(err)
+	}
+
+	client, err := newGraphClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	var relationships []graph.Relationship
+	for _, app := range relateSupersedes {
+		targetID, err := client.ResolveAppID(ctx, app)
+		if err != nil {
+			return uploadFailed(err)
+		}
+		relationships = append(relationships, graph.Relationship{TargetID: targetID, Supersedence: supersedenceType})
+	}
+	for _, app := range relateDependsOn {
+		targetID, err := client.ResolveAppID(ctx, app)
+		if err != nil {
+			return uploadFailed(err)
+		}
+		relationships = append(relationships, graph.Relationship{TargetID: targetID, Dependency: dependencyType})
+	}
+
+	if err := client.AddRelationships(ctx, relateAppID, relationships); err != nil {
+		return uploadFailed(err)
+	}
+
+	fmt.Printf("Updated relationships of app %s\n", relateAppID)
+	for _, app := range relateSupersedes {
+		fmt.Printf("  supersedes (%s): %s\n", supersedenceType, app)
+	}
+	for _, app := range relateDependsOn {
+		fmt.Printf("  depends on (%s): %s\n", dependencyType, app)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/signing"
+)
+
+// signingKeyEnv holds the signing key of --sign when --sign-key is not given
+const signingKeyEnv = "INTUNEWIN_SIGNING_KEY"
+
+var (
+	writeProvenance bool
+	signMethod      string
+	signKey         string
+)
+
+func init() {
+	rootCmd.Flags().BoolVar(&writeProvenance, "provenance", false, "Write an in-toto SLSA provenance statement of the package to <name>.intunewin"+packager.ProvenanceSuffix)
+	rootCmd.Flags().StringVar(&signMethod, "sign", "", "Sign the provenance statement into <name>.intunewin"+packager.SignatureSuffix+" (implies --provenance): "+strings.Join(signing.Methods, ", "))
+	rootCmd.Flags().StringVar(&signKey, "sign-key", "", "Private key file of --sign (cosign also takes a KMS URI, gpg a key ID; default: "+signingKeyEnv+")")
+}
+
+// provenanceOptions returns the provenance settings of --provenance and --sign, nil when
+// neither is set
+func provenanceOptions() (*packager.ProvenanceOptions, error) {
+	key := firstNonEmpty(signKey, os.Getenv(signingKeyEnv))
+	if !writeProvenance && signMethod == "" {
+		if signKey != "" {
+			return nil, fmt.Errorf("--sign-key requires --sign")
+		}
+		return nil, nil
+	}
+	opts := &packager.ProvenanceOptions{BuilderVersion: version}
+	if signMethod != "" {
+		signer, err := signing.New(signMethod, key)
+		if err != nil {
+			return nil, err
+		}
+		opts.Signer = signer
+	}
+	return opts, nil
+}
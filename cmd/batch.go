@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/metrics"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/spec"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/tui"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/webhook"
+)
+
+var (
+	batchTUI      bool
+	batchParallel int
+)
+
+var batchCmd = &cobra.Command{
+	Use:   "batch <manifest.yaml>",
+	Short: "Build every package listed in a batch manifest",
+	Long: `Build several .intunewin packages in one run from a YAML batch manifest.
+
+The manifest is validated against its schema before anything is packaged
+(see 'validate-spec'). Paths are relative to the manifest file.
+
+Example manifest:
+  output: ./output
+  packages:
+    - source: ./apps/7zip
+      setup: 7z2401-x64.msi
+      license:
+        type: freeware
+    - name: VS Code
+      source: ./apps/vscode
+      setup: VSCodeSetup-x64.exe
+
+With --tui the packages are shown on a full-screen dashboard with their
+progress, and --parallel builds several of them at the same time. Select a
+package and press Enter to read its log.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBatch(args[0])
+	},
+}
+
+func init() {
+	addTimeoutFlag(batchCmd.Flags())
+	addMetricsListenFlag(batchCmd.Flags())
+	batchCmd.Flags().BoolVar(&batchTUI, "tui", false, "Show the packages on a full-screen dashboard with their progress and logs")
+	batchCmd.Flags().IntVar(&batchParallel, "parallel", 1, "Number of packages built at the same time (requires --tui)")
+	batchCmd.Flags().StringVar(&metricsFile, "metrics-file", "", "Write Prometheus metrics of the run to this file when done, e.g. for the node exporter textfile collector")
+	rootCmd.AddCommand(batchCmd)
+}
+
+func runBatch(manifestPath string) error {
+	if batchParallel < 1 {
+		return invalidInput(fmt.Errorf("--parallel must be at least 1"))
+	}
+	if batchParallel > 1 && !batchTUI {
+		return invalidInput(fmt.Errorf("--parallel requires --tui"))
+	}
+
+	manifest, err := spec.LoadBatchManifest(manifestPath)
+	if err != nil {
+		return inputError(err)
+	}
+
+	profile, err := activeProfile()
+	if err != nil {
+		return invalidInput(err)
+	}
+	for i := range manifest.Packages {
+		pkg := &manifest.Packages[i]
+		pkg.Output = firstNonEmpty(pkg.Output, profile.Output)
+		if pkg.Output == "" {
+			return invalidInput(fmt.Errorf("package %s has no output folder (set output on the package, the manifest or the profile)", pkg.Name))
+		}
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	opts, err := packagingOptions(ctx)
+	if err != nil {
+		return inputError(err)
+	}
+	notifier, err := newNotifier()
+	if err != nil {
+		return invalidInput(err)
+	}
+	defer notifier.Close()
+
+	var reg *metrics.Registry
+	if metricsListen != "" || metricsFile != "" {
+		reg = metrics.New()
+	}
+	stopMetrics, err := serveMetrics(reg)
+	if err != nil {
+		return invalidInput(err)
+	}
+	defer stopMetrics()
+
+	var failed int
+	if batchTUI {
+		failed, err = runBatchDashboard(ctx, reg, notifier, manifest.Packages, opts)
+		if err != nil {
+			return err
+		}
+	} else {
+		failed = runBatchSequential(ctx, reg, notifier, manifest.Packages, opts)
+	}
+
+	fmt.Println()
+	fmt.Printf("Built %d of %d package(s)\n", len(manifest.Packages)-failed, len(manifest.Packages))
+	if metricsFile != "" {
+		if err := reg.WriteFile(metricsFile); err != nil {
+			slog.Warn("could not write metrics", "error", err)
+		}
+	}
+	if failed > 0 {
+		return withExitCode(exitPackagingFailed, fmt.Errorf("%d package(s) failed", failed))
+	}
+	return nil
+}
+
+// runBatchSequential builds the packages of a batch one after the other, printing their
+// progress, and returns the number that failed
+func runBatchSequential(ctx context.Context, reg *metrics.Registry, notifier *webhook.Notifier, packages []spec.BatchPackage, opts packager.Options) int {
+	var failed int
+	for i, pkg := range packages {
+		fmt.Printf("[%d/%d] %s\n", i+1, len(packages), pkg.Name)
+
+		result, err := buildBatchPackage(ctx, reg, notifier, pkg, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  Error: %v\n", err)
+			failed++
+			continue
+		}
+		fmt.Printf("  %s (%s)\n", result.OutputPath, packager.FormatSize(result.FinalSize))
+	}
+	return failed
+}
+
+// runBatchDashboard builds the packages of a batch on the TUI dashboard, up to
+// --parallel at a time, prints their results once it is closed and returns the number
+// that failed or were not built
+func runBatchDashboard(ctx context.Context, reg *metrics.Registry, notifier *webhook.Notifier, packages []spec.BatchPackage, opts packager.Options) (int, error) {
+	cfg, _, err := activeConfig()
+	if err != nil {
+		return 0, invalidInput(err)
+	}
+
+	jobs := make([]tui.BatchJob, len(packages))
+	for i, pkg := range packages {
+		jobs[i] = tui.BatchJob{
+			Name: pkg.Name,
+			// The dashboard owns the terminal, so each package logs to its own buffer
+			Run: func(ctx context.Context, progress func(packager.Progress), logs io.Writer) (*packager.PackageResult, error) {
+				pkgOpts := opts
+				logger, err := newLogger(logs)
+				if err != nil {
+					return nil, err
+				}
+				pkgOpts.Logger = logger
+				pkgOpts.OnProgress = progress
+				return buildBatchPackage(ctx, reg, notifier, pkg, pkgOpts)
+			},
+		}
+	}
+
+	results, err := tui.RunDashboard(ctx, jobs, batchParallel, cfg.TUI)
+	var panicErr *tui.PanicError
+	if errors.As(err, &panicErr) {
+		crash(panicErr.Value, panicErr.Stack)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var failed int
+	for i, r := range results {
+		fmt.Printf("[%d/%d] %s\n", i+1, len(results), r.Name)
+		switch {
+		case r.Err != nil:
+			fmt.Fprintf(os.Stderr, "  Error: %v\n", r.Err)
+			failed++
+		case r.Result == nil:
+			fmt.Println("  Not built: the batch was canceled")
+			failed++
+		default:
+			fmt.Printf("  %s (%s)\n", r.Result.OutputPath, packager.FormatSize(r.Result.FinalSize))
+		}
+	}
+	return failed, nil
+}
+
+// buildBatchPackage builds a package of a batch manifest into its output folder
+func buildBatchPackage(ctx context.Context, reg *metrics.Registry, notifier *webhook.Notifier, pkg spec.BatchPackage, opts packager.Options) (*packager.PackageResult, error) {
+	if err := os.MkdirAll(pkg.Output, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// A license in the manifest replaces the one of the --license-* flags for its package
+	if license := specLicense(pkg.License); license != nil {
+		opts.License = license
+	}
+	result, err := packageMeasured(ctx, reg, notifier, pkg.Source, pkg.Setup, pkg.Output, opts, nil)
+	if err != nil {
+		return nil, fmt.Errorf("packaging failed: %w", err)
+	}
+	return result, nil
+}
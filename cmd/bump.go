@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/bundle"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/graph"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+var (
+	bumpAppID      string
+	bumpContent    string
+	bumpSetup      string
+	bumpOutput     string
+	bumpPackage    string
+	bumpVersion    string
+	bumpDetectFile string
+	bumpExclude    []string
+)
+
+var bumpCmd = &cobra.Command{
+	Use:   "bump",
+	Short: "Upload a new version of an existing Win32 app",
+	Long: `Package a new version of an app and upload it as a new content version of
+the existing Win32 app, instead of creating a new app.
+
+The app keeps its name, description, requirements, relationships and
+assignments. Its version metadata is updated from the new package:
+  - the display version, from --version or the MSI product version
+  - the file name and setup file
+  - the setup file name in the install command line
+  - for MSIs, the MSI information, the product code in the uninstall
+    command line and the product code detection rules; other detection
+    rules are kept
+With --detect-file, the detection rules are replaced by a file check.
+
+--app-id takes the ID or the exact display name of the app. Pass an
+already built package with --package instead of --content and --setup.
+Use --what-if to review the requests first.
+
+Examples:
+  intunewin bump --app-id 3f2c9b1e-... -c ./apps/7zip -s 7z2409-x64.msi
+  intunewin bump --app-id "7-Zip" --package ./output/7z2409-x64.intunewin --what-if
+  intunewin bump --app-id "CAD Pro" -c ./apps/cadpro -s setup.exe --version 2025.1 \
+    --detect-file "C:\Program Files\CadPro\2025\cadpro.exe"`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBump()
+	},
+}
+
+func init() {
+	bumpCmd.Flags().StringVar(&bumpAppID, "app-id", "", "ID or display name of the Win32 app to update")
+	bumpCmd.Flags().StringVarP(&bumpContent, "content", "c", "", "Source folder of the new version")
+	bumpCmd.Flags().StringVarP(&bumpSetup, "setup", "s", "", "Setup file name of the new version (e.g., setup.msi)")
+	bumpCmd.Flags().StringVarP(&bumpOutput, "output", "o", "", "Keep the new package in this folder (default: the profile output, else a temporary folder)")
+	bumpCmd.Flags().StringVar(&bumpPackage, "package", "", "Upload this .intunewin instead of packaging --content")
+	bumpCmd.Flags().StringVar(&bumpVersion, "version", "", "Display version of the new version (default: MSI product version)")
+	bumpCmd.Flags().StringVar(&bumpDetectFile, "detect-file", "", "Replace the detection rules with a check for this file (full path)")
+	bumpCmd.Flags().StringArrayVar(&bumpExclude, "exclude", nil, "Glob pattern of files or folders to leave out of the package (repeatable)")
+	addGraphFlags(bumpCmd.Flags())
+	addTimeoutFlag(bumpCmd.Flags())
+	rootCmd.AddCommand(bumpCmd)
+}
+
+func runBump() error {
+	if bumpAppID == "" {
+		return invalidInput(fmt.Errorf("--app-id is required"))
+	}
+	if (bumpPackage == "") == (bumpContent == "") {
+		return invalidInput(fmt.Errorf("either --content and --setup, or --package, is required"))
+	}
+	if bumpContent != "" && bumpSetup == "" {
+		return invalidInput(fmt.Errorf("--setup (-s) is required with --content"))
+	}
+	if bumpContent != "" && bumpVersion == "" && !strings.EqualFold(filepath.Ext(bumpSetup), ".msi") {
+		return invalidInput(fmt.Errorf("%s is not an MSI: pass its version with --version", bumpSetup))
+	}
+
+	client, err := newGraphClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	// The app is checked before packaging, which may take a while
+	appID, err := client.ResolveAppID(ctx, bumpAppID)
+	if err != nil {
+		return uploadFailed(err)
+	}
+	current, err := client.GetWin32App(ctx, appID)
+	if err != nil {
+		return uploadFailed(err)
+	}
+
+	packagePath := bumpPackage
+	if packagePath == "" {
+		var cleanup func()
+		if packagePath, cleanup, err = packageBump(); err != nil {
+			return err
+		}
+		defer cleanup()
+	}
+
+	b, err := bundle.OpenPackage(packagePath)
+	if err != nil {
+		return inputError(err)
+	}
+	defer b.Close()
+	appInfo, err := packager.ParseDetectionXML(b.DetectionXML)
+	if err != nil {
+		return inputError(err)
+	}
+	update, notes, err := bumpAppVersion(current, appInfo, filepath.Base(packagePath), bumpVersion, bumpDetectFile)
+	if err != nil {
+		return invalidInput(err)
+	}
+
+	fmt.Printf("Updating %q (%s) from version %s to %s\n", current.DisplayName, appID, valueOrDash(current.DisplayVersion), update.DisplayVersion)
+	if update.DisplayVersion == current.DisplayVersion {
+		fmt.Println("  Warning: the app already has this version")
+	}
+
+	content, err := b.Content()
+	if err != nil {
+		return inputError(err)
+	}
+	defer content.Close()
+	upload, err := b.Upload(content)
+	if err != nil {
+		return inputError(err)
+	}
+	if err := client.UploadContent(ctx, appID, upload); err != nil {
+		return uploadFailed(err)
+	}
+	fmt.Printf("  Uploaded %s of content as a new content version\n", packager.FormatSize(upload.SizeEncrypted))
+
+	if err := client.UpdateAppVersion(ctx, appID, update); err != nil {
+		return uploadFailed(fmt.Errorf("content uploaded, but %w", err))
+	}
+	fmt.Println("  Updated version, setup file and command lines")
+	for _, note := range notes {
+		fmt.Printf("  %s\n", note)
+	}
+
+	assignments, err := client.ListAssignments(ctx, appID)
+	if err != nil {
+		return uploadFailed(err)
+	}
+	fmt.Printf("  Kept %d assignment(s)\n", len(assignments))
+	return nil
+}
+
+// packageBump packages the new version of bump and returns the package path
+// The returned function removes the package when it was written to a temporary folder
+func packageBump() (string, func(), error) {
+	profile, err := activeProfile()
+	if err != nil {
+		return "", nil, invalidInput(err)
+	}
+	opts := packager.Options{Exclude: profile.Exclude, ToolVersion: profile.ToolVersion}
+	if len(bumpExclude) > 0 {
+		opts.Exclude = bumpExclude
+	}
+	if err := packager.ValidateExcludePatterns(opts.Exclude); err != nil {
+		return "", nil, invalidInput(err)
+	}
+
+	output, cleanup := firstNonEmpty(bumpOutput, profile.Output), func() {}
+	if output == "" {
+		if output, err = os.MkdirTemp("", "intunewin-bump-"); err != nil {
+			return "", nil, fmt.Errorf("failed to create temporary folder: %w", err)
+		}
+		cleanup = func() { os.RemoveAll(output) }
+	}
+
+	fmt.Printf("Packaging %s...\n", filepath.Join(bumpContent, bumpSetup))
+	result, err := packager.PackageWithOptions(bumpContent, bumpSetup, output, opts, nil)
+	if err != nil {
+		cleanup()
+		return "", nil, inputError(err)
+	}
+	fmt.Printf("  %s (%d files, %s)\n", result.OutputPath, result.FileCount, packager.FormatSize(result.FinalSize))
+	return result.OutputPath, cleanup, nil
+}
+
+// bumpAppVersion returns the version metadata of an app for the package of appInfo, with
+// notes on what was kept; version overrides the MSI product version, and detectFile
+// replaces the detection rules when set
+func bumpAppVersion(current *graph.Win32App, appInfo *packager.ApplicationInfo, fileName, version, detectFile string) (graph.AppVersion, []string, error) {
+	update := graph.AppVersion{FileName: fileName, SetupFile: appInfo.SetupFile}
+	msi := appInfo.MsiInfo
+	if msi != nil && msi.MsiProductCode == "" {
+		msi = nil
+	}
+	if msi != nil {
+		update.DisplayVersion = msi.MsiProductVersion
+	}
+	update.DisplayVersion = firstNonEmpty(version, update.DisplayVersion)
+	if update.DisplayVersion == "" {
+		return update, nil, fmt.Errorf("the new package is not an MSI: pass its version with --version")
+	}
+
+	if current.SetupFile != "" && current.SetupFile != appInfo.SetupFile {
+		update.InstallCommandLine = strings.ReplaceAll(current.InstallCommandLine, current.SetupFile, appInfo.SetupFile)
+	}
+	var notes []string
+	if msi != nil {
+		update.Msi = &graph.MsiInformation{ProductCode: msi.MsiProductCode, ProductVersion: msi.MsiProductVersion, UpgradeCode: msi.MsiUpgradeCode}
+		if current.MsiProductCode != "" && current.MsiProductCode != msi.MsiProductCode {
+			update.UninstallCommandLine = strings.ReplaceAll(current.UninstallCommandLine, current.MsiProductCode, msi.MsiProductCode)
+		}
+		if current.MsiUpgradeCode != "" && msi.MsiUpgradeCode != "" && !strings.EqualFold(current.MsiUpgradeCode, msi.MsiUpgradeCode) {
+			notes = append(notes, fmt.Sprintf("Warning: the upgrade code changed from %s to %s; is this the same product?", current.MsiUpgradeCode, msi.MsiUpgradeCode))
+		}
+	}
+
+	switch {
+	case detectFile != "":
+		rule, err := graph.FileDetection(detectFile)
+		if err != nil {
+			return update, nil, err
+		}
+		update.DetectionRules = []map[string]any{rule}
+		notes = append(notes, "Replaced the detection rules with a check for "+detectFile)
+	case msi != nil:
+		rules, ok := graph.BumpProductCodeRules(current.DetectionRules, msi.MsiProductCode, msi.MsiProductVersion)
+		if ok {
+			update.DetectionRules = rules
+			notes = append(notes, "Updated the product code detection rules to "+msi.MsiProductCode)
+		} else {
+			notes = append(notes, "Kept the detection rules: none detects an MSI product code")
+		}
+	default:
+		notes = append(notes, "Kept the detection rules; pass --detect-file if they check the previous version")
+	}
+	return update, notes, nil
+}
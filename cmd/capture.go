@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/capture"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+var (
+	captureOutput       string
+	captureName         string
+	captureExclude      []string
+	captureExcludeNames []string
+	captureKeys         []string
+)
+
+var captureCmd = &cobra.Command{
+	Use:   "capture <folder> [-- <installer> [args...]]",
+	Short: "Repackage an install by capturing the changes it makes (Windows only)",
+	Long: `Snapshot Program Files, ProgramData, the public desktop and HKLM\SOFTWARE
+and services, run an installer, snapshot again and write the difference to
+<folder> as a source folder that installs it again:
+
+  Files\<location>\   the added and modified files of each location
+  registry.reg        the added and changed registry values
+  install.ps1         copies the files and imports registry.reg
+  uninstall.ps1       removes the added files, folders, keys and values
+  capture.json        every change found, including deletions
+
+Pass the installer after --; without one, install the app by hand and
+press Enter when done. Run on a clean machine or VM and close other
+programs: everything that changes while capturing is captured. Review
+capture.json and the scripts before deploying, and leave out noise with
+--exclude. Changes below user profiles are not captured.
+
+With --output, the source folder is packaged with install.ps1 as setup
+file. Install it with:
+  powershell.exe -NoProfile -ExecutionPolicy Bypass -File install.ps1
+
+Examples:
+  intunewin capture ./captured/legacyapp -- C:\media\setup.exe
+  intunewin capture ./captured/legacyapp -o ./output --name "Legacy App"
+  intunewin capture ./captured/cad --exclude "C:\ProgramData\CadPro\Logs" -- setup.exe /S`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var installer []string
+		if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+			installer = args[dash:]
+			args = args[:dash]
+		}
+		if len(args) != 1 {
+			return invalidInput(fmt.Errorf("capture takes one folder, and the installer after --"))
+		}
+		return runCapture(args[0], installer)
+	},
+}
+
+func init() {
+	captureCmd.Flags().StringVarP(&captureOutput, "output", "o", "", "Package the captured source folder into this folder")
+	captureCmd.Flags().StringVar(&captureName, "name", "", "App name used in the generated scripts (default: the folder name)")
+	captureCmd.Flags().StringArrayVar(&captureExclude, "exclude", nil, "Folder or registry key left out, with everything below it (repeatable)")
+	captureCmd.Flags().StringArrayVar(&captureExcludeNames, "exclude-name", nil, "Glob pattern of file names left out, e.g. *.bak (repeatable)")
+	captureCmd.Flags().StringArrayVar(&captureKeys, "registry-key", nil, "Additional registry key to capture, e.g. HKLM\\SYSTEM\\CurrentControlSet\\Control\\Print (repeatable)")
+	rootCmd.AddCommand(captureCmd)
+}
+
+func runCapture(dir string, installer []string) error {
+	// Fail before the install, not after it
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return invalidInput(fmt.Errorf("%s is not empty", dir))
+	}
+	name := captureName
+	if name == "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return invalidInput(err)
+		}
+		name = filepath.Base(abs)
+	}
+
+	opts := capture.DefaultOptions()
+	opts.RegistryKeys = append(opts.RegistryKeys, captureKeys...)
+	opts.Exclude = append(opts.Exclude, captureExclude...)
+	opts.ExcludeNames = append(opts.ExcludeNames, captureExcludeNames...)
+	// The source folder must not capture itself
+	if abs, err := filepath.Abs(dir); err == nil {
+		opts.Exclude = append(opts.Exclude, abs)
+	}
+
+	fmt.Println("Taking snapshot before install...")
+	before, err := capture.TakeSnapshot(opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("  %d files, %d registry values\n", len(before.Files), len(before.Values))
+
+	if err := runCaptureInstaller(installer); err != nil {
+		return err
+	}
+
+	fmt.Println("Taking snapshot after install...")
+	after, err := capture.TakeSnapshot(opts)
+	if err != nil {
+		return err
+	}
+	changes := capture.Diff(before, after)
+	if changes.Empty() {
+		return fmt.Errorf("no changes found; was the app installed while capturing?")
+	}
+	if after.Skipped > 0 {
+		fmt.Printf("  %d folders or keys could not be read (run as administrator to capture them)\n", after.Skipped)
+	}
+
+	src, err := capture.WriteSource(dir, name, changes, opts.Folders)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nCaptured %s to %s\n", name, src.Dir)
+	fmt.Printf("  Files:      %d (%s)\n", src.Files, packager.FormatSize(src.Size))
+	fmt.Printf("  Registry:   %d keys, %d values\n", len(changes.AddedKeys), src.Values)
+	if n := len(changes.DeletedFiles) + len(changes.DeletedValues); n > 0 {
+		fmt.Printf("  Deleted:    %d files and registry values (listed in %s, not repeated)\n", n, capture.ReportFile)
+	}
+	if len(src.Skipped) > 0 {
+		fmt.Printf("  Skipped:    %d files outside the captured folders or unreadable (listed in %s)\n", len(src.Skipped), capture.ReportFile)
+	}
+	fmt.Printf("  Install:    powershell.exe -NoProfile -ExecutionPolicy Bypass -File %s\n", capture.InstallScript)
+	fmt.Printf("  Uninstall:  powershell.exe -NoProfile -ExecutionPolicy Bypass -File %s\n", capture.UninstallScript)
+
+	if captureOutput == "" {
+		return nil
+	}
+	result, err := packager.Package(dir, capture.InstallScript, captureOutput, nil)
+	if err != nil {
+		return err
+	}
+	printPackageResult(result)
+	return nil
+}
+
+// runCaptureInstaller runs the installer and waits for it, or waits for the app to be
+// installed by hand
+func runCaptureInstaller(installer []string) error {
+	if len(installer) == 0 {
+		fmt.Print("\nInstall the app now, then press Enter to capture the changes...")
+		if _, err := bufio.NewReader(os.Stdin).ReadString('\n'); err != nil {
+			return fmt.Errorf("capture canceled: %w", err)
+		}
+		return nil
+	}
+
+	fmt.Printf("\nRunning %s...\n", installer[0])
+	run := exec.Command(installer[0], installer[1:]...)
+	run.Stdout, run.Stderr = os.Stdout, os.Stderr
+	err := run.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		// Installers that need a reboot, or fail, may still have made changes worth reviewing
+		fmt.Printf("  Installer exited with code %d; capturing anyway\n", exitErr.ExitCode())
+	case err != nil:
+		return invalidInput(fmt.Errorf("failed to run installer: %w", err))
+	}
+	return nil
+}
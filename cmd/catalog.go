@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/catalog"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/listing"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+var catalogListFlags listingFlags
+
+var catalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "Browse a published package catalog",
+	Long:  `Commands for browsing a package repository created with 'intunewin publish'.`,
+}
+
+var catalogListCmd = &cobra.Command{
+	Use:   "list <repository>",
+	Short: "List and search the packages of a catalog",
+	Long: `List the packages in the index of a repository created with 'intunewin publish',
+every published version included.
+
+The repository is given like the destination of 'intunewin publish': an
+Azure Blob container URL with a SAS token (read), an azblob:// URL for the
+managed identity, an s3:// URL, or a folder such as an SMB share. The SAS
+token can also be passed in the ` + sasTokenEnv + ` environment variable.
+
+--filter takes free text, matched against the package name, app, version and
+vendor, and field conditions:
+  vendor:Adobe           vendor contains "Adobe"
+  app:"Acrobat Reader"   quoted values may contain spaces
+  version:24             version 24, 24.1, 24.002.20759, ...
+  version:>=24.1         also >, <, <= and =
+  date:>2024-05-01       published after that day
+  size:<100MB            also KB and GB
+  license:LIC-0042       license type, ID, seats or EULA acceptance contains "LIC-0042"
+
+Text is matched ignoring case and accents, and sorted by the rules of the
+locale (--locale, or LANG), so "Zoë" is found by "zoe".
+
+Examples:
+  intunewin catalog list \\fileserver\intune\catalog --filter "vendor:Adobe version:>24"
+  intunewin catalog list \\fileserver\intune\catalog --sort date --desc --page-size 20
+  intunewin catalog list https://contoso.blob.core.windows.net/catalog --filter reader --page 2`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCatalogList(args[0])
+	},
+}
+
+func init() {
+	catalogListFlags.register(catalogListCmd, "name", false, "name, app, version, vendor, date, size, license")
+
+	catalogCmd.AddCommand(catalogListCmd)
+	rootCmd.AddCommand(catalogCmd)
+}
+
+// catalogFields are the fields of catalog entries for --filter and --sort
+var catalogFields = []listing.Field[catalog.Entry]{
+	listing.TextField("name", func(e catalog.Entry) string { return e.Name }),
+	listing.TextField("app", func(e catalog.Entry) string { return e.App }),
+	listing.VersionField("version", func(e catalog.Entry) string { return e.Version }),
+	listing.TextField("vendor", func(e catalog.Entry) string { return e.Publisher }),
+	listing.DateField("date", func(e catalog.Entry) time.Time { return e.Published }),
+	listing.SizeField("size", func(e catalog.Entry) int64 { return e.Size }),
+	listing.TextField("license", func(e catalog.Entry) string { return e.License.String() }),
+}
+
+func runCatalogList(dest string) error {
+	store, err := openRepository(dest)
+	if err != nil {
+		return err
+	}
+	index, _, err := catalog.ReadIndex(context.Background(), store)
+	if err != nil {
+		return err
+	}
+
+	entries, total, pages, err := applyListing(&catalogListFlags, index.Packages, catalogFields...)
+	if err != nil {
+		return err
+	}
+	if total == 0 {
+		fmt.Println("No packages found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tAPP\tVERSION\tVENDOR\tSIZE\tPUBLISHED\tLICENSE")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.Name,
+			valueOrDash(e.App),
+			valueOrDash(e.Version),
+			valueOrDash(e.Publisher),
+			packager.FormatSize(e.Size),
+			e.Published.Local().Format("2006-01-02 15:04"),
+			valueOrDash(e.License.String()),
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	printPageFooter(&catalogListFlags, total, pages)
+	return nil
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/config"
+)
+
+var (
+	// Config file flags
+	configPath  string
+	profileName string
+
+	// loadedProfile caches the profile selected for this run
+	loadedProfile *config.Profile
+	// loadedConfig caches the config file the profile was read from
+	loadedConfig     *config.Config
+	loadedConfigPath string
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Config file (default: ./"+config.ProjectFileName+" or ~/.config/intunewin/config.yaml)")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "Config profile to use (env: INTUNEWIN_PROFILE)")
+}
+
+// activeProfile returns the config profile selected by --profile, INTUNEWIN_PROFILE
+// or the config's default profile
+// Settings are applied with the precedence: flags, environment, profile, built-in defaults
+func activeProfile() (*config.Profile, error) {
+	if loadedProfile != nil {
+		return loadedProfile, nil
+	}
+
+	cfg, _, err := activeConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := cfg.Profile(selectedProfileName())
+	if err != nil {
+		return nil, err
+	}
+	loadedProfile = profile
+	return profile, nil
+}
+
+// activeConfig returns the config file selected by --config, or found by config.FindPath
+func activeConfig() (*config.Config, string, error) {
+	if loadedConfig != nil {
+		return loadedConfig, loadedConfigPath, nil
+	}
+
+	path := configPath
+	if path == "" {
+		var err error
+		path, err = config.FindPath()
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, "", err
+	}
+	loadedConfig, loadedConfigPath = cfg, path
+	return cfg, path, nil
+}
+
+// selectedProfileName returns the profile named by --profile or INTUNEWIN_PROFILE, if any
+func selectedProfileName() string {
+	return firstNonEmpty(profileName, os.Getenv("INTUNEWIN_PROFILE"))
+}
+
+// applyProfileFlags sets the flags of the active profile that were not given on the
+// command line, so a profile can bundle flags such as --low-memory for a whole team
+// Flags of other commands are skipped; names no command knows are an error
+func applyProfileFlags(cmd *cobra.Command) error {
+	profile, err := activeProfile()
+	if err != nil {
+		return err
+	}
+	values, err := profile.FlagValues()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == "config" || name == "profile" {
+			return fmt.Errorf("profile flag --%s cannot be set by a profile", name)
+		}
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil {
+			if !anyCommandHasFlag(cmd.Root(), name) {
+				return fmt.Errorf("unknown flag in profile: --%s", name)
+			}
+			continue
+		}
+		if flag.Changed {
+			continue
+		}
+		// Setting the value directly keeps Changed false: the flag still counts as not given
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			err = slice.Replace(values[name])
+		} else if len(values[name]) != 1 {
+			err = fmt.Errorf("takes a single value")
+		} else {
+			err = flag.Value.Set(values[name][0])
+		}
+		if err != nil {
+			return fmt.Errorf("invalid value of --%s in profile: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// anyCommandHasFlag reports whether cmd or one of its subcommands defines a flag
+func anyCommandHasFlag(cmd *cobra.Command, name string) bool {
+	if cmd.Flags().Lookup(name) != nil || cmd.PersistentFlags().Lookup(name) != nil {
+		return true
+	}
+	for _, sub := range cmd.Commands() {
+		if anyCommandHasFlag(sub, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/config"
+)
+
+// crashExitCode is the exit code after a panic (EX_SOFTWARE of sysexits.h), distinct
+// from the exit codes of failed commands
+const crashExitCode = 70
+
+// secretFlags are flags whose values are left out of crash reports
+var secretFlags = []string{"secret", "seed", "token", "sas", "password", "webhook"}
+
+// recoverCrash turns a panic in a command into a crash report and exits
+// Deferred at the start of Execute, so it covers every command running on the main goroutine
+func recoverCrash() {
+	if r := recover(); r != nil {
+		crash(r, debug.Stack())
+	}
+}
+
+// crash writes a crash report for a panic to the config folder, points the user to it
+// and exits; the stack is printed instead when the report cannot be written or in read-only mode
+func crash(value any, stack []byte) {
+	if logFileHandle != nil {
+		logFileHandle.Sync()
+	}
+	report := config.CrashReport{
+		Version: fmt.Sprintf("%s (built %s)", version, buildTime),
+		Time:    time.Now(),
+		Args:    redactArgs(os.Args),
+		Panic:   fmt.Sprint(value),
+		Stack:   stack,
+	}
+
+	fmt.Fprintf(os.Stderr, "\nintunewin crashed unexpectedly: %v\n", value)
+	if readOnly {
+		fmt.Fprintf(os.Stderr, "No crash report is written in read-only mode.\n\n%s", stack)
+		os.Exit(crashExitCode)
+	}
+	dir, err := config.DefaultCrashDir()
+	if err == nil {
+		var path string
+		if path, err = config.WriteCrashReport(dir, report); err == nil {
+			fmt.Fprintf(os.Stderr, "A crash report was written to %s\n", path)
+			fmt.Fprintln(os.Stderr, "Please attach it when reporting the issue.")
+			os.Exit(crashExitCode)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Could not write a crash report: %v\n\n%s", err, stack)
+	os.Exit(crashExitCode)
+}
+
+// redactArgs returns the command line with the values of secretFlags masked
+func redactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	copy(redacted, args)
+	for i := 1; i < len(redacted); i++ {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(redacted[i], "-"), "=")
+		if !strings.HasPrefix(redacted[i], "--") || !isSecretFlag(name) {
+			continue
+		}
+		if hasValue {
+			redacted[i] = "--" + name + "=***"
+		} else if i+1 < len(redacted) {
+			i++
+			redacted[i] = "***"
+		}
+	}
+	return redacted
+}
+
+// isSecretFlag reports whether a flag name carries a secret
+func isSecretFlag(name string) bool {
+	for _, s := range secretFlags {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+var (
+	diffFormat string
+	diffOutput string
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <old.intunewin> <new.intunewin>",
+	Short: "Compare the content of two .intunewin packages",
+	Long: `Decrypt two .intunewin packages with the keys from their Detection.xml and
+report the files that were added, removed and changed between them, with
+sizes and the file versions of executables, libraries and MSIs.
+
+Use it to check that only the intended files changed between two builds of
+an app. Files are matched by path, case-insensitively, and compared by
+SHA-256 of their content, so repackaging the same files shows no changes
+even though the encrypted packages always differ.
+
+Formats:
+  text      aligned table for the terminal (default)
+  markdown  tables for a release report or change request
+  json      machine-readable report
+
+Examples:
+  intunewin diff ./output/7z2301-x64.intunewin ./output/7z2401-x64.intunewin
+  intunewin diff old.intunewin new.intunewin --format markdown -o changes.md`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDiff(args[0], args[1])
+	},
+}
+
+func init() {
+	diffCmd.Flags().StringVar(&diffFormat, "format", "text", "Output format: text, markdown or json")
+	diffCmd.Flags().StringVarP(&diffOutput, "output", "o", "", "Write the report to this file instead of stdout")
+	rootCmd.AddCommand(diffCmd)
+}
+
+func runDiff(oldPackage, newPackage string) error {
+	if err := checkFootprintFormat(diffFormat); err != nil {
+		return err
+	}
+
+	oldFiles, err := packager.PackageFootprint(oldPackage)
+	if err != nil {
+		return err
+	}
+	newFiles, err := packager.PackageFootprint(newPackage)
+	if err != nil {
+		return err
+	}
+
+	report := footprintReport{title: "Package content changes", oldName: oldPackage, newName: newPackage}
+	return report.write(packager.CompareFootprints(oldFiles, newFiles), diffFormat, diffOutput)
+}
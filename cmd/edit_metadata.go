@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+var (
+	editName        string
+	editSetupFile   string
+	editToolVersion string
+	editOutput      string
+	editInPlace     bool
+)
+
+var editMetadataCmd = &cobra.Command{
+	Use:   "edit-metadata <package.intunewin>",
+	Short: "Change the Detection.xml metadata of a package without repackaging",
+	Long: `Change the app name, setup file or ToolVersion recorded in the Detection.xml
+of a .intunewin package. The encrypted content is copied unchanged, so the
+package keys and digest stay valid and nothing is recompressed.
+
+The edited package is written to a new file by default: --output, or
+<name>.edited.intunewin next to the package. When the package folder is
+read-only (a DVD, a read-only share or snapshot) the new file is written to
+the current folder instead.
+
+--in-place replaces the package itself, after copying it to
+<package>.<time>.bak. It is refused when the package is read-only or locked
+by another process, such as a OneDrive or Dropbox sync client; close the
+client or pause syncing and try again, or write a new file instead.
+
+Examples:
+  intunewin edit-metadata ./output/setup.intunewin --name "Contoso App"
+  intunewin edit-metadata ./output/setup.intunewin --setup-file install.cmd --output ./fixed/setup.intunewin
+  intunewin edit-metadata ./output/setup.intunewin --tool-version 1.8.4.0 --in-place`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runEditMetadata(args[0])
+	},
+}
+
+func init() {
+	editMetadataCmd.Flags().StringVar(&editName, "name", "", "New application name")
+	editMetadataCmd.Flags().StringVar(&editSetupFile, "setup-file", "", "New setup file name")
+	editMetadataCmd.Flags().StringVar(&editToolVersion, "tool-version", "", "New ToolVersion attribute")
+	editMetadataCmd.Flags().StringVarP(&editOutput, "output", "o", "", "Edited package to write (default: <name>.edited.intunewin next to the package)")
+	editMetadataCmd.Flags().BoolVar(&editInPlace, "in-place", false, "Replace the package itself, after taking a backup copy")
+	rootCmd.AddCommand(editMetadataCmd)
+}
+
+func runEditMetadata(packagePath string) error {
+	edit := packager.MetadataEdit{
+		Name:        editName,
+		SetupFile:   editSetupFile,
+		ToolVersion: editToolVersion,
+	}
+	if edit.Empty() {
+		return fmt.Errorf("nothing to change (use --name, --setup-file or --tool-version)")
+	}
+	if editInPlace && editOutput != "" {
+		return fmt.Errorf("--in-place cannot be combined with --output")
+	}
+	// Reading the metadata first rejects files that are not packages before anything is written
+	if _, err := packager.ReadDetectionXML(packagePath); err != nil {
+		return err
+	}
+
+	output, err := editOutputPath(packagePath)
+	if err != nil {
+		return err
+	}
+
+	if editInPlace {
+		backup, err := packager.BackupPackage(packagePath, time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Backed up %s to %s\n", packagePath, backup)
+	}
+
+	if err := packager.EditMetadata(packagePath, output, edit); err != nil {
+		return err
+	}
+	fmt.Printf("Edited metadata written to %s\n", output)
+
+	// Sidecar files describe the package bytes, which changed
+	if _, err := os.Stat(packager.ManifestPath(packagePath)); err == nil {
+		fmt.Printf("  Note: %s lists the hash of the unedited package\n", packager.ManifestPath(packagePath))
+	}
+	return nil
+}
+
+// editOutputPath returns where the edited package is written, checking that an
+// in-place edit can modify the package
+func editOutputPath(packagePath string) (string, error) {
+	if editInPlace {
+		if err := packager.CheckModifiable(packagePath); err != nil {
+			switch {
+			case errors.Is(err, packager.ErrLocked):
+				return "", fmt.Errorf("cannot edit in place: %w (close or pause the program holding it, or omit --in-place to write a new file)", err)
+			case errors.Is(err, packager.ErrReadOnly):
+				return "", fmt.Errorf("cannot edit in place: %w (omit --in-place to write a new file)", err)
+			}
+			return "", fmt.Errorf("cannot edit in place: %w", err)
+		}
+		return packagePath, nil
+	}
+
+	if editOutput != "" {
+		if samePath(editOutput, packagePath) {
+			return "", fmt.Errorf("--output is the package itself, use --in-place to replace it")
+		}
+		return editOutput, nil
+	}
+
+	output := packager.EditedPackagePath(packagePath, filepath.Dir(packagePath))
+	if err := packager.CheckFolderWritable(filepath.Dir(packagePath)); err != nil {
+		if !errors.Is(err, packager.ErrReadOnly) {
+			return "", err
+		}
+		output = packager.EditedPackagePath(packagePath, ".")
+		fmt.Println("Package folder is read-only, writing to the current folder")
+	}
+	if _, err := os.Stat(output); err == nil {
+		return "", fmt.Errorf("%s already exists, choose another file with --output", output)
+	}
+	return output, nil
+}
+
+// samePath reports whether two paths name the same file
+func samePath(a, b string) bool {
+	aInfo, errA := os.Stat(a)
+	bInfo, errB := os.Stat(b)
+	if errA == nil && errB == nil {
+		return os.SameFile(aInfo, bInfo)
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"errors"
+	"io/fs"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// Exit codes of failed commands, so pipelines can branch on the kind of failure
+// Keep the Exit Codes table of the README in sync
+const (
+	exitFailure            = 1 // any other error
+	exitInvalidInput       = 2 // unknown command or flag, invalid arguments, flags, specs or config
+	exitSourceMissing      = 3 // source folder, setup file, package or spec not found
+	exitPackagingFailed    = 4 // packaging, extraction or rewriting a package failed
+	exitUploadFailed       = 5 // a request to Intune or a catalog repository failed
+	exitVerificationFailed = 6 // package damaged, diverging or not written back as created
+	exitTimedOut           = 7 // the command did not finish within --timeout
+)
+
+// commandStarted is set once flags, arguments, the profile and read-only mode were
+// accepted; cobra errors before that are invalid input
+var commandStarted bool
+
+// exitError carries the exit code of a command error
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+
+func (e *exitError) Unwrap() error { return e.err }
+
+// withExitCode attaches an exit code to err, keeping nil errors nil
+// A code attached before, deeper in the error chain, takes precedence
+func withExitCode(code int, err error) error {
+	var existing *exitError
+	if err == nil || errors.As(err, &existing) {
+		return err
+	}
+	return &exitError{code: code, err: err}
+}
+
+// invalidInput marks err as an invalid argument, flag, spec or config
+func invalidInput(err error) error {
+	return withExitCode(exitInvalidInput, err)
+}
+
+// inputError marks err of reading an input file: a missing file is a missing source,
+// anything else invalid input
+func inputError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return withExitCode(exitSourceMissing, err)
+	}
+	return invalidInput(err)
+}
+
+// uploadFailed marks err of a request to Intune or a catalog repository
+func uploadFailed(err error) error {
+	return withExitCode(exitUploadFailed, err)
+}
+
+// exitCode returns the process exit code for an error returned by a command
+func exitCode(err error) int {
+	if timedOut {
+		return exitTimedOut
+	}
+	var e *exitError
+	if errors.As(err, &e) {
+		return e.code
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return exitSourceMissing
+	}
+	if !commandStarted {
+		return exitInvalidInput
+	}
+	return exitFailure
+}
+
+// packagingFailed marks err of packaging; a package that does not read back as written
+// is a verification failure
+func packagingFailed(err error) error {
+	if errors.Is(err, packager.ErrOutputMismatch) {
+		return withExitCode(exitVerificationFailed, err)
+	}
+	return withExitCode(exitPackagingFailed, err)
+}
+
+// verificationFailed marks err of checking a package; a package that does not exist is a
+// missing source
+func verificationFailed(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return withExitCode(exitSourceMissing, err)
+	}
+	return withExitCode(exitVerificationFailed, err)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+var explainFormatOutput string
+
+var explainFormatCmd = &cobra.Command{
+	Use:   "explain-format",
+	Short: "Describe the bytes and structures of generated packages",
+	Long: `Describe exactly what the package generator produces: the content ZIP, the
+encryption layout, Detection.xml and the outer package ZIP.
+
+The generator version changes whenever the package generation logic does,
+independently of the application version. Manifests, checkpoints, the packaging
+history and webhook events record it, so a given archive can be traced back to
+the logic that built it.
+
+Examples:
+  intunewin explain-format
+  intunewin explain-format --format json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runExplainFormat()
+	},
+}
+
+func init() {
+	explainFormatCmd.Flags().StringVar(&explainFormatOutput, "format", "text", "Output format: text or json (generator versions only)")
+	rootCmd.AddCommand(explainFormatCmd)
+}
+
+func runExplainFormat() error {
+	switch explainFormatOutput {
+	case "text":
+		return packager.ExplainFormat(os.Stdout)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			GeneratorVersion int                     `json:"generatorVersion"`
+			History          []packager.FormatChange `json:"history"`
+		}{packager.GeneratorVersion, packager.FormatHistory})
+	default:
+		return fmt.Errorf("invalid format: %s (supported: text, json)", explainFormatOutput)
+	}
+}
@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/graph"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/spec"
+)
+
+var (
+	exportAppID   string
+	exportOutput  string
+	exportPackage string
+)
+
+var exportAppCmd = &cobra.Command{
+	Use:   "export-app",
+	Short: "Export a Win32 app from Intune as a portable app spec",
+	Long: `Export the definition of a Win32 app as a YAML app spec that import-app
+(or 'apps create --spec') recreates in another tenant.
+
+The spec holds the app metadata, install and uninstall commands, detection
+rules, requirements, return codes and assignments. Groups are recorded by
+display name rather than object ID, so assignments resolve to the groups of
+the same name in the target tenant. Assignments to all users, all devices
+and exclusion groups are not exported.
+
+The spec's package is the app's .intunewin file name next to the spec;
+place the corresponding package there, or point to it with --package.
+Supersedence and dependencies are not exported, as they refer to other
+apps of the source tenant.
+
+Examples:
+  intunewin export-app --app-id <id> --output ./migration/7zip.yaml
+  intunewin export-app --app-id <id> --output ./migration/7zip.yaml --package ./output/7z2401-x64.intunewin`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runExportApp()
+	},
+}
+
+func init() {
+	exportAppCmd.Flags().StringVar(&exportAppID, "app-id", "", "ID of the Win32 app to export")
+	exportAppCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Spec file to write (default: <app name>.yaml)")
+	exportAppCmd.Flags().StringVar(&exportPackage, "package", "", "Path of the app's .intunewin package to reference from the spec")
+	addGraphFlags(exportAppCmd.Flags())
+	addTimeoutFlag(exportAppCmd.Flags())
+
+	rootCmd.AddCommand(exportAppCmd)
+}
+
+func runExportApp() error {
+	if exportAppID == "" {
+		return fmt.Errorf("--app-id is required")
+	}
+
+	client, err := newGraphClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	app, err := client.GetWin32App(ctx, exportAppID)
+	if err != nil {
+		return err
+	}
+	assignments, err := client.ListAssignments(ctx, exportAppID)
+	if err != nil {
+		return err
+	}
+
+	output := exportOutput
+	if output == "" {
+		output = safeFileName(app.DisplayName) + ".yaml"
+	}
+	appSpec := exportedSpec(app)
+
+	// The package path is written relative to the spec
+	appSpec.Package = app.FileName
+	if exportPackage != "" {
+		rel, err := relativeTo(filepath.Dir(output), exportPackage)
+		if err != nil {
+			return err
+		}
+		appSpec.Package = rel
+	}
+	if appSpec.Package == "" {
+		return fmt.Errorf("app %s has no package file name, use --package", exportAppID)
+	}
+
+	var skipped []string
+	for _, a := range assignments {
+		if !a.IsGroup() {
+			skipped = append(skipped, fmt.Sprintf("%s (%s)", a.TargetType, a.Intent))
+			continue
+		}
+		name, err := client.GroupName(ctx, a.GroupID)
+		if err != nil {
+			return err
+		}
+		appSpec.Assignments = append(appSpec.Assignments, spec.AssignmentSpec{
+			Group:    name,
+			Intent:   string(a.Intent),
+			Deadline: exportDeadline(a),
+		})
+	}
+
+	if err := spec.WriteAppSpec(output, appSpec); err != nil {
+		return err
+	}
+
+	fmt.Printf("Exported app %q (%s) to %s\n", app.DisplayName, exportAppID, output)
+	fmt.Printf("  Package:     %s\n", appSpec.Package)
+	fmt.Printf("  Assignments: %d\n", len(appSpec.Assignments))
+	for _, s := range skipped {
+		fmt.Printf("  Skipped assignment: %s\n", s)
+	}
+	return nil
+}
+
+// exportedSpec converts an app read from Graph into an app spec
+func exportedSpec(app *graph.Win32App) *spec.AppSpec {
+	appSpec := &spec.AppSpec{
+		Name:             app.DisplayName,
+		Version:          app.DisplayVersion,
+		Publisher:        app.Publisher,
+		Description:      app.Description,
+		InformationURL:   app.InformationURL,
+		PrivacyURL:       app.PrivacyInformationURL,
+		Developer:        app.Developer,
+		Owner:            app.Owner,
+		Notes:            app.Notes,
+		InstallCommand:   app.InstallCommandLine,
+		UninstallCommand: app.UninstallCommandLine,
+		RunAs:            app.RunAsAccount,
+		RestartBehavior:  app.RestartBehavior,
+	}
+	// Descriptions default to the display name when an app is created
+	if appSpec.Description == appSpec.Name {
+		appSpec.Description = ""
+	}
+	if validArchitectures(app.Architectures) {
+		appSpec.Architectures = app.Architectures
+	}
+	if len(app.DetectionRules) > 0 {
+		appSpec.Detection = &spec.DetectionSpec{Rules: app.DetectionRules}
+	}
+	if req := app.Requirements; req != nil {
+		appSpec.Requirements = &spec.RequirementsSpec{
+			MinimumWindowsRelease:  req.MinimumWindowsRelease,
+			MinimumFreeDiskSpaceMB: req.MinimumFreeDiskSpaceMB,
+			MinimumMemoryMB:        req.MinimumMemoryMB,
+			MinimumProcessors:      req.MinimumProcessors,
+			MinimumCPUSpeedMHz:     req.MinimumCPUSpeedMHz,
+			Rules:                  req.Rules,
+		}
+	}
+	for _, rc := range app.ReturnCodes {
+		appSpec.ReturnCodes = append(appSpec.ReturnCodes, spec.ReturnCodeSpec{Code: rc.Code, Type: rc.Type})
+	}
+	return appSpec
+}
+
+// validArchitectures reports whether an applicableArchitectures value can be written to a spec
+func validArchitectures(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, arch := range strings.Split(s, ",") {
+		switch arch {
+		case "x86", "x64", "arm", "arm64", "neutral":
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// exportDeadline formats the install deadline of an assignment for a spec
+// Deadlines in device local time keep their clock time, so they mean the same after import
+func exportDeadline(a graph.AppAssignment) string {
+	if a.Deadline == "" {
+		return ""
+	}
+	t, err := time.Parse(time.RFC3339, a.Deadline)
+	if err != nil {
+		return a.Deadline
+	}
+	if a.UseLocalTime {
+		return t.Format("2006-01-02T15:04")
+	}
+	return t.Format(time.RFC3339)
+}
+
+// relativeTo returns path relative to dir, or path unchanged when it has no relative form
+func relativeTo(dir, path string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		return absPath, nil
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// safeFileName replaces characters that are not allowed in file names
+func safeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) || r < 32 {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" {
+		return "app"
+	}
+	return name
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+var (
+	footprintFormat string
+	footprintOutput string
+)
+
+var footprintCmd = &cobra.Command{
+	Use:   "footprint <old-folder> <new-folder>",
+	Short: "Report what changed on disk between two versions of an app",
+	Long: `Compare the install footprint of two app versions and report the files that
+were added, removed and updated, with the file versions of executables,
+libraries and MSIs.
+
+The folders are typically MSI payloads extracted with an administrative
+install (msiexec /a setup.msi TARGETDIR=C:\extract\2.0 /qn), or the install
+folders of a reference machine before and after the upgrade. Files are
+matched by path, case-insensitively, and compared by SHA-256.
+
+Formats:
+  text      aligned table for the terminal (default)
+  markdown  tables for a release report or change request
+  json      machine-readable report
+
+Examples:
+  intunewin footprint ./extract/2.4.1 ./extract/2.5.0
+  intunewin footprint ./extract/2.4.1 ./extract/2.5.0 --format markdown -o footprint.md`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runFootprint(args[0], args[1])
+	},
+}
+
+func init() {
+	footprintCmd.Flags().StringVar(&footprintFormat, "format", "text", "Output format: text, markdown or json")
+	footprintCmd.Flags().StringVarP(&footprintOutput, "output", "o", "", "Write the report to this file instead of stdout")
+	rootCmd.AddCommand(footprintCmd)
+}
+
+func runFootprint(oldDir, newDir string) error {
+	if err := checkFootprintFormat(footprintFormat); err != nil {
+		return err
+	}
+
+	oldFiles, err := packager.ScanFootprint(oldDir, fileHashCache())
+	if err != nil {
+		return err
+	}
+	newFiles, err := packager.ScanFootprint(newDir, fileHashCache())
+	if err != nil {
+		return err
+	}
+
+	report := footprintReport{title: "Install footprint changes", oldName: oldDir, newName: newDir}
+	return report.write(packager.CompareFootprints(oldFiles, newFiles), footprintFormat, footprintOutput)
+}
+
+// checkFootprintFormat rejects unknown report formats before any work is done
+func checkFootprintFormat(format string) error {
+	switch format {
+	case "text", "markdown", "json":
+		return nil
+	default:
+		return fmt.Errorf("invalid format: %s (supported: text, markdown, json)", format)
+	}
+}
+
+// footprintReport describes what a footprint comparison compared
+type footprintReport struct {
+	title   string
+	oldName string
+	newName string
+}
+
+// write writes the comparison in a format to the output file, or stdout if output is empty
+func (r footprintReport) write(diff *packager.FootprintDiff, format, output string) error {
+	out := io.Writer(os.Stdout)
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create report: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diff)
+	case "markdown":
+		return r.writeMarkdown(out, diff)
+	default:
+		return r.writeText(out, diff)
+	}
+}
+
+// writeText writes the comparison as an aligned table
+func (r footprintReport) writeText(out io.Writer, diff *packager.FootprintDiff) error {
+	fmt.Fprintf(out, "%s from %s to %s\n\n", r.title, r.oldName, r.newName)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, f := range diff.Added {
+		fmt.Fprintf(w, "  added\t%s\t%s\t%s\n", f.Path, packager.FormatSize(f.Size), valueOrDash(f.Version))
+	}
+	for _, f := range diff.Removed {
+		fmt.Fprintf(w, "  removed\t%s\t%s\t%s\n", f.Path, packager.FormatSize(f.Size), valueOrDash(f.Version))
+	}
+	for _, c := range diff.Updated {
+		fmt.Fprintf(w, "  updated\t%s\t%s -> %s\t%s -> %s\n", c.Path,
+			packager.FormatSize(c.OldSize), packager.FormatSize(c.NewSize),
+			valueOrDash(c.OldVersion), valueOrDash(c.NewVersion))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if !diff.Empty() {
+		fmt.Fprintln(out)
+	}
+	_, err := fmt.Fprintf(out, "%d added, %d removed, %d updated, %d unchanged\n",
+		len(diff.Added), len(diff.Removed), len(diff.Updated), diff.Unchanged)
+	return err
+}
+
+// writeMarkdown writes the comparison as Markdown tables
+func (r footprintReport) writeMarkdown(out io.Writer, diff *packager.FootprintDiff) error {
+	fmt.Fprintf(out, "## %s\n\n", r.title)
+	fmt.Fprintf(out, "From `%s` to `%s`: %d added, %d removed, %d updated, %d unchanged.\n",
+		r.oldName, r.newName, len(diff.Added), len(diff.Removed), len(diff.Updated), diff.Unchanged)
+
+	if len(diff.Updated) > 0 {
+		fmt.Fprintf(out, "\n### Updated\n\n| File | Old version | New version | Old size | New size |\n|---|---|---|---|---|\n")
+		for _, c := range diff.Updated {
+			fmt.Fprintf(out, "| `%s` | %s | %s | %s | %s |\n", c.Path,
+				valueOrDash(c.OldVersion), valueOrDash(c.NewVersion),
+				packager.FormatSize(c.OldSize), packager.FormatSize(c.NewSize))
+		}
+	}
+	for _, section := range []struct {
+		title string
+		files []packager.FootprintFile
+	}{
+		{"Added", diff.Added},
+		{"Removed", diff.Removed},
+	} {
+		if len(section.files) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "\n### %s\n\n| File | Version | Size |\n|---|---|---|\n", section.title)
+		for _, f := range section.files {
+			fmt.Fprintf(out, "| `%s` | %s | %s |\n", f.Path, valueOrDash(f.Version), packager.FormatSize(f.Size))
+		}
+	}
+	return nil
+}
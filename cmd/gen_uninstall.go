@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/probe"
+)
+
+var (
+	uninstallName      string
+	uninstallString    string
+	uninstallArgs      string
+	uninstallOutput    string
+	uninstallNoPackage bool
+)
+
+var genUninstallCmd = &cobra.Command{
+	Use:   "gen-uninstall [installer.msi|setup.exe]",
+	Short: "Generate a companion package that uninstalls an app",
+	Long: `Generate a companion Win32 app that removes an application: a PowerShell
+wrapper packaged as an .intunewin, a detection script that detects the
+companion once the app is gone, and a JSON file with the command lines and
+Graph detection rules to create it in Intune.
+
+For an MSI, the wrapper runs msiexec /x {ProductCode} /qn with the product
+code read from the MSI, and treats "product not installed" (1605) as success.
+
+For an EXE-based app, the wrapper runs the uninstall command line given with
+--uninstall-string, or looks up the app by --name in the Uninstall registry
+keys and runs its QuietUninstallString, or its UninstallString with the
+silent switches of --uninstall-args. Those default to the uninstaller
+switches of the installer framework of setup.exe (Inno Setup, NSIS, WiX
+Burn). Uninstall strings calling msiexec are run as msiexec /x ... /qn.
+
+With --no-package, only the scripts and the JSON are written.
+
+Examples:
+  intunewin gen-uninstall ./7z2401-x64.msi -o ./output
+  intunewin gen-uninstall ./npp.8.6.Installer.x64.exe --name "Notepad++" -o ./output
+  intunewin gen-uninstall --name "Contoso App" --uninstall-string '"C:\Program Files\Contoso\uninst.exe" /S' --no-package`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var installer string
+		if len(args) == 1 {
+			installer = args[0]
+		}
+		return runGenUninstall(installer, cmd.Flags().Changed("uninstall-args"))
+	},
+}
+
+func init() {
+	genUninstallCmd.Flags().StringVar(&uninstallName, "name", "", "Display name in Add/Remove Programs (default: MSI product name or setup file name)")
+	genUninstallCmd.Flags().StringVar(&uninstallString, "uninstall-string", "", "Known uninstall command line of an EXE-based app (default: read from the registry on the device)")
+	genUninstallCmd.Flags().StringVar(&uninstallArgs, "uninstall-args", "", "Silent switches appended to a registry UninstallString (default: from the installer framework of setup.exe)")
+	genUninstallCmd.Flags().StringVarP(&uninstallOutput, "output", "o", ".", "Output folder")
+	genUninstallCmd.Flags().BoolVar(&uninstallNoPackage, "no-package", false, "Only write the scripts and the command lines and detection JSON, without packaging")
+	rootCmd.AddCommand(genUninstallCmd)
+}
+
+func runGenUninstall(installer string, argsSet bool) error {
+	params, err := uninstallParams(installer)
+	if err != nil {
+		return invalidInput(err)
+	}
+	params.Name = firstNonEmpty(uninstallName, params.Name)
+	params.UninstallString = firstNonEmpty(uninstallString, params.UninstallString)
+	if argsSet {
+		params.UninstallArgs = uninstallArgs
+	}
+	if params.Name == "" {
+		return invalidInput(fmt.Errorf("--name is required without an installer"))
+	}
+
+	script, detect, err := packager.GenerateUninstallScripts(params)
+	if err != nil {
+		return invalidInput(err)
+	}
+	app := packager.NewUninstallApp(params, detect)
+
+	base := packager.ScriptBaseName(params.Name)
+	sourceDir := filepath.Join(uninstallOutput, "Uninstall-"+base)
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	scriptPath := filepath.Join(sourceDir, app.SetupFile)
+	if err := os.WriteFile(scriptPath, script, 0644); err != nil {
+		return fmt.Errorf("failed to write uninstall script: %w", err)
+	}
+	detectPath := filepath.Join(uninstallOutput, "Detect-Uninstall-"+base+".ps1")
+	if err := os.WriteFile(detectPath, detect, 0644); err != nil {
+		return fmt.Errorf("failed to write detection script: %w", err)
+	}
+	data, err := json.MarshalIndent(app, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode uninstall app: %w", err)
+	}
+	jsonPath := filepath.Join(uninstallOutput, "Uninstall-"+base+".json")
+	if err := os.WriteFile(jsonPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write uninstall app: %w", err)
+	}
+
+	fmt.Printf("Generated uninstall app for %s\n", params.Name)
+	if !uninstallNoPackage {
+		result, err := packager.Package(sourceDir, app.SetupFile, uninstallOutput, nil)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("  Package:    %s\n", result.OutputPath)
+	}
+	fmt.Printf("  Script:     %s\n", scriptPath)
+	fmt.Printf("  Detection:  %s\n", detectPath)
+	fmt.Printf("  App JSON:   %s\n", jsonPath)
+	fmt.Printf("  Install:    %s\n", app.InstallCommandLine)
+	if app.AppUninstallCommandLine != "" {
+		fmt.Printf("  Uninstall command of %s: %s\n", params.Name, app.AppUninstallCommandLine)
+	}
+	return nil
+}
+
+// uninstallParams reads the uninstall parameters of an MSI, or the name and uninstaller
+// switches of an EXE installer
+func uninstallParams(installer string) (packager.UninstallParams, error) {
+	switch {
+	case installer == "":
+		return packager.UninstallParams{}, nil
+	case packager.IsMsiFile(installer):
+		info, err := packager.ExtractMsiInfo(installer)
+		if err != nil {
+			return packager.UninstallParams{}, err
+		}
+		if info.ProductCode == "" {
+			return packager.UninstallParams{}, fmt.Errorf("no ProductCode found in %s", installer)
+		}
+		return packager.UninstallParamsFromMsi(info), nil
+	case strings.EqualFold(filepath.Ext(installer), ".exe"):
+		kind, err := probe.DetectInstallerType(installer)
+		if err != nil {
+			return packager.UninstallParams{}, err
+		}
+		name := strings.TrimSuffix(filepath.Base(installer), filepath.Ext(installer))
+		return packager.UninstallParams{Name: name, UninstallArgs: probe.UninstallSwitches(kind)}, nil
+	default:
+		return packager.UninstallParams{}, fmt.Errorf("%s is not an MSI or EXE installer", installer)
+	}
+}
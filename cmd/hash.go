@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+var (
+	hashFormat  string
+	hashFiles   bool
+	hashCheck   string
+	hashExclude []string
+	hashVars    []string
+	hashRepro   bool
+	hashSetup   string
+	hashNoSuite bool
+	hashLinks   string
+	hashPerms   bool
+	hashCompr   string
+)
+
+var hashCmd = &cobra.Command{
+	Use:   "hash <folder|package.intunewin|file>",
+	Short: "Compute the SHA256 digest a package records, without packaging",
+	Long: `Compute the same SHA256 digests packaging uses, so they can be compared in
+approval workflows without building packages twice.
+
+For a source folder, the content is compressed exactly as packaging does and
+the FileDigest its package would record in Detection.xml is printed, with
+the UnencryptedContentSize. --exclude patterns (or those of the active
+profile), the --symlinks policy, --normalize-permissions and the --var
+template variables (merged with those of the profile) are honored, as they
+change the content. File modification times
+are part of the content ZIP, so copying files with new timestamps changes
+the digest, unless --reproducible computes the digest of a reproducible
+build. With an MSI --setup file, the install script of its language packs
+and add-ons (see 'Packaging MSI Suites' in the README) is part of the
+content, as when packaging.
+
+For a .intunewin package, the FileDigest recorded in its Detection.xml is
+printed, so a package can be checked against the digest of its source.
+
+For any other file, its SHA256 is printed.
+
+--check compares the digest with an expected value (base64 as in
+Detection.xml, or hex) and fails when they differ.
+
+Examples:
+  intunewin hash ./7zip
+  intunewin hash ./output/7z2401-x64.intunewin
+  intunewin hash ./7zip --check 'w2YVbdiB4vqqbRdDCa3atBaEt2tJw6XScDipPGebTtk='
+  intunewin hash ./7zip --files --format json
+  intunewin hash ./cadpro --setup CADPro.msi --reproducible`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runHash(args[0])
+	},
+}
+
+func init() {
+	hashCmd.Flags().StringVar(&hashFormat, "format", "text", "Output format: text or json")
+	hashCmd.Flags().BoolVar(&hashFiles, "files", false, "Also list the SHA256 of every file of a folder")
+	hashCmd.Flags().StringVar(&hashCheck, "check", "", "Fail unless the digest equals this value (base64 or hex)")
+	hashCmd.Flags().StringArrayVar(&hashExclude, "exclude", nil, "Glob pattern of files or folders left out of the package (repeatable)")
+	hashCmd.Flags().StringArrayVar(&hashVars, "var", nil, "Template variable KEY=VALUE stamped into scripts of the package (repeatable)")
+	hashCmd.Flags().BoolVar(&hashRepro, "reproducible", false, "Compute the digest of a reproducible build (file times from "+packager.SourceDateEpochEnv+")")
+	hashCmd.Flags().StringVarP(&hashSetup, "setup", "s", "", "Setup file the folder is packaged with (adds the install script of an MSI suite)")
+	hashCmd.Flags().StringVar(&hashLinks, "symlinks", string(packager.SymlinkFollow), "Symlink policy the folder is packaged with: follow, skip or error")
+	hashCmd.Flags().BoolVar(&hashPerms, "normalize-permissions", false, "Compute the digest of a package built with --normalize-permissions")
+	hashCmd.Flags().StringVar(&hashCompr, "compression", string(packager.CompressionDefault), "Compression the folder is packaged with: store, fast, default or best")
+	hashCmd.Flags().BoolVar(&hashNoSuite, "no-msi-suite", false, "Compute the digest of a package built with --no-msi-suite")
+	rootCmd.AddCommand(hashCmd)
+}
+
+// hashReport is the json output of the hash command
+type hashReport struct {
+	Path                   string                  `json:"path"`
+	Kind                   string                  `json:"kind"`
+	SHA256                 string                  `json:"sha256"`
+	FileDigest             string                  `json:"fileDigest"`
+	Size                   int64                   `json:"size"`
+	UnencryptedContentSize int64                   `json:"unencryptedContentSize,omitempty"`
+	Files                  []packager.ManifestFile `json:"files,omitempty"`
+}
+
+func runHash(path string) error {
+	if hashFormat != "text" && hashFormat != "json" {
+		return fmt.Errorf("invalid format: %s (supported: text, json)", hashFormat)
+	}
+	var expected []byte
+	if hashCheck != "" {
+		var err error
+		if expected, err = parseDigest(hashCheck); err != nil {
+			return err
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("cannot access %s: %w", path, err)
+	}
+
+	report := hashReport{Path: path}
+	var digest []byte
+	switch {
+	case info.IsDir():
+		opts, err := hashOptions()
+		if err != nil {
+			return err
+		}
+		content, err := packager.SetupFolderDigest(path, hashSetup, opts)
+		if err != nil {
+			return err
+		}
+		digest = content.FileDigest
+		report.Kind = "folder"
+		report.Size = content.UnencryptedContentSize
+		report.UnencryptedContentSize = content.UnencryptedContentSize
+		if hashFiles {
+			report.Files = content.Files
+		}
+	case strings.EqualFold(filepath.Ext(path), ".intunewin"):
+		content, err := packager.PackageDigest(path)
+		if err != nil {
+			return err
+		}
+		digest = content.FileDigest
+		report.Kind = "package"
+		report.Size = info.Size()
+		report.UnencryptedContentSize = content.UnencryptedContentSize
+	default:
+		digest, err = fileHashCache().FileSHA256(path)
+		if err != nil {
+			return err
+		}
+		report.Kind = "file"
+		report.Size = info.Size()
+	}
+	report.SHA256 = hex.EncodeToString(digest)
+	report.FileDigest = base64.StdEncoding.EncodeToString(digest)
+
+	if hashFormat == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printHashReport(report)
+	}
+
+	if expected != nil {
+		if !bytes.Equal(expected, digest) {
+			return fmt.Errorf("digest mismatch: expected %s, got %s", hashCheck, report.FileDigest)
+		}
+		if hashFormat == "text" {
+			fmt.Println("\nDigest matches")
+		}
+	}
+	return nil
+}
+
+// printHashReport prints the digests of a folder, package or file
+func printHashReport(report hashReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	switch report.Kind {
+	case "folder":
+		fmt.Fprintf(w, "FileDigest:\t%s\t(SHA256 of the content, as in Detection.xml)\n", report.FileDigest)
+		fmt.Fprintf(w, "SHA256:\t%s\n", report.SHA256)
+		fmt.Fprintf(w, "UnencryptedContentSize:\t%d\n", report.UnencryptedContentSize)
+	case "package":
+		fmt.Fprintf(w, "FileDigest:\t%s\t(recorded in Detection.xml)\n", report.FileDigest)
+		fmt.Fprintf(w, "SHA256:\t%s\n", report.SHA256)
+		fmt.Fprintf(w, "UnencryptedContentSize:\t%d\n", report.UnencryptedContentSize)
+	default:
+		fmt.Fprintf(w, "SHA256:\t%s\n", report.SHA256)
+		fmt.Fprintf(w, "Base64:\t%s\n", report.FileDigest)
+		fmt.Fprintf(w, "Size:\t%d\n", report.Size)
+	}
+	w.Flush()
+
+	if len(report.Files) > 0 {
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, f := range report.Files {
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", f.SHA256, packager.FormatSize(f.Size), f.Path)
+		}
+		w.Flush()
+	}
+}
+
+// hashOptions returns the packaging options that change the content digest: the
+// --exclude patterns (or those of the active profile), --symlinks, --normalize-permissions,
+// --var, --compression and --reproducible
+func hashOptions() (packager.Options, error) {
+	var opts packager.Options
+	profile, err := activeProfile()
+	if err != nil {
+		return opts, err
+	}
+	opts.Exclude = profile.Exclude
+	if len(hashExclude) > 0 {
+		opts.Exclude = hashExclude
+	}
+	if opts.Symlinks, err = packager.ParseSymlinkPolicy(hashLinks); err != nil {
+		return opts, err
+	}
+	if opts.Variables, _, err = templateVariables(context.Background(), profile, hashVars); err != nil {
+		return opts, err
+	}
+	opts.NoMsiSuite = hashNoSuite
+	opts.NormalizePermissions = hashPerms
+	if opts.Compression, err = packager.ParseCompression(hashCompr); err != nil {
+		return opts, err
+	}
+	if hashRepro {
+		if opts.Reproducible, err = reproducibleOptions(); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// parseDigest decodes a SHA256 digest given as hex or base64
+func parseDigest(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if digest, err := hex.DecodeString(s); err == nil && len(digest) == 32 {
+		return digest, nil
+	}
+	if digest, err := base64.StdEncoding.DecodeString(s); err == nil && len(digest) == 32 {
+		return digest, nil
+	}
+	return nil, fmt.Errorf("invalid digest %q: expected a SHA256 in hex or base64", s)
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+var (
+	// noCache disables the hash cache
+	noCache bool
+
+	// runHashCache is the hash cache of this run, opened on first use
+	runHashCache     *packager.HashCache
+	runHashCacheOnce sync.Once
+)
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "Hash every file again instead of reusing the SHA256 of unchanged files from the hash cache")
+}
+
+// fileHashCache returns the hash cache of this run, nil with --no-cache or when it cannot be opened
+func fileHashCache() *packager.HashCache {
+	runHashCacheOnce.Do(func() {
+		if noCache {
+			return
+		}
+		path, err := packager.DefaultHashCachePath()
+		if err != nil {
+			slog.Warn("hash cache disabled", "error", err)
+			return
+		}
+		if runHashCache, err = packager.OpenHashCache(path); err != nil {
+			slog.Warn("hash cache disabled", "error", err)
+		}
+	})
+	return runHashCache
+}
+
+// saveHashCache writes hashes computed during the run back to the hash cache
+// Read-only mode leaves the cache file untouched
+func saveHashCache() {
+	if readOnly || runHashCache == nil {
+		return
+	}
+	if err := runHashCache.Save(); err != nil {
+		slog.Warn("could not save hash cache", "error", err)
+	}
+}
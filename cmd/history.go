@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/config"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/listing"
+)
+
+var historyListFlags listingFlags
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show packaging jobs started from the interactive UI",
+	Long:  `Commands for the history of packaging jobs started from the interactive UI.`,
+}
+
+var historyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List and search recent packaging jobs",
+	Long: `List the recent packaging jobs started from the interactive UI, newest first.
+
+--filter takes free text, matched against the setup file, folders, package
+and error, and field conditions:
+  name:setup.msi         setup file contains "setup.msi"
+  source:Adobe           source folder contains "Adobe"
+  status:failed          succeeded or failed
+  date:>=2024-05-01      started on or after that day
+
+Text is matched ignoring case and accents, and sorted by the rules of the
+locale (--locale, or LANG).
+
+Examples:
+  intunewin history list --filter "status:failed"
+  intunewin history list --sort name --desc=false`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runHistoryList()
+	},
+}
+
+func init() {
+	historyListFlags.register(historyListCmd, "date", true, "name, source, output, package, status, error, date")
+
+	historyCmd.AddCommand(historyListCmd)
+	rootCmd.AddCommand(historyCmd)
+}
+
+// historyFields are the fields of history entries for --filter and --sort
+var historyFields = []listing.Field[config.HistoryEntry]{
+	listing.TextField("name", func(e config.HistoryEntry) string { return e.SetupFile }),
+	listing.TextField("source", func(e config.HistoryEntry) string { return e.SourcePath }),
+	listing.TextField("output", func(e config.HistoryEntry) string { return e.OutputPath }),
+	listing.TextField("package", func(e config.HistoryEntry) string { return e.Package }),
+	listing.TextField("status", historyStatus),
+	listing.TextField("error", func(e config.HistoryEntry) string { return e.Error }),
+	listing.DateField("date", func(e config.HistoryEntry) time.Time { return e.Timestamp }),
+}
+
+// historyStatus describes the outcome of a job
+func historyStatus(e config.HistoryEntry) string {
+	if e.Succeeded {
+		return "succeeded"
+	}
+	return "failed"
+}
+
+func runHistoryList() error {
+	path, err := config.DefaultHistoryPath()
+	if err != nil {
+		return err
+	}
+	history, err := config.LoadHistory(path)
+	if err != nil {
+		return err
+	}
+
+	entries, total, pages, err := applyListing(&historyListFlags, history.Entries, historyFields...)
+	if err != nil {
+		return err
+	}
+	if total == 0 {
+		fmt.Println("No packaging jobs found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DATE\tSTATUS\tSETUP\tSOURCE\tPACKAGE")
+	for _, e := range entries {
+		pkg := e.Package
+		if pkg != "" {
+			pkg = filepath.Base(pkg)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			e.Timestamp.Local().Format("2006-01-02 15:04"),
+			historyStatus(e),
+			e.SetupFile,
+			e.SourcePath,
+			valueOrDash(pkg),
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	printPageFooter(&historyListFlags, total, pages)
+	return nil
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/spec"
+)
+
+var (
+	importSpec       string
+	importPackage    string
+	importOnConflict string
+	importGroupMap   []string
+)
+
+var importAppCmd = &cobra.Command{
+	Use:   "import-app",
+	Short: "Recreate a Win32 app exported with export-app in another tenant",
+	Long: `Create a Win32 app from an app spec written by export-app, together with
+its detection rules, requirements, return codes and assignments.
+
+The package named in the spec (the .intunewin next to it) supplies the
+setup file and MSI details; --package points to it elsewhere. Only the
+app metadata is created; the package content is not uploaded.
+
+Assignments are resolved by group name in the target tenant. Groups named
+differently there can be mapped with --map-group "<source name>=<target
+name or ID>". Use --what-if to review the requests before importing.
+
+Examples:
+  intunewin import-app --spec ./migration/7zip.yaml --what-if
+  intunewin import-app --spec ./migration/7zip.yaml --on-conflict update \
+    --map-group "Pilot Devices=Contoso Pilot Devices"`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runImportApp(cmd)
+	},
+}
+
+func init() {
+	importAppCmd.Flags().StringVar(&importSpec, "spec", "", "App spec written by export-app")
+	importAppCmd.Flags().StringVar(&importPackage, "package", "", "Path to the .intunewin package (default: the package named in the spec)")
+	importAppCmd.Flags().StringVar(&importOnConflict, "on-conflict", "fail", "When an app with the same name exists: fail, suffix or update")
+	importAppCmd.Flags().StringArrayVar(&importGroupMap, "map-group", nil, "Assign to another group than the exported one: <source name>=<target name or ID> (repeatable)")
+	addGraphFlags(importAppCmd.Flags())
+	addTimeoutFlag(importAppCmd.Flags())
+
+	rootCmd.AddCommand(importAppCmd)
+}
+
+func runImportApp(cmd *cobra.Command) error {
+	if importSpec == "" {
+		return invalidInput(fmt.Errorf("--spec is required"))
+	}
+	appSpec, err := spec.LoadAppSpec(importSpec)
+	if err != nil {
+		return inputError(err)
+	}
+
+	appSpec.Package = firstNonEmpty(importPackage, appSpec.Package)
+	if info, err := os.Stat(appSpec.Package); err != nil || !info.Mode().IsRegular() {
+		return withExitCode(exitSourceMissing, fmt.Errorf("package not found: %s (place it next to the spec or use --package)", appSpec.Package))
+	}
+	if appSpec.OnConflict == "" || cmd.Flags().Changed("on-conflict") {
+		appSpec.OnConflict = importOnConflict
+	}
+
+	groups, err := parseGroupMap(importGroupMap)
+	if err != nil {
+		return invalidInput(err)
+	}
+	for i := range appSpec.Assignments {
+		if target, ok := groups[appSpec.Assignments[i].Group]; ok {
+			appSpec.Assignments[i].Group = target
+		}
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	return createAppFromSpec(ctx, appSpec, nil)
+}
+
+// parseGroupMap parses --map-group values of the form "<source>=<target>"
+func parseGroupMap(values []string) (map[string]string, error) {
+	groups := make(map[string]string, len(values))
+	for _, v := range values {
+		source, target, ok := strings.Cut(v, "=")
+		source, target = strings.TrimSpace(source), strings.TrimSpace(target)
+		if !ok || source == "" || target == "" {
+			return nil, fmt.Errorf("invalid --map-group %q (expected <source name>=<target name or ID>)", v)
+		}
+		groups[source] = target
+	}
+	return groups, nil
+}
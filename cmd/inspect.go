@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+var inspectFormat string
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <package.intunewin|Detection.xml>",
+	Short: "Show the metadata of a .intunewin package",
+	Long: `Show the Detection.xml metadata of a .intunewin package.
+
+Formats:
+  text            aligned, human-readable summary (default)
+  text-canonical  one stable "key: value" line per field, meant to be committed
+                  next to the app sources so reviews show what changed between
+                  package versions
+
+Encryption keys, the MAC and the IV are never shown: they are secret and
+differ on every build. Keys are colorized only when writing to a terminal.
+
+Examples:
+  intunewin inspect ./output/7z2401-x64.intunewin
+  intunewin inspect ./output/7z2401-x64.intunewin --format text-canonical > 7zip.intunewin.txt`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runInspect(args[0])
+	},
+}
+
+func init() {
+	inspectCmd.Flags().StringVar(&inspectFormat, "format", "text", "Output format: text or text-canonical")
+	rootCmd.AddCommand(inspectCmd)
+}
+
+func runInspect(path string) error {
+	if inspectFormat != "text" && inspectFormat != "text-canonical" {
+		return fmt.Errorf("invalid format: %s (supported: text, text-canonical)", inspectFormat)
+	}
+
+	appInfo, err := readDetectionXMLFrom(path)
+	if err != nil {
+		return err
+	}
+	fields := packager.CanonicalMetadata(appInfo)
+
+	// The renderer drops colors when stdout is not a terminal, keeping redirected output plain
+	keyStyle := lipgloss.NewRenderer(os.Stdout).NewStyle().Foreground(lipgloss.Color("#7D56F4"))
+
+	if inspectFormat == "text-canonical" {
+		for _, f := range fields {
+			fmt.Printf("%s: %s\n", keyStyle.Render(f.Key), packager.CanonicalValue(f.Value))
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, f := range fields {
+		value := f.Value
+		if f.Key == "UnencryptedContentSize" && appInfo.UnencryptedContentSize >= 1024 {
+			value = fmt.Sprintf("%s (%s bytes)", packager.FormatSize(appInfo.UnencryptedContentSize), value)
+		}
+		// Every key carries the same escape codes, so the columns still line up
+		fmt.Fprintf(w, "%s\t%s\n", keyStyle.Render(f.Key+":"), valueOrDash(strings.TrimSpace(value)))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+var (
+	macOutput      string
+	macName        string
+	macBundleID    string
+	macVersion     string
+	macToolVersion string
+)
+
+var intunemacCmd = &cobra.Command{
+	Use:   "intunemac <installer.pkg|installer.dmg>",
+	Short: "Wrap a macOS .pkg or .dmg in a .intunemac package",
+	Long: `Wrap a macOS installer in a .intunemac package, the format of macOS
+line-of-business apps. The installer is encrypted like .intunewin content and
+stored with a Detection.xml that records the primary bundle ID and version
+Intune uses to detect the install, and the app bundles the installer contains.
+
+For a .pkg, the bundle ID and version are read from the PackageInfo of its
+component packages, falling back to the product of its Distribution file.
+The apps of a .dmg are inside its file system, which is not read: give them
+with --bundle-id and --version.
+
+Intune also accepts .pkg and .dmg files for macOS apps directly; .intunemac is
+the format of the macOS LOB app type and of the Intune App Wrapping Tool.
+
+Examples:
+  intunewin intunemac ./CompanyPortal.pkg -o ./output
+  intunewin intunemac ./Contoso.pkg -o ./output --version 2.1.0
+  intunewin intunemac ./Contoso.dmg -o ./output --bundle-id com.contoso.app --version 2.1.0`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runIntunemac(args[0])
+	},
+}
+
+func init() {
+	intunemacCmd.Flags().StringVarP(&macOutput, "output", "o", ".", "Output folder")
+	intunemacCmd.Flags().StringVar(&macName, "name", "", "App name (default: the product title or file name)")
+	intunemacCmd.Flags().StringVar(&macBundleID, "bundle-id", "", "Primary bundle ID (required for .dmg)")
+	intunemacCmd.Flags().StringVar(&macVersion, "version", "", "Primary bundle version (required for .dmg)")
+	intunemacCmd.Flags().StringVar(&macToolVersion, "tool-version", "", "ToolVersion attribute written to Detection.xml")
+	rootCmd.AddCommand(intunemacCmd)
+}
+
+func runIntunemac(setupPath string) error {
+	if !packager.IsMacSetupFile(setupPath) {
+		return fmt.Errorf("%s is not a macOS installer (supported: .pkg, .dmg)", setupPath)
+	}
+	if packager.IsDiskImageFile(setupPath) && (macBundleID == "" || macVersion == "") {
+		return fmt.Errorf("--bundle-id and --version are required for a .dmg")
+	}
+
+	result, err := packager.PackageMac(setupPath, macOutput, packager.MacOptions{
+		Name:        macName,
+		BundleID:    macBundleID,
+		Version:     macVersion,
+		ToolVersion: macToolVersion,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Package created: %s\n", result.OutputPath)
+	fmt.Printf("  Name:       %s\n", result.Name)
+	fmt.Printf("  Bundle ID:  %s\n", result.Info.BundleID)
+	fmt.Printf("  Version:    %s\n", result.Info.Version)
+	for _, app := range result.Info.Apps[1:] {
+		fmt.Printf("  Includes:   %s %s\n", app.BundleID, app.Version)
+	}
+	fmt.Printf("  Source:     %s\n", packager.FormatSize(result.SourceSize))
+	fmt.Printf("  Final size: %s\n", packager.FormatSize(result.FinalSize))
+	return nil
+}
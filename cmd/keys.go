@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// Environment variables holding keys and passphrases, keeping them out of shell history and CI logs
+const (
+	encryptionKeyEnv = "INTUNEWIN_ENCRYPTION_KEY"
+	macKeyEnv        = "INTUNEWIN_MAC_KEY"
+	passphraseEnv    = "INTUNEWIN_KEYS_PASSPHRASE"
+)
+
+// contentKeys returns the keys supplied with --encryption-key-file or the key
+// environment variables, or nil when packages use generated keys
+func contentKeys() (*packager.ContentKeys, error) {
+	if encryptionKeyFile != "" {
+		return packager.LoadContentKeys(encryptionKeyFile)
+	}
+	encKey, macKey := os.Getenv(encryptionKeyEnv), os.Getenv(macKeyEnv)
+	if encKey == "" && macKey == "" {
+		return nil, nil
+	}
+	keys, err := packager.ParseContentKeys(encKey, macKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s/%s: %w", encryptionKeyEnv, macKeyEnv, err)
+	}
+	return keys, nil
+}
+
+// keysPassphrase returns the passphrase protecting key exports
+func keysPassphrase() ([]byte, error) {
+	passphrase := os.Getenv(passphraseEnv)
+	if passphrase == "" {
+		return nil, fmt.Errorf("set %s to the passphrase protecting exported keys", passphraseEnv)
+	}
+	return []byte(passphrase), nil
+}
+
+// readKeyExport decrypts a key export with the passphrase from the environment
+func readKeyExport(path string) (*packager.ExportedKeys, error) {
+	passphrase, err := keysPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	return packager.ReadKeyExport(path, passphrase)
+}
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/i18n"
+)
+
+// lang is the language of TUI and CLI messages
+var lang string
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&lang, "lang", "", "Language of the interactive UI and packaging messages: "+strings.Join(i18n.Languages, ", ")+" (default: from LC_ALL, LC_MESSAGES or LANG, or the Windows user locale)")
+}
+
+// setupLanguage selects the language of messages from --lang or the environment
+func setupLanguage(cmd *cobra.Command) error {
+	if err := i18n.SetLanguage(lang); err != nil {
+		return err
+	}
+	cmd.Root().SetErrPrefix(i18n.T("Error:"))
+	return nil
+}
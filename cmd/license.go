@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/spec"
+)
+
+// flagLicense returns the license record of the --license-* and --eula flags, nil when none is set
+func flagLicense() *packager.License {
+	license := &packager.License{
+		Type:       licenseType,
+		ID:         licenseID,
+		Seats:      licenseSeats,
+		EULA:       licenseEULA,
+		AcceptedBy: licenseAcceptedBy,
+	}
+	if license.Empty() {
+		return nil
+	}
+	return license
+}
+
+// specLicense converts the license of a batch package, nil when it has none
+func specLicense(l *spec.LicenseSpec) *packager.License {
+	if l == nil {
+		return nil
+	}
+	license := &packager.License{
+		Type:       l.Type,
+		ID:         l.ID,
+		Seats:      l.Seats,
+		EULA:       l.EULA,
+		AcceptedBy: l.AcceptedBy,
+	}
+	if license.Empty() {
+		return nil
+	}
+	return license
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+var (
+	lintSetup   string
+	lintFormat  string
+	lintExclude []string
+	lintLinks   string
+	lintStrict  bool
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint <folder>",
+	Short: "Warn about common mistakes in a source folder before packaging",
+	Long: `Check a source folder for mistakes that make packages fail or slow in Intune:
+
+  setup-missing      the setup file is not in the folder
+  setup-not-at-root  the setup file is in a subfolder
+  setup-file-name    the setup file name has spaces or non-ASCII characters
+  disk-image         large ISO, IMG, VHD(X) or WIM files
+  large-archive      large ZIP, 7z, RAR or tar files, noting ZIPs whose
+                     content is also extracted in the folder
+  duplicate-file     large files with identical content
+  nested-package     .intunewin packages inside the source
+  psadt-incomplete   PSAppDeployToolkit scripts without the toolkit files
+  file-count         more than 20000 files, which slow down downloads
+
+Without --setup, the setup file packaging would pick at the root is checked.
+--exclude patterns (or those of the active profile) and the --symlinks
+policy are honored, as when packaging. Findings are warnings; --strict
+fails when there are any, to gate a pipeline.
+
+Examples:
+  intunewin lint ./apps/7zip
+  intunewin lint ./apps/cadpro --setup Deploy-Application.exe --strict
+  intunewin lint ./apps/cadpro --format json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runLint(args[0])
+	},
+}
+
+func init() {
+	lintCmd.Flags().StringVarP(&lintSetup, "setup", "s", "", "Setup file the folder is packaged with (default: the preferred setup file at the root)")
+	lintCmd.Flags().StringVar(&lintFormat, "format", "text", "Output format: text or json")
+	lintCmd.Flags().StringArrayVar(&lintExclude, "exclude", nil, "Glob pattern of files or folders left out of the package (repeatable)")
+	lintCmd.Flags().StringVar(&lintLinks, "symlinks", string(packager.SymlinkFollow), "Symlink policy the folder is packaged with: follow, skip or error")
+	lintCmd.Flags().BoolVar(&lintStrict, "strict", false, "Fail when there are findings")
+	rootCmd.AddCommand(lintCmd)
+}
+
+func runLint(dir string) error {
+	if lintFormat != "text" && lintFormat != "json" {
+		return invalidInput(fmt.Errorf("invalid format: %s (supported: text, json)", lintFormat))
+	}
+	profile, err := activeProfile()
+	if err != nil {
+		return invalidInput(err)
+	}
+	opts := packager.Options{Exclude: profile.Exclude}
+	if len(lintExclude) > 0 {
+		opts.Exclude = lintExclude
+	}
+	if err := packager.ValidateExcludePatterns(opts.Exclude); err != nil {
+		return invalidInput(err)
+	}
+	if opts.Symlinks, err = packager.ParseSymlinkPolicy(lintLinks); err != nil {
+		return invalidInput(err)
+	}
+
+	findings, err := packager.LintSource(dir, lintSetup, opts)
+	if err != nil {
+		return inputError(err)
+	}
+
+	if lintFormat == "json" {
+		if findings == nil {
+			findings = []packager.LintFinding{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(findings); err != nil {
+			return fmt.Errorf("failed to encode findings: %w", err)
+		}
+	} else if err := printLintFindings(findings); err != nil {
+		return err
+	}
+
+	if lintStrict && len(findings) > 0 {
+		return fmt.Errorf("%d lint finding(s)", len(findings))
+	}
+	return nil
+}
+
+// printLintFindings prints a table of the findings of lint
+func printLintFindings(findings []packager.LintFinding) error {
+	if len(findings) == 0 {
+		fmt.Println("No problems found.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RULE\tPATH\tMESSAGE")
+	for _, f := range findings {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", f.Rule, valueOrDash(f.Path), f.Message)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d warning(s)\n", len(findings))
+	return nil
+}
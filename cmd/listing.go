@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/listing"
+)
+
+// defaultPageSize is the number of entries per page of catalog and history listings
+const defaultPageSize = 50
+
+// listingFlags are the filter, sort and paging flags shared by list commands
+type listingFlags struct {
+	filter   string
+	sortBy   string
+	desc     bool
+	page     int
+	pageSize int
+	locale   string
+}
+
+// register adds the listing flags to a command
+func (f *listingFlags) register(cmd *cobra.Command, defaultSort string, defaultDesc bool, fields string) {
+	cmd.Flags().StringVar(&f.filter, "filter", "", "Filter such as 'vendor:Adobe version:>24 reader' (fields: "+fields+")")
+	cmd.Flags().StringVar(&f.sortBy, "sort", defaultSort, "Sort by field ("+fields+")")
+	cmd.Flags().BoolVar(&f.desc, "desc", defaultDesc, "Sort in descending order")
+	cmd.Flags().IntVar(&f.page, "page", 1, "Page to show")
+	cmd.Flags().IntVar(&f.pageSize, "page-size", defaultPageSize, "Entries per page (0 shows all)")
+	cmd.Flags().StringVar(&f.locale, "locale", "", "Locale for sorting and matching text, e.g. de or sv-SE (default from LANG)")
+}
+
+// applyListing filters, sorts and pages entries with the listing flags
+// It returns the entries of the requested page, the number of matches and the number of pages
+func applyListing[T any](f *listingFlags, entries []T, fields ...listing.Field[T]) ([]T, int, int, error) {
+	if f.page < 1 {
+		return nil, 0, 0, fmt.Errorf("--page must be at least 1")
+	}
+	locale, err := listing.Locale(f.locale)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	query, err := listing.ParseQuery(f.filter)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	l := listing.New(locale, fields...)
+	matched, err := l.Filter(entries, query)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("invalid filter: %w", err)
+	}
+	if err := l.Sort(matched, f.sortBy, f.desc); err != nil {
+		return nil, 0, 0, fmt.Errorf("invalid sort: %w", err)
+	}
+	page, pages := listing.Paginate(matched, f.page, f.pageSize)
+	return page, len(matched), pages, nil
+}
+
+// printPageFooter tells which page of a longer listing is shown
+func printPageFooter(f *listingFlags, total, pages int) {
+	if pages > 1 {
+		fmt.Printf("\nPage %d of %d (%d entries), use --page to see more\n", f.page, pages, total)
+	}
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/secrets"
+)
+
+var (
+	// Logging flags
+	logLevel string
+	logFile  string
+
+	// logFileHandle is the open --log-file, if any
+	logFileHandle *os.File
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "warn", "Log verbosity: debug, info, warn or error")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "Write logs to this file instead of stderr")
+}
+
+// parseLogLevel converts a --log-level value into a slog level
+func parseLogLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("invalid log level: %s (supported: debug, info, warn, error)", s)
+	}
+}
+
+// setupLogging installs the default logger from --log-level and --log-file
+// Logs go to the log file if set, otherwise to stderr
+func setupLogging() error {
+	var console []io.Writer
+	if logFile == "" {
+		console = append(console, os.Stderr)
+	}
+	logger, err := newLogger(console...)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// newLogger creates a logger honoring --log-level that writes to writers and,
+// if set, to the --log-file
+func newLogger(writers ...io.Writer) (*slog.Logger, error) {
+	level, err := parseLogLevel(logLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	if logFile != "" {
+		if logFileHandle == nil {
+			logFileHandle, err = os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open log file: %w", err)
+			}
+		}
+		writers = append(writers, logFileHandle)
+	}
+
+	// Resolved secrets of template variables never reach the logs
+	out := secrets.Default.Writer(io.MultiWriter(writers...))
+	return slog.New(slog.NewTextHandler(out, &slog.HandlerOptions{Level: level})), nil
+}
+
+// closeLogging closes the log file, if one was opened
+func closeLogging() {
+	if logFileHandle != nil {
+		logFileHandle.Close()
+		logFileHandle = nil
+	}
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/metrics"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/webhook"
+)
+
+var (
+	// Phase breakdown of CLI runs
+	showTimings bool
+
+	// Metrics of batch and worker runs
+	metricsListen string
+	metricsFile   string
+)
+
+// addMetricsListenFlag registers --metrics-listen on a long-running command
+func addMetricsListenFlag(flags *pflag.FlagSet) {
+	flags.StringVar(&metricsListen, "metrics-listen", "", "Serve Prometheus metrics at http://<address>/metrics while running, e.g. :9464")
+}
+
+// serveMetrics serves the metrics of reg at /metrics on --metrics-listen, if set
+// Returns a function that stops the server
+func serveMetrics(reg *metrics.Registry) (func(), error) {
+	if metricsListen == "" {
+		return func() {}, nil
+	}
+	listener, err := net.Listen("tcp", metricsListen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", metricsListen, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", reg.Handler())
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			slog.Warn("metrics server failed", "error", err)
+		}
+	}()
+	fmt.Printf("Serving metrics on http://%s/metrics\n", listener.Addr())
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}, nil
+}
+
+// packageMeasured packages like packageNotified and records the run in reg, when set,
+// with its own tracer for the phase durations unless opts has one
+func packageMeasured(ctx context.Context, reg *metrics.Registry, notifier *webhook.Notifier, source, setup, output string, opts packager.Options, progress packager.ProgressCallback) (*packager.PackageResult, error) {
+	if reg == nil {
+		return packageNotified(ctx, notifier, source, setup, output, opts, progress)
+	}
+	if opts.Tracer == nil {
+		// Only phases are measured, per-file spans are not kept
+		opts.Tracer = packager.NewTracer(time.Hour)
+	}
+	done := reg.Start()
+	result, err := packageNotified(ctx, notifier, source, setup, output, opts, progress)
+	done(result, opts.Tracer, err)
+	return result, err
+}
+
+// printTimings prints the time spent in each packaging phase with --timings
+func printTimings(tracer *packager.Tracer) {
+	if !showTimings {
+		return
+	}
+	phases := tracer.Phases()
+	var total time.Duration
+	for _, phase := range phases {
+		if phase.Name == "package" {
+			total += phase.Duration
+		}
+	}
+	if total <= 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Println("Timings:")
+	for _, phase := range phases {
+		if phase.Name == "package" {
+			continue
+		}
+		share := float64(phase.Duration) / float64(total) * 100
+		line := fmt.Sprintf("  %-14s %10s %6.1f%%  %s", phase.Name, formatTiming(phase.Duration), share, strings.Repeat("#", int(share/5+0.5)))
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+	fmt.Printf("  %-14s %10s\n", "total", formatTiming(total))
+}
+
+// formatTiming rounds a phase duration for display
+func formatTiming(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.Round(time.Microsecond).String()
+	}
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/graphtest"
+)
+
+// graphURLEnv points the Graph commands at another server, such as mock-graph
+const graphURLEnv = "INTUNEWIN_GRAPH_URL"
+
+var (
+	mockGraphListen   string
+	mockGraphGroups   []string
+	mockGraphPageSize int
+)
+
+var mockGraphCmd = &cobra.Command{
+	Use:   "mock-graph",
+	Short: "Run an in-memory Microsoft Graph to test app specs and pipelines without a tenant",
+	Long: `Run a mock of the Microsoft Graph requests intunewin sends: tokens, Win32
+apps, assignments, relationships, groups and the content upload to Azure
+Storage. Apps live in memory until the mock stops.
+
+Point the Graph commands at the mock with --graph-url or ` + graphURLEnv + `.
+Any tenant ID, client ID and secret are accepted. Requests are validated as
+Intune does (required app properties, detection rules, existing groups and
+relationship targets), so a spec that fails against the mock fails in a
+tenant too. Every request is printed with its status; GET /mock/state
+returns the apps, groups and requests as JSON for pipeline assertions.
+
+Examples:
+  intunewin mock-graph --group "Pilot Devices" --group "All Users"
+
+  # In another shell or pipeline step
+  export ` + graphURLEnv + `=http://127.0.0.1:8089
+  intunewin apps create --spec 7zip.yaml --tenant-id mock --client-id mock --client-secret mock
+  curl http://127.0.0.1:8089/mock/state`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runMockGraph()
+	},
+}
+
+func init() {
+	mockGraphCmd.Flags().StringVar(&mockGraphListen, "listen", "127.0.0.1:8089", "Address to listen on")
+	mockGraphCmd.Flags().StringArrayVar(&mockGraphGroups, "group", nil, "Group to create in the mock tenant (repeatable)")
+	mockGraphCmd.Flags().IntVar(&mockGraphPageSize, "page-size", graphtest.DefaultPageSize, "Apps per page of app lists (small values exercise paging)")
+	rootCmd.AddCommand(mockGraphCmd)
+}
+
+func runMockGraph() error {
+	mock := graphtest.New()
+	mock.PageSize = mockGraphPageSize
+	mock.OnRequest = func(req graphtest.Request) {
+		fmt.Printf("%s %3d %-6s %s\n", time.Now().Format("15:04:05"), req.Status, req.Method, req.Path)
+	}
+
+	listener, err := net.Listen("tcp", mockGraphListen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", mockGraphListen, err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	httpServer := &http.Server{Handler: mock.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	url := "http://" + listener.Addr().String()
+	fmt.Printf("Mock Graph listening on %s (Ctrl+C to stop)\n", url)
+	fmt.Printf("  export %s=%s\n", graphURLEnv, url)
+	for _, name := range mockGraphGroups {
+		group := mock.AddGroup(name)
+		fmt.Printf("  Group: %s (%s)\n", group.DisplayName, group.ID)
+	}
+	fmt.Println()
+
+	if err := httpServer.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server failed: %w", err)
+	}
+	return nil
+}
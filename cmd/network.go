@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/graph"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/network"
+)
+
+// proxyPasswordEnv holds the password of --proxy-user, kept off the command line
+const proxyPasswordEnv = "INTUNEWIN_PROXY_PASSWORD"
+
+var (
+	proxyURL  string
+	proxyUser string
+	noProxy   string
+	caCerts   []string
+)
+
+// httpTransport sends the HTTP requests of the command through the proxy and trusts the
+// certificate authorities of the flags; set by setupNetwork, nil before
+var httpTransport http.RoundTripper
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&proxyURL, "proxy", "", "Proxy URL of downloads, Graph calls and webhook posts, e.g. http://proxy.contoso.com:8080 (default: HTTPS_PROXY or HTTP_PROXY)")
+	rootCmd.PersistentFlags().StringVar(&proxyUser, "proxy-user", "", "User of an authenticated proxy; the password is read from "+proxyPasswordEnv)
+	rootCmd.PersistentFlags().StringVar(&noProxy, "no-proxy", "", "Comma-separated hosts, domains and CIDR ranges reached without the proxy (default: NO_PROXY)")
+	rootCmd.PersistentFlags().StringArrayVar(&caCerts, "ca-cert", nil, "PEM file of a certificate authority to trust in addition to the system roots, e.g. of a TLS-inspecting proxy (repeatable)")
+}
+
+// setupNetwork routes the HTTP requests of the command through the proxy and trusts the
+// certificate authorities of the flags
+func setupNetwork() error {
+	transport, err := network.Configure(network.Options{
+		Proxy:         proxyURL,
+		ProxyUser:     proxyUser,
+		ProxyPassword: os.Getenv(proxyPasswordEnv),
+		NoProxy:       noProxy,
+		CACerts:       caCerts,
+	})
+	if err != nil {
+		return err
+	}
+	httpTransport = transport
+	return nil
+}
+
+// graphHTTPOptions returns opts sending Graph requests through httpTransport
+func graphHTTPOptions(opts graph.HTTPOptions) graph.HTTPOptions {
+	opts.Transport = httpTransport
+	return opts
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/probe"
+)
+
+var (
+	probeContent  string
+	probeSetup    string
+	probeTimeout  time.Duration
+	probeMaxWait  time.Duration
+	probeWorkDir  string
+	probeSwitches []string
+)
+
+var probeCmd = &cobra.Command{
+	Use:   "probe-switches",
+	Short: "Find the silent install switches of an EXE installer (experimental, Windows only)",
+	Long: `Run an EXE installer with common silent-switch candidates inside Windows
+Sandbox and suggest the command line that installs it silently.
+
+The installer framework (Inno Setup, NSIS, InstallShield, WiX Burn) is
+detected from the EXE and its documented switches are tried first. Each
+candidate runs until it exits or --timeout passes; a candidate is silent
+when it exits with 0 or 3010 without showing a window. Probing stops at
+the first silent candidate, so later candidates never run against an
+already installed app.
+
+The source folder is mapped read-only and networking is disabled in the
+sandbox. Windows Sandbox must be enabled (Windows 10/11 Pro or Enterprise)
+and only one sandbox can run at a time.
+
+This command is experimental: installers that only show a window after a
+delay, or that need network access, may be misjudged.
+
+Examples:
+  intunewin probe-switches -c ./source -s setup.exe
+  intunewin probe-switches -c ./source -s setup.exe --switch "/install /quiet" --timeout 5m`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runProbe()
+	},
+}
+
+func init() {
+	probeCmd.Flags().StringVarP(&probeContent, "content", "c", "", "Source folder containing the setup file")
+	probeCmd.Flags().StringVarP(&probeSetup, "setup", "s", "", "Setup file name (e.g., install.exe)")
+	probeCmd.Flags().DurationVar(&probeTimeout, "timeout", probe.DefaultCandidateTimeout, "Time each candidate may run before it is killed")
+	probeCmd.Flags().DurationVar(&probeMaxWait, "max-wait", 30*time.Minute, "Time to wait for the whole probe, including sandbox startup")
+	probeCmd.Flags().StringVar(&probeWorkDir, "work-dir", "", "Folder for the sandbox configuration and results (default: temporary folder)")
+	probeCmd.Flags().StringArrayVar(&probeSwitches, "switch", nil, "Candidate switches to try before the built-in ones (repeatable)")
+	rootCmd.AddCommand(probeCmd)
+}
+
+func runProbe() error {
+	if probeContent == "" || probeSetup == "" {
+		return fmt.Errorf("--content and --setup are required")
+	}
+	if !strings.EqualFold(filepath.Ext(probeSetup), ".exe") {
+		return fmt.Errorf("probing only supports EXE installers (MSI installers use msiexec /qn)")
+	}
+
+	kind, err := probe.DetectInstallerType(filepath.Join(probeContent, probeSetup))
+	if err != nil {
+		return err
+	}
+
+	workDir := probeWorkDir
+	if workDir == "" {
+		workDir, err = os.MkdirTemp("", "intunewin-probe-")
+		if err != nil {
+			return fmt.Errorf("failed to create work folder: %w", err)
+		}
+	}
+
+	job := &probe.Job{
+		SourceDir:  probeContent,
+		SetupFile:  probeSetup,
+		WorkDir:    workDir,
+		Candidates: append(append([]string{}, probeSwitches...), probe.Candidates(kind)...),
+		Timeout:    probeTimeout,
+	}
+
+	fmt.Printf("Installer type: %s\n", kind)
+	fmt.Printf("Probing %d candidate(s) in Windows Sandbox (work folder: %s)\n", len(job.Candidates), workDir)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	ctx, cancelWait := context.WithTimeout(ctx, probeMaxWait)
+	defer cancelWait()
+
+	results, runErr := probe.Run(ctx, job)
+	if len(results) > 0 {
+		printProbeResults(results)
+	}
+	if runErr != nil {
+		return runErr
+	}
+
+	if best := probe.Suggest(results); best != nil {
+		fmt.Printf("\nSuggested install command: %s %s\n", probeSetup, best.Args)
+		return nil
+	}
+	return fmt.Errorf("no candidate installed silently; check the vendor documentation or try --switch")
+}
+
+// printProbeResults prints the outcome of each candidate as a table
+func printProbeResults(results []probe.Result) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\nSWITCHES\tEXIT CODE\tWINDOW\tDURATION")
+	for _, r := range results {
+		exitCode := "-"
+		if r.TimedOut {
+			exitCode = "timed out"
+		} else if r.ExitCode != nil {
+			exitCode = fmt.Sprint(*r.ExitCode)
+		}
+		duration := (time.Duration(r.DurationMs) * time.Millisecond).Round(100 * time.Millisecond)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Args, exitCode, valueOrDash(r.Window), duration)
+	}
+	w.Flush()
+}
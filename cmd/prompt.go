@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/i18n"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/tui"
+)
+
+// promptMode asks for the inputs not given as flags, then packages like quiet mode
+var promptMode bool
+
+func init() {
+	rootCmd.Flags().BoolVar(&promptMode, "prompt", false, "Ask on stdin only for the source folder, setup file and output folder not given as flags, then package like quiet mode (for terminals the TUI cannot draw on)")
+}
+
+// runPromptMode fills in the missing inputs from plain questions and runs quiet mode
+// Questions go to stderr so that stdout stays the output of quiet mode
+func runPromptMode() error {
+	if quietMode {
+		return invalidInput(fmt.Errorf("--prompt cannot be combined with --quiet"))
+	}
+	if contentPath == stdioPath {
+		return invalidInput(fmt.Errorf("--prompt reads answers from stdin and cannot be combined with --content -"))
+	}
+	profile, err := activeProfile()
+	if err != nil {
+		return invalidInput(err)
+	}
+	outputPath = firstNonEmpty(outputPath, profile.Output)
+
+	p := tui.NewLinePrompter(context.Background(), os.Stdin, os.Stderr)
+	defer p.Close()
+	askInputs(p)
+	return runQuietMode()
+}
+
+// askInputs asks for the source folder, setup file and output folder that are not set
+// When the input ends, the remaining inputs stay empty and quiet mode reports them
+func askInputs(p *tui.LinePrompter) {
+	var err error
+	if contentPath == "" {
+		if contentPath, err = p.AskSource(i18n.T("Source folder (the folder with the app files)"), ""); err != nil {
+			return
+		}
+	}
+	// --split-arch finds the setup file of each architecture itself
+	if setupFile == "" && !splitArch {
+		if setupFile, err = p.AskSetupFile(i18n.T("Setup file (a number from the list or a file name)"), contentPath, ""); err != nil {
+			return
+		}
+	}
+	if outputPath == "" {
+		outputPath, _ = p.AskOutput(i18n.T("Output folder (where the .intunewin file is saved)"), "")
+	}
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/catalog"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// sasTokenEnv holds the SAS token for blob destinations given without one,
+// keeping it out of shell history and CI logs
+const sasTokenEnv = "AZURE_STORAGE_SAS_TOKEN"
+
+var (
+	publishSync   bool
+	publishKeep   int
+	publishDryRun bool
+)
+
+var publishCmd = &cobra.Command{
+	Use:   "publish <catalog-folder> <destination>",
+	Short: "Publish a folder of packages to Azure Blob, S3 or a file share",
+	Long: `Publish the .intunewin packages of a local catalog folder (and its subfolders)
+to a remote repository and update the repository's index.json.
+
+The destination is selected by its URL scheme:
+  https://<account>.blob.core.windows.net/<container>?<SAS>
+                        Azure Blob container (or folder of one) with a SAS token
+                        (read, write, delete); the token can also be passed in
+                        the ` + sasTokenEnv + ` environment variable
+  azblob://<account>/<container>[/<folder>][?client_id=<id>]
+                        Azure Blob with the managed identity of the machine
+                        (Storage Blob Data Contributor on the container)
+  s3://<bucket>[/<prefix>][?endpoint=<url>&region=<region>]
+                        S3 or S3-compatible storage (MinIO, Ceph, Wasabi);
+                        credentials from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY
+                        and AWS_SESSION_TOKEN, endpoint from AWS_ENDPOINT_URL
+  smb://<server>/<share>/<path> (Windows), file:///<path>, \\server\share\path
+                        or a folder: file share or local folder
+
+Packages are stored by their SHA256 hash, so a rebuilt package never
+overwrites the version it supersedes. Without --sync every package is
+uploaded. With --sync only new or changed packages are uploaded, content
+that was published before is referenced instead of uploaded again, and all
+but the newest --keep versions of each package are pruned.
+
+The index is replaced in one conditional write after all uploads, and pruned
+packages are deleted only afterwards, so readers never see an index listing
+missing packages. If another publish updated the index meanwhile, nothing is
+pruned and the command fails; run it again.
+
+Examples:
+  intunewin publish ./output \\fileserver\intune\catalog --sync
+  AZURE_STORAGE_SAS_TOKEN='sv=...' intunewin publish ./output https://contoso.blob.core.windows.net/catalog --sync --keep 5
+  intunewin publish ./output azblob://contoso/catalog --sync
+  intunewin publish ./output "s3://intune-packages/catalog?endpoint=https://minio.contoso.com:9000" --sync
+  intunewin publish ./output \\fileserver\intune\catalog --sync --dry-run`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPublish(args[0], args[1])
+	},
+}
+
+func init() {
+	publishCmd.Flags().BoolVar(&publishSync, "sync", false, "Upload only new or changed packages and prune superseded versions")
+	publishCmd.Flags().IntVar(&publishKeep, "keep", catalog.DefaultKeep, "Versions kept per package when syncing (0 keeps all)")
+	publishCmd.Flags().BoolVar(&publishDryRun, "dry-run", false, "Show what would be uploaded and pruned without changing the repository")
+	addTimeoutFlag(publishCmd.Flags())
+	rootCmd.AddCommand(publishCmd)
+}
+
+func runPublish(localDir, dest string) error {
+	if publishKeep < 0 {
+		return invalidInput(fmt.Errorf("--keep must not be negative"))
+	}
+	if info, err := os.Stat(localDir); err != nil || !info.IsDir() {
+		return withExitCode(exitSourceMissing, fmt.Errorf("catalog folder does not exist: %s", localDir))
+	}
+
+	store, err := openRepository(dest)
+	if err != nil {
+		return invalidInput(err)
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	report, err := catalog.Publish(ctx, localDir, store, catalog.Options{
+		Sync:      publishSync,
+		Keep:      publishKeep,
+		DryRun:    publishDryRun,
+		HashCache: fileHashCache(),
+	})
+	if err != nil {
+		return uploadFailed(fmt.Errorf("publish failed: %w", err))
+	}
+
+	printPublishReport(report, store.String())
+	return nil
+}
+
+// openRepository opens a catalog repository, taking a missing SAS token of a blob
+// container URL from the environment
+func openRepository(dest string) (catalog.Store, error) {
+	if strings.HasPrefix(dest, "https://") && !strings.Contains(dest, "?") {
+		if token := os.Getenv(sasTokenEnv); token != "" {
+			dest += "?" + strings.TrimPrefix(token, "?")
+		}
+	}
+	return catalog.OpenStore(dest, httpTransport)
+}
+
+// printPublishReport prints one line per package change and a summary
+func printPublishReport(report *catalog.Report, dest string) {
+	verb := "Published to"
+	if publishDryRun {
+		verb = "Dry run, nothing written to"
+	}
+	fmt.Printf("%s %s\n\n", verb, dest)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, e := range report.Uploaded {
+		fmt.Fprintf(w, "  uploaded\t%s\t%s\t%s\n", e.Name, packager.FormatSize(e.Size), e.SHA256[:12])
+	}
+	for _, e := range report.Reused {
+		fmt.Fprintf(w, "  reused\t%s\t%s\t%s\n", e.Name, packager.FormatSize(e.Size), e.SHA256[:12])
+	}
+	for _, e := range report.Relicensed {
+		fmt.Fprintf(w, "  relicensed\t%s\t%s\t%s\n", e.Name, packager.FormatSize(e.Size), e.SHA256[:12])
+	}
+	for _, e := range report.Pruned {
+		fmt.Fprintf(w, "  pruned\t%s\t%s\t%s\n", e.Name, packager.FormatSize(e.Size), e.SHA256[:12])
+	}
+	w.Flush()
+
+	if len(report.Uploaded)+len(report.Reused)+len(report.Relicensed)+len(report.Pruned) > 0 {
+		fmt.Println()
+	}
+	fmt.Printf("%d uploaded, %d reused, %d unchanged, %d relicensed, %d pruned\n",
+		len(report.Uploaded), len(report.Reused), len(report.Unchanged), len(report.Relicensed), len(report.Pruned))
+}
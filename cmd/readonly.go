@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/i18n"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// readOnlyEnv turns on read-only mode for every run, e.g. on training machines
+const readOnlyEnv = "INTUNEWIN_READ_ONLY"
+
+// readOnly blocks filesystem writes, uploads and catalog or tenant changes
+var readOnly bool
+
+// readOnlyCommands only read files, packages, catalogs or the tenant
+var readOnlyCommands = map[string]bool{
+	"":                           true, // interactive mode reviews packages, quiet mode previews them
+	"analyze":                    true,
+	"apps list":                  true,
+	"apps content-versions list": true,
+	"catalog list":               true,
+	"diff":                       true,
+	"explain-format":             true,
+	"footprint":                  true,
+	"hash":                       true,
+	"help":                       true,
+	"history list":               true,
+	"inspect":                    true,
+	"lint":                       true,
+	"secrets list":               true,
+	"mock-graph":                 true,
+	"update":                     true,
+	"validate-spec":              true,
+	"verify":                     true,
+	"worker status":              true,
+}
+
+// readOnlyDryRuns are commands that change a catalog or the tenant, with the flag
+// that makes them only report what they would change
+var readOnlyDryRuns = map[string]string{
+	"apps assign":                    "what-if",
+	"apps content-versions activate": "what-if",
+	"apps create":                    "what-if",
+	"apps relate":                    "what-if",
+	"import-and-upload":              "what-if",
+	"import-app":                     "what-if",
+	"publish":                        "dry-run",
+}
+
+// readOnlyWriteFlags are flags of read-only commands that write files
+var readOnlyWriteFlags = map[string][]string{
+	"diff":      {"output"},
+	"footprint": {"output"},
+	"update":    {"install"},
+}
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&readOnly, "read-only", false, "Browse, inspect and dry-run only: block file writes, uploads and catalog or tenant changes (env "+readOnlyEnv+")")
+}
+
+// enforceReadOnly checks a command against read-only mode before it runs
+// Commands with a dry run are switched to it, commands and flags that write are refused
+func enforceReadOnly(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("read-only") {
+		if value := os.Getenv(readOnlyEnv); value != "" {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s value: %s", readOnlyEnv, value)
+			}
+			readOnly = enabled
+		}
+	}
+	if !readOnly {
+		return nil
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()), " ")
+	if cmd.Flags().Changed("log-file") {
+		return fmt.Errorf("--log-file is not available in read-only mode")
+	}
+	if flag, ok := readOnlyDryRuns[name]; ok {
+		if !cmd.Flags().Changed(flag) {
+			if err := cmd.Flags().Set(flag, "true"); err != nil {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, i18n.Tf("Read-only mode: running %s with --%s", name, flag))
+		}
+		return nil
+	}
+	if !readOnlyCommands[name] && !strings.HasPrefix(name, "completion") && !strings.HasPrefix(name, "__complete") {
+		return fmt.Errorf("%s is not available in read-only mode, as it writes files or changes Intune", name)
+	}
+	for _, flag := range readOnlyWriteFlags[name] {
+		if cmd.Flags().Changed(flag) {
+			return fmt.Errorf("%s --%s is not available in read-only mode", name, flag)
+		}
+	}
+	return nil
+}
+
+// previewQuietMode reports the package a quiet mode run would create without writing it
+func previewQuietMode(sourcePath, setupFile, outputPath string, opts packager.Options) error {
+	preview, err := packager.PreviewPackage(sourcePath, setupFile, outputPath, opts)
+	if err != nil {
+		return err
+	}
+	printPreview(preview)
+	return nil
+}
+
+// printPreview prints what a package would contain, as printPackageResult does for created ones
+func printPreview(p *packager.Preview) {
+	fmt.Println()
+	fmt.Println(i18n.T("Read-only mode: the package was not created"))
+	w := newSummaryWriter()
+	defer w.Flush()
+	printField(w, i18n.T("Output"), p.OutputPath)
+	printField(w, i18n.T("Files"), fmt.Sprint(p.FileCount))
+	printField(w, i18n.T("Source"), packager.FormatSize(p.SourceSize))
+	printField(w, i18n.T("Estimate"), packager.FormatSize(p.EstimatedSize))
+	printField(w, i18n.T("Generator"), i18n.Tf("version %d", packager.GeneratorVersion))
+	if msi := p.MsiInfo; msi != nil {
+		printField(w, "MSI", fmt.Sprintf("%s %s (%s)", valueOrDash(msi.ProductName), valueOrDash(msi.ProductVersion), valueOrDash(msi.ProductCode)))
+	} else if p.MsiError != nil {
+		printField(w, "MSI", i18n.Tf("metadata could not be read: %v", p.MsiError))
+	}
+	if p.MspError != nil {
+		printField(w, "MSP", i18n.Tf("metadata could not be read: %v", p.MspError))
+	}
+	printMspInfo(w, p.MspInfo)
+	if p.InstallCommand != "" {
+		printField(w, i18n.T("Install"), p.InstallCommand)
+	}
+	printMsiSuite(w, p.MsiSuite)
+	if len(p.RiskyCustomActions) > 0 {
+		names := make([]string, len(p.RiskyCustomActions))
+		for i, ca := range p.RiskyCustomActions {
+			names[i] = ca.Action
+		}
+		printField(w, i18n.T("Warning"), i18n.Tf("high-risk MSI custom actions: %s", strings.Join(names, ", ")))
+	}
+	if p.SizeWarning != "" {
+		printField(w, i18n.T("Warning"), p.SizeWarning)
+	}
+	printSkippedLinks(w, p.SkippedLinks)
+}
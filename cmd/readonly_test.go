@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// commandName returns the name enforceReadOnly looks a command up by
+func commandName(cmd *cobra.Command) string {
+	return strings.TrimPrefix(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()), " ")
+}
+
+// allCommands returns the root command and all of its subcommands
+func allCommands(cmd *cobra.Command) []*cobra.Command {
+	cmds := []*cobra.Command{cmd}
+	for _, sub := range cmd.Commands() {
+		cmds = append(cmds, allCommands(sub)...)
+	}
+	return cmds
+}
+
+// resetFlag restores a flag changed by a test to its default
+func resetFlag(t *testing.T, cmd *cobra.Command, name string) {
+	t.Helper()
+	flag := cmd.Flags().Lookup(name)
+	if err := flag.Value.Set(flag.DefValue); err != nil {
+		t.Fatalf("Failed to reset --%s: %v", name, err)
+	}
+	flag.Changed = false
+}
+
+// setReadOnly turns on read-only mode for the test
+func setReadOnly(t *testing.T) {
+	t.Helper()
+	t.Setenv(readOnlyEnv, "")
+	readOnly = true
+	t.Cleanup(func() { readOnly = false })
+}
+
+func TestReadOnlyCommands(t *testing.T) {
+	setReadOnly(t)
+	rootCmd.InitDefaultHelpCmd()
+	rootCmd.InitDefaultCompletionCmd()
+
+	// Every command is allowed, switched to its dry run or refused
+	seen := map[string]bool{}
+	for _, cmd := range allCommands(rootCmd) {
+		name := commandName(cmd)
+		seen[name] = true
+		// Cobra merges the flags of the parent commands when it parses them, before the run
+		if err := cmd.ParseFlags(nil); err != nil {
+			t.Fatalf("Failed to parse the flags of %q: %v", name, err)
+		}
+		err := enforceReadOnly(cmd)
+		switch flag, dryRun := readOnlyDryRuns[name]; {
+		case dryRun:
+			if err != nil {
+				t.Errorf("%q: enforceReadOnly() error = %v, want its dry run", name, err)
+				continue
+			}
+			if value := cmd.Flags().Lookup(flag).Value.String(); value != "true" {
+				t.Errorf("%q: --%s = %s, want true", name, flag, value)
+			}
+			resetFlag(t, cmd, flag)
+		case readOnlyCommands[name], strings.HasPrefix(name, "completion"):
+			if err != nil {
+				t.Errorf("%q: enforceReadOnly() error = %v, want it allowed", name, err)
+			}
+		default:
+			if err == nil || !strings.Contains(err.Error(), "not available in read-only mode") {
+				t.Errorf("%q: enforceReadOnly() error = %v, want it refused", name, err)
+			}
+		}
+	}
+
+	// The allowlists only name existing commands, so that a rename does not refuse one
+	for name := range readOnlyCommands {
+		if !seen[name] {
+			t.Errorf("readOnlyCommands lists %q, which is not a command", name)
+		}
+	}
+	for name := range readOnlyDryRuns {
+		if !seen[name] {
+			t.Errorf("readOnlyDryRuns lists %q, which is not a command", name)
+		}
+	}
+}
+
+func TestReadOnlyWriteFlags(t *testing.T) {
+	setReadOnly(t)
+
+	for name, flags := range readOnlyWriteFlags {
+		cmd, _, err := rootCmd.Find(strings.Fields(name))
+		if err != nil || commandName(cmd) != name {
+			t.Errorf("readOnlyWriteFlags lists %q, which is not a command", name)
+			continue
+		}
+		if err := cmd.ParseFlags(nil); err != nil {
+			t.Fatalf("Failed to parse the flags of %q: %v", name, err)
+		}
+		if !readOnlyCommands[name] {
+			t.Errorf("readOnlyWriteFlags lists %q, which is not a read-only command", name)
+		}
+		for _, flag := range flags {
+			f := cmd.Flags().Lookup(flag)
+			if f == nil {
+				t.Errorf("%q has no --%s flag", name, flag)
+				continue
+			}
+			value := "out"
+			if f.Value.Type() == "bool" {
+				value = "true"
+			}
+			if err := cmd.Flags().Set(flag, value); err != nil {
+				t.Fatalf("Failed to set --%s: %v", flag, err)
+			}
+			err := enforceReadOnly(cmd)
+			resetFlag(t, cmd, flag)
+			if err == nil || !strings.Contains(err.Error(), "--"+flag) {
+				t.Errorf("%s --%s: enforceReadOnly() error = %v, want it refused", name, flag, err)
+			}
+		}
+		if err := enforceReadOnly(cmd); err != nil {
+			t.Errorf("%q without write flags: enforceReadOnly() error = %v", name, err)
+		}
+	}
+}
+
+func TestReadOnlyEnv(t *testing.T) {
+	tests := []struct {
+		name         string
+		value        string
+		wantReadOnly bool
+		wantErr      bool
+	}{
+		{name: "unset", value: "", wantReadOnly: false},
+		{name: "true", value: "1", wantReadOnly: true},
+		{name: "false", value: "false", wantReadOnly: false},
+		{name: "invalid", value: "sometimes", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(readOnlyEnv, tt.value)
+			readOnly = false
+			t.Cleanup(func() { readOnly = false })
+
+			// The root command is allowed either way, so only the mode changes
+			err := enforceReadOnly(rootCmd)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("enforceReadOnly() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if readOnly != tt.wantReadOnly {
+				t.Errorf("readOnly = %v, want %v", readOnly, tt.wantReadOnly)
+			}
+		})
+	}
+}
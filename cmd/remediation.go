@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+var (
+	remediationPackage string
+	remediationName    string
+	remediationVersion string
+	remediationAction  string
+	remediationOutput  string
+)
+
+var remediationCmd = &cobra.Command{
+	Use:   "remediation",
+	Short: "Generate Intune Remediations scripts for a package",
+	Long: `Generate a paired detection/remediation PowerShell script set for Intune
+Remediations from the metadata of a .intunewin package.
+
+The detection script compares the installed version (from the Uninstall
+registry keys) with the packaged version and exits with 1 when the app is
+missing or older. The remediation script then either restarts the Intune
+Management Extension so the Win32 app is re-evaluated and reinstalled
+(--action sync) or only writes an event log entry (--action log).
+
+MSI packages are matched by product code and version from Detection.xml.
+For other installers, pass --version (and --name if the Add/Remove Programs
+name differs from the package name).
+
+Examples:
+  intunewin remediation --package ./output/7z2401-x64.intunewin
+  intunewin remediation --package ./output/setup.intunewin --name "Contoso App" --version 2.1.0 --action log`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRemediation()
+	},
+}
+
+func init() {
+	remediationCmd.Flags().StringVar(&remediationPackage, "package", "", "Path to the .intunewin package")
+	remediationCmd.Flags().StringVar(&remediationName, "name", "", "Display name in Add/Remove Programs (default: package name)")
+	remediationCmd.Flags().StringVar(&remediationVersion, "version", "", "Packaged version (default: MSI product version)")
+	remediationCmd.Flags().StringVar(&remediationAction, "action", "sync", "Remediation action: sync (trigger reinstall) or log")
+	remediationCmd.Flags().StringVarP(&remediationOutput, "output", "o", "", "Folder for the scripts (default: package folder)")
+	rootCmd.AddCommand(remediationCmd)
+}
+
+func runRemediation() error {
+	if remediationPackage == "" {
+		return fmt.Errorf("--package is required")
+	}
+
+	action, err := packager.ParseRemediationAction(remediationAction)
+	if err != nil {
+		return err
+	}
+
+	appInfo, err := packager.ReadDetectionXML(remediationPackage)
+	if err != nil {
+		return err
+	}
+
+	params := packager.RemediationParamsFromDetection(appInfo)
+	params.Name = firstNonEmpty(remediationName, params.Name)
+	params.Version = firstNonEmpty(remediationVersion, params.Version)
+	params.Action = action
+	if params.Version == "" {
+		return fmt.Errorf("--version is required for non-MSI packages")
+	}
+
+	detect, remediate, err := packager.GenerateRemediationScripts(params)
+	if err != nil {
+		return err
+	}
+
+	outDir := firstNonEmpty(remediationOutput, filepath.Dir(remediationPackage))
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	base := packager.ScriptBaseName(params.Name)
+	detectPath := filepath.Join(outDir, "Detect-"+base+".ps1")
+	remediatePath := filepath.Join(outDir, "Remediate-"+base+".ps1")
+	if err := os.WriteFile(detectPath, detect, 0644); err != nil {
+		return fmt.Errorf("failed to write detection script: %w", err)
+	}
+	if err := os.WriteFile(remediatePath, remediate, 0644); err != nil {
+		return fmt.Errorf("failed to write remediation script: %w", err)
+	}
+
+	fmt.Printf("Generated remediation scripts for %s %s (%s)\n", params.Name, params.Version, params.Action)
+	fmt.Printf("  Detection:   %s\n", detectPath)
+	fmt.Printf("  Remediation: %s\n", remediatePath)
+	return nil
+}
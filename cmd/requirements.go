@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/spec"
+)
+
+var (
+	writeRequirements bool
+	minWindowsRelease string
+	diskSpaceFactor   float64
+)
+
+func init() {
+	rootCmd.Flags().BoolVar(&writeRequirements, "requirements", false, "Write Intune requirement rules (architecture, minimum Windows release, free disk space) to <name>.intunewin"+packager.RequirementsSuffix)
+	rootCmd.Flags().StringVar(&minWindowsRelease, "min-windows-release", "", "Minimum Windows 10/11 release of the requirement rules, e.g. 21H2 (implies --requirements)")
+	rootCmd.Flags().Float64Var(&diskSpaceFactor, "disk-space-factor", packager.DefaultDiskSpaceFactor, "Source size multiplier giving the minimum free disk space of the requirement rules")
+}
+
+// requirementsOptions returns the requirement rule settings of --requirements and
+// --min-windows-release, nil when neither is set
+func requirementsOptions() (*packager.RequirementsOptions, error) {
+	if !writeRequirements && minWindowsRelease == "" {
+		return nil, nil
+	}
+	opts := &packager.RequirementsOptions{
+		MinimumWindowsRelease: minWindowsRelease,
+		DiskSpaceFactor:       diskSpaceFactor,
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// applyPackageRequirements fills the architectures and requirements an app spec leaves
+// unset from the requirement rules written alongside its package, if any
+func applyPackageRequirements(appSpec *spec.AppSpec) error {
+	req, err := packager.ReadRequirements(appSpec.Package)
+	if err != nil || req == nil {
+		return err
+	}
+	if appSpec.Architectures == "" {
+		appSpec.Architectures = req.Architectures
+	}
+	if appSpec.Requirements == nil {
+		appSpec.Requirements = &spec.RequirementsSpec{}
+	}
+	if appSpec.Requirements.MinimumWindowsRelease == "" {
+		appSpec.Requirements.MinimumWindowsRelease = req.MinimumWindowsRelease
+	}
+	if appSpec.Requirements.MinimumFreeDiskSpaceMB == 0 {
+		appSpec.Requirements.MinimumFreeDiskSpaceMB = req.MinimumFreeDiskSpaceMB
+	}
+	return nil
+}
+
+// requirementsSummary returns a one-line summary of requirement rules such as
+// "x64, Windows 21H2 or later, 300 MB free disk space"
+func requirementsSummary(req *packager.Requirements) string {
+	summary := fmt.Sprintf("%d MB free disk space", req.MinimumFreeDiskSpaceMB)
+	if req.MinimumWindowsRelease != "" {
+		summary = fmt.Sprintf("Windows %s or later, %s", req.MinimumWindowsRelease, summary)
+	}
+	if req.Architectures != "" {
+		summary = req.Architectures + ", " + summary
+	}
+	return summary
+}
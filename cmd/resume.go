@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+var resumeList bool
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume [run-id]",
+	Short: "Continue an interrupted --resumable packaging run",
+	Long: `Continue a packaging run that was started with --resumable and did not finish.
+
+Completed phases (compression, encryption) are reused as long as the source
+folder is unchanged; otherwise the run starts over. Without a run ID the most
+recent interrupted run is resumed.
+
+Examples:
+  # List interrupted runs
+  intunewin resume --list
+
+  # Resume the most recent interrupted run
+  intunewin resume`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if resumeList {
+			return runResumeList()
+		}
+		runID := ""
+		if len(args) == 1 {
+			runID = args[0]
+		}
+		return runResume(runID)
+	},
+}
+
+func init() {
+	resumeCmd.Flags().BoolVar(&resumeList, "list", false, "List interrupted runs instead of resuming")
+	addTimeoutFlag(resumeCmd.Flags())
+	rootCmd.AddCommand(resumeCmd)
+}
+
+func runResumeList() error {
+	states, err := loadRunStates()
+	if err != nil {
+		return err
+	}
+	if len(states) == 0 {
+		fmt.Println("No interrupted runs found.")
+		return nil
+	}
+
+	for _, state := range states {
+		fmt.Printf("%s  %-10s  %s  %s/%s -> %s\n",
+			runIDOf(state),
+			state.Phase,
+			state.UpdatedAt.Format("2006-01-02 15:04"),
+			state.SourcePath, state.SetupFile, state.OutputPath)
+	}
+	return nil
+}
+
+func runResume(runID string) error {
+	states, err := loadRunStates()
+	if err != nil {
+		return err
+	}
+	if len(states) == 0 {
+		return invalidInput(fmt.Errorf("no interrupted runs found"))
+	}
+
+	state := states[0]
+	if runID != "" {
+		state = nil
+		for _, s := range states {
+			if strings.HasPrefix(runIDOf(s), runID) {
+				state = s
+				break
+			}
+		}
+		if state == nil {
+			return invalidInput(fmt.Errorf("interrupted run not found: %s", runID))
+		}
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	opts, err := packagingOptions(ctx)
+	if err != nil {
+		return inputError(err)
+	}
+	opts.CheckpointRoot = checkpointRootOf(state)
+	opts.Exclude = state.Exclude
+	opts.Symlinks = state.Symlinks
+	opts.NormalizePermissions = state.NormalizePermissions
+	opts.Compression = state.Compression
+	opts.License = state.License
+	notifier, err := newNotifier()
+	if err != nil {
+		return invalidInput(err)
+	}
+	defer notifier.Close()
+
+	fmt.Println("Resuming packaging run...")
+	fmt.Printf("  Source: %s\n", state.SourcePath)
+	fmt.Printf("  Setup:  %s\n", state.SetupFile)
+	fmt.Printf("  Output: %s\n", state.OutputPath)
+	fmt.Println()
+
+	result, err := packageNotified(ctx, notifier, state.SourcePath, state.SetupFile, state.OutputPath, opts, func(step string, pct float64) {
+		fmt.Printf("  [%3.0f%%] %s\n", pct*100, step)
+	})
+	if err != nil {
+		return packagingFailed(fmt.Errorf("packaging failed: %w", err))
+	}
+
+	printPackageResult(result)
+	return nil
+}
+
+// loadRunStates lists interrupted runs in the default checkpoint folder
+func loadRunStates() ([]*packager.RunState, error) {
+	root, err := packager.DefaultCheckpointRoot()
+	if err != nil {
+		return nil, err
+	}
+	return packager.ListRunStates(root)
+}
+
+// runIDOf returns the user-facing ID of a run (its checkpoint folder name)
+func runIDOf(state *packager.RunState) string {
+	return filepath.Base(state.Dir)
+}
+
+// checkpointRootOf returns the checkpoint root a run was stored under
+func checkpointRootOf(state *packager.RunState) string {
+	return filepath.Dir(state.Dir)
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/graph"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/spec"
+)
+
+var createReturnCodes []string
+
+// applyReturnCodes merges the return codes of the profile and --return-code into those
+// of an app spec, or into the Intune defaults when the spec has none
+// An app with return codes has only those, so the defaults are kept unless overridden
+func applyReturnCodes(appSpec *spec.AppSpec) error {
+	profile, err := activeProfile()
+	if err != nil {
+		return err
+	}
+
+	var overrides []graph.ReturnCode
+	for code, typ := range profile.ReturnCodes {
+		t, err := graph.ParseReturnCodeType(typ)
+		if err != nil {
+			return fmt.Errorf("return code %d of the profile: %w", code, err)
+		}
+		overrides = append(overrides, graph.ReturnCode{Code: code, Type: t})
+	}
+	for _, s := range createReturnCodes {
+		rc, err := graph.ParseReturnCode(s)
+		if err != nil {
+			return err
+		}
+		overrides = append(overrides, rc)
+	}
+	if len(overrides) == 0 {
+		return nil
+	}
+
+	base := graph.DefaultReturnCodes
+	if len(appSpec.ReturnCodes) > 0 {
+		base = nil
+		for _, rc := range appSpec.ReturnCodes {
+			t, err := graph.ParseReturnCodeType(rc.Type)
+			if err != nil {
+				return err
+			}
+			base = append(base, graph.ReturnCode{Code: rc.Code, Type: t})
+		}
+	}
+
+	appSpec.ReturnCodes = nil
+	for _, rc := range graph.MergeReturnCodes(base, overrides) {
+		appSpec.ReturnCodes = append(appSpec.ReturnCodes, spec.ReturnCodeSpec{Code: rc.Code, Type: rc.Type})
+	}
+	return nil
+}
@@ -1,11 +1,23 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/config"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/hooks"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/i18n"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/listing"
 	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
 	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/tui"
 )
@@ -20,6 +32,70 @@ var (
 	setupFile   string
 	outputPath  string
 	quietMode   bool
+
+	// Interactive mode without decorations, for screen readers
+	noTUIDecorations bool
+
+	// Diagnostics flags
+	tracePath      string
+	traceThreshold time.Duration
+
+	// Resumable runs
+	resumable bool
+
+	// Content store shared across packages
+	useContentStore bool
+
+	// Delta packaging against the previous version
+	deltaFrom string
+	deltaKeys string
+
+	// Reproducible builds
+	reproducible     bool
+	reproducibleSeed string
+
+	// Externally supplied and exported keys
+	encryptionKeyFile string
+	exportKeysPath    string
+
+	// Output writing
+	verifyOutput bool
+	stageOutput  bool
+	lowMemory    bool
+
+	// Size limits
+	maxSizeFlag  string
+	warnSizeFlag string
+
+	// Run events
+	webhookURL string
+
+	// License record
+	licenseType       string
+	licenseID         string
+	licenseSeats      int
+	licenseEULA       string
+	licenseAcceptedBy string
+
+	// Packaging flags
+	excludePatterns []string
+	writeManifest   bool
+	requireSigned   bool
+	splitArch       bool
+	partSizeFlag    string
+	setupArgs       string
+	noMsiSuite      bool
+	symlinkPolicy   string
+	normalizePerms  bool
+	compression     string
+	lockRetries     int
+	preHooks        []string
+	postHooks       []string
+
+	// Detection.xml overrides
+	toolVersion         string
+	msiExecutionContext string
+	msiRequiresReboot   bool
 )
 
 // SetVersionInfo sets the version information from main
@@ -42,93 +118,675 @@ Examples:
   intunewin
 
   # Quiet mode for CI/CD automation
-  intunewin -c /path/to/source -s setup.msi -o /path/to/output -q`,
+  intunewin -c /path/to/source -s setup.msi -o /path/to/output -q
+
+Exit codes:
+  0 success, 1 other error, 2 invalid input, 3 source missing,
+  4 packaging failed, 5 upload failed, 6 verification failed, 7 timed out`,
 	Version: version,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyProfileFlags(cmd); err != nil {
+			return invalidInput(err)
+		}
+		if err := setupLanguage(cmd); err != nil {
+			return invalidInput(err)
+		}
+		if err := enforceReadOnly(cmd); err != nil {
+			return invalidInput(err)
+		}
+		if err := setupLogging(); err != nil {
+			return invalidInput(err)
+		}
+		if err := setupNetwork(); err != nil {
+			return invalidInput(err)
+		}
+		commandStarted = true
+		return nil
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		closeLogging()
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if versionCheck {
+			return runVersionCheck()
+		}
+		if promptMode {
+			return runPromptMode()
+		}
 		if quietMode {
 			return runQuietMode()
 		}
+		if splitArch {
+			return invalidInput(fmt.Errorf("--split-arch requires quiet mode (-q)"))
+		}
+		if partSizeFlag != "" {
+			return invalidInput(fmt.Errorf("--part-size requires quiet mode (-q)"))
+		}
+		if cmd.Flags().Changed("timeout") {
+			return invalidInput(fmt.Errorf("--timeout requires quiet mode (-q)"))
+		}
 		return runTUI()
 	},
 }
 
-// Execute runs the root command
+// Execute runs the root command and exits with the exit code of its error
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+	defer recoverCrash()
+	err := rootCmd.Execute()
+	saveHashCache()
+	if err != nil {
+		os.Exit(exitCode(err))
 	}
 }
 
 func init() {
-	rootCmd.Flags().StringVarP(&contentPath, "content", "c", "", "Source folder containing the setup file")
+	rootCmd.Flags().StringVarP(&contentPath, "content", "c", "", "Source folder containing the setup file, or - for a tar or ZIP stream on stdin")
 	rootCmd.Flags().StringVarP(&setupFile, "setup", "s", "", "Setup file name (e.g., setup.msi or install.exe)")
-	rootCmd.Flags().StringVarP(&outputPath, "output", "o", "", "Output folder for the .intunewin file")
+	rootCmd.Flags().StringVarP(&outputPath, "output", "o", "", "Output folder for the .intunewin file, or - to write it to stdout")
 	rootCmd.Flags().BoolVarP(&quietMode, "quiet", "q", false, "Quiet mode - no interactive UI, just process and exit")
+	rootCmd.Flags().BoolVar(&noTUIDecorations, "no-tui-decorations", false, "Interactive mode with numbered prompts and plain text progress, without logo, boxes or colors (for screen readers)")
+	rootCmd.Flags().StringVar(&tracePath, "trace", "", "Write a Chrome trace (JSON) of packaging phases to this file")
+	rootCmd.Flags().DurationVar(&traceThreshold, "trace-threshold", packager.DefaultTraceFileThreshold, "Minimum duration for per-file operations to appear in the trace")
+	rootCmd.Flags().BoolVar(&showTimings, "timings", false, "Print the time spent in each packaging phase (walk, compress, encrypt, write, ...)")
+	rootCmd.Flags().BoolVar(&resumable, "resumable", false, "Checkpoint completed phases so an interrupted run can be continued with 'resume'")
+	rootCmd.Flags().BoolVar(&useContentStore, "content-store", false, "Reuse compressed data of large files packaged before (shared runtimes) from a local content store")
+	rootCmd.Flags().StringVar(&deltaFrom, "delta-from", "", "Previous .intunewin of the app; unchanged files reuse its compressed data instead of being compressed again")
+	rootCmd.Flags().StringVar(&deltaKeys, "delta-keys", "", "Detection.xml or key export holding the keys of the --delta-from package (default: its own Detection.xml)")
+	rootCmd.Flags().BoolVar(&reproducible, "reproducible", false, "Fix file times to "+packager.SourceDateEpochEnv+" and file modes so rebuilding the same source gives the same content digest")
+	rootCmd.Flags().StringVar(&reproducibleSeed, "seed", "", "Secret to derive encryption keys from, making reproducible packages byte-identical (env "+seedEnv+")")
+	rootCmd.Flags().StringVar(&encryptionKeyFile, "encryption-key-file", "", "JSON file with the base64 encryptionKey and macKey to encrypt with, e.g. from a KMS (or env "+encryptionKeyEnv+" and "+macKeyEnv+")")
+	rootCmd.Flags().StringVar(&exportKeysPath, "export-keys", "", "Save the package keys to this file, encrypted with the passphrase in "+passphraseEnv)
+	rootCmd.Flags().BoolVar(&verifyOutput, "verify-output", false, "Read the written package back and compare size and SHA256, retrying on mismatch (automatic on network shares)")
+	rootCmd.Flags().BoolVar(&stageOutput, "stage-output", false, "Write the package to a local staging folder first, then copy it to the output folder")
+	rootCmd.Flags().BoolVar(&lowMemory, "low-memory", false, "Stream compression and encryption through temporary files to keep memory use flat (automatic for sources over 1 GB)")
+	rootCmd.Flags().StringVar(&maxSizeFlag, "max-size", "", "Abort before compressing when the estimated package exceeds this size, e.g. 4GB (default 30GB, the Intune limit)")
+	rootCmd.Flags().StringVar(&warnSizeFlag, "warn-size", "", "Warn when the estimated package exceeds this size (default 8GB)")
+	rootCmd.Flags().StringVar(&webhookURL, "webhook", "", "POST JSON events (started, progress, completed, failed) to this URL (env "+webhookEnv+"; bodies signed with "+webhookSecretEnv+")")
+	rootCmd.Flags().StringVar(&licenseType, "license-type", "", "License model recorded with the package (e.g., per-device, per-user, site, freeware)")
+	rootCmd.Flags().StringVar(&licenseID, "license-id", "", "Internal license or contract ID recorded with the package")
+	rootCmd.Flags().IntVar(&licenseSeats, "license-seats", 0, "Number of purchased seats recorded with the package")
+	rootCmd.Flags().StringVar(&licenseEULA, "eula", "", "URL or document reference of the license agreement")
+	rootCmd.Flags().StringVar(&licenseAcceptedBy, "eula-accepted-by", "", "Who acknowledged the EULA for the organization (requires --eula)")
+	rootCmd.Flags().StringArrayVar(&excludePatterns, "exclude", nil, "Glob pattern of files or folders to leave out of the package (repeatable, e.g. '*.log')")
+	rootCmd.Flags().StringVar(&symlinkPolicy, "symlinks", string(packager.SymlinkFollow), "How to package symbolic links and junctions in the source folder: follow, skip or error")
+	rootCmd.Flags().BoolVar(&normalizePerms, "normalize-permissions", false, "Store mode 0755 for folders and executables and 0644 for other files instead of the modes of the source")
+	rootCmd.Flags().StringVar(&compression, "compression", string(packager.CompressionDefault), "Compression of the content: store, fast, default or best (files already compressed are stored unless store)")
+	rootCmd.Flags().IntVar(&lockRetries, "lock-retries", 0, "Check source files locked by another process (e.g. an antivirus scan) again this many times, waiting 1s, 2s, 4s, ... before failing")
+	rootCmd.Flags().StringArrayVar(&preHooks, "pre-hook", nil, "Shell command run before packaging, e.g. a script fetching the installer (repeatable; INTUNEWIN_* variables describe the job)")
+	rootCmd.Flags().StringArrayVar(&postHooks, "post-hook", nil, "Shell command run after packaging, e.g. a signing script or a malware scan; a failure fails the run (repeatable; INTUNEWIN_* variables describe the job and its result)")
+	rootCmd.Flags().BoolVar(&writeManifest, "manifest", false, "Write a list of packed files with sizes and SHA256/SHA1 hashes next to the .intunewin")
+	rootCmd.Flags().BoolVar(&splitArch, "split-arch", false, "Package x86/, x64/ and arm64/ subfolders of the source into separate per-architecture packages (quiet mode)")
+	rootCmd.Flags().StringVar(&partSizeFlag, "part-size", "", "Split the content into packages of at most this size, e.g. 4GB, staged on devices and installed by an app depending on them (quiet mode)")
+	rootCmd.Flags().StringVar(&setupArgs, "setup-args", "", "Arguments the install script of a split app passes to the setup file (default /qn /norestart for MSI and MSP, /quiet /norestart for MSU)")
+	rootCmd.Flags().BoolVar(&noMsiSuite, "no-msi-suite", false, "Package language pack and add-on MSIs next to an MSI setup file without the install script that chains them")
+	rootCmd.Flags().BoolVar(&requireSigned, "require-signed", false, "Fail unless the EXE/MSI setup file has a valid Authenticode signature")
+	rootCmd.Flags().StringVar(&toolVersion, "tool-version", "", "ToolVersion attribute written to Detection.xml (default "+packager.ToolVersion+")")
+	rootCmd.Flags().StringVar(&msiExecutionContext, "msi-execution-context", "", "Override the MsiExecutionContext read from an MSI setup file: Any, System or User (User records a per-user install)")
+	rootCmd.Flags().BoolVar(&msiRequiresReboot, "msi-requires-reboot", false, "Record MsiRequiresReboot for an MSI setup file")
+	addTimeoutFlag(rootCmd.Flags())
 
 	// Custom version template
-	rootCmd.SetVersionTemplate(fmt.Sprintf("LetsGoIntunePackager version %s (built %s)\n", version, buildTime))
+	rootCmd.SetVersionTemplate(fmt.Sprintf("LetsGoIntunePackager version %s (built %s, package generator %d)\n", version, buildTime, packager.GeneratorVersion))
 }
 
 func runQuietMode() error {
+	profile, err := activeProfile()
+	if err != nil {
+		return invalidInput(err)
+	}
+	outputPath = firstNonEmpty(outputPath, profile.Output)
+
+	streamed := outputPath == stdioPath
+	if streamed && splitArch {
+		return invalidInput(fmt.Errorf("--split-arch writes several packages and cannot write to stdout"))
+	}
+	if streamed && partSizeFlag != "" {
+		return invalidInput(fmt.Errorf("--part-size writes several packages and cannot write to stdout"))
+	}
+	if splitArch && partSizeFlag != "" {
+		return invalidInput(fmt.Errorf("--part-size cannot be combined with --split-arch"))
+	}
+	if setupArgs != "" && partSizeFlag == "" {
+		return invalidInput(fmt.Errorf("--setup-args requires --part-size"))
+	}
+	// Stdin is read once, into the package, so nothing else may touch it first
+	fromStdin := contentPath == stdioPath
+	if fromStdin && readOnly {
+		return invalidInput(fmt.Errorf("--content - cannot be previewed in read-only mode, which would read the stream without packaging it"))
+	}
+	if fromStdin && (splitArch || partSizeFlag != "") {
+		return invalidInput(fmt.Errorf("--split-arch and --part-size need a source folder and cannot read --content -"))
+	}
+
+	if splitArch {
+		return runSplitArch()
+	}
+	if partSizeFlag != "" {
+		return runSplitContent()
+	}
+
 	// Validate required flags in quiet mode
 	if contentPath == "" {
-		return fmt.Errorf("--content (-c) is required in quiet mode")
+		return invalidInput(fmt.Errorf("--content (-c) is required in quiet mode"))
 	}
 	if setupFile == "" {
-		return fmt.Errorf("--setup (-s) is required in quiet mode")
+		return invalidInput(fmt.Errorf("--setup (-s) is required in quiet mode"))
 	}
 	if outputPath == "" {
-		return fmt.Errorf("--output (-o) is required in quiet mode")
+		return invalidInput(fmt.Errorf("--output (-o) is required in quiet mode"))
 	}
 
-	// Validate paths exist
-	if _, err := os.Stat(contentPath); os.IsNotExist(err) {
-		return fmt.Errorf("source folder does not exist: %s", contentPath)
-	}
+	// Validate paths exist; the setup file of a stream is looked for while it is packaged
+	if !fromStdin {
+		if _, err := os.Stat(contentPath); os.IsNotExist(err) {
+			return withExitCode(exitSourceMissing, fmt.Errorf("source folder does not exist: %s", contentPath))
+		}
 
-	setupPath := fmt.Sprintf("%s/%s", contentPath, setupFile)
-	if _, err := os.Stat(setupPath); os.IsNotExist(err) {
-		return fmt.Errorf("setup file not found: %s", setupPath)
+		setupPath := fmt.Sprintf("%s/%s", contentPath, setupFile)
+		if _, err := os.Stat(setupPath); os.IsNotExist(err) {
+			return withExitCode(exitSourceMissing, fmt.Errorf("setup file not found: %s", setupPath))
+		}
 	}
 
-	// Create output directory if it doesn't exist
-	if err := os.MkdirAll(outputPath, 0755); err != nil {
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	opts, err := packagingOptions(ctx)
+	if err != nil {
+		return inputError(err)
+	}
+	if readOnly {
+		return previewQuietMode(contentPath, setupFile, outputPath, opts)
+	}
+	if fromStdin {
+		if opts.SourceStream, err = stdinSource(); err != nil {
+			return invalidInput(err)
+		}
+	}
+	if streamed {
+		packageOut, restore, err := streamToStdout()
+		if err != nil {
+			return invalidInput(err)
+		}
+		defer restore()
+		opts.OutputWriter = packageOut
+	} else if err := os.MkdirAll(outputPath, 0755); err != nil {
+		// Create output directory if it doesn't exist
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	fmt.Println("Starting packaging process...")
-	fmt.Printf("  Source: %s\n", contentPath)
-	fmt.Printf("  Setup:  %s\n", setupFile)
-	fmt.Printf("  Output: %s\n", outputPath)
+	fmt.Println(i18n.T("Starting packaging process..."))
+	w := newSummaryWriter()
+	printField(w, i18n.T("Source"), contentPath)
+	printField(w, i18n.T("Setup"), setupFile)
+	printField(w, i18n.T("Output"), outputPath)
+	w.Flush()
 	fmt.Println()
 
-	// Call packager with progress callback
-	result, err := packager.Package(contentPath, setupFile, outputPath, func(step string, pct float64) {
-		fmt.Printf("  [%3.0f%%] %s\n", pct*100, step)
-	})
+	notifier, err := newNotifier()
 	if err != nil {
-		return fmt.Errorf("packaging failed: %w", err)
+		return invalidInput(err)
 	}
+	defer notifier.Close()
 
-	// Print results
-	fmt.Println()
-	fmt.Println("Package created successfully!")
-	fmt.Printf("  Output:     %s\n", result.OutputPath)
-	fmt.Printf("  Files:      %d\n", result.FileCount)
-	fmt.Printf("  Source:     %s\n", packager.FormatSize(result.SourceSize))
-	fmt.Printf("  Final size: %s\n", packager.FormatSize(result.FinalSize))
+	// Print progress with the compression speed and time remaining
+	// A large file or the encryption reports its step many times; one line per percent is printed
+	var lastLine string
+	opts.OnProgress = func(p packager.Progress) {
+		line := fmt.Sprintf("  [%3.0f%%] %s", p.Percent*100, i18n.Step(p.Step))
+		if line == lastLine {
+			return
+		}
+		lastLine = line
+		if rate := p.Rate(); rate != "" {
+			line += " (" + rate + ")"
+		}
+		fmt.Println(line)
+	}
+	result, err := packageNotified(ctx, notifier, contentPath, setupFile, outputPath, opts, nil)
+
+	// Write the trace even when packaging failed - that is when it is most useful
+	if traceErr := writeTrace(opts.Tracer); traceErr != nil {
+		slog.Warn("could not write trace", "error", traceErr)
+	}
+
+	if err != nil {
+		printTimings(opts.Tracer)
+		return packagingFailed(fmt.Errorf("%s: %w", i18n.T("packaging failed"), err))
+	}
 
+	printPackageResult(result)
+	printTimings(opts.Tracer)
 	return nil
 }
 
+// printMspInfo prints the patch code and target products of an MSP setup file
+func printMspInfo(w io.Writer, msp *packager.MspInfo) {
+	if msp == nil {
+		return
+	}
+	printField(w, i18n.T("MSP patch"), fmt.Sprintf("%s (%s)", valueOrDash(msp.DisplayName), msp.PatchCode))
+	if msp.Classification != "" {
+		printField(w, i18n.T("Class"), msp.Classification)
+	}
+	if len(msp.TargetProductCodes) > 0 {
+		printField(w, i18n.T("Targets"), strings.Join(msp.TargetProductCodes, ", "))
+	}
+	if len(msp.ObsoletedPatches) > 0 {
+		printField(w, i18n.T("Obsoletes"), strings.Join(msp.ObsoletedPatches, ", "))
+	}
+}
+
+// printPackageResult prints the summary of a successful packaging run
+func printPackageResult(result *packager.PackageResult) {
+	fmt.Println()
+	fmt.Println(i18n.T("Package created successfully!"))
+	w := newSummaryWriter()
+	defer w.Flush()
+	printField(w, i18n.T("Output"), result.OutputPath)
+	printField(w, i18n.T("Files"), fmt.Sprint(result.FileCount))
+	printField(w, i18n.T("Source"), packager.FormatSize(result.SourceSize))
+	printField(w, i18n.T("Final size"), packager.FormatSize(result.FinalSize))
+	printField(w, i18n.T("Generator"), i18n.Tf("version %d", result.GeneratorVersion))
+	if rate := result.EncryptionThroughput(); rate > 0 {
+		accel := firstNonEmpty(packager.AESAcceleration(), i18n.T("software AES"))
+		printField(w, i18n.T("Encryption"), fmt.Sprintf("%s (%s)", packager.FormatThroughput(rate), accel))
+	}
+
+	if sig := result.Signature; sig != nil {
+		printField(w, i18n.T("Signed by"), i18n.Tf("%s (issuer: %s)", sig.Signer, sig.Issuer))
+		if sig.Timestamp != nil {
+			printField(w, i18n.T("Signed at"), sig.Timestamp.UTC().Format(time.RFC3339))
+		}
+	}
+	if v := result.Scan; v != nil {
+		printField(w, i18n.T("Malware scan"), v.String())
+		if v.Link != "" {
+			printField(w, "", v.Link)
+		}
+	}
+	for _, msix := range result.MsixInfo {
+		printField(w, "MSIX", fmt.Sprintf("%s %s (%s)", msix.Name, msix.Version, msix.FileName))
+	}
+	if msix := result.SetupMsix; msix != nil {
+		arch := firstNonEmpty(msix.Architecture, "bundle")
+		printField(w, i18n.T("MSIX setup"), fmt.Sprintf("%s %s (%s, %s)", msix.Name, msix.Version, arch, msix.Publisher))
+		printField(w, i18n.T("Install"), packager.MsixInstallCommand(msix.FileName))
+		printField(w, i18n.T("Uninstall"), packager.MsixUninstallCommand(msix))
+		printField(w, i18n.T("Detection"), i18n.Tf("%s (custom detection script)", result.DetectionScriptPath))
+	}
+	printMspInfo(w, result.SetupMsp)
+	if result.InstallCommand != "" {
+		printField(w, i18n.T("Install"), result.InstallCommand)
+	}
+	if msp := result.SetupMsp; msp != nil {
+		if uninstall := packager.MspUninstallCommand(msp); uninstall != "" {
+			printField(w, i18n.T("Uninstall"), uninstall)
+		}
+	}
+	printMsiSuite(w, result.MsiSuite)
+	if result.ReusedFiles > 0 {
+		printField(w, i18n.T("Reused"), i18n.Tf("%d files (%s) already compressed", result.ReusedFiles, packager.FormatSize(result.ReusedSize)))
+	}
+	if result.ResumedFrom != "" {
+		printField(w, i18n.T("Resumed"), i18n.Tf("from %s checkpoint", result.ResumedFrom))
+	}
+	if result.ManifestPath != "" {
+		printField(w, i18n.T("Manifest"), result.ManifestPath)
+	}
+	if result.Verified {
+		if result.WriteAttempts > 1 {
+			printField(w, i18n.T("Verified"), i18n.Tf("size and SHA256 match (after %d attempts)", result.WriteAttempts))
+		} else {
+			printField(w, i18n.T("Verified"), i18n.T("size and SHA256 match"))
+		}
+	}
+	if result.License != nil {
+		printField(w, i18n.T("License"), fmt.Sprintf("%s (%s)", result.License, result.LicensePath))
+	}
+	if result.Requirements != nil {
+		printField(w, i18n.T("Requirements"), fmt.Sprintf("%s (%s)", requirementsSummary(result.Requirements), result.RequirementsPath))
+	}
+	if result.KeysPath != "" {
+		printField(w, i18n.T("Keys"), result.KeysPath)
+	}
+	if result.ProvenancePath != "" {
+		printField(w, i18n.T("Provenance"), result.ProvenancePath)
+	}
+	if result.SignaturePath != "" {
+		printField(w, i18n.T("Provenance signature"), result.SignaturePath)
+	}
+	if result.LowMemory {
+		printField(w, i18n.T("Low memory"), i18n.T("streamed through temporary files"))
+	}
+	printSkippedLinks(w, result.SkippedLinks)
+}
+
+// printMsiSuite prints the members of an MSI suite and the commands installing them
+func printMsiSuite(w io.Writer, suite *packager.MsiSuite) {
+	if suite == nil {
+		return
+	}
+	printField(w, i18n.T("MSI suite"), suite.String())
+	for _, m := range suite.Members {
+		printField(w, "", fmt.Sprintf("%s (%s, %s)", m.File, firstNonEmpty(m.Language, "-"), m.Relation))
+	}
+	printField(w, i18n.T("Install"), suite.InstallCommand)
+	printField(w, i18n.T("Uninstall"), suite.UninstallCommand)
+}
+
+// newSummaryWriter returns a writer aligning the values of summary lines written by
+// printField, whatever the length of their translated labels; it must be flushed
+func newSummaryWriter() *tabwriter.Writer {
+	return tabwriter.NewWriter(os.Stdout, 14, 0, 1, ' ', 0)
+}
+
+// printField prints a line of a summary, its value aligned with the other lines
+// An empty label continues the value of the previous line
+func printField(w io.Writer, label, value string) {
+	if label != "" {
+		label += ":"
+	}
+	fmt.Fprintf(w, "  %s\t%s\n", label, value)
+}
+
+// printSkippedLinks lists the symbolic links and junctions left out of a package
+func printSkippedLinks(w io.Writer, links []packager.SkippedLink) {
+	for i, link := range links {
+		if i == 0 {
+			printField(w, i18n.T("Skipped"), link.String())
+		} else {
+			printField(w, "", link.String())
+		}
+	}
+}
+
 func runTUI() error {
 	// Check if flags were provided - if so, pass them as presets to TUI
+	// Only their secret references are resolved within --timeout; the session has no limit
+	ctx, cancel := commandContext()
+	opts, err := packagingOptions(ctx)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	profile, err := activeProfile()
+	if err != nil {
+		return err
+	}
+	outputPath = firstNonEmpty(outputPath, profile.Output)
+
+	// The TUI owns the terminal, so logs are captured for its error screen;
+	// plain output leaves them on stderr, where they are read in order
+	var logs *tui.LogBuffer
+	if noTUIDecorations {
+		opts.Logger, err = newLogger(os.Stderr)
+	} else {
+		logs = tui.NewLogBuffer(tui.DefaultLogBufferLines)
+		opts.Logger, err = newLogger(logs)
+	}
+	if err != nil {
+		return err
+	}
+
 	presets := &tui.Presets{
 		ContentPath: contentPath,
 		SetupFile:   setupFile,
 		OutputPath:  outputPath,
+		Options:     opts,
+		Logs:        logs,
+	}
+
+	// The settings screen edits the active profile of the config file in use
+	cfg, cfgPath, err := activeConfig()
+	if err != nil {
+		return err
+	}
+	presets.ConfigPath = cfgPath
+	if readOnly {
+		// Nothing is saved, so the settings screen is disabled
+		presets.ConfigPath = ""
+		presets.ReadOnly = true
+	}
+	presets.ProfileName = cfg.ProfileName(selectedProfileName())
+	presets.Settings = cfg.TUI
+	if !readOnly {
+		// Created packages can be uploaded to the tenants of the profiles
+		presets.Tenants = tuiTenants(cfg)
+		presets.Upload = tuiUploader(cfg)
+	}
+
+	// Recent jobs are remembered so they can be re-run without retyping paths
+	if historyPath, err := config.DefaultHistoryPath(); err == nil {
+		presets.HistoryPath = historyPath
+	} else {
+		slog.Warn("packaging history disabled", "error", err)
 	}
 
 	// Run the TUI
-	return tui.Run(presets)
+	if noTUIDecorations {
+		// Ctrl+C cancels a running job and ends the session
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		err = tui.RunPlain(ctx, presets, os.Stdin, os.Stdout)
+		stop()
+	} else {
+		err = tui.Run(presets)
+	}
+	var panicErr *tui.PanicError
+	if errors.As(err, &panicErr) {
+		crash(panicErr.Value, panicErr.Stack)
+	}
+	if traceErr := writeTrace(presets.Options.Tracer); traceErr != nil {
+		slog.Warn("could not write trace", "error", traceErr)
+	}
+	return err
+}
+
+// packagingOptions builds packager options from CLI flags; secret references of
+// template variables are resolved within ctx
+func packagingOptions(ctx context.Context) (packager.Options, error) {
+	var opts packager.Options
+
+	profile, err := activeProfile()
+	if err != nil {
+		return opts, err
+	}
+	opts.Exclude = profile.Exclude
+	if len(excludePatterns) > 0 {
+		opts.Exclude = excludePatterns
+	}
+	if opts.Symlinks, err = packager.ParseSymlinkPolicy(symlinkPolicy); err != nil {
+		return opts, err
+	}
+	opts.Hooks = packagingHooks(profile)
+	opts.ToolVersion = profile.ToolVersion
+	if toolVersion != "" {
+		opts.ToolVersion = toolVersion
+	}
+	opts.MsiOverrides, err = msiOverrides(profile)
+	if err != nil {
+		return opts, err
+	}
+	if opts.Variables, opts.SecretVariables, err = templateVariables(ctx, profile, templateVars); err != nil {
+		return opts, err
+	}
+	opts.Manifest = writeManifest
+	opts.RequireSigned = requireSigned
+	opts.NoMsiSuite = noMsiSuite
+	opts.NormalizePermissions = normalizePerms
+	if opts.Compression, err = packager.ParseCompression(compression); err != nil {
+		return opts, err
+	}
+	if lockRetries < 0 {
+		return opts, fmt.Errorf("--lock-retries cannot be negative")
+	}
+	opts.LockRetries = lockRetries
+
+	if tracePath != "" || showTimings {
+		opts.Tracer = packager.NewTracer(traceThreshold)
+	}
+	if resumable {
+		if lowMemory {
+			return opts, fmt.Errorf("--low-memory cannot be combined with --resumable")
+		}
+		root, err := packager.DefaultCheckpointRoot()
+		if err != nil {
+			return opts, err
+		}
+		opts.CheckpointRoot = root
+	}
+	opts.VerifyOutput = verifyOutput
+	opts.LowMemory = lowMemory
+	if maxSizeFlag != "" {
+		if opts.MaxSize, err = listing.ParseSize(maxSizeFlag); err != nil {
+			return opts, fmt.Errorf("invalid --max-size: %w", err)
+		}
+	}
+	if warnSizeFlag != "" {
+		if opts.WarnSize, err = listing.ParseSize(warnSizeFlag); err != nil {
+			return opts, fmt.Errorf("invalid --warn-size: %w", err)
+		}
+	}
+	opts.License = flagLicense()
+	if stageOutput {
+		root, err := packager.DefaultStagingRoot()
+		if err != nil {
+			return opts, err
+		}
+		opts.StagingRoot = root
+	}
+	if useContentStore {
+		root, err := packager.DefaultContentStoreRoot()
+		if err != nil {
+			return opts, err
+		}
+		opts.ContentStore = packager.NewContentStore(root)
+		opts.HashCache = fileHashCache()
+	}
+	if deltaFrom != "" {
+		if partSizeFlag != "" {
+			return opts, fmt.Errorf("--delta-from cannot be combined with --part-size")
+		}
+		opts.Previous = &packager.PreviousPackage{Path: deltaFrom}
+		if deltaKeys != "" {
+			info, err := readDetectionXMLFrom(deltaKeys)
+			if err != nil {
+				return opts, fmt.Errorf("failed to read --delta-keys: %w", err)
+			}
+			opts.Previous.Encryption = &info.EncryptionInfo
+		}
+	} else if deltaKeys != "" {
+		return opts, fmt.Errorf("--delta-keys requires --delta-from")
+	}
+	if reproducible {
+		if resumable {
+			return opts, fmt.Errorf("--reproducible cannot be combined with --resumable")
+		}
+		opts.Reproducible, err = reproducibleOptions()
+		if err != nil {
+			return opts, err
+		}
+	} else if reproducibleSeed != "" {
+		return opts, fmt.Errorf("--seed requires --reproducible")
+	}
+
+	if opts.Keys, err = contentKeys(); err != nil {
+		return opts, err
+	}
+	if opts.Keys != nil {
+		if resumable {
+			return opts, fmt.Errorf("supplied encryption keys cannot be combined with --resumable")
+		}
+		if opts.Reproducible != nil && len(opts.Reproducible.Seed) > 0 {
+			return opts, fmt.Errorf("supplied encryption keys cannot be combined with --seed")
+		}
+	}
+	if exportKeysPath != "" {
+		if splitArch {
+			return opts, fmt.Errorf("--export-keys cannot be combined with --split-arch")
+		}
+		if partSizeFlag != "" {
+			return opts, fmt.Errorf("--export-keys cannot be combined with --part-size")
+		}
+		opts.ExportKeys = exportKeysPath
+		if opts.ExportPassphrase, err = keysPassphrase(); err != nil {
+			return opts, err
+		}
+	}
+	if opts.Scan, err = scanOptions(profile); err != nil {
+		return opts, err
+	}
+	if opts.Provenance, err = provenanceOptions(); err != nil {
+		return opts, err
+	}
+	if opts.Requirements, err = requirementsOptions(); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// packagingHooks returns the commands of --pre-hook and --post-hook, or those of the
+// profile, nil when there are none
+// Flags replace the commands of the profile for their hook
+func packagingHooks(profile *config.Profile) packager.Hooks {
+	commands := &hooks.Commands{Pre: profile.PreHooks, Post: profile.PostHooks}
+	if len(preHooks) > 0 {
+		commands.Pre = preHooks
+	}
+	if len(postHooks) > 0 {
+		commands.Post = postHooks
+	}
+	if len(commands.Pre) == 0 && len(commands.Post) == 0 {
+		return nil
+	}
+	return commands
+}
+
+// seedEnv holds the seed of reproducible builds, keeping it out of shell history and CI logs
+const seedEnv = "INTUNEWIN_SEED"
+
+// msiOverrides returns the MsiInfo overrides of the --msi-* flags and the profile, nil when none is set
+// Flags win over the profile
+func msiOverrides(profile *config.Profile) (*packager.MsiOverrides, error) {
+	overrides := packager.MsiOverrides{
+		ExecutionContext: profile.MsiExecutionContext,
+		RequiresReboot:   profile.MsiRequiresReboot,
+	}
+	if msiExecutionContext != "" {
+		overrides.ExecutionContext = msiExecutionContext
+	}
+	if msiRequiresReboot {
+		overrides.RequiresReboot = &msiRequiresReboot
+	}
+	if overrides.ExecutionContext == "" && overrides.RequiresReboot == nil {
+		return nil, nil
+	}
+	if overrides.ExecutionContext != "" {
+		context, err := packager.ParseMsiExecutionContext(overrides.ExecutionContext)
+		if err != nil {
+			return nil, err
+		}
+		overrides.ExecutionContext = context
+	}
+	return &overrides, nil
+}
+
+// reproducibleOptions returns the settings of a reproducible build from SOURCE_DATE_EPOCH
+// and the --seed flag or its environment variable
+func reproducibleOptions() (*packager.Reproducible, error) {
+	modTime, err := packager.SourceDateEpoch()
+	if err != nil {
+		return nil, err
+	}
+	seed := firstNonEmpty(reproducibleSeed, os.Getenv(seedEnv))
+	return &packager.Reproducible{ModTime: modTime, Seed: []byte(seed)}, nil
+}
+
+// writeTrace writes the collected trace to the --trace file, if enabled
+func writeTrace(tracer *packager.Tracer) error {
+	if tracer == nil || tracePath == "" {
+		return nil
+	}
+	return tracer.WriteFile(tracePath)
 }
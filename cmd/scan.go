@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/config"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/scan"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/secrets"
+)
+
+// Environment variables of VirusTotal scans
+const (
+	// virusTotalKeyEnv holds the VirusTotal API key, winning over the one of the profile
+	virusTotalKeyEnv = "INTUNEWIN_VIRUSTOTAL_API_KEY"
+	// virusTotalURLEnv points VirusTotal lookups at another server, such as a test double
+	virusTotalURLEnv = "INTUNEWIN_VIRUSTOTAL_URL"
+)
+
+var (
+	scanner           string
+	scanMaxDetections int
+)
+
+func init() {
+	rootCmd.Flags().StringVar(&scanner, "scan", "", "Scan the setup file for malware before packaging: "+strings.Join(scan.Scanners, ", ")+" (VirusTotal API key from "+virusTotalKeyEnv+" or the profile)")
+	rootCmd.Flags().IntVar(&scanMaxDetections, "scan-max-detections", 0, "Number of malware detections of --scan allowed before packaging is blocked (default 0)")
+}
+
+// scanOptions returns the malware scan of --scan or the profile, nil when neither sets one
+// The VirusTotal API key of the profile may be a secretref:
+func scanOptions(profile *config.Profile) (*packager.ScanOptions, error) {
+	var settings config.ScanSettings
+	if profile.Scan != nil {
+		settings = *profile.Scan
+	}
+	name := firstNonEmpty(scanner, settings.Scanner)
+	if name == "" {
+		if scanMaxDetections != 0 {
+			return nil, fmt.Errorf("--scan-max-detections requires --scan")
+		}
+		return nil, nil
+	}
+	maxDetections := settings.MaxDetections
+	if scanMaxDetections != 0 {
+		maxDetections = scanMaxDetections
+	}
+	if maxDetections < 0 {
+		return nil, fmt.Errorf("the number of malware detections allowed cannot be negative")
+	}
+
+	key := firstNonEmpty(os.Getenv(virusTotalKeyEnv), settings.VirusTotalAPIKey)
+	if secrets.IsRef(key) {
+		resolved, err := secretResolver(profile).Resolve(context.Background(), key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve the VirusTotal API key: %w", err)
+		}
+		key = resolved
+	}
+	s, err := scan.New(name, key, httpTransport)
+	if err != nil {
+		return nil, err
+	}
+	if vt, ok := s.(*scan.VirusTotal); ok {
+		vt.BaseURL = os.Getenv(virusTotalURLEnv)
+	}
+	return &packager.ScanOptions{Scanner: s, MaxDetections: maxDetections}, nil
+}
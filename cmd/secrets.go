@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/secrets"
+)
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage encrypted secrets files for template variables",
+	Long: `Commands for encrypted secrets files, referenced by template variables as
+secretref:file:<path>#<name>.
+
+Secrets files are encrypted with AES-256-GCM under a key derived from the
+passphrase in ` + secretsPassphraseEnv + `, like key exports.`,
+}
+
+var secretsSetCmd = &cobra.Command{
+	Use:   "set <file> <name>",
+	Short: "Store a secret read from stdin, creating the file if needed",
+	Long: `Store a secret in a secrets file, creating the file if it does not exist.
+The value is the first line of stdin, so it stays out of the shell history.
+
+Examples:
+  read -rs LICENSE_KEY && echo "$LICENSE_KEY" | intunewin secrets set ./secrets.json LICENSE_KEY
+  intunewin -c ./app -s install.ps1 -o ./out -q --var LICENSE_KEY=secretref:file:./secrets.json#LICENSE_KEY`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSecretsSet(args[0], args[1])
+	},
+}
+
+var secretsListCmd = &cobra.Command{
+	Use:   "list <file>",
+	Short: "List the names of the secrets of a file",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSecretsList(args[0])
+	},
+}
+
+var secretsDeleteCmd = &cobra.Command{
+	Use:   "delete <file> <name>",
+	Short: "Remove a secret from a file",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		passphrase, err := secretsPassphrase()
+		if err != nil {
+			return invalidInput(err)
+		}
+		return secrets.Delete(args[0], args[1], passphrase)
+	},
+}
+
+func init() {
+	secretsCmd.AddCommand(secretsSetCmd, secretsListCmd, secretsDeleteCmd)
+	rootCmd.AddCommand(secretsCmd)
+}
+
+func runSecretsSet(path, name string) error {
+	passphrase, err := secretsPassphrase()
+	if err != nil {
+		return invalidInput(err)
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	if !scanner.Scan() {
+		return invalidInput(fmt.Errorf("no value on stdin"))
+	}
+	value := strings.TrimRight(scanner.Text(), "\r")
+	if value == "" {
+		return invalidInput(fmt.Errorf("the value of %s is empty", name))
+	}
+	if err := secrets.Set(path, name, value, passphrase); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Stored %s in %s\n", name, path)
+	return nil
+}
+
+func runSecretsList(path string) error {
+	passphrase, err := secretsPassphrase()
+	if err != nil {
+		return invalidInput(err)
+	}
+	values, err := secrets.ReadFile(path, passphrase)
+	if err != nil {
+		return err
+	}
+	for _, name := range secrets.Names(values) {
+		fmt.Println(name)
+	}
+	return nil
+}
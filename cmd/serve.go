@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/config"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/listing"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/metrics"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/server"
+)
+
+var (
+	serveListen  string
+	serveCatalog string
+	serveOutput  string
+	serveWorkers int
+
+	serveQueueSize      int
+	serveTenantWorkers  int
+	serveTenantRate     float64
+	serveTenantBurst    int
+	serveRate           float64
+	serveBurst          int
+	serveTenantHeader   string
+	serveMaxPackageSize string
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run packaging jobs behind a REST API with a web dashboard",
+	Long: `Run a packaging service: jobs are submitted over a REST API or the web
+dashboard and packaged in the background, and the dashboard shows the job
+queue, the packaging history and the catalog contents, with live progress.
+
+Open http://<listen address>/ in a browser for the dashboard. The API:
+  GET  /api/jobs       jobs, newest first
+  POST /api/jobs       queue a job: {"source": ..., "setup": ..., "output": ...}
+  GET  /api/jobs/{id}  one job
+  GET  /api/events     job updates as server-sent events
+  GET  /api/history    the packaging history
+  GET  /api/catalog    the packages of the --catalog repository
+  GET  /metrics        Prometheus metrics: jobs, packaged bytes, phase durations
+
+Paths of jobs are paths on the machine running the service. Packaging settings
+come from the active config profile. The service has no authentication: it
+listens on localhost by default, so expose it only behind a reverse proxy that
+authenticates users.
+
+Admission control keeps one user from taking the service over: --tenant-rate
+and --rate limit the jobs per minute each tenant and all tenants together may
+submit (429 with Retry-After beyond them), --max-package-size refuses larger
+sources (413) and --queue-size caps the waiting jobs (503 when full). Each
+tenant's jobs wait in their own queue and the workers take them from the tenants
+in turn; --tenant-workers caps the jobs of one tenant running at once. Tenants
+are client addresses, or the value of --tenant-header set by the proxy.
+
+Examples:
+  intunewin serve
+  intunewin serve --listen :8080 --output /srv/packages --catalog azblob://contoso/catalog
+  intunewin serve --tenant-header X-Forwarded-User --tenant-rate 2 --tenant-burst 5 --max-package-size 20GB
+  intunewin serve --workers 4 --tenant-workers 2 --tenant-header X-Forwarded-User`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runServe()
+	},
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveListen, "listen", "127.0.0.1:8080", "Address to listen on")
+	serveCmd.Flags().StringVar(&serveCatalog, "catalog", "", "Catalog repository shown on the dashboard (same destinations as publish)")
+	serveCmd.Flags().StringVarP(&serveOutput, "output", "o", "", "Output folder of jobs submitted without one (default: output of the profile)")
+	serveCmd.Flags().IntVar(&serveWorkers, "workers", 1, "Number of jobs packaged at the same time")
+	serveCmd.Flags().IntVar(&serveTenantWorkers, "tenant-workers", 0, "Number of jobs of one tenant packaged at the same time (default: all workers)")
+	serveCmd.Flags().IntVar(&serveQueueSize, "queue-size", 256, "Number of jobs waiting for a worker before new jobs are refused")
+	serveCmd.Flags().Float64Var(&serveTenantRate, "tenant-rate", 0, "Jobs per minute each tenant may submit (0 for no limit)")
+	serveCmd.Flags().IntVar(&serveTenantBurst, "tenant-burst", 0, "Jobs a tenant may submit at once (default: one minute of --tenant-rate)")
+	serveCmd.Flags().Float64Var(&serveRate, "rate", 0, "Jobs per minute all tenants together may submit (0 for no limit)")
+	serveCmd.Flags().IntVar(&serveBurst, "burst", 0, "Jobs all tenants together may submit at once (default: one minute of --rate)")
+	serveCmd.Flags().StringVar(&serveTenantHeader, "tenant-header", "", "Request header naming the tenant, set by the reverse proxy (default: the client address)")
+	serveCmd.Flags().StringVar(&serveMaxPackageSize, "max-package-size", "", "Refuse jobs whose source folder is larger, e.g. 20GB")
+	rootCmd.AddCommand(serveCmd)
+}
+
+func runServe() error {
+	if serveWorkers < 1 {
+		return fmt.Errorf("--workers must be at least 1")
+	}
+	if serveTenantWorkers < 0 {
+		return fmt.Errorf("--tenant-workers cannot be negative")
+	}
+	if serveQueueSize < 1 {
+		return fmt.Errorf("--queue-size must be at least 1")
+	}
+	if serveTenantRate < 0 || serveRate < 0 {
+		return fmt.Errorf("--tenant-rate and --rate cannot be negative")
+	}
+	var maxPackageSize int64
+	if serveMaxPackageSize != "" {
+		var err error
+		if maxPackageSize, err = listing.ParseSize(serveMaxPackageSize); err != nil {
+			return fmt.Errorf("invalid --max-package-size: %w", err)
+		}
+	}
+	profile, err := activeProfile()
+	if err != nil {
+		return err
+	}
+	opts, err := packagingOptions(context.Background())
+	if err != nil {
+		return err
+	}
+
+	reg := metrics.New()
+	cfg := server.Config{
+		Package:        servePackage(opts, reg),
+		Workers:        serveWorkers,
+		TenantWorkers:  serveTenantWorkers,
+		DefaultOutput:  firstNonEmpty(serveOutput, profile.Output),
+		Metrics:        reg.Handler(),
+		QueueSize:      serveQueueSize,
+		TenantRate:     serveTenantRate,
+		TenantBurst:    serveTenantBurst,
+		GlobalRate:     serveRate,
+		GlobalBurst:    serveBurst,
+		TenantHeader:   serveTenantHeader,
+		MaxPackageSize: maxPackageSize,
+	}
+	if historyPath, err := config.DefaultHistoryPath(); err == nil {
+		cfg.HistoryPath = historyPath
+	} else {
+		slog.Warn("packaging history disabled", "error", err)
+	}
+	if serveCatalog != "" {
+		if cfg.Catalog, err = openRepository(serveCatalog); err != nil {
+			return err
+		}
+	}
+
+	listener, err := net.Listen("tcp", serveListen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", serveListen, err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	srv := server.New(cfg)
+	var workers sync.WaitGroup
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		srv.Run(ctx)
+	}()
+
+	httpServer := &http.Server{Handler: srv.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Serving the dashboard on http://%s/ (Ctrl+C to stop)\n", listener.Addr())
+	err = httpServer.Serve(listener)
+	cancel()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server failed: %w", err)
+	}
+	fmt.Println("Waiting for running jobs to finish...")
+	workers.Wait()
+	return nil
+}
+
+// servePackage packages the jobs of the service with the options of the active profile,
+// recording them in reg
+func servePackage(opts packager.Options, reg *metrics.Registry) server.PackageFunc {
+	return func(ctx context.Context, job server.Job, progress packager.ProgressCallback) (string, error) {
+		// Running jobs finish when the service stops
+		result, err := packageMeasured(context.WithoutCancel(ctx), reg, nil, job.SourcePath, job.SetupFile, job.OutputPath, opts, progress)
+		if err != nil {
+			return "", err
+		}
+		slog.Info("package created", "job", job.ID, "package", result.OutputPath)
+		return result.OutputPath, nil
+	}
+}
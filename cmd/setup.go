@@ -0,0 +1,299 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/graph"
+)
+
+var (
+	setupSaveAs    string
+	setupNoBrowser bool
+)
+
+var setupCmd = &cobra.Command{
+	Use:   "setup",
+	Short: "Guided setup of the Azure AD app registration used for uploads",
+	Long: `Walk through the Azure AD app registration that uploads, assignments and the
+apps commands authenticate with, then save its tenant and client IDs in the
+config profile so later commands only need the client secret.
+
+The wizard:
+  1. prints the Microsoft Graph application permissions to add, with the
+     Azure portal steps and the equivalent az CLI commands
+  2. asks for the tenant ID (or domain) and the client ID
+  3. opens the admin consent page in the browser (--no-browser prints it)
+  4. asks for the client secret, without echoing it, and verifies a test
+     Graph call, reporting permissions that were not granted
+  5. saves the tenant and client IDs in the active profile, or the profile
+     named by --save-as
+
+The client secret is never saved: set AZURE_CLIENT_SECRET or pass
+--client-secret to commands that call Graph. Values given as flags are not
+asked for, so the wizard can run unattended.
+
+Examples:
+  intunewin setup
+  intunewin setup --save-as contoso
+  AZURE_CLIENT_SECRET=... intunewin setup --tenant-id contoso.onmicrosoft.com \
+    --client-id 11111111-2222-3333-4444-555555555555 --no-browser`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSetup()
+	},
+}
+
+func init() {
+	setupCmd.Flags().StringVar(&tenantID, "tenant-id", "", "Azure AD tenant ID or domain (env: AZURE_TENANT_ID)")
+	setupCmd.Flags().StringVar(&clientID, "client-id", "", "App registration client ID (env: AZURE_CLIENT_ID)")
+	setupCmd.Flags().StringVar(&clientSecret, "client-secret", "", "App registration client secret, used to verify access and never saved (env: AZURE_CLIENT_SECRET)")
+	setupCmd.Flags().StringVar(&cloudName, "cloud", "", "Azure cloud of the tenant: public, usgovhigh (GCC High), dod or china (21Vianet), saved in the profile (default: cloud of the profile, else public)")
+	setupCmd.Flags().StringVar(&graphURL, "graph-url", "", "Send token and Graph requests to this server instead, e.g. a mock-graph server (env "+graphURLEnv+")")
+	setupCmd.Flags().StringVar(&setupSaveAs, "save-as", "", "Profile to save the IDs in, created if needed (default: the active profile)")
+	setupCmd.Flags().BoolVar(&setupNoBrowser, "no-browser", false, "Print the admin consent URL instead of opening it")
+	addTimeoutFlag(setupCmd.Flags())
+
+	rootCmd.AddCommand(setupCmd)
+}
+
+func runSetup() error {
+	profile, err := activeProfile()
+	if err != nil {
+		return invalidInput(err)
+	}
+	prompt := &setupPrompter{in: bufio.NewReader(os.Stdin)}
+
+	fmt.Println("Intune upload setup")
+	fmt.Println()
+	printSetupPermissions()
+
+	tenant := tenantID
+	if tenant == "" {
+		if tenant, err = prompt.ask("Tenant ID or domain", firstNonEmpty(os.Getenv("AZURE_TENANT_ID"), profile.TenantID)); err != nil {
+			return invalidInput(err)
+		}
+	}
+	client := clientID
+	if client == "" {
+		if client, err = prompt.ask("Client ID of the app registration", firstNonEmpty(os.Getenv("AZURE_CLIENT_ID"), profile.ClientID)); err != nil {
+			return invalidInput(err)
+		}
+	}
+
+	cloud, err := graph.ParseCloud(firstNonEmpty(cloudName, profile.Cloud))
+	if err != nil {
+		return invalidInput(err)
+	}
+	consentURL := cloud.AdminConsentURL(tenant, client)
+	fmt.Println()
+	fmt.Println("An administrator of the tenant grants the permissions on the admin consent page:")
+	fmt.Printf("  %s\n", consentURL)
+	fmt.Println("(An error page after accepting is expected when the app has no redirect URI; consent is still granted.)")
+	if !setupNoBrowser {
+		if prompt.confirm("Open it in the browser now?", true) {
+			if err := openURL(consentURL); err != nil {
+				fmt.Printf("Could not open the browser: %v\n", err)
+			}
+		}
+		prompt.line("Press Enter once consent is granted ")
+	}
+
+	secret := firstNonEmpty(clientSecret, os.Getenv("AZURE_CLIENT_SECRET"))
+	if secret == "" {
+		if secret, err = prompt.secret("Client secret (used to verify access, not saved; empty to skip)"); err != nil {
+			return invalidInput(err)
+		}
+	}
+	fmt.Println()
+	if secret == "" {
+		fmt.Println("Skipped verification: no client secret given")
+	} else if err := verifySetup(cloud, tenant, client, secret); err != nil {
+		return err
+	}
+
+	name, path, err := saveSetupProfile(setupSaveAs, cloud, tenant, client)
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+	fmt.Printf("Saved the tenant and client IDs in profile %q of %s\n", name, path)
+	fmt.Println("Provide the client secret to commands that call Graph with AZURE_CLIENT_SECRET or --client-secret.")
+	if cfg, _, _ := activeConfig(); name != selectedProfileName() && name != cfg.DefaultProfile {
+		fmt.Printf("Select the profile with --profile %s or INTUNEWIN_PROFILE=%s.\n", name, name)
+	}
+	return nil
+}
+
+// printSetupPermissions prints the Graph permissions of the app registration and how to add them
+func printSetupPermissions() {
+	fmt.Println("The app registration needs these Microsoft Graph application permissions,")
+	fmt.Println("all requiring admin consent:")
+	for _, perm := range graph.RequiredPermissions {
+		fmt.Printf("  %-36s %s\n", perm.Name, perm.Reason)
+	}
+	fmt.Println()
+	fmt.Println("In the Azure portal: Microsoft Entra ID > App registrations > New registration,")
+	fmt.Println("then API permissions > Add a permission > Microsoft Graph > Application permissions,")
+	fmt.Println("and Certificates & secrets > New client secret. Or with the az CLI:")
+	var roles []string
+	for _, perm := range graph.RequiredPermissions {
+		roles = append(roles, perm.ID+"=Role")
+	}
+	fmt.Println("  az ad app create --display-name LetsGoIntunePackager --query appId -o tsv")
+	fmt.Printf("  az ad app permission add --id <client-id> --api %s --api-permissions %s\n", graph.GraphResourceAppID, strings.Join(roles, " "))
+	fmt.Println("  az ad sp create --id <client-id>")
+	fmt.Println("  az ad app credential reset --id <client-id> --display-name intunewin --query password -o tsv")
+	fmt.Println()
+}
+
+// verifySetup makes a test Graph call with the credentials and reports the permissions
+// that were not granted
+func verifySetup(cloud graph.Cloud, tenant, client, secret string) error {
+	fmt.Println("Verifying access to Intune...")
+	c := graph.NewClient(graph.Credentials{TenantID: tenant, ClientID: client, ClientSecret: secret})
+	c.SetCloud(cloud)
+	c.SetHTTPOptions(graphHTTPOptions(graph.DefaultHTTPOptions))
+	if server := firstNonEmpty(graphURL, os.Getenv(graphURLEnv)); server != "" {
+		c.SetServer(server)
+	}
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	check, err := c.CheckAccess(ctx)
+	if err != nil {
+		var apiErr *graph.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == 403 {
+			return uploadFailed(fmt.Errorf("access verification failed, check that admin consent was granted: %w", err))
+		}
+		return uploadFailed(fmt.Errorf("access verification failed: %w", err))
+	}
+	fmt.Println("  Token acquired and Win32 apps listed")
+	if check.Roles != nil {
+		fmt.Printf("  Granted:  %s\n", valueOrDash(strings.Join(check.Roles, ", ")))
+	}
+	for _, perm := range check.Missing {
+		fmt.Printf("  Missing:  %s (needed to %s)\n", perm.Name, perm.Reason)
+	}
+	if len(check.Missing) > 0 {
+		fmt.Println("  Add the missing permissions and grant admin consent again.")
+	}
+	return nil
+}
+
+// saveSetupProfile stores the cloud, tenant and client IDs in the named profile, or the
+// active one, keeping its other settings
+// Returns the name of the profile and the config file it was saved in
+func saveSetupProfile(name string, cloud graph.Cloud, tenant, client string) (string, string, error) {
+	cfg, path, err := activeConfig()
+	if err != nil {
+		return "", "", invalidInput(err)
+	}
+	name = cfg.ProfileName(firstNonEmpty(name, selectedProfileName()))
+	profile := cfg.Profiles[name]
+	profile.TenantID = tenant
+	profile.ClientID = client
+	profile.Cloud = ""
+	if cloud != graph.CloudPublic {
+		profile.Cloud = cloud.Name
+	}
+	cfg.SetProfile(name, profile)
+	if err := cfg.Save(path); err != nil {
+		return "", "", err
+	}
+	return cfg.ProfileName(name), path, nil
+}
+
+// setupPrompter asks the questions of the setup wizard
+// Answers are read line by line, so they can also be piped in
+type setupPrompter struct {
+	in *bufio.Reader
+}
+
+// line prints a prompt and reads an answer; ok is false when the input has ended
+func (p *setupPrompter) line(prompt string) (answer string, ok bool) {
+	fmt.Print(prompt)
+	line, err := p.in.ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Println()
+		return "", false
+	}
+	return strings.TrimSpace(line), true
+}
+
+// ask prints a question and returns the answer, or def when the answer is empty
+// Without a default, the question is asked until it is answered
+func (p *setupPrompter) ask(question, def string) (string, error) {
+	prompt := question + ": "
+	if def != "" {
+		prompt = fmt.Sprintf("%s [%s]: ", question, def)
+	}
+	for {
+		answer, ok := p.line(prompt)
+		switch {
+		case answer != "":
+			return answer, nil
+		case def != "":
+			return def, nil
+		case !ok:
+			return "", fmt.Errorf("no answer to %q (pass it as a flag when not running interactively)", question)
+		}
+	}
+}
+
+// confirm asks a yes or no question; def is the answer when none is given
+func (p *setupPrompter) confirm(question string, def bool) bool {
+	choices := "y/N"
+	if def {
+		choices = "Y/n"
+	}
+	answer, _ := p.line(fmt.Sprintf("%s (%s): ", question, choices))
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	}
+	return def
+}
+
+// secret asks for a value without echoing it when reading from a terminal
+func (p *setupPrompter) secret(question string) (string, error) {
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		answer, _ := p.line(question + ": ")
+		return answer, nil
+	}
+	fmt.Printf("%s: ", question)
+	data, err := term.ReadPassword(os.Stdin.Fd())
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the client secret: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// openURL opens a web page in the default browser without waiting for it
+func openURL(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	case "darwin":
+		cmd = exec.Command("open", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", cmd.Path, err)
+	}
+	go cmd.Wait()
+	return nil
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/spec"
+)
+
+// runSplitArch packages each architecture subfolder of the source (x86/, x64/, ...)
+// into its own .intunewin plus an app spec carrying the architecture requirement
+func runSplitArch() error {
+	if contentPath == "" {
+		return invalidInput(fmt.Errorf("--content (-c) is required in quiet mode"))
+	}
+	if outputPath == "" {
+		return invalidInput(fmt.Errorf("--output (-o) is required in quiet mode"))
+	}
+
+	sources, err := packager.FindArchSources(contentPath, setupFile)
+	if err != nil {
+		return inputError(err)
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	opts, err := packagingOptions(ctx)
+	if err != nil {
+		return inputError(err)
+	}
+	if readOnly {
+		for _, src := range sources {
+			fmt.Printf("\n[%s] %s\n", src.Arch, filepath.Join(src.Dir, src.SetupFile))
+			archOpts := opts
+			archOpts.OutputName = fmt.Sprintf("%s-%s", packager.GetApplicationName(src.SetupFile), src.Arch)
+			if err := previewQuietMode(src.Dir, src.SetupFile, outputPath, archOpts); err != nil {
+				return fmt.Errorf("%s: %w", src.Arch, err)
+			}
+		}
+		return nil
+	}
+	notifier, err := newNotifier()
+	if err != nil {
+		return invalidInput(err)
+	}
+	defer notifier.Close()
+
+	fmt.Printf("Packaging %d architectures from %s\n", len(sources), contentPath)
+	for _, src := range sources {
+		fmt.Printf("\n[%s] %s\n", src.Arch, filepath.Join(src.Dir, src.SetupFile))
+
+		archOpts := opts
+		archOpts.OutputName = fmt.Sprintf("%s-%s", packager.GetApplicationName(src.SetupFile), src.Arch)
+		result, err := packageNotified(ctx, notifier, src.Dir, src.SetupFile, outputPath, archOpts, func(step string, pct float64) {
+			fmt.Printf("  [%3.0f%%] %s\n", pct*100, step)
+		})
+		if err != nil {
+			return packagingFailed(fmt.Errorf("packaging %s failed: %w", src.Arch, err))
+		}
+
+		specPath, err := writeArchSpec(result.OutputPath, src.Arch)
+		if err != nil {
+			return err
+		}
+
+		printPackageResult(result)
+		fmt.Printf("  App spec:   %s\n", specPath)
+	}
+
+	if traceErr := writeTrace(opts.Tracer); traceErr != nil {
+		slog.Warn("could not write trace", "error", traceErr)
+	}
+	printTimings(opts.Tracer)
+	return nil
+}
+
+// writeArchSpec writes an app spec next to an arch-specific package, restricting it to arch
+func writeArchSpec(packagePath, arch string) (string, error) {
+	appInfo, err := packager.ReadDetectionXML(packagePath)
+	if err != nil {
+		return "", err
+	}
+
+	specPath := strings.TrimSuffix(packagePath, filepath.Ext(packagePath)) + ".yaml"
+	appSpec := &spec.AppSpec{
+		Name:          fmt.Sprintf("%s (%s)", appInfo.Name, arch),
+		Package:       filepath.Base(packagePath),
+		Architectures: arch,
+	}
+	if err := spec.WriteAppSpec(specPath, appSpec); err != nil {
+		return "", err
+	}
+	return specPath, nil
+}
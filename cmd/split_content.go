@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/listing"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/spec"
+)
+
+// runSplitContent packages a source too large for one package into content packages,
+// each staging its part on devices, and an install package depending on all of them
+// that joins the parts and runs the setup file
+func runSplitContent() error {
+	if contentPath == "" {
+		return invalidInput(fmt.Errorf("--content (-c) is required in quiet mode"))
+	}
+	if setupFile == "" {
+		return invalidInput(fmt.Errorf("--setup (-s) is required in quiet mode"))
+	}
+	if outputPath == "" {
+		return invalidInput(fmt.Errorf("--output (-o) is required in quiet mode"))
+	}
+	partSize, err := listing.ParseSize(partSizeFlag)
+	if err != nil || partSize < 1<<20 {
+		return invalidInput(fmt.Errorf("invalid --part-size %q: must be a size of at least 1MB", partSizeFlag))
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	opts, err := packagingOptions(ctx)
+	if err != nil {
+		return inputError(err)
+	}
+	plan, err := packager.PlanSplit(contentPath, setupFile, opts, packager.SplitOptions{PartSize: partSize, SetupArguments: setupArgs})
+	if err != nil {
+		return inputError(err)
+	}
+
+	fmt.Printf("Splitting %s (%s) into %d content packages of at most %s\n", contentPath, packager.FormatSize(plan.SourceSize), len(plan.Parts), packager.FormatSize(partSize))
+	for _, part := range plan.Parts {
+		chunks := 0
+		for _, slice := range part.Slices {
+			if slice.Chunk {
+				chunks++
+			}
+		}
+		line := fmt.Sprintf("  %s: %d files, %s", plan.ContentName(part.Index), len(part.Slices)-chunks, packager.FormatSize(part.Size))
+		if chunks > 0 {
+			line += fmt.Sprintf(", %d chunks", chunks)
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("  Staged on devices in %s\n", plan.StagingFolder)
+	if readOnly {
+		fmt.Println()
+		fmt.Println("Read-only mode: the packages were not created")
+		return nil
+	}
+
+	workDir, err := os.MkdirTemp("", "intunewin-split-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary folder: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+	parts, install, err := packager.StageSplit(plan, workDir)
+	if err != nil {
+		return packagingFailed(err)
+	}
+
+	notifier, err := newNotifier()
+	if err != nil {
+		return invalidInput(err)
+	}
+	defer notifier.Close()
+
+	// The staged folders hold only what was not excluded, plus the generated scripts
+	partOpts := opts
+	partOpts.Exclude = nil
+	partOpts.NoMsiSuite = true
+	partOpts.MsiOverrides = nil
+	installSpec := splitInstallSpec(plan, install)
+	for i, pkg := range append(parts, *install) {
+		fmt.Printf("\n[%s]\n", pkg.Name)
+		partOpts.OutputName = pkg.Name
+		result, err := packageNotified(ctx, notifier, pkg.Dir, pkg.SetupFile, outputPath, partOpts, func(step string, pct float64) {
+			fmt.Printf("  [%3.0f%%] %s\n", pct*100, step)
+		})
+		if err != nil {
+			return packagingFailed(fmt.Errorf("packaging %s failed: %w", pkg.Name, err))
+		}
+
+		appSpec := installSpec
+		if i < len(parts) {
+			appSpec = splitContentSpec(plan, pkg, i+1)
+		}
+		appSpec.Package = filepath.Base(result.OutputPath)
+		specPath := strings.TrimSuffix(result.OutputPath, filepath.Ext(result.OutputPath)) + ".yaml"
+		if err := spec.WriteAppSpec(specPath, appSpec); err != nil {
+			return err
+		}
+
+		printPackageResult(result)
+		fmt.Printf("  App spec:   %s\n", specPath)
+	}
+
+	if installSpec.Detection == nil {
+		fmt.Printf("\nAdd a detection rule and uninstall command for %s to %s.yaml before creating the app\n", setupFile, filepath.Join(outputPath, install.Name))
+	}
+	if traceErr := writeTrace(opts.Tracer); traceErr != nil {
+		slog.Warn("could not write trace", "error", traceErr)
+	}
+	printTimings(opts.Tracer)
+	return nil
+}
+
+// splitContentSpec returns the app spec of a content package, detected by the marker its
+// script leaves in the staging folder
+func splitContentSpec(plan *packager.SplitPlan, pkg packager.SplitPackage, index int) *spec.AppSpec {
+	return &spec.AppSpec{
+		Name:             splitContentApp(plan, index),
+		Notes:            fmt.Sprintf("Stages part %d of the content of %s. Installed as a dependency of %s, do not assign it.", index, plan.App, plan.App),
+		InstallCommand:   pkg.InstallCommand,
+		UninstallCommand: pkg.UninstallCommand,
+		Detection:        &spec.DetectionSpec{File: pkg.DetectionFile},
+	}
+}
+
+// splitInstallSpec returns the app spec of the install package, depending on the content
+// packages; MSI setup files are detected and uninstalled by their product code
+func splitInstallSpec(plan *packager.SplitPlan, install *packager.SplitPackage) *spec.AppSpec {
+	appSpec := &spec.AppSpec{
+		Name:           plan.App,
+		InstallCommand: install.InstallCommand,
+	}
+	for i := 1; i <= len(plan.Parts); i++ {
+		appSpec.DependsOn = append(appSpec.DependsOn, spec.RelationshipSpec{App: splitContentApp(plan, i)})
+	}
+	if packager.IsMsiFile(plan.SetupFile) {
+		if msi, err := packager.ExtractMsiInfo(filepath.Join(plan.SourcePath, plan.SetupFile)); err == nil && msi.ProductCode != "" {
+			appSpec.Name = firstNonEmpty(msi.ProductName, plan.App)
+			appSpec.Version = msi.ProductVersion
+			appSpec.Publisher = msi.Publisher
+			appSpec.UninstallCommand = fmt.Sprintf("msiexec /x %s /qn", msi.ProductCode)
+			appSpec.Detection = &spec.DetectionSpec{Rules: []map[string]any{{
+				"@odata.type":            "#microsoft.graph.win32LobAppProductCodeDetection",
+				"productCode":            msi.ProductCode,
+				"productVersionOperator": "notConfigured",
+			}}}
+		}
+	}
+	return appSpec
+}
+
+// splitContentApp returns the app name of the content package of part index (from 1)
+func splitContentApp(plan *packager.SplitPlan, index int) string {
+	return fmt.Sprintf("%s (content %d of %d)", plan.App, index, len(plan.Parts))
+}
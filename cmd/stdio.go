@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// stdioPath is the --content and --output value that streams through stdin and stdout
+const stdioPath = "-"
+
+// stdinSource returns stdin as the tar or ZIP stream of a source folder, which is
+// compressed into the package as it is read, without unpacking it anywhere
+func stdinSource() (io.Reader, error) {
+	if term.IsTerminal(os.Stdin.Fd()) {
+		return nil, fmt.Errorf("--content - reads a tar or ZIP stream from stdin, e.g. tar c app/ | intunewin -c - ...")
+	}
+	return os.Stdin, nil
+}
+
+// streamToStdout reserves stdout for the package and sends the messages of the run to
+// stderr instead
+// Returns the writer of the package and a function that restores stdout
+func streamToStdout() (io.Writer, func(), error) {
+	if term.IsTerminal(os.Stdout.Fd()) {
+		return nil, nil, fmt.Errorf("--output - writes the package to stdout, redirect it to a file or pipe")
+	}
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	return stdout, func() { os.Stdout = stdout }, nil
+}
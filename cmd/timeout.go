@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// timeoutGrace is how long a command may keep running after its --timeout passed, to stop
+// cleanly, before the process exits; calls that ignore cancelation, such as writes to a hung
+// network share, would otherwise block it until the job-level timeout of the CI agent
+const timeoutGrace = 30 * time.Second
+
+// commandTimeout is the deadline of a whole command run, 0 for none
+var commandTimeout time.Duration
+
+// timedOut is set when a command ended after the deadline of its commandContext passed
+var timedOut bool
+
+// timeoutError is the cause of a context that ended at the --timeout deadline
+type timeoutError struct {
+	timeout time.Duration
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s (--timeout)", e.timeout)
+}
+
+func (e *timeoutError) Unwrap() error { return context.DeadlineExceeded }
+
+// addTimeoutFlag adds the --timeout flag of commands that package, upload or download
+func addTimeoutFlag(flags *pflag.FlagSet) {
+	flags.DurationVar(&commandTimeout, "timeout", 0, "Fail when the command has not finished after this long, e.g. 30m (default no limit)")
+}
+
+// commandContext returns the context of a command run, which ends at the --timeout deadline
+// The process exits timeoutGrace after the deadline when the command has not returned by then
+func commandContext() (context.Context, context.CancelFunc) {
+	if commandTimeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+
+	cause := &timeoutError{timeout: commandTimeout}
+	ctx, cancel := context.WithTimeoutCause(context.Background(), commandTimeout, cause)
+	watchdog := time.AfterFunc(commandTimeout+timeoutGrace, func() {
+		if logFileHandle != nil {
+			logFileHandle.Sync()
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v; an operation did not stop within %s, exiting\n", cause, timeoutGrace)
+		os.Exit(exitTimedOut)
+	})
+	return ctx, func() {
+		watchdog.Stop()
+		if context.Cause(ctx) == error(cause) {
+			timedOut = true
+		}
+		cancel()
+	}
+}
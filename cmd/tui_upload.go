@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/bundle"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/config"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/graph"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/i18n"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/spec"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/tui"
+)
+
+// tuiTenants returns the tenants the TUI can upload to: those of the config profiles,
+// then the tenant of AZURE_TENANT_ID
+func tuiTenants(cfg *config.Config) []tui.Tenant {
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var tenants []tui.Tenant
+	for _, name := range names {
+		profile := cfg.Profiles[name]
+		if profile.TenantID != "" {
+			tenants = append(tenants, tui.Tenant{Profile: name, TenantID: profile.TenantID, Cloud: profile.Cloud})
+		}
+	}
+	if id := os.Getenv("AZURE_TENANT_ID"); id != "" {
+		tenants = append(tenants, tui.Tenant{TenantID: id})
+	}
+	return tenants
+}
+
+// tuiUploader returns the function the TUI creates apps and uploads packages with
+// The client ID of a tenant comes from its profile, else AZURE_CLIENT_ID; the client
+// secret always comes from AZURE_CLIENT_SECRET
+func tuiUploader(cfg *config.Config) tui.UploadFunc {
+	return func(ctx context.Context, req tui.UploadRequest, progress func(tui.UploadProgress)) (*tui.UploadResult, error) {
+		var profile config.Profile
+		if req.Tenant.Profile != "" {
+			profile = cfg.Profiles[req.Tenant.Profile]
+		}
+		creds := graph.Credentials{
+			TenantID:     req.Tenant.TenantID,
+			ClientID:     firstNonEmpty(profile.ClientID, os.Getenv("AZURE_CLIENT_ID")),
+			ClientSecret: os.Getenv("AZURE_CLIENT_SECRET"),
+		}
+		if err := creds.Validate(); err != nil {
+			return nil, fmt.Errorf("graph credentials: %w", err)
+		}
+		cloud, err := graph.ParseCloud(req.Tenant.Cloud)
+		if err != nil {
+			return nil, err
+		}
+		client := graph.NewClient(creds)
+		client.SetCloud(cloud)
+		client.SetHTTPOptions(graphHTTPOptions(graph.DefaultHTTPOptions))
+		if server := os.Getenv(graphURLEnv); server != "" {
+			client.SetServer(server)
+		}
+
+		appSpec := &spec.AppSpec{
+			Package:          req.Package,
+			Name:             req.Name,
+			Version:          req.Version,
+			Publisher:        req.Publisher,
+			Description:      req.Description,
+			InstallCommand:   req.InstallCommand,
+			UninstallCommand: req.UninstallCommand,
+		}
+		if req.DetectFile != "" {
+			appSpec.Detection = &spec.DetectionSpec{File: req.DetectFile}
+		}
+		if err := applyPackageRequirements(appSpec); err != nil {
+			return nil, err
+		}
+		appSpec.Architectures = firstNonEmpty(appSpec.Architectures, "x64")
+		if err := applyReturnCodes(appSpec); err != nil {
+			return nil, err
+		}
+		plan, err := planApp(appSpec)
+		if err != nil {
+			return nil, err
+		}
+
+		// The package is read before the app is created, so a bad package changes nothing
+		progress(tui.UploadProgress{Step: i18n.N("Reading package...")})
+		b, err := bundle.OpenPackage(req.Package)
+		if err != nil {
+			return nil, err
+		}
+		defer b.Close()
+		content, err := b.Content()
+		if err != nil {
+			return nil, err
+		}
+		defer content.Close()
+		upload, err := b.Upload(content)
+		if err != nil {
+			return nil, err
+		}
+
+		progress(tui.UploadProgress{Step: i18n.N("Creating app...")})
+		result, err := client.CreateWin32App(ctx, plan.app, graph.ConflictFail)
+		if err != nil {
+			return nil, err
+		}
+
+		progress(tui.UploadProgress{Step: i18n.N("Uploading content..."), Total: upload.SizeEncrypted})
+		upload.Progress = func(uploaded int64) {
+			progress(tui.UploadProgress{Step: i18n.N("Uploading content..."), Uploaded: uploaded, Total: upload.SizeEncrypted})
+		}
+		if err := client.UploadContent(ctx, result.App.ID, upload); err != nil {
+			return nil, fmt.Errorf("app %s created, but its content was not uploaded: %w", result.App.ID, err)
+		}
+
+		return &tui.UploadResult{
+			AppID:       result.App.ID,
+			DisplayName: result.App.DisplayName,
+			PortalURL:   cloud.AppPortalURL(result.App.ID),
+		}, nil
+	}
+}
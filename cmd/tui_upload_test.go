@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/config"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/graphtest"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/tui"
+)
+
+// writeTestPackage packages a setup file and returns the path of the .intunewin
+func writeTestPackage(t *testing.T) string {
+	t.Helper()
+	source := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "setup.exe"), []byte("7-Zip 24.01 setup"), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+	opts := packager.Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	result, err := packager.PackageWithOptions(source, "setup.exe", t.TempDir(), opts, nil)
+	if err != nil {
+		t.Fatalf("Failed to create package: %v", err)
+	}
+	return result.OutputPath
+}
+
+// startMockGraph serves a mock tenant for the Graph commands, failing the Azure Storage
+// uploads when failStorage is set
+func startMockGraph(t *testing.T, failStorage bool) *graphtest.Server {
+	t.Helper()
+	mock := graphtest.New()
+	handler := mock.Handler()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failStorage && strings.HasPrefix(r.URL.Path, "/storage/") {
+			http.Error(w, "AuthorizationFailure", http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv(graphURLEnv, server.URL)
+	t.Setenv("AZURE_CLIENT_ID", "client")
+	t.Setenv("AZURE_CLIENT_SECRET", "secret")
+	return mock
+}
+
+// testUploadRequest returns an upload of a package to the tenant of a profile
+func testUploadRequest(packagePath string) tui.UploadRequest {
+	return tui.UploadRequest{
+		Tenant:           tui.Tenant{Profile: "contoso", TenantID: "contoso-id"},
+		Package:          packagePath,
+		Name:             "7-Zip",
+		Version:          "24.01",
+		Publisher:        "Igor Pavlov",
+		InstallCommand:   "setup.exe /S",
+		UninstallCommand: `"%ProgramFiles%\7-Zip\Uninstall.exe" /S`,
+		DetectFile:       `%ProgramFiles%\7-Zip\7z.exe`,
+	}
+}
+
+func TestTUIUploader(t *testing.T) {
+	mock := startMockGraph(t, false)
+	cfg := &config.Config{Profiles: map[string]config.Profile{"contoso": {TenantID: "contoso-id", ClientID: "profile-client"}}}
+	upload := tuiUploader(cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	var steps []string
+	var uploaded, total int64
+	result, err := upload(ctx, testUploadRequest(writeTestPackage(t)), func(p tui.UploadProgress) {
+		if len(steps) == 0 || steps[len(steps)-1] != p.Step {
+			steps = append(steps, p.Step)
+		}
+		uploaded, total = p.Uploaded, p.Total
+	})
+	if err != nil {
+		t.Fatalf("upload() error = %v", err)
+	}
+
+	apps := mock.Apps()
+	if len(apps) != 1 || result.AppID != apps[0].Object["id"] || result.DisplayName != "7-Zip" || result.PortalURL == "" {
+		t.Fatalf("Result = %+v, apps %+v, want the created app", result, apps)
+	}
+	if len(apps[0].ContentVersions) != 1 || len(apps[0].ContentVersions[0].Files) != 1 ||
+		!apps[0].ContentVersions[0].Files[0].IsCommitted {
+		t.Errorf("Content = %+v, want one committed file", apps[0].ContentVersions)
+	}
+	if want := []string{"Reading package...", "Creating app...", "Uploading content..."}; strings.Join(steps, ",") != strings.Join(want, ",") {
+		t.Errorf("Steps = %v, want %v", steps, want)
+	}
+	if total == 0 || uploaded != total {
+		t.Errorf("Last progress %d of %d bytes, want all of them", uploaded, total)
+	}
+}
+
+func TestTUIUploaderErrors(t *testing.T) {
+	mock := startMockGraph(t, true)
+	cfg := &config.Config{}
+	upload := tuiUploader(cfg)
+	progress := func(tui.UploadProgress) {}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// A package that cannot be read changes nothing in the tenant
+	req := testUploadRequest(filepath.Join(t.TempDir(), "missing.intunewin"))
+	if _, err := upload(ctx, req, progress); err == nil {
+		t.Error("Expected error for a missing package")
+	}
+	if apps := mock.Apps(); len(apps) != 0 {
+		t.Fatalf("A missing package created %d apps", len(apps))
+	}
+
+	// The app is kept when its content fails to upload, and the error names it
+	req = testUploadRequest(writeTestPackage(t))
+	_, err := upload(ctx, req, progress)
+	apps := mock.Apps()
+	if len(apps) != 1 {
+		t.Fatalf("Failed upload left %d apps, want the created one", len(apps))
+	}
+	id, _ := apps[0].Object["id"].(string)
+	if err == nil || !strings.Contains(err.Error(), "app "+id+" created, but its content was not uploaded") {
+		t.Errorf("upload() error = %v, want the created app named", err)
+	}
+
+	// Credentials are checked first
+	t.Setenv("AZURE_CLIENT_SECRET", "")
+	if _, err := upload(ctx, req, progress); err == nil || !strings.Contains(err.Error(), "graph credentials") {
+		t.Errorf("upload() without a client secret: error = %v", err)
+	}
+}
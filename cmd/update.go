@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/selfupdate"
+)
+
+// updatePublicKey is the base64 Ed25519 key release checksums are signed with,
+// embedded at build time with -ldflags "-X .../cmd.updatePublicKey=<key>"
+// Builds without it can only --install with --public-key
+var updatePublicKey string
+
+var (
+	updateInstall   bool
+	updateForce     bool
+	updatePublicArg string
+	versionCheck    bool
+)
+
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Check for a newer release and update this binary in place",
+	Long: `Check the GitHub releases for a newer version of LetsGoIntunePackager and,
+with --install, replace the running binary with it. Packaging machines are
+often headless build agents that are never updated by hand; run this from a
+scheduled task to keep them current.
+
+The binary for this platform is downloaded with the release's SHA256SUMS and
+SHA256SUMS.sig, and installed only when the signature is a valid Ed25519
+signature of the checksums for the signing key built into release binaries (or
+given with --public-key) and its SHA256 matches. Builds without a key refuse
+to install unless --public-key is given.
+
+On Windows the running executable cannot be overwritten: it is renamed to
+<name>.old, which the next update removes.
+
+Set GITHUB_TOKEN to raise the API rate limit on shared build agents.
+
+Examples:
+  intunewin update
+  intunewin update --install
+  intunewin update --install --public-key <base64-key>`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runUpdate()
+	},
+}
+
+func init() {
+	updateCmd.Flags().BoolVar(&updateInstall, "install", false, "Download, verify and install the latest release")
+	updateCmd.Flags().BoolVar(&updateForce, "force", false, "Install even if this is a development build or already the latest version")
+	updateCmd.Flags().StringVar(&updatePublicArg, "public-key", "", "Base64 Ed25519 key to verify SHA256SUMS.sig with (overrides the built-in key)")
+	rootCmd.Flags().BoolVar(&versionCheck, "version-check", false, "Report whether a newer release is available and exit")
+	rootCmd.AddCommand(updateCmd)
+}
+
+// runVersionCheck reports whether a newer release than this build exists
+func runVersionCheck() error {
+	release, err := latestRelease()
+	if err != nil {
+		return err
+	}
+	if selfupdate.Newer(release.Tag, version) {
+		fmt.Printf("A newer version is available: %s (this is %s)\n", release.Tag, version)
+		fmt.Println("Run 'intunewin update --install' to update, or download it from", release.URL)
+		return nil
+	}
+	fmt.Printf("LetsGoIntunePackager %s is up to date (latest release %s)\n", version, release.Tag)
+	return nil
+}
+
+func runUpdate() error {
+	if !updateInstall {
+		return runVersionCheck()
+	}
+
+	key := firstNonEmpty(updatePublicArg, updatePublicKey)
+	if key == "" {
+		return fmt.Errorf("this build has no release signing key to verify the download with; pass it with --public-key")
+	}
+	publicKey, err := selfupdate.ParsePublicKey(key)
+	if err != nil {
+		return err
+	}
+
+	release, err := latestRelease()
+	if err != nil {
+		return err
+	}
+	if !updateForce {
+		if !selfupdate.IsRelease(version) {
+			return fmt.Errorf("this is a development build (%s); use --force to replace it with %s", version, release.Tag)
+		}
+		if !selfupdate.Newer(release.Tag, version) {
+			fmt.Printf("LetsGoIntunePackager %s is up to date (latest release %s)\n", version, release.Tag)
+			return nil
+		}
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the running binary: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	selfupdate.CleanupOld(exe)
+
+	fmt.Printf("Downloading %s %s...\n", selfupdate.AssetName(runtime.GOOS, runtime.GOARCH), release.Tag)
+	data, err := newUpdateClient().Download(context.Background(), release, runtime.GOOS, runtime.GOARCH, publicKey)
+	if err != nil {
+		return err
+	}
+	if err := selfupdate.Replace(exe, data, runtime.GOOS); err != nil {
+		return err
+	}
+	fmt.Printf("Updated %s from %s to %s\n", exe, version, release.Tag)
+	return nil
+}
+
+// latestRelease reads the latest published release
+func latestRelease() (*selfupdate.Release, error) {
+	return newUpdateClient().Latest(context.Background())
+}
+
+func newUpdateClient() *selfupdate.Client {
+	client := selfupdate.NewClient(httpTransport)
+	client.Token = os.Getenv("GITHUB_TOKEN")
+	return client
+}
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/bundle"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/graph"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+var (
+	exportBundleOutput string
+	uploadOnConflict   string
+)
+
+var exportUploadBundleCmd = &cobra.Command{
+	Use:   "export-upload-bundle",
+	Short: "Write a package and its app to one archive to upload from another machine",
+	Long: `Write everything needed to create and upload a Win32 app to one archive,
+for packaging machines on an isolated network: the encrypted content and
+Detection.xml of the package, the app metadata and the content sizes.
+Carry the bundle to a connected machine and run import-and-upload there.
+
+The app is described with the same flags and app spec as 'apps create'.
+Assignments and relationships are stored by name and resolved in the
+tenant at upload time. No Graph request is made.
+
+The bundle holds the encryption keys of the package: handle it like the
+.intunewin itself.
+
+Examples:
+  intunewin export-upload-bundle --package ./output/7z2401-x64.intunewin
+  intunewin export-upload-bundle --spec 7zip.yaml -o /media/usb/7zip` + bundle.Extension,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runExportUploadBundle(cmd)
+	},
+}
+
+var importAndUploadCmd = &cobra.Command{
+	Use:   "import-and-upload <bundle>",
+	Short: "Create a Win32 app from an upload bundle and upload its content",
+	Long: `Create the Win32 app stored in a bundle written by export-upload-bundle,
+upload its encrypted content to Intune and apply its relationships and
+assignments.
+
+The content is checked against the size and SHA256 recorded in the bundle
+before anything is sent. --on-conflict overrides the conflict policy of
+the bundle, and --assign-group adds assignments to those of the bundle.
+Use --what-if to review the requests first.
+
+Examples:
+  intunewin import-and-upload /media/usb/7zip` + bundle.Extension + ` --what-if
+  intunewin import-and-upload /media/usb/7zip` + bundle.Extension + ` --on-conflict update
+  intunewin import-and-upload /media/usb/7zip` + bundle.Extension + ` --assign-group "All Users" --intent available`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runImportAndUpload(cmd, args[0])
+	},
+}
+
+func init() {
+	addAppFlags(exportUploadBundleCmd.Flags())
+	exportUploadBundleCmd.Flags().StringVarP(&exportBundleOutput, "output", "o", "", "Bundle file to write (default: the package path with "+bundle.Extension+")")
+
+	importAndUploadCmd.Flags().StringVar(&uploadOnConflict, "on-conflict", "", "When an app with the same name exists: fail, suffix or update (default: the policy of the bundle)")
+	addAssignFlags(importAndUploadCmd.Flags())
+	addGraphFlags(importAndUploadCmd.Flags())
+	addTimeoutFlag(importAndUploadCmd.Flags())
+
+	rootCmd.AddCommand(exportUploadBundleCmd)
+	rootCmd.AddCommand(importAndUploadCmd)
+}
+
+func runExportUploadBundle(cmd *cobra.Command) error {
+	appSpec, err := appSpecFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	plan, err := planApp(appSpec)
+	if err != nil {
+		return err
+	}
+
+	output := exportBundleOutput
+	if output == "" {
+		output = strings.TrimSuffix(appSpec.Package, ".intunewin") + bundle.Extension
+	}
+	manifest, err := bundle.Write(output, appSpec.Package, plan.app, bundle.Manifest{
+		ToolVersion:   version,
+		OnConflict:    string(plan.policy),
+		Relationships: plan.relationships,
+		Assignments:   plan.assignments,
+	})
+	if err != nil {
+		return inputError(err)
+	}
+
+	fmt.Printf("Wrote upload bundle %s\n", output)
+	fmt.Printf("  App:     %s %s\n", plan.app.DisplayName, plan.app.DisplayVersion)
+	fmt.Printf("  Content: %s encrypted (SHA256 %s)\n", packager.FormatSize(manifest.Content.SizeEncrypted), manifest.Content.SHA256)
+	return nil
+}
+
+func runImportAndUpload(cmd *cobra.Command, path string) error {
+	b, err := bundle.Open(path)
+	if err != nil {
+		return inputError(err)
+	}
+	defer b.Close()
+	if err := b.Verify(); err != nil {
+		return inputError(err)
+	}
+
+	onConflict := b.Manifest.OnConflict
+	if cmd.Flags().Changed("on-conflict") {
+		onConflict = uploadOnConflict
+	}
+	policy, err := graph.ParseConflictPolicy(firstNonEmpty(onConflict, string(graph.ConflictFail)))
+	if err != nil {
+		return invalidInput(err)
+	}
+	assignments, err := flagAssignments(cmd)
+	if err != nil {
+		return invalidInput(err)
+	}
+
+	client, err := newGraphClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	result, err := client.CreateWin32App(ctx, b.App, policy)
+	if err != nil {
+		return uploadFailed(err)
+	}
+	printCreatedApp(result)
+
+	content, err := b.Content()
+	if err != nil {
+		return inputError(err)
+	}
+	defer content.Close()
+	upload, err := b.Upload(content)
+	if err != nil {
+		return inputError(err)
+	}
+	if err := client.UploadContent(ctx, result.App.ID, upload); err != nil {
+		return uploadFailed(err)
+	}
+	fmt.Printf("  Uploaded %s of content\n", packager.FormatSize(upload.SizeEncrypted))
+
+	return linkApp(ctx, client, result.App.ID, b.Manifest.Relationships, append(b.Manifest.Assignments, assignments...))
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/spec"
+)
+
+var (
+	validateSpecType  string
+	validatePrintType string
+)
+
+var validateSpecCmd = &cobra.Command{
+	Use:   "validate-spec <file>...",
+	Short: "Validate batch manifests and app specs against their JSON Schemas",
+	Long: `Validate YAML or JSON batch manifests and app specs without running them.
+
+The file type is detected from its content (batch manifests have a
+'packages' list) unless --type is given. The schemas are embedded in the
+binary; --print-schema writes one to stdout for use with editors.
+
+Editors using the YAML language server (e.g. VS Code) can reference the
+published schema with a comment on the first line of the spec:
+  # yaml-language-server: $schema=https://raw.githubusercontent.com/michelbragaguimaraes/LetsGoIntunePackager/main/internal/spec/schemas/app-spec.schema.json
+
+Examples:
+  intunewin validate-spec 7zip.yaml batch.yaml
+  intunewin validate-spec --print-schema app > app-spec.schema.json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if validatePrintType != "" {
+			return runPrintSchema(validatePrintType)
+		}
+		if len(args) == 0 {
+			return invalidInput(fmt.Errorf("at least one spec file is required"))
+		}
+		return runValidateSpec(args)
+	},
+}
+
+func init() {
+	validateSpecCmd.Flags().StringVar(&validateSpecType, "type", "", "Spec type: app or batch (default: detect)")
+	validateSpecCmd.Flags().StringVar(&validatePrintType, "print-schema", "", "Print the JSON Schema of a spec type (app or batch) and exit")
+	rootCmd.AddCommand(validateSpecCmd)
+}
+
+func runValidateSpec(paths []string) error {
+	var invalid int
+	for _, path := range paths {
+		kind, err := validateSpecFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			invalid++
+			continue
+		}
+		fmt.Printf("%s: valid %s\n", path, kind)
+	}
+
+	if invalid > 0 {
+		return invalidInput(fmt.Errorf("%d of %d file(s) invalid", invalid, len(paths)))
+	}
+	return nil
+}
+
+// validateSpecFile validates a single spec file and returns its kind
+func validateSpecFile(path string) (spec.Kind, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	var kind spec.Kind
+	if validateSpecType != "" {
+		kind, err = spec.ParseKind(validateSpecType)
+	} else {
+		kind, err = spec.DetectKind(data)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return kind, spec.Validate(kind, data)
+}
+
+func runPrintSchema(kindName string) error {
+	kind, err := spec.ParseKind(kindName)
+	if err != nil {
+		return err
+	}
+	schema, err := spec.Schema(kind)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(schema)
+	return err
+}
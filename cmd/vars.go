@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/azblob"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/config"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/graph"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/secrets"
+)
+
+// secretsPassphraseEnv holds the passphrase of secrets files
+const secretsPassphraseEnv = "INTUNEWIN_SECRETS_PASSPHRASE"
+
+// templateVars are the --var KEY=VALUE assignments stamped into scripts of the package
+var templateVars []string
+
+func init() {
+	rootCmd.Flags().StringArrayVar(&templateVars, "var", nil, "Template variable KEY=VALUE substituted for {{KEY}} in .ps1, .psm1, .psd1, .cmd and .bat files of the package; VALUE may be a secretref: (repeatable)")
+	// Error messages may quote a resolved secret
+	rootCmd.SetErr(secrets.Default.Writer(os.Stderr))
+}
+
+// templateVariables merges the template variables of the profile with the --var
+// assignments, which win, and resolves their secret references within ctx; nil when
+// there are none. secret reports whether any value was resolved from a secret reference
+func templateVariables(ctx context.Context, profile *config.Profile, assignments []string) (vars map[string]string, secret bool, err error) {
+	vars, err = packager.ParseVariables(assignments)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(profile.Vars) > 0 {
+		merged := make(map[string]string, len(profile.Vars)+len(vars))
+		for name, value := range profile.Vars {
+			merged[name] = value
+		}
+		for name, value := range vars {
+			merged[name] = value
+		}
+		vars = merged
+	}
+	for _, value := range vars {
+		secret = secret || secrets.IsRef(value)
+	}
+	if err := secretResolver(profile).ResolveAll(ctx, vars); err != nil {
+		return nil, false, err
+	}
+	return vars, secret, nil
+}
+
+// secretResolver returns the resolver of secretref: values
+func secretResolver(profile *config.Profile) *secrets.Resolver {
+	return &secrets.Resolver{
+		KeyVault:   secrets.NewKeyVault(keyVaultTokens(profile), httpTransport),
+		Passphrase: secretsPassphrase,
+	}
+}
+
+// keyVaultTokens returns the Key Vault token source: the app registration of
+// AZURE_CLIENT_SECRET, or else the managed identity of the machine
+func keyVaultTokens(profile *config.Profile) secrets.TokenSource {
+	creds := graph.Credentials{
+		TenantID:     firstNonEmpty(os.Getenv("AZURE_TENANT_ID"), profile.TenantID),
+		ClientID:     firstNonEmpty(os.Getenv("AZURE_CLIENT_ID"), profile.ClientID),
+		ClientSecret: os.Getenv("AZURE_CLIENT_SECRET"),
+	}
+	if creds.Validate() == nil {
+		tokens := graph.NewTokenSource(creds, secrets.KeyVaultScope)
+		tokens.SetHTTPOptions(graphHTTPOptions(graph.DefaultHTTPOptions))
+		return tokens
+	}
+	identity := azblob.NewManagedIdentity(os.Getenv("AZURE_CLIENT_ID"), httpTransport)
+	identity.Resource = secrets.KeyVaultResource
+	return identity
+}
+
+// secretsPassphrase returns the passphrase of secrets files
+func secretsPassphrase() ([]byte, error) {
+	passphrase := os.Getenv(secretsPassphraseEnv)
+	if passphrase == "" {
+		return nil, fmt.Errorf("set %s to the passphrase of secrets files", secretsPassphraseEnv)
+	}
+	return []byte(passphrase), nil
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/signing"
+)
+
+var (
+	verifyStrictMS   bool
+	verifyProvenance bool
+	verifyPublicKey  string
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify <package.intunewin>",
+	Short: "Check that a .intunewin package is intact and matches IntuneWinAppUtil output",
+	Long: `Decrypt a .intunewin package with the keys from its Detection.xml and check
+its MAC, file digest and content size, and that the content is a readable ZIP.
+
+With --strict-ms the package is also compared with every structural detail of
+Microsoft's IntuneWinAppUtil output, and each divergence is reported:
+  - the entries of the package, their order and the Store (uncompressed) method
+  - Detection.xml without XML declaration or byte order mark, with CRLF line
+    endings, two-space indentation and the official attribute and element order
+  - a four-part ToolVersion, the fixed FileName, ProfileIdentifier and
+    FileDigestAlgorithm values, key sizes and the layout of the encrypted content
+
+With --provenance the package must match the SHA256 of its provenance statement
+(written by packaging with --provenance or --sign). --public-key also checks the
+Ed25519 signature of the statement; signatures made with gpg, minisign or cosign
+are checked with those tools.
+
+The command fails when the package is damaged or, with --strict-ms, diverges.
+
+Examples:
+  intunewin verify ./output/7z2401-x64.intunewin
+  intunewin verify ./output/7z2401-x64.intunewin --strict-ms
+  intunewin verify ./output/7z2401-x64.intunewin --public-key signing.pub`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runVerify(args[0])
+	},
+}
+
+func init() {
+	verifyCmd.Flags().BoolVar(&verifyStrictMS, "strict-ms", false, "Also compare the package structure with IntuneWinAppUtil output")
+	verifyCmd.Flags().BoolVar(&verifyProvenance, "provenance", false, "Also check the package against its provenance statement")
+	verifyCmd.Flags().StringVar(&verifyPublicKey, "public-key", "", "Ed25519 public key file the provenance statement is signed with (implies --provenance)")
+	rootCmd.AddCommand(verifyCmd)
+}
+
+func runVerify(path string) error {
+	report, err := packager.VerifyPackage(path, verifyStrictMS)
+	if err != nil {
+		return verificationFailed(fmt.Errorf("package verification failed: %w", err))
+	}
+
+	fmt.Printf("Package:    %s\n", path)
+	fmt.Printf("Name:       %s\n", report.Name)
+	fmt.Printf("Setup file: %s\n", report.SetupFile)
+	fmt.Printf("Content:    %d file(s), %s\n", report.FileCount, packager.FormatSize(report.ContentSize))
+	fmt.Println("Integrity:  OK (MAC, digest and content size match)")
+
+	if verifyProvenance || verifyPublicKey != "" {
+		if err := checkProvenance(path); err != nil {
+			return verificationFailed(err)
+		}
+	}
+
+	if !verifyStrictMS {
+		return nil
+	}
+	if len(report.Divergences) == 0 {
+		fmt.Println("Structure:  matches IntuneWinAppUtil output")
+		return nil
+	}
+	fmt.Println("Structure:  differs from IntuneWinAppUtil output")
+	for _, d := range report.Divergences {
+		fmt.Printf("  %s\n", d)
+	}
+	return verificationFailed(fmt.Errorf("%d divergence(s) from IntuneWinAppUtil output", len(report.Divergences)))
+}
+
+// checkProvenance checks the package against its provenance statement and, with
+// --public-key, the signature of the statement
+func checkProvenance(path string) error {
+	statement, err := packager.ReadProvenance(path)
+	if err != nil {
+		return err
+	}
+	if statement == nil {
+		return fmt.Errorf("package has no provenance statement (%s)", packager.ProvenancePath(path))
+	}
+	if err := packager.CheckProvenance(path, statement); err != nil {
+		return fmt.Errorf("provenance check failed: %w", err)
+	}
+	fmt.Println("Provenance: OK (package matches its SHA256)")
+
+	if verifyPublicKey == "" {
+		return nil
+	}
+	keyData, err := os.ReadFile(verifyPublicKey)
+	if err != nil {
+		return fmt.Errorf("failed to read public key: %w", err)
+	}
+	publicKey, err := signing.ParsePublicKey(keyData)
+	if err != nil {
+		return err
+	}
+	message, err := os.ReadFile(packager.ProvenancePath(path))
+	if err != nil {
+		return fmt.Errorf("failed to read provenance: %w", err)
+	}
+	sig, err := os.ReadFile(packager.SignaturePath(path))
+	if err != nil {
+		return fmt.Errorf("failed to read provenance signature: %w", err)
+	}
+	if err := signing.Verify(publicKey, message, sig); err != nil {
+		return fmt.Errorf("provenance signature check failed: %w", err)
+	}
+	fmt.Println("Signature:  OK (provenance signed by the public key)")
+	return nil
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"context"
+	"os"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/webhook"
+)
+
+// Environment variables configuring the webhook, so build agents can set it once for
+// every command (batch and resume have no --webhook flag)
+const (
+	webhookEnv       = "INTUNEWIN_WEBHOOK_URL"
+	webhookSecretEnv = "INTUNEWIN_WEBHOOK_SECRET"
+)
+
+// newNotifier returns the notifier of --webhook or INTUNEWIN_WEBHOOK_URL, or nil when
+// no webhook is configured
+func newNotifier() (*webhook.Notifier, error) {
+	url := firstNonEmpty(webhookURL, os.Getenv(webhookEnv))
+	if url == "" {
+		return nil, nil
+	}
+	var secret []byte
+	if s := os.Getenv(webhookSecretEnv); s != "" {
+		secret = []byte(s)
+	}
+	return webhook.New(url, secret, version, httpTransport, nil)
+}
+
+// packageNotified packages like packager.PackageContext and reports the run to the
+// webhook of notifier (if any)
+func packageNotified(ctx context.Context, notifier *webhook.Notifier, source, setup, output string, opts packager.Options, progress packager.ProgressCallback) (*packager.PackageResult, error) {
+	run := notifier.Start(source, setup, output)
+	result, err := packager.PackageContext(ctx, source, setup, output, opts, func(step string, pct float64) {
+		if progress != nil {
+			progress(step, pct)
+		}
+		run.Progress(step, pct)
+	})
+	if err != nil {
+		run.Failed(err)
+		return nil, err
+	}
+	run.Completed(result)
+	return result, nil
+}
@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/metrics"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/worker"
+)
+
+var (
+	workerQueue       string
+	workerName        string
+	workerOutput      string
+	workerConcurrency int
+	workerOnce        bool
+	workerPoll        time.Duration
+	workerStaleAfter  time.Duration
+	workerJobTimeout  time.Duration
+	workerSource      string
+	workerSetup       string
+	workerFormat      string
+)
+
+var workerCmd = &cobra.Command{
+	Use:   "worker",
+	Short: "Package jobs from a queue shared by a farm of build machines",
+	Long: `Scale packaging out over several machines: jobs are submitted to a queue and
+every machine running 'worker run' takes jobs from it, packages them and
+records the results in the queue.
+
+The queue is a spool folder, usually on a file share all machines mount (a
+folder path, UNC path or file:// URL). Paths of jobs must be valid on the
+workers, so use shared or identical paths.`,
+}
+
+var workerRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Take jobs from the queue and package them",
+	Long: `Take jobs from the queue, oldest first, and package them with the packaging
+settings of the active config profile, until stopped with Ctrl+C. A stopping
+worker finishes its running jobs first; a second Ctrl+C exits immediately.
+
+Workers mark their running jobs every 30 seconds. Jobs of a worker that has not
+done so for --stale-after (a crashed or disconnected machine) are queued again
+and picked up by another worker.
+
+With --once the worker exits when the queue is empty, with a non-zero exit
+code if any job failed, e.g. to drain the queue from a CI job.
+
+Examples:
+  intunewin worker run --queue //fileserver/packaging/queue --concurrency 2
+  intunewin worker run --queue ./queue --output ./output --once`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWorker()
+	},
+}
+
+var workerSubmitCmd = &cobra.Command{
+	Use:   "submit",
+	Short: "Queue a packaging job",
+	Long: `Queue a packaging job and print its ID. The source folder is made absolute;
+jobs without --output are written to the default output of the worker.
+
+Example:
+  intunewin worker submit --queue //fileserver/packaging/queue \
+    -c //fileserver/apps/7zip -s 7z2401-x64.msi -o //fileserver/packages`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWorkerSubmit()
+	},
+}
+
+var workerStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "List the jobs of the queue",
+	Long: `List the jobs of the queue, oldest first, with their status, worker and package
+or error.
+
+Example:
+  intunewin worker status --queue //fileserver/packaging/queue --format json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWorkerStatus()
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{workerRunCmd, workerSubmitCmd, workerStatusCmd} {
+		c.Flags().StringVar(&workerQueue, "queue", "", "Queue of the jobs: a spool folder or file:// URL")
+		c.MarkFlagRequired("queue")
+	}
+
+	workerRunCmd.Flags().StringVar(&workerName, "name", "", "Name of the worker recorded in its jobs (default: host name and process ID)")
+	workerRunCmd.Flags().StringVarP(&workerOutput, "output", "o", "", "Output folder of jobs queued without one (default: output of the profile)")
+	workerRunCmd.Flags().IntVar(&workerConcurrency, "concurrency", 1, "Number of jobs packaged at the same time")
+	workerRunCmd.Flags().BoolVar(&workerOnce, "once", false, "Exit when the queue is empty instead of waiting for new jobs")
+	workerRunCmd.Flags().DurationVar(&workerPoll, "poll", 5*time.Second, "Wait between checks of an empty queue")
+	workerRunCmd.Flags().DurationVar(&workerStaleAfter, "stale-after", 5*time.Minute, "Queue running jobs again when their worker has not marked them for this long")
+	workerRunCmd.Flags().DurationVar(&workerJobTimeout, "job-timeout", 0, "Fail jobs that run longer than this, e.g. 30m (default no limit)")
+	addMetricsListenFlag(workerRunCmd.Flags())
+
+	workerSubmitCmd.Flags().StringVarP(&workerSource, "content", "c", "", "Source folder containing setup files")
+	workerSubmitCmd.Flags().StringVarP(&workerSetup, "setup", "s", "", "Setup file name (e.g., setup.msi or install.exe)")
+	workerSubmitCmd.Flags().StringVarP(&workerOutput, "output", "o", "", "Output folder for the .intunewin file (default: output of the worker)")
+	workerSubmitCmd.MarkFlagRequired("content")
+	workerSubmitCmd.MarkFlagRequired("setup")
+
+	workerStatusCmd.Flags().StringVar(&workerFormat, "format", "text", "Output format: text or json")
+
+	workerCmd.AddCommand(workerRunCmd, workerSubmitCmd, workerStatusCmd)
+	rootCmd.AddCommand(workerCmd)
+}
+
+func runWorker() error {
+	if workerConcurrency < 1 {
+		return invalidInput(fmt.Errorf("--concurrency must be at least 1"))
+	}
+	if workerStaleAfter <= 30*time.Second {
+		return invalidInput(fmt.Errorf("--stale-after must be longer than the 30s heartbeat interval"))
+	}
+	queue, err := worker.Open(workerQueue)
+	if err != nil {
+		return invalidInput(err)
+	}
+	profile, err := activeProfile()
+	if err != nil {
+		return invalidInput(err)
+	}
+	opts, err := packagingOptions(context.Background())
+	if err != nil {
+		return inputError(err)
+	}
+	notifier, err := newNotifier()
+	if err != nil {
+		return invalidInput(err)
+	}
+	defer notifier.Close()
+
+	var reg *metrics.Registry
+	if metricsListen != "" {
+		reg = metrics.New()
+	}
+	stopMetrics, err := serveMetrics(reg)
+	if err != nil {
+		return invalidInput(err)
+	}
+	defer stopMetrics()
+
+	name := workerName
+	if name == "" {
+		host, _ := os.Hostname()
+		name = fmt.Sprintf("%s-%d", firstNonEmpty(host, "worker"), os.Getpid())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// Let a second Ctrl+C terminate the process
+		stop()
+	}()
+
+	w := worker.New(worker.Config{
+		Queue:         queue,
+		Name:          name,
+		Concurrency:   workerConcurrency,
+		DefaultOutput: firstNonEmpty(workerOutput, profile.Output),
+		PollInterval:  workerPoll,
+		StaleAfter:    workerStaleAfter,
+		JobTimeout:    workerJobTimeout,
+		Once:          workerOnce,
+		Package: func(ctx context.Context, job worker.Job, progress packager.ProgressCallback) (*packager.PackageResult, error) {
+			if err := os.MkdirAll(job.OutputPath, 0755); err != nil {
+				return nil, fmt.Errorf("failed to create output directory: %w", err)
+			}
+			return packageMeasured(ctx, reg, notifier, job.SourcePath, job.SetupFile, job.OutputPath, opts, progress)
+		},
+		Finished: func(job worker.Job) {
+			if job.Status == worker.StatusSucceeded {
+				fmt.Printf("%s  succeeded  %s\n", job.ID, job.Package)
+			} else {
+				fmt.Printf("%s  failed     %s\n", job.ID, job.Error)
+			}
+		},
+	})
+
+	if workerOnce {
+		fmt.Printf("Worker %s packaging the jobs of %s\n", name, queue)
+	} else {
+		fmt.Printf("Worker %s waiting for jobs in %s (Ctrl+C to stop)\n", name, queue)
+	}
+	succeeded, failed, err := w.Run(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d succeeded, %d failed\n", succeeded, failed)
+	if workerOnce && failed > 0 {
+		return packagingFailed(fmt.Errorf("%d job(s) failed", failed))
+	}
+	return nil
+}
+
+func runWorkerSubmit() error {
+	queue, err := worker.Open(workerQueue)
+	if err != nil {
+		return invalidInput(err)
+	}
+	source, err := filepath.Abs(workerSource)
+	if err != nil {
+		return invalidInput(err)
+	}
+	output := workerOutput
+	if output != "" {
+		if output, err = filepath.Abs(output); err != nil {
+			return invalidInput(err)
+		}
+	}
+	job, err := queue.Submit(worker.Job{SourcePath: source, SetupFile: workerSetup, OutputPath: output})
+	if err != nil {
+		return err
+	}
+	fmt.Println(job.ID)
+	return nil
+}
+
+func runWorkerStatus() error {
+	if workerFormat != "text" && workerFormat != "json" {
+		return invalidInput(fmt.Errorf("invalid format: %s (supported: text, json)", workerFormat))
+	}
+	queue, err := worker.Open(workerQueue)
+	if err != nil {
+		return invalidInput(err)
+	}
+	jobs, err := queue.Jobs()
+	if err != nil {
+		return err
+	}
+
+	if workerFormat == "json" {
+		if jobs == nil {
+			jobs = []worker.Job{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(jobs)
+	}
+	if len(jobs) == 0 {
+		fmt.Println("No jobs found")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tWORKER\tSETUP\tSOURCE\tRESULT")
+	for _, job := range jobs {
+		result := job.Error
+		if job.Package != "" {
+			result = filepath.Base(job.Package)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", job.ID, job.Status, valueOrDash(job.Worker), job.SetupFile, job.SourcePath, valueOrDash(result))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,293 @@
+// Package azblob is a minimal Azure Blob Storage client for block blobs addressed by SAS URLs
+// or authorized with a managed identity
+package azblob
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/graph"
+)
+
+const (
+	// APIVersion is the Blob service REST API version sent with every request
+	APIVersion = "2021-08-06"
+	// DefaultBlockSize is the size of the blocks large files are uploaded in
+	DefaultBlockSize = 8 << 20
+)
+
+// httpOptions are the retries and timeouts of Blob service requests; an attempt may
+// upload a whole block, so it gets longer than a Graph request
+var httpOptions = graph.HTTPOptions{
+	Timeout:    10 * time.Minute,
+	MaxRetries: graph.DefaultHTTPOptions.MaxRetries,
+	MaxDelay:   graph.DefaultHTTPOptions.MaxDelay,
+}
+
+var (
+	// ErrNotFound is returned when a blob does not exist
+	ErrNotFound = errors.New("blob not found")
+	// ErrConditionFailed is returned when a conditional write lost a race with another writer
+	ErrConditionFailed = errors.New("blob was changed by another writer")
+)
+
+// Client sends Blob service requests; SAS URLs carry the authorization, unless the
+// client has a token source
+type Client struct {
+	httpClient *http.Client
+	blockSize  int
+	tokens     TokenSource
+}
+
+// NewClient creates a client with the default block size that sends its requests
+// through transport (nil: http.DefaultTransport)
+func NewClient(transport http.RoundTripper) *Client {
+	opts := httpOptions
+	opts.Transport = transport
+	return &Client{
+		httpClient: graph.NewHTTPClient(opts),
+		blockSize:  DefaultBlockSize,
+	}
+}
+
+// NewTokenClient creates a client that authorizes requests with bearer tokens, such as
+// those of a managed identity, instead of SAS tokens
+func NewTokenClient(tokens TokenSource, transport http.RoundTripper) *Client {
+	c := NewClient(transport)
+	c.tokens = tokens
+	return c
+}
+
+// BlobURL returns the URL of a blob inside a container (or virtual directory) URL,
+// keeping the SAS query string of the container URL
+func BlobURL(containerURL, name string) (string, error) {
+	u, err := url.Parse(containerURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid blob container URL: %w", err)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + strings.TrimPrefix(name, "/")
+	u.RawPath = ""
+	return u.String(), nil
+}
+
+// Get downloads a blob and returns its content and ETag
+func (c *Client) Get(ctx context.Context, blobURL string) ([]byte, string, error) {
+	resp, err := c.send(ctx, http.MethodGet, blobURL, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, "", ErrNotFound
+	default:
+		return nil, "", storageError("download", resp)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download blob: %w", err)
+	}
+	return data, resp.Header.Get("ETag"), nil
+}
+
+// Put uploads a small blob in one request, only if it still has the ETag ifMatch
+// An empty ifMatch requires that the blob does not exist yet; ErrConditionFailed
+// is returned when the precondition does not hold
+func (c *Client) Put(ctx context.Context, blobURL string, data []byte, contentType, ifMatch string) error {
+	header := http.Header{}
+	header.Set("x-ms-blob-type", "BlockBlob")
+	header.Set("Content-Type", contentType)
+	if ifMatch == "" {
+		header.Set("If-None-Match", "*")
+	} else {
+		header.Set("If-Match", ifMatch)
+	}
+
+	resp, err := c.send(ctx, http.MethodPut, blobURL, data, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return nil
+	case http.StatusPreconditionFailed, http.StatusConflict:
+		return ErrConditionFailed
+	default:
+		return storageError("upload", resp)
+	}
+}
+
+// UploadFile uploads a local file as a block blob, block by block, so files of any
+// size are sent without holding them in memory; the blob is replaced when it exists
+func (c *Client) UploadFile(ctx context.Context, blobURL, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var blockIDs []string
+	buf := make([]byte, c.blockSize)
+	for {
+		n, readErr := io.ReadFull(file, buf)
+		if n > 0 {
+			// Block IDs must all have the same length
+			id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("block-%08d", len(blockIDs))))
+			if err := c.putBlock(ctx, blobURL, id, buf[:n]); err != nil {
+				return err
+			}
+			blockIDs = append(blockIDs, id)
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return fmt.Errorf("failed to read %s: %w", path, readErr)
+		}
+	}
+	return c.putBlockList(ctx, blobURL, blockIDs)
+}
+
+// putBlock stages one block of a block blob
+func (c *Client) putBlock(ctx context.Context, blobURL, id string, data []byte) error {
+	target, err := withQuery(blobURL, "comp=block&blockid="+url.QueryEscape(id))
+	if err != nil {
+		return err
+	}
+	resp, err := c.send(ctx, http.MethodPut, target, data, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return storageError("upload block", resp)
+	}
+	return nil
+}
+
+// blockList is the body of a Put Block List request
+type blockList struct {
+	XMLName xml.Name `xml:"BlockList"`
+	Latest  []string `xml:"Latest"`
+}
+
+// putBlockList commits the staged blocks as the blob content
+func (c *Client) putBlockList(ctx context.Context, blobURL string, ids []string) error {
+	body, err := xml.Marshal(blockList{Latest: ids})
+	if err != nil {
+		return fmt.Errorf("failed to encode block list: %w", err)
+	}
+	target, err := withQuery(blobURL, "comp=blocklist")
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/xml")
+	header.Set("x-ms-blob-content-type", "application/octet-stream")
+
+	resp, err := c.send(ctx, http.MethodPut, target, append([]byte(xml.Header), body...), header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return storageError("commit blocks", resp)
+	}
+	return nil
+}
+
+// Delete removes a blob; deleting a missing blob is not an error
+func (c *Client) Delete(ctx context.Context, blobURL string) error {
+	resp, err := c.send(ctx, http.MethodDelete, blobURL, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusAccepted, http.StatusOK, http.StatusNotFound:
+		return nil
+	default:
+		return storageError("delete", resp)
+	}
+}
+
+// send performs a request with the service version header set
+func (c *Client) send(ctx context.Context, method, target string, body []byte, header http.Header) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("x-ms-version", APIVersion)
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	if c.tokens != nil {
+		token, err := c.tokens.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, redact(target), err)
+	}
+	return resp, nil
+}
+
+// withQuery appends query parameters to a URL that may already carry a SAS token
+func withQuery(target, query string) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("invalid blob URL: %w", err)
+	}
+	if u.RawQuery != "" {
+		u.RawQuery += "&"
+	}
+	u.RawQuery += query
+	return u.String(), nil
+}
+
+// redact drops the query string, which holds the SAS signature, from URLs written to logs
+func redact(target string) string {
+	if i := strings.IndexByte(target, '?'); i >= 0 {
+		return target[:i]
+	}
+	return target
+}
+
+// storageError converts an unexpected Blob service response into an error
+func storageError(action string, resp *http.Response) error {
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	data, _ := io.ReadAll(resp.Body)
+	if xml.Unmarshal(data, &body) == nil && body.Code != "" {
+		return fmt.Errorf("failed to %s blob (%d %s): %s", action, resp.StatusCode, body.Code, strings.TrimSpace(body.Message))
+	}
+	return fmt.Errorf("failed to %s blob: storage returned %s", action, resp.Status)
+}
@@ -0,0 +1,182 @@
+package azblob
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// fakeStorage emulates the block blob operations of the Blob service
+type fakeStorage struct {
+	mu      sync.Mutex
+	blobs   map[string][]byte
+	etags   map[string]int
+	blocks  map[string][]byte
+	queries []string
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{blobs: map[string][]byte{}, etags: map[string]int{}, blocks: map[string][]byte{}}
+}
+
+func (f *fakeStorage) etag(path string) string {
+	return fmt.Sprintf(`"0x%d"`, f.etags[path])
+}
+
+func (f *fakeStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("x-ms-version") != APIVersion {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("sig") != "secret" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	f.queries = append(f.queries, r.URL.Query().Get("comp"))
+
+	path := r.URL.Path
+	body, _ := io.ReadAll(r.Body)
+	_, exists := f.blobs[path]
+
+	switch {
+	case r.Method == http.MethodGet:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", f.etag(path))
+		w.Write(f.blobs[path])
+
+	case r.Method == http.MethodPut && r.URL.Query().Get("comp") == "block":
+		f.blocks[path+"#"+r.URL.Query().Get("blockid")] = body
+		w.WriteHeader(http.StatusCreated)
+
+	case r.Method == http.MethodPut && r.URL.Query().Get("comp") == "blocklist":
+		var list blockList
+		if err := xml.Unmarshal(body, &list); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var content []byte
+		for _, id := range list.Latest {
+			content = append(content, f.blocks[path+"#"+id]...)
+		}
+		f.blobs[path] = content
+		f.etags[path]++
+		w.WriteHeader(http.StatusCreated)
+
+	case r.Method == http.MethodPut:
+		if r.Header.Get("If-None-Match") == "*" && exists {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		if match := r.Header.Get("If-Match"); match != "" && (!exists || match != f.etag(path)) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		f.blobs[path] = body
+		f.etags[path]++
+		w.WriteHeader(http.StatusCreated)
+
+	case r.Method == http.MethodDelete:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.blobs, path)
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func TestBlobURL(t *testing.T) {
+	got, err := BlobURL("https://account.blob.core.windows.net/catalog/prod/?sv=2021&sig=abc", "packages/7z.intunewin")
+	if err != nil {
+		t.Fatalf("BlobURL() error = %v", err)
+	}
+	want := "https://account.blob.core.windows.net/catalog/prod/packages/7z.intunewin?sv=2021&sig=abc"
+	if got != want {
+		t.Errorf("BlobURL() = %s, want %s", got, want)
+	}
+}
+
+func TestConditionalPut(t *testing.T) {
+	storage := newFakeStorage()
+	server := httptest.NewServer(storage)
+	defer server.Close()
+
+	client := NewClient(nil)
+	ctx := context.Background()
+	blobURL := server.URL + "/catalog/index.json?sig=secret"
+
+	if _, _, err := client.Get(ctx, blobURL); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() of missing blob error = %v, want ErrNotFound", err)
+	}
+	if err := client.Put(ctx, blobURL, []byte("v1"), "application/json", ""); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := client.Put(ctx, blobURL, []byte("v1 again"), "application/json", ""); !errors.Is(err, ErrConditionFailed) {
+		t.Fatalf("Put() of existing blob without ETag error = %v, want ErrConditionFailed", err)
+	}
+
+	data, etag, err := client.Get(ctx, blobURL)
+	if err != nil || string(data) != "v1" {
+		t.Fatalf("Get() = %q, %v", data, err)
+	}
+	if err := client.Put(ctx, blobURL, []byte("v2"), "application/json", etag); err != nil {
+		t.Fatalf("Put() with current ETag error = %v", err)
+	}
+	if err := client.Put(ctx, blobURL, []byte("v3"), "application/json", etag); !errors.Is(err, ErrConditionFailed) {
+		t.Fatalf("Put() with stale ETag error = %v, want ErrConditionFailed", err)
+	}
+}
+
+func TestUploadFileInBlocks(t *testing.T) {
+	storage := newFakeStorage()
+	server := httptest.NewServer(storage)
+	defer server.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.intunewin")
+	content := []byte("0123456789abcdefghij") // Five blocks of four bytes
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	client := NewClient(nil)
+	client.blockSize = 4
+	blobURL := server.URL + "/catalog/packages/app.intunewin?sig=secret"
+	if err := client.UploadFile(context.Background(), blobURL, path); err != nil {
+		t.Fatalf("UploadFile() error = %v", err)
+	}
+
+	if got := string(storage.blobs["/catalog/packages/app.intunewin"]); got != string(content) {
+		t.Errorf("Uploaded content = %q, want %q", got, content)
+	}
+	blocks := 0
+	for _, comp := range storage.queries {
+		if comp == "block" {
+			blocks++
+		}
+	}
+	if blocks != 5 {
+		t.Errorf("Expected 5 blocks, got %d", blocks)
+	}
+
+	if err := client.Delete(context.Background(), blobURL); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := client.Delete(context.Background(), blobURL); err != nil {
+		t.Fatalf("Delete() of missing blob error = %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package azblob
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// storageResource is the resource tokens for the Blob service are requested for
+	storageResource = "https://storage.azure.com/"
+	// imdsEndpoint is the managed identity endpoint of Azure VMs and scale sets
+	imdsEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+	// tokenRefreshMargin renews tokens this long before they expire
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+// TokenSource returns bearer tokens for requests to URLs without a SAS token
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// ManagedIdentity gets tokens of the Azure managed identity of the machine: from the
+// instance metadata service on VMs, or from IDENTITY_ENDPOINT on App Service, Functions,
+// Container Apps and Arc-enabled servers. Tokens are cached until shortly before they expire
+type ManagedIdentity struct {
+	// ClientID selects a user-assigned identity; empty uses the system-assigned identity
+	ClientID string
+	// Resource is the service tokens are requested for (storage when empty)
+	Resource string
+
+	// endpoint and header override the environment (set in tests)
+	endpoint string
+	header   string
+
+	httpClient *http.Client
+	mu         sync.Mutex
+	token      string
+	expires    time.Time
+}
+
+// NewManagedIdentity creates a token source for the managed identity of the machine that
+// requests tokens through transport (nil: http.DefaultTransport)
+func NewManagedIdentity(clientID string, transport http.RoundTripper) *ManagedIdentity {
+	m := &ManagedIdentity{
+		ClientID:   clientID,
+		endpoint:   imdsEndpoint,
+		httpClient: &http.Client{Timeout: 30 * time.Second, Transport: transport},
+	}
+	if endpoint, header := os.Getenv("IDENTITY_ENDPOINT"), os.Getenv("IDENTITY_HEADER"); endpoint != "" && header != "" {
+		m.endpoint, m.header = endpoint, header
+	}
+	return m
+}
+
+// Token returns a cached token, or requests a new one when it is about to expire
+func (m *ManagedIdentity) Token(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.token != "" && time.Until(m.expires) > tokenRefreshMargin {
+		return m.token, nil
+	}
+
+	resource := m.Resource
+	if resource == "" {
+		resource = storageResource
+	}
+	query := url.Values{"resource": {resource}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	if m.header != "" {
+		query.Set("api-version", "2019-08-01")
+		req.Header.Set("X-IDENTITY-HEADER", m.header)
+	} else {
+		query.Set("api-version", "2018-02-01")
+		req.Header.Set("Metadata", "true")
+	}
+	if m.ClientID != "" {
+		query.Set("client_id", m.ClientID)
+	}
+	req.URL.RawQuery = query.Encode()
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get managed identity token (is this an Azure machine with a managed identity?): %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		AccessToken string          `json:"access_token"`
+		ExpiresOn   json.RawMessage `json:"expires_on"`
+		Error       string          `json:"error"`
+		Description string          `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to get managed identity token: identity endpoint returned %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK || body.AccessToken == "" {
+		return "", fmt.Errorf("failed to get managed identity token: %s %s", body.Error, body.Description)
+	}
+
+	// expires_on is Unix seconds, sent as a string by some endpoints and a number by others
+	seconds, err := strconv.ParseInt(strings.Trim(string(body.ExpiresOn), `"`), 10, 64)
+	if err != nil {
+		seconds = time.Now().Add(tokenRefreshMargin).Unix()
+	}
+	m.token, m.expires = body.AccessToken, time.Unix(seconds, 0)
+	return m.token, nil
+}
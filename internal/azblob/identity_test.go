@@ -0,0 +1,57 @@
+package azblob
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestManagedIdentityToken(t *testing.T) {
+	requests := 0
+	identity := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Metadata") != "true" || r.URL.Query().Get("resource") != storageResource {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("client_id") != "user-assigned" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_request","error_description":"Identity not found"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_on":"%d"}`, requests, time.Now().Add(time.Hour).Unix())
+	}))
+	defer identity.Close()
+
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-1" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("ETag", `"0x1"`)
+		w.Write([]byte("index"))
+	}))
+	defer storage.Close()
+
+	tokens := NewManagedIdentity("user-assigned", nil)
+	tokens.endpoint, tokens.header = identity.URL, ""
+	client := NewTokenClient(tokens, nil)
+	for i := 0; i < 2; i++ {
+		data, _, err := client.Get(context.Background(), storage.URL+"/catalog/index.json")
+		if err != nil || string(data) != "index" {
+			t.Fatalf("Get() = %q, %v", data, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Identity endpoint called %d times, want the token to be cached", requests)
+	}
+
+	other := NewManagedIdentity("missing", nil)
+	other.endpoint, other.header = identity.URL, ""
+	if _, err := other.Token(context.Background()); err == nil {
+		t.Error("Expected an error for an unknown identity")
+	}
+}
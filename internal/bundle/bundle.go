@@ -0,0 +1,349 @@
+// Package bundle writes and reads upload bundles: the encrypted content, Detection.xml and
+// app metadata of a package in one archive, so a package built on an isolated network can
+// be created and uploaded in Intune from a connected machine
+// Packages themselves open as bundles without app metadata, to upload their content
+package bundle
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/graph"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// FormatVersion is the version of the bundle layout; bundles of newer versions are refused
+const FormatVersion = 1
+
+// Extension is the file extension of upload bundles
+const Extension = ".uploadbundle"
+
+// Entries of a bundle archive
+const (
+	manifestEntry  = "bundle.json"
+	appEntry       = "app.json"
+	detectionEntry = "Detection.xml"
+	contentEntry   = "IntunePackage.intunewin"
+)
+
+// Manifest describes the content of a bundle and how to upload it
+type Manifest struct {
+	FormatVersion int       `json:"formatVersion"`
+	Created       time.Time `json:"created"`
+	ToolVersion   string    `json:"toolVersion,omitempty"`
+	// Package is the file name of the .intunewin the bundle was exported from
+	Package string `json:"package"`
+	// Content is the encrypted content file to upload
+	Content Content `json:"content"`
+	// OnConflict is the conflict policy when an app with the same name exists
+	OnConflict string `json:"onConflict,omitempty"`
+	// Relationships and Assignments are applied after the upload; targets and groups are
+	// names or IDs resolved in the tenant the bundle is uploaded to
+	Relationships []graph.Relationship `json:"relationships,omitempty"`
+	Assignments   []graph.Assignment   `json:"assignments,omitempty"`
+}
+
+// Content describes the encrypted content of a bundle
+type Content struct {
+	Name string `json:"name"`
+	// Size is the size of the content before encryption, from Detection.xml
+	Size int64 `json:"size"`
+	// SizeEncrypted is the size of the encrypted content
+	SizeEncrypted int64 `json:"sizeEncrypted"`
+	// SHA256 is the hex digest of the encrypted content, checked before uploading
+	SHA256 string `json:"sha256"`
+}
+
+// Write writes a bundle of a .intunewin package and the app to create from it to path
+// The manifest's format version, creation time, package and content are filled in
+func Write(path, packagePath string, app graph.Win32App, manifest Manifest) (*Manifest, error) {
+	pkg, err := zip.OpenReader(packagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open package: %w", err)
+	}
+	defer pkg.Close()
+
+	detectionFile, contentFile := packager.FindPackageEntry(&pkg.Reader, packager.DetectionXMLPath), packager.FindPackageEntry(&pkg.Reader, packager.EncryptedContentPath)
+	if detectionFile == nil || contentFile == nil {
+		return nil, fmt.Errorf("%s is not a Win32 .intunewin package", packagePath)
+	}
+	detection, err := readEntry(detectionFile)
+	if err != nil {
+		return nil, err
+	}
+	appInfo, err := packager.ParseDetectionXML(detection)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest.FormatVersion = FormatVersion
+	manifest.Created = time.Now().UTC().Truncate(time.Second)
+	manifest.Package = filepath.Base(packagePath)
+	manifest.Content = Content{Name: contentEntry, Size: appInfo.UnencryptedContentSize}
+
+	appJSON, err := json.MarshalIndent(app, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode app: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bundle: %w", err)
+	}
+	defer os.Remove(tmp)
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+	if err := writeEntry(zw, appEntry, zip.Deflate, appJSON); err != nil {
+		return nil, err
+	}
+	if err := writeEntry(zw, detectionEntry, zip.Deflate, detection); err != nil {
+		return nil, err
+	}
+	// The content is encrypted and does not compress
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: contentEntry, Method: zip.Store, Modified: manifest.Created})
+	if err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", contentEntry, err)
+	}
+	rc, err := contentFile.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open encrypted content: %w", err)
+	}
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, hash), rc)
+	rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", contentEntry, err)
+	}
+	manifest.Content.SizeEncrypted = n
+	manifest.Content.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode bundle manifest: %w", err)
+	}
+	if err := writeEntry(zw, manifestEntry, zip.Deflate, manifestJSON); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return &manifest, nil
+}
+
+// Bundle is an opened upload bundle
+type Bundle struct {
+	Manifest Manifest
+	// App is the app to create
+	App graph.Win32App
+	// DetectionXML is the Detection.xml of the package, holding its encryption keys
+	DetectionXML []byte
+
+	zip     *zip.ReadCloser
+	content *zip.File
+}
+
+// Open opens an upload bundle and reads its manifest and app
+func Open(path string) (*Bundle, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	b, err := open(zr)
+	if err != nil {
+		zr.Close()
+		return nil, fmt.Errorf("invalid bundle %s: %w", path, err)
+	}
+	return b, nil
+}
+
+// OpenPackage opens a .intunewin package as a bundle without app metadata, to upload its
+// content to an existing app
+// Packages carry no digest of their content, so Verify does not apply
+func OpenPackage(path string) (*Bundle, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open package: %w", err)
+	}
+	b, err := openPackage(zr, path)
+	if err != nil {
+		zr.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// openPackage reads the Detection.xml and finds the encrypted content of a package
+func openPackage(zr *zip.ReadCloser, path string) (*Bundle, error) {
+	detectionFile, contentFile := packager.FindPackageEntry(&zr.Reader, packager.DetectionXMLPath), packager.FindPackageEntry(&zr.Reader, packager.EncryptedContentPath)
+	if detectionFile == nil || contentFile == nil {
+		return nil, fmt.Errorf("%s is not a Win32 .intunewin package", path)
+	}
+	detection, err := readEntry(detectionFile)
+	if err != nil {
+		return nil, err
+	}
+	appInfo, err := packager.ParseDetectionXML(detection)
+	if err != nil {
+		return nil, err
+	}
+	return &Bundle{
+		Manifest: Manifest{
+			FormatVersion: FormatVersion,
+			Package:       filepath.Base(path),
+			Content: Content{
+				Name:          contentEntry,
+				Size:          appInfo.UnencryptedContentSize,
+				SizeEncrypted: int64(contentFile.UncompressedSize64),
+			},
+		},
+		DetectionXML: detection,
+		zip:          zr,
+		content:      contentFile,
+	}, nil
+}
+
+// open reads the entries of a bundle archive
+func open(zr *zip.ReadCloser) (*Bundle, error) {
+	b := &Bundle{zip: zr}
+	for _, entry := range []string{manifestEntry, appEntry, detectionEntry, contentEntry} {
+		if findEntry(&zr.Reader, entry) == nil {
+			return nil, fmt.Errorf("%s is missing", entry)
+		}
+	}
+
+	data, err := readEntry(findEntry(&zr.Reader, manifestEntry))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &b.Manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", manifestEntry, err)
+	}
+	if b.Manifest.FormatVersion < 1 || b.Manifest.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("unsupported format version %d (this version reads up to %d)", b.Manifest.FormatVersion, FormatVersion)
+	}
+
+	if data, err = readEntry(findEntry(&zr.Reader, appEntry)); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &b.App); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", appEntry, err)
+	}
+	if b.DetectionXML, err = readEntry(findEntry(&zr.Reader, detectionEntry)); err != nil {
+		return nil, err
+	}
+	b.content = findEntry(&zr.Reader, contentEntry)
+	return b, nil
+}
+
+// Verify checks the size and SHA256 of the encrypted content against the manifest
+func (b *Bundle) Verify() error {
+	rc, err := b.Content()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(hash, rc)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", contentEntry, err)
+	}
+	if n != b.Manifest.Content.SizeEncrypted {
+		return fmt.Errorf("%s has %d bytes, the manifest lists %d", contentEntry, n, b.Manifest.Content.SizeEncrypted)
+	}
+	if digest := hex.EncodeToString(hash.Sum(nil)); digest != b.Manifest.Content.SHA256 {
+		return fmt.Errorf("%s is corrupt: SHA256 %s, the manifest lists %s", contentEntry, digest, b.Manifest.Content.SHA256)
+	}
+	return nil
+}
+
+// Content opens the encrypted content of the bundle
+func (b *Bundle) Content() (io.ReadCloser, error) {
+	rc, err := b.content.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", contentEntry, err)
+	}
+	return rc, nil
+}
+
+// Upload returns the content upload of the bundle, reading the encryption of the content
+// from its Detection.xml; content is the reader returned by Content
+func (b *Bundle) Upload(content io.Reader) (graph.ContentUpload, error) {
+	appInfo, err := packager.ParseDetectionXML(b.DetectionXML)
+	if err != nil {
+		return graph.ContentUpload{}, err
+	}
+	enc := appInfo.EncryptionInfo
+	return graph.ContentUpload{
+		Name:          b.Manifest.Content.Name,
+		Size:          b.Manifest.Content.Size,
+		SizeEncrypted: b.Manifest.Content.SizeEncrypted,
+		Content:       content,
+		Manifest:      b.DetectionXML,
+		EncryptionInfo: graph.FileEncryptionInfo{
+			EncryptionKey:        enc.EncryptionKey,
+			MacKey:               enc.MacKey,
+			InitializationVector: enc.InitializationVector,
+			Mac:                  enc.Mac,
+			ProfileIdentifier:    enc.ProfileIdentifier,
+			FileDigest:           enc.FileDigest,
+			FileDigestAlgorithm:  enc.FileDigestAlgorithm,
+		},
+	}, nil
+}
+
+// Close closes the bundle archive
+func (b *Bundle) Close() error {
+	return b.zip.Close()
+}
+
+// findEntry returns the entry of an archive with the given name, nil if it has none
+func findEntry(zr *zip.Reader, name string) *zip.File {
+	for _, f := range zr.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// readEntry reads an archive entry
+func readEntry(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	return data, nil
+}
+
+// writeEntry writes an archive entry
+func writeEntry(zw *zip.Writer, name string, method uint16, data []byte) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: time.Now().UTC()})
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package bundle
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/graph"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/graphtest"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// writePackage packages a setup file and returns the path of the .intunewin
+func writePackage(t *testing.T) string {
+	t.Helper()
+	source := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "setup.exe"), []byte("7-Zip 24.01 setup"), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+	opts := packager.Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	result, err := packager.PackageWithOptions(source, "setup.exe", t.TempDir(), opts, nil)
+	if err != nil {
+		t.Fatalf("Failed to create package: %v", err)
+	}
+	return result.OutputPath
+}
+
+// testApp is the app exported with test bundles
+func testApp() graph.Win32App {
+	return graph.Win32App{
+		DisplayName:          "7-Zip",
+		DisplayVersion:       "24.01",
+		Publisher:            "Igor Pavlov",
+		FileName:             "setup.intunewin",
+		SetupFile:            "setup.exe",
+		InstallCommandLine:   "setup.exe /S",
+		UninstallCommandLine: `"%ProgramFiles%\7-Zip\Uninstall.exe" /S`,
+		Architectures:        "x64",
+		DetectionFile:        `%ProgramFiles%\7-Zip\7z.exe`,
+	}
+}
+
+func TestWriteOpen(t *testing.T) {
+	packagePath := writePackage(t)
+	path := filepath.Join(t.TempDir(), "7zip"+Extension)
+	manifest := Manifest{
+		OnConflict:    "skip",
+		Relationships: []graph.Relationship{{TargetID: "7-Zip 23.01", Supersedence: graph.SupersedenceUpdate}},
+		Assignments:   []graph.Assignment{{GroupID: "All Workstations", Intent: "required"}},
+	}
+	written, err := Write(path, packagePath, testApp(), manifest)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if written.Content.SizeEncrypted == 0 || len(written.Content.SHA256) != 64 || written.Content.Size == 0 {
+		t.Errorf("Write() content = %+v, want sizes and SHA256", written.Content)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Error("Write() left its temporary file")
+	}
+
+	b, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer b.Close()
+	if err := b.Verify(); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	if b.App.DisplayName != "7-Zip" || b.App.DetectionFile != `%ProgramFiles%\7-Zip\7z.exe` {
+		t.Errorf("App = %+v, want the exported app", b.App)
+	}
+	if b.Manifest.Content != written.Content || b.Manifest.Package != filepath.Base(packagePath) || b.Manifest.OnConflict != "skip" {
+		t.Errorf("Manifest = %+v, want %+v", b.Manifest, *written)
+	}
+	if len(b.Manifest.Relationships) != 1 || b.Manifest.Relationships[0].TargetID != "7-Zip 23.01" {
+		t.Errorf("Relationships = %+v", b.Manifest.Relationships)
+	}
+	if len(b.Manifest.Assignments) != 1 || b.Manifest.Assignments[0].GroupID != "All Workstations" {
+		t.Errorf("Assignments = %+v", b.Manifest.Assignments)
+	}
+
+	rc, err := b.Content()
+	if err != nil {
+		t.Fatalf("Content() error = %v", err)
+	}
+	defer rc.Close()
+	upload, err := b.Upload(rc)
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	enc := upload.EncryptionInfo
+	if enc.EncryptionKey == "" || enc.MacKey == "" || enc.InitializationVector == "" || enc.Mac == "" || enc.FileDigest == "" {
+		t.Errorf("Upload() encryption = %+v, want the keys of Detection.xml", enc)
+	}
+	if upload.SizeEncrypted != written.Content.SizeEncrypted || !strings.Contains(string(upload.Manifest), "ApplicationInfo") {
+		t.Errorf("Upload() = %+v, want the content and Detection.xml of the bundle", upload)
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	packagePath := writePackage(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "7zip"+Extension)
+	if _, err := Write(path, packagePath, testApp(), Manifest{}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	// rewrite copies the bundle, replacing or dropping entries
+	rewrite := func(name string, replace map[string]string) string {
+		t.Helper()
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			t.Fatalf("Failed to open bundle: %v", err)
+		}
+		defer zr.Close()
+		target := filepath.Join(dir, name)
+		out, err := os.Create(target)
+		if err != nil {
+			t.Fatalf("Failed to create bundle: %v", err)
+		}
+		defer out.Close()
+		zw := zip.NewWriter(out)
+		for _, f := range zr.File {
+			data, err := readEntry(f)
+			if err != nil {
+				t.Fatalf("Failed to read %s: %v", f.Name, err)
+			}
+			if value, ok := replace[f.Name]; ok {
+				if value == "" {
+					continue
+				}
+				data = []byte(value)
+			}
+			if err := writeEntry(zw, f.Name, zip.Store, data); err != nil {
+				t.Fatalf("Failed to write %s: %v", f.Name, err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatalf("Failed to write bundle: %v", err)
+		}
+		return target
+	}
+
+	tests := map[string]map[string]string{
+		"missing content": {contentEntry: ""},
+		"missing app":     {appEntry: ""},
+		"newer format":    {manifestEntry: `{"formatVersion": 99}`},
+		"invalid app":     {appEntry: "{"},
+	}
+	for name, replace := range tests {
+		if b, err := Open(rewrite(name+Extension, replace)); err == nil {
+			b.Close()
+			t.Errorf("%s: expected Open() error", name)
+		}
+	}
+
+	b, err := Open(rewrite("corrupt"+Extension, map[string]string{contentEntry: "tampered content"}))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer b.Close()
+	if err := b.Verify(); err == nil {
+		t.Error("Expected Verify() error for modified content")
+	}
+
+	if _, err := Write(filepath.Join(dir, "not-a-package"+Extension), path, testApp(), Manifest{}); err == nil {
+		t.Error("Expected Write() error for a file that is not a .intunewin")
+	}
+}
+
+func TestUploadBundle(t *testing.T) {
+	mock := graphtest.New()
+	server := httptest.NewServer(mock.Handler())
+	defer server.Close()
+	client := graph.NewClient(graph.Credentials{TenantID: "contoso", ClientID: "client", ClientSecret: "secret"})
+	client.SetServer(server.URL)
+
+	path := filepath.Join(t.TempDir(), "7zip"+Extension)
+	if _, err := Write(path, writePackage(t), testApp(), Manifest{}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	b, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer b.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	created, err := client.CreateWin32App(ctx, b.App, graph.ConflictFail)
+	if err != nil {
+		t.Fatalf("CreateWin32App() error = %v", err)
+	}
+	rc, err := b.Content()
+	if err != nil {
+		t.Fatalf("Content() error = %v", err)
+	}
+	defer rc.Close()
+	upload, err := b.Upload(rc)
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if err := client.UploadContent(ctx, created.App.ID, upload); err != nil {
+		t.Fatalf("UploadContent() error = %v", err)
+	}
+
+	committed, err := client.CommittedContentFile(ctx, created.App.ID)
+	if err != nil {
+		t.Fatalf("CommittedContentFile() error = %v", err)
+	}
+	if committed.SizeEncrypted != b.Manifest.Content.SizeEncrypted {
+		t.Errorf("Committed file has %d bytes, want %d", committed.SizeEncrypted, b.Manifest.Content.SizeEncrypted)
+	}
+}
+
+func TestOpenPackage(t *testing.T) {
+	packagePath := writePackage(t)
+	b, err := OpenPackage(packagePath)
+	if err != nil {
+		t.Fatalf("OpenPackage() error = %v", err)
+	}
+	defer b.Close()
+	if b.Manifest.Package != filepath.Base(packagePath) || b.Manifest.Content.Size == 0 || b.Manifest.Content.SizeEncrypted == 0 {
+		t.Errorf("Manifest = %+v, want the package and its content sizes", b.Manifest)
+	}
+
+	content, err := b.Content()
+	if err != nil {
+		t.Fatalf("Content() error = %v", err)
+	}
+	defer content.Close()
+	upload, err := b.Upload(content)
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	data, err := io.ReadAll(upload.Content)
+	if err != nil {
+		t.Fatalf("Failed to read content: %v", err)
+	}
+	if int64(len(data)) != upload.SizeEncrypted || upload.EncryptionInfo.EncryptionKey == "" || len(upload.Manifest) == 0 {
+		t.Errorf("Upload() = %d bytes of %d, key %q, want the encrypted content and its keys", len(data), upload.SizeEncrypted, upload.EncryptionInfo.EncryptionKey)
+	}
+
+	notPackage := filepath.Join(t.TempDir(), "other.zip")
+	out, _ := os.Create(notPackage)
+	zip.NewWriter(out).Close()
+	out.Close()
+	if _, err := OpenPackage(notPackage); err == nil || !strings.Contains(err.Error(), "not a Win32 .intunewin package") {
+		t.Errorf("OpenPackage() error = %v, want not a package", err)
+	}
+}
@@ -0,0 +1,268 @@
+// Package capture repackages an installer by snapshotting the file system and registry
+// before and after it runs: the difference becomes a source folder with the new files,
+// a .reg file of the new registry values and generated install and uninstall scripts
+package capture
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Folder is a folder whose files are captured, with where they are installed again
+type Folder struct {
+	// Name identifies the folder in the captured source, e.g. ProgramFiles
+	Name string
+	// Path is the folder on the capturing machine
+	Path string
+	// Target is the PowerShell expression of the folder on the installing machine,
+	// e.g. $env:ProgramFiles
+	Target string
+}
+
+// Options selects what is captured
+type Options struct {
+	// Folders are scanned for files
+	Folders []Folder
+	// RegistryKeys are the registry keys scanned for values, e.g. HKLM\SOFTWARE
+	RegistryKeys []string
+	// Exclude are path and registry key prefixes left out, case-insensitively, such as
+	// caches the system changes on its own
+	Exclude []string
+	// ExcludeNames are glob patterns of file names left out, e.g. *.log
+	ExcludeNames []string
+}
+
+// DefaultOptions captures the machine-wide install locations, Program Files, ProgramData
+// and the public desktop, and HKLM\SOFTWARE and services, leaving out what Windows changes
+// on its own while an installer runs
+func DefaultOptions() Options {
+	var opts Options
+	for _, folder := range []struct{ name, env, sub, target string }{
+		{"ProgramFiles", "ProgramFiles", "", "$env:ProgramFiles"},
+		{"ProgramFilesX86", "ProgramFiles(x86)", "", "${env:ProgramFiles(x86)}"},
+		{"ProgramData", "ProgramData", "", "$env:ProgramData"},
+		{"PublicDesktop", "PUBLIC", "Desktop", "(Join-Path $env:PUBLIC 'Desktop')"},
+	} {
+		if dir := os.Getenv(folder.env); dir != "" {
+			opts.Folders = append(opts.Folders, Folder{Name: folder.name, Path: filepath.Join(dir, folder.sub), Target: folder.target})
+		}
+	}
+	opts.RegistryKeys = []string{`HKLM\SOFTWARE`, `HKLM\SYSTEM\CurrentControlSet\Services`}
+	if dir := os.Getenv("ProgramData"); dir != "" {
+		for _, noise := range []string{`Microsoft\Windows Defender`, `Microsoft\Windows\WER`, `Microsoft\Diagnosis`, `Microsoft\Search`, `Microsoft\Network`, `Package Cache`} {
+			opts.Exclude = append(opts.Exclude, filepath.Join(dir, noise))
+		}
+	}
+	opts.Exclude = append(opts.Exclude,
+		`HKLM\SOFTWARE\Microsoft\Cryptography\RNG`,
+		`HKLM\SOFTWARE\Microsoft\Windows Defender`,
+		`HKLM\SOFTWARE\Microsoft\Windows NT\CurrentVersion\Prefetcher`,
+		`HKLM\SOFTWARE\Microsoft\Windows\CurrentVersion\WindowsUpdate`,
+		`HKLM\SYSTEM\CurrentControlSet\Services\bam`,
+		`HKLM\SYSTEM\CurrentControlSet\Services\Tcpip`,
+	)
+	opts.ExcludeNames = []string{"*.log", "*.tmp", "*.etl"}
+	return opts
+}
+
+// File is a file of a snapshot
+type File struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// RegistryValue is a registry value of a snapshot, with its data as stored by Windows
+type RegistryValue struct {
+	// Key is the full key, e.g. HKLM\SOFTWARE\Contoso\App
+	Key string `json:"key"`
+	// Name is the value name, empty for the default value
+	Name string `json:"name"`
+	// Type is the registry type, e.g. 1 for REG_SZ and 4 for REG_DWORD
+	Type uint32 `json:"type"`
+	Data []byte `json:"data"`
+}
+
+// Snapshot is the state of the captured folders and registry keys at one time
+// Maps are keyed by lowercase path, as Windows paths are case-insensitive
+type Snapshot struct {
+	Taken  time.Time
+	Files  map[string]File
+	Dirs   map[string]string
+	Keys   map[string]string
+	Values map[string]RegistryValue
+	// Skipped counts folders and keys that could not be read
+	Skipped int
+}
+
+// TakeSnapshot records the files and registry values selected by opts
+func TakeSnapshot(opts Options) (*Snapshot, error) {
+	s := &Snapshot{
+		Taken:  time.Now(),
+		Files:  map[string]File{},
+		Dirs:   map[string]string{},
+		Keys:   map[string]string{},
+		Values: map[string]RegistryValue{},
+	}
+	for _, folder := range opts.Folders {
+		if _, err := os.Stat(folder.Path); err != nil {
+			continue
+		}
+		err := filepath.WalkDir(folder.Path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				s.Skipped++
+				if d != nil && d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if excluded(path, opts.Exclude) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				s.Dirs[strings.ToLower(path)] = path
+				return nil
+			}
+			if !d.Type().IsRegular() || excludedName(d.Name(), opts.ExcludeNames) {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				s.Skipped++
+				return nil
+			}
+			s.Files[strings.ToLower(path)] = File{Path: path, Size: info.Size(), ModTime: info.ModTime()}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", folder.Path, err)
+		}
+	}
+
+	for _, key := range opts.RegistryKeys {
+		if err := readRegistry(key, opts.Exclude, s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Changes are the differences between two snapshots
+type Changes struct {
+	AddedFiles    []File          `json:"addedFiles,omitempty"`
+	ModifiedFiles []File          `json:"modifiedFiles,omitempty"`
+	DeletedFiles  []string        `json:"deletedFiles,omitempty"`
+	AddedDirs     []string        `json:"addedDirs,omitempty"`
+	AddedKeys     []string        `json:"addedKeys,omitempty"`
+	AddedValues   []RegistryValue `json:"addedValues,omitempty"`
+	ChangedValues []RegistryValue `json:"changedValues,omitempty"`
+	// DeletedValues are keys and values as KEY\name, or KEY for whole keys
+	DeletedValues []string `json:"deletedValues,omitempty"`
+}
+
+// Empty reports whether nothing changed
+func (c *Changes) Empty() bool {
+	return len(c.AddedFiles)+len(c.ModifiedFiles)+len(c.DeletedFiles)+len(c.AddedKeys)+
+		len(c.AddedValues)+len(c.ChangedValues)+len(c.DeletedValues) == 0
+}
+
+// Diff returns what changed from before to after, sorted by path
+// Files are modified when their size or modification time changed
+func Diff(before, after *Snapshot) *Changes {
+	c := &Changes{}
+	for _, id := range sortedKeys(after.Files) {
+		file := after.Files[id]
+		old, ok := before.Files[id]
+		switch {
+		case !ok:
+			c.AddedFiles = append(c.AddedFiles, file)
+		case old.Size != file.Size || !old.ModTime.Equal(file.ModTime):
+			c.ModifiedFiles = append(c.ModifiedFiles, file)
+		}
+	}
+	for _, id := range sortedKeys(before.Files) {
+		if _, ok := after.Files[id]; !ok {
+			c.DeletedFiles = append(c.DeletedFiles, before.Files[id].Path)
+		}
+	}
+	for _, id := range sortedKeys(after.Dirs) {
+		if _, ok := before.Dirs[id]; !ok {
+			c.AddedDirs = append(c.AddedDirs, after.Dirs[id])
+		}
+	}
+
+	for _, id := range sortedKeys(after.Keys) {
+		if _, ok := before.Keys[id]; !ok {
+			c.AddedKeys = append(c.AddedKeys, after.Keys[id])
+		}
+	}
+	for _, id := range sortedKeys(before.Keys) {
+		if _, ok := after.Keys[id]; !ok {
+			c.DeletedValues = append(c.DeletedValues, before.Keys[id])
+		}
+	}
+	for _, id := range sortedKeys(after.Values) {
+		value := after.Values[id]
+		old, ok := before.Values[id]
+		switch {
+		case !ok:
+			c.AddedValues = append(c.AddedValues, value)
+		case old.Type != value.Type || string(old.Data) != string(value.Data):
+			c.ChangedValues = append(c.ChangedValues, value)
+		}
+	}
+	for _, id := range sortedKeys(before.Values) {
+		if _, ok := after.Values[id]; !ok {
+			if _, keyKept := after.Keys[strings.ToLower(before.Values[id].Key)]; keyKept {
+				c.DeletedValues = append(c.DeletedValues, before.Values[id].Key+`\`+before.Values[id].Name)
+			}
+		}
+	}
+	return c
+}
+
+// valueID returns the snapshot key of a registry value
+func valueID(key, name string) string {
+	return strings.ToLower(key) + "\x00" + strings.ToLower(name)
+}
+
+// excluded reports whether a path or registry key starts with one of the prefixes
+func excluded(path string, prefixes []string) bool {
+	lower := strings.ToLower(path)
+	for _, prefix := range prefixes {
+		p := strings.ToLower(strings.TrimRight(prefix, `\/`))
+		if lower == p || strings.HasPrefix(lower, p+`\`) || strings.HasPrefix(lower, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// excludedName reports whether a file name matches one of the glob patterns
+func excludedName(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(strings.ToLower(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,225 @@
+package capture
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFiles creates files with the given content below dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create folder: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+}
+
+// fileNames returns the paths of files relative to dir, with forward slashes
+func fileNames(dir string, files []File) []string {
+	var names []string
+	for _, f := range files {
+		rel, _ := filepath.Rel(dir, f.Path)
+		names = append(names, filepath.ToSlash(rel))
+	}
+	return names
+}
+
+func TestSnapshotDiff(t *testing.T) {
+	root := t.TempDir()
+	opts := Options{
+		Folders:      []Folder{{Name: "ProgramFiles", Path: root, Target: "$env:ProgramFiles"}},
+		Exclude:      []string{filepath.Join(root, "Cache")},
+		ExcludeNames: []string{"*.log"},
+	}
+	writeFiles(t, root, map[string]string{"Shared/common.dll": "v1", "Old/remove.txt": "old", "Stable/keep.txt": "keep"})
+
+	before, err := TakeSnapshot(opts)
+	if err != nil {
+		t.Fatalf("TakeSnapshot() error = %v", err)
+	}
+
+	writeFiles(t, root, map[string]string{
+		"Contoso/App/app.exe": "application",
+		"Shared/common.dll":   "v2 longer",
+		"Contoso/install.log": "noise",
+		"Cache/blob.bin":      "noise",
+	})
+	if err := os.Remove(filepath.Join(root, "Old", "remove.txt")); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+
+	after, err := TakeSnapshot(opts)
+	if err != nil {
+		t.Fatalf("TakeSnapshot() error = %v", err)
+	}
+	changes := Diff(before, after)
+
+	if got := fileNames(root, changes.AddedFiles); strings.Join(got, ",") != "Contoso/App/app.exe" {
+		t.Errorf("AddedFiles = %v, want Contoso/App/app.exe", got)
+	}
+	if got := fileNames(root, changes.ModifiedFiles); strings.Join(got, ",") != "Shared/common.dll" {
+		t.Errorf("ModifiedFiles = %v, want Shared/common.dll", got)
+	}
+	if len(changes.DeletedFiles) != 1 || !strings.HasSuffix(changes.DeletedFiles[0], "remove.txt") {
+		t.Errorf("DeletedFiles = %v, want remove.txt", changes.DeletedFiles)
+	}
+	if len(changes.AddedDirs) != 2 {
+		t.Errorf("AddedDirs = %v, want Contoso and Contoso/App", changes.AddedDirs)
+	}
+	if changes.Empty() {
+		t.Error("Empty() = true, want false")
+	}
+	if !Diff(after, after).Empty() {
+		t.Error("Diff of a snapshot with itself is not empty")
+	}
+}
+
+func TestDiffRegistry(t *testing.T) {
+	snapshot := func(values ...RegistryValue) *Snapshot {
+		s := &Snapshot{Keys: map[string]string{}, Values: map[string]RegistryValue{}}
+		for _, v := range values {
+			s.Keys[strings.ToLower(v.Key)] = v.Key
+			s.Values[valueID(v.Key, v.Name)] = v
+		}
+		return s
+	}
+	version := RegistryValue{Key: `HKLM\SOFTWARE\Contoso`, Name: "Version", Type: regSZ, Data: []byte("1\x00")}
+	updated := version
+	updated.Data = []byte("2\x00")
+	flag := RegistryValue{Key: `HKLM\SOFTWARE\Contoso`, Name: "Flag", Type: regDWORD, Data: []byte{1, 0, 0, 0}}
+	added := RegistryValue{Key: `HKLM\SOFTWARE\Contoso\App`, Name: "Path", Type: regSZ, Data: []byte("C\x00")}
+
+	changes := Diff(snapshot(version, flag), snapshot(updated, added))
+	if len(changes.AddedKeys) != 1 || changes.AddedKeys[0] != `HKLM\SOFTWARE\Contoso\App` {
+		t.Errorf("AddedKeys = %v", changes.AddedKeys)
+	}
+	if len(changes.AddedValues) != 1 || changes.AddedValues[0].Name != "Path" {
+		t.Errorf("AddedValues = %v", changes.AddedValues)
+	}
+	if len(changes.ChangedValues) != 1 || changes.ChangedValues[0].Name != "Version" {
+		t.Errorf("ChangedValues = %v", changes.ChangedValues)
+	}
+	if len(changes.DeletedValues) != 1 || changes.DeletedValues[0] != `HKLM\SOFTWARE\Contoso\Flag` {
+		t.Errorf("DeletedValues = %v", changes.DeletedValues)
+	}
+}
+
+func TestWriteSource(t *testing.T) {
+	root := t.TempDir()
+	programFiles := filepath.Join(root, "Program Files")
+	programData := filepath.Join(root, "ProgramData")
+	writeFiles(t, programFiles, map[string]string{"Contoso/app.exe": "application", "Shared/common.dll": "v2"})
+	writeFiles(t, programData, map[string]string{"Contoso/settings.ini": "[app]"})
+	writeFiles(t, root, map[string]string{"elsewhere.txt": "outside"})
+
+	folders := []Folder{
+		{Name: "ProgramFiles", Path: programFiles, Target: "$env:ProgramFiles"},
+		{Name: "ProgramData", Path: programData, Target: "$env:ProgramData"},
+	}
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	changes := &Changes{
+		AddedFiles: []File{
+			{Path: filepath.Join(programFiles, "Contoso", "app.exe"), Size: 11, ModTime: modTime},
+			{Path: filepath.Join(programData, "Contoso", "settings.ini"), Size: 5, ModTime: modTime},
+			{Path: filepath.Join(root, "elsewhere.txt"), Size: 7, ModTime: modTime},
+		},
+		ModifiedFiles: []File{{Path: filepath.Join(programFiles, "Shared", "common.dll"), Size: 2, ModTime: modTime}},
+		AddedDirs:     []string{filepath.Join(programFiles, "Contoso"), filepath.Join(programData, "Contoso")},
+		AddedKeys:     []string{`HKLM\SOFTWARE\Contoso`, `HKLM\SOFTWARE\Contoso\App`},
+		AddedValues: []RegistryValue{
+			{Key: `HKLM\SOFTWARE\Contoso\App`, Name: "Path", Type: regSZ, Data: []byte("C\x00\x00\x00")},
+			{Key: `HKLM\SOFTWARE\Microsoft\Windows\CurrentVersion\Run`, Name: "Contoso's App", Type: regSZ, Data: []byte("C\x00\x00\x00")},
+		},
+	}
+
+	out := filepath.Join(t.TempDir(), "source")
+	src, err := WriteSource(out, "Contoso App", changes, folders)
+	if err != nil {
+		t.Fatalf("WriteSource() error = %v", err)
+	}
+	if src.Files != 3 || src.Size != 18 || src.Values != 2 {
+		t.Errorf("WriteSource() = %+v, want 3 files of 18 bytes and 2 values", src)
+	}
+	if len(src.Skipped) != 1 || !strings.HasSuffix(src.Skipped[0], "elsewhere.txt") {
+		t.Errorf("Skipped = %v, want the file outside the folders", src.Skipped)
+	}
+
+	for _, name := range []string{"Files/ProgramFiles/Contoso/app.exe", "Files/ProgramFiles/Shared/common.dll", "Files/ProgramData/Contoso/settings.ini", RegistryFile, InstallScript, UninstallScript, ReportFile} {
+		if _, err := os.Stat(filepath.Join(out, filepath.FromSlash(name))); err != nil {
+			t.Errorf("Missing %s in source: %v", name, err)
+		}
+	}
+	info, err := os.Stat(filepath.Join(out, "Files", "ProgramFiles", "Contoso", "app.exe"))
+	if err == nil && !info.ModTime().Equal(modTime) {
+		t.Errorf("Copied file ModTime = %v, want %v", info.ModTime(), modTime)
+	}
+
+	install, _ := os.ReadFile(filepath.Join(out, InstallScript))
+	for _, want := range []string{
+		"Copy-Item -Path (Join-Path $PSScriptRoot 'Files\\ProgramData\\*') -Destination $target",
+		"$target = $env:ProgramFiles\r\n",
+		"reg.exe import (Join-Path $PSScriptRoot 'registry.reg') /reg:64",
+		"Sysnative",
+	} {
+		if !strings.Contains(string(install), want) {
+			t.Errorf("install.ps1 does not contain %q:\n%s", want, install)
+		}
+	}
+
+	uninstall, _ := os.ReadFile(filepath.Join(out, UninstallScript))
+	for _, want := range []string{
+		"(Join-Path $env:ProgramFiles 'Contoso\\app.exe')",
+		"(Join-Path $env:ProgramData 'Contoso')",
+		"Remove-Item -LiteralPath 'Registry::HKEY_LOCAL_MACHINE\\SOFTWARE\\Contoso' -Recurse",
+		"Remove-ItemProperty -LiteralPath 'Registry::HKEY_LOCAL_MACHINE\\SOFTWARE\\Microsoft\\Windows\\CurrentVersion\\Run' -Name 'Contoso''s App'",
+	} {
+		if !strings.Contains(string(uninstall), want) {
+			t.Errorf("uninstall.ps1 does not contain %q:\n%s", want, uninstall)
+		}
+	}
+	for _, unwanted := range []string{"common.dll", `SOFTWARE\Contoso\App'`, "-Name 'Path'"} {
+		if strings.Contains(string(uninstall), unwanted) {
+			t.Errorf("uninstall.ps1 contains %q:\n%s", unwanted, uninstall)
+		}
+	}
+
+	data, _ := os.ReadFile(filepath.Join(out, ReportFile))
+	var report struct {
+		Name       string   `json:"name"`
+		AddedFiles []File   `json:"addedFiles"`
+		Skipped    []string `json:"skipped"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Failed to parse %s: %v", ReportFile, err)
+	}
+	if report.Name != "Contoso App" || len(report.AddedFiles) != 3 || len(report.Skipped) != 1 {
+		t.Errorf("%s = %+v", ReportFile, report)
+	}
+
+	if _, err := WriteSource(out, "Contoso App", changes, folders); err == nil {
+		t.Error("Expected error for a source folder that is not empty")
+	}
+}
+
+func TestFolderOfNested(t *testing.T) {
+	folders := []Folder{
+		{Name: "ProgramData", Path: filepath.Join("C:", "ProgramData")},
+		{Name: "StartMenu", Path: filepath.Join("C:", "ProgramData", "Start Menu")},
+	}
+	folder, rel, ok := folderOf(filepath.Join("C:", "ProgramData", "Start Menu", "App.lnk"), folders)
+	if !ok || folder.Name != "StartMenu" || rel != "App.lnk" {
+		t.Errorf("folderOf() = %s, %s, %v, want StartMenu, App.lnk", folder.Name, rel, ok)
+	}
+	if _, _, ok := folderOf(filepath.Join("C:", "Windows", "app.dll"), folders); ok {
+		t.Error("folderOf() found a folder for a path outside all folders")
+	}
+}
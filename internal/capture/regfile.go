@@ -0,0 +1,131 @@
+package capture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// Registry value types
+const (
+	regSZ       = 1
+	regExpandSZ = 2
+	regBinary   = 3
+	regDWORD    = 4
+	regMultiSZ  = 7
+)
+
+// registryRoots maps the short names of registry roots to the names .reg files use
+var registryRoots = map[string]string{
+	"HKLM": "HKEY_LOCAL_MACHINE",
+	"HKCU": "HKEY_CURRENT_USER",
+	"HKCR": "HKEY_CLASSES_ROOT",
+	"HKU":  "HKEY_USERS",
+	"HKCC": "HKEY_CURRENT_CONFIG",
+}
+
+// longKey returns a key with its root spelled out, e.g. HKEY_LOCAL_MACHINE\SOFTWARE
+func longKey(key string) string {
+	root, rest, _ := strings.Cut(key, `\`)
+	if long, ok := registryRoots[strings.ToUpper(root)]; ok {
+		root = long
+	}
+	if rest == "" {
+		return root
+	}
+	return root + `\` + rest
+}
+
+// shortRoot returns the short name of a registry root, e.g. HKLM for HKEY_LOCAL_MACHINE
+func shortRoot(root string) string {
+	root = strings.ToUpper(root)
+	for short, long := range registryRoots {
+		if root == long {
+			return short
+		}
+	}
+	return root
+}
+
+// RegFile returns a .reg file, as written by regedit (UTF-16LE with BOM), that creates
+// the keys and sets the values
+func RegFile(keys []string, values []RegistryValue) []byte {
+	byKey := map[string][]RegistryValue{}
+	names := map[string]string{}
+	for _, key := range keys {
+		names[strings.ToLower(longKey(key))] = longKey(key)
+	}
+	for _, v := range values {
+		id := strings.ToLower(longKey(v.Key))
+		names[id] = longKey(v.Key)
+		byKey[id] = append(byKey[id], v)
+	}
+	ids := make([]string, 0, len(names))
+	for id := range names {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var b strings.Builder
+	b.WriteString("Windows Registry Editor Version 5.00\r\n")
+	for _, id := range ids {
+		fmt.Fprintf(&b, "\r\n[%s]\r\n", names[id])
+		for _, v := range byKey[id] {
+			b.WriteString(regLine(v) + "\r\n")
+		}
+	}
+
+	encoded := utf16.Encode([]rune(b.String()))
+	out := make([]byte, 2, 2+2*len(encoded))
+	out[0], out[1] = 0xFF, 0xFE
+	for _, u := range encoded {
+		out = binary.LittleEndian.AppendUint16(out, u)
+	}
+	return out
+}
+
+// regLine formats a value as a line of a .reg file
+func regLine(v RegistryValue) string {
+	name := "@"
+	if v.Name != "" {
+		name = `"` + regEscape(v.Name) + `"`
+	}
+	switch {
+	case v.Type == regSZ && len(v.Data)%2 == 0:
+		return fmt.Sprintf(`%s="%s"`, name, regEscape(utf16String(v.Data)))
+	case v.Type == regDWORD && len(v.Data) == 4:
+		return fmt.Sprintf("%s=dword:%08x", name, binary.LittleEndian.Uint32(v.Data))
+	case v.Type == regBinary:
+		return name + "=hex:" + hexBytes(v.Data)
+	default:
+		return fmt.Sprintf("%s=hex(%x):%s", name, v.Type, hexBytes(v.Data))
+	}
+}
+
+// utf16String decodes a UTF-16LE registry string, without its terminating NULs
+func utf16String(data []byte) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	for len(units) > 0 && units[len(units)-1] == 0 {
+		units = units[:len(units)-1]
+	}
+	return string(utf16.Decode(units))
+}
+
+// regEscape escapes backslashes and quotes of .reg strings
+func regEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+// hexBytes formats data as comma-separated hex bytes
+func hexBytes(data []byte) string {
+	parts := make([]string, len(data))
+	for i, c := range data {
+		parts[i] = fmt.Sprintf("%02x", c)
+	}
+	return strings.Join(parts, ",")
+}
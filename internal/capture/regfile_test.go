@@ -0,0 +1,71 @@
+package capture
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// decodeRegFile decodes a UTF-16LE .reg file, checking its BOM
+func decodeRegFile(t *testing.T, data []byte) string {
+	t.Helper()
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xFE {
+		t.Fatalf("RegFile() has no UTF-16LE BOM")
+	}
+	units := make([]uint16, (len(data)-2)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(data[2+2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// utf16Data encodes a registry string as stored by Windows, with its terminating NUL
+func utf16Data(s string) []byte {
+	var data []byte
+	for _, u := range utf16.Encode([]rune(s + "\x00")) {
+		data = binary.LittleEndian.AppendUint16(data, u)
+	}
+	return data
+}
+
+func TestRegFile(t *testing.T) {
+	got := decodeRegFile(t, RegFile([]string{`HKLM\SOFTWARE\Contoso\Empty`}, []RegistryValue{
+		{Key: `HKLM\SOFTWARE\Contoso`, Name: "", Type: regSZ, Data: utf16Data(`C:\Program Files\Contoso "App"`)},
+		{Key: `HKLM\SOFTWARE\Contoso`, Name: "Flag", Type: regDWORD, Data: []byte{0x2a, 0, 0, 0}},
+		{Key: `HKLM\SOFTWARE\Contoso`, Name: "Blob", Type: regBinary, Data: []byte{0xde, 0xad}},
+		{Key: `HKLM\SOFTWARE\Contoso`, Name: "Home", Type: regExpandSZ, Data: utf16Data("%A%")},
+		{Key: `HKEY_LOCAL_MACHINE\SOFTWARE\Contoso`, Name: "List", Type: regMultiSZ, Data: []byte{'a', 0, 0, 0, 0, 0}},
+	}))
+
+	want := strings.Join([]string{
+		"Windows Registry Editor Version 5.00",
+		"",
+		`[HKEY_LOCAL_MACHINE\SOFTWARE\Contoso]`,
+		`@="C:\\Program Files\\Contoso \"App\""`,
+		`"Flag"=dword:0000002a`,
+		`"Blob"=hex:de,ad`,
+		`"Home"=hex(2):25,00,41,00,25,00,00,00`,
+		`"List"=hex(7):61,00,00,00,00,00`,
+		"",
+		`[HKEY_LOCAL_MACHINE\SOFTWARE\Contoso\Empty]`,
+		"",
+	}, "\r\n")
+	if got != want {
+		t.Errorf("RegFile() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLongKey(t *testing.T) {
+	tests := map[string]string{
+		`HKLM\SOFTWARE\Contoso`:  `HKEY_LOCAL_MACHINE\SOFTWARE\Contoso`,
+		`hkcu\Software`:          `HKEY_CURRENT_USER\Software`,
+		`HKU`:                    `HKEY_USERS`,
+		`HKEY_CLASSES_ROOT\.txt`: `HKEY_CLASSES_ROOT\.txt`,
+	}
+	for key, want := range tests {
+		if got := longKey(key); got != want {
+			t.Errorf("longKey(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
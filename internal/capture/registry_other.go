@@ -0,0 +1,10 @@
+//go:build !windows
+
+package capture
+
+import "fmt"
+
+// readRegistry is only supported on Windows
+func readRegistry(key string, exclude []string, s *Snapshot) error {
+	return fmt.Errorf("capturing registry keys requires Windows")
+}
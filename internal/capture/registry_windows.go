@@ -0,0 +1,83 @@
+package capture
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// registryHandles maps the short names of registry roots to their handles
+var registryHandles = map[string]registry.Key{
+	"HKLM": registry.LOCAL_MACHINE,
+	"HKCU": registry.CURRENT_USER,
+	"HKCR": registry.CLASSES_ROOT,
+	"HKU":  registry.USERS,
+	"HKCC": registry.CURRENT_CONFIG,
+}
+
+// readRegistry records a registry key and everything below it in the 64-bit view,
+// counting subkeys that cannot be read as skipped
+func readRegistry(key string, exclude []string, s *Snapshot) error {
+	rootName, path, _ := strings.Cut(key, `\`)
+	rootName, path = shortRoot(rootName), strings.Trim(path, `\`)
+	root, ok := registryHandles[rootName]
+	if !ok {
+		return fmt.Errorf("invalid registry key: %s (supported roots: HKLM, HKCU, HKCR, HKU, HKCC)", key)
+	}
+	k, err := registry.OpenKey(root, path, registry.READ|registry.WOW64_64KEY)
+	if err != nil {
+		return fmt.Errorf("failed to open registry key %s: %w", key, err)
+	}
+	defer k.Close()
+	name := rootName
+	if path != "" {
+		name += `\` + path
+	}
+	readKey(k, name, exclude, s)
+	return nil
+}
+
+// readKey records an open key's values and recurses into its subkeys
+func readKey(k registry.Key, name string, exclude []string, s *Snapshot) {
+	if excluded(name, exclude) {
+		return
+	}
+	s.Keys[strings.ToLower(name)] = name
+
+	values, err := k.ReadValueNames(0)
+	if err != nil {
+		s.Skipped++
+	}
+	for _, value := range values {
+		n, typ, err := k.GetValue(value, nil)
+		if err != nil {
+			s.Skipped++
+			continue
+		}
+		data := make([]byte, n)
+		if n > 0 {
+			if n, typ, err = k.GetValue(value, data); err != nil {
+				// The value changed size since it was measured
+				s.Skipped++
+				continue
+			}
+		}
+		s.Values[valueID(name, value)] = RegistryValue{Key: name, Name: value, Type: typ, Data: data[:n]}
+	}
+
+	subkeys, err := k.ReadSubKeyNames(0)
+	if err != nil {
+		s.Skipped++
+		return
+	}
+	for _, subkey := range subkeys {
+		sub, err := registry.OpenKey(k, subkey, registry.READ|registry.WOW64_64KEY)
+		if err != nil {
+			s.Skipped++
+			continue
+		}
+		readKey(sub, name+`\`+subkey, exclude, s)
+		sub.Close()
+	}
+}
@@ -0,0 +1,281 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Files of a captured source folder
+const (
+	InstallScript   = "install.ps1"
+	UninstallScript = "uninstall.ps1"
+	RegistryFile    = "registry.reg"
+	ReportFile      = "capture.json"
+	// filesDir holds the captured files, in a subfolder per captured folder
+	filesDir = "Files"
+)
+
+// Source is a source folder written by WriteSource
+type Source struct {
+	Dir string
+	// Files and Size are the captured files copied to the source
+	Files int
+	Size  int64
+	// Values is the number of registry values in the .reg file
+	Values int
+	// Skipped are changed files outside the captured folders or that could not be read
+	Skipped []string
+}
+
+// report is the content of capture.json
+type report struct {
+	Name     string    `json:"name"`
+	Captured time.Time `json:"captured"`
+	*Changes
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// WriteSource writes a source folder that installs the changes: the added and modified
+// files of the folders, a .reg file of the added and changed registry values, and install
+// and uninstall scripts; deletions are listed in capture.json but not repeated
+// dir must not exist or be empty
+func WriteSource(dir, name string, changes *Changes, folders []Folder) (*Source, error) {
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("%s is not empty", dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create source folder: %w", err)
+	}
+
+	src := &Source{Dir: dir}
+	var installed []installedFile
+	used := map[string]Folder{}
+	for _, file := range append(append([]File{}, changes.AddedFiles...), changes.ModifiedFiles...) {
+		folder, rel, ok := folderOf(file.Path, folders)
+		if !ok {
+			src.Skipped = append(src.Skipped, file.Path)
+			continue
+		}
+		target := filepath.Join(dir, filesDir, folder.Name, rel)
+		if err := copyFile(file.Path, target, file.ModTime); err != nil {
+			src.Skipped = append(src.Skipped, file.Path)
+			continue
+		}
+		used[folder.Name] = folder
+		src.Files++
+		src.Size += file.Size
+		installed = append(installed, installedFile{folder: folder, rel: rel})
+	}
+
+	values := append(append([]RegistryValue{}, changes.AddedValues...), changes.ChangedValues...)
+	if len(values) > 0 || len(changes.AddedKeys) > 0 {
+		if err := os.WriteFile(filepath.Join(dir, RegistryFile), RegFile(changes.AddedKeys, values), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", RegistryFile, err)
+		}
+		src.Values = len(values)
+	}
+
+	now := time.Now()
+	if err := writeScripts(dir, name, now, used, installed, changes, folders); err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(report{Name: name, Captured: now.UTC().Truncate(time.Second), Changes: changes, Skipped: src.Skipped}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", ReportFile, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ReportFile), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", ReportFile, err)
+	}
+	return src, nil
+}
+
+// installedFile is a captured file by the folder it is installed to
+type installedFile struct {
+	folder Folder
+	rel    string
+}
+
+// folderOf returns the captured folder a path is in, the most specific one when folders
+// are nested, and the path relative to it
+func folderOf(path string, folders []Folder) (Folder, string, bool) {
+	var best Folder
+	var bestRel string
+	for _, folder := range folders {
+		rel, err := filepath.Rel(folder.Path, path)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if bestRel == "" || len(folder.Path) > len(best.Path) {
+			best, bestRel = folder, rel
+		}
+	}
+	return best, bestRel, bestRel != ""
+}
+
+// copyFile copies a file, keeping its modification time
+func copyFile(from, to string, modTime time.Time) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(to, modTime, modTime)
+}
+
+// scriptHeader relaunches a script in 64-bit PowerShell: Intune may start installs in
+// 32-bit PowerShell, which redirects Program Files and HKLM\SOFTWARE
+const scriptHeader = `$ErrorActionPreference = 'Stop'
+
+# Intune may start this script in 32-bit PowerShell, which redirects Program Files and HKLM\SOFTWARE
+if ($env:PROCESSOR_ARCHITEW6432 -eq 'AMD64') {
+    & "$env:SystemRoot\Sysnative\WindowsPowerShell\v1.0\powershell.exe" -NoProfile -ExecutionPolicy Bypass -File $PSCommandPath
+    exit $LASTEXITCODE
+}
+`
+
+var installTemplate = template.Must(template.New(InstallScript).Parse(`# {{.Name}}: installs the files and registry values captured by intunewin capture on {{.Date}}
+` + scriptHeader + `{{range .Folders}}
+$target = {{.Target}}
+New-Item -ItemType Directory -Path $target -Force | Out-Null
+Copy-Item -Path (Join-Path $PSScriptRoot {{.Source}}) -Destination $target -Recurse -Force
+{{end}}{{if .Registry}}
+& reg.exe import (Join-Path $PSScriptRoot '` + RegistryFile + `') /reg:64
+if ($LASTEXITCODE -ne 0) { throw "reg.exe import failed with exit code $LASTEXITCODE" }
+{{end}}
+exit 0
+`))
+
+var uninstallTemplate = template.Must(template.New(UninstallScript).Parse(`# {{.Name}}: removes the files and registry keys added by install.ps1
+` + scriptHeader + `$ErrorActionPreference = 'Continue'
+
+$files = @(
+{{range .Files}}    {{.}}
+{{end}})
+foreach ($file in $files) { Remove-Item -LiteralPath $file -Force -ErrorAction SilentlyContinue }
+
+# Folders the install created, deepest first, removed when empty
+$folders = @(
+{{range .Dirs}}    {{.}}
+{{end}})
+foreach ($folder in $folders) {
+    if ((Test-Path -LiteralPath $folder) -and -not (Get-ChildItem -LiteralPath $folder -Force)) {
+        Remove-Item -LiteralPath $folder -Force
+    }
+}
+{{range .Keys}}
+Remove-Item -LiteralPath {{.}} -Recurse -Force -ErrorAction SilentlyContinue{{end}}{{range .Values}}
+Remove-ItemProperty -LiteralPath {{.Key}} -Name {{.Name}} -Force -ErrorAction SilentlyContinue{{end}}
+
+exit 0
+`))
+
+// writeScripts writes the install and uninstall scripts of a captured source
+func writeScripts(dir, name string, now time.Time, used map[string]Folder, installed []installedFile, changes *Changes, folders []Folder) error {
+	type copyStep struct{ Target, Source string }
+	var steps []copyStep
+	for _, folderName := range sortedKeys(used) {
+		steps = append(steps, copyStep{Target: used[folderName].Target, Source: psQuote(filesDir + `\` + folderName + `\*`)})
+	}
+	install := map[string]any{
+		"Name":     name,
+		"Date":     now.Format("2006-01-02"),
+		"Folders":  steps,
+		"Registry": len(changes.AddedKeys)+len(changes.AddedValues)+len(changes.ChangedValues) > 0,
+	}
+
+	// Only added files are removed; modified files belonged to the system before
+	var files []string
+	added := map[string]bool{}
+	for _, f := range changes.AddedFiles {
+		added[strings.ToLower(f.Path)] = true
+	}
+	for _, f := range installed {
+		if added[strings.ToLower(filepath.Join(f.folder.Path, f.rel))] {
+			files = append(files, installedPath(f.folder, f.rel))
+		}
+	}
+	dirs := append([]string{}, changes.AddedDirs...)
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+	var dirPaths []string
+	for _, d := range dirs {
+		if folder, rel, ok := folderOf(d, folders); ok {
+			dirPaths = append(dirPaths, installedPath(folder, rel))
+		}
+	}
+
+	// Keys whose parent was added too go with their parent
+	var keys []string
+	for _, key := range changes.AddedKeys {
+		if !hasAddedParent(key, changes.AddedKeys) {
+			keys = append(keys, psQuote(`Registry::`+longKey(key)))
+		}
+	}
+	type valueStep struct{ Key, Name string }
+	var values []valueStep
+	for _, v := range changes.AddedValues {
+		if !hasAddedParent(v.Key+`\`, changes.AddedKeys) {
+			values = append(values, valueStep{Key: psQuote(`Registry::` + longKey(v.Key)), Name: psQuote(v.Name)})
+		}
+	}
+	uninstall := map[string]any{"Name": name, "Files": files, "Dirs": dirPaths, "Keys": keys, "Values": values}
+
+	for file, tmpl := range map[string]struct {
+		t    *template.Template
+		data any
+	}{InstallScript: {installTemplate, install}, UninstallScript: {uninstallTemplate, uninstall}} {
+		var b bytes.Buffer
+		if err := tmpl.t.Execute(&b, tmpl.data); err != nil {
+			return fmt.Errorf("failed to generate %s: %w", file, err)
+		}
+		script := strings.ReplaceAll(b.String(), "\n", "\r\n")
+		// A BOM makes Windows PowerShell read non-ASCII paths as UTF-8
+		if err := os.WriteFile(filepath.Join(dir, file), append([]byte("\xEF\xBB\xBF"), script...), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// installedPath returns the PowerShell expression of a path below a captured folder
+func installedPath(folder Folder, rel string) string {
+	return fmt.Sprintf("(Join-Path %s %s)", folder.Target, psQuote(strings.ReplaceAll(filepath.ToSlash(rel), "/", `\`)))
+}
+
+// hasAddedParent reports whether a key is below one of the added keys
+func hasAddedParent(key string, added []string) bool {
+	lower := strings.ToLower(key)
+	for _, a := range added {
+		if strings.HasPrefix(lower, strings.ToLower(a)+`\`) {
+			return true
+		}
+	}
+	return false
+}
+
+// psQuote quotes a string for PowerShell
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
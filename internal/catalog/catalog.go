@@ -0,0 +1,353 @@
+// Package catalog publishes a local folder of .intunewin packages to a remote repository
+// (an Azure Blob container or an SMB share) described by a JSON index
+package catalog
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+const (
+	// IndexName is the remote index object, at the repository root
+	IndexName = "index.json"
+	// IndexVersion is the format version written to the index
+	IndexVersion = 1
+	// DefaultKeep is the number of versions kept per package when pruning
+	DefaultKeep = 3
+)
+
+// Index lists the packages in a remote repository
+type Index struct {
+	Version  int       `json:"version"`
+	Updated  time.Time `json:"updated"`
+	Packages []Entry   `json:"packages"`
+}
+
+// Entry is one published version of a package
+type Entry struct {
+	// Name is the package path relative to the local catalog, with forward slashes
+	Name string `json:"name"`
+	// Object is the remote object holding the package, named after its hash
+	Object    string    `json:"object"`
+	SHA256    string    `json:"sha256"`
+	Size      int64     `json:"size"`
+	App       string    `json:"app,omitempty"`
+	Version   string    `json:"version,omitempty"`
+	Publisher string    `json:"publisher,omitempty"`
+	Published time.Time `json:"published"`
+	// License is the licensing record packaged with the app (see packager.License)
+	License *packager.License `json:"license,omitempty"`
+}
+
+// Options controls a publish
+type Options struct {
+	// Sync skips packages whose hash is already published and prunes superseded versions;
+	// otherwise every local package is uploaded and nothing is removed
+	Sync bool
+	// Keep is the number of versions kept per package name when syncing (0 keeps all)
+	Keep int
+	// DryRun reports what would change without writing to the repository
+	DryRun bool
+	// Logger receives progress messages (optional, defaults to slog.Default())
+	Logger *slog.Logger
+	// HashCache skips rehashing local packages that did not change (optional)
+	HashCache *packager.HashCache
+}
+
+// Report describes the changes made by a publish
+type Report struct {
+	// Uploaded packages were transferred to the repository
+	Uploaded []Entry
+	// Reused packages already had their content in the repository under another entry
+	Reused []Entry
+	// Unchanged packages are already the latest published version
+	Unchanged []Entry
+	// Relicensed packages are unchanged but their license record was updated in the index
+	Relicensed []Entry
+	// Pruned versions were superseded beyond the retention policy and removed
+	Pruned []Entry
+}
+
+// LocalPackage is a .intunewin file in the local catalog
+type LocalPackage struct {
+	Name    string
+	Path    string
+	SHA256  string
+	Size    int64
+	App     string
+	Version string
+	// Publisher is the MSI publisher, empty for other installers
+	Publisher string
+	// License is read from the license record next to the package, nil when it has none
+	License *packager.License
+}
+
+// ScanLocal finds the .intunewin packages in a folder and its subfolders and hashes them
+// Unchanged packages take their hash from cache (optional)
+func ScanLocal(root string, cache *packager.HashCache) ([]LocalPackage, error) {
+	var packages []LocalPackage
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(p), ".intunewin") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		pkg := LocalPackage{Name: filepath.ToSlash(rel), Path: p}
+		pkg.SHA256, pkg.Size, err = hashFile(p, cache)
+		if err != nil {
+			return err
+		}
+
+		appInfo, err := packager.ReadDetectionXML(p)
+		if err != nil {
+			return fmt.Errorf("%s is not a valid package: %w", pkg.Name, err)
+		}
+		pkg.App = appInfo.Name
+		if appInfo.MsiInfo != nil {
+			pkg.Version = appInfo.MsiInfo.MsiProductVersion
+			pkg.Publisher = appInfo.MsiInfo.MsiPublisher
+		}
+		pkg.License, err = packager.ReadLicense(p)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.Name, err)
+		}
+
+		packages = append(packages, pkg)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan catalog: %w", err)
+	}
+	return packages, nil
+}
+
+// hashFile returns the SHA256 and size of a file
+func hashFile(p string, cache *packager.HashCache) (string, int64, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return "", 0, err
+	}
+	sum, err := cache.FileSHA256(p)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(sum), info.Size(), nil
+}
+
+// ObjectName returns the remote object of a package: content-addressed, so a changed
+// package never overwrites the version it supersedes
+func ObjectName(sha256, name string) string {
+	return path.Join("packages", sha256, path.Base(name))
+}
+
+// ReadIndex fetches the remote index and its version tag; a missing index is empty
+func ReadIndex(ctx context.Context, store Store) (*Index, string, error) {
+	data, tag, err := store.Get(ctx, IndexName)
+	if errors.Is(err, ErrNotFound) {
+		return &Index{Version: IndexVersion}, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read remote index: %w", err)
+	}
+
+	var index Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, "", fmt.Errorf("failed to parse remote index: %w", err)
+	}
+	if index.Version > IndexVersion {
+		return nil, "", fmt.Errorf("remote index version %d is newer than supported (%d)", index.Version, IndexVersion)
+	}
+	return &index, tag, nil
+}
+
+// Publish uploads the local catalog to the repository and updates the remote index
+// Packages are uploaded first, then the index is replaced in a single conditional write,
+// and superseded objects are deleted last, so the index never lists missing packages.
+// If another publish changed the index in the meantime, nothing is deleted and an
+// error asks to re-run
+func Publish(ctx context.Context, localDir string, store Store, opts Options) (*Report, error) {
+	log := opts.Logger
+	if log == nil {
+		log = slog.Default()
+	}
+
+	local, err := ScanLocal(localDir, opts.HashCache)
+	if err != nil {
+		return nil, err
+	}
+	index, tag, err := ReadIndex(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	now := time.Now().UTC()
+	entries := index.Packages
+
+	for _, pkg := range local {
+		entry := Entry{
+			Name:      pkg.Name,
+			Object:    ObjectName(pkg.SHA256, pkg.Name),
+			SHA256:    pkg.SHA256,
+			Size:      pkg.Size,
+			App:       pkg.App,
+			Version:   pkg.Version,
+			Publisher: pkg.Publisher,
+			Published: now,
+			License:   pkg.License,
+		}
+
+		latest := latestEntry(entries, pkg.Name)
+		if opts.Sync && latest != nil && latest.SHA256 == pkg.SHA256 {
+			// A license record edited after publishing is updated without uploading again
+			if !latest.License.Equal(pkg.License) {
+				latest.License = pkg.License
+				report.Relicensed = append(report.Relicensed, *latest)
+				continue
+			}
+			report.Unchanged = append(report.Unchanged, *latest)
+			continue
+		}
+
+		// Identical content published before (an older version restored, or a renamed
+		// package) is referenced again instead of uploaded
+		if existing := entryWithHash(entries, pkg.SHA256); opts.Sync && existing != nil {
+			entry.Object = existing.Object
+			report.Reused = append(report.Reused, entry)
+		} else {
+			if !opts.DryRun {
+				log.Info("uploading package", "package", pkg.Name, "bytes", pkg.Size, "to", store.String())
+				if err := store.Upload(ctx, entry.Object, pkg.Path); err != nil {
+					return nil, fmt.Errorf("failed to upload %s: %w", pkg.Name, err)
+				}
+			}
+			report.Uploaded = append(report.Uploaded, entry)
+		}
+		entries = withoutEntry(entries, pkg.Name, pkg.SHA256)
+		entries = append(entries, entry)
+	}
+
+	if opts.Sync {
+		var pruned []Entry
+		entries, pruned = applyRetention(entries, opts.Keep)
+		report.Pruned = pruned
+	}
+
+	if opts.DryRun {
+		return report, nil
+	}
+	if len(report.Uploaded) == 0 && len(report.Reused) == 0 && len(report.Pruned) == 0 && len(report.Relicensed) == 0 {
+		return report, nil
+	}
+
+	sortEntries(entries)
+	updated := Index{Version: IndexVersion, Updated: now, Packages: entries}
+	data, err := json.MarshalIndent(updated, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode index: %w", err)
+	}
+	if err := store.Put(ctx, IndexName, append(data, '\n'), tag); err != nil {
+		if errors.Is(err, ErrConditionFailed) {
+			return nil, fmt.Errorf("remote index was changed by another publish while syncing, run again: %w", err)
+		}
+		return nil, fmt.Errorf("failed to update remote index: %w", err)
+	}
+
+	// Objects still referenced by a kept entry (reused content) stay
+	referenced := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		referenced[e.Object] = true
+	}
+	for _, e := range report.Pruned {
+		if referenced[e.Object] {
+			continue
+		}
+		log.Info("pruning superseded package", "package", e.Name, "published", e.Published.Format(time.RFC3339))
+		if err := store.Delete(ctx, e.Object); err != nil {
+			// The index no longer lists it, so a leftover object is harmless
+			log.Warn("could not delete superseded package", "object", e.Object, "error", err)
+		}
+	}
+	return report, nil
+}
+
+// latestEntry returns the most recently published entry for a package name
+func latestEntry(entries []Entry, name string) *Entry {
+	var latest *Entry
+	for i := range entries {
+		if entries[i].Name == name && (latest == nil || entries[i].Published.After(latest.Published)) {
+			latest = &entries[i]
+		}
+	}
+	return latest
+}
+
+// entryWithHash returns an entry whose content has the given hash
+func entryWithHash(entries []Entry, sha string) *Entry {
+	for i := range entries {
+		if entries[i].SHA256 == sha {
+			return &entries[i]
+		}
+	}
+	return nil
+}
+
+// withoutEntry drops the entry of a package name with the given hash, which is
+// re-added as the newest version
+func withoutEntry(entries []Entry, name, sha string) []Entry {
+	kept := entries[:0:0]
+	for _, e := range entries {
+		if e.Name != name || e.SHA256 != sha {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// applyRetention keeps the newest keep versions of each package name
+func applyRetention(entries []Entry, keep int) (kept, pruned []Entry) {
+	if keep <= 0 {
+		return entries, nil
+	}
+	sortEntries(entries)
+
+	count := make(map[string]int)
+	for _, e := range entries {
+		count[e.Name]++
+		if count[e.Name] > keep {
+			pruned = append(pruned, e)
+		} else {
+			kept = append(kept, e)
+		}
+	}
+	return kept, pruned
+}
+
+// sortEntries orders entries by name, newest version first
+func sortEntries(entries []Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Name != entries[j].Name {
+			return entries[i].Name < entries[j].Name
+		}
+		return entries[i].Published.After(entries[j].Published)
+	})
+}
@@ -0,0 +1,352 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+var quietLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// writePackage packages a setup file with the given content as name in the catalog folder
+func writePackage(t *testing.T, catalogDir, name, content string) {
+	t.Helper()
+	source := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "setup.exe"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+	out := t.TempDir()
+	result, err := packager.PackageWithOptions(source, "setup.exe", out, packager.Options{Logger: quietLogger}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create package: %v", err)
+	}
+
+	target := filepath.Join(catalogDir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		t.Fatalf("Failed to create catalog folder: %v", err)
+	}
+	if err := os.Rename(result.OutputPath, target); err != nil {
+		t.Fatalf("Failed to move package: %v", err)
+	}
+}
+
+func syncOptions(keep int) Options {
+	return Options{Sync: true, Keep: keep, Logger: quietLogger}
+}
+
+func TestPublishSyncUploadsOnlyChanged(t *testing.T) {
+	local := t.TempDir()
+	store := &DirStore{Root: t.TempDir()}
+	ctx := context.Background()
+
+	writePackage(t, local, "7zip/7zip.intunewin", "7zip 24.01")
+	writePackage(t, local, "notepad.intunewin", "notepad++ 8.6")
+
+	report, err := Publish(ctx, local, store, syncOptions(DefaultKeep))
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if len(report.Uploaded) != 2 {
+		t.Fatalf("First sync uploaded %d packages, want 2", len(report.Uploaded))
+	}
+	_, firstTag, err := store.Get(ctx, IndexName)
+	if err != nil {
+		t.Fatalf("Remote index missing: %v", err)
+	}
+
+	// Nothing changed locally: no uploads and the index is left alone
+	report, err = Publish(ctx, local, store, syncOptions(DefaultKeep))
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if len(report.Uploaded) != 0 || len(report.Unchanged) != 2 {
+		t.Fatalf("Second sync: uploaded %d, unchanged %d; want 0 and 2", len(report.Uploaded), len(report.Unchanged))
+	}
+	if _, tag, _ := store.Get(ctx, IndexName); tag != firstTag {
+		t.Error("Index was rewritten although nothing changed")
+	}
+
+	// A rebuilt package is uploaded next to the version it supersedes
+	writePackage(t, local, "7zip/7zip.intunewin", "7zip 24.07")
+	report, err = Publish(ctx, local, store, syncOptions(DefaultKeep))
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if len(report.Uploaded) != 1 || report.Uploaded[0].Name != "7zip/7zip.intunewin" {
+		t.Fatalf("Third sync uploaded %+v, want only 7zip/7zip.intunewin", report.Uploaded)
+	}
+
+	index, _, err := ReadIndex(ctx, store)
+	if err != nil {
+		t.Fatalf("ReadIndex() error = %v", err)
+	}
+	if len(index.Packages) != 3 {
+		t.Fatalf("Index has %d entries, want 3", len(index.Packages))
+	}
+	for _, e := range index.Packages {
+		if _, _, err := store.Get(ctx, e.Object); err != nil {
+			t.Errorf("Object of %s missing: %v", e.Name, err)
+		}
+		if e.App == "" {
+			t.Errorf("Entry %s has no app name", e.Name)
+		}
+	}
+	if index.Packages[0].Name != "7zip/7zip.intunewin" || index.Packages[0].SHA256 != report.Uploaded[0].SHA256 {
+		t.Errorf("Newest 7zip version is not listed first: %+v", index.Packages[0])
+	}
+}
+
+func TestPublishSyncRetention(t *testing.T) {
+	local := t.TempDir()
+	store := &DirStore{Root: t.TempDir()}
+	ctx := context.Background()
+
+	var objects []string
+	for _, version := range []string{"1.0", "2.0", "3.0"} {
+		writePackage(t, local, "app.intunewin", "app "+version)
+		report, err := Publish(ctx, local, store, syncOptions(2))
+		if err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+		objects = append(objects, report.Uploaded[0].Object)
+	}
+
+	index, _, err := ReadIndex(ctx, store)
+	if err != nil {
+		t.Fatalf("ReadIndex() error = %v", err)
+	}
+	if len(index.Packages) != 2 {
+		t.Fatalf("Index has %d entries, want 2", len(index.Packages))
+	}
+	if _, _, err := store.Get(ctx, objects[0]); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Oldest version was not pruned: %v", err)
+	}
+	for _, object := range objects[1:] {
+		if _, _, err := store.Get(ctx, object); err != nil {
+			t.Errorf("Kept version %s missing: %v", object, err)
+		}
+	}
+}
+
+func TestPublishSyncReusesRestoredVersion(t *testing.T) {
+	local := t.TempDir()
+	store := &DirStore{Root: t.TempDir()}
+	ctx := context.Background()
+
+	writePackage(t, local, "app.intunewin", "app 1.0")
+	first, err := Publish(ctx, local, store, syncOptions(DefaultKeep))
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(local, "app.intunewin"))
+	if err != nil {
+		t.Fatalf("Failed to read package: %v", err)
+	}
+
+	writePackage(t, local, "app.intunewin", "app 1.1 (broken)")
+	if _, err := Publish(ctx, local, store, syncOptions(DefaultKeep)); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	// Rolling back to the earlier build references the object already published
+	os.WriteFile(filepath.Join(local, "app.intunewin"), data, 0644)
+	report, err := Publish(ctx, local, store, syncOptions(DefaultKeep))
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if len(report.Uploaded) != 0 || len(report.Reused) != 1 {
+		t.Fatalf("Rollback: uploaded %d, reused %d; want 0 and 1", len(report.Uploaded), len(report.Reused))
+	}
+	if report.Reused[0].Object != first.Uploaded[0].Object {
+		t.Errorf("Reused object = %s, want %s", report.Reused[0].Object, first.Uploaded[0].Object)
+	}
+
+	index, _, _ := ReadIndex(ctx, store)
+	if len(index.Packages) != 2 || index.Packages[0].SHA256 != first.Uploaded[0].SHA256 {
+		t.Errorf("Restored version is not the newest entry: %+v", index.Packages)
+	}
+}
+
+func TestPublishDryRun(t *testing.T) {
+	local := t.TempDir()
+	remote := t.TempDir()
+	store := &DirStore{Root: remote}
+
+	writePackage(t, local, "app.intunewin", "app 1.0")
+	opts := syncOptions(DefaultKeep)
+	opts.DryRun = true
+	report, err := Publish(context.Background(), local, store, opts)
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if len(report.Uploaded) != 1 {
+		t.Errorf("Dry run reported %d uploads, want 1", len(report.Uploaded))
+	}
+	if entries, _ := os.ReadDir(remote); len(entries) != 0 {
+		t.Errorf("Dry run wrote %d entries to the repository", len(entries))
+	}
+}
+
+// racingStore simulates another publish updating the index during an upload
+type racingStore struct {
+	*DirStore
+}
+
+func (s *racingStore) Upload(ctx context.Context, name, localPath string) error {
+	if err := os.WriteFile(filepath.Join(s.Root, IndexName), []byte(`{"version":1,"packages":[]}`), 0644); err != nil {
+		return err
+	}
+	return s.DirStore.Upload(ctx, name, localPath)
+}
+
+func TestPublishIndexConflict(t *testing.T) {
+	local := t.TempDir()
+	store := &racingStore{&DirStore{Root: t.TempDir()}}
+
+	writePackage(t, local, "app.intunewin", "app 1.0")
+	_, err := Publish(context.Background(), local, store, syncOptions(DefaultKeep))
+	if !errors.Is(err, ErrConditionFailed) {
+		t.Fatalf("Publish() error = %v, want ErrConditionFailed", err)
+	}
+}
+
+func TestOpenStore(t *testing.T) {
+	store, err := OpenStore("https://account.blob.core.windows.net/catalog?sv=2021&sig=secret", nil)
+	if err != nil {
+		t.Fatalf("OpenStore() error = %v", err)
+	}
+	if _, ok := store.(*BlobStore); !ok {
+		t.Errorf("Expected a BlobStore, got %T", store)
+	}
+	if got := store.String(); got != "https://account.blob.core.windows.net/catalog" {
+		t.Errorf("String() = %s, the SAS token must not be shown", got)
+	}
+
+	if _, err := OpenStore("https://account.blob.core.windows.net/catalog", nil); err == nil {
+		t.Error("Expected an error for a container URL without SAS token")
+	}
+
+	dir := t.TempDir()
+	store, err = OpenStore(dir, nil)
+	if err != nil {
+		t.Fatalf("OpenStore() error = %v", err)
+	}
+	if _, ok := store.(*DirStore); !ok {
+		t.Errorf("Expected a DirStore, got %T", store)
+	}
+}
+
+func TestOpenStoreSchemes(t *testing.T) {
+	store, err := OpenStore("azblob://contoso/catalog/prod?client_id=user-assigned", nil)
+	if err != nil {
+		t.Fatalf("OpenStore(azblob) error = %v", err)
+	}
+	if blob, ok := store.(*BlobStore); !ok || blob.ContainerURL != "https://contoso.blob.core.windows.net/catalog/prod" {
+		t.Errorf("OpenStore(azblob) = %#v", store)
+	}
+	if _, err := OpenStore("azblob://contoso", nil); err == nil {
+		t.Error("Expected an error for an azblob URL without container")
+	}
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	if _, err := OpenStore("s3://intune/catalog", nil); err == nil {
+		t.Error("Expected an error for an s3 URL without credentials")
+	}
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	store, err = OpenStore("s3://intune/catalog/prod/?endpoint=https://minio.contoso.com:9000&region=eu-west-1", nil)
+	if err != nil {
+		t.Fatalf("OpenStore(s3) error = %v", err)
+	}
+	bucket, ok := store.(*S3Store)
+	if !ok || bucket.Bucket != "intune" || bucket.key(IndexName) != "catalog/prod/"+IndexName {
+		t.Errorf("OpenStore(s3) = %#v", store)
+	}
+	if store.String() != "s3://intune/catalog/prod" {
+		t.Errorf("String() = %s", store.String())
+	}
+
+	dir := t.TempDir()
+	store, err = OpenStore("file:///"+strings.TrimPrefix(filepath.ToSlash(dir), "/"), nil)
+	if err != nil {
+		t.Fatalf("OpenStore(file) error = %v", err)
+	}
+	if _, ok := store.(*DirStore); !ok {
+		t.Errorf("Expected a DirStore, got %T", store)
+	}
+
+	if _, err := OpenStore("ftp://fileserver/catalog", nil); err == nil {
+		t.Error("Expected an error for an unsupported scheme")
+	}
+	if runtime.GOOS != "windows" {
+		if _, err := OpenStore("smb://fileserver/intune/catalog", nil); err == nil {
+			t.Error("Expected an error for an SMB URL outside Windows")
+		}
+	}
+}
+
+func TestPublishLicense(t *testing.T) {
+	local := t.TempDir()
+	store := &DirStore{Root: t.TempDir()}
+	ctx := context.Background()
+
+	writePackage(t, local, "7zip.intunewin", "7zip 24.01")
+	writePackage(t, local, "acrobat.intunewin", "acrobat 24.1")
+	acrobat := filepath.Join(local, "acrobat.intunewin")
+	if err := packager.WriteLicense(packager.LicensePath(acrobat), &packager.License{Type: "per-user", ID: "LIC-0042", Seats: 50}); err != nil {
+		t.Fatalf("WriteLicense() error = %v", err)
+	}
+
+	if _, err := Publish(ctx, local, store, syncOptions(DefaultKeep)); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	index, _, err := ReadIndex(ctx, store)
+	if err != nil {
+		t.Fatalf("ReadIndex() error = %v", err)
+	}
+	for _, e := range index.Packages {
+		switch e.Name {
+		case "acrobat.intunewin":
+			if e.License == nil || e.License.ID != "LIC-0042" || e.License.Seats != 50 {
+				t.Errorf("Acrobat license = %+v, want LIC-0042 with 50 seats", e.License)
+			}
+		case "7zip.intunewin":
+			if e.License != nil {
+				t.Errorf("7zip license = %+v, want none", e.License)
+			}
+		}
+	}
+
+	// More seats bought: the entry is updated without uploading the package again
+	if err := packager.WriteLicense(packager.LicensePath(acrobat), &packager.License{Type: "per-user", ID: "LIC-0042", Seats: 75}); err != nil {
+		t.Fatalf("WriteLicense() error = %v", err)
+	}
+	report, err := Publish(ctx, local, store, syncOptions(DefaultKeep))
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if len(report.Uploaded) != 0 || len(report.Relicensed) != 1 || len(report.Unchanged) != 1 {
+		t.Fatalf("Sync: uploaded %d, relicensed %d, unchanged %d; want 0, 1 and 1",
+			len(report.Uploaded), len(report.Relicensed), len(report.Unchanged))
+	}
+	index, _, err = ReadIndex(ctx, store)
+	if err != nil {
+		t.Fatalf("ReadIndex() error = %v", err)
+	}
+	if len(index.Packages) != 2 {
+		t.Fatalf("Index has %d entries, want 2", len(index.Packages))
+	}
+	for _, e := range index.Packages {
+		if e.Name == "acrobat.intunewin" && (e.License == nil || e.License.Seats != 75) {
+			t.Errorf("Acrobat license = %+v, want 75 seats", e.License)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/azblob"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/s3"
+)
+
+var (
+	// ErrNotFound is returned when a remote object does not exist
+	ErrNotFound = errors.New("object not found")
+	// ErrConditionFailed is returned when the remote index changed since it was read
+	ErrConditionFailed = errors.New("object was changed by another writer")
+)
+
+// Store is a remote package repository
+// Object names use forward slashes and are relative to the repository root
+type Store interface {
+	// Get returns an object and a tag identifying its current version
+	Get(ctx context.Context, name string) (data []byte, tag string, err error)
+	// Put replaces a small object only if it still has the version tag ifMatch;
+	// an empty ifMatch requires that the object does not exist
+	Put(ctx context.Context, name string, data []byte, ifMatch string) error
+	// Upload copies a local file to an object, replacing it if it exists
+	Upload(ctx context.Context, name, localPath string) error
+	// Delete removes an object; deleting a missing object is not an error
+	Delete(ctx context.Context, name string) error
+	// String describes the repository for messages, without credentials
+	String() string
+}
+
+// OpenStore returns the store for a destination, selected by its URL scheme:
+//   - https://<account>.blob.core.windows.net/<container>[/<folder>]?<SAS token>: Azure Blob
+//   - azblob://<account>/<container>[/<folder>][?client_id=<id>]: Azure Blob with the managed identity
+//   - s3://<bucket>[/<prefix>][?endpoint=<url>&region=<region>]: S3-compatible storage
+//   - smb://<server>/<share>[/<path>] (Windows only), file:///<path> or a plain folder path
+//
+// Blob and S3 requests are sent through transport (nil: http.DefaultTransport)
+func OpenStore(dest string, transport http.RoundTripper) (Store, error) {
+	scheme, _, hasScheme := strings.Cut(dest, "://")
+	if !hasScheme {
+		return openDirStore(dest)
+	}
+
+	u, err := url.Parse(dest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository URL: %w", err)
+	}
+	switch strings.ToLower(scheme) {
+	case "https", "http":
+		if u.RawQuery == "" {
+			return nil, fmt.Errorf("blob container URL has no SAS token: %s (use azblob:// for a managed identity)", dest)
+		}
+		return &BlobStore{ContainerURL: dest, client: azblob.NewClient(transport)}, nil
+	case "azblob":
+		return openManagedIdentityStore(u, transport)
+	case "s3":
+		return openS3Store(u, transport)
+	case "smb":
+		return openShareStore(u)
+	case "file":
+		if u.Host != "" && u.Host != "localhost" {
+			return openShareStore(u)
+		}
+		return openDirStore(fileURLPath(u.Path))
+	default:
+		return nil, fmt.Errorf("unsupported repository URL scheme: %s (supported: https, azblob, s3, smb, file)", scheme)
+	}
+}
+
+// openDirStore opens a repository in an existing folder
+func openDirStore(dir string) (Store, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("repository is not a folder: %s", dir)
+	}
+	return &DirStore{Root: dir}, nil
+}
+
+// openShareStore opens an smb:// (or file://server/) repository through its UNC path,
+// which only Windows resolves
+func openShareStore(u *url.URL) (Store, error) {
+	share := `\\` + u.Host + filepath.FromSlash(u.Path)
+	if runtime.GOOS != "windows" {
+		return nil, fmt.Errorf("%s://%s%s: SMB shares are opened by UNC path on Windows only; mount the share and use its local path", u.Scheme, u.Host, u.Path)
+	}
+	return openDirStore(share)
+}
+
+// fileURLPath returns the local path of a file:// URL path, dropping the slash before
+// a Windows drive letter (file:///C:/catalog)
+func fileURLPath(p string) string {
+	if len(p) >= 3 && p[0] == '/' && p[2] == ':' {
+		p = p[1:]
+	}
+	return filepath.FromSlash(p)
+}
+
+// openManagedIdentityStore opens an azblob:// repository; the account is either an
+// account name or the full host name of an account in another cloud
+func openManagedIdentityStore(u *url.URL, transport http.RoundTripper) (Store, error) {
+	host := u.Host
+	if host == "" || strings.Trim(u.Path, "/") == "" {
+		return nil, fmt.Errorf("azblob repository needs an account and a container: azblob://<account>/<container>")
+	}
+	if !strings.Contains(host, ".") {
+		host += ".blob.core.windows.net"
+	}
+	container := url.URL{Scheme: "https", Host: host, Path: u.Path}
+	tokens := azblob.NewManagedIdentity(u.Query().Get("client_id"), transport)
+	return &BlobStore{ContainerURL: container.String(), client: azblob.NewTokenClient(tokens, transport)}, nil
+}
+
+// openS3Store opens an s3:// repository with credentials, region and endpoint from the
+// standard AWS environment variables, or the endpoint and region of the URL
+func openS3Store(u *url.URL, transport http.RoundTripper) (Store, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("s3 repository needs a bucket: s3://<bucket>[/<prefix>]")
+	}
+	creds, err := s3.CredentialsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	region := u.Query().Get("region")
+	if region == "" {
+		region = s3.RegionFromEnv()
+	}
+	endpoint := u.Query().Get("endpoint")
+	if endpoint == "" {
+		endpoint = s3.EndpointFromEnv(region)
+	}
+	client, err := s3.NewClient(endpoint, region, creds, transport)
+	if err != nil {
+		return nil, err
+	}
+	return &S3Store{Bucket: u.Host, Prefix: strings.Trim(u.Path, "/"), client: client}, nil
+}
+
+// DirStore is a repository in a folder, typically on an SMB share
+type DirStore struct {
+	Root string
+}
+
+func (s *DirStore) path(name string) string {
+	return filepath.Join(s.Root, filepath.FromSlash(name))
+}
+
+// Get reads an object; its tag is the SHA256 of the content
+func (s *DirStore) Get(ctx context.Context, name string) ([]byte, string, error) {
+	data, err := os.ReadFile(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return data, contentTag(data), nil
+}
+
+// Put checks the current version and replaces the object with a rename, so readers
+// never see a partially written file
+// The check and the rename are not atomic together; file shares offer no
+// compare-and-swap, so two publishes racing within that window can still collide
+func (s *DirStore) Put(ctx context.Context, name string, data []byte, ifMatch string) error {
+	_, tag, err := s.Get(ctx, name)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		if ifMatch != "" {
+			return ErrConditionFailed
+		}
+	case err != nil:
+		return err
+	case tag != ifMatch:
+		return ErrConditionFailed
+	}
+	return s.replace(name, bytes.NewReader(data))
+}
+
+// Upload copies a local file into the repository
+func (s *DirStore) Upload(ctx context.Context, name, localPath string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", localPath, err)
+	}
+	defer file.Close()
+	return s.replace(name, file)
+}
+
+// replace writes an object through a temporary file in the same folder
+func (s *DirStore) replace(name string, r io.Reader) error {
+	target := s.path(name)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create folder for %s: %w", name, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".publish-*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// Delete removes an object and its folder once empty
+func (s *DirStore) Delete(ctx context.Context, name string) error {
+	target := s.path(name)
+	if err := os.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete %s: %w", name, err)
+	}
+	// Package folders hold a single file; a non-empty folder is left alone
+	os.Remove(filepath.Dir(target))
+	return nil
+}
+
+func (s *DirStore) String() string {
+	return s.Root
+}
+
+// BlobStore is a repository in an Azure Blob container (or a virtual folder of one)
+type BlobStore struct {
+	// ContainerURL includes a SAS token with read, write, delete and list permissions
+	ContainerURL string
+	client       *azblob.Client
+}
+
+func (s *BlobStore) blobURL(name string) (string, error) {
+	return azblob.BlobURL(s.ContainerURL, name)
+}
+
+// Get downloads an object; its tag is the blob ETag
+func (s *BlobStore) Get(ctx context.Context, name string) ([]byte, string, error) {
+	target, err := s.blobURL(name)
+	if err != nil {
+		return nil, "", err
+	}
+	data, etag, err := s.client.Get(ctx, target)
+	if errors.Is(err, azblob.ErrNotFound) {
+		return nil, "", ErrNotFound
+	}
+	return data, etag, err
+}
+
+// Put writes an object with an ETag precondition, which the Blob service enforces atomically
+func (s *BlobStore) Put(ctx context.Context, name string, data []byte, ifMatch string) error {
+	target, err := s.blobURL(name)
+	if err != nil {
+		return err
+	}
+	err = s.client.Put(ctx, target, data, "application/json", ifMatch)
+	if errors.Is(err, azblob.ErrConditionFailed) {
+		return ErrConditionFailed
+	}
+	return err
+}
+
+// Upload sends a local file as a block blob
+func (s *BlobStore) Upload(ctx context.Context, name, localPath string) error {
+	target, err := s.blobURL(name)
+	if err != nil {
+		return err
+	}
+	return s.client.UploadFile(ctx, target, localPath)
+}
+
+// Delete removes a blob
+func (s *BlobStore) Delete(ctx context.Context, name string) error {
+	target, err := s.blobURL(name)
+	if err != nil {
+		return err
+	}
+	return s.client.Delete(ctx, target)
+}
+
+// String returns the container URL without its SAS token
+func (s *BlobStore) String() string {
+	if i := strings.IndexByte(s.ContainerURL, '?'); i >= 0 {
+		return s.ContainerURL[:i]
+	}
+	return s.ContainerURL
+}
+
+// S3Store is a repository in a bucket (or a prefix of one) of S3-compatible storage
+type S3Store struct {
+	Bucket string
+	Prefix string
+	client *s3.Client
+}
+
+func (s *S3Store) key(name string) string {
+	if s.Prefix == "" {
+		return name
+	}
+	return s.Prefix + "/" + name
+}
+
+// Get downloads an object; its tag is the object ETag
+func (s *S3Store) Get(ctx context.Context, name string) ([]byte, string, error) {
+	data, etag, err := s.client.Get(ctx, s.Bucket, s.key(name))
+	if errors.Is(err, s3.ErrNotFound) {
+		return nil, "", ErrNotFound
+	}
+	return data, etag, err
+}
+
+// Put writes an object with an ETag precondition (conditional writes of S3 and most
+// compatible stores)
+func (s *S3Store) Put(ctx context.Context, name string, data []byte, ifMatch string) error {
+	err := s.client.Put(ctx, s.Bucket, s.key(name), data, "application/json", ifMatch)
+	if errors.Is(err, s3.ErrConditionFailed) {
+		return ErrConditionFailed
+	}
+	return err
+}
+
+// Upload sends a local file, in parts when it is large
+func (s *S3Store) Upload(ctx context.Context, name, localPath string) error {
+	return s.client.UploadFile(ctx, s.Bucket, s.key(name), localPath)
+}
+
+// Delete removes an object
+func (s *S3Store) Delete(ctx context.Context, name string) error {
+	return s.client.Delete(ctx, s.Bucket, s.key(name))
+}
+
+// String returns the s3:// URL of the repository
+func (s *S3Store) String() string {
+	return strings.TrimSuffix("s3://"+s.Bucket+"/"+s.Prefix, "/")
+}
+
+// contentTag identifies a version of an object by its content
+func contentTag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
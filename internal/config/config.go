@@ -0,0 +1,222 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFileName is a config file looked up in the current directory,
+// so a team can share profiles in their packaging repository
+const ProjectFileName = ".intunewin.yaml"
+
+// DefaultProfileName is the profile created when settings are saved without a selected profile
+const DefaultProfileName = "default"
+
+// Config is the content of a config file
+type Config struct {
+	// DefaultProfile is used when no profile is selected
+	DefaultProfile string `yaml:"defaultProfile,omitempty"`
+	// Profiles maps profile names to their settings
+	Profiles map[string]Profile `yaml:"profiles,omitempty"`
+	// TUI holds preferences of the interactive mode
+	TUI TUISettings `yaml:"tui,omitempty"`
+}
+
+// Profile holds default settings; command-line flags and environment variables take precedence
+// Client secrets are deliberately not supported, use AZURE_CLIENT_SECRET instead
+type Profile struct {
+	TenantID    string   `yaml:"tenantId,omitempty"`
+	ClientID    string   `yaml:"clientId,omitempty"`
+	Cloud       string   `yaml:"cloud,omitempty"` // Azure cloud of the tenant: public (default), usgovhigh, dod or china
+	Output      string   `yaml:"output,omitempty"`
+	Exclude     []string `yaml:"exclude,omitempty"`
+	ToolVersion string   `yaml:"toolVersion,omitempty"`
+	// MsiExecutionContext and MsiRequiresReboot override the MsiInfo of Detection.xml
+	MsiExecutionContext string `yaml:"msiExecutionContext,omitempty"`
+	MsiRequiresReboot   *bool  `yaml:"msiRequiresReboot,omitempty"`
+	// Vars are the template variables stamped into scripts of the package, e.g. the
+	// tenant name of the environment the profile packages for
+	Vars map[string]string `yaml:"vars,omitempty"`
+	// ReturnCodes map installer exit codes to the result Intune reports (success,
+	// softReboot, hardReboot, retry or failed) for apps created with this profile
+	ReturnCodes map[int]string `yaml:"returnCodes,omitempty"`
+	// PreHooks and PostHooks are shell commands run before and after each packaging run,
+	// e.g. a signing script or a CMDB update; --pre-hook and --post-hook replace them
+	PreHooks  []string `yaml:"preHooks,omitempty"`
+	PostHooks []string `yaml:"postHooks,omitempty"`
+	// Scan checks setup files for malware before they are packaged; --scan and
+	// --scan-max-detections win over it
+	Scan *ScanSettings `yaml:"scan,omitempty"`
+	// Flags are command-line flags by name (without dashes), applied unless given on
+	// the command line; lists set repeatable flags, e.g. low-memory: true, exclude: ["*.log"]
+	Flags map[string]any `yaml:"flags,omitempty"`
+}
+
+// FlagValues returns the flags of the profile as command-line values, one per
+// occurrence of the flag
+func (p *Profile) FlagValues() (map[string][]string, error) {
+	values := make(map[string][]string, len(p.Flags))
+	for name, value := range p.Flags {
+		switch v := value.(type) {
+		case nil:
+			return nil, fmt.Errorf("flag %s of the profile has no value", name)
+		case []any:
+			for _, item := range v {
+				s, err := flagScalar(name, item)
+				if err != nil {
+					return nil, err
+				}
+				values[name] = append(values[name], s)
+			}
+		default:
+			s, err := flagScalar(name, v)
+			if err != nil {
+				return nil, err
+			}
+			values[name] = []string{s}
+		}
+	}
+	return values, nil
+}
+
+// flagScalar formats a YAML scalar as a flag value
+func flagScalar(name string, value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool, int, float64:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("flag %s of the profile must be a string, number, boolean or list of them", name)
+	}
+}
+
+// ScanSettings configure the malware scan of setup files before packaging
+type ScanSettings struct {
+	// Scanner is virustotal or defender
+	Scanner string `yaml:"scanner,omitempty"`
+	// VirusTotalAPIKey is the API key of VirusTotal lookups; a secretref: keeps it out of
+	// the file, e.g. secretref:env:VT_API_KEY
+	VirusTotalAPIKey string `yaml:"virusTotalApiKey,omitempty"`
+	// MaxDetections is the number of detections allowed before packaging is blocked
+	MaxDetections int `yaml:"maxDetections,omitempty"`
+}
+
+// TUISettings are preferences of the interactive mode, edited on its settings screen
+type TUISettings struct {
+	// Theme is the color theme (default, high-contrast or plain)
+	Theme string `yaml:"theme,omitempty"`
+	// OpenOutput opens the output folder in the file manager after a package is created
+	OpenOutput bool `yaml:"openOutput,omitempty"`
+	// Upload offers to create the app in Intune and upload a package after it is created
+	Upload bool `yaml:"upload,omitempty"`
+	// Keys binds actions of the interactive mode to other keys, e.g. browse: [alt+o, f3]
+	Keys map[string][]string `yaml:"keys,omitempty"`
+}
+
+// DefaultPath returns the per-user config file (~/.config/intunewin/config.yaml on Linux)
+func DefaultPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(configDir, "intunewin", "config.yaml"), nil
+}
+
+// FindPath returns the config file to use: the project file in the current
+// directory if present, otherwise the per-user config file
+func FindPath() (string, error) {
+	if _, err := os.Stat(ProjectFileName); err == nil {
+		return ProjectFileName, nil
+	}
+	return DefaultPath()
+}
+
+// Load reads a config file; a missing file yields an empty config
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Save writes the config file, creating its directory if needed
+// Comments in an existing file are not preserved
+func (c *Config) Save(path string) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	data := buf.Bytes()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
+
+// ProfileName returns the profile that name selects: name itself, or the default profile
+func (c *Config) ProfileName(name string) string {
+	if name == "" {
+		return c.DefaultProfile
+	}
+	return name
+}
+
+// SetProfile stores a profile under the name selected by name
+// Without a name or default profile, DefaultProfileName is created and made the default
+func (c *Config) SetProfile(name string, profile Profile) {
+	name = c.ProfileName(name)
+	if name == "" {
+		name = DefaultProfileName
+		c.DefaultProfile = name
+	}
+	if c.Profiles == nil {
+		c.Profiles = make(map[string]Profile)
+	}
+	c.Profiles[name] = profile
+}
+
+// Profile returns the named profile, or the default profile if name is empty
+// Without a name or default profile, an empty profile is returned
+func (c *Config) Profile(name string) (*Profile, error) {
+	name = c.ProfileName(name)
+	if name == "" {
+		return &Profile{}, nil
+	}
+
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile not found: %s (available: %v)", name, c.ProfileNames())
+	}
+	return &profile, nil
+}
+
+// ProfileNames returns the names of all profiles, sorted
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
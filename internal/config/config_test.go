@@ -0,0 +1,178 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadProfiles(t *testing.T) {
+	dir, err := os.MkdirTemp("", "config")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	content := `
+defaultProfile: contoso
+profiles:
+  contoso:
+    tenantId: contoso-tenant
+    clientId: contoso-client
+    output: /packages
+    exclude: ["*.log", ".git"]
+  fabrikam:
+    tenantId: fabrikam-tenant
+    toolVersion: 1.8.4.0
+    vars:
+      TENANT_NAME: Fabrikam
+    returnCodes:
+      1618: retry
+      3010: success
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	profile, err := cfg.Profile("")
+	if err != nil {
+		t.Fatalf("Profile() error = %v", err)
+	}
+	if profile.TenantID != "contoso-tenant" || profile.Output != "/packages" || len(profile.Exclude) != 2 {
+		t.Errorf("Default profile = %+v, want contoso", profile)
+	}
+
+	profile, err = cfg.Profile("fabrikam")
+	if err != nil {
+		t.Fatalf("Profile() error = %v", err)
+	}
+	if profile.ToolVersion != "1.8.4.0" {
+		t.Errorf("ToolVersion = %s, want 1.8.4.0", profile.ToolVersion)
+	}
+	if profile.Vars["TENANT_NAME"] != "Fabrikam" {
+		t.Errorf("Vars = %v, want TENANT_NAME Fabrikam", profile.Vars)
+	}
+	if profile.ReturnCodes[1618] != "retry" || profile.ReturnCodes[3010] != "success" {
+		t.Errorf("ReturnCodes = %v", profile.ReturnCodes)
+	}
+
+	if _, err := cfg.Profile("missing"); err == nil {
+		t.Error("Expected error for unknown profile")
+	}
+}
+
+func TestProfileFlagValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+profiles:
+  big-app:
+    flags:
+      low-memory: true
+      workers: 4
+      exclude: ["*.log", .git]
+      log-level: debug
+  broken:
+    flags:
+      exclude: {pattern: "*.log"}
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	profile, err := cfg.Profile("big-app")
+	if err != nil {
+		t.Fatalf("Profile() error = %v", err)
+	}
+	values, err := profile.FlagValues()
+	if err != nil {
+		t.Fatalf("FlagValues() error = %v", err)
+	}
+	want := map[string][]string{
+		"low-memory": {"true"},
+		"workers":    {"4"},
+		"exclude":    {"*.log", ".git"},
+		"log-level":  {"debug"},
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("FlagValues() = %v, want %v", values, want)
+	}
+
+	profile, err = cfg.Profile("broken")
+	if err != nil {
+		t.Fatalf("Profile() error = %v", err)
+	}
+	if _, err := profile.FlagValues(); err == nil {
+		t.Error("Expected error for a map flag value")
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	cfg, err := Load(filepath.Join(os.TempDir(), "does-not-exist", "config.yaml"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	profile, err := cfg.Profile("")
+	if err != nil {
+		t.Fatalf("Profile() error = %v", err)
+	}
+	if profile.TenantID != "" {
+		t.Errorf("Profile = %+v, want empty", profile)
+	}
+}
+
+func TestSaveSettings(t *testing.T) {
+	dir, err := os.MkdirTemp("", "config")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "intunewin", "config.yaml")
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// Without a selected profile, settings go to a new default profile
+	cfg.SetProfile("", Profile{Output: `\\server\packages`, Exclude: []string{"*.log"}})
+	cfg.TUI = TUISettings{Theme: "plain", OpenOutput: true, Upload: true, Keys: map[string][]string{"browse": {"alt+o", "f3"}}}
+	if err := cfg.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if loaded.DefaultProfile != DefaultProfileName {
+		t.Errorf("DefaultProfile = %q, want %q", loaded.DefaultProfile, DefaultProfileName)
+	}
+	profile, err := loaded.Profile("")
+	if err != nil {
+		t.Fatalf("Profile() error = %v", err)
+	}
+	if profile.Output != `\\server\packages` || len(profile.Exclude) != 1 {
+		t.Errorf("Profile = %+v", profile)
+	}
+	if loaded.TUI.Theme != "plain" || !loaded.TUI.OpenOutput || !loaded.TUI.Upload || len(loaded.TUI.Keys["browse"]) != 2 {
+		t.Errorf("TUI settings = %+v", loaded.TUI)
+	}
+
+	// A selected profile is updated in place
+	loaded.Profiles["contoso"] = Profile{TenantID: "contoso-tenant"}
+	loaded.SetProfile("contoso", Profile{TenantID: "contoso-tenant", Output: "/out"})
+	if loaded.Profiles["contoso"].Output != "/out" || loaded.DefaultProfile != DefaultProfileName {
+		t.Errorf("SetProfile(contoso) changed the wrong profile: %+v", loaded)
+	}
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxCrashReports is the number of crash reports kept in the crash folder
+const MaxCrashReports = 10
+
+// CrashReport describes an unexpected panic, for attaching to a bug report
+type CrashReport struct {
+	Version string
+	Time    time.Time
+	Args    []string // Command line, with secrets redacted by the caller
+	Panic   string
+	Stack   []byte
+}
+
+// DefaultCrashDir returns the per-user crash report folder (~/.config/intunewin/crashes on Linux)
+func DefaultCrashDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(configDir, "intunewin", "crashes"), nil
+}
+
+// WriteCrashReport writes a report to a new file in dir and returns its path
+// The oldest reports are removed beyond MaxCrashReports
+func WriteCrashReport(dir string, report CrashReport) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create crash directory: %w", err)
+	}
+
+	f, err := os.CreateTemp(dir, "crash-"+report.Time.UTC().Format("20060102-150405")+"-*.txt")
+	if err != nil {
+		return "", fmt.Errorf("failed to create crash report: %w", err)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "LetsGoIntunePackager crash report\n\n")
+	fmt.Fprintf(&b, "Version:  %s\n", report.Version)
+	fmt.Fprintf(&b, "Time:     %s\n", report.Time.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "Platform: %s/%s (%s)\n", runtime.GOOS, runtime.GOARCH, runtime.Version())
+	fmt.Fprintf(&b, "Command:  %s\n", strings.Join(report.Args, " "))
+	fmt.Fprintf(&b, "Panic:    %s\n\n", report.Panic)
+	b.Write(report.Stack)
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to write crash report: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to write crash report: %w", err)
+	}
+
+	pruneCrashReports(dir)
+	return f.Name(), nil
+}
+
+// pruneCrashReports removes the oldest reports beyond MaxCrashReports
+// Names start with the UTC time, so they sort oldest first
+func pruneCrashReports(dir string) {
+	reports, err := filepath.Glob(filepath.Join(dir, "crash-*.txt"))
+	if err != nil || len(reports) <= MaxCrashReports {
+		return
+	}
+	sort.Strings(reports)
+	for _, path := range reports[:len(reports)-MaxCrashReports] {
+		os.Remove(path)
+	}
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteCrashReport(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "crashes")
+	report := CrashReport{
+		Version: "1.2.3",
+		Time:    time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+		Args:    []string{"intunewin", "-c", "./7zip", "-q"},
+		Panic:   "runtime error: index out of range [3] with length 3",
+		Stack:   []byte("goroutine 1 [running]:\nmain.main()\n"),
+	}
+
+	path, err := WriteCrashReport(dir, report)
+	if err != nil {
+		t.Fatalf("WriteCrashReport() error = %v", err)
+	}
+	if filepath.Dir(path) != dir || !strings.HasPrefix(filepath.Base(path), "crash-20240501-123000-") {
+		t.Errorf("Unexpected report path %s", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	for _, want := range []string{
+		"Version:  1.2.3",
+		"Time:     2024-05-01T12:30:00Z",
+		"Command:  intunewin -c ./7zip -q",
+		"Panic:    runtime error: index out of range [3] with length 3",
+		"goroutine 1 [running]:",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Report does not contain %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestWriteCrashReportPrunes(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	var last string
+	for i := 0; i < MaxCrashReports+3; i++ {
+		path, err := WriteCrashReport(dir, CrashReport{Time: start.Add(time.Duration(i) * time.Minute), Panic: "boom"})
+		if err != nil {
+			t.Fatalf("WriteCrashReport() error = %v", err)
+		}
+		last = path
+	}
+
+	reports, err := filepath.Glob(filepath.Join(dir, "crash-*.txt"))
+	if err != nil {
+		t.Fatalf("Failed to list reports: %v", err)
+	}
+	if len(reports) != MaxCrashReports {
+		t.Errorf("Expected %d reports, got %d", MaxCrashReports, len(reports))
+	}
+	if _, err := os.Stat(last); err != nil {
+		t.Errorf("Expected the newest report to be kept: %v", err)
+	}
+	if oldest, _ := filepath.Glob(filepath.Join(dir, "crash-20240501-000000-*.txt")); len(oldest) != 0 {
+		t.Errorf("Expected the oldest report to be removed, found %v", oldest)
+	}
+}
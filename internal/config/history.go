@@ -0,0 +1,100 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MaxHistoryEntries is the number of packaging jobs kept in the history file
+const MaxHistoryEntries = 20
+
+// HistoryEntry records one packaging job started from the TUI or the dashboard of serve
+type HistoryEntry struct {
+	SourcePath string    `json:"sourcePath"`
+	SetupFile  string    `json:"setupFile"`
+	OutputPath string    `json:"outputPath"`
+	Succeeded  bool      `json:"succeeded"`
+	Package    string    `json:"package,omitempty"` // Created .intunewin file
+	Error      string    `json:"error,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+	// GeneratorVersion is the package generation logic that built Package
+	GeneratorVersion int `json:"generatorVersion,omitempty"`
+}
+
+// SameJob reports whether both entries package the same setup file to the same output folder
+func (e HistoryEntry) SameJob(other HistoryEntry) bool {
+	return e.SourcePath == other.SourcePath && e.SetupFile == other.SetupFile && e.OutputPath == other.OutputPath
+}
+
+// History is the list of recent packaging jobs, newest first
+type History struct {
+	Entries []HistoryEntry `json:"entries"`
+}
+
+// DefaultHistoryPath returns the per-user history file (~/.config/intunewin/history.json on Linux)
+func DefaultHistoryPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(configDir, "intunewin", "history.json"), nil
+}
+
+// LoadHistory reads a history file; a missing file yields an empty history
+func LoadHistory(path string) (*History, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &History{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	var history History
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("failed to parse history %s: %w", path, err)
+	}
+	return &history, nil
+}
+
+// Add records a job as the most recent entry
+// An earlier entry for the same job is replaced, and the oldest entries are dropped
+// beyond MaxHistoryEntries
+func (h *History) Add(entry HistoryEntry) {
+	entries := []HistoryEntry{entry}
+	for _, existing := range h.Entries {
+		if !existing.SameJob(entry) {
+			entries = append(entries, existing)
+		}
+	}
+	if len(entries) > MaxHistoryEntries {
+		entries = entries[:MaxHistoryEntries]
+	}
+	h.Entries = entries
+}
+
+// Save writes the history file, creating its directory if needed
+// The file is replaced atomically so a crash never leaves it truncated
+func (h *History) Save(path string) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode history: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadHistoryMissingFile(t *testing.T) {
+	history, err := LoadHistory(filepath.Join(os.TempDir(), "does-not-exist", "history.json"))
+	if err != nil {
+		t.Fatalf("LoadHistory() error = %v", err)
+	}
+	if len(history.Entries) != 0 {
+		t.Errorf("Expected empty history, got %d entries", len(history.Entries))
+	}
+}
+
+func TestHistoryAdd(t *testing.T) {
+	var history History
+	job := HistoryEntry{SourcePath: `\\server\apps\7zip`, SetupFile: "7z.msi", OutputPath: `\\server\packages`}
+
+	first := job
+	first.Error = "access denied"
+	history.Add(first)
+	history.Add(HistoryEntry{SourcePath: "/src/other", SetupFile: "setup.exe", OutputPath: "/out"})
+
+	second := job
+	second.Succeeded = true
+	history.Add(second)
+
+	if len(history.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(history.Entries))
+	}
+	if !history.Entries[0].SameJob(job) || !history.Entries[0].Succeeded {
+		t.Errorf("Expected the re-run job first with its latest result, got %+v", history.Entries[0])
+	}
+	if history.Entries[1].SourcePath != "/src/other" {
+		t.Errorf("Expected the other job second, got %+v", history.Entries[1])
+	}
+}
+
+func TestHistoryAddLimit(t *testing.T) {
+	var history History
+	for i := 0; i < MaxHistoryEntries+5; i++ {
+		history.Add(HistoryEntry{SourcePath: fmt.Sprintf("/src/%d", i), SetupFile: "setup.msi", OutputPath: "/out"})
+	}
+
+	if len(history.Entries) != MaxHistoryEntries {
+		t.Fatalf("Expected %d entries, got %d", MaxHistoryEntries, len(history.Entries))
+	}
+	want := fmt.Sprintf("/src/%d", MaxHistoryEntries+4)
+	if history.Entries[0].SourcePath != want {
+		t.Errorf("Newest entry = %s, want %s", history.Entries[0].SourcePath, want)
+	}
+}
+
+func TestHistorySaveLoad(t *testing.T) {
+	dir, err := os.MkdirTemp("", "history")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "intunewin", "history.json")
+	timestamp := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+
+	var history History
+	history.Add(HistoryEntry{
+		SourcePath: `\\fileserver\software\Contoso\App`,
+		SetupFile:  "setup.msi",
+		OutputPath: `\\fileserver\intune`,
+		Succeeded:  true,
+		Package:    `\\fileserver\intune\setup.intunewin`,
+		Timestamp:  timestamp,
+	})
+	if err := history.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := LoadHistory(path)
+	if err != nil {
+		t.Fatalf("LoadHistory() error = %v", err)
+	}
+	if len(loaded.Entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(loaded.Entries))
+	}
+	got := loaded.Entries[0]
+	if got.SourcePath != `\\fileserver\software\Contoso\App` || !got.Succeeded || !got.Timestamp.Equal(timestamp) {
+		t.Errorf("Loaded entry = %+v", got)
+	}
+}
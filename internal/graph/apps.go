@@ -0,0 +1,106 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// win32AppFilter restricts mobile app queries to Win32 apps
+const win32AppFilter = "isof('microsoft.graph.win32LobApp')"
+
+// App is a Win32 app in Intune
+type App struct {
+	ID              string    `json:"id"`
+	DisplayName     string    `json:"displayName"`
+	DisplayVersion  string    `json:"displayVersion,omitempty"`
+	Publisher       string    `json:"publisher,omitempty"`
+	CreatedDateTime time.Time `json:"createdDateTime"`
+
+	// MsiInformation is set for apps packaged from an MSI
+	MsiInformation *MsiInformation `json:"msiInformation,omitempty"`
+	// Assignments is only populated by ListApps
+	Assignments []struct {
+		ID string `json:"id"`
+	} `json:"assignments,omitempty"`
+}
+
+// MsiInformation is the MSI metadata of a Win32 app
+type MsiInformation struct {
+	ProductCode    string `json:"productCode"`
+	ProductVersion string `json:"productVersion,omitempty"`
+	UpgradeCode    string `json:"upgradeCode,omitempty"`
+}
+
+// ProductCode returns the MSI product code of the app, or "" for non-MSI apps
+func (a App) ProductCode() string {
+	if a.MsiInformation == nil {
+		return ""
+	}
+	return a.MsiInformation.ProductCode
+}
+
+// ListApps returns Win32 apps whose display name contains filter (all apps if empty),
+// including their assignments
+func (c *Client) ListApps(ctx context.Context, filter string) ([]App, error) {
+	expr := win32AppFilter
+	if filter != "" {
+		expr += " and contains(displayName, " + odataQuote(filter) + ")"
+	}
+
+	query := url.Values{}
+	query.Set("$filter", expr)
+	query.Set("$expand", "assignments")
+	path := "/deviceAppManagement/mobileApps?" + query.Encode()
+
+	var apps []App
+	for path != "" {
+		var resp struct {
+			Value    []App  `json:"value"`
+			NextLink string `json:"@odata.nextLink"`
+		}
+		if err := c.do(ctx, "GET", path, nil, &resp); err != nil {
+			return nil, fmt.Errorf("failed to list apps: %w", err)
+		}
+		apps = append(apps, resp.Value...)
+		path = strings.TrimPrefix(resp.NextLink, c.baseURL)
+	}
+	return apps, nil
+}
+
+// FindAppsByName returns Win32 apps whose display name equals name
+func (c *Client) FindAppsByName(ctx context.Context, name string) ([]App, error) {
+	query := url.Values{}
+	query.Set("$filter", win32AppFilter+" and displayName eq "+odataQuote(name))
+
+	var resp struct {
+		Value []App `json:"value"`
+	}
+	if err := c.do(ctx, "GET", "/deviceAppManagement/mobileApps?"+query.Encode(), nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to look up app %q: %w", name, err)
+	}
+	return resp.Value, nil
+}
+
+// ResolveAppID returns the ID of a Win32 app given its ID or display name
+func (c *Client) ResolveAppID(ctx context.Context, nameOrID string) (string, error) {
+	if isGUID(nameOrID) {
+		return nameOrID, nil
+	}
+
+	apps, err := c.FindAppsByName(ctx, nameOrID)
+	if err != nil {
+		return "", err
+	}
+
+	switch len(apps) {
+	case 0:
+		return "", fmt.Errorf("app not found: %s", nameOrID)
+	case 1:
+		return apps[0].ID, nil
+	default:
+		return "", fmt.Errorf("app name %q is ambiguous (%d matches), use the app ID instead", nameOrID, len(apps))
+	}
+}
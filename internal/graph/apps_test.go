@@ -0,0 +1,53 @@
+package graph
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestListApps(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handleToken(w, r) {
+			return
+		}
+		if r.URL.Query().Get("page") == "2" {
+			w.Write([]byte(`{"value":[{"id":"b","displayName":"7-Zip 23.01"}]}`))
+			return
+		}
+
+		filter := r.URL.Query().Get("$filter")
+		if !strings.Contains(filter, "contains(displayName, '7-Zip')") {
+			t.Errorf("$filter = %s, want displayName contains clause", filter)
+		}
+		if r.URL.Query().Get("$expand") != "assignments" {
+			t.Errorf("$expand = %s, want assignments", r.URL.Query().Get("$expand"))
+		}
+		w.Write([]byte(`{
+			"value":[{"id":"a","displayName":"7-Zip","displayVersion":"24.01",
+				"msiInformation":{"productCode":"{23170F69-40C1-2702-2401-000001000000}"},
+				"assignments":[{"id":"x"},{"id":"y"}]}],
+			"@odata.nextLink":"` + server.URL + `/beta/deviceAppManagement/mobileApps?page=2"}`))
+	}))
+	defer server.Close()
+
+	apps, err := newTestClient(server).ListApps(context.Background(), "7-Zip")
+	if err != nil {
+		t.Fatalf("ListApps() error = %v", err)
+	}
+	if len(apps) != 2 {
+		t.Fatalf("ListApps() returned %d apps, want 2 (across pages)", len(apps))
+	}
+	if apps[0].ProductCode() != "{23170F69-40C1-2702-2401-000001000000}" {
+		t.Errorf("ProductCode() = %s", apps[0].ProductCode())
+	}
+	if len(apps[0].Assignments) != 2 {
+		t.Errorf("Assignments = %d, want 2", len(apps[0].Assignments))
+	}
+	if apps[1].ProductCode() != "" {
+		t.Errorf("ProductCode() = %s, want empty for non-MSI app", apps[1].ProductCode())
+	}
+}
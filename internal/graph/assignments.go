@@ -0,0 +1,139 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Intent is the install intent of a mobile app assignment
+type Intent string
+
+const (
+	IntentRequired  Intent = "required"
+	IntentAvailable Intent = "available"
+	IntentUninstall Intent = "uninstall"
+)
+
+// ParseIntent converts a user-supplied string into an Intent
+func ParseIntent(s string) (Intent, error) {
+	switch Intent(strings.ToLower(s)) {
+	case IntentRequired:
+		return IntentRequired, nil
+	case IntentAvailable:
+		return IntentAvailable, nil
+	case IntentUninstall:
+		return IntentUninstall, nil
+	default:
+		return "", fmt.Errorf("invalid intent: %s (supported: required, available, uninstall)", s)
+	}
+}
+
+// Assignment describes a group assignment to create for an app
+type Assignment struct {
+	// GroupID is the Azure AD group object ID
+	GroupID string `json:"groupId"`
+	// Intent is the install intent (required, available, uninstall)
+	Intent Intent `json:"intent"`
+	// Deadline is the optional install deadline (required assignments only)
+	Deadline *time.Time `json:"deadline,omitempty"`
+}
+
+// mobileAppAssignment is the Graph representation of an app assignment
+type mobileAppAssignment struct {
+	ODataType string                       `json:"@odata.type"`
+	ID        string                       `json:"id,omitempty"`
+	Intent    Intent                       `json:"intent"`
+	Target    assignmentTarget             `json:"target"`
+	Settings  *win32LobAppAssignmentConfig `json:"settings,omitempty"`
+}
+
+// assignmentTarget is the Graph group assignment target
+type assignmentTarget struct {
+	ODataType string `json:"@odata.type"`
+	GroupID   string `json:"groupId,omitempty"`
+}
+
+// win32LobAppAssignmentConfig holds Win32-specific assignment settings
+type win32LobAppAssignmentConfig struct {
+	ODataType                    string           `json:"@odata.type"`
+	Notifications                string           `json:"notifications"`
+	InstallTimeSettings          *installTimeJSON `json:"installTimeSettings,omitempty"`
+	DeliveryOptimizationPriority string           `json:"deliveryOptimizationPriority"`
+}
+
+// installTimeJSON holds the install deadline of a required assignment
+type installTimeJSON struct {
+	UseLocalTime     bool   `json:"useLocalTime"`
+	DeadlineDateTime string `json:"deadlineDateTime,omitempty"`
+}
+
+// ResolveGroupID returns the object ID of a group given its ID or display name
+func (c *Client) ResolveGroupID(ctx context.Context, nameOrID string) (string, error) {
+	if isGUID(nameOrID) {
+		return nameOrID, nil
+	}
+
+	query := url.Values{}
+	query.Set("$filter", "displayName eq "+odataQuote(nameOrID))
+	query.Set("$select", "id,displayName")
+
+	var resp struct {
+		Value []struct {
+			ID          string `json:"id"`
+			DisplayName string `json:"displayName"`
+		} `json:"value"`
+	}
+	if err := c.do(ctx, "GET", "/groups?"+query.Encode(), nil, &resp); err != nil {
+		return "", fmt.Errorf("failed to look up group %q: %w", nameOrID, err)
+	}
+
+	switch len(resp.Value) {
+	case 0:
+		return "", fmt.Errorf("group not found: %s", nameOrID)
+	case 1:
+		return resp.Value[0].ID, nil
+	default:
+		return "", fmt.Errorf("group name %q is ambiguous (%d matches), use the object ID instead", nameOrID, len(resp.Value))
+	}
+}
+
+// AssignApp creates one mobileAppAssignment per entry for the given app
+// Existing assignments of the app are left untouched
+func (c *Client) AssignApp(ctx context.Context, appID string, assignments []Assignment) error {
+	for _, a := range assignments {
+		if a.Deadline != nil && a.Intent != IntentRequired {
+			return fmt.Errorf("install deadline is only supported for required assignments")
+		}
+
+		settings := &win32LobAppAssignmentConfig{
+			ODataType:                    "#microsoft.graph.win32LobAppAssignmentSettings",
+			Notifications:                "showAll",
+			DeliveryOptimizationPriority: "notConfigured",
+		}
+		if a.Deadline != nil {
+			settings.InstallTimeSettings = &installTimeJSON{
+				UseLocalTime:     true,
+				DeadlineDateTime: a.Deadline.Format(time.RFC3339),
+			}
+		}
+
+		body := mobileAppAssignment{
+			ODataType: "#microsoft.graph.mobileAppAssignment",
+			Intent:    a.Intent,
+			Target: assignmentTarget{
+				ODataType: "#microsoft.graph.groupAssignmentTarget",
+				GroupID:   a.GroupID,
+			},
+			Settings: settings,
+		}
+
+		path := fmt.Sprintf("/deviceAppManagement/mobileApps/%s/assignments", url.PathEscape(appID))
+		if err := c.do(ctx, "POST", path, body, nil); err != nil {
+			return fmt.Errorf("failed to assign group %s: %w", a.GroupID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBaseURL is the Microsoft Graph endpoint used for Intune app management
+	// The beta endpoint is required for Win32 app relationships and content versions
+	DefaultBaseURL = "https://graph.microsoft.com/beta"
+	// DefaultLoginURL is the Azure AD authority used to acquire tokens
+	DefaultLoginURL = "https://login.microsoftonline.com"
+	// DefaultScope is the OAuth2 scope requested for app-only Graph access
+	DefaultScope = "https://graph.microsoft.com/.default"
+)
+
+// Credentials holds the Azure AD app registration used for app-only Graph access
+type Credentials struct {
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+}
+
+// Validate checks that all credential fields are set
+func (c Credentials) Validate() error {
+	if c.TenantID == "" {
+		return fmt.Errorf("tenant ID is required")
+	}
+	if c.ClientID == "" {
+		return fmt.Errorf("client ID is required")
+	}
+	if c.ClientSecret == "" {
+		return fmt.Errorf("client secret is required")
+	}
+	return nil
+}
+
+// Client is a minimal Microsoft Graph client for Intune mobile app operations
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	loginURL   string
+	scope      string
+	creds      Credentials
+	whatIf     io.Writer // Non-nil in what-if mode, see SetWhatIf
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// NewClient creates a Graph client using the client credentials flow
+func NewClient(creds Credentials) *Client {
+	return &Client{
+		httpClient: NewHTTPClient(DefaultHTTPOptions),
+		baseURL:    DefaultBaseURL,
+		loginURL:   DefaultLoginURL,
+		scope:      DefaultScope,
+		creds:      creds,
+	}
+}
+
+// NewTokenSource creates a client that acquires app-only tokens of the credentials for
+// another scope, such as https://vault.azure.net/.default for Azure Key Vault
+func NewTokenSource(creds Credentials, scope string) *Client {
+	c := NewClient(creds)
+	c.scope = scope
+	return c
+}
+
+// Token returns an access token for the scope of the client
+func (c *Client) Token(ctx context.Context) (string, error) {
+	return c.accessToken(ctx)
+}
+
+// SetServer sends token and Graph requests to a server that provides both, such as the
+// mock Graph of the graphtest package, instead of Azure AD and Microsoft Graph
+func (c *Client) SetServer(serverURL string) {
+	serverURL = strings.TrimSuffix(serverURL, "/")
+	c.baseURL = serverURL + "/beta"
+	c.loginURL = serverURL
+}
+
+// SetHTTPOptions changes the retries and timeouts of the requests of the client
+func (c *Client) SetHTTPOptions(opts HTTPOptions) {
+	c.httpClient = NewHTTPClient(opts)
+}
+
+// APIError is returned when Graph responds with a non-success status code
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("graph request failed (%d %s): %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("graph request failed (%d): %s", e.StatusCode, e.Message)
+}
+
+// tokenResponse is the OAuth2 token endpoint response
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// accessToken returns a cached token or acquires a new one
+func (c *Client) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Refresh a minute early to avoid using a token that expires mid-request
+	if c.token != "" && time.Now().Add(time.Minute).Before(c.tokenExpiry) {
+		return c.token, nil
+	}
+
+	if err := c.creds.Validate(); err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", c.creds.ClientID)
+	form.Set("client_secret", c.creds.ClientSecret)
+	form.Set("scope", c.scope)
+
+	tokenURL := fmt.Sprintf("%s/%s/oauth2/v2.0/token", c.loginURL, url.PathEscape(c.creds.TenantID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to acquire token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("failed to acquire token (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var tok tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %w", err)
+	}
+
+	c.token = tok.AccessToken
+	c.tokenExpiry = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	return c.token, nil
+}
+
+// do sends a Graph request with a JSON body and decodes the JSON response into out
+// body and out may be nil
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	if c.whatIf != nil {
+		if method != "GET" {
+			return c.planRequest(method, path, body, out)
+		}
+		// Objects created in what-if mode do not exist, so reading them returns nothing
+		if strings.Contains(path, WhatIfID) {
+			return nil
+		}
+	}
+
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return parseAPIError(resp)
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// parseAPIError converts a Graph error response into an APIError
+func parseAPIError(resp *http.Response) error {
+	data, _ := io.ReadAll(resp.Body)
+
+	var payload struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	if json.Unmarshal(data, &payload) == nil && payload.Error.Message != "" {
+		apiErr.Code = payload.Error.Code
+		apiErr.Message = payload.Error.Message
+	} else {
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+	return apiErr
+}
+
+// isGUID checks if a string looks like an Azure AD object ID
+func isGUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// odataQuote escapes a string literal for use in an OData $filter expression
+func odataQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,189 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestClient creates a client whose login and Graph endpoints point at the given server
+func newTestClient(server *httptest.Server) *Client {
+	client := NewClient(Credentials{TenantID: "tenant", ClientID: "client", ClientSecret: "secret"})
+	client.baseURL = server.URL + "/beta"
+	client.loginURL = server.URL
+	return client
+}
+
+// handleToken serves the OAuth2 token endpoint for tests
+func handleToken(w http.ResponseWriter, r *http.Request) bool {
+	if !strings.HasSuffix(r.URL.Path, "/oauth2/v2.0/token") {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"access_token":"test-token","expires_in":3600}`))
+	return true
+}
+
+func TestCredentialsValidate(t *testing.T) {
+	if err := (Credentials{}).Validate(); err == nil {
+		t.Error("Expected error for empty credentials")
+	}
+	if err := (Credentials{TenantID: "t", ClientID: "c", ClientSecret: "s"}).Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestClientAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handleToken(w, r) {
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error":{"code":"Forbidden","message":"Insufficient privileges"}}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+	err := client.do(context.Background(), "GET", "/deviceAppManagement/mobileApps", nil, nil)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("StatusCode = %d, want %d", apiErr.StatusCode, http.StatusForbidden)
+	}
+	if apiErr.Code != "Forbidden" {
+		t.Errorf("Code = %s, want Forbidden", apiErr.Code)
+	}
+}
+
+func TestAssignApp(t *testing.T) {
+	var received []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handleToken(w, r) {
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			t.Errorf("Authorization = %q, want bearer token", r.Header.Get("Authorization"))
+		}
+		if r.URL.Path != "/beta/deviceAppManagement/mobileApps/app-1/assignments" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		received = append(received, body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	deadline := time.Date(2026, 11, 1, 18, 0, 0, 0, time.UTC)
+	client := newTestClient(server)
+	err := client.AssignApp(context.Background(), "app-1", []Assignment{
+		{GroupID: "group-1", Intent: IntentRequired, Deadline: &deadline},
+		{GroupID: "group-2", Intent: IntentAvailable},
+	})
+	if err != nil {
+		t.Fatalf("AssignApp() error = %v", err)
+	}
+
+	if len(received) != 2 {
+		t.Fatalf("Received %d assignments, want 2", len(received))
+	}
+	if received[0]["intent"] != "required" {
+		t.Errorf("intent = %v, want required", received[0]["intent"])
+	}
+	settings := received[0]["settings"].(map[string]any)
+	installTime := settings["installTimeSettings"].(map[string]any)
+	if installTime["deadlineDateTime"] != "2026-11-01T18:00:00Z" {
+		t.Errorf("deadlineDateTime = %v, want 2026-11-01T18:00:00Z", installTime["deadlineDateTime"])
+	}
+	target := received[1]["target"].(map[string]any)
+	if target["groupId"] != "group-2" {
+		t.Errorf("groupId = %v, want group-2", target["groupId"])
+	}
+}
+
+func TestAssignAppDeadlineRequiresRequiredIntent(t *testing.T) {
+	deadline := time.Now()
+	client := NewClient(Credentials{})
+	err := client.AssignApp(context.Background(), "app-1", []Assignment{
+		{GroupID: "group-1", Intent: IntentAvailable, Deadline: &deadline},
+	})
+	if err == nil {
+		t.Error("Expected error for deadline on available assignment")
+	}
+}
+
+func TestResolveGroupID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handleToken(w, r) {
+			return
+		}
+		filter := r.URL.Query().Get("$filter")
+		switch filter {
+		case "displayName eq 'Pilot Devices'":
+			w.Write([]byte(`{"value":[{"id":"11111111-2222-3333-4444-555555555555","displayName":"Pilot Devices"}]}`))
+		case "displayName eq 'O''Brien Team'":
+			w.Write([]byte(`{"value":[{"id":"a"},{"id":"b"}]}`))
+		default:
+			w.Write([]byte(`{"value":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	id, err := client.ResolveGroupID(ctx, "Pilot Devices")
+	if err != nil {
+		t.Fatalf("ResolveGroupID() error = %v", err)
+	}
+	if id != "11111111-2222-3333-4444-555555555555" {
+		t.Errorf("ResolveGroupID() = %s, want 11111111-2222-3333-4444-555555555555", id)
+	}
+
+	// Object IDs are returned without a lookup
+	id, err = client.ResolveGroupID(ctx, "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee")
+	if err != nil || id != "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee" {
+		t.Errorf("ResolveGroupID() = %s, %v, want object ID unchanged", id, err)
+	}
+
+	if _, err := client.ResolveGroupID(ctx, "O'Brien Team"); err == nil {
+		t.Error("Expected error for ambiguous group name")
+	}
+	if _, err := client.ResolveGroupID(ctx, "Missing"); err == nil {
+		t.Error("Expected error for missing group")
+	}
+}
+
+func TestParseIntent(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Intent
+		wantErr  bool
+	}{
+		{"required", IntentRequired, false},
+		{"Available", IntentAvailable, false},
+		{"UNINSTALL", IntentUninstall, false},
+		{"optional", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := ParseIntent(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseIntent(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if result != tt.expected {
+				t.Errorf("ParseIntent(%q) = %s, want %s", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
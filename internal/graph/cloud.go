@@ -0,0 +1,71 @@
+package graph
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Cloud is an Azure cloud: the Microsoft Graph endpoint and Azure AD authority of its tenants
+type Cloud struct {
+	// Name is the name of the cloud as given to --cloud
+	Name string
+	// GraphURL is the Microsoft Graph endpoint, without API version
+	GraphURL string
+	// LoginURL is the Azure AD authority tokens are acquired from
+	LoginURL string
+	// PortalURL is the Intune admin center of the cloud's tenants
+	PortalURL string
+}
+
+// Clouds are the supported clouds; GCC tenants use the public cloud
+var (
+	CloudPublic    = Cloud{Name: "public", GraphURL: "https://graph.microsoft.com", LoginURL: DefaultLoginURL, PortalURL: "https://intune.microsoft.com"}
+	CloudUSGovHigh = Cloud{Name: "usgovhigh", GraphURL: "https://graph.microsoft.us", LoginURL: "https://login.microsoftonline.us", PortalURL: "https://intune.microsoft.us"}
+	CloudDoD       = Cloud{Name: "dod", GraphURL: "https://dod-graph.microsoft.us", LoginURL: "https://login.microsoftonline.us", PortalURL: "https://intune.microsoft.us"}
+	CloudChina     = Cloud{Name: "china", GraphURL: "https://microsoftgraph.chinacloudapi.cn", LoginURL: "https://login.chinacloudapi.cn", PortalURL: "https://intune.microsoftonline.cn"}
+)
+
+// cloudAliases maps other common names of the clouds to them
+var cloudAliases = map[string]Cloud{
+	"global":   CloudPublic,
+	"gcc":      CloudPublic,
+	"gcchigh":  CloudUSGovHigh,
+	"usgov":    CloudUSGovHigh,
+	"21vianet": CloudChina,
+}
+
+// ParseCloud returns the cloud of a name; an empty name is the public cloud
+// Names are case-insensitive and may contain dashes, e.g. GCC-High
+func ParseCloud(s string) (Cloud, error) {
+	name := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(s), "-", ""))
+	for _, cloud := range []Cloud{CloudPublic, CloudUSGovHigh, CloudDoD, CloudChina} {
+		if name == "" || name == cloud.Name {
+			return cloud, nil
+		}
+	}
+	if cloud, ok := cloudAliases[name]; ok {
+		return cloud, nil
+	}
+	return Cloud{}, fmt.Errorf("invalid cloud: %s (supported: public, usgovhigh, dod, china)", s)
+}
+
+// AdminConsentURL returns the admin consent page of the cloud, see AdminConsentURL
+func (c Cloud) AdminConsentURL(tenantID, clientID string) string {
+	return fmt.Sprintf("%s/%s/adminconsent?client_id=%s", c.LoginURL, url.PathEscape(tenantID), url.QueryEscape(clientID))
+}
+
+// AppPortalURL returns the page of a mobile app in the Intune admin center of the cloud
+func (c Cloud) AppPortalURL(appID string) string {
+	return fmt.Sprintf("%s/#view/Microsoft_Intune_Apps/SettingsMenu/~/0/appId/%s", c.PortalURL, url.PathEscape(appID))
+}
+
+// SetCloud sends token and Graph requests to the endpoints of a cloud
+// Token sources of another scope than Graph keep their scope
+func (c *Client) SetCloud(cloud Cloud) {
+	c.baseURL = cloud.GraphURL + "/beta"
+	c.loginURL = cloud.LoginURL
+	if c.scope == DefaultScope {
+		c.scope = cloud.GraphURL + "/.default"
+	}
+}
@@ -0,0 +1,58 @@
+package graph
+
+import "testing"
+
+func TestParseCloud(t *testing.T) {
+	tests := map[string]Cloud{
+		"":          CloudPublic,
+		"public":    CloudPublic,
+		"GCC":       CloudPublic,
+		"usgovhigh": CloudUSGovHigh,
+		"GCC-High":  CloudUSGovHigh,
+		"DoD":       CloudDoD,
+		"china":     CloudChina,
+		"21Vianet":  CloudChina,
+	}
+	for name, want := range tests {
+		got, err := ParseCloud(name)
+		if err != nil {
+			t.Errorf("ParseCloud(%q) error = %v", name, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseCloud(%q) = %s, want %s", name, got.Name, want.Name)
+		}
+	}
+	if _, err := ParseCloud("germany"); err == nil {
+		t.Error("Expected error for an unsupported cloud")
+	}
+}
+
+func TestSetCloud(t *testing.T) {
+	creds := Credentials{TenantID: "contoso.onmicrosoft.us", ClientID: "client", ClientSecret: "secret"}
+	client := NewClient(creds)
+	client.SetCloud(CloudUSGovHigh)
+	if client.baseURL != "https://graph.microsoft.us/beta" || client.loginURL != "https://login.microsoftonline.us" {
+		t.Errorf("Endpoints = %s, %s, want the US Government endpoints", client.baseURL, client.loginURL)
+	}
+	if client.scope != "https://graph.microsoft.us/.default" {
+		t.Errorf("scope = %s, want the Graph scope of the cloud", client.scope)
+	}
+
+	// Token sources of other resources keep their scope
+	tokens := NewTokenSource(creds, "https://vault.usgovcloudapi.net/.default")
+	tokens.SetCloud(CloudUSGovHigh)
+	if tokens.scope != "https://vault.usgovcloudapi.net/.default" || tokens.loginURL != "https://login.microsoftonline.us" {
+		t.Errorf("Token source = %s from %s, want its own scope from the cloud authority", tokens.scope, tokens.loginURL)
+	}
+
+	want := "https://login.chinacloudapi.cn/contoso.partner.onmschina.cn/adminconsent?client_id=client"
+	if got := CloudChina.AdminConsentURL("contoso.partner.onmschina.cn", "client"); got != want {
+		t.Errorf("AdminConsentURL() = %s, want %s", got, want)
+	}
+
+	wantPortal := "https://intune.microsoft.us/#view/Microsoft_Intune_Apps/SettingsMenu/~/0/appId/3f2c9b1e"
+	if got := CloudUSGovHigh.AppPortalURL("3f2c9b1e"); got != wantPortal {
+		t.Errorf("AppPortalURL() = %s, want %s", got, wantPortal)
+	}
+}
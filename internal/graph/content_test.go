@@ -0,0 +1,120 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDownloadCommittedContent(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handleToken(w, r) {
+			return
+		}
+		switch r.URL.Path {
+		case "/beta/deviceAppManagement/mobileApps/app-1":
+			w.Write([]byte(`{"id":"app-1","committedContentVersion":"2"}`))
+		case "/beta/deviceAppManagement/mobileApps/app-1/microsoft.graph.win32LobApp/contentVersions/2/files":
+			w.Write([]byte(`{"value":[
+				{"id":"f1","name":"old.intunewin","isCommitted":false},
+				{"id":"f2","name":"IntunePackage.intunewin","isCommitted":true,"sizeEncrypted":7,
+				 "azureStorageUri":"` + server.URL + `/storage/f2?sig=abc"}
+			]}`))
+		case "/storage/f2":
+			if r.Header.Get("Authorization") != "" {
+				t.Error("Graph token must not be sent to Azure Storage")
+			}
+			w.Write([]byte("content"))
+		default:
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+	file, err := client.CommittedContentFile(context.Background(), "app-1")
+	if err != nil {
+		t.Fatalf("CommittedContentFile() error = %v", err)
+	}
+	if file.ID != "f2" {
+		t.Errorf("File ID = %s, want f2", file.ID)
+	}
+
+	data, err := client.DownloadContentFile(context.Background(), file)
+	if err != nil {
+		t.Fatalf("DownloadContentFile() error = %v", err)
+	}
+	if string(data) != "content" {
+		t.Errorf("Downloaded = %q, want content", data)
+	}
+
+	file.AzureStorageURI = ""
+	if _, err := client.DownloadContentFile(context.Background(), file); err == nil {
+		t.Error("Expected error without storage URI")
+	}
+}
+
+func TestContentVersions(t *testing.T) {
+	defer func(size int64, interval time.Duration) {
+		uploadBlockSize, uploadPollInterval = size, interval
+	}(uploadBlockSize, uploadPollInterval)
+	uploadBlockSize, uploadPollInterval = 10, time.Millisecond
+
+	client, _ := newMockClient(t)
+	ctx := context.Background()
+	created, err := client.CreateWin32App(ctx, mockMsiApp("7-Zip"), ConflictFail)
+	if err != nil {
+		t.Fatalf("CreateWin32App() error = %v", err)
+	}
+	appID := created.App.ID
+
+	for _, content := range []string{"version one", "version two!"} {
+		upload := ContentUpload{
+			Name:           "IntunePackage.intunewin",
+			Size:           20,
+			SizeEncrypted:  int64(len(content)),
+			Content:        bytes.NewReader([]byte(content)),
+			Manifest:       []byte("<ApplicationInfo>" + content + "</ApplicationInfo>"),
+			EncryptionInfo: testEncryptionInfo,
+		}
+		if err := client.UploadContent(ctx, appID, upload); err != nil {
+			t.Fatalf("UploadContent() error = %v", err)
+		}
+	}
+
+	versions, err := client.ListContentVersions(ctx, appID)
+	if err != nil {
+		t.Fatalf("ListContentVersions() error = %v", err)
+	}
+	if len(versions) != 2 || versions[0].Active || !versions[1].Active {
+		t.Fatalf("ListContentVersions() = %+v, want two versions with the second active", versions)
+	}
+	file := versions[0].CommittedFile()
+	if file == nil || file.SizeEncrypted != 11 || string(file.Manifest) != "<ApplicationInfo>version one</ApplicationInfo>" || file.CreatedDateTime.IsZero() {
+		t.Errorf("CommittedFile() = %+v, want the first upload with its manifest", file)
+	}
+
+	if err := client.ActivateContentVersion(ctx, appID, versions[0].ID); err != nil {
+		t.Fatalf("ActivateContentVersion() error = %v", err)
+	}
+	committed, err := client.CommittedContentFile(ctx, appID)
+	if err != nil {
+		t.Fatalf("CommittedContentFile() error = %v", err)
+	}
+	if committed.ID != file.ID {
+		t.Errorf("CommittedContentFile() = %s, want the reactivated file %s", committed.ID, file.ID)
+	}
+
+	// A version whose upload never finished cannot be activated
+	if err := client.do(ctx, "POST", contentVersionsPath(appID), map[string]any{}, nil); err != nil {
+		t.Fatalf("Failed to create content version: %v", err)
+	}
+	if err := client.ActivateContentVersion(ctx, appID, "3"); err == nil {
+		t.Error("Expected error for a content version without committed file")
+	}
+}
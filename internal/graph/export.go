@@ -0,0 +1,183 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// win32AppJSON is the Graph win32LobApp representation read back from a tenant
+type win32AppJSON struct {
+	ODataType               string `json:"@odata.type"`
+	DisplayName             string `json:"displayName"`
+	DisplayVersion          string `json:"displayVersion"`
+	Description             string `json:"description"`
+	Publisher               string `json:"publisher"`
+	FileName                string `json:"fileName"`
+	SetupFilePath           string `json:"setupFilePath"`
+	InstallCommandLine      string `json:"installCommandLine"`
+	UninstallCommandLine    string `json:"uninstallCommandLine"`
+	ApplicableArchitectures string `json:"applicableArchitectures"`
+	InformationURL          string `json:"informationUrl"`
+	PrivacyInformationURL   string `json:"privacyInformationUrl"`
+	Developer               string `json:"developer"`
+	Owner                   string `json:"owner"`
+	Notes                   string `json:"notes"`
+
+	MinimumSupportedWindowsRelease string `json:"minimumSupportedWindowsRelease"`
+	MinimumFreeDiskSpaceInMB       *int   `json:"minimumFreeDiskSpaceInMB"`
+	MinimumMemoryInMB              *int   `json:"minimumMemoryInMB"`
+	MinimumNumberOfProcessors      *int   `json:"minimumNumberOfProcessors"`
+	MinimumCPUSpeedInMHz           *int   `json:"minimumCpuSpeedInMHz"`
+
+	InstallExperience struct {
+		RunAsAccount          string `json:"runAsAccount"`
+		DeviceRestartBehavior string `json:"deviceRestartBehavior"`
+	} `json:"installExperience"`
+
+	MsiInformation   *MsiInformation  `json:"msiInformation"`
+	DetectionRules   []map[string]any `json:"detectionRules"`
+	RequirementRules []map[string]any `json:"requirementRules"`
+	ReturnCodes      []ReturnCode     `json:"returnCodes"`
+}
+
+// GetWin32App reads the full definition of an existing Win32 app, in the form
+// CreateWin32App accepts
+func (c *Client) GetWin32App(ctx context.Context, appID string) (*Win32App, error) {
+	var resp win32AppJSON
+	if err := c.do(ctx, "GET", fmt.Sprintf("/deviceAppManagement/mobileApps/%s", url.PathEscape(appID)), nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to read app %s: %w", appID, err)
+	}
+	if resp.ODataType != "#microsoft.graph.win32LobApp" {
+		return nil, fmt.Errorf("app %s is not a Win32 app (%s)", appID, strings.TrimPrefix(resp.ODataType, "#microsoft.graph."))
+	}
+
+	app := &Win32App{
+		DisplayName:           resp.DisplayName,
+		DisplayVersion:        resp.DisplayVersion,
+		Description:           resp.Description,
+		Publisher:             resp.Publisher,
+		FileName:              resp.FileName,
+		SetupFile:             resp.SetupFilePath,
+		InstallCommandLine:    resp.InstallCommandLine,
+		UninstallCommandLine:  resp.UninstallCommandLine,
+		Architectures:         resp.ApplicableArchitectures,
+		InformationURL:        resp.InformationURL,
+		PrivacyInformationURL: resp.PrivacyInformationURL,
+		Developer:             resp.Developer,
+		Owner:                 resp.Owner,
+		Notes:                 resp.Notes,
+		RunAsAccount:          resp.InstallExperience.RunAsAccount,
+		RestartBehavior:       resp.InstallExperience.DeviceRestartBehavior,
+		ReturnCodes:           resp.ReturnCodes,
+	}
+	if msi := resp.MsiInformation; msi != nil {
+		app.MsiProductCode = msi.ProductCode
+		app.MsiProductVersion = msi.ProductVersion
+		app.MsiUpgradeCode = msi.UpgradeCode
+	}
+	for _, rule := range resp.DetectionRules {
+		app.DetectionRules = append(app.DetectionRules, compactRule(rule))
+	}
+
+	req := Requirements{
+		MinimumWindowsRelease:  resp.MinimumSupportedWindowsRelease,
+		MinimumFreeDiskSpaceMB: intValue(resp.MinimumFreeDiskSpaceInMB),
+		MinimumMemoryMB:        intValue(resp.MinimumMemoryInMB),
+		MinimumProcessors:      intValue(resp.MinimumNumberOfProcessors),
+		MinimumCPUSpeedMHz:     intValue(resp.MinimumCPUSpeedInMHz),
+	}
+	for _, rule := range resp.RequirementRules {
+		req.Rules = append(req.Rules, compactRule(rule))
+	}
+	if req.MinimumWindowsRelease != "" || req.MinimumFreeDiskSpaceMB > 0 || req.MinimumMemoryMB > 0 ||
+		req.MinimumProcessors > 0 || req.MinimumCPUSpeedMHz > 0 || len(req.Rules) > 0 {
+		app.Requirements = &req
+	}
+	return app, nil
+}
+
+// compactRule drops the unset (null) properties Graph returns with a rule
+func compactRule(rule map[string]any) map[string]any {
+	out := make(map[string]any, len(rule))
+	for key, value := range rule {
+		if value != nil {
+			out[key] = value
+		}
+	}
+	return out
+}
+
+// intValue returns the value of an optional number, 0 when unset
+func intValue(v *int) int {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+// AppAssignment is an existing assignment of an app
+type AppAssignment struct {
+	Intent Intent
+	// TargetType is the Graph target type without its namespace, e.g. groupAssignmentTarget
+	TargetType string
+	// GroupID is set for group and exclusion group targets
+	GroupID string
+	// Deadline is the install deadline as stored by Intune, "" when none
+	Deadline string
+	// UseLocalTime reports whether the deadline is in device local time
+	UseLocalTime bool
+}
+
+// IsGroup reports whether the assignment includes a group
+func (a AppAssignment) IsGroup() bool {
+	return a.TargetType == "groupAssignmentTarget"
+}
+
+// ListAssignments returns the assignments of an app
+func (c *Client) ListAssignments(ctx context.Context, appID string) ([]AppAssignment, error) {
+	var resp struct {
+		Value []struct {
+			Intent Intent `json:"intent"`
+			Target struct {
+				ODataType string `json:"@odata.type"`
+				GroupID   string `json:"groupId"`
+			} `json:"target"`
+			Settings *struct {
+				InstallTimeSettings *installTimeJSON `json:"installTimeSettings"`
+			} `json:"settings"`
+		} `json:"value"`
+	}
+	path := fmt.Sprintf("/deviceAppManagement/mobileApps/%s/assignments", url.PathEscape(appID))
+	if err := c.do(ctx, "GET", path, nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to list assignments of app %s: %w", appID, err)
+	}
+
+	assignments := make([]AppAssignment, 0, len(resp.Value))
+	for _, v := range resp.Value {
+		a := AppAssignment{
+			Intent:     v.Intent,
+			TargetType: strings.TrimPrefix(v.Target.ODataType, "#microsoft.graph."),
+			GroupID:    v.Target.GroupID,
+		}
+		if v.Settings != nil && v.Settings.InstallTimeSettings != nil {
+			a.Deadline = v.Settings.InstallTimeSettings.DeadlineDateTime
+			a.UseLocalTime = v.Settings.InstallTimeSettings.UseLocalTime
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments, nil
+}
+
+// GroupName returns the display name of a group
+func (c *Client) GroupName(ctx context.Context, groupID string) (string, error) {
+	var group struct {
+		DisplayName string `json:"displayName"`
+	}
+	path := fmt.Sprintf("/groups/%s?$select=id,displayName", url.PathEscape(groupID))
+	if err := c.do(ctx, "GET", path, nil, &group); err != nil {
+		return "", fmt.Errorf("failed to look up group %s: %w", groupID, err)
+	}
+	return group.DisplayName, nil
+}
@@ -0,0 +1,127 @@
+package graph
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetWin32AppAndAssignments(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handleToken(w, r) {
+			return
+		}
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/mobileApps/app-id"):
+			w.Write([]byte(`{
+				"@odata.type":"#microsoft.graph.win32LobApp",
+				"displayName":"7-Zip","displayVersion":"24.01","fileName":"7z2401-x64.intunewin",
+				"setupFilePath":"7z2401-x64.msi","applicableArchitectures":"x64","notes":null,
+				"installCommandLine":"msiexec /i \"7z2401-x64.msi\" /qn","uninstallCommandLine":"msiexec /x {23170F69-40C1-2702-2401-000001000000} /qn",
+				"installExperience":{"runAsAccount":"system","deviceRestartBehavior":"suppress"},
+				"minimumSupportedWindowsRelease":"21H2","minimumFreeDiskSpaceInMB":250,"minimumMemoryInMB":null,
+				"msiInformation":{"productCode":"{23170F69-40C1-2702-2401-000001000000}"},
+				"detectionRules":[{"@odata.type":"#microsoft.graph.win32LobAppProductCodeDetection",
+					"productCode":"{23170F69-40C1-2702-2401-000001000000}","productVersion":null}],
+				"returnCodes":[{"returnCode":0,"type":"success"},{"returnCode":3010,"type":"softReboot"}]}`))
+		case strings.HasSuffix(r.URL.Path, "/mobileApps/app-id/assignments"):
+			w.Write([]byte(`{"value":[
+				{"intent":"required","target":{"@odata.type":"#microsoft.graph.groupAssignmentTarget","groupId":"group-id"},
+					"settings":{"installTimeSettings":{"useLocalTime":true,"deadlineDateTime":"2026-11-01T18:00:00Z"}}},
+				{"intent":"available","target":{"@odata.type":"#microsoft.graph.allLicensedUsersAssignmentTarget"},"settings":null}]}`))
+		case strings.HasSuffix(r.URL.Path, "/groups/group-id"):
+			w.Write([]byte(`{"id":"group-id","displayName":"Pilot Devices"}`))
+		case strings.HasSuffix(r.URL.Path, "/mobileApps/store-app"):
+			w.Write([]byte(`{"@odata.type":"#microsoft.graph.winGetApp","displayName":"Notepad++"}`))
+		default:
+			t.Errorf("Unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	app, err := client.GetWin32App(ctx, "app-id")
+	if err != nil {
+		t.Fatalf("GetWin32App() error = %v", err)
+	}
+	if app.DisplayName != "7-Zip" || app.SetupFile != "7z2401-x64.msi" || app.RestartBehavior != "suppress" {
+		t.Errorf("App = %+v", app)
+	}
+	if len(app.DetectionRules) != 1 || len(app.DetectionRules[0]) != 2 {
+		t.Errorf("DetectionRules = %v, want one rule without null properties", app.DetectionRules)
+	}
+	if app.Requirements == nil || app.Requirements.MinimumWindowsRelease != "21H2" ||
+		app.Requirements.MinimumFreeDiskSpaceMB != 250 || app.Requirements.MinimumMemoryMB != 0 {
+		t.Errorf("Requirements = %+v", app.Requirements)
+	}
+	if len(app.ReturnCodes) != 2 || app.ReturnCodes[1] != (ReturnCode{Code: 3010, Type: "softReboot"}) {
+		t.Errorf("ReturnCodes = %+v", app.ReturnCodes)
+	}
+
+	// The exported app can be created again as it is
+	if _, err := win32AppBody(*app); err != nil {
+		t.Errorf("win32AppBody() of exported app error = %v", err)
+	}
+
+	if _, err := client.GetWin32App(ctx, "store-app"); err == nil {
+		t.Error("Expected error for an app that is not a Win32 app")
+	}
+
+	assignments, err := client.ListAssignments(ctx, "app-id")
+	if err != nil {
+		t.Fatalf("ListAssignments() error = %v", err)
+	}
+	if len(assignments) != 2 {
+		t.Fatalf("ListAssignments() returned %d assignments, want 2", len(assignments))
+	}
+	if a := assignments[0]; !a.IsGroup() || a.GroupID != "group-id" || a.Intent != IntentRequired || a.Deadline == "" || !a.UseLocalTime {
+		t.Errorf("Group assignment = %+v", a)
+	}
+	if assignments[1].IsGroup() || assignments[1].TargetType != "allLicensedUsersAssignmentTarget" {
+		t.Errorf("All users assignment = %+v", assignments[1])
+	}
+
+	name, err := client.GroupName(ctx, "group-id")
+	if err != nil || name != "Pilot Devices" {
+		t.Errorf("GroupName() = %s, %v", name, err)
+	}
+}
+
+func TestWin32AppBodyExportedSettings(t *testing.T) {
+	app := testWin32App()
+	app.DetectionRules = []map[string]any{{
+		"@odata.type": "#microsoft.graph.win32LobAppRegistryDetection",
+		"keyPath":     `HKEY_LOCAL_MACHINE\SOFTWARE\7-Zip`,
+	}}
+	app.Requirements = &Requirements{MinimumWindowsRelease: "21H2", MinimumFreeDiskSpaceMB: 250}
+	app.ReturnCodes = []ReturnCode{{Code: 3010, Type: "softReboot"}}
+	app.RunAsAccount = "user"
+
+	body, err := win32AppBody(app)
+	if err != nil {
+		t.Fatalf("win32AppBody() error = %v", err)
+	}
+	rules := body["detectionRules"].([]map[string]any)
+	if rules[0]["@odata.type"] != "#microsoft.graph.win32LobAppRegistryDetection" {
+		t.Errorf("Detection rule = %v, want the supplied rule over MSI detection", rules[0])
+	}
+	if body["msiInformation"] == nil {
+		t.Error("Expected MSI information to be kept with supplied detection rules")
+	}
+	if body["minimumSupportedWindowsRelease"] != "21H2" || body["minimumFreeDiskSpaceInMB"] != 250 {
+		t.Errorf("Requirements not in body: %v", body)
+	}
+	if body["installExperience"].(map[string]any)["runAsAccount"] != "user" {
+		t.Errorf("installExperience = %v", body["installExperience"])
+	}
+
+	app.DetectionRules = []map[string]any{{"keyPath": "HKEY_LOCAL_MACHINE"}}
+	if _, err := win32AppBody(app); err == nil {
+		t.Error("Expected error for a detection rule without @odata.type")
+	}
+}
@@ -0,0 +1,274 @@
+package graph
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/graphtest"
+)
+
+// newMockClient creates a client for a mock Graph tenant served by httptest
+func newMockClient(t *testing.T) (*Client, *graphtest.Server) {
+	t.Helper()
+	mock := graphtest.New()
+	server := httptest.NewServer(mock.Handler())
+	t.Cleanup(server.Close)
+
+	client := NewClient(Credentials{TenantID: "contoso", ClientID: "client", ClientSecret: "secret"})
+	client.SetServer(server.URL)
+	return client, mock
+}
+
+// mockMsiApp is an MSI app as apps create builds it from a package
+func mockMsiApp(name string) Win32App {
+	return Win32App{
+		DisplayName:          name,
+		DisplayVersion:       "24.01",
+		Publisher:            "Igor Pavlov",
+		FileName:             "7z2401-x64.intunewin",
+		SetupFile:            "7z2401-x64.msi",
+		InstallCommandLine:   `msiexec /i "7z2401-x64.msi" /qn`,
+		UninstallCommandLine: "msiexec /x {23170F69-40C1-2702-2401-000001000000} /qn",
+		MsiProductCode:       "{23170F69-40C1-2702-2401-000001000000}",
+		MsiProductVersion:    "24.01.00.0",
+		Requirements:         &Requirements{MinimumWindowsRelease: "21H2", MinimumFreeDiskSpaceMB: 200},
+		ReturnCodes:          []ReturnCode{{Code: 0, Type: "success"}, {Code: 3010, Type: "softReboot"}},
+	}
+}
+
+func TestMockCreateAssignAndRelate(t *testing.T) {
+	client, mock := newMockClient(t)
+	ctx := context.Background()
+	pilot := mock.AddGroup("Pilot Devices")
+
+	created, err := client.CreateWin32App(ctx, mockMsiApp("7-Zip"), ConflictFail)
+	if err != nil {
+		t.Fatalf("CreateWin32App() error = %v", err)
+	}
+	if !isGUID(created.App.ID) || created.Updated {
+		t.Errorf("CreateWin32App() = %+v", created)
+	}
+
+	// Name conflicts are resolved as in a real tenant
+	if _, err := client.CreateWin32App(ctx, mockMsiApp("7-zip"), ConflictFail); err == nil {
+		t.Error("Expected error for an existing app name")
+	}
+	suffixed, err := client.CreateWin32App(ctx, mockMsiApp("7-Zip"), ConflictSuffix)
+	if err != nil {
+		t.Fatalf("CreateWin32App(suffix) error = %v", err)
+	}
+	if suffixed.App.DisplayName != "7-Zip 24.01" {
+		t.Errorf("DisplayName = %q, want 7-Zip 24.01", suffixed.App.DisplayName)
+	}
+	updated, err := client.CreateWin32App(ctx, mockMsiApp("7-Zip"), ConflictUpdate)
+	if err != nil || !updated.Updated || updated.App.ID != created.App.ID {
+		t.Errorf("CreateWin32App(update) = %+v, %v", updated, err)
+	}
+
+	// Groups are resolved by name and the assignment reads back as written
+	groupID, err := client.ResolveGroupID(ctx, "pilot devices")
+	if err != nil || groupID != pilot.ID {
+		t.Fatalf("ResolveGroupID() = %s, %v, want %s", groupID, err, pilot.ID)
+	}
+	deadline := time.Date(2026, 11, 1, 18, 0, 0, 0, time.UTC)
+	if err := client.AssignApp(ctx, suffixed.App.ID, []Assignment{{GroupID: groupID, Intent: IntentRequired, Deadline: &deadline}}); err != nil {
+		t.Fatalf("AssignApp() error = %v", err)
+	}
+	if err := client.AssignApp(ctx, suffixed.App.ID, []Assignment{{GroupID: "00000000-0000-4000-8000-000000000000", Intent: IntentAvailable}}); err == nil {
+		t.Error("Expected error for an unknown group")
+	}
+	assignments, err := client.ListAssignments(ctx, suffixed.App.ID)
+	if err != nil {
+		t.Fatalf("ListAssignments() error = %v", err)
+	}
+	if len(assignments) != 1 || !assignments[0].IsGroup() || assignments[0].GroupID != pilot.ID || assignments[0].Deadline != "2026-11-01T18:00:00Z" {
+		t.Errorf("ListAssignments() = %+v", assignments)
+	}
+	if name, err := client.GroupName(ctx, pilot.ID); err != nil || name != "Pilot Devices" {
+		t.Errorf("GroupName() = %q, %v", name, err)
+	}
+
+	// The new version supersedes the old one, which lists it as parent
+	oldID, err := client.ResolveAppID(ctx, "7-Zip")
+	if err != nil {
+		t.Fatalf("ResolveAppID() error = %v", err)
+	}
+	if err := client.AddRelationships(ctx, suffixed.App.ID, []Relationship{{TargetID: oldID, Supersedence: SupersedenceReplace}}); err != nil {
+		t.Fatalf("AddRelationships() error = %v", err)
+	}
+	if err := client.AddRelationships(ctx, oldID, []Relationship{{TargetID: created.App.ID, Dependency: DependencyAutoInstall}}); err == nil {
+		t.Error("Expected error for an app depending on itself")
+	}
+	for _, app := range mock.Apps() {
+		if app.Object["id"] == suffixed.App.ID {
+			if len(app.Relationships) != 1 || app.Relationships[0]["targetId"] != oldID || app.Relationships[0]["targetType"] != "child" {
+				t.Errorf("Relationships = %v", app.Relationships)
+			}
+		}
+	}
+
+	// The app reads back in the form it was created from
+	app, err := client.GetWin32App(ctx, suffixed.App.ID)
+	if err != nil {
+		t.Fatalf("GetWin32App() error = %v", err)
+	}
+	want := mockMsiApp("7-Zip 24.01")
+	if app.DisplayName != want.DisplayName || app.MsiProductCode != want.MsiProductCode || app.InstallCommandLine != want.InstallCommandLine ||
+		app.Requirements == nil || app.Requirements.MinimumWindowsRelease != "21H2" || len(app.ReturnCodes) != 2 || len(app.DetectionRules) != 1 {
+		t.Errorf("GetWin32App() = %+v", app)
+	}
+}
+
+func TestMockListAppsPaging(t *testing.T) {
+	client, mock := newMockClient(t)
+	mock.PageSize = 2
+	for i := 1; i <= 5; i++ {
+		mock.AddApp(map[string]any{"displayName": fmt.Sprintf("Contoso Tool %d", i)})
+	}
+	mock.AddApp(map[string]any{"@odata.type": "#microsoft.graph.webApp", "displayName": "Contoso Portal"})
+
+	apps, err := client.ListApps(context.Background(), "contoso")
+	if err != nil {
+		t.Fatalf("ListApps() error = %v", err)
+	}
+	if len(apps) != 5 {
+		t.Errorf("ListApps() = %d apps, want the 5 Win32 apps over 3 pages", len(apps))
+	}
+	var pages int
+	for _, req := range mock.Requests() {
+		if req.Method == "GET" && req.Path == "/beta/deviceAppManagement/mobileApps" {
+			pages++
+		}
+	}
+	if pages != 3 {
+		t.Errorf("Requested %d pages, want 3", pages)
+	}
+}
+
+func TestMockContentUpload(t *testing.T) {
+	client, mock := newMockClient(t)
+	ctx := context.Background()
+	created, err := client.CreateWin32App(ctx, mockMsiApp("7-Zip"), ConflictFail)
+	if err != nil {
+		t.Fatalf("CreateWin32App() error = %v", err)
+	}
+	appID := created.App.ID
+	content := []byte("encrypted package content")
+
+	// The upload sequence of Intune: content version, file, storage URI, blocks, commit
+	var version struct {
+		ID string `json:"id"`
+	}
+	if err := client.do(ctx, "POST", contentVersionsPath(appID), map[string]any{}, &version); err != nil {
+		t.Fatalf("Failed to create content version: %v", err)
+	}
+	filesPath := fmt.Sprintf("%s/%s/files", contentVersionsPath(appID), version.ID)
+	var file ContentFile
+	body := map[string]any{"name": "IntunePackage.intunewin", "size": 20, "sizeEncrypted": len(content)}
+	if err := client.do(ctx, "POST", filesPath, body, &file); err != nil {
+		t.Fatalf("Failed to create content file: %v", err)
+	}
+	if err := client.do(ctx, "GET", filesPath+"/"+file.ID, nil, &file); err != nil {
+		t.Fatalf("Failed to read content file: %v", err)
+	}
+	if file.UploadState != graphtest.UploadStateReady || file.AzureStorageURI == "" {
+		t.Fatalf("Content file = %+v, want a storage URI", file)
+	}
+
+	blockIDs := []string{base64.StdEncoding.EncodeToString([]byte("block-0")), base64.StdEncoding.EncodeToString([]byte("block-1"))}
+	for i, part := range [][]byte{content[:10], content[10:]} {
+		putBlob(t, file.AzureStorageURI+"&comp=block&blockid="+blockIDs[i], string(part))
+	}
+	putBlob(t, file.AzureStorageURI+"&comp=blocklist", `<?xml version="1.0" encoding="utf-8"?><BlockList><Latest>`+blockIDs[0]+`</Latest><Latest>`+blockIDs[1]+`</Latest></BlockList>`)
+
+	// The app cannot point to content before it is committed
+	patch := map[string]any{"@odata.type": "#microsoft.graph.win32LobApp", "committedContentVersion": version.ID}
+	var apiErr *APIError
+	if err := client.do(ctx, "PATCH", "/deviceAppManagement/mobileApps/"+appID, patch, nil); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("PATCH before commit error = %v, want 400", err)
+	}
+
+	commit := map[string]any{"fileEncryptionInfo": map[string]any{
+		"encryptionKey": "a2V5", "macKey": "bWFj", "initializationVector": "aXY=", "mac": "bWFj",
+		"profileIdentifier": "ProfileVersion1", "fileDigest": "ZGlnZXN0", "fileDigestAlgorithm": "SHA256",
+	}}
+	if err := client.do(ctx, "POST", filesPath+"/"+file.ID+"/commit", commit, nil); err != nil {
+		t.Fatalf("Failed to commit content file: %v", err)
+	}
+	if err := client.do(ctx, "PATCH", "/deviceAppManagement/mobileApps/"+appID, patch, nil); err != nil {
+		t.Fatalf("Failed to set the committed content version: %v", err)
+	}
+
+	// The committed content downloads as uploaded
+	committed, err := client.CommittedContentFile(ctx, appID)
+	if err != nil {
+		t.Fatalf("CommittedContentFile() error = %v", err)
+	}
+	data, err := client.DownloadContentFile(ctx, committed)
+	if err != nil {
+		t.Fatalf("DownloadContentFile() error = %v", err)
+	}
+	if string(data) != string(content) {
+		t.Errorf("Downloaded %q, want %q", data, content)
+	}
+	if state := mock.Apps()[0].Object["publishingState"]; state != "published" {
+		t.Errorf("publishingState = %v, want published", state)
+	}
+}
+
+func TestMockRejectsInvalidRequests(t *testing.T) {
+	client, _ := newMockClient(t)
+	ctx := context.Background()
+
+	// Win32 apps need commands and a detection rule, as in Intune
+	var apiErr *APIError
+	err := client.do(ctx, "POST", "/deviceAppManagement/mobileApps", map[string]any{
+		"@odata.type": "#microsoft.graph.win32LobApp", "displayName": "No Detection",
+		"fileName": "a.intunewin", "setupFilePath": "setup.exe", "installCommandLine": "setup.exe /S", "uninstallCommandLine": "setup.exe /U",
+	}, nil)
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || !strings.Contains(apiErr.Message, "detection rule") {
+		t.Errorf("Create without detection rule error = %v", err)
+	}
+
+	if err := client.do(ctx, "GET", "/deviceAppManagement/mobileApps?$filter=startswith(displayName,'7')", nil, nil); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Unsupported filter error = %v", err)
+	}
+	if _, err := client.GetWin32App(ctx, "00000000-0000-4000-8000-000000000000"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("GetWin32App() of a missing app error = %v", err)
+	}
+
+	// Requests without the issued token are refused
+	resp, err := http.Get(strings.TrimSuffix(client.baseURL, "/beta") + "/beta/deviceAppManagement/mobileApps")
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Unauthenticated request status = %d, want 401", resp.StatusCode)
+	}
+}
+
+// putBlob sends a PUT to a storage URI as Azure Storage clients do
+func putBlob(t *testing.T, uri, body string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPut, uri, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT %s error = %v", uri, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT %s status = %d, want 201", uri, resp.StatusCode)
+	}
+}
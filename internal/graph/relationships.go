@@ -0,0 +1,123 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// SupersedenceType controls what happens to the superseded app
+type SupersedenceType string
+
+const (
+	// SupersedenceUpdate updates the superseded app in place
+	SupersedenceUpdate SupersedenceType = "update"
+	// SupersedenceReplace uninstalls the superseded app before installing
+	SupersedenceReplace SupersedenceType = "replace"
+)
+
+// DependencyType controls how a dependency is handled at install time
+type DependencyType string
+
+const (
+	// DependencyAutoInstall installs the dependency if it is missing
+	DependencyAutoInstall DependencyType = "autoInstall"
+	// DependencyDetect only checks that the dependency is present
+	DependencyDetect DependencyType = "detect"
+)
+
+// ParseSupersedenceType converts a user-supplied string into a SupersedenceType
+func ParseSupersedenceType(s string) (SupersedenceType, error) {
+	switch SupersedenceType(strings.ToLower(s)) {
+	case SupersedenceUpdate:
+		return SupersedenceUpdate, nil
+	case SupersedenceReplace:
+		return SupersedenceReplace, nil
+	default:
+		return "", fmt.Errorf("invalid supersedence type: %s (supported: update, replace)", s)
+	}
+}
+
+// ParseDependencyType converts a user-supplied string into a DependencyType
+func ParseDependencyType(s string) (DependencyType, error) {
+	switch strings.ToLower(s) {
+	case "autoinstall", "auto-install", "auto":
+		return DependencyAutoInstall, nil
+	case "detect":
+		return DependencyDetect, nil
+	default:
+		return "", fmt.Errorf("invalid dependency type: %s (supported: autoInstall, detect)", s)
+	}
+}
+
+// Relationship is a supersedence or dependency link from an app to a target app
+type Relationship struct {
+	// TargetID is the ID of the superseded app or of the dependency
+	TargetID string `json:"targetId"`
+	// Supersedence is set for supersedence relationships
+	Supersedence SupersedenceType `json:"supersedenceType,omitempty"`
+	// Dependency is set for dependency relationships
+	Dependency DependencyType `json:"dependencyType,omitempty"`
+}
+
+// mobileAppRelationship is the Graph representation of a relationship
+type mobileAppRelationship struct {
+	ODataType        string           `json:"@odata.type"`
+	TargetID         string           `json:"targetId"`
+	TargetType       string           `json:"targetType,omitempty"`
+	SupersedenceType SupersedenceType `json:"supersedenceType,omitempty"`
+	DependencyType   DependencyType   `json:"dependencyType,omitempty"`
+}
+
+const (
+	odataSupersedence = "#microsoft.graph.mobileAppSupersedence"
+	odataDependency   = "#microsoft.graph.mobileAppDependency"
+)
+
+// AddRelationships adds supersedence and dependency relationships to an app
+// Graph replaces the full relationship set on update, so existing relationships
+// of the app are read first and kept
+func (c *Client) AddRelationships(ctx context.Context, appID string, relationships []Relationship) error {
+	basePath := fmt.Sprintf("/deviceAppManagement/mobileApps/%s", url.PathEscape(appID))
+
+	var existing struct {
+		Value []mobileAppRelationship `json:"value"`
+	}
+	if err := c.do(ctx, "GET", basePath+"/relationships", nil, &existing); err != nil {
+		return fmt.Errorf("failed to read existing relationships: %w", err)
+	}
+
+	var merged []mobileAppRelationship
+	seen := make(map[string]bool)
+	for _, r := range relationships {
+		rel := mobileAppRelationship{TargetID: r.TargetID}
+		switch {
+		case r.Supersedence != "":
+			rel.ODataType = odataSupersedence
+			rel.SupersedenceType = r.Supersedence
+		case r.Dependency != "":
+			rel.ODataType = odataDependency
+			rel.DependencyType = r.Dependency
+		default:
+			return fmt.Errorf("relationship to %s has no supersedence or dependency type", r.TargetID)
+		}
+		merged = append(merged, rel)
+		seen[r.TargetID] = true
+	}
+
+	// Only relationships where this app is the parent can be written back
+	for _, rel := range existing.Value {
+		if rel.TargetType != "child" || seen[rel.TargetID] {
+			continue
+		}
+		rel.TargetType = ""
+		merged = append(merged, rel)
+	}
+
+	body := map[string]any{"relationships": merged}
+	if err := c.do(ctx, "POST", basePath+"/updateRelationships", body, nil); err != nil {
+		return fmt.Errorf("failed to update relationships: %w", err)
+	}
+	return nil
+}
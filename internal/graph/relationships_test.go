@@ -0,0 +1,94 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAddRelationships(t *testing.T) {
+	var updated struct {
+		Relationships []mobileAppRelationship `json:"relationships"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handleToken(w, r) {
+			return
+		}
+		switch r.URL.Path {
+		case "/beta/deviceAppManagement/mobileApps/new-app/relationships":
+			w.Write([]byte(`{"value":[
+				{"@odata.type":"#microsoft.graph.mobileAppDependency","targetId":"runtime","targetType":"child","dependencyType":"detect"},
+				{"@odata.type":"#microsoft.graph.mobileAppSupersedence","targetId":"newer-app","targetType":"parent","supersedenceType":"update"}
+			]}`))
+		case "/beta/deviceAppManagement/mobileApps/new-app/updateRelationships":
+			json.NewDecoder(r.Body).Decode(&updated)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+	err := client.AddRelationships(context.Background(), "new-app", []Relationship{
+		{TargetID: "old-app", Supersedence: SupersedenceReplace},
+		{TargetID: "runtime", Dependency: DependencyAutoInstall},
+	})
+	if err != nil {
+		t.Fatalf("AddRelationships() error = %v", err)
+	}
+
+	// The new dependency replaces the existing one on the same target,
+	// and the parent relationship (owned by another app) is not written back
+	if len(updated.Relationships) != 2 {
+		t.Fatalf("Relationships = %d, want 2: %+v", len(updated.Relationships), updated.Relationships)
+	}
+	if updated.Relationships[0].ODataType != odataSupersedence || updated.Relationships[0].SupersedenceType != SupersedenceReplace {
+		t.Errorf("Relationships[0] = %+v, want replace supersedence", updated.Relationships[0])
+	}
+	if updated.Relationships[1].DependencyType != DependencyAutoInstall {
+		t.Errorf("Relationships[1].DependencyType = %s, want autoInstall", updated.Relationships[1].DependencyType)
+	}
+}
+
+func TestResolveAppID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handleToken(w, r) {
+			return
+		}
+		want := "isof('microsoft.graph.win32LobApp') and displayName eq '7-Zip'"
+		if r.URL.Query().Get("$filter") != want {
+			w.Write([]byte(`{"value":[]}`))
+			return
+		}
+		w.Write([]byte(`{"value":[{"id":"app-7zip","displayName":"7-Zip"}]}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+	id, err := client.ResolveAppID(context.Background(), "7-Zip")
+	if err != nil {
+		t.Fatalf("ResolveAppID() error = %v", err)
+	}
+	if id != "app-7zip" {
+		t.Errorf("ResolveAppID() = %s, want app-7zip", id)
+	}
+
+	if _, err := client.ResolveAppID(context.Background(), "Unknown"); err == nil {
+		t.Error("Expected error for unknown app")
+	}
+}
+
+func TestParseDependencyType(t *testing.T) {
+	for _, input := range []string{"autoInstall", "autoinstall", "auto"} {
+		if got, err := ParseDependencyType(input); err != nil || got != DependencyAutoInstall {
+			t.Errorf("ParseDependencyType(%q) = %s, %v, want autoInstall", input, got, err)
+		}
+	}
+	if _, err := ParseDependencyType("always"); err == nil {
+		t.Error("Expected error for invalid dependency type")
+	}
+}
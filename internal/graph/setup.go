@@ -0,0 +1,101 @@
+package graph
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// GraphResourceAppID is the application ID of Microsoft Graph, the API whose
+// permissions the app registration requests
+const GraphResourceAppID = "00000003-0000-0000-c000-000000000000"
+
+// Permission is a Microsoft Graph application permission (app role)
+type Permission struct {
+	// Name is the permission as shown in the Azure portal
+	Name string
+	// ID is the app role ID, as used by az ad app permission add
+	ID string
+	// Reason is what the permission is needed for
+	Reason string
+}
+
+// RequiredPermissions are the application permissions uploads, assignments and app
+// management need, all requiring admin consent
+var RequiredPermissions = []Permission{
+	{"DeviceManagementApps.ReadWrite.All", "78145de6-330d-4800-a6ce-494ff2d33d07", "create, update, upload and assign Win32 apps"},
+	{"Group.Read.All", "5b567255-7703-4780-807c-7be8301ae99b", "resolve assignment groups by name"},
+}
+
+// AdminConsentURL returns the page where an administrator of the tenant grants the
+// permissions of the app registration, in the public cloud
+func AdminConsentURL(tenantID, clientID string) string {
+	return CloudPublic.AdminConsentURL(tenantID, clientID)
+}
+
+// AccessCheck is the result of CheckAccess
+type AccessCheck struct {
+	// Roles are the application permissions granted to the app registration, nil when
+	// the token does not list them
+	Roles []string
+	// Missing are the RequiredPermissions that are not granted, when Roles are known
+	Missing []Permission
+	// Apps is the number of Win32 apps returned by the test request (at most one)
+	Apps int
+}
+
+// CheckAccess acquires a token and lists a Win32 app, to verify the credentials and the
+// permissions of the app registration before the first upload
+func (c *Client) CheckAccess(ctx context.Context) (*AccessCheck, error) {
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	check := &AccessCheck{Roles: tokenRoles(token)}
+	if check.Roles != nil {
+		for _, perm := range RequiredPermissions {
+			if !slices.Contains(check.Roles, perm.Name) {
+				check.Missing = append(check.Missing, perm)
+			}
+		}
+	}
+
+	query := url.Values{}
+	query.Set("$filter", win32AppFilter)
+	query.Set("$top", "1")
+	var resp struct {
+		Value []App `json:"value"`
+	}
+	if err := c.do(ctx, "GET", "/deviceAppManagement/mobileApps?"+query.Encode(), nil, &resp); err != nil {
+		return check, fmt.Errorf("failed to list apps: %w", err)
+	}
+	check.Apps = len(resp.Value)
+	return check, nil
+}
+
+// tokenRoles returns the roles claim of a JWT access token, or nil when the token is
+// not a JWT (the token is not verified, Graph does that)
+func tokenRoles(token string) []string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil
+	}
+	var claims struct {
+		Roles []string `json:"roles"`
+	}
+	if json.Unmarshal(payload, &claims) != nil {
+		return nil
+	}
+	if claims.Roles == nil {
+		return []string{}
+	}
+	return claims.Roles
+}
@@ -0,0 +1,91 @@
+package graph
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testJWT returns an unsigned JWT whose payload is the given JSON
+func testJWT(payload string) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"none"}`)) + "." + enc.EncodeToString([]byte(payload)) + ".sig"
+}
+
+func TestAdminConsentURL(t *testing.T) {
+	got := AdminConsentURL("contoso.onmicrosoft.com", "11111111-2222-3333-4444-555555555555")
+	want := "https://login.microsoftonline.com/contoso.onmicrosoft.com/adminconsent?client_id=11111111-2222-3333-4444-555555555555"
+	if got != want {
+		t.Errorf("AdminConsentURL() = %s, want %s", got, want)
+	}
+}
+
+func TestTokenRoles(t *testing.T) {
+	tests := map[string]struct {
+		token string
+		want  []string
+	}{
+		"roles":        {testJWT(`{"roles":["Group.Read.All"]}`), []string{"Group.Read.All"}},
+		"no roles":     {testJWT(`{"aud":"https://graph.microsoft.com"}`), []string{}},
+		"not a JWT":    {"mock-graph-token", nil},
+		"invalid JSON": {testJWT(`{"roles":`), nil},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := tokenRoles(tt.token)
+			if (got == nil) != (tt.want == nil) || strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("tokenRoles() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckAccess(t *testing.T) {
+	token := testJWT(`{"roles":["DeviceManagementApps.ReadWrite.All"]}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/oauth2/v2.0/token") {
+			w.Write([]byte(`{"access_token":"` + token + `","expires_in":3600}`))
+			return
+		}
+		if r.URL.Query().Get("$top") != "1" {
+			t.Errorf("$top = %s, want 1", r.URL.Query().Get("$top"))
+		}
+		w.Write([]byte(`{"value":[{"id":"a","displayName":"7-Zip"}]}`))
+	}))
+	defer server.Close()
+
+	check, err := newTestClient(server).CheckAccess(context.Background())
+	if err != nil {
+		t.Fatalf("CheckAccess() error = %v", err)
+	}
+	if check.Apps != 1 {
+		t.Errorf("Apps = %d, want 1", check.Apps)
+	}
+	if len(check.Missing) != 1 || check.Missing[0].Name != "Group.Read.All" {
+		t.Errorf("Missing = %+v, want Group.Read.All", check.Missing)
+	}
+}
+
+func TestCheckAccessForbidden(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handleToken(w, r) {
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error":{"code":"Forbidden","message":"Application is not authorized to perform this operation"}}`))
+	}))
+	defer server.Close()
+
+	check, err := newTestClient(server).CheckAccess(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("CheckAccess() error = %v, want a 403 APIError", err)
+	}
+	if check == nil || check.Roles != nil {
+		t.Errorf("CheckAccess() = %+v, want a check without roles", check)
+	}
+}
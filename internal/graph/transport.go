@@ -0,0 +1,204 @@
+package graph
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPOptions configures the retries and timeouts of the HTTP clients of NewHTTPClient
+type HTTPOptions struct {
+	// Timeout limits each attempt of a request, including reading its response (0: no limit)
+	Timeout time.Duration
+	// MaxRetries is how often a throttled or failed request is sent again
+	MaxRetries int
+	// MaxDelay caps the wait before a retry, including waits asked for by Retry-After
+	MaxDelay time.Duration
+	// Transport sends each attempt, such as the proxied transport of the network package
+	// (nil: http.DefaultTransport)
+	Transport http.RoundTripper
+}
+
+// DefaultHTTPOptions are the retries and timeouts of Graph requests
+var DefaultHTTPOptions = HTTPOptions{
+	Timeout:    60 * time.Second,
+	MaxRetries: 5,
+	MaxDelay:   2 * time.Minute,
+}
+
+// retryBaseDelay is the wait before the first retry without Retry-After, doubled for
+// each further retry (shortened in tests)
+var retryBaseDelay = time.Second
+
+// NewHTTPClient creates an HTTP client for Graph and Azure Storage requests
+// Requests answered with 429 Too Many Requests or 503 Service Unavailable are retried
+// after the Retry-After of the response, or with exponential backoff and jitter;
+// other server errors and network errors are only retried for idempotent methods,
+// so a create that may have succeeded is never sent twice
+func NewHTTPClient(opts HTTPOptions) *http.Client {
+	base := opts.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &http.Client{Transport: &retryTransport{base: base, opts: opts}}
+}
+
+// retryTransport retries throttled and failed requests and logs every attempt at debug level
+type retryTransport struct {
+	base http.RoundTripper
+	opts HTTPOptions
+}
+
+// RoundTrip sends a request until it succeeds, fails for good or runs out of retries
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		start := time.Now()
+		resp, err := t.send(req)
+		if err != nil {
+			slog.Debug("http request", "method", req.Method, "url", logURL(req), "attempt", attempt+1, "error", err)
+		} else {
+			slog.Debug("http request", "method", req.Method, "url", logURL(req), "attempt", attempt+1, "status", resp.StatusCode, "duration", time.Since(start))
+		}
+
+		if !t.shouldRetry(req, resp, err, attempt) {
+			return resp, err
+		}
+		delay := t.retryDelay(resp, attempt)
+		if resp != nil {
+			// Drain the body so the connection can be reused
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+			slog.Debug("retrying http request", "method", req.Method, "url", logURL(req), "status", resp.StatusCode, "delay", delay)
+		} else {
+			slog.Debug("retrying http request", "method", req.Method, "url", logURL(req), "error", err, "delay", delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// send performs one attempt of a request within the attempt timeout
+func (t *retryTransport) send(req *http.Request) (*http.Response, error) {
+	if t.opts.Timeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.opts.Timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The timeout covers reading the body, so it ends when the caller closes it
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// shouldRetry reports whether a request that got resp or err is worth sending again
+func (t *retryTransport) shouldRetry(req *http.Request, resp *http.Response, err error, attempt int) bool {
+	if attempt >= t.opts.MaxRetries || req.Context().Err() != nil {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false // the body cannot be sent again
+	}
+	if err != nil {
+		return idempotent(req.Method)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		// Throttled requests were not processed
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent(req.Method)
+	default:
+		return false
+	}
+}
+
+// retryDelay returns the wait before the next attempt: the Retry-After of resp, or
+// exponential backoff with jitter, capped at the maximum delay
+func (t *retryTransport) retryDelay(resp *http.Response, attempt int) time.Duration {
+	maxDelay := t.opts.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = DefaultHTTPOptions.MaxDelay
+	}
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return min(d, maxDelay)
+		}
+	}
+	backoff := retryBaseDelay << min(attempt, 16)
+	if backoff > maxDelay {
+		backoff = maxDelay
+	}
+	// Half fixed, half random, so clients throttled together do not retry together
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// idempotent reports whether sending a request twice has the effect of sending it once
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// logURL returns the URL of a request without its query, which may hold a SAS signature
+func logURL(req *http.Request) string {
+	u := *req.URL
+	u.RawQuery = ""
+	u.User = nil
+	return u.String()
+}
+
+// cancelBody releases the attempt timeout of a response when its body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
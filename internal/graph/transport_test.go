@@ -0,0 +1,197 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingServer answers requests with the statuses in order, then with 200 OK,
+// and counts the requests it got
+func countingServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	var count atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(count.Add(1))
+		if body, _ := io.ReadAll(r.Body); r.Method == http.MethodPost && string(body) != "payload" {
+			t.Errorf("Attempt %d got body %q", n, body)
+		}
+		if n <= len(statuses) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &count
+}
+
+func TestRetryTransport(t *testing.T) {
+	defer func(d time.Duration) { retryBaseDelay = d }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+
+	tests := map[string]struct {
+		method       string
+		statuses     []int
+		wantStatus   int
+		wantRequests int32
+	}{
+		"throttled get":          {"GET", []int{429, 429}, 200, 3},
+		"throttled post":         {"POST", []int{429, 503}, 200, 3},
+		"server error get":       {"GET", []int{500, 502, 504}, 200, 4},
+		"server error post":      {"POST", []int{500}, 500, 1},
+		"client error":           {"GET", []int{404}, 404, 1},
+		"retries exhausted":      {"DELETE", []int{503, 503, 503, 503}, 503, 4},
+		"success without retry":  {"PUT", nil, 200, 1},
+		"gateway timeout on put": {"PUT", []int{504}, 200, 2},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server, count := countingServer(t, tt.statuses...)
+			client := NewHTTPClient(HTTPOptions{Timeout: 5 * time.Second, MaxRetries: 3})
+
+			req, err := http.NewRequest(tt.method, server.URL, strings.NewReader("payload"))
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := count.Load(); got != tt.wantRequests {
+				t.Errorf("Server got %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestRetryTransportAttemptTimeout(t *testing.T) {
+	defer func(d time.Duration) { retryBaseDelay = d }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+
+	var count atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if count.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(HTTPOptions{Timeout: 100 * time.Millisecond, MaxRetries: 1})
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "ok" {
+		t.Errorf("Body = %q, want ok", body)
+	}
+	if count.Load() != 2 {
+		t.Errorf("Server got %d requests, want 2", count.Load())
+	}
+}
+
+// roundTripFunc is a transport made of a function
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRetryTransportBase(t *testing.T) {
+	defer func(d time.Duration) { retryBaseDelay = d }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+
+	// Every attempt, retries included, goes through the transport of the options
+	server, count := countingServer(t, http.StatusTooManyRequests)
+	var attempts atomic.Int32
+	client := NewHTTPClient(HTTPOptions{Timeout: 5 * time.Second, MaxRetries: 3, Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		attempts.Add(1)
+		return http.DefaultTransport.RoundTrip(req)
+	})})
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+	if attempts.Load() != 2 || count.Load() != 2 {
+		t.Errorf("Transport sent %d of %d requests, want 2", attempts.Load(), count.Load())
+	}
+}
+
+func TestRetryTransportCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	start := time.Now()
+	_, err := NewHTTPClient(DefaultHTTPOptions).Do(req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do() error = %v, want deadline exceeded", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("Waiting for Retry-After should stop when the context ends")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		"seconds":   {"30", 30 * time.Second, true},
+		"http date": {"Fri, 16 Oct 2026 12:00:10 GMT", 10 * time.Second, true},
+		"past date": {"Fri, 16 Oct 2026 11:00:00 GMT", 0, true},
+		"empty":     {"", 0, false},
+		"negative":  {"-5", 0, false},
+		"garbage":   {"soon", 0, false},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	defer func(d time.Duration) { retryBaseDelay = d }(retryBaseDelay)
+	retryBaseDelay = time.Second
+
+	transport := &retryTransport{opts: HTTPOptions{MaxDelay: 10 * time.Second}}
+	for attempt := 0; attempt < 8; attempt++ {
+		backoff := min(time.Second<<attempt, 10*time.Second)
+		if d := transport.retryDelay(nil, attempt); d < backoff/2 || d > backoff {
+			t.Errorf("retryDelay(attempt %d) = %v, want between %v and %v", attempt, d, backoff/2, backoff)
+		}
+	}
+
+	resp := &http.Response{Header: http.Header{"Retry-After": {"600"}}}
+	if d := transport.retryDelay(resp, 0); d != 10*time.Second {
+		t.Errorf("retryDelay() = %v, want Retry-After capped at 10s", d)
+	}
+}
@@ -0,0 +1,215 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Content file upload states reported by Graph; states ending in Failed or TimedOut
+// end the upload
+const (
+	uploadStateURIReady      = "azureStorageUriRequestSuccess"
+	uploadStateCommitSuccess = "commitFileSuccess"
+)
+
+var (
+	// uploadBlockSize is the size of the blocks content is uploaded to Azure Storage in
+	// (shortened in tests)
+	uploadBlockSize int64 = 6 << 20
+	// uploadPollInterval is the wait between reads of the upload state of a content file
+	// (shortened in tests)
+	uploadPollInterval = 2 * time.Second
+	// uploadStateTimeout is how long Intune may take to hand out a storage URI or to
+	// process a commit
+	uploadStateTimeout = 10 * time.Minute
+)
+
+// FileEncryptionInfo is the encryption of package content, as in its Detection.xml
+// All values but the profile identifier and digest algorithm are base64 encoded
+type FileEncryptionInfo struct {
+	EncryptionKey        string `json:"encryptionKey"`
+	MacKey               string `json:"macKey"`
+	InitializationVector string `json:"initializationVector"`
+	Mac                  string `json:"mac"`
+	ProfileIdentifier    string `json:"profileIdentifier"`
+	FileDigest           string `json:"fileDigest"`
+	FileDigestAlgorithm  string `json:"fileDigestAlgorithm"`
+}
+
+// ContentUpload is the encrypted content of a package to upload to a Win32 app
+type ContentUpload struct {
+	// Name is the name of the content file, IntunePackage.intunewin
+	Name string
+	// Size is the size of the content before encryption
+	Size int64
+	// SizeEncrypted is the number of bytes Content yields
+	SizeEncrypted int64
+	// Content is the encrypted content
+	Content io.Reader
+	// Manifest is the Detection.xml of the package (optional)
+	Manifest []byte
+	// EncryptionInfo lets Intune decrypt the content
+	EncryptionInfo FileEncryptionInfo
+	// Progress is called with the bytes uploaded so far after each block (optional)
+	Progress func(uploaded int64)
+}
+
+// UploadContent uploads the content of a package to a Win32 app: it creates a content
+// version and file, uploads the content to the Azure Storage URI Intune hands out, commits
+// the file and makes the version the committed content of the app
+func (c *Client) UploadContent(ctx context.Context, appID string, upload ContentUpload) error {
+	var version struct {
+		ID string `json:"id"`
+	}
+	if err := c.do(ctx, "POST", contentVersionsPath(appID), map[string]any{}, &version); err != nil {
+		return fmt.Errorf("failed to create content version: %w", err)
+	}
+	filesPath := fmt.Sprintf("%s/%s/files", contentVersionsPath(appID), url.PathEscape(version.ID))
+
+	body := map[string]any{
+		"@odata.type":   "#microsoft.graph.mobileAppContentFile",
+		"name":          upload.Name,
+		"size":          upload.Size,
+		"sizeEncrypted": upload.SizeEncrypted,
+		"isDependency":  false,
+	}
+	if len(upload.Manifest) > 0 {
+		body["manifest"] = base64.StdEncoding.EncodeToString(upload.Manifest)
+	}
+	var file ContentFile
+	if err := c.do(ctx, "POST", filesPath, body, &file); err != nil {
+		return fmt.Errorf("failed to create content file: %w", err)
+	}
+	filePath := filesPath + "/" + url.PathEscape(file.ID)
+
+	if c.whatIf != nil {
+		blocks := (upload.SizeEncrypted + uploadBlockSize - 1) / uploadBlockSize
+		fmt.Fprintf(c.whatIf, "What-if: upload %d bytes in %d block(s) to Azure Storage\n\n", upload.SizeEncrypted, blocks)
+	} else {
+		ready, err := c.waitForUploadState(ctx, filePath, uploadStateURIReady)
+		if err != nil {
+			return err
+		}
+		if err := c.uploadBlocks(ctx, ready.AzureStorageURI, upload.Content, upload.SizeEncrypted, upload.Progress); err != nil {
+			return err
+		}
+	}
+
+	commit := map[string]any{"fileEncryptionInfo": upload.EncryptionInfo}
+	if err := c.do(ctx, "POST", filePath+"/commit", commit, nil); err != nil {
+		return fmt.Errorf("failed to commit content file: %w", err)
+	}
+	if c.whatIf == nil {
+		if _, err := c.waitForUploadState(ctx, filePath, uploadStateCommitSuccess); err != nil {
+			return err
+		}
+	}
+
+	return c.setCommittedContentVersion(ctx, appID, version.ID)
+}
+
+// waitForUploadState polls a content file until its upload state is want, and fails when
+// Intune reports a failure
+func (c *Client) waitForUploadState(ctx context.Context, filePath, want string) (*ContentFile, error) {
+	deadline := time.Now().Add(uploadStateTimeout)
+	for {
+		var file ContentFile
+		if err := c.do(ctx, "GET", filePath, nil, &file); err != nil {
+			return nil, fmt.Errorf("failed to read content file: %w", err)
+		}
+		if file.UploadState == want {
+			return &file, nil
+		}
+		if strings.HasSuffix(file.UploadState, "Failed") || strings.HasSuffix(file.UploadState, "TimedOut") {
+			return nil, fmt.Errorf("content upload failed: %s", file.UploadState)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("content file is still %s after %s, expected %s", file.UploadState, uploadStateTimeout, want)
+		}
+
+		timer := time.NewTimer(uploadPollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// uploadBlocks uploads content to a block blob at a SAS URI and commits its block list
+func (c *Client) uploadBlocks(ctx context.Context, sasURI string, content io.Reader, size int64, progress func(int64)) error {
+	buf := make([]byte, uploadBlockSize)
+	var blockIDs []string
+	var uploaded int64
+	for {
+		n, err := io.ReadFull(content, buf)
+		if n > 0 {
+			id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("block-%08d", len(blockIDs))))
+			target := withQuery(sasURI, "comp=block&blockid="+url.QueryEscape(id))
+			if err := c.putBlob(ctx, target, buf[:n]); err != nil {
+				return fmt.Errorf("failed to upload block %d: %w", len(blockIDs), err)
+			}
+			blockIDs = append(blockIDs, id)
+			uploaded += int64(n)
+			if progress != nil {
+				progress(uploaded)
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read content: %w", err)
+		}
+	}
+	if uploaded != size {
+		return fmt.Errorf("content has %d bytes, expected %d", uploaded, size)
+	}
+
+	var list strings.Builder
+	list.WriteString(`<?xml version="1.0" encoding="utf-8"?><BlockList>`)
+	for _, id := range blockIDs {
+		list.WriteString("<Latest>" + id + "</Latest>")
+	}
+	list.WriteString("</BlockList>")
+	if err := c.putBlob(ctx, withQuery(sasURI, "comp=blocklist"), []byte(list.String())); err != nil {
+		return fmt.Errorf("failed to commit block list: %w", err)
+	}
+	return nil
+}
+
+// putBlob sends a PUT to Azure Storage; the SAS URI carries the authorization
+func (c *Client) putBlob(ctx context.Context, target string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("PUT %s: %w", sanitizeURL(target), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("storage returned %s", resp.Status)
+	}
+	return nil
+}
+
+// withQuery appends query parameters to a URI that may already carry a SAS token
+func withQuery(uri, query string) string {
+	if strings.Contains(uri, "?") {
+		return uri + "&" + query
+	}
+	return uri + "?" + query
+}
@@ -0,0 +1,90 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testEncryptionInfo is the encryption of test content, which the mock only checks for presence
+var testEncryptionInfo = FileEncryptionInfo{
+	EncryptionKey: "a2V5", MacKey: "bWFj", InitializationVector: "aXY=", Mac: "bWFj",
+	ProfileIdentifier: "ProfileVersion1", FileDigest: "ZGlnZXN0", FileDigestAlgorithm: "SHA256",
+}
+
+func TestUploadContent(t *testing.T) {
+	defer func(size int64, interval time.Duration) {
+		uploadBlockSize, uploadPollInterval = size, interval
+	}(uploadBlockSize, uploadPollInterval)
+	uploadBlockSize, uploadPollInterval = 10, time.Millisecond
+
+	client, mock := newMockClient(t)
+	ctx := context.Background()
+	created, err := client.CreateWin32App(ctx, mockMsiApp("7-Zip"), ConflictFail)
+	if err != nil {
+		t.Fatalf("CreateWin32App() error = %v", err)
+	}
+
+	content := []byte("encrypted package content")
+	var progress []int64
+	upload := ContentUpload{
+		Name:           "IntunePackage.intunewin",
+		Size:           20,
+		SizeEncrypted:  int64(len(content)),
+		Content:        bytes.NewReader(content),
+		Manifest:       []byte("<ApplicationInfo />"),
+		EncryptionInfo: testEncryptionInfo,
+		Progress:       func(uploaded int64) { progress = append(progress, uploaded) },
+	}
+	if err := client.UploadContent(ctx, created.App.ID, upload); err != nil {
+		t.Fatalf("UploadContent() error = %v", err)
+	}
+	if want := []int64{10, 20, 25}; !slices.Equal(progress, want) {
+		t.Errorf("Progress = %v, want %v after each block", progress, want)
+	}
+
+	committed, err := client.CommittedContentFile(ctx, created.App.ID)
+	if err != nil {
+		t.Fatalf("CommittedContentFile() error = %v", err)
+	}
+	data, err := client.DownloadContentFile(ctx, committed)
+	if err != nil {
+		t.Fatalf("DownloadContentFile() error = %v", err)
+	}
+	if string(data) != string(content) {
+		t.Errorf("Downloaded %q, want %q", data, content)
+	}
+	if state := mock.Apps()[0].Object["publishingState"]; state != "published" {
+		t.Errorf("publishingState = %v, want published", state)
+	}
+
+	// Content shorter than announced is not committed
+	upload.Content = bytes.NewReader(content[:5])
+	if err := client.UploadContent(ctx, created.App.ID, upload); err == nil {
+		t.Error("Expected error for content shorter than sizeEncrypted")
+	}
+}
+
+func TestUploadContentWhatIf(t *testing.T) {
+	client, mock := newMockClient(t)
+	var out bytes.Buffer
+	client.SetWhatIf(&out)
+
+	upload := ContentUpload{Name: "IntunePackage.intunewin", Size: 10, SizeEncrypted: 13 << 20, EncryptionInfo: testEncryptionInfo}
+	if err := client.UploadContent(context.Background(), WhatIfID, upload); err != nil {
+		t.Fatalf("UploadContent() error = %v", err)
+	}
+
+	text := out.String()
+	for _, want := range []string{"/contentVersions\n", "upload 13631488 bytes in 3 block(s)", "/commit", "redacted", "PATCH"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("What-if output missing %q:\n%s", want, text)
+		}
+	}
+	if len(mock.Apps()) != 0 {
+		t.Error("What-if mode should not change the tenant")
+	}
+}
@@ -0,0 +1,107 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// productCodeDetectionType is the @odata.type of MSI product code detection rules
+const productCodeDetectionType = "#microsoft.graph.win32LobAppProductCodeDetection"
+
+// AppVersion is the metadata of a Win32 app that changes with each version of its package
+type AppVersion struct {
+	DisplayVersion       string
+	FileName             string
+	SetupFile            string
+	InstallCommandLine   string
+	UninstallCommandLine string
+	// Msi is the MSI information of the new version, nil for non-MSI apps
+	Msi *MsiInformation
+	// DetectionRules replace the detection rules of the app when set
+	DetectionRules []map[string]any
+}
+
+// UpdateAppVersion updates the version metadata of a Win32 app, leaving its other
+// metadata, relationships and assignments as they are
+func (c *Client) UpdateAppVersion(ctx context.Context, appID string, v AppVersion) error {
+	body := map[string]any{"@odata.type": "#microsoft.graph.win32LobApp"}
+	for key, value := range map[string]string{
+		"displayVersion":       v.DisplayVersion,
+		"fileName":             v.FileName,
+		"setupFilePath":        v.SetupFile,
+		"installCommandLine":   v.InstallCommandLine,
+		"uninstallCommandLine": v.UninstallCommandLine,
+	} {
+		if value != "" {
+			body[key] = value
+		}
+	}
+	if v.Msi != nil {
+		body["msiInformation"] = map[string]any{
+			"productCode":    v.Msi.ProductCode,
+			"productVersion": v.Msi.ProductVersion,
+			"upgradeCode":    v.Msi.UpgradeCode,
+			"packageType":    "perMachine",
+			"requiresReboot": false,
+		}
+	}
+	if len(v.DetectionRules) > 0 {
+		body["detectionRules"] = v.DetectionRules
+	}
+
+	if err := c.do(ctx, "PATCH", fmt.Sprintf("/deviceAppManagement/mobileApps/%s", url.PathEscape(appID)), body, nil); err != nil {
+		return fmt.Errorf("failed to update app %s: %w", appID, err)
+	}
+	return nil
+}
+
+// ProductCodeDetection returns a detection rule that detects an MSI by its product code
+func ProductCodeDetection(productCode string) map[string]any {
+	return map[string]any{
+		"@odata.type":            productCodeDetectionType,
+		"productCode":            productCode,
+		"productVersionOperator": "notConfigured",
+	}
+}
+
+// FileDetection returns a detection rule that detects an app by the existence of a file,
+// given as full path
+func FileDetection(path string) (map[string]any, error) {
+	dir, file := splitWindowsPath(path)
+	if dir == "" {
+		return nil, fmt.Errorf("detection file must be a full path: %s", path)
+	}
+	return map[string]any{
+		"@odata.type":          "#microsoft.graph.win32LobAppFileSystemDetection",
+		"path":                 dir,
+		"fileOrFolderName":     file,
+		"check32BitOn64System": false,
+		"detectionType":        "exists",
+	}, nil
+}
+
+// BumpProductCodeRules returns detection rules with the product code detection rules of a
+// previous MSI switched to a new product code and version; the other rules are kept
+// ok is false when none of the rules detects a product code
+func BumpProductCodeRules(rules []map[string]any, productCode, productVersion string) (bumped []map[string]any, ok bool) {
+	for _, rule := range rules {
+		if rule["@odata.type"] != productCodeDetectionType {
+			bumped = append(bumped, rule)
+			continue
+		}
+		updated := make(map[string]any, len(rule))
+		for key, value := range rule {
+			updated[key] = value
+		}
+		updated["productCode"] = productCode
+		// A version check of the previous version would no longer detect the new one
+		if op, _ := updated["productVersionOperator"].(string); op != "" && !strings.EqualFold(op, "notConfigured") {
+			updated["productVersion"] = productVersion
+		}
+		bumped = append(bumped, updated)
+		ok = true
+	}
+	return bumped, ok
+}
@@ -0,0 +1,106 @@
+package graph
+
+import (
+	"context"
+	"testing"
+)
+
+func TestUpdateAppVersion(t *testing.T) {
+	client, mock := newMockClient(t)
+	ctx := context.Background()
+	created, err := client.CreateWin32App(ctx, mockMsiApp("7-Zip"), ConflictFail)
+	if err != nil {
+		t.Fatalf("CreateWin32App() error = %v", err)
+	}
+	group := mock.AddGroup("All Workstations")
+	if err := client.AssignApp(ctx, created.App.ID, []Assignment{{GroupID: group.ID, Intent: IntentRequired}}); err != nil {
+		t.Fatalf("AssignApp() error = %v", err)
+	}
+
+	newCode := "{23170F69-40C1-2702-2409-000001000000}"
+	err = client.UpdateAppVersion(ctx, created.App.ID, AppVersion{
+		DisplayVersion:       "24.09",
+		FileName:             "7z2409-x64.intunewin",
+		SetupFile:            "7z2409-x64.msi",
+		UninstallCommandLine: "msiexec /x " + newCode + " /qn",
+		Msi:                  &MsiInformation{ProductCode: newCode, ProductVersion: "24.09.00.0"},
+		DetectionRules:       []map[string]any{ProductCodeDetection(newCode)},
+	})
+	if err != nil {
+		t.Fatalf("UpdateAppVersion() error = %v", err)
+	}
+
+	app, err := client.GetWin32App(ctx, created.App.ID)
+	if err != nil {
+		t.Fatalf("GetWin32App() error = %v", err)
+	}
+	if app.DisplayVersion != "24.09" || app.SetupFile != "7z2409-x64.msi" || app.MsiProductCode != newCode {
+		t.Errorf("App = %+v, want the new version", app)
+	}
+	if app.DisplayName != "7-Zip" || app.InstallCommandLine != `msiexec /i "7z2401-x64.msi" /qn` {
+		t.Errorf("App = %+v, want unchanged fields kept", app)
+	}
+	if len(app.DetectionRules) != 1 || app.DetectionRules[0]["productCode"] != newCode {
+		t.Errorf("DetectionRules = %v, want the new product code", app.DetectionRules)
+	}
+	assignments, err := client.ListAssignments(ctx, created.App.ID)
+	if err != nil {
+		t.Fatalf("ListAssignments() error = %v", err)
+	}
+	if len(assignments) != 1 {
+		t.Errorf("Assignments = %v, want the assignment kept", assignments)
+	}
+	if len(mock.Apps()) != 1 {
+		t.Errorf("Apps = %d, want the app updated in place", len(mock.Apps()))
+	}
+}
+
+func TestBumpProductCodeRules(t *testing.T) {
+	registry := map[string]any{"@odata.type": "#microsoft.graph.win32LobAppRegistryDetection", "keyPath": `HKLM\SOFTWARE\7-Zip`}
+	tests := map[string]struct {
+		rules       []map[string]any
+		wantOK      bool
+		wantVersion any
+	}{
+		"product code":      {[]map[string]any{ProductCodeDetection("{OLD}")}, true, nil},
+		"version operator":  {[]map[string]any{{"@odata.type": productCodeDetectionType, "productCode": "{OLD}", "productVersionOperator": "greaterThanOrEqual", "productVersion": "24.01"}}, true, "24.09"},
+		"with other rule":   {[]map[string]any{registry, ProductCodeDetection("{OLD}")}, true, nil},
+		"no product code":   {[]map[string]any{registry}, false, nil},
+		"no detection rule": {nil, false, nil},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			bumped, ok := BumpProductCodeRules(tt.rules, "{NEW}", "24.09")
+			if ok != tt.wantOK {
+				t.Fatalf("BumpProductCodeRules() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if len(bumped) != len(tt.rules) {
+				t.Fatalf("BumpProductCodeRules() = %v, want %d rules", bumped, len(tt.rules))
+			}
+			for i, rule := range bumped {
+				if rule["@odata.type"] != productCodeDetectionType {
+					continue
+				}
+				if rule["productCode"] != "{NEW}" || rule["productVersion"] != tt.wantVersion {
+					t.Errorf("Rule = %v, want product code {NEW} and version %v", rule, tt.wantVersion)
+				}
+				if tt.rules[i]["productCode"] != "{OLD}" {
+					t.Error("BumpProductCodeRules() changed the rules passed in")
+				}
+			}
+		})
+	}
+}
+
+func TestFileDetection(t *testing.T) {
+	rule, err := FileDetection(`C:\Program Files\7-Zip\7z.exe`)
+	if err != nil {
+		t.Fatalf("FileDetection() error = %v", err)
+	}
+	if rule["path"] != `C:\Program Files\7-Zip` || rule["fileOrFolderName"] != "7z.exe" {
+		t.Errorf("FileDetection() = %v", rule)
+	}
+	if _, err := FileDetection("7z.exe"); err == nil {
+		t.Error("Expected error for a file name without folder")
+	}
+}
@@ -0,0 +1,100 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// WhatIfID is the ID returned for objects that would be created in what-if mode
+const WhatIfID = "00000000-0000-0000-0000-000000000000"
+
+// redacted replaces sensitive values in what-if output
+const redacted = "<redacted>"
+
+// sensitiveFields are JSON properties whose values are never printed
+var sensitiveFields = map[string]bool{
+	"encryptionKey":        true,
+	"macKey":               true,
+	"initializationVector": true,
+	"mac":                  true,
+	"fileDigest":           true,
+	"client_secret":        true,
+}
+
+// SetWhatIf switches the client to what-if mode: reads are still sent,
+// but writes are printed to w (sanitized) instead of being executed
+func (c *Client) SetWhatIf(w io.Writer) {
+	c.whatIf = w
+}
+
+// WhatIf reports whether the client is in what-if mode
+func (c *Client) WhatIf() bool {
+	return c.whatIf != nil
+}
+
+// planRequest prints a write request instead of sending it and fills out as
+// Graph would for a created object
+func (c *Client) planRequest(method, path string, body, out any) error {
+	fmt.Fprintf(c.whatIf, "What-if: %s %s\n", method, sanitizeURL(c.baseURL+path))
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		var generic any
+		if err := json.Unmarshal(data, &generic); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		pretty, err := json.MarshalIndent(sanitizeJSON(generic), "  ", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		fmt.Fprintf(c.whatIf, "  %s\n", pretty)
+	}
+	fmt.Fprintln(c.whatIf)
+
+	if out != nil {
+		return json.Unmarshal([]byte(`{"id":"`+WhatIfID+`"}`), out)
+	}
+	return nil
+}
+
+// sanitizeJSON redacts sensitive fields of a decoded JSON value
+func sanitizeJSON(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, inner := range val {
+			if sensitiveFields[k] {
+				val[k] = redacted
+				continue
+			}
+			val[k] = sanitizeJSON(inner)
+		}
+	case []any:
+		for i, inner := range val {
+			val[i] = sanitizeJSON(inner)
+		}
+	case string:
+		if strings.HasPrefix(val, "https://") {
+			return sanitizeURL(val)
+		}
+	}
+	return v
+}
+
+// sanitizeURL redacts shared access signatures from a URL
+func sanitizeURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.RawQuery == "" {
+		return raw
+	}
+	query := u.Query()
+	if query.Has("sig") {
+		query.Set("sig", redacted)
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
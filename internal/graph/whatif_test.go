@@ -0,0 +1,79 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWhatIfDoesNotWrite(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handleToken(w, r) {
+			return
+		}
+		if r.Method != "GET" {
+			t.Errorf("Write request sent in what-if mode: %s %s", r.Method, r.URL.Path)
+		}
+		if strings.Contains(r.URL.Path, WhatIfID) {
+			t.Errorf("Read of what-if object sent: %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"value":[]}`))
+	}))
+	defer server.Close()
+
+	var plan bytes.Buffer
+	client := newTestClient(server)
+	client.SetWhatIf(&plan)
+
+	result, err := client.CreateWin32App(context.Background(), testWin32App(), ConflictFail)
+	if err != nil {
+		t.Fatalf("CreateWin32App() error = %v", err)
+	}
+	if result.App.ID != WhatIfID {
+		t.Errorf("App ID = %s, want what-if placeholder", result.App.ID)
+	}
+
+	err = client.AddRelationships(context.Background(), result.App.ID, []Relationship{
+		{TargetID: "old-app", Supersedence: SupersedenceUpdate},
+	})
+	if err != nil {
+		t.Fatalf("AddRelationships() error = %v", err)
+	}
+
+	out := plan.String()
+	for _, want := range []string{
+		"What-if: POST " + server.URL + "/beta/deviceAppManagement/mobileApps\n",
+		`"displayName": "7-Zip"`,
+		"/mobileApps/" + WhatIfID + "/updateRelationships",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("What-if output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestSanitizeJSON(t *testing.T) {
+	body := map[string]any{
+		"fileEncryptionInfo": map[string]any{
+			"encryptionKey":     "secret-key",
+			"macKey":            "secret-mac",
+			"profileIdentifier": "ProfileVersion1",
+		},
+		"uri": "https://storage.example.com/file?sv=2020&sig=abcdef",
+	}
+
+	sanitized := sanitizeJSON(body).(map[string]any)
+	enc := sanitized["fileEncryptionInfo"].(map[string]any)
+	if enc["encryptionKey"] != redacted || enc["macKey"] != redacted {
+		t.Errorf("Keys not redacted: %v", enc)
+	}
+	if enc["profileIdentifier"] != "ProfileVersion1" {
+		t.Errorf("profileIdentifier = %v, want unchanged", enc["profileIdentifier"])
+	}
+	if strings.Contains(sanitized["uri"].(string), "abcdef") {
+		t.Errorf("SAS signature not redacted: %s", sanitized["uri"])
+	}
+}
@@ -0,0 +1,358 @@
+package graph
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ConflictPolicy decides what happens when an app with the same display name already exists
+type ConflictPolicy string
+
+const (
+	// ConflictFail refuses to create a duplicate app
+	ConflictFail ConflictPolicy = "fail"
+	// ConflictSuffix appends the app version to the display name
+	ConflictSuffix ConflictPolicy = "suffix"
+	// ConflictUpdate updates the metadata of the existing app instead of creating one
+	ConflictUpdate ConflictPolicy = "update"
+)
+
+// ParseConflictPolicy converts a user-supplied string into a ConflictPolicy
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch strings.ToLower(s) {
+	case "fail":
+		return ConflictFail, nil
+	case "suffix":
+		return ConflictSuffix, nil
+	case "update":
+		return ConflictUpdate, nil
+	default:
+		return "", fmt.Errorf("invalid conflict policy: %s (supported: fail, suffix, update)", s)
+	}
+}
+
+// Win32App describes the metadata of a Win32 app to create in Intune
+type Win32App struct {
+	DisplayName          string `json:"displayName,omitempty"`
+	DisplayVersion       string `json:"displayVersion,omitempty"`
+	Description          string `json:"description,omitempty"`
+	Publisher            string `json:"publisher,omitempty"`
+	FileName             string `json:"fileName,omitempty"`      // Name of the .intunewin file
+	SetupFile            string `json:"setupFilePath,omitempty"` // Setup file inside the package (from Detection.xml)
+	InstallCommandLine   string `json:"installCommandLine,omitempty"`
+	UninstallCommandLine string `json:"uninstallCommandLine,omitempty"`
+	Architectures        string `json:"applicableArchitectures,omitempty"` // Applicable architectures (e.g., "x64" or "x86,x64")
+
+	// MSI apps are detected by product code
+	MsiProductCode    string `json:"msiProductCode,omitempty"`
+	MsiProductVersion string `json:"msiProductVersion,omitempty"`
+	MsiUpgradeCode    string `json:"msiUpgradeCode,omitempty"`
+
+	// DetectionFile is a full file path whose existence detects non-MSI apps
+	DetectionFile string `json:"detectionFile,omitempty"`
+	// DetectionRules are Graph detection rules used as-is instead of MSI or file
+	// detection, e.g. from an exported app
+	DetectionRules []map[string]any `json:"detectionRules,omitempty"`
+
+	// Optional store metadata
+	InformationURL        string `json:"informationUrl,omitempty"`
+	PrivacyInformationURL string `json:"privacyInformationUrl,omitempty"`
+	Developer             string `json:"developer,omitempty"`
+	Owner                 string `json:"owner,omitempty"`
+	Notes                 string `json:"notes,omitempty"`
+
+	// LargeIcon is the PNG image shown for the app in the Company Portal (optional)
+	LargeIcon []byte `json:"largeIcon,omitempty"`
+
+	// RunAsAccount is "system" (default) or "user"
+	RunAsAccount string `json:"runAsAccount,omitempty"`
+	// RestartBehavior is the device restart behavior (default basedOnReturnCode)
+	RestartBehavior string `json:"restartBehavior,omitempty"`
+
+	Requirements *Requirements `json:"requirements,omitempty"`
+	ReturnCodes  []ReturnCode  `json:"returnCodes,omitempty"`
+}
+
+// Requirements are the conditions a device must meet before the app is installed
+type Requirements struct {
+	// MinimumWindowsRelease is a Windows 10/11 release such as "1607" or "21H2"
+	MinimumWindowsRelease  string `json:"minimumSupportedWindowsRelease,omitempty"`
+	MinimumFreeDiskSpaceMB int    `json:"minimumFreeDiskSpaceInMB,omitempty"`
+	MinimumMemoryMB        int    `json:"minimumMemoryInMB,omitempty"`
+	MinimumProcessors      int    `json:"minimumNumberOfProcessors,omitempty"`
+	MinimumCPUSpeedMHz     int    `json:"minimumCpuSpeedInMHz,omitempty"`
+	// Rules are Graph requirement rules (file, registry or script), used as-is
+	Rules []map[string]any `json:"requirementRules,omitempty"`
+}
+
+// ReturnCode maps an installer exit code to the result Intune reports
+type ReturnCode struct {
+	Code int    `json:"returnCode"`
+	Type string `json:"type"` // success, softReboot, hardReboot, retry or failed
+}
+
+// returnCodeTypes are the results Intune reports for an installer exit code
+var returnCodeTypes = []string{"success", "softReboot", "hardReboot", "retry", "failed"}
+
+// DefaultReturnCodes are the return codes Intune gives a Win32 app created without any;
+// an app with return codes has only those, so custom codes are merged into these
+var DefaultReturnCodes = []ReturnCode{
+	{Code: 0, Type: "success"},
+	{Code: 1707, Type: "success"},
+	{Code: 3010, Type: "softReboot"},
+	{Code: 1641, Type: "hardReboot"},
+	{Code: 1618, Type: "retry"},
+}
+
+// ParseReturnCodeType validates a return code type, also given as e.g. "soft reboot"
+// or "soft-reboot", and returns its Graph name
+func ParseReturnCodeType(s string) (string, error) {
+	folded := strings.NewReplacer(" ", "", "-", "", "_", "").Replace(s)
+	for _, t := range returnCodeTypes {
+		if strings.EqualFold(folded, t) {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("invalid return code type: %s (supported: %s)", s, strings.Join(returnCodeTypes, ", "))
+}
+
+// ParseReturnCode parses a CODE=TYPE mapping such as 3010=softReboot
+func ParseReturnCode(s string) (ReturnCode, error) {
+	code, typ, ok := strings.Cut(s, "=")
+	if !ok {
+		return ReturnCode{}, fmt.Errorf("invalid return code %q: want CODE=TYPE, e.g. 3010=softReboot", s)
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(code), 10, 32)
+	if err != nil {
+		return ReturnCode{}, fmt.Errorf("invalid return code %q: %s is not a 32-bit exit code", s, code)
+	}
+	t, err := ParseReturnCodeType(strings.TrimSpace(typ))
+	if err != nil {
+		return ReturnCode{}, err
+	}
+	return ReturnCode{Code: int(n), Type: t}, nil
+}
+
+// MergeReturnCodes returns base with the codes of overrides replaced or added, in code order
+func MergeReturnCodes(base, overrides []ReturnCode) []ReturnCode {
+	types := make(map[int]string, len(base)+len(overrides))
+	for _, rc := range base {
+		types[rc.Code] = rc.Type
+	}
+	for _, rc := range overrides {
+		types[rc.Code] = rc.Type
+	}
+	merged := make([]ReturnCode, 0, len(types))
+	for code, t := range types {
+		merged = append(merged, ReturnCode{Code: code, Type: t})
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Code < merged[j].Code })
+	return merged
+}
+
+// CreateResult reports how CreateWin32App resolved a name conflict
+type CreateResult struct {
+	App     App
+	Updated bool // An existing app was updated instead of creating a new one
+}
+
+// CreateWin32App creates a Win32 app, applying policy when an app with
+// the same display name already exists in the tenant
+func (c *Client) CreateWin32App(ctx context.Context, app Win32App, policy ConflictPolicy) (*CreateResult, error) {
+	existing, err := c.FindAppsByName(ctx, app.DisplayName)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(existing) > 0 {
+		switch policy {
+		case ConflictSuffix:
+			if app.DisplayVersion == "" {
+				return nil, fmt.Errorf("app %q already exists and no version is known to suffix the name with", app.DisplayName)
+			}
+			app.DisplayName = fmt.Sprintf("%s %s", app.DisplayName, app.DisplayVersion)
+			existing, err = c.FindAppsByName(ctx, app.DisplayName)
+			if err != nil {
+				return nil, err
+			}
+			if len(existing) > 0 {
+				return nil, fmt.Errorf("app %q already exists (%s)", app.DisplayName, existing[0].ID)
+			}
+		case ConflictUpdate:
+			if len(existing) > 1 {
+				return nil, fmt.Errorf("app name %q is ambiguous (%d matches), cannot pick one to update", app.DisplayName, len(existing))
+			}
+			return c.updateWin32App(ctx, existing[0].ID, app)
+		default:
+			return nil, fmt.Errorf("app %q already exists (%s)", app.DisplayName, existing[0].ID)
+		}
+	}
+
+	body, err := win32AppBody(app)
+	if err != nil {
+		return nil, err
+	}
+
+	var created App
+	if err := c.do(ctx, "POST", "/deviceAppManagement/mobileApps", body, &created); err != nil {
+		return nil, fmt.Errorf("failed to create app %q: %w", app.DisplayName, err)
+	}
+	return &CreateResult{App: created}, nil
+}
+
+// updateWin32App replaces the metadata of an existing Win32 app
+func (c *Client) updateWin32App(ctx context.Context, appID string, app Win32App) (*CreateResult, error) {
+	body, err := win32AppBody(app)
+	if err != nil {
+		return nil, err
+	}
+
+	path := fmt.Sprintf("/deviceAppManagement/mobileApps/%s", url.PathEscape(appID))
+	if err := c.do(ctx, "PATCH", path, body, nil); err != nil {
+		return nil, fmt.Errorf("failed to update app %q: %w", app.DisplayName, err)
+	}
+
+	return &CreateResult{
+		App: App{
+			ID:             appID,
+			DisplayName:    app.DisplayName,
+			DisplayVersion: app.DisplayVersion,
+			Publisher:      app.Publisher,
+		},
+		Updated: true,
+	}, nil
+}
+
+// win32AppBody builds the Graph win32LobApp representation of an app
+func win32AppBody(app Win32App) (map[string]any, error) {
+	if app.DisplayName == "" {
+		return nil, fmt.Errorf("app display name is required")
+	}
+	if app.InstallCommandLine == "" || app.UninstallCommandLine == "" {
+		return nil, fmt.Errorf("install and uninstall command lines are required")
+	}
+
+	description := app.Description
+	if description == "" {
+		description = app.DisplayName
+	}
+	architectures := app.Architectures
+	if architectures == "" {
+		architectures = "x64"
+	}
+	runAs := app.RunAsAccount
+	if runAs == "" {
+		runAs = "system"
+	}
+	restart := app.RestartBehavior
+	if restart == "" {
+		restart = "basedOnReturnCode"
+	}
+
+	body := map[string]any{
+		"@odata.type":             "#microsoft.graph.win32LobApp",
+		"displayName":             app.DisplayName,
+		"displayVersion":          app.DisplayVersion,
+		"description":             description,
+		"publisher":               app.Publisher,
+		"fileName":                app.FileName,
+		"setupFilePath":           app.SetupFile,
+		"installCommandLine":      app.InstallCommandLine,
+		"uninstallCommandLine":    app.UninstallCommandLine,
+		"applicableArchitectures": architectures,
+		"minimumSupportedOperatingSystem": map[string]any{
+			"v10_1607": true,
+		},
+		"installExperience": map[string]any{
+			"runAsAccount":          runAs,
+			"deviceRestartBehavior": restart,
+		},
+	}
+	for key, value := range map[string]string{
+		"informationUrl":        app.InformationURL,
+		"privacyInformationUrl": app.PrivacyInformationURL,
+		"developer":             app.Developer,
+		"owner":                 app.Owner,
+		"notes":                 app.Notes,
+	} {
+		if value != "" {
+			body[key] = value
+		}
+	}
+	if len(app.LargeIcon) > 0 {
+		body["largeIcon"] = map[string]any{
+			"@odata.type": "#microsoft.graph.mimeContent",
+			"type":        "image/png",
+			"value":       base64.StdEncoding.EncodeToString(app.LargeIcon),
+		}
+	}
+	if req := app.Requirements; req != nil {
+		if req.MinimumWindowsRelease != "" {
+			body["minimumSupportedWindowsRelease"] = req.MinimumWindowsRelease
+		}
+		for key, value := range map[string]int{
+			"minimumFreeDiskSpaceInMB":  req.MinimumFreeDiskSpaceMB,
+			"minimumMemoryInMB":         req.MinimumMemoryMB,
+			"minimumNumberOfProcessors": req.MinimumProcessors,
+			"minimumCpuSpeedInMHz":      req.MinimumCPUSpeedMHz,
+		} {
+			if value > 0 {
+				body[key] = value
+			}
+		}
+		if len(req.Rules) > 0 {
+			body["requirementRules"] = req.Rules
+		}
+	}
+	if len(app.ReturnCodes) > 0 {
+		body["returnCodes"] = app.ReturnCodes
+	}
+
+	if app.MsiProductCode != "" {
+		body["msiInformation"] = map[string]any{
+			"productCode":    app.MsiProductCode,
+			"productVersion": app.MsiProductVersion,
+			"upgradeCode":    app.MsiUpgradeCode,
+			"productName":    app.DisplayName,
+			"publisher":      app.Publisher,
+			"packageType":    "perMachine",
+			"requiresReboot": false,
+		}
+	}
+
+	switch {
+	case len(app.DetectionRules) > 0:
+		for _, rule := range app.DetectionRules {
+			if _, ok := rule["@odata.type"].(string); !ok {
+				return nil, fmt.Errorf("detection rule without @odata.type: %v", rule)
+			}
+		}
+		body["detectionRules"] = app.DetectionRules
+	case app.MsiProductCode != "":
+		body["detectionRules"] = []map[string]any{ProductCodeDetection(app.MsiProductCode)}
+	case app.DetectionFile != "":
+		rule, err := FileDetection(app.DetectionFile)
+		if err != nil {
+			return nil, err
+		}
+		body["detectionRules"] = []map[string]any{rule}
+	default:
+		return nil, fmt.Errorf("a detection rule is required: package an MSI or set a detection file")
+	}
+
+	return body, nil
+}
+
+// splitWindowsPath splits a Windows path into directory and file name
+func splitWindowsPath(path string) (dir, file string) {
+	i := strings.LastIndexAny(path, `\/`)
+	if i < 0 {
+		return "", path
+	}
+	return path[:i], path[i+1:]
+}
@@ -0,0 +1,206 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTenantServer serves a tenant that already contains the named Win32 apps
+// and records the method and body of every write request
+func newTenantServer(t *testing.T, existing map[string]string, writes *[]string, bodies *[]map[string]any) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handleToken(w, r) {
+			return
+		}
+		if r.Method == "GET" {
+			filter := r.URL.Query().Get("$filter")
+			for name, id := range existing {
+				if strings.HasSuffix(filter, "displayName eq "+odataQuote(name)) {
+					w.Write([]byte(`{"value":[{"id":"` + id + `","displayName":"` + name + `"}]}`))
+					return
+				}
+			}
+			w.Write([]byte(`{"value":[]}`))
+			return
+		}
+
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		*writes = append(*writes, r.Method+" "+r.URL.Path)
+		*bodies = append(*bodies, body)
+		if r.Method == "POST" {
+			w.Write([]byte(`{"id":"new-id","displayName":"` + body["displayName"].(string) + `"}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func testWin32App() Win32App {
+	return Win32App{
+		DisplayName:          "7-Zip",
+		DisplayVersion:       "24.01",
+		FileName:             "7z2401-x64.intunewin",
+		SetupFile:            "7z2401-x64.msi",
+		InstallCommandLine:   `msiexec /i "7z2401-x64.msi" /qn`,
+		UninstallCommandLine: "msiexec /x {23170F69-40C1-2702-2401-000001000000} /qn",
+		MsiProductCode:       "{23170F69-40C1-2702-2401-000001000000}",
+	}
+}
+
+func TestCreateWin32AppConflictPolicies(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     ConflictPolicy
+		existing   map[string]string
+		wantErr    bool
+		wantWrite  string
+		wantName   string
+		wantUpdate bool
+	}{
+		{"no conflict", ConflictFail, nil, false, "POST /beta/deviceAppManagement/mobileApps", "7-Zip", false},
+		{"fail", ConflictFail, map[string]string{"7-Zip": "old-id"}, true, "", "", false},
+		{"suffix", ConflictSuffix, map[string]string{"7-Zip": "old-id"}, false, "POST /beta/deviceAppManagement/mobileApps", "7-Zip 24.01", false},
+		{"suffix taken", ConflictSuffix, map[string]string{"7-Zip": "old-id", "7-Zip 24.01": "other-id"}, true, "", "", false},
+		{"update", ConflictUpdate, map[string]string{"7-Zip": "old-id"}, false, "PATCH /beta/deviceAppManagement/mobileApps/old-id", "7-Zip", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			var bodies []map[string]any
+			server := newTenantServer(t, tt.existing, &writes, &bodies)
+			defer server.Close()
+
+			result, err := newTestClient(server).CreateWin32App(context.Background(), testWin32App(), tt.policy)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected conflict error")
+				}
+				if len(writes) != 0 {
+					t.Errorf("Writes = %v, want none", writes)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateWin32App() error = %v", err)
+			}
+			if len(writes) != 1 || writes[0] != tt.wantWrite {
+				t.Fatalf("Writes = %v, want [%s]", writes, tt.wantWrite)
+			}
+			if bodies[0]["displayName"] != tt.wantName {
+				t.Errorf("displayName = %v, want %s", bodies[0]["displayName"], tt.wantName)
+			}
+			if result.Updated != tt.wantUpdate {
+				t.Errorf("Updated = %v, want %v", result.Updated, tt.wantUpdate)
+			}
+		})
+	}
+}
+
+func TestWin32AppBodyDetectionRules(t *testing.T) {
+	body, err := win32AppBody(testWin32App())
+	if err != nil {
+		t.Fatalf("win32AppBody() error = %v", err)
+	}
+	rules := body["detectionRules"].([]map[string]any)
+	if rules[0]["@odata.type"] != "#microsoft.graph.win32LobAppProductCodeDetection" {
+		t.Errorf("Detection rule = %v, want product code detection", rules[0]["@odata.type"])
+	}
+
+	app := testWin32App()
+	app.MsiProductCode = ""
+	app.DetectionFile = `C:\Program Files\7-Zip\7z.exe`
+	body, err = win32AppBody(app)
+	if err != nil {
+		t.Fatalf("win32AppBody() error = %v", err)
+	}
+	rules = body["detectionRules"].([]map[string]any)
+	if rules[0]["path"] != `C:\Program Files\7-Zip` || rules[0]["fileOrFolderName"] != "7z.exe" {
+		t.Errorf("File detection = %v, want path and file name split", rules[0])
+	}
+
+	app.DetectionFile = ""
+	if _, err := win32AppBody(app); err == nil {
+		t.Error("Expected error without detection rule")
+	}
+}
+
+func TestWin32AppBodyLargeIcon(t *testing.T) {
+	body, err := win32AppBody(testWin32App())
+	if err != nil {
+		t.Fatalf("win32AppBody() error = %v", err)
+	}
+	if _, ok := body["largeIcon"]; ok {
+		t.Error("Expected no largeIcon without an icon")
+	}
+
+	app := testWin32App()
+	app.LargeIcon = []byte("\x89PNG icon")
+	body, err = win32AppBody(app)
+	if err != nil {
+		t.Fatalf("win32AppBody() error = %v", err)
+	}
+	icon, _ := body["largeIcon"].(map[string]any)
+	if icon["type"] != "image/png" || icon["value"] != "iVBORyBpY29u" || icon["@odata.type"] != "#microsoft.graph.mimeContent" {
+		t.Errorf("largeIcon = %v", icon)
+	}
+}
+
+func TestParseReturnCode(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		want    ReturnCode
+		wantErr bool
+	}{
+		"graph name":    {"3010=softReboot", ReturnCode{Code: 3010, Type: "softReboot"}, false},
+		"spaced":        {"1641 = hard reboot", ReturnCode{Code: 1641, Type: "hardReboot"}, false},
+		"retry":         {"1618=retry", ReturnCode{Code: 1618, Type: "retry"}, false},
+		"negative":      {"-2147009293=failed", ReturnCode{Code: -2147009293, Type: "failed"}, false},
+		"no type":       {"3010", ReturnCode{}, true},
+		"unknown type":  {"3010=reboot", ReturnCode{}, true},
+		"not a number":  {"abc=success", ReturnCode{}, true},
+		"out of range":  {"4294967296=success", ReturnCode{}, true},
+		"soft-reboot":   {"3010=soft-reboot", ReturnCode{Code: 3010, Type: "softReboot"}, false},
+		"case-folded":   {"0=SUCCESS", ReturnCode{Code: 0, Type: "success"}, false},
+		"empty setting": {"=success", ReturnCode{}, true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseReturnCode(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseReturnCode(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseReturnCode(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeReturnCodes(t *testing.T) {
+	merged := MergeReturnCodes(DefaultReturnCodes, []ReturnCode{{Code: 3010, Type: "success"}, {Code: 1, Type: "retry"}})
+	want := []ReturnCode{
+		{Code: 0, Type: "success"},
+		{Code: 1, Type: "retry"},
+		{Code: 1618, Type: "retry"},
+		{Code: 1641, Type: "hardReboot"},
+		{Code: 1707, Type: "success"},
+		{Code: 3010, Type: "success"},
+	}
+	if len(merged) != len(want) {
+		t.Fatalf("MergeReturnCodes() = %+v, want %+v", merged, want)
+	}
+	for i := range want {
+		if merged[i] != want[i] {
+			t.Errorf("MergeReturnCodes()[%d] = %+v, want %+v", i, merged[i], want[i])
+		}
+	}
+	if DefaultReturnCodes[2] != (ReturnCode{Code: 3010, Type: "softReboot"}) {
+		t.Error("MergeReturnCodes() changed its base")
+	}
+}
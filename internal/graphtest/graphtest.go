@@ -0,0 +1,1010 @@
+// Package graphtest is an in-memory Microsoft Graph that serves the Intune app management
+// requests of the graph package: tokens, Win32 apps, assignments, relationships, groups
+// and the content upload to Azure Storage. It backs the integration tests and the
+// mock-graph command, so upload specs and pipelines can run end to end without a tenant
+package graphtest
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Token is the access token issued for any client credentials and required by Graph requests
+	Token = "mock-graph-token"
+	// DefaultPageSize is the number of apps per page of an app list
+	DefaultPageSize = 100
+
+	// maxBodySize bounds Graph request bodies; content goes to storage in blocks
+	maxBodySize = 4 << 20
+	// maxBlobSize bounds the content uploaded to storage
+	maxBlobSize = 8 << 30
+)
+
+// Content file upload states, as reported by Graph
+const (
+	UploadStatePending = "azureStorageUriRequestPending"
+	UploadStateReady   = "azureStorageUriRequestSuccess"
+	UploadStateSuccess = "commitFileSuccess"
+	UploadStateFailed  = "commitFileFailed"
+)
+
+// Request is a request the server received
+type Request struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Status int    `json:"status"`
+}
+
+// Group is an Azure AD group of the mock tenant
+type Group struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+// ContentFile is a content file of a Win32 app content version
+type ContentFile struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	Size            int64  `json:"size"`
+	SizeEncrypted   int64  `json:"sizeEncrypted"`
+	UploadState     string `json:"uploadState"`
+	AzureStorageURI string `json:"azureStorageUri,omitempty"`
+	IsCommitted     bool   `json:"isCommitted"`
+	CreatedDateTime string `json:"createdDateTime"`
+	// Manifest is the Detection.xml of the package the file was uploaded from
+	Manifest []byte `json:"manifest,omitempty"`
+	// Uploaded is the number of bytes committed to storage
+	Uploaded int64 `json:"uploaded"`
+
+	blob string
+}
+
+// ContentVersion is a content version of a Win32 app
+type ContentVersion struct {
+	ID    string         `json:"id"`
+	Files []*ContentFile `json:"files"`
+}
+
+// App is a mobile app with what the tenant stores next to it
+type App struct {
+	// Object is the mobile app as Graph returns it
+	Object          map[string]any   `json:"app"`
+	Assignments     []map[string]any `json:"assignments"`
+	Relationships   []map[string]any `json:"relationships"`
+	ContentVersions []ContentVersion `json:"contentVersions"`
+}
+
+// State is a snapshot of the mock tenant, served at /mock/state
+type State struct {
+	Apps     []App     `json:"apps"`
+	Groups   []Group   `json:"groups"`
+	Requests []Request `json:"requests"`
+}
+
+// app is the stored form of an App
+type app struct {
+	object        map[string]any
+	assignments   []map[string]any
+	relationships []map[string]any
+	versions      []*ContentVersion
+}
+
+// blob is content uploaded to storage, in blocks until the block list is committed
+type blob struct {
+	blocks map[string][]byte
+	data   []byte
+}
+
+// Server is a mock Microsoft Graph tenant
+type Server struct {
+	// PageSize is the number of apps per page of an app list (DefaultPageSize when 0)
+	PageSize int
+	// OnRequest, if set, is called after every request
+	OnRequest func(Request)
+
+	mu       sync.Mutex
+	apps     []*app
+	groups   []Group
+	blobs    map[string]*blob
+	requests []Request
+}
+
+// New returns a server with an empty tenant
+func New() *Server {
+	return &Server{blobs: make(map[string]*blob)}
+}
+
+// AddGroup adds a group to the tenant
+func (s *Server) AddGroup(displayName string) Group {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	group := Group{ID: newID(), DisplayName: displayName}
+	s.groups = append(s.groups, group)
+	return group
+}
+
+// AddApp adds an app to the tenant as it is, for apps that exist before a test runs,
+// and returns its ID
+func (s *Server) AddApp(object map[string]any) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	object = clone(object)
+	if _, ok := object["@odata.type"]; !ok {
+		object["@odata.type"] = "#microsoft.graph.win32LobApp"
+	}
+	return s.insertApp(object)
+}
+
+// Apps returns a snapshot of the apps of the tenant
+func (s *Server) Apps() []App {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	apps := make([]App, len(s.apps))
+	for i, a := range s.apps {
+		apps[i] = App{
+			Object:        clone(a.object),
+			Assignments:   cloneAll(a.assignments),
+			Relationships: cloneAll(a.relationships),
+		}
+		for _, v := range a.versions {
+			version := ContentVersion{ID: v.ID}
+			for _, f := range v.Files {
+				file := *f
+				version.Files = append(version.Files, &file)
+			}
+			apps[i].ContentVersions = append(apps[i].ContentVersions, version)
+		}
+	}
+	return apps
+}
+
+// Requests returns the requests received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// State returns a snapshot of the tenant and the requests received
+func (s *Server) State() State {
+	state := State{Apps: s.Apps(), Requests: s.Requests()}
+	s.mu.Lock()
+	state.Groups = append([]Group{}, s.groups...)
+	s.mu.Unlock()
+	return state
+}
+
+// Handler returns the HTTP handler of the server:
+//
+//	POST /{tenant}/oauth2/v2.0/token   client credentials tokens
+//	/beta/deviceAppManagement/...      mobile apps, assignments, relationships, content
+//	/beta/groups                       groups by display name or ID
+//	PUT, GET /storage/{blob}           Azure Storage blocks, block lists and downloads
+//	GET /mock/state                    the tenant and the requests received, as JSON
+func (s *Server) Handler() http.Handler {
+	const apps = "/beta/deviceAppManagement/mobileApps"
+	const content = apps + "/{id}/microsoft.graph.win32LobApp/contentVersions"
+
+	mux := http.NewServeMux()
+	graph := func(pattern string, handler http.HandlerFunc) {
+		mux.Handle(pattern, requireToken(handler))
+	}
+	graph("GET "+apps, s.handleListApps)
+	graph("POST "+apps, s.handleCreateApp)
+	graph("GET "+apps+"/{id}", s.handleGetApp)
+	graph("PATCH "+apps+"/{id}", s.handleUpdateApp)
+	graph("DELETE "+apps+"/{id}", s.handleDeleteApp)
+	graph("GET "+apps+"/{id}/assignments", s.handleListAssignments)
+	graph("POST "+apps+"/{id}/assignments", s.handleAssign)
+	graph("GET "+apps+"/{id}/relationships", s.handleListRelationships)
+	graph("POST "+apps+"/{id}/updateRelationships", s.handleUpdateRelationships)
+	graph("GET "+content, s.handleListVersions)
+	graph("POST "+content, s.handleCreateVersion)
+	graph("GET "+content+"/{version}/files", s.handleListFiles)
+	graph("POST "+content+"/{version}/files", s.handleCreateFile)
+	graph("GET "+content+"/{version}/files/{file}", s.handleGetFile)
+	graph("POST "+content+"/{version}/files/{file}/commit", s.handleCommitFile)
+	graph("GET /beta/groups", s.handleListGroups)
+	graph("GET /beta/groups/{id}", s.handleGetGroup)
+
+	mux.HandleFunc("POST /{tenant}/oauth2/v2.0/token", s.handleToken)
+	mux.HandleFunc("PUT /storage/{blob}", s.handlePutBlob)
+	mux.HandleFunc("GET /storage/{blob}", s.handleGetBlob)
+	mux.HandleFunc("GET /mock/state", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.State())
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "ResourceNotFound", fmt.Sprintf("%s %s is not supported by the mock", r.Method, r.URL.Path))
+	})
+	return s.record(mux)
+}
+
+// record adds every request to the request log
+func (s *Server) record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if r.URL.Path == "/mock/state" {
+			return
+		}
+		req := Request{Method: r.Method, Path: r.URL.Path, Status: rec.status}
+		s.mu.Lock()
+		s.requests = append(s.requests, req)
+		s.mu.Unlock()
+		if s.OnRequest != nil {
+			s.OnRequest(req)
+		}
+	})
+}
+
+// statusRecorder remembers the status code of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// requireToken rejects Graph requests without the bearer token the server issues
+func requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+Token {
+			writeError(w, http.StatusUnauthorized, "InvalidAuthenticationToken", "Access token is empty or invalid")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+	switch {
+	case r.PostForm.Get("grant_type") != "client_credentials":
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type", "error_description": "only client_credentials is supported"})
+	case r.PostForm.Get("client_id") == "" || r.PostForm.Get("client_secret") == "":
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client", "error_description": "client_id and client_secret are required"})
+	case !strings.HasSuffix(r.PostForm.Get("scope"), "/.default"):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_scope", "error_description": "the scope of app-only tokens ends with /.default"})
+	default:
+		writeJSON(w, http.StatusOK, map[string]any{"token_type": "Bearer", "expires_in": 3600, "access_token": Token})
+	}
+}
+
+func (s *Server) handleListApps(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	match, err := parseFilter(query.Get("$filter"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", err.Error())
+		return
+	}
+	skip, _ := strconv.Atoi(query.Get("$skiptoken"))
+	expand := query.Get("$expand") == "assignments"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var matched []*app
+	for _, a := range s.apps {
+		if match(a.object) {
+			matched = append(matched, a)
+		}
+	}
+
+	pageSize := s.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	resp := map[string]any{}
+	values := []map[string]any{}
+	for i := skip; i < len(matched) && i < skip+pageSize; i++ {
+		object := clone(matched[i].object)
+		if expand {
+			object["assignments"] = cloneAll(matched[i].assignments)
+		}
+		values = append(values, object)
+	}
+	resp["value"] = values
+	if skip+pageSize < len(matched) {
+		query.Set("$skiptoken", strconv.Itoa(skip+pageSize))
+		resp["@odata.nextLink"] = baseURL(r) + "/beta/deviceAppManagement/mobileApps?" + query.Encode()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleCreateApp(w http.ResponseWriter, r *http.Request) {
+	object, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	if err := validateApp(object); err != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", err.Error())
+		return
+	}
+	for _, key := range []string{"id", "createdDateTime", "lastModifiedDateTime", "committedContentVersion"} {
+		if _, set := object[key]; set {
+			writeError(w, http.StatusBadRequest, "BadRequest", key+" is read-only")
+			return
+		}
+	}
+
+	s.mu.Lock()
+	s.insertApp(object)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusCreated, object)
+}
+
+// insertApp stores a new app with the properties Intune sets, and returns its ID
+func (s *Server) insertApp(object map[string]any) string {
+	now := time.Now().UTC().Format(time.RFC3339)
+	id := newID()
+	object["id"] = id
+	object["createdDateTime"] = now
+	object["lastModifiedDateTime"] = now
+	object["isAssigned"] = false
+	object["publishingState"] = "notPublished"
+	object["committedContentVersion"] = nil
+	s.apps = append(s.apps, &app{object: object})
+	return id
+}
+
+func (s *Server) handleGetApp(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.app(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, clone(a.object))
+}
+
+func (s *Server) handleUpdateApp(w http.ResponseWriter, r *http.Request) {
+	changes, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.app(w, r)
+	if !ok {
+		return
+	}
+	if odataType, set := changes["@odata.type"]; set && odataType != a.object["@odata.type"] {
+		writeError(w, http.StatusBadRequest, "BadRequest", "the @odata.type of an app cannot be changed")
+		return
+	}
+	for _, key := range []string{"id", "createdDateTime", "lastModifiedDateTime"} {
+		if _, set := changes[key]; set {
+			writeError(w, http.StatusBadRequest, "BadRequest", key+" is read-only")
+			return
+		}
+	}
+	if version, set := changes["committedContentVersion"]; set {
+		if v := a.version(fmt.Sprint(version)); v == nil || !v.committed() {
+			writeError(w, http.StatusBadRequest, "BadRequest", fmt.Sprintf("content version %v has no committed file", version))
+			return
+		}
+	}
+
+	updated := clone(a.object)
+	for key, value := range changes {
+		updated[key] = value
+	}
+	if _, set := changes["committedContentVersion"]; set {
+		updated["publishingState"] = "published"
+	}
+	if err := validateApp(updated); err != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", err.Error())
+		return
+	}
+	updated["lastModifiedDateTime"] = time.Now().UTC().Format(time.RFC3339)
+	a.object = updated
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleDeleteApp(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.app(w, r)
+	if !ok {
+		return
+	}
+	for i := range s.apps {
+		if s.apps[i] == a {
+			s.apps = append(s.apps[:i], s.apps[i+1:]...)
+			break
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListAssignments(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.app(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"value": cloneAll(a.assignments)})
+}
+
+func (s *Server) handleAssign(w http.ResponseWriter, r *http.Request) {
+	assignment, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.app(w, r)
+	if !ok {
+		return
+	}
+
+	switch assignment["intent"] {
+	case "required", "available", "uninstall", "availableWithoutEnrollment":
+	default:
+		writeError(w, http.StatusBadRequest, "BadRequest", fmt.Sprintf("invalid intent: %v", assignment["intent"]))
+		return
+	}
+	target, _ := assignment["target"].(map[string]any)
+	targetType, _ := target["@odata.type"].(string)
+	if targetType == "" {
+		writeError(w, http.StatusBadRequest, "BadRequest", "assignment target without @odata.type")
+		return
+	}
+	// Group and exclusion group targets must name a group the app is not assigned to yet
+	if groupID, _ := target["groupId"].(string); strings.HasSuffix(strings.ToLower(targetType), "groupassignmenttarget") {
+		if s.group(groupID) == nil {
+			writeError(w, http.StatusBadRequest, "BadRequest", fmt.Sprintf("group not found: %s", groupID))
+			return
+		}
+		for _, existing := range a.assignments {
+			if other, _ := existing["target"].(map[string]any); other["groupId"] == groupID {
+				writeError(w, http.StatusBadRequest, "BadRequest", fmt.Sprintf("the app is already assigned to group %s", groupID))
+				return
+			}
+		}
+	}
+
+	assignment["id"] = fmt.Sprintf("%s_%d", a.object["id"], len(a.assignments))
+	a.assignments = append(a.assignments, assignment)
+	a.object["isAssigned"] = true
+	writeJSON(w, http.StatusCreated, assignment)
+}
+
+func (s *Server) handleListRelationships(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.app(w, r)
+	if !ok {
+		return
+	}
+	relationships := cloneAll(a.relationships)
+	// The apps that supersede or depend on this one list it as their child
+	for _, other := range s.apps {
+		for _, rel := range other.relationships {
+			if rel["targetId"] == a.object["id"] {
+				parent := clone(rel)
+				parent["targetId"] = other.object["id"]
+				parent["targetDisplayName"] = other.object["displayName"]
+				parent["targetType"] = "parent"
+				relationships = append(relationships, parent)
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"value": relationships})
+}
+
+func (s *Server) handleUpdateRelationships(w http.ResponseWriter, r *http.Request) {
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.app(w, r)
+	if !ok {
+		return
+	}
+
+	list, _ := body["relationships"].([]any)
+	var relationships []map[string]any
+	for _, item := range list {
+		rel, _ := item.(map[string]any)
+		targetID, _ := rel["targetId"].(string)
+		target := s.findApp(targetID)
+		if target == nil || target == a {
+			writeError(w, http.StatusBadRequest, "BadRequest", fmt.Sprintf("invalid relationship target: %s", targetID))
+			return
+		}
+		switch rel["@odata.type"] {
+		case "#microsoft.graph.mobileAppSupersedence":
+			if t := rel["supersedenceType"]; t != "update" && t != "replace" {
+				writeError(w, http.StatusBadRequest, "BadRequest", fmt.Sprintf("invalid supersedence type: %v", t))
+				return
+			}
+		case "#microsoft.graph.mobileAppDependency":
+			if t := rel["dependencyType"]; t != "autoInstall" && t != "detect" {
+				writeError(w, http.StatusBadRequest, "BadRequest", fmt.Sprintf("invalid dependency type: %v", t))
+				return
+			}
+		default:
+			writeError(w, http.StatusBadRequest, "BadRequest", fmt.Sprintf("invalid relationship type: %v", rel["@odata.type"]))
+			return
+		}
+		if _, set := rel["targetType"]; set {
+			writeError(w, http.StatusBadRequest, "BadRequest", "targetType is read-only")
+			return
+		}
+		rel["id"] = fmt.Sprintf("%s_%s", a.object["id"], targetID)
+		rel["targetType"] = "child"
+		rel["targetDisplayName"] = target.object["displayName"]
+		rel["targetDisplayVersion"] = target.object["displayVersion"]
+		rel["targetPublisher"] = target.object["publisher"]
+		relationships = append(relationships, rel)
+	}
+	a.relationships = relationships
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListVersions(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.app(w, r)
+	if !ok {
+		return
+	}
+	values := []map[string]any{}
+	for _, v := range a.versions {
+		values = append(values, map[string]any{"id": v.ID})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"value": values})
+}
+
+func (s *Server) handleCreateVersion(w http.ResponseWriter, r *http.Request) {
+	if _, ok := readBody(w, r); !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.app(w, r)
+	if !ok {
+		return
+	}
+	version := &ContentVersion{ID: strconv.Itoa(len(a.versions) + 1)}
+	a.versions = append(a.versions, version)
+	writeJSON(w, http.StatusCreated, map[string]any{"id": version.ID})
+}
+
+func (s *Server) handleListFiles(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	version, ok := s.contentVersion(w, r)
+	if !ok {
+		return
+	}
+	files := []ContentFile{}
+	for _, f := range version.Files {
+		files = append(files, *f)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"value": files})
+}
+
+func (s *Server) handleCreateFile(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name          string `json:"name"`
+		Size          *int64 `json:"size"`
+		SizeEncrypted *int64 `json:"sizeEncrypted"`
+		Manifest      []byte `json:"manifest"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	if req.Name == "" || req.Size == nil || req.SizeEncrypted == nil || *req.SizeEncrypted <= 0 || *req.SizeEncrypted > maxBlobSize {
+		writeError(w, http.StatusBadRequest, "BadRequest", "a content file needs a name, size and sizeEncrypted")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	version, ok := s.contentVersion(w, r)
+	if !ok {
+		return
+	}
+	file := &ContentFile{
+		ID:              newID(),
+		Name:            req.Name,
+		Size:            *req.Size,
+		SizeEncrypted:   *req.SizeEncrypted,
+		UploadState:     UploadStatePending,
+		CreatedDateTime: time.Now().UTC().Format(time.RFC3339),
+		Manifest:        req.Manifest,
+	}
+	version.Files = append(version.Files, file)
+	writeJSON(w, http.StatusCreated, file)
+}
+
+func (s *Server) handleGetFile(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, ok := s.contentFile(w, r)
+	if !ok {
+		return
+	}
+	// The storage URI is handed out on the first poll, as Intune does after a short wait
+	if file.UploadState == UploadStatePending {
+		file.blob = newID()
+		s.blobs[file.blob] = &blob{blocks: make(map[string][]byte)}
+		file.AzureStorageURI = baseURL(r) + "/storage/" + file.blob + "?sv=mock&sig=mock"
+		file.UploadState = UploadStateReady
+	}
+	writeJSON(w, http.StatusOK, file)
+}
+
+func (s *Server) handleCommitFile(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		FileEncryptionInfo map[string]any `json:"fileEncryptionInfo"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	for _, key := range []string{"encryptionKey", "macKey", "initializationVector", "mac", "profileIdentifier", "fileDigest", "fileDigestAlgorithm"} {
+		if value, _ := req.FileEncryptionInfo[key].(string); value == "" {
+			writeError(w, http.StatusBadRequest, "BadRequest", "fileEncryptionInfo."+key+" is required")
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, ok := s.contentFile(w, r)
+	if !ok {
+		return
+	}
+	if file.UploadState != UploadStateReady {
+		writeError(w, http.StatusBadRequest, "BadRequest", fmt.Sprintf("content file is %s, not ready to commit", file.UploadState))
+		return
+	}
+	// A commit is accepted and fails later when the upload is incomplete, as in Intune
+	file.Uploaded = int64(len(s.blobs[file.blob].data))
+	if file.Uploaded == file.SizeEncrypted {
+		file.UploadState = UploadStateSuccess
+		file.IsCommitted = true
+	} else {
+		file.UploadState = UploadStateFailed
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleListGroups(w http.ResponseWriter, r *http.Request) {
+	match, err := parseFilter(r.URL.Query().Get("$filter"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	groups := []Group{}
+	for _, g := range s.groups {
+		if match(map[string]any{"id": g.ID, "displayName": g.DisplayName}) {
+			groups = append(groups, g)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"value": groups})
+}
+
+func (s *Server) handleGetGroup(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	group := s.group(r.PathValue("id"))
+	if group == nil {
+		writeError(w, http.StatusNotFound, "Request_ResourceNotFound", fmt.Sprintf("group %s does not exist", r.PathValue("id")))
+		return
+	}
+	writeJSON(w, http.StatusOK, group)
+}
+
+// handlePutBlob stores a block (comp=block), commits a block list (comp=blocklist)
+// or replaces the whole blob, as Azure Storage does with a SAS URI
+func (s *Server) handlePutBlob(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("sig") == "" {
+		http.Error(w, "AuthenticationFailed", http.StatusForbidden)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBlobSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.blobs[r.PathValue("blob")]
+	if !ok {
+		http.Error(w, "BlobNotFound", http.StatusNotFound)
+		return
+	}
+	switch query.Get("comp") {
+	case "block":
+		id := query.Get("blockid")
+		if _, err := base64.StdEncoding.DecodeString(id); err != nil || id == "" {
+			http.Error(w, "InvalidBlockId", http.StatusBadRequest)
+			return
+		}
+		b.blocks[id] = data
+	case "blocklist":
+		var list struct {
+			Blocks []struct {
+				ID string `xml:",chardata"`
+			} `xml:",any"`
+		}
+		if err := xml.Unmarshal(data, &list); err != nil {
+			http.Error(w, "InvalidXmlDocument", http.StatusBadRequest)
+			return
+		}
+		var committed []byte
+		for _, block := range list.Blocks {
+			part, ok := b.blocks[strings.TrimSpace(block.ID)]
+			if !ok {
+				http.Error(w, "InvalidBlockList", http.StatusBadRequest)
+				return
+			}
+			committed = append(committed, part...)
+		}
+		b.data = committed
+		b.blocks = make(map[string][]byte)
+	case "":
+		b.data = data
+	default:
+		http.Error(w, "UnsupportedQueryParameter", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) handleGetBlob(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("sig") == "" {
+		http.Error(w, "AuthenticationFailed", http.StatusForbidden)
+		return
+	}
+	s.mu.Lock()
+	b, ok := s.blobs[r.PathValue("blob")]
+	var data []byte
+	if ok {
+		data = b.data
+	}
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "BlobNotFound", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+// app returns the app of the request path, or writes a not found error
+func (s *Server) app(w http.ResponseWriter, r *http.Request) (*app, bool) {
+	a := s.findApp(r.PathValue("id"))
+	if a == nil {
+		writeError(w, http.StatusNotFound, "ResourceNotFound", fmt.Sprintf("app %s does not exist", r.PathValue("id")))
+		return nil, false
+	}
+	return a, true
+}
+
+// contentVersion returns the content version of the request path, or writes a not found error
+func (s *Server) contentVersion(w http.ResponseWriter, r *http.Request) (*ContentVersion, bool) {
+	a, ok := s.app(w, r)
+	if !ok {
+		return nil, false
+	}
+	v := a.version(r.PathValue("version"))
+	if v == nil {
+		writeError(w, http.StatusNotFound, "ResourceNotFound", fmt.Sprintf("content version %s does not exist", r.PathValue("version")))
+		return nil, false
+	}
+	return v, true
+}
+
+// contentFile returns the content file of the request path, or writes a not found error
+func (s *Server) contentFile(w http.ResponseWriter, r *http.Request) (*ContentFile, bool) {
+	v, ok := s.contentVersion(w, r)
+	if !ok {
+		return nil, false
+	}
+	for _, f := range v.Files {
+		if f.ID == r.PathValue("file") {
+			return f, true
+		}
+	}
+	writeError(w, http.StatusNotFound, "ResourceNotFound", fmt.Sprintf("content file %s does not exist", r.PathValue("file")))
+	return nil, false
+}
+
+// findApp returns the app with an ID, or nil
+func (s *Server) findApp(id string) *app {
+	for _, a := range s.apps {
+		if a.object["id"] == id {
+			return a
+		}
+	}
+	return nil
+}
+
+// group returns the group with an ID, or nil
+func (s *Server) group(id string) *Group {
+	for i := range s.groups {
+		if s.groups[i].ID == id {
+			return &s.groups[i]
+		}
+	}
+	return nil
+}
+
+// version returns the content version with an ID, or nil
+func (a *app) version(id string) *ContentVersion {
+	for _, v := range a.versions {
+		if v.ID == id {
+			return v
+		}
+	}
+	return nil
+}
+
+// committed reports whether a content version has a committed file
+func (v *ContentVersion) committed() bool {
+	for _, f := range v.Files {
+		if f.IsCommitted {
+			return true
+		}
+	}
+	return false
+}
+
+// validateApp checks the properties Intune requires of a mobile app
+func validateApp(object map[string]any) error {
+	odataType, _ := object["@odata.type"].(string)
+	if odataType == "" {
+		return fmt.Errorf("@odata.type is required")
+	}
+	if name, _ := object["displayName"].(string); name == "" {
+		return fmt.Errorf("displayName is required")
+	}
+	if odataType != "#microsoft.graph.win32LobApp" {
+		return nil
+	}
+	for _, key := range []string{"fileName", "setupFilePath", "installCommandLine", "uninstallCommandLine"} {
+		if value, _ := object[key].(string); value == "" {
+			return fmt.Errorf("%s is required for a Win32 app", key)
+		}
+	}
+	rules, _ := object["detectionRules"].([]any)
+	if len(rules) == 0 {
+		return fmt.Errorf("a Win32 app needs at least one detection rule")
+	}
+	for _, item := range rules {
+		if rule, _ := item.(map[string]any); rule["@odata.type"] == nil {
+			return fmt.Errorf("detection rule without @odata.type")
+		}
+	}
+	return nil
+}
+
+// OData $filter clauses the graph package sends
+var (
+	isofClause     = regexp.MustCompile(`^isof\('microsoft\.graph\.(\w+)'\)$`)
+	containsClause = regexp.MustCompile(`^contains\((\w+), '((?:[^']|'')*)'\)$`)
+	eqClause       = regexp.MustCompile(`^(\w+) eq '((?:[^']|'')*)'$`)
+)
+
+// parseFilter converts a $filter of clauses joined by "and" into a predicate;
+// string comparisons ignore case, as Graph does for display names
+func parseFilter(expr string) (func(map[string]any) bool, error) {
+	var preds []func(map[string]any) bool
+	for _, clause := range strings.Split(expr, " and ") {
+		clause = strings.TrimSpace(clause)
+		if m := isofClause.FindStringSubmatch(clause); m != nil {
+			odataType := "#microsoft.graph." + m[1]
+			preds = append(preds, func(o map[string]any) bool { return o["@odata.type"] == odataType })
+		} else if m := containsClause.FindStringSubmatch(clause); m != nil {
+			key, value := m[1], strings.ToLower(strings.ReplaceAll(m[2], "''", "'"))
+			preds = append(preds, func(o map[string]any) bool {
+				s, _ := o[key].(string)
+				return strings.Contains(strings.ToLower(s), value)
+			})
+		} else if m := eqClause.FindStringSubmatch(clause); m != nil {
+			key, value := m[1], strings.ReplaceAll(m[2], "''", "'")
+			preds = append(preds, func(o map[string]any) bool {
+				s, _ := o[key].(string)
+				return strings.EqualFold(s, value)
+			})
+		} else if clause != "" {
+			return nil, fmt.Errorf("unsupported $filter clause: %s", clause)
+		}
+	}
+	return func(o map[string]any) bool {
+		for _, pred := range preds {
+			if !pred(o) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// readBody decodes a JSON object request body, or writes a bad request error
+func readBody(w http.ResponseWriter, r *http.Request) (map[string]any, bool) {
+	var body map[string]any
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&body); err != nil || body == nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", fmt.Sprintf("request body must be a JSON object: %v", err))
+		return nil, false
+	}
+	return body, true
+}
+
+// baseURL returns the URL the client reached the server at
+func baseURL(r *http.Request) string {
+	if r.TLS != nil {
+		return "https://" + r.Host
+	}
+	return "http://" + r.Host
+}
+
+// newID returns a random object ID in the GUID format of Azure AD
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// clone deep-copies a JSON object
+func clone(object map[string]any) map[string]any {
+	data, _ := json.Marshal(object)
+	var copied map[string]any
+	json.Unmarshal(data, &copied)
+	if copied == nil {
+		copied = map[string]any{}
+	}
+	return copied
+}
+
+// cloneAll deep-copies a list of JSON objects, never returning nil
+func cloneAll(objects []map[string]any) []map[string]any {
+	copied := make([]map[string]any, len(objects))
+	for i, o := range objects {
+		copied[i] = clone(o)
+	}
+	return copied
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a Graph error response
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]any{"error": map[string]string{"code": code, "message": message}})
+}
@@ -0,0 +1,126 @@
+package graphtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// request sends a Graph request with the issued token and decodes the JSON response
+func request(t *testing.T, method, url, body string, out any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+Token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s error = %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		json.NewDecoder(resp.Body).Decode(out)
+	}
+	return resp.StatusCode
+}
+
+func TestToken(t *testing.T) {
+	server := httptest.NewServer(New().Handler())
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		form url.Values
+		want int
+	}{
+		{"client credentials", url.Values{"grant_type": {"client_credentials"}, "client_id": {"c"}, "client_secret": {"s"}, "scope": {"https://graph.microsoft.com/.default"}}, http.StatusOK},
+		{"missing secret", url.Values{"grant_type": {"client_credentials"}, "client_id": {"c"}, "scope": {"https://graph.microsoft.com/.default"}}, http.StatusUnauthorized},
+		{"password grant", url.Values{"grant_type": {"password"}, "client_id": {"c"}, "client_secret": {"s"}}, http.StatusBadRequest},
+		{"delegated scope", url.Values{"grant_type": {"client_credentials"}, "client_id": {"c"}, "client_secret": {"s"}, "scope": {"User.Read"}}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.PostForm(server.URL+"/contoso/oauth2/v2.0/token", tt.form)
+			if err != nil {
+				t.Fatalf("POST error = %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("Status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	app := map[string]any{"@odata.type": "#microsoft.graph.win32LobApp", "displayName": "Bob's Tool"}
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{"", true},
+		{"isof('microsoft.graph.win32LobApp')", true},
+		{"isof('microsoft.graph.webApp')", false},
+		{"isof('microsoft.graph.win32LobApp') and displayName eq 'bob''s tool'", true},
+		{"isof('microsoft.graph.win32LobApp') and contains(displayName, 'TOOL')", true},
+		{"contains(displayName, 'Alice')", false},
+	}
+	for _, tt := range tests {
+		match, err := parseFilter(tt.filter)
+		if err != nil {
+			t.Errorf("parseFilter(%q) error = %v", tt.filter, err)
+			continue
+		}
+		if got := match(app); got != tt.want {
+			t.Errorf("parseFilter(%q) matched = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+	if _, err := parseFilter("startswith(displayName, 'Bob')"); err == nil {
+		t.Error("Expected error for an unsupported clause")
+	}
+}
+
+func TestIncompleteUploadFailsCommit(t *testing.T) {
+	mock := New()
+	server := httptest.NewServer(mock.Handler())
+	defer server.Close()
+	appID := mock.AddApp(map[string]any{"displayName": "Contoso Tool"})
+	versions := server.URL + "/beta/deviceAppManagement/mobileApps/" + appID + "/microsoft.graph.win32LobApp/contentVersions"
+
+	var file ContentFile
+	request(t, "POST", versions, `{}`, nil)
+	if status := request(t, "POST", versions+"/1/files", `{"name":"IntunePackage.intunewin","size":10,"sizeEncrypted":48}`, &file); status != http.StatusCreated {
+		t.Fatalf("Create file status = %d", status)
+	}
+	request(t, "GET", versions+"/1/files/"+file.ID, "", &file)
+
+	// A block list naming a block that was never uploaded is refused
+	if status := request(t, "PUT", file.AzureStorageURI+"&comp=blocklist", `<BlockList><Latest>YmxvY2s=</Latest></BlockList>`, nil); status != http.StatusBadRequest {
+		t.Errorf("Block list status = %d, want 400", status)
+	}
+	if status := request(t, "PUT", strings.Split(file.AzureStorageURI, "?")[0], "data", nil); status != http.StatusForbidden {
+		t.Errorf("PUT without SAS status = %d, want 403", status)
+	}
+	request(t, "PUT", file.AzureStorageURI, "only part of the content", nil)
+
+	commit := `{"fileEncryptionInfo":{"encryptionKey":"k","macKey":"m","initializationVector":"i","mac":"m","profileIdentifier":"ProfileVersion1","fileDigest":"d","fileDigestAlgorithm":"SHA256"}}`
+	if status := request(t, "POST", versions+"/1/files/"+file.ID+"/commit", commit, nil); status != http.StatusOK {
+		t.Fatalf("Commit status = %d", status)
+	}
+	request(t, "GET", versions+"/1/files/"+file.ID, "", &file)
+	if file.UploadState != UploadStateFailed || file.IsCommitted {
+		t.Errorf("Content file = %+v, want a failed commit", file)
+	}
+
+	state := mock.State()
+	if len(state.Apps) != 1 || len(state.Apps[0].ContentVersions) != 1 || state.Apps[0].ContentVersions[0].Files[0].Uploaded != 24 {
+		t.Errorf("State() = %+v", state.Apps)
+	}
+	if len(state.Requests) == 0 || state.Requests[len(state.Requests)-1].Path != strings.TrimPrefix(versions, server.URL)+"/1/files/"+file.ID {
+		t.Errorf("Requests = %+v", state.Requests)
+	}
+}
@@ -0,0 +1,154 @@
+// Package hooks runs user commands before and after packaging runs, with environment
+// variables describing the run, for steps such as signing scripts, malware scans or
+// CMDB updates
+package hooks
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// Hook names, passed to commands in INTUNEWIN_HOOK
+const (
+	HookPre  = "pre"
+	HookPost = "post"
+)
+
+// Run statuses, passed to post-packaging commands in INTUNEWIN_STATUS
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// outputTailLines is the number of output lines of a failed command quoted in its error
+const outputTailLines = 5
+
+// Commands runs shell commands around packaging runs; it implements packager.Hooks
+// Commands run in order with the shell of the platform (sh -c, or cmd /C on Windows)
+// and stop at the first that fails. Their output is logged at info level.
+type Commands struct {
+	// Pre runs before the source folder is read; a failure stops the run
+	Pre []string
+	// Post runs once the run has ended, also when it failed (but not when it was
+	// canceled); a failure fails a run that succeeded
+	Post []string
+}
+
+// Before runs the pre-packaging commands
+func (c *Commands) Before(ctx context.Context, job packager.HookJob) error {
+	job.Log = jobLogger(job)
+	return c.run(ctx, job, HookPre, c.Pre, jobEnv(job, HookPre))
+}
+
+// After runs the post-packaging commands with the result or error of the run
+func (c *Commands) After(ctx context.Context, job packager.HookJob, result *packager.PackageResult, runErr error) error {
+	if len(c.Post) == 0 || ctx.Err() != nil {
+		return nil
+	}
+	job.Log = jobLogger(job)
+	env := append(jobEnv(job, HookPost), resultEnv(job, result, runErr)...)
+	return c.run(ctx, job, HookPost, c.Post, env)
+}
+
+// run runs commands in order with env added to the environment of the process
+func (c *Commands) run(ctx context.Context, job packager.HookJob, hook string, commands []string, env []string) error {
+	for _, command := range commands {
+		started := time.Now()
+		cmd := shellCommand(ctx, command)
+		cmd.Env = append(os.Environ(), env...)
+		output, err := cmd.CombinedOutput()
+
+		lines := outputLines(output)
+		for _, line := range lines {
+			job.Log.Info("hook output", "hook", hook, "command", command, "line", line)
+		}
+		if err != nil {
+			if tail := lines[max(len(lines)-outputTailLines, 0):]; len(tail) > 0 {
+				return fmt.Errorf("%s: %w: %s", command, err, strings.Join(tail, " | "))
+			}
+			return fmt.Errorf("%s: %w", command, err)
+		}
+		job.Log.Info("hook finished", "hook", hook, "command", command, "duration", time.Since(started).Round(time.Millisecond))
+	}
+	return nil
+}
+
+// jobLogger returns the logger of a run, or the default logger
+func jobLogger(job packager.HookJob) *slog.Logger {
+	if job.Log != nil {
+		return job.Log
+	}
+	return slog.Default()
+}
+
+// jobEnv returns the variables describing a run, given to every command
+func jobEnv(job packager.HookJob, hook string) []string {
+	env := []string{
+		"INTUNEWIN_HOOK=" + hook,
+		"INTUNEWIN_SOURCE=" + job.Source,
+		"INTUNEWIN_SETUP_FILE=" + job.SetupFile,
+		"INTUNEWIN_OUTPUT=" + job.Output,
+	}
+	if msi := job.Msi; msi != nil {
+		env = append(env,
+			"INTUNEWIN_MSI_PRODUCT_NAME="+msi.ProductName,
+			"INTUNEWIN_MSI_PRODUCT_VERSION="+msi.ProductVersion,
+			"INTUNEWIN_MSI_PRODUCT_CODE="+msi.ProductCode,
+			"INTUNEWIN_MSI_UPGRADE_CODE="+msi.UpgradeCode,
+			"INTUNEWIN_MSI_PUBLISHER="+msi.Publisher,
+		)
+	}
+	return env
+}
+
+// resultEnv returns the variables describing the outcome of a run, given to
+// post-packaging commands
+func resultEnv(job packager.HookJob, result *packager.PackageResult, runErr error) []string {
+	env := []string{"INTUNEWIN_DURATION_MS=" + strconv.FormatInt(time.Since(job.Started).Milliseconds(), 10)}
+	if runErr != nil || result == nil {
+		env = append(env, "INTUNEWIN_STATUS="+StatusFailed)
+		if runErr != nil {
+			env = append(env, "INTUNEWIN_ERROR="+runErr.Error())
+		}
+		return env
+	}
+
+	env = append(env,
+		"INTUNEWIN_STATUS="+StatusSucceeded,
+		"INTUNEWIN_PACKAGE="+result.OutputPath,
+		"INTUNEWIN_PACKAGE_SIZE="+strconv.FormatInt(result.FinalSize, 10),
+		"INTUNEWIN_SOURCE_SIZE="+strconv.FormatInt(result.SourceSize, 10),
+		"INTUNEWIN_FILE_COUNT="+strconv.Itoa(result.FileCount),
+	)
+	if result.OutputPath != "" {
+		// Scans and inventories look packages up by hash; packages written to a stream have no path
+		if digest, err := packager.FileSHA256(result.OutputPath); err == nil {
+			env = append(env, "INTUNEWIN_PACKAGE_SHA256="+hex.EncodeToString(digest))
+		} else {
+			job.Log.Warn("could not hash the package for hooks", "error", err)
+		}
+	}
+	if result.ManifestPath != "" {
+		env = append(env, "INTUNEWIN_MANIFEST="+result.ManifestPath)
+	}
+	return env
+}
+
+// outputLines splits the output of a command into its non-empty lines
+func outputLines(output []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(string(output), "\r\n", "\n"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
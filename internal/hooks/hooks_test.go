@@ -0,0 +1,123 @@
+package hooks
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// hookSource creates a source folder with a setup file, skipping on Windows where the
+// test commands, written for sh, do not run
+func hookSource(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands of the tests are written for sh")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "setup.exe"), []byte("setup"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// readEnv reads a file written by env into a map
+func readEnv(t *testing.T, path string) map[string]string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("hook did not run: %v", err)
+	}
+	env := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		if name, value, ok := strings.Cut(line, "="); ok && strings.HasPrefix(name, "INTUNEWIN_") {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+func TestCommandsDescribeTheRun(t *testing.T) {
+	source := hookSource(t)
+	output := t.TempDir()
+	envDir := t.TempDir()
+	commands := &Commands{
+		Pre:  []string{"env > '" + filepath.Join(envDir, "pre") + "'"},
+		Post: []string{"env > '" + filepath.Join(envDir, "post") + "'"},
+	}
+
+	result, err := packager.PackageWithOptions(source, "setup.exe", output, packager.Options{Hooks: commands}, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+
+	pre := readEnv(t, filepath.Join(envDir, "pre"))
+	if pre["INTUNEWIN_HOOK"] != HookPre || pre["INTUNEWIN_SOURCE"] != source || pre["INTUNEWIN_SETUP_FILE"] != "setup.exe" || pre["INTUNEWIN_OUTPUT"] != output {
+		t.Errorf("pre-packaging environment = %v", pre)
+	}
+	if _, ok := pre["INTUNEWIN_STATUS"]; ok {
+		t.Error("pre-packaging hook got the status of the run")
+	}
+
+	post := readEnv(t, filepath.Join(envDir, "post"))
+	digest, err := packager.FileSHA256(result.OutputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post["INTUNEWIN_HOOK"] != HookPost || post["INTUNEWIN_STATUS"] != StatusSucceeded || post["INTUNEWIN_PACKAGE"] != result.OutputPath {
+		t.Errorf("post-packaging environment = %v", post)
+	}
+	if post["INTUNEWIN_PACKAGE_SHA256"] != hex.EncodeToString(digest) || post["INTUNEWIN_FILE_COUNT"] != "1" {
+		t.Errorf("post-packaging environment = %v, want the SHA256 and file count of the package", post)
+	}
+}
+
+func TestPreHookFailureStopsTheRun(t *testing.T) {
+	source := hookSource(t)
+	output := t.TempDir()
+	marker := filepath.Join(t.TempDir(), "post")
+	commands := &Commands{
+		Pre:  []string{"echo source not approved; exit 3"},
+		Post: []string{"touch '" + marker + "'"},
+	}
+
+	_, err := packager.PackageWithOptions(source, "setup.exe", output, packager.Options{Hooks: commands}, nil)
+	if err == nil || !strings.Contains(err.Error(), "pre-packaging hook failed") || !strings.Contains(err.Error(), "source not approved") {
+		t.Fatalf("PackageWithOptions() error = %v, want the pre-packaging hook failure with its output", err)
+	}
+	if entries, _ := os.ReadDir(output); len(entries) != 0 {
+		t.Errorf("output folder has %d entries, want none", len(entries))
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("post-packaging hook ran after the pre-packaging hook failed")
+	}
+}
+
+func TestPostHookFailureFailsTheRun(t *testing.T) {
+	source := hookSource(t)
+	commands := &Commands{Post: []string{"echo 2 engines flagged the package >&2; exit 1"}}
+
+	_, err := packager.PackageWithOptions(source, "setup.exe", t.TempDir(), packager.Options{Hooks: commands}, nil)
+	if err == nil || !strings.Contains(err.Error(), "post-packaging hook failed") || !strings.Contains(err.Error(), "2 engines flagged") {
+		t.Fatalf("PackageWithOptions() error = %v, want the post-packaging hook failure with its output", err)
+	}
+}
+
+func TestPostHookRunsAfterAFailedRun(t *testing.T) {
+	source := hookSource(t)
+	envFile := filepath.Join(t.TempDir(), "post")
+	commands := &Commands{Post: []string{"env > '" + envFile + "'; exit 1"}}
+
+	_, err := packager.PackageWithOptions(source, "missing.exe", t.TempDir(), packager.Options{Hooks: commands}, nil)
+	if err == nil || strings.Contains(err.Error(), "hook") {
+		t.Fatalf("PackageWithOptions() error = %v, want the error of the run", err)
+	}
+	post := readEnv(t, envFile)
+	if post["INTUNEWIN_STATUS"] != StatusFailed || !strings.Contains(post["INTUNEWIN_ERROR"], "missing.exe") {
+		t.Errorf("post-packaging environment = %v, want the failure of the run", post)
+	}
+}
@@ -0,0 +1,16 @@
+package hooks
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+)
+
+// shellCommand returns the command running a hook with cmd.exe
+// The command line is passed as is: Go's argument quoting does not match the one of
+// cmd.exe and would break quoted paths
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "cmd.exe")
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: `cmd.exe /S /C "` + command + `"`}
+	return cmd
+}
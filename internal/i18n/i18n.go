@@ -0,0 +1,139 @@
+// Package i18n translates the messages of the TUI and the CLI
+//
+// Messages are written in English in the code and looked up in the message catalog of
+// the selected language, like gettext: the English text is the key, so a message missing
+// from a catalog is shown in English.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// DefaultLanguage is the language of the messages in the code
+const DefaultLanguage = "en"
+
+// Languages are the supported languages: English and those with a catalog in locales/
+var Languages = []string{DefaultLanguage, "de", "pt-BR"}
+
+//go:embed locales/*.json
+var locales embed.FS
+
+var (
+	// current is the selected language
+	current = DefaultLanguage
+	// catalog maps English messages to their translation in the selected language
+	catalog map[string]string
+)
+
+// Language returns the selected language
+func Language() string {
+	return current
+}
+
+// SetLanguage selects the language of messages, one of Languages or a locale close to
+// one (e.g. de-AT or pt_BR.UTF-8); an empty lang selects the language of the environment
+func SetLanguage(lang string) error {
+	if lang == "" {
+		lang = DefaultLanguage
+		if detected, ok := Match(Detect()); ok {
+			lang = detected
+		}
+	}
+	matched, ok := Match(lang)
+	if !ok {
+		return fmt.Errorf("unsupported language %q (supported: %s)", lang, strings.Join(Languages, ", "))
+	}
+	messages, err := loadCatalog(matched)
+	if err != nil {
+		return err
+	}
+	current, catalog = matched, messages
+	return nil
+}
+
+// Match returns the supported language closest to a BCP 47 tag or POSIX locale such as
+// pt_BR.UTF-8; C and POSIX are English
+func Match(lang string) (string, bool) {
+	// POSIX locales are language_TERRITORY.codeset@modifier
+	lang, _, _ = strings.Cut(lang, ".")
+	lang, _, _ = strings.Cut(lang, "@")
+	lang = strings.ReplaceAll(lang, "_", "-")
+	if lang == "C" || lang == "POSIX" {
+		return DefaultLanguage, true
+	}
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return "", false
+	}
+
+	tags := make([]language.Tag, len(Languages))
+	for i, l := range Languages {
+		tags[i] = language.MustParse(l)
+	}
+	_, index, confidence := language.NewMatcher(tags).Match(tag)
+	if confidence == language.No {
+		return "", false
+	}
+	return Languages[index], true
+}
+
+// Detect returns the locale of the environment: LC_ALL, LC_MESSAGES or LANG, like
+// gettext, or else the display language of the user on Windows
+func Detect() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return systemLanguage()
+}
+
+// loadCatalog reads the message catalog of a language; English has none
+func loadCatalog(lang string) (map[string]string, error) {
+	if lang == DefaultLanguage {
+		return nil, nil
+	}
+	data, err := locales.ReadFile("locales/" + lang + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message catalog: %w", err)
+	}
+	var messages map[string]string
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return nil, fmt.Errorf("failed to parse message catalog %s: %w", lang, err)
+	}
+	return messages, nil
+}
+
+// T returns the translation of a message
+func T(msg string) string {
+	if translated, ok := catalog[msg]; ok && translated != "" {
+		return translated
+	}
+	return msg
+}
+
+// Tf translates a format string and formats it with args, as fmt.Sprintf does
+func Tf(format string, args ...any) string {
+	return fmt.Sprintf(T(format), args...)
+}
+
+// N marks a message for translation without translating it, for messages defined before
+// the language is selected (e.g. key bindings); they are passed to T when shown
+func N(msg string) string {
+	return msg
+}
+
+// Step translates a progress step of the packager, including the file of
+// "Compressing: <file>" steps
+func Step(step string) string {
+	if file, ok := strings.CutPrefix(step, "Compressing: "); ok {
+		return Tf("Compressing: %s", file)
+	}
+	return T(step)
+}
@@ -0,0 +1,166 @@
+package i18n
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// translatedDirs hold the code whose messages are translated, relative to this package
+var translatedDirs = []string{"../../cmd", "../tui", "../packager"}
+
+// progressSteps are the progress steps of the packager shown through Step
+var progressSteps = []string{
+	"Validating inputs", "Checking setup file signature", "Checking for MSI metadata",
+	"Decrypting previous package", "Compressing files", "Compressing: %s", "Compression complete",
+	"Compressed content restored from checkpoint", "Encrypting content", "Encryption complete",
+	"Encrypted content restored from checkpoint", "Generating metadata", "Creating package",
+	"Writing output file", "Complete",
+}
+
+// sourceMessages returns the string literals passed to T, Tf and N in dirs
+func sourceMessages(t *testing.T, dirs []string) []string {
+	t.Helper()
+	seen := make(map[string]bool)
+	fset := token.NewFileSet()
+	for _, dir := range dirs {
+		files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+		if err != nil {
+			t.Fatalf("Failed to list %s: %v", dir, err)
+		}
+		for _, file := range files {
+			f, err := parser.ParseFile(fset, file, nil, 0)
+			if err != nil {
+				t.Fatalf("Failed to parse %s: %v", file, err)
+			}
+			ast.Inspect(f, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok || len(call.Args) == 0 {
+					return true
+				}
+				sel, ok := call.Fun.(*ast.SelectorExpr)
+				if !ok || !slices.Contains([]string{"T", "Tf", "N"}, sel.Sel.Name) {
+					return true
+				}
+				if pkg, ok := sel.X.(*ast.Ident); !ok || pkg.Name != "i18n" {
+					return true
+				}
+				if lit, ok := call.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					msg, err := strconv.Unquote(lit.Value)
+					if err != nil {
+						t.Fatalf("Failed to unquote %s: %v", lit.Value, err)
+					}
+					seen[msg] = true
+				}
+				return true
+			})
+		}
+	}
+	messages := make([]string, 0, len(seen))
+	for msg := range seen {
+		messages = append(messages, msg)
+	}
+	sort.Strings(messages)
+	return messages
+}
+
+// formatVerbs matches the verbs of a format string
+var formatVerbs = regexp.MustCompile(`%[-+# 0]*[0-9*]*(\.[0-9*]+)?[a-zA-Z%]`)
+
+func TestCatalogsComplete(t *testing.T) {
+	messages := append(sourceMessages(t, translatedDirs), progressSteps...)
+	if len(messages) < 100 {
+		t.Fatalf("Found only %d messages in the source", len(messages))
+	}
+	for _, lang := range Languages[1:] {
+		catalog, err := loadCatalog(lang)
+		if err != nil {
+			t.Fatalf("loadCatalog(%s) error = %v", lang, err)
+		}
+		for _, msg := range messages {
+			translated, ok := catalog[msg]
+			if !ok || translated == "" {
+				t.Errorf("%s: no translation of %q", lang, msg)
+				continue
+			}
+			// Translations are formatted with the arguments of the English message
+			if got, want := formatVerbs.FindAllString(translated, -1), formatVerbs.FindAllString(msg, -1); !slices.Equal(got, want) {
+				t.Errorf("%s: %q has verbs %v, want %v", lang, translated, got, want)
+			}
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := map[string]struct {
+		lang string
+		want string
+		ok   bool
+	}{
+		"english":        {"en", "en", true},
+		"us locale":      {"en_US.UTF-8", "en", true},
+		"c locale":       {"C", "en", true},
+		"posix locale":   {"POSIX", "en", true},
+		"german":         {"de", "de", true},
+		"austria":        {"de-AT", "de", true},
+		"german locale":  {"de_DE.UTF-8@euro", "de", true},
+		"brazil":         {"pt-BR", "pt-BR", true},
+		"brazil locale":  {"pt_BR.UTF-8", "pt-BR", true},
+		"portuguese":     {"pt", "pt-BR", true},
+		"unsupported":    {"ja_JP.UTF-8", "", false},
+		"not a language": {"klingon-mode", "", false},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := Match(tt.lang)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Match(%q) = %q, %v, want %q, %v", tt.lang, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSetLanguage(t *testing.T) {
+	t.Cleanup(func() { SetLanguage(DefaultLanguage) })
+
+	if err := SetLanguage("de_DE.UTF-8"); err != nil {
+		t.Fatalf("SetLanguage() error = %v", err)
+	}
+	if Language() != "de" || T("Source Folder") != "Quellordner" {
+		t.Errorf("Language() = %s, T() = %q", Language(), T("Source Folder"))
+	}
+	if got := Tf("Compression ratio: %.1f%%", 42.0); got != "Komprimierungsrate: 42.0 %" {
+		t.Errorf("Tf() = %q", got)
+	}
+	if got := Step("Compressing: setup.msi"); got != "Komprimiere: setup.msi" {
+		t.Errorf("Step() = %q", got)
+	}
+	if got := T("A message without translation"); got != "A message without translation" {
+		t.Errorf("T() = %q, want the English message", got)
+	}
+
+	if err := SetLanguage("ja"); err == nil {
+		t.Error("Expected error for an unsupported language")
+	}
+	if Language() != "de" {
+		t.Errorf("Language() = %s after a failed SetLanguage, want de", Language())
+	}
+
+	// The environment selects the language, falling back to English
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANG", "pt_BR.UTF-8")
+	if err := SetLanguage(""); err != nil || Language() != "pt-BR" {
+		t.Errorf("SetLanguage() from LANG = %s, %v", Language(), err)
+	}
+	t.Setenv("LANG", "ja_JP.UTF-8")
+	if err := SetLanguage(""); err != nil || Language() != DefaultLanguage {
+		t.Errorf("SetLanguage() from unsupported LANG = %s, %v", Language(), err)
+	}
+}
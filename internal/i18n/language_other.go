@@ -0,0 +1,8 @@
+//go:build !windows
+
+package i18n
+
+// systemLanguage returns no language; outside Windows the locale comes from the environment
+func systemLanguage() string {
+	return ""
+}
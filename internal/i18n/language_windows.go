@@ -0,0 +1,21 @@
+package i18n
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetUserDefaultLocaleName = syscall.NewLazyDLL("kernel32.dll").NewProc("GetUserDefaultLocaleName")
+
+// localeNameMaxLength is LOCALE_NAME_MAX_LENGTH, in UTF-16 code units
+const localeNameMaxLength = 85
+
+// systemLanguage returns the locale of the user, e.g. de-DE
+func systemLanguage() string {
+	buf := make([]uint16, localeNameMaxLength)
+	n, _, _ := procGetUserDefaultLocaleName.Call(uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	if n == 0 {
+		return ""
+	}
+	return syscall.UTF16ToString(buf)
+}
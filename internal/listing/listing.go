@@ -0,0 +1,389 @@
+// Package listing filters, sorts and pages the entries of catalog and history listings
+// Text is matched and ordered with the collation rules of a locale, ignoring case and
+// accents, so "adobe" finds "Adobe" and "Zoë" sorts next to "Zoe"
+package listing
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"golang.org/x/text/search"
+)
+
+// Kind is how the values of a field are compared
+type Kind int
+
+const (
+	// Text fields are compared with the collation rules of the locale
+	Text Kind = iota
+	// Version fields are compared part by part, numerically where both parts are numbers
+	Version
+	// Date fields are compared by time; query values are dates such as 2024-05-01
+	Date
+	// Size fields are compared in bytes; query values accept KB, MB and GB suffixes
+	Size
+)
+
+// Field is a searchable and sortable property of a listing entry
+type Field[T any] struct {
+	Name string
+	Kind Kind
+	text func(T) string
+	date func(T) time.Time
+	size func(T) int64
+}
+
+// TextField returns a field compared as text
+func TextField[T any](name string, value func(T) string) Field[T] {
+	return Field[T]{Name: name, Kind: Text, text: value}
+}
+
+// VersionField returns a field compared as a version number
+func VersionField[T any](name string, value func(T) string) Field[T] {
+	return Field[T]{Name: name, Kind: Version, text: value}
+}
+
+// DateField returns a field compared as a point in time
+func DateField[T any](name string, value func(T) time.Time) Field[T] {
+	return Field[T]{Name: name, Kind: Date, date: value}
+}
+
+// SizeField returns a field compared as a size in bytes
+func SizeField[T any](name string, value func(T) int64) Field[T] {
+	return Field[T]{Name: name, Kind: Size, size: value}
+}
+
+// Lister applies queries, sorting and paging to the entries of one kind of listing
+type Lister[T any] struct {
+	fields   []Field[T]
+	collator *collate.Collator
+	// strict breaks ties between text that differs only in case or accents
+	strict  *collate.Collator
+	matcher *search.Matcher
+}
+
+// New returns a lister for entries with the given fields, matching text by the rules of locale
+func New[T any](locale language.Tag, fields ...Field[T]) *Lister[T] {
+	return &Lister[T]{
+		fields:   fields,
+		collator: collate.New(locale, collate.IgnoreCase, collate.IgnoreDiacritics, collate.Numeric),
+		strict:   collate.New(locale, collate.Numeric),
+		matcher:  search.New(locale, search.IgnoreCase, search.IgnoreDiacritics),
+	}
+}
+
+// FieldNames returns the names of the fields, in the order they were given
+func (l *Lister[T]) FieldNames() []string {
+	names := make([]string, len(l.fields))
+	for i, f := range l.fields {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// field looks up a field by name, case-insensitively
+func (l *Lister[T]) field(name string) (Field[T], error) {
+	for _, f := range l.fields {
+		if strings.EqualFold(f.Name, name) {
+			return f, nil
+		}
+	}
+	return Field[T]{}, fmt.Errorf("unknown field %q (supported: %s)", name, strings.Join(l.FieldNames(), ", "))
+}
+
+// Filter returns the entries matching every term and condition of the query
+func (l *Lister[T]) Filter(entries []T, q Query) ([]T, error) {
+	matchers := make([]func(T) bool, 0, len(q.Terms)+len(q.Conditions))
+	for _, term := range q.Terms {
+		matchers = append(matchers, l.termMatcher(term))
+	}
+	for _, c := range q.Conditions {
+		m, err := l.conditionMatcher(c)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+
+	var matched []T
+	for _, e := range entries {
+		ok := true
+		for _, m := range matchers {
+			if !m(e) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			matched = append(matched, e)
+		}
+	}
+	return matched, nil
+}
+
+// termMatcher matches entries with a text or version field containing term
+func (l *Lister[T]) termMatcher(term string) func(T) bool {
+	return func(e T) bool {
+		for _, f := range l.fields {
+			if f.text != nil && l.contains(f.text(e), term) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// contains reports whether s contains substr, ignoring case and accents
+func (l *Lister[T]) contains(s, substr string) bool {
+	start, _ := l.matcher.IndexString(s, substr)
+	return start >= 0
+}
+
+// conditionMatcher matches entries whose field satisfies a condition
+func (l *Lister[T]) conditionMatcher(c Condition) (func(T) bool, error) {
+	f, err := l.field(c.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	switch f.Kind {
+	case Version:
+		return func(e T) bool {
+			v := f.text(e)
+			if c.Op == OpMatch {
+				return versionHasPrefix(v, c.Value)
+			}
+			return c.Op.holds(compareVersions(v, c.Value))
+		}, nil
+	case Date:
+		day, err := parseDay(c.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date for %s: %w", f.Name, err)
+		}
+		next := day.AddDate(0, 0, 1)
+		return func(e T) bool {
+			t := f.date(e)
+			switch c.Op {
+			case OpGreater:
+				return !t.Before(next)
+			case OpGreaterEqual:
+				return !t.Before(day)
+			case OpLess:
+				return t.Before(day)
+			case OpLessEqual:
+				return t.Before(next)
+			default:
+				return !t.Before(day) && t.Before(next)
+			}
+		}, nil
+	case Size:
+		size, err := ParseSize(c.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size for %s: %w", f.Name, err)
+		}
+		return func(e T) bool {
+			return c.Op.holds(compareInts(f.size(e), size))
+		}, nil
+	default:
+		return func(e T) bool {
+			v := f.text(e)
+			if c.Op == OpMatch {
+				return l.contains(v, c.Value)
+			}
+			return c.Op.holds(l.collator.CompareString(v, c.Value))
+		}, nil
+	}
+}
+
+// Sort orders entries by a field, keeping the order of equal entries
+func (l *Lister[T]) Sort(entries []T, by string, descending bool) error {
+	f, err := l.field(by)
+	if err != nil {
+		return err
+	}
+
+	compare := func(a, b T) int {
+		switch f.Kind {
+		case Version:
+			return compareVersions(f.text(a), f.text(b))
+		case Date:
+			return f.date(a).Compare(f.date(b))
+		case Size:
+			return compareInts(f.size(a), f.size(b))
+		default:
+			if c := l.collator.CompareString(f.text(a), f.text(b)); c != 0 {
+				return c
+			}
+			return l.strict.CompareString(f.text(a), f.text(b))
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if descending {
+			return compare(entries[i], entries[j]) > 0
+		}
+		return compare(entries[i], entries[j]) < 0
+	})
+	return nil
+}
+
+// Paginate returns the entries of a 1-based page and the number of pages
+// A size of 0 or less puts all entries on one page; pages past the end are empty
+func Paginate[T any](entries []T, page, size int) ([]T, int) {
+	if size <= 0 || len(entries) == 0 {
+		if page > 1 {
+			return nil, 1
+		}
+		return entries, 1
+	}
+	pages := (len(entries) + size - 1) / size
+	if page < 1 {
+		page = 1
+	}
+	start := (page - 1) * size
+	if start >= len(entries) {
+		return nil, pages
+	}
+	return entries[start:min(start+size, len(entries))], pages
+}
+
+// compareVersions compares dotted versions part by part, numerically where both parts
+// are numbers, so 10.0 sorts after 9.5; missing parts count as 0, so 24 equals 24.0
+func compareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		partA, partB := "0", "0"
+		if i < len(pa) {
+			partA = pa[i]
+		}
+		if i < len(pb) {
+			partB = pb[i]
+		}
+		if c := comparePart(partA, partB); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// comparePart compares two version parts
+func comparePart(a, b string) int {
+	na, errA := strconv.ParseUint(a, 10, 64)
+	nb, errB := strconv.ParseUint(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil:
+		return compareInts(int64(na), int64(nb))
+	case errA == nil:
+		return 1 // 1.0.1 is newer than 1.0.beta
+	case errB == nil:
+		return -1
+	default:
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	}
+}
+
+// versionHasPrefix reports whether version starts with the parts of prefix, so 24 matches 24.1.0
+func versionHasPrefix(version, prefix string) bool {
+	pv, pp := versionParts(version), versionParts(prefix)
+	if len(pp) == 0 || len(pp) > len(pv) {
+		return len(pp) == 0
+	}
+	for i := range pp {
+		if comparePart(pv[i], pp[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// versionParts splits a version at dots, dashes and other separators
+func versionParts(v string) []string {
+	return strings.FieldsFunc(v, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// parseDay parses a date such as 2024-05-01 as the start of that day in local time
+func parseDay(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date (expected YYYY-MM-DD)", s)
+}
+
+// ParseSize parses a size such as 1048576, 500KB, 1.5MB or 2GB (binary units)
+func ParseSize(s string) (int64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{
+		{"KB", 1 << 10},
+		{"MB", 1 << 20},
+		{"GB", 1 << 30},
+		{"K", 1 << 10},
+		{"M", 1 << 20},
+		{"G", 1 << 30},
+		{"B", 1},
+	} {
+		if strings.HasSuffix(upper, unit.suffix) {
+			upper = strings.TrimSpace(strings.TrimSuffix(upper, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(upper, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a size (e.g. 500KB, 1.5MB, 2GB)", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// Locale parses a locale name such as "de", "sv-SE" or "de_DE.UTF-8"
+// An empty name uses the locale of the environment (LC_ALL, LC_COLLATE, LANG)
+func Locale(name string) (language.Tag, error) {
+	explicit := name != ""
+	if !explicit {
+		for _, env := range []string{"LC_ALL", "LC_COLLATE", "LANG"} {
+			if name = os.Getenv(env); name != "" {
+				break
+			}
+		}
+	}
+
+	// POSIX locales carry a codeset and modifier (de_DE.UTF-8@euro)
+	name, _, _ = strings.Cut(name, ".")
+	name, _, _ = strings.Cut(name, "@")
+	if name == "" || name == "C" || name == "POSIX" {
+		return language.Und, nil
+	}
+
+	tag, err := language.Parse(strings.ReplaceAll(name, "_", "-"))
+	if err != nil {
+		if !explicit {
+			// An unusual environment locale falls back to the root collation
+			return language.Und, nil
+		}
+		return language.Und, fmt.Errorf("invalid locale %q: %w", name, err)
+	}
+	return tag, nil
+}
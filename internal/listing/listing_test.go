@@ -0,0 +1,236 @@
+package listing
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+type app struct {
+	Name    string
+	Vendor  string
+	Version string
+	Size    int64
+	Date    time.Time
+}
+
+func day(s string) time.Time {
+	t, _ := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+	return t
+}
+
+var testApps = []app{
+	{"Acrobat Reader", "Adobe", "24.002.20759", 700 << 20, day("2024-05-02 10:00")},
+	{"Creative Cloud", "Adobe", "6.1.0", 300 << 20, day("2024-03-10 09:00")},
+	{"Zoë Editor", "Zoë Software", "2.0", 5 << 20, day("2024-05-01 23:30")},
+	{"zoom", "Zoom Video", "5.17.11", 90 << 20, day("2024-04-20 08:00")},
+	{"7-Zip", "Igor Pavlov", "23.01", 2 << 20, day("2024-01-15 12:00")},
+}
+
+func newAppLister() *Lister[app] {
+	return New(language.Und,
+		TextField("name", func(a app) string { return a.Name }),
+		TextField("vendor", func(a app) string { return a.Vendor }),
+		VersionField("version", func(a app) string { return a.Version }),
+		SizeField("size", func(a app) int64 { return a.Size }),
+		DateField("date", func(a app) time.Time { return a.Date }),
+	)
+}
+
+func names(apps []app) []string {
+	var n []string
+	for _, a := range apps {
+		n = append(n, a.Name)
+	}
+	return n
+}
+
+func TestParseQuery(t *testing.T) {
+	q, err := ParseQuery(`vendor:Adobe version:>24 reader name:"Acrobat Reader" size:<=1GB`)
+	if err != nil {
+		t.Fatalf("ParseQuery failed: %v", err)
+	}
+	if !reflect.DeepEqual(q.Terms, []string{"reader"}) {
+		t.Errorf("Terms = %q, want [reader]", q.Terms)
+	}
+	want := []Condition{
+		{Field: "vendor", Op: OpMatch, Value: "Adobe"},
+		{Field: "version", Op: OpGreater, Value: "24"},
+		{Field: "name", Op: OpMatch, Value: "Acrobat Reader"},
+		{Field: "size", Op: OpLessEqual, Value: "1GB"},
+	}
+	if !reflect.DeepEqual(q.Conditions, want) {
+		t.Errorf("Conditions = %+v, want %+v", q.Conditions, want)
+	}
+
+	for _, bad := range []string{`name:"Acrobat`, `version:>`} {
+		if _, err := ParseQuery(bad); err == nil {
+			t.Errorf("ParseQuery(%q) should fail", bad)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"adobe", []string{"Acrobat Reader", "Creative Cloud"}},
+		{"vendor:adobe version:>24", []string{"Acrobat Reader"}},
+		{"version:6", []string{"Creative Cloud"}},
+		{"version:>=5.17.11 version:<24", []string{"Creative Cloud", "zoom", "7-Zip"}},
+		{"zoe", []string{"Zoë Editor"}},
+		{"ZOOM", []string{"zoom"}},
+		{"size:>100MB", []string{"Acrobat Reader", "Creative Cloud"}},
+		{"date:2024-05-01", []string{"Zoë Editor"}},
+		{"date:>2024-04-30 reader", []string{"Acrobat Reader"}},
+		{"date:<2024-02-01", []string{"7-Zip"}},
+		{"vendor:Microsoft", nil},
+	}
+
+	l := newAppLister()
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q) failed: %v", tt.query, err)
+		}
+		got, err := l.Filter(testApps, q)
+		if err != nil {
+			t.Fatalf("Filter(%q) failed: %v", tt.query, err)
+		}
+		if !reflect.DeepEqual(names(got), tt.want) {
+			t.Errorf("Filter(%q) = %q, want %q", tt.query, names(got), tt.want)
+		}
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	l := newAppLister()
+	for _, query := range []string{"publisher:Adobe", "size:>lots", "date:yesterday"} {
+		q, err := ParseQuery(query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q) failed: %v", query, err)
+		}
+		if _, err := l.Filter(testApps, q); err == nil {
+			t.Errorf("Filter(%q) should fail", query)
+		}
+	}
+}
+
+func TestSort(t *testing.T) {
+	tests := []struct {
+		by         string
+		descending bool
+		want       []string
+	}{
+		{"name", false, []string{"7-Zip", "Acrobat Reader", "Creative Cloud", "Zoë Editor", "zoom"}},
+		{"version", false, []string{"Zoë Editor", "zoom", "Creative Cloud", "7-Zip", "Acrobat Reader"}},
+		{"size", true, []string{"Acrobat Reader", "Creative Cloud", "zoom", "Zoë Editor", "7-Zip"}},
+		{"date", true, []string{"Acrobat Reader", "Zoë Editor", "zoom", "Creative Cloud", "7-Zip"}},
+	}
+
+	l := newAppLister()
+	for _, tt := range tests {
+		apps := append([]app(nil), testApps...)
+		if err := l.Sort(apps, tt.by, tt.descending); err != nil {
+			t.Fatalf("Sort(%s) failed: %v", tt.by, err)
+		}
+		if !reflect.DeepEqual(names(apps), tt.want) {
+			t.Errorf("Sort(%s) = %q, want %q", tt.by, names(apps), tt.want)
+		}
+	}
+
+	if err := l.Sort(testApps, "publisher", false); err == nil {
+		t.Error("Sort by an unknown field should fail")
+	}
+}
+
+func TestSortLocale(t *testing.T) {
+	words := []string{"zebra", "äpple", "apple", "öl", "ost"}
+	sortWords := func(tag language.Tag) []string {
+		w := append([]string(nil), words...)
+		l := New(tag, TextField("word", func(s string) string { return s }))
+		if err := l.Sort(w, "word", false); err != nil {
+			t.Fatalf("Sort failed: %v", err)
+		}
+		return w
+	}
+
+	if got, want := sortWords(language.German), []string{"apple", "äpple", "öl", "ost", "zebra"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("German order = %q, want %q", got, want)
+	}
+	// Swedish sorts å, ä and ö as separate letters after z
+	if got, want := sortWords(language.Swedish), []string{"apple", "ost", "zebra", "äpple", "öl"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Swedish order = %q, want %q", got, want)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"10.0", "9.5", 1},
+		{"24", "24.0.0", 0},
+		{"1.2.3", "1.2.10", -1},
+		{"1.0", "1.0-beta", 1},
+		{"", "1.0", -1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+	tests := []struct {
+		page, size int
+		want       []int
+		pages      int
+	}{
+		{1, 2, []int{1, 2}, 3},
+		{3, 2, []int{5}, 3},
+		{4, 2, nil, 3},
+		{1, 0, items, 1},
+	}
+	for _, tt := range tests {
+		got, pages := Paginate(items, tt.page, tt.size)
+		if !reflect.DeepEqual(got, tt.want) || pages != tt.pages {
+			t.Errorf("Paginate(page %d, size %d) = %v, %d pages, want %v, %d pages", tt.page, tt.size, got, pages, tt.want, tt.pages)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"1024":  1024,
+		"500KB": 500 << 10,
+		"1.5MB": 3 << 19,
+		"2gb":   2 << 30,
+	}
+	for in, want := range tests {
+		got, err := ParseSize(in)
+		if err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", in, got, err, want)
+		}
+	}
+}
+
+func TestLocale(t *testing.T) {
+	tag, err := Locale("de_DE.UTF-8")
+	if err != nil || tag != language.MustParse("de-DE") {
+		t.Errorf("Locale(de_DE.UTF-8) = %v, %v", tag, err)
+	}
+	if _, err := Locale("not a locale!"); err == nil {
+		t.Error("Locale should reject an invalid name")
+	}
+
+	t.Setenv("LC_ALL", "C")
+	if tag, err := Locale(""); err != nil || tag != language.Und {
+		t.Errorf("Locale from C environment = %v, %v, want und", tag, err)
+	}
+}
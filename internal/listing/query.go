@@ -0,0 +1,111 @@
+package listing
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Op is the comparison of a query condition
+type Op string
+
+const (
+	// OpMatch matches text containing the value, versions starting with it and dates on that day
+	OpMatch        Op = ""
+	OpEqual        Op = "="
+	OpGreater      Op = ">"
+	OpGreaterEqual Op = ">="
+	OpLess         Op = "<"
+	OpLessEqual    Op = "<="
+)
+
+// holds reports whether a comparison result (-1, 0, 1) satisfies the operator
+func (op Op) holds(cmp int) bool {
+	switch op {
+	case OpGreater:
+		return cmp > 0
+	case OpGreaterEqual:
+		return cmp >= 0
+	case OpLess:
+		return cmp < 0
+	case OpLessEqual:
+		return cmp <= 0
+	default:
+		return cmp == 0
+	}
+}
+
+// Condition restricts a field, such as version:>24
+type Condition struct {
+	Field string
+	Op    Op
+	Value string
+}
+
+// Query is a parsed filter: free text terms that must each appear in some text field,
+// and conditions on named fields
+type Query struct {
+	Terms      []string
+	Conditions []Condition
+}
+
+// ParseQuery parses a filter such as `vendor:Adobe version:>24 reader`
+// Values with spaces are quoted: `app:"Acrobat Reader"`
+func ParseQuery(s string) (Query, error) {
+	var q Query
+	tokens, err := splitQuery(s)
+	if err != nil {
+		return q, err
+	}
+
+	for _, token := range tokens {
+		field, value, ok := strings.Cut(token, ":")
+		if !ok || field == "" || strings.ContainsAny(field, `"`) {
+			q.Terms = append(q.Terms, strings.Trim(token, `"`))
+			continue
+		}
+
+		c := Condition{Field: field}
+		for _, op := range []Op{OpGreaterEqual, OpLessEqual, OpGreater, OpLess, OpEqual} {
+			if strings.HasPrefix(value, string(op)) {
+				c.Op = op
+				value = value[len(op):]
+				break
+			}
+		}
+		c.Value = strings.Trim(value, `"`)
+		if c.Value == "" {
+			return q, fmt.Errorf("no value given for %s in filter", field)
+		}
+		q.Conditions = append(q.Conditions, c)
+	}
+	return q, nil
+}
+
+// splitQuery splits a filter at spaces outside double quotes
+func splitQuery(s string) ([]string, error) {
+	var tokens []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in filter %q", s)
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens, nil
+}
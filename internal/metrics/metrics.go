@@ -0,0 +1,199 @@
+// Package metrics counts packaging jobs, packaged bytes and phase durations of long-running
+// modes (serve, batch, worker) and renders them in the Prometheus text exposition format
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// ContentType is the media type of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DurationBuckets are the upper bounds, in seconds, of the duration histograms
+var DurationBuckets = []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600}
+
+// histogram counts observations per bucket of DurationBuckets
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(v float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(DurationBuckets))
+	}
+	for i, bound := range DurationBuckets {
+		if v <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// Registry collects the metrics of packaging jobs
+// It is safe for concurrent use
+type Registry struct {
+	mu           sync.Mutex
+	succeeded    uint64
+	failed       uint64
+	running      int64
+	sourceBytes  int64
+	packageBytes int64
+	files        int64
+	jobDuration  histogram
+	phases       map[string]*histogram
+}
+
+// New creates an empty registry
+func New() *Registry {
+	return &Registry{phases: make(map[string]*histogram)}
+}
+
+// Start records the start of a packaging job and returns the function that records its
+// end, with the result of a successful job and the tracer of the job (both may be nil)
+func (r *Registry) Start() func(result *packager.PackageResult, tracer *packager.Tracer, err error) {
+	begin := time.Now()
+	r.mu.Lock()
+	r.running++
+	r.mu.Unlock()
+
+	return func(result *packager.PackageResult, tracer *packager.Tracer, err error) {
+		phases := tracer.Phases()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.running--
+		r.jobDuration.observe(time.Since(begin).Seconds())
+		if err != nil {
+			r.failed++
+		} else {
+			r.succeeded++
+		}
+		if result != nil {
+			r.sourceBytes += result.SourceSize
+			r.packageBytes += result.FinalSize
+			r.files += int64(result.FileCount)
+		}
+		for _, phase := range phases {
+			if phase.Name == "package" {
+				continue // the whole run, recorded as the job duration
+			}
+			h := r.phases[phase.Name]
+			if h == nil {
+				h = &histogram{}
+				r.phases[phase.Name] = h
+			}
+			h.observe(phase.Duration.Seconds())
+		}
+	}
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+	r.mu.Lock()
+	metric(&b, "intunewin_jobs_total", "counter", "Packaging jobs finished, by status.")
+	fmt.Fprintf(&b, "intunewin_jobs_total{status=\"failed\"} %d\n", r.failed)
+	fmt.Fprintf(&b, "intunewin_jobs_total{status=\"succeeded\"} %d\n", r.succeeded)
+	metric(&b, "intunewin_jobs_in_progress", "gauge", "Packaging jobs running.")
+	fmt.Fprintf(&b, "intunewin_jobs_in_progress %d\n", r.running)
+	metric(&b, "intunewin_source_bytes_total", "counter", "Bytes of source folders packaged.")
+	fmt.Fprintf(&b, "intunewin_source_bytes_total %d\n", r.sourceBytes)
+	metric(&b, "intunewin_package_bytes_total", "counter", "Bytes of .intunewin packages written.")
+	fmt.Fprintf(&b, "intunewin_package_bytes_total %d\n", r.packageBytes)
+	metric(&b, "intunewin_files_total", "counter", "Files packaged.")
+	fmt.Fprintf(&b, "intunewin_files_total %d\n", r.files)
+	metric(&b, "intunewin_job_duration_seconds", "histogram", "Duration of packaging jobs.")
+	writeHistogram(&b, "intunewin_job_duration_seconds", "", &r.jobDuration)
+	metric(&b, "intunewin_phase_duration_seconds", "histogram", "Duration of packaging phases (walk, compress, encrypt, write, ...).")
+	names := make([]string, 0, len(r.phases))
+	for name := range r.phases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeHistogram(&b, "intunewin_phase_duration_seconds", `phase="`+escapeLabel(name)+`"`, r.phases[name])
+	}
+	r.mu.Unlock()
+	return b.WriteTo(w)
+}
+
+// Handler serves the metrics for Prometheus to scrape
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteTo(w)
+	})
+}
+
+// WriteFile writes the metrics to path through a temporary file, for the textfile
+// collector of the node exporter, which must never read a partial file
+func (r *Registry) WriteFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".metrics-*")
+	if err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	_, err = r.WriteTo(tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// CreateTemp makes the file private, the exporter may run as another user
+		os.Chmod(tmp.Name(), 0644)
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	return nil
+}
+
+// metric writes the HELP and TYPE lines of a metric
+func metric(b *bytes.Buffer, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// writeHistogram writes the cumulative buckets, sum and count of a histogram
+func writeHistogram(b *bytes.Buffer, name, labels string, h *histogram) {
+	prefix := ""
+	if labels != "" {
+		prefix = labels + ","
+	}
+	var cumulative uint64
+	for i, bound := range DurationBuckets {
+		if h.counts != nil {
+			cumulative += h.counts[i]
+		}
+		fmt.Fprintf(b, "%s_bucket{%sle=\"%s\"} %d\n", name, prefix, formatFloat(bound), cumulative)
+	}
+	fmt.Fprintf(b, "%s_bucket{%sle=\"+Inf\"} %d\n", name, prefix, h.count)
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(b, "%s_sum%s %s\n", name, labels, formatFloat(h.sum))
+	fmt.Fprintf(b, "%s_count%s %d\n", name, labels, h.count)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escapeLabel escapes a label value for the text format
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
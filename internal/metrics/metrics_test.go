@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+func TestRegistry(t *testing.T) {
+	reg := New()
+
+	done := reg.Start()
+	running := reg.Start()
+	tracer := packager.NewTracer(time.Hour)
+	tracer.StartPhase("compress")()
+	tracer.StartPhase("encrypt")()
+	done(&packager.PackageResult{SourceSize: 1000, FinalSize: 600, FileCount: 3}, tracer, nil)
+	reg.Start()(nil, nil, errors.New("setup file not found"))
+
+	var b strings.Builder
+	if _, err := reg.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"# TYPE intunewin_jobs_total counter\n",
+		`intunewin_jobs_total{status="succeeded"} 1` + "\n",
+		`intunewin_jobs_total{status="failed"} 1` + "\n",
+		"intunewin_jobs_in_progress 1\n",
+		"intunewin_source_bytes_total 1000\n",
+		"intunewin_package_bytes_total 600\n",
+		"intunewin_files_total 3\n",
+		`intunewin_job_duration_seconds_bucket{le="+Inf"} 2` + "\n",
+		"intunewin_job_duration_seconds_count 2\n",
+		`intunewin_phase_duration_seconds_bucket{phase="compress",le="0.01"} 1` + "\n",
+		`intunewin_phase_duration_seconds_count{phase="encrypt"} 1` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `phase="package"`) {
+		t.Error("the whole run should not be reported as a phase")
+	}
+	running(nil, nil, nil)
+}
+
+func TestHandler(t *testing.T) {
+	reg := New()
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if got := rec.Header().Get("Content-Type"); got != ContentType {
+		t.Errorf("Content-Type = %s, want %s", got, ContentType)
+	}
+	if !strings.Contains(rec.Body.String(), "intunewin_jobs_in_progress 0") {
+		t.Errorf("body = %s, want metrics", rec.Body.String())
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	reg := New()
+	reg.Start()(&packager.PackageResult{FinalSize: 10}, nil, nil)
+	path := filepath.Join(t.TempDir(), "intunewin.prom")
+	if err := reg.WriteFile(path); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read metrics file: %v", err)
+	}
+	if !strings.Contains(string(data), "intunewin_package_bytes_total 10\n") {
+		t.Errorf("metrics file = %s", data)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
@@ -0,0 +1,204 @@
+// Package msitest synthesizes small MSI files for tests, so the MSI extractor can be
+// exercised across edge cases without committing vendor binaries
+package msitest
+
+import (
+	"encoding/binary"
+	"unicode/utf16"
+)
+
+// Compound file constants (MS-CFB, version 3 with 512-byte sectors)
+const (
+	sectorSize     = 512
+	miniSectorSize = 64
+	miniCutoff     = 4096
+	dirEntrySize   = 128
+	fatPerSector   = sectorSize / 4
+
+	freeSect   = 0xFFFFFFFF
+	endOfChain = 0xFFFFFFFE
+	fatSect    = 0xFFFFFFFD
+	noStream   = 0xFFFFFFFF
+
+	typeStream  = 2
+	typeRoot    = 5
+	colorBlack  = 1
+	maxNameLen  = 31
+	headerMinor = 0x003E
+	headerMajor = 0x0003
+)
+
+// cfbSignature starts every compound file
+var cfbSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// Stream is a named stream in the root storage of a compound file
+type Stream struct {
+	Name string
+	Data []byte
+}
+
+// WriteCFB builds a version 3 compound file holding streams in its root storage
+// Streams below the 4096-byte cutoff are stored in the mini stream, as readers expect.
+// Directory entries are chained through their right siblings instead of forming a
+// balanced red-black tree, which readers accept
+func WriteCFB(streams []Stream) []byte {
+	var fat []uint32
+	var sectors [][]byte
+
+	// allocate appends data as a chain of regular sectors and returns its first sector
+	allocate := func(data []byte) uint32 {
+		if len(data) == 0 {
+			return endOfChain
+		}
+		first := uint32(len(sectors))
+		for off := 0; off < len(data); off += sectorSize {
+			sector := make([]byte, sectorSize)
+			copy(sector, data[off:])
+			sectors = append(sectors, sector)
+			fat = append(fat, uint32(len(sectors)))
+		}
+		fat[len(fat)-1] = endOfChain
+		return first
+	}
+
+	// Small streams go to the mini stream, chained through the mini FAT
+	var miniStream []byte
+	var miniFAT []uint32
+	starts := make([]uint32, len(streams))
+	for i, s := range streams {
+		if len(s.Data) >= miniCutoff {
+			continue
+		}
+		if len(s.Data) == 0 {
+			starts[i] = endOfChain
+			continue
+		}
+		starts[i] = uint32(len(miniFAT))
+		for off := 0; off < len(s.Data); off += miniSectorSize {
+			chunk := make([]byte, miniSectorSize)
+			copy(chunk, s.Data[off:])
+			miniStream = append(miniStream, chunk...)
+			miniFAT = append(miniFAT, uint32(len(miniFAT)+1))
+		}
+		miniFAT[len(miniFAT)-1] = endOfChain
+	}
+
+	// Regular streams are laid out first; the FAT goes after them once its size is known
+	for i, s := range streams {
+		if len(s.Data) >= miniCutoff {
+			starts[i] = allocate(s.Data)
+		}
+	}
+	miniStreamStart := allocate(miniStream)
+	miniFATStart := allocate(uint32Bytes(miniFAT, freeSect))
+
+	dir := make([]byte, 0, (len(streams)+1)*dirEntrySize)
+	root := dirEntry("Root Entry", typeRoot, miniStreamStart, uint64(len(miniStream)))
+	if len(streams) > 0 {
+		binary.LittleEndian.PutUint32(root[76:], 1)
+	}
+	dir = append(dir, root...)
+	for i, s := range streams {
+		entry := dirEntry(s.Name, typeStream, starts[i], uint64(len(s.Data)))
+		if i+1 < len(streams) {
+			binary.LittleEndian.PutUint32(entry[72:], uint32(i+2))
+		}
+		dir = append(dir, entry...)
+	}
+	for len(dir)%sectorSize != 0 {
+		dir = append(dir, unusedDirEntry()...)
+	}
+	dirStart := allocate(dir)
+
+	// The FAT sectors are placed last; they also need FAT entries of their own
+	fatSectors := 1
+	for len(sectors)+fatSectors > fatSectors*fatPerSector {
+		fatSectors++
+	}
+	fatStart := uint32(len(sectors))
+	for i := 0; i < fatSectors; i++ {
+		fat = append(fat, fatSect)
+		sectors = append(sectors, nil)
+	}
+	fatData := uint32Bytes(fat, freeSect)
+	for i := 0; i < fatSectors; i++ {
+		sector := make([]byte, sectorSize)
+		for j := range sector {
+			sector[j] = 0xFF
+		}
+		copy(sector, fatData[i*sectorSize:min((i+1)*sectorSize, len(fatData))])
+		sectors[int(fatStart)+i] = sector
+	}
+
+	header := make([]byte, sectorSize)
+	copy(header, cfbSignature)
+	binary.LittleEndian.PutUint16(header[24:], headerMinor)
+	binary.LittleEndian.PutUint16(header[26:], headerMajor)
+	binary.LittleEndian.PutUint16(header[28:], 0xFFFE)
+	binary.LittleEndian.PutUint16(header[30:], 9) // 512-byte sectors
+	binary.LittleEndian.PutUint16(header[32:], 6) // 64-byte mini sectors
+	binary.LittleEndian.PutUint32(header[44:], uint32(fatSectors))
+	binary.LittleEndian.PutUint32(header[48:], dirStart)
+	binary.LittleEndian.PutUint32(header[56:], miniCutoff)
+	binary.LittleEndian.PutUint32(header[60:], miniFATStart)
+	binary.LittleEndian.PutUint32(header[64:], uint32((len(miniFAT)*4+sectorSize-1)/sectorSize))
+	binary.LittleEndian.PutUint32(header[68:], endOfChain) // No DIFAT sectors
+	for i := 0; i < 109; i++ {
+		difat := uint32(freeSect)
+		if i < fatSectors {
+			difat = fatStart + uint32(i)
+		}
+		binary.LittleEndian.PutUint32(header[76+i*4:], difat)
+	}
+
+	out := header
+	for _, sector := range sectors {
+		out = append(out, sector...)
+	}
+	return out
+}
+
+// dirEntry encodes a directory entry without siblings or children
+func dirEntry(name string, objectType byte, start uint32, size uint64) []byte {
+	entry := make([]byte, dirEntrySize)
+	units := utf16.Encode([]rune(name))
+	if len(units) > maxNameLen {
+		units = units[:maxNameLen]
+	}
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(entry[i*2:], u)
+	}
+	binary.LittleEndian.PutUint16(entry[64:], uint16((len(units)+1)*2))
+	entry[66] = objectType
+	entry[67] = colorBlack
+	binary.LittleEndian.PutUint32(entry[68:], noStream) // Left sibling
+	binary.LittleEndian.PutUint32(entry[72:], noStream) // Right sibling
+	binary.LittleEndian.PutUint32(entry[76:], noStream) // Child
+	binary.LittleEndian.PutUint32(entry[116:], start)
+	binary.LittleEndian.PutUint64(entry[120:], size)
+	return entry
+}
+
+// unusedDirEntry pads the directory to a whole sector
+func unusedDirEntry() []byte {
+	entry := make([]byte, dirEntrySize)
+	binary.LittleEndian.PutUint32(entry[68:], noStream)
+	binary.LittleEndian.PutUint32(entry[72:], noStream)
+	binary.LittleEndian.PutUint32(entry[76:], noStream)
+	return entry
+}
+
+// uint32Bytes encodes values little-endian, padded with pad to a whole sector
+func uint32Bytes(values []uint32, pad uint32) []byte {
+	if len(values) == 0 {
+		return nil
+	}
+	out := make([]byte, 0, len(values)*4)
+	for _, v := range values {
+		out = binary.LittleEndian.AppendUint32(out, v)
+	}
+	for len(out)%sectorSize != 0 {
+		out = binary.LittleEndian.AppendUint32(out, pad)
+	}
+	return out
+}
@@ -0,0 +1,343 @@
+package msitest
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf8"
+)
+
+// DefaultCodepage is the string pool codepage used when MSI.Codepage is zero (Windows-1252)
+const DefaultCodepage = 1252
+
+// Codepage for UTF-8 string pools, used by installers with non-Latin metadata
+const CodepageUTF8 = 65001
+
+// Property is a row of the MSI Property table
+type Property struct {
+	Name  string
+	Value string
+}
+
+// MSI describes a synthetic installer database
+type MSI struct {
+	// Properties become rows of the Property table, in this order
+	Properties []Property
+	// PackageCode is stored as the Summary Information revision number (omitted when empty)
+	PackageCode string
+	// WordCount is stored as the Summary Information Word Count flags (omitted when zero)
+	WordCount int
+	// Subject, Author and Template are stored as the Summary Information properties of the
+	// same name (omitted when empty); the Template of a patch lists its target product codes
+	Subject  string
+	Author   string
+	Template string
+	// Codepage of the string pool; strings are encoded as UTF-8 for CodepageUTF8 and
+	// as single bytes otherwise (runes above 0xFF become '?')
+	Codepage int
+	// Tables are further tables, such as CustomAction, stored after the Property table
+	Tables []Table
+}
+
+// Column types of the _Columns table: strings are pool references, integers are
+// stored with their sign bit flipped; the low byte is the size
+const (
+	ColumnString   = 0x0D00 // s<size>, e.g. ColumnString|72
+	ColumnInt16    = 0x0102 // i2
+	ColumnInt32    = 0x0104 // i4
+	ColumnNullable = 0x1000
+	ColumnKey      = 0x2000
+)
+
+// Column is a column of a Table
+type Column struct {
+	Name string
+	Type uint16
+}
+
+// Table is an MSI table; cells are strings for string columns and ints for integer
+// columns, nil for null
+type Table struct {
+	Name    string
+	Columns []Column
+	Rows    [][]any
+}
+
+// Build returns the MSI as compound file bytes
+func Build(m MSI) []byte {
+	codepage := m.Codepage
+	if codepage == 0 {
+		codepage = DefaultCodepage
+	}
+
+	pool := newStringPool(codepage)
+	propertyTable := pool.intern("Property")
+	valueColumn := pool.intern("Value")
+
+	// Tables are stored column by column as 16-bit string pool references
+	var names, values []byte
+	for _, p := range m.Properties {
+		names = binary.LittleEndian.AppendUint16(names, pool.intern(p.Name))
+		values = binary.LittleEndian.AppendUint16(values, pool.intern(p.Value))
+	}
+
+	// _Columns rows: Table, Number, Name, Type (numbers are stored with the high bit set)
+	type columnRow struct{ table, number, name, columnType uint16 }
+	columnRows := []columnRow{
+		{propertyTable, 1, propertyTable, 0x2D48}, // s72 primary key
+		{propertyTable, 2, valueColumn, 0x0F00},   // l0
+	}
+	tables := binary.LittleEndian.AppendUint16(nil, propertyTable)
+	var extra []Stream
+	for _, table := range m.Tables {
+		name := pool.intern(table.Name)
+		tables = binary.LittleEndian.AppendUint16(tables, name)
+		var data []byte
+		for c, column := range table.Columns {
+			columnRows = append(columnRows, columnRow{name, uint16(c + 1), pool.intern(column.Name), column.Type})
+			for _, row := range table.Rows {
+				data = appendCell(data, pool, column.Type, row[c])
+			}
+		}
+		extra = append(extra, Stream{Name: tableStreamName(table.Name), Data: data})
+	}
+
+	var columns []byte
+	for _, r := range columnRows {
+		columns = binary.LittleEndian.AppendUint16(columns, r.table)
+	}
+	for _, r := range columnRows {
+		columns = binary.LittleEndian.AppendUint16(columns, 0x8000|r.number)
+	}
+	for _, r := range columnRows {
+		columns = binary.LittleEndian.AppendUint16(columns, r.name)
+	}
+	for _, r := range columnRows {
+		columns = binary.LittleEndian.AppendUint16(columns, 0x8000^r.columnType)
+	}
+
+	streams := []Stream{
+		{Name: tableStreamName("_StringPool"), Data: pool.poolBytes()},
+		{Name: tableStreamName("_StringData"), Data: pool.data},
+		{Name: tableStreamName("_Tables"), Data: tables},
+		{Name: tableStreamName("_Columns"), Data: columns},
+		{Name: tableStreamName("Property"), Data: append(names, values...)},
+	}
+	streams = append(streams, extra...)
+	if m.PackageCode != "" || m.WordCount != 0 || m.Subject != "" || m.Author != "" || m.Template != "" {
+		streams = append(streams, Stream{Name: "\x05SummaryInformation", Data: summaryInformation(m)})
+	}
+	return WriteCFB(streams)
+}
+
+// appendCell encodes one cell of a table column
+func appendCell(data []byte, pool *stringPool, columnType uint16, cell any) []byte {
+	switch {
+	case columnType&0x0800 != 0:
+		s, _ := cell.(string)
+		return binary.LittleEndian.AppendUint16(data, pool.intern(s))
+	case columnType&0xFF == 4:
+		v, ok := cell.(int)
+		if !ok {
+			return binary.LittleEndian.AppendUint32(data, 0)
+		}
+		return binary.LittleEndian.AppendUint32(data, uint32(int32(v))^0x80000000)
+	default:
+		v, ok := cell.(int)
+		if !ok {
+			return binary.LittleEndian.AppendUint16(data, 0)
+		}
+		return binary.LittleEndian.AppendUint16(data, uint16(int16(v))^0x8000)
+	}
+}
+
+// WriteFile builds the MSI and writes it to dir/name, returning the path
+func WriteFile(t testing.TB, dir, name string, m MSI) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, Build(m), 0644); err != nil {
+		t.Fatalf("Failed to write MSI fixture: %v", err)
+	}
+	return path
+}
+
+// stringPool assigns 1-based ids to unique strings, as the MSI _StringPool does
+type stringPool struct {
+	codepage int
+	ids      map[string]uint16
+	entries  []poolEntry
+	data     []byte
+}
+
+// poolEntry is the length and reference count of one pooled string
+type poolEntry struct {
+	length   uint16
+	refCount uint16
+}
+
+func newStringPool(codepage int) *stringPool {
+	return &stringPool{codepage: codepage, ids: make(map[string]uint16)}
+}
+
+// intern returns the id of s, adding it to the pool on first use
+// Empty strings are stored as id 0, like null table cells
+func (p *stringPool) intern(s string) uint16 {
+	if s == "" {
+		return 0
+	}
+	if id, ok := p.ids[s]; ok {
+		p.entries[id-1].refCount++
+		return id
+	}
+	encoded := p.encode(s)
+	p.data = append(p.data, encoded...)
+	p.entries = append(p.entries, poolEntry{length: uint16(len(encoded)), refCount: 1})
+	id := uint16(len(p.entries))
+	p.ids[s] = id
+	return id
+}
+
+// encode converts s to the pool codepage
+func (p *stringPool) encode(s string) []byte {
+	if p.codepage == CodepageUTF8 {
+		return []byte(s)
+	}
+	out := make([]byte, 0, utf8.RuneCountInString(s))
+	for _, r := range s {
+		if r > 0xFF {
+			r = '?'
+		}
+		out = append(out, byte(r))
+	}
+	return out
+}
+
+// poolBytes encodes the _StringPool stream: the codepage, then a length and
+// reference count per string
+func (p *stringPool) poolBytes() []byte {
+	out := binary.LittleEndian.AppendUint32(nil, uint32(p.codepage))
+	for _, e := range p.entries {
+		out = binary.LittleEndian.AppendUint16(out, e.length)
+		out = binary.LittleEndian.AppendUint16(out, e.refCount)
+	}
+	return out
+}
+
+// msiNameCharset maps 6-bit values to the characters allowed in compressed stream names
+const msiNameCharset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz._"
+
+// tableStreamName encodes an MSI table name the way Windows Installer stores it:
+// a 0x4840 marker, then pairs of characters packed into one code unit each
+func tableStreamName(table string) string {
+	units := []rune{0x4840}
+	for i := 0; i < len(table); i += 2 {
+		first := charsetIndex(table[i])
+		if i+1 < len(table) {
+			second := charsetIndex(table[i+1])
+			units = append(units, rune(0x3800+first+second<<6))
+		} else {
+			units = append(units, rune(0x4800+first))
+		}
+	}
+	return string(units)
+}
+
+// charsetIndex returns the 6-bit code of c in msiNameCharset
+func charsetIndex(c byte) int {
+	for i := 0; i < len(msiNameCharset); i++ {
+		if msiNameCharset[i] == c {
+			return i
+		}
+	}
+	panic("msitest: character not allowed in table name: " + string(c))
+}
+
+// summaryInformationFMTID is {F29F85E0-4FF9-1068-AB91-08002B27B3D9} in its on-disk byte order
+var summaryInformationFMTID = []byte{
+	0xE0, 0x85, 0x9F, 0xF2, 0xF9, 0x4F, 0x68, 0x10,
+	0xAB, 0x91, 0x08, 0x00, 0x2B, 0x27, 0xB3, 0xD9,
+}
+
+// Summary Information property ids and types
+const (
+	pidCodepage  = 1
+	pidSubject   = 3
+	pidAuthor    = 4
+	pidTemplate  = 7
+	pidRevNumber = 9
+	pidWordCount = 15
+	vtI2         = 0x0002
+	vtI4         = 0x0003
+	vtLPSTR      = 0x001E
+)
+
+// summaryInformation encodes a property set stream holding the codepage, the
+// subject, author and template, the revision number, where MSI keeps its PackageCode,
+// and the Word Count flags
+func summaryInformation(m MSI) []byte {
+	type property struct {
+		id    uint32
+		value []byte
+	}
+
+	// Property values, each padded to 4 bytes
+	codepage := binary.LittleEndian.AppendUint32(nil, vtI2)
+	codepage = binary.LittleEndian.AppendUint16(codepage, DefaultCodepage)
+	codepage = append(codepage, 0, 0)
+	properties := []property{{pidCodepage, codepage}}
+
+	for _, p := range []struct {
+		id    uint32
+		value string
+	}{{pidSubject, m.Subject}, {pidAuthor, m.Author}, {pidTemplate, m.Template}, {pidRevNumber, m.PackageCode}} {
+		if p.value != "" {
+			properties = append(properties, property{p.id, lpstr(p.value)})
+		}
+	}
+	if m.WordCount != 0 {
+		flags := binary.LittleEndian.AppendUint32(nil, vtI4)
+		flags = binary.LittleEndian.AppendUint32(flags, uint32(m.WordCount))
+		properties = append(properties, property{pidWordCount, flags})
+	}
+
+	sectionHeader := 8 + 8*len(properties) // size, count, id/offset pairs
+	size := sectionHeader
+	for _, p := range properties {
+		size += len(p.value)
+	}
+	section := binary.LittleEndian.AppendUint32(nil, uint32(size))
+	section = binary.LittleEndian.AppendUint32(section, uint32(len(properties)))
+	offset := sectionHeader
+	for _, p := range properties {
+		section = binary.LittleEndian.AppendUint32(section, p.id)
+		section = binary.LittleEndian.AppendUint32(section, uint32(offset))
+		offset += len(p.value)
+	}
+	for _, p := range properties {
+		section = append(section, p.value...)
+	}
+
+	// Stream header: byte order, version, system id, CLSID, one FMTID/offset pair
+	out := binary.LittleEndian.AppendUint16(nil, 0xFFFE)
+	out = binary.LittleEndian.AppendUint16(out, 0)
+	out = binary.LittleEndian.AppendUint32(out, 0x00020006) // Windows NT 6.2
+	out = append(out, make([]byte, 16)...)
+	out = binary.LittleEndian.AppendUint32(out, 1)
+	out = append(out, summaryInformationFMTID...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(out)+4))
+	return append(out, section...)
+}
+
+// lpstr encodes a string property value: its type, length and null-terminated text,
+// padded to 4 bytes
+func lpstr(s string) []byte {
+	text := append([]byte(s), 0)
+	value := binary.LittleEndian.AppendUint32(nil, vtLPSTR)
+	value = binary.LittleEndian.AppendUint32(value, uint32(len(text)))
+	value = append(value, text...)
+	for len(value)%4 != 0 {
+		value = append(value, 0)
+	}
+	return value
+}
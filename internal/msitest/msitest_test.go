@@ -0,0 +1,111 @@
+package msitest
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/msoleps"
+)
+
+func TestWriteCFBRoundTrip(t *testing.T) {
+	large := bytes.Repeat([]byte("0123456789abcdef"), 600) // Above the mini stream cutoff
+	streams := []Stream{
+		{Name: "small", Data: []byte("hello")},
+		{Name: "large", Data: large},
+		{Name: "empty", Data: nil},
+		{Name: "boundary", Data: bytes.Repeat([]byte{0xAB}, miniSectorSize)},
+	}
+
+	doc, err := mscfb.New(bytes.NewReader(WriteCFB(streams)))
+	if err != nil {
+		t.Fatalf("Failed to parse compound file: %v", err)
+	}
+
+	got := make(map[string][]byte)
+	for entry, err := doc.Next(); err == nil; entry, err = doc.Next() {
+		data, readErr := io.ReadAll(entry)
+		if readErr != nil {
+			t.Fatalf("Failed to read stream %q: %v", entry.Name, readErr)
+		}
+		got[entry.Name] = data
+	}
+
+	for _, s := range streams {
+		data, ok := got[s.Name]
+		if !ok {
+			t.Errorf("Stream %q missing", s.Name)
+			continue
+		}
+		if !bytes.Equal(data, s.Data) {
+			t.Errorf("Stream %q: got %d bytes, want %d", s.Name, len(data), len(s.Data))
+		}
+	}
+}
+
+func TestBuildMSIStreams(t *testing.T) {
+	data := Build(MSI{
+		Properties:  []Property{{Name: "ProductName", Value: "Fixture"}, {Name: "ProductVersion", Value: "1.2.3"}},
+		PackageCode: "{11111111-2222-3333-4444-555555555555}",
+	})
+
+	doc, err := mscfb.New(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to parse MSI: %v", err)
+	}
+
+	names := make(map[string][]byte)
+	for entry, err := doc.Next(); err == nil; entry, err = doc.Next() {
+		content, _ := io.ReadAll(entry)
+		names[entry.Name] = content
+	}
+
+	if got := string(names[tableStreamName("_StringData")]); got != "PropertyValueProductNameFixtureProductVersion1.2.3" {
+		t.Errorf("_StringData = %q", got)
+	}
+	// Property column: ids 3 and 5, Value column: ids 4 and 6
+	want := []byte{3, 0, 5, 0, 4, 0, 6, 0}
+	if got := names[tableStreamName("Property")]; !bytes.Equal(got, want) {
+		t.Errorf("Property table = %v, want %v", got, want)
+	}
+
+	// mscfb strips the leading \x05 from property set stream names
+	props, err := msoleps.NewFrom(bytes.NewReader(names["SummaryInformation"]))
+	if err != nil {
+		t.Fatalf("Failed to parse Summary Information: %v", err)
+	}
+	found := false
+	for _, prop := range props.Property {
+		if prop.Name == "RevNumber" {
+			found = true
+			if prop.String() != "{11111111-2222-3333-4444-555555555555}" {
+				t.Errorf("Revision number = %q", prop.String())
+			}
+		}
+	}
+	if !found {
+		t.Error("Revision number property missing from Summary Information")
+	}
+}
+
+func TestTableStreamName(t *testing.T) {
+	// The name starts with the table marker and packs two characters per unit
+	got := []rune(tableStreamName("Property"))
+	if len(got) != 5 || got[0] != 0x4840 {
+		t.Fatalf("tableStreamName(Property) = %U", got)
+	}
+	// Each packed unit decodes back to its two characters
+	decoded := ""
+	for _, u := range got[1:] {
+		if u >= 0x4800 {
+			decoded += string(msiNameCharset[u-0x4800])
+			continue
+		}
+		v := int(u - 0x3800)
+		decoded += string(msiNameCharset[v&0x3F]) + string(msiNameCharset[v>>6])
+	}
+	if decoded != "Property" {
+		t.Errorf("Decoded name = %q, want Property", decoded)
+	}
+}
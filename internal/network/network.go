@@ -0,0 +1,172 @@
+// Package network routes the HTTP requests of the tool through a proxy and trusts additional
+// certificate authorities, for packaging servers behind TLS-inspecting proxies
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Options configures the proxy and certificate authorities of HTTP requests
+type Options struct {
+	// Proxy is the URL of the proxy of all requests, with user:password@ for a proxy that
+	// requires authentication; empty uses HTTPS_PROXY, or else HTTP_PROXY
+	Proxy string
+	// ProxyUser and ProxyPassword authenticate to a proxy whose URL has no credentials
+	ProxyUser     string
+	ProxyPassword string
+	// NoProxy lists hosts, domains and CIDR ranges reached without the proxy (e.g.
+	// "build01,.corp.contoso.com,10.0.0.0/8"); empty uses NO_PROXY
+	NoProxy string
+	// CACerts are PEM files of certificate authorities trusted in addition to the system
+	// roots, such as the root certificate of a TLS-inspecting proxy
+	CACerts []string
+}
+
+// NewTransport returns a transport like http.DefaultTransport with opts applied
+func NewTransport(opts Options) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if opts.Proxy == "" {
+		opts.Proxy = firstEnv("HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy")
+	}
+	if opts.NoProxy == "" {
+		opts.NoProxy = firstEnv("NO_PROXY", "no_proxy")
+	}
+	proxy, err := proxyFunc(opts)
+	if err != nil {
+		return nil, err
+	}
+	transport.Proxy = proxy
+
+	if len(opts.CACerts) > 0 {
+		roots, err := certPool(opts.CACerts)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	}
+	return transport, nil
+}
+
+// Configure returns the transport to build the HTTP clients of the tool on, with opts
+// applied; http.DefaultTransport is left unchanged
+func Configure(opts Options) (http.RoundTripper, error) {
+	transport, err := NewTransport(opts)
+	if err != nil {
+		return nil, err
+	}
+	return transport, nil
+}
+
+// proxyFunc returns the proxy selection of a transport
+func proxyFunc(opts Options) (func(*http.Request) (*url.URL, error), error) {
+	if opts.Proxy == "" {
+		return nil, nil
+	}
+	proxyURL, err := parseProxyURL(opts.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL.User == nil && opts.ProxyUser != "" {
+		proxyURL.User = url.UserPassword(opts.ProxyUser, opts.ProxyPassword)
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(req.URL.Hostname(), opts.NoProxy) {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}, nil
+}
+
+// parseProxyURL parses a proxy URL, taking a bare host:port as an HTTP proxy
+func parseProxyURL(raw string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %s: unsupported scheme %s (supported: http, https, socks5)", u.Redacted(), u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %s: missing host", u.Redacted())
+	}
+	return u, nil
+}
+
+// bypassProxy reports whether host is reached without the proxy: loopback and link-local
+// addresses, such as the managed identity endpoint, and the entries of noProxy
+func bypassProxy(host, noProxy string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && (ip.IsLoopback() || ip.IsLinkLocalUnicast()) {
+		return true
+	}
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		// Ports of entries are ignored
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		entry = strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// certPool returns the system roots with the certificates of the PEM files added
+func certPool(files []string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no PEM certificates found in %s", file)
+		}
+	}
+	return pool, nil
+}
+
+// firstEnv returns the first non-empty of the environment variables
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,166 @@
+package network
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCACerts(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	// Without the CA of the server, its certificate is not trusted
+	transport, err := NewTransport(Options{})
+	if err != nil {
+		t.Fatalf("NewTransport() error = %v", err)
+	}
+	if _, err := (&http.Client{Transport: transport}).Get(server.URL); err == nil {
+		t.Fatal("Expected a certificate error without --ca-cert")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "proxy-ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, cert, 0644); err != nil {
+		t.Fatalf("Failed to write CA file: %v", err)
+	}
+	transport, err = NewTransport(Options{CACerts: []string{caFile}})
+	if err != nil {
+		t.Fatalf("NewTransport() error = %v", err)
+	}
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() with CA error = %v", err)
+	}
+	resp.Body.Close()
+
+	notPEM := filepath.Join(t.TempDir(), "empty.pem")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0644)
+	for _, file := range []string{notPEM, filepath.Join(t.TempDir(), "missing.pem")} {
+		if _, err := NewTransport(Options{CACerts: []string{file}}); err == nil {
+			t.Errorf("Expected error for CA file %s", filepath.Base(file))
+		}
+	}
+}
+
+func TestProxy(t *testing.T) {
+	t.Setenv("NO_PROXY", "")
+	t.Setenv("no_proxy", "")
+
+	var gotURL, gotAuth string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURL = r.URL.String()
+		gotAuth = r.Header.Get("Proxy-Authorization")
+		io.WriteString(w, "proxied")
+	}))
+	defer proxy.Close()
+
+	transport, err := NewTransport(Options{Proxy: proxy.URL, ProxyUser: "svc-packager", ProxyPassword: "p@ss"})
+	if err != nil {
+		t.Fatalf("NewTransport() error = %v", err)
+	}
+	resp, err := (&http.Client{Transport: transport}).Get("http://downloads.contoso.com/setup.exe")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if string(body) != "proxied" || gotURL != "http://downloads.contoso.com/setup.exe" {
+		t.Errorf("Request was not sent through the proxy: body %q, URL %q", body, gotURL)
+	}
+	want := "Basic " + base64.StdEncoding.EncodeToString([]byte("svc-packager:p@ss"))
+	if gotAuth != want {
+		t.Errorf("Proxy-Authorization = %q, want %q", gotAuth, want)
+	}
+}
+
+func TestProxyFromEnvironment(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "")
+	t.Setenv("https_proxy", "")
+	t.Setenv("HTTP_PROXY", "proxy.contoso.com:3128")
+	t.Setenv("NO_PROXY", ".corp.contoso.com")
+
+	transport, err := NewTransport(Options{})
+	if err != nil {
+		t.Fatalf("NewTransport() error = %v", err)
+	}
+	req, _ := http.NewRequest("GET", "https://graph.microsoft.com/beta", nil)
+	if u, _ := transport.Proxy(req); u == nil || u.String() != "http://proxy.contoso.com:3128" {
+		t.Errorf("Proxy = %v, want http://proxy.contoso.com:3128", u)
+	}
+	req, _ = http.NewRequest("GET", "https://share.corp.contoso.com/apps", nil)
+	if u, _ := transport.Proxy(req); u != nil {
+		t.Errorf("Proxy = %v, want none for a NO_PROXY domain", u)
+	}
+
+	if _, err := NewTransport(Options{Proxy: "ftp://proxy.contoso.com"}); err == nil {
+		t.Error("Expected error for an unsupported proxy scheme")
+	}
+}
+
+func TestBypassProxy(t *testing.T) {
+	noProxy := "build01, .corp.contoso.com,*.internal.example,10.0.0.0/8,files.contoso.com:8443"
+	tests := map[string]bool{
+		"localhost":               true,
+		"127.0.0.1":               true,
+		"169.254.169.254":         true,
+		"build01":                 true,
+		"share.corp.contoso.com":  true,
+		"corp.contoso.com":        true,
+		"a.b.internal.example":    true,
+		"10.20.30.40":             true,
+		"files.contoso.com":       true,
+		"graph.microsoft.com":     false,
+		"notcorp.contoso.com":     false,
+		"11.0.0.1":                false,
+		"login.microsoftonline.x": false,
+	}
+	for host, want := range tests {
+		if got := bypassProxy(host, noProxy); got != want {
+			t.Errorf("bypassProxy(%q) = %v, want %v", host, got, want)
+		}
+	}
+	if !bypassProxy("graph.microsoft.com", "*") {
+		t.Error("Expected * to bypass the proxy for every host")
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	t.Setenv("NO_PROXY", "")
+	t.Setenv("no_proxy", "")
+	defaultTransport := http.DefaultTransport
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "proxied")
+	}))
+	defer proxy.Close()
+
+	transport, err := Configure(Options{Proxy: proxy.URL})
+	if err != nil {
+		t.Fatalf("Configure() error = %v", err)
+	}
+	if http.DefaultTransport != defaultTransport {
+		t.Error("Configure() should leave http.DefaultTransport unchanged")
+	}
+	resp, err := (&http.Client{Transport: transport}).Get("http://downloads.contoso.com/setup.exe")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "proxied" {
+		t.Errorf("Request was not sent through the proxy: body %q", body)
+	}
+
+	if _, err := Configure(Options{Proxy: "ftp://proxy.contoso.com"}); err == nil {
+		t.Error("Expected error for an unsupported proxy scheme")
+	}
+}
@@ -0,0 +1,55 @@
+package packager
+
+import (
+	"log/slog"
+	"runtime"
+	"time"
+
+	"golang.org/x/sys/cpu"
+)
+
+// softwareAESWarnSize is the content size above which encrypting without hardware AES is
+// slow enough to warn about
+const softwareAESWarnSize = 1 << 30
+
+// AESAcceleration returns the CPU instructions crypto/aes encrypts with, such as AES-NI,
+// or "" when it falls back to constant-time software AES, several times slower
+func AESAcceleration() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		if cpu.X86.HasAES {
+			return "AES-NI"
+		}
+	case "arm64":
+		if cpu.ARM64.HasAES {
+			return "ARMv8 AES"
+		}
+	case "s390x":
+		if cpu.S390X.HasAES {
+			return "CPACF"
+		}
+	case "ppc64le":
+		// POWER8 and later, the minimum for ppc64le, always have the AES instructions
+		return "POWER8 AES"
+	}
+	return ""
+}
+
+// FormatThroughput formats a rate in bytes per second, like FormatSize
+func FormatThroughput(bytesPerSecond float64) string {
+	return FormatSize(int64(bytesPerSecond)) + "/s"
+}
+
+// logEncryption logs the encryption throughput of a package and warns when large content
+// is encrypted without hardware AES
+func logEncryption(log *slog.Logger, size int64, elapsed time.Duration) {
+	accel := AESAcceleration()
+	if accel == "" && size > softwareAESWarnSize {
+		log.Warn("the CPU has no AES instructions, encryption runs in software and is slow", "bytes", size)
+	}
+	var rate float64
+	if elapsed > 0 {
+		rate = float64(size) / elapsed.Seconds()
+	}
+	log.Debug("content encrypted", "bytes", size, "duration", elapsed, "throughput", FormatThroughput(rate), "aes", firstNonEmptyString(accel, "software"))
+}
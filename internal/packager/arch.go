@@ -0,0 +1,103 @@
+package packager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// archFolderNames maps common per-architecture folder names to Intune architecture names
+var archFolderNames = map[string]string{
+	"x86":   "x86",
+	"win32": "x86",
+	"32bit": "x86",
+	"x64":   "x64",
+	"amd64": "x64",
+	"win64": "x64",
+	"64bit": "x64",
+	"arm64": "arm64",
+}
+
+// ArchSource is the installer of one architecture in a multi-arch source folder
+type ArchSource struct {
+	Arch      string // Intune architecture name (x86, x64 or arm64)
+	Dir       string // Subfolder holding the installer
+	SetupFile string // Setup file name within Dir
+}
+
+// ArchitectureFromDir returns the Intune architecture of a folder name such as "x64" or "amd64"
+func ArchitectureFromDir(name string) (string, bool) {
+	arch, ok := archFolderNames[strings.ToLower(name)]
+	return arch, ok
+}
+
+// FindArchSources finds per-architecture subfolders (x86/, x64/, arm64/, ...) of sourcePath
+// setupFile names the installer in every subfolder; when empty, each subfolder must
+// contain exactly one MSI or EXE. At least two architectures are required
+func FindArchSources(sourcePath, setupFile string) ([]ArchSource, error) {
+	entries, err := os.ReadDir(sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source folder: %w", err)
+	}
+
+	var sources []ArchSource
+	seen := make(map[string]string)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		arch, ok := ArchitectureFromDir(entry.Name())
+		if !ok {
+			continue
+		}
+		if other, dup := seen[arch]; dup {
+			return nil, fmt.Errorf("folders %s and %s both hold %s installers", other, entry.Name(), arch)
+		}
+		seen[arch] = entry.Name()
+
+		dir := filepath.Join(sourcePath, entry.Name())
+		setup := setupFile
+		if setup == "" {
+			setup, err = findSingleInstaller(dir)
+			if err != nil {
+				return nil, err
+			}
+		} else if _, err := os.Stat(filepath.Join(dir, setup)); err != nil {
+			return nil, fmt.Errorf("setup file %s not found in %s", setup, dir)
+		}
+		sources = append(sources, ArchSource{Arch: arch, Dir: dir, SetupFile: setup})
+	}
+
+	if len(sources) < 2 {
+		return nil, fmt.Errorf("no multi-arch layout found in %s (expected subfolders such as x86/ and x64/)", sourcePath)
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Arch < sources[j].Arch })
+	return sources, nil
+}
+
+// findSingleInstaller returns the only MSI or EXE file directly in dir
+func findSingleInstaller(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	var installers []string
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if !entry.IsDir() && (ext == ".msi" || ext == ".exe") {
+			installers = append(installers, entry.Name())
+		}
+	}
+
+	switch len(installers) {
+	case 1:
+		return installers[0], nil
+	case 0:
+		return "", fmt.Errorf("no MSI or EXE installer found in %s", dir)
+	default:
+		return "", fmt.Errorf("several installers found in %s (%s), choose one with --setup", dir, strings.Join(installers, ", "))
+	}
+}
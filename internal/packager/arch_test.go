@@ -0,0 +1,84 @@
+package packager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeArchLayout creates the given files (relative paths) below a new temp folder
+func writeArchLayout(t *testing.T, files ...string) string {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "arch")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	for _, file := range files {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create folder: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(file), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestFindArchSources(t *testing.T) {
+	dir := writeArchLayout(t, "x86/setup.exe", "AMD64/setup.exe", "AMD64/readme.txt", "docs/manual.pdf")
+
+	sources, err := FindArchSources(dir, "")
+	if err != nil {
+		t.Fatalf("FindArchSources() error = %v", err)
+	}
+	if len(sources) != 2 {
+		t.Fatalf("FindArchSources() = %d sources, want 2", len(sources))
+	}
+	if sources[0].Arch != "x64" || sources[0].Dir != filepath.Join(dir, "AMD64") || sources[0].SetupFile != "setup.exe" {
+		t.Errorf("sources[0] = %+v", sources[0])
+	}
+	if sources[1].Arch != "x86" {
+		t.Errorf("sources[1].Arch = %s, want x86", sources[1].Arch)
+	}
+}
+
+func TestFindArchSourcesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		setup   string
+		wantErr string
+	}{
+		{"single arch", []string{"x64/setup.exe"}, "", "no multi-arch layout"},
+		{"duplicate arch", []string{"x64/setup.exe", "amd64/setup.exe", "x86/setup.exe"}, "", "both hold x64"},
+		{"ambiguous installer", []string{"x64/a.exe", "x64/b.msi", "x86/a.exe"}, "", "several installers"},
+		{"missing setup", []string{"x64/setup.exe", "x86/install.exe"}, "setup.exe", "not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeArchLayout(t, tt.files...)
+			_, err := FindArchSources(dir, tt.setup)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("FindArchSources() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPackageOutputName(t *testing.T) {
+	sourceDir, outputDir, _ := setupCheckpointTest(t)
+
+	result, err := PackageWithOptions(sourceDir, "setup.exe", outputDir, Options{OutputName: "setup-x64"}, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	if filepath.Base(result.OutputPath) != "setup-x64.intunewin" {
+		t.Errorf("OutputPath = %s, want setup-x64.intunewin", result.OutputPath)
+	}
+}
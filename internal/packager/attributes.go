@@ -0,0 +1,119 @@
+package packager
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MS-DOS attributes stored in the low byte of the external attributes of a ZIP entry,
+// next to the Unix mode in the high 16 bits
+const (
+	dosHidden  = 0x02
+	dosSystem  = 0x04
+	dosArchive = 0x20
+)
+
+// Modes of entries when permissions are normalized
+const (
+	normalDirMode  = os.ModeDir | 0755
+	normalExecMode = 0755
+	normalFileMode = 0644
+)
+
+// executableExtensions are scripts that get mode 0755 when permissions are normalized,
+// even when packaged from Windows, which has no executable bit
+var executableExtensions = map[string]bool{
+	".sh":      true,
+	".bash":    true,
+	".zsh":     true,
+	".ksh":     true,
+	".command": true,
+	".py":      true,
+	".pl":      true,
+	".rb":      true,
+}
+
+// entryHeader returns the content ZIP header of the source file or folder at path,
+// named name in the ZIP
+// The Unix mode of the source is stored, and on Windows its hidden, system and archive
+// attributes; ZipOptions.NormalizePermissions and ZipOptions.ModTime replace them
+func entryHeader(name, path string, info os.FileInfo, opts ZipOptions) (*zip.FileHeader, error) {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return nil, err
+	}
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+	} else {
+		header.Method = zip.Deflate
+	}
+
+	switch {
+	case opts.NormalizePermissions:
+		mode, err := normalizedMode(path, info)
+		if err != nil {
+			return nil, err
+		}
+		header.SetMode(mode)
+	case !opts.ModTime.IsZero():
+		// Reproducible builds do not depend on how the files were copied
+		if info.IsDir() {
+			header.SetMode(normalDirMode)
+		} else {
+			header.SetMode(normalFileMode)
+		}
+	default:
+		header.ExternalAttrs |= fileAttributes(info) & (dosHidden | dosSystem | dosArchive)
+	}
+	if !opts.ModTime.IsZero() {
+		header.Modified = opts.ModTime
+	}
+	return header, nil
+}
+
+// normalizedMode returns 0755 for folders and executables and 0644 for other files
+func normalizedMode(path string, info os.FileInfo) (os.FileMode, error) {
+	if info.IsDir() {
+		return normalDirMode, nil
+	}
+	executable, err := isExecutable(path, info)
+	if err != nil {
+		return 0, err
+	}
+	if executable {
+		return normalExecMode, nil
+	}
+	return normalFileMode, nil
+}
+
+// isExecutable reports whether a file is executable: it has an executable bit, a script
+// extension (see executableExtensions) or starts with a #! line
+func isExecutable(path string, info os.FileInfo) (bool, error) {
+	if info.Mode().Perm()&0111 != 0 || executableExtensions[strings.ToLower(filepath.Ext(path))] {
+		return true, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false, nil
+	}
+	return bytes.Equal(magic, []byte("#!")), nil
+}
+
+// extractMode returns the mode a file extracted from a content ZIP is created with:
+// 0755 when the entry has an executable bit, 0644 otherwise
+func extractMode(f *zip.File) os.FileMode {
+	if f.Mode().Perm()&0111 != 0 {
+		return normalExecMode
+	}
+	return normalFileMode
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package packager
+
+import "os"
+
+// fileAttributes returns the Windows file attributes of info; there are none on this platform
+func fileAttributes(info os.FileInfo) uint32 {
+	return 0
+}
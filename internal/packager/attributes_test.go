@@ -0,0 +1,162 @@
+package packager
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// modeSource creates files and folders with the given modes under a new folder
+func modeSource(t *testing.T, files map[string]os.FileMode) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix modes are not stored on Windows")
+	}
+	dir := t.TempDir()
+	contents := make(map[string]string, len(files))
+	for name := range files {
+		contents[name] = "content"
+		if filepath.Ext(name) == "" {
+			contents[name] = "#!/bin/sh\necho hello\n"
+		}
+	}
+	writeTree(t, dir, contents)
+	for name, mode := range files {
+		if err := os.Chmod(filepath.Join(dir, filepath.FromSlash(name)), mode); err != nil {
+			t.Fatalf("Failed to change mode: %v", err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(dir, "bin"), 0700); err != nil {
+		t.Fatalf("Failed to change mode: %v", err)
+	}
+	return dir
+}
+
+// zipModes returns the mode of every entry of a ZIP archive
+func zipModes(t *testing.T, data []byte) map[string]os.FileMode {
+	t.Helper()
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Failed to read ZIP: %v", err)
+	}
+	modes := make(map[string]os.FileMode)
+	for _, f := range reader.File {
+		modes[f.Name] = f.Mode()
+	}
+	return modes
+}
+
+var modeFiles = map[string]os.FileMode{
+	"bin/install.sh": 0644,
+	"bin/launcher":   0640,
+	"bin/tool":       0750,
+	"data.ini":       0600,
+}
+
+func TestZipPreservesModes(t *testing.T) {
+	source := modeSource(t, modeFiles)
+
+	data, err := ZipFolderWithOptions(source, ZipOptions{})
+	if err != nil {
+		t.Fatalf("ZipFolderWithOptions() error = %v", err)
+	}
+	want := map[string]os.FileMode{
+		"bin/":           os.ModeDir | 0700,
+		"bin/install.sh": 0644,
+		"bin/launcher":   0640,
+		"bin/tool":       0750,
+		"data.ini":       0600,
+	}
+	modes := zipModes(t, data)
+	for name, mode := range want {
+		if modes[name] != mode {
+			t.Errorf("Mode of %s = %v, want %v", name, modes[name], mode)
+		}
+	}
+
+	dest := t.TempDir()
+	if _, err := ExtractZip(data, dest); err != nil {
+		t.Fatalf("ExtractZip() error = %v", err)
+	}
+	for name, mode := range map[string]os.FileMode{"bin/tool": 0755, "data.ini": 0644} {
+		info, err := os.Stat(filepath.Join(dest, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", name, err)
+		}
+		if info.Mode().Perm()&^0022 != mode&^0022 {
+			t.Errorf("Extracted mode of %s = %v, want %v", name, info.Mode().Perm(), mode)
+		}
+	}
+}
+
+func TestZipNormalizePermissions(t *testing.T) {
+	source := modeSource(t, modeFiles)
+
+	data, err := ZipFolderWithOptions(source, ZipOptions{NormalizePermissions: true})
+	if err != nil {
+		t.Fatalf("ZipFolderWithOptions() error = %v", err)
+	}
+	want := map[string]os.FileMode{
+		"bin/":           os.ModeDir | 0755,
+		"bin/install.sh": 0755, // script extension
+		"bin/launcher":   0755, // #! line
+		"bin/tool":       0755, // executable bit
+		"data.ini":       0644,
+	}
+	modes := zipModes(t, data)
+	for name, mode := range want {
+		if modes[name] != mode {
+			t.Errorf("Mode of %s = %v, want %v", name, modes[name], mode)
+		}
+	}
+}
+
+func TestZipReproducibleModes(t *testing.T) {
+	source := modeSource(t, modeFiles)
+	modTime := time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC)
+
+	data, err := ZipFolderWithOptions(source, ZipOptions{ModTime: modTime})
+	if err != nil {
+		t.Fatalf("ZipFolderWithOptions() error = %v", err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Failed to read ZIP: %v", err)
+	}
+	for _, f := range reader.File {
+		want := os.FileMode(0644)
+		if f.FileInfo().IsDir() {
+			want = os.ModeDir | 0755
+		}
+		if f.Mode() != want {
+			t.Errorf("Mode of %s = %v, want %v", f.Name, f.Mode(), want)
+		}
+		if !f.Modified.Equal(modTime) {
+			t.Errorf("Modified of %s = %v, want %v", f.Name, f.Modified, modTime)
+		}
+	}
+}
+
+func TestEntryHeaderReadOnly(t *testing.T) {
+	source := modeSource(t, map[string]os.FileMode{"bin/readme.txt": 0444})
+	path := filepath.Join(source, "bin", "readme.txt")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+
+	header, err := entryHeader("bin/readme.txt", path, info, ZipOptions{})
+	if err != nil {
+		t.Fatalf("entryHeader() error = %v", err)
+	}
+	if header.ExternalAttrs&0x01 == 0 {
+		t.Errorf("ExternalAttrs = %#x, want the MS-DOS read-only attribute", header.ExternalAttrs)
+	}
+	if header.Method != zip.Deflate {
+		t.Errorf("Method = %d, want Deflate", header.Method)
+	}
+}
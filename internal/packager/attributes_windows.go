@@ -0,0 +1,15 @@
+package packager
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileAttributes returns the Windows file attributes of info, whose low byte matches the
+// MS-DOS attributes of ZIP entries
+func fileAttributes(info os.FileInfo) uint32 {
+	if data, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return data.FileAttributes
+	}
+	return 0
+}
@@ -0,0 +1,522 @@
+package packager
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/richardlehane/mscfb"
+)
+
+// ErrNotSigned is returned by VerifySignature for files without an Authenticode signature
+var ErrNotSigned = errors.New("file is not signed")
+
+// Signature describes the Authenticode signature of a setup file
+type Signature struct {
+	Signer          string     // Common name of the signing certificate
+	Issuer          string     // Common name of the certificate issuer
+	SerialNumber    string     // Serial number of the signing certificate (hex)
+	NotBefore       time.Time  // Start of the signing certificate validity period
+	NotAfter        time.Time  // End of the signing certificate validity period
+	Timestamp       *time.Time // Signing time from the countersignature (nil when not timestamped)
+	DigestAlgorithm string     // Digest algorithm of the signature (e.g., SHA256)
+
+	// ContentVerified reports that the file content matches the signed digest
+	// Only PE files (EXE) are checked; MSI content hashing is not implemented
+	ContentVerified bool
+
+	// TrustError is set when the certificate chain does not verify against the system roots
+	TrustError error
+}
+
+// Expired reports whether the signing certificate is expired at now without a
+// timestamp proving the file was signed while the certificate was valid
+func (s *Signature) Expired(now time.Time) bool {
+	if !now.After(s.NotAfter) {
+		return false
+	}
+	return s.Timestamp == nil || s.Timestamp.After(s.NotAfter) || s.Timestamp.Before(s.NotBefore)
+}
+
+// Warnings lists the problems of a structurally valid signature
+func (s *Signature) Warnings(now time.Time) []string {
+	var warnings []string
+	if s.Expired(now) {
+		warnings = append(warnings, fmt.Sprintf("signing certificate expired on %s and the signature is not timestamped", s.NotAfter.Format("2006-01-02")))
+	}
+	if s.TrustError != nil {
+		warnings = append(warnings, fmt.Sprintf("signing certificate is not trusted: %v", s.TrustError))
+	}
+	return warnings
+}
+
+// SupportsSignature reports whether Authenticode signatures of the setup file type can be checked
+func SupportsSignature(setupFile string) bool {
+	switch strings.ToLower(filepath.Ext(setupFile)) {
+	case ".exe", ".msi":
+		return true
+	}
+	return false
+}
+
+// VerifySignature reads and verifies the Authenticode signature of an EXE or MSI file
+// Returns ErrNotSigned for unsigned files and an error when the signature is malformed
+// or does not match the file. Trust and expiry problems are reported on the Signature
+func VerifySignature(path string) (*Signature, error) {
+	var blob []byte
+	var imageHash func(crypto.Hash) []byte
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".exe":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read setup file: %w", err)
+		}
+		var certOffset int
+		blob, certOffset, err = readPESignature(data)
+		if err != nil {
+			return nil, err
+		}
+		imageHash = func(h crypto.Hash) []byte { return peImageHash(data, certOffset, h) }
+	case ".msi":
+		var err error
+		blob, err = readMsiSignature(path)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("signature checks support only EXE and MSI files: %s", filepath.Base(path))
+	}
+
+	return verifyAuthenticode(blob, imageHash)
+}
+
+// PE layout offsets used to locate the certificate table
+const (
+	peSignatureOffset     = 0x3c // e_lfanew in the DOS header
+	peCoffHeaderSize      = 20
+	peChecksumOffset      = 64  // Within the optional header
+	pe32DataDirOffset     = 96  // Within the PE32 optional header
+	pe32PlusDataDirOffset = 112 // Within the PE32+ optional header
+	peSecurityDirIndex    = 4
+	winCertTypePKCS7      = 0x0002
+	winCertHeaderLength   = 8
+)
+
+// peLayout holds the offsets of the fields excluded from the Authenticode image hash
+type peLayout struct {
+	checksum    int // Offset of the optional header CheckSum field
+	securityDir int // Offset of the certificate table data directory entry
+}
+
+// parsePELayout locates the checksum and certificate table directory of a PE file
+func parsePELayout(data []byte) (*peLayout, error) {
+	if len(data) < peSignatureOffset+4 || data[0] != 'M' || data[1] != 'Z' {
+		return nil, fmt.Errorf("not a PE file")
+	}
+	peOffset := int(binary.LittleEndian.Uint32(data[peSignatureOffset:]))
+	optional := peOffset + 4 + peCoffHeaderSize
+	if optional+2 > len(data) || !bytes.Equal(data[peOffset:peOffset+4], []byte("PE\x00\x00")) {
+		return nil, fmt.Errorf("not a PE file")
+	}
+
+	var dataDirs int
+	switch binary.LittleEndian.Uint16(data[optional:]) {
+	case 0x10b:
+		dataDirs = optional + pe32DataDirOffset
+	case 0x20b:
+		dataDirs = optional + pe32PlusDataDirOffset
+	default:
+		return nil, fmt.Errorf("unknown PE optional header format")
+	}
+	if dataDirs > len(data) {
+		return nil, fmt.Errorf("truncated PE header")
+	}
+	rvaCount := int(binary.LittleEndian.Uint32(data[dataDirs-4:]))
+
+	layout := &peLayout{
+		checksum:    optional + peChecksumOffset,
+		securityDir: dataDirs + peSecurityDirIndex*8,
+	}
+	if rvaCount <= peSecurityDirIndex || layout.securityDir+8 > len(data) {
+		layout.securityDir = -1
+	}
+	return layout, nil
+}
+
+// readPESignature returns the PKCS#7 signature blob of a PE file and the offset of its certificate table
+func readPESignature(data []byte) ([]byte, int, error) {
+	layout, err := parsePELayout(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if layout.securityDir < 0 {
+		return nil, 0, ErrNotSigned
+	}
+
+	offset := int(binary.LittleEndian.Uint32(data[layout.securityDir:]))
+	size := int(binary.LittleEndian.Uint32(data[layout.securityDir+4:]))
+	if offset == 0 || size == 0 {
+		return nil, 0, ErrNotSigned
+	}
+	if size < winCertHeaderLength || offset < layout.securityDir+8 || offset+size > len(data) {
+		return nil, 0, fmt.Errorf("certificate table is outside the file")
+	}
+
+	length := int(binary.LittleEndian.Uint32(data[offset:]))
+	certType := binary.LittleEndian.Uint16(data[offset+6:])
+	if certType != winCertTypePKCS7 {
+		return nil, 0, fmt.Errorf("unsupported certificate type: %d", certType)
+	}
+	if length < winCertHeaderLength || length > size {
+		return nil, 0, fmt.Errorf("invalid certificate entry length")
+	}
+	return data[offset+winCertHeaderLength : offset+length], offset, nil
+}
+
+// peImageHash computes the Authenticode hash of a PE file: everything up to the
+// certificate table except the checksum field and the certificate table directory entry
+func peImageHash(data []byte, certOffset int, hash crypto.Hash) []byte {
+	layout, err := parsePELayout(data)
+	if err != nil || layout.securityDir < 0 {
+		return nil
+	}
+
+	h := hash.New()
+	h.Write(data[:layout.checksum])
+	h.Write(data[layout.checksum+4 : layout.securityDir])
+	h.Write(data[layout.securityDir+8 : certOffset])
+	return h.Sum(nil)
+}
+
+// readMsiSignature returns the PKCS#7 signature blob stored in the DigitalSignature stream of an MSI
+func readMsiSignature(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MSI file: %w", err)
+	}
+	defer file.Close()
+
+	doc, err := mscfb.New(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse MSI as OLE document: %w", err)
+	}
+	for entry, err := doc.Next(); err == nil; entry, err = doc.Next() {
+		if entry.Name == "\x05DigitalSignature" {
+			data, err := io.ReadAll(entry)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read MSI signature: %w", err)
+			}
+			return data, nil
+		}
+	}
+	return nil, ErrNotSigned
+}
+
+// ASN.1 object identifiers used by Authenticode
+var (
+	oidSignedData         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidSpcIndirectData    = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 4}
+	oidMessageDigest      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidCountersignature   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 6}
+	oidRFC3161Timestamp   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 3, 3, 1}
+	oidDigestSHA1         = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidDigestSHA256       = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidDigestSHA384       = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidDigestSHA512       = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+	digestAlgorithmsByOID = map[string]crypto.Hash{
+		oidDigestSHA1.String():   crypto.SHA1,
+		oidDigestSHA256.String(): crypto.SHA256,
+		oidDigestSHA384.String(): crypto.SHA384,
+		oidDigestSHA512.String(): crypto.SHA512,
+	}
+)
+
+// pkcs7ContentInfo is a PKCS#7 ContentInfo
+// Content keeps the explicit [0] wrapper; its Bytes are the DER of the content itself
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+// pkcs7SignedData is a PKCS#7 SignedData
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      pkcs7ContentInfo
+	Certificates     asn1.RawValue     `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue     `asn1:"optional,tag:1"`
+	SignerInfos      []pkcs7SignerInfo `asn1:"set"`
+}
+
+// pkcs7SignerInfo is a PKCS#7 SignerInfo
+// Attributes are kept raw: the signature covers their exact DER encoding
+type pkcs7SignerInfo struct {
+	Version                   int
+	IssuerAndSerial           pkcs7IssuerAndSerial
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+	UnauthenticatedAttributes asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+// pkcs7IssuerAndSerial identifies the signing certificate
+type pkcs7IssuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+// pkcs7Attribute is a signed or unsigned attribute of a SignerInfo
+type pkcs7Attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+// spcIndirectDataContent is the signed content of an Authenticode signature
+type spcIndirectDataContent struct {
+	Data          asn1.RawValue
+	MessageDigest digestInfo
+}
+
+// digestInfo holds the digest of the signed file
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+// tstInfo is the leading part of an RFC 3161 timestamp token; later fields are ignored
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint asn1.RawValue
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+}
+
+// verifyAuthenticode checks a PKCS#7 Authenticode signature
+// imageHash computes the file digest for comparison with the signed one (nil to skip)
+func verifyAuthenticode(blob []byte, imageHash func(crypto.Hash) []byte) (*Signature, error) {
+	signedData, err := parseSignedData(blob)
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature: %w", err)
+	}
+	if !signedData.ContentInfo.ContentType.Equal(oidSpcIndirectData) {
+		return nil, fmt.Errorf("malformed signature: not an Authenticode signature")
+	}
+	if len(signedData.SignerInfos) != 1 {
+		return nil, fmt.Errorf("malformed signature: expected one signer, found %d", len(signedData.SignerInfos))
+	}
+	signerInfo := signedData.SignerInfos[0]
+
+	certs, err := x509.ParseCertificates(signedData.Certificates.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature certificates: %w", err)
+	}
+	signer := findCertificate(certs, signerInfo.IssuerAndSerial)
+	if signer == nil {
+		return nil, fmt.Errorf("malformed signature: signing certificate not included")
+	}
+
+	hash, ok := digestAlgorithmsByOID[signerInfo.DigestAlgorithm.Algorithm.String()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported digest algorithm: %s", signerInfo.DigestAlgorithm.Algorithm)
+	}
+
+	// The signed attributes carry the digest of the SpcIndirectDataContent value,
+	// excluding its SEQUENCE tag and length
+	var content asn1.RawValue
+	if _, err := asn1.Unmarshal(signedData.ContentInfo.Content.Bytes, &content); err != nil {
+		return nil, fmt.Errorf("malformed Authenticode content: %w", err)
+	}
+	attrs, err := parseAttributes(signerInfo.AuthenticatedAttributes.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("malformed signed attributes: %w", err)
+	}
+	var messageDigest []byte
+	if _, err := asn1.Unmarshal(attrs[oidMessageDigest.String()], &messageDigest); err != nil {
+		return nil, fmt.Errorf("malformed signature: missing or invalid message digest attribute")
+	}
+	if !bytes.Equal(messageDigest, hashBytes(hash, content.Bytes)) {
+		return nil, fmt.Errorf("signature does not match the signed content")
+	}
+
+	// The signature covers the signed attributes encoded as a SET
+	signedAttrs := append([]byte{0x31}, signerInfo.AuthenticatedAttributes.FullBytes[1:]...)
+	if err := checkSignerSignature(signer, hash, hashBytes(hash, signedAttrs), signerInfo.EncryptedDigest); err != nil {
+		return nil, err
+	}
+
+	sig := &Signature{
+		Signer:          signer.Subject.CommonName,
+		Issuer:          signer.Issuer.CommonName,
+		SerialNumber:    fmt.Sprintf("%X", signer.SerialNumber),
+		NotBefore:       signer.NotBefore,
+		NotAfter:        signer.NotAfter,
+		DigestAlgorithm: hash.String(),
+	}
+
+	if imageHash != nil {
+		var indirect spcIndirectDataContent
+		if _, err := asn1.Unmarshal(content.FullBytes, &indirect); err != nil {
+			return nil, fmt.Errorf("malformed Authenticode content: %w", err)
+		}
+		fileHash, ok := digestAlgorithmsByOID[indirect.MessageDigest.Algorithm.Algorithm.String()]
+		if !ok {
+			return nil, fmt.Errorf("unsupported digest algorithm: %s", indirect.MessageDigest.Algorithm.Algorithm)
+		}
+		if !bytes.Equal(imageHash(fileHash), indirect.MessageDigest.Digest) {
+			return nil, fmt.Errorf("file content does not match its signature (modified after signing)")
+		}
+		sig.ContentVerified = true
+	}
+
+	if signerInfo.UnauthenticatedAttributes.Bytes != nil {
+		sig.Timestamp = signatureTimestamp(signerInfo.UnauthenticatedAttributes.Bytes)
+	}
+
+	verifyTime := time.Now()
+	if sig.Timestamp != nil {
+		verifyTime = *sig.Timestamp
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs {
+		if cert != signer {
+			intermediates.AddCert(cert)
+		}
+	}
+	_, sig.TrustError = signer.Verify(x509.VerifyOptions{
+		Intermediates: intermediates,
+		CurrentTime:   verifyTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+
+	return sig, nil
+}
+
+// parseSignedData decodes a DER ContentInfo holding a SignedData
+func parseSignedData(der []byte) (*pkcs7SignedData, error) {
+	var info pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, err
+	}
+	if !info.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("content is not signed data")
+	}
+
+	var signedData pkcs7SignedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &signedData); err != nil {
+		return nil, err
+	}
+	return &signedData, nil
+}
+
+// parseAttributes decodes the contents of an attribute SET into the DER of each first value, keyed by OID
+func parseAttributes(der []byte) (map[string][]byte, error) {
+	attrs := make(map[string][]byte)
+	for rest := der; len(rest) > 0; {
+		var attr pkcs7Attribute
+		var err error
+		rest, err = asn1.Unmarshal(rest, &attr)
+		if err != nil {
+			return nil, err
+		}
+		var value asn1.RawValue
+		if _, err := asn1.Unmarshal(attr.Values.Bytes, &value); err != nil {
+			return nil, err
+		}
+		attrs[attr.Type.String()] = value.FullBytes
+	}
+	return attrs, nil
+}
+
+// findCertificate returns the certificate matching an issuer and serial number
+func findCertificate(certs []*x509.Certificate, id pkcs7IssuerAndSerial) *x509.Certificate {
+	for _, cert := range certs {
+		if cert.SerialNumber.Cmp(id.SerialNumber) == 0 && bytes.Equal(cert.RawIssuer, id.Issuer.FullBytes) {
+			return cert
+		}
+	}
+	return nil
+}
+
+// checkSignerSignature verifies the signature over the signed attributes digest
+// SHA1 signatures are still common on older installers, so x509's policy checks are bypassed
+func checkSignerSignature(signer *x509.Certificate, hash crypto.Hash, digest, signature []byte) error {
+	switch pub := signer.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(pub, hash, digest, signature); err != nil {
+			return fmt.Errorf("signature verification failed: %w", err)
+		}
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest, signature) {
+			return fmt.Errorf("signature verification failed")
+		}
+	default:
+		return fmt.Errorf("unsupported signing key type: %T", signer.PublicKey)
+	}
+	return nil
+}
+
+// signatureTimestamp extracts the signing time from a legacy countersignature or an RFC 3161 timestamp
+// The timestamp signature itself is not verified
+func signatureTimestamp(unsignedAttrs []byte) *time.Time {
+	attrs, err := parseAttributes(unsignedAttrs)
+	if err != nil {
+		return nil
+	}
+
+	if value, ok := attrs[oidCountersignature.String()]; ok {
+		var counter pkcs7SignerInfo
+		if _, err := asn1.Unmarshal(value, &counter); err == nil {
+			counterAttrs, err := parseAttributes(counter.AuthenticatedAttributes.Bytes)
+			if err == nil {
+				var signingTime time.Time
+				if _, err := asn1.Unmarshal(counterAttrs[oidSigningTime.String()], &signingTime); err == nil {
+					return &signingTime
+				}
+			}
+		}
+	}
+
+	if value, ok := attrs[oidRFC3161Timestamp.String()]; ok {
+		token, err := parseSignedData(value)
+		if err != nil {
+			return nil
+		}
+		var encoded []byte
+		if _, err := asn1.Unmarshal(token.ContentInfo.Content.Bytes, &encoded); err != nil {
+			return nil
+		}
+		var info tstInfo
+		if _, err := asn1.Unmarshal(encoded, &info); err != nil {
+			return nil
+		}
+		return &info.GenTime
+	}
+	return nil
+}
+
+// hashBytes returns the digest of data
+func hashBytes(hash crypto.Hash, data []byte) []byte {
+	h := hash.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,332 @@
+package packager
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// minimalPE returns a PE32+ image with headers only, enough for Authenticode hashing
+func minimalPE() []byte {
+	const peOffset = 0x40
+	data := make([]byte, 0x200)
+	copy(data, "MZ")
+	binary.LittleEndian.PutUint32(data[peSignatureOffset:], peOffset)
+	copy(data[peOffset:], "PE\x00\x00")
+
+	optional := peOffset + 4 + peCoffHeaderSize
+	binary.LittleEndian.PutUint16(data[optional:], 0x20b)
+	binary.LittleEndian.PutUint32(data[optional+pe32PlusDataDirOffset-4:], 16) // NumberOfRvaAndSizes
+	copy(data[0x150:], "program code")
+	return data
+}
+
+// newCodeSigningCert creates a self-signed code signing certificate valid between notBefore and notAfter
+func newCodeSigningCert(t *testing.T, key crypto.Signer, notBefore, notAfter time.Time) *x509.Certificate {
+	t.Helper()
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(4242),
+		Subject:      pkix.Name{CommonName: "Contoso Ltd"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert
+}
+
+// testAttribute encodes a PKCS#7 attribute with a single value
+type testAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+// mustMarshal encodes v as DER, failing the test on error
+func mustMarshal(t *testing.T, v any, params string) []byte {
+	t.Helper()
+	der, err := asn1.MarshalWithParams(v, params)
+	if err != nil {
+		t.Fatalf("Failed to marshal ASN.1: %v", err)
+	}
+	return der
+}
+
+// attributeSet encodes attributes as a SET retagged with the given context-specific tag
+func attributeSet(t *testing.T, tag byte, attrs ...testAttribute) asn1.RawValue {
+	der := mustMarshal(t, attrs, "set")
+	der[0] = 0xa0 | tag
+	return asn1.RawValue{FullBytes: der}
+}
+
+// contextWrap wraps DER in an explicit [0] tag
+func contextWrap(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+// signPE appends an Authenticode signature made with key to a PE image
+// A non-nil timestamp adds a legacy countersignature with that signing time
+func signPE(t *testing.T, pe []byte, cert *x509.Certificate, key crypto.Signer, timestamp *time.Time) []byte {
+	t.Helper()
+
+	layout, err := parsePELayout(pe)
+	if err != nil {
+		t.Fatalf("parsePELayout() error = %v", err)
+	}
+	signed := append([]byte{}, pe...)
+	for len(signed)%8 != 0 {
+		signed = append(signed, 0)
+	}
+	certOffset := len(signed)
+
+	sha256ID := pkix.AlgorithmIdentifier{Algorithm: oidDigestSHA256, Parameters: asn1.NullRawValue}
+	indirect := spcIndirectDataContent{
+		Data: asn1.RawValue{FullBytes: mustMarshal(t, struct{ Type asn1.ObjectIdentifier }{asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 15}}, "")},
+		MessageDigest: digestInfo{
+			Algorithm: sha256ID,
+			Digest:    peImageHash(signed, certOffset, crypto.SHA256),
+		},
+	}
+	indirectDER := mustMarshal(t, indirect, "")
+	var indirectValue asn1.RawValue
+	if _, err := asn1.Unmarshal(indirectDER, &indirectValue); err != nil {
+		t.Fatalf("Failed to parse indirect data: %v", err)
+	}
+	contentDigest := sha256.Sum256(indirectValue.Bytes)
+
+	signedAttrs := attributeSet(t, 0, testAttribute{
+		Type:   oidMessageDigest,
+		Values: []asn1.RawValue{{FullBytes: mustMarshal(t, contentDigest[:], "")}},
+	})
+	attrsDigest := sha256.Sum256(append([]byte{0x31}, signedAttrs.FullBytes[1:]...))
+	signature, err := key.Sign(rand.Reader, attrsDigest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	signerInfo := pkcs7SignerInfo{
+		Version:                   1,
+		IssuerAndSerial:           pkcs7IssuerAndSerial{Issuer: asn1.RawValue{FullBytes: cert.RawIssuer}, SerialNumber: cert.SerialNumber},
+		DigestAlgorithm:           sha256ID,
+		AuthenticatedAttributes:   signedAttrs,
+		DigestEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}},
+		EncryptedDigest:           signature,
+	}
+	if timestamp != nil {
+		counter := signerInfo
+		counter.AuthenticatedAttributes = attributeSet(t, 0, testAttribute{
+			Type:   oidSigningTime,
+			Values: []asn1.RawValue{{FullBytes: mustMarshal(t, *timestamp, "utc")}},
+		})
+		counter.EncryptedDigest = []byte{0}
+		signerInfo.UnauthenticatedAttributes = attributeSet(t, 1, testAttribute{
+			Type:   oidCountersignature,
+			Values: []asn1.RawValue{{FullBytes: mustMarshal(t, counter, "")}},
+		})
+	}
+
+	signedData := pkcs7SignedData{
+		Version:          1,
+		DigestAlgorithms: asn1.RawValue{FullBytes: mustMarshal(t, []pkix.AlgorithmIdentifier{sha256ID}, "set")},
+		ContentInfo:      pkcs7ContentInfo{ContentType: oidSpcIndirectData, Content: contextWrap(indirectDER)},
+		Certificates:     contextWrap(cert.Raw),
+		SignerInfos:      []pkcs7SignerInfo{signerInfo},
+	}
+	blob := mustMarshal(t, pkcs7ContentInfo{
+		ContentType: oidSignedData,
+		Content:     contextWrap(mustMarshal(t, signedData, "")),
+	}, "")
+
+	entry := make([]byte, winCertHeaderLength, winCertHeaderLength+len(blob)+8)
+	binary.LittleEndian.PutUint32(entry, uint32(winCertHeaderLength+len(blob)))
+	binary.LittleEndian.PutUint16(entry[4:], 0x0200)
+	binary.LittleEndian.PutUint16(entry[6:], winCertTypePKCS7)
+	entry = append(entry, blob...)
+	for len(entry)%8 != 0 {
+		entry = append(entry, 0)
+	}
+
+	binary.LittleEndian.PutUint32(signed[layout.securityDir:], uint32(certOffset))
+	binary.LittleEndian.PutUint32(signed[layout.securityDir+4:], uint32(len(entry)))
+	return append(signed, entry...)
+}
+
+// writeSetup writes data as setup.exe in a new temp folder and returns its path
+func writeSetup(t *testing.T, data []byte) string {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "authenticode")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "setup.exe")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+	return path
+}
+
+func TestVerifySignature(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	now := time.Now()
+	tests := []struct {
+		name string
+		key  crypto.Signer
+	}{
+		{"ecdsa", ecKey},
+		{"rsa", rsaKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := newCodeSigningCert(t, tt.key, now.Add(-time.Hour), now.Add(24*time.Hour))
+			path := writeSetup(t, signPE(t, minimalPE(), cert, tt.key, nil))
+
+			sig, err := VerifySignature(path)
+			if err != nil {
+				t.Fatalf("VerifySignature() error = %v", err)
+			}
+			if sig.Signer != "Contoso Ltd" || sig.SerialNumber != "1092" {
+				t.Errorf("Unexpected signer: %q (%s)", sig.Signer, sig.SerialNumber)
+			}
+			if !sig.ContentVerified {
+				t.Error("ContentVerified = false, want true")
+			}
+			if sig.DigestAlgorithm != "SHA-256" {
+				t.Errorf("DigestAlgorithm = %q", sig.DigestAlgorithm)
+			}
+			if sig.Timestamp != nil {
+				t.Errorf("Timestamp = %v, want nil", sig.Timestamp)
+			}
+			// A self-signed certificate does not chain to a trusted root
+			if sig.TrustError == nil {
+				t.Error("Expected a trust error for a self-signed certificate")
+			}
+		})
+	}
+}
+
+func TestVerifySignatureUnsigned(t *testing.T) {
+	if _, err := VerifySignature(writeSetup(t, minimalPE())); !errors.Is(err, ErrNotSigned) {
+		t.Errorf("VerifySignature() error = %v, want ErrNotSigned", err)
+	}
+
+	if _, err := VerifySignature(writeSetup(t, []byte("not a PE file"))); err == nil || errors.Is(err, ErrNotSigned) {
+		t.Errorf("VerifySignature() error = %v, want a parse error", err)
+	}
+}
+
+func TestVerifySignatureTampered(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	cert := newCodeSigningCert(t, key, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+
+	signed := signPE(t, minimalPE(), cert, key, nil)
+	copy(signed[0x150:], "patched code")
+
+	_, err = VerifySignature(writeSetup(t, signed))
+	if err == nil || !strings.Contains(err.Error(), "modified after signing") {
+		t.Errorf("VerifySignature() error = %v, want content mismatch", err)
+	}
+}
+
+func TestSignatureExpiry(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	notBefore := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	cert := newCodeSigningCert(t, key, notBefore, notAfter)
+
+	// Signed while the certificate was valid and timestamped: still acceptable
+	signedAt := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	sig, err := VerifySignature(writeSetup(t, signPE(t, minimalPE(), cert, key, &signedAt)))
+	if err != nil {
+		t.Fatalf("VerifySignature() error = %v", err)
+	}
+	if sig.Timestamp == nil || !sig.Timestamp.Equal(signedAt) {
+		t.Fatalf("Timestamp = %v, want %v", sig.Timestamp, signedAt)
+	}
+	if sig.Expired(time.Now()) {
+		t.Error("Expired() = true for a timestamped signature")
+	}
+
+	// Without a timestamp the expired certificate invalidates the signature
+	sig, err = VerifySignature(writeSetup(t, signPE(t, minimalPE(), cert, key, nil)))
+	if err != nil {
+		t.Fatalf("VerifySignature() error = %v", err)
+	}
+	if !sig.Expired(time.Now()) {
+		t.Error("Expired() = false for an expired, untimestamped signature")
+	}
+	warnings := sig.Warnings(time.Now())
+	if len(warnings) == 0 || !strings.Contains(warnings[0], "expired on 2021-01-01") {
+		t.Errorf("Warnings() = %v, want an expiry warning", warnings)
+	}
+}
+
+func TestPackageRequireSigned(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	cert := newCodeSigningCert(t, key, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+
+	sourceDir, outputDir, _ := setupCheckpointTest(t)
+	setupPath := filepath.Join(sourceDir, "setup.exe")
+	opts := Options{RequireSigned: true}
+
+	if err := os.WriteFile(setupPath, minimalPE(), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+	_, err = PackageWithOptions(sourceDir, "setup.exe", outputDir, opts, nil)
+	if err == nil || !strings.Contains(err.Error(), "is not signed") {
+		t.Fatalf("PackageWithOptions() error = %v, want unsigned setup rejected", err)
+	}
+
+	if err := os.WriteFile(setupPath, signPE(t, minimalPE(), cert, key, nil), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+	result, err := PackageWithOptions(sourceDir, "setup.exe", outputDir, opts, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	if result.Signature == nil || result.Signature.Signer != "Contoso Ltd" {
+		t.Errorf("Signature = %+v, want signer Contoso Ltd", result.Signature)
+	}
+}
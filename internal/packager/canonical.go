@@ -0,0 +1,79 @@
+package packager
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MetadataField is a single key/value of the canonical metadata rendering
+type MetadataField struct {
+	Key   string
+	Value string
+}
+
+// CanonicalMetadata flattens Detection.xml into a stable, ordered list of fields
+// Encryption keys, the MAC and the IV are left out: they are secret and change on every build
+func CanonicalMetadata(appInfo *ApplicationInfo) []MetadataField {
+	enc := appInfo.EncryptionInfo
+	fields := []MetadataField{
+		{"ToolVersion", appInfo.ToolVersion},
+		{"Name", appInfo.Name},
+		{"SetupFile", appInfo.SetupFile},
+		{"FileName", appInfo.FileName},
+		{"UnencryptedContentSize", strconv.FormatInt(appInfo.UnencryptedContentSize, 10)},
+		{"Encryption.ProfileIdentifier", enc.ProfileIdentifier},
+		{"Encryption.FileDigest", enc.FileDigest},
+		{"Encryption.FileDigestAlgorithm", enc.FileDigestAlgorithm},
+	}
+
+	if mac := appInfo.MacOSAppInfo; mac != nil {
+		fields = append(fields,
+			MetadataField{"MacOS.PrimaryBundleId", mac.PrimaryBundleID},
+			MetadataField{"MacOS.PrimaryBundleVersion", mac.PrimaryBundleVersion},
+		)
+		for i, app := range mac.IncludedApps {
+			fields = append(fields, MetadataField{fmt.Sprintf("MacOS.IncludedApps[%d]", i), app.BundleID + " " + app.BundleVersion})
+		}
+	}
+
+	msi := appInfo.MsiInfo
+	if msi == nil {
+		return fields
+	}
+	fields = append(fields,
+		MetadataField{"Msi.ProductCode", msi.MsiProductCode},
+		MetadataField{"Msi.ProductVersion", msi.MsiProductVersion},
+		MetadataField{"Msi.PackageCode", msi.MsiPackageCode},
+		MetadataField{"Msi.UpgradeCode", msi.MsiUpgradeCode},
+		MetadataField{"Msi.ExecutionContext", msi.MsiExecutionContext},
+		MetadataField{"Msi.RequiresLogon", strconv.FormatBool(msi.MsiRequiresLogon)},
+		MetadataField{"Msi.RequiresReboot", strconv.FormatBool(msi.MsiRequiresReboot)},
+		MetadataField{"Msi.IsMachineInstall", strconv.FormatBool(msi.MsiIsMachineInstall)},
+		MetadataField{"Msi.IsUserInstall", strconv.FormatBool(msi.MsiIsUserInstall)},
+		MetadataField{"Msi.IncludesServices", strconv.FormatBool(msi.MsiIncludesServices)},
+		MetadataField{"Msi.IncludesODBCDataSource", strconv.FormatBool(msi.MsiIncludesODBCDataSource)},
+		MetadataField{"Msi.ContainsSystemRegistryKeys", strconv.FormatBool(msi.MsiContainsSystemRegistryKeys)},
+		MetadataField{"Msi.ContainsSystemFolders", strconv.FormatBool(msi.MsiContainsSystemFolders)},
+		MetadataField{"Msi.Publisher", msi.MsiPublisher},
+	)
+	return fields
+}
+
+// CanonicalValue renders a field value on a single line
+// Values that would be ambiguous (empty, surrounding spaces, control characters) are quoted
+func CanonicalValue(value string) string {
+	if value == "" || strings.TrimSpace(value) != value || strings.ContainsFunc(value, func(r rune) bool { return r < 0x20 || r == 0x7f }) || strings.HasPrefix(value, `"`) {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// FormatCanonical renders fields as "key: value" lines with LF line endings
+func FormatCanonical(fields []MetadataField) string {
+	var b strings.Builder
+	for _, f := range fields {
+		fmt.Fprintf(&b, "%s: %s\n", f.Key, CanonicalValue(f.Value))
+	}
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package packager
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatCanonical(t *testing.T) {
+	appInfo := &ApplicationInfo{
+		ToolVersion:            ToolVersion,
+		Name:                   "7-Zip 24.01 (x64)",
+		SetupFile:              "7z2401-x64.msi",
+		FileName:               "IntunePackage.intunewin",
+		UnencryptedContentSize: 1581056,
+		EncryptionInfo: EncryptionXML{
+			EncryptionKey:       "c2VjcmV0",
+			MacKey:              "c2VjcmV0",
+			Mac:                 "bWFj",
+			ProfileIdentifier:   ProfileIdentifier,
+			FileDigest:          "ZGlnZXN0",
+			FileDigestAlgorithm: FileDigestAlgorithm,
+		},
+		MsiInfo: &MsiInfoXML{
+			MsiProductCode:      "{23170F69-40C1-2702-2401-000001000000}",
+			MsiProductVersion:   "24.01.00.0",
+			MsiIsMachineInstall: true,
+		},
+	}
+
+	got := FormatCanonical(CanonicalMetadata(appInfo))
+
+	for _, want := range []string{
+		"ToolVersion: 1.8.6.0\n",
+		"Name: 7-Zip 24.01 (x64)\n",
+		"UnencryptedContentSize: 1581056\n",
+		"Encryption.FileDigest: ZGlnZXN0\n",
+		"Msi.ProductCode: {23170F69-40C1-2702-2401-000001000000}\n",
+		"Msi.IsMachineInstall: true\n",
+		"Msi.RequiresReboot: false\n",
+		`Msi.Publisher: ""` + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "c2VjcmV0") || strings.Contains(got, "bWFj") {
+		t.Errorf("Expected keys and MAC to be left out, got:\n%s", got)
+	}
+
+	// Rendering is stable
+	if again := FormatCanonical(CanonicalMetadata(appInfo)); again != got {
+		t.Error("FormatCanonical() output is not stable")
+	}
+
+	appInfo.MsiInfo = nil
+	if got := FormatCanonical(CanonicalMetadata(appInfo)); strings.Contains(got, "Msi.") {
+		t.Errorf("Expected no MSI fields without MSI metadata, got:\n%s", got)
+	}
+}
+
+func TestCanonicalValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"plain value", "plain value"},
+		{"", `""`},
+		{" padded", `" padded"`},
+		{"two\nlines", `"two\nlines"`},
+		{`"quoted"`, `"\"quoted\""`},
+	}
+
+	for _, tt := range tests {
+		if got := CanonicalValue(tt.value); got != tt.want {
+			t.Errorf("CanonicalValue(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package packager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Checkpoint phases, in pipeline order
+const (
+	PhaseCompressed = "compressed"
+	PhaseEncrypted  = "encrypted"
+)
+
+// Checkpoint file names inside a checkpoint directory
+const (
+	checkpointStateFile     = "state.json"
+	checkpointZipFile       = "content.zip"
+	checkpointEncryptedFile = "content.bin"
+)
+
+// RunState records the progress of an interrupted packaging run
+// It is stored as state.json in the run's checkpoint directory
+type RunState struct {
+	// Dir is the checkpoint directory the state was loaded from (not persisted)
+	Dir string `json:"-"`
+	// GeneratorVersion is the package generation logic that wrote the artifacts
+	GeneratorVersion int `json:"generatorVersion"`
+
+	SourcePath      string          `json:"sourcePath"`
+	SetupFile       string          `json:"setupFile"`
+	OutputPath      string          `json:"outputPath"`
+	EnumerationHash string          `json:"enumerationHash"`
+	Exclude         []string        `json:"exclude,omitempty"`
+	License         *License        `json:"license,omitempty"`
+	Phase           string          `json:"phase"`
+	ZipSize         int64           `json:"zipSize"`
+	EncryptionInfo  *EncryptionInfo `json:"encryptionInfo,omitempty"`
+	UpdatedAt       time.Time       `json:"updatedAt"`
+	// Symlinks is the symlink policy of the run, empty for SymlinkFollow
+	Symlinks SymlinkPolicy `json:"symlinks,omitempty"`
+	// NormalizePermissions records whether the content ZIP was built with normalized modes
+	NormalizePermissions bool `json:"normalizePermissions,omitempty"`
+	// Compression is the compression of the content ZIP, empty for CompressionDefault
+	Compression Compression `json:"compression,omitempty"`
+	// Variables identifies the template variables of the run by their hash, keeping
+	// their values out of the checkpoint (see variablesHash)
+	Variables string `json:"variables,omitempty"`
+}
+
+// DefaultCheckpointRoot returns the folder holding checkpoints of resumable runs
+func DefaultCheckpointRoot() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "intunewin", "checkpoints"), nil
+}
+
+// CheckpointDir returns the checkpoint directory for a run under root
+// The same source, setup file and output folder always map to the same directory
+func CheckpointDir(root, sourcePath, setupFile, outputPath string) string {
+	absSource, _ := filepath.Abs(sourcePath)
+	absOutput, _ := filepath.Abs(outputPath)
+	absSource, absOutput = normalPath(absSource), normalPath(absOutput)
+	sum := sha256.Sum256([]byte(absSource + "\x00" + setupFile + "\x00" + absOutput))
+	return filepath.Join(root, hex.EncodeToString(sum[:8]))
+}
+
+// EnumerationHash hashes the relative path, size and modification time of every file
+// in the source folder, so any change to the source invalidates a checkpoint
+func EnumerationHash(sourcePath string) (string, error) {
+	absSource, err := filepath.Abs(sourcePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	absSource = longPath(absSource)
+
+	// Linked files and folders are hashed as their targets, so a changed target invalidates it too
+	hash := sha256.New()
+	_, err = walkSource(absSource, nil, SymlinkFollow, func(relPath, _ string, info os.FileInfo) error {
+		fmt.Fprintf(hash, "%s\x00%t\x00%d\x00%d\n", filepath.ToSlash(relPath), info.IsDir(), info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to enumerate source folder: %w", err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// LoadRunState reads the run state from a checkpoint directory
+func LoadRunState(dir string) (*RunState, error) {
+	data, err := os.ReadFile(filepath.Join(dir, checkpointStateFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var state RunState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint state: %w", err)
+	}
+	state.Dir = dir
+	return &state, nil
+}
+
+// ListRunStates returns all interrupted runs under root, most recent first
+func ListRunStates(root string) ([]*RunState, error) {
+	entries, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint folder: %w", err)
+	}
+
+	var states []*RunState
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		state, err := LoadRunState(filepath.Join(root, entry.Name()))
+		if err != nil {
+			// Incomplete or foreign folders are not resumable
+			continue
+		}
+		states = append(states, state)
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].UpdatedAt.After(states[j].UpdatedAt)
+	})
+	return states, nil
+}
+
+// saveRunState writes the run state atomically
+func saveRunState(dir string, state *RunState) error {
+	state.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint state: %w", err)
+	}
+	return writeCheckpointFile(dir, checkpointStateFile, data)
+}
+
+// writeCheckpointFile writes a checkpoint artifact via a temp file and rename,
+// so a crash never leaves a truncated artifact behind
+// Artifacts contain encryption keys, so they are only readable by the owner
+func writeCheckpointFile(dir, name string, data []byte) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+	tmpPath := filepath.Join(dir, name+".tmp")
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write checkpoint %s: %w", name, err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(dir, name)); err != nil {
+		return fmt.Errorf("failed to write checkpoint %s: %w", name, err)
+	}
+	return nil
+}
+
+// readCheckpointFile reads a checkpoint artifact
+func readCheckpointFile(dir, name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(dir, name))
+}
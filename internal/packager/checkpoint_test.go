@@ -0,0 +1,145 @@
+package packager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupCheckpointTest creates a source folder with a setup file, an output folder and a checkpoint root
+func setupCheckpointTest(t *testing.T) (sourceDir, outputDir, root string) {
+	t.Helper()
+
+	baseDir, err := os.MkdirTemp("", "checkpoint")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(baseDir) })
+
+	sourceDir = filepath.Join(baseDir, "source")
+	outputDir = filepath.Join(baseDir, "output")
+	root = filepath.Join(baseDir, "checkpoints")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatalf("Failed to create source dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "setup.exe"), []byte("installer"), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+	return sourceDir, outputDir, root
+}
+
+func TestEnumerationHashChangesWithSource(t *testing.T) {
+	sourceDir, _, _ := setupCheckpointTest(t)
+
+	first, err := EnumerationHash(sourceDir)
+	if err != nil {
+		t.Fatalf("EnumerationHash() error = %v", err)
+	}
+	second, err := EnumerationHash(sourceDir)
+	if err != nil {
+		t.Fatalf("EnumerationHash() error = %v", err)
+	}
+	if first != second {
+		t.Error("EnumerationHash() is not stable for an unchanged source")
+	}
+
+	if err := os.WriteFile(filepath.Join(sourceDir, "extra.txt"), []byte("new"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	third, err := EnumerationHash(sourceDir)
+	if err != nil {
+		t.Fatalf("EnumerationHash() error = %v", err)
+	}
+	if first == third {
+		t.Error("EnumerationHash() did not change after adding a file")
+	}
+}
+
+func TestPackageResumesFromCheckpoint(t *testing.T) {
+	sourceDir, outputDir, root := setupCheckpointTest(t)
+	dir := CheckpointDir(root, sourceDir, "setup.exe", outputDir)
+
+	// Simulate a run that died after encryption
+	zipData, err := ZipFolder(sourceDir)
+	if err != nil {
+		t.Fatalf("ZipFolder() error = %v", err)
+	}
+	encInfo, encrypted, err := CreateEncryptionInfo(zipData)
+	if err != nil {
+		t.Fatalf("CreateEncryptionInfo() error = %v", err)
+	}
+	enumHash, err := EnumerationHash(sourceDir)
+	if err != nil {
+		t.Fatalf("EnumerationHash() error = %v", err)
+	}
+	if err := writeCheckpointFile(dir, checkpointEncryptedFile, encrypted); err != nil {
+		t.Fatalf("writeCheckpointFile() error = %v", err)
+	}
+	state := &RunState{
+		GeneratorVersion: GeneratorVersion,
+		SourcePath:       sourceDir,
+		SetupFile:        "setup.exe",
+		OutputPath:       outputDir,
+		EnumerationHash:  enumHash,
+		Phase:            PhaseEncrypted,
+		ZipSize:          int64(len(zipData)),
+		EncryptionInfo:   encInfo,
+	}
+	if err := saveRunState(dir, state); err != nil {
+		t.Fatalf("saveRunState() error = %v", err)
+	}
+
+	states, err := ListRunStates(root)
+	if err != nil {
+		t.Fatalf("ListRunStates() error = %v", err)
+	}
+	if len(states) != 1 {
+		t.Fatalf("ListRunStates() = %d runs, want 1", len(states))
+	}
+
+	result, err := PackageWithOptions(sourceDir, "setup.exe", outputDir, Options{CheckpointRoot: root}, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	if result.ResumedFrom != PhaseEncrypted {
+		t.Errorf("ResumedFrom = %q, want %q", result.ResumedFrom, PhaseEncrypted)
+	}
+	if result.EncryptedSize != int64(len(encrypted)) {
+		t.Errorf("EncryptedSize = %d, want %d", result.EncryptedSize, len(encrypted))
+	}
+
+	// A completed run removes its checkpoint
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("Checkpoint directory was not removed after a successful run")
+	}
+}
+
+func TestPackageIgnoresStaleCheckpoint(t *testing.T) {
+	sourceDir, outputDir, root := setupCheckpointTest(t)
+	dir := CheckpointDir(root, sourceDir, "setup.exe", outputDir)
+	enumHash, err := EnumerationHash(sourceDir)
+	if err != nil {
+		t.Fatalf("EnumerationHash() error = %v", err)
+	}
+
+	stale := map[string]*RunState{
+		"changed source": {GeneratorVersion: GeneratorVersion, EnumerationHash: "stale"},
+		// Artifacts written by an older generator are not mixed into a new package
+		"older generator": {GeneratorVersion: GeneratorVersion - 1, EnumerationHash: enumHash},
+	}
+	for name, state := range stale {
+		state.SourcePath, state.SetupFile, state.OutputPath = sourceDir, "setup.exe", outputDir
+		state.Phase = PhaseCompressed
+		if err := saveRunState(dir, state); err != nil {
+			t.Fatalf("saveRunState() error = %v", err)
+		}
+
+		result, err := PackageWithOptions(sourceDir, "setup.exe", outputDir, Options{CheckpointRoot: root}, nil)
+		if err != nil {
+			t.Fatalf("%s: PackageWithOptions() error = %v", name, err)
+		}
+		if result.ResumedFrom != "" {
+			t.Errorf("%s: ResumedFrom = %q, want a fresh run", name, result.ResumedFrom)
+		}
+	}
+}
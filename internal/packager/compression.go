@@ -0,0 +1,128 @@
+package packager
+
+import (
+	"compress/flate"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Compression decides how the files of the content ZIP are compressed
+// Intune extracts Deflate and stored entries only, so no other method is offered
+type Compression string
+
+const (
+	// CompressionDefault deflates at the level archive/zip uses (the default)
+	CompressionDefault Compression = "default"
+	// CompressionStore stores every file without compression
+	CompressionStore Compression = "store"
+	// CompressionFast deflates at the fastest level
+	CompressionFast Compression = "fast"
+	// CompressionBest deflates at the best level
+	CompressionBest Compression = "best"
+)
+
+// Compressions lists the valid compression strategies, the default first
+// All but CompressionStore store files that are already compressed (see storeFile)
+var Compressions = []Compression{CompressionDefault, CompressionStore, CompressionFast, CompressionBest}
+
+// ParseCompression returns the compression named s, case-insensitively; empty is CompressionDefault
+func ParseCompression(s string) (Compression, error) {
+	if s == "" {
+		return CompressionDefault, nil
+	}
+	for _, c := range Compressions {
+		if strings.EqualFold(s, string(c)) {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("invalid compression %q (expected default, store, fast or best)", s)
+}
+
+// level returns the deflate level of the compression
+func (c Compression) level() int {
+	switch c {
+	case CompressionFast:
+		return flate.BestSpeed
+	case CompressionBest:
+		return flate.BestCompression
+	}
+	return zipDeflateLevel
+}
+
+// compressedExtensions are formats whose content is always compressed, so deflating
+// them again costs time for no size gain
+// MSI and MSP files are not listed: their cabinets may be embedded uncompressed, they
+// are sampled like any other file
+var compressedExtensions = map[string]bool{
+	".7z": true, ".appx": true, ".appxbundle": true, ".bz2": true, ".cab": true, ".gz": true,
+	".intunewin": true, ".jar": true, ".msix": true, ".msixbundle": true, ".msu": true,
+	".nupkg": true, ".rar": true, ".xz": true, ".zip": true, ".zst": true,
+	".docx": true, ".pptx": true, ".xlsx": true,
+	".gif": true, ".jpeg": true, ".jpg": true, ".png": true, ".webp": true,
+	".avi": true, ".m4a": true, ".mkv": true, ".mov": true, ".mp3": true, ".mp4": true,
+	".wma": true, ".wmv": true,
+}
+
+const (
+	// entropySampleSize is the size of each of the samples taken at a quarter, half and
+	// three quarters of a file to tell whether it is compressed
+	entropySampleSize = 64 << 10
+	// entropyThreshold is the Shannon entropy, in bits per byte, from which a sample is
+	// considered compressed; Deflate and LZMA output reaches about 7.99
+	entropyThreshold = 7.9
+)
+
+// storeName reports whether a file is stored rather than deflated from its name alone:
+// always with CompressionStore, otherwise when its extension is that of compressed data
+func (c Compression) storeName(name string) bool {
+	return c == CompressionStore || compressedExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// storeFile reports whether a file is stored rather than deflated: always with
+// CompressionStore, otherwise when its extension or samples of its content show it
+// is compressed already
+// Files smaller than four samples are always deflated, which takes no time
+func (c Compression) storeFile(path string, size int64) (bool, error) {
+	if c.storeName(path) {
+		return true, nil
+	}
+	if size < 4*entropySampleSize {
+		return false, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	buf := make([]byte, entropySampleSize)
+	for _, offset := range []int64{size / 4, size / 2, size / 4 * 3} {
+		if _, err := file.ReadAt(buf, offset); err != nil && err != io.EOF {
+			return false, fmt.Errorf("failed to read file: %w", err)
+		}
+		if entropy(buf) < entropyThreshold {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// entropy returns the Shannon entropy of data in bits per byte
+func entropy(data []byte) float64 {
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+	var e float64
+	for _, n := range counts {
+		if n > 0 {
+			p := float64(n) / float64(len(data))
+			e -= p * math.Log2(p)
+		}
+	}
+	return e
+}
@@ -0,0 +1,129 @@
+package packager
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseCompression(t *testing.T) {
+	tests := map[string]struct {
+		input   string
+		want    Compression
+		wantErr bool
+	}{
+		"empty":   {"", CompressionDefault, false},
+		"store":   {"store", CompressionStore, false},
+		"fast":    {"FAST", CompressionFast, false},
+		"best":    {"Best", CompressionBest, false},
+		"zstd":    {"zstd", "", true},
+		"numeric": {"9", "", true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseCompression(tt.input)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ParseCompression(%q) = %q, %v, want %q", tt.input, got, err, tt.want)
+			}
+		})
+	}
+}
+
+// compressionSource writes a folder with random data, text and a small archive
+func compressionSource(t *testing.T) string {
+	t.Helper()
+	sourceDir := t.TempDir()
+	random := make([]byte, 512<<10)
+	rand.Read(random)
+	files := map[string][]byte{
+		"payload.bin": random,
+		"small.bin":   random[:100<<10],
+		"readme.txt":  bytes.Repeat([]byte("The quick brown fox jumps over the lazy dog. "), 12000),
+		"media.zip":   []byte("not really a zip"),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(sourceDir, name), content, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return sourceDir
+}
+
+func TestCompressionStoreFile(t *testing.T) {
+	sourceDir := compressionSource(t)
+	tests := map[string]struct {
+		compression Compression
+		file        string
+		want        bool
+	}{
+		"random data":        {CompressionDefault, "payload.bin", true},
+		"text":               {CompressionDefault, "readme.txt", false},
+		"too small to probe": {CompressionDefault, "small.bin", false},
+		"archive extension":  {CompressionFast, "media.zip", true},
+		"store everything":   {CompressionStore, "readme.txt", true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(sourceDir, tt.file)
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("Failed to stat %s: %v", tt.file, err)
+			}
+			got, err := tt.compression.storeFile(path, info.Size())
+			if err != nil {
+				t.Fatalf("storeFile() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("storeFile(%s) = %v, want %v", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestZipCompression(t *testing.T) {
+	sourceDir := compressionSource(t)
+	textSizes := make(map[Compression]uint64)
+	for _, compression := range Compressions {
+		data, err := ZipFolderWithOptions(sourceDir, ZipOptions{Compression: compression})
+		if err != nil {
+			t.Fatalf("ZipFolderWithOptions(%s) error = %v", compression, err)
+		}
+		reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("Failed to open ZIP: %v", err)
+		}
+		for _, f := range reader.File {
+			want := zip.Deflate
+			if compression == CompressionStore || f.Name == "payload.bin" || f.Name == "media.zip" {
+				want = zip.Store
+			}
+			if f.Method != want {
+				t.Errorf("%s: %s method = %d, want %d", compression, f.Name, f.Method, want)
+			}
+			if f.Name == "readme.txt" {
+				textSizes[compression] = f.CompressedSize64
+			}
+
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatalf("Failed to open %s: %v", f.Name, err)
+			}
+			content, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatalf("Failed to read %s: %v", f.Name, err)
+			}
+			source, _ := os.ReadFile(filepath.Join(sourceDir, f.Name))
+			if !bytes.Equal(content, source) {
+				t.Errorf("%s: %s content differs from the source", compression, f.Name)
+			}
+		}
+	}
+	if !(textSizes[CompressionBest] <= textSizes[CompressionDefault] && textSizes[CompressionDefault] <= textSizes[CompressionFast] && textSizes[CompressionFast] < textSizes[CompressionStore]) {
+		t.Errorf("compressed text sizes = %v, want best <= default <= fast < store", textSizes)
+	}
+}
@@ -0,0 +1,303 @@
+package packager
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// The layout of Microsoft's IntuneWinAppUtil output that strict verification compares
+// packages with. These lists are written out rather than derived from ApplicationInfo
+// so that a change to the generator cannot silently change the reference
+var (
+	msPackageEntries       = []string{EncryptedContentPath, DetectionXMLPath}
+	msRootAttributes       = []string{"xmlns:xsd", "xmlns:xsi", "ToolVersion"}
+	msApplicationInfoOrder = []string{"Name", "UnencryptedContentSize", "FileName", "SetupFile", "EncryptionInfo", "MsiInfo"}
+	msEncryptionInfoOrder  = []string{"EncryptionKey", "MacKey", "InitializationVector", "Mac", "ProfileIdentifier", "FileDigest", "FileDigestAlgorithm"}
+	msMsiInfoOrder         = []string{"MsiProductCode", "MsiProductVersion", "MsiPackageCode", "MsiUpgradeCode", "MsiExecutionContext",
+		"MsiRequiresLogon", "MsiRequiresReboot", "MsiIsMachineInstall", "MsiIsUserInstall", "MsiIncludesServices",
+		"MsiIncludesODBCDataSource", "MsiContainsSystemRegistryKeys", "MsiContainsSystemFolders", "MsiPublisher"}
+
+	// msToolVersionPattern is the four-part version IntuneWinAppUtil writes as ToolVersion
+	msToolVersionPattern = regexp.MustCompile(`^\d+\.\d+\.\d+\.\d+$`)
+)
+
+// Divergence is a difference between a package and the output of IntuneWinAppUtil
+type Divergence struct {
+	// Check names the compared detail, e.g. "entry-order" or "xml-declaration"
+	Check  string
+	Detail string
+}
+
+func (d Divergence) String() string {
+	return d.Check + ": " + d.Detail
+}
+
+// VerifyReport is the result of VerifyPackage
+type VerifyReport struct {
+	// Name and SetupFile are read from Detection.xml
+	Name      string
+	SetupFile string
+	// ContentSize is the size of the decrypted content ZIP, FileCount the files in it
+	ContentSize int64
+	FileCount   int
+	// Divergences lists the differences from IntuneWinAppUtil output (strict mode only)
+	Divergences []Divergence
+}
+
+// VerifyPackage checks that a .intunewin package is intact: it decrypts with the keys
+// of its Detection.xml, its MAC and digest match, and its content is a readable ZIP
+// of the recorded size. An error is returned for the first integrity failure
+// With strict, the package is also compared with every structural detail of
+// IntuneWinAppUtil output (see CheckConformance)
+func VerifyPackage(packagePath string, strict bool) (*VerifyReport, error) {
+	reader, err := zip.OpenReader(packagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open package: %w", err)
+	}
+	defer reader.Close()
+
+	var encrypted, detectionXML []byte
+	for _, f := range reader.File {
+		switch {
+		case IsPackageEntry(f.Name, EncryptedContentPath):
+			if encrypted, err = readZipFile(f); err != nil {
+				return nil, err
+			}
+		case IsPackageEntry(f.Name, DetectionXMLPath):
+			if detectionXML, err = readZipFile(f); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if detectionXML == nil {
+		return nil, fmt.Errorf("Detection.xml not found in package: %s", packagePath)
+	}
+	if encrypted == nil {
+		return nil, fmt.Errorf("encrypted content not found in package: %s", packagePath)
+	}
+
+	appInfo, err := ParseDetectionXML(detectionXML)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := DecryptPackageContent(encrypted, appInfo.EncryptionInfo)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(plaintext)) != appInfo.UnencryptedContentSize {
+		return nil, fmt.Errorf("content is %d bytes, Detection.xml records %d", len(plaintext), appInfo.UnencryptedContentSize)
+	}
+	content, err := zip.NewReader(bytes.NewReader(plaintext), int64(len(plaintext)))
+	if err != nil {
+		return nil, fmt.Errorf("decrypted content is not a valid ZIP: %w", err)
+	}
+
+	report := &VerifyReport{
+		Name:        appInfo.Name,
+		SetupFile:   appInfo.SetupFile,
+		ContentSize: int64(len(plaintext)),
+	}
+	for _, f := range content.File {
+		if !strings.HasSuffix(normalizeEntryName(f.Name), "/") {
+			report.FileCount++
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from content: %w", f.Name, err)
+		}
+		_, err = io.Copy(io.Discard, rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from content: %w", f.Name, err)
+		}
+	}
+
+	if strict {
+		report.Divergences = CheckConformance(&reader.Reader, detectionXML, encrypted)
+	}
+	return report, nil
+}
+
+// CheckConformance compares a package with the structure IntuneWinAppUtil produces:
+// the entries of the outer ZIP, their names, order and Store method; Detection.xml in
+// UTF-8 without XML declaration or byte order mark, with CRLF line endings, two-space indentation and
+// the official attribute and element order; the ToolVersion format; the fixed metadata
+// values, key sizes and the layout of the encrypted content
+func CheckConformance(pkg *zip.Reader, detectionXML, encrypted []byte) []Divergence {
+	var divs []Divergence
+	add := func(check, format string, args ...any) {
+		divs = append(divs, Divergence{Check: check, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	var names []string
+	for _, f := range pkg.File {
+		names = append(names, f.Name)
+		if f.Method != zip.Store {
+			add("store-method", "%s is compressed (method %d), IntuneWinAppUtil stores entries uncompressed", f.Name, f.Method)
+		}
+	}
+	if !slices.Equal(names, msPackageEntries) {
+		add("entry-order", "entries are %s, want %s", strings.Join(names, ", "), strings.Join(msPackageEntries, ", "))
+	}
+
+	divs = append(divs, checkDetectionXMLLayout(detectionXML)...)
+
+	appInfo, err := ParseDetectionXML(detectionXML)
+	if err != nil {
+		add("detection-xml", "%v", err)
+		return divs
+	}
+	if !msToolVersionPattern.MatchString(appInfo.ToolVersion) {
+		add("tool-version", "ToolVersion %q is not a four-part version like %s", appInfo.ToolVersion, ToolVersion)
+	}
+	if appInfo.FileName != "IntunePackage.intunewin" {
+		add("file-name", "FileName is %q, want IntunePackage.intunewin", appInfo.FileName)
+	}
+	enc := appInfo.EncryptionInfo
+	if enc.ProfileIdentifier != ProfileIdentifier {
+		add("profile-identifier", "ProfileIdentifier is %q, want %s", enc.ProfileIdentifier, ProfileIdentifier)
+	}
+	if enc.FileDigestAlgorithm != FileDigestAlgorithm {
+		add("digest-algorithm", "FileDigestAlgorithm is %q, want %s", enc.FileDigestAlgorithm, FileDigestAlgorithm)
+	}
+
+	keys, err := enc.Decode()
+	if err != nil {
+		add("encryption-info", "%v", err)
+		return divs
+	}
+	for _, k := range []struct {
+		name  string
+		value []byte
+		size  int
+	}{
+		{"EncryptionKey", keys.EncryptionKey, 32},
+		{"MacKey", keys.MacKey, 32},
+		{"InitializationVector", keys.InitializationVector, 16},
+		{"Mac", keys.Mac, 32},
+		{"FileDigest", keys.FileDigest, 32},
+	} {
+		if len(k.value) != k.size {
+			add("key-size", "%s is %d bytes, want %d", k.name, len(k.value), k.size)
+		}
+	}
+
+	// Encrypted content is [HMAC (32)][IV (16)][AES-CBC blocks]
+	switch {
+	case len(encrypted) < 64 || (len(encrypted)-48)%16 != 0:
+		add("content-layout", "encrypted content is %d bytes, want 48 plus whole AES blocks", len(encrypted))
+	default:
+		if !bytes.Equal(encrypted[:32], keys.Mac) {
+			add("content-layout", "the MAC of Detection.xml is not the first 32 bytes of the content")
+		}
+		if !bytes.Equal(encrypted[32:48], keys.InitializationVector) {
+			add("content-layout", "the IV of Detection.xml does not follow the MAC in the content")
+		}
+	}
+	return divs
+}
+
+// checkDetectionXMLLayout compares the text layout of Detection.xml with IntuneWinAppUtil output
+func checkDetectionXMLLayout(data []byte) []Divergence {
+	var divs []Divergence
+	add := func(check, format string, args ...any) {
+		divs = append(divs, Divergence{Check: check, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		add("byte-order-mark", "Detection.xml starts with a UTF-8 byte order mark")
+	case bytes.HasPrefix(data, bomUTF16LE), bytes.HasPrefix(data, bomUTF16BE):
+		add("encoding", "Detection.xml is UTF-16, IntuneWinAppUtil writes UTF-8")
+	}
+	data = decodeDetectionXML(data)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("<?xml")) {
+		add("xml-declaration", "Detection.xml has an XML declaration, IntuneWinAppUtil writes none")
+	}
+	if lf := bytes.Count(data, []byte("\n")); lf != bytes.Count(data, []byte("\r\n")) {
+		add("line-endings", "Detection.xml has %d line(s) without CRLF ending", lf-bytes.Count(data, []byte("\r\n")))
+	}
+	for i, line := range strings.Split(string(data), "\r\n") {
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if strings.Contains(line[:indent], "\t") || indent%2 != 0 {
+			add("indentation", "line %d is not indented with two spaces per level", i+1)
+			break
+		}
+	}
+
+	// Element and attribute order
+	decoder := newDetectionXMLDecoder(data)
+	var path []string
+	children := map[string][]string{}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			add("detection-xml", "Detection.xml is not well-formed: %v", err)
+			return divs
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if len(path) == 0 {
+				var attrs []string
+				for _, a := range t.Attr {
+					name := a.Name.Local
+					if a.Name.Space != "" {
+						name = a.Name.Space + ":" + name
+					}
+					attrs = append(attrs, name)
+				}
+				if t.Name.Local != "ApplicationInfo" {
+					add("root-element", "root element is %s, want ApplicationInfo", t.Name.Local)
+				}
+				if !slices.Equal(attrs, msRootAttributes) {
+					add("attribute-order", "ApplicationInfo attributes are %s, want %s", strings.Join(attrs, ", "), strings.Join(msRootAttributes, ", "))
+				}
+			} else {
+				parent := path[len(path)-1]
+				children[parent] = append(children[parent], t.Name.Local)
+			}
+			path = append(path, t.Name.Local)
+		case xml.EndElement:
+			path = path[:len(path)-1]
+		}
+	}
+
+	for _, order := range []struct {
+		element string
+		want    []string
+	}{
+		{"ApplicationInfo", msApplicationInfoOrder},
+		{"EncryptionInfo", msEncryptionInfoOrder},
+		{"MsiInfo", msMsiInfoOrder},
+	} {
+		if got, ok := children[order.element]; ok && !inOrder(got, order.want) {
+			add("element-order", "%s children are %s, want the order %s", order.element, strings.Join(got, ", "), strings.Join(order.want, ", "))
+		}
+	}
+	return divs
+}
+
+// inOrder reports whether every name of got appears in want, in the order of want
+// Optional elements may be missing
+func inOrder(got, want []string) bool {
+	i := 0
+	for _, name := range got {
+		for i < len(want) && want[i] != name {
+			i++
+		}
+		if i == len(want) {
+			return false
+		}
+		i++
+	}
+	return true
+}
@@ -0,0 +1,180 @@
+package packager
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/msitest"
+)
+
+// TestConformance packages sources through every code path that writes a package and
+// checks each result against the structure of IntuneWinAppUtil output
+func TestConformance(t *testing.T) {
+	exeSource := t.TempDir()
+	os.WriteFile(filepath.Join(exeSource, "setup.exe"), []byte("setup content"), 0644)
+	os.MkdirAll(filepath.Join(exeSource, "files"), 0755)
+	os.WriteFile(filepath.Join(exeSource, "files", "app.dll"), bytes.Repeat([]byte("dll"), 1000), 0644)
+
+	msiSource := t.TempDir()
+	msitest.WriteFile(t, msiSource, "contoso.msi", msitest.MSI{
+		Properties: []msitest.Property{
+			{Name: "Manufacturer", Value: "Contoso Ltd"},
+			{Name: "ProductCode", Value: "{6F1A2B3C-4D5E-4F60-8A9B-0C1D2E3F4A5B}"},
+			{Name: "ProductName", Value: "Contoso App"},
+			{Name: "ProductVersion", Value: "2.4.1"},
+			{Name: "UpgradeCode", Value: "{A1B2C3D4-E5F6-4789-ABCD-0123456789AB}"},
+		},
+		PackageCode: "{0D2E8F41-7C3B-4A5E-9F60-112233445566}",
+	})
+
+	quiet := Options{}
+	lowMemory := quiet
+	lowMemory.LowMemory = true
+	reproducible := quiet
+	reproducible.Reproducible = &Reproducible{Seed: []byte("seed")}
+
+	cases := []struct {
+		name   string
+		source string
+		setup  string
+		opts   Options
+	}{
+		{"exe", exeSource, "setup.exe", quiet},
+		{"msi", msiSource, "contoso.msi", quiet},
+		{"low memory", exeSource, "setup.exe", lowMemory},
+		{"reproducible", exeSource, "setup.exe", reproducible},
+	}
+	var packages []string
+	for _, tc := range cases {
+		result, err := PackageWithOptions(tc.source, tc.setup, t.TempDir(), tc.opts, nil)
+		if err != nil {
+			t.Fatalf("%s: PackageWithOptions() error = %v", tc.name, err)
+		}
+		packages = append(packages, result.OutputPath)
+	}
+
+	edited := filepath.Join(t.TempDir(), "edited.intunewin")
+	if err := EditMetadata(packages[1], edited, MetadataEdit{Name: "Contoso App (edited)"}); err != nil {
+		t.Fatalf("EditMetadata() error = %v", err)
+	}
+	packages = append(packages, edited)
+
+	for _, pkg := range packages {
+		report, err := VerifyPackage(pkg, true)
+		if err != nil {
+			t.Errorf("VerifyPackage(%s) error = %v", filepath.Base(pkg), err)
+			continue
+		}
+		for _, d := range report.Divergences {
+			t.Errorf("%s: %s", filepath.Base(pkg), d)
+		}
+		if report.FileCount == 0 || report.ContentSize == 0 {
+			t.Errorf("%s: report = %+v", filepath.Base(pkg), report)
+		}
+	}
+}
+
+func TestCheckConformanceDivergences(t *testing.T) {
+	encInfo, encrypted, err := CreateEncryptionInfo([]byte("content"))
+	if err != nil {
+		t.Fatalf("CreateEncryptionInfo() error = %v", err)
+	}
+	detectionXML, err := GenerateDetectionXML(&MetadataParams{Name: "App", SetupFile: "setup.exe", UnencryptedContentSize: 7, EncryptionInfo: encInfo, ToolVersion: "1.8"})
+	if err != nil {
+		t.Fatalf("GenerateDetectionXML() error = %v", err)
+	}
+
+	// A declaration, LF line endings and reordered encryption elements
+	detectionXML = append([]byte("<?xml version=\"1.0\"?>\r\n"), detectionXML...)
+	detectionXML = bytes.Replace(detectionXML, []byte("\r\n"), []byte("\n"), 1)
+	macKey := elementLine(t, detectionXML, "MacKey")
+	detectionXML = bytes.Replace(detectionXML, macKey, nil, 1)
+	detectionXML = bytes.Replace(detectionXML, []byte("<ProfileIdentifier>"), append(macKey, []byte("<ProfileIdentifier>")...), 1)
+
+	// Metadata first, and compressed
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for _, entry := range []struct {
+		name   string
+		data   []byte
+		method uint16
+	}{
+		{DetectionXMLPath, detectionXML, zip.Deflate},
+		{EncryptedContentPath, encrypted, zip.Store},
+	} {
+		fw, _ := w.CreateHeader(&zip.FileHeader{Name: entry.name, Method: entry.method, Modified: time.Now()})
+		fw.Write(entry.data)
+	}
+	w.Close()
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Failed to read package: %v", err)
+	}
+
+	found := map[string]bool{}
+	for _, d := range CheckConformance(reader, detectionXML, encrypted) {
+		found[d.Check] = true
+	}
+	for _, check := range []string{"store-method", "entry-order", "xml-declaration", "line-endings", "element-order", "tool-version"} {
+		if !found[check] {
+			t.Errorf("Divergence %s not reported (got %v)", check, found)
+		}
+	}
+	if found["content-layout"] || found["key-size"] {
+		t.Errorf("Unexpected divergences %v", found)
+	}
+}
+
+func TestVerifyPackageCorrupt(t *testing.T) {
+	packagePath := buildTestPackage(t)
+	data, err := os.ReadFile(packagePath)
+	if err != nil {
+		t.Fatalf("Failed to read package: %v", err)
+	}
+
+	// Flip a byte of the encrypted content and rewrite the package, so the ZIP
+	// checksums are valid and only the MAC can catch the change
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Failed to read package: %v", err)
+	}
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for _, f := range reader.File {
+		content, err := readZipFile(f)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", f.Name, err)
+		}
+		if f.Name == EncryptedContentPath {
+			content[60] ^= 0xff
+		}
+		fw, _ := w.CreateHeader(&zip.FileHeader{Name: f.Name, Method: zip.Store, Modified: f.Modified})
+		fw.Write(content)
+	}
+	w.Close()
+	corrupt := filepath.Join(t.TempDir(), "corrupt.intunewin")
+	os.WriteFile(corrupt, buf.Bytes(), 0644)
+
+	if _, err := VerifyPackage(corrupt, false); err == nil || !strings.Contains(err.Error(), "HMAC") {
+		t.Errorf("VerifyPackage() error = %v, want an HMAC failure", err)
+	}
+	if _, err := VerifyPackage(packagePath, false); err != nil {
+		t.Errorf("VerifyPackage() of intact package error = %v", err)
+	}
+}
+
+// elementLine returns the line holding an element of Detection.xml, with its line ending
+func elementLine(t *testing.T, data []byte, name string) []byte {
+	t.Helper()
+	start := bytes.Index(data, []byte("<"+name+">"))
+	end := bytes.Index(data, []byte("</"+name+">\r\n"))
+	if start < 0 || end < 0 {
+		t.Fatalf("Element %s not found", name)
+	}
+	return data[start : end+len("</"+name+">\r\n")]
+}
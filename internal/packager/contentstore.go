@@ -0,0 +1,286 @@
+package packager
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ContentStoreMinSize is the smallest file kept in the content store
+// Smaller files compress faster than they can be hashed and looked up
+const ContentStoreMinSize = 256 * 1024
+
+// contentBlobHeaderSize is the CRC-32 of the file and SHA-256 of the deflate data stored
+// in front of the deflate data of a blob, so a damaged blob is never put into a package
+const contentBlobHeaderSize = 4 + sha256.Size
+
+// ContentStore is a local cache of compressed files addressed by the SHA-256 of their content
+// Apps sharing large runtimes reuse the compressed data instead of deflating it again
+type ContentStore struct {
+	dir string
+}
+
+// DefaultContentStoreRoot returns the folder of the shared content store
+func DefaultContentStoreRoot() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "intunewin", "content"), nil
+}
+
+// NewContentStore returns the content store kept in dir
+// The folder is created on first write and can be deleted at any time to reclaim space
+func NewContentStore(dir string) *ContentStore {
+	return &ContentStore{dir: dir}
+}
+
+// Dir returns the folder of the content store
+func (s *ContentStore) Dir() string {
+	return s.dir
+}
+
+// blobPath returns the file holding the compressed content with the given hash
+func (s *ContentStore) blobPath(sum string) string {
+	return filepath.Join(s.dir, sum[:2], sum)
+}
+
+// open returns the blob stored for a hash positioned at its raw deflate data, with the
+// CRC-32 of the file and the size of the data
+// The blob is read through once to check it against its SHA-256 before it is used
+func (s *ContentStore) open(sum string) (*os.File, uint32, int64, bool) {
+	path := s.blobPath(sum)
+	blob, err := os.Open(path)
+	if err != nil {
+		return nil, 0, 0, false
+	}
+	var header [contentBlobHeaderSize]byte
+	if _, err := io.ReadFull(blob, header[:]); err != nil {
+		blob.Close()
+		return nil, 0, 0, false
+	}
+	digest := sha256.New()
+	size, err := io.Copy(digest, blob)
+	if err != nil || !bytes.Equal(digest.Sum(nil), header[4:]) {
+		blob.Close()
+		return nil, 0, 0, false
+	}
+	if _, err := blob.Seek(contentBlobHeaderSize, io.SeekStart); err != nil {
+		blob.Close()
+		return nil, 0, 0, false
+	}
+	// Used blobs are touched so the least recently used ones can be cleaned up by age
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return blob, binary.LittleEndian.Uint32(header[:]), size, true
+}
+
+// put compresses the file at path at level into the blob of sum, streaming it through
+// a temporary file that is renamed into place once complete
+// The file is hashed again on the way, so a file changed since sum was computed is
+// never stored under it
+func (s *ContentStore) put(sum, path string, digest []byte, level int) error {
+	blobPath := s.blobPath(sum)
+	if err := os.MkdirAll(filepath.Dir(blobPath), 0755); err != nil {
+		return fmt.Errorf("failed to create content store folder: %w", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(blobPath), sum+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create content store blob: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	err = writeBlob(tmp, file, digest, level)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write content store blob: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), blobPath); err != nil {
+		return fmt.Errorf("failed to store content store blob: %w", err)
+	}
+	return nil
+}
+
+// writeBlob writes the header and raw deflate data of src to a new blob file; src must
+// hash to digest
+func writeBlob(blob *os.File, src io.Reader, digest []byte, level int) error {
+	// Room for the header, written once the data is compressed
+	var header [contentBlobHeaderSize]byte
+	if _, err := blob.Write(header[:]); err != nil {
+		return err
+	}
+	out := bufio.NewWriter(blob)
+	dataDigest := sha256.New()
+	fw, err := flate.NewWriter(io.MultiWriter(out, dataDigest), level)
+	if err != nil {
+		return err
+	}
+	crc, fileDigest := crc32.NewIEEE(), sha256.New()
+	if _, err := io.Copy(fw, io.TeeReader(src, io.MultiWriter(crc, fileDigest))); err != nil {
+		return err
+	}
+	if err := fw.Close(); err != nil {
+		return err
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	if !bytes.Equal(fileDigest.Sum(nil), digest) {
+		return fmt.Errorf("file changed while it was read")
+	}
+	binary.LittleEndian.PutUint32(header[:], crc.Sum32())
+	copy(header[4:], dataDigest.Sum(nil))
+	_, err = blob.WriteAt(header[:], 0)
+	return err
+}
+
+// writeFile adds a file to the ZIP from the store, compressing it at level and storing
+// it on a miss; neither the file nor its blob is ever held in memory
+// A file whose hash is in the hash cache is not read at all when its blob is stored
+// It reports whether the compressed data was reused
+func (s *ContentStore) writeFile(zw *zip.Writer, header *zip.FileHeader, path string, info os.FileInfo, level int, cache *HashCache) (bool, error) {
+	digest, cached := cache.lookup(path, info)
+	size := info.Size()
+	if !cached {
+		var err error
+		if digest, size, err = hashFile(path); err != nil {
+			return false, err
+		}
+		if size == info.Size() {
+			cache.store(path, info, digest)
+		}
+	}
+	sum := blobKey(digest, level)
+
+	if blob, crc, compressedSize, hit := s.open(sum); hit {
+		defer blob.Close()
+		return true, writeRawEntry(zw, header, crc, uint64(size), blob, compressedSize)
+	}
+	// A store that cannot be written only costs the next run its speed-up
+	if err := s.put(sum, path, digest, level); err == nil {
+		if blob, crc, compressedSize, ok := s.open(sum); ok {
+			defer blob.Close()
+			return false, writeRawEntry(zw, header, crc, uint64(size), blob, compressedSize)
+		}
+	}
+	return false, compressEntry(zw, header, path)
+}
+
+// hashFile returns the SHA-256 and size of a file, reading it as a stream
+func hashFile(path string) ([]byte, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer file.Close()
+	digest := sha256.New()
+	size, err := io.Copy(digest, file)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read file: %w", err)
+	}
+	return digest.Sum(nil), size, nil
+}
+
+// compressEntry adds a file to the ZIP compressed on the spot, as when no store is used
+func compressEntry(zw *zip.Writer, header *zip.FileHeader, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	defer file.Close()
+	writer, err := zw.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("failed to create ZIP entry: %w", err)
+	}
+	if _, err := io.Copy(writer, file); err != nil {
+		return fmt.Errorf("failed to write file to ZIP: %w", err)
+	}
+	return nil
+}
+
+// blobKey returns the name of the blob of a file with the given SHA-256 compressed at level
+func blobKey(digest []byte, level int) string {
+	sum := hex.EncodeToString(digest)
+	if level != zipDeflateLevel {
+		// Blobs of other levels are kept apart, blobs of the default level keep their names
+		sum += fmt.Sprintf("-%d", level)
+	}
+	return sum
+}
+
+// writeRawEntry adds a file to the ZIP from its raw deflate data
+func writeRawEntry(zw *zip.Writer, header *zip.FileHeader, crc uint32, size uint64, compressed io.Reader, compressedSize int64) error {
+	header.Method = zip.Deflate
+	header.CRC32 = crc
+	header.UncompressedSize64 = size
+	header.CompressedSize64 = uint64(compressedSize)
+	prepareRawHeader(header)
+	writer, err := zw.CreateRaw(header)
+	if err != nil {
+		return fmt.Errorf("failed to create ZIP entry: %w", err)
+	}
+	if _, err := io.Copy(writer, compressed); err != nil {
+		return fmt.Errorf("failed to write file to ZIP: %w", err)
+	}
+	return nil
+}
+
+// prepareRawHeader sets the fields zip.Writer.CreateHeader sets for a compressed file, so
+// an entry written from the store is byte-identical to one compressed on the spot and the
+// package records the same FileDigest with or without the store
+func prepareRawHeader(header *zip.FileHeader) {
+	if !header.NonUTF8 && utf8.ValidString(header.Name) && strings.ContainsFunc(header.Name, func(r rune) bool {
+		// CreateHeader flags names that are not plain CP-437 compatible ASCII as UTF-8
+		return r < 0x20 || r > 0x7d || r == 0x5c
+	}) {
+		header.Flags |= 0x800
+	}
+
+	header.CreatorVersion = header.CreatorVersion&0xff00 | zipVersion20
+	header.ReaderVersion = zipVersion20
+	if header.CompressedSize64 > math.MaxUint32 || header.UncompressedSize64 > math.MaxUint32 {
+		header.ReaderVersion = zipVersion45
+	}
+
+	if !header.Modified.IsZero() {
+		// The extended timestamp extra field holding the modification time
+		var extra [9]byte
+		binary.LittleEndian.PutUint16(extra[0:], 0x5455)
+		binary.LittleEndian.PutUint16(extra[2:], 5)
+		extra[4] = 1
+		binary.LittleEndian.PutUint32(extra[5:], uint32(header.Modified.Unix()))
+		header.Extra = append(header.Extra, extra[:]...)
+	}
+
+	// The CRC and sizes follow the data in a data descriptor, as for streamed entries
+	header.Flags |= 0x8
+}
+
+// ZIP versions needed to extract, as written by archive/zip
+const (
+	zipVersion20 = 20
+	zipVersion45 = 45
+)
+
+// zipDeflateLevel is the compression level archive/zip uses for Deflate entries
+const zipDeflateLevel = 5
@@ -0,0 +1,137 @@
+package packager
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeRuntime writes a compressible file larger than ContentStoreMinSize
+func writeRuntime(t *testing.T, path string, seed int64) []byte {
+	t.Helper()
+	rng := rand.New(rand.NewSource(seed))
+	words := []string{"runtime ", "library ", "export ", "symbol ", "0x", "\n"}
+	var buf bytes.Buffer
+	for buf.Len() < ContentStoreMinSize*2 {
+		buf.WriteString(words[rng.Intn(len(words))])
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// restorePackage decrypts a package into a new folder and returns it
+func restorePackage(t *testing.T, packagePath string) string {
+	t.Helper()
+	appInfo, err := ReadDetectionXML(packagePath)
+	if err != nil {
+		t.Fatalf("ReadDetectionXML() error = %v", err)
+	}
+	encrypted, err := ReadEncryptedContent(packagePath)
+	if err != nil {
+		t.Fatalf("ReadEncryptedContent() error = %v", err)
+	}
+	restoreDir := t.TempDir()
+	if _, err := RestoreContent(encrypted, appInfo.EncryptionInfo, restoreDir); err != nil {
+		t.Fatalf("RestoreContent() error = %v", err)
+	}
+	return restoreDir
+}
+
+func TestPackageWithContentStore(t *testing.T) {
+	// Low-memory runs stream the reused blobs too
+	for _, lowMemory := range []bool{false, true} {
+		store := NewContentStore(filepath.Join(t.TempDir(), "content"))
+
+		// Two apps shipping the same runtime
+		appA := t.TempDir()
+		appB := t.TempDir()
+		runtime := writeRuntime(t, filepath.Join(appA, "runtime", "core.dll"), 1)
+		writeRuntime(t, filepath.Join(appB, "bin", "core.dll"), 1)
+		writeRuntime(t, filepath.Join(appB, "bin", "app.dll"), 2)
+		for _, dir := range []string{appA, appB} {
+			if err := os.WriteFile(filepath.Join(dir, "setup.exe"), []byte("installer"), 0644); err != nil {
+				t.Fatalf("Failed to write setup file: %v", err)
+			}
+		}
+
+		opts := Options{ContentStore: store, LowMemory: lowMemory}
+		first, err := PackageWithOptions(appA, "setup.exe", t.TempDir(), opts, nil)
+		if err != nil {
+			t.Fatalf("PackageWithOptions(LowMemory: %v) error = %v", lowMemory, err)
+		}
+		if first.ReusedFiles != 0 {
+			t.Errorf("LowMemory %v: first run ReusedFiles = %d, want 0", lowMemory, first.ReusedFiles)
+		}
+
+		second, err := PackageWithOptions(appB, "setup.exe", t.TempDir(), opts, nil)
+		if err != nil {
+			t.Fatalf("PackageWithOptions(LowMemory: %v) error = %v", lowMemory, err)
+		}
+		if second.ReusedFiles != 1 || second.ReusedSize != int64(len(runtime)) {
+			t.Errorf("LowMemory %v: second run reused %d files (%d bytes), want 1 file (%d bytes)", lowMemory, second.ReusedFiles, second.ReusedSize, len(runtime))
+		}
+
+		// The reused entry must restore to the original bytes
+		restored := restorePackage(t, second.OutputPath)
+		got, err := os.ReadFile(filepath.Join(restored, "bin", "core.dll"))
+		if err != nil {
+			t.Fatalf("Failed to read restored file: %v", err)
+		}
+		if !bytes.Equal(got, runtime) {
+			t.Errorf("LowMemory %v: restored core.dll differs from the source", lowMemory)
+		}
+		if _, err := os.Stat(filepath.Join(restored, "setup.exe")); err != nil {
+			t.Errorf("LowMemory %v: setup.exe missing from package: %v", lowMemory, err)
+		}
+	}
+}
+
+func TestContentStoreIgnoresDamagedBlob(t *testing.T) {
+	store := NewContentStore(t.TempDir())
+	source := t.TempDir()
+	runtime := writeRuntime(t, filepath.Join(source, "core.dll"), 3)
+	if err := os.WriteFile(filepath.Join(source, "setup.exe"), []byte("installer"), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+
+	opts := Options{ContentStore: store}
+	if _, err := PackageWithOptions(source, "setup.exe", t.TempDir(), opts, nil); err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+
+	// Corrupt every stored blob
+	blobs, err := filepath.Glob(filepath.Join(store.Dir(), "*", "*"))
+	if err != nil || len(blobs) != 1 {
+		t.Fatalf("Expected one stored blob, got %v (%v)", blobs, err)
+	}
+	data, err := os.ReadFile(blobs[0])
+	if err != nil {
+		t.Fatalf("Failed to read blob: %v", err)
+	}
+	data[len(data)-1] ^= 0xFF
+	if err := os.WriteFile(blobs[0], data, 0644); err != nil {
+		t.Fatalf("Failed to write blob: %v", err)
+	}
+
+	result, err := PackageWithOptions(source, "setup.exe", t.TempDir(), opts, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	if result.ReusedFiles != 0 {
+		t.Errorf("ReusedFiles = %d, want 0 for a damaged blob", result.ReusedFiles)
+	}
+	got, err := os.ReadFile(filepath.Join(restorePackage(t, result.OutputPath), "core.dll"))
+	if err != nil {
+		t.Fatalf("Failed to read restored file: %v", err)
+	}
+	if !bytes.Equal(got, runtime) {
+		t.Error("Restored core.dll differs from the source")
+	}
+}
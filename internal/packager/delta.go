@@ -0,0 +1,276 @@
+package packager
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// PreviousPackage is an earlier package of the same app whose compressed file data is
+// reused for files that did not change, so a new version only compresses what changed
+type PreviousPackage struct {
+	// Path is the .intunewin file of the previous version
+	Path string
+	// Encryption holds the keys of the previous package (optional, read from its Detection.xml)
+	Encryption *EncryptionXML
+}
+
+// PreviousContent is the decrypted content ZIP of a previous package, kept in a
+// temporary file so packages of any size are reused with flat memory use
+type PreviousContent struct {
+	file    *os.File
+	reader  *zip.Reader
+	entries map[string]*zip.File
+}
+
+// OpenPreviousContent decrypts the content of a previous package into a temporary file,
+// verifying its HMAC and digest against the keys of the package
+// The caller must Close it to remove the temporary file
+func OpenPreviousContent(prev PreviousPackage) (*PreviousContent, error) {
+	encXML := prev.Encryption
+	if encXML == nil {
+		info, err := ReadDetectionXML(prev.Path)
+		if err != nil {
+			return nil, err
+		}
+		encXML = &info.EncryptionInfo
+	}
+	keys, err := encXML.Decode()
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption info: %w", err)
+	}
+
+	reader, err := zip.OpenReader(prev.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open previous package: %w", err)
+	}
+	defer reader.Close()
+	var encrypted *zip.File
+	for _, f := range reader.File {
+		if IsPackageEntry(f.Name, EncryptedContentPath) {
+			encrypted = f
+			break
+		}
+	}
+	if encrypted == nil {
+		return nil, fmt.Errorf("encrypted content not found in package: %s", prev.Path)
+	}
+	rc, err := encrypted.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open encrypted content: %w", err)
+	}
+	defer rc.Close()
+
+	file, err := os.CreateTemp("", "intunewin-previous-*.zip")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	content := &PreviousContent{file: file}
+	buffered := bufio.NewWriterSize(file, 1<<20)
+	digest, err := decryptContentTo(buffered, rc, keys.EncryptionKey, keys.MacKey)
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err != nil {
+		content.Close()
+		return nil, fmt.Errorf("failed to decrypt previous package: %w", err)
+	}
+	if !bytes.Equal(digest, keys.FileDigest) {
+		content.Close()
+		return nil, fmt.Errorf("file digest mismatch: content does not match Detection.xml")
+	}
+
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		content.Close()
+		return nil, fmt.Errorf("failed to read previous package: %w", err)
+	}
+	zr, err := zip.NewReader(file, size)
+	if err != nil {
+		content.Close()
+		return nil, fmt.Errorf("failed to open content ZIP of previous package: %w", err)
+	}
+	content.reader = zr
+	content.entries = make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		// Only deflated files can be copied as they are into the new content ZIP
+		if f.Method == zip.Deflate && !f.FileInfo().IsDir() {
+			content.entries[f.Name] = f
+		}
+	}
+	return content, nil
+}
+
+// openPrevious decrypts the previous package of a packaging run, nil when it has none
+func openPrevious(opts Options, report ProgressCallback, log *slog.Logger) (*PreviousContent, error) {
+	if opts.Previous == nil {
+		return nil, nil
+	}
+	report("Decrypting previous package", 0.15)
+	previous, err := OpenPreviousContent(*opts.Previous)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug("previous package decrypted", "path", opts.Previous.Path, "files", previous.Files())
+	return previous, nil
+}
+
+// Files returns the number of files whose compressed data can be reused
+func (c *PreviousContent) Files() int {
+	return len(c.entries)
+}
+
+// Entry returns a file of the content by its path in the content ZIP, whatever its compression
+func (c *PreviousContent) Entry(name string) (*zip.File, bool) {
+	for _, f := range c.reader.File {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// Close removes the temporary file holding the decrypted content
+func (c *PreviousContent) Close() error {
+	c.file.Close()
+	return os.Remove(c.file.Name())
+}
+
+// writeFile adds a file to the ZIP with the compressed data of the previous package when
+// the file has the same path and content there
+// It reports whether the file was written; files that changed are left to the caller
+func (c *PreviousContent) writeFile(zw *zip.Writer, header *zip.FileHeader, path string, size int64) (bool, error) {
+	prev, ok := c.entries[header.Name]
+	if !ok || prev.UncompressedSize64 != uint64(size) {
+		return false, nil
+	}
+	// A matching CRC-32 is not proof enough to ship stale content, compare the bytes
+	same, err := sameContent(prev, path)
+	if err != nil || !same {
+		return false, err
+	}
+
+	header.Method = zip.Deflate
+	header.CRC32 = prev.CRC32
+	header.UncompressedSize64 = prev.UncompressedSize64
+	header.CompressedSize64 = prev.CompressedSize64
+	prepareRawHeader(header)
+	writer, err := zw.CreateRaw(header)
+	if err != nil {
+		return false, fmt.Errorf("failed to create ZIP entry: %w", err)
+	}
+	raw, err := prev.OpenRaw()
+	if err != nil {
+		return false, fmt.Errorf("failed to read previous package: %w", err)
+	}
+	if _, err := io.Copy(writer, raw); err != nil {
+		return false, fmt.Errorf("failed to write file to ZIP: %w", err)
+	}
+	return true, nil
+}
+
+// sameContent reports whether a ZIP entry holds exactly the content of a file
+func sameContent(entry *zip.File, path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	rc, err := entry.Open()
+	if err != nil {
+		return false, fmt.Errorf("failed to read previous package: %w", err)
+	}
+	defer rc.Close()
+
+	a := make([]byte, 256<<10)
+	b := make([]byte, len(a))
+	for {
+		n, errA := io.ReadFull(file, a)
+		m, errB := io.ReadFull(rc, b)
+		if n != m || !bytes.Equal(a[:n], b[:m]) {
+			return false, nil
+		}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == io.EOF || errB == io.ErrUnexpectedEOF, nil
+		}
+		if errA != nil {
+			return false, fmt.Errorf("failed to read file: %w", errA)
+		}
+		if errB != nil {
+			// A damaged entry is compressed again rather than copied
+			return false, nil
+		}
+	}
+}
+
+// decryptContentTo decrypts content in the .intunewin format (see DecryptContent) from r
+// to w one chunk at a time, returning the SHA256 digest of the plaintext
+// The HMAC is verified once all data is read, so w must be discarded on error
+func decryptContentTo(w io.Writer, r io.Reader, encKey, macKey []byte) ([]byte, error) {
+	var head [48]byte // HMAC (32) + IV (16)
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, fmt.Errorf("encrypted data too short")
+	}
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+	}
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(head[32:])
+	mode := cipher.NewCBCDecrypter(block, head[32:])
+	digest := sha256.New()
+	out := io.MultiWriter(w, digest)
+
+	buf := make([]byte, 1<<20)
+	// The last block is held back until the end to remove its padding
+	var last []byte
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if readErr != nil && !errors.Is(readErr, io.EOF) && !errors.Is(readErr, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("failed to read encrypted content: %w", readErr)
+		}
+		if n%aes.BlockSize != 0 {
+			return nil, fmt.Errorf("encrypted data is not a whole number of AES blocks")
+		}
+		if n > 0 {
+			chunk := buf[:n]
+			mac.Write(chunk)
+			mode.CryptBlocks(chunk, chunk)
+			if last != nil {
+				if _, err := out.Write(last); err != nil {
+					return nil, err
+				}
+			}
+			if _, err := out.Write(chunk[:n-aes.BlockSize]); err != nil {
+				return nil, err
+			}
+			last = append(last[:0], chunk[n-aes.BlockSize:]...)
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	if last == nil {
+		return nil, fmt.Errorf("encrypted data too short")
+	}
+	if !hmac.Equal(head[:32], mac.Sum(nil)) {
+		return nil, fmt.Errorf("HMAC verification failed")
+	}
+	tail, err := PKCS7Unpad(last)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := out.Write(tail); err != nil {
+		return nil, err
+	}
+	return digest.Sum(nil), nil
+}
@@ -0,0 +1,113 @@
+package packager
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPackageWithPrevious(t *testing.T) {
+	sourceDir := t.TempDir()
+	random := make([]byte, 300<<10)
+	rand.Read(random)
+	files := map[string][]byte{
+		"setup.exe":        random,
+		"data/runtime.dll": bytes.Repeat([]byte("runtime "), 50000),
+		"data/readme.txt":  []byte("version 1"),
+	}
+	for name, content := range files {
+		path := filepath.Join(sourceDir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	previous, err := PackageWithOptions(sourceDir, "setup.exe", t.TempDir(), Options{}, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+
+	// Version 2 changes the readme, keeping its size, and adds a file
+	os.WriteFile(filepath.Join(sourceDir, "data", "readme.txt"), []byte("version 2"), 0644)
+	os.WriteFile(filepath.Join(sourceDir, "data", "new.txt"), []byte("added"), 0644)
+
+	fresh, err := PackageWithOptions(sourceDir, "setup.exe", t.TempDir(), Options{}, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	want := packageDigest(t, fresh.OutputPath)
+
+	for _, lowMemory := range []bool{false, true} {
+		opts := Options{LowMemory: lowMemory, Previous: &PreviousPackage{Path: previous.OutputPath}}
+		result, err := PackageWithOptions(sourceDir, "setup.exe", t.TempDir(), opts, nil)
+		if err != nil {
+			t.Fatalf("PackageWithOptions(LowMemory: %v) error = %v", lowMemory, err)
+		}
+		// setup.exe holds random data and is stored, which takes no compressing to begin with
+		if result.ReusedFiles != 1 || result.ReusedSize != 400000 {
+			t.Errorf("LowMemory %v: reused %d files (%d bytes), want runtime.dll", lowMemory, result.ReusedFiles, result.ReusedSize)
+		}
+		// Reused entries are the ones compression would have produced
+		if got := packageDigest(t, result.OutputPath); got != want {
+			t.Errorf("LowMemory %v: FileDigest = %s, want %s as without the previous package", lowMemory, got, want)
+		}
+	}
+}
+
+func TestOpenPreviousContentRejectsWrongKeys(t *testing.T) {
+	sourceDir := writeSplitSource(t, map[string]int{"setup.exe": 1000})
+	first, err := PackageWithOptions(sourceDir, "setup.exe", t.TempDir(), Options{}, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	other, err := PackageWithOptions(sourceDir, "setup.exe", t.TempDir(), Options{}, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	info, err := ReadDetectionXML(other.OutputPath)
+	if err != nil {
+		t.Fatalf("ReadDetectionXML() error = %v", err)
+	}
+
+	_, err = OpenPreviousContent(PreviousPackage{Path: first.OutputPath, Encryption: &info.EncryptionInfo})
+	if err == nil || !strings.Contains(err.Error(), "HMAC") {
+		t.Errorf("OpenPreviousContent() error = %v, want HMAC verification failure", err)
+	}
+}
+
+func TestDecryptContentTo(t *testing.T) {
+	encKey, macKey, iv, err := GenerateKeys()
+	if err != nil {
+		t.Fatalf("GenerateKeys() error = %v", err)
+	}
+	for _, size := range []int{0, 15, 16, 1<<20 - 16, 1<<20 + 3} {
+		plaintext := make([]byte, size)
+		rand.Read(plaintext)
+		encrypted, err := EncryptContent(plaintext, encKey, macKey, iv)
+		if err != nil {
+			t.Fatalf("EncryptContent() error = %v", err)
+		}
+
+		var out bytes.Buffer
+		digest, err := decryptContentTo(&out, bytes.NewReader(encrypted), encKey, macKey)
+		if err != nil {
+			t.Fatalf("decryptContentTo(%d bytes) error = %v", size, err)
+		}
+		if !bytes.Equal(out.Bytes(), plaintext) || !bytes.Equal(digest, CalculateFileDigest(plaintext)) {
+			t.Errorf("decryptContentTo(%d bytes) did not restore the plaintext", size)
+		}
+	}
+}
+
+// packageDigest returns the FileDigest recorded in the Detection.xml of a package
+func packageDigest(t *testing.T, packagePath string) string {
+	t.Helper()
+	info, err := ReadDetectionXML(packagePath)
+	if err != nil {
+		t.Fatalf("ReadDetectionXML() error = %v", err)
+	}
+	return info.EncryptionInfo.FileDigest
+}
@@ -0,0 +1,108 @@
+package packager
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ContentDigest is the digest Detection.xml records for the content of a package:
+// the SHA256 of the unencrypted content ZIP, before encryption
+type ContentDigest struct {
+	// FileDigest is the SHA256 of the content ZIP
+	FileDigest []byte `json:"-"`
+	// UnencryptedContentSize is the size of the content ZIP in bytes
+	UnencryptedContentSize int64 `json:"unencryptedContentSize"`
+	// Files lists the files of the content with their own digests (folders only)
+	Files []ManifestFile `json:"files,omitempty"`
+}
+
+// Base64 returns the digest as Detection.xml writes it
+func (d *ContentDigest) Base64() string {
+	return base64.StdEncoding.EncodeToString(d.FileDigest)
+}
+
+// Hex returns the digest as a hex string, as printed by sha256sum
+func (d *ContentDigest) Hex() string {
+	return hex.EncodeToString(d.FileDigest)
+}
+
+// FolderDigest compresses a source folder exactly as packaging with opts does and returns
+// the digest its package will record, without encrypting or writing anything
+// Only opts.Exclude and opts.Reproducible affect the digest. Unless the build is
+// reproducible, files keep their modification times in the ZIP, so touching a file
+// changes the digest
+func FolderDigest(sourcePath string, opts Options) (*ContentDigest, error) {
+	return SetupFolderDigest(sourcePath, "", opts)
+}
+
+// SetupFolderDigest returns the digest like FolderDigest for packaging with a setup file,
+// which adds the install script of an MSI suite to the content (see DetectMsiSuite)
+// The script records the packaging time unless the build is reproducible
+func SetupFolderDigest(sourcePath, setupFile string, opts Options) (*ContentDigest, error) {
+	if err := ValidateExcludePatterns(opts.Exclude); err != nil {
+		return nil, err
+	}
+	zipOpts := ZipOptions{Exclude: opts.Exclude, Symlinks: opts.Symlinks, NormalizePermissions: opts.NormalizePermissions, Compression: opts.Compression, Variables: opts.Variables}
+	if opts.Reproducible != nil {
+		zipOpts.ModTime = opts.Reproducible.modTime()
+	}
+	if setupFile != "" {
+		_, extra, err := suiteContent(sourcePath, setupFile, opts, opts.logger())
+		if err != nil {
+			return nil, err
+		}
+		zipOpts.Extra = extra
+	}
+	zipData, err := ZipFolderWithOptions(sourcePath, zipOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ZIP: %w", err)
+	}
+	files, err := BuildManifestFiles(zipData)
+	if err != nil {
+		return nil, err
+	}
+	return &ContentDigest{
+		FileDigest:             CalculateFileDigest(zipData),
+		UnencryptedContentSize: int64(len(zipData)),
+		Files:                  files,
+	}, nil
+}
+
+// PackageDigest returns the content digest recorded in the Detection.xml of a package
+func PackageDigest(packagePath string) (*ContentDigest, error) {
+	appInfo, err := ReadDetectionXML(packagePath)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(appInfo.EncryptionInfo.FileDigestAlgorithm, FileDigestAlgorithm) {
+		return nil, fmt.Errorf("unsupported digest algorithm: %s", appInfo.EncryptionInfo.FileDigestAlgorithm)
+	}
+	digest, err := base64.StdEncoding.DecodeString(appInfo.EncryptionInfo.FileDigest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid FileDigest in Detection.xml: %w", err)
+	}
+	return &ContentDigest{
+		FileDigest:             digest,
+		UnencryptedContentSize: appInfo.UnencryptedContentSize,
+	}, nil
+}
+
+// FileSHA256 returns the SHA256 of a single file
+func FileSHA256(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return h.Sum(nil), nil
+}
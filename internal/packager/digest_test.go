@@ -0,0 +1,54 @@
+package packager
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFolderDigestMatchesPackage(t *testing.T) {
+	sourceDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "setup.exe"), bytes.Repeat([]byte("installer"), ContentStoreMinSize), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "install.log"), []byte("left over"), 0644); err != nil {
+		t.Fatalf("Failed to write log file: %v", err)
+	}
+
+	exclude := []string{"*.log"}
+	digest, err := FolderDigest(sourceDir, Options{Exclude: exclude})
+	if err != nil {
+		t.Fatalf("FolderDigest() error = %v", err)
+	}
+	if len(digest.Files) != 1 || digest.Files[0].Path != "setup.exe" {
+		t.Errorf("Files = %+v, want only setup.exe", digest.Files)
+	}
+
+	// The setup file is large enough for the content store, whose precompressed
+	// entries must not change the digest
+	store := NewContentStore(t.TempDir())
+	result, err := PackageWithOptions(sourceDir, "setup.exe", t.TempDir(), Options{Exclude: exclude, ContentStore: store}, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	recorded, err := PackageDigest(result.OutputPath)
+	if err != nil {
+		t.Fatalf("PackageDigest() error = %v", err)
+	}
+	if !bytes.Equal(digest.FileDigest, recorded.FileDigest) {
+		t.Errorf("FolderDigest() = %s, package records %s", digest.Base64(), recorded.Base64())
+	}
+	if digest.UnencryptedContentSize != recorded.UnencryptedContentSize {
+		t.Errorf("UnencryptedContentSize = %d, package records %d", digest.UnencryptedContentSize, recorded.UnencryptedContentSize)
+	}
+
+	// Without the exclusion the content, and so the digest, differs
+	all, err := FolderDigest(sourceDir, Options{})
+	if err != nil {
+		t.Fatalf("FolderDigest() error = %v", err)
+	}
+	if bytes.Equal(all.FileDigest, digest.FileDigest) {
+		t.Error("Excluded files did not change the digest")
+	}
+}
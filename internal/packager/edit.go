@@ -0,0 +1,185 @@
+package packager
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrReadOnly reports a package on read-only media, or whose file or folder cannot be written
+	ErrReadOnly = errors.New("read-only")
+	// ErrLocked reports a package held open by another process, such as a sync client
+	ErrLocked = errors.New("locked by another process")
+)
+
+// MetadataEdit lists the Detection.xml fields to change; empty fields are left as they are
+// The encrypted content is not touched, so its keys and digest stay valid
+type MetadataEdit struct {
+	// Name is the application name
+	Name string
+	// SetupFile is the setup file name inside the content
+	SetupFile string
+	// ToolVersion is the ToolVersion attribute
+	ToolVersion string
+}
+
+// Empty reports whether the edit changes nothing
+func (e MetadataEdit) Empty() bool {
+	return e == MetadataEdit{}
+}
+
+// EditDetectionXML applies an edit to Detection.xml content
+func EditDetectionXML(data []byte, edit MetadataEdit) ([]byte, error) {
+	appInfo, err := ParseDetectionXML(data)
+	if err != nil {
+		return nil, err
+	}
+	if edit.Name != "" {
+		appInfo.Name = edit.Name
+	}
+	if edit.SetupFile != "" {
+		appInfo.SetupFile = edit.SetupFile
+	}
+	if edit.ToolVersion != "" {
+		appInfo.ToolVersion = edit.ToolVersion
+	}
+	return marshalDetectionXML(appInfo)
+}
+
+// EditMetadata writes the package at packagePath to outputPath with its Detection.xml edited
+// Every other entry is copied unchanged. The result is written to a temporary file next to
+// outputPath and renamed into place, so outputPath may be packagePath for an in-place edit
+// (check it with CheckModifiable and back it up with BackupPackage first)
+func EditMetadata(packagePath, outputPath string, edit MetadataEdit) (err error) {
+	reader, err := zip.OpenReader(packagePath)
+	if err != nil {
+		return fmt.Errorf("failed to open package: %w", err)
+	}
+	defer reader.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(outputPath), filepath.Base(outputPath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	// Temporary files are created private; the edit gets the permissions of the package,
+	// writable so a copy of a package on read-only media can be edited again
+	if info, err := os.Stat(packagePath); err == nil {
+		if err := tmp.Chmod(info.Mode().Perm() | 0200); err != nil {
+			return fmt.Errorf("failed to set output permissions: %w", err)
+		}
+	}
+
+	zipWriter := zip.NewWriter(tmp)
+	found := false
+	for _, f := range reader.File {
+		if !IsPackageEntry(f.Name, DetectionXMLPath) {
+			if err := zipWriter.Copy(f); err != nil {
+				return fmt.Errorf("failed to copy %s: %w", f.Name, err)
+			}
+			continue
+		}
+
+		found = true
+		data, err := readZipFile(f)
+		if err != nil {
+			return err
+		}
+		edited, err := EditDetectionXML(data, edit)
+		if err != nil {
+			return err
+		}
+		// The entry keeps its name, method and time; sizes and CRC are recomputed
+		header := &zip.FileHeader{Name: f.Name, Method: f.Method, Modified: f.Modified}
+		w, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			return fmt.Errorf("failed to create metadata entry: %w", err)
+		}
+		if _, err := w.Write(edited); err != nil {
+			return fmt.Errorf("failed to write metadata: %w", err)
+		}
+	}
+	if !found {
+		return fmt.Errorf("Detection.xml not found in package: %s", packagePath)
+	}
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to close package: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to flush package: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close package: %w", err)
+	}
+
+	// The source must be closed before an in-place rename on Windows
+	reader.Close()
+	if err := os.Rename(tmp.Name(), outputPath); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", outputPath, err)
+	}
+	return nil
+}
+
+// CheckModifiable reports why a package cannot be modified in place: an error wrapping
+// ErrReadOnly when the file, its folder or the volume is read-only, or ErrLocked when
+// another process holds it open (detected on Windows, where sync clients lock files)
+func CheckModifiable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("cannot access package: %w", err)
+	}
+	if info.Mode().Perm()&0200 == 0 {
+		return fmt.Errorf("%s is %w (file attribute)", path, ErrReadOnly)
+	}
+	if err := CheckFolderWritable(filepath.Dir(path)); err != nil {
+		return err
+	}
+	return checkFileLock(path)
+}
+
+// CheckFolderWritable creates and removes a probe file, which fails on read-only media
+func CheckFolderWritable(dir string) error {
+	probe, err := os.CreateTemp(dir, ".intunewin-probe-*")
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) || isReadOnlyFSError(err) {
+			return fmt.Errorf("folder %s is %w", dir, ErrReadOnly)
+		}
+		return fmt.Errorf("cannot write to folder %s: %w", dir, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return nil
+}
+
+// BackupPackage copies a package to <package>.<time>.bak next to it and returns the copy's path
+func BackupPackage(path string, now time.Time) (string, error) {
+	backup := fmt.Sprintf("%s.%s.bak", path, now.Format("20060102-150405"))
+	src, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open package: %w", err)
+	}
+	defer src.Close()
+
+	if err := copyToFile(backup, src); err != nil {
+		os.Remove(backup)
+		return "", fmt.Errorf("failed to back up package: %w", err)
+	}
+	return backup, nil
+}
+
+// EditedPackagePath returns the default output of an edit: <name>.edited.intunewin in dir
+func EditedPackagePath(packagePath, dir string) string {
+	base := strings.TrimSuffix(filepath.Base(packagePath), filepath.Ext(packagePath))
+	return filepath.Join(dir, base+".edited.intunewin")
+}
@@ -0,0 +1,137 @@
+package packager
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// buildTestPackage packages a single setup file and returns the package path
+func buildTestPackage(t *testing.T) string {
+	t.Helper()
+	sourceDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "setup.exe"), []byte("setup content"), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+	result, err := Package(sourceDir, "setup.exe", t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Package() error = %v", err)
+	}
+	return result.OutputPath
+}
+
+func TestEditMetadata(t *testing.T) {
+	packagePath := buildTestPackage(t)
+	before, err := ReadDetectionXML(packagePath)
+	if err != nil {
+		t.Fatalf("ReadDetectionXML() error = %v", err)
+	}
+
+	output := EditedPackagePath(packagePath, t.TempDir())
+	if filepath.Base(output) != "setup.edited.intunewin" {
+		t.Errorf("EditedPackagePath() = %s, want setup.edited.intunewin", output)
+	}
+	if err := EditMetadata(packagePath, output, MetadataEdit{Name: "Contoso App", ToolVersion: "1.8.4.0"}); err != nil {
+		t.Fatalf("EditMetadata() error = %v", err)
+	}
+
+	after, err := ReadDetectionXML(output)
+	if err != nil {
+		t.Fatalf("ReadDetectionXML() error = %v", err)
+	}
+	if after.Name != "Contoso App" || after.ToolVersion != "1.8.4.0" || after.SetupFile != "setup.exe" {
+		t.Errorf("Edited metadata = %s, %s, %s", after.Name, after.ToolVersion, after.SetupFile)
+	}
+	if after.EncryptionInfo != before.EncryptionInfo {
+		t.Error("Encryption info changed by a metadata edit")
+	}
+
+	// The content is copied unchanged and still decrypts with the keys of Detection.xml
+	original, err := ReadEncryptedContent(packagePath)
+	if err != nil {
+		t.Fatalf("ReadEncryptedContent() error = %v", err)
+	}
+	edited, err := ReadEncryptedContent(output)
+	if err != nil {
+		t.Fatalf("ReadEncryptedContent() error = %v", err)
+	}
+	if !bytes.Equal(original, edited) {
+		t.Error("Encrypted content changed by a metadata edit")
+	}
+	if _, err := DecryptPackageContent(edited, after.EncryptionInfo); err != nil {
+		t.Errorf("DecryptPackageContent() error = %v", err)
+	}
+
+	// An in-place edit replaces the package and leaves no temporary files behind
+	if err := EditMetadata(packagePath, packagePath, MetadataEdit{SetupFile: "install.cmd"}); err != nil {
+		t.Fatalf("EditMetadata() in place error = %v", err)
+	}
+	inPlace, err := ReadDetectionXML(packagePath)
+	if err != nil {
+		t.Fatalf("ReadDetectionXML() error = %v", err)
+	}
+	if inPlace.SetupFile != "install.cmd" || inPlace.Name != before.Name {
+		t.Errorf("In-place edit = %s, %s", inPlace.Name, inPlace.SetupFile)
+	}
+	entries, err := os.ReadDir(filepath.Dir(packagePath))
+	if err != nil {
+		t.Fatalf("Failed to list output folder: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Output folder has %d files after an in-place edit, want 1", len(entries))
+	}
+}
+
+func TestEditMetadataNotAPackage(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "broken.intunewin")
+	if err := os.WriteFile(path, []byte("not a zip"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := EditMetadata(path, filepath.Join(dir, "out.intunewin"), MetadataEdit{Name: "x"}); err == nil {
+		t.Error("Expected error for a file that is not a package")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out.intunewin")); !os.IsNotExist(err) {
+		t.Error("Output written for a failed edit")
+	}
+}
+
+func TestCheckModifiable(t *testing.T) {
+	packagePath := buildTestPackage(t)
+	if err := CheckModifiable(packagePath); err != nil {
+		t.Errorf("CheckModifiable() error = %v", err)
+	}
+
+	if err := os.Chmod(packagePath, 0444); err != nil {
+		t.Fatalf("Failed to make package read-only: %v", err)
+	}
+	defer os.Chmod(packagePath, 0644)
+	if err := CheckModifiable(packagePath); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("CheckModifiable() of a read-only file = %v, want ErrReadOnly", err)
+	}
+
+	if err := CheckModifiable(filepath.Join(t.TempDir(), "missing.intunewin")); err == nil {
+		t.Error("Expected error for a missing package")
+	}
+}
+
+func TestBackupPackage(t *testing.T) {
+	packagePath := buildTestPackage(t)
+	now := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+
+	backup, err := BackupPackage(packagePath, now)
+	if err != nil {
+		t.Fatalf("BackupPackage() error = %v", err)
+	}
+	if backup != packagePath+".20261016-093000.bak" {
+		t.Errorf("Backup path = %s", backup)
+	}
+	original, _ := os.ReadFile(packagePath)
+	copied, err := os.ReadFile(backup)
+	if err != nil || !bytes.Equal(original, copied) {
+		t.Errorf("Backup does not match the package: %v", err)
+	}
+}
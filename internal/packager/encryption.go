@@ -76,6 +76,12 @@ func PKCS7Unpad(data []byte) ([]byte, error) {
 // Output format: [HMAC-SHA256 (32 bytes)][IV (16 bytes)][AES-256-CBC Ciphertext]
 // This matches Microsoft's .intunewin encryption format
 func EncryptContent(plaintext, encKey, macKey, iv []byte) ([]byte, error) {
+	return encryptContent(plaintext, encKey, macKey, iv, nil)
+}
+
+// encryptContent encrypts like EncryptContent, passing the bytes encrypted so far and in
+// total to progress after each chunk (optional)
+func encryptContent(plaintext, encKey, macKey, iv []byte, progress func(done, total int64)) ([]byte, error) {
 	if len(encKey) != 32 {
 		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(encKey))
 	}
@@ -86,34 +92,92 @@ func EncryptContent(plaintext, encKey, macKey, iv []byte) ([]byte, error) {
 		return nil, fmt.Errorf("IV must be 16 bytes, got %d", len(iv))
 	}
 
-	// Step 1: PKCS7 pad the plaintext
-	padded := PKCS7Pad(plaintext, aes.BlockSize)
+	// The blob is allocated once and the padded plaintext encrypted in place, so
+	// multi-GB content is not copied for each step
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	result := make([]byte, sha256.Size+aes.BlockSize+len(plaintext)+padding)
+	copy(result[sha256.Size:], iv)
+	ciphertext := result[sha256.Size+aes.BlockSize:]
+	copy(ciphertext, plaintext)
+	for i := len(plaintext); i < len(ciphertext); i++ {
+		ciphertext[i] = byte(padding)
+	}
 
-	// Step 2: Create AES cipher
 	block, err := aes.NewCipher(encKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
 	}
-
-	// Step 3: Encrypt using CBC mode
-	ciphertext := make([]byte, len(padded))
 	mode := cipher.NewCBCEncrypter(block, iv)
-	mode.CryptBlocks(ciphertext, padded)
 
-	// Step 4: Prepend IV to ciphertext
-	ivAndCiphertext := append(iv, ciphertext...)
-
-	// Step 5: Calculate HMAC-SHA256 over IV+ciphertext
+	// HMAC-SHA256 over IV+ciphertext, computed for each chunk while the next is encrypted
 	mac := hmac.New(sha256.New, macKey)
-	mac.Write(ivAndCiphertext)
-	hmacResult := mac.Sum(nil)
-
-	// Step 6: Final format: [HMAC(32)][IV(16)][Ciphertext]
-	result := append(hmacResult, ivAndCiphertext...)
+	mac.Write(iv)
+	macs := newChunkPipeline(func(chunk []byte) error {
+		mac.Write(chunk)
+		return nil
+	})
+	for offset := 0; offset < len(ciphertext); offset += macChunkSize {
+		chunk := ciphertext[offset:min(offset+macChunkSize, len(ciphertext))]
+		mode.CryptBlocks(chunk, chunk)
+		macs.send(chunk)
+		if progress != nil {
+			progress(int64(offset+len(chunk)), int64(len(ciphertext)))
+		}
+	}
+	if err := macs.close(); err != nil {
+		return nil, err
+	}
+	mac.Sum(result[:0])
 
 	return result, nil
 }
 
+// macChunkSize is the amount of content encrypted in memory before it is passed to the
+// HMAC; a multiple of the AES block size
+var macChunkSize = 1 << 20
+
+// chunkPipeline consumes chunks on its own goroutine, in order, so that producing the
+// next chunk (encrypting it) overlaps with consuming the previous one (MAC, write)
+// CBC encryption cannot be split across cores since each block depends on the one before,
+// but this takes the HMAC and the writes off its core
+type chunkPipeline struct {
+	chunks chan []byte
+	done   chan error
+}
+
+// chunkPipelineDepth is how many chunks may wait for the consumer
+const chunkPipelineDepth = 2
+
+// newChunkPipeline starts consuming chunks with consume
+// After consume fails, later chunks are drained without being consumed
+func newChunkPipeline(consume func([]byte) error) *chunkPipeline {
+	p := &chunkPipeline{
+		chunks: make(chan []byte, chunkPipelineDepth),
+		done:   make(chan error, 1),
+	}
+	go func() {
+		var err error
+		for chunk := range p.chunks {
+			if err == nil {
+				err = consume(chunk)
+			}
+		}
+		p.done <- err
+	}()
+	return p
+}
+
+// send queues a chunk; it must not be modified until the consumer is done with it
+func (p *chunkPipeline) send(chunk []byte) {
+	p.chunks <- chunk
+}
+
+// close waits for the queued chunks to be consumed and returns the first error of consume
+func (p *chunkPipeline) close() error {
+	close(p.chunks)
+	return <-p.done
+}
+
 // DecryptContent decrypts data in the .intunewin format
 // Input format: [HMAC-SHA256 (32 bytes)][IV (16 bytes)][AES-256-CBC Ciphertext]
 func DecryptContent(encrypted, encKey, macKey []byte) ([]byte, error) {
@@ -121,6 +185,10 @@ func DecryptContent(encrypted, encKey, macKey []byte) ([]byte, error) {
 		return nil, fmt.Errorf("encrypted data too short")
 	}
 
+	if (len(encrypted)-48)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("encrypted data is not a whole number of AES blocks")
+	}
+
 	// Extract components
 	hmacReceived := encrypted[:32]
 	iv := encrypted[32:48]
@@ -158,17 +226,33 @@ func CalculateFileDigest(data []byte) []byte {
 // CreateEncryptionInfo generates all encryption components and returns EncryptionInfo
 // This is the main entry point for encrypting content
 func CreateEncryptionInfo(plaintext []byte) (*EncryptionInfo, []byte, error) {
-	// Calculate file digest before encryption
-	fileDigest := CalculateFileDigest(plaintext)
-
 	// Generate keys
 	encKey, macKey, iv, err := GenerateKeys()
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to generate keys: %w", err)
 	}
+	return EncryptWithKeys(plaintext, encKey, macKey, iv)
+}
+
+// EncryptWithKeys encrypts content like CreateEncryptionInfo with the given keys and IV
+// instead of random ones
+func EncryptWithKeys(plaintext, encKey, macKey, iv []byte) (*EncryptionInfo, []byte, error) {
+	return encryptWithDigest(plaintext, nil, encKey, macKey, iv, nil)
+}
+
+// encryptWithDigest encrypts content like EncryptWithKeys; the file digest is computed on
+// another core while the content is encrypted, unless it is given
+// progress receives the bytes encrypted so far and in total (optional)
+func encryptWithDigest(plaintext, fileDigest, encKey, macKey, iv []byte, progress func(done, total int64)) (*EncryptionInfo, []byte, error) {
+	digested := make(chan []byte, 1)
+	if fileDigest != nil {
+		digested <- fileDigest
+	} else {
+		go func() { digested <- CalculateFileDigest(plaintext) }()
+	}
 
-	// Encrypt content
-	encrypted, err := EncryptContent(plaintext, encKey, macKey, iv)
+	encrypted, err := encryptContent(plaintext, encKey, macKey, iv, progress)
+	fileDigest = <-digested
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to encrypt content: %w", err)
 	}
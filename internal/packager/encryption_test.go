@@ -3,6 +3,10 @@ package packager
 import (
 	"bytes"
 	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"testing"
 )
 
@@ -234,3 +238,68 @@ func TestEncryptionRoundTrip(t *testing.T) {
 		})
 	}
 }
+
+func TestEncryptContentChunks(t *testing.T) {
+	defer func(size int) { macChunkSize = size }(macChunkSize)
+	macChunkSize = 32
+
+	encKey, macKey, iv, err := GenerateKeys()
+	if err != nil {
+		t.Fatalf("GenerateKeys() error = %v", err)
+	}
+	block, _ := aes.NewCipher(encKey)
+	// Sizes around the block and chunk boundaries
+	for _, size := range []int{0, 15, 16, 31, 32, 33, 100, 4096} {
+		plaintext := make([]byte, size)
+		rand.Read(plaintext)
+		original := append([]byte(nil), plaintext...)
+
+		// Encrypted in one go, then authenticated
+		padded := PKCS7Pad(append([]byte(nil), plaintext...), aes.BlockSize)
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(padded, padded)
+		mac := hmac.New(sha256.New, macKey)
+		mac.Write(iv)
+		mac.Write(padded)
+		want := append(append(mac.Sum(nil), iv...), padded...)
+
+		got, err := EncryptContent(plaintext, encKey, macKey, iv)
+		if err != nil {
+			t.Fatalf("EncryptContent(%d bytes) error = %v", size, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("EncryptContent(%d bytes) differs from one-shot encryption", size)
+		}
+		if !bytes.Equal(plaintext, original) {
+			t.Errorf("EncryptContent(%d bytes) modified the plaintext", size)
+		}
+	}
+}
+
+func TestEncryptWithKeysDigest(t *testing.T) {
+	encKey, macKey, iv, err := GenerateKeys()
+	if err != nil {
+		t.Fatalf("GenerateKeys() error = %v", err)
+	}
+	plaintext := bytes.Repeat([]byte("content"), 1000)
+
+	info, encrypted, err := EncryptWithKeys(plaintext, encKey, macKey, iv)
+	if err != nil {
+		t.Fatalf("EncryptWithKeys() error = %v", err)
+	}
+	if !bytes.Equal(info.FileDigest, CalculateFileDigest(plaintext)) {
+		t.Error("FileDigest is not the SHA256 of the plaintext")
+	}
+	if !bytes.Equal(info.Mac, encrypted[:32]) {
+		t.Error("Mac is not the HMAC of the encrypted content")
+	}
+
+	// A digest computed before, e.g. for seeded keys, is used as is
+	given := bytes.Repeat([]byte{1}, 32)
+	info, _, err = encryptWithDigest(plaintext, given, encKey, macKey, iv, nil)
+	if err != nil {
+		t.Fatalf("encryptWithDigest() error = %v", err)
+	}
+	if !bytes.Equal(info.FileDigest, given) {
+		t.Error("encryptWithDigest() did not keep the given digest")
+	}
+}
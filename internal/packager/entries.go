@@ -0,0 +1,37 @@
+package packager
+
+import (
+	"archive/zip"
+	"strings"
+)
+
+// IsPackageEntry reports whether name, the name of an entry of a package ZIP, is the entry
+// at path, e.g. DetectionXMLPath. Names are compared without regard to case, with backslash
+// separators and a leading "./" or "/" accepted, so packages of other tools and versions
+// of IntuneWinAppUtil are read as well as those written here
+func IsPackageEntry(name, path string) bool {
+	return strings.EqualFold(normalizeEntryName(name), path)
+}
+
+// FindPackageEntry returns the entry at path of a package ZIP (see IsPackageEntry), or nil
+func FindPackageEntry(r *zip.Reader, path string) *zip.File {
+	for _, f := range r.File {
+		if IsPackageEntry(f.Name, path) {
+			return f
+		}
+	}
+	return nil
+}
+
+// normalizeEntryName converts the separators of a ZIP entry name to forward slashes and
+// removes a leading "./" or "/"
+func normalizeEntryName(name string) string {
+	name = strings.ReplaceAll(name, `\`, "/")
+	for {
+		trimmed := strings.TrimPrefix(strings.TrimPrefix(name, "./"), "/")
+		if trimmed == name {
+			return name
+		}
+		name = trimmed
+	}
+}
@@ -0,0 +1,158 @@
+package packager
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIsPackageEntry(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{DetectionXMLPath, true},
+		{"intunewinpackage/metadata/detection.xml", true},
+		{`IntuneWinPackage\Metadata\Detection.xml`, true},
+		{"./IntuneWinPackage/Metadata/Detection.xml", true},
+		{"/INTUNEWINPACKAGE/METADATA/DETECTION.XML", true},
+		{"IntuneWinPackage/Metadata/Detection.xml.bak", false},
+		{"Other/IntuneWinPackage/Metadata/Detection.xml", false},
+	}
+	for _, tc := range tests {
+		if got := IsPackageEntry(tc.name, DetectionXMLPath); got != tc.want {
+			t.Errorf("IsPackageEntry(%q) = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseDetectionXMLEncodings(t *testing.T) {
+	encInfo, _, err := CreateEncryptionInfo([]byte("content"))
+	if err != nil {
+		t.Fatalf("CreateEncryptionInfo() error = %v", err)
+	}
+	detectionXML, err := GenerateDetectionXML(&MetadataParams{Name: "Contoso Ä", SetupFile: "setup.exe", UnencryptedContentSize: 7, EncryptionInfo: encInfo})
+	if err != nil {
+		t.Fatalf("GenerateDetectionXML() error = %v", err)
+	}
+	declared := func(encoding string) []byte {
+		return append([]byte(`<?xml version="1.0" encoding="`+encoding+`"?>`+"\r\n"), detectionXML...)
+	}
+
+	variants := map[string][]byte{
+		"plain":          detectionXML,
+		"declaration":    declared("utf-8"),
+		"utf-8 bom":      append(append([]byte{}, bomUTF8...), declared("utf-8")...),
+		"utf-16le bom":   append(append([]byte{}, bomUTF16LE...), encodeUTF16(string(declared("utf-16")), binary.LittleEndian)...),
+		"utf-16be bom":   append(append([]byte{}, bomUTF16BE...), encodeUTF16(string(detectionXML), binary.BigEndian)...),
+		"unicode label":  append(append([]byte{}, bomUTF16LE...), encodeUTF16(string(declared("Unicode")), binary.LittleEndian)...),
+		"bom, no header": append(append([]byte{}, bomUTF8...), detectionXML...),
+	}
+	for name, data := range variants {
+		appInfo, err := ParseDetectionXML(data)
+		if err != nil {
+			t.Errorf("%s: ParseDetectionXML() error = %v", name, err)
+			continue
+		}
+		if appInfo.Name != "Contoso Ä" || appInfo.EncryptionInfo.EncryptionKey != base64.StdEncoding.EncodeToString(encInfo.EncryptionKey) {
+			t.Errorf("%s: ParseDetectionXML() = %+v", name, appInfo)
+		}
+	}
+
+	if _, err := ParseDetectionXML(declared("windows-1252")); err == nil {
+		t.Error("ParseDetectionXML() of an unsupported encoding should fail")
+	}
+}
+
+// TestForeignPackageLayout reads a package in the layout of other tools: lowercase folders
+// with backslash separators, a UTF-16 Detection.xml with declaration and content folders
+// separated with backslashes
+func TestForeignPackageLayout(t *testing.T) {
+	content := new(bytes.Buffer)
+	cw := zip.NewWriter(content)
+	for name, data := range map[string]string{"setup.exe": "installer", `files\app.dll`: "library"} {
+		fw, _ := cw.Create(name)
+		fw.Write([]byte(data))
+	}
+	cw.Close()
+
+	encInfo, encrypted, err := CreateEncryptionInfo(content.Bytes())
+	if err != nil {
+		t.Fatalf("CreateEncryptionInfo() error = %v", err)
+	}
+	detectionXML, err := GenerateDetectionXML(&MetadataParams{Name: "Foreign", SetupFile: "setup.exe", UnencryptedContentSize: int64(content.Len()), EncryptionInfo: encInfo})
+	if err != nil {
+		t.Fatalf("GenerateDetectionXML() error = %v", err)
+	}
+	text := "<?xml version=\"1.0\" encoding=\"utf-16\"?>\r\n" + string(detectionXML)
+	detectionXML = append(append([]byte{}, bomUTF16LE...), encodeUTF16(text, binary.LittleEndian)...)
+
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for _, entry := range []struct {
+		name string
+		data []byte
+	}{
+		{`intunewinpackage\contents\IntunePackage.intunewin`, encrypted},
+		{`intunewinpackage\metadata\detection.xml`, detectionXML},
+	} {
+		fw, _ := w.CreateHeader(&zip.FileHeader{Name: entry.name, Method: zip.Store, Modified: time.Now()})
+		fw.Write(entry.data)
+	}
+	w.Close()
+	packagePath := filepath.Join(t.TempDir(), "foreign.intunewin")
+	os.WriteFile(packagePath, buf.Bytes(), 0644)
+
+	appInfo, err := ReadDetectionXML(packagePath)
+	if err != nil {
+		t.Fatalf("ReadDetectionXML() error = %v", err)
+	}
+	if appInfo.Name != "Foreign" {
+		t.Errorf("Name = %s, want Foreign", appInfo.Name)
+	}
+	data, err := ReadEncryptedContent(packagePath)
+	if err != nil {
+		t.Fatalf("ReadEncryptedContent() error = %v", err)
+	}
+	dest := t.TempDir()
+	if count, err := RestoreContent(data, appInfo.EncryptionInfo, dest); err != nil || count != 2 {
+		t.Fatalf("RestoreContent() = %d, %v, want 2 files", count, err)
+	}
+	if got, err := os.ReadFile(filepath.Join(dest, "files", "app.dll")); err != nil || string(got) != "library" {
+		t.Errorf("files/app.dll = %q, %v, want library", got, err)
+	}
+
+	report, err := VerifyPackage(packagePath, true)
+	if err != nil {
+		t.Fatalf("VerifyPackage() error = %v", err)
+	}
+	if report.FileCount != 2 {
+		t.Errorf("FileCount = %d, want 2", report.FileCount)
+	}
+	found := map[string]bool{}
+	for _, d := range report.Divergences {
+		found[d.Check] = true
+	}
+	for _, check := range []string{"entry-order", "encoding", "xml-declaration"} {
+		if !found[check] {
+			t.Errorf("Divergence %s not reported (got %v)", check, found)
+		}
+	}
+
+	// An edit keeps the entry names and writes Detection.xml in the layout of IntuneWinAppUtil
+	edited := filepath.Join(t.TempDir(), "edited.intunewin")
+	if err := EditMetadata(packagePath, edited, MetadataEdit{Name: "Edited"}); err != nil {
+		t.Fatalf("EditMetadata() error = %v", err)
+	}
+	if appInfo, err := ReadDetectionXML(edited); err != nil || appInfo.Name != "Edited" {
+		t.Errorf("ReadDetectionXML() of the edit = %+v, %v", appInfo, err)
+	}
+	if _, err := VerifyPackage(edited, false); err != nil {
+		t.Errorf("VerifyPackage() of the edit error = %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package packager
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ValidateExcludePatterns checks that all exclusion patterns are valid globs
+func ValidateExcludePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// IsExcluded reports whether a path relative to the source folder matches an exclusion pattern
+// Patterns containing a slash are matched against the whole relative path (e.g., "docs/*.pdf"),
+// other patterns against each path element (e.g., "*.log" or ".git")
+func IsExcluded(relPath string, patterns []string) bool {
+	relPath = filepath.ToSlash(relPath)
+	elements := strings.Split(relPath, "/")
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(filepath.ToSlash(pattern), "/")
+		if strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, relPath); ok {
+				return true
+			}
+			continue
+		}
+		for _, element := range elements {
+			if ok, _ := path.Match(pattern, element); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sourceStats returns the total size and number of files in the source folder, skipping
+// excluded paths, and the links left out under the symlink policy
+func sourceStats(sourcePath string, exclude []string, symlinks SymlinkPolicy) (int64, int, []SkippedLink, error) {
+	var size int64
+	var count int
+	skipped, err := walkSource(longPath(sourcePath), exclude, symlinks, func(_, _ string, info os.FileInfo) error {
+		if !info.IsDir() {
+			size += info.Size()
+			count++
+		}
+		return nil
+	})
+	return size, count, skipped, err
+}
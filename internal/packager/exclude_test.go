@@ -0,0 +1,102 @@
+package packager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsExcluded(t *testing.T) {
+	patterns := []string{"*.log", ".git", "docs/*.pdf"}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"setup.exe", false},
+		{"install.log", true},
+		{"logs/old/install.log", true},
+		{".git", true},
+		{".git/config", true},
+		{"docs/manual.pdf", true},
+		{"docs/readme.txt", false},
+		{"other/docs/manual.pdf", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := IsExcluded(tt.path, patterns); got != tt.want {
+				t.Errorf("IsExcluded(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateExcludePatterns(t *testing.T) {
+	if err := ValidateExcludePatterns([]string{"*.log", "docs/*"}); err != nil {
+		t.Errorf("ValidateExcludePatterns() error = %v", err)
+	}
+	if err := ValidateExcludePatterns([]string{"[unclosed"}); err == nil {
+		t.Error("Expected error for invalid pattern")
+	}
+}
+
+func TestPackageWithExclude(t *testing.T) {
+	sourceDir, err := os.MkdirTemp("", "source")
+	if err != nil {
+		t.Fatalf("Failed to create source dir: %v", err)
+	}
+	defer os.RemoveAll(sourceDir)
+
+	outputDir, err := os.MkdirTemp("", "output")
+	if err != nil {
+		t.Fatalf("Failed to create output dir: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	restoreDir, err := os.MkdirTemp("", "restore")
+	if err != nil {
+		t.Fatalf("Failed to create restore dir: %v", err)
+	}
+	defer os.RemoveAll(restoreDir)
+
+	files := map[string]string{
+		"setup.exe":       "installer",
+		"install.log":     "log",
+		".git/HEAD":       "ref",
+		"data/config.ini": "[settings]",
+	}
+	writeTree(t, sourceDir, files)
+
+	result, err := PackageWithOptions(sourceDir, "setup.exe", outputDir, Options{Exclude: []string{"*.log", ".git"}}, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	if result.FileCount != 2 {
+		t.Errorf("FileCount = %d, want 2", result.FileCount)
+	}
+
+	appInfo, err := ReadDetectionXML(result.OutputPath)
+	if err != nil {
+		t.Fatalf("ReadDetectionXML() error = %v", err)
+	}
+	encrypted, err := ReadEncryptedContent(result.OutputPath)
+	if err != nil {
+		t.Fatalf("ReadEncryptedContent() error = %v", err)
+	}
+	if _, err := RestoreContent(encrypted, appInfo.EncryptionInfo, restoreDir); err != nil {
+		t.Fatalf("RestoreContent() error = %v", err)
+	}
+
+	for _, excluded := range []string{"install.log", ".git"} {
+		if _, err := os.Stat(filepath.Join(restoreDir, excluded)); err == nil {
+			t.Errorf("%s was packaged but is excluded", excluded)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(restoreDir, "data", "config.ini")); err != nil {
+		t.Errorf("data/config.ini missing from package: %v", err)
+	}
+
+	if _, err := PackageWithOptions(sourceDir, "setup.exe", outputDir, Options{Exclude: []string{"*.exe"}}, nil); err == nil {
+		t.Error("Expected error when the setup file is excluded")
+	}
+}
@@ -0,0 +1,138 @@
+package packager
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RestoreContent decrypts encrypted package content with the keys from
+// Detection.xml, verifies its digest and extracts the files into destDir
+// Returns the number of files restored
+func RestoreContent(encrypted []byte, encXML EncryptionXML, destDir string) (int, error) {
+	plaintext, err := DecryptPackageContent(encrypted, encXML)
+	if err != nil {
+		return 0, err
+	}
+	return ExtractZip(plaintext, destDir)
+}
+
+// DecryptPackageContent decrypts encrypted package content with the keys from
+// Detection.xml and verifies its digest, returning the content ZIP
+func DecryptPackageContent(encrypted []byte, encXML EncryptionXML) ([]byte, error) {
+	info, err := encXML.Decode()
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption info: %w", err)
+	}
+
+	plaintext, err := DecryptContent(encrypted, info.EncryptionKey, info.MacKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt content: %w", err)
+	}
+
+	if !bytes.Equal(CalculateFileDigest(plaintext), info.FileDigest) {
+		return nil, fmt.Errorf("file digest mismatch: content does not match Detection.xml")
+	}
+	return plaintext, nil
+}
+
+// ExtractZip extracts an in-memory ZIP archive into destDir
+// Entries that would escape destDir are rejected
+// Returns the number of files extracted
+func ExtractZip(data []byte, destDir string) (int, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to open ZIP: %w", err)
+	}
+
+	absDest, err := filepath.Abs(destDir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	absDest = longPath(absDest)
+
+	var count int
+	for _, f := range reader.File {
+		// Content written with .NET Framework may separate folders with backslashes
+		name := normalizeEntryName(f.Name)
+		target := filepath.Join(absDest, filepath.FromSlash(name))
+		if target != absDest && !strings.HasPrefix(target, absDest+string(os.PathSeparator)) {
+			return count, fmt.Errorf("illegal path in ZIP: %s", f.Name)
+		}
+
+		if f.FileInfo().IsDir() || strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return count, fmt.Errorf("failed to create directory: %w", err)
+			}
+			continue
+		}
+
+		if err := extractZipFile(f, target); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// extractZipFile writes a single ZIP entry to target, creating parent directories
+func extractZipFile(f *zip.File, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, extractMode(f))
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", target, err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, rc); err != nil {
+		return fmt.Errorf("failed to extract %s: %w", f.Name, err)
+	}
+
+	if !f.Modified.IsZero() {
+		os.Chtimes(target, f.Modified, f.Modified)
+	}
+	return nil
+}
+
+// ReadEncryptedContent reads the encrypted IntunePackage.intunewin from a .intunewin package
+func ReadEncryptedContent(packagePath string) ([]byte, error) {
+	reader, err := zip.OpenReader(packagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open package: %w", err)
+	}
+	defer reader.Close()
+
+	for _, f := range reader.File {
+		if !IsPackageEntry(f.Name, EncryptedContentPath) {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open encrypted content: %w", err)
+		}
+		defer rc.Close()
+
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encrypted content: %w", err)
+		}
+		return data, nil
+	}
+
+	return nil, fmt.Errorf("encrypted content not found in package: %s", packagePath)
+}
@@ -0,0 +1,99 @@
+package packager
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRestoreContentRoundTrip(t *testing.T) {
+	sourceDir, err := os.MkdirTemp("", "source")
+	if err != nil {
+		t.Fatalf("Failed to create source dir: %v", err)
+	}
+	defer os.RemoveAll(sourceDir)
+
+	outputDir, err := os.MkdirTemp("", "output")
+	if err != nil {
+		t.Fatalf("Failed to create output dir: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	restoreDir, err := os.MkdirTemp("", "restore")
+	if err != nil {
+		t.Fatalf("Failed to create restore dir: %v", err)
+	}
+	defer os.RemoveAll(restoreDir)
+
+	if err := os.WriteFile(filepath.Join(sourceDir, "setup.exe"), []byte("installer"), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(sourceDir, "data"), 0755); err != nil {
+		t.Fatalf("Failed to create subdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "data", "config.ini"), []byte("[settings]"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	result, err := Package(sourceDir, "setup.exe", outputDir, nil)
+	if err != nil {
+		t.Fatalf("Package() error = %v", err)
+	}
+
+	appInfo, err := ReadDetectionXML(result.OutputPath)
+	if err != nil {
+		t.Fatalf("ReadDetectionXML() error = %v", err)
+	}
+	encrypted, err := ReadEncryptedContent(result.OutputPath)
+	if err != nil {
+		t.Fatalf("ReadEncryptedContent() error = %v", err)
+	}
+
+	count, err := RestoreContent(encrypted, appInfo.EncryptionInfo, restoreDir)
+	if err != nil {
+		t.Fatalf("RestoreContent() error = %v", err)
+	}
+	if count != 2 {
+		t.Errorf("RestoreContent() = %d files, want 2", count)
+	}
+
+	data, err := os.ReadFile(filepath.Join(restoreDir, "data", "config.ini"))
+	if err != nil {
+		t.Fatalf("Failed to read restored file: %v", err)
+	}
+	if string(data) != "[settings]" {
+		t.Errorf("Restored content = %q, want [settings]", data)
+	}
+
+	// Tampered content must fail HMAC verification
+	encrypted[len(encrypted)-1] ^= 0xff
+	if _, err := RestoreContent(encrypted, appInfo.EncryptionInfo, restoreDir); err == nil {
+		t.Error("Expected error for tampered content")
+	}
+}
+
+func TestExtractZipRejectsTraversal(t *testing.T) {
+	destDir, err := os.MkdirTemp("", "dest")
+	if err != nil {
+		t.Fatalf("Failed to create dest dir: %v", err)
+	}
+	defer os.RemoveAll(destDir)
+
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	w, err := zw.Create("../escape.txt")
+	if err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	w.Write([]byte("x"))
+	zw.Close()
+
+	if _, err := ExtractZip(buf.Bytes(), destDir); err == nil {
+		t.Error("Expected error for path traversal entry")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(destDir), "escape.txt")); err == nil {
+		t.Error("File was written outside destination")
+	}
+}
@@ -0,0 +1,274 @@
+package packager
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"debug/pe"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// FootprintFile is a single file of an install footprint
+type FootprintFile struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	SHA256  string `json:"sha256"`
+	Version string `json:"version,omitempty"`
+}
+
+// FootprintChange is a file that exists in both footprints with different content
+type FootprintChange struct {
+	Path       string `json:"path"`
+	OldSize    int64  `json:"oldSize"`
+	NewSize    int64  `json:"newSize"`
+	OldVersion string `json:"oldVersion,omitempty"`
+	NewVersion string `json:"newVersion,omitempty"`
+}
+
+// FootprintDiff lists what changed on disk between two versions of an app
+type FootprintDiff struct {
+	Added     []FootprintFile   `json:"added"`
+	Removed   []FootprintFile   `json:"removed"`
+	Updated   []FootprintChange `json:"updated"`
+	Unchanged int               `json:"unchanged"`
+}
+
+// Empty reports whether the footprints are identical
+func (d *FootprintDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Updated) == 0
+}
+
+// versionedExtensions are the file types whose version resource is read
+var versionedExtensions = map[string]bool{
+	".exe": true,
+	".dll": true,
+	".sys": true,
+	".ocx": true,
+	".cpl": true,
+	".drv": true,
+	".msi": true,
+}
+
+// ScanFootprint hashes every file below dir, such as an MSI extracted with
+// msiexec /a, and reads the file version of executables, libraries and MSIs
+// Unchanged files take their hash from cache (optional)
+// Paths are relative to dir with forward slashes, sorted
+func ScanFootprint(dir string, cache *HashCache) ([]FootprintFile, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot access folder: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("not a folder: %s", dir)
+	}
+
+	var files []FootprintFile
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		sum, err := cache.FileSHA256(path)
+		if err != nil {
+			return err
+		}
+		files = append(files, FootprintFile{
+			Path:    filepath.ToSlash(relPath),
+			Size:    info.Size(),
+			SHA256:  hex.EncodeToString(sum),
+			Version: FileVersion(path),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// PackageFootprint decrypts a .intunewin package with the keys from its Detection.xml
+// and lists the files of its content, sorted by path like ScanFootprint
+func PackageFootprint(packagePath string) ([]FootprintFile, error) {
+	appInfo, err := ReadDetectionXML(packagePath)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := ReadEncryptedContent(packagePath)
+	if err != nil {
+		return nil, err
+	}
+	zipData, err := DecryptPackageContent(encrypted, appInfo.EncryptionInfo)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(packagePath), err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open content ZIP: %w", err)
+	}
+
+	var files []FootprintFile
+	for _, f := range reader.File {
+		// Packages built by other tools may separate folders with backslashes
+		name := normalizeEntryName(f.Name)
+		if strings.HasSuffix(name, "/") {
+			continue
+		}
+		data, err := readZipFile(f)
+		if err != nil {
+			return nil, err
+		}
+		digest := sha256.Sum256(data)
+		files = append(files, FootprintFile{
+			Path:    name,
+			Size:    int64(len(data)),
+			SHA256:  hex.EncodeToString(digest[:]),
+			Version: readFileVersion(name, bytes.NewReader(data)),
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// readZipFile reads the content of a ZIP entry
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	return data, nil
+}
+
+// CompareFootprints reports the files added, removed and updated from the old to the new footprint
+// Paths are compared case-insensitively, as Windows does
+func CompareFootprints(oldFiles, newFiles []FootprintFile) *FootprintDiff {
+	oldByPath := make(map[string]FootprintFile, len(oldFiles))
+	for _, f := range oldFiles {
+		oldByPath[strings.ToLower(f.Path)] = f
+	}
+
+	diff := &FootprintDiff{}
+	seen := make(map[string]bool, len(newFiles))
+	for _, f := range newFiles {
+		key := strings.ToLower(f.Path)
+		seen[key] = true
+		before, ok := oldByPath[key]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, f)
+		case before.SHA256 != f.SHA256:
+			diff.Updated = append(diff.Updated, FootprintChange{
+				Path:       f.Path,
+				OldSize:    before.Size,
+				NewSize:    f.Size,
+				OldVersion: before.Version,
+				NewVersion: f.Version,
+			})
+		default:
+			diff.Unchanged++
+		}
+	}
+	for _, f := range oldFiles {
+		if !seen[strings.ToLower(f.Path)] {
+			diff.Removed = append(diff.Removed, f)
+		}
+	}
+	return diff
+}
+
+// FileVersion returns the file version of a PE file or the ProductVersion of an MSI
+// It returns "" for other files and for files without version information
+func FileVersion(path string) string {
+	if !versionedExtensions[strings.ToLower(filepath.Ext(path))] {
+		return ""
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	return readFileVersion(path, f)
+}
+
+// readFileVersion returns the version of the file called name from its content
+func readFileVersion(name string, r msiSource) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if !versionedExtensions[ext] {
+		return ""
+	}
+	if ext == ".msi" {
+		info, err := readMsiInfo(r)
+		if err != nil {
+			return ""
+		}
+		return info.ProductVersion
+	}
+
+	f, err := pe.NewFile(r)
+	if err != nil {
+		return ""
+	}
+	section := f.Section(".rsrc")
+	if section == nil {
+		return ""
+	}
+	data, err := section.Data()
+	if err != nil {
+		return ""
+	}
+	return peFixedFileVersion(data)
+}
+
+// vsFixedFileInfoSignature starts the VS_FIXEDFILEINFO structure of a version resource
+const vsFixedFileInfoSignature = 0xFEEF04BD
+
+// peFixedFileVersion finds the VS_VERSION_INFO resource in resource section data and
+// returns the file version of its VS_FIXEDFILEINFO as a.b.c.d
+func peFixedFileVersion(rsrc []byte) string {
+	var key bytes.Buffer
+	for _, c := range utf16.Encode([]rune("VS_VERSION_INFO\x00")) {
+		binary.Write(&key, binary.LittleEndian, c)
+	}
+
+	for start := 0; ; {
+		i := bytes.Index(rsrc[start:], key.Bytes())
+		if i < 0 {
+			return ""
+		}
+		pos := start + i + key.Len()
+		start = pos
+
+		// The fixed file info is aligned to 32 bits after the key
+		pos = (pos + 3) &^ 3
+		if pos+16 > len(rsrc) || binary.LittleEndian.Uint32(rsrc[pos:]) != vsFixedFileInfoSignature {
+			continue
+		}
+		ms := binary.LittleEndian.Uint32(rsrc[pos+8:])
+		ls := binary.LittleEndian.Uint32(rsrc[pos+12:])
+		return fmt.Sprintf("%d.%d.%d.%d", ms>>16, ms&0xFFFF, ls>>16, ls&0xFFFF)
+	}
+}
@@ -0,0 +1,186 @@
+package packager
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+)
+
+// versionedPE returns a PE32 image whose .rsrc section holds a VS_VERSION_INFO
+// resource with the given file version
+func versionedPE(major, minor, build, revision uint16) []byte {
+	var rsrc bytes.Buffer
+	binary.Write(&rsrc, binary.LittleEndian, []uint16{0, 52, 0}) // wLength, wValueLength, wType
+	binary.Write(&rsrc, binary.LittleEndian, utf16.Encode([]rune("VS_VERSION_INFO\x00")))
+	for rsrc.Len()%4 != 0 {
+		rsrc.WriteByte(0)
+	}
+	binary.Write(&rsrc, binary.LittleEndian, []uint32{
+		vsFixedFileInfoSignature, 0x10000,
+		uint32(major)<<16 | uint32(minor), uint32(build)<<16 | uint32(revision),
+	})
+	rsrc.Write(make([]byte, 36))
+	return rsrcPE(rsrc.Bytes())
+}
+
+// rsrcPE returns a PE32 image with a single .rsrc section holding rsrc at virtual address 0x1000
+func rsrcPE(rsrc []byte) []byte {
+	const (
+		peOffset     = 0x40
+		optionalSize = 224
+		rsrcOffset   = 0x200
+	)
+
+	data := make([]byte, rsrcOffset+len(rsrc))
+	copy(data, "MZ")
+	binary.LittleEndian.PutUint32(data[peSignatureOffset:], peOffset)
+	copy(data[peOffset:], "PE\x00\x00")
+
+	coff := peOffset + 4
+	binary.LittleEndian.PutUint16(data[coff:], 0x14c)           // Machine: i386
+	binary.LittleEndian.PutUint16(data[coff+2:], 1)             // NumberOfSections
+	binary.LittleEndian.PutUint16(data[coff+16:], optionalSize) // SizeOfOptionalHeader
+
+	optional := coff + peCoffHeaderSize
+	binary.LittleEndian.PutUint16(data[optional:], 0x10b)
+	binary.LittleEndian.PutUint32(data[optional+pe32DataDirOffset-4:], 16) // NumberOfRvaAndSizes
+
+	section := optional + optionalSize
+	copy(data[section:], ".rsrc")
+	binary.LittleEndian.PutUint32(data[section+8:], uint32(len(rsrc)))  // VirtualSize
+	binary.LittleEndian.PutUint32(data[section+12:], 0x1000)            // VirtualAddress
+	binary.LittleEndian.PutUint32(data[section+16:], uint32(len(rsrc))) // SizeOfRawData
+	binary.LittleEndian.PutUint32(data[section+20:], rsrcOffset)        // PointerToRawData
+
+	copy(data[rsrcOffset:], rsrc)
+	return data
+}
+
+func TestFileVersion(t *testing.T) {
+	dir := t.TempDir()
+	dll := filepath.Join(dir, "core.dll")
+	if err := os.WriteFile(dll, versionedPE(14, 38, 33130, 0), 0644); err != nil {
+		t.Fatalf("Failed to write DLL: %v", err)
+	}
+	if got := FileVersion(dll); got != "14.38.33130.0" {
+		t.Errorf("FileVersion() = %q, want %q", got, "14.38.33130.0")
+	}
+
+	// Text files and PE files without a version resource have no version
+	txt := filepath.Join(dir, "readme.txt")
+	if err := os.WriteFile(txt, versionedPE(1, 0, 0, 0), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if got := FileVersion(txt); got != "" {
+		t.Errorf("FileVersion(readme.txt) = %q, want empty", got)
+	}
+	exe := filepath.Join(dir, "setup.exe")
+	if err := os.WriteFile(exe, minimalPE(), 0644); err != nil {
+		t.Fatalf("Failed to write EXE: %v", err)
+	}
+	if got := FileVersion(exe); got != "" {
+		t.Errorf("FileVersion(setup.exe) = %q, want empty", got)
+	}
+}
+
+func TestCompareFootprints(t *testing.T) {
+	oldDir := t.TempDir()
+	newDir := t.TempDir()
+
+	write := func(dir, name string, data []byte) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	write(oldDir, "App/app.dll", versionedPE(1, 2, 0, 0))
+	write(oldDir, "App/license.txt", []byte("license"))
+	write(oldDir, "App/legacy.ini", []byte("[old]"))
+	write(newDir, "app/APP.dll", versionedPE(1, 3, 0, 0))
+	write(newDir, "App/license.txt", []byte("license"))
+	write(newDir, "App/plugins/pdf.dll", versionedPE(1, 0, 0, 0))
+
+	oldFiles, err := ScanFootprint(oldDir, nil)
+	if err != nil {
+		t.Fatalf("ScanFootprint(old) error = %v", err)
+	}
+	newFiles, err := ScanFootprint(newDir, nil)
+	if err != nil {
+		t.Fatalf("ScanFootprint(new) error = %v", err)
+	}
+
+	diff := CompareFootprints(oldFiles, newFiles)
+	if diff.Empty() {
+		t.Fatal("Expected differences")
+	}
+	if len(diff.Added) != 1 || diff.Added[0].Path != "App/plugins/pdf.dll" || diff.Added[0].Version != "1.0.0.0" {
+		t.Errorf("Added = %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Path != "App/legacy.ini" {
+		t.Errorf("Removed = %+v", diff.Removed)
+	}
+	if len(diff.Updated) != 1 {
+		t.Fatalf("Updated = %+v, want the DLL", diff.Updated)
+	}
+	if u := diff.Updated[0]; u.Path != "app/APP.dll" || u.OldVersion != "1.2.0.0" || u.NewVersion != "1.3.0.0" {
+		t.Errorf("Updated[0] = %+v", u)
+	}
+	if diff.Unchanged != 1 {
+		t.Errorf("Unchanged = %d, want 1", diff.Unchanged)
+	}
+
+	if !CompareFootprints(oldFiles, oldFiles).Empty() {
+		t.Error("Comparing a footprint with itself reported differences")
+	}
+}
+
+func TestPackageFootprint(t *testing.T) {
+	sourceDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "setup.exe"), versionedPE(2, 5, 0, 1), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(sourceDir, "data"), 0755); err != nil {
+		t.Fatalf("Failed to create subdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "data", "config.ini"), []byte("[settings]"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	first, err := Package(sourceDir, "setup.exe", t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Package() error = %v", err)
+	}
+	second, err := Package(sourceDir, "setup.exe", t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Package() error = %v", err)
+	}
+
+	files, err := PackageFootprint(first.OutputPath)
+	if err != nil {
+		t.Fatalf("PackageFootprint() error = %v", err)
+	}
+	if len(files) != 2 || files[0].Path != "data/config.ini" || files[1].Path != "setup.exe" {
+		t.Fatalf("PackageFootprint() = %+v, want data/config.ini and setup.exe", files)
+	}
+	if files[0].Size != int64(len("[settings]")) {
+		t.Errorf("config.ini size = %d", files[0].Size)
+	}
+	if files[1].Version != "2.5.0.1" {
+		t.Errorf("setup.exe version = %q, want 2.5.0.1", files[1].Version)
+	}
+
+	// Packages of the same files have different keys but the same content
+	again, err := PackageFootprint(second.OutputPath)
+	if err != nil {
+		t.Fatalf("PackageFootprint() error = %v", err)
+	}
+	if diff := CompareFootprints(files, again); !diff.Empty() || diff.Unchanged != 2 {
+		t.Errorf("Repackaged content differs: %+v", diff)
+	}
+}
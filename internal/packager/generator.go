@@ -0,0 +1,108 @@
+package packager
+
+import (
+	"fmt"
+	"io"
+)
+
+// GeneratorVersion identifies the logic that produces package bytes: the content ZIP
+// layout, compression, encryption and Detection.xml rendering
+// It is independent of the application version: bump it, and add a FormatHistory entry,
+// whenever the same input would produce differently structured output
+const GeneratorVersion = 4
+
+// FormatChange describes what changed in a generator version
+type FormatChange struct {
+	Version     int    `json:"version"`
+	Description string `json:"description"`
+}
+
+// FormatHistory lists the generator versions, oldest first; the last one is GeneratorVersion
+var FormatHistory = []FormatChange{
+	{1, "Versioned baseline: IntuneWinAppUtil 1.8.6 compatible layout, Deflate content ZIP, AES-256-CBC with HMAC-SHA256, CRLF Detection.xml without declaration"},
+	{2, "MSI suites: " + SuiteScriptName + " chaining the language packs and add-ons of an MSI setup file is added to the content ZIP"},
+	{3, "Entry attributes: folder entries carry their mode and time, Windows hidden, system and archive attributes are stored, and --normalize-permissions stores 0755/0644 modes"},
+	{4, "Compression strategies: files already compressed (archives, media, high-entropy content) are stored instead of deflated, and --compression selects store, fast, default or best"},
+}
+
+// ExplainFormat writes a description of the bytes and structures the current generator
+// produces, so a given archive can be traced back to the logic that built it
+func ExplainFormat(w io.Writer) error {
+	_, err := fmt.Fprintf(w, `Package format, generator version %d
+
+Generator versions identify the package generation logic, independently of the
+application version. Manifests (--manifest), checkpoints, the packaging history
+and webhook events record the version a package was built with.
+
+1. Content ZIP (the plaintext)
+   - A ZIP of the source folder, in the lexical order of a directory walk.
+   - Entry names are relative to the source folder, with forward slashes;
+     folders are stored as entries ending in "/".
+   - Files are compressed with Deflate at level 5 (1 with --compression fast,
+     9 with best); excluded paths (--exclude) are left out.
+   - Files already compressed are stored: archives, packages and media by
+     extension, and files of 256 KB or more whose samples at a quarter, half
+     and three quarters have an entropy of 7.9 bits per byte or more. With
+     --compression store every file is stored.
+   - Entries carry the Unix mode of the source in the high 16 bits of their
+     external attributes, and the MS-DOS read-only, hidden, system, folder
+     and archive attributes in the low byte.
+   - With --normalize-permissions, folders and executables (an executable
+     bit, a script extension or a #! line) get mode 0755 and other files 0644,
+     without Windows attributes.
+   - With --reproducible, every entry gets the same modification time
+     (%s, at least 1980-01-01) and mode 0644 (0755 for folders), unless
+     --normalize-permissions is given.
+   - With --content-store, files of %s or more reuse compressed data of earlier
+     runs; the raw Deflate stream is identical to compressing them again.
+   - When MSIs next to an MSI setup file belong with it (same UpgradeCode, or
+     a product or file name extending its own), %s is added after
+     the source files to install them in order (unless --no-msi-suite).
+
+2. Encrypted content
+   - Layout: [HMAC-SHA256, 32 bytes][IV, 16 bytes][AES-256-CBC ciphertext]
+   - The content ZIP is padded with PKCS#7 and encrypted in CBC mode with a
+     32-byte encryption key and a 16-byte IV.
+   - The HMAC covers the IV and the ciphertext, keyed with a separate 32-byte
+     MAC key.
+   - Keys and IV are random, derived from --seed, or read from
+     --encryption-key-file.
+
+3. Detection.xml
+   - UTF-8, no XML declaration, two-space indentation, CRLF line endings.
+   - Root element ApplicationInfo with the xsd and xsi namespaces and a
+     ToolVersion attribute (default %s).
+   - Name, UnencryptedContentSize (size of the content ZIP), FileName
+     (IntunePackage.intunewin), SetupFile, then EncryptionInfo with the base64
+     EncryptionKey, MacKey, InitializationVector and Mac, ProfileIdentifier
+     (%s), FileDigest (%s of the content ZIP) and FileDigestAlgorithm.
+   - MSI setup files add MsiInfo: product, version, package and upgrade codes,
+     execution context and install flags read from the MSI tables.
+
+4. Package (.intunewin)
+   - A ZIP with two entries, both stored without compression, in this order:
+       %s
+       %s
+   - Both entries carry the packaging time (the fixed time with --reproducible).
+   - No folder entries, no archive comment.
+
+5. macOS package (.intunemac)
+   - The .pkg or .dmg itself is encrypted as in step 2 (no content ZIP).
+   - Same structure as step 4, under IntuneMacPackage/:
+       %s
+       %s
+
+Generator versions:
+`, GeneratorVersion, SourceDateEpochEnv, FormatSize(ContentStoreMinSize), SuiteScriptName, ToolVersion,
+		ProfileIdentifier, FileDigestAlgorithm, EncryptedContentPath, DetectionXMLPath,
+		MacEncryptedContentPath, MacDetectionXMLPath)
+	if err != nil {
+		return err
+	}
+	for _, change := range FormatHistory {
+		if _, err := fmt.Fprintf(w, "  %d  %s\n", change.Version, change.Description); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package packager
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFormatHistoryEndsAtGeneratorVersion(t *testing.T) {
+	for i, change := range FormatHistory {
+		if change.Version != i+1 || change.Description == "" {
+			t.Errorf("FormatHistory[%d] = %+v, want version %d with a description", i, change, i+1)
+		}
+	}
+	if last := FormatHistory[len(FormatHistory)-1]; last.Version != GeneratorVersion {
+		t.Errorf("Last FormatHistory version = %d, want GeneratorVersion %d", last.Version, GeneratorVersion)
+	}
+}
+
+func TestExplainFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := ExplainFormat(&buf); err != nil {
+		t.Fatalf("ExplainFormat() error = %v", err)
+	}
+	text := buf.String()
+	for _, want := range []string{
+		"generator version 4",
+		SuiteScriptName,
+		EncryptedContentPath,
+		DetectionXMLPath,
+		MacDetectionXMLPath,
+		"[HMAC-SHA256, 32 bytes][IV, 16 bytes][AES-256-CBC ciphertext]",
+		"ToolVersion attribute (default " + ToolVersion + ")",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("ExplainFormat() is missing %q", want)
+		}
+	}
+}
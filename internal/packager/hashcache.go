@@ -0,0 +1,204 @@
+package packager
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// hashCacheVersion is the format of the hash cache file; other versions are discarded
+	hashCacheVersion = 1
+	// hashCacheMaxAge drops entries not used for this long when the cache is saved
+	hashCacheMaxAge = 30 * 24 * time.Hour
+	// hashCacheSettle is how long a file must be unmodified before its hash is cached, so
+	// a write within the timestamp resolution of the file system is never missed
+	hashCacheSettle = 2 * time.Second
+)
+
+// HashCache remembers the SHA256 of files by path, size and modification time, so
+// repeated runs over large unchanged trees do not read every byte again
+// A nil HashCache hashes every file; all methods are safe for concurrent use
+type HashCache struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]*hashCacheEntry
+	dirty   bool
+}
+
+// hashCacheFile is the JSON layout of the hash cache file
+type hashCacheFile struct {
+	Version int                        `json:"version"`
+	Files   map[string]*hashCacheEntry `json:"files"`
+}
+
+// hashCacheEntry is the hash of a file as it was when hashed
+type hashCacheEntry struct {
+	Size int64 `json:"size"`
+	// ModTime is the modification time in Unix nanoseconds
+	ModTime int64  `json:"modTime"`
+	SHA256  string `json:"sha256"`
+	// Used is the day the entry was last looked up or stored, in Unix seconds
+	Used int64 `json:"used"`
+}
+
+// DefaultHashCachePath returns the file of the shared hash cache
+func DefaultHashCachePath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "intunewin", "hashes.json"), nil
+}
+
+// OpenHashCache loads the hash cache kept in path
+// A missing, damaged or outdated file gives an empty cache; it is rebuilt as files are hashed
+func OpenHashCache(path string) (*HashCache, error) {
+	c := &HashCache{path: path, entries: make(map[string]*hashCacheEntry)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hash cache: %w", err)
+	}
+	var file hashCacheFile
+	if json.Unmarshal(data, &file) == nil && file.Version == hashCacheVersion && file.Files != nil {
+		c.entries = file.Files
+	}
+	return c, nil
+}
+
+// Len returns the number of cached hashes
+func (c *HashCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// FileSHA256 returns the SHA256 of a file, from the cache when the file has the size and
+// modification time it had when it was hashed
+func (c *HashCache) FileSHA256(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	if sum, ok := c.lookup(path, info); ok {
+		return sum, nil
+	}
+	sum, err := FileSHA256(path)
+	if err != nil {
+		return nil, err
+	}
+	// A file written to while it was hashed is not cached
+	if after, err := os.Stat(path); err == nil && after.Size() == info.Size() && after.ModTime().Equal(info.ModTime()) {
+		c.store(path, info, sum)
+	}
+	return sum, nil
+}
+
+// lookup returns the cached hash of a file with the given size and modification time
+func (c *HashCache) lookup(path string, info os.FileInfo) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	key := hashCacheKey(path)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || entry.Size != info.Size() || entry.ModTime != info.ModTime().UnixNano() {
+		return nil, false
+	}
+	sum, err := hex.DecodeString(entry.SHA256)
+	if err != nil || len(sum) != 32 {
+		return nil, false
+	}
+	// Entries are touched once a day, so a run that hashes nothing new writes nothing
+	if today := usedDay(time.Now()); entry.Used != today {
+		entry.Used = today
+		c.dirty = true
+	}
+	return sum, true
+}
+
+// store caches the hash of a file with the given size and modification time
+func (c *HashCache) store(path string, info os.FileInfo, sum []byte) {
+	if c == nil || time.Since(info.ModTime()) < hashCacheSettle {
+		return
+	}
+	key := hashCacheKey(path)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = &hashCacheEntry{
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+		SHA256:  hex.EncodeToString(sum),
+		Used:    usedDay(time.Now()),
+	}
+	c.dirty = true
+}
+
+// Save writes the cache back if it changed, dropping entries not used for 30 days
+// The file is replaced atomically; when several runs save at once, the last one wins
+func (c *HashCache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	oldest := usedDay(time.Now().Add(-hashCacheMaxAge))
+	for key, entry := range c.entries {
+		if entry.Used < oldest {
+			delete(c.entries, key)
+		}
+	}
+	data, err := json.Marshal(hashCacheFile{Version: hashCacheVersion, Files: c.entries})
+	if err != nil {
+		return fmt.Errorf("failed to encode hash cache: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("failed to create hash cache folder: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write hash cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write hash cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write hash cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("failed to write hash cache: %w", err)
+	}
+	c.dirty = false
+	return nil
+}
+
+// hashCacheKey returns the cache key of a file: its absolute path
+func hashCacheKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return normalPath(path)
+}
+
+// usedDay returns the start of the UTC day of t in Unix seconds
+func usedDay(t time.Time) int64 {
+	return t.UTC().Truncate(24 * time.Hour).Unix()
+}
@@ -0,0 +1,136 @@
+package packager
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeOldFile writes a file modified an hour ago, long enough for its hash to be cached
+func writeOldFile(t *testing.T, path string, content []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("Failed to set file time: %v", err)
+	}
+}
+
+func TestHashCache(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "cache", "hashes.json")
+	file := filepath.Join(dir, "setup.exe")
+	writeOldFile(t, file, []byte("version 1"))
+
+	cache, err := OpenHashCache(cachePath)
+	if err != nil {
+		t.Fatalf("OpenHashCache() error = %v", err)
+	}
+	sum, err := cache.FileSHA256(file)
+	want := sha256.Sum256([]byte("version 1"))
+	if err != nil || !bytes.Equal(sum, want[:]) {
+		t.Fatalf("FileSHA256() = %x, %v, want %x", sum, err, want)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	cache, err = OpenHashCache(cachePath)
+	if err != nil || cache.Len() != 1 {
+		t.Fatalf("OpenHashCache() = %d entries, %v, want 1", cache.Len(), err)
+	}
+
+	// Same size and time: the file is not read again
+	info, _ := os.Stat(file)
+	os.WriteFile(file, []byte("version 2"), 0644)
+	os.Chtimes(file, info.ModTime(), info.ModTime())
+	if sum, _ := cache.FileSHA256(file); !bytes.Equal(sum, want[:]) {
+		t.Errorf("FileSHA256() = %x, want the cached %x", sum, want)
+	}
+
+	// A new modification time invalidates the entry
+	writeOldFile(t, file, []byte("version 2"))
+	want = sha256.Sum256([]byte("version 2"))
+	if sum, _ := cache.FileSHA256(file); !bytes.Equal(sum, want[:]) {
+		t.Errorf("FileSHA256() = %x, want %x", sum, want)
+	}
+}
+
+func TestHashCacheSkipsRecentFiles(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "setup.exe")
+	if err := os.WriteFile(file, []byte("just written"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	cache, _ := OpenHashCache(filepath.Join(dir, "hashes.json"))
+	if _, err := cache.FileSHA256(file); err != nil {
+		t.Fatalf("FileSHA256() error = %v", err)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Len() = %d, want a file modified just now not cached", cache.Len())
+	}
+}
+
+func TestOpenHashCacheDamaged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hashes.json")
+	os.WriteFile(path, []byte("{not json"), 0644)
+	cache, err := OpenHashCache(path)
+	if err != nil || cache.Len() != 0 {
+		t.Errorf("OpenHashCache() = %d entries, %v, want an empty cache", cache.Len(), err)
+	}
+
+	var none *HashCache
+	file := filepath.Join(t.TempDir(), "setup.exe")
+	writeOldFile(t, file, []byte("data"))
+	if _, err := none.FileSHA256(file); err != nil {
+		t.Errorf("nil FileSHA256() error = %v", err)
+	}
+	if err := none.Save(); err != nil {
+		t.Errorf("nil Save() error = %v", err)
+	}
+}
+
+func TestContentStoreWithHashCache(t *testing.T) {
+	sourceDir := t.TempDir()
+	file := filepath.Join(sourceDir, "runtime.dll")
+	original := bytes.Repeat([]byte("runtime "), ContentStoreMinSize/8+1)
+	writeOldFile(t, file, original)
+
+	store := NewContentStore(t.TempDir())
+	cache, _ := OpenHashCache(filepath.Join(t.TempDir(), "hashes.json"))
+	opts := ZipOptions{ContentStore: store, HashCache: cache}
+	if _, err := ZipFolderWithOptions(sourceDir, opts); err != nil {
+		t.Fatalf("ZipFolderWithOptions() error = %v", err)
+	}
+
+	// Unchanged by size and time, the file is taken from the store without reading it
+	info, _ := os.Stat(file)
+	os.WriteFile(file, bytes.Repeat([]byte("RUNTIME "), ContentStoreMinSize/8+1), 0644)
+	os.Chtimes(file, info.ModTime(), info.ModTime())
+	var reused int
+	opts.Reused = func(string, int64) { reused++ }
+	data, err := ZipFolderWithOptions(sourceDir, opts)
+	if err != nil {
+		t.Fatalf("ZipFolderWithOptions() error = %v", err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Failed to open ZIP: %v", err)
+	}
+	rc, err := reader.File[0].Open()
+	if err != nil {
+		t.Fatalf("Failed to open entry: %v", err)
+	}
+	content, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || reused != 1 || !bytes.Equal(content, original) {
+		t.Errorf("reused %d files, content from the store = %v (%v)", reused, bytes.Equal(content, original), err)
+	}
+}
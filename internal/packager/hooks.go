@@ -0,0 +1,63 @@
+package packager
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+)
+
+// Hooks runs steps of the caller around a packaging run, such as the commands of
+// --pre-hook and --post-hook
+type Hooks interface {
+	// Before runs before the source folder is read; an error fails the run before
+	// anything is written
+	Before(ctx context.Context, job HookJob) error
+	// After runs once the run has ended, with runErr set when it failed (result is then
+	// nil); an error fails a run that succeeded, leaving its package in place
+	After(ctx context.Context, job HookJob, result *PackageResult, runErr error) error
+}
+
+// HookJob describes the packaging run hooks are called for
+type HookJob struct {
+	Source    string
+	SetupFile string
+	Output    string
+	// Msi is the metadata of an MSI setup file (nil for other setup files, or when the
+	// MSI cannot be read)
+	Msi *MsiInfo
+	// Started is when the run started
+	Started time.Time
+	// Log is the logger of the run
+	Log *slog.Logger
+}
+
+// packageHooked creates a package like packageContext between the Before and After
+// steps of opts.Hooks
+func packageHooked(ctx context.Context, sourcePath, setupFile, outputPath string, opts Options, progress ProgressCallback) (*PackageResult, error) {
+	job := HookJob{
+		Source:    sourcePath,
+		SetupFile: setupFile,
+		Output:    outputPath,
+		Started:   time.Now(),
+		Log:       opts.logger().With("setup", setupFile),
+	}
+	if IsMsiFile(setupFile) {
+		job.Msi, _ = ExtractMsiInfo(filepath.Join(sourcePath, setupFile))
+	}
+
+	if err := opts.Hooks.Before(ctx, job); err != nil {
+		return nil, fmt.Errorf("pre-packaging hook failed: %w", err)
+	}
+	result, err := packageContext(ctx, sourcePath, setupFile, outputPath, opts, progress)
+	if hookErr := opts.Hooks.After(ctx, job, result, err); hookErr != nil {
+		if err != nil {
+			// The error of the run is the one reported
+			job.Log.Warn("post-packaging hook failed", "error", hookErr)
+			return nil, err
+		}
+		return nil, fmt.Errorf("post-packaging hook failed: %w", hookErr)
+	}
+	return result, err
+}
@@ -0,0 +1,411 @@
+package packager
+
+import (
+	"archive/zip"
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoIcon reports a setup file or icon file without an icon that can be used
+var ErrNoIcon = errors.New("no icon found")
+
+// Resource types of the PE resource section holding icons
+const (
+	peResourceIcon      = 3
+	peResourceGroupIcon = 14
+)
+
+// maxIconSide is the largest width and height of an icon image that is decoded
+const maxIconSide = 1024
+
+var (
+	pngSignature  = []byte("\x89PNG\r\n\x1a\n")
+	jpegSignature = []byte{0xFF, 0xD8, 0xFF}
+	icoSignature  = []byte{0, 0, 1, 0}
+)
+
+// LoadIcon reads the icon shown for an app in the Company Portal from a PNG, JPEG or ICO
+// file, or from the resources of an EXE or DLL, and returns it as PNG
+// The largest image of an icon with several sizes is used
+func LoadIcon(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open icon: %w", err)
+	}
+	defer file.Close()
+
+	head := make([]byte, 8)
+	n, _ := io.ReadFull(file, head)
+	head = head[:n]
+	if bytes.HasPrefix(head, []byte("MZ")) {
+		return ExtractExeIcon(file)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read icon: %w", err)
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read icon: %w", err)
+	}
+
+	switch {
+	case bytes.HasPrefix(data, pngSignature):
+		if _, err := png.DecodeConfig(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("invalid PNG icon: %w", err)
+		}
+		return data, nil
+	case bytes.HasPrefix(data, jpegSignature):
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid JPEG icon: %w", err)
+		}
+		return encodePNG(img)
+	case bytes.HasPrefix(data, icoSignature):
+		return icoFilePNG(data)
+	}
+	return nil, fmt.Errorf("unsupported icon file %s (supported: PNG, JPEG, ICO, EXE)", filepath.Base(path))
+}
+
+// ExtractExeIcon returns the application icon of an EXE or DLL as PNG: the largest image
+// of the first icon group in its resources, the icon Explorer shows for the file
+func ExtractExeIcon(r io.ReaderAt) ([]byte, error) {
+	f, err := pe.NewFile(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PE file: %w", err)
+	}
+	defer f.Close()
+	section := f.Section(".rsrc")
+	if section == nil {
+		return nil, ErrNoIcon
+	}
+	data, err := section.Data()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PE resources: %w", err)
+	}
+	res := peResources{data: data, base: section.VirtualAddress}
+
+	group, err := res.find(peResourceGroupIcon, 0)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := parseIconDir(group, 14)
+	if err != nil {
+		return nil, err
+	}
+	best := bestIconEntry(entries)
+	iconImage, err := res.find(peResourceIcon, best.id)
+	if err != nil {
+		return nil, err
+	}
+	return iconImagePNG(iconImage)
+}
+
+// PackageIcon extracts the icon of the EXE setup file of a package, decrypting its
+// content into a temporary file; it returns ErrNoIcon for other setup files
+func PackageIcon(packagePath string) ([]byte, error) {
+	info, err := ReadDetectionXML(packagePath)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(filepath.Ext(info.SetupFile), ".exe") {
+		return nil, ErrNoIcon
+	}
+	content, err := OpenPreviousContent(PreviousPackage{Path: packagePath, Encryption: &info.EncryptionInfo})
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	entry, ok := content.Entry(strings.ReplaceAll(info.SetupFile, `\`, "/"))
+	if !ok {
+		return nil, fmt.Errorf("setup file %s not found in package", info.SetupFile)
+	}
+	// A stored setup file is read in place; a compressed one is expanded next to the content
+	if entry.Method == zip.Store {
+		offset, err := entry.DataOffset()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read setup file: %w", err)
+		}
+		return ExtractExeIcon(io.NewSectionReader(content.file, offset, int64(entry.UncompressedSize64)))
+	}
+	tmp, err := os.CreateTemp("", "intunewin-setup-*.exe")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	rc, err := entry.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read setup file: %w", err)
+	}
+	_, err = io.Copy(tmp, rc)
+	rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read setup file: %w", err)
+	}
+	return ExtractExeIcon(tmp)
+}
+
+// peResources is the resource section of a PE file
+type peResources struct {
+	data []byte
+	base uint32 // Virtual address of the section, which data entries are relative to
+}
+
+// peResourceEntry is an entry of a resource directory
+type peResourceEntry struct {
+	id     int
+	named  bool
+	subdir bool
+	offset int
+}
+
+// find returns the first resource of a type with the given id, or any id when id is 0,
+// in whatever language comes first
+func (r peResources) find(resourceType, id int) ([]byte, error) {
+	types, err := r.dir(0)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range types {
+		if t.named || t.id != resourceType || !t.subdir {
+			continue
+		}
+		names, err := r.dir(t.offset)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range names {
+			if !n.subdir || (id != 0 && (n.named || n.id != id)) {
+				continue
+			}
+			languages, err := r.dir(n.offset)
+			if err != nil {
+				return nil, err
+			}
+			for _, l := range languages {
+				if !l.subdir {
+					return r.leaf(l.offset)
+				}
+			}
+		}
+	}
+	return nil, ErrNoIcon
+}
+
+// dir reads the entries of the resource directory at offset
+func (r peResources) dir(offset int) ([]peResourceEntry, error) {
+	if offset < 0 || offset+16 > len(r.data) {
+		return nil, fmt.Errorf("damaged PE resources")
+	}
+	count := int(binary.LittleEndian.Uint16(r.data[offset+12:])) + int(binary.LittleEndian.Uint16(r.data[offset+14:]))
+	if offset+16+count*8 > len(r.data) {
+		return nil, fmt.Errorf("damaged PE resources")
+	}
+	entries := make([]peResourceEntry, count)
+	for i := range entries {
+		pos := offset + 16 + i*8
+		name := binary.LittleEndian.Uint32(r.data[pos:])
+		target := binary.LittleEndian.Uint32(r.data[pos+4:])
+		entries[i] = peResourceEntry{
+			id:     int(name & 0xFFFF),
+			named:  name&0x80000000 != 0,
+			subdir: target&0x80000000 != 0,
+			offset: int(target & 0x7FFFFFFF),
+		}
+	}
+	return entries, nil
+}
+
+// leaf returns the data of the resource data entry at offset
+func (r peResources) leaf(offset int) ([]byte, error) {
+	if offset+16 > len(r.data) {
+		return nil, fmt.Errorf("damaged PE resources")
+	}
+	start := int(binary.LittleEndian.Uint32(r.data[offset:])) - int(r.base)
+	size := int(binary.LittleEndian.Uint32(r.data[offset+4:]))
+	if start < 0 || size < 0 || start+size > len(r.data) {
+		return nil, fmt.Errorf("damaged PE resources")
+	}
+	return r.data[start : start+size], nil
+}
+
+// iconEntry is an image of an icon as listed in its directory
+type iconEntry struct {
+	width    int
+	bitCount int
+	// id is the RT_ICON resource of a group icon; offset and size locate the image of an ICO file
+	id     int
+	offset int
+	size   int
+}
+
+// parseIconDir reads the directory of an ICO file (16-byte entries) or of a group icon
+// resource (14-byte entries)
+func parseIconDir(data []byte, entrySize int) ([]iconEntry, error) {
+	if len(data) < 6 || binary.LittleEndian.Uint16(data[2:]) != 1 {
+		return nil, fmt.Errorf("invalid icon directory")
+	}
+	count := int(binary.LittleEndian.Uint16(data[4:]))
+	if count == 0 {
+		return nil, ErrNoIcon
+	}
+	if 6+count*entrySize > len(data) {
+		return nil, fmt.Errorf("invalid icon directory")
+	}
+	entries := make([]iconEntry, count)
+	for i := range entries {
+		e := data[6+i*entrySize:]
+		width := int(e[0])
+		if width == 0 {
+			width = 256
+		}
+		entries[i] = iconEntry{width: width, bitCount: int(binary.LittleEndian.Uint16(e[6:]))}
+		if entrySize == 14 {
+			entries[i].id = int(binary.LittleEndian.Uint16(e[12:]))
+		} else {
+			entries[i].size = int(binary.LittleEndian.Uint32(e[8:]))
+			entries[i].offset = int(binary.LittleEndian.Uint32(e[12:]))
+		}
+	}
+	return entries, nil
+}
+
+// bestIconEntry returns the largest image of an icon, the one with most colors among those
+func bestIconEntry(entries []iconEntry) iconEntry {
+	best := entries[0]
+	for _, e := range entries[1:] {
+		if e.width > best.width || (e.width == best.width && e.bitCount > best.bitCount) {
+			best = e
+		}
+	}
+	return best
+}
+
+// icoFilePNG returns the largest image of an ICO file as PNG
+func icoFilePNG(data []byte) ([]byte, error) {
+	entries, err := parseIconDir(data, 16)
+	if err != nil {
+		return nil, err
+	}
+	best := bestIconEntry(entries)
+	if best.offset < 0 || best.size <= 0 || best.offset+best.size > len(data) {
+		return nil, fmt.Errorf("invalid icon directory")
+	}
+	return iconImagePNG(data[best.offset : best.offset+best.size])
+}
+
+// iconImagePNG converts an image of an icon to PNG; large icons are stored as PNG already,
+// others as a device-independent bitmap followed by a transparency mask
+func iconImagePNG(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, pngSignature) {
+		if _, err := png.DecodeConfig(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("invalid PNG icon image: %w", err)
+		}
+		return data, nil
+	}
+	img, err := decodeIconBitmap(data)
+	if err != nil {
+		return nil, err
+	}
+	return encodePNG(img)
+}
+
+// decodeIconBitmap decodes an uncompressed 1, 4, 8, 24 or 32-bit icon bitmap
+// The bitmap height covers the image and the AND mask below it, so it is twice the icon height
+func decodeIconBitmap(data []byte) (*image.NRGBA, error) {
+	if len(data) < 40 {
+		return nil, fmt.Errorf("invalid icon image")
+	}
+	headerSize := int(binary.LittleEndian.Uint32(data))
+	width := int(int32(binary.LittleEndian.Uint32(data[4:])))
+	height := int(int32(binary.LittleEndian.Uint32(data[8:]))) / 2
+	bitCount := int(binary.LittleEndian.Uint16(data[14:]))
+	compression := binary.LittleEndian.Uint32(data[16:])
+	colorsUsed := int(binary.LittleEndian.Uint32(data[32:]))
+	if width <= 0 || height <= 0 || width > maxIconSide || height > maxIconSide || compression != 0 || headerSize < 40 {
+		return nil, fmt.Errorf("unsupported icon image")
+	}
+
+	var palette []byte
+	pixels := headerSize
+	switch bitCount {
+	case 1, 4, 8:
+		if colorsUsed == 0 || colorsUsed > 1<<bitCount {
+			colorsUsed = 1 << bitCount
+		}
+		if headerSize+colorsUsed*4 > len(data) {
+			return nil, fmt.Errorf("invalid icon image")
+		}
+		palette = data[headerSize : headerSize+colorsUsed*4]
+		pixels += colorsUsed * 4
+	case 24, 32:
+	default:
+		return nil, fmt.Errorf("unsupported icon image: %d bits per pixel", bitCount)
+	}
+	stride := (width*bitCount + 31) / 32 * 4
+	maskStride := (width + 31) / 32 * 4
+	mask := pixels + stride*height
+	if mask > len(data) {
+		return nil, fmt.Errorf("invalid icon image")
+	}
+	hasMask := mask+maskStride*height <= len(data)
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	hasAlpha := false
+	for y := 0; y < height; y++ {
+		// Rows are stored bottom-up
+		row := data[pixels+(height-1-y)*stride:]
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+			switch bitCount {
+			case 32:
+				c = color.NRGBA{R: row[x*4+2], G: row[x*4+1], B: row[x*4], A: row[x*4+3]}
+				hasAlpha = hasAlpha || c.A != 0
+			case 24:
+				c = color.NRGBA{R: row[x*3+2], G: row[x*3+1], B: row[x*3], A: 0xFF}
+			default:
+				index := int(row[x*bitCount/8]) >> (8 - bitCount - x*bitCount%8) & (1<<bitCount - 1)
+				if index*4+2 < len(palette) {
+					c = color.NRGBA{R: palette[index*4+2], G: palette[index*4+1], B: palette[index*4], A: 0xFF}
+				}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	// Without an alpha channel, the AND mask marks the transparent pixels
+	if !hasAlpha {
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				c := img.NRGBAAt(x, y)
+				c.A = 0xFF
+				if hasMask && data[mask+(height-1-y)*maskStride+x/8]>>(7-x%8)&1 == 1 {
+					c.A = 0
+				}
+				img.SetNRGBA(x, y, c)
+			}
+		}
+	}
+	return img, nil
+}
+
+// encodePNG encodes an image as PNG
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode icon as PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,271 @@
+package packager
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testIconImage is an image of an icon as listed in its directory
+type testIconImage struct {
+	width    int
+	bitCount int
+	data     []byte
+}
+
+// solidBitmap returns a 32-bit icon bitmap of one color
+func solidBitmap(width int, c color.NRGBA) []byte {
+	header := make([]byte, 40)
+	binary.LittleEndian.PutUint32(header, 40)
+	binary.LittleEndian.PutUint32(header[4:], uint32(width))
+	binary.LittleEndian.PutUint32(header[8:], uint32(width*2))
+	binary.LittleEndian.PutUint16(header[12:], 1)
+	binary.LittleEndian.PutUint16(header[14:], 32)
+	data := header
+	for i := 0; i < width*width; i++ {
+		data = append(data, c.B, c.G, c.R, c.A)
+	}
+	// AND mask rows are padded to 32 bits
+	return append(data, make([]byte, (width+31)/32*4*width)...)
+}
+
+// maskedBitmap returns an 8-bit icon bitmap of red pixels whose left column is
+// transparent in the AND mask
+func maskedBitmap(width int) []byte {
+	header := make([]byte, 40)
+	binary.LittleEndian.PutUint32(header, 40)
+	binary.LittleEndian.PutUint32(header[4:], uint32(width))
+	binary.LittleEndian.PutUint32(header[8:], uint32(width*2))
+	binary.LittleEndian.PutUint16(header[12:], 1)
+	binary.LittleEndian.PutUint16(header[14:], 8)
+	binary.LittleEndian.PutUint32(header[32:], 2)
+	data := append(header, 0, 0, 0, 0, 0, 0, 0xFF, 0) // palette: black, red
+	stride := (width*8 + 31) / 32 * 4
+	for y := 0; y < width; y++ {
+		data = append(data, bytes.Repeat([]byte{1}, stride)...)
+	}
+	maskStride := (width + 31) / 32 * 4
+	for y := 0; y < width; y++ {
+		row := make([]byte, maskStride)
+		row[0] = 0x80
+		data = append(data, row...)
+	}
+	return data
+}
+
+// testPNG returns a PNG image of one color
+func testPNG(t *testing.T, width int, c color.NRGBA) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, width))
+	for i := 0; i < len(img.Pix); i += 4 {
+		copy(img.Pix[i:], []byte{c.R, c.G, c.B, c.A})
+	}
+	data, err := encodePNG(img)
+	if err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	return data
+}
+
+// iconPE returns a PE image whose resources hold one icon group with the given images,
+// stored as RT_ICON resources numbered from 1
+func iconPE(images ...testIconImage) []byte {
+	group := binary.LittleEndian.AppendUint16(nil, 0)
+	group = binary.LittleEndian.AppendUint16(group, 1)
+	group = binary.LittleEndian.AppendUint16(group, uint16(len(images)))
+	for i, img := range images {
+		group = append(group, byte(img.width%256), byte(img.width%256), 0, 0)
+		group = binary.LittleEndian.AppendUint16(group, 1)
+		group = binary.LittleEndian.AppendUint16(group, uint16(img.bitCount))
+		group = binary.LittleEndian.AppendUint32(group, uint32(len(img.data)))
+		group = binary.LittleEndian.AppendUint16(group, uint16(i+1))
+	}
+	blobs := [][]byte{group}
+	for _, img := range images {
+		blobs = append(blobs, img.data)
+	}
+
+	// Root, RT_ICON and RT_GROUP_ICON directories, then a language directory and a
+	// data entry per resource, then the data
+	n := len(images)
+	iconDir := 16 + 2*8
+	groupDir := iconDir + 16 + n*8
+	languageDirs := groupDir + 16 + 8
+	dataEntries := languageDirs + (n+1)*24
+	offset := dataEntries + (n+1)*16
+
+	rsrc := make([]byte, offset)
+	directory := func(at int, entries ...[2]uint32) {
+		binary.LittleEndian.PutUint16(rsrc[at+14:], uint16(len(entries)))
+		for i, e := range entries {
+			binary.LittleEndian.PutUint32(rsrc[at+16+i*8:], e[0])
+			binary.LittleEndian.PutUint32(rsrc[at+20+i*8:], e[1])
+		}
+	}
+	const subdir = 0x80000000
+	directory(0, [2]uint32{peResourceIcon, subdir | uint32(iconDir)}, [2]uint32{peResourceGroupIcon, subdir | uint32(groupDir)})
+	var icons [][2]uint32
+	for i := 1; i <= n; i++ {
+		icons = append(icons, [2]uint32{uint32(i), subdir | uint32(languageDirs+i*24)})
+	}
+	directory(iconDir, icons...)
+	directory(groupDir, [2]uint32{1, subdir | uint32(languageDirs)})
+	for i, blob := range blobs {
+		directory(languageDirs+i*24, [2]uint32{1033, uint32(dataEntries + i*16)})
+		binary.LittleEndian.PutUint32(rsrc[dataEntries+i*16:], 0x1000+uint32(len(rsrc)))
+		binary.LittleEndian.PutUint32(rsrc[dataEntries+i*16+4:], uint32(len(blob)))
+		rsrc = append(rsrc, blob...)
+	}
+	return rsrcPE(rsrc)
+}
+
+// decodeTestPNG decodes an icon returned as PNG
+func decodeTestPNG(t *testing.T, data []byte) image.Image {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Icon is not a PNG: %v", err)
+	}
+	return img
+}
+
+func TestExtractExeIcon(t *testing.T) {
+	blue := color.NRGBA{B: 0xFF, A: 0xFF}
+	large := testPNG(t, 256, color.NRGBA{G: 0xFF, A: 0xFF})
+
+	// The largest image wins
+	icon, err := ExtractExeIcon(bytes.NewReader(iconPE(
+		testIconImage{32, 32, solidBitmap(32, blue)},
+		testIconImage{256, 32, large},
+		testIconImage{16, 32, solidBitmap(16, blue)},
+	)))
+	if err != nil {
+		t.Fatalf("ExtractExeIcon() error = %v", err)
+	}
+	if !bytes.Equal(icon, large) {
+		t.Error("Expected the 256x256 PNG image of the icon")
+	}
+
+	// Bitmaps are converted to PNG
+	icon, err = ExtractExeIcon(bytes.NewReader(iconPE(testIconImage{48, 32, solidBitmap(48, blue)})))
+	if err != nil {
+		t.Fatalf("ExtractExeIcon() error = %v", err)
+	}
+	img := decodeTestPNG(t, icon)
+	if img.Bounds().Dx() != 48 || color.NRGBAModel.Convert(img.At(10, 10)) != blue {
+		t.Errorf("Icon = %v with color %v, want 48x48 blue", img.Bounds(), img.At(10, 10))
+	}
+
+	// An empty resource directory
+	if _, err := ExtractExeIcon(bytes.NewReader(rsrcPE(make([]byte, 16)))); !errors.Is(err, ErrNoIcon) {
+		t.Errorf("ExtractExeIcon() without icon error = %v, want ErrNoIcon", err)
+	}
+}
+
+func TestDecodeIconBitmapMask(t *testing.T) {
+	img, err := decodeIconBitmap(maskedBitmap(16))
+	if err != nil {
+		t.Fatalf("decodeIconBitmap() error = %v", err)
+	}
+	if c := img.NRGBAAt(0, 5); c.A != 0 {
+		t.Errorf("Masked pixel = %v, want transparent", c)
+	}
+	if c := img.NRGBAAt(5, 5); c != (color.NRGBA{R: 0xFF, A: 0xFF}) {
+		t.Errorf("Pixel = %v, want opaque red", c)
+	}
+}
+
+func TestLoadIcon(t *testing.T) {
+	dir := t.TempDir()
+	red := color.NRGBA{R: 0xFF, A: 0xFF}
+	pngIcon := testPNG(t, 64, red)
+
+	// An ICO file with a 16x16 bitmap and a 32x32 bitmap
+	small, big := solidBitmap(16, red), solidBitmap(32, red)
+	ico := []byte{0, 0, 1, 0, 2, 0}
+	for _, img := range []testIconImage{{16, 32, small}, {32, 32, big}} {
+		ico = append(ico, byte(img.width), byte(img.width), 0, 0, 1, 0, 32, 0)
+		ico = binary.LittleEndian.AppendUint32(ico, uint32(len(img.data)))
+		ico = binary.LittleEndian.AppendUint32(ico, 0) // offset, set below
+	}
+	binary.LittleEndian.PutUint32(ico[6+12:], uint32(len(ico)))
+	binary.LittleEndian.PutUint32(ico[6+16+12:], uint32(len(ico)+len(small)))
+	ico = append(append(ico, small...), big...)
+
+	var jpg bytes.Buffer
+	jpeg.Encode(&jpg, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil)
+
+	files := map[string][]byte{
+		"icon.png":  pngIcon,
+		"icon.ico":  ico,
+		"photo.jpg": jpg.Bytes(),
+		"setup.exe": iconPE(testIconImage{32, 32, big}),
+		"notes.txt": []byte("not an icon"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	tests := map[string]struct {
+		file    string
+		width   int
+		wantErr bool
+	}{
+		"png":         {"icon.png", 64, false},
+		"largest ico": {"icon.ico", 32, false},
+		"jpeg":        {"photo.jpg", 8, false},
+		"exe":         {"setup.exe", 32, false},
+		"unsupported": {"notes.txt", 0, true},
+		"missing":     {"missing.png", 0, true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			icon, err := LoadIcon(filepath.Join(dir, tt.file))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadIcon() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && decodeTestPNG(t, icon).Bounds().Dx() != tt.width {
+				t.Errorf("LoadIcon() width = %d, want %d", decodeTestPNG(t, icon).Bounds().Dx(), tt.width)
+			}
+		})
+	}
+}
+
+func TestPackageIcon(t *testing.T) {
+	sourceDir := t.TempDir()
+	outputDir := t.TempDir()
+	green := color.NRGBA{G: 0xFF, A: 0xFF}
+	if err := os.WriteFile(filepath.Join(sourceDir, "setup.exe"), iconPE(testIconImage{32, 32, solidBitmap(32, green)}), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+	os.WriteFile(filepath.Join(sourceDir, "install.ps1"), []byte("Write-Output 'install'"), 0644)
+
+	result, err := PackageWithOptions(sourceDir, "setup.exe", outputDir, Options{}, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	icon, err := PackageIcon(result.OutputPath)
+	if err != nil {
+		t.Fatalf("PackageIcon() error = %v", err)
+	}
+	if c := color.NRGBAModel.Convert(decodeTestPNG(t, icon).At(3, 3)); c != green {
+		t.Errorf("Icon color = %v, want green", c)
+	}
+
+	result, err = PackageWithOptions(sourceDir, "install.ps1", outputDir, Options{}, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	if _, err := PackageIcon(result.OutputPath); !errors.Is(err, ErrNoIcon) {
+		t.Errorf("PackageIcon() for a script error = %v, want ErrNoIcon", err)
+	}
+}
@@ -0,0 +1,327 @@
+package packager
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ContentKeys are an AES-256 encryption key and HMAC-SHA256 key supplied from outside
+// (a KMS) instead of generated for each package
+// A random IV is still generated for every package, so content encrypted with the same
+// keys never shares an IV
+type ContentKeys struct {
+	EncryptionKey []byte
+	MacKey        []byte
+}
+
+// contentKeysFile is the JSON layout of a key file
+type contentKeysFile struct {
+	EncryptionKey string `json:"encryptionKey"`
+	MacKey        string `json:"macKey"`
+}
+
+// ParseContentKeys decodes base64 encryption and MAC keys
+func ParseContentKeys(encKey, macKey string) (*ContentKeys, error) {
+	keys := &ContentKeys{}
+	var err error
+	if keys.EncryptionKey, err = decodeKey("encryption key", encKey); err != nil {
+		return nil, err
+	}
+	if keys.MacKey, err = decodeKey("MAC key", macKey); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// decodeKey decodes a base64 32-byte key
+func decodeKey(name, value string) ([]byte, error) {
+	if value == "" {
+		return nil, fmt.Errorf("%s is missing", name)
+	}
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: expected base64", name)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("invalid %s: must be 32 bytes, got %d", name, len(key))
+	}
+	return key, nil
+}
+
+// LoadContentKeys reads keys from a JSON key file with base64 "encryptionKey" and "macKey"
+func LoadContentKeys(path string) (*ContentKeys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	var file contentKeysFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse key file %s: %w", path, err)
+	}
+	keys, err := ParseContentKeys(file.EncryptionKey, file.MacKey)
+	if err != nil {
+		return nil, fmt.Errorf("key file %s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// packageKeys returns the keys and IV for content with the given digest: derived from the
+// reproducible build seed, the supplied keys with a random IV, or random keys
+func packageKeys(opts Options, fileDigest []byte) (encKey, macKey, iv []byte, err error) {
+	switch {
+	case seededKeys(opts):
+		encKey, macKey, iv = DeriveKeys(opts.Reproducible.Seed, fileDigest)
+		return encKey, macKey, iv, nil
+	case opts.Keys != nil:
+		iv = make([]byte, aes.BlockSize)
+		if _, err := rand.Read(iv); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to generate IV: %w", err)
+		}
+		return opts.Keys.EncryptionKey, opts.Keys.MacKey, iv, nil
+	default:
+		return GenerateKeys()
+	}
+}
+
+// seededKeys reports whether the keys of a package are derived from the reproducible seed
+// and its file digest
+func seededKeys(opts Options) bool {
+	return opts.Reproducible != nil && len(opts.Reproducible.Seed) > 0
+}
+
+// exportPackageKeys writes the key export of a package when opts.ExportKeys is set
+// Returns the path of the export, or "" when none was requested
+func exportPackageKeys(opts Options, packagePath string, detectionXML []byte) (string, error) {
+	if opts.ExportKeys == "" {
+		return "", nil
+	}
+	appInfo, err := ParseDetectionXML(detectionXML)
+	if err != nil {
+		return "", fmt.Errorf("key export failed: %w", err)
+	}
+	if err := WriteKeyExport(opts.ExportKeys, NewExportedKeys(packagePath, appInfo), opts.ExportPassphrase); err != nil {
+		return "", err
+	}
+	return opts.ExportKeys, nil
+}
+
+// ExportedKeys holds what is needed to decrypt and verify a package without its
+// Detection.xml: the keys, IV and digest, as base64 like Detection.xml writes them
+type ExportedKeys struct {
+	Package                string `json:"package"`
+	Name                   string `json:"name"`
+	SetupFile              string `json:"setupFile"`
+	UnencryptedContentSize int64  `json:"unencryptedContentSize"`
+	EncryptionKey          string `json:"encryptionKey"`
+	MacKey                 string `json:"macKey"`
+	InitializationVector   string `json:"initializationVector"`
+	Mac                    string `json:"mac"`
+	ProfileIdentifier      string `json:"profileIdentifier"`
+	FileDigest             string `json:"fileDigest"`
+	FileDigestAlgorithm    string `json:"fileDigestAlgorithm"`
+}
+
+// NewExportedKeys collects the keys of a package from its Detection.xml
+func NewExportedKeys(packagePath string, appInfo *ApplicationInfo) *ExportedKeys {
+	enc := appInfo.EncryptionInfo
+	return &ExportedKeys{
+		Package:                filepath.Base(packagePath),
+		Name:                   appInfo.Name,
+		SetupFile:              appInfo.SetupFile,
+		UnencryptedContentSize: appInfo.UnencryptedContentSize,
+		EncryptionKey:          enc.EncryptionKey,
+		MacKey:                 enc.MacKey,
+		InitializationVector:   enc.InitializationVector,
+		Mac:                    enc.Mac,
+		ProfileIdentifier:      enc.ProfileIdentifier,
+		FileDigest:             enc.FileDigest,
+		FileDigestAlgorithm:    enc.FileDigestAlgorithm,
+	}
+}
+
+// ApplicationInfo returns the exported keys as the Detection.xml fields they came from,
+// for use wherever a package's Detection.xml is accepted
+func (k *ExportedKeys) ApplicationInfo() *ApplicationInfo {
+	return &ApplicationInfo{
+		Name:                   k.Name,
+		UnencryptedContentSize: k.UnencryptedContentSize,
+		FileName:               "IntunePackage.intunewin",
+		SetupFile:              k.SetupFile,
+		EncryptionInfo: EncryptionXML{
+			EncryptionKey:        k.EncryptionKey,
+			MacKey:               k.MacKey,
+			InitializationVector: k.InitializationVector,
+			Mac:                  k.Mac,
+			ProfileIdentifier:    k.ProfileIdentifier,
+			FileDigest:           k.FileDigest,
+			FileDigestAlgorithm:  k.FileDigestAlgorithm,
+		},
+	}
+}
+
+const (
+	// keyExportFormat identifies key export files
+	keyExportFormat = "intunewin-keys"
+	// keyExportIterations is the PBKDF2 iteration count for new exports (OWASP guidance for SHA-256)
+	keyExportIterations = 600000
+	// MinPassphraseLength is the shortest passphrase accepted for key exports
+	MinPassphraseLength = 8
+)
+
+// sealedFile is the JSON layout of a file encrypted with a passphrase: AES-256-GCM under a
+// key derived from the passphrase with PBKDF2-HMAC-SHA256, such as a key export
+type sealedFile struct {
+	Format     string `json:"format"`
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Cipher     string `json:"cipher"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// SealWithPassphrase encrypts plaintext with a passphrase into the JSON layout of key
+// exports, identified by format (e.g. "intunewin-keys")
+func SealWithPassphrase(format string, plaintext, passphrase []byte) ([]byte, error) {
+	if len(passphrase) < MinPassphraseLength {
+		return nil, fmt.Errorf("passphrase must be at least %d characters", MinPassphraseLength)
+	}
+	file := sealedFile{
+		Format:     format,
+		Version:    1,
+		KDF:        "PBKDF2-HMAC-SHA256",
+		Iterations: keyExportIterations,
+		Salt:       make([]byte, 16),
+		Cipher:     "AES-256-GCM",
+	}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	gcm, err := keyExportCipher(passphrase, file.Salt, file.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	file.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	file.Data = gcm.Seal(nil, file.Nonce, plaintext, []byte(format))
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s file: %w", format, err)
+	}
+	return append(data, '\n'), nil
+}
+
+// OpenWithPassphrase decrypts data sealed by SealWithPassphrase with the same format
+func OpenWithPassphrase(format string, data, passphrase []byte) ([]byte, error) {
+	var file sealedFile
+	if err := json.Unmarshal(data, &file); err != nil || file.Format != format {
+		return nil, fmt.Errorf("not a %s file", format)
+	}
+	if file.Version != 1 {
+		return nil, fmt.Errorf("unsupported %s version %d", format, file.Version)
+	}
+	if file.Iterations <= 0 || len(file.Salt) == 0 {
+		return nil, fmt.Errorf("invalid %s file", format)
+	}
+
+	gcm, err := keyExportCipher(passphrase, file.Salt, file.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid %s file", format)
+	}
+	plaintext, err := gcm.Open(nil, file.Nonce, file.Data, []byte(format))
+	if err != nil {
+		return nil, errors.New("wrong passphrase or corrupted file")
+	}
+	return plaintext, nil
+}
+
+// WriteKeyExport encrypts keys with a passphrase and writes them to path
+// The file is readable by the owner only, as the keys decrypt the package
+func WriteKeyExport(path string, keys *ExportedKeys, passphrase []byte) error {
+	if len(passphrase) < MinPassphraseLength {
+		return fmt.Errorf("key export passphrase must be at least %d characters", MinPassphraseLength)
+	}
+	plaintext, err := json.Marshal(keys)
+	if err != nil {
+		return fmt.Errorf("failed to encode keys: %w", err)
+	}
+	data, err := SealWithPassphrase(keyExportFormat, plaintext, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt key export: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write key export: %w", err)
+	}
+	return nil
+}
+
+// ReadKeyExport reads and decrypts a key export written by WriteKeyExport
+func ReadKeyExport(path string, passphrase []byte) (*ExportedKeys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key export: %w", err)
+	}
+	plaintext, err := OpenWithPassphrase(keyExportFormat, data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt key export %s: %w", path, err)
+	}
+
+	var keys ExportedKeys
+	if err := json.Unmarshal(plaintext, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse key export: %w", err)
+	}
+	return &keys, nil
+}
+
+// keyExportCipher returns the AES-256-GCM cipher for a passphrase
+func keyExportCipher(passphrase, salt []byte, iterations int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2SHA256(passphrase, salt, iterations, 32))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM cipher: %w", err)
+	}
+	return gcm, nil
+}
+
+// pbkdf2SHA256 derives a key from a password as specified by RFC 8018, with HMAC-SHA256
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var key []byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(binary.BigEndian.AppendUint32(nil, block))
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
@@ -0,0 +1,154 @@
+package packager
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPBKDF2SHA256(t *testing.T) {
+	// Test vectors for PBKDF2-HMAC-SHA256 from RFC 7914 and the RFC 6070 inputs
+	tests := []struct {
+		password, salt string
+		iterations     int
+		keyLen         int
+		want           string
+	}{
+		{"password", "salt", 1, 32, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{"password", "salt", 2, 32, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{"password", "salt", 4096, 32, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+		{"passwd", "salt", 1, 64, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+	}
+	for _, tt := range tests {
+		got := hex.EncodeToString(pbkdf2SHA256([]byte(tt.password), []byte(tt.salt), tt.iterations, tt.keyLen))
+		if got != tt.want {
+			t.Errorf("pbkdf2SHA256(%q, %q, %d) = %s, want %s", tt.password, tt.salt, tt.iterations, got, tt.want)
+		}
+	}
+}
+
+func TestLoadContentKeys(t *testing.T) {
+	dir := t.TempDir()
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	short := base64.StdEncoding.EncodeToString([]byte("short"))
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"valid", `{"encryptionKey": "` + key + `", "macKey": "` + key + `"}`, ""},
+		{"missing MAC key", `{"encryptionKey": "` + key + `"}`, "MAC key is missing"},
+		{"short key", `{"encryptionKey": "` + short + `", "macKey": "` + key + `"}`, "must be 32 bytes"},
+		{"not base64", `{"encryptionKey": "not base64!", "macKey": "` + key + `"}`, "expected base64"},
+		{"not JSON", `encryptionKey=abc`, "failed to parse key file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "-")+".json")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatalf("Failed to write key file: %v", err)
+			}
+			keys, err := LoadContentKeys(path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("LoadContentKeys() error = %v", err)
+				}
+				if len(keys.EncryptionKey) != 32 || len(keys.MacKey) != 32 {
+					t.Errorf("Unexpected key sizes %d, %d", len(keys.EncryptionKey), len(keys.MacKey))
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadContentKeys() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPackageWithSuppliedKeysAndExport(t *testing.T) {
+	sourceDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "setup.exe"), []byte("installer"), 0755); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+
+	keys := &ContentKeys{EncryptionKey: bytes.Repeat([]byte{7}, 32), MacKey: bytes.Repeat([]byte{9}, 32)}
+	exportPath := filepath.Join(t.TempDir(), "setup.keys.json")
+	passphrase := []byte("correct horse battery")
+	opts := Options{Keys: keys, ExportKeys: exportPath, ExportPassphrase: passphrase}
+
+	result, err := PackageWithOptions(sourceDir, "setup.exe", t.TempDir(), opts, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	if result.KeysPath != exportPath {
+		t.Errorf("KeysPath = %q, want %q", result.KeysPath, exportPath)
+	}
+
+	appInfo, err := ReadDetectionXML(result.OutputPath)
+	if err != nil {
+		t.Fatalf("ReadDetectionXML() error = %v", err)
+	}
+	if appInfo.EncryptionInfo.EncryptionKey != base64.StdEncoding.EncodeToString(keys.EncryptionKey) ||
+		appInfo.EncryptionInfo.MacKey != base64.StdEncoding.EncodeToString(keys.MacKey) {
+		t.Error("Detection.xml does not hold the supplied keys")
+	}
+
+	info, err := os.Stat(exportPath)
+	if err != nil {
+		t.Fatalf("Key export not written: %v", err)
+	}
+	if data, _ := os.ReadFile(exportPath); bytes.Contains(data, []byte(appInfo.EncryptionInfo.EncryptionKey)) {
+		t.Error("Key export holds the encryption key in clear text")
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 && filepath.Separator == '/' {
+		t.Errorf("Key export is readable by others: %v", perm)
+	}
+
+	if _, err := ReadKeyExport(exportPath, []byte("wrong passphrase")); err == nil {
+		t.Error("Expected a wrong passphrase to be rejected")
+	}
+	exported, err := ReadKeyExport(exportPath, passphrase)
+	if err != nil {
+		t.Fatalf("ReadKeyExport() error = %v", err)
+	}
+	if exported.Package != filepath.Base(result.OutputPath) || exported.SetupFile != "setup.exe" {
+		t.Errorf("Unexpected exported package details: %+v", exported)
+	}
+	if exported.ApplicationInfo().EncryptionInfo != appInfo.EncryptionInfo {
+		t.Errorf("Exported keys differ from Detection.xml:\n%+v\n%+v", exported.ApplicationInfo().EncryptionInfo, appInfo.EncryptionInfo)
+	}
+
+	// The exported keys decrypt the content of the package
+	encrypted, err := ReadEncryptedContent(result.OutputPath)
+	if err != nil {
+		t.Fatalf("Failed to read encrypted content: %v", err)
+	}
+	if _, err := DecryptPackageContent(encrypted, exported.ApplicationInfo().EncryptionInfo); err != nil {
+		t.Errorf("DecryptPackageContent() with exported keys error = %v", err)
+	}
+}
+
+func TestPackageKeyOptionsValidation(t *testing.T) {
+	sourceDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "setup.exe"), []byte("installer"), 0755); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+	keys := &ContentKeys{EncryptionKey: make([]byte, 32), MacKey: make([]byte, 32)}
+
+	tests := map[string]Options{
+		"keys with seed":       {Keys: keys, Reproducible: &Reproducible{Seed: []byte("seed")}},
+		"keys with checkpoint": {Keys: keys, CheckpointRoot: t.TempDir()},
+		"short key":            {Keys: &ContentKeys{EncryptionKey: make([]byte, 16), MacKey: make([]byte, 32)}},
+		"short passphrase":     {ExportKeys: filepath.Join(t.TempDir(), "keys.json"), ExportPassphrase: []byte("short")},
+	}
+	for name, opts := range tests {
+		if _, err := PackageWithOptions(sourceDir, "setup.exe", t.TempDir(), opts, nil); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package packager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LicenseSuffix is appended to the .intunewin path to name its license record
+const LicenseSuffix = ".license.json"
+
+// License is the licensing record of a package, kept with it so asset management
+// can link a deployment to the license it consumes
+type License struct {
+	// Type is the license model (e.g., "per-device", "per-user", "site", "freeware")
+	Type string `json:"type,omitempty"`
+	// ID is the internal license or contract identifier
+	ID string `json:"id,omitempty"`
+	// Seats is the number of purchased seats (0 when not counted)
+	Seats int `json:"seats,omitempty"`
+	// EULA is the URL or document reference of the accepted license agreement
+	EULA string `json:"eula,omitempty"`
+	// AcceptedBy records who acknowledged the EULA for the organization
+	AcceptedBy string `json:"acceptedBy,omitempty"`
+}
+
+// Empty reports whether no license field is set
+func (l *License) Empty() bool {
+	return l == nil || *l == License{}
+}
+
+// Equal reports whether two license records are equal, treating empty as none
+func (l *License) Equal(other *License) bool {
+	if l.Empty() || other.Empty() {
+		return l.Empty() && other.Empty()
+	}
+	return *l == *other
+}
+
+// Validate checks that a license record can be written
+func (l *License) Validate() error {
+	if l.Seats < 0 {
+		return fmt.Errorf("license seats must not be negative")
+	}
+	if l.AcceptedBy != "" && l.EULA == "" {
+		return fmt.Errorf("license acceptance requires the EULA it applies to")
+	}
+	return nil
+}
+
+// String returns a one-line summary such as "per-device LIC-0042, 250 seats"
+func (l *License) String() string {
+	if l == nil {
+		return ""
+	}
+	var parts []string
+	if head := strings.TrimSpace(l.Type + " " + l.ID); head != "" {
+		parts = append(parts, head)
+	}
+	if l.Seats > 0 {
+		parts = append(parts, fmt.Sprintf("%d seats", l.Seats))
+	}
+	if l.AcceptedBy != "" {
+		parts = append(parts, "EULA accepted by "+l.AcceptedBy)
+	} else if l.EULA != "" {
+		parts = append(parts, "EULA "+l.EULA)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// LicensePath returns the path of the license record written alongside a package
+func LicensePath(packagePath string) string {
+	return packagePath + LicenseSuffix
+}
+
+// WriteLicense writes a license record as indented JSON
+func WriteLicense(path string, license *License) error {
+	data, err := json.MarshalIndent(license, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode license: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write license: %w", err)
+	}
+	return nil
+}
+
+// ReadLicense reads the license record of a package, nil when it has none
+func ReadLicense(packagePath string) (*License, error) {
+	data, err := os.ReadFile(LicensePath(packagePath))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read license: %w", err)
+	}
+
+	var license License
+	if err := json.Unmarshal(data, &license); err != nil {
+		return nil, fmt.Errorf("failed to parse license %s: %w", LicensePath(packagePath), err)
+	}
+	return &license, nil
+}
+
+// writePackageLicense writes the license record of a package when opts.License is set
+// A package built without one drops the record of a previous build at the same path
+func writePackageLicense(opts Options, packagePath string) (string, error) {
+	if opts.OutputWriter != nil {
+		return "", nil // there is no package file to keep a license record next to
+	}
+	path := LicensePath(packagePath)
+	if opts.License.Empty() {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("failed to remove stale license: %w", err)
+		}
+		return "", nil
+	}
+	if err := WriteLicense(path, opts.License); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,91 @@
+package packager
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLicenseString(t *testing.T) {
+	tests := []struct {
+		license  *License
+		expected string
+	}{
+		{&License{Type: "per-device", ID: "LIC-0042", Seats: 250}, "per-device LIC-0042, 250 seats"},
+		{&License{ID: "LIC-0042", EULA: "https://contoso.com/eula", AcceptedBy: "Legal"}, "LIC-0042, EULA accepted by Legal"},
+		{&License{EULA: "https://contoso.com/eula"}, "EULA https://contoso.com/eula"},
+		{nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := tt.license.String(); got != tt.expected {
+			t.Errorf("String() = %q, want %q", got, tt.expected)
+		}
+	}
+}
+
+func TestLicenseValidate(t *testing.T) {
+	if err := (&License{Seats: -1}).Validate(); err == nil {
+		t.Error("Expected error for negative seats")
+	}
+	if err := (&License{AcceptedBy: "Legal"}).Validate(); err == nil {
+		t.Error("Expected error for an acceptance without EULA")
+	}
+	if err := (&License{Type: "site"}).Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestPackageWithLicense(t *testing.T) {
+	sourceDir := t.TempDir()
+	outputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "setup.exe"), []byte("setup"), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+
+	license := &License{Type: "per-device", ID: "LIC-0042", Seats: 250}
+	result, err := PackageWithOptions(sourceDir, "setup.exe", outputDir, Options{License: license, Manifest: true}, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	if result.LicensePath != LicensePath(result.OutputPath) || result.License != license {
+		t.Errorf("LicensePath = %s, License = %+v", result.LicensePath, result.License)
+	}
+
+	read, err := ReadLicense(result.OutputPath)
+	if err != nil {
+		t.Fatalf("ReadLicense() error = %v", err)
+	}
+	if read == nil || *read != *license {
+		t.Errorf("ReadLicense() = %+v, want %+v", read, license)
+	}
+
+	data, err := os.ReadFile(result.ManifestPath)
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("Failed to parse manifest: %v", err)
+	}
+	if manifest.License == nil || manifest.License.ID != "LIC-0042" {
+		t.Errorf("Manifest license = %+v, want LIC-0042", manifest.License)
+	}
+
+	// Rebuilding without a license drops the stale record
+	result, err = PackageWithOptions(sourceDir, "setup.exe", outputDir, Options{}, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	if result.LicensePath != "" || result.License != nil {
+		t.Errorf("LicensePath = %s, License = %+v, want none", result.LicensePath, result.License)
+	}
+	if read, err := ReadLicense(result.OutputPath); err != nil || read != nil {
+		t.Errorf("ReadLicense() = %+v, %v, want no record", read, err)
+	}
+
+	if _, err := PackageWithOptions(sourceDir, "setup.exe", outputDir, Options{License: &License{Seats: -5}}, nil); err == nil {
+		t.Error("Expected error for an invalid license")
+	}
+}
@@ -0,0 +1,314 @@
+package packager
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Rules of LintSource
+const (
+	LintSetupMissing   = "setup-missing"
+	LintSetupNotAtRoot = "setup-not-at-root"
+	LintSetupName      = "setup-file-name"
+	LintDiskImage      = "disk-image"
+	LintLargeArchive   = "large-archive"
+	LintDuplicateFile  = "duplicate-file"
+	LintNestedPackage  = "nested-package"
+	LintPSADT          = "psadt-incomplete"
+	LintFileCount      = "file-count"
+)
+
+// LintMaxFiles is the file count above which Intune downloads and installs slow down
+const LintMaxFiles = 20000
+
+var (
+	// lintArchiveSize is the size from which archives and disk images are reported
+	// (shortened in tests)
+	lintArchiveSize int64 = 50 << 20
+	// lintDuplicateSize is the size from which identical files are reported
+	// (shortened in tests)
+	lintDuplicateSize int64 = 10 << 20
+)
+
+// Extensions of disk images and archives, which are usually downloads that were extracted
+var (
+	diskImageTypes = []string{".iso", ".img", ".vhd", ".vhdx", ".wim"}
+	archiveTypes   = []string{".zip", ".7z", ".rar", ".tar", ".gz", ".tgz"}
+)
+
+// psadtLayouts are the files the PowerShell App Deployment Toolkit needs, by the script
+// that marks its version
+var psadtLayouts = map[string][]string{
+	// Version 3
+	"Deploy-Application.ps1": {
+		"AppDeployToolkit/AppDeployToolkitMain.ps1",
+		"AppDeployToolkit/AppDeployToolkitConfig.xml",
+		"AppDeployToolkit/AppDeployToolkitExtensions.ps1",
+	},
+	// Version 4
+	"Invoke-AppDeployToolkit.ps1": {
+		"PSAppDeployToolkit/PSAppDeployToolkit.psd1",
+		"PSAppDeployToolkit/PSAppDeployToolkit.psm1",
+	},
+}
+
+// LintFinding is a likely mistake in a source folder, found by LintSource
+type LintFinding struct {
+	// Rule names the check, e.g. "nested-package"
+	Rule string `json:"rule"`
+	// Path is the file the finding is about, relative to the source folder with forward
+	// slashes (empty for the folder itself)
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+func (f LintFinding) String() string {
+	if f.Path == "" {
+		return f.Rule + ": " + f.Message
+	}
+	return fmt.Sprintf("%s: %s: %s", f.Rule, f.Path, f.Message)
+}
+
+// lintFile is a file of the source folder
+type lintFile struct {
+	rel  string
+	path string
+	size int64
+}
+
+// LintSource checks a source folder for common mistakes before packaging: a setup file
+// that is missing, not at the root or awkwardly named, disk images and large archives,
+// identical large files, nested .intunewin packages, incomplete PSADT layouts and file
+// counts above LintMaxFiles
+// setupFile may be empty, then the preferred setup file at the root is checked
+// opts.Exclude and opts.Symlinks are honored like when packaging
+func LintSource(sourcePath, setupFile string, opts Options) ([]LintFinding, error) {
+	info, err := os.Stat(sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot access source folder: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("source path is not a directory: %s", sourcePath)
+	}
+
+	var files []lintFile
+	_, err = walkSource(sourcePath, opts.Exclude, opts.Symlinks, func(rel, path string, info os.FileInfo) error {
+		if !info.IsDir() {
+			files = append(files, lintFile{rel: filepath.ToSlash(rel), path: path, size: info.Size()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan source folder: %w", err)
+	}
+
+	var findings []LintFinding
+	findings = append(findings, lintSetupFile(files, setupFile)...)
+	for _, f := range files {
+		findings = append(findings, lintFileType(f, files)...)
+	}
+	findings = append(findings, lintPSADT(files)...)
+	duplicates, err := lintDuplicates(files)
+	if err != nil {
+		return nil, err
+	}
+	findings = append(findings, duplicates...)
+	if len(files) > LintMaxFiles {
+		findings = append(findings, LintFinding{
+			Rule:    LintFileCount,
+			Message: fmt.Sprintf("%d files (more than %d) slow down Intune downloads and installs; archive rarely changed folders and extract them in the install script", len(files), LintMaxFiles),
+		})
+	}
+	return findings, nil
+}
+
+// lintSetupFile checks that the setup file exists at the root and has a plain name
+func lintSetupFile(files []lintFile, setupFile string) []LintFinding {
+	setupFile = filepath.ToSlash(setupFile)
+	if setupFile == "" {
+		var atRoot, nested []string
+		for _, f := range files {
+			if !IsSetupFileType(f.rel) {
+				continue
+			}
+			if strings.Contains(f.rel, "/") {
+				nested = append(nested, f.rel)
+			} else {
+				atRoot = append(atRoot, f.rel)
+			}
+		}
+		if len(atRoot) == 0 {
+			if len(nested) == 0 {
+				return []LintFinding{{Rule: LintSetupMissing, Message: "no setup file found (supported: " + strings.Join(SetupFileTypes, ", ") + ")"}}
+			}
+			setup := PreferredSetupFile(nested)
+			return []LintFinding{{Rule: LintSetupNotAtRoot, Path: setup, Message: "no setup file at the root; use the folder of " + path.Base(setup) + " as the source folder, or start it from a script at the root"}}
+		}
+		setupFile = PreferredSetupFile(atRoot)
+	}
+
+	if !hasLintFile(files, setupFile) {
+		return []LintFinding{{Rule: LintSetupMissing, Path: setupFile, Message: "setup file not found in the source folder"}}
+	}
+
+	var findings []LintFinding
+	if strings.Contains(setupFile, "/") {
+		findings = append(findings, LintFinding{Rule: LintSetupNotAtRoot, Path: setupFile, Message: "setup file is in a subfolder; Intune runs install commands from the root, so use its folder as the source folder"})
+	}
+	name := path.Base(setupFile)
+	if strings.ContainsFunc(name, unicode.IsSpace) {
+		findings = append(findings, LintFinding{Rule: LintSetupName, Path: setupFile, Message: "setup file name contains spaces; every install command must quote it"})
+	}
+	if strings.ContainsFunc(name, func(r rune) bool { return r > unicode.MaxASCII }) {
+		findings = append(findings, LintFinding{Rule: LintSetupName, Path: setupFile, Message: "setup file name contains non-ASCII characters, which install commands may not pass on intact"})
+	}
+	return findings
+}
+
+// lintFileType checks for nested packages, disk images and large archives
+func lintFileType(f lintFile, files []lintFile) []LintFinding {
+	ext := strings.ToLower(path.Ext(f.rel))
+	switch {
+	case ext == ".intunewin":
+		return []LintFinding{{Rule: LintNestedPackage, Path: f.rel, Message: "a .intunewin package inside the source is encrypted content Intune cannot install; remove it or fix the output folder"}}
+	case f.size < lintArchiveSize:
+		return nil
+	case slices.Contains(diskImageTypes, ext):
+		return []LintFinding{{Rule: LintDiskImage, Path: f.rel, Message: fmt.Sprintf("%s disk image; package the installer extracted from it instead", FormatSize(f.size))}}
+	case slices.Contains(archiveTypes, ext):
+		message := fmt.Sprintf("%s archive; package its extracted content or extract it in the install script", FormatSize(f.size))
+		if ext == ".zip" && zipExtractedNextTo(f, files) {
+			message = fmt.Sprintf("%s archive whose content is also extracted in the folder, so it is packaged twice", FormatSize(f.size))
+		}
+		return []LintFinding{{Rule: LintLargeArchive, Path: f.rel, Message: message}}
+	}
+	return nil
+}
+
+// zipExtractedNextTo reports whether most files of a ZIP also exist, with the same size, in
+// its folder or in a folder named after it
+func zipExtractedNextTo(archive lintFile, files []lintFile) bool {
+	zr, err := zip.OpenReader(archive.path)
+	if err != nil {
+		return false
+	}
+	defer zr.Close()
+
+	sizes := make(map[string]int64, len(files))
+	for _, f := range files {
+		sizes[strings.ToLower(f.rel)] = f.size
+	}
+	dir := path.Dir(archive.rel)
+	named := path.Join(dir, strings.TrimSuffix(path.Base(archive.rel), path.Ext(archive.rel)))
+
+	var entries, extracted int
+	for _, entry := range zr.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		entries++
+		for _, base := range []string{dir, named} {
+			if size, ok := sizes[strings.ToLower(path.Join(base, entry.Name))]; ok && size == int64(entry.UncompressedSize64) {
+				extracted++
+				break
+			}
+		}
+	}
+	return entries > 0 && extracted*2 >= entries
+}
+
+// lintPSADT checks that a PowerShell App Deployment Toolkit package has the toolkit files
+func lintPSADT(files []lintFile) []LintFinding {
+	scripts := make([]string, 0, len(psadtLayouts))
+	for script := range psadtLayouts {
+		scripts = append(scripts, script)
+	}
+	sort.Strings(scripts)
+
+	var findings []LintFinding
+	for _, script := range scripts {
+		launcher := strings.TrimSuffix(script, ".ps1") + ".exe"
+		switch {
+		case hasLintFile(files, script):
+			for _, required := range psadtLayouts[script] {
+				if !hasLintFile(files, required) {
+					findings = append(findings, LintFinding{Rule: LintPSADT, Path: required, Message: "missing PSAppDeployToolkit file required by " + script})
+				}
+			}
+		case hasLintFile(files, launcher):
+			findings = append(findings, LintFinding{Rule: LintPSADT, Path: script, Message: "missing the deployment script " + launcher + " runs"})
+		}
+	}
+	return findings
+}
+
+// lintDuplicates finds large files with identical content
+func lintDuplicates(files []lintFile) ([]LintFinding, error) {
+	bySize := map[int64][]lintFile{}
+	for _, f := range files {
+		if f.size >= lintDuplicateSize {
+			bySize[f.size] = append(bySize[f.size], f)
+		}
+	}
+
+	var findings []LintFinding
+	for _, f := range files {
+		same := bySize[f.size]
+		if len(same) < 2 || same[0].rel != f.rel {
+			continue
+		}
+		byHash := map[string][]string{}
+		var order []string
+		for _, candidate := range same {
+			digest, err := fileSHA256(candidate.path)
+			if err != nil {
+				return nil, err
+			}
+			if len(byHash[digest]) == 0 {
+				order = append(order, digest)
+			}
+			byHash[digest] = append(byHash[digest], candidate.rel)
+		}
+		for _, digest := range order {
+			paths := byHash[digest]
+			for _, dup := range paths[1:] {
+				findings = append(findings, LintFinding{Rule: LintDuplicateFile, Path: dup, Message: fmt.Sprintf("%s, identical to %s", FormatSize(f.size), paths[0])})
+			}
+		}
+	}
+	return findings, nil
+}
+
+// fileSHA256 returns the hex SHA256 of a file
+func fileSHA256(name string) (string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hasLintFile reports whether the files include rel, case-insensitively like Windows
+func hasLintFile(files []lintFile, rel string) bool {
+	for _, f := range files {
+		if strings.EqualFold(f.rel, rel) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package packager
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// lintRules returns the findings as "rule path" strings
+func lintRules(findings []LintFinding) []string {
+	var rules []string
+	for _, f := range findings {
+		rules = append(rules, f.Rule+" "+f.Path)
+	}
+	return rules
+}
+
+func TestLintSource(t *testing.T) {
+	defer func(archive, duplicate int64) {
+		lintArchiveSize, lintDuplicateSize = archive, duplicate
+	}(lintArchiveSize, lintDuplicateSize)
+	lintArchiveSize, lintDuplicateSize = 16, 16
+
+	tests := map[string]struct {
+		files map[string]string
+		setup string
+		want  []string
+	}{
+		"clean": {
+			files: map[string]string{"setup.exe": "installer", "config/settings.ini": "[app]"},
+			want:  nil,
+		},
+		"setup in subfolder": {
+			files: map[string]string{"x64/setup.exe": "installer", "readme.txt": "notes"},
+			want:  []string{"setup-not-at-root x64/setup.exe"},
+		},
+		"given setup in subfolder": {
+			files: map[string]string{"x64/setup.exe": "installer"},
+			setup: "x64/setup.exe",
+			want:  []string{"setup-not-at-root x64/setup.exe"},
+		},
+		"missing setup": {
+			files: map[string]string{"setup.exe": "installer"},
+			setup: "install.msi",
+			want:  []string{"setup-missing install.msi"},
+		},
+		"no setup": {
+			files: map[string]string{"readme.txt": "notes"},
+			want:  []string{"setup-missing "},
+		},
+		"setup name": {
+			files: map[string]string{"My Setup ä.exe": "installer"},
+			setup: "My Setup ä.exe",
+			want:  []string{"setup-file-name My Setup ä.exe", "setup-file-name My Setup ä.exe"},
+		},
+		"nested package": {
+			files: map[string]string{"setup.exe": "installer", "output/setup.intunewin": "package"},
+			want:  []string{"nested-package output/setup.intunewin"},
+		},
+		"disk image and archive": {
+			files: map[string]string{"setup.exe": "installer", "media/office.iso": strings.Repeat("i", 32), "drivers.7z": strings.Repeat("z", 32), "small.zip": "zip"},
+			want:  []string{"large-archive drivers.7z", "disk-image media/office.iso"},
+		},
+		"duplicates": {
+			files: map[string]string{"setup.exe": "installer", "a/runtime.dll": strings.Repeat("r", 20), "b/runtime.dll": strings.Repeat("r", 20), "c/other.dll": strings.Repeat("o", 20)},
+			want:  []string{"duplicate-file b/runtime.dll"},
+		},
+		"psadt v3": {
+			files: map[string]string{"Deploy-Application.exe": "launcher", "Deploy-Application.ps1": "script", "AppDeployToolkit/AppDeployToolkitMain.ps1": "main"},
+			setup: "Deploy-Application.exe",
+			want:  []string{"psadt-incomplete AppDeployToolkit/AppDeployToolkitConfig.xml", "psadt-incomplete AppDeployToolkit/AppDeployToolkitExtensions.ps1"},
+		},
+		"psadt v4 without script": {
+			files: map[string]string{"Invoke-AppDeployToolkit.exe": "launcher"},
+			setup: "Invoke-AppDeployToolkit.exe",
+			want:  []string{"psadt-incomplete Invoke-AppDeployToolkit.ps1"},
+		},
+		"psadt v4 complete": {
+			files: map[string]string{"Invoke-AppDeployToolkit.ps1": "script", "PSAppDeployToolkit/PSAppDeployToolkit.psd1": "manifest", "PSAppDeployToolkit/PSAppDeployToolkit.psm1": "module"},
+			setup: "Invoke-AppDeployToolkit.ps1",
+			want:  nil,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTree(t, dir, tt.files)
+			findings, err := LintSource(dir, tt.setup, Options{})
+			if err != nil {
+				t.Fatalf("LintSource() error = %v", err)
+			}
+			if got := lintRules(findings); strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("LintSource() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintSourceExcludeAndErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"setup.exe": "installer", "old/setup.intunewin": "package"})
+	findings, err := LintSource(dir, "setup.exe", Options{Exclude: []string{"old"}})
+	if err != nil {
+		t.Fatalf("LintSource() error = %v", err)
+	}
+	if len(findings) != 0 {
+		t.Errorf("LintSource() = %v, want excluded files skipped", findings)
+	}
+
+	if _, err := LintSource(filepath.Join(dir, "missing"), "", Options{}); err == nil {
+		t.Error("Expected error for a missing source folder")
+	}
+	if _, err := LintSource(filepath.Join(dir, "setup.exe"), "", Options{}); err == nil {
+		t.Error("Expected error for a file as source folder")
+	}
+}
+
+func TestLintArchiveExtracted(t *testing.T) {
+	defer func(size int64) { lintArchiveSize = size }(lintArchiveSize)
+	lintArchiveSize = 1
+
+	dir := t.TempDir()
+	content := map[string]string{"app/app.exe": "application", "app/lib.dll": "library"}
+	writeTree(t, dir, map[string]string{"setup.exe": "installer"})
+	writeTree(t, filepath.Join(dir, "payload"), content)
+
+	out, err := os.Create(filepath.Join(dir, "payload.zip"))
+	if err != nil {
+		t.Fatalf("Failed to create ZIP: %v", err)
+	}
+	zw := zip.NewWriter(out)
+	for name, data := range content {
+		w, _ := zw.Create(name)
+		w.Write([]byte(data))
+	}
+	zw.Close()
+	out.Close()
+
+	findings, err := LintSource(dir, "setup.exe", Options{})
+	if err != nil {
+		t.Fatalf("LintSource() error = %v", err)
+	}
+	if len(findings) != 1 || findings[0].Rule != LintLargeArchive || !strings.Contains(findings[0].Message, "packaged twice") {
+		t.Errorf("LintSource() = %v, want the ZIP reported as extracted", findings)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build !windows
+
+package packager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkFileLock opens the package for writing; advisory locks of other processes
+// are not detected, only files that cannot be opened for writing
+func checkFileLock(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) || isReadOnlyFSError(err) {
+			return fmt.Errorf("%s is %w", path, ErrReadOnly)
+		}
+		return fmt.Errorf("cannot open package for writing: %w", err)
+	}
+	return f.Close()
+}
+
+// isReadOnlyFSError reports whether err comes from a read-only file system
+func isReadOnlyFSError(err error) bool {
+	return errors.Is(err, syscall.EROFS)
+}
+
+// isLockError reports whether err comes from a file locked by another process; locks
+// are advisory outside Windows and never keep a file from being read
+func isLockError(err error) bool {
+	return false
+}
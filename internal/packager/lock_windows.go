@@ -0,0 +1,47 @@
+package packager
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+const (
+	// errorSharingViolation is ERROR_SHARING_VIOLATION: another process has the file open
+	errorSharingViolation syscall.Errno = 32
+	// errorLockViolation is ERROR_LOCK_VIOLATION: another process has locked part of the file
+	errorLockViolation syscall.Errno = 33
+	// errorWriteProtect is ERROR_WRITE_PROTECT: the media is write-protected
+	errorWriteProtect syscall.Errno = 19
+)
+
+// checkFileLock opens the package for writing without sharing, which fails while a
+// sync client (OneDrive, Dropbox) or any other process holds it open
+func checkFileLock(path string) error {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return fmt.Errorf("invalid path %s: %w", path, err)
+	}
+	handle, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil,
+		syscall.OPEN_EXISTING, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	switch {
+	case errors.Is(err, errorSharingViolation), errors.Is(err, errorLockViolation):
+		return fmt.Errorf("%s is %w", path, ErrLocked)
+	case errors.Is(err, syscall.ERROR_ACCESS_DENIED), isReadOnlyFSError(err):
+		return fmt.Errorf("%s is %w", path, ErrReadOnly)
+	case err != nil:
+		return fmt.Errorf("cannot open package for writing: %w", err)
+	}
+	return syscall.CloseHandle(handle)
+}
+
+// isReadOnlyFSError reports whether err comes from write-protected media
+func isReadOnlyFSError(err error) bool {
+	return errors.Is(err, errorWriteProtect)
+}
+
+// isLockError reports whether err comes from a file another process holds open without
+// sharing it, or has locked part of
+func isLockError(err error) bool {
+	return errors.Is(err, errorSharingViolation) || errors.Is(err, errorLockViolation)
+}
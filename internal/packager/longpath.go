@@ -0,0 +1,36 @@
+package packager
+
+import "strings"
+
+// Prefixes of Windows extended-length paths, which are not limited to MAX_PATH (260 characters)
+const (
+	longPathPrefix    = `\\?\`
+	longUNCPathPrefix = `\\?\UNC\`
+)
+
+// extendedLengthPath returns the extended-length form of an absolute Windows path:
+// C:\dir becomes \\?\C:\dir and \\server\share\dir becomes \\?\UNC\server\share\dir
+// Paths already in that form, device paths and relative paths are returned unchanged
+func extendedLengthPath(abs string) string {
+	switch {
+	case strings.HasPrefix(abs, longPathPrefix), strings.HasPrefix(abs, `\\.\`):
+		return abs
+	case strings.HasPrefix(abs, `\\`):
+		return longUNCPathPrefix + abs[2:]
+	case len(abs) >= 3 && abs[1] == ':' && abs[2] == '\\':
+		return longPathPrefix + abs
+	}
+	return abs
+}
+
+// normalPath returns path without an extended-length prefix, so \\?\C:\dir and C:\dir
+// (or \\?\UNC\server\share and \\server\share) compare equal
+func normalPath(path string) string {
+	switch {
+	case strings.HasPrefix(strings.ToUpper(path), longUNCPathPrefix):
+		return `\\` + path[len(longUNCPathPrefix):]
+	case strings.HasPrefix(path, longPathPrefix):
+		return path[len(longPathPrefix):]
+	}
+	return path
+}
@@ -0,0 +1,137 @@
+package packager
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtendedLengthPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{`C:\src\app`, `\\?\C:\src\app`},
+		{`\\fileserver\packages\app`, `\\?\UNC\fileserver\packages\app`},
+		{`\\?\C:\src\app`, `\\?\C:\src\app`},
+		{`\\?\UNC\fileserver\packages`, `\\?\UNC\fileserver\packages`},
+		{`\\.\PhysicalDrive0`, `\\.\PhysicalDrive0`},
+		{`src\app`, `src\app`},
+		{`/home/user/src`, `/home/user/src`},
+	}
+	for _, tt := range tests {
+		if got := extendedLengthPath(tt.path); got != tt.want {
+			t.Errorf("extendedLengthPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestNormalPath(t *testing.T) {
+	tests := map[string]string{
+		`\\?\C:\src`:                  `C:\src`,
+		`\\?\UNC\fileserver\packages`: `\\fileserver\packages`,
+		`\\?\unc\fileserver\packages`: `\\fileserver\packages`,
+		`\\fileserver\packages`:       `\\fileserver\packages`,
+		`C:\src`:                      `C:\src`,
+	}
+	for path, want := range tests {
+		if got := normalPath(path); got != want {
+			t.Errorf("normalPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+// deepTree creates files nested far beyond MAX_PATH (260 characters) under dir and
+// returns their relative slash-separated paths
+func deepTree(t *testing.T, dir string) []string {
+	t.Helper()
+	deep := ""
+	for i := 0; i < 15; i++ {
+		deep = filepath.Join(deep, "a-rather-long-folder-name-"+string(rune('a'+i)))
+	}
+	files := []string{
+		filepath.Join(deep, "setup.msi"),
+		filepath.Join(deep, "data", "payload-with-a-long-file-name-to-go-past-the-limit.cab"),
+	}
+	var names []string
+	for _, f := range files {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("content of "+filepath.Base(f)), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if len(path) <= 260 {
+			t.Fatalf("Test path is only %d characters", len(path))
+		}
+		names = append(names, filepath.ToSlash(f))
+	}
+	return names
+}
+
+func TestZipAndExtractDeepTree(t *testing.T) {
+	source := t.TempDir()
+	names := deepTree(t, source)
+
+	size, count, _, err := sourceStats(source, nil, SymlinkFollow)
+	if err != nil || count != len(names) || size == 0 {
+		t.Fatalf("sourceStats() = %d, %d, %v", size, count, err)
+	}
+
+	zipData, err := ZipFolder(source)
+	if err != nil {
+		t.Fatalf("ZipFolder() error = %v", err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
+	if err != nil {
+		t.Fatalf("Failed to read ZIP: %v", err)
+	}
+	found := make(map[string]bool)
+	for _, f := range reader.File {
+		found[f.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			t.Errorf("File %s not found in ZIP", name)
+		}
+	}
+
+	dest := t.TempDir()
+	extracted, err := ExtractZip(zipData, dest)
+	if err != nil {
+		t.Fatalf("ExtractZip() error = %v", err)
+	}
+	if extracted != len(names) {
+		t.Errorf("Extracted %d files, want %d", extracted, len(names))
+	}
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(name)))
+		if err != nil || string(data) != "content of "+filepath.Base(name) {
+			t.Errorf("Extracted %s = %q, %v", name, data, err)
+		}
+	}
+}
+
+func TestPackageDeepTree(t *testing.T) {
+	source := t.TempDir()
+	names := deepTree(t, source)
+	if err := os.WriteFile(filepath.Join(source, "setup.exe"), []byte("MZ"), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+	// The output folder is nested beyond MAX_PATH as well
+	output := filepath.Join(t.TempDir(), filepath.Dir(filepath.FromSlash(names[0])))
+
+	result, err := PackageWithOptions(source, "setup.exe", output, Options{}, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	if result.FileCount != len(names)+1 {
+		t.Errorf("FileCount = %d, want %d", result.FileCount, len(names)+1)
+	}
+	if _, err := VerifyPackage(result.OutputPath, false); err != nil {
+		t.Errorf("VerifyPackage() error = %v", err)
+	}
+}
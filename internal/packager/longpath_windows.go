@@ -0,0 +1,16 @@
+package packager
+
+import "path/filepath"
+
+// longPath returns path as an absolute extended-length path, so files in deep source trees
+// and on UNC shares can be opened beyond MAX_PATH; see extendedLengthPath
+func longPath(path string) string {
+	if normalPath(path) != path {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return extendedLengthPath(abs)
+}
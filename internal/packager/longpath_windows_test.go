@@ -0,0 +1,34 @@
+package packager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLongPath(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	if got, want := longPath(`src\app`), longPathPrefix+filepath.Join(wd, `src\app`); got != want {
+		t.Errorf("longPath(relative) = %q, want %q", got, want)
+	}
+	if got := longPath(`//fileserver/packages/app`); got != `\\?\UNC\fileserver\packages\app` {
+		t.Errorf("longPath(UNC) = %q", got)
+	}
+	if got := longPath(`\\?\C:\src`); got != `\\?\C:\src` {
+		t.Errorf("longPath(extended) = %q", got)
+	}
+	if !strings.HasPrefix(longPath(t.TempDir()), longPathPrefix) {
+		t.Error("Expected an extended-length temp folder")
+	}
+}
+
+func TestCheckpointDirLongPath(t *testing.T) {
+	// The checkpoint of a run does not depend on how the source path was written
+	if CheckpointDir("root", `\\?\C:\src`, "setup.msi", `C:\out`) != CheckpointDir("root", `C:\src`, "setup.msi", `\\?\C:\out`) {
+		t.Error("Expected the same checkpoint for extended-length and plain paths")
+	}
+}
@@ -0,0 +1,363 @@
+package packager
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LowMemoryThreshold is the source size above which packaging streams through temporary
+// files as with Options.LowMemory, unless the run is resumable
+const LowMemoryThreshold = 1 << 30
+
+// encryptChunkSize is the amount of content encrypted at a time when streaming
+var encryptChunkSize = 64 << 20
+
+// useLowMemory reports whether a run streams through temporary files
+// Resumable runs keep their phases in memory and in checkpoint files instead
+func useLowMemory(opts Options, sourceSize int64) bool {
+	if opts.CheckpointRoot != "" {
+		return false
+	}
+	return opts.LowMemory || sourceSize >= LowMemoryThreshold
+}
+
+// streamRun carries what the streaming steps need from the earlier packaging steps
+type streamRun struct {
+	sourcePath string
+	setupFile  string
+	outputPath string
+	opts       Options
+	modTime    time.Time
+	msiInfo    *MsiInfo
+	suite      *MsiSuite
+	suiteFiles []ZipEntry
+	report     func(step string, pct float64)
+	compressed func(done, total int64)
+	log        *slog.Logger
+}
+
+// packageStreaming compresses, encrypts and assembles a package through temporary files
+// instead of memory, so memory use stays flat whatever the size of the source
+// The content ZIP is hashed while it is written, encrypted in chunks into a second file
+// with the HMAC computed on the way, and copied into the package; the package is the
+// same as the one built in memory
+func packageStreaming(ctx context.Context, run streamRun) (*PackageResult, error) {
+	opts, tracer, report, log := run.opts, run.opts.Tracer, run.report, run.log
+
+	tmpDir, err := os.MkdirTemp("", "intunewin-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary folder: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	log.Debug("packaging through temporary files", "dir", tmpDir)
+
+	// Step 3: Compress source folder (10-40%)
+	report("Compressing files", 0.15)
+
+	endPhase := tracer.StartPhase("compress")
+	zipFile, err := os.Create(filepath.Join(tmpDir, "content.zip"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer zipFile.Close()
+
+	previous, err := openPrevious(opts, report, log)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil {
+		defer previous.Close()
+	}
+
+	var reusedFiles int
+	var reusedSize int64
+	digest := sha256.New()
+	buffered := bufio.NewWriterSize(io.MultiWriter(zipFile, digest), 1<<20)
+	err = zipFolderTo(buffered, run.sourcePath, ZipOptions{
+		Progress: func(file string, pct float64) {
+			report(fmt.Sprintf("Compressing: %s", file), 0.15+(pct*0.25))
+		},
+		Compressed:           run.compressed,
+		Context:              ctx,
+		Tracer:               tracer,
+		Exclude:              opts.Exclude,
+		Symlinks:             opts.Symlinks,
+		NormalizePermissions: opts.NormalizePermissions,
+		Compression:          opts.Compression,
+		Variables:            opts.Variables,
+		ContentStore:         opts.ContentStore,
+		HashCache:            opts.HashCache,
+		Previous:             previous,
+		ModTime:              run.modTime,
+		Reused: func(file string, size int64) {
+			reusedFiles++
+			reusedSize += size
+		},
+		Extra: run.suiteFiles,
+	})
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("compression failed: %w", err)
+	}
+	if err := buffered.Flush(); err != nil {
+		return nil, fmt.Errorf("compression failed: %w", err)
+	}
+	zipSize, err := zipFile.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("compression failed: %w", err)
+	}
+	fileDigest := digest.Sum(nil)
+	endPhase()
+
+	report("Compression complete", 0.40)
+
+	// Step 4: Encrypt content (40-70%)
+	report("Encrypting content", 0.45)
+
+	endPhase = tracer.StartPhase("encrypt")
+	encKey, macKey, iv, err := packageKeys(opts, fileDigest)
+	if err != nil {
+		return nil, fmt.Errorf("encryption failed: %w", err)
+	}
+	encFile, err := os.Create(filepath.Join(tmpDir, "content.enc"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer encFile.Close()
+	started := time.Now()
+	encrypting := stepProgress(report, "Encrypting content", 0.45, 0.70)
+	mac, err := encryptStream(encFile, io.NewSectionReader(zipFile, 0, zipSize), encKey, macKey, iv, func(done int64) {
+		encrypting(done, zipSize)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encryption failed: %w", err)
+	}
+	encryptedSize, err := encFile.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("encryption failed: %w", err)
+	}
+	encryptionTime := time.Since(started)
+	logEncryption(log, encryptedSize, encryptionTime)
+	encInfo := &EncryptionInfo{
+		EncryptionKey:        encKey,
+		MacKey:               macKey,
+		InitializationVector: iv,
+		Mac:                  mac,
+		FileDigest:           fileDigest,
+	}
+	endPhase()
+
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	report("Encryption complete", 0.70)
+
+	// Step 5: Generate metadata XML (70-80%)
+	report("Generating metadata", 0.75)
+
+	endPhase = tracer.StartPhase("metadata")
+	detectionXML, err := GenerateDetectionXML(&MetadataParams{
+		Name:                   GetApplicationName(run.setupFile),
+		SetupFile:              run.setupFile,
+		UnencryptedContentSize: zipSize,
+		EncryptionInfo:         encInfo,
+		MsiInfo:                run.msiInfo,
+		ToolVersion:            opts.ToolVersion,
+		MsiOverrides:           opts.MsiOverrides,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("metadata generation failed: %w", err)
+	}
+	endPhase()
+
+	// Step 6: Create final package (80-95%)
+	report("Creating package", 0.85)
+
+	endPhase = tracer.StartPhase("assemble")
+	modTime := run.modTime
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+	packageFile, err := os.Create(filepath.Join(tmpDir, "package.intunewin"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer packageFile.Close()
+	buffered = bufio.NewWriterSize(packageFile, 1<<20)
+	if err := writeIntunewinPackage(buffered, io.NewSectionReader(encFile, 0, encryptedSize), detectionXML, modTime); err != nil {
+		return nil, fmt.Errorf("package creation failed: %w", err)
+	}
+	if err := buffered.Flush(); err != nil {
+		return nil, fmt.Errorf("package creation failed: %w", err)
+	}
+	finalSize, err := packageFile.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("package creation failed: %w", err)
+	}
+	endPhase()
+	if err := checkFinalSize(finalSize, opts); err != nil {
+		return nil, err
+	}
+
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+
+	// Step 7: Write output file (95-100%)
+	report("Writing output file", 0.95)
+
+	endPhase = tracer.StartPhase("write")
+	if opts.OutputWriter == nil {
+		if err := os.MkdirAll(longPath(run.outputPath), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+	outputFilePath := packageFilePath(run.outputPath, run.setupFile, opts)
+	written, err := writeOutputFrom(ctx, outputFilePath, packageFile, finalSize, opts, log)
+	if err != nil {
+		return nil, err
+	}
+	endPhase()
+
+	log.Info("package created", "path", outputFilePath, "bytes", finalSize)
+
+	keysPath, err := exportPackageKeys(opts, outputFilePath, detectionXML)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifestPath string
+	if opts.Manifest {
+		endPhase = tracer.StartPhase("manifest")
+		files, err := buildManifestFilesFrom(zipFile, zipSize)
+		if err != nil {
+			return nil, fmt.Errorf("manifest generation failed: %w", err)
+		}
+		packageSum := sha256.New()
+		if _, err := io.Copy(packageSum, io.NewSectionReader(packageFile, 0, finalSize)); err != nil {
+			return nil, fmt.Errorf("manifest generation failed: %w", err)
+		}
+		manifest := newManifest(outputFilePath, run.setupFile, files, packageSum.Sum(nil))
+		if !opts.License.Empty() {
+			manifest.License = opts.License
+		}
+		manifest.MsiSuite = run.suite
+		manifestPath = ManifestPath(outputFilePath)
+		if err := WriteManifest(manifestPath, manifest); err != nil {
+			return nil, err
+		}
+		log.Debug("manifest written", "path", manifestPath, "files", manifest.FileCount)
+		endPhase()
+	}
+
+	return &PackageResult{
+		OutputPath:       outputFilePath,
+		ZipSize:          zipSize,
+		EncryptedSize:    encryptedSize,
+		EncryptionTime:   encryptionTime,
+		FinalSize:        finalSize,
+		ManifestPath:     manifestPath,
+		ReusedFiles:      reusedFiles,
+		ReusedSize:       reusedSize,
+		KeysPath:         keysPath,
+		Verified:         written.verified,
+		WriteAttempts:    written.attempts,
+		LowMemory:        true,
+		GeneratorVersion: GeneratorVersion,
+	}, nil
+}
+
+// encryptStream encrypts src into dst in the format of EncryptContent, a chunk at a time
+// The HMAC is computed while the ciphertext is written and stored at the start of dst last
+// progress receives the bytes of src encrypted so far after each chunk (optional)
+// Returns the HMAC
+func encryptStream(dst io.WriteSeeker, src io.Reader, encKey, macKey, iv []byte, progress func(done int64)) ([]byte, error) {
+	if len(encKey) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(encKey))
+	}
+	if len(macKey) != 32 {
+		return nil, fmt.Errorf("MAC key must be 32 bytes, got %d", len(macKey))
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("IV must be 16 bytes, got %d", len(iv))
+	}
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+	}
+	mode := cipher.NewCBCEncrypter(block, iv)
+	mac := hmac.New(sha256.New, macKey)
+
+	// Room for the HMAC, written once all content is encrypted
+	if _, err := dst.Write(make([]byte, sha256.Size)); err != nil {
+		return nil, err
+	}
+	out := io.MultiWriter(dst, mac)
+	if _, err := out.Write(iv); err != nil {
+		return nil, err
+	}
+
+	// Each chunk is written and added to the HMAC while the next one is read and
+	// encrypted; buffers return to free once written. Two buffers are enough for that
+	// overlap and keep memory at twice the chunk size
+	size := encryptChunkSize - encryptChunkSize%aes.BlockSize
+	free := make(chan []byte, 2)
+	for i := 0; i < cap(free); i++ {
+		free <- make([]byte, size, size+aes.BlockSize)
+	}
+	writes := newChunkPipeline(func(chunk []byte) error {
+		_, err := out.Write(chunk)
+		free <- chunk[:size]
+		return err
+	})
+
+	// Full chunks are multiples of the block size; the last one is padded
+	var read int64
+	for {
+		buf := <-free
+		n, err := io.ReadFull(src, buf)
+		read += int64(n)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			last := PKCS7Pad(buf[:n], aes.BlockSize)
+			mode.CryptBlocks(last, last)
+			writes.send(last)
+			if progress != nil {
+				progress(read)
+			}
+			break
+		}
+		if err != nil {
+			writes.close()
+			return nil, fmt.Errorf("failed to read content: %w", err)
+		}
+		mode.CryptBlocks(buf, buf)
+		writes.send(buf)
+		if progress != nil {
+			progress(read)
+		}
+	}
+	if err := writes.close(); err != nil {
+		return nil, err
+	}
+
+	sum := mac.Sum(nil)
+	if _, err := dst.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := dst.Write(sum); err != nil {
+		return nil, err
+	}
+	return sum, nil
+}
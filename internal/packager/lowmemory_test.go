@@ -0,0 +1,141 @@
+package packager
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// seekBuffer is an in-memory io.WriteSeeker
+type seekBuffer struct {
+	data []byte
+	pos  int
+}
+
+func (b *seekBuffer) Write(p []byte) (int, error) {
+	if end := b.pos + len(p); end > len(b.data) {
+		b.data = append(b.data, make([]byte, end-len(b.data))...)
+	}
+	copy(b.data[b.pos:], p)
+	b.pos += len(p)
+	return len(p), nil
+}
+
+func (b *seekBuffer) Seek(offset int64, whence int) (int64, error) {
+	b.pos = int(offset) // only io.SeekStart is used
+	return offset, nil
+}
+
+func TestEncryptStreamMatchesEncryptContent(t *testing.T) {
+	defer func(size int) { encryptChunkSize = size }(encryptChunkSize)
+	encryptChunkSize = 32
+
+	encKey, macKey, iv, err := GenerateKeys()
+	if err != nil {
+		t.Fatalf("GenerateKeys() error = %v", err)
+	}
+	// Sizes around the block and chunk boundaries
+	for _, size := range []int{0, 1, 15, 16, 17, 31, 32, 33, 64, 100, 4096} {
+		plaintext := make([]byte, size)
+		rand.Read(plaintext)
+
+		want, err := EncryptContent(append([]byte(nil), plaintext...), encKey, macKey, append([]byte(nil), iv...))
+		if err != nil {
+			t.Fatalf("EncryptContent() error = %v", err)
+		}
+		var got seekBuffer
+		mac, err := encryptStream(&got, bytes.NewReader(plaintext), encKey, macKey, iv, nil)
+		if err != nil {
+			t.Fatalf("encryptStream(%d bytes) error = %v", size, err)
+		}
+		if !bytes.Equal(got.data, want) {
+			t.Errorf("encryptStream(%d bytes) differs from EncryptContent", size)
+		}
+		if !bytes.Equal(mac, want[:32]) {
+			t.Errorf("encryptStream(%d bytes) returned a different HMAC", size)
+		}
+	}
+}
+
+func TestLowMemoryPackageMatchesInMemory(t *testing.T) {
+	defer func(size int) { encryptChunkSize = size }(encryptChunkSize)
+	encryptChunkSize = 4096
+
+	sourceDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "setup.exe"), []byte("installer"), 0755); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+	large := make([]byte, 100*1024)
+	rand.Read(large)
+	if err := os.WriteFile(filepath.Join(sourceDir, "payload.bin"), large, 0644); err != nil {
+		t.Fatalf("Failed to write payload: %v", err)
+	}
+
+	// A seeded reproducible build fixes everything but the way the package is built
+	build := func(lowMemory bool) (*PackageResult, []byte) {
+		t.Helper()
+		opts := Options{
+			Reproducible: &Reproducible{ModTime: time.Unix(1700000000, 0), Seed: []byte("seed")},
+			LowMemory:    lowMemory,
+			Manifest:     true,
+		}
+		result, err := PackageWithOptions(sourceDir, "setup.exe", t.TempDir(), opts, nil)
+		if err != nil {
+			t.Fatalf("PackageWithOptions(LowMemory: %v) error = %v", lowMemory, err)
+		}
+		data, err := os.ReadFile(result.OutputPath)
+		if err != nil {
+			t.Fatalf("Failed to read package: %v", err)
+		}
+		return result, data
+	}
+
+	memResult, memPackage := build(false)
+	lowResult, lowPackage := build(true)
+	if memResult.LowMemory || !lowResult.LowMemory {
+		t.Errorf("LowMemory = %v and %v, want false and true", memResult.LowMemory, lowResult.LowMemory)
+	}
+	if !bytes.Equal(memPackage, lowPackage) {
+		t.Error("Low-memory package differs from the in-memory package")
+	}
+	if lowResult.ZipSize != memResult.ZipSize || lowResult.EncryptedSize != memResult.EncryptedSize ||
+		lowResult.FinalSize != memResult.FinalSize || lowResult.FileCount != 2 {
+		t.Errorf("Sizes differ: in memory %+v, low memory %+v", memResult, lowResult)
+	}
+
+	// Manifests only differ in their creation time
+	readManifest := func(path string) Manifest {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read manifest: %v", err)
+		}
+		var manifest Manifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			t.Fatalf("Failed to parse manifest: %v", err)
+		}
+		manifest.Created = time.Time{}
+		return manifest
+	}
+	memManifest, lowManifest := readManifest(memResult.ManifestPath), readManifest(lowResult.ManifestPath)
+	if !reflect.DeepEqual(memManifest, lowManifest) {
+		t.Errorf("Manifests differ:\n%+v\n%+v", memManifest, lowManifest)
+	}
+
+	files, err := PackageFootprint(lowResult.OutputPath)
+	if err != nil {
+		t.Fatalf("PackageFootprint() error = %v", err)
+	}
+	if len(files) != 2 || files[0].Path != "payload.bin" || files[0].Size != int64(len(large)) {
+		t.Errorf("Unexpected package content: %+v", files)
+	}
+
+	if _, err := PackageWithOptions(sourceDir, "setup.exe", t.TempDir(), Options{LowMemory: true, CheckpointRoot: t.TempDir()}, nil); err == nil {
+		t.Error("Expected low-memory resumable runs to be rejected")
+	}
+}
@@ -0,0 +1,384 @@
+package packager
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// MacDetectionXMLPath is the location of Detection.xml inside a .intunemac package
+	MacDetectionXMLPath = "IntuneMacPackage/Metadata/Detection.xml"
+	// MacEncryptedContentPath is the location of the encrypted content inside a .intunemac package
+	MacEncryptedContentPath = "IntuneMacPackage/Contents/IntunePackage.intunemac"
+	// MacPackageExtension is the extension of macOS LOB packages
+	MacPackageExtension = ".intunemac"
+
+	// diskImageTrailerSize is the size of the "koly" trailer at the end of a UDIF disk image
+	diskImageTrailerSize = 512
+)
+
+// MacApp is an app bundle installed by a macOS package
+type MacApp struct {
+	BundleID string
+	// Version is CFBundleShortVersionString, Build is CFBundleVersion
+	Version string
+	Build   string
+	// Path is the bundle path relative to the install location, e.g. ./Contoso.app
+	Path string
+}
+
+// MacPackageInfo is the metadata of a macOS .pkg or .dmg
+type MacPackageInfo struct {
+	// Title is the product title of a distribution package, "" when it has none
+	Title string
+	// BundleID and Version identify the primary app Intune detects the install by
+	BundleID string
+	Version  string
+	// Apps are the app bundles of the package, the primary app first
+	Apps []MacApp
+}
+
+// IsMacPackageFile reports whether a file name is a macOS flat package (.pkg)
+func IsMacPackageFile(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".pkg")
+}
+
+// IsDiskImageFile reports whether a file name is a macOS disk image (.dmg)
+func IsDiskImageFile(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".dmg")
+}
+
+// IsMacSetupFile reports whether a file name can be wrapped in a .intunemac package
+func IsMacSetupFile(name string) bool {
+	return IsMacPackageFile(name) || IsDiskImageFile(name)
+}
+
+// distributionXML is the part of a product archive's Distribution file that is used
+type distributionXML struct {
+	Title   string `xml:"title"`
+	Product *struct {
+		ID      string `xml:"id,attr"`
+		Version string `xml:"version,attr"`
+	} `xml:"product"`
+	PkgRefs []struct {
+		ID      string `xml:"id,attr"`
+		Version string `xml:"version,attr"`
+	} `xml:"pkg-ref"`
+}
+
+// pkgInfoXML is a component package's PackageInfo file
+type pkgInfoXML struct {
+	Identifier string         `xml:"identifier,attr"`
+	Version    string         `xml:"version,attr"`
+	Bundles    []pkgBundleXML `xml:"bundle"`
+}
+
+// pkgBundleXML is a bundle listed in PackageInfo
+type pkgBundleXML struct {
+	Path         string `xml:"path,attr"`
+	ID           string `xml:"id,attr"`
+	ShortVersion string `xml:"CFBundleShortVersionString,attr"`
+	Version      string `xml:"CFBundleVersion,attr"`
+}
+
+// ExtractPkgInfo reads the bundle ID and version of a macOS flat package
+// App bundles come from the PackageInfo of each component package; the product ID and
+// version of the Distribution file (or the package identifier) are used when it lists none
+func ExtractPkgInfo(pkgPath string) (*MacPackageInfo, error) {
+	archive, err := openXar(pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	names := archive.Names()
+	sort.Strings(names)
+
+	info := &MacPackageInfo{}
+	var fallbackID, fallbackVersion string
+
+	if data, err := archive.ReadFile("Distribution"); err == nil {
+		var dist distributionXML
+		if err := xml.Unmarshal(data, &dist); err != nil {
+			return nil, fmt.Errorf("failed to parse Distribution: %w", err)
+		}
+		info.Title = strings.TrimSpace(dist.Title)
+		if dist.Product != nil {
+			fallbackID, fallbackVersion = dist.Product.ID, dist.Product.Version
+		}
+		for _, ref := range dist.PkgRefs {
+			if fallbackID == "" && ref.ID != "" && ref.Version != "" {
+				fallbackID, fallbackVersion = ref.ID, ref.Version
+			}
+		}
+	}
+
+	for _, name := range names {
+		if path.Base(name) != "PackageInfo" {
+			continue
+		}
+		data, err := archive.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		var pkgInfo pkgInfoXML
+		if err := xml.Unmarshal(data, &pkgInfo); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		if fallbackID == "" {
+			fallbackID, fallbackVersion = pkgInfo.Identifier, pkgInfo.Version
+		}
+		for _, b := range pkgInfo.Bundles {
+			if b.ID == "" || !strings.HasSuffix(strings.TrimSuffix(b.Path, "/"), ".app") {
+				continue
+			}
+			info.Apps = append(info.Apps, MacApp{
+				BundleID: b.ID,
+				Version:  firstNonEmptyString(b.ShortVersion, b.Version),
+				Build:    b.Version,
+				Path:     b.Path,
+			})
+		}
+	}
+
+	info.BundleID, info.Version = fallbackID, fallbackVersion
+	if len(info.Apps) > 0 {
+		primary := primaryApp(info.Apps, fallbackID, fallbackVersion)
+		info.BundleID, info.Version = primary.BundleID, primary.Version
+		info.Apps = primaryAppFirst(info)
+	}
+	if info.BundleID == "" {
+		return nil, fmt.Errorf("no bundle ID found in %s", filepath.Base(pkgPath))
+	}
+	return info, nil
+}
+
+// primaryApp picks the app a package is detected by: the app of the product ID, else the
+// first app of the product version, else the first app
+func primaryApp(apps []MacApp, productID, productVersion string) MacApp {
+	for _, app := range apps {
+		if app.BundleID == productID {
+			return app
+		}
+	}
+	for _, app := range apps {
+		if productVersion != "" && app.Version == productVersion {
+			return app
+		}
+	}
+	return apps[0]
+}
+
+// firstNonEmptyString returns the first value that is not empty
+func firstNonEmptyString(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// checkDiskImage verifies that a file ends with the trailer of a UDIF disk image
+func checkDiskImage(dmgPath string) error {
+	f, err := os.Open(dmgPath)
+	if err != nil {
+		return fmt.Errorf("failed to open disk image: %w", err)
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat disk image: %w", err)
+	}
+	trailer := make([]byte, 4)
+	if stat.Size() < diskImageTrailerSize {
+		return fmt.Errorf("%s is not a disk image", filepath.Base(dmgPath))
+	}
+	if _, err := f.ReadAt(trailer, stat.Size()-diskImageTrailerSize); err != nil || string(trailer) != "koly" {
+		return fmt.Errorf("%s is not a disk image", filepath.Base(dmgPath))
+	}
+	return nil
+}
+
+// MacOSAppInfoXML is the macOS app metadata of a .intunemac Detection.xml
+// Element names follow the macOSLobApp properties of Microsoft Graph
+type MacOSAppInfoXML struct {
+	PrimaryBundleID      string           `xml:"PrimaryBundleId"`
+	PrimaryBundleVersion string           `xml:"PrimaryBundleVersion"`
+	IncludedApps         []IncludedAppXML `xml:"IncludedApps>IncludedApp"`
+}
+
+// IncludedAppXML is an app bundle of a .intunemac package
+type IncludedAppXML struct {
+	BundleID      string `xml:"BundleId"`
+	BundleVersion string `xml:"BundleVersion"`
+}
+
+// MacOptions configures PackageMac
+type MacOptions struct {
+	// Name overrides the app name (default: the product title, or the file name)
+	Name string
+	// BundleID and Version override the values read from a .pkg; both are required for a .dmg
+	BundleID string
+	Version  string
+	// ToolVersion overrides the ToolVersion attribute (optional, defaults to ToolVersion)
+	ToolVersion string
+}
+
+// MacPackageResult describes a created .intunemac package
+type MacPackageResult struct {
+	OutputPath string
+	Name       string
+	Info       *MacPackageInfo
+	SourceSize int64
+	FinalSize  int64
+}
+
+// MacPackagePath returns the path of the .intunemac package for a setup file in outputFolder
+func MacPackagePath(setupPath, outputFolder string) string {
+	base := filepath.Base(setupPath)
+	return filepath.Join(outputFolder, strings.TrimSuffix(base, filepath.Ext(base))+MacPackageExtension)
+}
+
+// PackageMac wraps a macOS .pkg or .dmg in a .intunemac package in outputFolder
+// The payload is encrypted like .intunewin content and stored with a Detection.xml that
+// carries the primary bundle ID and version Intune uses to detect the install
+func PackageMac(setupPath, outputFolder string, opts MacOptions) (*MacPackageResult, error) {
+	setupFile := filepath.Base(setupPath)
+	var info *MacPackageInfo
+	switch {
+	case IsMacPackageFile(setupFile):
+		var err error
+		if info, err = ExtractPkgInfo(setupPath); err != nil {
+			return nil, err
+		}
+	case IsDiskImageFile(setupFile):
+		if err := checkDiskImage(setupPath); err != nil {
+			return nil, err
+		}
+		// The apps of a disk image are inside its file system, which is not read
+		if opts.BundleID == "" || opts.Version == "" {
+			return nil, fmt.Errorf("the bundle ID and version of a disk image must be given")
+		}
+		info = &MacPackageInfo{}
+	default:
+		return nil, fmt.Errorf("%s is not a macOS package (.pkg or .dmg)", setupFile)
+	}
+
+	if opts.BundleID != "" && opts.BundleID != info.BundleID {
+		// Another app of the package is primary: take its version
+		info.BundleID, info.Version = opts.BundleID, ""
+		for _, app := range info.Apps {
+			if app.BundleID == opts.BundleID {
+				info.Version = app.Version
+			}
+		}
+	}
+	if opts.Version != "" {
+		info.Version = opts.Version
+	}
+	if info.Version == "" {
+		return nil, fmt.Errorf("no version found in %s", setupFile)
+	}
+	info.Apps = primaryAppFirst(info)
+
+	payload, err := os.ReadFile(setupPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read setup file: %w", err)
+	}
+	encInfo, encrypted, err := CreateEncryptionInfo(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	name := firstNonEmptyString(opts.Name, info.Title, strings.TrimSuffix(setupFile, filepath.Ext(setupFile)))
+	detectionXML, err := GenerateMacDetectionXML(name, setupFile, int64(len(payload)), encInfo, info, opts.ToolVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	if err := writePackageZip(buf, MacEncryptedContentPath, MacDetectionXMLPath, bytes.NewReader(encrypted), detectionXML, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(outputFolder, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output folder: %w", err)
+	}
+	outputPath := MacPackagePath(setupPath, outputFolder)
+	finalSize := int64(buf.Len())
+	if err := copyToFile(outputPath, buf); err != nil {
+		os.Remove(outputPath)
+		return nil, fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	return &MacPackageResult{
+		OutputPath: outputPath,
+		Name:       name,
+		Info:       info,
+		SourceSize: int64(len(payload)),
+		FinalSize:  finalSize,
+	}, nil
+}
+
+// primaryAppFirst returns the apps of a package with the primary bundle first, adding it
+// when the package lists no app of that ID
+func primaryAppFirst(info *MacPackageInfo) []MacApp {
+	apps := []MacApp{{BundleID: info.BundleID, Version: info.Version}}
+	for _, app := range info.Apps {
+		if app.BundleID == info.BundleID {
+			apps[0] = app
+			apps[0].Version = info.Version
+			continue
+		}
+		apps = append(apps, app)
+	}
+	return apps
+}
+
+// GenerateMacDetectionXML creates the Detection.xml content of a .intunemac package
+func GenerateMacDetectionXML(name, setupFile string, size int64, encInfo *EncryptionInfo, info *MacPackageInfo, toolVersion string) ([]byte, error) {
+	if encInfo == nil {
+		return nil, fmt.Errorf("encryption info cannot be nil")
+	}
+	if info == nil {
+		return nil, fmt.Errorf("package info cannot be nil")
+	}
+
+	appInfo := ApplicationInfo{
+		ToolVersion:            firstNonEmptyString(toolVersion, ToolVersion),
+		Name:                   name,
+		UnencryptedContentSize: size,
+		FileName:               path.Base(MacEncryptedContentPath),
+		SetupFile:              setupFile,
+		EncryptionInfo: EncryptionXML{
+			EncryptionKey:        base64.StdEncoding.EncodeToString(encInfo.EncryptionKey),
+			MacKey:               base64.StdEncoding.EncodeToString(encInfo.MacKey),
+			InitializationVector: base64.StdEncoding.EncodeToString(encInfo.InitializationVector),
+			Mac:                  base64.StdEncoding.EncodeToString(encInfo.Mac),
+			ProfileIdentifier:    ProfileIdentifier,
+			FileDigest:           base64.StdEncoding.EncodeToString(encInfo.FileDigest),
+			FileDigestAlgorithm:  FileDigestAlgorithm,
+		},
+		MacOSAppInfo: &MacOSAppInfoXML{
+			PrimaryBundleID:      info.BundleID,
+			PrimaryBundleVersion: info.Version,
+		},
+	}
+	for _, app := range info.Apps {
+		appInfo.MacOSAppInfo.IncludedApps = append(appInfo.MacOSAppInfo.IncludedApps, IncludedAppXML{
+			BundleID:      app.BundleID,
+			BundleVersion: app.Version,
+		})
+	}
+	return marshalDetectionXML(&appInfo)
+}
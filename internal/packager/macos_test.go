@@ -0,0 +1,223 @@
+package packager
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testDistribution = `<?xml version="1.0" encoding="utf-8"?>
+<installer-gui-script minSpecVersion="2">
+  <title>Contoso Suite</title>
+  <pkg-ref id="com.contoso.suite.pkg" version="2.1.0" onConclusion="none">#Contoso.pkg</pkg-ref>
+  <product id="com.contoso.suite" version="2.1.0"/>
+</installer-gui-script>`
+
+const testPackageInfo = `<?xml version="1.0" encoding="utf-8"?>
+<pkg-info format-version="2" identifier="com.contoso.suite.pkg" version="2.1.0" install-location="/Applications" auth="root">
+  <bundle path="./Contoso Helper.app" id="com.contoso.helper" CFBundleShortVersionString="1.4" CFBundleVersion="140"/>
+  <bundle path="./Contoso.app" id="com.contoso.app" CFBundleShortVersionString="2.1.0" CFBundleVersion="2100"/>
+  <bundle path="./Contoso.app/Contents/Frameworks/Sparkle.framework" id="org.sparkle-project.Sparkle" CFBundleShortVersionString="2.5"/>
+</pkg-info>`
+
+// writeTestPkg writes a flat package (XAR archive) holding files, keyed by their path
+// Files with an odd index are stored zlib compressed
+func writeTestPkg(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var heap bytes.Buffer
+	var toc strings.Builder
+	toc.WriteString(`<?xml version="1.0" encoding="UTF-8"?><xar><toc>`)
+	openDir := ""
+	for i, name := range names {
+		dir, base := filepath.Split(name)
+		dir = strings.TrimSuffix(dir, "/")
+		if dir != openDir {
+			if openDir != "" {
+				toc.WriteString(`</file>`)
+			}
+			if dir != "" {
+				fmt.Fprintf(&toc, `<file><name>%s</name><type>directory</type>`, dir)
+			}
+			openDir = dir
+		}
+
+		content := []byte(files[name])
+		encoding := "application/octet-stream"
+		if i%2 == 1 {
+			var zbuf bytes.Buffer
+			zw := zlib.NewWriter(&zbuf)
+			zw.Write(content)
+			zw.Close()
+			content = zbuf.Bytes()
+			encoding = "application/x-gzip"
+		}
+		fmt.Fprintf(&toc, `<file><name>%s</name><type>file</type><data><offset>%d</offset><length>%d</length><size>%d</size><encoding style="%s"/></data></file>`,
+			base, heap.Len(), len(content), len(files[name]), encoding)
+		heap.Write(content)
+	}
+	if openDir != "" {
+		toc.WriteString(`</file>`)
+	}
+	toc.WriteString(`</toc></xar>`)
+
+	var ztoc bytes.Buffer
+	zw := zlib.NewWriter(&ztoc)
+	zw.Write([]byte(toc.String()))
+	zw.Close()
+
+	var out bytes.Buffer
+	binary.Write(&out, binary.BigEndian, struct {
+		Magic           uint32
+		HeaderSize      uint16
+		Version         uint16
+		TOCCompressed   uint64
+		TOCUncompressed uint64
+		ChecksumAlg     uint32
+	}{xarMagic, xarHeaderSize, 1, uint64(ztoc.Len()), uint64(toc.Len()), 0})
+	out.Write(ztoc.Bytes())
+	out.Write(heap.Bytes())
+	if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write test package: %v", err)
+	}
+}
+
+func TestExtractPkgInfo(t *testing.T) {
+	dir := t.TempDir()
+
+	product := filepath.Join(dir, "Contoso.pkg")
+	writeTestPkg(t, product, map[string]string{
+		"Distribution":            testDistribution,
+		"Contoso.pkg/PackageInfo": testPackageInfo,
+		"Contoso.pkg/Payload":     "payload",
+	})
+	info, err := ExtractPkgInfo(product)
+	if err != nil {
+		t.Fatalf("ExtractPkgInfo() error = %v", err)
+	}
+	// The app of the product version is primary, the helper app is included
+	if info.Title != "Contoso Suite" || info.BundleID != "com.contoso.app" || info.Version != "2.1.0" {
+		t.Errorf("Info = %+v", info)
+	}
+	if len(info.Apps) != 2 || info.Apps[0].Build != "2100" || info.Apps[1].BundleID != "com.contoso.helper" {
+		t.Errorf("Apps = %+v, want the two app bundles, primary first, and no framework", info.Apps)
+	}
+
+	// A component package without app bundles is identified by its package identifier
+	component := filepath.Join(dir, "Agent.pkg")
+	writeTestPkg(t, component, map[string]string{
+		"PackageInfo": `<pkg-info identifier="com.contoso.agent" version="5.0"/>`,
+	})
+	info, err = ExtractPkgInfo(component)
+	if err != nil {
+		t.Fatalf("ExtractPkgInfo() error = %v", err)
+	}
+	if info.BundleID != "com.contoso.agent" || info.Version != "5.0" || len(info.Apps) != 0 {
+		t.Errorf("Component info = %+v", info)
+	}
+
+	notPkg := filepath.Join(dir, "setup.pkg")
+	os.WriteFile(notPkg, []byte("PK\x03\x04 not a flat package"), 0644)
+	if _, err := ExtractPkgInfo(notPkg); err == nil {
+		t.Error("Expected error for a file that is not a XAR archive")
+	}
+}
+
+func TestPackageMac(t *testing.T) {
+	dir := t.TempDir()
+	pkg := filepath.Join(dir, "Contoso.pkg")
+	writeTestPkg(t, pkg, map[string]string{
+		"Distribution":            testDistribution,
+		"Contoso.pkg/PackageInfo": testPackageInfo,
+	})
+	payload, _ := os.ReadFile(pkg)
+
+	outDir := filepath.Join(dir, "output")
+	result, err := PackageMac(pkg, outDir, MacOptions{BundleID: "com.contoso.helper"})
+	if err != nil {
+		t.Fatalf("PackageMac() error = %v", err)
+	}
+	if result.OutputPath != filepath.Join(outDir, "Contoso.intunemac") || result.Name != "Contoso Suite" {
+		t.Errorf("Result = %+v", result)
+	}
+	if len(result.Info.Apps) != 2 || result.Info.Apps[0].BundleID != "com.contoso.helper" || result.Info.Version != "1.4" {
+		t.Errorf("Apps = %+v, want the primary bundle first with its version", result.Info.Apps)
+	}
+
+	reader, err := zip.OpenReader(result.OutputPath)
+	if err != nil {
+		t.Fatalf("Failed to open package: %v", err)
+	}
+	defer reader.Close()
+	var encrypted []byte
+	for _, f := range reader.File {
+		if f.Method != zip.Store {
+			t.Errorf("%s is compressed, want Store", f.Name)
+		}
+		if f.Name == MacEncryptedContentPath {
+			encrypted, _ = readZipFile(f)
+		}
+	}
+
+	appInfo, err := ReadDetectionXML(result.OutputPath)
+	if err != nil {
+		t.Fatalf("ReadDetectionXML() error = %v", err)
+	}
+	mac := appInfo.MacOSAppInfo
+	if mac == nil || mac.PrimaryBundleID != "com.contoso.helper" || mac.PrimaryBundleVersion != "1.4" || len(mac.IncludedApps) != 2 {
+		t.Fatalf("MacOSAppInfo = %+v", mac)
+	}
+	if appInfo.FileName != "IntunePackage.intunemac" || appInfo.SetupFile != "Contoso.pkg" || appInfo.UnencryptedContentSize != int64(len(payload)) {
+		t.Errorf("ApplicationInfo = %+v", appInfo)
+	}
+
+	keys, err := appInfo.EncryptionInfo.Decode()
+	if err != nil {
+		t.Fatalf("Failed to decode keys: %v", err)
+	}
+	decrypted, err := DecryptContent(encrypted, keys.EncryptionKey, keys.MacKey)
+	if err != nil {
+		t.Fatalf("DecryptContent() error = %v", err)
+	}
+	if !bytes.Equal(decrypted, payload) {
+		t.Error("Decrypted content does not match the .pkg")
+	}
+}
+
+func TestPackageMacDiskImage(t *testing.T) {
+	dir := t.TempDir()
+	dmg := filepath.Join(dir, "Contoso.dmg")
+	image := make([]byte, 4096)
+	copy(image[len(image)-diskImageTrailerSize:], "koly")
+	os.WriteFile(dmg, image, 0644)
+
+	if _, err := PackageMac(dmg, dir, MacOptions{}); err == nil {
+		t.Error("Expected error for a disk image without bundle ID and version")
+	}
+	result, err := PackageMac(dmg, dir, MacOptions{BundleID: "com.contoso.app", Version: "2.1.0"})
+	if err != nil {
+		t.Fatalf("PackageMac() error = %v", err)
+	}
+	if len(result.Info.Apps) != 1 || result.Info.Apps[0] != (MacApp{BundleID: "com.contoso.app", Version: "2.1.0"}) {
+		t.Errorf("Apps = %+v", result.Info.Apps)
+	}
+
+	notImage := filepath.Join(dir, "setup.dmg")
+	os.WriteFile(notImage, make([]byte, 4096), 0644)
+	if _, err := PackageMac(notImage, dir, MacOptions{BundleID: "com.contoso.app", Version: "2.1.0"}); err == nil {
+		t.Error("Expected error for a file without the disk image trailer")
+	}
+}
@@ -0,0 +1,140 @@
+package packager
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ManifestSuffix is appended to the .intunewin path to name its manifest
+const ManifestSuffix = ".manifest.json"
+
+// Manifest records exactly which files were packed into a .intunewin package
+type Manifest struct {
+	Package       string    `json:"package"`
+	PackageSHA256 string    `json:"packageSha256"`
+	SetupFile     string    `json:"setupFile"`
+	Created       time.Time `json:"created"`
+	// GeneratorVersion is the package generation logic that built the package
+	GeneratorVersion int            `json:"generatorVersion"`
+	FileCount        int            `json:"fileCount"`
+	TotalSize        int64          `json:"totalSize"`
+	License          *License       `json:"license,omitempty"`
+	Files            []ManifestFile `json:"files"`
+	// MsiSuite lists the MSIs chained by the suite install script, in install order
+	MsiSuite *MsiSuite `json:"msiSuite,omitempty"`
+}
+
+// ManifestFile is a single packed file
+type ManifestFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	SHA1   string `json:"sha1"`
+}
+
+// ManifestPath returns the path of the manifest written alongside a package
+func ManifestPath(packagePath string) string {
+	return packagePath + ManifestSuffix
+}
+
+// BuildManifestFiles hashes every file of the content ZIP
+// Reading the ZIP rather than the source folder records what was actually shipped
+func BuildManifestFiles(zipData []byte) ([]ManifestFile, error) {
+	return buildManifestFilesFrom(bytes.NewReader(zipData), int64(len(zipData)))
+}
+
+// buildManifestFilesFrom hashes every file of a content ZIP of size bytes read from r
+func buildManifestFilesFrom(r io.ReaderAt, size int64) ([]ManifestFile, error) {
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open content ZIP: %w", err)
+	}
+
+	var files []ManifestFile
+	for _, f := range reader.File {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+
+		entry, err := hashZipFile(f)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, entry)
+	}
+	return files, nil
+}
+
+// hashZipFile computes the size and digests of a single ZIP entry
+func hashZipFile(f *zip.File) (ManifestFile, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return ManifestFile{}, fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	sha256Hash := sha256.New()
+	sha1Hash := sha1.New()
+	size, err := io.Copy(io.MultiWriter(sha256Hash, sha1Hash), rc)
+	if err != nil {
+		return ManifestFile{}, fmt.Errorf("failed to hash %s: %w", f.Name, err)
+	}
+
+	return ManifestFile{
+		Path:   f.Name,
+		Size:   size,
+		SHA256: hex.EncodeToString(sha256Hash.Sum(nil)),
+		SHA1:   hex.EncodeToString(sha1Hash.Sum(nil)),
+	}, nil
+}
+
+// NewManifest builds the manifest of a package from its content ZIP and final bytes
+func NewManifest(packagePath, setupFile string, zipData, packageData []byte) (*Manifest, error) {
+	files, err := BuildManifestFiles(zipData)
+	if err != nil {
+		return nil, err
+	}
+	packageSum := sha256.Sum256(packageData)
+	return newManifest(packagePath, setupFile, files, packageSum[:]), nil
+}
+
+// newManifest creates the manifest of a package from its files and SHA256
+func newManifest(packagePath, setupFile string, files []ManifestFile, packageSum []byte) *Manifest {
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+
+	return &Manifest{
+		Package:          filepath.Base(packagePath),
+		PackageSHA256:    hex.EncodeToString(packageSum),
+		SetupFile:        setupFile,
+		Created:          time.Now().UTC(),
+		GeneratorVersion: GeneratorVersion,
+		FileCount:        len(files),
+		TotalSize:        total,
+		Files:            files,
+	}
+}
+
+// WriteManifest writes a manifest as indented JSON
+func WriteManifest(path string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
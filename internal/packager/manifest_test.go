@@ -0,0 +1,94 @@
+package packager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPackageWritesManifest(t *testing.T) {
+	sourceDir, outputDir, _ := setupCheckpointTest(t)
+	if err := os.MkdirAll(filepath.Join(sourceDir, "data"), 0755); err != nil {
+		t.Fatalf("Failed to create subdirectory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "data", "config.ini"), []byte("[app]\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "debug.log"), []byte("log"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	opts := Options{Manifest: true, Exclude: []string{"*.log"}}
+	result, err := PackageWithOptions(sourceDir, "setup.exe", outputDir, opts, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	if result.ManifestPath != ManifestPath(result.OutputPath) {
+		t.Fatalf("ManifestPath = %q, want %q", result.ManifestPath, ManifestPath(result.OutputPath))
+	}
+
+	data, err := os.ReadFile(result.ManifestPath)
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("Failed to parse manifest: %v", err)
+	}
+
+	packageData, err := os.ReadFile(result.OutputPath)
+	if err != nil {
+		t.Fatalf("Failed to read package: %v", err)
+	}
+	packageSum := sha256.Sum256(packageData)
+	if manifest.PackageSHA256 != hex.EncodeToString(packageSum[:]) {
+		t.Errorf("PackageSHA256 does not match the written package")
+	}
+	if manifest.Package != "setup.intunewin" || manifest.SetupFile != "setup.exe" {
+		t.Errorf("Unexpected package fields: %q, %q", manifest.Package, manifest.SetupFile)
+	}
+	if manifest.GeneratorVersion != GeneratorVersion || result.GeneratorVersion != GeneratorVersion {
+		t.Errorf("GeneratorVersion = %d in manifest, %d in result, want %d", manifest.GeneratorVersion, result.GeneratorVersion, GeneratorVersion)
+	}
+
+	// Excluded files are not shipped, so they are not listed
+	if manifest.FileCount != 2 || len(manifest.Files) != 2 {
+		t.Fatalf("Expected 2 files, got %d: %+v", manifest.FileCount, manifest.Files)
+	}
+	setupSum := sha256.Sum256([]byte("installer"))
+	for _, f := range manifest.Files {
+		switch f.Path {
+		case "setup.exe":
+			if f.Size != int64(len("installer")) || f.SHA256 != hex.EncodeToString(setupSum[:]) {
+				t.Errorf("Unexpected setup.exe entry: %+v", f)
+			}
+			if f.SHA1 != "2fc42d37fee2c81d767e09fb298b70c748940f86" {
+				t.Errorf("SHA1 = %q", f.SHA1)
+			}
+		case "data/config.ini":
+		default:
+			t.Errorf("Unexpected file in manifest: %s", f.Path)
+		}
+	}
+	if manifest.TotalSize != int64(len("installer")+len("[app]\n")) {
+		t.Errorf("TotalSize = %d", manifest.TotalSize)
+	}
+}
+
+func TestPackageWithoutManifest(t *testing.T) {
+	sourceDir, outputDir, _ := setupCheckpointTest(t)
+
+	result, err := Package(sourceDir, "setup.exe", outputDir, nil)
+	if err != nil {
+		t.Fatalf("Package() error = %v", err)
+	}
+	if result.ManifestPath != "" {
+		t.Errorf("ManifestPath = %q, want empty", result.ManifestPath)
+	}
+	if _, err := os.Stat(ManifestPath(result.OutputPath)); !os.IsNotExist(err) {
+		t.Error("Manifest was written without Options.Manifest")
+	}
+}
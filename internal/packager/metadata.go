@@ -1,10 +1,14 @@
 package packager
 
 import (
+	"archive/zip"
 	"bytes"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/xml"
 	"fmt"
+	"io"
+	"strings"
 )
 
 const (
@@ -14,21 +18,26 @@ const (
 	ProfileIdentifier = "ProfileVersion1"
 	// FileDigestAlgorithm is the hash algorithm used
 	FileDigestAlgorithm = "SHA256"
+	// DetectionXMLPath is the location of Detection.xml inside a .intunewin package
+	DetectionXMLPath = "IntuneWinPackage/Metadata/Detection.xml"
+	// EncryptedContentPath is the location of the encrypted content inside a .intunewin package
+	EncryptedContentPath = "IntuneWinPackage/Contents/IntunePackage.intunewin"
 )
 
 // ApplicationInfo is the root XML element for Detection.xml
 // Field order matches official Microsoft IntuneWinAppUtil output
 type ApplicationInfo struct {
-	XMLName                xml.Name        `xml:"ApplicationInfo"`
-	XSD                    string          `xml:"xmlns:xsd,attr"`
-	XSI                    string          `xml:"xmlns:xsi,attr"`
-	ToolVersion            string          `xml:"ToolVersion,attr"`
-	Name                   string          `xml:"Name"`
-	UnencryptedContentSize int64           `xml:"UnencryptedContentSize"`
-	FileName               string          `xml:"FileName"`
-	SetupFile              string          `xml:"SetupFile"`
-	EncryptionInfo         EncryptionXML   `xml:"EncryptionInfo"`
-	MsiInfo                *MsiInfoXML     `xml:"MsiInfo,omitempty"`
+	XMLName                xml.Name         `xml:"ApplicationInfo"`
+	XSD                    string           `xml:"xmlns:xsd,attr"`
+	XSI                    string           `xml:"xmlns:xsi,attr"`
+	ToolVersion            string           `xml:"ToolVersion,attr"`
+	Name                   string           `xml:"Name"`
+	UnencryptedContentSize int64            `xml:"UnencryptedContentSize"`
+	FileName               string           `xml:"FileName"`
+	SetupFile              string           `xml:"SetupFile"`
+	EncryptionInfo         EncryptionXML    `xml:"EncryptionInfo"`
+	MsiInfo                *MsiInfoXML      `xml:"MsiInfo,omitempty"`
+	MacOSAppInfo           *MacOSAppInfoXML `xml:"MacOSAppInfo,omitempty"`
 }
 
 // EncryptionXML contains the encryption metadata in XML format
@@ -73,6 +82,62 @@ type MetadataParams struct {
 	EncryptionInfo *EncryptionInfo
 	// MsiInfo contains MSI metadata (optional, only for .msi files)
 	MsiInfo *MsiInfo
+	// ToolVersion overrides the ToolVersion attribute (optional, defaults to ToolVersion)
+	ToolVersion string
+	// MsiOverrides replaces MsiInfo values that are not read from the MSI (optional)
+	MsiOverrides *MsiOverrides
+}
+
+// MSI execution contexts recorded as MsiExecutionContext
+const (
+	MsiContextAny    = "Any"
+	MsiContextSystem = "System"
+	MsiContextUser   = "User"
+)
+
+// MsiOverrides replaces MsiInfo values of Detection.xml
+// The zero value keeps the install context and reboot requirement read from the MSI
+type MsiOverrides struct {
+	// ExecutionContext is Any, System or User; User records a per-user install
+	// (MsiIsUserInstall) instead of a machine install, Any records both
+	ExecutionContext string
+	// RequiresReboot replaces the MsiRequiresReboot read from the MSI (optional)
+	RequiresReboot *bool
+}
+
+// ParseMsiExecutionContext returns the MsiExecutionContext value of s, case-insensitively
+func ParseMsiExecutionContext(s string) (string, error) {
+	for _, context := range []string{MsiContextAny, MsiContextSystem, MsiContextUser} {
+		if strings.EqualFold(s, context) {
+			return context, nil
+		}
+	}
+	return "", fmt.Errorf("invalid MSI execution context: %s (supported: Any, System, User)", s)
+}
+
+// apply sets the overridden values in msiInfo
+func (o *MsiOverrides) apply(msiInfo *MsiInfoXML) error {
+	if o == nil {
+		return nil
+	}
+	if o.ExecutionContext != "" {
+		context, err := ParseMsiExecutionContext(o.ExecutionContext)
+		if err != nil {
+			return err
+		}
+		setMsiExecutionContext(msiInfo, context)
+	}
+	if o.RequiresReboot != nil {
+		msiInfo.MsiRequiresReboot = *o.RequiresReboot
+	}
+	return nil
+}
+
+// setMsiExecutionContext records an execution context with the install scopes it allows
+func setMsiExecutionContext(msiInfo *MsiInfoXML, context string) {
+	msiInfo.MsiExecutionContext = context
+	msiInfo.MsiIsUserInstall = context != MsiContextSystem
+	msiInfo.MsiIsMachineInstall = context != MsiContextUser
 }
 
 // GenerateDetectionXML creates the Detection.xml content
@@ -106,6 +171,9 @@ func GenerateDetectionXML(params *MetadataParams) ([]byte, error) {
 		UnencryptedContentSize: params.UnencryptedContentSize,
 		EncryptionInfo:         encXML,
 	}
+	if params.ToolVersion != "" {
+		appInfo.ToolVersion = params.ToolVersion
+	}
 
 	// Add MSI info if available
 	if params.MsiInfo != nil {
@@ -121,17 +189,32 @@ func GenerateDetectionXML(params *MetadataParams) ([]byte, error) {
 			MsiUpgradeCode:                params.MsiInfo.UpgradeCode,
 			MsiExecutionContext:           "Any",
 			MsiRequiresLogon:              false,
-			MsiRequiresReboot:             false,
+			MsiRequiresReboot:             params.MsiInfo.RequiresReboot,
 			MsiIsMachineInstall:           true,
 			MsiIsUserInstall:              false,
-			MsiIncludesServices:           false,
-			MsiIncludesODBCDataSource:     false,
-			MsiContainsSystemRegistryKeys: false,
-			MsiContainsSystemFolders:      false,
+			MsiIncludesServices:           params.MsiInfo.IncludesServices,
+			MsiIncludesODBCDataSource:     params.MsiInfo.IncludesODBCDataSource,
+			MsiContainsSystemRegistryKeys: params.MsiInfo.ContainsSystemRegistryKeys,
+			MsiContainsSystemFolders:      params.MsiInfo.ContainsSystemFolders,
 			MsiPublisher:                  params.MsiInfo.Publisher,
 		}
+		if params.MsiInfo.ExecutionContext != "" {
+			setMsiExecutionContext(appInfo.MsiInfo, params.MsiInfo.ExecutionContext)
+		}
+		if err := params.MsiOverrides.apply(appInfo.MsiInfo); err != nil {
+			return nil, err
+		}
 	}
 
+	return marshalDetectionXML(&appInfo)
+}
+
+// marshalDetectionXML renders Detection.xml in the layout of the official tool
+func marshalDetectionXML(appInfo *ApplicationInfo) ([]byte, error) {
+	// Namespace attributes are not read back by ParseDetectionXML, so they are always set here
+	appInfo.XSD = "http://www.w3.org/2001/XMLSchema"
+	appInfo.XSI = "http://www.w3.org/2001/XMLSchema-instance"
+
 	// Generate XML without declaration (Microsoft's official tool doesn't include it)
 	xmlData, err := xml.MarshalIndent(appInfo, "", "  ")
 	if err != nil {
@@ -145,11 +228,106 @@ func GenerateDetectionXML(params *MetadataParams) ([]byte, error) {
 	return result, nil
 }
 
+// ReadDetectionXML reads and parses Detection.xml from an existing .intunewin or .intunemac package
+func ReadDetectionXML(packagePath string) (*ApplicationInfo, error) {
+	reader, err := zip.OpenReader(packagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open package: %w", err)
+	}
+	defer reader.Close()
+
+	for _, f := range reader.File {
+		if !IsPackageEntry(f.Name, DetectionXMLPath) && !IsPackageEntry(f.Name, MacDetectionXMLPath) {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open Detection.xml: %w", err)
+		}
+		defer rc.Close()
+
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Detection.xml: %w", err)
+		}
+
+		return ParseDetectionXML(data)
+	}
+
+	return nil, fmt.Errorf("Detection.xml not found in package: %s", packagePath)
+}
+
+// ParseDetectionXML parses Detection.xml content, with or without XML declaration
+// A UTF-8 byte order mark is skipped and UTF-16 content, which some tools write, decoded
+func ParseDetectionXML(data []byte) (*ApplicationInfo, error) {
+	var appInfo ApplicationInfo
+	if err := newDetectionXMLDecoder(decodeDetectionXML(data)).Decode(&appInfo); err != nil {
+		return nil, fmt.Errorf("failed to parse Detection.xml: %w", err)
+	}
+	return &appInfo, nil
+}
+
+// bomUTF8 is the UTF-8 byte order mark, which IntuneWinAppUtil does not write but some tools do
+var bomUTF8 = []byte{0xEF, 0xBB, 0xBF}
+
+// decodeDetectionXML returns Detection.xml content as UTF-8 without byte order mark
+func decodeDetectionXML(data []byte) []byte {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		return data[len(bomUTF8):]
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return []byte(decodeUTF16(data[2:], binary.LittleEndian))
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return []byte(decodeUTF16(data[2:], binary.BigEndian))
+	}
+	return data
+}
+
+// newDetectionXMLDecoder returns a decoder of Detection.xml content from decodeDetectionXML
+// A declaration of UTF-16 encoding is accepted since the content has been decoded already
+func newDetectionXMLDecoder(data []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		switch strings.ToLower(charset) {
+		case "utf-16", "utf-16le", "utf-16be", "unicode":
+			return input, nil
+		}
+		return nil, fmt.Errorf("unsupported encoding %q", charset)
+	}
+	return decoder
+}
+
+// Decode converts the base64-encoded values back into EncryptionInfo
+func (x EncryptionXML) Decode() (*EncryptionInfo, error) {
+	info := &EncryptionInfo{}
+	fields := []struct {
+		name  string
+		value string
+		dst   *[]byte
+	}{
+		{"EncryptionKey", x.EncryptionKey, &info.EncryptionKey},
+		{"MacKey", x.MacKey, &info.MacKey},
+		{"InitializationVector", x.InitializationVector, &info.InitializationVector},
+		{"Mac", x.Mac, &info.Mac},
+		{"FileDigest", x.FileDigest, &info.FileDigest},
+	}
+
+	for _, f := range fields {
+		decoded, err := base64.StdEncoding.DecodeString(f.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", f.name, err)
+		}
+		*f.dst = decoded
+	}
+	return info, nil
+}
+
 // GetApplicationName extracts the application name from the setup file
 func GetApplicationName(setupFile string) string {
 	// Remove extension to get base name
 	name := setupFile
-	for _, ext := range []string{".msi", ".exe", ".MSI", ".EXE"} {
+	for _, ext := range []string{".msi", ".exe", ".MSI", ".EXE", ".msixbundle", ".appxbundle", ".msix", ".appx"} {
 		if len(name) > len(ext) && name[len(name)-len(ext):] == ext {
 			name = name[:len(name)-len(ext)]
 			break
@@ -2,6 +2,8 @@ package packager
 
 import (
 	"encoding/xml"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -135,9 +137,9 @@ func TestGenerateDetectionXMLFormat(t *testing.T) {
 
 	xmlStr := string(xmlData)
 
-	// Check XML declaration (Go's xml.Header uses UTF-8 uppercase)
-	if !strings.HasPrefix(xmlStr, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>") {
-		t.Error("Missing or incorrect XML declaration")
+	// Microsoft's IntuneWinAppUtil writes no XML declaration
+	if !strings.HasPrefix(xmlStr, "<ApplicationInfo ") {
+		t.Error("Detection.xml must start with the ApplicationInfo element, without XML declaration")
 	}
 
 	// Check namespace attributes
@@ -154,6 +156,128 @@ func TestGenerateDetectionXMLFormat(t *testing.T) {
 	}
 }
 
+func TestGenerateDetectionXMLToolVersionOverride(t *testing.T) {
+	params := &MetadataParams{
+		Name:                   "Test",
+		SetupFile:              "test.exe",
+		UnencryptedContentSize: 1000,
+		EncryptionInfo: &EncryptionInfo{
+			EncryptionKey:        make([]byte, 32),
+			MacKey:               make([]byte, 32),
+			InitializationVector: make([]byte, 16),
+			Mac:                  make([]byte, 32),
+			FileDigest:           make([]byte, 32),
+		},
+		ToolVersion: "1.8.4.0",
+	}
+
+	xmlData, err := GenerateDetectionXML(params)
+	if err != nil {
+		t.Fatalf("GenerateDetectionXML() error = %v", err)
+	}
+	if !strings.Contains(string(xmlData), `ToolVersion="1.8.4.0"`) {
+		t.Errorf("ToolVersion override not applied:\n%s", xmlData)
+	}
+}
+
+func TestGenerateDetectionXMLMsiOverrides(t *testing.T) {
+	reboot := true
+	params := &MetadataParams{
+		Name:                   "Test",
+		SetupFile:              "setup.msi",
+		UnencryptedContentSize: 1000,
+		EncryptionInfo: &EncryptionInfo{
+			EncryptionKey:        make([]byte, 32),
+			MacKey:               make([]byte, 32),
+			InitializationVector: make([]byte, 16),
+			Mac:                  make([]byte, 32),
+			FileDigest:           make([]byte, 32),
+		},
+		MsiInfo:      &MsiInfo{ProductCode: "{12345678-1234-1234-1234-123456789ABC}"},
+		MsiOverrides: &MsiOverrides{ExecutionContext: "user", RequiresReboot: &reboot},
+	}
+
+	xmlData, err := GenerateDetectionXML(params)
+	if err != nil {
+		t.Fatalf("GenerateDetectionXML() error = %v", err)
+	}
+	appInfo, err := ParseDetectionXML(xmlData)
+	if err != nil {
+		t.Fatalf("ParseDetectionXML() error = %v", err)
+	}
+	msi := appInfo.MsiInfo
+	if msi.MsiExecutionContext != "User" || !msi.MsiIsUserInstall || msi.MsiIsMachineInstall || !msi.MsiRequiresReboot {
+		t.Errorf("MsiInfo = %+v, want a per-user install requiring a reboot", msi)
+	}
+
+	params.MsiOverrides = &MsiOverrides{ExecutionContext: "Machine"}
+	if _, err := GenerateDetectionXML(params); err == nil {
+		t.Error("Expected error for an invalid execution context")
+	}
+
+	// Flags read from the MSI are recorded, and an explicit reboot override wins
+	noReboot := false
+	params.MsiInfo = &MsiInfo{IncludesServices: true, ContainsSystemFolders: true, RequiresReboot: true}
+	params.MsiOverrides = &MsiOverrides{RequiresReboot: &noReboot}
+	xmlData, err = GenerateDetectionXML(params)
+	if err != nil {
+		t.Fatalf("GenerateDetectionXML() error = %v", err)
+	}
+	if appInfo, err = ParseDetectionXML(xmlData); err != nil {
+		t.Fatalf("ParseDetectionXML() error = %v", err)
+	}
+	msi = appInfo.MsiInfo
+	if !msi.MsiIncludesServices || !msi.MsiContainsSystemFolders || msi.MsiIncludesODBCDataSource || msi.MsiRequiresReboot {
+		t.Errorf("MsiInfo = %+v, want services and system folders without reboot", msi)
+	}
+}
+
+func TestGenerateDetectionXMLMsiExecutionContext(t *testing.T) {
+	params := &MetadataParams{
+		Name:                   "Test",
+		SetupFile:              "setup.msi",
+		UnencryptedContentSize: 1000,
+		EncryptionInfo: &EncryptionInfo{
+			EncryptionKey:        make([]byte, 32),
+			MacKey:               make([]byte, 32),
+			InitializationVector: make([]byte, 16),
+			Mac:                  make([]byte, 32),
+			FileDigest:           make([]byte, 32),
+		},
+	}
+
+	tests := []struct {
+		read      string
+		override  string
+		want      string
+		isUser    bool
+		isMachine bool
+	}{
+		{"", "", MsiContextAny, false, true},
+		{MsiContextUser, "", MsiContextUser, true, false},
+		{MsiContextSystem, "", MsiContextSystem, false, true},
+		{MsiContextAny, "", MsiContextAny, true, true},
+		{MsiContextUser, "System", MsiContextSystem, false, true},
+	}
+	for _, tt := range tests {
+		params.MsiInfo = &MsiInfo{ProductCode: "{12345678-1234-1234-1234-123456789ABC}", ExecutionContext: tt.read}
+		params.MsiOverrides = &MsiOverrides{ExecutionContext: tt.override}
+
+		xmlData, err := GenerateDetectionXML(params)
+		if err != nil {
+			t.Fatalf("GenerateDetectionXML() error = %v", err)
+		}
+		appInfo, err := ParseDetectionXML(xmlData)
+		if err != nil {
+			t.Fatalf("ParseDetectionXML() error = %v", err)
+		}
+		msi := appInfo.MsiInfo
+		if msi.MsiExecutionContext != tt.want || msi.MsiIsUserInstall != tt.isUser || msi.MsiIsMachineInstall != tt.isMachine {
+			t.Errorf("Read %q, override %q: MsiInfo = %+v", tt.read, tt.override, msi)
+		}
+	}
+}
+
 func TestGenerateDetectionXMLNilParams(t *testing.T) {
 	_, err := GenerateDetectionXML(nil)
 	if err == nil {
@@ -173,3 +297,47 @@ func TestGenerateDetectionXMLNilEncryptionInfo(t *testing.T) {
 		t.Error("Expected error for nil encryption info")
 	}
 }
+
+func TestReadDetectionXML(t *testing.T) {
+	sourceDir, err := os.MkdirTemp("", "source")
+	if err != nil {
+		t.Fatalf("Failed to create source dir: %v", err)
+	}
+	defer os.RemoveAll(sourceDir)
+
+	outputDir, err := os.MkdirTemp("", "output")
+	if err != nil {
+		t.Fatalf("Failed to create output dir: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	if err := os.WriteFile(filepath.Join(sourceDir, "setup.exe"), []byte("installer"), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+
+	result, err := Package(sourceDir, "setup.exe", outputDir, nil)
+	if err != nil {
+		t.Fatalf("Package() error = %v", err)
+	}
+
+	appInfo, err := ReadDetectionXML(result.OutputPath)
+	if err != nil {
+		t.Fatalf("ReadDetectionXML() error = %v", err)
+	}
+	if appInfo.Name != "setup" {
+		t.Errorf("Name = %s, want setup", appInfo.Name)
+	}
+	if appInfo.SetupFile != "setup.exe" {
+		t.Errorf("SetupFile = %s, want setup.exe", appInfo.SetupFile)
+	}
+	if appInfo.EncryptionInfo.EncryptionKey == "" {
+		t.Error("EncryptionKey is empty")
+	}
+	if appInfo.MsiInfo != nil {
+		t.Error("MsiInfo should be nil for EXE package")
+	}
+
+	if _, err := ReadDetectionXML(filepath.Join(sourceDir, "setup.exe")); err == nil {
+		t.Error("Expected error for non-package file")
+	}
+}
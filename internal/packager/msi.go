@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 	"unicode/utf16"
 
@@ -21,6 +22,18 @@ type MsiInfo struct {
 	Publisher      string // Manufacturer from Property table
 	UpgradeCode    string // {GUID} from Property table
 	ProductName    string // ProductName from Property table (for display)
+	// Platform is the platform of the Summary Information Template (Intel, x64, Arm64, ...)
+	Platform string
+	// ExecutionContext is Any (dual-purpose), System or User, from the ALLUSERS and
+	// MSIINSTALLPERUSER properties and the Word Count summary flags (empty when unknown)
+	ExecutionContext string
+	// What the install changes, from the ServiceInstall, ODBCDataSource, Registry,
+	// Directory, Component and InstallExecuteSequence tables
+	IncludesServices           bool
+	IncludesODBCDataSource     bool
+	ContainsSystemRegistryKeys bool
+	ContainsSystemFolders      bool
+	RequiresReboot             bool
 }
 
 // IsMsiFile checks if the given file path has an .msi extension
@@ -36,7 +49,17 @@ func ExtractMsiInfo(msiPath string) (*MsiInfo, error) {
 		return nil, fmt.Errorf("failed to open MSI file: %w", err)
 	}
 	defer file.Close()
+	return readMsiInfo(file)
+}
+
+// msiSource is an MSI file on disk or in memory
+type msiSource interface {
+	io.ReaderAt
+	io.ReadSeeker
+}
 
+// readMsiInfo extracts metadata from MSI data
+func readMsiInfo(file msiSource) (*MsiInfo, error) {
 	// Parse the OLE Compound File
 	doc, err := mscfb.New(file)
 	if err != nil {
@@ -45,16 +68,20 @@ func ExtractMsiInfo(msiPath string) (*MsiInfo, error) {
 
 	info := &MsiInfo{}
 	var stringPool []string
+	var wordCount int
 
 	// First pass: collect data from streams
 	for entry, err := doc.Next(); err == nil; entry, err = doc.Next() {
 		name := entry.Name
 
 		// Summary Information stream contains PackageCode (PIDSI_REVNUMBER)
-		if name == "\x05SummaryInformation" {
+		// mscfb strips the leading \x05 from the name and keeps it in Initial
+		if entry.Initial == 0x05 && name == "SummaryInformation" {
 			data, readErr := io.ReadAll(entry)
 			if readErr == nil {
 				info.PackageCode = extractPackageCodeFromOLEPS(data)
+				wordCount = extractWordCountFromOLEPS(data)
+				info.Platform = extractPlatformFromOLEPS(data)
 			}
 		}
 
@@ -117,9 +144,113 @@ func ExtractMsiInfo(msiPath string) (*MsiInfo, error) {
 		info.PackageCode = findFirstGUIDInData(rawData)
 	}
 
+	// The install context and flags need the real table contents, not pattern matches
+	if db, err := openMsiDatabase(file); err == nil {
+		info.ExecutionContext = msiInstallContext(db.Property("ALLUSERS"), db.Property("MSIINSTALLPERUSER"), wordCount)
+		info.readInstallFlags(db)
+	}
+
 	return info, nil
 }
 
+// msiWordCountNoElevation is the Word Count flag of packages that install without elevation
+const msiWordCountNoElevation = 0x08
+
+// msiInstallContext returns the execution context an MSI installs in by default:
+// without ALLUSERS it installs per-user, ALLUSERS=2 with MSIINSTALLPERUSER=1 (or the
+// no-elevation Word Count flag) is a dual-purpose package, anything else is per-machine
+func msiInstallContext(allUsers, installPerUser string, wordCount int) string {
+	switch {
+	case allUsers == "":
+		return MsiContextUser
+	case allUsers == "2" && (installPerUser == "1" || wordCount&msiWordCountNoElevation != 0):
+		return MsiContextAny
+	default:
+		return MsiContextSystem
+	}
+}
+
+// Registry table roots (msidbRegistryRoot*); -1 is HKLM for per-machine installs and HKCU otherwise
+const (
+	msiRegistryRootDefault      = "-1"
+	msiRegistryRootClassesRoot  = "0"
+	msiRegistryRootLocalMachine = "2"
+	msiRegistryRootUsers        = "3"
+)
+
+// msiSystemFolders are the standard directories outside the user profile
+var msiSystemFolders = map[string]bool{
+	"ProgramFilesFolder": true, "ProgramFiles64Folder": true, "CommonFilesFolder": true,
+	"CommonFiles64Folder": true, "CommonAppDataFolder": true, "WindowsFolder": true,
+	"WindowsVolume": true, "SystemFolder": true, "System64Folder": true, "System16Folder": true,
+	"FontsFolder": true, "CommonDesktopFolder": true, "CommonProgramMenuFolder": true,
+}
+
+// readInstallFlags sets what the install changes from the tables of the MSI
+// ExecutionContext must be set first: default registry roots depend on it
+func (info *MsiInfo) readInstallFlags(db *msiDatabase) {
+	info.IncludesServices = len(db.Rows("ServiceInstall")) > 0
+	info.IncludesODBCDataSource = len(db.Rows("ODBCDataSource")) > 0
+
+	perUser := info.ExecutionContext == MsiContextUser
+	for _, row := range db.Rows("Registry") {
+		switch row["Root"] {
+		case msiRegistryRootLocalMachine, msiRegistryRootUsers:
+			info.ContainsSystemRegistryKeys = true
+		case msiRegistryRootDefault, msiRegistryRootClassesRoot:
+			info.ContainsSystemRegistryKeys = info.ContainsSystemRegistryKeys || !perUser
+		}
+	}
+
+	// A standard directory is used when a directory or a component is placed in it
+	for _, row := range db.Rows("Directory") {
+		info.ContainsSystemFolders = info.ContainsSystemFolders || msiSystemFolders[row["Directory_Parent"]]
+	}
+	for _, row := range db.Rows("Component") {
+		info.ContainsSystemFolders = info.ContainsSystemFolders || msiSystemFolders[row["Directory_"]]
+	}
+
+	for _, row := range db.Rows("InstallExecuteSequence") {
+		if row["Action"] == "ScheduleReboot" || row["Action"] == "ForceReboot" {
+			info.RequiresReboot = true
+		}
+	}
+	if strings.EqualFold(db.Property("REBOOT"), "Force") {
+		info.RequiresReboot = true
+	}
+}
+
+// extractWordCountFromOLEPS reads the Word Count flags (PIDSI_WORDCOUNT) of the Summary Information
+func extractWordCountFromOLEPS(data []byte) int {
+	props, err := msoleps.NewFrom(bytes.NewReader(data))
+	if err != nil {
+		return 0
+	}
+	for _, prop := range props.Property {
+		if prop.Name == "WordCount" {
+			wordCount, _ := strconv.Atoi(fmt.Sprintf("%v", prop))
+			return wordCount
+		}
+	}
+	return 0
+}
+
+// extractPlatformFromOLEPS reads the platform of the Template (PIDSI_TEMPLATE) of the
+// Summary Information, written as "<platform>;<languages>"
+func extractPlatformFromOLEPS(data []byte) string {
+	props, err := msoleps.NewFrom(bytes.NewReader(data))
+	if err != nil {
+		return ""
+	}
+	for _, prop := range props.Property {
+		if prop.Name == "Template" {
+			platform, _, _ := strings.Cut(fmt.Sprintf("%v", prop), ";")
+			return strings.TrimSpace(platform)
+		}
+	}
+	return ""
+}
+
 // extractPackageCodeFromOLEPS extracts the PackageCode from OLE Property Set Summary Information
 func extractPackageCodeFromOLEPS(data []byte) string {
 	// Try to parse as OLE Property Set using NewFrom
@@ -128,17 +259,17 @@ func extractPackageCodeFromOLEPS(data []byte) string {
 	if err == nil {
 		// PIDSI_REVNUMBER is property ID 9 in Summary Information
 		for _, prop := range props.Property {
-			if prop.Name == "PIDSI_REVNUMBER" || prop.Name == "Revision Number" {
+			if prop.Name == "RevNumber" || prop.Name == "PIDSI_REVNUMBER" || prop.Name == "Revision Number" {
 				str := fmt.Sprintf("%v", prop)
-				// MSI PackageCode is stored in a special compressed format
+				// Windows Installer stores a regular {GUID}
+				if isValidGUID(str) {
+					return str
+				}
+				// Or it might be in MSI's compressed format
 				guid := decompressMSIGUID(str)
 				if guid != "" {
 					return guid
 				}
-				// Or it might be a regular GUID
-				if isValidGUID(str) {
-					return str
-				}
 			}
 		}
 	}
@@ -1,7 +1,10 @@
 package packager
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/msitest"
 )
 
 func TestIsMsiFile(t *testing.T) {
@@ -245,3 +248,276 @@ func TestDecodeStringPool(t *testing.T) {
 		t.Errorf("decodeStringPool()[1] = %q, want %q", strings[1], "Hi")
 	}
 }
+
+func TestExtractMsiInfoFixtures(t *testing.T) {
+	const (
+		productCode = "{6F330B47-2577-43AD-9095-1861BA25889B}"
+		upgradeCode = "{A1B2C3D4-E5F6-4789-ABCD-0123456789AB}"
+		packageCode = "{0D2E8F41-7C3B-4A5E-9F60-112233445566}"
+	)
+	longPublisher := "Contoso International Software Development and Distribution Services Limited"
+
+	tests := []struct {
+		name string
+		msi  msitest.MSI
+		want MsiInfo
+	}{
+		{
+			name: "typical",
+			msi: msitest.MSI{
+				Properties: []msitest.Property{
+					{Name: "Manufacturer", Value: "Contoso Ltd"},
+					{Name: "ProductCode", Value: productCode},
+					{Name: "ProductLanguage", Value: "1033"},
+					{Name: "ProductName", Value: "Contoso App"},
+					{Name: "ProductVersion", Value: "2.4.1"},
+					{Name: "UpgradeCode", Value: upgradeCode},
+				},
+				PackageCode: packageCode,
+			},
+			want: MsiInfo{
+				ProductCode:      productCode,
+				ProductVersion:   "2.4.1",
+				PackageCode:      packageCode,
+				Publisher:        "Contoso Ltd",
+				UpgradeCode:      upgradeCode,
+				ProductName:      "Contoso App",
+				ExecutionContext: MsiContextUser,
+			},
+		},
+		{
+			name: "missing UpgradeCode",
+			msi: msitest.MSI{
+				Properties: []msitest.Property{
+					{Name: "Manufacturer", Value: "Fabrikam"},
+					{Name: "ProductCode", Value: productCode},
+					{Name: "ProductName", Value: "Fabrikam Tool"},
+					{Name: "ProductVersion", Value: "1.0.0.7"},
+				},
+				PackageCode: packageCode,
+			},
+			want: MsiInfo{
+				ProductCode:      productCode,
+				ProductVersion:   "1.0.0.7",
+				PackageCode:      packageCode,
+				Publisher:        "Fabrikam",
+				ProductName:      "Fabrikam Tool",
+				ExecutionContext: MsiContextUser,
+			},
+		},
+		{
+			name: "long publisher",
+			msi: msitest.MSI{
+				Properties: []msitest.Property{
+					{Name: "Manufacturer", Value: longPublisher},
+					{Name: "ProductCode", Value: productCode},
+					{Name: "ProductName", Value: "Long Name App"},
+					{Name: "ProductVersion", Value: "3.0"},
+					{Name: "UpgradeCode", Value: upgradeCode},
+				},
+				PackageCode: packageCode,
+			},
+			want: MsiInfo{
+				ProductCode:      productCode,
+				ProductVersion:   "3.0",
+				PackageCode:      packageCode,
+				Publisher:        longPublisher,
+				UpgradeCode:      upgradeCode,
+				ProductName:      "Long Name App",
+				ExecutionContext: MsiContextUser,
+			},
+		},
+		{
+			name: "japanese UTF-8 metadata",
+			msi: msitest.MSI{
+				Properties: []msitest.Property{
+					{Name: "Manufacturer", Value: "株式会社コントソ"},
+					{Name: "ProductCode", Value: productCode},
+					{Name: "ProductLanguage", Value: "1041"},
+					{Name: "ProductName", Value: "コントソ ビューア"},
+					{Name: "ProductVersion", Value: "5.1.2"},
+					{Name: "UpgradeCode", Value: upgradeCode},
+				},
+				PackageCode: packageCode,
+				Codepage:    msitest.CodepageUTF8,
+			},
+			// Non-ASCII names are not recognized by the byte pattern matching and are left empty
+			want: MsiInfo{
+				ProductCode:      productCode,
+				ProductVersion:   "5.1.2",
+				PackageCode:      packageCode,
+				UpgradeCode:      upgradeCode,
+				ExecutionContext: MsiContextUser,
+			},
+		},
+		{
+			name: "german Windows-1252 publisher",
+			msi: msitest.MSI{
+				Properties: []msitest.Property{
+					{Name: "Manufacturer", Value: "Müller Büro GmbH"},
+					{Name: "ProductCode", Value: productCode},
+					{Name: "ProductLanguage", Value: "1031"},
+					{Name: "ProductName", Value: "Büro Suite"},
+					{Name: "ProductVersion", Value: "12.0.4"},
+					{Name: "UpgradeCode", Value: upgradeCode},
+				},
+				PackageCode: packageCode,
+			},
+			want: MsiInfo{
+				ProductCode:      productCode,
+				ProductVersion:   "12.0.4",
+				PackageCode:      packageCode,
+				UpgradeCode:      upgradeCode,
+				ExecutionContext: MsiContextUser,
+			},
+		},
+	}
+
+	dir := t.TempDir()
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := msitest.WriteFile(t, dir, strings.Repeat("x", i+1)+".msi", tt.msi)
+
+			info, err := ExtractMsiInfo(path)
+			if err != nil {
+				t.Fatalf("ExtractMsiInfo failed: %v", err)
+			}
+			if *info != tt.want {
+				t.Errorf("ExtractMsiInfo() =\n  %+v\nwant\n  %+v", *info, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractMsiInfoExecutionContext(t *testing.T) {
+	tests := []struct {
+		name       string
+		properties []msitest.Property
+		wordCount  int
+		want       string
+	}{
+		{"per-machine", []msitest.Property{{Name: "ALLUSERS", Value: "1"}}, 0, MsiContextSystem},
+		{"per-user without ALLUSERS", nil, 0, MsiContextUser},
+		{"dual-purpose", []msitest.Property{{Name: "ALLUSERS", Value: "2"}, {Name: "MSIINSTALLPERUSER", Value: "1"}}, 0, MsiContextAny},
+		{"dual-purpose without elevation", []msitest.Property{{Name: "ALLUSERS", Value: "2"}}, 0x08, MsiContextAny},
+		{"ALLUSERS=2 defaulting to per-machine", []msitest.Property{{Name: "ALLUSERS", Value: "2"}}, 0x02, MsiContextSystem},
+	}
+
+	dir := t.TempDir()
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			properties := append([]msitest.Property{{Name: "ProductCode", Value: "{6F330B47-2577-43AD-9095-1861BA25889B}"}}, tt.properties...)
+			path := msitest.WriteFile(t, dir, strings.Repeat("c", i+1)+".msi", msitest.MSI{
+				Properties:  properties,
+				PackageCode: "{0D2E8F41-7C3B-4A5E-9F60-112233445566}",
+				WordCount:   tt.wordCount,
+			})
+
+			info, err := ExtractMsiInfo(path)
+			if err != nil {
+				t.Fatalf("ExtractMsiInfo failed: %v", err)
+			}
+			if info.ExecutionContext != tt.want {
+				t.Errorf("ExecutionContext = %q, want %q", info.ExecutionContext, tt.want)
+			}
+			if info.PackageCode != "{0D2E8F41-7C3B-4A5E-9F60-112233445566}" {
+				t.Errorf("PackageCode = %q next to the Word Count", info.PackageCode)
+			}
+		})
+	}
+}
+
+func TestExtractMsiInfoInstallFlags(t *testing.T) {
+	const s72 = msitest.ColumnString | 72
+	registry := func(roots ...int) msitest.Table {
+		table := msitest.Table{Name: "Registry", Columns: []msitest.Column{
+			{Name: "Registry", Type: s72 | msitest.ColumnKey},
+			{Name: "Root", Type: msitest.ColumnInt16},
+			{Name: "Key", Type: msitest.ColumnString | 255},
+			{Name: "Component_", Type: s72},
+		}}
+		for i, root := range roots {
+			table.Rows = append(table.Rows, []any{strings.Repeat("r", i+1), root, `Software\Contoso`, "MainComponent"})
+		}
+		return table
+	}
+	directory := msitest.Table{Name: "Directory", Columns: []msitest.Column{
+		{Name: "Directory", Type: s72 | msitest.ColumnKey},
+		{Name: "Directory_Parent", Type: s72 | msitest.ColumnNullable},
+		{Name: "DefaultDir", Type: msitest.ColumnString | 255},
+	}, Rows: [][]any{
+		{"TARGETDIR", nil, "SourceDir"},
+		{"ProgramFilesFolder", "TARGETDIR", "PFiles"},
+		{"INSTALLDIR", "ProgramFilesFolder", "Contoso"},
+	}}
+	services := msitest.Table{Name: "ServiceInstall", Columns: []msitest.Column{
+		{Name: "ServiceInstall", Type: s72 | msitest.ColumnKey},
+		{Name: "Name", Type: msitest.ColumnString | 255},
+		{Name: "ServiceType", Type: msitest.ColumnInt32},
+		{Name: "Component_", Type: s72},
+	}, Rows: [][]any{{"AgentService", "ContosoAgent", 16, "MainComponent"}}}
+	odbc := msitest.Table{Name: "ODBCDataSource", Columns: []msitest.Column{
+		{Name: "DataSource", Type: s72 | msitest.ColumnKey},
+		{Name: "Component_", Type: s72},
+		{Name: "Description", Type: msitest.ColumnString | 255},
+		{Name: "DriverDescription", Type: msitest.ColumnString | 255},
+		{Name: "Registration", Type: msitest.ColumnInt16},
+	}, Rows: [][]any{{"ContosoDB", "MainComponent", "Contoso DB", "SQL Server", 0}}}
+	sequence := msitest.Table{Name: "InstallExecuteSequence", Columns: []msitest.Column{
+		{Name: "Action", Type: s72 | msitest.ColumnKey},
+		{Name: "Condition", Type: msitest.ColumnString | msitest.ColumnNullable | 255},
+		{Name: "Sequence", Type: msitest.ColumnInt16 | msitest.ColumnNullable},
+	}, Rows: [][]any{{"InstallFiles", nil, 4000}, {"ScheduleReboot", "NOT Installed", 6599}}}
+
+	tests := []struct {
+		name     string
+		allUsers string
+		tables   []msitest.Table
+		want     MsiInfo
+	}{
+		{
+			name:     "machine-wide agent",
+			allUsers: "1",
+			tables:   []msitest.Table{registry(2), directory, services, odbc, sequence},
+			want: MsiInfo{IncludesServices: true, IncludesODBCDataSource: true, ContainsSystemRegistryKeys: true,
+				ContainsSystemFolders: true, RequiresReboot: true},
+		},
+		{
+			name:     "default root of a per-machine install",
+			allUsers: "1",
+			tables:   []msitest.Table{registry(-1, 1)},
+			want:     MsiInfo{ContainsSystemRegistryKeys: true},
+		},
+		{
+			name:   "per-user install",
+			tables: []msitest.Table{registry(-1, 1, 0)},
+			want:   MsiInfo{},
+		},
+	}
+
+	dir := t.TempDir()
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			properties := []msitest.Property{{Name: "ProductCode", Value: "{6F330B47-2577-43AD-9095-1861BA25889B}"}}
+			if tt.allUsers != "" {
+				properties = append(properties, msitest.Property{Name: "ALLUSERS", Value: tt.allUsers})
+			}
+			path := msitest.WriteFile(t, dir, strings.Repeat("f", i+1)+".msi", msitest.MSI{Properties: properties, Tables: tt.tables})
+
+			info, err := ExtractMsiInfo(path)
+			if err != nil {
+				t.Fatalf("ExtractMsiInfo failed: %v", err)
+			}
+			got := MsiInfo{
+				IncludesServices:           info.IncludesServices,
+				IncludesODBCDataSource:     info.IncludesODBCDataSource,
+				ContainsSystemRegistryKeys: info.ContainsSystemRegistryKeys,
+				ContainsSystemFolders:      info.ContainsSystemFolders,
+				RequiresReboot:             info.RequiresReboot,
+			}
+			if got != tt.want {
+				t.Errorf("Install flags = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package packager
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Risk levels of MSI custom actions, from most to least severe
+const (
+	RiskHigh   = "high"
+	RiskMedium = "medium"
+	RiskLow    = "low"
+)
+
+// Custom action type bits (msidbCustomActionType* in the Windows Installer SDK)
+const (
+	caTypeDll          = 0x01
+	caTypeExe          = 0x02
+	caTypeJScript      = 0x05
+	caTypeVBScript     = 0x06
+	caTypeInstall      = 0x07
+	caTypeBaseMask     = 0x07
+	caSourceDirectory  = 0x20
+	caSourceMask       = 0x30
+	caContinue         = 0x40
+	caAsync            = 0x80
+	caFirstSequence    = 0x100
+	caOncePerProcess   = 0x200
+	caInScript         = 0x400
+	caNoImpersonate    = 0x800
+	caHideTarget       = 0x2000
+	caRollbackInScript = caInScript | caFirstSequence
+	caCommitInScript   = caInScript | caOncePerProcess
+)
+
+// riskyCommands are programs whose use by a custom action deserves a closer look:
+// interpreters and system tools often used to run downloaded or embedded code
+var riskyCommands = []string{
+	"powershell", "pwsh", "cmd.exe", "cmd /c", "wscript", "cscript", "mshta",
+	"rundll32", "regsvr32", "bitsadmin", "certutil", "schtasks", "reg.exe", "sc.exe",
+}
+
+// MsiCustomAction is a custom action of an MSI that runs code, with its assessed risk
+type MsiCustomAction struct {
+	// Action, Type, Source and Target are the columns of the CustomAction table
+	Action string `json:"action"`
+	Type   int    `json:"type"`
+	Source string `json:"source,omitempty"`
+	Target string `json:"target,omitempty"`
+	// Kind is what the action runs: EXE, DLL, JScript, VBScript or MSI (nested install)
+	Kind string `json:"kind"`
+	// Execution is immediate, deferred, rollback or commit
+	Execution string `json:"execution"`
+	// NoImpersonate is set for deferred actions that run as LocalSystem
+	NoImpersonate bool `json:"noImpersonate"`
+	// Risk is high, medium or low; Reasons explain it
+	Risk    string   `json:"risk"`
+	Reasons []string `json:"reasons"`
+}
+
+// MsiAnalysis is a security review aid for an MSI: its metadata and the custom
+// actions that run code, riskiest first
+type MsiAnalysis struct {
+	Info          *MsiInfo          `json:"info"`
+	CustomActions []MsiCustomAction `json:"customActions"`
+}
+
+// Risky returns the custom actions at or above a risk level
+func (a *MsiAnalysis) Risky(level string) []MsiCustomAction {
+	var risky []MsiCustomAction
+	for _, ca := range a.CustomActions {
+		if riskRank(ca.Risk) >= riskRank(level) {
+			risky = append(risky, ca)
+		}
+	}
+	return risky
+}
+
+// ParseRiskLevel validates a risk level given on the command line
+func ParseRiskLevel(s string) (string, error) {
+	level := strings.ToLower(s)
+	if riskRank(level) == 0 {
+		return "", fmt.Errorf("invalid risk level: %s (supported: high, medium, low)", s)
+	}
+	return level, nil
+}
+
+// riskRank orders risk levels; unknown levels rank 0
+func riskRank(level string) int {
+	switch level {
+	case RiskHigh:
+		return 3
+	case RiskMedium:
+		return 2
+	case RiskLow:
+		return 1
+	}
+	return 0
+}
+
+// AnalyzeMsi reads the metadata and the custom actions of an MSI
+func AnalyzeMsi(msiPath string) (*MsiAnalysis, error) {
+	file, err := os.Open(msiPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MSI file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := readMsiInfo(file)
+	if err != nil {
+		return nil, err
+	}
+	db, err := openMsiDatabase(file)
+	if err != nil {
+		return nil, err
+	}
+	return &MsiAnalysis{Info: info, CustomActions: assessCustomActions(db.Rows("CustomAction"))}, nil
+}
+
+// readCustomActions rates the custom actions of an MSI without reading its metadata
+func readCustomActions(msiPath string) ([]MsiCustomAction, error) {
+	file, err := os.Open(msiPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MSI file: %w", err)
+	}
+	defer file.Close()
+
+	db, err := openMsiDatabase(file)
+	if err != nil {
+		return nil, err
+	}
+	return assessCustomActions(db.Rows("CustomAction")), nil
+}
+
+// warnRiskyCustomActions logs the high-risk custom actions of an MSI being packaged,
+// so they are reviewed before the app is deployed to the fleet
+func warnRiskyCustomActions(msiPath string, log *slog.Logger) {
+	actions, err := readCustomActions(msiPath)
+	if err != nil {
+		log.Debug("could not read MSI custom actions", "error", err)
+		return
+	}
+	for _, ca := range actions {
+		if ca.Risk == RiskHigh {
+			log.Warn("high-risk MSI custom action, review before deployment", "action", ca.Action, "kind", ca.Kind, "reasons", strings.Join(ca.Reasons, "; "))
+		}
+	}
+}
+
+// assessCustomActions rates the custom actions that run code, riskiest first
+// Actions that only set properties or directories or show an error are left out
+func assessCustomActions(rows []msiRow) []MsiCustomAction {
+	actions := []MsiCustomAction{}
+	for _, row := range rows {
+		caType, _ := strconv.Atoi(row["Type"])
+		ca := MsiCustomAction{
+			Action: row["Action"],
+			Type:   caType,
+			Source: row["Source"],
+			Target: row["Target"],
+		}
+		switch caType & caTypeBaseMask {
+		case caTypeDll:
+			ca.Kind = "DLL"
+		case caTypeExe:
+			ca.Kind = "EXE"
+		case caTypeJScript:
+			ca.Kind = "JScript"
+		case caTypeVBScript:
+			ca.Kind = "VBScript"
+		case caTypeInstall:
+			ca.Kind = "MSI"
+		default:
+			continue
+		}
+		assessCustomAction(&ca)
+		actions = append(actions, ca)
+	}
+	slices.SortStableFunc(actions, func(a, b MsiCustomAction) int {
+		return riskRank(b.Risk) - riskRank(a.Risk)
+	})
+	return actions
+}
+
+// assessCustomAction sets the execution, risk and reasons of a custom action
+func assessCustomAction(ca *MsiCustomAction) {
+	deferred := ca.Type&caInScript != 0
+	switch {
+	case ca.Type&caRollbackInScript == caRollbackInScript:
+		ca.Execution = "rollback"
+	case ca.Type&caCommitInScript == caCommitInScript:
+		ca.Execution = "commit"
+	case deferred:
+		ca.Execution = "deferred"
+	default:
+		ca.Execution = "immediate"
+	}
+	ca.NoImpersonate = deferred && ca.Type&caNoImpersonate != 0
+
+	ca.Risk = RiskLow
+	raise := func(level, reason string) {
+		if riskRank(level) > riskRank(ca.Risk) {
+			ca.Risk = level
+		}
+		ca.Reasons = append(ca.Reasons, reason)
+	}
+
+	runsCode := ca.Kind == "EXE" || ca.Kind == "JScript" || ca.Kind == "VBScript"
+	switch {
+	case ca.NoImpersonate && runsCode:
+		raise(RiskHigh, fmt.Sprintf("runs a %s as LocalSystem during the install script", ca.Kind))
+	case ca.NoImpersonate:
+		raise(RiskMedium, fmt.Sprintf("runs %s code as LocalSystem during the install script", ca.Kind))
+	case deferred && ca.Kind != "MSI":
+		raise(RiskMedium, "impersonates the installing user: in a user-context install without elevation its changes to the machine fail")
+	case runsCode:
+		raise(RiskMedium, "runs before the install script with the rights of the installing user, outside rollback")
+	default:
+		ca.Reasons = append(ca.Reasons, "runs before the install script")
+	}
+
+	if ca.Kind == "JScript" || ca.Kind == "VBScript" {
+		raise(RiskMedium, "depends on Windows Script Host, which is often disabled or blocked")
+		if ca.Type&caSourceMask == caSourceDirectory {
+			ca.Reasons = append(ca.Reasons, "the script is stored inline in the Target column")
+		}
+	}
+	if ca.Kind == "MSI" {
+		raise(RiskMedium, "installs a nested MSI, which Windows Installer does not support reliably")
+	}
+
+	// The target of an EXE action is its command line
+	target := strings.ToLower(ca.Target)
+	for _, command := range riskyCommands {
+		if ca.Kind == "EXE" && mentionsCommand(target, command) {
+			raise(RiskHigh, "starts "+strings.TrimSuffix(command, " /c"))
+			break
+		}
+	}
+	if strings.Contains(target, "http://") || strings.Contains(target, "https://") {
+		raise(RiskHigh, "references a URL, the action may download code")
+	}
+	if ca.Type&caHideTarget != 0 {
+		raise(RiskMedium, "hides its target from the installation log")
+	}
+	if ca.Kind == "EXE" && ca.Type&caContinue != 0 {
+		ca.Reasons = append(ca.Reasons, "its exit code is ignored")
+	}
+	if ca.Type&caAsync != 0 {
+		ca.Reasons = append(ca.Reasons, "runs asynchronously")
+	}
+}
+
+// mentionsCommand reports whether a command line names a program, as a whole word
+// or the last element of a path
+func mentionsCommand(commandLine, command string) bool {
+	for i := 0; ; {
+		j := strings.Index(commandLine[i:], command)
+		if j < 0 {
+			return false
+		}
+		start := i + j
+		if start == 0 || strings.ContainsRune(` "'\/[]`, rune(commandLine[start-1])) {
+			return true
+		}
+		i = start + 1
+	}
+}
@@ -0,0 +1,156 @@
+package packager
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/msitest"
+)
+
+// customActionTable is the CustomAction table of the Windows Installer schema
+func customActionTable(rows ...[]any) msitest.Table {
+	return msitest.Table{
+		Name: "CustomAction",
+		Columns: []msitest.Column{
+			{Name: "Action", Type: msitest.ColumnString | msitest.ColumnKey | 72},
+			{Name: "Type", Type: msitest.ColumnInt16},
+			{Name: "Source", Type: msitest.ColumnString | msitest.ColumnNullable | 72},
+			{Name: "Target", Type: msitest.ColumnString | msitest.ColumnNullable | 255},
+		},
+		Rows: rows,
+	}
+}
+
+func TestMsiDatabaseRows(t *testing.T) {
+	path := msitest.WriteFile(t, t.TempDir(), "app.msi", msitest.MSI{
+		Properties: []msitest.Property{
+			{Name: "ProductName", Value: "Müller Viewer"},
+			{Name: "ALLUSERS", Value: "1"},
+		},
+		Tables: []msitest.Table{customActionTable(
+			[]any{"SetInstallDir", 51, "INSTALLDIR", "[ProgramFilesFolder]Viewer"},
+			[]any{"Cleanup", -29694, nil, nil},
+		)},
+	})
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open MSI: %v", err)
+	}
+	defer file.Close()
+
+	db, err := openMsiDatabase(file)
+	if err != nil {
+		t.Fatalf("openMsiDatabase() error = %v", err)
+	}
+	if got := db.Property("ProductName"); got != "Müller Viewer" {
+		t.Errorf("Property(ProductName) = %q", got)
+	}
+	if got := db.Property("ALLUSERS"); got != "1" {
+		t.Errorf("Property(ALLUSERS) = %q", got)
+	}
+
+	rows := db.Rows("CustomAction")
+	if len(rows) != 2 {
+		t.Fatalf("Rows(CustomAction) = %v", rows)
+	}
+	if rows[0]["Action"] != "SetInstallDir" || rows[0]["Type"] != "51" || rows[0]["Target"] != "[ProgramFilesFolder]Viewer" {
+		t.Errorf("Row 0 = %v", rows[0])
+	}
+	if rows[1]["Type"] != "-29694" {
+		t.Errorf("Negative Type = %s, want -29694", rows[1]["Type"])
+	}
+	if _, ok := rows[1]["Source"]; ok {
+		t.Errorf("Null Source read as %q", rows[1]["Source"])
+	}
+	if rows := db.Rows("ServiceInstall"); rows != nil {
+		t.Errorf("Rows of a missing table = %v", rows)
+	}
+	if _, ok := decodeMsiStreamName("SummaryInformation"); ok {
+		t.Error("Summary Information decoded as a table")
+	}
+}
+
+func TestAnalyzeMsiCustomActions(t *testing.T) {
+	path := msitest.WriteFile(t, t.TempDir(), "vendor.msi", msitest.MSI{
+		Properties: []msitest.Property{
+			{Name: "Manufacturer", Value: "Contoso Ltd"},
+			{Name: "ProductCode", Value: "{6F1A2B3C-4D5E-4F60-8A9B-0C1D2E3F4A5B}"},
+			{Name: "ProductName", Value: "Contoso Agent"},
+			{Name: "ProductVersion", Value: "4.2.0"},
+		},
+		Tables: []msitest.Table{customActionTable(
+			// Immediate DLL call from the Binary table
+			[]any{"CheckLicense", 1, "LicenseDll", "CheckLicense"},
+			// Set a property: does not run code
+			[]any{"SetInstallDir", 51, "INSTALLDIR", "[ProgramFilesFolder]Contoso"},
+			// Deferred, no impersonation, PowerShell from the installed files directory
+			[]any{"ConfigureAgent", 34 | 0x400 | 0x800, "INSTALLDIR", `powershell.exe -ExecutionPolicy Bypass -File "[INSTALLDIR]setup.ps1"`},
+			// Deferred impersonated VBScript stored inline
+			[]any{"WriteUserConfig", 38 | 0x400, nil, "CreateObject(\"WScript.Shell\")"},
+			// Rollback of an installed EXE with a hidden command line
+			[]any{"UndoAgent", 18 | 0x500 | 0x2000, "agent.exe", "--uninstall"},
+			// Tool whose name only ends like sc.exe
+			[]any{"RunDisc", 18, "disc.exe", "[INSTALLDIR]disc.exe /quiet"},
+		)},
+	})
+
+	analysis, err := AnalyzeMsi(path)
+	if err != nil {
+		t.Fatalf("AnalyzeMsi() error = %v", err)
+	}
+	if analysis.Info.ProductName != "Contoso Agent" {
+		t.Errorf("Info = %+v", analysis.Info)
+	}
+
+	byAction := map[string]MsiCustomAction{}
+	var order []string
+	for _, ca := range analysis.CustomActions {
+		byAction[ca.Action] = ca
+		order = append(order, ca.Action)
+	}
+	if _, ok := byAction["SetInstallDir"]; ok {
+		t.Error("Property-setting action listed")
+	}
+	if order[0] != "ConfigureAgent" {
+		t.Errorf("Actions = %v, want the high-risk action first", order)
+	}
+
+	tests := []struct {
+		action    string
+		kind      string
+		execution string
+		risk      string
+		reason    string
+	}{
+		{"CheckLicense", "DLL", "immediate", RiskLow, "runs before the install script"},
+		{"ConfigureAgent", "EXE", "deferred", RiskHigh, "starts powershell"},
+		{"WriteUserConfig", "VBScript", "deferred", RiskMedium, "impersonates the installing user"},
+		{"UndoAgent", "EXE", "rollback", RiskMedium, "hides its target"},
+		{"RunDisc", "EXE", "immediate", RiskMedium, "rights of the installing user"},
+	}
+	for _, tt := range tests {
+		ca, ok := byAction[tt.action]
+		if !ok {
+			t.Errorf("%s not listed", tt.action)
+			continue
+		}
+		if ca.Kind != tt.kind || ca.Execution != tt.execution || ca.Risk != tt.risk {
+			t.Errorf("%s = %s %s %s, want %s %s %s", tt.action, ca.Kind, ca.Execution, ca.Risk, tt.kind, tt.execution, tt.risk)
+		}
+		if !slices.ContainsFunc(ca.Reasons, func(r string) bool { return strings.Contains(r, tt.reason) }) {
+			t.Errorf("%s reasons = %v, want %q", tt.action, ca.Reasons, tt.reason)
+		}
+	}
+	if !byAction["ConfigureAgent"].NoImpersonate {
+		t.Error("ConfigureAgent NoImpersonate = false")
+	}
+
+	if risky := analysis.Risky(RiskHigh); len(risky) != 1 {
+		t.Errorf("Risky(high) = %d actions, want 1", len(risky))
+	}
+	if _, err := ParseRiskLevel("critical"); err == nil {
+		t.Error("Expected error for an unknown risk level")
+	}
+}
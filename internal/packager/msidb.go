@@ -0,0 +1,261 @@
+package packager
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/richardlehane/mscfb"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// MSI column type bits (see the _Columns table of Windows Installer)
+const (
+	msiTypeValid    = 0x0100
+	msiTypeString   = 0x0800
+	msiTypeNullable = 0x1000
+	msiTypeSizeMask = 0x00FF
+	// msiTypeBinary marks stream columns, stored as a 2-byte reference
+	msiTypeBinary = msiTypeString | msiTypeValid
+	// msiLongStringRefs in the string pool codepage means string references take 3 bytes
+	msiLongStringRefs = 0x80000000
+	// msiCodepageUTF8 is the string pool codepage of UTF-8 databases
+	msiCodepageUTF8 = 65001
+)
+
+// msiDatabase reads the tables of an MSI through their column definitions, unlike the
+// pattern matching of readMsiInfo, which only finds the few properties Detection.xml needs
+type msiDatabase struct {
+	strings    []string // index 0 is the null string
+	stringRefs int      // bytes per string reference, 2 or 3
+	columns    map[string][]msiColumn
+	streams    map[string][]byte // table streams by decoded name
+}
+
+// msiColumn is a column of an MSI table
+type msiColumn struct {
+	Name string
+	Type int
+}
+
+// isString reports whether the column holds string pool references
+func (c msiColumn) isString() bool {
+	return c.Type&msiTypeString != 0 && c.Type&^msiTypeNullable != msiTypeBinary
+}
+
+// size returns the bytes a cell of the column takes in the table stream
+func (c msiColumn) size(stringRefs int) int {
+	switch {
+	case c.Type&^msiTypeNullable == msiTypeBinary:
+		return 2
+	case c.Type&msiTypeString != 0:
+		return stringRefs
+	case c.Type&msiTypeSizeMask <= 2:
+		return 2
+	default:
+		return 4
+	}
+}
+
+// msiRow is a table row by column name; strings are decoded and integers are
+// formatted in decimal, null cells are absent
+type msiRow map[string]string
+
+// openMsiDatabase reads the string pool and the table streams of an MSI
+func openMsiDatabase(file io.ReaderAt) (*msiDatabase, error) {
+	doc, err := mscfb.New(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse MSI as OLE document: %w", err)
+	}
+
+	streams := make(map[string][]byte)
+	for entry, err := doc.Next(); err == nil; entry, err = doc.Next() {
+		name, ok := decodeMsiStreamName(entry.Name)
+		if !ok {
+			continue
+		}
+		data, err := io.ReadAll(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read MSI table %s: %w", name, err)
+		}
+		streams[name] = data
+	}
+
+	db := &msiDatabase{streams: streams, stringRefs: 2}
+	if err := db.readStrings(streams["_StringPool"], streams["_StringData"]); err != nil {
+		return nil, err
+	}
+	if err := db.readColumns(streams["_Columns"]); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// decodeMsiStreamName decodes the compressed name of a table stream; other streams
+// (summary information, embedded cabinets and binaries) are reported as not a table
+func decodeMsiStreamName(name string) (string, bool) {
+	runes := []rune(name)
+	if len(runes) == 0 || runes[0] != 0x4840 {
+		return "", false
+	}
+	var b strings.Builder
+	for _, r := range runes[1:] {
+		switch {
+		case r >= 0x3800 && r < 0x4800:
+			r -= 0x3800
+			b.WriteByte(msiNameCharset[r&0x3F])
+			b.WriteByte(msiNameCharset[r>>6&0x3F])
+		case r >= 0x4800 && r < 0x4840:
+			b.WriteByte(msiNameCharset[r-0x4800])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), true
+}
+
+// msiNameCharset maps 6-bit values to the characters of compressed stream names
+const msiNameCharset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz._"
+
+// readStrings decodes the string pool: a codepage, then a length and reference count
+// per string, whose bytes follow one another in the string data
+func (db *msiDatabase) readStrings(pool, data []byte) error {
+	if len(pool) < 4 {
+		return fmt.Errorf("MSI has no string pool")
+	}
+	codepage := binary.LittleEndian.Uint32(pool)
+	if codepage&msiLongStringRefs != 0 {
+		db.stringRefs = 3
+		codepage &^= msiLongStringRefs
+	}
+	decode := func(b []byte) string { return string(b) }
+	if codepage != msiCodepageUTF8 {
+		// Windows-1252 covers the Western codepages installers use in practice
+		decoder := charmap.Windows1252.NewDecoder()
+		decode = func(b []byte) string {
+			s, err := decoder.Bytes(b)
+			if err != nil {
+				return string(b)
+			}
+			return string(s)
+		}
+	}
+
+	db.strings = []string{""}
+	offset := 0
+	for i := 4; i+4 <= len(pool); i += 4 {
+		length := int(binary.LittleEndian.Uint16(pool[i:]))
+		refs := binary.LittleEndian.Uint16(pool[i+2:])
+		if length == 0 && refs != 0 {
+			// Strings over 64 KB: the high word of the length is in the reference count,
+			// and the next entry holds the low word
+			if i+8 > len(pool) {
+				break
+			}
+			length = int(refs)<<16 | int(binary.LittleEndian.Uint16(pool[i+4:]))
+			i += 4
+		}
+		if offset+length > len(data) {
+			return fmt.Errorf("MSI string pool exceeds its string data")
+		}
+		db.strings = append(db.strings, decode(data[offset:offset+length]))
+		offset += length
+	}
+	return nil
+}
+
+// readColumns reads the column definitions of all tables from _Columns
+// (Table, Number, Name, Type, stored column by column)
+func (db *msiDatabase) readColumns(data []byte) error {
+	rows := len(data) / (2*db.stringRefs + 4)
+	db.columns = make(map[string][]msiColumn)
+
+	tables := data[:rows*db.stringRefs]
+	numbers := data[rows*db.stringRefs : rows*(db.stringRefs+2)]
+	names := data[rows*(db.stringRefs+2) : rows*(2*db.stringRefs+2)]
+	types := data[rows*(2*db.stringRefs+2) : rows*(2*db.stringRefs+4)]
+	for r := 0; r < rows; r++ {
+		table := db.stringAt(tables, r)
+		number := int(binary.LittleEndian.Uint16(numbers[r*2:]) ^ 0x8000)
+		column := msiColumn{
+			Name: db.stringAt(names, r),
+			Type: int(binary.LittleEndian.Uint16(types[r*2:]) ^ 0x8000),
+		}
+		cols := db.columns[table]
+		for len(cols) < number {
+			cols = append(cols, msiColumn{})
+		}
+		if number >= 1 {
+			cols[number-1] = column
+		}
+		db.columns[table] = cols
+	}
+	return nil
+}
+
+// stringAt returns the string referenced by cell r of a string column
+func (db *msiDatabase) stringAt(column []byte, r int) string {
+	var id int
+	if db.stringRefs == 3 {
+		id = int(column[r*3]) | int(column[r*3+1])<<8 | int(column[r*3+2])<<16
+	} else {
+		id = int(binary.LittleEndian.Uint16(column[r*2:]))
+	}
+	if id < len(db.strings) {
+		return db.strings[id]
+	}
+	return ""
+}
+
+// Rows returns the rows of a table; a table missing from the database has none
+func (db *msiDatabase) Rows(table string) []msiRow {
+	cols := db.columns[table]
+	data := db.streams[table]
+	rowSize := 0
+	for _, c := range cols {
+		rowSize += c.size(db.stringRefs)
+	}
+	if rowSize == 0 || len(data) < rowSize {
+		return nil
+	}
+	count := len(data) / rowSize
+
+	rows := make([]msiRow, count)
+	for r := range rows {
+		rows[r] = msiRow{}
+	}
+	offset := 0
+	for _, c := range cols {
+		size := c.size(db.stringRefs)
+		cells := data[offset : offset+count*size]
+		offset += count * size
+		for r := range rows {
+			switch {
+			case c.isString():
+				if s := db.stringAt(cells, r); s != "" {
+					rows[r][c.Name] = s
+				}
+			case size == 2 && c.Type&^msiTypeNullable != msiTypeBinary:
+				if v := binary.LittleEndian.Uint16(cells[r*2:]); v != 0 {
+					rows[r][c.Name] = fmt.Sprint(int16(v ^ 0x8000))
+				}
+			case size == 4:
+				if v := binary.LittleEndian.Uint32(cells[r*4:]); v != 0 {
+					rows[r][c.Name] = fmt.Sprint(int32(v ^ 0x80000000))
+				}
+			}
+		}
+	}
+	return rows
+}
+
+// Property returns the value of a row of the Property table
+func (db *msiDatabase) Property(name string) string {
+	for _, row := range db.Rows("Property") {
+		if row["Property"] == name {
+			return row["Value"]
+		}
+	}
+	return ""
+}
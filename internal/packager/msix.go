@@ -0,0 +1,325 @@
+package packager
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+const (
+	// msixBundleManifestPath is the location of the bundle manifest inside an .msixbundle
+	msixBundleManifestPath = "AppxMetadata/AppxBundleManifest.xml"
+	// msixPackageManifestPath is the location of the package manifest inside an .msix
+	msixPackageManifestPath = "AppxManifest.xml"
+	// MsixDetectionSuffix is appended to the package path for the generated detection script
+	MsixDetectionSuffix = ".detect.ps1"
+)
+
+// MsixInfo contains identity metadata extracted from an MSIX package, bundle or App Installer file
+type MsixInfo struct {
+	FileName     string        // Name of the file the metadata was read from
+	Name         string        // Package identity name (e.g., "Contoso.App")
+	Publisher    string        // Publisher certificate subject (e.g., "CN=Contoso")
+	Version      string        // Package identity version (e.g., "1.2.3.0")
+	Architecture string        // Processor architecture, if declared
+	SourceURI    string        // Uri of the main package/bundle (App Installer only)
+	Packages     []MsixPackage // Packages contained in a bundle
+}
+
+// MsixPackage describes a single package listed in an MSIX bundle manifest
+type MsixPackage struct {
+	FileName     string // Package file name inside the bundle
+	Type         string // "application" or "resource"
+	Version      string // Package version
+	Architecture string // Processor architecture (e.g., "x64", "neutral")
+}
+
+// msixIdentityXML is the Identity element shared by bundle and package manifests
+type msixIdentityXML struct {
+	Name                  string `xml:"Name,attr"`
+	Publisher             string `xml:"Publisher,attr"`
+	Version               string `xml:"Version,attr"`
+	ProcessorArchitecture string `xml:"ProcessorArchitecture,attr"`
+}
+
+// msixBundleManifestXML is the root element of AppxBundleManifest.xml
+type msixBundleManifestXML struct {
+	XMLName  xml.Name        `xml:"Bundle"`
+	Identity msixIdentityXML `xml:"Identity"`
+	Packages []struct {
+		Type         string `xml:"Type,attr"`
+		Version      string `xml:"Version,attr"`
+		Architecture string `xml:"Architecture,attr"`
+		FileName     string `xml:"FileName,attr"`
+	} `xml:"Packages>Package"`
+}
+
+// msixPackageManifestXML is the root element of AppxManifest.xml
+type msixPackageManifestXML struct {
+	XMLName  xml.Name        `xml:"Package"`
+	Identity msixIdentityXML `xml:"Identity"`
+}
+
+// appInstallerXML is the root element of an .appinstaller file
+type appInstallerXML struct {
+	XMLName     xml.Name             `xml:"AppInstaller"`
+	Version     string               `xml:"Version,attr"`
+	Uri         string               `xml:"Uri,attr"`
+	MainBundle  *appInstallerMainXML `xml:"MainBundle"`
+	MainPackage *appInstallerMainXML `xml:"MainPackage"`
+}
+
+// appInstallerMainXML is the MainBundle or MainPackage element of an .appinstaller file
+type appInstallerMainXML struct {
+	msixIdentityXML
+	Uri string `xml:"Uri,attr"`
+}
+
+// IsMsixPackageFile checks if the given file path has an .msix or .appx extension
+func IsMsixPackageFile(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".msix") || strings.HasSuffix(lower, ".appx")
+}
+
+// IsMsixSetupFile checks if the given file path is an MSIX/AppX package or bundle,
+// which can be wrapped as the setup file of a Win32 app
+func IsMsixSetupFile(path string) bool {
+	return IsMsixPackageFile(path) || IsMsixBundleFile(path)
+}
+
+// IsMsixBundleFile checks if the given file path has an .msixbundle or .appxbundle extension
+func IsMsixBundleFile(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".msixbundle") || strings.HasSuffix(lower, ".appxbundle")
+}
+
+// IsAppInstallerFile checks if the given file path has an .appinstaller extension
+func IsAppInstallerFile(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".appinstaller")
+}
+
+// ExtractMsixInfo extracts identity metadata from an .msix, .msixbundle or .appinstaller file
+func ExtractMsixInfo(path string) (*MsixInfo, error) {
+	switch {
+	case IsMsixPackageFile(path):
+		return ExtractMsixPackageInfo(path)
+	case IsMsixBundleFile(path):
+		return ExtractMsixBundleInfo(path)
+	case IsAppInstallerFile(path):
+		return ExtractAppInstallerInfo(path)
+	default:
+		return nil, fmt.Errorf("unsupported MSIX file type: %s", filepath.Ext(path))
+	}
+}
+
+// ExtractMsixPackageInfo reads the package identity from the AppxManifest.xml of an .msix or .appx file
+func ExtractMsixPackageInfo(packagePath string) (*MsixInfo, error) {
+	data, err := readMsixManifest(packagePath, msixPackageManifestPath, "package")
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest msixPackageManifestXML
+	if err := xml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse package manifest: %w", err)
+	}
+
+	return &MsixInfo{
+		FileName:     filepath.Base(packagePath),
+		Name:         manifest.Identity.Name,
+		Publisher:    manifest.Identity.Publisher,
+		Version:      manifest.Identity.Version,
+		Architecture: manifest.Identity.ProcessorArchitecture,
+	}, nil
+}
+
+// ExtractMsixBundleInfo reads the bundle manifest from an .msixbundle file
+func ExtractMsixBundleInfo(bundlePath string) (*MsixInfo, error) {
+	data, err := readMsixManifest(bundlePath, msixBundleManifestPath, "bundle")
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest msixBundleManifestXML
+	if err := xml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle manifest: %w", err)
+	}
+
+	info := &MsixInfo{
+		FileName:  filepath.Base(bundlePath),
+		Name:      manifest.Identity.Name,
+		Publisher: manifest.Identity.Publisher,
+		Version:   manifest.Identity.Version,
+	}
+	for _, pkg := range manifest.Packages {
+		info.Packages = append(info.Packages, MsixPackage{
+			FileName:     pkg.FileName,
+			Type:         pkg.Type,
+			Version:      pkg.Version,
+			Architecture: pkg.Architecture,
+		})
+	}
+
+	return info, nil
+}
+
+// readMsixManifest reads a manifest entry from an MSIX package or bundle
+// kind ("package" or "bundle") is used in error messages
+func readMsixManifest(path, manifestPath, kind string) ([]byte, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MSIX %s: %w", kind, err)
+	}
+	defer reader.Close()
+
+	var manifestFile *zip.File
+	for _, f := range reader.File {
+		if strings.EqualFold(f.Name, manifestPath) {
+			manifestFile = f
+			break
+		}
+	}
+	if manifestFile == nil {
+		return nil, fmt.Errorf("%s manifest not found: %s", kind, manifestPath)
+	}
+
+	rc, err := manifestFile.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s manifest: %w", kind, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s manifest: %w", kind, err)
+	}
+	return data, nil
+}
+
+// ExtractAppInstallerInfo reads the main bundle/package identity from an .appinstaller file
+func ExtractAppInstallerInfo(appInstallerPath string) (*MsixInfo, error) {
+	data, err := os.ReadFile(appInstallerPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read App Installer file: %w", err)
+	}
+
+	var appInstaller appInstallerXML
+	if err := xml.Unmarshal(data, &appInstaller); err != nil {
+		return nil, fmt.Errorf("failed to parse App Installer file: %w", err)
+	}
+
+	main := appInstaller.MainBundle
+	if main == nil {
+		main = appInstaller.MainPackage
+	}
+	if main == nil {
+		return nil, fmt.Errorf("App Installer file has no MainBundle or MainPackage")
+	}
+
+	return &MsixInfo{
+		FileName:     filepath.Base(appInstallerPath),
+		Name:         main.Name,
+		Publisher:    main.Publisher,
+		Version:      main.Version,
+		Architecture: main.ProcessorArchitecture,
+		SourceURI:    main.Uri,
+	}, nil
+}
+
+// findMsixFiles lists .msix, .msixbundle and .appinstaller files at the root of a directory
+func findMsixFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if IsMsixSetupFile(entry.Name()) || IsAppInstallerFile(entry.Name()) {
+			files = append(files, entry.Name())
+		}
+	}
+	return files, nil
+}
+
+// MsixInstallCommand returns an install command line for an MSIX setup file
+// Win32 apps install as SYSTEM, so the package is provisioned for every user of the
+// device rather than installed for the current user with Add-AppxPackage
+func MsixInstallCommand(setupFile string) string {
+	return fmt.Sprintf(`powershell.exe -NoProfile -ExecutionPolicy Bypass -Command "Add-AppxProvisionedPackage -Online -PackagePath '.\%s' -SkipLicense"`,
+		strings.ReplaceAll(setupFile, "'", "''"))
+}
+
+// MsixUninstallCommand returns an uninstall command line that deprovisions the package
+// and removes it for every user
+func MsixUninstallCommand(info *MsixInfo) string {
+	name := strings.ReplaceAll(info.Name, "'", "''")
+	return fmt.Sprintf(`powershell.exe -NoProfile -ExecutionPolicy Bypass -Command "Get-AppxProvisionedPackage -Online | Where-Object DisplayName -eq '%s' | Remove-AppxProvisionedPackage -Online -AllUsers; Get-AppxPackage -AllUsers -Name '%s' | Remove-AppxPackage -AllUsers"`,
+		name, name)
+}
+
+// msixDetectTemplate is an Intune custom detection script: the app is detected
+// when the script exits 0 and writes to STDOUT
+const msixDetectTemplate = `# Detection script for {{.Name}} {{.Version}}
+# Generated by LetsGoIntunePackager - use as a custom detection script for the Win32 app
+$PackageName = {{ps .Name}}
+$PackagedVersion = {{ps .Version}}
+
+$installed = Get-AppxProvisionedPackage -Online -ErrorAction SilentlyContinue |
+    Where-Object { $_.DisplayName -eq $PackageName } |
+    Sort-Object { [version]$_.Version } -Descending |
+    Select-Object -First 1
+if (-not $installed) {
+    $installed = Get-AppxPackage -AllUsers -Name $PackageName -ErrorAction SilentlyContinue |
+        Sort-Object { [version]$_.Version } -Descending |
+        Select-Object -First 1
+}
+
+if ($installed -and [version]$installed.Version -ge [version]$PackagedVersion) {
+    Write-Output "$PackageName $($installed.Version) is installed"
+}
+exit 0
+`
+
+// GenerateMsixDetectionScript creates a custom detection script that finds the
+// package identity, provisioned or installed, at or above the packaged version
+func GenerateMsixDetectionScript(info *MsixInfo) ([]byte, error) {
+	if info.Name == "" {
+		return nil, fmt.Errorf("MSIX package name is required")
+	}
+	if info.Version == "" {
+		return nil, fmt.Errorf("MSIX package version is required")
+	}
+
+	tmpl, err := template.New("msix-detect").Funcs(template.FuncMap{"ps": powershellQuote}).Parse(msixDetectTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse detection template: %w", err)
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, info); err != nil {
+		return nil, fmt.Errorf("failed to generate detection script: %w", err)
+	}
+	return []byte(strings.ReplaceAll(buf.String(), "\n", "\r\n")), nil
+}
+
+// writeMsixDetectionScript writes the detection script of an MSIX setup file next to the package
+func writeMsixDetectionScript(info *MsixInfo, packagePath string) (string, error) {
+	script, err := GenerateMsixDetectionScript(info)
+	if err != nil {
+		return "", err
+	}
+	path := packagePath + MsixDetectionSuffix
+	if err := os.WriteFile(path, script, 0644); err != nil {
+		return "", fmt.Errorf("failed to write detection script: %w", err)
+	}
+	return path, nil
+}
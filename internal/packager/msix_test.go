@@ -0,0 +1,320 @@
+package packager
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testBundleManifest = `<?xml version="1.0" encoding="UTF-8"?>
+<Bundle xmlns="http://schemas.microsoft.com/appx/2013/bundle" SchemaVersion="5.0">
+  <Identity Name="Contoso.App" Publisher="CN=Contoso, O=Contoso, C=US" Version="2.3.4.0" />
+  <Packages>
+    <Package Type="application" Version="2.3.4.0" Architecture="x64" FileName="Contoso.App_2.3.4.0_x64.msix" />
+    <Package Type="application" Version="2.3.4.0" Architecture="arm64" FileName="Contoso.App_2.3.4.0_arm64.msix" />
+  </Packages>
+</Bundle>`
+
+const testAppInstaller = `<?xml version="1.0" encoding="utf-8"?>
+<AppInstaller xmlns="http://schemas.microsoft.com/appx/appinstaller/2018" Version="1.0.0.0" Uri="https://example.com/app.appinstaller">
+  <MainBundle Name="Contoso.App" Publisher="CN=Contoso" Version="2.3.4.0" Uri="https://example.com/Contoso.App.msixbundle" />
+</AppInstaller>`
+
+const testPackageManifest = `<?xml version="1.0" encoding="utf-8"?>
+<Package xmlns="http://schemas.microsoft.com/appx/manifest/foundation/windows10" IgnorableNamespaces="uap rescap">
+  <Identity Name="Contoso.App" Publisher="CN=Contoso, O=Contoso, C=US" Version="2.3.4.0" ProcessorArchitecture="x64" />
+  <Properties>
+    <DisplayName>Contoso App</DisplayName>
+  </Properties>
+</Package>`
+
+func writeTestBundle(t *testing.T, path, manifest string) {
+	t.Helper()
+	writeTestMsix(t, path, msixBundleManifestPath, manifest)
+}
+
+// writeTestMsix writes an MSIX package or bundle holding only its manifest
+func writeTestMsix(t *testing.T, path, manifestPath, manifest string) {
+	t.Helper()
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create bundle: %v", err)
+	}
+	defer file.Close()
+
+	zipWriter := zip.NewWriter(file)
+	writer, err := zipWriter.Create(manifestPath)
+	if err != nil {
+		t.Fatalf("Failed to create manifest entry: %v", err)
+	}
+	if _, err := writer.Write([]byte(manifest)); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+	if err := zipWriter.Close(); err != nil {
+		t.Fatalf("Failed to close bundle: %v", err)
+	}
+}
+
+func TestIsMsixBundleFile(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"app.msixbundle", true},
+		{"APP.MSIXBUNDLE", true},
+		{"app.appxbundle", true},
+		{"app.msix", false},
+		{"app.appinstaller", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result := IsMsixBundleFile(tt.path)
+			if result != tt.expected {
+				t.Errorf("IsMsixBundleFile(%q) = %v, want %v", tt.path, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsMsixSetupFile(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"app.msix", true},
+		{"APP.APPX", true},
+		{"app.msixbundle", true},
+		{"app.appxbundle", true},
+		{"app.appinstaller", false},
+		{"app.msi", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result := IsMsixSetupFile(tt.path)
+			if result != tt.expected {
+				t.Errorf("IsMsixSetupFile(%q) = %v, want %v", tt.path, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExtractMsixPackageInfo(t *testing.T) {
+	tempDir := t.TempDir()
+
+	packagePath := filepath.Join(tempDir, "Contoso.App_2.3.4.0_x64.msix")
+	writeTestMsix(t, packagePath, msixPackageManifestPath, testPackageManifest)
+
+	info, err := ExtractMsixInfo(packagePath)
+	if err != nil {
+		t.Fatalf("ExtractMsixInfo() error = %v", err)
+	}
+	if info.Name != "Contoso.App" || info.Version != "2.3.4.0" || info.Architecture != "x64" {
+		t.Errorf("Identity = %s %s %s, want Contoso.App 2.3.4.0 x64", info.Name, info.Version, info.Architecture)
+	}
+	if info.Publisher != "CN=Contoso, O=Contoso, C=US" {
+		t.Errorf("Publisher = %s, want CN=Contoso, O=Contoso, C=US", info.Publisher)
+	}
+
+	// A bundle manifest is not a package manifest
+	bundlePath := filepath.Join(tempDir, "wrong.msix")
+	writeTestMsix(t, bundlePath, msixBundleManifestPath, testBundleManifest)
+	if _, err := ExtractMsixPackageInfo(bundlePath); err == nil {
+		t.Error("Expected error for package without AppxManifest.xml")
+	}
+}
+
+func TestGenerateMsixDetectionScript(t *testing.T) {
+	script, err := GenerateMsixDetectionScript(&MsixInfo{Name: "Contoso's.App", Version: "2.3.4.0"})
+	if err != nil {
+		t.Fatalf("GenerateMsixDetectionScript() error = %v", err)
+	}
+	text := string(script)
+	for _, want := range []string{"$PackageName = 'Contoso''s.App'", "$PackagedVersion = '2.3.4.0'", "Get-AppxProvisionedPackage -Online", "\r\nexit 0\r\n"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Script does not contain %q:\n%s", want, text)
+		}
+	}
+
+	if _, err := GenerateMsixDetectionScript(&MsixInfo{Name: "Contoso.App"}); err == nil {
+		t.Error("Expected error without a version")
+	}
+}
+
+func TestMsixCommands(t *testing.T) {
+	install := MsixInstallCommand("Contoso.App_2.3.4.0_x64.msix")
+	if !strings.Contains(install, `Add-AppxProvisionedPackage -Online -PackagePath '.\Contoso.App_2.3.4.0_x64.msix'`) {
+		t.Errorf("Install command = %s", install)
+	}
+	uninstall := MsixUninstallCommand(&MsixInfo{Name: "Contoso.App"})
+	if !strings.Contains(uninstall, "Remove-AppxProvisionedPackage") || !strings.Contains(uninstall, "Get-AppxPackage -AllUsers -Name 'Contoso.App'") {
+		t.Errorf("Uninstall command = %s", uninstall)
+	}
+}
+
+func TestExtractMsixBundleInfo(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "msixtest")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	bundlePath := filepath.Join(tempDir, "Contoso.App.msixbundle")
+	writeTestBundle(t, bundlePath, testBundleManifest)
+
+	info, err := ExtractMsixInfo(bundlePath)
+	if err != nil {
+		t.Fatalf("ExtractMsixInfo() error = %v", err)
+	}
+
+	if info.Name != "Contoso.App" {
+		t.Errorf("Name = %s, want Contoso.App", info.Name)
+	}
+	if info.Version != "2.3.4.0" {
+		t.Errorf("Version = %s, want 2.3.4.0", info.Version)
+	}
+	if info.Publisher != "CN=Contoso, O=Contoso, C=US" {
+		t.Errorf("Publisher = %s, want CN=Contoso, O=Contoso, C=US", info.Publisher)
+	}
+	if len(info.Packages) != 2 {
+		t.Fatalf("Packages = %d, want 2", len(info.Packages))
+	}
+	if info.Packages[1].Architecture != "arm64" {
+		t.Errorf("Packages[1].Architecture = %s, want arm64", info.Packages[1].Architecture)
+	}
+}
+
+func TestExtractMsixBundleInfoMissingManifest(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "msixtest")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	bundlePath := filepath.Join(tempDir, "empty.msixbundle")
+	zipData, err := ZipFolder(tempDir)
+	if err != nil {
+		t.Fatalf("ZipFolder() error = %v", err)
+	}
+	if err := os.WriteFile(bundlePath, zipData, 0644); err != nil {
+		t.Fatalf("Failed to write bundle: %v", err)
+	}
+
+	if _, err := ExtractMsixBundleInfo(bundlePath); err == nil {
+		t.Error("Expected error for bundle without manifest")
+	}
+}
+
+func TestExtractAppInstallerInfo(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "msixtest")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "app.appinstaller")
+	if err := os.WriteFile(path, []byte(testAppInstaller), 0644); err != nil {
+		t.Fatalf("Failed to write App Installer file: %v", err)
+	}
+
+	info, err := ExtractMsixInfo(path)
+	if err != nil {
+		t.Fatalf("ExtractMsixInfo() error = %v", err)
+	}
+
+	if info.Name != "Contoso.App" {
+		t.Errorf("Name = %s, want Contoso.App", info.Name)
+	}
+	if info.Version != "2.3.4.0" {
+		t.Errorf("Version = %s, want 2.3.4.0", info.Version)
+	}
+	if info.SourceURI != "https://example.com/Contoso.App.msixbundle" {
+		t.Errorf("SourceURI = %s, want https://example.com/Contoso.App.msixbundle", info.SourceURI)
+	}
+}
+
+func TestPackageWithMsixBundle(t *testing.T) {
+	sourceDir, err := os.MkdirTemp("", "source")
+	if err != nil {
+		t.Fatalf("Failed to create source dir: %v", err)
+	}
+	defer os.RemoveAll(sourceDir)
+
+	outputDir, err := os.MkdirTemp("", "output")
+	if err != nil {
+		t.Fatalf("Failed to create output dir: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	if err := os.WriteFile(filepath.Join(sourceDir, "install.ps1"), []byte("Add-AppxPackage"), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+	writeTestBundle(t, filepath.Join(sourceDir, "Contoso.App.msixbundle"), testBundleManifest)
+
+	result, err := Package(sourceDir, "install.ps1", outputDir, nil)
+	if err != nil {
+		t.Fatalf("Package() error = %v", err)
+	}
+
+	if len(result.MsixInfo) != 1 {
+		t.Fatalf("MsixInfo = %d entries, want 1", len(result.MsixInfo))
+	}
+	if result.MsixInfo[0].Version != "2.3.4.0" {
+		t.Errorf("MsixInfo[0].Version = %s, want 2.3.4.0", result.MsixInfo[0].Version)
+	}
+}
+
+func TestPackageWithMsixSetup(t *testing.T) {
+	sourceDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	setupFile := "Contoso.App_2.3.4.0_x64.msix"
+	writeTestMsix(t, filepath.Join(sourceDir, setupFile), msixPackageManifestPath, testPackageManifest)
+
+	result, err := Package(sourceDir, setupFile, outputDir, nil)
+	if err != nil {
+		t.Fatalf("Package() error = %v", err)
+	}
+
+	if result.SetupMsix == nil || result.SetupMsix.Name != "Contoso.App" || result.SetupMsix.Version != "2.3.4.0" {
+		t.Fatalf("SetupMsix = %+v, want Contoso.App 2.3.4.0", result.SetupMsix)
+	}
+	if len(result.MsixInfo) != 0 {
+		t.Errorf("MsixInfo = %d entries, want the setup file reported only as SetupMsix", len(result.MsixInfo))
+	}
+	if result.DetectionScriptPath != result.OutputPath+MsixDetectionSuffix {
+		t.Errorf("DetectionScriptPath = %s, want %s", result.DetectionScriptPath, result.OutputPath+MsixDetectionSuffix)
+	}
+	script, err := os.ReadFile(result.DetectionScriptPath)
+	if err != nil {
+		t.Fatalf("Failed to read detection script: %v", err)
+	}
+	if !strings.Contains(string(script), "'Contoso.App'") {
+		t.Errorf("Detection script does not look for Contoso.App:\n%s", script)
+	}
+
+	appInfo, err := ReadDetectionXML(result.OutputPath)
+	if err != nil {
+		t.Fatalf("ReadDetectionXML() error = %v", err)
+	}
+	if appInfo.Name != "Contoso.App_2.3.4.0_x64" || appInfo.SetupFile != setupFile {
+		t.Errorf("Detection.xml Name = %s, SetupFile = %s", appInfo.Name, appInfo.SetupFile)
+	}
+}
+
+func TestPackageWithInvalidMsixSetup(t *testing.T) {
+	sourceDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "broken.msix"), []byte("not a zip"), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+
+	if _, err := Package(sourceDir, "broken.msix", t.TempDir(), nil); err == nil {
+		t.Error("Expected error for an MSIX setup file without a readable manifest")
+	}
+}
@@ -0,0 +1,142 @@
+package packager
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/msoleps"
+)
+
+// MspInfo contains metadata extracted from an MSP patch
+type MspInfo struct {
+	// PatchCode is the {GUID} identifying the patch, from the Summary Information revision number
+	PatchCode string
+	// ObsoletedPatches are the patch codes this patch supersedes
+	ObsoletedPatches []string
+	// TargetProductCodes are the product codes the patch applies to, from the Summary
+	// Information template
+	TargetProductCodes []string
+	// DisplayName, Description, Manufacturer, Classification (e.g. Update, Hotfix or
+	// Security Rollup) and MoreInfoURL come from the MsiPatchMetadata table
+	DisplayName    string
+	Description    string
+	Manufacturer   string
+	Classification string
+	MoreInfoURL    string
+	// TargetProductName is the name of the product the patch applies to
+	TargetProductName string
+}
+
+// IsMspFile checks if the given file path has an .msp extension
+func IsMspFile(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".msp")
+}
+
+// ExtractMspInfo extracts the patch code, target products and patch metadata of an MSP
+func ExtractMspInfo(mspPath string) (*MspInfo, error) {
+	file, err := os.Open(mspPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MSP file: %w", err)
+	}
+	defer file.Close()
+	return readMspInfo(file)
+}
+
+// readMspInfo extracts metadata from MSP data
+func readMspInfo(file msiSource) (*MspInfo, error) {
+	doc, err := mscfb.New(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse MSP as OLE document: %w", err)
+	}
+
+	info := &MspInfo{}
+	for entry, err := doc.Next(); err == nil; entry, err = doc.Next() {
+		// mscfb strips the leading \x05 from the name and keeps it in Initial
+		if entry.Initial != 0x05 || entry.Name != "SummaryInformation" {
+			continue
+		}
+		data, err := io.ReadAll(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read MSP summary information: %w", err)
+		}
+		props, err := msoleps.NewFrom(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse MSP summary information: %w", err)
+		}
+		for _, prop := range props.Property {
+			switch prop.Name {
+			case "RevNumber":
+				// The patch code followed by the codes of the patches it obsoletes
+				codes := splitGUIDs(prop.String())
+				if len(codes) > 0 {
+					info.PatchCode, info.ObsoletedPatches = codes[0], codes[1:]
+				}
+			case "Template":
+				info.TargetProductCodes = splitGUIDs(prop.String())
+			case "Subject":
+				info.DisplayName = prop.String()
+			case "Author":
+				info.Manufacturer = prop.String()
+			}
+		}
+	}
+	if info.PatchCode == "" {
+		return nil, fmt.Errorf("MSP has no patch code in its summary information")
+	}
+
+	// The metadata table is optional; patches built without it keep the summary values
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read MSP file: %w", err)
+	}
+	if db, err := openMsiDatabase(file); err == nil {
+		for _, row := range db.Rows("MsiPatchMetadata") {
+			// Rows with a company are vendor extensions, not the standard properties
+			if row["Company"] != "" || row["Value"] == "" {
+				continue
+			}
+			switch row["Property"] {
+			case "DisplayName":
+				info.DisplayName = row["Value"]
+			case "Description":
+				info.Description = row["Value"]
+			case "ManufacturerName":
+				info.Manufacturer = row["Value"]
+			case "Classification":
+				info.Classification = row["Value"]
+			case "MoreInfoURL":
+				info.MoreInfoURL = row["Value"]
+			case "TargetProductName":
+				info.TargetProductName = row["Value"]
+			}
+		}
+	}
+	return info, nil
+}
+
+// MspUninstallCommand returns the command removing a patch from the product it was applied
+// to, or an empty string when the patch targets no single product
+func MspUninstallCommand(info *MspInfo) string {
+	if len(info.TargetProductCodes) != 1 {
+		return ""
+	}
+	return fmt.Sprintf("msiexec /package %s MSIPATCHREMOVE=%s /qn", info.TargetProductCodes[0], info.PatchCode)
+}
+
+// splitGUIDs returns the {GUID}s of a list, separated by semicolons or not separated at all
+func splitGUIDs(s string) []string {
+	var guids []string
+	for i := 0; i+38 <= len(s); {
+		if s[i] != '{' || !isValidGUID(s[i:i+38]) {
+			i++
+			continue
+		}
+		guids = append(guids, strings.ToUpper(s[i:i+38]))
+		i += 38
+	}
+	return guids
+}
@@ -0,0 +1,122 @@
+package packager
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/msitest"
+)
+
+const (
+	testPatchCode   = "{AAAAAAAA-1111-2222-3333-444444444444}"
+	testObsoleted   = "{BBBBBBBB-1111-2222-3333-444444444444}"
+	testTargetCode  = "{CCCCCCCC-1111-2222-3333-444444444444}"
+	testTargetCode2 = "{DDDDDDDD-1111-2222-3333-444444444444}"
+)
+
+func TestExtractMspInfo(t *testing.T) {
+	const s72 = msitest.ColumnString | 72
+	metadata := msitest.Table{Name: "MsiPatchMetadata", Columns: []msitest.Column{
+		{Name: "Company", Type: s72 | msitest.ColumnKey | msitest.ColumnNullable},
+		{Name: "Property", Type: s72 | msitest.ColumnKey},
+		{Name: "Value", Type: msitest.ColumnString | 255},
+	}, Rows: [][]any{
+		{nil, "DisplayName", "Contoso Agent 2.1 Hotfix 3"},
+		{nil, "Description", "Fixes a crash on startup"},
+		{nil, "ManufacturerName", "Contoso Ltd"},
+		{nil, "Classification", "Hotfix"},
+		{nil, "MoreInfoURL", "https://contoso.example/kb/3"},
+		{nil, "TargetProductName", "Contoso Agent"},
+		{"Contoso", "DisplayName", "Vendor extension"},
+	}}
+
+	tests := map[string]struct {
+		msp  msitest.MSI
+		want MspInfo
+	}{
+		"with metadata table": {
+			msp: msitest.MSI{
+				PackageCode: testPatchCode + testObsoleted,
+				Template:    testTargetCode + ";" + testTargetCode2,
+				Subject:     "Summary subject",
+				Tables:      []msitest.Table{metadata},
+			},
+			want: MspInfo{
+				PatchCode:          testPatchCode,
+				ObsoletedPatches:   []string{testObsoleted},
+				TargetProductCodes: []string{testTargetCode, testTargetCode2},
+				DisplayName:        "Contoso Agent 2.1 Hotfix 3",
+				Description:        "Fixes a crash on startup",
+				Manufacturer:       "Contoso Ltd",
+				Classification:     "Hotfix",
+				MoreInfoURL:        "https://contoso.example/kb/3",
+				TargetProductName:  "Contoso Agent",
+			},
+		},
+		"summary only": {
+			msp: msitest.MSI{
+				PackageCode: testPatchCode,
+				Template:    testTargetCode,
+				Subject:     "Contoso Agent Patch",
+				Author:      "Contoso Ltd",
+			},
+			want: MspInfo{
+				PatchCode:          testPatchCode,
+				ObsoletedPatches:   []string{},
+				TargetProductCodes: []string{testTargetCode},
+				DisplayName:        "Contoso Agent Patch",
+				Manufacturer:       "Contoso Ltd",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := msitest.WriteFile(t, t.TempDir(), "hotfix.msp", tt.msp)
+			got, err := ExtractMspInfo(path)
+			if err != nil {
+				t.Fatalf("ExtractMspInfo() error = %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("ExtractMspInfo() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractMspInfoNoPatchCode(t *testing.T) {
+	path := msitest.WriteFile(t, t.TempDir(), "setup.msp", msitest.MSI{Template: testTargetCode})
+	if _, err := ExtractMspInfo(path); err == nil {
+		t.Error("Expected error for an MSP without a patch code")
+	}
+	if _, err := ExtractMspInfo(filepath.Join(t.TempDir(), "missing.msp")); err == nil {
+		t.Error("Expected error for a missing MSP")
+	}
+}
+
+func TestMspUninstallCommand(t *testing.T) {
+	info := &MspInfo{PatchCode: testPatchCode, TargetProductCodes: []string{testTargetCode}}
+	want := "msiexec /package " + testTargetCode + " MSIPATCHREMOVE=" + testPatchCode + " /qn"
+	if got := MspUninstallCommand(info); got != want {
+		t.Errorf("MspUninstallCommand() = %q, want %q", got, want)
+	}
+	info.TargetProductCodes = append(info.TargetProductCodes, testTargetCode2)
+	if got := MspUninstallCommand(info); got != "" {
+		t.Errorf("MspUninstallCommand() = %q for several targets, want empty", got)
+	}
+}
+
+func TestPackageMsp(t *testing.T) {
+	sourceDir := t.TempDir()
+	msitest.WriteFile(t, sourceDir, "hotfix.msp", msitest.MSI{PackageCode: testPatchCode, Template: testTargetCode})
+	result, err := PackageWithOptions(sourceDir, "hotfix.msp", t.TempDir(), Options{}, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	if result.SetupMsp == nil || result.SetupMsp.PatchCode != testPatchCode {
+		t.Errorf("SetupMsp = %+v, want patch code %s", result.SetupMsp, testPatchCode)
+	}
+	if result.InstallCommand != `msiexec /p "hotfix.msp" /qn` {
+		t.Errorf("InstallCommand = %q", result.InstallCommand)
+	}
+}
@@ -0,0 +1,71 @@
+//go:build !windows
+
+package packager
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// networkFilesystems are the mount types of network shares in /proc/mounts
+var networkFilesystems = map[string]bool{
+	"cifs": true, "smb3": true, "smbfs": true, "nfs": true, "nfs4": true, "afpfs": true, "fuse.sshfs": true,
+}
+
+// IsNetworkPath reports whether path is on a network share: a UNC-style //server/share
+// path, or a folder on an SMB or NFS mount (detected from /proc/mounts where available)
+func IsNetworkPath(path string) bool {
+	if strings.HasPrefix(filepath.ToSlash(path), "//") {
+		return true
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	f, err := os.Open("/proc/mounts")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	// The longest mount point containing the path is the one it lives on
+	var mountPoint, fsType string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		// Spaces in mount points are escaped as \040
+		dir := strings.ReplaceAll(fields[1], `\040`, " ")
+		if (abs == dir || strings.HasPrefix(abs, strings.TrimSuffix(dir, "/")+"/")) && len(dir) >= len(mountPoint) {
+			mountPoint, fsType = dir, fields[2]
+		}
+	}
+	return networkFilesystems[fsType]
+}
+
+// transientErrors are errors of network file systems that may succeed when retried
+var transientErrors = []syscall.Errno{
+	syscall.EIO, syscall.EAGAIN, syscall.EINTR, syscall.ETIMEDOUT, syscall.ESTALE,
+	syscall.ECONNRESET, syscall.ECONNABORTED, syscall.ENETDOWN, syscall.ENETRESET,
+	syscall.ENETUNREACH, syscall.EHOSTDOWN, syscall.EHOSTUNREACH,
+}
+
+// isTransientWriteError reports whether a write error may be caused by a flaky network
+func isTransientWriteError(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	for _, transient := range transientErrors {
+		if errno == transient {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package packager
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var procGetDriveTypeW = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDriveTypeW")
+
+// driveRemote is the GetDriveType result for network drives
+const driveRemote = 4
+
+// IsNetworkPath reports whether path is on a network share: a UNC path or a mapped network drive
+func IsNetworkPath(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	volume := filepath.VolumeName(abs)
+	if strings.HasPrefix(volume, `\\`) {
+		// \\?\UNC\server\share is a UNC path; other \\?\ paths are local drives
+		return !strings.HasPrefix(volume, `\\?\`) || strings.HasPrefix(strings.ToUpper(abs), `\\?\UNC\`)
+	}
+	root, err := syscall.UTF16PtrFromString(volume + `\`)
+	if err != nil {
+		return false
+	}
+	driveType, _, _ := procGetDriveTypeW.Call(uintptr(unsafe.Pointer(root)))
+	return driveType == driveRemote
+}
+
+// transientErrors are Windows errors of network shares that may succeed when retried
+var transientErrors = []syscall.Errno{
+	51,    // ERROR_REM_NOT_LIST: the remote computer is not available
+	53,    // ERROR_BAD_NETPATH
+	59,    // ERROR_UNEXP_NET_ERR
+	64,    // ERROR_NETNAME_DELETED: the connection was dropped
+	121,   // ERROR_SEM_TIMEOUT
+	1231,  // ERROR_NETWORK_UNREACHABLE
+	1236,  // ERROR_CONNECTION_ABORTED
+	10054, // WSAECONNRESET
+	10060, // WSAETIMEDOUT
+}
+
+// isTransientWriteError reports whether a write error may be caused by a flaky network
+func isTransientWriteError(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	for _, transient := range transientErrors {
+		if errno == transient {
+			return true
+		}
+	}
+	return false
+}
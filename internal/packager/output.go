@@ -0,0 +1,224 @@
+package packager
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// outputWriteAttempts is the number of times a verified package write is tried
+const outputWriteAttempts = 4
+
+// outputRetryDelay is the wait before the first retry; it doubles for every further retry
+var outputRetryDelay = time.Second
+
+// writeAttempt writes and verifies the package once (replaced in tests to simulate failures)
+var writeAttempt = writeVerifiedSum
+
+// ErrOutputMismatch reports a package file that does not read back as written
+var ErrOutputMismatch = errors.New("written file does not match the package")
+
+// DefaultStagingRoot returns the per-user folder packages are staged in before they are
+// copied to the output folder (~/.cache/intunewin/staging on Linux)
+func DefaultStagingRoot() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "intunewin", "staging"), nil
+}
+
+// outputWrite describes how a package file was written
+type outputWrite struct {
+	verified bool
+	attempts int
+}
+
+// writeOutputFile writes an in-memory package file to path (see writeOutputFrom)
+func writeOutputFile(ctx context.Context, path string, data []byte, opts Options, log *slog.Logger) (outputWrite, error) {
+	return writeOutputFrom(ctx, path, bytes.NewReader(data), int64(len(data)), opts, log)
+}
+
+// writeOutputFrom writes a package file of size bytes read from content to path, or to
+// opts.OutputWriter when set
+// Outputs on network shares (or any output with opts.VerifyOutput) are written to a
+// temporary file, read back and compared by size and SHA256, and only then renamed into
+// place; transient network errors and mismatches are retried with backoff. With
+// opts.StagingRoot, the package is first written there so a local copy survives a failed copy
+func writeOutputFrom(ctx context.Context, path string, content io.ReaderAt, size int64, opts Options, log *slog.Logger) (outputWrite, error) {
+	content = contextReaderAt{ctx: ctx, r: content}
+	if opts.OutputWriter != nil {
+		return writeStream(opts.OutputWriter, content, size)
+	}
+	path = longPath(path)
+	verify := opts.VerifyOutput || IsNetworkPath(filepath.Dir(path))
+	if !verify && opts.StagingRoot == "" {
+		if err := copyToFile(path, io.NewSectionReader(content, 0, size)); err != nil {
+			os.Remove(path)
+			return outputWrite{attempts: 1}, fmt.Errorf("failed to write output file: %w", err)
+		}
+		return outputWrite{attempts: 1}, nil
+	}
+
+	sum := sha256.New()
+	if _, err := io.Copy(sum, io.NewSectionReader(content, 0, size)); err != nil {
+		return outputWrite{}, fmt.Errorf("failed to read package: %w", err)
+	}
+	source := packageSource{content: content, size: size}
+	copy(source.sum[:], sum.Sum(nil))
+
+	var staged string
+	if opts.StagingRoot != "" {
+		staged = filepath.Join(opts.StagingRoot, filepath.Base(path))
+		if err := os.MkdirAll(opts.StagingRoot, 0755); err != nil {
+			return outputWrite{}, fmt.Errorf("failed to create staging folder: %w", err)
+		}
+		if err := writeVerifiedSum(staged, source); err != nil {
+			return outputWrite{}, fmt.Errorf("failed to stage package: %w", err)
+		}
+		log.Debug("package staged", "path", staged)
+	}
+
+	result := outputWrite{verified: true}
+	delay := outputRetryDelay
+	var err error
+	for {
+		result.attempts++
+		err = writeAttempt(path, source)
+		if err == nil || result.attempts == outputWriteAttempts || !retryableWriteError(err) {
+			break
+		}
+		log.Warn("writing package failed, retrying", "path", path, "attempt", result.attempts, "delay", delay, "error", err)
+		if err = sleepContext(ctx, delay); err != nil {
+			break
+		}
+		delay *= 2
+	}
+	if err != nil {
+		if staged != "" {
+			return result, fmt.Errorf("failed to write output file after %d attempt(s): %w (the package was kept at %s)", result.attempts, err, staged)
+		}
+		return result, fmt.Errorf("failed to write output file after %d attempt(s): %w", result.attempts, err)
+	}
+
+	if staged != "" {
+		os.Remove(staged)
+	}
+	return result, nil
+}
+
+// retryableWriteError reports whether writing the package again may succeed
+func retryableWriteError(err error) bool {
+	return errors.Is(err, ErrOutputMismatch) || isTransientWriteError(err)
+}
+
+// sleepContext waits for d, returning early with the context's error when it is canceled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-timer.C:
+		return nil
+	}
+}
+
+// contextReaderAt stops reads once its context ends, so a deadline interrupts a long copy
+// (e.g. to a slow network share) between chunks instead of after the whole package
+type contextReaderAt struct {
+	ctx context.Context
+	r   io.ReaderAt
+}
+
+func (c contextReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if c.ctx.Err() != nil {
+		return 0, context.Cause(c.ctx)
+	}
+	return c.r.ReadAt(p, off)
+}
+
+// packageSource is a package to write with its size and SHA256
+type packageSource struct {
+	content io.ReaderAt
+	size    int64
+	sum     [sha256.Size]byte
+}
+
+// copyToFile writes the content of r to a new file at path
+func copyToFile(path string, r io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeVerifiedSum writes a package to a temporary file next to path, flushes it to disk,
+// compares the size and SHA256 read back with the package and renames it to path
+// A failed attempt leaves neither the temporary file nor a partial package behind
+func writeVerifiedSum(path string, source packageSource) (err error) {
+	tmp := path + ".partial"
+	defer func() {
+		if err != nil {
+			os.Remove(tmp)
+		}
+	}()
+
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, io.NewSectionReader(source.content, 0, source.size)); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := verifyFile(tmp, source.size, source.sum); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// verifyFile checks that a file has the given size and SHA256
+func verifyFile(path string, size int64, sum [sha256.Size]byte) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() != size {
+		return fmt.Errorf("%w: size %d, expected %d", ErrOutputMismatch, info.Size(), size)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if !bytes.Equal(h.Sum(nil), sum[:]) {
+		return fmt.Errorf("%w: SHA256 differs", ErrOutputMismatch)
+	}
+	return nil
+}
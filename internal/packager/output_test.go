@@ -0,0 +1,117 @@
+package packager
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsNetworkPath(t *testing.T) {
+	if !IsNetworkPath("//fileserver/packages/apps") {
+		t.Error("Expected a UNC path to be a network path")
+	}
+	if IsNetworkPath(t.TempDir()) {
+		t.Error("Expected a temp folder to be local")
+	}
+}
+
+func TestWriteOutputFileVerified(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "setup.intunewin")
+	data := bytes.Repeat([]byte("package"), 1000)
+
+	written, err := writeOutputFile(context.Background(), path, data, Options{VerifyOutput: true}, slog.Default())
+	if err != nil {
+		t.Fatalf("writeOutputFile() error = %v", err)
+	}
+	if !written.verified || written.attempts != 1 {
+		t.Errorf("Expected one verified attempt, got %+v", written)
+	}
+	if got, err := os.ReadFile(path); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Output file does not hold the package (error %v)", err)
+	}
+	if _, err := os.Stat(path + ".partial"); !os.IsNotExist(err) {
+		t.Errorf("Expected the temporary file to be renamed, stat error = %v", err)
+	}
+}
+
+func TestWriteOutputFileCanceled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "setup.intunewin")
+	deadline := fmt.Errorf("deadline passed")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(deadline)
+
+	for _, opts := range []Options{{}, {VerifyOutput: true}} {
+		_, err := writeOutputFile(ctx, path, bytes.Repeat([]byte("package"), 1000), opts, slog.Default())
+		if !errors.Is(err, deadline) {
+			t.Errorf("writeOutputFile(%+v) error = %v, want the cancelation cause", opts, err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected no output file after cancelation, stat error = %v", err)
+		}
+	}
+}
+
+func TestWriteOutputFileRetries(t *testing.T) {
+	defer func(attempt func(string, packageSource) error, delay time.Duration) {
+		writeAttempt, outputRetryDelay = attempt, delay
+	}(writeAttempt, outputRetryDelay)
+	outputRetryDelay = time.Millisecond
+
+	// A share that corrupts the first two copies
+	var calls int
+	writeAttempt = func(path string, source packageSource) error {
+		calls++
+		if calls <= 2 {
+			return fmt.Errorf("%w: SHA256 differs", ErrOutputMismatch)
+		}
+		return writeVerifiedSum(path, source)
+	}
+
+	path := filepath.Join(t.TempDir(), "setup.intunewin")
+	written, err := writeOutputFile(context.Background(), path, []byte("package"), Options{VerifyOutput: true}, slog.Default())
+	if err != nil {
+		t.Fatalf("writeOutputFile() error = %v", err)
+	}
+	if written.attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", written.attempts)
+	}
+
+	// A share that never keeps the file intact gives up after the last attempt
+	calls = -100
+	_, err = writeOutputFile(context.Background(), path, []byte("package"), Options{VerifyOutput: true}, slog.Default())
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("after %d attempt(s)", outputWriteAttempts)) {
+		t.Errorf("Expected failure after %d attempts, got %v", outputWriteAttempts, err)
+	}
+}
+
+func TestWriteOutputFileStaging(t *testing.T) {
+	staging := t.TempDir()
+	data := []byte("package")
+
+	path := filepath.Join(t.TempDir(), "setup.intunewin")
+	if _, err := writeOutputFile(context.Background(), path, data, Options{StagingRoot: staging}, slog.Default()); err != nil {
+		t.Fatalf("writeOutputFile() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(staging, "setup.intunewin")); !os.IsNotExist(err) {
+		t.Errorf("Expected the staged copy to be removed after a successful copy, stat error = %v", err)
+	}
+
+	// The output folder is gone: not a transient error, and the staged copy is kept
+	missing := filepath.Join(t.TempDir(), "gone", "setup.intunewin")
+	_, err := writeOutputFile(context.Background(), missing, data, Options{StagingRoot: staging}, slog.Default())
+	staged := filepath.Join(staging, "setup.intunewin")
+	if err == nil || !strings.Contains(err.Error(), staged) {
+		t.Fatalf("Expected an error naming the staged copy, got %v", err)
+	}
+	if got, err := os.ReadFile(staged); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Staged copy not kept (error %v)", err)
+	}
+}
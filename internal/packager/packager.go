@@ -1,10 +1,16 @@
 package packager
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 )
 
 // PackageResult contains the results of a successful packaging operation
@@ -17,10 +23,78 @@ type PackageResult struct {
 	ZipSize int64
 	// EncryptedSize is the size of the encrypted blob in bytes
 	EncryptedSize int64
+	// EncryptionTime is the time spent encrypting the content (0 when it was restored
+	// from a checkpoint)
+	EncryptionTime time.Duration
 	// FinalSize is the size of the final .intunewin file in bytes
 	FinalSize int64
 	// FileCount is the number of files in the source folder
 	FileCount int
+	// MsixInfo contains identities of MSIX packages, bundles and App Installer files found in the source folder
+	MsixInfo []*MsixInfo
+	// SetupMsix is the identity of the setup file when it is an MSIX/AppX package or bundle
+	SetupMsix *MsixInfo
+	// SetupMsp is the patch code, target products and metadata of an MSP setup file
+	SetupMsp *MspInfo
+	// InstallCommand is the silent install command of a script, registry file, patch or
+	// update setup file (empty for other setup files, see SetupInstallCommand)
+	InstallCommand string
+	// DetectionScriptPath is the path of the detection script written for an MSIX setup file
+	DetectionScriptPath string
+	// ResumedFrom is the checkpoint phase the run was resumed from (empty for a fresh run)
+	ResumedFrom string
+	// ManifestPath is the path of the file manifest (empty unless Options.Manifest is set)
+	ManifestPath string
+	// Signature is the Authenticode signature of the setup file (nil when unsigned or not an EXE/MSI)
+	Signature *Signature
+	// ReusedFiles is the number of files whose compressed data came from the content store
+	// or the previous package
+	ReusedFiles int
+	// ReusedSize is the uncompressed size of those files in bytes
+	ReusedSize int64
+	// KeysPath is the path of the key export (empty unless Options.ExportKeys is set)
+	KeysPath string
+	// Verified is set when the written package was read back and matched by size and SHA256
+	Verified bool
+	// WriteAttempts is the number of times the package was written (more than one after retries)
+	WriteAttempts int
+	// LowMemory is set when the package was built through temporary files instead of memory
+	LowMemory bool
+	// License is the license record written with the package (nil unless Options.License is set)
+	License *License
+	// LicensePath is the path of the license record (empty unless Options.License is set)
+	LicensePath string
+	// ProvenancePath is the path of the provenance statement (empty unless Options.Provenance is set)
+	ProvenancePath string
+	// SignaturePath is the path of the signature of the provenance statement (empty unless
+	// Options.Provenance has a Signer)
+	SignaturePath string
+	// Requirements are the requirement rules written with the package (nil unless
+	// Options.Requirements is set)
+	Requirements *Requirements
+	// RequirementsPath is the path of the requirement rules (empty unless Options.Requirements is set)
+	RequirementsPath string
+	// GeneratorVersion is the package generation logic that built the package
+	GeneratorVersion int
+	// MsiSuite is the primary MSI with the language packs and add-ons chained by the
+	// install script added to the content (nil unless the source holds a suite)
+	MsiSuite *MsiSuite
+	// SkippedLinks are the symbolic links and junctions left out of the package
+	SkippedLinks []SkippedLink
+	// EstimatedSize is the package size predicted before compressing (0 when the source
+	// was too small to need an estimate, see EstimatePackageSize)
+	EstimatedSize int64
+	// Scan is the verdict of the malware scan of the setup file (nil unless Options.Scan is set)
+	Scan *ScanVerdict
+}
+
+// EncryptionThroughput returns the rate the content was encrypted at, in bytes per second,
+// or 0 when it was not encrypted by this run
+func (r *PackageResult) EncryptionThroughput() float64 {
+	if r.EncryptionTime <= 0 {
+		return 0
+	}
+	return float64(r.EncryptedSize) / r.EncryptionTime.Seconds()
 }
 
 // ProgressCallback is called during packaging to report progress
@@ -28,77 +102,564 @@ type PackageResult struct {
 // percent: progress percentage (0.0 to 1.0)
 type ProgressCallback func(step string, percent float64)
 
+// Options controls optional packaging behavior
+// The zero value packages with default settings
+type Options struct {
+	// Tracer records phase and per-file timings (optional)
+	Tracer *Tracer
+	// CheckpointRoot enables resumable runs: completed phases are saved in a
+	// per-run folder below it and reused by a later run over the unchanged source (optional)
+	CheckpointRoot string
+	// Exclude lists glob patterns of files and folders to leave out of the package (see IsExcluded)
+	Exclude []string
+	// ToolVersion overrides the ToolVersion attribute written to Detection.xml (optional)
+	ToolVersion string
+	// MsiOverrides replaces MsiInfo values of Detection.xml for MSI setup files (optional)
+	MsiOverrides *MsiOverrides
+	// Logger receives warnings and diagnostic messages (optional, defaults to slog.Default())
+	Logger *slog.Logger
+	// Manifest writes a list of the packed files with their sizes and hashes alongside the package
+	Manifest bool
+	// RequireSigned fails packaging unless the setup file has a valid Authenticode signature
+	RequireSigned bool
+	// OutputName overrides the .intunewin file name, without extension (optional, defaults to the setup file name)
+	OutputName string
+	// ContentStore reuses compressed data of large files packaged before, by any app (optional)
+	ContentStore *ContentStore
+	// HashCache remembers the SHA256 of source files, so files whose compressed data is in
+	// the content store are not read again while unchanged (optional)
+	HashCache *HashCache
+	// Reproducible fixes timestamps, file modes and optionally keys, so building the same
+	// source again produces the same content digest or package (optional)
+	Reproducible *Reproducible
+	// Keys supplies the encryption and MAC keys instead of random ones (optional)
+	Keys *ContentKeys
+	// ExportKeys writes the keys of the package to this file, encrypted with
+	// ExportPassphrase, for decrypting or verifying it later (optional)
+	ExportKeys       string
+	ExportPassphrase []byte
+	// VerifyOutput reads the written package back and compares its size and SHA256,
+	// retrying on mismatch; outputs on network shares are always verified (see IsNetworkPath)
+	VerifyOutput bool
+	// StagingRoot writes the package to this local folder first and then copies it to the
+	// output folder, keeping the local copy if the copy fails (optional)
+	StagingRoot string
+	// LowMemory builds the package through temporary files, keeping memory use flat for
+	// large sources; sources of LowMemoryThreshold or more always are, unless resumable
+	LowMemory bool
+	// License is written alongside the package and into its manifest for asset management (optional)
+	License *License
+	// NoMsiSuite packages language packs and add-on MSIs next to an MSI setup file as
+	// they are, without adding the install script that chains them (see DetectMsiSuite)
+	NoMsiSuite bool
+	// Symlinks decides how symbolic links and junctions in the source folder are packaged
+	// (optional, defaults to SymlinkFollow)
+	Symlinks SymlinkPolicy
+	// NormalizePermissions stores mode 0755 for folders and executables and 0644 for other
+	// files in the content ZIP, instead of the modes and attributes of the source files
+	NormalizePermissions bool
+	// OutputWriter receives the package instead of a file in the output folder, e.g. stdout
+	// in a pipeline; the output path is then not used (optional)
+	OutputWriter io.Writer
+	// SourceStream is a tar (optionally gzip-compressed) or ZIP stream of the source folder,
+	// e.g. stdin in a pipeline, compressed into the package as it is read; the source path
+	// is then not used, and the content store and hash cache are not either (optional)
+	SourceStream io.Reader
+	// MaxSize fails packaging when the estimated or built package exceeds it, in bytes
+	// (optional, defaults to IntuneMaxSize)
+	MaxSize int64
+	// WarnSize logs a warning when the estimated package exceeds it, in bytes
+	// (optional, defaults to DefaultWarnSize)
+	WarnSize int64
+	// Compression decides which files are stored and the deflate level of the others
+	// (optional, defaults to CompressionDefault)
+	Compression Compression
+	// LockRetries checks source files locked by another process again this many times,
+	// waiting 1s, 2s, 4s, ... in between, before failing (optional)
+	LockRetries int
+	// Previous is the package of the previous version of the app; files that did not
+	// change reuse its compressed data instead of being compressed again (optional)
+	Previous *PreviousPackage
+	// Variables are substituted for the {{NAME}} placeholders of scripts while they are
+	// compressed, leaving the source untouched (see ExpandTemplate, optional)
+	Variables map[string]string
+	// SecretVariables reports that some Variables were resolved from secrets; their
+	// content is never written to checkpoints, so such runs cannot be resumable
+	SecretVariables bool
+	// Provenance writes an in-toto SLSA provenance statement of the package alongside it,
+	// signed when it has a Signer (optional)
+	Provenance *ProvenanceOptions
+	// Requirements writes Intune requirement rules gathered from the setup file and source
+	// size alongside the package (optional)
+	Requirements *RequirementsOptions
+	// OnProgress receives every progress report with the bytes compressed and the time
+	// elapsed, for showing speed and time remaining (optional)
+	OnProgress func(Progress)
+	// Scan checks the setup file for malware before it is packaged, blocking packaging
+	// when it has too many detections (optional)
+	Scan *ScanOptions
+	// Hooks runs steps of the caller before and after the run, e.g. signing scripts or
+	// scans (optional)
+	Hooks Hooks
+}
+
+// logger returns the logger to use for a packaging run
+func (o Options) logger() *slog.Logger {
+	if o.Logger != nil {
+		return o.Logger
+	}
+	return slog.Default()
+}
+
 // Package creates an .intunewin package from the source folder
 // sourcePath: folder containing the setup file and related files
 // setupFile: name of the setup file (e.g., "setup.msi", "install.exe")
 // outputPath: folder where the .intunewin file will be created
 // progress: optional callback for progress updates (can be nil)
 func Package(sourcePath, setupFile, outputPath string, progress ProgressCallback) (*PackageResult, error) {
-	// Helper to report progress
-	report := func(step string, pct float64) {
-		if progress != nil {
-			progress(step, pct)
-		}
+	return PackageWithOptions(sourcePath, setupFile, outputPath, Options{}, progress)
+}
+
+// PackageWithOptions creates an .intunewin package like Package, with optional behavior set by opts
+func PackageWithOptions(sourcePath, setupFile, outputPath string, opts Options, progress ProgressCallback) (*PackageResult, error) {
+	return PackageContext(context.Background(), sourcePath, setupFile, outputPath, opts, progress)
+}
+
+// PackageContext creates an .intunewin package like PackageWithOptions and stops when ctx is canceled
+// A canceled run writes no output file; the checkpoint of a resumable run is kept
+func PackageContext(ctx context.Context, sourcePath, setupFile, outputPath string, opts Options, progress ProgressCallback) (*PackageResult, error) {
+	if opts.Hooks == nil {
+		return packageContext(ctx, sourcePath, setupFile, outputPath, opts, progress)
 	}
+	return packageHooked(ctx, sourcePath, setupFile, outputPath, opts, progress)
+}
+
+// packageContext creates an .intunewin package, see PackageContext
+func packageContext(ctx context.Context, sourcePath, setupFile, outputPath string, opts Options, progress ProgressCallback) (*PackageResult, error) {
+	started := time.Now()
+	tracer := opts.Tracer
+	defer tracer.StartPhase("package")()
+
+	log := opts.logger().With("setup", setupFile)
+	log.Debug("packaging started", "source", sourcePath, "output", outputPath)
+
+	// Helper to report progress
+	reporter := &progressReporter{callback: progress, detail: opts.OnProgress, started: started}
+	report := reporter.report
 
 	// Step 1: Validate inputs (5%)
 	report("Validating inputs", 0.05)
 
-	if err := validateInputs(sourcePath, setupFile, outputPath); err != nil {
+	endPhase := tracer.StartPhase("validate")
+	streamed := opts.SourceStream != nil
+	var err error
+	if streamed {
+		err = validateSourceStream(setupFile, outputPath, opts)
+	} else {
+		err = validatePackaging(sourcePath, setupFile, outputPath, opts)
+	}
+	if err != nil {
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
-
-	// Get source folder stats
-	sourceSize, err := GetFolderSize(sourcePath)
+	// A checkpoint may hold content compressed or encrypted by a non-reproducible run
+	if opts.Reproducible != nil && opts.CheckpointRoot != "" {
+		return nil, fmt.Errorf("validation failed: reproducible builds cannot be resumed")
+	}
+	if err := validateKeyOptions(opts); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	if err := validateStreamOptions(setupFile, opts); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	symlinks, err := ParseSymlinkPolicy(string(opts.Symlinks))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get source folder size: %w", err)
+		return nil, fmt.Errorf("validation failed: %w", err)
 	}
-
-	fileCount, err := CountFiles(sourcePath)
+	opts.Symlinks = symlinks
+	compression, err := ParseCompression(string(opts.Compression))
 	if err != nil {
-		return nil, fmt.Errorf("failed to count files: %w", err)
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	opts.Compression = compression
+	if !opts.License.Empty() {
+		if err := opts.License.Validate(); err != nil {
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
+	}
+	if opts.LowMemory && opts.CheckpointRoot != "" {
+		return nil, fmt.Errorf("validation failed: low-memory runs cannot be resumed")
+	}
+	for name := range opts.Variables {
+		if err := validateVariableName(name); err != nil {
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
+	}
+	// Checkpoints keep the content ZIP, secrets included, in the user cache
+	if opts.SecretVariables && opts.CheckpointRoot != "" {
+		return nil, fmt.Errorf("validation failed: template variables resolved from secrets cannot be used with resumable runs")
+	}
+	if opts.Provenance != nil && opts.OutputWriter != nil {
+		return nil, fmt.Errorf("validation failed: provenance needs a package file to attest")
+	}
+	if opts.Requirements != nil {
+		if err := opts.Requirements.Validate(); err != nil {
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
+	}
+	endPhase()
+
+	var modTime time.Time
+	if opts.Reproducible != nil {
+		modTime = opts.Reproducible.modTime()
+	}
+
+	// Get source folder stats; those of a source stream are known once it is compressed
+	endPhase = tracer.StartPhase("walk")
+	var sourceSize, estimatedSize int64
+	var fileCount int
+	var skippedLinks []SkippedLink
+	if !streamed {
+		sourceSize, fileCount, skippedLinks, err = sourceStats(sourcePath, opts.Exclude, opts.Symlinks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get source folder size: %w", err)
+		}
+		log.Debug("source folder scanned", "files", fileCount, "bytes", sourceSize, "excluded", opts.Exclude)
+		for _, link := range skippedLinks {
+			log.Warn("link left out of the package", "path", link.Path, "target", link.Target, "reason", link.Reason)
+		}
+		if err := checkSourceReadable(ctx, sourcePath, opts, log); err != nil {
+			return nil, err
+		}
+		estimatedSize, err = checkEstimatedSize(sourcePath, sourceSize, fileCount, opts, log)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Step 2: Extract MSI info if applicable (10%)
-	report("Checking for MSI metadata", 0.10)
+	// Pick up a previous interrupted run over the same, unchanged source
+	var state *RunState
+	var resumedFrom string
+	if opts.CheckpointRoot != "" {
+		dir := CheckpointDir(opts.CheckpointRoot, sourcePath, setupFile, outputPath)
+		state, err = prepareCheckpoint(dir, sourcePath, setupFile, outputPath, opts)
+		if err != nil {
+			return nil, err
+		}
+		resumedFrom = state.Phase
+		if resumedFrom != "" {
+			log.Info("resuming from checkpoint", "phase", resumedFrom, "dir", dir)
+		}
+	}
+	endPhase()
+
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+
+	// Step 2: Check the signature and extract MSI info if applicable (10%)
+	report("Checking setup file signature", 0.08)
 
+	endPhase = tracer.StartPhase("inspect setup")
+	// A source stream is only read once, while it is compressed
+	var signature *Signature
+	var scan *ScanVerdict
 	var msiInfo *MsiInfo
-	setupFilePath := filepath.Join(sourcePath, setupFile)
-	if IsMsiFile(setupFile) {
-		msiInfo, err = ExtractMsiInfo(setupFilePath)
+	var setupMsp *MspInfo
+	var suite *MsiSuite
+	var suiteFiles []ZipEntry
+	var setupMsix *MsixInfo
+	var msixInfos []*MsixInfo
+	if !streamed {
+		setupFilePath := filepath.Join(sourcePath, setupFile)
+		signature, err = checkSetupSignature(setupFilePath, opts.RequireSigned, log)
+		if err != nil {
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
+		scan, err = scanSetup(ctx, setupFilePath, opts.Scan, log)
 		if err != nil {
-			// Log warning but continue - MSI info is optional
-			fmt.Printf("Warning: Could not extract MSI metadata: %v\n", err)
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
+
+		report("Checking for MSI metadata", 0.10)
+
+		if IsMsiFile(setupFile) {
+			msiInfo, err = ExtractMsiInfo(setupFilePath)
+			if err != nil {
+				// Log warning but continue - MSI info is optional
+				log.Warn("could not extract MSI metadata", "error", err)
+			} else {
+				log.Debug("MSI metadata extracted", "product", msiInfo.ProductName, "version", msiInfo.ProductVersion, "productCode", msiInfo.ProductCode)
+			}
+			warnRiskyCustomActions(setupFilePath, log)
+		}
+		if IsMspFile(setupFile) {
+			setupMsp, err = ExtractMspInfo(setupFilePath)
+			if err != nil {
+				// Log warning but continue - MSP info is optional
+				log.Warn("could not extract MSP metadata", "error", err)
+			} else {
+				log.Debug("MSP metadata extracted", "patch", setupMsp.DisplayName, "patchCode", setupMsp.PatchCode, "targets", setupMsp.TargetProductCodes)
+			}
 		}
+
+		// Language packs and add-ons shipped with an MSI are installed after it by a generated script
+		suite, suiteFiles, err = suiteContent(sourcePath, setupFile, opts, log)
+		if err != nil {
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
+		if opts.MsiOverrides != nil && msiInfo == nil {
+			log.Warn("MsiInfo overrides ignored, Detection.xml has no MsiInfo for this setup file", "setup", setupFile)
+		}
+
+		// An MSIX setup file is wrapped as is; its identity drives the detection script
+		if IsMsixSetupFile(setupFile) {
+			setupMsix, err = ExtractMsixInfo(setupFilePath)
+			if err != nil {
+				return nil, fmt.Errorf("validation failed: %w", err)
+			}
+			log.Debug("MSIX setup identity extracted", "name", setupMsix.Name, "version", setupMsix.Version, "architecture", setupMsix.Architecture)
+		}
+
+		// Vendors often ship MSIX bundles or App Installer files alongside the installer
+		msixFiles, err := findMsixFiles(sourcePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan for MSIX files: %w", err)
+		}
+		for _, name := range msixFiles {
+			if strings.EqualFold(name, setupFile) {
+				continue
+			}
+			msixInfo, err := ExtractMsixInfo(filepath.Join(sourcePath, name))
+			if err != nil {
+				// Log warning but continue - MSIX info is optional
+				log.Warn("could not extract MSIX metadata", "file", name, "error", err)
+				continue
+			}
+			log.Debug("MSIX metadata extracted", "file", name, "name", msixInfo.Name, "version", msixInfo.Version)
+			msixInfos = append(msixInfos, msixInfo)
+		}
+	}
+	endPhase()
+
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+
+	// Large sources are streamed through temporary files rather than held in memory
+	if useLowMemory(opts, sourceSize) {
+		result, err := packageStreaming(ctx, streamRun{
+			sourcePath: sourcePath,
+			setupFile:  setupFile,
+			outputPath: outputPath,
+			opts:       opts,
+			modTime:    modTime,
+			msiInfo:    msiInfo,
+			suite:      suite,
+			suiteFiles: suiteFiles,
+			report:     report,
+			compressed: reporter.compressed,
+			log:        log,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result.SourceSize = sourceSize
+		result.FileCount = fileCount
+		result.MsixInfo = msixInfos
+		result.Signature = signature
+		result.Scan = scan
+		result.MsiSuite = suite
+		result.SkippedLinks = skippedLinks
+		result.EstimatedSize = estimatedSize
+		result.SetupMsp = setupMsp
+		result.InstallCommand = SetupInstallCommand(setupFile)
+		if setupMsix != nil {
+			result.SetupMsix = setupMsix
+			result.DetectionScriptPath, err = writeMsixDetectionScript(setupMsix, result.OutputPath)
+			if err != nil {
+				return nil, err
+			}
+		}
+		result.LicensePath, err = writePackageLicense(opts, result.OutputPath)
+		if err != nil {
+			return nil, err
+		}
+		if result.LicensePath != "" {
+			result.License = opts.License
+		}
+		if err := writePackageRequirements(opts, result, sourcePath, setupFile); err != nil {
+			return nil, err
+		}
+		if err := writePackageProvenance(ctx, result, sourcePath, setupFile, opts, started); err != nil {
+			return nil, err
+		}
+		report("Complete", 1.0)
+		return result, nil
 	}
 
 	// Step 3: Compress source folder (10-40%)
 	report("Compressing files", 0.15)
 
-	zipData, err := ZipFolderWithProgress(sourcePath, func(file string, pct float64) {
-		// Scale ZIP progress from 15% to 40%
-		scaledPct := 0.15 + (pct * 0.25)
-		report(fmt.Sprintf("Compressing: %s", file), scaledPct)
-	})
-	if err != nil {
-		return nil, fmt.Errorf("compression failed: %w", err)
+	endPhase = tracer.StartPhase("compress")
+	var zipData, fileDigest []byte
+	var zipSize int64
+	var reusedFiles int
+	var reusedSize int64
+	switch {
+	case state != nil && state.Phase == PhaseEncrypted:
+		// The encrypted content is reused below, the ZIP itself is not needed
+		report("Compressed content restored from checkpoint", 0.40)
+		zipSize = state.ZipSize
+	case state != nil && state.Phase == PhaseCompressed:
+		report("Compressed content restored from checkpoint", 0.40)
+		zipData, err = readCheckpointFile(state.Dir, checkpointZipFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+		}
+		zipSize = int64(len(zipData))
+	case streamed:
+		zipData, fileCount, sourceSize, err = zipSourceStream(opts.SourceStream, ZipOptions{
+			Progress: func(file string, pct float64) {
+				report(fmt.Sprintf("Compressing: %s", file), 0.15+(pct*0.25))
+			},
+			Compressed:  reporter.compressed,
+			Context:     ctx,
+			Tracer:      tracer,
+			Exclude:     opts.Exclude,
+			Compression: opts.Compression,
+			Variables:   opts.Variables,
+			ModTime:     modTime,
+		})
+		if err := canceled(ctx); err != nil {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("compression failed: %w", err)
+		}
+		zipSize = int64(len(zipData))
+		log.Debug("source stream compressed", "files", fileCount, "bytes", sourceSize)
+		msiInfo, setupMsp, err = inspectStreamSetup(zipData, setupFile, log)
+		if err != nil {
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
+		if opts.MsiOverrides != nil && msiInfo == nil {
+			log.Warn("MsiInfo overrides ignored, Detection.xml has no MsiInfo for this setup file", "setup", setupFile)
+		}
+	default:
+		var previous *PreviousContent
+		previous, err = openPrevious(opts, report, log)
+		if err != nil {
+			return nil, err
+		}
+		if previous != nil {
+			defer previous.Close()
+		}
+		zipData, fileDigest, err = zipFolderDigest(sourcePath, ZipOptions{
+			Progress: func(file string, pct float64) {
+				// Scale ZIP progress from 15% to 40%
+				scaledPct := 0.15 + (pct * 0.25)
+				report(fmt.Sprintf("Compressing: %s", file), scaledPct)
+			},
+			Compressed:           reporter.compressed,
+			Context:              ctx,
+			Tracer:               tracer,
+			Exclude:              opts.Exclude,
+			Symlinks:             opts.Symlinks,
+			NormalizePermissions: opts.NormalizePermissions,
+			Compression:          opts.Compression,
+			Variables:            opts.Variables,
+			ContentStore:         opts.ContentStore,
+			HashCache:            opts.HashCache,
+			Previous:             previous,
+			ModTime:              modTime,
+			Reused: func(file string, size int64) {
+				reusedFiles++
+				reusedSize += size
+			},
+			Extra: suiteFiles,
+		}, estimatedSize)
+		if err := canceled(ctx); err != nil {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("compression failed: %w", err)
+		}
+		zipSize = int64(len(zipData))
+		if reusedFiles > 0 {
+			log.Debug("compressed data reused", "files", reusedFiles, "bytes", reusedSize)
+		}
+
+		if state != nil {
+			if err := writeCheckpointFile(state.Dir, checkpointZipFile, zipData); err != nil {
+				return nil, err
+			}
+			state.Phase = PhaseCompressed
+			state.ZipSize = zipSize
+			if err := saveRunState(state.Dir, state); err != nil {
+				return nil, err
+			}
+		}
+	}
+	endPhase()
+
+	if err := canceled(ctx); err != nil {
+		return nil, err
 	}
-	zipSize := int64(len(zipData))
 
 	// Step 4: Encrypt content (40-70%)
 	report("Encrypting content", 0.45)
 
-	encInfo, encryptedData, err := CreateEncryptionInfo(zipData)
-	if err != nil {
-		return nil, fmt.Errorf("encryption failed: %w", err)
+	endPhase = tracer.StartPhase("encrypt")
+	var encInfo *EncryptionInfo
+	var encryptedData []byte
+	var encryptionTime time.Duration
+	if state != nil && state.Phase == PhaseEncrypted {
+		report("Encrypted content restored from checkpoint", 0.65)
+		encInfo = state.EncryptionInfo
+		encryptedData, err = readCheckpointFile(state.Dir, checkpointEncryptedFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+		}
+	} else {
+		// The digest was computed while zipping, except for a ZIP restored from a
+		// checkpoint: keys derived from a seed need it first, otherwise it is computed
+		// while the content is encrypted
+		if fileDigest == nil && seededKeys(opts) {
+			fileDigest = CalculateFileDigest(zipData)
+		}
+		encKey, macKey, iv, err := packageKeys(opts, fileDigest)
+		if err != nil {
+			return nil, fmt.Errorf("encryption failed: %w", err)
+		}
+		started := time.Now()
+		encInfo, encryptedData, err = encryptWithDigest(zipData, fileDigest, encKey, macKey, iv,
+			stepProgress(report, "Encrypting content", 0.45, 0.70))
+		if err != nil {
+			return nil, fmt.Errorf("encryption failed: %w", err)
+		}
+		encryptionTime = time.Since(started)
+		logEncryption(log, int64(len(encryptedData)), encryptionTime)
+
+		if state != nil {
+			if err := writeCheckpointFile(state.Dir, checkpointEncryptedFile, encryptedData); err != nil {
+				return nil, err
+			}
+			state.Phase = PhaseEncrypted
+			state.EncryptionInfo = encInfo
+			if err := saveRunState(state.Dir, state); err != nil {
+				return nil, err
+			}
+		}
 	}
 	encryptedSize := int64(len(encryptedData))
+	endPhase()
 
 	report("Encryption complete", 0.70)
 
 	// Step 5: Generate metadata XML (70-80%)
 	report("Generating metadata", 0.75)
 
+	endPhase = tracer.StartPhase("metadata")
 	appName := GetApplicationName(setupFile)
 	metadataParams := &MetadataParams{
 		Name:                   appName,
@@ -106,53 +667,299 @@ func Package(sourcePath, setupFile, outputPath string, progress ProgressCallback
 		UnencryptedContentSize: zipSize,
 		EncryptionInfo:         encInfo,
 		MsiInfo:                msiInfo,
+		ToolVersion:            opts.ToolVersion,
+		MsiOverrides:           opts.MsiOverrides,
 	}
 
 	detectionXML, err := GenerateDetectionXML(metadataParams)
 	if err != nil {
 		return nil, fmt.Errorf("metadata generation failed: %w", err)
 	}
+	endPhase()
 
 	// Step 6: Create final package (80-95%)
 	report("Creating package", 0.85)
 
-	packageData, err := CreateIntunewinPackage(encryptedData, detectionXML)
+	endPhase = tracer.StartPhase("assemble")
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+	packageData, err := CreateIntunewinPackageAt(encryptedData, detectionXML, modTime)
 	if err != nil {
 		return nil, fmt.Errorf("package creation failed: %w", err)
 	}
 	finalSize := int64(len(packageData))
+	endPhase()
+	if err := checkFinalSize(finalSize, opts); err != nil {
+		return nil, err
+	}
+
+	// Nothing is written once the run is canceled, so there is no partial output to clean up
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
 
 	// Step 7: Write output file (95-100%)
 	report("Writing output file", 0.95)
 
+	endPhase = tracer.StartPhase("write")
+
 	// Ensure output directory exists
-	if err := os.MkdirAll(outputPath, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	if opts.OutputWriter == nil {
+		if err := os.MkdirAll(longPath(outputPath), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create output directory: %w", err)
+		}
 	}
 
-	// Generate output filename
-	outputFileName := fmt.Sprintf("%s.intunewin", appName)
-	outputFilePath := filepath.Join(outputPath, outputFileName)
+	outputFilePath := packageFilePath(outputPath, setupFile, opts)
 
-	// Write the package
-	if err := os.WriteFile(outputFilePath, packageData, 0644); err != nil {
-		return nil, fmt.Errorf("failed to write output file: %w", err)
+	// Write the package, verified and retried on network shares
+	written, err := writeOutputFile(ctx, outputFilePath, packageData, opts, log)
+	if err != nil {
+		return nil, err
+	}
+	endPhase()
+
+	log.Info("package created", "path", outputFilePath, "bytes", finalSize)
+
+	var manifestPath string
+	if opts.Manifest {
+		endPhase = tracer.StartPhase("manifest")
+		if zipData == nil {
+			// Resumed after encryption - recover the ZIP from the encrypted content
+			zipData, err = DecryptContent(encryptedData, encInfo.EncryptionKey, encInfo.MacKey)
+			if err != nil {
+				return nil, fmt.Errorf("manifest generation failed: %w", err)
+			}
+		}
+		manifest, err := NewManifest(outputFilePath, setupFile, zipData, packageData)
+		if err != nil {
+			return nil, fmt.Errorf("manifest generation failed: %w", err)
+		}
+		if !opts.License.Empty() {
+			manifest.License = opts.License
+		}
+		manifest.MsiSuite = suite
+		manifestPath = ManifestPath(outputFilePath)
+		if err := WriteManifest(manifestPath, manifest); err != nil {
+			return nil, err
+		}
+		log.Debug("manifest written", "path", manifestPath, "files", manifest.FileCount)
+		endPhase()
+	}
+
+	keysPath, err := exportPackageKeys(opts, outputFilePath, detectionXML)
+	if err != nil {
+		return nil, err
+	}
+
+	var detectionScriptPath string
+	if setupMsix != nil {
+		detectionScriptPath, err = writeMsixDetectionScript(setupMsix, outputFilePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	licensePath, err := writePackageLicense(opts, outputFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PackageResult{
+		OutputPath:          outputFilePath,
+		SourceSize:          sourceSize,
+		ZipSize:             zipSize,
+		EncryptedSize:       encryptedSize,
+		EncryptionTime:      encryptionTime,
+		FinalSize:           finalSize,
+		FileCount:           fileCount,
+		MsixInfo:            msixInfos,
+		SetupMsix:           setupMsix,
+		SetupMsp:            setupMsp,
+		InstallCommand:      SetupInstallCommand(setupFile),
+		ResumedFrom:         resumedFrom,
+		ManifestPath:        manifestPath,
+		Signature:           signature,
+		Scan:                scan,
+		ReusedFiles:         reusedFiles,
+		ReusedSize:          reusedSize,
+		KeysPath:            keysPath,
+		Verified:            written.verified,
+		WriteAttempts:       written.attempts,
+		DetectionScriptPath: detectionScriptPath,
+		LicensePath:         licensePath,
+		GeneratorVersion:    GeneratorVersion,
+		MsiSuite:            suite,
+		SkippedLinks:        skippedLinks,
+		EstimatedSize:       estimatedSize,
+	}
+	if licensePath != "" {
+		result.License = opts.License
+	}
+	if err := writePackageRequirements(opts, result, sourcePath, setupFile); err != nil {
+		return nil, err
+	}
+	if err := writePackageProvenance(ctx, result, sourcePath, setupFile, opts, started); err != nil {
+		return nil, err
+	}
+
+	// The run completed, its checkpoint is no longer needed
+	if state != nil {
+		os.RemoveAll(state.Dir)
 	}
 
 	report("Complete", 1.0)
+	return result, nil
+}
 
-	return &PackageResult{
-		OutputPath:    outputFilePath,
-		SourceSize:    sourceSize,
-		ZipSize:       zipSize,
-		EncryptedSize: encryptedSize,
-		FinalSize:     finalSize,
-		FileCount:     fileCount,
-	}, nil
+// validateKeyOptions checks that supplied and exported keys can be used with the other options
+func validateKeyOptions(opts Options) error {
+	if opts.Keys != nil {
+		if len(opts.Keys.EncryptionKey) != 32 || len(opts.Keys.MacKey) != 32 {
+			return fmt.Errorf("encryption and MAC keys must be 32 bytes")
+		}
+		if opts.Reproducible != nil && len(opts.Reproducible.Seed) > 0 {
+			return fmt.Errorf("supplied keys cannot be combined with a reproducible build seed")
+		}
+		// Checkpoints store the keys in the user cache, out of the KMS's control
+		if opts.CheckpointRoot != "" {
+			return fmt.Errorf("supplied keys cannot be used with resumable runs")
+		}
+	}
+	if opts.ExportKeys != "" && len(opts.ExportPassphrase) < MinPassphraseLength {
+		return fmt.Errorf("key export passphrase must be at least %d characters", MinPassphraseLength)
+	}
+	return nil
+}
+
+// canceled returns an error when the packaging run was canceled
+func canceled(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("packaging canceled: %w", context.Cause(ctx))
+	}
+	return nil
+}
+
+// checkSetupSignature verifies the Authenticode signature of the setup file
+// Unsigned or invalid signatures are only logged unless requireSigned is set;
+// expired and untrusted certificates are always warnings
+func checkSetupSignature(setupFilePath string, requireSigned bool, log *slog.Logger) (*Signature, error) {
+	if !SupportsSignature(setupFilePath) {
+		if requireSigned {
+			return nil, fmt.Errorf("signature checks support only EXE and MSI setup files")
+		}
+		return nil, nil
+	}
+
+	sig, err := VerifySignature(setupFilePath)
+	switch {
+	case errors.Is(err, ErrNotSigned):
+		if requireSigned {
+			return nil, fmt.Errorf("setup file %s is not signed", filepath.Base(setupFilePath))
+		}
+		log.Info("setup file is not signed")
+		return nil, nil
+	case err != nil:
+		if requireSigned {
+			return nil, fmt.Errorf("setup file signature could not be verified: %w", err)
+		}
+		log.Warn("could not verify setup file signature", "error", err)
+		return nil, nil
+	}
+
+	attrs := []any{"signer", sig.Signer, "issuer", sig.Issuer}
+	if sig.Timestamp != nil {
+		attrs = append(attrs, "timestamp", sig.Timestamp.UTC().Format(time.RFC3339))
+	}
+	log.Info("setup file is signed", attrs...)
+	for _, warning := range sig.Warnings(time.Now()) {
+		log.Warn(warning, "signer", sig.Signer)
+	}
+	return sig, nil
+}
+
+// prepareCheckpoint loads the run state from dir if it matches the current source,
+// otherwise it starts a fresh checkpoint
+// The license is kept in the state so a resumed run still records it
+func prepareCheckpoint(dir, sourcePath, setupFile, outputPath string, opts Options) (*RunState, error) {
+	exclude, license := opts.Exclude, opts.License
+	symlinks := opts.Symlinks
+	if symlinks == SymlinkFollow {
+		symlinks = ""
+	}
+	compression := opts.Compression
+	if compression == CompressionDefault {
+		compression = ""
+	}
+	enumHash, err := EnumerationHash(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Artifacts of another generator version would mix two package formats
+	state, err := LoadRunState(dir)
+	if err == nil && state.GeneratorVersion == GeneratorVersion && state.EnumerationHash == enumHash && state.SetupFile == setupFile && slices.Equal(state.Exclude, exclude) && state.Symlinks == symlinks && state.NormalizePermissions == opts.NormalizePermissions && state.Compression == compression && state.Variables == variablesHash(opts.Variables) {
+		if !state.License.Equal(license) {
+			state.License = license
+			if err := saveRunState(dir, state); err != nil {
+				return nil, err
+			}
+		}
+		return state, nil
+	}
+
+	// No usable checkpoint - discard stale artifacts and start over
+	os.RemoveAll(dir)
+	state = &RunState{
+		Dir:                  dir,
+		GeneratorVersion:     GeneratorVersion,
+		SourcePath:           sourcePath,
+		SetupFile:            setupFile,
+		OutputPath:           outputPath,
+		EnumerationHash:      enumHash,
+		Exclude:              exclude,
+		License:              license,
+		Symlinks:             symlinks,
+		NormalizePermissions: opts.NormalizePermissions,
+		Compression:          compression,
+		Variables:            variablesHash(opts.Variables),
+	}
+	if err := saveRunState(dir, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// validatePackaging validates the input parameters and exclusion patterns of a run
+func validatePackaging(sourcePath, setupFile, outputPath string, opts Options) error {
+	if err := validateInputs(sourcePath, setupFile); err != nil {
+		return err
+	}
+	// Validate output path is not empty, unless the package is streamed
+	if outputPath == "" && opts.OutputWriter == nil {
+		return fmt.Errorf("output path cannot be empty")
+	}
+	if err := ValidateExcludePatterns(opts.Exclude); err != nil {
+		return err
+	}
+	if IsExcluded(setupFile, opts.Exclude) {
+		return fmt.Errorf("setup file %s is excluded", setupFile)
+	}
+	return nil
+}
+
+// outputFileName returns the .intunewin file name for a setup file
+func outputFileName(setupFile string, opts Options) string {
+	name := GetApplicationName(setupFile)
+	if opts.OutputName != "" {
+		name = opts.OutputName
+	}
+	return name + ".intunewin"
 }
 
 // validateInputs validates the input parameters
-func validateInputs(sourcePath, setupFile, outputPath string) error {
+func validateInputs(sourcePath, setupFile string) error {
 	// Check source path exists and is a directory
 	sourceInfo, err := os.Stat(sourcePath)
 	if os.IsNotExist(err) {
@@ -179,21 +986,8 @@ func validateInputs(sourcePath, setupFile, outputPath string) error {
 	}
 
 	// Validate setup file extension
-	ext := strings.ToLower(filepath.Ext(setupFile))
-	validExtensions := map[string]bool{
-		".msi": true,
-		".exe": true,
-		".ps1": true,
-		".cmd": true,
-		".bat": true,
-	}
-	if !validExtensions[ext] {
-		return fmt.Errorf("unsupported setup file type: %s (supported: .msi, .exe, .ps1, .cmd, .bat)", ext)
-	}
-
-	// Validate output path is not empty
-	if outputPath == "" {
-		return fmt.Errorf("output path cannot be empty")
+	if !IsSetupFileType(setupFile) {
+		return fmt.Errorf("unsupported setup file type: %s (supported: %s)", strings.ToLower(filepath.Ext(setupFile)), strings.Join(SetupFileTypes, ", "))
 	}
 
 	return nil
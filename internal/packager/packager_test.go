@@ -3,11 +3,30 @@ package packager
 import (
 	"archive/zip"
 	"bytes"
+	"context"
+	"errors"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
+// writeTree writes files under dir, keyed by slash-separated path, creating their folders
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create folder: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+}
+
 func TestPackage(t *testing.T) {
 	// Create source directory with test files
 	sourceDir, err := os.MkdirTemp("", "source")
@@ -168,6 +187,18 @@ func TestFormatSize(t *testing.T) {
 	}
 }
 
+func TestEncryptionThroughput(t *testing.T) {
+	result := &PackageResult{EncryptedSize: 300 << 20, EncryptionTime: 2 * time.Second}
+	if got := FormatThroughput(result.EncryptionThroughput()); got != "150.00 MB/s" {
+		t.Errorf("EncryptionThroughput() = %s, want 150.00 MB/s", got)
+	}
+	// Content restored from a checkpoint was not encrypted by the run
+	result.EncryptionTime = 0
+	if got := result.EncryptionThroughput(); got != 0 {
+		t.Errorf("EncryptionThroughput() without encryption = %v, want 0", got)
+	}
+}
+
 func TestPackageWithSubdirectories(t *testing.T) {
 	// Create source directory with subdirectories
 	sourceDir, err := os.MkdirTemp("", "source")
@@ -241,3 +272,78 @@ func TestPackageNilProgressCallback(t *testing.T) {
 		t.Fatal("Result is nil")
 	}
 }
+
+func TestPackageLogsMsiWarning(t *testing.T) {
+	sourceDir, err := os.MkdirTemp("", "source")
+	if err != nil {
+		t.Fatalf("Failed to create source dir: %v", err)
+	}
+	defer os.RemoveAll(sourceDir)
+
+	outputDir, err := os.MkdirTemp("", "output")
+	if err != nil {
+		t.Fatalf("Failed to create output dir: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	// Not a real MSI - metadata extraction fails but packaging continues
+	if err := os.WriteFile(filepath.Join(sourceDir, "setup.msi"), []byte("not an msi"), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	if _, err := PackageWithOptions(sourceDir, "setup.msi", outputDir, Options{Logger: logger}, nil); err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+
+	out := logs.String()
+	if !strings.Contains(out, "level=WARN") || !strings.Contains(out, "could not extract MSI metadata") {
+		t.Errorf("Expected MSI warning in logs, got: %s", out)
+	}
+	if !strings.Contains(out, "setup=setup.msi") {
+		t.Errorf("Expected setup attribute in logs, got: %s", out)
+	}
+}
+
+func TestPackageContextCanceled(t *testing.T) {
+	sourceDir := t.TempDir()
+	outputDir := t.TempDir()
+	for _, name := range []string{"setup.exe", "data/a.bin", "data/b.bin"} {
+		path := filepath.Join(sourceDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	// Cancel as soon as compression starts, like a user pressing Esc in the TUI
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var steps []string
+	_, err := PackageContext(ctx, sourceDir, "setup.exe", outputDir, Options{}, func(step string, pct float64) {
+		steps = append(steps, step)
+		if strings.HasPrefix(step, "Compressing") {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("PackageContext() error = %v, want context.Canceled", err)
+	}
+	for _, step := range steps {
+		if step == "Encrypting content" {
+			t.Error("Packaging continued to encryption after being canceled")
+		}
+	}
+
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		t.Fatalf("Failed to read output dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Canceled run left %d files in the output folder", len(entries))
+	}
+}
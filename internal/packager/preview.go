@@ -0,0 +1,96 @@
+package packager
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// Preview describes the package a run would create, gathered without compressing or encrypting
+type Preview struct {
+	// Name is the application name written to Detection.xml
+	Name string
+	// OutputPath is the full path the .intunewin file will be written to
+	OutputPath string
+	// SourceSize is the size of the files that will be packaged in bytes
+	SourceSize int64
+	// FileCount is the number of files that will be packaged
+	FileCount int
+	// MsiInfo contains the MSI metadata that will be written to Detection.xml (nil for other setup files)
+	MsiInfo *MsiInfo
+	// MsiError is why MSI metadata could not be read (packaging continues without it)
+	MsiError error
+	// MspInfo contains the patch metadata of an MSP setup file (nil for other setup files)
+	MspInfo *MspInfo
+	// MspError is why MSP metadata could not be read (packaging continues without it)
+	MspError error
+	// InstallCommand is the silent install command of a script, registry file, patch or
+	// update setup file (empty for other setup files)
+	InstallCommand string
+	// RiskyCustomActions are the high-risk custom actions of an MSI setup file (see AnalyzeMsi)
+	RiskyCustomActions []MsiCustomAction
+	// MsiSuite lists the language packs and add-ons that will be chained after an MSI setup file
+	MsiSuite *MsiSuite
+	// SkippedLinks are the symbolic links and junctions that will be left out of the package
+	SkippedLinks []SkippedLink
+	// EstimatedSize is the predicted size of the .intunewin file (see EstimatePackageSize)
+	EstimatedSize int64
+	// SizeWarning explains how to split a package above the warning size of Options.WarnSize
+	// (empty otherwise)
+	SizeWarning string
+}
+
+// PreviewPackage validates a run and reports what it would package, so a wrong
+// source folder or setup file is caught before the slow compression and encryption
+func PreviewPackage(sourcePath, setupFile, outputPath string, opts Options) (*Preview, error) {
+	if err := validatePackaging(sourcePath, setupFile, outputPath, opts); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	if _, err := ParseSymlinkPolicy(string(opts.Symlinks)); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	sourceSize, fileCount, skippedLinks, err := sourceStats(sourcePath, opts.Exclude, opts.Symlinks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source folder size: %w", err)
+	}
+
+	estimate, err := EstimatePackageSize(sourcePath, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := estimate.checkMaxSize(opts); err != nil {
+		return nil, err
+	}
+
+	preview := &Preview{
+		Name:           GetApplicationName(setupFile),
+		OutputPath:     filepath.Join(outputPath, outputFileName(setupFile, opts)),
+		SourceSize:     sourceSize,
+		FileCount:      fileCount,
+		SkippedLinks:   skippedLinks,
+		EstimatedSize:  estimate.Size,
+		InstallCommand: SetupInstallCommand(setupFile),
+	}
+	if estimate.Size > opts.warnSize() {
+		preview.SizeWarning = fmt.Sprintf("estimated size exceeds %s; %s", FormatSize(opts.warnSize()), splitSuggestion(estimate.Largest))
+	}
+	if IsMsiFile(setupFile) {
+		msiPath := filepath.Join(sourcePath, setupFile)
+		preview.MsiInfo, preview.MsiError = ExtractMsiInfo(msiPath)
+		if actions, err := readCustomActions(msiPath); err == nil {
+			for _, ca := range actions {
+				if ca.Risk == RiskHigh {
+					preview.RiskyCustomActions = append(preview.RiskyCustomActions, ca)
+				}
+			}
+		}
+		if !opts.NoMsiSuite {
+			preview.MsiSuite, _ = DetectMsiSuite(sourcePath, setupFile, opts.Exclude)
+		}
+	}
+	if IsMspFile(setupFile) {
+		preview.MspInfo, preview.MspError = ExtractMspInfo(filepath.Join(sourcePath, setupFile))
+	}
+	return preview, nil
+}
@@ -0,0 +1,110 @@
+package packager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/msitest"
+)
+
+func TestPreviewPackage(t *testing.T) {
+	sourceDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	const productCode = "{6F330B47-2577-43AD-9095-1861BA25889B}"
+	msitest.WriteFile(t, sourceDir, "contoso.msi", msitest.MSI{
+		Properties: []msitest.Property{
+			{Name: "Manufacturer", Value: "Contoso Ltd"},
+			{Name: "ProductCode", Value: productCode},
+			{Name: "ProductName", Value: "Contoso App"},
+			{Name: "ProductVersion", Value: "2.4.1"},
+		},
+		PackageCode: "{0D2E8F41-7C3B-4A5E-9F60-112233445566}",
+	})
+	if err := os.WriteFile(filepath.Join(sourceDir, "config.ini"), []byte("[app]\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "install.log"), []byte("old log"), 0644); err != nil {
+		t.Fatalf("Failed to write log file: %v", err)
+	}
+
+	preview, err := PreviewPackage(sourceDir, "contoso.msi", outputDir, Options{Exclude: []string{"*.log"}})
+	if err != nil {
+		t.Fatalf("PreviewPackage() error = %v", err)
+	}
+
+	if preview.Name != "contoso" {
+		t.Errorf("Name = %q, want %q", preview.Name, "contoso")
+	}
+	if want := filepath.Join(outputDir, "contoso.intunewin"); preview.OutputPath != want {
+		t.Errorf("OutputPath = %q, want %q", preview.OutputPath, want)
+	}
+	if preview.FileCount != 2 {
+		t.Errorf("FileCount = %d, want 2", preview.FileCount)
+	}
+	msiInfo, err := os.Stat(filepath.Join(sourceDir, "contoso.msi"))
+	if err != nil {
+		t.Fatalf("Failed to stat MSI: %v", err)
+	}
+	if want := msiInfo.Size() + int64(len("[app]\n")); preview.SourceSize != want {
+		t.Errorf("SourceSize = %d, want %d", preview.SourceSize, want)
+	}
+	if preview.MsiError != nil {
+		t.Fatalf("MsiError = %v", preview.MsiError)
+	}
+	if preview.MsiInfo == nil || preview.MsiInfo.ProductCode != productCode || preview.MsiInfo.ProductVersion != "2.4.1" {
+		t.Errorf("MsiInfo = %+v, want ProductCode %s and version 2.4.1", preview.MsiInfo, productCode)
+	}
+
+	// Nothing is written by a preview
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		t.Fatalf("Failed to read output dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Output folder has %d entries, want none", len(entries))
+	}
+}
+
+func TestPreviewPackageOutputName(t *testing.T) {
+	sourceDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "setup.exe"), []byte("MZ"), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+
+	preview, err := PreviewPackage(sourceDir, "setup.exe", "out", Options{OutputName: "setup-x64"})
+	if err != nil {
+		t.Fatalf("PreviewPackage() error = %v", err)
+	}
+	if want := filepath.Join("out", "setup-x64.intunewin"); preview.OutputPath != want {
+		t.Errorf("OutputPath = %q, want %q", preview.OutputPath, want)
+	}
+	if preview.MsiInfo != nil || preview.MsiError != nil {
+		t.Errorf("Expected no MSI metadata for an EXE, got %+v / %v", preview.MsiInfo, preview.MsiError)
+	}
+}
+
+func TestPreviewPackageInvalid(t *testing.T) {
+	sourceDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "setup.exe"), []byte("MZ"), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		setupFile string
+		exclude   []string
+	}{
+		{"missing setup file", "other.exe", nil},
+		{"excluded setup file", "setup.exe", []string{"*.exe"}},
+		{"invalid pattern", "setup.exe", []string{"[unclosed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := PreviewPackage(sourceDir, tt.setupFile, t.TempDir(), Options{Exclude: tt.exclude}); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
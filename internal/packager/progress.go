@@ -0,0 +1,129 @@
+package packager
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/i18n"
+)
+
+// progressStep is the smallest advance reported within a file being compressed or the
+// content being encrypted, so large files move the progress bar without flooding it
+const progressStep = 0.01
+
+// Progress is a detailed progress report of a packaging run, with the bytes compressed
+// and the time elapsed to show its speed and time remaining
+type Progress struct {
+	// Step and Percent are the step and percentage passed to the ProgressCallback
+	Step    string
+	Percent float64
+	// Bytes and TotalBytes are the source bytes compressed so far and in total; both are
+	// 0 until the first file is compressed
+	Bytes      int64
+	TotalBytes int64
+	// Elapsed is the time since packaging started
+	Elapsed time.Duration
+}
+
+// Speed returns the source bytes compressed per second of the run, 0 before the first file
+func (p Progress) Speed() float64 {
+	if p.Bytes == 0 || p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Bytes) / p.Elapsed.Seconds()
+}
+
+// Remaining estimates the time until the run completes from the percentage done so far,
+// rounded to the second; 0 until compression has started or once the run is complete
+func (p Progress) Remaining() time.Duration {
+	if p.Bytes == 0 || p.Percent <= 0 || p.Percent >= 1 {
+		return 0
+	}
+	return time.Duration(float64(p.Elapsed) * (1 - p.Percent) / p.Percent).Round(time.Second)
+}
+
+// Rate formats the compression speed and the time remaining, e.g. "5.00 MB/s, 2:05 left",
+// in the language of i18n; empty when neither is known
+// The speed is left out once all files are compressed
+func (p Progress) Rate() string {
+	var parts []string
+	if p.Bytes < p.TotalBytes && p.Speed() > 0 {
+		parts = append(parts, FormatThroughput(p.Speed()))
+	}
+	if remaining := p.Remaining(); remaining > 0 {
+		parts = append(parts, i18n.Tf("%s left", FormatRemaining(remaining)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// FormatRemaining formats a time remaining as minutes and seconds, e.g. "2:05", or hours,
+// minutes and seconds from an hour on
+func FormatRemaining(d time.Duration) string {
+	s := int64(d.Round(time.Second) / time.Second)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+// progressReporter passes the progress of a run to its ProgressCallback and to
+// Options.OnProgress, adding the bytes compressed and the time elapsed
+type progressReporter struct {
+	callback ProgressCallback
+	detail   func(Progress)
+	started  time.Time
+	bytes    int64
+	total    int64
+}
+
+// report reports a step and percentage
+func (r *progressReporter) report(step string, pct float64) {
+	if r.callback != nil {
+		r.callback(step, pct)
+	}
+	if r.detail != nil {
+		r.detail(Progress{Step: step, Percent: pct, Bytes: r.bytes, TotalBytes: r.total, Elapsed: time.Since(r.started)})
+	}
+}
+
+// compressed records the source bytes compressed so far, for the next report
+func (r *progressReporter) compressed(done, total int64) {
+	r.bytes, r.total = done, total
+}
+
+// stepProgress returns a function reporting the progress of a step of the run as its bytes
+// are processed, scaled from one percentage of the run to another
+// Reports are made at most once per progressStep of the step
+func stepProgress(report func(step string, pct float64), step string, from, to float64) func(done, total int64) {
+	var reported int64
+	return func(done, total int64) {
+		if total <= 0 {
+			return
+		}
+		// Counted in whole steps, which float fractions would not add up to
+		steps := done * int64(1/progressStep) / total
+		if steps <= reported {
+			return
+		}
+		reported = steps
+		report(step, from+(to-from)*float64(done)/float64(total))
+	}
+}
+
+// progressReader passes the bytes read so far through it to onRead
+type progressReader struct {
+	r      io.Reader
+	read   int64
+	onRead func(read int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.read += int64(n)
+		p.onRead(p.read)
+	}
+	return n, err
+}
@@ -0,0 +1,154 @@
+package packager
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProgressSpeedAndRemaining(t *testing.T) {
+	p := Progress{Percent: 0.25, Bytes: 50 << 20, TotalBytes: 200 << 20, Elapsed: 10 * time.Second}
+	if got := FormatThroughput(p.Speed()); got != "5.00 MB/s" {
+		t.Errorf("Speed() = %s, want 5.00 MB/s", got)
+	}
+	if got := p.Remaining(); got != 30*time.Second {
+		t.Errorf("Remaining() = %v, want 30s", got)
+	}
+
+	// Nothing is estimated before compression starts or once the run is complete
+	for _, p := range []Progress{
+		{Percent: 0.10, Elapsed: time.Second},
+		{Percent: 1, Bytes: 1, TotalBytes: 1, Elapsed: time.Second},
+	} {
+		if p.Remaining() != 0 {
+			t.Errorf("Remaining() of %+v = %v, want 0", p, p.Remaining())
+		}
+	}
+	if (Progress{}).Speed() != 0 {
+		t.Error("Speed() of an empty report should be 0")
+	}
+
+	if got := p.Rate(); got != "5.00 MB/s, 0:30 left" {
+		t.Errorf("Rate() = %q, want speed and time remaining", got)
+	}
+	// Once all files are compressed only the time remaining is left
+	encrypting := Progress{Percent: 0.5, Bytes: 200 << 20, TotalBytes: 200 << 20, Elapsed: 10 * time.Second}
+	if got := encrypting.Rate(); got != "0:10 left" {
+		t.Errorf("Rate() while encrypting = %q, want the time remaining", got)
+	}
+	if got := (Progress{}).Rate(); got != "" {
+		t.Errorf("Rate() of an empty report = %q, want empty", got)
+	}
+}
+
+func TestFormatRemaining(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                             "0:00",
+		1500 * time.Millisecond:       "0:02",
+		2*time.Minute + 5*time.Second: "2:05",
+		time.Hour + 2*time.Minute + 3*time.Second: "1:02:03",
+	}
+	for d, want := range tests {
+		if got := FormatRemaining(d); got != want {
+			t.Errorf("FormatRemaining(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestPackageReportsCompressedBytes(t *testing.T) {
+	sourceDir := t.TempDir()
+	os.WriteFile(filepath.Join(sourceDir, "setup.exe"), make([]byte, 1000), 0644)
+	os.WriteFile(filepath.Join(sourceDir, "data.bin"), make([]byte, 3000), 0644)
+
+	for _, lowMemory := range []bool{false, true} {
+		var reports []Progress
+		var steps int
+		opts := Options{LowMemory: lowMemory, OnProgress: func(p Progress) { reports = append(reports, p) }}
+		if _, err := PackageWithOptions(sourceDir, "setup.exe", t.TempDir(), opts, func(string, float64) { steps++ }); err != nil {
+			t.Fatalf("PackageWithOptions(LowMemory: %v) error = %v", lowMemory, err)
+		}
+		if len(reports) != steps {
+			t.Errorf("LowMemory %v: OnProgress got %d reports, the callback %d", lowMemory, len(reports), steps)
+		}
+		last := reports[len(reports)-1]
+		if last.Step != "Complete" || last.Bytes != 4000 || last.TotalBytes != 4000 || last.Elapsed <= 0 {
+			t.Errorf("LowMemory %v: last report = %+v, want Complete with 4000 of 4000 bytes", lowMemory, last)
+		}
+		if reports[0].Bytes != 0 {
+			t.Errorf("LowMemory %v: first report = %+v, want no bytes compressed", lowMemory, reports[0])
+		}
+	}
+}
+
+// TestProgressFollowsBytes checks that a large file among small ones moves the progress
+// while it is compressed and that encryption reports progress between 45% and 70%
+func TestProgressFollowsBytes(t *testing.T) {
+	defer func(size int) { macChunkSize = size }(macChunkSize)
+	defer func(size int) { encryptChunkSize = size }(encryptChunkSize)
+	macChunkSize, encryptChunkSize = 64<<10, 64<<10
+
+	sourceDir := t.TempDir()
+	files := map[string][]byte{"setup.cmd": []byte("@echo off")}
+	for i := 0; i < 10; i++ {
+		files[fmt.Sprintf("file%d.txt", i)] = []byte("small")
+	}
+	big := make([]byte, 4<<20)
+	if _, err := rand.Read(big); err != nil {
+		t.Fatalf("Failed to generate content: %v", err)
+	}
+	files["big.iso"] = big
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(sourceDir, name), data, 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	var zipCalls []float64
+	if _, err := ZipFolderWithProgress(sourceDir, func(file string, pct float64) {
+		if file == "big.iso" {
+			zipCalls = append(zipCalls, pct)
+		}
+	}); err != nil {
+		t.Fatalf("ZipFolderWithProgress() error = %v", err)
+	}
+	if len(zipCalls) < 10 || zipCalls[len(zipCalls)-1] < 0.99 {
+		t.Errorf("Progress while compressing big.iso = %v, want steps up to the end of the file", zipCalls)
+	}
+
+	for _, lowMemory := range []bool{false, true} {
+		var encrypting []float64
+		opts := Options{LowMemory: lowMemory}
+		_, err := PackageWithOptions(sourceDir, "setup.cmd", t.TempDir(), opts, func(step string, pct float64) {
+			if step == "Encrypting content" {
+				encrypting = append(encrypting, pct)
+			}
+		})
+		if err != nil {
+			t.Fatalf("PackageWithOptions(LowMemory: %v) error = %v", lowMemory, err)
+		}
+		if len(encrypting) < 10 || encrypting[0] != 0.45 || encrypting[len(encrypting)-1] < 0.69 {
+			t.Errorf("LowMemory %v: encryption progress = %v, want steps from 0.45 to 0.70", lowMemory, encrypting)
+		}
+		for i := 1; i < len(encrypting); i++ {
+			if encrypting[i] < encrypting[i-1] || encrypting[i] > 0.70 {
+				t.Errorf("LowMemory %v: encryption progress %v is not increasing up to 0.70", lowMemory, encrypting)
+				break
+			}
+		}
+	}
+}
+
+func TestStepProgress(t *testing.T) {
+	var reports []float64
+	progress := stepProgress(func(_ string, pct float64) { reports = append(reports, pct) }, "step", 0.2, 0.4)
+	for done := int64(0); done <= 1000; done++ {
+		progress(done, 1000)
+	}
+	progress(1, 0)
+	if len(reports) != 100 || reports[len(reports)-1] != 0.4 {
+		t.Errorf("stepProgress reported %d times up to %v, want 100 times up to 0.4", len(reports), reports[len(reports)-1])
+	}
+}
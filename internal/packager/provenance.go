@@ -0,0 +1,293 @@
+package packager
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Names written alongside the package for supply-chain attestation
+const (
+	// ProvenanceSuffix is appended to the .intunewin path to name its provenance statement
+	ProvenanceSuffix = ".provenance.json"
+	// SignatureSuffix is appended to the .intunewin path to name the detached signature
+	// of its provenance statement
+	SignatureSuffix = ".sig"
+)
+
+// in-toto and SLSA identifiers of the provenance statement
+const (
+	StatementType  = "https://in-toto.io/Statement/v1"
+	PredicateType  = "https://slsa.dev/provenance/v1"
+	BuildType      = "https://github.com/michelbragaguimaraes/LetsGoIntunePackager/intunewin/v1"
+	BuilderID      = "https://github.com/michelbragaguimaraes/LetsGoIntunePackager"
+	builderVersion = "letsgointunepackager"
+)
+
+// ProvenanceOptions controls the provenance statement written alongside a package
+type ProvenanceOptions struct {
+	// BuilderVersion is the version of the tool recorded as the builder (optional)
+	BuilderVersion string
+	// Signer signs the statement into a detached signature next to it (optional)
+	Signer Signer
+}
+
+// Signer writes a detached signature of a file
+type Signer interface {
+	// Sign signs the file at path and writes the signature to sigPath
+	Sign(ctx context.Context, path, sigPath string) error
+}
+
+// Statement is an in-toto statement attesting the SLSA provenance of a package
+type Statement struct {
+	Type          string     `json:"_type"`
+	Subject       []Resource `json:"subject"`
+	PredicateType string     `json:"predicateType"`
+	Predicate     Provenance `json:"predicate"`
+}
+
+// Resource names an artifact and its digests, e.g. {"sha256": "<hex>"}
+type Resource struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Provenance is the SLSA v1 provenance predicate: how the package was built and by what
+type Provenance struct {
+	BuildDefinition BuildDefinition `json:"buildDefinition"`
+	RunDetails      RunDetails      `json:"runDetails"`
+}
+
+// BuildDefinition records the inputs of a packaging run
+type BuildDefinition struct {
+	BuildType            string             `json:"buildType"`
+	ExternalParameters   ProvenanceParams   `json:"externalParameters"`
+	InternalParameters   ProvenanceInternal `json:"internalParameters"`
+	ResolvedDependencies []Resource         `json:"resolvedDependencies"`
+}
+
+// ProvenanceParams are the packaging inputs chosen by the user
+type ProvenanceParams struct {
+	Source       string   `json:"source"`
+	SetupFile    string   `json:"setupFile"`
+	Exclude      []string `json:"exclude,omitempty"`
+	Compression  string   `json:"compression,omitempty"`
+	Reproducible bool     `json:"reproducible,omitempty"`
+	// Variables are the names of the template variables; their values may be secrets
+	Variables []string `json:"variables,omitempty"`
+}
+
+// ProvenanceInternal are the packaging settings chosen by the tool
+type ProvenanceInternal struct {
+	GeneratorVersion int    `json:"generatorVersion"`
+	ToolVersion      string `json:"toolVersion"`
+}
+
+// RunDetails records the builder and the time of a packaging run
+type RunDetails struct {
+	Builder  ProvenanceBuilder `json:"builder"`
+	Metadata BuildMetadata     `json:"metadata"`
+	// Byproducts are the content ZIP and the files written alongside the package
+	Byproducts []Resource `json:"byproducts,omitempty"`
+}
+
+// ProvenanceBuilder identifies the tool that built the package
+type ProvenanceBuilder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version,omitempty"`
+}
+
+// BuildMetadata holds the start and end of a packaging run
+type BuildMetadata struct {
+	StartedOn  time.Time `json:"startedOn"`
+	FinishedOn time.Time `json:"finishedOn"`
+}
+
+// ProvenancePath returns the path of the provenance statement written alongside a package
+func ProvenancePath(packagePath string) string {
+	return packagePath + ProvenanceSuffix
+}
+
+// SignaturePath returns the path of the detached signature of the provenance statement
+func SignaturePath(packagePath string) string {
+	return packagePath + SignatureSuffix
+}
+
+// sha256Digest returns a digest set holding a SHA256
+func sha256Digest(sum []byte) map[string]string {
+	return map[string]string{"sha256": hex.EncodeToString(sum)}
+}
+
+// NewProvenance builds the provenance statement of a package built from sourcePath
+// The package, its content digest and the files written alongside it are hashed as they
+// are on disk, so the statement is built once they are all written
+func NewProvenance(result *PackageResult, sourcePath, setupFile string, opts Options, started, finished time.Time) (*Statement, error) {
+	packageSum, err := FileSHA256(longPath(result.OutputPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash package: %w", err)
+	}
+	setupSum, err := FileSHA256(longPath(filepath.Join(sourcePath, setupFile)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash setup file: %w", err)
+	}
+	content, err := PackageDigest(result.OutputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	byproducts := []Resource{{Name: "content.zip", Digest: sha256Digest(content.FileDigest)}}
+	for _, path := range []string{result.ManifestPath, result.LicensePath, result.RequirementsPath, result.DetectionScriptPath} {
+		if path == "" {
+			continue
+		}
+		sum, err := FileSHA256(longPath(path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash %s: %w", filepath.Base(path), err)
+		}
+		byproducts = append(byproducts, Resource{Name: filepath.Base(path), Digest: sha256Digest(sum)})
+	}
+
+	source, err := filepath.Abs(sourcePath)
+	if err != nil {
+		source = sourcePath
+	}
+	builder := ProvenanceBuilder{ID: BuilderID}
+	if opts.Provenance != nil && opts.Provenance.BuilderVersion != "" {
+		builder.Version = map[string]string{builderVersion: opts.Provenance.BuilderVersion}
+	}
+	toolVersion := opts.ToolVersion
+	if toolVersion == "" {
+		toolVersion = ToolVersion
+	}
+
+	return &Statement{
+		Type:          StatementType,
+		Subject:       []Resource{{Name: filepath.Base(result.OutputPath), Digest: sha256Digest(packageSum)}},
+		PredicateType: PredicateType,
+		Predicate: Provenance{
+			BuildDefinition: BuildDefinition{
+				BuildType: BuildType,
+				ExternalParameters: ProvenanceParams{
+					Source:       source,
+					SetupFile:    setupFile,
+					Exclude:      opts.Exclude,
+					Compression:  string(opts.Compression),
+					Reproducible: opts.Reproducible != nil,
+					Variables:    variableNames(opts.Variables),
+				},
+				InternalParameters: ProvenanceInternal{
+					GeneratorVersion: GeneratorVersion,
+					ToolVersion:      toolVersion,
+				},
+				ResolvedDependencies: []Resource{{Name: setupFile, Digest: sha256Digest(setupSum)}},
+			},
+			RunDetails: RunDetails{
+				Builder:    builder,
+				Metadata:   BuildMetadata{StartedOn: started.UTC(), FinishedOn: finished.UTC()},
+				Byproducts: byproducts,
+			},
+		},
+	}, nil
+}
+
+// WriteProvenance writes a provenance statement as indented JSON
+func WriteProvenance(path string, statement *Statement) error {
+	data, err := json.MarshalIndent(statement, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode provenance: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write provenance: %w", err)
+	}
+	return nil
+}
+
+// ReadProvenance reads the provenance statement of a package, nil when it has none
+func ReadProvenance(packagePath string) (*Statement, error) {
+	data, err := os.ReadFile(ProvenancePath(packagePath))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provenance: %w", err)
+	}
+
+	var statement Statement
+	if err := json.Unmarshal(data, &statement); err != nil {
+		return nil, fmt.Errorf("failed to parse provenance %s: %w", ProvenancePath(packagePath), err)
+	}
+	return &statement, nil
+}
+
+// CheckProvenance checks that a provenance statement is an in-toto SLSA provenance whose
+// subject is the package at packagePath as it is now
+func CheckProvenance(packagePath string, statement *Statement) error {
+	if statement.Type != StatementType || statement.PredicateType != PredicateType {
+		return fmt.Errorf("provenance is not an in-toto SLSA provenance statement")
+	}
+	if len(statement.Subject) != 1 {
+		return fmt.Errorf("provenance has %d subjects, want 1", len(statement.Subject))
+	}
+	want := statement.Subject[0].Digest["sha256"]
+	if want == "" {
+		return fmt.Errorf("provenance has no SHA256 of the package")
+	}
+	sum, err := FileSHA256(longPath(packagePath))
+	if err != nil {
+		return fmt.Errorf("failed to hash package: %w", err)
+	}
+	if got := hex.EncodeToString(sum); got != want {
+		return fmt.Errorf("SHA256 of the package is %s, the provenance records %s", got, want)
+	}
+	return nil
+}
+
+// writePackageProvenance writes the provenance statement of a package and its signature
+// when opts.Provenance is set, and records their paths in result
+// A package built without them drops those of a previous build at the same path,
+// which no longer match it
+func writePackageProvenance(ctx context.Context, result *PackageResult, sourcePath, setupFile string, opts Options, started time.Time) error {
+	if opts.OutputWriter != nil {
+		return nil // there is no package file to attest
+	}
+	provenancePath, sigPath := ProvenancePath(result.OutputPath), SignaturePath(result.OutputPath)
+	var stale []string
+	if opts.Provenance == nil {
+		stale = []string{provenancePath, sigPath}
+	} else if opts.Provenance.Signer == nil {
+		stale = []string{sigPath}
+	}
+	for _, path := range stale {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove stale %s: %w", filepath.Base(path), err)
+		}
+	}
+	if opts.Provenance == nil {
+		return nil
+	}
+
+	statement, err := NewProvenance(result, sourcePath, setupFile, opts, started, time.Now())
+	if err != nil {
+		return fmt.Errorf("provenance generation failed: %w", err)
+	}
+	if err := WriteProvenance(provenancePath, statement); err != nil {
+		return err
+	}
+	result.ProvenancePath = provenancePath
+	opts.logger().Debug("provenance written", "path", provenancePath)
+
+	if opts.Provenance.Signer == nil {
+		return nil
+	}
+	if err := opts.Provenance.Signer.Sign(ctx, provenancePath, sigPath); err != nil {
+		return fmt.Errorf("failed to sign provenance: %w", err)
+	}
+	result.SignaturePath = sigPath
+	opts.logger().Debug("provenance signed", "path", sigPath)
+	return nil
+}
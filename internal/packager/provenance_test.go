@@ -0,0 +1,105 @@
+package packager
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// copySigner "signs" by copying the file, so tests can check what was signed
+type copySigner struct{}
+
+func (copySigner) Sign(ctx context.Context, path, sigPath string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(sigPath, data, 0644)
+}
+
+func TestPackageWithProvenance(t *testing.T) {
+	sourceDir := t.TempDir()
+	outputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "setup.exe"), []byte("setup"), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+
+	opts := Options{Manifest: true, Provenance: &ProvenanceOptions{BuilderVersion: "1.2.3", Signer: copySigner{}}}
+	result, err := PackageWithOptions(sourceDir, "setup.exe", outputDir, opts, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	if result.ProvenancePath != ProvenancePath(result.OutputPath) || result.SignaturePath != SignaturePath(result.OutputPath) {
+		t.Fatalf("ProvenancePath = %s, SignaturePath = %s", result.ProvenancePath, result.SignaturePath)
+	}
+
+	statement, err := ReadProvenance(result.OutputPath)
+	if err != nil || statement == nil {
+		t.Fatalf("ReadProvenance() = %v, %v", statement, err)
+	}
+	if err := CheckProvenance(result.OutputPath, statement); err != nil {
+		t.Errorf("CheckProvenance() error = %v", err)
+	}
+	if statement.Subject[0].Name != "setup.intunewin" {
+		t.Errorf("Subject = %s, want setup.intunewin", statement.Subject[0].Name)
+	}
+	setupSum := sha256Digest(CalculateFileDigest([]byte("setup")))
+	if deps := statement.Predicate.BuildDefinition.ResolvedDependencies; len(deps) != 1 || deps[0].Digest["sha256"] != setupSum["sha256"] {
+		t.Errorf("ResolvedDependencies = %+v, want the setup file", deps)
+	}
+	content, err := PackageDigest(result.OutputPath)
+	if err != nil {
+		t.Fatalf("PackageDigest() error = %v", err)
+	}
+	manifestSum, err := FileSHA256(result.ManifestPath)
+	if err != nil {
+		t.Fatalf("FileSHA256() error = %v", err)
+	}
+	byproducts := statement.Predicate.RunDetails.Byproducts
+	if len(byproducts) != 2 || byproducts[0].Digest["sha256"] != content.Hex() || byproducts[1].Digest["sha256"] != hex.EncodeToString(manifestSum) {
+		t.Errorf("Byproducts = %+v, want the content ZIP and manifest", byproducts)
+	}
+	if got := statement.Predicate.RunDetails.Builder.Version[builderVersion]; got != "1.2.3" {
+		t.Errorf("Builder version = %q, want 1.2.3", got)
+	}
+
+	// The signature covers the statement as written
+	signed, _ := os.ReadFile(result.SignaturePath)
+	written, _ := os.ReadFile(result.ProvenancePath)
+	if !bytes.Equal(signed, written) {
+		t.Error("Signature does not cover the provenance statement")
+	}
+
+	// A changed package no longer matches its provenance
+	if err := os.WriteFile(result.OutputPath, []byte("tampered"), 0644); err != nil {
+		t.Fatalf("Failed to change package: %v", err)
+	}
+	if err := CheckProvenance(result.OutputPath, statement); err == nil {
+		t.Error("Expected error for a changed package")
+	}
+
+	// Building again without provenance drops the stale statement and signature
+	result, err = PackageWithOptions(sourceDir, "setup.exe", outputDir, Options{}, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	for _, path := range []string{ProvenancePath(result.OutputPath), SignaturePath(result.OutputPath)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Stale %s was kept", filepath.Base(path))
+		}
+	}
+}
+
+func TestProvenanceNeedsPackageFile(t *testing.T) {
+	sourceDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "setup.exe"), []byte("setup"), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+	opts := Options{OutputWriter: &bytes.Buffer{}, Provenance: &ProvenanceOptions{}}
+	if _, err := PackageWithOptions(sourceDir, "setup.exe", "", opts, nil); err == nil {
+		t.Error("Expected error for provenance of a package written to a stream")
+	}
+}
@@ -0,0 +1,195 @@
+package packager
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// RemediationAction is what the remediation script does when an outdated or missing install is detected
+type RemediationAction string
+
+const (
+	// RemediationSync restarts the Intune Management Extension so the Win32 app is re-evaluated and reinstalled
+	RemediationSync RemediationAction = "sync"
+	// RemediationLog only records the finding in the event log
+	RemediationLog RemediationAction = "log"
+)
+
+// ParseRemediationAction converts a user-supplied string into a RemediationAction
+func ParseRemediationAction(s string) (RemediationAction, error) {
+	switch strings.ToLower(s) {
+	case "sync":
+		return RemediationSync, nil
+	case "log":
+		return RemediationLog, nil
+	default:
+		return "", fmt.Errorf("invalid remediation action: %s (supported: sync, log)", s)
+	}
+}
+
+// RemediationParams holds the values used to generate remediation scripts
+type RemediationParams struct {
+	// Name is the application display name as registered in Add/Remove Programs
+	Name string
+	// Version is the packaged version; older installs are remediated
+	Version string
+	// ProductCode identifies MSI installs (optional, name matching is used otherwise)
+	ProductCode string
+	// Action is performed by the remediation script
+	Action RemediationAction
+}
+
+// RemediationParamsFromDetection derives remediation parameters from Detection.xml metadata
+func RemediationParamsFromDetection(appInfo *ApplicationInfo) RemediationParams {
+	params := RemediationParams{
+		Name:   appInfo.Name,
+		Action: RemediationSync,
+	}
+	if appInfo.MsiInfo != nil {
+		params.ProductCode = appInfo.MsiInfo.MsiProductCode
+		params.Version = appInfo.MsiInfo.MsiProductVersion
+	}
+	return params
+}
+
+// remediationCommon finds the installed version in the Uninstall registry keys
+const remediationCommon = `$AppName = {{ps .Name}}
+$PackagedVersion = {{ps .Version}}
+$ProductCode = {{ps .ProductCode}}
+
+function Get-InstalledVersion {
+    $roots = @(
+        'HKLM:\SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall',
+        'HKLM:\SOFTWARE\WOW6432Node\Microsoft\Windows\CurrentVersion\Uninstall'
+    )
+    foreach ($root in $roots) {
+        if ($ProductCode) {
+            $entry = Get-ItemProperty -Path (Join-Path $root $ProductCode) -ErrorAction SilentlyContinue
+        } else {
+            $entry = Get-ChildItem -Path $root -ErrorAction SilentlyContinue |
+                Get-ItemProperty -ErrorAction SilentlyContinue |
+                Where-Object { $_.DisplayName -like "$AppName*" } |
+                Select-Object -First 1
+        }
+        if ($entry -and $entry.DisplayVersion) {
+            return $entry.DisplayVersion
+        }
+    }
+    return $null
+}
+
+function Test-Outdated([string]$installed) {
+    if (-not $installed) { return $true }
+    try {
+        return [version]$installed -lt [version]$PackagedVersion
+    } catch {
+        return $installed -ne $PackagedVersion
+    }
+}
+`
+
+// remediationDetectTemplate exits 1 when remediation is needed, as Intune Remediations expect
+const remediationDetectTemplate = `# Detection script for {{.Name}} {{.Version}}
+# Generated by LetsGoIntunePackager - exit code 1 triggers the remediation script
+` + remediationCommon + `
+$installed = Get-InstalledVersion
+if (Test-Outdated $installed) {
+    if ($installed) {
+        Write-Output "$AppName $installed is older than $PackagedVersion"
+    } else {
+        Write-Output "$AppName is not installed"
+    }
+    exit 1
+}
+
+Write-Output "$AppName $installed is up to date"
+exit 0
+`
+
+// remediationRemediateTemplate performs the configured action
+const remediationRemediateTemplate = `# Remediation script for {{.Name}} {{.Version}}
+# Generated by LetsGoIntunePackager - action: {{.Action}}
+` + remediationCommon + `
+$installed = Get-InstalledVersion
+if (-not (Test-Outdated $installed)) {
+    Write-Output "$AppName $installed is up to date"
+    exit 0
+}
+
+$source = 'LetsGoIntunePackager'
+if (-not [System.Diagnostics.EventLog]::SourceExists($source)) {
+    New-EventLog -LogName Application -Source $source -ErrorAction SilentlyContinue
+}
+$message = "$AppName is outdated or missing (installed: $installed, packaged: $PackagedVersion)"
+Write-EventLog -LogName Application -Source $source -EventId 1000 -EntryType Warning -Message $message -ErrorAction SilentlyContinue
+{{if eq .Action "sync"}}
+# Restarting the Intune Management Extension makes it re-evaluate Win32 apps,
+# which reinstalls required apps that are no longer detected
+Restart-Service -Name IntuneManagementExtension -Force -ErrorAction Stop
+Write-Output "$message - Intune Management Extension restarted to trigger reinstall"
+{{else}}
+Write-Output $message
+{{end}}exit 0
+`
+
+// GenerateRemediationScripts creates a paired detection and remediation PowerShell script
+func GenerateRemediationScripts(params RemediationParams) (detect, remediate []byte, err error) {
+	if params.Name == "" {
+		return nil, nil, fmt.Errorf("application name is required")
+	}
+	if params.Version == "" {
+		return nil, nil, fmt.Errorf("packaged version is required")
+	}
+	if params.Action == "" {
+		params.Action = RemediationSync
+	}
+
+	funcs := template.FuncMap{"ps": powershellQuote}
+
+	detect, err = renderScript("detect", remediationDetectTemplate, funcs, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	remediate, err = renderScript("remediate", remediationRemediateTemplate, funcs, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	return detect, remediate, nil
+}
+
+// renderScript executes a script template and converts it to CRLF line endings
+func renderScript(name, text string, funcs template.FuncMap, params any) ([]byte, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s template: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, params); err != nil {
+		return nil, fmt.Errorf("failed to generate %s script: %w", name, err)
+	}
+	return bytes.ReplaceAll(buf.Bytes(), []byte("\n"), []byte("\r\n")), nil
+}
+
+// powershellQuote returns s as a single-quoted PowerShell string literal
+func powershellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// ScriptBaseName turns an app name into a file-name-friendly form (e.g., "Contoso App" -> "Contoso-App")
+func ScriptBaseName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r == ' ':
+			b.WriteRune('-')
+		case strings.ContainsRune(`<>:"/\|?*`, r) || r < 32:
+			continue
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
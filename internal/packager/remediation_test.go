@@ -0,0 +1,75 @@
+package packager
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateRemediationScripts(t *testing.T) {
+	params := RemediationParams{
+		Name:        "Contoso's App",
+		Version:     "2.1.0",
+		ProductCode: "{12345678-1234-1234-1234-123456789012}",
+		Action:      RemediationSync,
+	}
+
+	detect, remediate, err := GenerateRemediationScripts(params)
+	if err != nil {
+		t.Fatalf("GenerateRemediationScripts() error = %v", err)
+	}
+
+	detectStr := string(detect)
+	for _, want := range []string{
+		"$AppName = 'Contoso''s App'",
+		"$PackagedVersion = '2.1.0'",
+		"$ProductCode = '{12345678-1234-1234-1234-123456789012}'",
+		"exit 1",
+	} {
+		if !strings.Contains(detectStr, want) {
+			t.Errorf("Detection script missing %q", want)
+		}
+	}
+	if !strings.Contains(detectStr, "\r\n") {
+		t.Error("Detection script should use CRLF line endings")
+	}
+
+	if !strings.Contains(string(remediate), "Restart-Service -Name IntuneManagementExtension") {
+		t.Error("Sync remediation should restart the Intune Management Extension")
+	}
+
+	params.Action = RemediationLog
+	_, remediate, err = GenerateRemediationScripts(params)
+	if err != nil {
+		t.Fatalf("GenerateRemediationScripts() error = %v", err)
+	}
+	if strings.Contains(string(remediate), "Restart-Service") {
+		t.Error("Log remediation should not restart services")
+	}
+	if !strings.Contains(string(remediate), "Write-EventLog") {
+		t.Error("Log remediation should write to the event log")
+	}
+}
+
+func TestGenerateRemediationScriptsRequiresVersion(t *testing.T) {
+	if _, _, err := GenerateRemediationScripts(RemediationParams{Name: "App"}); err == nil {
+		t.Error("Expected error without version")
+	}
+}
+
+func TestRemediationParamsFromDetection(t *testing.T) {
+	appInfo := &ApplicationInfo{
+		Name: "7-Zip",
+		MsiInfo: &MsiInfoXML{
+			MsiProductCode:    "{23170F69-40C1-2702-2401-000001000000}",
+			MsiProductVersion: "24.01.00.0",
+		},
+	}
+
+	params := RemediationParamsFromDetection(appInfo)
+	if params.ProductCode != appInfo.MsiInfo.MsiProductCode || params.Version != "24.01.00.0" {
+		t.Errorf("Params = %+v, want MSI product code and version", params)
+	}
+	if params.Action != RemediationSync {
+		t.Errorf("Action = %s, want sync", params.Action)
+	}
+}
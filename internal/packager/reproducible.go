@@ -0,0 +1,65 @@
+package packager
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// SourceDateEpochEnv is the environment variable of the reproducible-builds.org
+// convention holding the timestamp of a reproducible build in Unix seconds
+const SourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
+// zipEpoch is the earliest time a ZIP (MS-DOS) timestamp can hold
+var zipEpoch = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Reproducible makes repeated builds of the same source produce the same package
+// File times and modes in the content ZIP are fixed, so the content and its FileDigest
+// are identical. With a Seed, the keys and IV are derived from it and the content
+// digest instead of generated randomly, so the whole .intunewin is byte-identical.
+type Reproducible struct {
+	// ModTime is written as the modification time of every file (earlier times are
+	// raised to 1980-01-01, the earliest ZIP time)
+	ModTime time.Time
+	// Seed derives the encryption keys and IV (optional); it must be kept as secret as
+	// the keys, since anyone holding it and the package can decrypt the content
+	Seed []byte
+}
+
+// modTime returns the timestamp written to the package, in UTC so the time zone
+// of the build machine does not leak into the ZIP
+func (r *Reproducible) modTime() time.Time {
+	if r.ModTime.Before(zipEpoch) {
+		return zipEpoch
+	}
+	return r.ModTime.UTC()
+}
+
+// SourceDateEpoch returns the time in SOURCE_DATE_EPOCH, or 1980-01-01 when it is not set
+func SourceDateEpoch() (time.Time, error) {
+	value := os.Getenv(SourceDateEpochEnv)
+	if value == "" {
+		return zipEpoch, nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: expected Unix seconds", SourceDateEpochEnv, value)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// DeriveKeys derives the encryption key, MAC key and IV for content from a seed and
+// the content digest. Binding them to the digest means different content never
+// shares a key and IV, even with the same seed
+func DeriveKeys(seed, fileDigest []byte) (encKey, macKey, iv []byte) {
+	derive := func(label string) []byte {
+		mac := hmac.New(sha256.New, seed)
+		mac.Write([]byte("intunewin " + label + "\x00"))
+		mac.Write(fileDigest)
+		return mac.Sum(nil)
+	}
+	return derive("encryption key"), derive("mac key"), derive("iv")[:16]
+}
@@ -0,0 +1,132 @@
+package packager
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReproduciblePackage(t *testing.T) {
+	sourceDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "setup.exe"), []byte("installer"), 0755); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(sourceDir, "data"), 0755); err != nil {
+		t.Fatalf("Failed to create subdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "data", "config.ini"), []byte("[settings]"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	build := func(repro *Reproducible) []byte {
+		t.Helper()
+		result, err := PackageWithOptions(sourceDir, "setup.exe", t.TempDir(), Options{Reproducible: repro}, nil)
+		if err != nil {
+			t.Fatalf("PackageWithOptions() error = %v", err)
+		}
+		data, err := os.ReadFile(result.OutputPath)
+		if err != nil {
+			t.Fatalf("Failed to read package: %v", err)
+		}
+		return data
+	}
+	// A fresh checkout has new file times and may have other modes
+	touch := func(modTime time.Time) {
+		t.Helper()
+		path := filepath.Join(sourceDir, "setup.exe")
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Failed to touch setup file: %v", err)
+		}
+		if err := os.Chmod(path, 0600); err != nil {
+			t.Fatalf("Failed to change mode: %v", err)
+		}
+	}
+
+	epoch := time.Unix(1700000000, 0)
+	seeded := &Reproducible{ModTime: epoch, Seed: []byte("release seed")}
+	first := build(seeded)
+	touch(time.Now().Add(-time.Hour))
+	if second := build(seeded); !bytes.Equal(first, second) {
+		t.Error("Seeded reproducible builds of the same source differ")
+	}
+
+	// Without a seed the keys are random, but the content digest is reproducible
+	unseeded := &Reproducible{ModTime: epoch}
+	a := build(unseeded)
+	touch(time.Now())
+	b := build(unseeded)
+	if bytes.Equal(a, b) {
+		t.Error("Unseeded builds should use random keys")
+	}
+	digestA := packageFileDigest(t, a)
+	if digestB := packageFileDigest(t, b); digestA != digestB {
+		t.Errorf("FileDigest differs between reproducible builds: %s, %s", digestA, digestB)
+	}
+
+	// Ordinary builds record file times, so touched files change the digest
+	if digestA == packageFileDigest(t, build(nil)) {
+		t.Error("Non-reproducible build has the reproducible digest")
+	}
+
+	if _, err := PackageWithOptions(sourceDir, "setup.exe", t.TempDir(), Options{Reproducible: seeded, CheckpointRoot: t.TempDir()}, nil); err == nil {
+		t.Error("Expected reproducible resumable builds to be rejected")
+	}
+}
+
+// packageFileDigest returns the FileDigest recorded in a package
+func packageFileDigest(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "package.intunewin")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write package: %v", err)
+	}
+	appInfo, err := ReadDetectionXML(path)
+	if err != nil {
+		t.Fatalf("ReadDetectionXML() error = %v", err)
+	}
+	return appInfo.EncryptionInfo.FileDigest
+}
+
+func TestDeriveKeys(t *testing.T) {
+	seed := []byte("seed")
+	encKey, macKey, iv := DeriveKeys(seed, []byte("digest one"))
+	if len(encKey) != 32 || len(macKey) != 32 || len(iv) != 16 {
+		t.Fatalf("DeriveKeys() lengths = %d, %d, %d", len(encKey), len(macKey), len(iv))
+	}
+	if bytes.Equal(encKey, macKey) {
+		t.Error("Encryption and MAC keys are equal")
+	}
+
+	again, _, _ := DeriveKeys(seed, []byte("digest one"))
+	if !bytes.Equal(encKey, again) {
+		t.Error("DeriveKeys() is not deterministic")
+	}
+	other, _, otherIV := DeriveKeys(seed, []byte("digest two"))
+	if bytes.Equal(encKey, other) || bytes.Equal(iv, otherIV) {
+		t.Error("Different content shares a key or IV")
+	}
+}
+
+func TestSourceDateEpoch(t *testing.T) {
+	t.Setenv(SourceDateEpochEnv, "")
+	if got, err := SourceDateEpoch(); err != nil || !got.Equal(zipEpoch) {
+		t.Errorf("SourceDateEpoch() unset = %v, %v, want %v", got, err, zipEpoch)
+	}
+
+	t.Setenv(SourceDateEpochEnv, "1700000000")
+	if got, err := SourceDateEpoch(); err != nil || got.Unix() != 1700000000 {
+		t.Errorf("SourceDateEpoch() = %v, %v", got, err)
+	}
+
+	t.Setenv(SourceDateEpochEnv, "yesterday")
+	if _, err := SourceDateEpoch(); err == nil {
+		t.Error("Expected an error for an invalid SOURCE_DATE_EPOCH")
+	}
+
+	// SOURCE_DATE_EPOCH=0 predates ZIP timestamps
+	if got := (&Reproducible{ModTime: time.Unix(0, 0)}).modTime(); !got.Equal(zipEpoch) {
+		t.Errorf("modTime() = %v, want %v", got, zipEpoch)
+	}
+}
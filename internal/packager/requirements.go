@@ -0,0 +1,191 @@
+package packager
+
+import (
+	"debug/pe"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// RequirementsSuffix is appended to the .intunewin path to name its requirement rules
+const RequirementsSuffix = ".requirements.json"
+
+// DefaultDiskSpaceFactor multiplies the source size into the minimum free disk space:
+// the device holds the downloaded package, the extracted content and the install
+const DefaultDiskSpaceFactor = 3.0
+
+// windowsReleasePattern matches Windows 10/11 releases such as 1607 or 21H2
+var windowsReleasePattern = regexp.MustCompile(`^(\d{4}|\d{2}H[12])$`)
+
+// RequirementsOptions configures the requirement rules written alongside a package
+type RequirementsOptions struct {
+	// MinimumWindowsRelease is the oldest Windows 10/11 release the app installs on (optional)
+	MinimumWindowsRelease string
+	// DiskSpaceFactor multiplies the source size into the minimum free disk space
+	// (0 uses DefaultDiskSpaceFactor)
+	DiskSpaceFactor float64
+}
+
+// Requirements are the requirement rules of a package, named like the properties of
+// a Graph win32LobApp so they can be applied as they are
+type Requirements struct {
+	// Architectures are the applicable architectures (e.g., "x64" or "x86,x64"), empty
+	// when the setup file does not tell
+	Architectures string `json:"applicableArchitectures,omitempty"`
+	// MinimumWindowsRelease is a Windows 10/11 release such as "1607" or "21H2"
+	MinimumWindowsRelease string `json:"minimumSupportedWindowsRelease,omitempty"`
+	// MinimumFreeDiskSpaceMB is the free disk space the install needs
+	MinimumFreeDiskSpaceMB int `json:"minimumFreeDiskSpaceInMB,omitempty"`
+}
+
+// ValidateWindowsRelease checks that s is a Windows 10/11 release such as 1607 or 21H2
+func ValidateWindowsRelease(s string) error {
+	if !windowsReleasePattern.MatchString(s) {
+		return fmt.Errorf("invalid Windows release: %s (e.g., 1607, 2004 or 21H2)", s)
+	}
+	return nil
+}
+
+// Validate checks that requirement options can be applied
+func (o *RequirementsOptions) Validate() error {
+	if o.MinimumWindowsRelease != "" {
+		if err := ValidateWindowsRelease(o.MinimumWindowsRelease); err != nil {
+			return err
+		}
+	}
+	if o.DiskSpaceFactor < 0 || math.IsNaN(o.DiskSpaceFactor) || math.IsInf(o.DiskSpaceFactor, 0) {
+		return fmt.Errorf("disk space factor must be a positive number")
+	}
+	return nil
+}
+
+// msiPlatformArchitectures maps the platform of an MSI Template to applicable
+// architectures; 32-bit packages also install on 64-bit Windows
+var msiPlatformArchitectures = map[string]string{
+	"intel": "x86,x64",
+	"x64":   "x64",
+	"amd64": "x64",
+	"arm64": "arm64",
+}
+
+// peMachineArchitectures maps the machine of a PE header to applicable architectures
+var peMachineArchitectures = map[uint16]string{
+	pe.IMAGE_FILE_MACHINE_I386:  "x86,x64",
+	pe.IMAGE_FILE_MACHINE_AMD64: "x64",
+	pe.IMAGE_FILE_MACHINE_ARM64: "arm64",
+}
+
+// SetupArchitectures returns the applicable architectures of a setup file: from the
+// Template of an MSI or the PE header of an EXE, empty for other files
+// An x86 EXE is often a bootstrapper of any architecture; the MSI is the better source
+func SetupArchitectures(setupPath string) (string, error) {
+	switch {
+	case IsMsiFile(setupPath):
+		info, err := ExtractMsiInfo(setupPath)
+		if err != nil {
+			return "", err
+		}
+		if info.Platform == "" {
+			return "", nil
+		}
+		arch, ok := msiPlatformArchitectures[strings.ToLower(info.Platform)]
+		if !ok {
+			return "", fmt.Errorf("unsupported MSI platform: %s", info.Platform)
+		}
+		return arch, nil
+	case strings.EqualFold(filepath.Ext(setupPath), ".exe"):
+		file, err := pe.Open(setupPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read PE header: %w", err)
+		}
+		defer file.Close()
+		arch, ok := peMachineArchitectures[file.Machine]
+		if !ok {
+			return "", fmt.Errorf("unsupported PE machine type: %#x", file.Machine)
+		}
+		return arch, nil
+	default:
+		return "", nil
+	}
+}
+
+// NewRequirements returns the requirement rules of a package of the given applicable
+// architectures (see SetupArchitectures) and source size
+func NewRequirements(architectures string, sourceSize int64, opts RequirementsOptions) *Requirements {
+	factor := opts.DiskSpaceFactor
+	if factor == 0 {
+		factor = DefaultDiskSpaceFactor
+	}
+	return &Requirements{
+		Architectures:          architectures,
+		MinimumWindowsRelease:  opts.MinimumWindowsRelease,
+		MinimumFreeDiskSpaceMB: int(math.Ceil(float64(sourceSize) * factor / (1 << 20))),
+	}
+}
+
+// RequirementsPath returns the path of the requirement rules written alongside a package
+func RequirementsPath(packagePath string) string {
+	return packagePath + RequirementsSuffix
+}
+
+// WriteRequirements writes requirement rules as indented JSON
+func WriteRequirements(path string, req *Requirements) error {
+	data, err := json.MarshalIndent(req, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode requirements: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write requirements: %w", err)
+	}
+	return nil
+}
+
+// ReadRequirements reads the requirement rules of a package, nil when it has none
+func ReadRequirements(packagePath string) (*Requirements, error) {
+	data, err := os.ReadFile(RequirementsPath(packagePath))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read requirements: %w", err)
+	}
+
+	var req Requirements
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("failed to parse requirements %s: %w", RequirementsPath(packagePath), err)
+	}
+	return &req, nil
+}
+
+// writePackageRequirements writes the requirement rules of a package when
+// opts.Requirements is set, and records them in result
+// A package built without them drops the rules of a previous build at the same path
+func writePackageRequirements(opts Options, result *PackageResult, sourcePath, setupFile string) error {
+	if opts.OutputWriter != nil {
+		return nil // there is no package file to keep requirement rules next to
+	}
+	path := RequirementsPath(result.OutputPath)
+	if opts.Requirements == nil {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove stale requirements: %w", err)
+		}
+		return nil
+	}
+	// Rules without architectures are still useful; Intune then applies its default
+	arch, err := SetupArchitectures(longPath(filepath.Join(sourcePath, setupFile)))
+	if err != nil {
+		opts.logger().Warn("could not read the architecture of the setup file", "setup", setupFile, "error", err)
+	}
+	req := NewRequirements(arch, result.SourceSize, *opts.Requirements)
+	if err := WriteRequirements(path, req); err != nil {
+		return err
+	}
+	result.Requirements = req
+	result.RequirementsPath = path
+	return nil
+}
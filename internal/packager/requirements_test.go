@@ -0,0 +1,129 @@
+package packager
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/msitest"
+)
+
+func TestValidateWindowsRelease(t *testing.T) {
+	for _, release := range []string{"1607", "2004", "21H2", "24H2"} {
+		if err := ValidateWindowsRelease(release); err != nil {
+			t.Errorf("ValidateWindowsRelease(%q) error = %v", release, err)
+		}
+	}
+	for _, release := range []string{"", "21h2", "Windows 11", "21H3", "160"} {
+		if err := ValidateWindowsRelease(release); err == nil {
+			t.Errorf("ValidateWindowsRelease(%q) expected error", release)
+		}
+	}
+}
+
+func TestSetupArchitectures(t *testing.T) {
+	dir := t.TempDir()
+	exe := func(name string, machine uint16) string {
+		data := minimalPE()
+		binary.LittleEndian.PutUint16(data[0x44:], machine)
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+	msi := func(name, template string) string {
+		return msitest.WriteFile(t, dir, name, msitest.MSI{
+			Properties: []msitest.Property{{Name: "ProductCode", Value: "{6F1A2B3C-4D5E-4F60-8A9B-0C1D2E3F4A5B}"}},
+			Template:   template,
+		})
+	}
+	script := filepath.Join(dir, "install.ps1")
+	os.WriteFile(script, []byte("Write-Host"), 0644)
+	stub := filepath.Join(dir, "stub.exe")
+	os.WriteFile(stub, []byte("MZ"), 0644)
+
+	tests := map[string]struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		"x86 exe":      {exe("setup32.exe", pe.IMAGE_FILE_MACHINE_I386), "x86,x64", false},
+		"x64 exe":      {exe("setup64.exe", pe.IMAGE_FILE_MACHINE_AMD64), "x64", false},
+		"arm64 exe":    {exe("setuparm.exe", pe.IMAGE_FILE_MACHINE_ARM64), "arm64", false},
+		"itanium exe":  {exe("setupia64.exe", pe.IMAGE_FILE_MACHINE_IA64), "", true},
+		"intel msi":    {msi("app32.msi", "Intel;1033"), "x86,x64", false},
+		"x64 msi":      {msi("app64.msi", "x64;1033,1031"), "x64", false},
+		"arm64 msi":    {msi("apparm.msi", "Arm64;0"), "arm64", false},
+		"no template":  {msi("plain.msi", ""), "", false},
+		"script":       {script, "", false},
+		"not a pe exe": {stub, "", true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := SetupArchitectures(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetupArchitectures() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("SetupArchitectures() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRequirements(t *testing.T) {
+	req := NewRequirements("x64", 100<<20, RequirementsOptions{MinimumWindowsRelease: "21H2"})
+	if req.MinimumFreeDiskSpaceMB != 300 || req.Architectures != "x64" || req.MinimumWindowsRelease != "21H2" {
+		t.Errorf("NewRequirements() = %+v", req)
+	}
+	// Partial megabytes round up
+	if req := NewRequirements("", 1, RequirementsOptions{DiskSpaceFactor: 1.5}); req.MinimumFreeDiskSpaceMB != 1 {
+		t.Errorf("MinimumFreeDiskSpaceMB = %d, want 1", req.MinimumFreeDiskSpaceMB)
+	}
+
+	opts := RequirementsOptions{DiskSpaceFactor: -1}
+	if err := opts.Validate(); err == nil {
+		t.Error("Expected error for a negative disk space factor")
+	}
+}
+
+func TestPackageRequirements(t *testing.T) {
+	source := t.TempDir()
+	msitest.WriteFile(t, source, "app.msi", msitest.MSI{
+		Properties: []msitest.Property{{Name: "ProductCode", Value: "{6F1A2B3C-4D5E-4F60-8A9B-0C1D2E3F4A5B}"}},
+		Template:   "x64;1033",
+	})
+	output := t.TempDir()
+
+	opts := Options{Requirements: &RequirementsOptions{MinimumWindowsRelease: "2004"}}
+	result, err := PackageWithOptions(source, "app.msi", output, opts, nil)
+	if err != nil {
+		t.Fatalf("Failed to package: %v", err)
+	}
+	if result.RequirementsPath != RequirementsPath(result.OutputPath) {
+		t.Errorf("RequirementsPath = %q", result.RequirementsPath)
+	}
+	req, err := ReadRequirements(result.OutputPath)
+	if err != nil || req == nil {
+		t.Fatalf("ReadRequirements() = %v, %v", req, err)
+	}
+	if req.Architectures != "x64" || req.MinimumWindowsRelease != "2004" || req.MinimumFreeDiskSpaceMB != 1 {
+		t.Errorf("Requirements = %+v", req)
+	}
+
+	// A rebuild without requirements drops the stale rules
+	if _, err := PackageWithOptions(source, "app.msi", output, Options{}, nil); err != nil {
+		t.Fatalf("Failed to package: %v", err)
+	}
+	if req, err := ReadRequirements(result.OutputPath); req != nil || err != nil {
+		t.Errorf("ReadRequirements() after rebuild = %v, %v, want none", req, err)
+	}
+
+	opts.Requirements.MinimumWindowsRelease = "Windows 11"
+	if _, err := PackageWithOptions(source, "app.msi", output, opts, nil); err == nil {
+		t.Error("Expected error for an invalid Windows release")
+	}
+}
@@ -0,0 +1,86 @@
+package packager
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ScanOptions checks the setup file for malware before it is packaged
+type ScanOptions struct {
+	// Scanner scans the setup file
+	Scanner Scanner
+	// MaxDetections is the number of detections allowed; more block packaging
+	MaxDetections int
+}
+
+// Scanner checks a file for malware, e.g. with VirusTotal or Microsoft Defender
+type Scanner interface {
+	// Scan scans the file at path; an error means the file could not be scanned
+	Scan(ctx context.Context, path string) (*ScanVerdict, error)
+}
+
+// ScanVerdict is the result of a malware scan of the setup file
+type ScanVerdict struct {
+	// Scanner names the scanner, e.g. virustotal or defender
+	Scanner string `json:"scanner"`
+	// SHA256 is the hash of the file scanned, when the scanner looks files up by hash
+	SHA256 string `json:"sha256,omitempty"`
+	// Known is false when the scanner has no report of the file, e.g. a file never
+	// uploaded to VirusTotal; it then has no detections
+	Known bool `json:"known"`
+	// Detections is the number of engines (or threats, for a local scanner) detecting the file
+	Detections int `json:"detections"`
+	// Engines is the number of engines that scanned the file (0 when not reported)
+	Engines int `json:"engines,omitempty"`
+	// Threats names what was detected, when the scanner reports it
+	Threats []string `json:"threats,omitempty"`
+	// Link is the page of the report, when the scanner has one
+	Link string `json:"link,omitempty"`
+	// ScannedAt is when the file was scanned (the last analysis for lookups)
+	ScannedAt time.Time `json:"scannedAt"`
+}
+
+// String describes the verdict, e.g. "virustotal: 0 of 72 engines"
+func (v *ScanVerdict) String() string {
+	switch {
+	case !v.Known:
+		return fmt.Sprintf("%s: no report of the file", v.Scanner)
+	case v.Engines > 0:
+		return fmt.Sprintf("%s: %d of %d engines", v.Scanner, v.Detections, v.Engines)
+	case v.Detections > 0:
+		return fmt.Sprintf("%s: %d detections (%s)", v.Scanner, v.Detections, strings.Join(v.Threats, ", "))
+	}
+	return fmt.Sprintf("%s: no detections", v.Scanner)
+}
+
+// scanSetup scans the setup file with the scanner of opts, failing when it has more
+// detections than allowed; it returns nil when no scanner is set
+func scanSetup(ctx context.Context, setupFilePath string, opts *ScanOptions, log *slog.Logger) (*ScanVerdict, error) {
+	if opts == nil || opts.Scanner == nil {
+		return nil, nil
+	}
+	verdict, err := opts.Scanner.Scan(ctx, setupFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan setup file: %w", err)
+	}
+
+	attrs := []any{"scanner", verdict.Scanner, "detections", verdict.Detections}
+	if verdict.Link != "" {
+		attrs = append(attrs, "report", verdict.Link)
+	}
+	switch {
+	case verdict.Detections > opts.MaxDetections:
+		return nil, fmt.Errorf("setup file %s blocked by malware scan (%s, at most %d allowed)", filepath.Base(setupFilePath), verdict, opts.MaxDetections)
+	case !verdict.Known:
+		log.Warn("setup file is unknown to the malware scanner", attrs...)
+	case verdict.Detections > 0:
+		log.Warn("setup file has malware detections", append(attrs, "threats", verdict.Threats)...)
+	default:
+		log.Info("setup file scanned", attrs...)
+	}
+	return verdict, nil
+}
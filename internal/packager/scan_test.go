@@ -0,0 +1,50 @@
+package packager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeScanner returns a fixed verdict and records the file it scanned
+type fakeScanner struct {
+	verdict ScanVerdict
+	scanned string
+}
+
+func (s *fakeScanner) Scan(ctx context.Context, path string) (*ScanVerdict, error) {
+	s.scanned = path
+	v := s.verdict
+	return &v, nil
+}
+
+func TestPackageScansSetupFile(t *testing.T) {
+	sourceDir := t.TempDir()
+	os.WriteFile(filepath.Join(sourceDir, "setup.exe"), []byte("setup"), 0644)
+
+	scanner := &fakeScanner{verdict: ScanVerdict{Scanner: "fake", Known: true, Detections: 1, Engines: 70}}
+	opts := Options{Scan: &ScanOptions{Scanner: scanner, MaxDetections: 1}}
+	result, err := PackageWithOptions(sourceDir, "setup.exe", t.TempDir(), opts, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	if scanner.scanned != filepath.Join(sourceDir, "setup.exe") {
+		t.Errorf("scanned %q, want the setup file", scanner.scanned)
+	}
+	if result.Scan == nil || result.Scan.Detections != 1 {
+		t.Errorf("result.Scan = %+v, want the verdict of the scanner", result.Scan)
+	}
+
+	// One detection more than allowed blocks packaging before anything is written
+	outputDir := t.TempDir()
+	opts.Scan.MaxDetections = 0
+	_, err = PackageWithOptions(sourceDir, "setup.exe", outputDir, opts, nil)
+	if err == nil || !strings.Contains(err.Error(), "blocked by malware scan (fake: 1 of 70 engines, at most 0 allowed)") {
+		t.Fatalf("PackageWithOptions() error = %v, want the setup file blocked", err)
+	}
+	if entries, _ := os.ReadDir(outputDir); len(entries) != 0 {
+		t.Errorf("output folder has %d entries, want none", len(entries))
+	}
+}
@@ -0,0 +1,91 @@
+package packager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// SetupFileTypes are the extensions of setup files a package can be built around
+var SetupFileTypes = []string{
+	".msi", ".exe", ".ps1", ".cmd", ".bat", ".vbs", ".wsf", ".reg", ".msp", ".msu",
+	".msix", ".appx", ".msixbundle", ".appxbundle",
+}
+
+// IsSetupFileType checks if the given file path has the extension of a supported setup file
+func IsSetupFileType(path string) bool {
+	return slices.Contains(SetupFileTypes, strings.ToLower(filepath.Ext(path)))
+}
+
+// SetupInstallCommand returns the silent install command of a script, registry file,
+// patch or update setup file, which Intune cannot start by itself; it returns an empty
+// string for installers whose switches depend on the vendor (EXE) or that have their
+// own commands (MSI, MSIX)
+func SetupInstallCommand(setupFile string) string {
+	switch strings.ToLower(filepath.Ext(setupFile)) {
+	case ".ps1":
+		return fmt.Sprintf(`powershell.exe -NoProfile -ExecutionPolicy Bypass -File ".\%s"`, setupFile)
+	case ".cmd", ".bat":
+		return fmt.Sprintf(`cmd.exe /c "%s"`, setupFile)
+	case ".vbs", ".wsf":
+		return fmt.Sprintf(`cscript.exe //NoLogo "%s"`, setupFile)
+	case ".reg":
+		return fmt.Sprintf(`reg.exe import "%s"`, setupFile)
+	case ".msp":
+		return fmt.Sprintf(`msiexec /p "%s" /qn`, setupFile)
+	case ".msu":
+		return fmt.Sprintf(`wusa.exe "%s" /quiet /norestart`, setupFile)
+	}
+	return ""
+}
+
+// ListSetupFiles lists the files of a folder that can be setup files, without subfolders
+func ListSetupFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && IsSetupFileType(entry.Name()) {
+			files = append(files, entry.Name())
+		}
+	}
+	return files, nil
+}
+
+// PreferredSetupFile picks the most likely main setup file from a folder listing:
+// a conventional name such as setup.msi, else the first MSI, else the first file
+func PreferredSetupFile(files []string) string {
+	// Priority patterns for setup file detection
+	patterns := []string{
+		"setup.msi",
+		"setup.exe",
+		"install.msi",
+		"install.exe",
+		"installer.msi",
+		"installer.exe",
+	}
+	for _, pattern := range patterns {
+		for _, file := range files {
+			if strings.EqualFold(filepath.Base(file), pattern) {
+				return file
+			}
+		}
+	}
+
+	// Prefer MSI over EXE
+	for _, file := range files {
+		if strings.EqualFold(filepath.Ext(file), ".msi") {
+			return file
+		}
+	}
+
+	if len(files) > 0 {
+		return files[0]
+	}
+	return ""
+}
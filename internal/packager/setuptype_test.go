@@ -0,0 +1,109 @@
+package packager
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestIsSetupFileType(t *testing.T) {
+	tests := map[string]bool{
+		"setup.msi":          true,
+		"Install.PS1":        true,
+		"configure.vbs":      true,
+		"deploy.wsf":         true,
+		"settings.reg":       true,
+		"hotfix.MSP":         true,
+		"windows-kb123.msu":  true,
+		"app.msixbundle":     true,
+		"readme.txt":         false,
+		"setup":              false,
+		"archive.zip":        false,
+		"settings.reg.bak":   false,
+		"folder.msp/app.txt": false,
+	}
+	for path, want := range tests {
+		if got := IsSetupFileType(path); got != want {
+			t.Errorf("IsSetupFileType(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestSetupInstallCommand(t *testing.T) {
+	tests := map[string]string{
+		"setup.exe":    "",
+		"setup.msi":    "",
+		"install.ps1":  `powershell.exe -NoProfile -ExecutionPolicy Bypass -File ".\install.ps1"`,
+		"install.cmd":  `cmd.exe /c "install.cmd"`,
+		"config.vbs":   `cscript.exe //NoLogo "config.vbs"`,
+		"config.WSF":   `cscript.exe //NoLogo "config.WSF"`,
+		"settings.reg": `reg.exe import "settings.reg"`,
+		"hotfix.msp":   `msiexec /p "hotfix.msp" /qn`,
+		"kb123.msu":    `wusa.exe "kb123.msu" /quiet /norestart`,
+	}
+	for setupFile, want := range tests {
+		if got := SetupInstallCommand(setupFile); got != want {
+			t.Errorf("SetupInstallCommand(%q) = %q, want %q", setupFile, got, want)
+		}
+	}
+}
+
+func TestValidateInputsSetupFileTypes(t *testing.T) {
+	sourceDir := t.TempDir()
+	for _, name := range []string{"settings.reg", "kb123.msu", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(sourceDir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	for _, name := range []string{"settings.reg", "kb123.msu"} {
+		if err := validateInputs(sourceDir, name); err != nil {
+			t.Errorf("validateInputs(%s) error = %v", name, err)
+		}
+	}
+	if err := validateInputs(sourceDir, "notes.txt"); err == nil {
+		t.Error("Expected error for an unsupported setup file type")
+	}
+}
+
+func TestListSetupFiles(t *testing.T) {
+	sourceDir := t.TempDir()
+	for _, name := range []string{"readme.txt", "install.ps1", "setup.exe"} {
+		if err := os.WriteFile(filepath.Join(sourceDir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(sourceDir, "tools.msi"), 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+
+	files, err := ListSetupFiles(sourceDir)
+	if err != nil {
+		t.Fatalf("ListSetupFiles() error = %v", err)
+	}
+	if !slices.Equal(files, []string{"install.ps1", "setup.exe"}) {
+		t.Errorf("ListSetupFiles() = %v, want [install.ps1 setup.exe]", files)
+	}
+	if _, err := ListSetupFiles(filepath.Join(sourceDir, "missing")); err == nil {
+		t.Error("Expected error for a missing folder")
+	}
+}
+
+func TestPreferredSetupFile(t *testing.T) {
+	tests := map[string]struct {
+		files []string
+		want  string
+	}{
+		"conventional name": {[]string{"app.msi", "Setup.exe"}, "Setup.exe"},
+		"msi before exe":    {[]string{"vendor.exe", "app.msi"}, "app.msi"},
+		"first file":        {[]string{"deploy.ps1", "config.reg"}, "deploy.ps1"},
+		"none":              {nil, ""},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := PreferredSetupFile(tt.files); got != tt.want {
+				t.Errorf("PreferredSetupFile(%v) = %q, want %q", tt.files, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package packager
+
+import (
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// IntuneMaxSize is the largest Win32 app package Intune accepts
+const IntuneMaxSize int64 = 30 << 30
+
+// DefaultWarnSize is the package size above which packaging warns: apps this large
+// download slowly, fill the disk of devices and often time out on slow connections
+const DefaultWarnSize int64 = 8 << 30
+
+const (
+	// sizeSampleFile is how much of the start of each file is compressed to estimate its ratio
+	sizeSampleFile = 256 << 10
+	// sizeSampleTotal caps the bytes sampled; later files are estimated with the average ratio
+	sizeSampleTotal = 64 << 20
+	// packageOverhead covers Detection.xml, the outer ZIP and the encryption header and padding
+	packageOverhead = 8 << 10
+)
+
+// ErrPackageTooLarge is returned when a package exceeds the maximum size of Options.MaxSize
+var ErrPackageTooLarge = errors.New("package too large")
+
+// SizeEntry is a top-level file or folder of a source folder with its size in bytes
+type SizeEntry struct {
+	Path string
+	Size int64
+}
+
+// SizeEstimate is the predicted size of a package, from compressing samples of its files
+type SizeEstimate struct {
+	// SourceSize is the size of the files that will be packaged in bytes
+	SourceSize int64
+	// Size is the predicted size of the .intunewin file in bytes
+	Size int64
+	// Largest are the largest top-level files and folders of the source, largest first
+	Largest []SizeEntry
+}
+
+// EstimatePackageSize predicts the size of the package of a source folder without
+// building it, by compressing the start of its files the way packaging does
+func EstimatePackageSize(sourcePath string, opts Options) (*SizeEstimate, error) {
+	var sampled, compressed int64
+	var unsampled float64 // size of files left once the sample budget is used up
+	estimate := float64(packageOverhead)
+	top := make(map[string]int64)
+	counter := &countingWriter{}
+	fw, _ := flate.NewWriter(counter, opts.Compression.level())
+	buf := make([]byte, sizeSampleFile)
+	var sourceSize int64
+
+	_, err := walkSource(longPath(sourcePath), opts.Exclude, opts.Symlinks, func(rel, path string, info os.FileInfo) error {
+		// Local and central ZIP headers both hold the name
+		estimate += float64(100 + 2*len(rel))
+		if info.IsDir() {
+			return nil
+		}
+		sourceSize += info.Size()
+		top[strings.SplitN(rel, string(os.PathSeparator), 2)[0]] += info.Size()
+		if info.Size() == 0 {
+			return nil
+		}
+		if opts.Compression == CompressionStore || compressedExtensions[strings.ToLower(filepath.Ext(rel))] {
+			// Stored files keep their size
+			estimate += float64(info.Size())
+			return nil
+		}
+		if sampled >= sizeSampleTotal {
+			unsampled += float64(info.Size())
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+		n, err := io.ReadFull(f, buf)
+		f.Close()
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("failed to read %s: %w", rel, err)
+		}
+		counter.n = 0
+		fw.Reset(counter)
+		fw.Write(buf[:n])
+		fw.Close()
+		sampled += int64(n)
+		compressed += counter.n
+		estimate += float64(info.Size()) * float64(counter.n) / float64(n)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate package size: %w", err)
+	}
+	if unsampled > 0 {
+		estimate += unsampled * float64(compressed) / float64(sampled)
+	}
+
+	largest := make([]SizeEntry, 0, len(top))
+	for path, size := range top {
+		largest = append(largest, SizeEntry{Path: path, Size: size})
+	}
+	sort.Slice(largest, func(i, j int) bool {
+		if largest[i].Size != largest[j].Size {
+			return largest[i].Size > largest[j].Size
+		}
+		return largest[i].Path < largest[j].Path
+	})
+	if len(largest) > 5 {
+		largest = largest[:5]
+	}
+	return &SizeEstimate{SourceSize: sourceSize, Size: int64(estimate), Largest: largest}, nil
+}
+
+// countingWriter counts the bytes written to it
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// maxSize returns the size packages must not exceed
+func (o Options) maxSize() int64 {
+	if o.MaxSize > 0 {
+		return o.MaxSize
+	}
+	return IntuneMaxSize
+}
+
+// warnSize returns the size above which packaging warns
+func (o Options) warnSize() int64 {
+	if o.WarnSize > 0 {
+		return o.WarnSize
+	}
+	return DefaultWarnSize
+}
+
+// checkEstimatedSize estimates the package size before compressing and fails when it
+// exceeds the maximum, so an oversized package is not built for hours only to be rejected
+// Sources too small to reach the warning size even uncompressed are not sampled
+// Returns the estimated size, 0 when the source was not sampled
+func checkEstimatedSize(sourcePath string, sourceSize int64, fileCount int, opts Options, log *slog.Logger) (int64, error) {
+	// Deflate grows incompressible data by well under 0.1%
+	bound := sourceSize + sourceSize/1000 + int64(fileCount)*1024 + packageOverhead
+	if bound <= opts.warnSize() && bound <= opts.maxSize() {
+		return 0, nil
+	}
+
+	estimate, err := EstimatePackageSize(sourcePath, opts)
+	if err != nil {
+		return 0, err
+	}
+	log.Debug("package size estimated", "bytes", estimate.Size, "source", estimate.SourceSize)
+	if err := estimate.checkMaxSize(opts); err != nil {
+		return 0, err
+	}
+	if estimate.Size > opts.warnSize() {
+		log.Warn("large package, consider splitting it into several apps",
+			"estimated", FormatSize(estimate.Size), "warnAbove", FormatSize(opts.warnSize()), "largest", formatSizeEntries(estimate.Largest))
+	}
+	return estimate.Size, nil
+}
+
+// checkMaxSize fails when the estimate exceeds the maximum package size
+func (e *SizeEstimate) checkMaxSize(opts Options) error {
+	if e.Size > opts.maxSize() {
+		return fmt.Errorf("%w: estimated size %s exceeds the maximum of %s; %s", ErrPackageTooLarge,
+			FormatSize(e.Size), FormatSize(opts.maxSize()), splitSuggestion(e.Largest))
+	}
+	return nil
+}
+
+// checkFinalSize fails when the built package exceeds the maximum size, before it is written
+func checkFinalSize(finalSize int64, opts Options) error {
+	if finalSize > opts.maxSize() {
+		return fmt.Errorf("%w: %s exceeds the maximum of %s; %s", ErrPackageTooLarge,
+			FormatSize(finalSize), FormatSize(opts.maxSize()), splitSuggestion(nil))
+	}
+	return nil
+}
+
+// splitSuggestion explains how to bring a package below the limit
+func splitSuggestion(largest []SizeEntry) string {
+	s := "split the content into several apps linked as dependencies (e.g. per architecture, language or optional component) or exclude files devices do not need"
+	if len(largest) > 0 {
+		s += " (largest parts: " + formatSizeEntries(largest) + ")"
+	}
+	return s
+}
+
+// formatSizeEntries formats entries as "Data (12.00 GB), x64 (9.50 GB)"
+func formatSizeEntries(entries []SizeEntry) string {
+	parts := make([]string, len(entries))
+	for i, e := range entries {
+		parts[i] = fmt.Sprintf("%s (%s)", e.Path, FormatSize(e.Size))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,137 @@
+package packager
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// sizedSource writes a source folder with a compressible and an incompressible file
+func sizedSource(t *testing.T) string {
+	t.Helper()
+	sourceDir := t.TempDir()
+	random := make([]byte, 512<<10)
+	rand.Read(random)
+	if err := os.MkdirAll(filepath.Join(sourceDir, "data"), 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	files := map[string][]byte{
+		"setup.exe":        random,
+		"data/readme.txt":  bytes.Repeat([]byte("The quick brown fox jumps over the lazy dog. "), 20000),
+		"data/license.txt": []byte("freeware"),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(sourceDir, filepath.FromSlash(name)), content, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return sourceDir
+}
+
+func TestEstimatePackageSize(t *testing.T) {
+	sourceDir := sizedSource(t)
+	estimate, err := EstimatePackageSize(sourceDir, Options{})
+	if err != nil {
+		t.Fatalf("EstimatePackageSize() error = %v", err)
+	}
+	result, err := PackageWithOptions(sourceDir, "setup.exe", t.TempDir(), Options{}, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+
+	if estimate.SourceSize != result.SourceSize {
+		t.Errorf("SourceSize = %d, want %d", estimate.SourceSize, result.SourceSize)
+	}
+	// The text compresses to almost nothing, the random data not at all
+	if diff := float64(estimate.Size-result.FinalSize) / float64(result.FinalSize); diff < -0.05 || diff > 0.05 {
+		t.Errorf("Size = %d, want within 5%% of %d", estimate.Size, result.FinalSize)
+	}
+	if len(estimate.Largest) != 2 || estimate.Largest[0].Path != "data" || estimate.Largest[1].Path != "setup.exe" {
+		t.Errorf("Largest = %v, want data, setup.exe", estimate.Largest)
+	}
+}
+
+func TestPackageMaxSize(t *testing.T) {
+	sourceDir := sizedSource(t)
+	for _, lowMemory := range []bool{false, true} {
+		outputDir := t.TempDir()
+		_, err := PackageWithOptions(sourceDir, "setup.exe", outputDir, Options{MaxSize: 256 << 10, LowMemory: lowMemory}, nil)
+		if !errors.Is(err, ErrPackageTooLarge) {
+			t.Fatalf("PackageWithOptions(LowMemory: %v) error = %v, want ErrPackageTooLarge", lowMemory, err)
+		}
+		if !strings.Contains(err.Error(), "split the content") || !strings.Contains(err.Error(), "largest parts: data") {
+			t.Errorf("error = %v, want a split suggestion naming the largest parts", err)
+		}
+		if entries, _ := os.ReadDir(outputDir); len(entries) != 0 {
+			t.Errorf("output written despite the error: %v", entries)
+		}
+	}
+
+	if _, err := PreviewPackage(sourceDir, "setup.exe", t.TempDir(), Options{MaxSize: 256 << 10}); !errors.Is(err, ErrPackageTooLarge) {
+		t.Errorf("PreviewPackage() error = %v, want ErrPackageTooLarge", err)
+	}
+}
+
+func TestPackageWarnSize(t *testing.T) {
+	sourceDir := sizedSource(t)
+	var logs bytes.Buffer
+	opts := Options{WarnSize: 256 << 10, Logger: slog.New(slog.NewTextHandler(&logs, nil))}
+
+	result, err := PackageWithOptions(sourceDir, "setup.exe", t.TempDir(), opts, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	if result.EstimatedSize == 0 {
+		t.Error("EstimatedSize not set for a package above the warning size")
+	}
+	if !strings.Contains(logs.String(), "large package") {
+		t.Errorf("no warning logged, logs:\n%s", logs.String())
+	}
+
+	preview, err := PreviewPackage(sourceDir, "setup.exe", t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("PreviewPackage() error = %v", err)
+	}
+	if preview.EstimatedSize == 0 || !strings.Contains(preview.SizeWarning, "split the content") {
+		t.Errorf("preview = %d bytes, warning %q", preview.EstimatedSize, preview.SizeWarning)
+	}
+
+	// Small sources are not sampled
+	logs.Reset()
+	result, err = PackageWithOptions(sourceDir, "setup.exe", t.TempDir(), Options{Logger: opts.Logger}, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	if result.EstimatedSize != 0 || strings.Contains(logs.String(), "large package") {
+		t.Errorf("EstimatedSize = %d, want 0 without a warning", result.EstimatedSize)
+	}
+}
+
+func TestCheckFinalSize(t *testing.T) {
+	tests := map[string]struct {
+		size    int64
+		opts    Options
+		wantErr bool
+	}{
+		"below default":  {IntuneMaxSize, Options{}, false},
+		"above default":  {IntuneMaxSize + 1, Options{}, true},
+		"below max size": {1 << 20, Options{MaxSize: 1 << 20}, false},
+		"above max size": {1<<20 + 1, Options{MaxSize: 1 << 20}, true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := checkFinalSize(tt.size, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkFinalSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrPackageTooLarge) {
+				t.Errorf("checkFinalSize() error = %v, want ErrPackageTooLarge", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package packager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// lockRetryDelay is the wait before the first check of locked files again; it doubles
+// for every further retry
+var lockRetryDelay = time.Second
+
+// maxListedFiles is the number of unreadable files named in an error
+const maxListedFiles = 10
+
+// UnreadableFile is a source file that cannot be read, and why
+type UnreadableFile struct {
+	// Path is relative to the source folder
+	Path string
+	Err  error
+}
+
+// probeFile opens a source file and reads its first byte, as compression will
+// (replaced in tests to simulate locked files)
+var probeFile = func(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var b [1]byte
+	if _, err := f.Read(b[:]); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// isLocked reports whether a source file failed to open because another process holds it
+func isLocked(err error) bool {
+	return errors.Is(err, ErrLocked) || isLockError(err)
+}
+
+// checkSourceReadable opens every file of the source before compressing, so a file held
+// open by another process, such as an installer still open in an editor, is reported up
+// front with the other unreadable files instead of failing the run halfway through
+// Locked files are checked again up to opts.LockRetries times, waiting longer each time
+func checkSourceReadable(ctx context.Context, sourcePath string, opts Options, log *slog.Logger) error {
+	files := make(map[string]string) // relative path -> path
+	var unreadable []UnreadableFile
+	_, err := walkSource(longPath(sourcePath), opts.Exclude, opts.Symlinks, func(rel, path string, info os.FileInfo) error {
+		if info.IsDir() {
+			return nil
+		}
+		if err := probeFile(path); err != nil {
+			files[rel] = path
+			unreadable = append(unreadable, UnreadableFile{Path: rel, Err: err})
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to check source files: %w", err)
+	}
+
+	delay := lockRetryDelay
+	for attempt := 1; attempt <= opts.LockRetries && hasLocked(unreadable); attempt++ {
+		log.Info("source files locked, waiting", "files", len(unreadable), "attempt", attempt, "delay", delay)
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+		delay *= 2
+
+		var still []UnreadableFile
+		for _, f := range unreadable {
+			if !isLocked(f.Err) {
+				still = append(still, f)
+				continue
+			}
+			if err := probeFile(files[f.Path]); err != nil {
+				still = append(still, UnreadableFile{Path: f.Path, Err: err})
+			}
+		}
+		if len(still) == 0 {
+			// Locks that come and go while files are opened are typically on-access scans
+			log.Warn("source files were locked for a moment, likely by an antivirus scan; excluding the source folder from real-time scanning speeds up packaging",
+				"source", sourcePath, "hint", defenderExclusionHint(sourcePath))
+		}
+		unreadable = still
+	}
+	if len(unreadable) == 0 {
+		return nil
+	}
+	return unreadableError(sourcePath, unreadable, opts.LockRetries)
+}
+
+// hasLocked reports whether any of the files is locked rather than unreadable for good
+func hasLocked(files []UnreadableFile) bool {
+	for _, f := range files {
+		if isLocked(f.Err) {
+			return true
+		}
+	}
+	return false
+}
+
+// unreadableError lists the files that cannot be read and how to free them
+// It wraps ErrLocked when any of them is locked by another process
+func unreadableError(sourcePath string, files []UnreadableFile, retries int) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d source file(s) cannot be read:", len(files))
+	for i, f := range files {
+		if i == maxListedFiles {
+			fmt.Fprintf(&b, "\n  ... and %d more", len(files)-maxListedFiles)
+			break
+		}
+		reason := f.Err.Error()
+		if isLocked(f.Err) {
+			reason = ErrLocked.Error()
+		}
+		fmt.Fprintf(&b, "\n  %s: %s", f.Path, reason)
+	}
+	if !hasLocked(files) {
+		return errors.New(b.String())
+	}
+
+	b.WriteString("\nClose the programs holding these files open, such as an editor or an installer that is still running.")
+	hint := defenderExclusionHint(sourcePath)
+	if retries == 0 {
+		fmt.Fprintf(&b, " Locks that come and go are usually antivirus scans: retry with --lock-retries, or exclude the source folder from real-time scanning (%s).", hint)
+	} else {
+		fmt.Fprintf(&b, " The files were still locked after %d retries; if an antivirus scan holds them, exclude the source folder from real-time scanning (%s).", retries, hint)
+	}
+	return fmt.Errorf("%w: %s", ErrLocked, b.String())
+}
+
+// defenderExclusionHint returns the PowerShell command excluding a folder from Microsoft
+// Defender real-time scanning
+func defenderExclusionHint(sourcePath string) string {
+	if abs, err := filepath.Abs(sourcePath); err == nil {
+		sourcePath = abs
+	}
+	return "Add-MpPreference -ExclusionPath '" + strings.ReplaceAll(normalPath(sourcePath), "'", "''") + "'"
+}
+
+// sourceReadError wraps an error reading a source file while compressing, naming the file
+// and wrapping ErrLocked when another process locked it since the files were checked
+func sourceReadError(name, action string, err error) error {
+	if isLockError(err) {
+		return fmt.Errorf("%s: %s is %w: %v", action, name, ErrLocked, err)
+	}
+	return fmt.Errorf("%s: %w", action, err)
+}
@@ -0,0 +1,103 @@
+package packager
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lockedSource writes a source folder and makes probeFile report the files in locked as
+// locked for the given number of probes; it returns the folder
+func lockedSource(t *testing.T, locked map[string]int) string {
+	t.Helper()
+	sourceDir := t.TempDir()
+	for _, name := range []string{"setup.exe", "data.cab", "readme.txt"} {
+		if err := os.WriteFile(filepath.Join(sourceDir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	probe, delay := probeFile, lockRetryDelay
+	t.Cleanup(func() { probeFile, lockRetryDelay = probe, delay })
+	lockRetryDelay = time.Millisecond
+	probeFile = func(path string) error {
+		name := filepath.Base(path)
+		if locked[name] > 0 {
+			locked[name]--
+			return ErrLocked
+		}
+		return probe(path)
+	}
+	return sourceDir
+}
+
+func TestCheckSourceReadable(t *testing.T) {
+	tests := map[string]struct {
+		locked  map[string]int
+		retries int
+		wantErr bool
+	}{
+		"nothing locked":        {map[string]int{}, 0, false},
+		"locked":                {map[string]int{"data.cab": 1}, 0, true},
+		"transient lock":        {map[string]int{"data.cab": 2}, 3, false},
+		"locked beyond retries": {map[string]int{"data.cab": 5, "setup.exe": 5}, 2, true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sourceDir := lockedSource(t, tt.locked)
+			err := checkSourceReadable(context.Background(), sourceDir, Options{LockRetries: tt.retries}, slog.Default())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkSourceReadable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				return
+			}
+			if !errors.Is(err, ErrLocked) {
+				t.Errorf("checkSourceReadable() error = %v, want ErrLocked", err)
+			}
+			for file := range tt.locked {
+				if !strings.Contains(err.Error(), file) {
+					t.Errorf("error does not name %s: %v", file, err)
+				}
+			}
+			if strings.Contains(err.Error(), "readme.txt") {
+				t.Errorf("error names a readable file: %v", err)
+			}
+			if !strings.Contains(err.Error(), "Add-MpPreference -ExclusionPath") {
+				t.Errorf("error lacks the exclusion hint: %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckSourceReadableNotLocked(t *testing.T) {
+	sourceDir := lockedSource(t, nil)
+	denied := errors.New("access denied")
+	probeFile = func(path string) error {
+		if filepath.Base(path) == "setup.exe" {
+			return denied
+		}
+		return nil
+	}
+	err := checkSourceReadable(context.Background(), sourceDir, Options{LockRetries: 3}, slog.Default())
+	if err == nil || errors.Is(err, ErrLocked) || !strings.Contains(err.Error(), "setup.exe: access denied") {
+		t.Errorf("checkSourceReadable() error = %v, want setup.exe listed as unreadable", err)
+	}
+}
+
+func TestPackageReportsLockedFiles(t *testing.T) {
+	sourceDir := lockedSource(t, map[string]int{"data.cab": 1})
+	outputDir := t.TempDir()
+	_, err := PackageWithOptions(sourceDir, "setup.exe", outputDir, Options{}, nil)
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("PackageWithOptions() error = %v, want ErrLocked", err)
+	}
+	if entries, _ := os.ReadDir(outputDir); len(entries) != 0 {
+		t.Errorf("Expected no output after the check failed, found %d files", len(entries))
+	}
+}
@@ -0,0 +1,588 @@
+package packager
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Files generated for the packages of a split app
+const (
+	// SplitStageScriptName is the script of a content package that stages its part on the device
+	SplitStageScriptName = "Stage-Content.ps1"
+	// SplitInstallScriptName is the script of the install package that joins the parts and runs the setup
+	SplitInstallScriptName = "Install-Split.ps1"
+	// SplitManifestName lists the parts and chunked files for the install script
+	SplitManifestName = "split.json"
+)
+
+// DefaultSplitStaging is the folder on devices below which the content of split apps is staged
+const DefaultSplitStaging = `%ProgramData%\LetsGoIntunePackager\Staging`
+
+// splitChunkSuffix names the chunks of a file cut across parts: setup.cab.split001, ...
+const splitChunkSuffix = ".split%03d"
+
+// SplitOptions controls how the content of an app is split into several packages
+type SplitOptions struct {
+	// PartSize is the most source bytes a content package holds; files larger than the
+	// room left in a part are cut into chunks
+	PartSize int64
+	// SetupArguments are passed to the setup file by the install script (optional,
+	// defaults to /qn /norestart for MSI setup files)
+	SetupArguments string
+	// Staging is the folder on devices the content is staged in (optional, defaults to
+	// DefaultSplitStaging)
+	Staging string
+}
+
+// SplitSlice is a file, or a chunk of a file, of a content part
+type SplitSlice struct {
+	// Path is the path of the source file relative to the source folder
+	Path string
+	// Name is the path the slice is staged as relative to the content folder: Path, or Path
+	// with a .splitNNN suffix for a chunk
+	Name   string
+	Offset int64
+	Size   int64
+	// Chunk is set for the chunks of a file cut across parts
+	Chunk bool
+}
+
+// SplitPart is the content of one content package of a split app
+type SplitPart struct {
+	// Index numbers the parts from 1
+	Index  int
+	Slices []SplitSlice
+	// Size is the number of source bytes of the part
+	Size int64
+}
+
+// SplitChunked is a file cut into chunks, joined again on devices by the install script
+type SplitChunked struct {
+	Path   string   `json:"path"`
+	Size   int64    `json:"size"`
+	SHA256 string   `json:"sha256"`
+	Chunks []string `json:"chunks"`
+}
+
+// SplitFile is a file of the content as the setup file sees it, checked by the install
+// script before it runs anything
+type SplitFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// SplitManifest is the SplitManifestName of the install package
+type SplitManifest struct {
+	App           string         `json:"app"`
+	SetupFile     string         `json:"setupFile"`
+	StagingFolder string         `json:"stagingFolder"`
+	Parts         int            `json:"parts"`
+	Files         []SplitFile    `json:"files"`
+	Chunked       []SplitChunked `json:"chunked,omitempty"`
+}
+
+// SplitPlan is how the content of an app is divided into content packages
+type SplitPlan struct {
+	// App names the packages and apps of the split
+	App        string
+	SourcePath string
+	SetupFile  string
+	// SetupArguments are passed to the setup file by the install script
+	SetupArguments string
+	// StagingFolder is the folder on devices the parts are staged in, unique for the source
+	// so two versions of an app never mix their parts
+	StagingFolder string
+	Parts         []SplitPart
+	SourceSize    int64
+}
+
+// SplitPackage is a folder ready to be packaged with the commands of its app
+type SplitPackage struct {
+	// Name is the name of the package file and app, without extension
+	Name      string
+	Dir       string
+	SetupFile string
+	// Size is the number of source bytes of a content package
+	Size             int64
+	InstallCommand   string
+	UninstallCommand string
+	// DetectionFile is the marker a content package leaves on devices (empty for the
+	// install package, which is detected like the setup file)
+	DetectionFile string
+}
+
+// PlanSplit divides the files of a source folder, in walk order, into parts of at most
+// split.PartSize bytes for packages too large for Intune or slow links
+func PlanSplit(sourcePath, setupFile string, opts Options, split SplitOptions) (*SplitPlan, error) {
+	if split.PartSize <= 0 {
+		return nil, fmt.Errorf("part size must be positive")
+	}
+	if err := validateInputs(sourcePath, setupFile); err != nil {
+		return nil, err
+	}
+	if IsMsixSetupFile(setupFile) {
+		return nil, fmt.Errorf("MSIX setup files cannot be split, they are installed from a single file")
+	}
+
+	plan := &SplitPlan{
+		App:            firstNonEmptyString(opts.OutputName, GetApplicationName(filepath.Base(setupFile))),
+		SourcePath:     sourcePath,
+		SetupFile:      filepath.ToSlash(setupFile),
+		SetupArguments: split.SetupArguments,
+	}
+	if plan.SetupArguments == "" {
+		switch {
+		case IsMsiFile(setupFile), IsMspFile(setupFile):
+			plan.SetupArguments = "/qn /norestart"
+		case strings.EqualFold(filepath.Ext(setupFile), ".msu"):
+			plan.SetupArguments = "/quiet /norestart"
+		}
+	}
+
+	// The staging folder is named after the files, sizes and times, so a new version of
+	// the content is staged next to an old one instead of into it
+	id := sha256.New()
+	fmt.Fprintf(id, "%s\x00%d\x00", plan.SetupFile, split.PartSize)
+	files := make(map[string]bool)
+	part := SplitPart{Index: 1}
+	_, err := walkSource(longPath(sourcePath), opts.Exclude, opts.Symlinks, func(rel, _ string, info os.FileInfo) error {
+		if info.IsDir() {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		files[rel] = true
+		fmt.Fprintf(id, "%s\x00%d\x00%d\x00", rel, info.Size(), info.ModTime().Unix())
+		plan.SourceSize += info.Size()
+
+		size := info.Size()
+		if part.Size > 0 && part.Size+size > split.PartSize && size <= split.PartSize {
+			plan.Parts = append(plan.Parts, part)
+			part = SplitPart{Index: part.Index + 1}
+		}
+		if part.Size+size <= split.PartSize {
+			part.Slices = append(part.Slices, SplitSlice{Path: rel, Name: rel, Size: size})
+			part.Size += size
+			return nil
+		}
+
+		// Larger than a part: the first chunk fills the room left in the current part
+		var offset int64
+		for chunk := 1; offset < size; chunk++ {
+			if part.Size == split.PartSize {
+				plan.Parts = append(plan.Parts, part)
+				part = SplitPart{Index: part.Index + 1}
+			}
+			n := split.PartSize - part.Size
+			if n > size-offset {
+				n = size - offset
+			}
+			part.Slices = append(part.Slices, SplitSlice{Path: rel, Name: rel + fmt.Sprintf(splitChunkSuffix, chunk), Offset: offset, Size: n, Chunk: true})
+			part.Size += n
+			offset += n
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan source folder: %w", err)
+	}
+	if len(part.Slices) > 0 {
+		plan.Parts = append(plan.Parts, part)
+	}
+	if len(plan.Parts) == 0 {
+		return nil, fmt.Errorf("source folder holds no files to split")
+	}
+	for _, part := range plan.Parts {
+		for _, slice := range part.Slices {
+			if slice.Chunk && files[slice.Name] {
+				return nil, fmt.Errorf("%s would be replaced by a chunk of %s", slice.Name, slice.Path)
+			}
+		}
+	}
+
+	staging := strings.TrimRight(firstNonEmptyString(split.Staging, DefaultSplitStaging), `\`)
+	plan.StagingFolder = fmt.Sprintf(`%s\%s-%s`, staging, plan.App, hex.EncodeToString(id.Sum(nil))[:8])
+	return plan, nil
+}
+
+// ContentName returns the name of the content package of part index (from 1)
+func (p *SplitPlan) ContentName(index int) string {
+	return fmt.Sprintf("%s.part%d", p.App, index)
+}
+
+// StageSplit lays the parts of plan out in folders below workDir, each with the script
+// that stages it on devices, and the install package with the script that joins the parts
+// and runs the setup file
+// Returns the content packages in order and the install package
+func StageSplit(plan *SplitPlan, workDir string) ([]SplitPackage, *SplitPackage, error) {
+	manifest := SplitManifest{
+		App:           plan.App,
+		SetupFile:     strings.ReplaceAll(plan.SetupFile, "/", `\`),
+		StagingFolder: plan.StagingFolder,
+		Parts:         len(plan.Parts),
+	}
+	// Files are hashed while they are copied, chunked files in order across parts
+	var hashes []hash.Hash
+	protected := splitProtectedFolders(plan.StagingFolder)
+
+	var packages []SplitPackage
+	for _, part := range plan.Parts {
+		pkg := SplitPackage{
+			Name:             plan.ContentName(part.Index),
+			Dir:              filepath.Join(workDir, fmt.Sprintf("part%d", part.Index)),
+			SetupFile:        SplitStageScriptName,
+			Size:             part.Size,
+			InstallCommand:   fmt.Sprintf(`powershell.exe -NoProfile -ExecutionPolicy Bypass -File .\%s`, SplitStageScriptName),
+			UninstallCommand: fmt.Sprintf(`powershell.exe -NoProfile -ExecutionPolicy Bypass -File .\%s -Uninstall`, SplitStageScriptName),
+			DetectionFile:    fmt.Sprintf(`%s\part%d.staged`, plan.StagingFolder, part.Index),
+		}
+		for _, slice := range part.Slices {
+			if slice.Offset == 0 {
+				hashes = append(hashes, sha256.New())
+				manifest.Files = append(manifest.Files, SplitFile{Path: strings.ReplaceAll(slice.Path, "/", `\`)})
+			}
+			h := hashes[len(hashes)-1]
+			file := &manifest.Files[len(manifest.Files)-1]
+			file.Size += slice.Size
+			if slice.Chunk {
+				if slice.Offset == 0 {
+					manifest.Chunked = append(manifest.Chunked, SplitChunked{Path: file.Path})
+				}
+				chunked := &manifest.Chunked[len(manifest.Chunked)-1]
+				chunked.Chunks = append(chunked.Chunks, strings.ReplaceAll(slice.Name, "/", `\`))
+				chunked.Size += slice.Size
+			}
+			target := filepath.Join(pkg.Dir, "content", filepath.FromSlash(slice.Name))
+			if err := stageSlice(filepath.Join(plan.SourcePath, filepath.FromSlash(slice.Path)), target, slice, h); err != nil {
+				return nil, nil, err
+			}
+		}
+		script, err := generateSplitScript(stageScriptTemplate, map[string]any{
+			"App":              plan.App,
+			"Part":             part.Index,
+			"Parts":            len(plan.Parts),
+			"StagingFolder":    plan.StagingFolder,
+			"Protected":        protected,
+			"Marker":           fmt.Sprintf("part%d.staged", part.Index),
+			"InstallCommand":   pkg.InstallCommand,
+			"UninstallCommand": pkg.UninstallCommand,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := os.WriteFile(filepath.Join(pkg.Dir, SplitStageScriptName), script, 0644); err != nil {
+			return nil, nil, fmt.Errorf("failed to write stage script: %w", err)
+		}
+		packages = append(packages, pkg)
+	}
+	sums := make(map[string]string, len(hashes))
+	for i, h := range hashes {
+		manifest.Files[i].SHA256 = strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
+		sums[manifest.Files[i].Path] = manifest.Files[i].SHA256
+	}
+	for i := range manifest.Chunked {
+		manifest.Chunked[i].SHA256 = sums[manifest.Chunked[i].Path]
+	}
+
+	install := &SplitPackage{
+		Name:           plan.App,
+		Dir:            filepath.Join(workDir, "install"),
+		SetupFile:      SplitInstallScriptName,
+		InstallCommand: fmt.Sprintf(`powershell.exe -NoProfile -ExecutionPolicy Bypass -File .\%s`, SplitInstallScriptName),
+	}
+	if err := os.MkdirAll(install.Dir, 0755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode split manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(install.Dir, SplitManifestName), append(data, '\n'), 0644); err != nil {
+		return nil, nil, fmt.Errorf("failed to write split manifest: %w", err)
+	}
+	script, err := generateSplitScript(installScriptTemplate, map[string]any{
+		"App":            plan.App,
+		"Parts":          len(plan.Parts),
+		"StagingFolder":  plan.StagingFolder,
+		"Protected":      protected,
+		"SetupArguments": plan.SetupArguments,
+		"InstallCommand": install.InstallCommand,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.WriteFile(filepath.Join(install.Dir, SplitInstallScriptName), script, 0644); err != nil {
+		return nil, nil, fmt.Errorf("failed to write install script: %w", err)
+	}
+	return packages, install, nil
+}
+
+// splitProtectedFolders returns the folders the split scripts restrict to SYSTEM and
+// Administrators, parents first: the staging folder and the folder it is in, and below
+// the default staging folder also the LetsGoIntunePackager folder
+func splitProtectedFolders(stagingFolder string) []string {
+	root := stagingFolder[:strings.LastIndex(stagingFolder, `\`)]
+	folders := []string{root, stagingFolder}
+	if strings.EqualFold(root, DefaultSplitStaging) {
+		folders = append([]string{root[:strings.LastIndex(root, `\`)]}, folders...)
+	}
+	return folders
+}
+
+// stageSlice copies a file, or a chunk of it, to target, adding the copied bytes to h
+// when set; whole files keep their modification time
+func stageSlice(source, target string, slice SplitSlice, h hash.Hash) error {
+	if err := os.MkdirAll(filepath.Dir(longPath(target)), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	in, err := os.Open(longPath(source))
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer in.Close()
+	out, err := os.Create(longPath(target))
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", slice.Name, err)
+	}
+	var w io.Writer = out
+	if h != nil {
+		w = io.MultiWriter(out, h)
+	}
+	_, err = io.Copy(w, io.NewSectionReader(in, slice.Offset, slice.Size))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to copy %s: %w", slice.Name, err)
+	}
+	if !slice.Chunk {
+		if info, err := in.Stat(); err == nil {
+			os.Chtimes(longPath(target), time.Now(), info.ModTime())
+		}
+	}
+	return nil
+}
+
+// generateSplitScript renders a script template with Windows line endings
+func generateSplitScript(text string, params map[string]any) ([]byte, error) {
+	tmpl, err := template.New("split").Funcs(template.FuncMap{"ps": powershellQuote}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse split script template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, params); err != nil {
+		return nil, fmt.Errorf("failed to generate split script: %w", err)
+	}
+	return bytes.ReplaceAll(buf.Bytes(), []byte("\n"), []byte("\r\n")), nil
+}
+
+// stageScriptTemplate copies the part of a content package to the staging folder, or
+// removes it again with -Uninstall
+const stageScriptTemplate = `# Stages part {{.Part}} of {{.Parts}} of {{.App}} for its install app
+# Generated by LetsGoIntunePackager
+#   Install:   {{.InstallCommand}}
+#   Uninstall: {{.UninstallCommand}}
+# The content is copied to {{.StagingFolder}}, where the install app of
+# {{.App}} joins the parts and runs the setup file
+# Exit codes: 0 on success, otherwise the robocopy exit code
+param([switch]$Uninstall)
+
+$ErrorActionPreference = 'Stop'
+$staging = [Environment]::ExpandEnvironmentVariables({{ps .StagingFolder}})
+$content = Join-Path $staging 'content'
+$source = Join-Path $PSScriptRoot 'content'
+$marker = Join-Path $staging {{ps .Marker}}
+` + splitProtectScript + `
+if ($Uninstall) {
+    Get-ChildItem -LiteralPath $source -Recurse -File | ForEach-Object {
+        $staged = Join-Path $content $_.FullName.Substring($source.Length + 1)
+        Remove-Item -LiteralPath $staged -Force -ErrorAction SilentlyContinue
+    }
+    Remove-Item -LiteralPath $marker -Force -ErrorAction SilentlyContinue
+    exit 0
+}
+
+foreach ($folder in $protected) {
+    Protect-StagingFolder $folder
+}
+New-Item -ItemType Directory -Force -Path $content | Out-Null
+robocopy $source $content /E /R:3 /W:10 /NP /NFL /NDL
+# robocopy exit codes below 8 report success
+if ($LASTEXITCODE -ge 8) {
+    Write-Output "Copying part {{.Part}} to $content failed with exit code $LASTEXITCODE"
+    exit $LASTEXITCODE
+}
+Set-Content -LiteralPath $marker -Value (Get-Date -Format o)
+exit 0
+`
+
+// splitProtectScript restricts the folders content is staged in to SYSTEM and
+// Administrators, so that users of the device cannot change it before the install script
+// runs it as SYSTEM
+const splitProtectScript = `
+# Only SYSTEM and Administrators may write to the staging folders: a folder, file or link
+# in their place that someone else owns is removed, and their ACL is replaced
+$trusted = @('S-1-5-18', 'S-1-5-32-544')
+$protected = @(
+{{- range .Protected}}
+    [Environment]::ExpandEnvironmentVariables({{ps .}})
+{{- end}}
+)
+function Protect-StagingFolder([string]$Path) {
+    $item = Get-Item -LiteralPath $Path -Force -ErrorAction SilentlyContinue
+    if ($item) {
+        $owner = (Get-Acl -LiteralPath $Path).GetOwner([Security.Principal.SecurityIdentifier]).Value
+        $link = $item.Attributes -band [IO.FileAttributes]::ReparsePoint
+        if ($link -or -not $item.PSIsContainer -or $owner -notin $trusted) {
+            Write-Output "Removing $Path, which is owned by $owner"
+            if ($item.PSIsContainer) {
+                # rmdir removes junctions and links without following them
+                cmd.exe /c rmdir /s /q "$Path"
+            } else {
+                Remove-Item -LiteralPath $Path -Force
+            }
+            if (Test-Path -LiteralPath $Path) {
+                throw "Failed to remove $Path"
+            }
+        }
+    }
+    New-Item -ItemType Directory -Force -Path $Path | Out-Null
+    $acl = New-Object Security.AccessControl.DirectorySecurity
+    $acl.SetAccessRuleProtection($true, $false)
+    foreach ($sid in $trusted) {
+        $account = New-Object Security.Principal.SecurityIdentifier $sid
+        $acl.AddAccessRule((New-Object Security.AccessControl.FileSystemAccessRule $account, 'FullControl', 'ContainerInherit, ObjectInherit', 'None', 'Allow'))
+    }
+    Set-Acl -LiteralPath $Path -AclObject $acl
+}
+`
+
+// installScriptTemplate joins the chunked files of the staged parts, checks them, runs the
+// setup file and removes the staged content once it succeeded
+const installScriptTemplate = `# Installs {{.App}} from the content staged by its {{.Parts}} content apps
+# Generated by LetsGoIntunePackager
+#   Install: {{.InstallCommand}}
+# The content apps are dependencies of this app and stage their part of the content in
+# {{.StagingFolder}}. This script joins the files cut across parts,
+# checks the owner and SHA256 of every staged file and runs the setup file, then removes
+# the staged content
+# Exit codes: the exit code of the setup file, or 1 when the content is incomplete or
+# fails its checks
+param([string]$SetupArguments = {{ps .SetupArguments}})
+
+$ErrorActionPreference = 'Stop'
+$manifest = Get-Content -LiteralPath (Join-Path $PSScriptRoot 'split.json') -Raw | ConvertFrom-Json
+$staging = [Environment]::ExpandEnvironmentVariables($manifest.stagingFolder)
+$content = Join-Path $staging 'content'
+` + splitProtectScript + `
+# A staging folder someone else owned is removed here, and then found incomplete
+foreach ($folder in $protected) {
+    Protect-StagingFolder $folder
+}
+
+for ($part = 1; $part -le $manifest.parts; $part++) {
+    if (-not (Test-Path -LiteralPath (Join-Path $staging "part$part.staged"))) {
+        Write-Output "Part $part of $($manifest.parts) is not staged in $staging, install the content apps first"
+        exit 1
+    }
+}
+if (-not (Test-Path -LiteralPath $content)) {
+    Write-Output "The staged content was removed after an earlier install, reinstall the content apps"
+    exit 1
+}
+
+foreach ($file in $manifest.chunked) {
+    $target = Join-Path $content $file.path
+    # A file joined by an earlier attempt is checked with the others below
+    if (-not (Test-Path -LiteralPath $target)) {
+        $joining = "$target.joining"
+        $out = [IO.File]::Create($joining)
+        try {
+            foreach ($chunk in $file.chunks) {
+                $in = [IO.File]::OpenRead((Join-Path $content $chunk))
+                try {
+                    $in.CopyTo($out, 4MB)
+                } finally {
+                    $in.Dispose()
+                }
+            }
+        } finally {
+            $out.Dispose()
+        }
+        $hash = (Get-FileHash -LiteralPath $joining -Algorithm SHA256).Hash
+        if ($hash -ne $file.sha256) {
+            Remove-Item -LiteralPath $joining -Force
+            Write-Output "$($file.path) is damaged after joining its chunks (SHA256 $hash, expected $($file.sha256))"
+            exit 1
+        }
+        Move-Item -LiteralPath $joining -Destination $target
+    }
+    foreach ($chunk in $file.chunks) {
+        Remove-Item -LiteralPath (Join-Path $content $chunk) -Force -ErrorAction SilentlyContinue
+    }
+}
+
+# Nothing runs unless every staged file is a file of the content, owned by SYSTEM or
+# Administrators and unchanged
+$expected = @{}
+foreach ($file in $manifest.files) {
+    $expected[$file.path] = $true
+}
+foreach ($item in Get-ChildItem -LiteralPath $content -Recurse -Force) {
+    $path = $item.FullName.Substring($content.Length + 1)
+    $owner = (Get-Acl -LiteralPath $item.FullName).GetOwner([Security.Principal.SecurityIdentifier]).Value
+    if ($owner -notin $trusted -or ($item.Attributes -band [IO.FileAttributes]::ReparsePoint)) {
+        Write-Output "$path in $content is a link or owned by $owner, reinstall the content apps"
+        exit 1
+    }
+    if (-not $item.PSIsContainer -and -not $expected.ContainsKey($path)) {
+        Write-Output "$path in $content is not part of the content of $($manifest.app)"
+        exit 1
+    }
+}
+foreach ($file in $manifest.files) {
+    $path = Join-Path $content $file.path
+    if (-not (Test-Path -LiteralPath $path -PathType Leaf)) {
+        Write-Output "$($file.path) is missing from $content, reinstall the content apps"
+        exit 1
+    }
+    $hash = (Get-FileHash -LiteralPath $path -Algorithm SHA256).Hash
+    if ($hash -ne $file.sha256) {
+        Write-Output "$($file.path) was changed after it was staged (SHA256 $hash, expected $($file.sha256))"
+        exit 1
+    }
+}
+
+$setup = Join-Path $content $manifest.setupFile
+switch ([IO.Path]::GetExtension($setup).ToLower()) {
+    '.msi' { $file = 'msiexec.exe'; $arguments = "/i ""$setup"" $SetupArguments" }
+    '.msp' { $file = 'msiexec.exe'; $arguments = "/p ""$setup"" $SetupArguments" }
+    '.msu' { $file = 'wusa.exe'; $arguments = """$setup"" $SetupArguments" }
+    { $_ -in '.vbs', '.wsf' } { $file = 'cscript.exe'; $arguments = "//NoLogo ""$setup"" $SetupArguments" }
+    '.reg' { $file = 'reg.exe'; $arguments = "import ""$setup""" }
+    '.ps1' { $file = 'powershell.exe'; $arguments = "-NoProfile -ExecutionPolicy Bypass -File ""$setup"" $SetupArguments" }
+    { $_ -in '.cmd', '.bat' } { $file = 'cmd.exe'; $arguments = "/c """"$setup"" $SetupArguments""" }
+    default { $file = $setup; $arguments = $SetupArguments }
+}
+Write-Output "$file $arguments"
+$start = @{ FilePath = $file; WorkingDirectory = (Split-Path -Parent $setup); Wait = $true; PassThru = $true }
+if ($arguments.Trim()) {
+    $start.ArgumentList = $arguments.Trim()
+}
+$process = Start-Process @start
+$code = $process.ExitCode
+if ($code -eq 0 -or $code -eq 3010 -or $code -eq 1641) {
+    Remove-Item -LiteralPath $content -Recurse -Force
+}
+exit $code
+`
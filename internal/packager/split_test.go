@@ -0,0 +1,259 @@
+package packager
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeSplitSource writes files of the given sizes, named by their slash paths
+func writeSplitSource(t *testing.T, sizes map[string]int) string {
+	t.Helper()
+	sourceDir := t.TempDir()
+	files := make(map[string]string, len(sizes))
+	for name, size := range sizes {
+		content := bytes.Repeat([]byte(name[:1]), size)
+		for i := range content {
+			content[i] += byte(i % 7)
+		}
+		files[name] = string(content)
+	}
+	writeTree(t, sourceDir, files)
+	return sourceDir
+}
+
+func TestPlanSplit(t *testing.T) {
+	tests := map[string]struct {
+		sizes    map[string]int
+		partSize int64
+		// want lists the slice names of each part
+		want [][]string
+	}{
+		"fits one part": {
+			sizes:    map[string]int{"setup.exe": 10, "readme.txt": 5},
+			partSize: 100,
+			want:     [][]string{{"readme.txt", "setup.exe"}},
+		},
+		"files start new parts": {
+			sizes:    map[string]int{"a.cab": 60, "b.cab": 60, "setup.exe": 30},
+			partSize: 100,
+			want:     [][]string{{"a.cab"}, {"b.cab", "setup.exe"}},
+		},
+		"large file chunked": {
+			sizes:    map[string]int{"a.txt": 40, "data/big.cab": 250, "setup.exe": 10},
+			partSize: 100,
+			// 60 bytes of big.cab fill the first part, the last 90 leave room for setup.exe
+			want: [][]string{
+				{"a.txt", "data/big.cab.split001"},
+				{"data/big.cab.split002"},
+				{"data/big.cab.split003", "setup.exe"},
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sourceDir := writeSplitSource(t, tt.sizes)
+			plan, err := PlanSplit(sourceDir, "setup.exe", Options{}, SplitOptions{PartSize: tt.partSize})
+			if err != nil {
+				t.Fatalf("PlanSplit() error = %v", err)
+			}
+			var got [][]string
+			var total int64
+			for i, part := range plan.Parts {
+				if part.Index != i+1 || part.Size > tt.partSize {
+					t.Errorf("part %d: index %d, %d bytes", i+1, part.Index, part.Size)
+				}
+				var names []string
+				for _, slice := range part.Slices {
+					names = append(names, slice.Name)
+					total += slice.Size
+				}
+				got = append(got, names)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parts = %v, want %v", got, tt.want)
+			}
+			if total != plan.SourceSize {
+				t.Errorf("slices hold %d bytes, source %d", total, plan.SourceSize)
+			}
+			if !strings.HasPrefix(plan.StagingFolder, DefaultSplitStaging+`\setup-`) {
+				t.Errorf("StagingFolder = %s", plan.StagingFolder)
+			}
+		})
+	}
+}
+
+func TestPlanSplitRejects(t *testing.T) {
+	tests := map[string]struct {
+		sizes     map[string]int
+		setupFile string
+		partSize  int64
+		wantErr   string
+	}{
+		"no part size":    {map[string]int{"setup.exe": 10}, "setup.exe", 0, "part size"},
+		"msix":            {map[string]int{"app.msix": 10}, "app.msix", 100, "MSIX"},
+		"chunk collision": {map[string]int{"setup.exe": 10, "big.cab": 250, "big.cab.split002": 1}, "setup.exe", 100, "would be replaced"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sourceDir := writeSplitSource(t, tt.sizes)
+			if _, err := PlanSplit(sourceDir, tt.setupFile, Options{}, SplitOptions{PartSize: tt.partSize}); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("PlanSplit() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlanSplitStagingFolder(t *testing.T) {
+	sourceDir := writeSplitSource(t, map[string]int{"setup.exe": 10, "data.cab": 50})
+	first, err := PlanSplit(sourceDir, "setup.exe", Options{}, SplitOptions{PartSize: 100})
+	if err != nil {
+		t.Fatalf("PlanSplit() error = %v", err)
+	}
+	again, err := PlanSplit(sourceDir, "setup.exe", Options{}, SplitOptions{PartSize: 100})
+	if err != nil {
+		t.Fatalf("PlanSplit() error = %v", err)
+	}
+	if first.StagingFolder != again.StagingFolder {
+		t.Errorf("StagingFolder changed for the same source: %s, %s", first.StagingFolder, again.StagingFolder)
+	}
+
+	// A new version of the content is staged in another folder
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(sourceDir, "data.cab"), later, later)
+	changed, err := PlanSplit(sourceDir, "setup.exe", Options{}, SplitOptions{PartSize: 100, Staging: `D:\Staging\`})
+	if err != nil {
+		t.Fatalf("PlanSplit() error = %v", err)
+	}
+	if !strings.HasPrefix(changed.StagingFolder, `D:\Staging\setup-`) || changed.StagingFolder[len(`D:\Staging\`):] == first.StagingFolder[len(DefaultSplitStaging)+1:] {
+		t.Errorf("StagingFolder = %s, want a new folder below D:\\Staging", changed.StagingFolder)
+	}
+}
+
+func TestStageSplit(t *testing.T) {
+	sourceDir := writeSplitSource(t, map[string]int{"a.txt": 40, "data/big.cab": 250, "setup.msi": 10})
+	plan, err := PlanSplit(sourceDir, "setup.msi", Options{}, SplitOptions{PartSize: 100})
+	if err != nil {
+		t.Fatalf("PlanSplit() error = %v", err)
+	}
+	workDir := t.TempDir()
+	parts, install, err := StageSplit(plan, workDir)
+	if err != nil {
+		t.Fatalf("StageSplit() error = %v", err)
+	}
+	if len(parts) != len(plan.Parts) {
+		t.Fatalf("StageSplit() = %d content packages, want %d", len(parts), len(plan.Parts))
+	}
+
+	for i, pkg := range parts {
+		if pkg.Name != plan.ContentName(i+1) || pkg.DetectionFile != fmt.Sprintf(`%s\part%d.staged`, plan.StagingFolder, i+1) {
+			t.Errorf("content package %d = %s detected by %s", i+1, pkg.Name, pkg.DetectionFile)
+		}
+		script, err := os.ReadFile(filepath.Join(pkg.Dir, SplitStageScriptName))
+		if err != nil {
+			t.Fatalf("Failed to read stage script: %v", err)
+		}
+		if !bytes.Contains(script, []byte("'"+plan.StagingFolder+"'")) || !bytes.Contains(script, []byte("\r\n")) {
+			t.Errorf("stage script of part %d does not stage to %s with CRLF line endings", i+1, plan.StagingFolder)
+		}
+		if !bytes.Contains(script, []byte("Protect-StagingFolder $folder")) || !bytes.Contains(script, []byte(`'%ProgramData%\LetsGoIntunePackager'`)) {
+			t.Errorf("stage script of part %d does not protect the staging folders", i+1)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(install.Dir, SplitManifestName))
+	if err != nil {
+		t.Fatalf("Failed to read split manifest: %v", err)
+	}
+	var manifest SplitManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("Failed to parse split manifest: %v", err)
+	}
+	if manifest.Parts != len(parts) || manifest.SetupFile != "setup.msi" || len(manifest.Chunked) != 1 {
+		t.Fatalf("manifest = %+v", manifest)
+	}
+
+	// Every file of the content is listed with its SHA256, chunked files once joined
+	if len(manifest.Files) != 3 {
+		t.Fatalf("manifest lists %d files, want 3: %+v", len(manifest.Files), manifest.Files)
+	}
+	for _, file := range manifest.Files {
+		data, err := os.ReadFile(filepath.Join(sourceDir, filepath.FromSlash(strings.ReplaceAll(file.Path, `\`, "/"))))
+		if err != nil {
+			t.Fatalf("manifest lists %s, which is not a source file", file.Path)
+		}
+		sum := sha256.Sum256(data)
+		if file.Size != int64(len(data)) || file.SHA256 != strings.ToUpper(hex.EncodeToString(sum[:])) {
+			t.Errorf("manifest file %+v, want %d bytes with SHA256 %X", file, len(data), sum)
+		}
+	}
+
+	// Join the chunks like the install script does
+	chunked := manifest.Chunked[0]
+	var joined []byte
+	for _, chunk := range chunked.Chunks {
+		var found bool
+		for _, pkg := range parts {
+			if data, err := os.ReadFile(filepath.Join(pkg.Dir, "content", filepath.FromSlash(strings.ReplaceAll(chunk, `\`, "/")))); err == nil {
+				joined = append(joined, data...)
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("chunk %s not staged", chunk)
+		}
+	}
+	want, _ := os.ReadFile(filepath.Join(sourceDir, "data", "big.cab"))
+	sum := sha256.Sum256(want)
+	if !bytes.Equal(joined, want) || int64(len(want)) != chunked.Size || chunked.SHA256 != strings.ToUpper(hex.EncodeToString(sum[:])) {
+		t.Errorf("joined chunks differ from the source file (manifest %+v)", chunked)
+	}
+
+	script, err := os.ReadFile(filepath.Join(install.Dir, SplitInstallScriptName))
+	if err != nil {
+		t.Fatalf("Failed to read install script: %v", err)
+	}
+	if !bytes.Contains(script, []byte("$SetupArguments = '/qn /norestart'")) {
+		t.Errorf("install script does not default to quiet MSI arguments:\n%s", script)
+	}
+	// The staged files are checked before the setup file runs
+	checked := bytes.Index(script, []byte("foreach ($file in $manifest.files)"))
+	if !bytes.Contains(script, []byte("Protect-StagingFolder $folder")) || checked < 0 || checked > bytes.Index(script, []byte("Start-Process")) {
+		t.Errorf("install script does not check the staged content before running the setup file:\n%s", script)
+	}
+}
+
+func TestSplitProtectedFolders(t *testing.T) {
+	tests := []struct {
+		name    string
+		staging string
+		want    []string
+	}{
+		{
+			name:    "default",
+			staging: DefaultSplitStaging + `\setup-0123abcd`,
+			want:    []string{`%ProgramData%\LetsGoIntunePackager`, DefaultSplitStaging, DefaultSplitStaging + `\setup-0123abcd`},
+		},
+		{
+			name:    "custom",
+			staging: `D:\Staging\setup-0123abcd`,
+			want:    []string{`D:\Staging`, `D:\Staging\setup-0123abcd`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitProtectedFolders(tt.staging)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("splitProtectedFolders() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
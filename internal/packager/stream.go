@@ -0,0 +1,676 @@
+package packager
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// sourceEntry is a file or folder read from a source stream
+type sourceEntry struct {
+	// name is the cleaned, slash-separated path of the entry in the stream
+	name string
+	dir  bool
+	// link is the earlier file a tar hard link refers to
+	link    string
+	exec    bool
+	modTime time.Time
+	// data reads the content of a file
+	data io.Reader
+}
+
+// sourceReader returns the entries of a source stream one at a time, and io.EOF after
+// the last one
+type sourceReader interface {
+	next() (*sourceEntry, error)
+}
+
+// openSourceStream returns the entries of a tar (optionally gzip-compressed) or ZIP
+// stream of a source folder, telling them apart by their first bytes
+func openSourceStream(r io.Reader) (sourceReader, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	magic, _ := br.Peek(4)
+	switch {
+	case len(magic) == 0:
+		return nil, fmt.Errorf("source stream is empty")
+	case bytes.HasPrefix(magic, []byte("PK")):
+		return &zipSource{r: br}, nil
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		return &tarSource{tr: tar.NewReader(gz)}, nil
+	default:
+		return &tarSource{tr: tar.NewReader(br)}, nil
+	}
+}
+
+// streamEntryName cleans the name of an entry of a source stream
+// Leading ../ elements are dropped, so no entry can be placed outside the source folder;
+// the source folder itself is returned as ""
+func streamEntryName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(name, `\`, "/")), "/")
+}
+
+// tarSource reads the entries of a tar stream
+// Symbolic links are rejected, as they could point anywhere on the machine creating
+// the stream
+type tarSource struct {
+	tr *tar.Reader
+}
+
+func (s *tarSource) next() (*sourceEntry, error) {
+	for {
+		hdr, err := s.tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tar stream: %w", err)
+		}
+		entry := &sourceEntry{name: streamEntryName(hdr.Name), modTime: hdr.ModTime}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			entry.dir = true
+		case tar.TypeReg:
+			entry.exec = hdr.Mode&0111 != 0
+			entry.data = s.tr
+		case tar.TypeLink:
+			// Hard links refer to a file earlier in the stream
+			entry.exec = hdr.Mode&0111 != 0
+			entry.link = streamEntryName(hdr.Linkname)
+		case tar.TypeSymlink:
+			return nil, fmt.Errorf("symbolic link in source stream: %s (create the stream with tar -h to store the files links point to)", hdr.Name)
+		case tar.TypeXGlobalHeader:
+			continue
+		default:
+			return nil, fmt.Errorf("unsupported entry in source stream: %s (type %c)", hdr.Name, hdr.Typeflag)
+		}
+		return entry, nil
+	}
+}
+
+// ZIP record signatures and flags read by zipSource
+const (
+	zipLocalHeaderSignature    = 0x04034b50
+	zipCentralHeaderSignature  = 0x02014b50
+	zipEndSignature            = 0x06054b50
+	zip64EndSignature          = 0x06064b50
+	zipDescriptorSignature     = 0x08074b50
+	zipFlagEncrypted           = 0x1
+	zipFlagDescriptor          = 0x8
+	zip64ExtraID               = 0x0001
+	zipLocalHeaderLen          = 26
+	zipMaxSize32               = 0xFFFFFFFF
+	zipDescriptorSignatureSize = 4
+)
+
+// zipSource reads the entries of a ZIP stream from their local headers, front to back
+// The central directory at the end is never needed, so the stream is not held in memory
+// Entries whose sizes are only known from a data descriptor must be deflated, the end of
+// the deflate data telling where they end
+type zipSource struct {
+	r *bufio.Reader
+	// entry is the entry returned last, read to its end before the next header
+	entry *zipSourceEntry
+	done  bool
+}
+
+func (s *zipSource) next() (*sourceEntry, error) {
+	if s.entry != nil {
+		if _, err := io.Copy(io.Discard, s.entry); err != nil {
+			return nil, err
+		}
+		s.entry = nil
+	}
+	if s.done {
+		return nil, io.EOF
+	}
+
+	var sig [4]byte
+	if _, err := io.ReadFull(s.r, sig[:]); err != nil {
+		return nil, fmt.Errorf("failed to read ZIP stream: %w", err)
+	}
+	switch binary.LittleEndian.Uint32(sig[:]) {
+	case zipLocalHeaderSignature:
+	case zipCentralHeaderSignature, zipEndSignature, zip64EndSignature:
+		// The directory repeats the entries; the rest of the stream is drained so that the
+		// process writing it does not fail
+		s.done = true
+		io.Copy(io.Discard, s.r)
+		return nil, io.EOF
+	default:
+		return nil, fmt.Errorf("failed to read ZIP stream: unexpected data instead of an entry")
+	}
+
+	var fixed [zipLocalHeaderLen]byte
+	if _, err := io.ReadFull(s.r, fixed[:]); err != nil {
+		return nil, fmt.Errorf("failed to read ZIP stream: %w", err)
+	}
+	flags := binary.LittleEndian.Uint16(fixed[2:])
+	method := binary.LittleEndian.Uint16(fixed[4:])
+	modTime := binary.LittleEndian.Uint16(fixed[6:])
+	modDate := binary.LittleEndian.Uint16(fixed[8:])
+	entry := &zipSourceEntry{
+		r:              s.r,
+		crc:            crc32.NewIEEE(),
+		wantCRC:        binary.LittleEndian.Uint32(fixed[10:]),
+		compressedSize: uint64(binary.LittleEndian.Uint32(fixed[14:])),
+		size:           uint64(binary.LittleEndian.Uint32(fixed[18:])),
+		descriptor:     flags&zipFlagDescriptor != 0,
+	}
+	nameAndExtra := make([]byte, int(binary.LittleEndian.Uint16(fixed[22:]))+int(binary.LittleEndian.Uint16(fixed[24:])))
+	if _, err := io.ReadFull(s.r, nameAndExtra); err != nil {
+		return nil, fmt.Errorf("failed to read ZIP stream: %w", err)
+	}
+	rawName := string(nameAndExtra[:binary.LittleEndian.Uint16(fixed[22:])])
+	entry.name = rawName
+	entry.readZip64Extra(nameAndExtra[len(rawName):])
+
+	if flags&zipFlagEncrypted != 0 {
+		return nil, fmt.Errorf("encrypted entry in source stream: %s", rawName)
+	}
+	switch {
+	case method == zip.Deflate && entry.descriptor:
+		// The deflate data ends itself; a bufio.Reader is read no further than that
+		entry.data = flate.NewReader(s.r)
+	case method == zip.Deflate:
+		entry.limited = io.LimitReader(s.r, int64(entry.compressedSize))
+		entry.data = flate.NewReader(entry.limited)
+	case method == zip.Store && entry.descriptor:
+		return nil, fmt.Errorf("stored entry of unknown size in source stream: %s (pipe a tar stream instead)", rawName)
+	case method == zip.Store:
+		entry.limited = io.LimitReader(s.r, int64(entry.compressedSize))
+		entry.data = entry.limited
+	default:
+		return nil, fmt.Errorf("unsupported compression method %d in source stream: %s", method, rawName)
+	}
+	s.entry = entry
+
+	source := &sourceEntry{name: streamEntryName(rawName), modTime: msDosTime(modDate, modTime)}
+	if strings.HasSuffix(strings.ReplaceAll(rawName, `\`, "/"), "/") {
+		source.dir = true
+	} else {
+		source.data = entry
+	}
+	return source, nil
+}
+
+// msDosTime converts the MS-DOS date and time of a ZIP entry
+func msDosTime(date, t uint16) time.Time {
+	return time.Date(1980+int(date>>9), time.Month(date>>5&0xf), int(date&0x1f),
+		int(t>>11), int(t>>5&0x3f), int(t&0x1f)*2, 0, time.UTC)
+}
+
+// zipSourceEntry reads the content of an entry of a ZIP stream, checking its size and
+// CRC-32 once it is read to the end
+type zipSourceEntry struct {
+	name           string
+	r              *bufio.Reader
+	data           io.Reader
+	limited        io.Reader
+	crc            hash.Hash32
+	read           uint64
+	wantCRC        uint32
+	compressedSize uint64
+	size           uint64
+	descriptor     bool
+	zip64          bool
+	err            error
+}
+
+// readZip64Extra takes the sizes of the entry from its ZIP64 extra field, if it has one
+func (e *zipSourceEntry) readZip64Extra(extra []byte) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		if len(extra) < 4+size {
+			return
+		}
+		field := extra[4 : 4+size]
+		extra = extra[4+size:]
+		if id != zip64ExtraID {
+			continue
+		}
+		e.zip64 = true
+		if e.size == zipMaxSize32 && len(field) >= 8 {
+			e.size = binary.LittleEndian.Uint64(field)
+			field = field[8:]
+		}
+		if e.compressedSize == zipMaxSize32 && len(field) >= 8 {
+			e.compressedSize = binary.LittleEndian.Uint64(field)
+		}
+	}
+}
+
+func (e *zipSourceEntry) Read(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	n, err := e.data.Read(p)
+	e.crc.Write(p[:n])
+	e.read += uint64(n)
+	if err == io.EOF {
+		if err := e.finish(); err != nil {
+			e.err = err
+			return n, err
+		}
+		e.err = io.EOF
+		return n, io.EOF
+	}
+	if err != nil {
+		e.err = fmt.Errorf("failed to read %s from ZIP stream: %w", e.name, err)
+		return n, e.err
+	}
+	return n, nil
+}
+
+// finish reads the data descriptor of the entry, if it has one, and checks the size and
+// CRC-32 of its content
+func (e *zipSourceEntry) finish() error {
+	if e.limited != nil {
+		// Deflate data may end before its recorded size
+		if _, err := io.Copy(io.Discard, e.limited); err != nil {
+			return fmt.Errorf("failed to read %s from ZIP stream: %w", e.name, err)
+		}
+	}
+	if e.descriptor {
+		if sig, err := e.r.Peek(zipDescriptorSignatureSize); err == nil && binary.LittleEndian.Uint32(sig) == zipDescriptorSignature {
+			e.r.Discard(zipDescriptorSignatureSize)
+		}
+		sizeLen := 4
+		if e.zip64 {
+			sizeLen = 8
+		}
+		descriptor := make([]byte, 4+2*sizeLen)
+		if _, err := io.ReadFull(e.r, descriptor); err != nil {
+			return fmt.Errorf("failed to read %s from ZIP stream: %w", e.name, err)
+		}
+		e.wantCRC = binary.LittleEndian.Uint32(descriptor)
+		if e.zip64 {
+			e.size = binary.LittleEndian.Uint64(descriptor[4+sizeLen:])
+		} else {
+			e.size = uint64(binary.LittleEndian.Uint32(descriptor[4+sizeLen:]))
+		}
+	}
+	if e.read != e.size || e.crc.Sum32() != e.wantCRC {
+		return fmt.Errorf("%s is damaged in the ZIP stream: checksum mismatch", e.name)
+	}
+	return nil
+}
+
+// streamFile is a file written to the content ZIP of a source stream, kept for hard links
+type streamFile struct {
+	header *zip.FileHeader
+	// offset is where its compressed data starts in the ZIP
+	offset int
+}
+
+// streamZipper compresses the entries of a source stream into a ZIP held in memory
+type streamZipper struct {
+	buf  *bytes.Buffer
+	zw   *zip.Writer
+	opts ZipOptions
+	// prefix is the top-level folder left out of the names while every entry is in it,
+	// as for `tar c app/`; root is its own entry, if the stream has one
+	prefix  string
+	root    *sourceEntry
+	decided bool
+	files   map[string]*streamFile
+	entries int
+	count   int
+	size    int64
+}
+
+// zipSourceStream compresses a tar (optionally gzip-compressed) or ZIP stream of a source
+// folder into a ZIP archive, like zipFolderTo does for a folder, entry by entry as the
+// stream is read
+// A stream holding a single top-level folder and nothing else is packaged as the contents
+// of that folder
+// Returns the archive with the number of files and their size
+func zipSourceStream(r io.Reader, opts ZipOptions) ([]byte, int, int64, error) {
+	source, err := openSourceStream(r)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	z := &streamZipper{buf: new(bytes.Buffer), opts: opts, files: map[string]*streamFile{}}
+	z.zw = newContentZipWriter(z.buf, opts.Compression.level())
+	for {
+		if opts.Context != nil {
+			if err := opts.Context.Err(); err != nil {
+				return nil, 0, 0, err
+			}
+		}
+		entry, err := source.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		if err := z.add(entry); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+	if z.entries == 0 && z.root == nil {
+		return nil, 0, 0, fmt.Errorf("source stream is not a tar or ZIP archive, or holds no files")
+	}
+	if z.count == 0 {
+		return nil, 0, 0, fmt.Errorf("no files found in source stream")
+	}
+	if opts.Progress != nil {
+		opts.Progress("complete", 1.0)
+	}
+	if err := z.zw.Close(); err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to close ZIP writer: %w", err)
+	}
+	return z.buf.Bytes(), z.count, z.size, nil
+}
+
+// add writes an entry of the stream to the ZIP
+func (z *streamZipper) add(entry *sourceEntry) error {
+	if entry.name == "" {
+		return nil
+	}
+	if !z.decided {
+		z.decided = true
+		if top, _, nested := strings.Cut(entry.name, "/"); nested || entry.dir {
+			z.prefix = top + "/"
+			if !nested {
+				z.root = entry
+				return nil
+			}
+		}
+	}
+	name := entry.name
+	if z.prefix != "" {
+		switch {
+		case strings.HasPrefix(name, z.prefix):
+			name = strings.TrimPrefix(name, z.prefix)
+		case entry.dir && name+"/" == z.prefix:
+			return nil
+		default:
+			// Not a single folder after all: its name is put back on the entries written
+			if err := z.keepPrefix(); err != nil {
+				return err
+			}
+		}
+	}
+	if IsExcluded(name, z.opts.Exclude) {
+		return nil
+	}
+	z.entries++
+
+	header := z.header(name, entry)
+	if entry.dir {
+		if _, err := z.zw.CreateHeader(header); err != nil {
+			return fmt.Errorf("failed to create ZIP entry: %w", err)
+		}
+		return nil
+	}
+
+	if z.opts.Progress != nil {
+		z.opts.Progress(name, 0)
+	}
+	fileStart := time.Now()
+	if z.opts.Compression.storeName(name) {
+		header.Method = zip.Store
+	}
+	// Creating the entry completes the one before it, which a hard link may refer to
+	writer, err := z.zw.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("failed to create ZIP entry: %w", err)
+	}
+	if err := z.zw.Flush(); err != nil {
+		return fmt.Errorf("failed to create ZIP entry: %w", err)
+	}
+	data := entry.data
+	if entry.link != "" {
+		if data, err = z.linked(entry.link); err != nil {
+			return err
+		}
+	}
+	z.files[entry.name] = &streamFile{header: header, offset: z.buf.Len()}
+	if len(z.opts.Variables) > 0 && IsTemplateFile(name) {
+		// Scripts are small, so they are expanded in memory
+		content, err := io.ReadAll(data)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		if content, err = ExpandTemplate(content, z.opts.Variables); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		data = bytes.NewReader(content)
+	}
+
+	written, err := io.Copy(writer, &progressReader{r: data, onRead: func(read int64) {
+		if z.opts.Compressed != nil {
+			z.opts.Compressed(z.size+read, 0)
+		}
+	}})
+	if err != nil {
+		return fmt.Errorf("failed to write %s to ZIP: %w", name, err)
+	}
+	z.opts.Tracer.RecordFile("compress", name, fileStart, written)
+	z.count++
+	z.size += written
+	return nil
+}
+
+// header returns the ZIP header of an entry of the stream named name in the package
+func (z *streamZipper) header(name string, entry *sourceEntry) *zip.FileHeader {
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: entry.modTime}
+	switch {
+	case entry.dir:
+		header.Name += "/"
+		header.Method = zip.Store
+		header.SetMode(normalDirMode)
+	case entry.exec:
+		header.SetMode(normalExecMode)
+	default:
+		header.SetMode(normalFileMode)
+	}
+	if !z.opts.ModTime.IsZero() {
+		header.Modified = z.opts.ModTime
+	}
+	if header.Modified.IsZero() {
+		header.Modified = time.Now()
+	}
+	return header
+}
+
+// linked returns the content of the file a hard link refers to, read back from the ZIP
+// The ZIP entry of the file is complete once the entry of the link has been created
+func (z *streamZipper) linked(name string) (io.Reader, error) {
+	file, ok := z.files[name]
+	if !ok {
+		return nil, fmt.Errorf("hard link to a file not before it in the source stream: %s", name)
+	}
+	// Later writes to the buffer do not change the bytes already in it
+	compressed := bytes.NewReader(z.buf.Bytes()[file.offset : file.offset+int(file.header.CompressedSize64)])
+	if file.header.Method == zip.Store {
+		return compressed, nil
+	}
+	return flate.NewReader(compressed), nil
+}
+
+// keepPrefix rewrites the entries written so far with the top-level folder in their
+// names, after an entry outside of it showed the stream holds more than that folder
+func (z *streamZipper) keepPrefix() error {
+	prefix := z.prefix
+	z.prefix = ""
+	if err := z.zw.Close(); err != nil {
+		return fmt.Errorf("failed to close ZIP writer: %w", err)
+	}
+	written := z.buf.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(written), int64(len(written)))
+	if err != nil {
+		return fmt.Errorf("failed to read ZIP: %w", err)
+	}
+
+	z.buf = bytes.NewBuffer(make([]byte, 0, len(written)))
+	z.zw = newContentZipWriter(z.buf, z.opts.Compression.level())
+	if z.root != nil {
+		z.entries++
+		if _, err := z.zw.CreateHeader(z.header(strings.TrimSuffix(prefix, "/"), z.root)); err != nil {
+			return fmt.Errorf("failed to create ZIP entry: %w", err)
+		}
+	}
+	for _, f := range zr.File {
+		header := f.FileHeader
+		header.Name = prefix + header.Name
+		raw, err := f.OpenRaw()
+		if err != nil {
+			return fmt.Errorf("failed to read ZIP: %w", err)
+		}
+		writer, err := z.zw.CreateRaw(&header)
+		if err != nil {
+			return fmt.Errorf("failed to create ZIP entry: %w", err)
+		}
+		if err := z.zw.Flush(); err != nil {
+			return fmt.Errorf("failed to create ZIP entry: %w", err)
+		}
+		if file, ok := z.files[header.Name]; ok {
+			file.header, file.offset = &header, z.buf.Len()
+		}
+		if _, err := io.Copy(writer, raw); err != nil {
+			return fmt.Errorf("failed to write %s to ZIP: %w", header.Name, err)
+		}
+	}
+	return nil
+}
+
+// inspectStreamSetup returns the MSI and MSP metadata of the setup file in the content
+// ZIP of a source stream, which is all there is of the setup file
+func inspectStreamSetup(zipData []byte, setupFile string, log *slog.Logger) (*MsiInfo, *MspInfo, error) {
+	zr, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read ZIP: %w", err)
+	}
+	var setup *zip.File
+	for _, f := range zr.File {
+		if f.Name == filepath.ToSlash(setupFile) {
+			setup = f
+			break
+		}
+	}
+	if setup == nil {
+		return nil, nil, fmt.Errorf("setup file not found in source stream: %s", setupFile)
+	}
+	if !IsMsiFile(setupFile) && !IsMspFile(setupFile) {
+		return nil, nil, nil
+	}
+
+	rc, err := setup.Open()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read setup file: %w", err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read setup file: %w", err)
+	}
+	if IsMspFile(setupFile) {
+		msp, err := readMspInfo(bytes.NewReader(data))
+		if err != nil {
+			// Log warning but continue - MSP info is optional
+			log.Warn("could not extract MSP metadata", "error", err)
+			return nil, nil, nil
+		}
+		return nil, msp, nil
+	}
+	msi, err := readMsiInfo(bytes.NewReader(data))
+	if err != nil {
+		// Log warning but continue - MSI info is optional
+		log.Warn("could not extract MSI metadata", "error", err)
+		return nil, nil, nil
+	}
+	return msi, nil, nil
+}
+
+// validateSourceStream checks the options of a run packaging Options.SourceStream, which
+// is read once, front to back, and never unpacked: steps that need the source folder or
+// the setup file on disk cannot be run
+func validateSourceStream(setupFile, outputPath string, opts Options) error {
+	switch {
+	case outputPath == "" && opts.OutputWriter == nil:
+		return fmt.Errorf("output path cannot be empty")
+	case !IsSetupFileType(setupFile):
+		return fmt.Errorf("unsupported setup file type: %s (supported: %s)", strings.ToLower(filepath.Ext(setupFile)), strings.Join(SetupFileTypes, ", "))
+	case opts.CheckpointRoot != "":
+		return fmt.Errorf("source streams cannot be resumed")
+	case opts.LowMemory:
+		return fmt.Errorf("low-memory runs use temporary files and cannot read a source stream")
+	case opts.Previous != nil:
+		return fmt.Errorf("source streams cannot reuse a previous package")
+	case opts.RequireSigned || opts.Scan != nil:
+		return fmt.Errorf("the setup file of a source stream cannot be checked for a signature or scanned")
+	case opts.Requirements != nil || opts.Provenance != nil:
+		return fmt.Errorf("requirement rules and provenance need the source folder and cannot be combined with a source stream")
+	case IsMsixSetupFile(setupFile):
+		return fmt.Errorf("MSIX setup files cannot be packaged from a source stream")
+	}
+	if err := ValidateExcludePatterns(opts.Exclude); err != nil {
+		return err
+	}
+	if IsExcluded(setupFile, opts.Exclude) {
+		return fmt.Errorf("setup file %s is excluded", setupFile)
+	}
+	return nil
+}
+
+// validateStreamOptions checks that a package written to Options.OutputWriter needs no
+// files next to it: manifests, license records, MSIX detection scripts and checkpoints
+// are named after the package file
+func validateStreamOptions(setupFile string, opts Options) error {
+	if opts.OutputWriter == nil {
+		return nil
+	}
+	switch {
+	case opts.CheckpointRoot != "":
+		return fmt.Errorf("streamed packages cannot be resumed")
+	case opts.Manifest:
+		return fmt.Errorf("manifests are written next to the package and cannot be combined with a streamed package")
+	case !opts.License.Empty():
+		return fmt.Errorf("license records are written next to the package and cannot be combined with a streamed package")
+	case opts.Requirements != nil:
+		return fmt.Errorf("requirement rules are written next to the package and cannot be combined with a streamed package")
+	case opts.StagingRoot != "" || opts.VerifyOutput:
+		return fmt.Errorf("streamed packages cannot be staged or verified")
+	case IsMsixSetupFile(setupFile):
+		return fmt.Errorf("MSIX setup files need a detection script next to the package and cannot be streamed")
+	}
+	return nil
+}
+
+// packageFilePath returns the path of the package file in outputPath, or only its name
+// when the package is written to Options.OutputWriter
+func packageFilePath(outputPath, setupFile string, opts Options) string {
+	if opts.OutputWriter != nil {
+		return outputFileName(setupFile, opts)
+	}
+	return filepath.Join(outputPath, outputFileName(setupFile, opts))
+}
+
+// writeStream copies the package to Options.OutputWriter
+func writeStream(w io.Writer, content io.ReaderAt, size int64) (outputWrite, error) {
+	if _, err := io.Copy(w, io.NewSectionReader(content, 0, size)); err != nil {
+		return outputWrite{attempts: 1}, fmt.Errorf("failed to write package stream: %w", err)
+	}
+	return outputWrite{attempts: 1}, nil
+}
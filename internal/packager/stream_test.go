@@ -0,0 +1,309 @@
+package packager
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tarStream builds a tar stream of the given headers, with files holding their names
+func tarStream(t *testing.T, headers ...*tar.Header) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range headers {
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size = int64(len(hdr.Name))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("Failed to write tar header: %v", err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			tw.Write([]byte(hdr.Name))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// zipEntries returns the content and mode of the entries of a ZIP archive by name
+func zipEntries(t *testing.T, data []byte) map[string]*zip.File {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Failed to open ZIP: %v", err)
+	}
+	entries := map[string]*zip.File{}
+	for _, f := range zr.File {
+		entries[f.Name] = f
+	}
+	return entries
+}
+
+// zipFileContent returns the content of a ZIP entry
+func zipFileContent(t *testing.T, f *zip.File) string {
+	t.Helper()
+	rc, err := f.Open()
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", f.Name, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", f.Name, err)
+	}
+	return string(data)
+}
+
+func TestZipSourceStream(t *testing.T) {
+	appTar := tarStream(t,
+		&tar.Header{Name: "app/", Typeflag: tar.TypeDir, Mode: 0755},
+		&tar.Header{Name: "app/setup.exe", Typeflag: tar.TypeReg, Mode: 0644},
+		&tar.Header{Name: "app/bin/run.sh", Typeflag: tar.TypeReg, Mode: 0755},
+		&tar.Header{Name: "app/bin/copy.sh", Typeflag: tar.TypeLink, Linkname: "app/bin/run.sh", Mode: 0755},
+	)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(appTar)
+	zw.Close()
+	// Written to a buffer, the entries have data descriptors as when zip writes to a pipe
+	var zipped bytes.Buffer
+	archive := zip.NewWriter(&zipped)
+	w, _ := archive.Create("setup.exe")
+	w.Write([]byte("app/setup.exe"))
+	w, _ = archive.Create("bin/run.sh")
+	w.Write([]byte("app/bin/run.sh"))
+	// Stored entries of known size, as zip writes to a file
+	stored := []byte("app/bin/copy.sh")
+	w, _ = archive.CreateRaw(&zip.FileHeader{Name: "bin/copy.sh", Method: zip.Store, CRC32: crc32.ChecksumIEEE(stored),
+		CompressedSize64: uint64(len(stored)), UncompressedSize64: uint64(len(stored))})
+	w.Write(stored)
+	archive.Close()
+
+	tests := map[string]struct {
+		stream []byte
+		// exec reports whether the stream records the exec bit of run.sh
+		exec bool
+	}{
+		"tar with top folder": {appTar, true},
+		"gzip tar":            {gz.Bytes(), true},
+		"flat tar": {tarStream(t,
+			&tar.Header{Name: "./setup.exe", Typeflag: tar.TypeReg, Mode: 0644},
+			&tar.Header{Name: "./bin/run.sh", Typeflag: tar.TypeReg, Mode: 0755},
+			&tar.Header{Name: "./bin/copy.sh", Typeflag: tar.TypeReg, Mode: 0644},
+		), true},
+		"zip": {zipped.Bytes(), false},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, files, size, err := zipSourceStream(bytes.NewReader(tt.stream), ZipOptions{})
+			if err != nil {
+				t.Fatalf("zipSourceStream() error = %v", err)
+			}
+			if files != 3 {
+				t.Errorf("zipSourceStream() files = %d, want 3", files)
+			}
+			entries := zipEntries(t, data)
+			var wantSize int64
+			for _, name := range []string{"setup.exe", "bin/run.sh", "bin/copy.sh"} {
+				f, ok := entries[name]
+				if !ok {
+					t.Fatalf("%s missing from the ZIP, got %v", name, entries)
+				}
+				content := zipFileContent(t, f)
+				wantSize += int64(len(content))
+				// Files hold their stream names; the flat tar's copy.sh holds its own
+				if name != "bin/copy.sh" && !strings.HasSuffix(content, name) {
+					t.Errorf("%s = %q, want its content", name, content)
+				}
+			}
+			if size != wantSize {
+				t.Errorf("zipSourceStream() size = %d, want %d", size, wantSize)
+			}
+			if got := zipFileContent(t, entries["bin/copy.sh"]); strings.HasPrefix(name, "tar") && got != "app/bin/run.sh" {
+				t.Errorf("hard link bin/copy.sh = %q, want the content of bin/run.sh", got)
+			}
+			if mode := entries["bin/run.sh"].Mode().Perm(); tt.exec && mode != 0755 {
+				t.Errorf("bin/run.sh mode = %v, want 0755", mode)
+			}
+		})
+	}
+}
+
+func TestZipSourceStreamKeepsTopFolder(t *testing.T) {
+	// A second top-level entry after the folder's files puts the folder back in their names
+	stream := tarStream(t,
+		&tar.Header{Name: "app/", Typeflag: tar.TypeDir, Mode: 0755},
+		&tar.Header{Name: "app/setup.exe", Typeflag: tar.TypeReg, Mode: 0644},
+		&tar.Header{Name: "readme.txt", Typeflag: tar.TypeReg, Mode: 0644},
+		&tar.Header{Name: "app/copy.exe", Typeflag: tar.TypeLink, Linkname: "app/setup.exe", Mode: 0644},
+	)
+	data, files, _, err := zipSourceStream(bytes.NewReader(stream), ZipOptions{})
+	if err != nil {
+		t.Fatalf("zipSourceStream() error = %v", err)
+	}
+	if files != 3 {
+		t.Errorf("zipSourceStream() files = %d, want 3", files)
+	}
+	entries := zipEntries(t, data)
+	for _, name := range []string{"app/", "app/setup.exe", "readme.txt", "app/copy.exe"} {
+		if _, ok := entries[name]; !ok {
+			t.Fatalf("%s missing from the ZIP, got %v", name, entries)
+		}
+	}
+	if got := zipFileContent(t, entries["app/copy.exe"]); got != "app/setup.exe" {
+		t.Errorf("hard link app/copy.exe = %q, want the content of app/setup.exe", got)
+	}
+}
+
+func TestZipSourceStreamRejects(t *testing.T) {
+	var stored bytes.Buffer
+	archive := zip.NewWriter(&stored)
+	w, _ := archive.CreateHeader(&zip.FileHeader{Name: "setup.exe", Method: zip.Store})
+	w.Write([]byte("installer"))
+	archive.Close()
+
+	tests := map[string]struct {
+		stream  []byte
+		wantErr string
+	}{
+		"empty":     {nil, "empty"},
+		"not a tar": {[]byte("just some text that is not an archive"), "tar"},
+		"symlink":   {tarStream(t, &tar.Header{Name: "passwd", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}), "symbolic link"},
+		"dangling hard link": {tarStream(t,
+			&tar.Header{Name: "copy.exe", Typeflag: tar.TypeLink, Linkname: "setup.exe"},
+		), "hard link"},
+		"stored zip entry of unknown size": {stored.Bytes(), "unknown size"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, _, _, err := zipSourceStream(bytes.NewReader(tt.stream), ZipOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("zipSourceStream() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestZipSourceStreamTraversal(t *testing.T) {
+	// Leading ../ is dropped rather than placing the entry outside the source folder
+	stream := tarStream(t, &tar.Header{Name: "../evil.exe", Typeflag: tar.TypeReg, Mode: 0644})
+	data, _, _, err := zipSourceStream(bytes.NewReader(stream), ZipOptions{})
+	if err != nil {
+		t.Fatalf("zipSourceStream() error = %v", err)
+	}
+	if _, ok := zipEntries(t, data)["evil.exe"]; !ok {
+		t.Error("evil.exe missing from the root of the ZIP")
+	}
+}
+
+func TestPackageSourceStream(t *testing.T) {
+	stream := tarStream(t,
+		&tar.Header{Name: "app/", Typeflag: tar.TypeDir, Mode: 0755},
+		&tar.Header{Name: "app/setup.exe", Typeflag: tar.TypeReg, Mode: 0644},
+		&tar.Header{Name: "app/logs/debug.log", Typeflag: tar.TypeReg, Mode: 0644},
+	)
+	opts := Options{SourceStream: bytes.NewReader(stream), Exclude: []string{"*.log"}}
+	result, err := PackageWithOptions("", "setup.exe", t.TempDir(), opts, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	if result.FileCount != 1 || result.SourceSize != int64(len("app/setup.exe")) {
+		t.Errorf("result = %d files, %d bytes, want 1 file, %d bytes", result.FileCount, result.SourceSize, len("app/setup.exe"))
+	}
+	restored := restorePackage(t, result.OutputPath)
+	if _, err := os.Stat(filepath.Join(restored, "setup.exe")); err != nil {
+		t.Errorf("setup.exe missing from package: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(restored, "logs", "debug.log")); err == nil {
+		t.Error("excluded debug.log packaged")
+	}
+
+	// The setup file has to be in the stream
+	opts.SourceStream = bytes.NewReader(stream)
+	if _, err := PackageWithOptions("", "install.exe", t.TempDir(), opts, nil); err == nil || !strings.Contains(err.Error(), "not found in source stream") {
+		t.Errorf("PackageWithOptions() error = %v, want the setup file not found", err)
+	}
+	// Steps needing the source folder on disk are refused
+	for name, opts := range map[string]Options{
+		"low memory": {LowMemory: true},
+		"resumable":  {CheckpointRoot: t.TempDir()},
+		"signed":     {RequireSigned: true},
+	} {
+		opts.SourceStream = bytes.NewReader(stream)
+		if _, err := PackageWithOptions("", "setup.exe", t.TempDir(), opts, nil); err == nil {
+			t.Errorf("%s: PackageWithOptions() should fail", name)
+		}
+	}
+}
+
+func TestPackageToWriter(t *testing.T) {
+	sourceDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "setup.exe"), []byte("installer"), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+	opts := Options{Reproducible: &Reproducible{ModTime: time.Unix(1700000000, 0), Seed: []byte("seed")}}
+
+	fileResult, err := PackageWithOptions(sourceDir, "setup.exe", t.TempDir(), opts, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	want, err := os.ReadFile(fileResult.OutputPath)
+	if err != nil {
+		t.Fatalf("Failed to read package: %v", err)
+	}
+
+	for _, lowMemory := range []bool{false, true} {
+		var buf bytes.Buffer
+		opts := opts
+		opts.OutputWriter = &buf
+		opts.LowMemory = lowMemory
+		result, err := PackageWithOptions(sourceDir, "setup.exe", "", opts, nil)
+		if err != nil {
+			t.Fatalf("PackageWithOptions(LowMemory: %v) to writer error = %v", lowMemory, err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("streamed package (LowMemory: %v) differs from the package file", lowMemory)
+		}
+		if result.OutputPath != "setup.intunewin" || result.FinalSize != int64(len(want)) {
+			t.Errorf("result = %s, %d bytes, want setup.intunewin, %d bytes", result.OutputPath, result.FinalSize, len(want))
+		}
+	}
+}
+
+func TestPackageToWriterRejectsSideFiles(t *testing.T) {
+	sourceDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "setup.exe"), []byte("installer"), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+	tests := map[string]Options{
+		"manifest":     {Manifest: true},
+		"license":      {License: &License{Type: "freeware"}},
+		"requirements": {Requirements: &RequirementsOptions{}},
+		"provenance":   {Provenance: &ProvenanceOptions{}},
+		"resumable":    {CheckpointRoot: t.TempDir()},
+		"verify":       {VerifyOutput: true},
+	}
+	for name, opts := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			opts.OutputWriter = &buf
+			if _, err := PackageWithOptions(sourceDir, "setup.exe", "", opts, nil); err == nil {
+				t.Error("PackageWithOptions() should fail")
+			}
+			if buf.Len() != 0 {
+				t.Error("package written despite the error")
+			}
+		})
+	}
+}
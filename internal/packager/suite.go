@@ -0,0 +1,281 @@
+package packager
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// SuiteScriptName is the script added to the package content to chain the MSIs of a suite
+const SuiteScriptName = "Install-Suite.ps1"
+
+// Relations of suite members to the primary MSI
+const (
+	SuiteRelationPrimary     = "primary"
+	SuiteRelationUpgradeCode = "upgrade code"
+	SuiteRelationProductName = "product name"
+	SuiteRelationFileName    = "file name"
+)
+
+// languageTagPattern finds a language tag such as de-DE or pt-br in a file name
+var languageTagPattern = regexp.MustCompile(`(?i)(?:^|[^a-z])([a-z]{2}-[a-z]{2})(?:[^a-z]|$)`)
+
+// msiLanguages maps the LCIDs of common language packs (ProductLanguage) to language tags
+var msiLanguages = map[string]string{
+	"1025": "ar-SA", "1028": "zh-TW", "1029": "cs-CZ", "1030": "da-DK", "1031": "de-DE",
+	"1032": "el-GR", "1033": "en-US", "1035": "fi-FI", "1036": "fr-FR", "1037": "he-IL",
+	"1038": "hu-HU", "1040": "it-IT", "1041": "ja-JP", "1042": "ko-KR", "1043": "nl-NL",
+	"1044": "nb-NO", "1045": "pl-PL", "1046": "pt-BR", "1049": "ru-RU", "1053": "sv-SE",
+	"1055": "tr-TR", "2052": "zh-CN", "2057": "en-GB", "2070": "pt-PT", "3082": "es-ES",
+	"1034": "es-ES", "3084": "fr-CA",
+}
+
+// SuiteMember is an MSI of a suite, in install order
+type SuiteMember struct {
+	// File is the path of the MSI relative to the source folder
+	File           string `json:"file"`
+	ProductName    string `json:"productName"`
+	ProductVersion string `json:"productVersion"`
+	ProductCode    string `json:"productCode"`
+	UpgradeCode    string `json:"upgradeCode"`
+	// Language is a language tag (de-DE) or the ProductLanguage LCID when it is not known
+	Language string `json:"language,omitempty"`
+	// Relation is how the MSI was recognized as part of the suite (primary for the setup file)
+	Relation string `json:"relation"`
+}
+
+// MsiSuite is a primary MSI with the language packs or add-on MSIs shipped next to it,
+// installed in order by SuiteScriptName
+type MsiSuite struct {
+	Members          []SuiteMember `json:"members"`
+	Script           string        `json:"script"`
+	InstallCommand   string        `json:"installCommand"`
+	UninstallCommand string        `json:"uninstallCommand"`
+}
+
+// String summarizes the suite, e.g. "Contoso CAD + 2 MSIs (de-DE, fr-FR)"
+func (s *MsiSuite) String() string {
+	var languages []string
+	for _, m := range s.Members[1:] {
+		languages = append(languages, firstNonEmptyString(m.Language, filepath.Base(m.File)))
+	}
+	return fmt.Sprintf("%s + %d MSIs (%s)", firstNonEmptyString(s.Members[0].ProductName, s.Members[0].File), len(s.Members)-1, strings.Join(languages, ", "))
+}
+
+// DetectMsiSuite finds the MSIs of the source folder that belong with the MSI setup file:
+// MSIs sharing its UpgradeCode, whose product name extends its product name, or whose
+// file name extends its file name (CADPro.msi, CADPro_de-DE.msi)
+// Returns nil when the setup file is not an MSI or nothing belongs with it. Per-architecture
+// subfolders (see FindArchSources) and excluded paths are not searched
+func DetectMsiSuite(sourcePath, setupFile string, exclude []string) (*MsiSuite, error) {
+	if !IsMsiFile(setupFile) {
+		return nil, nil
+	}
+	primary, err := readSuiteMember(sourcePath, setupFile)
+	if err != nil {
+		return nil, err
+	}
+	primary.Relation = SuiteRelationPrimary
+	primaryBase := strings.ToLower(strings.TrimSuffix(filepath.Base(setupFile), filepath.Ext(setupFile)))
+
+	var members []SuiteMember
+	err = filepath.WalkDir(sourcePath, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(sourcePath, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if IsExcluded(rel, exclude) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if _, isArch := ArchitectureFromDir(d.Name()); isArch {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !IsMsiFile(rel) || filepath.Clean(rel) == filepath.Clean(setupFile) {
+			return nil
+		}
+
+		member, err := readSuiteMember(sourcePath, rel)
+		if err != nil {
+			return nil // Not a readable MSI, it is packaged but not chained
+		}
+		if member.ProductCode != "" && strings.EqualFold(member.ProductCode, primary.ProductCode) {
+			return nil // Another copy of the same product
+		}
+		base := strings.ToLower(strings.TrimSuffix(d.Name(), filepath.Ext(d.Name())))
+		switch {
+		case primary.UpgradeCode != "" && strings.EqualFold(member.UpgradeCode, primary.UpgradeCode):
+			member.Relation = SuiteRelationUpgradeCode
+		case primary.ProductName != "" && len(member.ProductName) > len(primary.ProductName) &&
+			strings.HasPrefix(strings.ToLower(member.ProductName), strings.ToLower(primary.ProductName)):
+			member.Relation = SuiteRelationProductName
+		case len(base) > len(primaryBase) && strings.HasPrefix(base, primaryBase) && strings.ContainsRune("-_. ", rune(base[len(primaryBase)])):
+			member.Relation = SuiteRelationFileName
+		default:
+			return nil
+		}
+		members = append(members, *member)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan for suite MSIs: %w", err)
+	}
+	if len(members) == 0 {
+		return nil, nil
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].File < members[j].File })
+
+	suite := &MsiSuite{
+		Members:          append([]SuiteMember{*primary}, members...),
+		Script:           SuiteScriptName,
+		InstallCommand:   fmt.Sprintf(`powershell.exe -NoProfile -ExecutionPolicy Bypass -File .\%s`, SuiteScriptName),
+		UninstallCommand: fmt.Sprintf(`powershell.exe -NoProfile -ExecutionPolicy Bypass -File .\%s -Uninstall`, SuiteScriptName),
+	}
+	return suite, nil
+}
+
+// readSuiteMember reads the identity and language of an MSI of the source folder
+func readSuiteMember(sourcePath, rel string) (*SuiteMember, error) {
+	file, err := os.Open(filepath.Join(sourcePath, rel))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MSI file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := readMsiInfo(file)
+	if err != nil {
+		return nil, err
+	}
+	member := &SuiteMember{
+		File:           filepath.ToSlash(rel),
+		ProductName:    info.ProductName,
+		ProductVersion: info.ProductVersion,
+		ProductCode:    info.ProductCode,
+		UpgradeCode:    info.UpgradeCode,
+	}
+	if match := languageTagPattern.FindStringSubmatch(filepath.Base(rel)); match != nil {
+		lang, region, _ := strings.Cut(match[1], "-")
+		member.Language = strings.ToLower(lang) + "-" + strings.ToUpper(region)
+	} else if db, err := openMsiDatabase(file); err == nil {
+		lcid := db.Property("ProductLanguage")
+		member.Language = firstNonEmptyString(msiLanguages[lcid], lcid)
+	}
+	return member, nil
+}
+
+// suiteScriptTemplate installs the MSIs of a suite in order, or removes them in reverse
+// order with -Uninstall, and returns the msiexec exit code Intune maps to a result
+const suiteScriptTemplate = `# Installs {{.Primary.ProductName}} {{.Primary.ProductVersion}} with its language packs and add-ons
+# Generated by LetsGoIntunePackager
+#   Install:   {{.InstallCommand}}
+#   Uninstall: {{.UninstallCommand}}
+# Exit codes: 0 on success, 3010 when a reboot is required, otherwise the msiexec
+# exit code of the first MSI that failed (later MSIs are not installed)
+param([switch]$Uninstall)
+
+$Suite = @(
+{{- range .Members}}
+    @{ File = {{ps .File}}; ProductCode = {{ps .ProductCode}}; Name = {{ps .ProductName}} }
+{{- end}}
+)
+
+if ($Uninstall) {
+    [array]::Reverse($Suite)
+}
+
+$reboot = $false
+foreach ($msi in $Suite) {
+    $log = Join-Path $env:TEMP ([IO.Path]::GetFileNameWithoutExtension($msi.File) + '.log')
+    if ($Uninstall) {
+        $arguments = "/x $($msi.ProductCode) /qn /norestart /l*v ""$log"""
+    } else {
+        $arguments = "/i ""$(Join-Path $PSScriptRoot $msi.File)"" /qn /norestart /l*v ""$log"""
+    }
+    Write-Output "msiexec.exe $arguments"
+    $process = Start-Process -FilePath msiexec.exe -ArgumentList $arguments -Wait -PassThru
+    $code = $process.ExitCode
+    if ($code -eq 3010 -or $code -eq 1641) {
+        $reboot = $true
+    } elseif ($Uninstall -and $code -eq 1605) {
+        Write-Output "$($msi.Name) is not installed"
+    } elseif ($code -ne 0) {
+        Write-Output "$($msi.File) failed with exit code $code, see $log"
+        exit $code
+    }
+}
+
+if ($reboot) {
+    exit 3010
+}
+exit 0
+`
+
+// GenerateSuiteScript creates the script that chains the MSIs of a suite silently
+func GenerateSuiteScript(suite *MsiSuite) ([]byte, error) {
+	if len(suite.Members) < 2 {
+		return nil, fmt.Errorf("a suite needs a primary MSI and at least one more MSI")
+	}
+	members := make([]SuiteMember, len(suite.Members))
+	for i, m := range suite.Members {
+		if m.ProductCode == "" {
+			return nil, fmt.Errorf("%s has no ProductCode", m.File)
+		}
+		m.File = strings.ReplaceAll(m.File, "/", `\`)
+		members[i] = m
+	}
+
+	tmpl, err := template.New("suite").Funcs(template.FuncMap{"ps": powershellQuote}).Parse(suiteScriptTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse suite script template: %w", err)
+	}
+	var buf strings.Builder
+	err = tmpl.Execute(&buf, map[string]any{
+		"Primary":          members[0],
+		"Members":          members,
+		"InstallCommand":   suite.InstallCommand,
+		"UninstallCommand": suite.UninstallCommand,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate suite script: %w", err)
+	}
+	return []byte(strings.ReplaceAll(buf.String(), "\n", "\r\n")), nil
+}
+
+// suiteContent detects the MSI suite of a run and returns the script to add to the
+// package content (nil suite when there is none or opts.NoMsiSuite is set)
+func suiteContent(sourcePath, setupFile string, opts Options, log *slog.Logger) (*MsiSuite, []ZipEntry, error) {
+	if opts.NoMsiSuite {
+		return nil, nil, nil
+	}
+	suite, err := DetectMsiSuite(sourcePath, setupFile, opts.Exclude)
+	if err != nil || suite == nil {
+		if err != nil {
+			log.Warn("could not detect MSI suite", "error", err)
+		}
+		return nil, nil, nil
+	}
+	if _, err := os.Stat(filepath.Join(sourcePath, SuiteScriptName)); err == nil {
+		return nil, nil, fmt.Errorf("%s in the source folder would be replaced by the suite install script (use --no-msi-suite to package it as is)", SuiteScriptName)
+	}
+	script, err := GenerateSuiteScript(suite)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, m := range suite.Members[1:] {
+		log.Info("MSI chained after the setup file", "file", m.File, "product", m.ProductName, "language", m.Language, "relation", m.Relation)
+	}
+	return suite, []ZipEntry{{Name: SuiteScriptName, Data: script}}, nil
+}
@@ -0,0 +1,190 @@
+package packager
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/msitest"
+)
+
+const (
+	cadUpgradeCode = "{0A1B2C3D-4E5F-4061-8293-A4B5C6D7E8F9}"
+	cadProductCode = "{11111111-2222-4333-8444-555555555555}"
+)
+
+// suiteMsi is a synthetic MSI with the identity properties suite detection reads
+func suiteMsi(name, productCode, upgradeCode, language string) msitest.MSI {
+	return msitest.MSI{Properties: []msitest.Property{
+		{Name: "ProductCode", Value: productCode},
+		{Name: "ProductLanguage", Value: language},
+		{Name: "ProductName", Value: name},
+		{Name: "ProductVersion", Value: "2025.1"},
+		{Name: "UpgradeCode", Value: upgradeCode},
+	}}
+}
+
+// createSuiteSource writes a CAD suite: the primary MSI, language packs related by
+// UpgradeCode, product name and file name, and MSIs that do not belong with it
+func createSuiteSource(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"CADPro.msi":       string(msitest.Build(suiteMsi("Contoso CAD", cadProductCode, cadUpgradeCode, "1033"))),
+		"lang/de.msi":      string(msitest.Build(suiteMsi("Contoso CAD Sprachpaket", "{22222222-2222-4333-8444-555555555555}", cadUpgradeCode, "1031"))),
+		"lang/fr.msi":      string(msitest.Build(suiteMsi("Contoso CAD Language Pack - French", "{33333333-2222-4333-8444-555555555555}", "{99999999-0000-4000-8000-000000000000}", "1036"))),
+		"CADPro_ja-jp.msi": string(msitest.Build(suiteMsi("CAD 日本語", "{44444444-2222-4333-8444-555555555555}", "{88888888-0000-4000-8000-000000000000}", "1041"))),
+		// Unrelated runtime, a copy of the primary and an installer of another architecture
+		"vcredist.msi":    string(msitest.Build(suiteMsi("Visual C++ Runtime", "{55555555-2222-4333-8444-555555555555}", "{77777777-0000-4000-8000-000000000000}", "1033"))),
+		"CADPro-copy.msi": string(msitest.Build(suiteMsi("Contoso CAD", cadProductCode, cadUpgradeCode, "1033"))),
+		"x86/CADPro.msi":  string(msitest.Build(suiteMsi("Contoso CAD (x86)", "{66666666-2222-4333-8444-555555555555}", cadUpgradeCode, "1033"))),
+	})
+	return dir
+}
+
+func TestDetectMsiSuite(t *testing.T) {
+	dir := createSuiteSource(t)
+
+	suite, err := DetectMsiSuite(dir, "CADPro.msi", nil)
+	if err != nil {
+		t.Fatalf("DetectMsiSuite() error = %v", err)
+	}
+	if suite == nil {
+		t.Fatal("DetectMsiSuite() found no suite")
+	}
+
+	want := []struct {
+		file, language, relation string
+	}{
+		{"CADPro.msi", "en-US", SuiteRelationPrimary},
+		{"CADPro_ja-jp.msi", "ja-JP", SuiteRelationFileName},
+		{"lang/de.msi", "de-DE", SuiteRelationUpgradeCode},
+		{"lang/fr.msi", "fr-FR", SuiteRelationProductName},
+	}
+	if len(suite.Members) != len(want) {
+		t.Fatalf("Members = %+v, want %d", suite.Members, len(want))
+	}
+	for i, w := range want {
+		m := suite.Members[i]
+		if m.File != w.file || m.Language != w.language || m.Relation != w.relation {
+			t.Errorf("Member %d = %s %s %s, want %s %s %s", i, m.File, m.Language, m.Relation, w.file, w.language, w.relation)
+		}
+	}
+	if got := suite.String(); got != "Contoso CAD + 3 MSIs (ja-JP, de-DE, fr-FR)" {
+		t.Errorf("String() = %q", got)
+	}
+
+	suite, err = DetectMsiSuite(dir, "CADPro.msi", []string{"lang"})
+	if err != nil {
+		t.Fatalf("DetectMsiSuite() error = %v", err)
+	}
+	if len(suite.Members) != 2 {
+		t.Errorf("Members with lang/ excluded = %+v", suite.Members)
+	}
+
+	suite, err = DetectMsiSuite(dir, "vcredist.msi", nil)
+	if err != nil || suite != nil {
+		t.Errorf("DetectMsiSuite(vcredist.msi) = %+v, %v, want no suite", suite, err)
+	}
+}
+
+func TestGenerateSuiteScript(t *testing.T) {
+	suite, err := DetectMsiSuite(createSuiteSource(t), "CADPro.msi", nil)
+	if err != nil {
+		t.Fatalf("DetectMsiSuite() error = %v", err)
+	}
+	script, err := GenerateSuiteScript(suite)
+	if err != nil {
+		t.Fatalf("GenerateSuiteScript() error = %v", err)
+	}
+	text := string(script)
+	for _, want := range []string{
+		"param([switch]$Uninstall)",
+		"@{ File = 'CADPro.msi'; ProductCode = '" + cadProductCode + "'; Name = 'Contoso CAD' }",
+		`@{ File = 'lang\de.msi';`,
+		"[array]::Reverse($Suite)",
+		"exit 3010",
+		"\r\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Script is missing %q", want)
+		}
+	}
+	if strings.Index(text, "CADPro.msi'") > strings.Index(text, `lang\de.msi`) {
+		t.Error("Primary MSI is not installed first")
+	}
+
+	suite.Members[1].ProductCode = ""
+	if _, err := GenerateSuiteScript(suite); err == nil {
+		t.Error("Expected error for a member without ProductCode")
+	}
+}
+
+func TestPackageMsiSuite(t *testing.T) {
+	reproducible := &Reproducible{ModTime: time.Unix(1700000000, 0), Seed: []byte("seed")}
+	for _, lowMemory := range []bool{false, true} {
+		source := createSuiteSource(t)
+		opts := Options{Manifest: true, LowMemory: lowMemory, Reproducible: reproducible}
+		result, err := PackageWithOptions(source, "CADPro.msi", t.TempDir(), opts, nil)
+		if err != nil {
+			t.Fatalf("Package() error = %v", err)
+		}
+		if result.MsiSuite == nil || len(result.MsiSuite.Members) != 4 {
+			t.Fatalf("MsiSuite = %+v", result.MsiSuite)
+		}
+
+		data, err := os.ReadFile(result.ManifestPath)
+		if err != nil {
+			t.Fatalf("Failed to read manifest: %v", err)
+		}
+		var manifest Manifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			t.Fatalf("Failed to parse manifest: %v", err)
+		}
+		if manifest.MsiSuite == nil || manifest.MsiSuite.Members[3].File != "lang/fr.msi" {
+			t.Errorf("Manifest suite = %+v", manifest.MsiSuite)
+		}
+		var packed bool
+		for _, f := range manifest.Files {
+			packed = packed || f.Path == SuiteScriptName
+		}
+		if !packed {
+			t.Errorf("%s not in the package content (low memory %v)", SuiteScriptName, lowMemory)
+		}
+
+		// The digest of the source with its setup file matches the package
+		digest, err := SetupFolderDigest(source, "CADPro.msi", opts)
+		if err != nil {
+			t.Fatalf("SetupFolderDigest() error = %v", err)
+		}
+		recorded, err := PackageDigest(result.OutputPath)
+		if err != nil {
+			t.Fatalf("PackageDigest() error = %v", err)
+		}
+		if !bytes.Equal(digest.FileDigest, recorded.FileDigest) {
+			t.Errorf("SetupFolderDigest() = %s, package records %s", digest.Base64(), recorded.Base64())
+		}
+	}
+
+	// Without the suite script the MSIs are packaged as they are
+	result, err := PackageWithOptions(createSuiteSource(t), "CADPro.msi", t.TempDir(), Options{NoMsiSuite: true}, nil)
+	if err != nil {
+		t.Fatalf("Package() error = %v", err)
+	}
+	if result.MsiSuite != nil {
+		t.Errorf("MsiSuite = %+v with NoMsiSuite", result.MsiSuite)
+	}
+
+	// A script of the same name in the source is not replaced silently
+	source := createSuiteSource(t)
+	if err := os.WriteFile(filepath.Join(source, SuiteScriptName), []byte("# vendor script"), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+	if _, err := PackageWithOptions(source, "CADPro.msi", t.TempDir(), Options{}, nil); err == nil {
+		t.Error("Expected error for an existing " + SuiteScriptName)
+	}
+}
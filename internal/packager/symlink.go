@@ -0,0 +1,168 @@
+package packager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SymlinkPolicy decides how symbolic links and junctions in a source folder are packaged
+// Hard links are regular files and are always packaged with their content
+type SymlinkPolicy string
+
+const (
+	// SymlinkFollow packages the file or folder a link points to (the default)
+	// Links to nothing and links back to a folder they are in are skipped
+	SymlinkFollow SymlinkPolicy = "follow"
+	// SymlinkSkip leaves links out of the package
+	SymlinkSkip SymlinkPolicy = "skip"
+	// SymlinkError fails packaging when the source folder contains a link
+	SymlinkError SymlinkPolicy = "error"
+)
+
+// SymlinkPolicies lists the valid policies, the default first
+var SymlinkPolicies = []SymlinkPolicy{SymlinkFollow, SymlinkSkip, SymlinkError}
+
+// ParseSymlinkPolicy returns the policy named s, case-insensitively; empty is SymlinkFollow
+func ParseSymlinkPolicy(s string) (SymlinkPolicy, error) {
+	if s == "" {
+		return SymlinkFollow, nil
+	}
+	for _, policy := range SymlinkPolicies {
+		if strings.EqualFold(s, string(policy)) {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("invalid symlink policy %q (expected follow, skip or error)", s)
+}
+
+// SkippedLink is a symbolic link or junction left out of a package
+type SkippedLink struct {
+	// Path is the link relative to the source folder, with forward slashes
+	Path string
+	// Target is where the link points, empty when it cannot be read
+	Target string
+	// Reason is why the link was skipped
+	Reason string
+}
+
+func (l SkippedLink) String() string {
+	if l.Target == "" {
+		return fmt.Sprintf("%s (%s)", l.Path, l.Reason)
+	}
+	return fmt.Sprintf("%s -> %s (%s)", l.Path, l.Target, l.Reason)
+}
+
+// Reasons a link is skipped
+const (
+	skipReasonPolicy   = "symlink policy is skip"
+	skipReasonDangling = "target not found"
+	skipReasonLoop     = "points to a folder it is in"
+)
+
+// sourceVisitor is called by walkSource for every file and folder: rel is the path relative
+// to the source folder, path the path to open and info describes the file or folder, or
+// the target of a followed link
+type sourceVisitor func(rel, path string, info os.FileInfo) error
+
+// isLink reports whether info describes a symbolic link, or a junction or other reparse
+// point (reported as irregular on Windows)
+func isLink(info os.FileInfo) bool {
+	return info.Mode()&(os.ModeSymlink|os.ModeIrregular) != 0
+}
+
+// walkSource walks the source folder root in lexical order like filepath.Walk, leaving out
+// excluded paths and applying the symlink policy; the root itself is not visited
+// Returns the links that were skipped
+func walkSource(root string, exclude []string, policy SymlinkPolicy, visit sourceVisitor) ([]SkippedLink, error) {
+	if policy == "" {
+		policy = SymlinkFollow
+	}
+	rootInfo, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	w := &sourceWalker{exclude: exclude, policy: policy, visit: visit}
+	err = w.walkDir(root, "", []os.FileInfo{rootInfo})
+	return w.skipped, err
+}
+
+// sourceWalker holds the state of walkSource
+type sourceWalker struct {
+	exclude []string
+	policy  SymlinkPolicy
+	visit   sourceVisitor
+	skipped []SkippedLink
+}
+
+// walkDir visits the entries of dir, whose path relative to the root is rel
+// parents describe dir and the folders it is in, to detect link loops
+func (w *sourceWalker) walkDir(dir, rel string, parents []os.FileInfo) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		entryRel := filepath.Join(rel, entry.Name())
+		if IsExcluded(entryRel, w.exclude) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+		if isLink(info) {
+			if info, err = w.link(path, entryRel, parents); err != nil {
+				return err
+			}
+			if info == nil {
+				continue
+			}
+		}
+
+		if err := w.visit(entryRel, path, info); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if err := w.walkDir(path, entryRel, append(parents, info)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// link applies the symlink policy to the link at path, returning its target when the
+// link is followed and nil when it is skipped
+func (w *sourceWalker) link(path, rel string, parents []os.FileInfo) (os.FileInfo, error) {
+	target, _ := os.Readlink(path)
+	skip := func(reason string) (os.FileInfo, error) {
+		w.skipped = append(w.skipped, SkippedLink{Path: filepath.ToSlash(rel), Target: target, Reason: reason})
+		return nil, nil
+	}
+
+	switch w.policy {
+	case SymlinkSkip:
+		return skip(skipReasonPolicy)
+	case SymlinkError:
+		if target != "" {
+			return nil, fmt.Errorf("source contains a link: %s -> %s (symlink policy is error)", filepath.ToSlash(rel), target)
+		}
+		return nil, fmt.Errorf("source contains a link: %s (symlink policy is error)", filepath.ToSlash(rel))
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return skip(skipReasonDangling)
+	}
+	if info.IsDir() {
+		for _, parent := range parents {
+			if os.SameFile(info, parent) {
+				return skip(skipReasonLoop)
+			}
+		}
+	}
+	return info, nil
+}
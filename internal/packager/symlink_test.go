@@ -0,0 +1,159 @@
+package packager
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseSymlinkPolicy(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    SymlinkPolicy
+		wantErr bool
+	}{
+		{"", SymlinkFollow, false},
+		{"follow", SymlinkFollow, false},
+		{"Skip", SymlinkSkip, false},
+		{"ERROR", SymlinkError, false},
+		{"dereference", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSymlinkPolicy(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSymlinkPolicy(%q) = %q, %v", tt.value, got, err)
+		}
+	}
+}
+
+// linkedSource creates a source folder with a file link, a folder link, a dangling link
+// and a link back to the source folder; skips the test where links cannot be created
+func linkedSource(t *testing.T) string {
+	t.Helper()
+	source := t.TempDir()
+	outside := t.TempDir()
+	files := map[string]string{
+		"setup.exe":                            "installer",
+		filepath.Join(outside, "shared.dll"):   "library",
+		filepath.Join(outside, "lang", "en.x"): "strings",
+	}
+	for name, content := range files {
+		path := name
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(source, name)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	links := map[string]string{
+		"shared.dll": filepath.Join(outside, "shared.dll"),
+		"lang":       filepath.Join(outside, "lang"),
+		"missing":    filepath.Join(outside, "missing.dll"),
+		"self":       source,
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(source, name)); err != nil {
+			t.Skipf("Cannot create symbolic links: %v", err)
+		}
+	}
+	return source
+}
+
+func skippedPaths(links []SkippedLink) []string {
+	var paths []string
+	for _, link := range links {
+		paths = append(paths, link.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestPackageSymlinkFollow(t *testing.T) {
+	source := linkedSource(t)
+	restoreDir := t.TempDir()
+
+	result, err := PackageWithOptions(source, "setup.exe", t.TempDir(), Options{}, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	if result.FileCount != 3 {
+		t.Errorf("FileCount = %d, want 3", result.FileCount)
+	}
+	if got := strings.Join(skippedPaths(result.SkippedLinks), ","); got != "missing,self" {
+		t.Errorf("SkippedLinks = %v, want missing and self", result.SkippedLinks)
+	}
+
+	appInfo, err := ReadDetectionXML(result.OutputPath)
+	if err != nil {
+		t.Fatalf("ReadDetectionXML() error = %v", err)
+	}
+	encrypted, err := ReadEncryptedContent(result.OutputPath)
+	if err != nil {
+		t.Fatalf("ReadEncryptedContent() error = %v", err)
+	}
+	if _, err := RestoreContent(encrypted, appInfo.EncryptionInfo, restoreDir); err != nil {
+		t.Fatalf("RestoreContent() error = %v", err)
+	}
+	for name, want := range map[string]string{"shared.dll": "library", "lang/en.x": "strings"} {
+		data, err := os.ReadFile(filepath.Join(restoreDir, filepath.FromSlash(name)))
+		if err != nil || string(data) != want {
+			t.Errorf("Restored %s = %q, %v, want %q", name, data, err, want)
+		}
+	}
+}
+
+func TestPackageSymlinkSkip(t *testing.T) {
+	source := linkedSource(t)
+
+	result, err := PackageWithOptions(source, "setup.exe", t.TempDir(), Options{Symlinks: SymlinkSkip}, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	if result.FileCount != 1 {
+		t.Errorf("FileCount = %d, want 1", result.FileCount)
+	}
+	if got := strings.Join(skippedPaths(result.SkippedLinks), ","); got != "lang,missing,self,shared.dll" {
+		t.Errorf("SkippedLinks = %v, want all four links", result.SkippedLinks)
+	}
+
+	preview, err := PreviewPackage(source, "setup.exe", t.TempDir(), Options{Symlinks: SymlinkSkip})
+	if err != nil {
+		t.Fatalf("PreviewPackage() error = %v", err)
+	}
+	if len(preview.SkippedLinks) != 4 {
+		t.Errorf("Preview SkippedLinks = %v, want 4 links", preview.SkippedLinks)
+	}
+}
+
+func TestPackageSymlinkError(t *testing.T) {
+	source := linkedSource(t)
+
+	_, err := PackageWithOptions(source, "setup.exe", t.TempDir(), Options{Symlinks: SymlinkError}, nil)
+	if err == nil || !strings.Contains(err.Error(), "source contains a link") {
+		t.Errorf("PackageWithOptions() error = %v, want a link error", err)
+	}
+
+	_, err = PackageWithOptions(source, "setup.exe", t.TempDir(), Options{Symlinks: "dereference"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid symlink policy") {
+		t.Errorf("PackageWithOptions() error = %v, want an invalid policy error", err)
+	}
+}
+
+func TestPackageSymlinkExcluded(t *testing.T) {
+	source := linkedSource(t)
+
+	opts := Options{Symlinks: SymlinkError, Exclude: []string{"shared.dll", "lang", "missing", "self"}}
+	result, err := PackageWithOptions(source, "setup.exe", t.TempDir(), opts, nil)
+	if err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+	if len(result.SkippedLinks) != 0 {
+		t.Errorf("SkippedLinks = %v, want none for excluded links", result.SkippedLinks)
+	}
+}
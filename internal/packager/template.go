@@ -0,0 +1,173 @@
+package packager
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// TemplateExtensions are the script types whose placeholders are substituted
+var TemplateExtensions = []string{".ps1", ".psm1", ".psd1", ".cmd", ".bat"}
+
+// placeholderPattern matches {{NAME}} placeholders, with optional spaces inside the braces
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// variableName matches the names of template variables
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Byte order marks of the UTF-16 encodings PowerShell scripts are often saved in
+var (
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// ParseVariables parses KEY=VALUE assignments of template variables
+// Values may contain '=' and be empty; a key given twice keeps its last value
+func ParseVariables(assignments []string) (map[string]string, error) {
+	if len(assignments) == 0 {
+		return nil, nil
+	}
+	vars := make(map[string]string, len(assignments))
+	for _, assignment := range assignments {
+		name, value, ok := strings.Cut(assignment, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid variable %q: want KEY=VALUE", assignment)
+		}
+		if err := validateVariableName(name); err != nil {
+			return nil, err
+		}
+		vars[name] = value
+	}
+	return vars, nil
+}
+
+// validateVariableName checks that a template variable can be written as a placeholder
+func validateVariableName(name string) error {
+	if !variableName.MatchString(name) {
+		return fmt.Errorf("invalid variable name %q: use letters, digits and underscores, not starting with a digit", name)
+	}
+	return nil
+}
+
+// IsTemplateFile reports whether the placeholders of a file are substituted
+func IsTemplateFile(name string) bool {
+	return slices.Contains(TemplateExtensions, strings.ToLower(path.Ext(name)))
+}
+
+// ExpandTemplate substitutes the {{NAME}} placeholders of a script with the values of vars
+// UTF-16 scripts are decoded and written back in their encoding and byte order mark;
+// other scripts are taken as UTF-8 or a single-byte code page and only the placeholders
+// change. A placeholder without a variable fails, so no script is packaged half-stamped
+func ExpandTemplate(data []byte, vars map[string]string) ([]byte, error) {
+	var order binary.ByteOrder
+	switch {
+	case bytes.HasPrefix(data, bomUTF16LE):
+		order = binary.LittleEndian
+	case bytes.HasPrefix(data, bomUTF16BE):
+		order = binary.BigEndian
+	default:
+		text, err := expandText(string(data), vars)
+		return []byte(text), err
+	}
+
+	text, err := expandText(decodeUTF16(data[2:], order), vars)
+	if err != nil {
+		return nil, err
+	}
+	return append(data[:2:2], encodeUTF16(text, order)...), nil
+}
+
+// expandText substitutes the placeholders of a decoded script
+func expandText(text string, vars map[string]string) (string, error) {
+	var missing []string
+	expanded := placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		name := placeholderPattern.FindStringSubmatch(placeholder)[1]
+		value, ok := vars[name]
+		if !ok {
+			if !slices.Contains(missing, name) {
+				missing = append(missing, name)
+			}
+			return placeholder
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("no value for template variable(s) %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// decodeUTF16 decodes UTF-16 text without byte order mark
+func decodeUTF16(data []byte, order binary.ByteOrder) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// encodeUTF16 encodes text as UTF-16 without byte order mark
+func encodeUTF16(text string, order binary.ByteOrder) []byte {
+	units := utf16.Encode([]rune(text))
+	data := make([]byte, 2*len(units))
+	for i, unit := range units {
+		order.PutUint16(data[2*i:], unit)
+	}
+	return data
+}
+
+// variablesHash identifies a set of template variables without keeping their values,
+// which may be secrets, e.g. in the state of a resumable run ("" when there are none)
+func variablesHash(vars map[string]string) string {
+	if len(vars) == 0 {
+		return ""
+	}
+	h := sha256.New()
+	for _, name := range variableNames(vars) {
+		fmt.Fprintf(h, "%s=%d:%s\n", name, len(vars[name]), vars[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// variableNames returns the names of template variables in order (nil when there are none)
+func variableNames(vars map[string]string) []string {
+	if len(vars) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeTemplateFile adds a script to the ZIP with its placeholders substituted
+func writeTemplateFile(zipWriter *zip.Writer, header *zip.FileHeader, path, zipPath string, vars map[string]string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return sourceReadError(zipPath, "failed to read file", err)
+	}
+	data, err = ExpandTemplate(data, vars)
+	if err != nil {
+		return fmt.Errorf("%s: %w", zipPath, err)
+	}
+	writer, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("failed to create ZIP entry: %w", err)
+	}
+	if _, err := writer.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s to ZIP: %w", zipPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package packager
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseVariables(t *testing.T) {
+	tests := map[string]struct {
+		assignments []string
+		want        map[string]string
+		wantErr     bool
+	}{
+		"none":            {nil, nil, false},
+		"simple":          {[]string{"TENANT_NAME=Contoso"}, map[string]string{"TENANT_NAME": "Contoso"}, false},
+		"equals in value": {[]string{"ARGS=/S /D=C:\\App"}, map[string]string{"ARGS": "/S /D=C:\\App"}, false},
+		"empty value":     {[]string{"EMPTY="}, map[string]string{"EMPTY": ""}, false},
+		"last wins":       {[]string{"A=1", "A=2"}, map[string]string{"A": "2"}, false},
+		"no value":        {[]string{"TENANT_NAME"}, nil, true},
+		"bad name":        {[]string{"1ST=x"}, nil, true},
+		"dashed name":     {[]string{"TENANT-NAME=x"}, nil, true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseVariables(tt.assignments)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseVariables() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseVariables() = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("ParseVariables()[%s] = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	vars := map[string]string{"TENANT_NAME": "Contoso", "LICENSE_KEY": "ABC-123"}
+	tests := map[string]struct {
+		script  string
+		want    string
+		wantErr bool
+	}{
+		"placeholders":   {"$tenant = '{{TENANT_NAME}}'\r\n$key = '{{ LICENSE_KEY }}'", "$tenant = 'Contoso'\r\n$key = 'ABC-123'", false},
+		"repeated":       {"{{TENANT_NAME}}-{{TENANT_NAME}}", "Contoso-Contoso", false},
+		"no placeholder": {"Write-Host '{not a placeholder}' {{ }}", "Write-Host '{not a placeholder}' {{ }}", false},
+		"utf-8 bom":      {"\xEF\xBB\xBFset T={{TENANT_NAME}}", "\xEF\xBB\xBFset T=Contoso", false},
+		"undefined":      {"{{TENANT_NAME}} {{MISSING}}", "", true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ExpandTemplate([]byte(tt.script), vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExpandTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && string(got) != tt.want {
+				t.Errorf("ExpandTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// UTF-16 scripts keep their encoding and byte order mark
+	for name, order := range map[string]binary.ByteOrder{"utf-16le": binary.LittleEndian, "utf-16be": binary.BigEndian} {
+		bom := bomUTF16LE
+		if order == binary.BigEndian {
+			bom = bomUTF16BE
+		}
+		script := append(append([]byte{}, bom...), encodeUTF16("$t = 'Grüße {{TENANT_NAME}}'", order)...)
+		got, err := ExpandTemplate(script, vars)
+		if err != nil {
+			t.Fatalf("%s: ExpandTemplate() error = %v", name, err)
+		}
+		want := append(append([]byte{}, bom...), encodeUTF16("$t = 'Grüße Contoso'", order)...)
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: ExpandTemplate() = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestZipFolderVariables(t *testing.T) {
+	sourceDir := t.TempDir()
+	files := map[string]string{
+		"install.ps1":     "New-Item HKLM:\\Software\\{{TENANT_NAME}}",
+		"install.cmd":     "setup.exe /S /TENANT={{TENANT_NAME}}",
+		"readme.txt":      "{{TENANT_NAME}} is left alone",
+		"setup.exe":       "binary {{TENANT_NAME}}",
+		"lib/helper.PSM1": "function Get-Tenant { '{{TENANT_NAME}}' }",
+	}
+	writeTree(t, sourceDir, files)
+
+	data, err := ZipFolderWithOptions(sourceDir, ZipOptions{Variables: map[string]string{"TENANT_NAME": "Contoso"}})
+	if err != nil {
+		t.Fatalf("ZipFolderWithOptions() error = %v", err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Failed to read ZIP: %v", err)
+	}
+	for _, f := range reader.File {
+		original, ok := files[f.Name]
+		if !ok {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", f.Name, err)
+		}
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		want := original
+		if IsTemplateFile(f.Name) {
+			want = strings.ReplaceAll(original, "{{TENANT_NAME}}", "Contoso")
+		}
+		if string(content) != want {
+			t.Errorf("%s = %q, want %q", f.Name, content, want)
+		}
+		if f.UncompressedSize64 != uint64(len(want)) {
+			t.Errorf("%s size = %d, want %d", f.Name, f.UncompressedSize64, len(want))
+		}
+	}
+
+	// The source is not modified
+	if got, _ := os.ReadFile(filepath.Join(sourceDir, "install.ps1")); string(got) != files["install.ps1"] {
+		t.Errorf("Source script changed to %q", got)
+	}
+
+	// A script with a placeholder without value fails the run
+	if _, err := ZipFolderWithOptions(sourceDir, ZipOptions{Variables: map[string]string{"OTHER": "x"}}); err == nil || !strings.Contains(err.Error(), "TENANT_NAME") {
+		t.Errorf("ZipFolderWithOptions() error = %v, want the missing variable", err)
+	}
+}
+
+func TestVariablesHash(t *testing.T) {
+	if variablesHash(nil) != "" {
+		t.Error("variablesHash(nil) is not empty")
+	}
+	a := variablesHash(map[string]string{"A": "1", "B": "2"})
+	if a != variablesHash(map[string]string{"B": "2", "A": "1"}) {
+		t.Error("variablesHash() depends on map order")
+	}
+	if a == variablesHash(map[string]string{"A": "1", "B": "3"}) {
+		t.Error("variablesHash() ignores values")
+	}
+}
+
+func TestSecretVariablesNotResumable(t *testing.T) {
+	sourceDir := t.TempDir()
+	writeTree(t, sourceDir, map[string]string{"setup.exe": "installer", "install.ps1": "$key = '{{LICENSE_KEY}}'"})
+	vars := map[string]string{"LICENSE_KEY": "XXXX-YYYY"}
+
+	// The checkpoint would keep the content ZIP with the secret stamped in
+	root := t.TempDir()
+	_, err := PackageWithOptions(sourceDir, "setup.exe", t.TempDir(), Options{Variables: vars, SecretVariables: true, CheckpointRoot: root}, nil)
+	if err == nil || !strings.Contains(err.Error(), "resumable") {
+		t.Fatalf("PackageWithOptions() error = %v, want resumable runs refused", err)
+	}
+	if entries, _ := os.ReadDir(root); len(entries) != 0 {
+		t.Errorf("Checkpoint root has %d entries, want none", len(entries))
+	}
+
+	if _, err := PackageWithOptions(sourceDir, "setup.exe", t.TempDir(), Options{Variables: vars, CheckpointRoot: t.TempDir()}, nil); err != nil {
+		t.Errorf("PackageWithOptions() with plain variables error = %v", err)
+	}
+	if _, err := PackageWithOptions(sourceDir, "setup.exe", t.TempDir(), Options{Variables: vars, SecretVariables: true}, nil); err != nil {
+		t.Errorf("PackageWithOptions() with secrets and no checkpoints error = %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package packager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultTraceFileThreshold is the minimum duration of a per-file operation to be recorded
+const DefaultTraceFileThreshold = 50 * time.Millisecond
+
+// TraceEvent is a single complete ("X") event in Chrome trace event format
+// The resulting file can be loaded in chrome://tracing, Perfetto or speedscope
+type TraceEvent struct {
+	Name     string         `json:"name"`
+	Category string         `json:"cat"`
+	Phase    string         `json:"ph"`
+	Start    int64          `json:"ts"`  // microseconds since trace start
+	Duration int64          `json:"dur"` // microseconds
+	Pid      int            `json:"pid"`
+	Tid      int            `json:"tid"`
+	Args     map[string]any `json:"args,omitempty"`
+}
+
+// Tracer records phase and per-file timing spans
+// A nil *Tracer is valid and records nothing
+type Tracer struct {
+	// FileThreshold is the minimum duration for per-file spans to be kept
+	FileThreshold time.Duration
+
+	mu     sync.Mutex
+	start  time.Time
+	events []TraceEvent
+}
+
+// NewTracer creates a tracer that keeps per-file spans lasting at least fileThreshold
+func NewTracer(fileThreshold time.Duration) *Tracer {
+	return &Tracer{
+		FileThreshold: fileThreshold,
+		start:         time.Now(),
+	}
+}
+
+// StartPhase begins a span for a packaging phase and returns a function that ends it
+func (t *Tracer) StartPhase(name string) func() {
+	if t == nil {
+		return func() {}
+	}
+	begin := time.Now()
+	return func() {
+		t.record(name, "phase", begin, time.Since(begin), nil)
+	}
+}
+
+// RecordFile records a per-file operation if it exceeds the file threshold
+func (t *Tracer) RecordFile(op, path string, begin time.Time, size int64) {
+	if t == nil {
+		return
+	}
+	elapsed := time.Since(begin)
+	if elapsed < t.FileThreshold {
+		return
+	}
+	t.record(op+": "+path, "file", begin, elapsed, map[string]any{
+		"path":  path,
+		"bytes": size,
+	})
+}
+
+// Events returns a copy of the recorded events
+func (t *Tracer) Events() []TraceEvent {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]TraceEvent(nil), t.events...)
+}
+
+// PhaseTiming is the time spent in a packaging phase
+type PhaseTiming struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Phases returns the time spent in each phase, in the order the phases started
+// Phases run more than once (e.g. one run per architecture) are summed; "package"
+// spans the whole run
+func (t *Tracer) Phases() []PhaseTiming {
+	events := t.Events()
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Start < events[j].Start })
+	var phases []PhaseTiming
+	index := make(map[string]int)
+	for _, e := range events {
+		if e.Category != "phase" {
+			continue
+		}
+		i, ok := index[e.Name]
+		if !ok {
+			i = len(phases)
+			index[e.Name] = i
+			phases = append(phases, PhaseTiming{Name: e.Name})
+		}
+		phases[i].Duration += time.Duration(e.Duration) * time.Microsecond
+	}
+	return phases
+}
+
+// WriteFile writes the recorded events as a Chrome trace JSON file
+func (t *Tracer) WriteFile(path string) error {
+	data, err := json.MarshalIndent(map[string]any{
+		"traceEvents":     t.Events(),
+		"displayTimeUnit": "ms",
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode trace: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write trace file: %w", err)
+	}
+	return nil
+}
+
+// record appends a complete event to the trace
+func (t *Tracer) record(name, category string, begin time.Time, elapsed time.Duration, args map[string]any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, TraceEvent{
+		Name:     name,
+		Category: category,
+		Phase:    "X",
+		Start:    begin.Sub(t.start).Microseconds(),
+		Duration: elapsed.Microseconds(),
+		Pid:      1,
+		Tid:      1,
+		Args:     args,
+	})
+}
@@ -0,0 +1,129 @@
+package packager
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTracerNil(t *testing.T) {
+	var tracer *Tracer
+
+	// A nil tracer must be safe to use everywhere
+	tracer.StartPhase("phase")()
+	tracer.RecordFile("compress", "file.txt", time.Now(), 10)
+
+	if events := tracer.Events(); len(events) != 0 {
+		t.Errorf("Events() = %d, want 0", len(events))
+	}
+}
+
+func TestTracerFileThreshold(t *testing.T) {
+	tracer := NewTracer(time.Hour)
+	tracer.RecordFile("compress", "fast.txt", time.Now(), 10)
+	tracer.RecordFile("compress", "slow.txt", time.Now().Add(-2*time.Hour), 10)
+
+	events := tracer.Events()
+	if len(events) != 1 {
+		t.Fatalf("Events() = %d, want 1", len(events))
+	}
+	if events[0].Name != "compress: slow.txt" {
+		t.Errorf("Name = %s, want compress: slow.txt", events[0].Name)
+	}
+	if events[0].Category != "file" {
+		t.Errorf("Category = %s, want file", events[0].Category)
+	}
+}
+
+func TestPackageWithTracer(t *testing.T) {
+	sourceDir, err := os.MkdirTemp("", "source")
+	if err != nil {
+		t.Fatalf("Failed to create source dir: %v", err)
+	}
+	defer os.RemoveAll(sourceDir)
+
+	outputDir, err := os.MkdirTemp("", "output")
+	if err != nil {
+		t.Fatalf("Failed to create output dir: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	if err := os.WriteFile(filepath.Join(sourceDir, "setup.exe"), []byte("installer"), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+
+	tracer := NewTracer(0)
+	if _, err := PackageWithOptions(sourceDir, "setup.exe", outputDir, Options{Tracer: tracer}, nil); err != nil {
+		t.Fatalf("PackageWithOptions() error = %v", err)
+	}
+
+	phases := make(map[string]bool)
+	files := 0
+	for _, event := range tracer.Events() {
+		switch event.Category {
+		case "phase":
+			phases[event.Name] = true
+		case "file":
+			files++
+		}
+	}
+	for _, name := range []string{"package", "validate", "walk", "compress", "encrypt", "metadata", "assemble", "write"} {
+		if !phases[name] {
+			t.Errorf("Missing phase span %q", name)
+		}
+	}
+	if files != 1 {
+		t.Errorf("File spans = %d, want 1", files)
+	}
+
+	// The written file must be valid Chrome trace JSON
+	tracePath := filepath.Join(outputDir, "trace.json")
+	if err := tracer.WriteFile(tracePath); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	data, err := os.ReadFile(tracePath)
+	if err != nil {
+		t.Fatalf("Failed to read trace: %v", err)
+	}
+	var trace struct {
+		TraceEvents []TraceEvent `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(data, &trace); err != nil {
+		t.Fatalf("Trace is not valid JSON: %v", err)
+	}
+	if len(trace.TraceEvents) == 0 {
+		t.Error("Trace file has no events")
+	}
+}
+
+func TestTracerPhases(t *testing.T) {
+	tracer := NewTracer(0)
+	begin := tracer.start
+	tracer.record("package", "phase", begin, 10*time.Second, nil)
+	tracer.record("compress", "phase", begin.Add(time.Second), 3*time.Second, nil)
+	tracer.record("compress: big.bin", "file", begin.Add(time.Second), 2*time.Second, nil)
+	tracer.record("encrypt", "phase", begin.Add(4*time.Second), time.Second, nil)
+	tracer.record("compress", "phase", begin.Add(6*time.Second), 2*time.Second, nil)
+
+	phases := tracer.Phases()
+	want := []PhaseTiming{
+		{"package", 10 * time.Second},
+		{"compress", 5 * time.Second},
+		{"encrypt", time.Second},
+	}
+	if len(phases) != len(want) {
+		t.Fatalf("Phases() = %+v, want %+v", phases, want)
+	}
+	for i := range want {
+		if phases[i] != want[i] {
+			t.Errorf("Phases()[%d] = %+v, want %+v", i, phases[i], want[i])
+		}
+	}
+
+	var none *Tracer
+	if phases := none.Phases(); phases != nil {
+		t.Errorf("Phases() of nil tracer = %+v, want nil", phases)
+	}
+}
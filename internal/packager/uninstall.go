@@ -0,0 +1,205 @@
+package packager
+
+import (
+	"encoding/base64"
+	"fmt"
+	"text/template"
+)
+
+// UninstallParams holds the values used to generate an uninstall wrapper
+type UninstallParams struct {
+	// Name is the application display name as registered in Add/Remove Programs
+	Name string
+	// ProductCode identifies MSI installs, removed with msiexec /x
+	ProductCode string
+	// UninstallString is a known uninstall command line of an EXE install (optional,
+	// read from the Uninstall registry keys otherwise)
+	UninstallString string
+	// UninstallArgs are the silent switches appended to an UninstallString of the
+	// registry, which is usually interactive (a QuietUninstallString is used as is)
+	UninstallArgs string
+}
+
+// UninstallParamsFromMsi derives uninstall parameters from MSI metadata
+func UninstallParamsFromMsi(info *MsiInfo) UninstallParams {
+	return UninstallParams{Name: info.ProductName, ProductCode: info.ProductCode}
+}
+
+// UninstallApp holds the command lines and detection rules of an uninstall app,
+// written as JSON for import into Intune or an app spec
+type UninstallApp struct {
+	Name string `json:"displayName"`
+	// SetupFile is the uninstall wrapper packaged as the setup file
+	SetupFile string `json:"setupFilePath"`
+	// InstallCommandLine runs the wrapper, removing the app
+	InstallCommandLine string `json:"installCommandLine"`
+	// UninstallCommandLine is required by Intune and runs the wrapper again, as there is
+	// nothing to undo
+	UninstallCommandLine string `json:"uninstallCommandLine"`
+	// AppUninstallCommandLine removes the app directly, for its own Win32 app
+	AppUninstallCommandLine string `json:"appUninstallCommandLine,omitempty"`
+	// DetectionRules detect the uninstall app once the app is gone
+	DetectionRules []map[string]any `json:"detectionRules"`
+}
+
+// uninstallCommon finds the Uninstall registry entry of the app
+const uninstallCommon = `$AppName = {{ps .Name}}
+$ProductCode = {{ps .ProductCode}}
+$UninstallString = {{ps .UninstallString}}
+
+function Get-UninstallEntry {
+    $roots = @(
+        'HKLM:\SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall',
+        'HKLM:\SOFTWARE\WOW6432Node\Microsoft\Windows\CurrentVersion\Uninstall'
+    )
+    foreach ($root in $roots) {
+        if ($ProductCode) {
+            $entry = Get-ItemProperty -Path (Join-Path $root $ProductCode) -ErrorAction SilentlyContinue
+        } else {
+            $entry = Get-ChildItem -Path $root -ErrorAction SilentlyContinue |
+                Get-ItemProperty -ErrorAction SilentlyContinue |
+                Where-Object { $_.DisplayName -like "$AppName*" } |
+                Select-Object -First 1
+        }
+        if ($entry) {
+            return $entry
+        }
+    }
+    return $null
+}
+
+function Split-CommandLine([string]$commandLine) {
+    if ($commandLine -match '^\s*"([^"]+)"\s*(.*)$') {
+        return $Matches[1], $Matches[2]
+    }
+    if ($commandLine -match '^\s*(.+?\.exe)\s*(.*)$') {
+        return $Matches[1], $Matches[2]
+    }
+    return $commandLine.Trim(), ''
+}
+
+function Test-Installed {
+    if ($UninstallString) {
+        $file, $null = Split-CommandLine $UninstallString
+        return Test-Path -LiteralPath $file
+    }
+    return [bool](Get-UninstallEntry)
+}
+`
+
+// uninstallScriptTemplate removes the app, succeeding when it is not installed
+const uninstallScriptTemplate = `# Uninstall script for {{.Name}}
+# Generated by LetsGoIntunePackager
+$UninstallArgs = {{ps .UninstallArgs}}
+` + uninstallCommon + `
+if (-not (Test-Installed)) {
+    Write-Output "$AppName is not installed"
+    exit 0
+}
+
+$commandLine = $UninstallString
+$quiet = [bool]$UninstallString
+if (-not $commandLine) {
+    $entry = Get-UninstallEntry
+    if ($entry.QuietUninstallString) {
+        $commandLine = $entry.QuietUninstallString
+        $quiet = $true
+    } else {
+        $commandLine = $entry.UninstallString
+    }
+}
+
+if ($ProductCode) {
+    $file, $arguments = 'msiexec.exe', "/x $ProductCode /qn /norestart"
+} elseif ($commandLine -match '(?i)msiexec(\.exe)?"?\s.*?/[IX]\s*(\{[0-9A-F-]+\})') {
+    # MSI installs register msiexec /I or /X, which shows the maintenance dialog
+    $file, $arguments = 'msiexec.exe', "/x $($Matches[2]) /qn /norestart"
+} elseif ($commandLine) {
+    $file, $arguments = Split-CommandLine $commandLine
+    if (-not $quiet -and $UninstallArgs) {
+        $arguments = "$arguments $UninstallArgs".Trim()
+    }
+} else {
+    Write-Output "$AppName has no uninstall command"
+    exit 1
+}
+
+Write-Output "Uninstalling ${AppName}: $file $arguments"
+$start = @{ FilePath = $file; Wait = $true; PassThru = $true; WindowStyle = 'Hidden' }
+if ($arguments) {
+    $start.ArgumentList = $arguments
+}
+$process = Start-Process @start
+$exitCode = $process.ExitCode
+# 1605: the product is not installed (anymore)
+if ($exitCode -eq 1605) {
+    $exitCode = 0
+}
+Write-Output "$AppName uninstall finished with exit code $exitCode"
+exit $exitCode
+`
+
+// uninstallDetectTemplate detects the uninstall app, as Intune script detection expects:
+// output and exit code 0 once the app is gone
+const uninstallDetectTemplate = `# Detection script of the uninstall app for {{.Name}}
+# Generated by LetsGoIntunePackager - detected when the app is not installed
+` + uninstallCommon + `
+if (-not (Test-Installed)) {
+    Write-Output "$AppName is not installed"
+}
+exit 0
+`
+
+// UninstallScriptName returns the file name of the uninstall wrapper of an app
+func UninstallScriptName(name string) string {
+	return "Uninstall-" + ScriptBaseName(name) + ".ps1"
+}
+
+// GenerateUninstallScripts creates the uninstall wrapper of an app and the detection
+// script of the uninstall app
+func GenerateUninstallScripts(params UninstallParams) (uninstall, detect []byte, err error) {
+	if params.Name == "" {
+		return nil, nil, fmt.Errorf("application name is required")
+	}
+	if params.ProductCode != "" && !isValidGUID(params.ProductCode) {
+		return nil, nil, fmt.Errorf("invalid product code: %s", params.ProductCode)
+	}
+
+	funcs := template.FuncMap{"ps": powershellQuote}
+
+	uninstall, err = renderScript("uninstall", uninstallScriptTemplate, funcs, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	detect, err = renderScript("uninstall detection", uninstallDetectTemplate, funcs, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	return uninstall, detect, nil
+}
+
+// NewUninstallApp returns the command lines and detection rules of the uninstall app
+// running the wrapper of GenerateUninstallScripts
+func NewUninstallApp(params UninstallParams, detect []byte) *UninstallApp {
+	script := UninstallScriptName(params.Name)
+	command := fmt.Sprintf(`powershell.exe -NoProfile -ExecutionPolicy Bypass -File .\%s`, script)
+	app := &UninstallApp{
+		Name:                 "Uninstall " + params.Name,
+		SetupFile:            script,
+		InstallCommandLine:   command,
+		UninstallCommandLine: command,
+		DetectionRules: []map[string]any{{
+			"@odata.type":           "#microsoft.graph.win32LobAppPowerShellScriptDetection",
+			"enforceSignatureCheck": false,
+			"runAs32Bit":            false,
+			"scriptContent":         base64.StdEncoding.EncodeToString(detect),
+		}},
+	}
+	switch {
+	case params.ProductCode != "":
+		app.AppUninstallCommandLine = fmt.Sprintf("msiexec /x %s /qn", params.ProductCode)
+	case params.UninstallString != "":
+		app.AppUninstallCommandLine = params.UninstallString
+	}
+	return app
+}
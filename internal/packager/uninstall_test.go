@@ -0,0 +1,90 @@
+package packager
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestGenerateUninstallScripts(t *testing.T) {
+	params := UninstallParamsFromMsi(&MsiInfo{
+		ProductName: "Contoso's App",
+		ProductCode: "{12345678-1234-1234-1234-123456789012}",
+	})
+
+	uninstall, detect, err := GenerateUninstallScripts(params)
+	if err != nil {
+		t.Fatalf("GenerateUninstallScripts() error = %v", err)
+	}
+
+	uninstallStr := string(uninstall)
+	for _, want := range []string{
+		"$AppName = 'Contoso''s App'",
+		"$ProductCode = '{12345678-1234-1234-1234-123456789012}'",
+		`"/x $ProductCode /qn /norestart"`,
+		"-eq 1605",
+	} {
+		if !strings.Contains(uninstallStr, want) {
+			t.Errorf("Uninstall script missing %q", want)
+		}
+	}
+	if !strings.Contains(uninstallStr, "\r\n") {
+		t.Error("Uninstall script should use CRLF line endings")
+	}
+	if !strings.Contains(string(detect), "if (-not (Test-Installed))") {
+		t.Error("Detection script should detect the uninstall app when the app is gone")
+	}
+
+	app := NewUninstallApp(params, detect)
+	if app.SetupFile != "Uninstall-Contoso's-App.ps1" {
+		t.Errorf("SetupFile = %q", app.SetupFile)
+	}
+	if app.AppUninstallCommandLine != "msiexec /x {12345678-1234-1234-1234-123456789012} /qn" {
+		t.Errorf("AppUninstallCommandLine = %q", app.AppUninstallCommandLine)
+	}
+	if !strings.HasSuffix(app.InstallCommandLine, `-File .\Uninstall-Contoso's-App.ps1`) {
+		t.Errorf("InstallCommandLine = %q", app.InstallCommandLine)
+	}
+	script, err := base64.StdEncoding.DecodeString(app.DetectionRules[0]["scriptContent"].(string))
+	if err != nil || string(script) != string(detect) {
+		t.Errorf("Detection rule script does not match the detection script: %v", err)
+	}
+}
+
+func TestGenerateUninstallScriptsExe(t *testing.T) {
+	params := UninstallParams{
+		Name:            "Contoso App",
+		UninstallString: `"C:\Program Files\Contoso\uninst.exe" /S`,
+		UninstallArgs:   "/S",
+	}
+	uninstall, _, err := GenerateUninstallScripts(params)
+	if err != nil {
+		t.Fatalf("GenerateUninstallScripts() error = %v", err)
+	}
+	for _, want := range []string{
+		`$UninstallString = '"C:\Program Files\Contoso\uninst.exe" /S'`,
+		"$UninstallArgs = '/S'",
+		"$ProductCode = ''",
+	} {
+		if !strings.Contains(string(uninstall), want) {
+			t.Errorf("Uninstall script missing %q", want)
+		}
+	}
+	if app := NewUninstallApp(params, nil); app.AppUninstallCommandLine != params.UninstallString {
+		t.Errorf("AppUninstallCommandLine = %q", app.AppUninstallCommandLine)
+	}
+}
+
+func TestGenerateUninstallScriptsErrors(t *testing.T) {
+	tests := map[string]UninstallParams{
+		"no name":              {ProductCode: "{12345678-1234-1234-1234-123456789012}"},
+		"invalid product code": {Name: "App", ProductCode: "{not-a-guid}"},
+	}
+	for name, params := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := GenerateUninstallScripts(params); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
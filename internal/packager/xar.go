@@ -0,0 +1,165 @@
+package packager
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+)
+
+const (
+	// xarMagic is the first four bytes of a XAR archive ("xar!")
+	xarMagic = 0x78617221
+	// xarHeaderSize is the size of the fixed part of the XAR header
+	xarHeaderSize = 28
+	// xarMaxTOCSize bounds the table of contents read from an archive
+	xarMaxTOCSize = 64 << 20
+	// xarMaxEntrySize bounds the metadata files read from an archive (not the payloads)
+	xarMaxEntrySize = 16 << 20
+)
+
+// xarTOC is the XML table of contents of a XAR archive
+type xarTOC struct {
+	Files []xarFile `xml:"toc>file"`
+}
+
+// xarFile is a file or directory of the table of contents
+type xarFile struct {
+	Name  string    `xml:"name"`
+	Type  string    `xml:"type"`
+	Data  *xarData  `xml:"data"`
+	Files []xarFile `xml:"file"`
+}
+
+// xarData locates the content of a file in the heap
+type xarData struct {
+	Offset   int64 `xml:"offset"`
+	Length   int64 `xml:"length"`
+	Size     int64 `xml:"size"`
+	Encoding struct {
+		Style string `xml:"style,attr"`
+	} `xml:"encoding"`
+}
+
+// xarArchive is an open XAR archive, the container format of macOS flat packages (.pkg)
+type xarArchive struct {
+	file  *os.File
+	heap  int64
+	files map[string]*xarData
+}
+
+// openXar opens a XAR archive and reads its table of contents
+func openXar(archivePath string) (*xarArchive, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open package: %w", err)
+	}
+
+	var header struct {
+		Magic           uint32
+		HeaderSize      uint16
+		Version         uint16
+		TOCCompressed   uint64
+		TOCUncompressed uint64
+		ChecksumAlg     uint32
+	}
+	if err := binary.Read(f, binary.BigEndian, &header); err != nil || header.Magic != xarMagic {
+		f.Close()
+		return nil, fmt.Errorf("%s is not a flat package (XAR archive)", archivePath)
+	}
+	if header.HeaderSize < xarHeaderSize || header.TOCCompressed > xarMaxTOCSize || header.TOCUncompressed > xarMaxTOCSize {
+		f.Close()
+		return nil, fmt.Errorf("invalid XAR header in %s", archivePath)
+	}
+
+	compressed := io.NewSectionReader(f, int64(header.HeaderSize), int64(header.TOCCompressed))
+	zr, err := zlib.NewReader(compressed)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read package table of contents: %w", err)
+	}
+	defer zr.Close()
+	tocData, err := io.ReadAll(io.LimitReader(zr, int64(header.TOCUncompressed)))
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read package table of contents: %w", err)
+	}
+
+	var toc xarTOC
+	if err := xml.Unmarshal(tocData, &toc); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to parse package table of contents: %w", err)
+	}
+
+	archive := &xarArchive{
+		file:  f,
+		heap:  int64(header.HeaderSize) + int64(header.TOCCompressed),
+		files: make(map[string]*xarData),
+	}
+	archive.index("", toc.Files)
+	return archive, nil
+}
+
+// index records the files of a directory of the table of contents by their path
+func (a *xarArchive) index(dir string, files []xarFile) {
+	for i := range files {
+		name := path.Join(dir, files[i].Name)
+		if files[i].Type == "file" && files[i].Data != nil {
+			a.files[name] = files[i].Data
+		}
+		a.index(name, files[i].Files)
+	}
+}
+
+// Close closes the archive file
+func (a *xarArchive) Close() error {
+	return a.file.Close()
+}
+
+// Names returns the paths of the files in the archive
+func (a *xarArchive) Names() []string {
+	names := make([]string, 0, len(a.files))
+	for name := range a.files {
+		names = append(names, name)
+	}
+	return names
+}
+
+// ReadFile returns the decoded content of a file of the archive
+func (a *xarArchive) ReadFile(name string) ([]byte, error) {
+	data, ok := a.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%s not found in package", name)
+	}
+	if data.Length < 0 || data.Size > xarMaxEntrySize || data.Length > xarMaxEntrySize {
+		return nil, fmt.Errorf("%s is too large to read", name)
+	}
+
+	raw := make([]byte, data.Length)
+	if _, err := a.file.ReadAt(raw, a.heap+data.Offset); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	switch data.Encoding.Style {
+	case "", "application/octet-stream":
+		return raw, nil
+	case "application/x-gzip":
+		// XAR labels zlib streams as x-gzip
+		zr, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", name, err)
+		}
+		defer zr.Close()
+		content, err := io.ReadAll(io.LimitReader(zr, xarMaxEntrySize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", name, err)
+		}
+		return content, nil
+	default:
+		return nil, fmt.Errorf("%s uses unsupported encoding %s", name, data.Encoding.Style)
+	}
+}
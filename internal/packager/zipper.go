@@ -3,8 +3,12 @@ package packager
 import (
 	"archive/zip"
 	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/sha256"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
@@ -75,13 +79,13 @@ func ZipFolder(sourcePath string) ([]byte, error) {
 		// Open and copy file content
 		file, err := os.Open(path)
 		if err != nil {
-			return fmt.Errorf("failed to open file: %w", err)
+			return sourceReadError(zipPath, "failed to open file", err)
 		}
 		defer file.Close()
 
 		_, err = io.Copy(writer, file)
 		if err != nil {
-			return fmt.Errorf("failed to write file to ZIP: %w", err)
+			return sourceReadError(zipPath, "failed to write file to ZIP", err)
 		}
 
 		return nil
@@ -99,73 +103,207 @@ func ZipFolder(sourcePath string) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// ZipOptions controls optional behavior of ZipFolderWithOptions
+type ZipOptions struct {
+	// Context stops compression between files when it is canceled (optional)
+	Context context.Context
+	// Progress receives the current file path and the share of the source bytes compressed
+	// (0.0 to 1.0), when a file starts and as a large file is read
+	Progress func(file string, progress float64)
+	// Compressed receives the source bytes compressed so far and in total as files are
+	// read (optional)
+	Compressed func(done, total int64)
+	// Tracer records per-file compression spans (optional)
+	Tracer *Tracer
+	// Exclude lists glob patterns of files and folders to leave out (see IsExcluded)
+	Exclude []string
+	// ContentStore reuses compressed data of files of at least ContentStoreMinSize (optional)
+	ContentStore *ContentStore
+	// HashCache skips reading files whose compressed data is in the content store when their
+	// hash is cached (optional)
+	HashCache *HashCache
+	// Previous reuses compressed data of files unchanged since a previous package (optional)
+	Previous *PreviousContent
+	// Reused is called for every file whose compressed data came from the content store
+	// or the previous package (optional)
+	Reused func(file string, size int64)
+	// ModTime replaces the modification time of every file and fixes file modes, so the
+	// ZIP only depends on file names and content (optional, for reproducible builds)
+	// Entries are always added in lexical order of their paths
+	ModTime time.Time
+	// Extra adds generated files after the files of the folder (optional)
+	Extra []ZipEntry
+	// Symlinks decides how symbolic links and junctions are packaged (default SymlinkFollow)
+	Symlinks SymlinkPolicy
+	// NormalizePermissions stores mode 0755 for folders and executables and 0644 for other
+	// files instead of the modes and attributes of the source (see normalizedMode)
+	NormalizePermissions bool
+	// Compression decides which files are stored and the deflate level of the others
+	// (optional, defaults to CompressionDefault)
+	Compression Compression
+	// Variables are substituted for the placeholders of scripts (see IsTemplateFile)
+	Variables map[string]string
+}
+
+// ZipEntry is a generated file added to the content ZIP, such as the install script of an MSI suite
+type ZipEntry struct {
+	Name string
+	Data []byte
+}
+
 // ZipFolderWithProgress compresses a folder with progress callback
 // callback receives current file path and progress percentage (0.0 to 1.0)
 func ZipFolderWithProgress(sourcePath string, callback func(file string, progress float64)) ([]byte, error) {
-	// First pass: count total files for progress calculation
+	return ZipFolderWithOptions(sourcePath, ZipOptions{Progress: callback})
+}
+
+// ZipFolderWithOptions compresses a folder into an in-memory ZIP archive
+func ZipFolderWithOptions(sourcePath string, opts ZipOptions) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := zipFolderTo(buf, sourcePath, opts); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// zipFolderDigest compresses a folder like ZipFolderWithOptions and returns the SHA256 of
+// the archive too, hashed as it is written rather than in a second pass over it
+// sizeHint, the expected size of the archive (0 if unknown), is allocated up front so the
+// buffer is not copied each time it grows
+func zipFolderDigest(sourcePath string, opts ZipOptions, sizeHint int64) (data, digest []byte, err error) {
+	buf := new(bytes.Buffer)
+	if sizeHint > 0 && sizeHint <= maxSizeHint && sizeHint <= math.MaxInt {
+		buf.Grow(int(sizeHint))
+	}
+	hash := sha256.New()
+	if err := zipFolderTo(io.MultiWriter(buf, hash), sourcePath, opts); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), hash.Sum(nil), nil
+}
+
+// maxSizeHint bounds the memory allocated up front from a size estimate, which can be off
+const maxSizeHint int64 = 8 << 30
+
+// zipFolderTo compresses a folder like ZipFolderWithOptions, writing the ZIP archive to w
+func zipFolderTo(w io.Writer, sourcePath string, opts ZipOptions) error {
+	callback := opts.Progress
+
+	// First pass: count total files and bytes for progress calculation
 	var totalFiles int
+	var totalSize int64
 	absSource, err := filepath.Abs(sourcePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+		return fmt.Errorf("failed to get absolute path: %w", err)
 	}
+	// Deep source trees and UNC shares exceed MAX_PATH on Windows
+	absSource = longPath(absSource)
 
-	err = filepath.Walk(absSource, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() {
-			totalFiles++
-		}
-		return nil
-	})
+	totalSize, totalFiles, _, err = sourceStats(absSource, opts.Exclude, opts.Symlinks)
 	if err != nil {
-		return nil, fmt.Errorf("failed to count files: %w", err)
+		return fmt.Errorf("failed to count files: %w", err)
 	}
 
 	if totalFiles == 0 {
-		return nil, fmt.Errorf("no files found in source directory")
+		return fmt.Errorf("no files found in source directory")
 	}
 
-	// Create buffer for ZIP
-	buf := new(bytes.Buffer)
-	zipWriter := zip.NewWriter(buf)
+	level := opts.Compression.level()
+	zipWriter := newContentZipWriter(w, level)
 
 	var processedFiles int
+	var processedSize int64
+	var reported float64
+	fileDone := func(size int64) {
+		processedFiles++
+		processedSize += size
+		if opts.Compressed != nil {
+			opts.Compressed(processedSize, totalSize)
+		}
+	}
+	// done returns the share of the source compressed with read bytes of the current file,
+	// by size so that one large file does not stall the progress, or by count for a source
+	// of empty files
+	done := func(read int64) float64 {
+		if totalSize == 0 {
+			return float64(processedFiles) / float64(totalFiles)
+		}
+		return float64(processedSize+read) / float64(totalSize)
+	}
 
 	// Walk and compress
-	err = filepath.Walk(absSource, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+	_, err = walkSource(absSource, opts.Exclude, opts.Symlinks, func(relPath, path string, info os.FileInfo) error {
+		if opts.Context != nil {
+			if err := opts.Context.Err(); err != nil {
+				return err
+			}
 		}
 
-		if path == absSource {
-			return nil
-		}
+		zipPath := strings.ReplaceAll(relPath, string(os.PathSeparator), "/")
 
-		relPath, err := filepath.Rel(absSource, path)
+		header, err := entryHeader(zipPath, path, info, opts)
 		if err != nil {
-			return fmt.Errorf("failed to calculate relative path: %w", err)
+			return fmt.Errorf("failed to create file header: %w", err)
 		}
 
-		zipPath := strings.ReplaceAll(relPath, string(os.PathSeparator), "/")
-
 		if info.IsDir() {
-			_, err = zipWriter.Create(zipPath + "/")
+			_, err = zipWriter.CreateHeader(header)
 			return err
 		}
 
 		// Report progress
 		if callback != nil {
-			progress := float64(processedFiles) / float64(totalFiles)
-			callback(relPath, progress)
+			reported = done(0)
+			callback(relPath, reported)
 		}
 
-		header, err := zip.FileInfoHeader(info)
+		fileStart := time.Now()
+
+		store, err := opts.Compression.storeFile(path, info.Size())
 		if err != nil {
-			return fmt.Errorf("failed to create file header: %w", err)
+			return err
+		}
+		if store {
+			header.Method = zip.Store
+		}
+
+		// Stamped scripts differ from the source, so their data is never reused
+		if len(opts.Variables) > 0 && IsTemplateFile(zipPath) {
+			if err := writeTemplateFile(zipWriter, header, path, zipPath, opts.Variables); err != nil {
+				return err
+			}
+			opts.Tracer.RecordFile("compress", zipPath, fileStart, info.Size())
+			fileDone(info.Size())
+			return nil
+		}
+
+		if opts.Previous != nil && !store {
+			reused, err := opts.Previous.writeFile(zipWriter, header, path, info.Size())
+			if err != nil {
+				return err
+			}
+			if reused {
+				if opts.Reused != nil {
+					opts.Reused(zipPath, info.Size())
+				}
+				opts.Tracer.RecordFile("compress", zipPath, fileStart, info.Size())
+				fileDone(info.Size())
+				return nil
+			}
+		}
+
+		if opts.ContentStore != nil && !store && info.Size() >= ContentStoreMinSize {
+			reused, err := opts.ContentStore.writeFile(zipWriter, header, path, info, level, opts.HashCache)
+			if err != nil {
+				return err
+			}
+			if reused && opts.Reused != nil {
+				opts.Reused(zipPath, info.Size())
+			}
+			opts.Tracer.RecordFile("compress", zipPath, fileStart, info.Size())
+			fileDone(info.Size())
+			return nil
 		}
-		header.Name = zipPath
-		header.Method = zip.Deflate
 
 		writer, err := zipWriter.CreateHeader(header)
 		if err != nil {
@@ -174,21 +312,50 @@ func ZipFolderWithProgress(sourcePath string, callback func(file string, progres
 
 		file, err := os.Open(path)
 		if err != nil {
-			return fmt.Errorf("failed to open file: %w", err)
+			return sourceReadError(zipPath, "failed to open file", err)
 		}
 		defer file.Close()
 
-		_, err = io.Copy(writer, file)
+		// Large files report their progress as they are read
+		_, err = io.Copy(writer, &progressReader{r: file, onRead: func(read int64) {
+			if opts.Compressed != nil {
+				opts.Compressed(processedSize+read, totalSize)
+			}
+			if pct := done(read); callback != nil && pct-reported >= progressStep {
+				reported = pct
+				callback(relPath, pct)
+			}
+		}})
 		if err != nil {
-			return fmt.Errorf("failed to write file to ZIP: %w", err)
+			return sourceReadError(zipPath, "failed to write file to ZIP", err)
 		}
 
-		processedFiles++
+		opts.Tracer.RecordFile("compress", zipPath, fileStart, info.Size())
+
+		fileDone(info.Size())
 		return nil
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to walk directory: %w", err)
+		return fmt.Errorf("failed to walk directory: %w", err)
+	}
+
+	for _, entry := range opts.Extra {
+		header := &zip.FileHeader{Name: entry.Name, Method: zip.Deflate, Modified: opts.ModTime}
+		if opts.Compression == CompressionStore {
+			header.Method = zip.Store
+		}
+		if header.Modified.IsZero() {
+			header.Modified = time.Now()
+		}
+		header.SetMode(0644)
+		writer, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			return fmt.Errorf("failed to create ZIP entry: %w", err)
+		}
+		if _, err := writer.Write(entry.Data); err != nil {
+			return fmt.Errorf("failed to write %s to ZIP: %w", entry.Name, err)
+		}
 	}
 
 	// Final progress callback
@@ -197,57 +364,87 @@ func ZipFolderWithProgress(sourcePath string, callback func(file string, progres
 	}
 
 	if err := zipWriter.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close ZIP writer: %w", err)
+		return fmt.Errorf("failed to close ZIP writer: %w", err)
 	}
 
-	return buf.Bytes(), nil
+	return nil
+}
+
+// newContentZipWriter returns a writer of a content ZIP deflating files at level
+func newContentZipWriter(w io.Writer, level int) *zip.Writer {
+	zipWriter := zip.NewWriter(w)
+	if level != zipDeflateLevel {
+		zipWriter.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(out, level)
+		})
+	}
+	return zipWriter
 }
 
 // CreateIntunewinPackage creates the final .intunewin package structure
 // Structure: outer.zip/IntuneWinPackage/Contents/IntunePackage.intunewin + Metadata/Detection.xml
 // IMPORTANT: The outer ZIP must use Store method (no compression) to match Microsoft's official format
 func CreateIntunewinPackage(encryptedContent, detectionXML []byte) ([]byte, error) {
-	buf := new(bytes.Buffer)
-	zipWriter := zip.NewWriter(buf)
+	return CreateIntunewinPackageAt(encryptedContent, detectionXML, time.Now())
+}
 
-	now := time.Now()
+// CreateIntunewinPackageAt creates the final .intunewin package like CreateIntunewinPackage,
+// with now as the modification time of its entries
+func CreateIntunewinPackageAt(encryptedContent, detectionXML []byte, now time.Time) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := writeIntunewinPackage(buf, bytes.NewReader(encryptedContent), detectionXML, now); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
 
+// writeIntunewinPackage writes the package structure of CreateIntunewinPackageAt to w,
+// streaming the encrypted content from r
+func writeIntunewinPackage(w io.Writer, encryptedContent io.Reader, detectionXML []byte, now time.Time) error {
 	// Create directory structure (using IntuneWinPackage to match official Microsoft format)
 	// IntuneWinPackage/Contents/IntunePackage.intunewin
+	// IntuneWinPackage/Metadata/Detection.xml
+	return writePackageZip(w, EncryptedContentPath, DetectionXMLPath, encryptedContent, detectionXML, now)
+}
+
+// writePackageZip writes the encrypted content and Detection.xml of a package at the given
+// paths; .intunewin and .intunemac packages share this layout
+func writePackageZip(w io.Writer, contentPath, metadataPath string, encryptedContent io.Reader, detectionXML []byte, now time.Time) error {
+	zipWriter := zip.NewWriter(w)
+
 	// Must use Store method (no compression) - this is critical for Intune acceptance
 	contentHeader := &zip.FileHeader{
-		Name:   "IntuneWinPackage/Contents/IntunePackage.intunewin",
+		Name:   contentPath,
 		Method: zip.Store, // No compression - required by Microsoft Intune
 	}
 	contentHeader.Modified = now
 	contentWriter, err := zipWriter.CreateHeader(contentHeader)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create encrypted content entry: %w", err)
+		return fmt.Errorf("failed to create encrypted content entry: %w", err)
 	}
-	if _, err := contentWriter.Write(encryptedContent); err != nil {
-		return nil, fmt.Errorf("failed to write encrypted content: %w", err)
+	if _, err := io.Copy(contentWriter, encryptedContent); err != nil {
+		return fmt.Errorf("failed to write encrypted content: %w", err)
 	}
 
-	// IntuneWinPackage/Metadata/Detection.xml
 	// Must use Store method (no compression) - this is critical for Intune acceptance
 	metadataHeader := &zip.FileHeader{
-		Name:   "IntuneWinPackage/Metadata/Detection.xml",
+		Name:   metadataPath,
 		Method: zip.Store, // No compression - required by Microsoft Intune
 	}
 	metadataHeader.Modified = now
 	metadataWriter, err := zipWriter.CreateHeader(metadataHeader)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create metadata entry: %w", err)
+		return fmt.Errorf("failed to create metadata entry: %w", err)
 	}
 	if _, err := metadataWriter.Write(detectionXML); err != nil {
-		return nil, fmt.Errorf("failed to write metadata: %w", err)
+		return fmt.Errorf("failed to write metadata: %w", err)
 	}
 
 	if err := zipWriter.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close package: %w", err)
+		return fmt.Errorf("failed to close package: %w", err)
 	}
 
-	return buf.Bytes(), nil
+	return nil
 }
 
 // GetFolderSize calculates the total size of all files in a folder
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 // Note: os and path/filepath are still used by other tests in this file
@@ -136,6 +137,30 @@ func TestZipFolderNonExistent(t *testing.T) {
 	}
 }
 
+func TestZipFolderDigest(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "setup.msi"), bytes.Repeat([]byte("msi"), 10000), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "config.ini"), []byte("[app]"), 0644)
+	opts := ZipOptions{ModTime: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	want, err := ZipFolderWithOptions(tmpDir, opts)
+	if err != nil {
+		t.Fatalf("ZipFolderWithOptions() error = %v", err)
+	}
+	for _, sizeHint := range []int64{0, 100, 1 << 20} {
+		data, digest, err := zipFolderDigest(tmpDir, opts, sizeHint)
+		if err != nil {
+			t.Fatalf("zipFolderDigest() error = %v", err)
+		}
+		if !bytes.Equal(data, want) {
+			t.Errorf("zipFolderDigest(hint %d) archive differs from ZipFolderWithOptions", sizeHint)
+		}
+		if !bytes.Equal(digest, CalculateFileDigest(data)) {
+			t.Errorf("zipFolderDigest(hint %d) digest is not the SHA256 of the archive", sizeHint)
+		}
+	}
+}
+
 func TestCreateIntunewinPackage(t *testing.T) {
 	// Create fake encrypted data and detection XML
 	encryptedData := []byte("fake encrypted data")
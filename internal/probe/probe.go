@@ -0,0 +1,358 @@
+package probe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// DefaultCandidateTimeout is how long a single candidate may run before it is killed
+	DefaultCandidateTimeout = 2 * time.Minute
+
+	// Folders inside the sandbox
+	sandboxSourceDir = `C:\Probe\Source`
+	sandboxWorkDir   = `C:\Probe\Work`
+
+	// Files in the work folder
+	scriptFile  = "probe.ps1"
+	configFile  = "probe.wsb"
+	resultsFile = "results.json"
+	doneFile    = "done"
+
+	// pollInterval is how often the host checks the work folder for results
+	pollInterval = 2 * time.Second
+)
+
+// InstallerType is the installer framework an EXE was built with
+type InstallerType string
+
+const (
+	InstallerUnknown       InstallerType = "unknown"
+	InstallerInno          InstallerType = "Inno Setup"
+	InstallerNSIS          InstallerType = "NSIS"
+	InstallerInstallShield InstallerType = "InstallShield"
+	InstallerWixBurn       InstallerType = "WiX Burn"
+)
+
+// installerMarkers identify installer frameworks by strings embedded in the EXE
+var installerMarkers = []struct {
+	marker string
+	kind   InstallerType
+}{
+	{"Inno Setup", InstallerInno},
+	{"Nullsoft", InstallerNSIS},
+	{"InstallShield", InstallerInstallShield},
+	{".wixburn", InstallerWixBurn},
+}
+
+// frameworkSwitches are the documented silent switches of known installer frameworks
+var frameworkSwitches = map[InstallerType][]string{
+	InstallerInno:          {"/VERYSILENT /SUPPRESSMSGBOXES /NORESTART", "/SILENT /SUPPRESSMSGBOXES /NORESTART"},
+	InstallerNSIS:          {"/S"},
+	InstallerInstallShield: {`/s /v"/qn REBOOT=ReallySuppress"`, "/s"},
+	InstallerWixBurn:       {"/quiet /norestart", "/passive /norestart"},
+}
+
+// frameworkUninstallSwitches are the silent switches of the uninstallers of known
+// installer frameworks (InstallShield uninstall command lines differ per product)
+var frameworkUninstallSwitches = map[InstallerType]string{
+	InstallerInno:    "/VERYSILENT /SUPPRESSMSGBOXES /NORESTART",
+	InstallerNSIS:    "/S",
+	InstallerWixBurn: "/quiet /norestart",
+}
+
+// commonSwitches are tried for every installer, after the framework switches
+var commonSwitches = []string{
+	"/S",
+	"/s",
+	"/quiet /norestart",
+	"/silent",
+	"/VERYSILENT /SUPPRESSMSGBOXES /NORESTART",
+	"/qn",
+	"-s",
+	"-q",
+	"--silent",
+	"/passive",
+}
+
+// Result is the outcome of running the installer with one candidate command line
+type Result struct {
+	Args       string `json:"args"`
+	ExitCode   *int   `json:"exitCode"`
+	Window     string `json:"window"`
+	TimedOut   bool   `json:"timedOut"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// Silent reports whether the candidate finished successfully without showing a window
+func (r Result) Silent() bool {
+	return !r.TimedOut && r.Window == "" && r.ExitCode != nil && (*r.ExitCode == 0 || *r.ExitCode == 3010)
+}
+
+// DetectInstallerType guesses the installer framework from strings embedded in the EXE
+func DetectInstallerType(setupPath string) (InstallerType, error) {
+	data, err := os.ReadFile(setupPath)
+	if err != nil {
+		return InstallerUnknown, fmt.Errorf("failed to read setup file: %w", err)
+	}
+	for _, m := range installerMarkers {
+		if bytes.Contains(data, []byte(m.marker)) {
+			return m.kind, nil
+		}
+	}
+	return InstallerUnknown, nil
+}
+
+// Candidates returns the silent switch candidates to try, most likely first
+func Candidates(kind InstallerType) []string {
+	seen := make(map[string]bool)
+	var candidates []string
+	for _, list := range [][]string{frameworkSwitches[kind], commonSwitches} {
+		for _, c := range list {
+			if !seen[c] {
+				seen[c] = true
+				candidates = append(candidates, c)
+			}
+		}
+	}
+	return candidates
+}
+
+// UninstallSwitches returns the silent switches of the uninstaller of an installer
+// framework, or "" when they are not known
+func UninstallSwitches(kind InstallerType) string {
+	return frameworkUninstallSwitches[kind]
+}
+
+// Suggest returns the first candidate that installed silently, or nil
+func Suggest(results []Result) *Result {
+	for i := range results {
+		if results[i].Silent() {
+			return &results[i]
+		}
+	}
+	return nil
+}
+
+// probeScriptTemplate runs each candidate inside the sandbox, stopping at the first silent install
+// Results are rewritten after every candidate so partial results survive a timeout
+const probeScriptTemplate = `$ErrorActionPreference = 'Continue'
+$setup = {{ps .Setup}}
+$timeout = {{.TimeoutSeconds}}
+$work = {{ps .WorkDir}}
+$candidates = @(
+{{- range $i, $c := .Candidates}}{{if $i}},{{end}}
+    {{ps $c}}
+{{- end}}
+)
+
+$results = @()
+foreach ($candidate in $candidates) {
+    $start = Get-Date
+    $process = Start-Process -FilePath $setup -ArgumentList $candidate -PassThru
+    $null = $process.Handle
+    $window = ''
+    $timedOut = $false
+
+    while (-not $process.HasExited) {
+        if (((Get-Date) - $start).TotalSeconds -gt $timeout) {
+            $timedOut = $true
+            break
+        }
+        $visible = Get-Process -ErrorAction SilentlyContinue |
+            Where-Object { $_.MainWindowHandle -ne 0 -and $_.StartTime -ge $start } |
+            Select-Object -First 1
+        if ($visible -and -not $window) {
+            $window = if ($visible.MainWindowTitle) { $visible.MainWindowTitle } else { $visible.ProcessName }
+        }
+        Start-Sleep -Milliseconds 500
+    }
+
+    $exitCode = $null
+    if ($timedOut) {
+        Get-Process -ErrorAction SilentlyContinue |
+            Where-Object { $_.StartTime -ge $start } |
+            Stop-Process -Force -ErrorAction SilentlyContinue
+    } else {
+        $exitCode = $process.ExitCode
+    }
+
+    $results += [pscustomobject]@{
+        args       = $candidate
+        exitCode   = $exitCode
+        window     = $window
+        timedOut   = $timedOut
+        durationMs = [int64]((Get-Date) - $start).TotalMilliseconds
+    }
+    ConvertTo-Json -InputObject @($results) | Set-Content -Path (Join-Path $work '{{.ResultsFile}}') -Encoding UTF8
+
+    if (-not $timedOut -and -not $window -and ($exitCode -eq 0 -or $exitCode -eq 3010)) {
+        break
+    }
+}
+
+New-Item -Path (Join-Path $work '{{.DoneFile}}') -ItemType File -Force | Out-Null
+Stop-Computer -Force
+`
+
+// sandboxConfig is a Windows Sandbox (.wsb) configuration
+type sandboxConfig struct {
+	XMLName       xml.Name       `xml:"Configuration"`
+	VGpu          string         `xml:"VGpu"`
+	Networking    string         `xml:"Networking"`
+	MappedFolders []mappedFolder `xml:"MappedFolders>MappedFolder"`
+	LogonCommand  string         `xml:"LogonCommand>Command"`
+}
+
+// mappedFolder maps a host folder into the sandbox
+type mappedFolder struct {
+	HostFolder    string `xml:"HostFolder"`
+	SandboxFolder string `xml:"SandboxFolder"`
+	ReadOnly      bool   `xml:"ReadOnly"`
+}
+
+// Job describes a probe run prepared in a work folder
+type Job struct {
+	SourceDir  string
+	SetupFile  string
+	WorkDir    string
+	Candidates []string
+	Timeout    time.Duration
+}
+
+// ConfigPath returns the path of the Windows Sandbox configuration of the job
+func (j *Job) ConfigPath() string {
+	return filepath.Join(j.WorkDir, configFile)
+}
+
+// Prepare writes the probe script and sandbox configuration into the work folder
+// The source folder is mapped read-only and networking is disabled
+func (j *Job) Prepare() error {
+	absSource, err := filepath.Abs(j.SourceDir)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	absWork, err := filepath.Abs(j.WorkDir)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	if err := os.MkdirAll(absWork, 0755); err != nil {
+		return fmt.Errorf("failed to create work folder: %w", err)
+	}
+
+	script, err := j.script()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(absWork, scriptFile), script, 0644); err != nil {
+		return fmt.Errorf("failed to write probe script: %w", err)
+	}
+
+	config := sandboxConfig{
+		VGpu:       "Disable",
+		Networking: "Disable",
+		MappedFolders: []mappedFolder{
+			{HostFolder: absSource, SandboxFolder: sandboxSourceDir, ReadOnly: true},
+			{HostFolder: absWork, SandboxFolder: sandboxWorkDir, ReadOnly: false},
+		},
+		LogonCommand: fmt.Sprintf(`powershell.exe -NoProfile -ExecutionPolicy Bypass -File %s\%s`, sandboxWorkDir, scriptFile),
+	}
+	data, err := xml.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to generate sandbox configuration: %w", err)
+	}
+	if err := os.WriteFile(j.ConfigPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write sandbox configuration: %w", err)
+	}
+	return nil
+}
+
+// script renders the PowerShell probe script of the job
+func (j *Job) script() ([]byte, error) {
+	timeout := j.Timeout
+	if timeout <= 0 {
+		timeout = DefaultCandidateTimeout
+	}
+
+	tmpl, err := template.New("probe").Funcs(template.FuncMap{
+		"ps": func(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" },
+	}).Parse(probeScriptTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse probe script template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, map[string]any{
+		"Setup":          sandboxSourceDir + `\` + j.SetupFile,
+		"TimeoutSeconds": int(timeout.Seconds()),
+		"WorkDir":        sandboxWorkDir,
+		"Candidates":     j.Candidates,
+		"ResultsFile":    resultsFile,
+		"DoneFile":       doneFile,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate probe script: %w", err)
+	}
+	return bytes.ReplaceAll(buf.Bytes(), []byte("\n"), []byte("\r\n")), nil
+}
+
+// Done reports whether the probe script has finished
+func (j *Job) Done() bool {
+	_, err := os.Stat(filepath.Join(j.WorkDir, doneFile))
+	return err == nil
+}
+
+// Results reads the results written by the probe script so far
+func (j *Job) Results() ([]Result, error) {
+	data, err := os.ReadFile(filepath.Join(j.WorkDir, resultsFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read probe results: %w", err)
+	}
+
+	// PowerShell writes UTF-8 with a byte order mark
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	var results []Result
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse probe results: %w", err)
+	}
+	return results, nil
+}
+
+// Run prepares the job and probes the installer in Windows Sandbox, waiting until
+// the probe script finishes or ctx is done. Partial results are returned with the
+// error when the wait is cut short
+func Run(ctx context.Context, j *Job) ([]Result, error) {
+	if err := j.Prepare(); err != nil {
+		return nil, err
+	}
+
+	stop, err := launchSandbox(j.ConfigPath())
+	if err != nil {
+		return nil, err
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for !j.Done() {
+		select {
+		case <-ctx.Done():
+			stop()
+			results, _ := j.Results()
+			return results, fmt.Errorf("probe did not finish: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	return j.Results()
+}
@@ -0,0 +1,173 @@
+package probe
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetectInstallerType(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "probe-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	tests := []struct {
+		name    string
+		content string
+		want    InstallerType
+	}{
+		{"inno", "MZ...Inno Setup Setup Data (6.2.0)...", InstallerInno},
+		{"nsis", "MZ...Nullsoft Install System v3.09...", InstallerNSIS},
+		{"burn", "MZ....wixburn...", InstallerWixBurn},
+		{"unknown", "MZ...", InstallerUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tempDir, tt.name+".exe")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write setup file: %v", err)
+			}
+			got, err := DetectInstallerType(path)
+			if err != nil {
+				t.Fatalf("DetectInstallerType failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCandidatesFrameworkFirst(t *testing.T) {
+	candidates := Candidates(InstallerInno)
+	if candidates[0] != "/VERYSILENT /SUPPRESSMSGBOXES /NORESTART" {
+		t.Errorf("Expected Inno Setup switches first, got %q", candidates[0])
+	}
+
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		if seen[c] {
+			t.Errorf("Duplicate candidate %q", c)
+		}
+		seen[c] = true
+	}
+
+	if len(Candidates(InstallerUnknown)) != len(commonSwitches) {
+		t.Errorf("Expected only common switches for unknown installers")
+	}
+}
+
+func TestUninstallSwitches(t *testing.T) {
+	if got := UninstallSwitches(InstallerNSIS); got != "/S" {
+		t.Errorf("Expected /S for NSIS, got %q", got)
+	}
+	if got := UninstallSwitches(InstallerInstallShield); got != "" {
+		t.Errorf("Expected no switches for InstallShield, got %q", got)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	zero, failed, reboot := 0, 1603, 3010
+	results := []Result{
+		{Args: "/S", ExitCode: &failed},
+		{Args: "/silent", ExitCode: &zero, Window: "Setup Wizard"},
+		{Args: "/q", TimedOut: true},
+		{Args: "/quiet /norestart", ExitCode: &reboot},
+	}
+
+	best := Suggest(results)
+	if best == nil || best.Args != "/quiet /norestart" {
+		t.Fatalf("Expected /quiet /norestart to be suggested, got %+v", best)
+	}
+	if Suggest(results[:3]) != nil {
+		t.Errorf("Expected no suggestion without a silent candidate")
+	}
+}
+
+func TestJobPrepare(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "probe-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	job := &Job{
+		SourceDir:  filepath.Join(tempDir, "source"),
+		SetupFile:  "setup.exe",
+		WorkDir:    filepath.Join(tempDir, "work"),
+		Candidates: []string{"/S", `/s /v"/qn"`, "/name='x'"},
+	}
+	if err := job.Prepare(); err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+
+	data, err := os.ReadFile(job.ConfigPath())
+	if err != nil {
+		t.Fatalf("Failed to read sandbox configuration: %v", err)
+	}
+	var config sandboxConfig
+	if err := xml.Unmarshal(data, &config); err != nil {
+		t.Fatalf("Failed to parse sandbox configuration: %v", err)
+	}
+	if config.Networking != "Disable" {
+		t.Errorf("Expected networking to be disabled, got %q", config.Networking)
+	}
+	if len(config.MappedFolders) != 2 || !config.MappedFolders[0].ReadOnly {
+		t.Errorf("Expected a read-only source mapping, got %+v", config.MappedFolders)
+	}
+
+	script, err := os.ReadFile(filepath.Join(job.WorkDir, scriptFile))
+	if err != nil {
+		t.Fatalf("Failed to read probe script: %v", err)
+	}
+	text := string(script)
+	for _, want := range []string{
+		`$setup = 'C:\Probe\Source\setup.exe'`,
+		`$timeout = 120`,
+		`'/s /v"/qn"'`,
+		`'/name=''x'''`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected probe script to contain %q", want)
+		}
+	}
+	if strings.Contains(strings.ReplaceAll(text, "\r\n", ""), "\n") {
+		t.Errorf("Expected CRLF line endings")
+	}
+}
+
+func TestJobResults(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "probe-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	job := &Job{WorkDir: tempDir}
+	results, err := job.Results()
+	if err != nil || results != nil {
+		t.Fatalf("Expected no results before the probe writes any, got %v, %v", results, err)
+	}
+
+	data := "\xef\xbb\xbf" + `[{"args":"/S","exitCode":0,"window":"","timedOut":false,"durationMs":1500},` +
+		`{"args":"/q","exitCode":null,"window":"","timedOut":true,"durationMs":120000}]`
+	if err := os.WriteFile(filepath.Join(tempDir, resultsFile), []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write results: %v", err)
+	}
+
+	results, err = job.Results()
+	if err != nil {
+		t.Fatalf("Results failed: %v", err)
+	}
+	if len(results) != 2 || !results[0].Silent() || results[1].ExitCode != nil {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+	if job.Done() {
+		t.Errorf("Expected job not to be done without the done marker")
+	}
+}
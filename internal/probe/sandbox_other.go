@@ -0,0 +1,10 @@
+//go:build !windows
+
+package probe
+
+import "fmt"
+
+// launchSandbox is only supported on Windows
+func launchSandbox(configPath string) (func(), error) {
+	return nil, fmt.Errorf("probing requires Windows Sandbox on Windows 10/11 Pro or Enterprise")
+}
@@ -0,0 +1,27 @@
+package probe
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// launchSandbox starts Windows Sandbox with a .wsb configuration
+// The returned function closes the sandbox window if the probe is abandoned
+func launchSandbox(configPath string) (func(), error) {
+	exe, err := exec.LookPath("WindowsSandbox.exe")
+	if err != nil {
+		return nil, fmt.Errorf("Windows Sandbox is not available (enable the \"Windows Sandbox\" optional feature): %w", err)
+	}
+
+	cmd := exec.Command(exe, configPath)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start Windows Sandbox: %w", err)
+	}
+	go cmd.Wait()
+
+	return func() {
+		// The launcher may already have handed off to the sandbox client
+		exec.Command("taskkill", "/IM", "WindowsSandboxClient.exe", "/F").Run()
+		cmd.Process.Kill()
+	}, nil
+}
@@ -0,0 +1,353 @@
+// Package s3 is a minimal client for S3-compatible object storage (AWS S3, MinIO, Ceph,
+// Wasabi, ...) using path-style addressing and Signature Version 4
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultRegion is used when no region is configured
+	DefaultRegion = "us-east-1"
+	// DefaultPartSize is the size of the parts large files are uploaded in
+	DefaultPartSize = 64 << 20
+)
+
+var (
+	// ErrNotFound is returned when an object does not exist
+	ErrNotFound = errors.New("object not found")
+	// ErrConditionFailed is returned when a conditional write lost a race with another writer
+	ErrConditionFailed = errors.New("object was changed by another writer")
+)
+
+// Credentials are the access keys requests are signed with
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set for temporary credentials
+	SessionToken string
+}
+
+// CredentialsFromEnv reads credentials from the standard AWS environment variables
+func CredentialsFromEnv() (Credentials, error) {
+	creds := Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return creds, fmt.Errorf("S3 credentials not set (AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY)")
+	}
+	return creds, nil
+}
+
+// RegionFromEnv returns the region of AWS_REGION or AWS_DEFAULT_REGION, or DefaultRegion
+func RegionFromEnv() string {
+	for _, name := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := os.Getenv(name); region != "" {
+			return region
+		}
+	}
+	return DefaultRegion
+}
+
+// EndpointFromEnv returns the endpoint of AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL, or
+// the AWS endpoint of region
+func EndpointFromEnv(region string) string {
+	for _, name := range []string{"AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"} {
+		if endpoint := os.Getenv(name); endpoint != "" {
+			return endpoint
+		}
+	}
+	return "https://s3." + region + ".amazonaws.com"
+}
+
+// Client sends S3 requests to one endpoint
+type Client struct {
+	endpoint   *url.URL
+	region     string
+	creds      Credentials
+	httpClient *http.Client
+	partSize   int
+}
+
+// NewClient creates a client for an endpoint such as https://s3.eu-west-1.amazonaws.com
+// or https://minio.example.com:9000 that sends its requests through transport (nil:
+// http.DefaultTransport)
+func NewClient(endpoint, region string, creds Credentials, transport http.RoundTripper) (*Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint: %s", endpoint)
+	}
+	if region == "" {
+		region = DefaultRegion
+	}
+	return &Client{
+		endpoint:   u,
+		region:     region,
+		creds:      creds,
+		httpClient: &http.Client{Timeout: 10 * time.Minute, Transport: transport},
+		partSize:   DefaultPartSize,
+	}, nil
+}
+
+// Endpoint returns the endpoint URL of the client
+func (c *Client) Endpoint() string {
+	return c.endpoint.String()
+}
+
+// Get downloads an object and returns its content and ETag
+func (c *Client) Get(ctx context.Context, bucket, key string) ([]byte, string, error) {
+	resp, err := c.send(ctx, http.MethodGet, bucket, key, nil, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, "", ErrNotFound
+	default:
+		return nil, "", storageError("download", resp)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download object: %w", err)
+	}
+	return data, resp.Header.Get("ETag"), nil
+}
+
+// Put uploads a small object in one request, only if it still has the ETag ifMatch
+// An empty ifMatch requires that the object does not exist yet; ErrConditionFailed
+// is returned when the precondition does not hold
+func (c *Client) Put(ctx context.Context, bucket, key string, data []byte, contentType, ifMatch string) error {
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	if ifMatch == "" {
+		header.Set("If-None-Match", "*")
+	} else {
+		header.Set("If-Match", ifMatch)
+	}
+
+	resp, err := c.send(ctx, http.MethodPut, bucket, key, nil, data, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusPreconditionFailed, http.StatusConflict:
+		return ErrConditionFailed
+	default:
+		return storageError("upload", resp)
+	}
+}
+
+// UploadFile uploads a local file, replacing the object if it exists
+// Files larger than one part are sent as a multipart upload, so files of any size are
+// sent without holding them in memory
+func (c *Client) UploadFile(ctx context.Context, bucket, key, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	if info.Size() <= int64(c.partSize) {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		header := http.Header{}
+		header.Set("Content-Type", "application/octet-stream")
+		resp, err := c.send(ctx, http.MethodPut, bucket, key, nil, data, header)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return storageError("upload", resp)
+		}
+		return nil
+	}
+
+	uploadID, err := c.createMultipartUpload(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	if err := c.uploadParts(ctx, bucket, key, uploadID, file, path); err != nil {
+		// Abort so the parts uploaded so far are not kept (and billed)
+		if resp, abortErr := c.send(context.Background(), http.MethodDelete, bucket, key, url.Values{"uploadId": {uploadID}}, nil, nil); abortErr == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	return nil
+}
+
+// completedPart is a part of a multipart upload
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// completeMultipartUpload is the body of a Complete Multipart Upload request
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+// createMultipartUpload starts a multipart upload and returns its ID
+func (c *Client) createMultipartUpload(ctx context.Context, bucket, key string) (string, error) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/octet-stream")
+	resp, err := c.send(ctx, http.MethodPost, bucket, key, url.Values{"uploads": {""}}, []byte{}, header)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", storageError("start upload of", resp)
+	}
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil || result.UploadID == "" {
+		return "", fmt.Errorf("failed to start upload: invalid response")
+	}
+	return result.UploadID, nil
+}
+
+// uploadParts sends a file part by part and completes the multipart upload
+func (c *Client) uploadParts(ctx context.Context, bucket, key, uploadID string, file io.Reader, path string) error {
+	var parts []completedPart
+	buf := make([]byte, c.partSize)
+	for {
+		n, readErr := io.ReadFull(file, buf)
+		if n > 0 {
+			number := len(parts) + 1
+			query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+			resp, err := c.send(ctx, http.MethodPut, bucket, key, query, buf[:n], nil)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return storageError("upload part of", resp)
+			}
+			parts = append(parts, completedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return fmt.Errorf("failed to read %s: %w", path, readErr)
+		}
+	}
+
+	body, err := xml.Marshal(completeMultipartUpload{Parts: parts})
+	if err != nil {
+		return fmt.Errorf("failed to encode part list: %w", err)
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/xml")
+	resp, err := c.send(ctx, http.MethodPost, bucket, key, url.Values{"uploadId": {uploadID}}, body, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Completion can fail after the 200 status was sent; the error is then in the body
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || bytes.Contains(data, []byte("<Error>")) {
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+		return storageError("complete upload of", resp)
+	}
+	return nil
+}
+
+// Delete removes an object; deleting a missing object is not an error
+func (c *Client) Delete(ctx context.Context, bucket, key string) error {
+	resp, err := c.send(ctx, http.MethodDelete, bucket, key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusOK, http.StatusNotFound:
+		return nil
+	default:
+		return storageError("delete", resp)
+	}
+}
+
+// objectURL returns the path-style URL of an object
+func (c *Client) objectURL(bucket, key string, query url.Values) *url.URL {
+	u := *c.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + bucket + "/" + strings.TrimPrefix(key, "/")
+	// The path is sent exactly as it is signed
+	u.RawPath = escapePath(u.Path)
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+// send signs and performs a request; bodies are signed with their hash
+func (c *Client) send(ctx context.Context, method, bucket, key string, query url.Values, body []byte, header http.Header) (*http.Response, error) {
+	target := c.objectURL(bucket, key, query)
+	var reader io.Reader
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		reader = bytes.NewReader(body)
+		payloadHash = hashHex(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	c.sign(req, payloadHash, time.Now())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, target.Redacted(), err)
+	}
+	slog.Debug("s3 request", "method", method, "bucket", bucket, "key", key, "status", resp.StatusCode)
+	return resp, nil
+}
+
+// storageError converts an unexpected S3 response into an error
+func storageError(action string, resp *http.Response) error {
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	data, _ := io.ReadAll(resp.Body)
+	if xml.Unmarshal(data, &body) == nil && body.Code != "" {
+		return fmt.Errorf("failed to %s object (%d %s): %s", action, resp.StatusCode, body.Code, strings.TrimSpace(body.Message))
+	}
+	return fmt.Errorf("failed to %s object: storage returned %s", action, resp.Status)
+}
@@ -0,0 +1,124 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+const (
+	// defenderThreatsFound is the exit code of MpCmdRun.exe -Scan when it found threats
+	defenderThreatsFound = 2
+	// defenderScanTypeFile is the -ScanType of a scan of one file
+	defenderScanTypeFile = "3"
+
+	defenderExecutable = "MpCmdRun.exe"
+	// defenderPlatformsPath holds a folder per Defender platform version under ProgramData
+	defenderPlatformsPath = `Microsoft\Windows Defender\Platform`
+)
+
+var (
+	// defenderThreatLine matches the name of a threat in the output of MpCmdRun.exe
+	defenderThreatLine = regexp.MustCompile(`(?m)^Threat\s*:\s*(.+?)\s*$`)
+	// defenderFoundLine matches the number of threats found in the output of MpCmdRun.exe
+	defenderFoundLine = regexp.MustCompile(`found (\d+) threats?`)
+)
+
+// Defender scans files with the MpCmdRun.exe of Microsoft Defender, without removing
+// what it detects
+type Defender struct {
+	// Path is MpCmdRun.exe (the one of the latest Defender platform when empty)
+	Path string
+}
+
+// Scan scans the file at path
+// Detections counts the threats found
+func (d *Defender) Scan(ctx context.Context, path string) (*packager.ScanVerdict, error) {
+	exe := d.Path
+	if exe == "" {
+		var err error
+		if exe, err = defenderPath(); err != nil {
+			return nil, err
+		}
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, exe, "-Scan", "-ScanType", defenderScanTypeFile, "-File", abs, "-DisableRemediation")
+	output, err := cmd.CombinedOutput()
+	verdict := &packager.ScanVerdict{Scanner: ScannerDefender, Known: true, ScannedAt: time.Now().UTC()}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return verdict, nil
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == defenderThreatsFound:
+		verdict.Threats = defenderThreats(string(output))
+		verdict.Detections = len(verdict.Threats)
+		if m := defenderFoundLine.FindStringSubmatch(string(output)); m != nil {
+			if n, err := strconv.Atoi(m[1]); err == nil && n > verdict.Detections {
+				verdict.Detections = n
+			}
+		}
+		// MpCmdRun reports threats with exit code 2 even when it cannot name them
+		verdict.Detections = max(verdict.Detections, 1)
+		return verdict, nil
+	}
+	return nil, fmt.Errorf("Defender scan failed: %w: %s", err, lastLine(string(output)))
+}
+
+// defenderThreats returns the names of the threats in the output of MpCmdRun.exe, sorted
+func defenderThreats(output string) []string {
+	var threats []string
+	for _, m := range defenderThreatLine.FindAllStringSubmatch(strings.ReplaceAll(output, "\r\n", "\n"), -1) {
+		threats = append(threats, m[1])
+	}
+	sort.Strings(threats)
+	return threats
+}
+
+// defenderPath returns the MpCmdRun.exe of the latest Defender platform, or else the one
+// in Program Files
+func defenderPath() (string, error) {
+	if runtime.GOOS != "windows" {
+		return "", fmt.Errorf("Defender scans need Windows (%s)", defenderExecutable)
+	}
+	if programData := os.Getenv("ProgramData"); programData != "" {
+		// Platform updates install next to each other; the newest is the one in use
+		platforms, _ := filepath.Glob(filepath.Join(programData, defenderPlatformsPath, "*", defenderExecutable))
+		var newest string
+		var newestTime time.Time
+		for _, path := range platforms {
+			if info, err := os.Stat(path); err == nil && info.ModTime().After(newestTime) {
+				newest, newestTime = path, info.ModTime()
+			}
+		}
+		if newest != "" {
+			return newest, nil
+		}
+	}
+	path := filepath.Join(os.Getenv("ProgramFiles"), "Windows Defender", defenderExecutable)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("Microsoft Defender not found: %w", err)
+	}
+	return path, nil
+}
+
+// lastLine returns the last non-empty line of output
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(strings.ReplaceAll(output, "\r\n", "\n")), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,40 @@
+// Package scan checks setup files for malware before they are packaged, by looking their
+// hash up on VirusTotal or scanning them with Microsoft Defender
+package scan
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// Scanner names
+const (
+	// ScannerVirusTotal looks the SHA256 of the setup file up on VirusTotal; the file
+	// itself is never uploaded
+	ScannerVirusTotal = "virustotal"
+	// ScannerDefender scans the setup file with the MpCmdRun.exe of Microsoft Defender
+	ScannerDefender = "defender"
+)
+
+// Scanners lists the supported scanners
+var Scanners = []string{ScannerVirusTotal, ScannerDefender}
+
+// New returns the scanner of a name
+// apiKey is the VirusTotal API key and transport sends its requests (nil:
+// http.DefaultTransport); both are unused by Defender
+func New(name, apiKey string, transport http.RoundTripper) (packager.Scanner, error) {
+	switch strings.ToLower(name) {
+	case ScannerVirusTotal:
+		if apiKey == "" {
+			return nil, fmt.Errorf("VirusTotal scans need an API key")
+		}
+		return &VirusTotal{APIKey: apiKey, HTTPClient: &http.Client{Timeout: 30 * time.Second, Transport: transport}}, nil
+	case ScannerDefender:
+		return &Defender{}, nil
+	}
+	return nil, fmt.Errorf("unknown scanner %q (supported: %s)", name, strings.Join(Scanners, ", "))
+}
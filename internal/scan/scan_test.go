@@ -0,0 +1,138 @@
+package scan
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// setupFile writes a setup file and returns its path and SHA256
+func setupFile(t *testing.T) (string, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "setup.exe")
+	if err := os.WriteFile(path, []byte("setup"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := packager.FileSHA256(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, hex.EncodeToString(digest)
+}
+
+const virusTotalReport = `{"data":{"attributes":{
+	"last_analysis_date":1760000000,
+	"last_analysis_stats":{"malicious":2,"suspicious":1,"undetected":60,"harmless":0,"type-unsupported":9},
+	"last_analysis_results":{
+		"EngineB":{"category":"malicious","result":"Trojan.Generic"},
+		"EngineA":{"category":"malicious","result":"Win32.Agent"},
+		"EngineC":{"category":"undetected","result":null}}}}}`
+
+func TestVirusTotalReport(t *testing.T) {
+	path, sha := setupFile(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/files/"+sha || r.Header.Get("x-apikey") != "key" {
+			t.Errorf("request %s with key %q, want the SHA256 of the file and the API key", r.URL.Path, r.Header.Get("x-apikey"))
+		}
+		w.Write([]byte(virusTotalReport))
+	}))
+	defer server.Close()
+
+	verdict, err := (&VirusTotal{APIKey: "key", BaseURL: server.URL}).Scan(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if !verdict.Known || verdict.Detections != 2 || verdict.Engines != 63 || verdict.SHA256 != sha {
+		t.Errorf("Scan() = %+v, want 2 of 63 engines", verdict)
+	}
+	if want := []string{"EngineA: Win32.Agent", "EngineB: Trojan.Generic"}; strings.Join(verdict.Threats, "|") != strings.Join(want, "|") {
+		t.Errorf("Threats = %v, want %v", verdict.Threats, want)
+	}
+	if got := verdict.String(); got != "virustotal: 2 of 63 engines" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestVirusTotalUnknownFileAndErrors(t *testing.T) {
+	path, _ := setupFile(t)
+	status := http.StatusNotFound
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	vt := &VirusTotal{APIKey: "key", BaseURL: server.URL}
+
+	verdict, err := vt.Scan(context.Background(), path)
+	if err != nil || verdict.Known || verdict.Detections != 0 {
+		t.Fatalf("Scan() of an unknown file = %+v, %v, want an unknown verdict", verdict, err)
+	}
+
+	for code, want := range map[int]string{
+		http.StatusUnauthorized:        "API key",
+		http.StatusTooManyRequests:     "quota",
+		http.StatusInternalServerError: "500",
+	} {
+		status = code
+		if _, err := vt.Scan(context.Background(), path); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Scan() with status %d error = %v, want it to mention %q", code, err, want)
+		}
+	}
+}
+
+// fakeMpCmdRun writes a script standing in for MpCmdRun.exe
+func fakeMpCmdRun(t *testing.T, output string, exitCode string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the stand-in for MpCmdRun.exe is a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "MpCmdRun")
+	script := "#!/bin/sh\ncat <<'EOF'\n" + output + "\nEOF\nexit " + exitCode + "\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDefenderScan(t *testing.T) {
+	path, _ := setupFile(t)
+
+	clean := fakeMpCmdRun(t, "Scan starting...\nScan finished.\nScanning "+path+" found no threats.", "0")
+	verdict, err := (&Defender{Path: clean}).Scan(context.Background(), path)
+	if err != nil || !verdict.Known || verdict.Detections != 0 {
+		t.Fatalf("Scan() of a clean file = %+v, %v", verdict, err)
+	}
+
+	threats := fakeMpCmdRun(t, "Scanning "+path+" found 1 threats.\r\n"+
+		"----------------------------- Threat information ------------------------------\r\n"+
+		"Threat                  : Virus:DOS/EICAR_Test_File\r\n"+
+		"Resources               : 1 total", "2")
+	verdict, err = (&Defender{Path: threats}).Scan(context.Background(), path)
+	if err != nil || verdict.Detections != 1 || len(verdict.Threats) != 1 || verdict.Threats[0] != "Virus:DOS/EICAR_Test_File" {
+		t.Fatalf("Scan() of a detected file = %+v, %v", verdict, err)
+	}
+
+	broken := fakeMpCmdRun(t, "ERROR: Scan failed", "5")
+	if _, err := (&Defender{Path: broken}).Scan(context.Background(), path); err == nil || !strings.Contains(err.Error(), "Scan failed") {
+		t.Errorf("Scan() error = %v, want the failure of MpCmdRun", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New("virustotal", "", nil); err == nil {
+		t.Error("New(virustotal) without an API key succeeded")
+	}
+	if _, err := New("clamav", "", nil); err == nil || !strings.Contains(err.Error(), "virustotal, defender") {
+		t.Errorf("New(clamav) error = %v, want the supported scanners", err)
+	}
+	if s, err := New("Defender", "", nil); err != nil || s == nil {
+		t.Errorf("New(Defender) = %v, %v", s, err)
+	}
+}
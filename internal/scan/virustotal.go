@@ -0,0 +1,115 @@
+package scan
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+const (
+	// DefaultVirusTotalURL is the base URL of the VirusTotal API
+	DefaultVirusTotalURL = "https://www.virustotal.com/api/v3"
+	// virusTotalReportURL is the page of the report of a file, by SHA256
+	virusTotalReportURL = "https://www.virustotal.com/gui/file/"
+)
+
+// VirusTotal looks files up on VirusTotal by SHA256
+// Files VirusTotal has never seen are reported as unknown, with no detections
+type VirusTotal struct {
+	APIKey string
+	// BaseURL is the API to call (DefaultVirusTotalURL when empty)
+	BaseURL string
+	// HTTPClient sends the requests (a client with a 30s timeout when nil)
+	HTTPClient *http.Client
+}
+
+// virusTotalFile is the part of a VirusTotal file object the verdict is made of
+type virusTotalFile struct {
+	Data struct {
+		Attributes struct {
+			LastAnalysisDate  int64 `json:"last_analysis_date"`
+			LastAnalysisStats struct {
+				Malicious  int `json:"malicious"`
+				Suspicious int `json:"suspicious"`
+				Undetected int `json:"undetected"`
+				Harmless   int `json:"harmless"`
+			} `json:"last_analysis_stats"`
+			LastAnalysisResults map[string]struct {
+				Category string `json:"category"`
+				Result   string `json:"result"`
+			} `json:"last_analysis_results"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// Scan looks the SHA256 of the file at path up
+// Detections counts the engines rating the file malicious
+func (v *VirusTotal) Scan(ctx context.Context, path string) (*packager.ScanVerdict, error) {
+	digest, err := packager.FileSHA256(path)
+	if err != nil {
+		return nil, err
+	}
+	sha := hex.EncodeToString(digest)
+	verdict := &packager.ScanVerdict{Scanner: ScannerVirusTotal, SHA256: sha, Link: virusTotalReportURL + sha}
+
+	base := strings.TrimSuffix(v.BaseURL, "/")
+	if base == "" {
+		base = DefaultVirusTotalURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/files/"+sha, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-apikey", v.APIKey)
+	req.Header.Set("Accept", "application/json")
+
+	client := v.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("VirusTotal lookup failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		verdict.ScannedAt = time.Now().UTC()
+		return verdict, nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, fmt.Errorf("VirusTotal rejected the API key (%s)", resp.Status)
+	case http.StatusTooManyRequests:
+		return nil, fmt.Errorf("VirusTotal quota of the API key exceeded (%s)", resp.Status)
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("VirusTotal lookup failed: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var file virusTotalFile
+	if err := json.NewDecoder(resp.Body).Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to decode VirusTotal report: %w", err)
+	}
+	attrs := file.Data.Attributes
+	stats := attrs.LastAnalysisStats
+	verdict.Known = true
+	verdict.Detections = stats.Malicious
+	verdict.Engines = stats.Malicious + stats.Suspicious + stats.Undetected + stats.Harmless
+	verdict.ScannedAt = time.Unix(attrs.LastAnalysisDate, 0).UTC()
+	for engine, result := range attrs.LastAnalysisResults {
+		if result.Category == "malicious" {
+			verdict.Threats = append(verdict.Threats, fmt.Sprintf("%s: %s", engine, result.Result))
+		}
+	}
+	sort.Strings(verdict.Threats)
+	return verdict, nil
+}
@@ -0,0 +1,87 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// fileFormat identifies secrets files, encrypted like key exports (see packager.SealWithPassphrase)
+const fileFormat = "intunewin-secrets"
+
+// ReadFile decrypts a secrets file and returns its secrets by name
+func ReadFile(path string, passphrase []byte) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+	plaintext, err := packager.OpenWithPassphrase(fileFormat, data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secrets file %s: %w", path, err)
+	}
+	var secrets map[string]string
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets file %s: %w", path, err)
+	}
+	for _, value := range secrets {
+		Default.Add(value)
+	}
+	return secrets, nil
+}
+
+// WriteFile encrypts secrets with a passphrase and writes them to path, readable by the
+// owner only
+func WriteFile(path string, secrets map[string]string, passphrase []byte) error {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return fmt.Errorf("failed to encode secrets: %w", err)
+	}
+	data, err := packager.SealWithPassphrase(fileFormat, plaintext, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt secrets file: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write secrets file: %w", err)
+	}
+	return nil
+}
+
+// Set stores a secret in a secrets file, creating the file when it does not exist
+func Set(path, name, value string, passphrase []byte) error {
+	secrets, err := ReadFile(path, passphrase)
+	if errors.Is(err, os.ErrNotExist) {
+		secrets, err = map[string]string{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	secrets[name] = value
+	return WriteFile(path, secrets, passphrase)
+}
+
+// Delete removes a secret from a secrets file
+func Delete(path, name string, passphrase []byte) error {
+	secrets, err := ReadFile(path, passphrase)
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[name]; !ok {
+		return fmt.Errorf("%s has no secret %q", path, name)
+	}
+	delete(secrets, name)
+	return WriteFile(path, secrets, passphrase)
+}
+
+// Names returns the names of the secrets of a file in order
+func Names(secrets map[string]string) []string {
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
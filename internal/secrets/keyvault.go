@@ -0,0 +1,103 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// KeyVaultScope is the OAuth2 scope of app-only Key Vault tokens
+	KeyVaultScope = "https://vault.azure.net/.default"
+	// KeyVaultResource is the resource of managed identity Key Vault tokens
+	KeyVaultResource = "https://vault.azure.net"
+	// keyVaultAPIVersion is the Key Vault REST API version used
+	keyVaultAPIVersion = "7.4"
+)
+
+// TokenSource returns bearer tokens for Key Vault requests
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// KeyVault reads secrets from Azure Key Vault
+type KeyVault struct {
+	tokens     TokenSource
+	httpClient *http.Client
+}
+
+// NewKeyVault creates a Key Vault client authenticated by tokens that sends its requests
+// through transport (nil: http.DefaultTransport)
+func NewKeyVault(tokens TokenSource, transport http.RoundTripper) *KeyVault {
+	return &KeyVault{tokens: tokens, httpClient: &http.Client{Timeout: 30 * time.Second, Transport: transport}}
+}
+
+// secretURL returns the URL of a secret given as <vault>/<secret>[/<version>], the vault
+// as a name (contoso-kv) or URL (https://contoso-kv.vault.azure.net)
+func secretURL(target string) (string, error) {
+	var vault, path string
+	if rest, ok := strings.CutPrefix(target, "https://"); ok {
+		host, secret, _ := strings.Cut(rest, "/")
+		vault = "https://" + host
+		path = strings.TrimPrefix(secret, "secrets/")
+	} else {
+		name, secret, _ := strings.Cut(target, "/")
+		vault = "https://" + name + ".vault.azure.net"
+		path = secret
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if parts[0] == "" || len(parts) > 2 || strings.HasSuffix(vault, "https://") {
+		return "", fmt.Errorf("invalid Key Vault secret %q: want <vault>/<secret>[/<version>]", target)
+	}
+	u := vault + "/secrets/" + url.PathEscape(parts[0])
+	if len(parts) == 2 {
+		u += "/" + url.PathEscape(parts[1])
+	}
+	return u + "?api-version=" + keyVaultAPIVersion, nil
+}
+
+// Secret returns the current value, or the given version, of a secret
+func (k *KeyVault) Secret(ctx context.Context, target string) (string, error) {
+	u, err := secretURL(target)
+	if err != nil {
+		return "", err
+	}
+	token, err := k.tokens.Token(ctx)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := k.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Key Vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Value string `json:"value"`
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read Key Vault response: %w", err)
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return "", fmt.Errorf("Key Vault returned %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Key Vault returned %s: %s %s", resp.Status, body.Error.Code, body.Error.Message)
+	}
+	return body.Value, nil
+}
@@ -0,0 +1,95 @@
+package secrets
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces secret values in output
+const Redacted = "[REDACTED]"
+
+// minRedactLength is the shortest value redacted; shorter ones would mask ordinary text
+const minRedactLength = 4
+
+// Default is the redactor of the resolved secrets of the process
+var Default = &Redactor{}
+
+// Redactor replaces known secret values in text
+type Redactor struct {
+	mu       sync.RWMutex
+	replacer *strings.Replacer
+	values   map[string]bool
+}
+
+// Add makes a value redacted, as written and as quoted in Go and JSON strings
+// (e.g. by log handlers), so escaped quotes and backslashes do not reveal it
+func (r *Redactor) Add(value string) {
+	if len(value) < minRedactLength {
+		return
+	}
+	quoted := strconv.Quote(value)
+	forms := []string{value, quoted[1 : len(quoted)-1]}
+	if encoded, err := json.Marshal(value); err == nil {
+		forms = append(forms, string(encoded[1:len(encoded)-1]))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.values == nil {
+		r.values = make(map[string]bool)
+	}
+	for _, form := range forms {
+		r.values[form] = true
+	}
+	// The replacer tries values in order, so longer values go first to hide all of a
+	// secret that contains another one
+	sorted := make([]string, 0, len(r.values))
+	for form := range r.values {
+		sorted = append(sorted, form)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i]) != len(sorted[j]) {
+			return len(sorted[i]) > len(sorted[j])
+		}
+		return sorted[i] < sorted[j]
+	})
+	pairs := make([]string, 0, 2*len(sorted))
+	for _, form := range sorted {
+		pairs = append(pairs, form, Redacted)
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+}
+
+// Redact returns s with the secret values replaced by Redacted
+func (r *Redactor) Redact(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.replacer == nil {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
+
+// Writer returns a writer redacting each write to w
+// Values are found within one write, as log handlers write a record at a time
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	return &redactingWriter{r: r, w: w}
+}
+
+// redactingWriter redacts writes to an underlying writer
+type redactingWriter struct {
+	r *Redactor
+	w io.Writer
+}
+
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	redacted := w.r.Redact(string(p))
+	if _, err := io.WriteString(w.w, redacted); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,121 @@
+// Package secrets resolves secretref: references of template variables from environment
+// variables, Azure Key Vault or an encrypted secrets file, and redacts the resolved values
+// from logs and other output
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Prefix starts the value of a template variable that references a secret
+const Prefix = "secretref:"
+
+// Reference schemes
+const (
+	// SchemeEnv reads an environment variable: secretref:env:NAME
+	SchemeEnv = "env"
+	// SchemeKeyVault reads an Azure Key Vault secret:
+	// secretref:keyvault:<vault>/<secret>[/<version>], the vault as name or URL
+	SchemeKeyVault = "keyvault"
+	// SchemeFile reads a secret of an encrypted secrets file: secretref:file:<path>#<name>
+	SchemeFile = "file"
+)
+
+// IsRef reports whether a value references a secret
+func IsRef(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// Resolver resolves secret references
+// Resolved values are added to the default redactor, so they never reach the logs
+type Resolver struct {
+	// KeyVault reads secretref:keyvault: references (nil fails them)
+	KeyVault *KeyVault
+	// Passphrase returns the passphrase of secrets files (nil fails secretref:file: references)
+	Passphrase func() ([]byte, error)
+
+	// files caches the decrypted secrets files by path
+	files map[string]map[string]string
+}
+
+// Resolve returns the value of a secret reference
+func (r *Resolver) Resolve(ctx context.Context, ref string) (string, error) {
+	scheme, target, ok := strings.Cut(strings.TrimPrefix(ref, Prefix), ":")
+	if !IsRef(ref) || !ok || target == "" {
+		return "", fmt.Errorf("invalid secret reference %q: want %s<scheme>:<name> (schemes: %s, %s, %s)", ref, Prefix, SchemeEnv, SchemeKeyVault, SchemeFile)
+	}
+
+	var value string
+	var err error
+	switch scheme {
+	case SchemeEnv:
+		var set bool
+		if value, set = os.LookupEnv(target); !set {
+			err = fmt.Errorf("environment variable %s is not set", target)
+		}
+	case SchemeKeyVault:
+		if r.KeyVault == nil {
+			return "", fmt.Errorf("%s: Azure Key Vault is not configured", ref)
+		}
+		value, err = r.KeyVault.Secret(ctx, target)
+	case SchemeFile:
+		value, err = r.fileSecret(target)
+	default:
+		return "", fmt.Errorf("unknown secret reference scheme %q (supported: %s, %s, %s)", scheme, SchemeEnv, SchemeKeyVault, SchemeFile)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", ref, err)
+	}
+	Default.Add(value)
+	return value, nil
+}
+
+// ResolveAll replaces the secret references among vars by their values, in place
+func (r *Resolver) ResolveAll(ctx context.Context, vars map[string]string) error {
+	for name, value := range vars {
+		if !IsRef(value) {
+			continue
+		}
+		resolved, err := r.Resolve(ctx, value)
+		if err != nil {
+			return fmt.Errorf("variable %s: %w", name, err)
+		}
+		vars[name] = resolved
+	}
+	return nil
+}
+
+// fileSecret reads a secret of a secrets file, given as <path>#<name>
+// The name follows the last #, so Windows paths with drive letters work
+func (r *Resolver) fileSecret(target string) (string, error) {
+	i := strings.LastIndex(target, "#")
+	if i <= 0 || i == len(target)-1 {
+		return "", fmt.Errorf("want <path>#<name>")
+	}
+	path, name := target[:i], target[i+1:]
+	secrets, ok := r.files[path]
+	if !ok {
+		if r.Passphrase == nil {
+			return "", fmt.Errorf("no passphrase for secrets files")
+		}
+		passphrase, err := r.Passphrase()
+		if err != nil {
+			return "", err
+		}
+		if secrets, err = ReadFile(path, passphrase); err != nil {
+			return "", err
+		}
+		if r.files == nil {
+			r.files = make(map[string]map[string]string)
+		}
+		r.files[path] = secrets
+	}
+	value, ok := secrets[name]
+	if !ok {
+		return "", fmt.Errorf("%s has no secret %q", path, name)
+	}
+	return value, nil
+}
@@ -0,0 +1,209 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// staticToken is a token source returning a fixed token
+type staticToken string
+
+func (t staticToken) Token(ctx context.Context) (string, error) {
+	return string(t), nil
+}
+
+func TestResolveEnv(t *testing.T) {
+	t.Setenv("TEST_LICENSE_KEY", "env-secret-value")
+	var r Resolver
+	value, err := r.Resolve(context.Background(), "secretref:env:TEST_LICENSE_KEY")
+	if err != nil || value != "env-secret-value" {
+		t.Fatalf("Resolve() = %q, %v", value, err)
+	}
+	if got := Default.Redact("key=env-secret-value"); got != "key="+Redacted {
+		t.Errorf("Redact() = %q, want the resolved value redacted", got)
+	}
+
+	tests := map[string]string{
+		"unset":          "secretref:env:TEST_UNSET_SECRET",
+		"no scheme":      "secretref:TEST_LICENSE_KEY",
+		"unknown scheme": "secretref:vault:TEST_LICENSE_KEY",
+		"not a ref":      "TEST_LICENSE_KEY",
+		"no key vault":   "secretref:keyvault:contoso/license",
+		"no passphrase":  "secretref:file:secrets.json#LICENSE",
+	}
+	for name, ref := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := r.Resolve(context.Background(), ref); err == nil {
+				t.Errorf("Resolve(%q) expected error", ref)
+			}
+		})
+	}
+}
+
+func TestResolveAll(t *testing.T) {
+	t.Setenv("TEST_TENANT", "Contoso")
+	vars := map[string]string{"TENANT_NAME": "secretref:env:TEST_TENANT", "PLAIN": "value"}
+	var r Resolver
+	if err := r.ResolveAll(context.Background(), vars); err != nil {
+		t.Fatalf("ResolveAll() error = %v", err)
+	}
+	if vars["TENANT_NAME"] != "Contoso" || vars["PLAIN"] != "value" {
+		t.Errorf("ResolveAll() = %v", vars)
+	}
+	vars["MISSING"] = "secretref:env:TEST_UNSET_SECRET"
+	if err := r.ResolveAll(context.Background(), vars); err == nil || !strings.Contains(err.Error(), "MISSING") {
+		t.Errorf("ResolveAll() error = %v, want the variable name", err)
+	}
+}
+
+func TestSecretsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	passphrase := []byte("correct horse battery")
+
+	if err := Set(path, "LICENSE_KEY", "ABCD-1234-EFGH", passphrase); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := Set(path, "API_TOKEN", "token-5678", passphrase); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if data, _ := os.ReadFile(path); bytes.Contains(data, []byte("ABCD-1234-EFGH")) {
+		t.Error("Secrets file holds the secret in plain text")
+	}
+
+	secrets, err := ReadFile(path, passphrase)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if names := Names(secrets); len(names) != 2 || names[0] != "API_TOKEN" || names[1] != "LICENSE_KEY" {
+		t.Errorf("Names() = %v", names)
+	}
+	if _, err := ReadFile(path, []byte("wrong passphrase")); err == nil {
+		t.Error("Expected error for a wrong passphrase")
+	}
+
+	// Windows paths contain ':' and are split at the last '#'
+	r := Resolver{Passphrase: func() ([]byte, error) { return passphrase, nil }}
+	value, err := r.Resolve(context.Background(), "secretref:file:"+path+"#LICENSE_KEY")
+	if err != nil || value != "ABCD-1234-EFGH" {
+		t.Fatalf("Resolve() = %q, %v", value, err)
+	}
+	if _, err := r.Resolve(context.Background(), "secretref:file:"+path+"#MISSING"); err == nil {
+		t.Error("Expected error for a missing secret")
+	}
+	if _, err := r.Resolve(context.Background(), "secretref:file:"+path); err == nil {
+		t.Error("Expected error for a reference without name")
+	}
+
+	if err := Delete(path, "API_TOKEN", passphrase); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if secrets, _ := ReadFile(path, passphrase); len(secrets) != 1 {
+		t.Errorf("ReadFile() after Delete() = %d secrets, want 1", len(secrets))
+	}
+}
+
+func TestSecretURL(t *testing.T) {
+	tests := map[string]struct {
+		target  string
+		want    string
+		wantErr bool
+	}{
+		"vault name":    {"contoso-kv/license-key", "https://contoso-kv.vault.azure.net/secrets/license-key?api-version=7.4", false},
+		"version":       {"contoso-kv/license-key/0123abcd", "https://contoso-kv.vault.azure.net/secrets/license-key/0123abcd?api-version=7.4", false},
+		"vault url":     {"https://contoso-kv.vault.azure.net/secrets/license-key", "https://contoso-kv.vault.azure.net/secrets/license-key?api-version=7.4", false},
+		"sovereign url": {"https://kv.vault.usgovcloudapi.net/license-key", "https://kv.vault.usgovcloudapi.net/secrets/license-key?api-version=7.4", false},
+		"no secret":     {"contoso-kv", "", true},
+		"too deep":      {"contoso-kv/a/b/c", "", true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := secretURL(tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("secretURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("secretURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyVaultSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"code": "Unauthorized", "message": "no token"}})
+			return
+		}
+		if r.URL.Path != "/secrets/license-key" {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"code": "SecretNotFound", "message": "not found"}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"value": "kv-secret-value"})
+	}))
+	defer server.Close()
+
+	// The test server speaks HTTP, so requests go to it through the transport of the vault
+	vault := NewKeyVault(staticToken("test-token"), rewriteTransport{target: server.URL})
+
+	value, err := vault.Secret(context.Background(), "contoso-kv/license-key")
+	if err != nil || value != "kv-secret-value" {
+		t.Fatalf("Secret() = %q, %v", value, err)
+	}
+	if _, err := vault.Secret(context.Background(), "contoso-kv/missing"); err == nil || !strings.Contains(err.Error(), "SecretNotFound") {
+		t.Errorf("Secret() error = %v, want SecretNotFound", err)
+	}
+}
+
+// rewriteTransport sends requests to a test server instead of their host
+type rewriteTransport struct {
+	target string
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = "http"
+	req.URL.Host = strings.TrimPrefix(t.target, "http://")
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestRedactor(t *testing.T) {
+	var r Redactor
+	if got := r.Redact("nothing to hide"); got != "nothing to hide" {
+		t.Errorf("Redact() without values = %q", got)
+	}
+	r.Add("abc") // too short to redact
+	r.Add(`pa"ss\word`)
+	r.Add("long-secret")
+	r.Add("long-secret-with-suffix")
+
+	tests := map[string]string{
+		`password=pa"ss\word`:         "password=" + Redacted,
+		`password="pa\"ss\\word"`:     `password="` + Redacted + `"`,
+		"abc long-secret-with-suffix": "abc " + Redacted,
+		"token long-secret, other":    "token " + Redacted + ", other",
+		"no secret here":              "no secret here",
+	}
+	for in, want := range tests {
+		if got := r.Redact(in); got != want {
+			t.Errorf("Redact(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	// Log records quote values with special characters
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(r.Writer(&buf), nil))
+	logger.Warn("resolved", "value", `pa"ss\word`, "other", "long-secret")
+	if out := buf.String(); strings.Contains(out, "ss") || strings.Count(out, Redacted) != 2 {
+		t.Errorf("Log output = %q, want both values redacted", out)
+	}
+}
@@ -0,0 +1,313 @@
+// Package selfupdate checks GitHub releases for a newer version and replaces the
+// running binary with a verified release asset
+package selfupdate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultRepository is the GitHub repository releases are published to
+	DefaultRepository = "michelbragaguimaraes/LetsGoIntunePackager"
+	// DefaultAPIURL is the GitHub REST API
+	DefaultAPIURL = "https://api.github.com"
+
+	// BinaryName is the prefix of the release assets, followed by -<os>-<arch>
+	BinaryName = "letsgointunepackager"
+	// ChecksumsName is the release asset listing the SHA256 of every binary
+	ChecksumsName = "SHA256SUMS"
+	// SignatureName is the release asset holding the Ed25519 signature of ChecksumsName
+	SignatureName = "SHA256SUMS.sig"
+
+	// maxAssetSize bounds downloads, far above the size of a release binary
+	maxAssetSize = 256 << 20
+)
+
+// Release is a published GitHub release
+type Release struct {
+	Tag    string  `json:"tag_name"`
+	Name   string  `json:"name"`
+	URL    string  `json:"html_url"`
+	Assets []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+	Size int64  `json:"size"`
+}
+
+// Asset returns the asset with the given name, or nil
+func (r *Release) Asset(name string) *Asset {
+	for i := range r.Assets {
+		if r.Assets[i].Name == name {
+			return &r.Assets[i]
+		}
+	}
+	return nil
+}
+
+// Client reads releases from the GitHub API
+type Client struct {
+	// APIURL is the GitHub API (DefaultAPIURL unless testing)
+	APIURL string
+	// Repository is owner/name of the repository releases are read from
+	Repository string
+	// Token authenticates API requests to raise the rate limit (optional)
+	Token string
+
+	httpClient *http.Client
+}
+
+// NewClient returns a client for the releases of DefaultRepository that downloads
+// through transport (nil: http.DefaultTransport)
+func NewClient(transport http.RoundTripper) *Client {
+	return &Client{
+		APIURL:     DefaultAPIURL,
+		Repository: DefaultRepository,
+		httpClient: &http.Client{Timeout: 5 * time.Minute, Transport: transport},
+	}
+}
+
+// Latest returns the latest published release (drafts and prereleases are skipped by GitHub)
+func (c *Client) Latest(ctx context.Context) (*Release, error) {
+	url := strings.TrimSuffix(c.APIURL, "/") + "/repos/" + c.Repository + "/releases/latest"
+	data, err := c.get(ctx, url, "application/vnd.github+json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for releases: %w", err)
+	}
+	var release Release
+	if err := json.Unmarshal(data, &release); err != nil {
+		return nil, fmt.Errorf("failed to parse release: %w", err)
+	}
+	if release.Tag == "" {
+		return nil, fmt.Errorf("release has no tag")
+	}
+	return &release, nil
+}
+
+// Download fetches the binary for goos/goarch from a release and verifies its SHA256
+// against the checksums asset, whose Ed25519 signature must be valid for publicKey
+// A checksum alone proves nothing when the release itself was tampered with, so a key is
+// required
+func (c *Client) Download(ctx context.Context, release *Release, goos, goarch string, publicKey ed25519.PublicKey) ([]byte, error) {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("no release signing key, the download cannot be verified")
+	}
+	name := AssetName(goos, goarch)
+	binary := release.Asset(name)
+	if binary == nil {
+		return nil, fmt.Errorf("release %s has no binary for %s/%s (%s)", release.Tag, goos, goarch, name)
+	}
+	sumsAsset := release.Asset(ChecksumsName)
+	if sumsAsset == nil {
+		return nil, fmt.Errorf("release %s has no %s, the download cannot be verified", release.Tag, ChecksumsName)
+	}
+
+	sums, err := c.get(ctx, sumsAsset.URL, "application/octet-stream")
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", ChecksumsName, err)
+	}
+	sigAsset := release.Asset(SignatureName)
+	if sigAsset == nil {
+		return nil, fmt.Errorf("release %s has no %s, the download cannot be verified", release.Tag, SignatureName)
+	}
+	sig, err := c.get(ctx, sigAsset.URL, "application/octet-stream")
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", SignatureName, err)
+	}
+	if !ed25519.Verify(publicKey, sums, sig) && !verifyBase64(publicKey, sums, sig) {
+		return nil, fmt.Errorf("signature of %s is not valid for the release signing key", ChecksumsName)
+	}
+	want, err := checksumFor(sums, name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := c.get(ctx, binary.URL, "application/octet-stream")
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return nil, fmt.Errorf("SHA256 of %s is %s, the release lists %s", name, got, want)
+	}
+	return data, nil
+}
+
+// verifyBase64 accepts signatures published as base64 text rather than raw bytes
+func verifyBase64(publicKey ed25519.PublicKey, message, sig []byte) bool {
+	decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(sig)))
+	return err == nil && ed25519.Verify(publicKey, message, decoded)
+}
+
+// get downloads a URL, following redirects to the asset storage
+func (c *Client) get(ctx context.Context, url, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAssetSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxAssetSize {
+		return nil, fmt.Errorf("GET %s returned more than %d bytes", url, maxAssetSize)
+	}
+	return data, nil
+}
+
+// AssetName returns the name of the release binary for a platform
+func AssetName(goos, goarch string) string {
+	name := BinaryName + "-" + goos + "-" + goarch
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// checksumFor finds the SHA256 of a file in sha256sum output ("<hex>  <name>")
+func checksumFor(sums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// sha256sum marks binary mode with a * before the name
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s does not list %s", ChecksumsName, name)
+}
+
+// ParsePublicKey decodes a base64 Ed25519 public key
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid release signing key: want %d base64-encoded bytes", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// Newer reports whether version latest is newer than current
+// Versions are compared as dot-separated numbers after an optional "v"; a version
+// with a pre-release suffix (-rc.1) is older than the same version without one.
+// A current version that is not a version at all ("dev") is always older.
+func Newer(latest, current string) bool {
+	l, lPre, lok := parseVersion(latest)
+	c, cPre, cok := parseVersion(current)
+	if !lok {
+		return false
+	}
+	if !cok {
+		return true
+	}
+	for i := 0; i < max(len(l), len(c)); i++ {
+		var a, b int
+		if i < len(l) {
+			a = l[i]
+		}
+		if i < len(c) {
+			b = c[i]
+		}
+		if a != b {
+			return a > b
+		}
+	}
+	return cPre != "" && (lPre == "" || lPre > cPre)
+}
+
+// IsRelease reports whether a version names a published release rather than a
+// development build ("dev", "v1.2.0-3-gabc1234", "v1.2.0-dirty")
+func IsRelease(version string) bool {
+	_, pre, ok := parseVersion(version)
+	return ok && !strings.Contains(pre, "-") && !strings.Contains(pre, "dirty")
+}
+
+// parseVersion splits "v1.4.2-rc.1" into [1 4 2] and "rc.1"
+func parseVersion(v string) ([]int, string, bool) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	v, pre, _ := strings.Cut(v, "-")
+	var parts []int
+	for _, field := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, "", false
+		}
+		parts = append(parts, n)
+	}
+	return parts, pre, true
+}
+
+// Replace swaps the executable at path for data, keeping its file mode
+// The new binary is written next to the old one and renamed over it; Windows cannot
+// overwrite a running executable, so there the old one is moved aside to path.old first
+// (removed by CleanupOld on the next run)
+func Replace(path string, data []byte, goos string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to locate the running binary: %w", err)
+	}
+	newPath := path + ".new"
+	if err := os.WriteFile(newPath, data, info.Mode().Perm()|0o100); err != nil {
+		return fmt.Errorf("failed to write the new binary: %w", err)
+	}
+
+	if goos == "windows" {
+		oldPath := OldPath(path)
+		os.Remove(oldPath)
+		if err := os.Rename(path, oldPath); err != nil {
+			os.Remove(newPath)
+			return fmt.Errorf("failed to move the running binary aside: %w", err)
+		}
+		if err := os.Rename(newPath, path); err != nil {
+			os.Rename(oldPath, path)
+			os.Remove(newPath)
+			return fmt.Errorf("failed to install the new binary: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.Rename(newPath, path); err != nil {
+		os.Remove(newPath)
+		return fmt.Errorf("failed to install the new binary: %w", err)
+	}
+	return nil
+}
+
+// OldPath returns where Replace moves the previous binary on Windows
+func OldPath(path string) string {
+	return filepath.Clean(path) + ".old"
+}
+
+// CleanupOld removes the binary a previous update left behind on Windows
+func CleanupOld(path string) {
+	os.Remove(OldPath(path))
+}
@@ -0,0 +1,236 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// releaseServer serves a latest release with the given assets
+func releaseServer(t *testing.T, assets map[string][]byte) (*Client, *httptest.Server) {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/"+DefaultRepository+"/releases/latest" {
+			release := Release{Tag: "v1.3.0", URL: server.URL + "/release"}
+			for name, data := range assets {
+				release.Assets = append(release.Assets, Asset{Name: name, URL: server.URL + "/download/" + name, Size: int64(len(data))})
+			}
+			json.NewEncoder(w).Encode(release)
+			return
+		}
+		data, ok := assets[strings.TrimPrefix(r.URL.Path, "/download/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient(nil)
+	client.APIURL = server.URL
+	return client, server
+}
+
+// signedChecksums returns the checksums of files and their signature, made with a new key
+func signedChecksums(t *testing.T, files map[string][]byte) (sums, sig []byte, publicKey ed25519.PublicKey) {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	sums = checksums(files)
+	return sums, ed25519.Sign(privateKey, sums), publicKey
+}
+
+func checksums(files map[string][]byte) []byte {
+	var b strings.Builder
+	for name, data := range files {
+		sum := sha256.Sum256(data)
+		b.WriteString(hex.EncodeToString(sum[:]) + "  " + name + "\n")
+	}
+	return []byte(b.String())
+}
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		latest, current string
+		want            bool
+	}{
+		{"v1.3.0", "v1.2.9", true},
+		{"v1.10.0", "v1.9.0", true},
+		{"v1.3.0", "v1.3.0", false},
+		{"v1.3", "1.3.0", false},
+		{"v1.2.0", "v1.3.0", false},
+		{"v1.3.0", "v1.3.0-rc.1", true},
+		{"v1.3.0-rc.2", "v1.3.0-rc.1", true},
+		{"v1.3.0-rc.1", "v1.3.0", false},
+		{"v1.3.0", "dev", true},
+		{"nightly", "v1.3.0", false},
+	}
+	for _, tt := range tests {
+		if got := Newer(tt.latest, tt.current); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tt.latest, tt.current, got, tt.want)
+		}
+	}
+
+	for version, want := range map[string]bool{"v1.3.0": true, "v1.3.0-rc.1": true, "dev": false, "v1.3.0-4-gabc1234": false, "v1.3.0-dirty": false} {
+		if got := IsRelease(version); got != want {
+			t.Errorf("IsRelease(%q) = %v, want %v", version, got, want)
+		}
+	}
+}
+
+func TestDownloadVerifiesChecksum(t *testing.T) {
+	binary := []byte("new binary")
+	name := AssetName("windows", "amd64")
+	if name != "letsgointunepackager-windows-amd64.exe" {
+		t.Errorf("AssetName() = %s", name)
+	}
+
+	sums, sig, publicKey := signedChecksums(t, map[string][]byte{name: binary})
+	client, _ := releaseServer(t, map[string][]byte{
+		name:          binary,
+		ChecksumsName: sums,
+		SignatureName: sig,
+	})
+	release, err := client.Latest(context.Background())
+	if err != nil {
+		t.Fatalf("Latest() error = %v", err)
+	}
+	if release.Tag != "v1.3.0" {
+		t.Errorf("Tag = %s", release.Tag)
+	}
+	data, err := client.Download(context.Background(), release, "windows", "amd64", publicKey)
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	if string(data) != string(binary) {
+		t.Errorf("Download() = %q", data)
+	}
+
+	if _, err := client.Download(context.Background(), release, "darwin", "arm64", publicKey); err == nil {
+		t.Error("Expected error for a platform without a binary")
+	}
+
+	tampered, _ := releaseServer(t, map[string][]byte{
+		name:          []byte("tampered binary"),
+		ChecksumsName: sums,
+		SignatureName: sig,
+	})
+	release, err = tampered.Latest(context.Background())
+	if err != nil {
+		t.Fatalf("Latest() error = %v", err)
+	}
+	if _, err := tampered.Download(context.Background(), release, "windows", "amd64", publicKey); err == nil || !strings.Contains(err.Error(), "SHA256") {
+		t.Errorf("Download() of a tampered binary error = %v", err)
+	}
+
+	unverifiable, _ := releaseServer(t, map[string][]byte{name: binary, SignatureName: sig})
+	release, err = unverifiable.Latest(context.Background())
+	if err != nil {
+		t.Fatalf("Latest() error = %v", err)
+	}
+	if _, err := unverifiable.Download(context.Background(), release, "windows", "amd64", publicKey); err == nil {
+		t.Error("Expected error for a release without checksums")
+	}
+}
+
+func TestDownloadVerifiesSignature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	parsed, err := ParsePublicKey(base64.StdEncoding.EncodeToString(publicKey))
+	if err != nil {
+		t.Fatalf("ParsePublicKey() error = %v", err)
+	}
+
+	binary := []byte("new binary")
+	name := AssetName("linux", "arm64")
+	sums := checksums(map[string][]byte{name: binary})
+	// Checksums changed after signing, which still match the binary: only the signature
+	// can refuse them
+	forged := append(checksums(map[string][]byte{"letsgointunepackager-linux-amd64": []byte("other")}), sums...)
+
+	for _, tt := range []struct {
+		name    string
+		sums    []byte
+		sig     []byte
+		key     ed25519.PublicKey
+		wantErr bool
+	}{
+		{"raw signature", sums, ed25519.Sign(privateKey, sums), parsed, false},
+		{"base64 signature", sums, []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, sums)) + "\n"), parsed, false},
+		{"other key", sums, ed25519.Sign(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)), sums), parsed, true},
+		{"missing signature", sums, nil, parsed, true},
+		{"forged checksums", forged, ed25519.Sign(privateKey, sums), parsed, true},
+		{"forged checksums signed with other key", forged, ed25519.Sign(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)), forged), parsed, true},
+		{"no key", sums, ed25519.Sign(privateKey, sums), nil, true},
+		{"unsigned without key", sums, nil, nil, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assets := map[string][]byte{name: binary, ChecksumsName: tt.sums}
+			if tt.sig != nil {
+				assets[SignatureName] = tt.sig
+			}
+			client, _ := releaseServer(t, assets)
+			release, err := client.Latest(context.Background())
+			if err != nil {
+				t.Fatalf("Latest() error = %v", err)
+			}
+			_, err = client.Download(context.Background(), release, "linux", "arm64", tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Download() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := ParsePublicKey("c2hvcnQ="); err == nil {
+		t.Error("Expected error for a short key")
+	}
+}
+
+func TestReplace(t *testing.T) {
+	for _, goos := range []string{"linux", "windows"} {
+		t.Run(goos, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "letsgointunepackager")
+			if err := os.WriteFile(path, []byte("old"), 0755); err != nil {
+				t.Fatalf("Failed to write binary: %v", err)
+			}
+			if err := Replace(path, []byte("new"), goos); err != nil {
+				t.Fatalf("Replace() error = %v", err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil || string(data) != "new" {
+				t.Errorf("Binary = %q, %v", data, err)
+			}
+			info, err := os.Stat(path)
+			if err != nil || info.Mode().Perm() != 0755 {
+				t.Errorf("Mode = %v, %v", info.Mode(), err)
+			}
+			if _, err := os.Stat(path + ".new"); !os.IsNotExist(err) {
+				t.Error("Temporary binary left behind")
+			}
+
+			_, err = os.Stat(OldPath(path))
+			if goos == "windows" && err != nil {
+				t.Errorf("Previous binary not kept: %v", err)
+			}
+			CleanupOld(path)
+			if _, err := os.Stat(OldPath(path)); !os.IsNotExist(err) {
+				t.Error("CleanupOld() left the previous binary")
+			}
+		})
+	}
+}
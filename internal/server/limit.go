@@ -0,0 +1,120 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrQueueFull is returned by Submit when the job queue has no room left
+var ErrQueueFull = errors.New("job queue is full")
+
+// ErrPackageTooLarge is returned by Submit when the source of a job exceeds
+// Config.MaxPackageSize
+var ErrPackageTooLarge = errors.New("package too large")
+
+// maxTenantBuckets is the number of tenant buckets kept before the full ones are dropped;
+// a full bucket is the same as a new one
+const maxTenantBuckets = 1024
+
+// RateLimitError is returned by Submit when a tenant, or the service as a whole, has
+// submitted more jobs than its rate allows
+type RateLimitError struct {
+	// Tenant is the tenant that was limited, or empty when the global rate was reached
+	Tenant string
+	// RetryAfter is the time until a job would be accepted
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Tenant == "" {
+		return fmt.Sprintf("too many jobs submitted to the service, retry in %s", e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("too many jobs submitted by %s, retry in %s", e.Tenant, e.RetryAfter.Round(time.Second))
+}
+
+// tokenBucket admits jobs at a steady rate with bursts: it holds up to burst tokens,
+// refills rate tokens per second and each job takes one
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket creates a full bucket; perMinute is the rate in jobs per minute and
+// burst defaults to the jobs of one minute, at least 1
+func newTokenBucket(perMinute float64, burst int, now time.Time) *tokenBucket {
+	if burst < 1 {
+		burst = max(1, int(math.Ceil(perMinute)))
+	}
+	return &tokenBucket{rate: perMinute / 60, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+// refill adds the tokens earned since the last call
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = min(b.burst, b.tokens+elapsed*b.rate)
+	}
+	b.last = now
+}
+
+// wait returns the time until a token is available, 0 when one is
+func (b *tokenBucket) wait(now time.Time) time.Duration {
+	b.refill(now)
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// take removes a token; wait must have returned 0
+func (b *tokenBucket) take() {
+	b.tokens--
+}
+
+// full reports whether the bucket has refilled completely
+func (b *tokenBucket) full(now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= b.burst
+}
+
+// admit takes a token of the tenant and of the service, or returns a *RateLimitError
+// without taking any; s.mu must be held
+func (s *Server) admit(tenant string, now time.Time) error {
+	var tenantBucket *tokenBucket
+	if s.cfg.TenantRate > 0 {
+		tenantBucket = s.tenantBuckets[tenant]
+		if tenantBucket == nil {
+			s.pruneBuckets(now)
+			tenantBucket = newTokenBucket(s.cfg.TenantRate, s.cfg.TenantBurst, now)
+			s.tenantBuckets[tenant] = tenantBucket
+		}
+		if wait := tenantBucket.wait(now); wait > 0 {
+			return &RateLimitError{Tenant: tenant, RetryAfter: wait}
+		}
+	}
+	if s.globalBucket != nil {
+		if wait := s.globalBucket.wait(now); wait > 0 {
+			return &RateLimitError{RetryAfter: wait}
+		}
+		s.globalBucket.take()
+	}
+	if tenantBucket != nil {
+		tenantBucket.take()
+	}
+	return nil
+}
+
+// pruneBuckets drops the full tenant buckets once there are too many; s.mu must be held
+func (s *Server) pruneBuckets(now time.Time) {
+	if len(s.tenantBuckets) < maxTenantBuckets {
+		return
+	}
+	for tenant, bucket := range s.tenantBuckets {
+		if bucket.full(now) {
+			delete(s.tenantBuckets, tenant)
+		}
+	}
+}
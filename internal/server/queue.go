@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"slices"
+)
+
+// enqueue adds a job to the queue of its tenant; s.mu must be held
+func (s *Server) enqueue(job *Job) {
+	if len(s.queues[job.Tenant]) == 0 {
+		s.turns = append(s.turns, job.Tenant)
+	}
+	s.queues[job.Tenant] = append(s.queues[job.Tenant], job)
+	s.queued++
+	s.ready.Broadcast()
+}
+
+// dequeue takes the oldest job of the first tenant in turn that runs fewer than
+// Config.TenantWorkers jobs, and moves the tenant to the back of the turns
+// Returns nil when no tenant with queued jobs may run another; s.mu must be held
+func (s *Server) dequeue() *Job {
+	for i, tenant := range s.turns {
+		if s.cfg.TenantWorkers > 0 && s.running[tenant] >= s.cfg.TenantWorkers {
+			continue
+		}
+		queue := s.queues[tenant]
+		job := queue[0]
+		s.turns = slices.Delete(s.turns, i, i+1)
+		if len(queue) > 1 {
+			s.queues[tenant] = queue[1:]
+			s.turns = append(s.turns, tenant)
+		} else {
+			delete(s.queues, tenant)
+		}
+		s.queued--
+		s.running[tenant]++
+		return job
+	}
+	return nil
+}
+
+// next waits for a job a worker may run, and returns nil once ctx is done
+func (s *Server) next(ctx context.Context) *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ctx.Err() == nil {
+		if job := s.dequeue(); job != nil {
+			return job
+		}
+		s.ready.Wait()
+	}
+	return nil
+}
+
+// finish frees the worker of a job of a tenant, so the next job of the tenant may run
+func (s *Server) finish(job *Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[job.Tenant]--; s.running[job.Tenant] == 0 {
+		delete(s.running, job.Tenant)
+	}
+	s.ready.Broadcast()
+}
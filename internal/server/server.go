@@ -0,0 +1,548 @@
+// Package server runs packaging jobs behind a small REST API and serves the web dashboard
+// that shows the job queue, packaging history and catalog contents with live progress
+package server
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/catalog"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/config"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+//go:embed static
+var staticFiles embed.FS
+
+// Job states
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+const (
+	// maxJobs is the number of finished jobs kept in memory; older ones remain in the history
+	maxJobs = 100
+	// defaultQueueSize is the number of jobs waiting for a worker before new jobs are
+	// refused, unless Config.QueueSize says otherwise
+	defaultQueueSize = 256
+	// subscriberBuffer is the number of events buffered per dashboard; progress events are
+	// dropped for a dashboard that does not keep up
+	subscriberBuffer = 64
+)
+
+// heartbeatInterval keeps idle event streams open through proxies
+const heartbeatInterval = 30 * time.Second
+
+// Job is a packaging job submitted to the server
+type Job struct {
+	ID         string     `json:"id"`
+	Tenant     string     `json:"tenant,omitempty"`
+	SourcePath string     `json:"source"`
+	SetupFile  string     `json:"setup"`
+	OutputPath string     `json:"output"`
+	Status     string     `json:"status"`
+	Step       string     `json:"step,omitempty"`
+	Percent    int        `json:"percent"`
+	Package    string     `json:"package,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Queued     time.Time  `json:"queued"`
+	Started    *time.Time `json:"started,omitempty"`
+	Finished   *time.Time `json:"finished,omitempty"`
+}
+
+// PackageFunc builds the package of a job, reporting progress, and returns its path
+type PackageFunc func(ctx context.Context, job Job, progress packager.ProgressCallback) (string, error)
+
+// Config configures a Server
+type Config struct {
+	// Package builds packages; required
+	Package PackageFunc
+	// Workers is the number of jobs run at the same time (default 1)
+	Workers int
+	// TenantWorkers is the number of jobs of one tenant run at the same time, so that
+	// other tenants get the remaining workers (0 for all workers)
+	TenantWorkers int
+	// DefaultOutput is the output folder of jobs submitted without one (optional)
+	DefaultOutput string
+	// HistoryPath records finished jobs in the packaging history and lists it (optional)
+	HistoryPath string
+	// Catalog is the package repository listed on the dashboard (optional)
+	Catalog catalog.Store
+	// Logger receives errors of the server (optional, defaults to slog.Default())
+	Logger *slog.Logger
+	// Metrics is served at /metrics for Prometheus to scrape (optional)
+	Metrics http.Handler
+	// QueueSize is the number of jobs waiting for a worker before new jobs are refused
+	// (default 256)
+	QueueSize int
+	// TenantRate is the number of jobs per minute each tenant may submit, in bursts of up
+	// to TenantBurst jobs (0 for no limit; the burst defaults to one minute of jobs)
+	TenantRate  float64
+	TenantBurst int
+	// GlobalRate and GlobalBurst limit the jobs submitted by all tenants together the
+	// same way (0 for no limit)
+	GlobalRate  float64
+	GlobalBurst int
+	// TenantHeader is the request header naming the tenant, set by the reverse proxy that
+	// authenticates users (optional; tenants are client addresses without it)
+	TenantHeader string
+	// MaxPackageSize refuses jobs whose source folder is larger, in bytes (0 for no limit)
+	MaxPackageSize int64
+}
+
+// Server queues packaging jobs and publishes their progress to dashboards
+type Server struct {
+	cfg Config
+	log *slog.Logger
+
+	mu          sync.Mutex
+	jobs        []*Job // oldest first
+	nextID      int
+	subscribers map[chan []byte]struct{}
+	historyMu   sync.Mutex
+
+	// Jobs wait in a queue per tenant, and workers take them from the tenants in turn
+	queues  map[string][]*Job
+	turns   []string // tenants with queued jobs, the next one first
+	queued  int
+	running map[string]int // running jobs per tenant
+	ready   *sync.Cond     // broadcast when a job is queued or ends, and when Run stops
+
+	tenantBuckets map[string]*tokenBucket
+	globalBucket  *tokenBucket // nil without a global rate
+}
+
+// New creates a server; call Run to start its workers
+func New(cfg Config) *Server {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.QueueSize < 1 {
+		cfg.QueueSize = defaultQueueSize
+	}
+	log := cfg.Logger
+	if log == nil {
+		log = slog.Default()
+	}
+	s := &Server{
+		cfg:           cfg,
+		log:           log,
+		subscribers:   make(map[chan []byte]struct{}),
+		queues:        make(map[string][]*Job),
+		running:       make(map[string]int),
+		tenantBuckets: make(map[string]*tokenBucket),
+	}
+	s.ready = sync.NewCond(&s.mu)
+	if cfg.GlobalRate > 0 {
+		s.globalBucket = newTokenBucket(cfg.GlobalRate, cfg.GlobalBurst, time.Now())
+	}
+	return s
+}
+
+// Run processes queued jobs until ctx is cancelled, then waits for running jobs
+func (s *Server) Run(ctx context.Context) {
+	// Workers waiting for a job are woken to stop
+	stop := context.AfterFunc(ctx, func() {
+		s.mu.Lock()
+		s.ready.Broadcast()
+		s.mu.Unlock()
+	})
+	defer stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				job := s.next(ctx)
+				if job == nil {
+					return
+				}
+				s.run(ctx, job)
+				s.finish(job)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Submit queues a packaging job of a tenant
+// Jobs over the limits of the Config are refused with ErrPackageTooLarge, a
+// *RateLimitError or ErrQueueFull
+func (s *Server) Submit(tenant, source, setup, output string) (Job, error) {
+	if source == "" || setup == "" {
+		return Job{}, fmt.Errorf("source and setup are required")
+	}
+	if output == "" {
+		output = s.cfg.DefaultOutput
+	}
+	if output == "" {
+		return Job{}, fmt.Errorf("output is required (no default output folder configured)")
+	}
+	if s.cfg.MaxPackageSize > 0 {
+		size, err := packager.GetFolderSize(source)
+		if err != nil {
+			return Job{}, fmt.Errorf("failed to read source: %w", err)
+		}
+		if size > s.cfg.MaxPackageSize {
+			return Job{}, fmt.Errorf("%w: the source is %s, the limit is %s", ErrPackageTooLarge,
+				packager.FormatSize(size), packager.FormatSize(s.cfg.MaxPackageSize))
+		}
+	}
+
+	s.mu.Lock()
+	// The queue is checked first so that refused jobs do not use up the rates
+	if s.queued >= s.cfg.QueueSize {
+		s.mu.Unlock()
+		return Job{}, ErrQueueFull
+	}
+	if err := s.admit(tenant, time.Now()); err != nil {
+		s.mu.Unlock()
+		return Job{}, err
+	}
+	s.nextID++
+	job := &Job{
+		ID:         strconv.Itoa(s.nextID),
+		Tenant:     tenant,
+		SourcePath: source,
+		SetupFile:  setup,
+		OutputPath: output,
+		Status:     StatusQueued,
+		Queued:     time.Now(),
+	}
+	s.enqueue(job)
+	s.jobs = append(s.jobs, job)
+	s.pruneJobs()
+	snapshot := *job
+	s.mu.Unlock()
+
+	s.publish(snapshot)
+	return snapshot, nil
+}
+
+// Jobs returns the known jobs, newest first
+func (s *Server) Jobs() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]Job, 0, len(s.jobs))
+	for i := len(s.jobs) - 1; i >= 0; i-- {
+		jobs = append(jobs, *s.jobs[i])
+	}
+	return jobs
+}
+
+// Job returns the job with the given ID
+func (s *Server) Job(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		if job.ID == id {
+			return *job, true
+		}
+	}
+	return Job{}, false
+}
+
+// pruneJobs drops the oldest finished jobs beyond maxJobs; s.mu must be held
+func (s *Server) pruneJobs() {
+	excess := len(s.jobs) - maxJobs
+	kept := s.jobs[:0]
+	for _, job := range s.jobs {
+		finished := job.Status == StatusSucceeded || job.Status == StatusFailed
+		if excess > 0 && finished {
+			excess--
+			continue
+		}
+		kept = append(kept, job)
+	}
+	s.jobs = kept
+}
+
+// update changes a job under the lock and publishes the result
+func (s *Server) update(job *Job, change func(*Job)) {
+	s.mu.Lock()
+	change(job)
+	snapshot := *job
+	s.mu.Unlock()
+	s.publish(snapshot)
+}
+
+// run builds the package of a job
+func (s *Server) run(ctx context.Context, job *Job) {
+	var snapshot Job
+	s.update(job, func(j *Job) {
+		now := time.Now()
+		j.Status, j.Started = StatusRunning, &now
+		snapshot = *j
+	})
+
+	lastPercent := -1
+	progress := func(step string, percent float64) {
+		p := int(percent * 100)
+		if p == lastPercent {
+			return
+		}
+		lastPercent = p
+		s.update(job, func(j *Job) { j.Step, j.Percent = step, p })
+	}
+
+	packagePath, err := s.cfg.Package(ctx, snapshot, progress)
+
+	// The history is saved before the final update, which makes dashboards reload it
+	s.record(snapshot, packagePath, err)
+	s.update(job, func(j *Job) {
+		now := time.Now()
+		j.Finished = &now
+		if err != nil {
+			j.Status, j.Error = StatusFailed, err.Error()
+			return
+		}
+		j.Status, j.Package, j.Percent = StatusSucceeded, packagePath, 100
+	})
+}
+
+// record adds a finished job to the packaging history
+func (s *Server) record(job Job, packagePath string, err error) {
+	if s.cfg.HistoryPath == "" {
+		return
+	}
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+
+	history, loadErr := config.LoadHistory(s.cfg.HistoryPath)
+	if loadErr != nil {
+		s.log.Warn("could not record job in history", "error", loadErr)
+		return
+	}
+	entry := config.HistoryEntry{
+		SourcePath: job.SourcePath,
+		SetupFile:  job.SetupFile,
+		OutputPath: job.OutputPath,
+		Succeeded:  err == nil,
+		Package:    packagePath,
+		Timestamp:  time.Now(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.GeneratorVersion = packager.GeneratorVersion
+	}
+	history.Add(entry)
+	if saveErr := history.Save(s.cfg.HistoryPath); saveErr != nil {
+		s.log.Warn("could not record job in history", "error", saveErr)
+	}
+}
+
+// publish sends a job update to every connected dashboard
+func (s *Server) publish(job Job) {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subscribers {
+		select {
+		case ch <- data:
+		default:
+		}
+	}
+}
+
+// subscribe registers a dashboard for job updates
+func (s *Server) subscribe() chan []byte {
+	ch := make(chan []byte, subscriberBuffer)
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+	return ch
+}
+
+// unsubscribe removes a dashboard registered with subscribe
+func (s *Server) unsubscribe(ch chan []byte) {
+	s.mu.Lock()
+	delete(s.subscribers, ch)
+	s.mu.Unlock()
+}
+
+// Handler returns the REST API and the dashboard:
+//
+//	GET  /api/jobs       jobs, newest first
+//	POST /api/jobs       queue a job: {"source": ..., "setup": ..., "output": ...}; 413
+//	                     when the source is too large, 429 with Retry-After when the
+//	                     rate is reached and 503 when the queue is full
+//	GET  /api/jobs/{id}  one job
+//	GET  /api/events     job updates as server-sent events
+//	GET  /api/history    the packaging history
+//	GET  /api/catalog    the packages of the catalog
+//	GET  /metrics        Prometheus metrics, when configured
+//	GET  /               the dashboard
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/jobs", s.handleJobs)
+	mux.HandleFunc("POST /api/jobs", s.handleSubmit)
+	mux.HandleFunc("GET /api/jobs/{id}", s.handleJob)
+	mux.HandleFunc("GET /api/events", s.handleEvents)
+	mux.HandleFunc("GET /api/history", s.handleHistory)
+	mux.HandleFunc("GET /api/catalog", s.handleCatalog)
+	if s.cfg.Metrics != nil {
+		mux.Handle("GET /metrics", s.cfg.Metrics)
+	}
+
+	static, _ := fs.Sub(staticFiles, "static")
+	mux.Handle("GET /", http.FileServerFS(static))
+	return mux
+}
+
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Jobs())
+}
+
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	// Requiring JSON makes browsers send a CORS preflight, so other sites cannot submit jobs
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, errors.New("jobs must be submitted as application/json"))
+		return
+	}
+	var req struct {
+		Source string `json:"source"`
+		Setup  string `json:"setup"`
+		Output string `json:"output"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid job: %w", err))
+		return
+	}
+	job, err := s.Submit(s.tenant(r), req.Source, req.Setup, req.Output)
+	var limited *RateLimitError
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusAccepted, job)
+	case errors.As(err, &limited):
+		// Retry-After is in whole seconds, rounded up so that the retry is admitted
+		w.Header().Set("Retry-After", strconv.Itoa(int((limited.RetryAfter+time.Second-1)/time.Second)))
+		writeError(w, http.StatusTooManyRequests, err)
+	case errors.Is(err, ErrQueueFull):
+		writeError(w, http.StatusServiceUnavailable, err)
+	case errors.Is(err, ErrPackageTooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+	default:
+		writeError(w, http.StatusBadRequest, err)
+	}
+}
+
+// tenant returns the tenant of a request: the value of Config.TenantHeader, or the
+// address of the client
+func (s *Server) tenant(r *http.Request) string {
+	if s.cfg.TenantHeader != "" {
+		if tenant := r.Header.Get(s.cfg.TenantHeader); tenant != "" {
+			return tenant
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.Job(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("job not found: %s", r.PathValue("id")))
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming not supported"))
+		return
+	}
+	ch := s.subscribe()
+	defer s.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case data := <-ch:
+			fmt.Fprintf(w, "event: job\ndata: %s\n\n", data)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.HistoryPath == "" {
+		writeJSON(w, http.StatusOK, []config.HistoryEntry{})
+		return
+	}
+	s.historyMu.Lock()
+	history, err := config.LoadHistory(s.cfg.HistoryPath)
+	s.historyMu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	entries := history.Entries
+	if entries == nil {
+		entries = []config.HistoryEntry{}
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *Server) handleCatalog(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.Catalog == nil {
+		writeError(w, http.StatusNotFound, errors.New("no catalog configured"))
+		return
+	}
+	index, _, err := catalog.ReadIndex(r.Context(), s.cfg.Catalog)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	packages := index.Packages
+	if packages == nil {
+		packages = []catalog.Entry{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"repository": s.cfg.Catalog.String(), "packages": packages})
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
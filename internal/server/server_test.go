@@ -0,0 +1,431 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/catalog"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// newTestServer starts a server whose jobs fail for setup files named fail.exe
+func newTestServer(t *testing.T, cfg Config) (*Server, *httptest.Server) {
+	t.Helper()
+	cfg.Package = func(ctx context.Context, job Job, progress packager.ProgressCallback) (string, error) {
+		progress("Compressing files", 0.3)
+		progress("Encrypting", 0.6)
+		if job.SetupFile == "fail.exe" {
+			return "", errors.New("setup file not found")
+		}
+		return filepath.Join(job.OutputPath, strings.TrimSuffix(job.SetupFile, filepath.Ext(job.SetupFile))+".intunewin"), nil
+	}
+	srv := New(cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		srv.Run(ctx)
+		close(done)
+	}()
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(func() {
+		ts.Close()
+		cancel()
+		<-done
+	})
+	return srv, ts
+}
+
+func postJob(t *testing.T, url, body string) (*http.Response, map[string]any) {
+	t.Helper()
+	resp, err := http.Post(url+"/api/jobs", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST /api/jobs error = %v", err)
+	}
+	defer resp.Body.Close()
+	var decoded map[string]any
+	json.NewDecoder(resp.Body).Decode(&decoded)
+	return resp, decoded
+}
+
+func TestJobsAndEvents(t *testing.T) {
+	historyPath := filepath.Join(t.TempDir(), "history.json")
+	_, ts := newTestServer(t, Config{HistoryPath: historyPath, DefaultOutput: "/out"})
+
+	// Subscribe before submitting so every update is received
+	resp, err := http.Get(ts.URL + "/api/events")
+	if err != nil {
+		t.Fatalf("GET /api/events error = %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %s", ct)
+	}
+	events := make(chan Job)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				var job Job
+				json.Unmarshal([]byte(data), &job)
+				events <- job
+			}
+		}
+		close(events)
+	}()
+
+	if r, body := postJob(t, ts.URL, `{"source": "/src/7zip", "setup": "7z2401-x64.msi"}`); r.StatusCode != http.StatusAccepted || body["output"] != "/out" {
+		t.Fatalf("POST /api/jobs = %d %v", r.StatusCode, body)
+	}
+	postJob(t, ts.URL, `{"source": "/src/broken", "setup": "fail.exe", "output": "/other"}`)
+
+	finished := map[string]Job{}
+	var sawProgress bool
+	timeout := time.After(5 * time.Second)
+	for len(finished) < 2 {
+		select {
+		case job, ok := <-events:
+			if !ok {
+				t.Fatal("Event stream closed")
+			}
+			if job.Status == StatusRunning && job.Percent == 60 && job.Step == "Encrypting" {
+				sawProgress = true
+			}
+			if job.Status == StatusSucceeded || job.Status == StatusFailed {
+				finished[job.ID] = job
+			}
+		case <-timeout:
+			t.Fatalf("Jobs did not finish, got %v", finished)
+		}
+	}
+	if !sawProgress {
+		t.Error("No progress event received")
+	}
+	if job := finished["1"]; job.Status != StatusSucceeded || job.Package != filepath.Join("/out", "7z2401-x64.intunewin") || job.Finished == nil {
+		t.Errorf("Job 1 = %+v", job)
+	}
+	if job := finished["2"]; job.Status != StatusFailed || job.Error != "setup file not found" {
+		t.Errorf("Job 2 = %+v", job)
+	}
+
+	var jobs []Job
+	getJSON(t, ts.URL+"/api/jobs", http.StatusOK, &jobs)
+	if len(jobs) != 2 || jobs[0].ID != "2" {
+		t.Errorf("GET /api/jobs = %+v, want newest first", jobs)
+	}
+	var job Job
+	getJSON(t, ts.URL+"/api/jobs/1", http.StatusOK, &job)
+	if job.SetupFile != "7z2401-x64.msi" {
+		t.Errorf("GET /api/jobs/1 = %+v", job)
+	}
+	getJSON(t, ts.URL+"/api/jobs/9", http.StatusNotFound, nil)
+
+	var history []map[string]any
+	getJSON(t, ts.URL+"/api/history", http.StatusOK, &history)
+	if len(history) != 2 {
+		t.Errorf("GET /api/history = %v, want both jobs", history)
+	}
+}
+
+func TestSubmitValidation(t *testing.T) {
+	_, ts := newTestServer(t, Config{})
+
+	if r, _ := postJob(t, ts.URL, `{"source": "/src", "setup": "setup.exe"}`); r.StatusCode != http.StatusBadRequest {
+		t.Errorf("Job without output = %d, want 400", r.StatusCode)
+	}
+	if r, _ := postJob(t, ts.URL, `{"setup": "setup.exe", "output": "/out"}`); r.StatusCode != http.StatusBadRequest {
+		t.Errorf("Job without source = %d, want 400", r.StatusCode)
+	}
+
+	// A form post from another site is refused
+	resp, err := http.Post(ts.URL+"/api/jobs", "text/plain", strings.NewReader(`{"source": "/src", "setup": "setup.exe", "output": "/out"}`))
+	if err != nil {
+		t.Fatalf("POST /api/jobs error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("text/plain job = %d, want 415", resp.StatusCode)
+	}
+}
+
+func TestCatalogAndDashboard(t *testing.T) {
+	_, withoutCatalog := newTestServer(t, Config{})
+	getJSON(t, withoutCatalog.URL+"/api/catalog", http.StatusNotFound, nil)
+
+	repo := t.TempDir()
+	index := catalog.Index{Version: catalog.IndexVersion, Packages: []catalog.Entry{{Name: "7zip/7z2401-x64.intunewin", App: "7-Zip", Version: "24.01", Size: 1024}}}
+	data, _ := json.Marshal(index)
+	if err := os.WriteFile(filepath.Join(repo, catalog.IndexName), data, 0644); err != nil {
+		t.Fatalf("Failed to write index: %v", err)
+	}
+	store, err := catalog.OpenStore(repo, nil)
+	if err != nil {
+		t.Fatalf("OpenStore() error = %v", err)
+	}
+	_, ts := newTestServer(t, Config{Catalog: store})
+
+	var listed struct {
+		Repository string          `json:"repository"`
+		Packages   []catalog.Entry `json:"packages"`
+	}
+	getJSON(t, ts.URL+"/api/catalog", http.StatusOK, &listed)
+	if len(listed.Packages) != 1 || listed.Packages[0].App != "7-Zip" || listed.Repository == "" {
+		t.Errorf("GET /api/catalog = %+v", listed)
+	}
+
+	for path, want := range map[string]string{"/": "<title>LetsGoIntunePackager</title>", "/app.js": "EventSource", "/style.css": "--accent"} {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s error = %v", path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), want) {
+			t.Errorf("GET %s = %d, missing %q", path, resp.StatusCode, want)
+		}
+	}
+}
+
+func getJSON(t *testing.T, url string, wantStatus int, v any) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s error = %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("GET %s = %d, want %d", url, resp.StatusCode, wantStatus)
+	}
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("GET %s returned invalid JSON: %v", url, err)
+		}
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	_, withoutMetrics := newTestServer(t, Config{})
+	resp, err := http.Get(withoutMetrics.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /metrics without metrics = %d, want 404", resp.StatusCode)
+	}
+
+	metrics := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "intunewin_jobs_in_progress 0\n")
+	})
+	_, ts := newTestServer(t, Config{Metrics: metrics})
+	resp, err = http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "intunewin_jobs_in_progress") {
+		t.Errorf("GET /metrics = %d %s", resp.StatusCode, body)
+	}
+}
+
+// postJobAs posts a job for the tenant named in the X-Tenant header
+func postJobAs(t *testing.T, url, tenant, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url+"/api/jobs", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tenant", tenant)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /api/jobs error = %v", err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestSubmitRateLimits(t *testing.T) {
+	const job = `{"source": "/src", "setup": "setup.exe", "output": "/out"}`
+	_, ts := newTestServer(t, Config{TenantRate: 1, TenantBurst: 2, GlobalRate: 1, GlobalBurst: 3, TenantHeader: "X-Tenant"})
+
+	for i := 0; i < 2; i++ {
+		if r := postJobAs(t, ts.URL, "contoso", job); r.StatusCode != http.StatusAccepted {
+			t.Fatalf("Job %d of contoso = %d, want 202", i+1, r.StatusCode)
+		}
+	}
+	r := postJobAs(t, ts.URL, "contoso", job)
+	if r.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Job over the tenant burst = %d, want 429", r.StatusCode)
+	}
+	if retry, err := strconv.Atoi(r.Header.Get("Retry-After")); err != nil || retry < 1 || retry > 60 {
+		t.Errorf("Retry-After = %q, want up to a minute", r.Header.Get("Retry-After"))
+	}
+
+	// Another tenant has its own bucket, until the global one runs out
+	if r := postJobAs(t, ts.URL, "fabrikam", job); r.StatusCode != http.StatusAccepted {
+		t.Errorf("Job of fabrikam = %d, want 202", r.StatusCode)
+	}
+	if r := postJobAs(t, ts.URL, "northwind", job); r.StatusCode != http.StatusTooManyRequests || r.Header.Get("Retry-After") == "" {
+		t.Errorf("Job over the global burst = %d, Retry-After %q, want 429", r.StatusCode, r.Header.Get("Retry-After"))
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	bucket := newTokenBucket(60, 0, now) // one job per second, a minute of jobs at once
+	for i := 0; i < 60; i++ {
+		if wait := bucket.wait(now); wait != 0 {
+			t.Fatalf("Job %d waits %v, want a full burst", i+1, wait)
+		}
+		bucket.take()
+	}
+	if wait := bucket.wait(now); wait != time.Second {
+		t.Errorf("wait() of an empty bucket = %v, want 1s", wait)
+	}
+	if wait := bucket.wait(now.Add(time.Second)); wait != 0 {
+		t.Errorf("wait() a second later = %v, want a token", wait)
+	}
+	if !bucket.full(now.Add(time.Hour)) {
+		t.Error("Bucket should refill completely")
+	}
+}
+
+func TestSubmitQueueFull(t *testing.T) {
+	// Without Run nothing takes jobs from the queue
+	srv := New(Config{QueueSize: 1, DefaultOutput: "/out"})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	if r, _ := postJob(t, ts.URL, `{"source": "/src", "setup": "setup.exe"}`); r.StatusCode != http.StatusAccepted {
+		t.Fatalf("First job = %d, want 202", r.StatusCode)
+	}
+	if r, body := postJob(t, ts.URL, `{"source": "/src", "setup": "setup.exe"}`); r.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Job with a full queue = %d %v, want 503", r.StatusCode, body)
+	}
+}
+
+func TestSubmitMaxPackageSize(t *testing.T) {
+	source := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "setup.exe"), make([]byte, 2048), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	body := `{"source": ` + strconv.Quote(source) + `, "setup": "setup.exe", "output": "/out"}`
+
+	_, small := newTestServer(t, Config{MaxPackageSize: 1024})
+	if r, decoded := postJob(t, small.URL, body); r.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Job over the size limit = %d %v, want 413", r.StatusCode, decoded)
+	}
+	_, large := newTestServer(t, Config{MaxPackageSize: 4096})
+	if r, decoded := postJob(t, large.URL, body); r.StatusCode != http.StatusAccepted {
+		t.Errorf("Job under the size limit = %d %v, want 202", r.StatusCode, decoded)
+	}
+}
+
+// blockingServer returns a server whose jobs report their start on started and run
+// until a value is sent on release
+func blockingServer(cfg Config) (*Server, chan Job, chan struct{}) {
+	started := make(chan Job, 16)
+	release := make(chan struct{})
+	cfg.DefaultOutput = "/out"
+	cfg.Package = func(ctx context.Context, job Job, progress packager.ProgressCallback) (string, error) {
+		started <- job
+		<-release
+		return "/out/setup.intunewin", nil
+	}
+	return New(cfg), started, release
+}
+
+// runServer runs the workers of srv until the test ends
+func runServer(t *testing.T, srv *Server) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		srv.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// nextStarted returns the next job that started, failing after a timeout
+func nextStarted(t *testing.T, started chan Job) Job {
+	t.Helper()
+	select {
+	case job := <-started:
+		return job
+	case <-time.After(10 * time.Second):
+		t.Fatal("No job started")
+	}
+	return Job{}
+}
+
+func TestDispatchTenantsInTurn(t *testing.T) {
+	srv, started, release := blockingServer(Config{})
+	for _, tenant := range []string{"contoso", "contoso", "contoso", "fabrikam"} {
+		if _, err := srv.Submit(tenant, "/src", "setup.exe", ""); err != nil {
+			t.Fatalf("Submit() error = %v", err)
+		}
+	}
+	runServer(t, srv)
+
+	// The job of fabrikam runs before the later jobs of contoso, which was queued first
+	var order []string
+	for i := 0; i < 4; i++ {
+		order = append(order, nextStarted(t, started).Tenant)
+		release <- struct{}{}
+	}
+	if got, want := strings.Join(order, ","), "contoso,fabrikam,contoso,contoso"; got != want {
+		t.Errorf("Jobs ran for %s, want %s", got, want)
+	}
+}
+
+func TestDispatchTenantWorkers(t *testing.T) {
+	srv, started, release := blockingServer(Config{Workers: 3, TenantWorkers: 2})
+	runServer(t, srv)
+	for i := 0; i < 4; i++ {
+		if _, err := srv.Submit("contoso", "/src", "setup.exe", ""); err != nil {
+			t.Fatalf("Submit() error = %v", err)
+		}
+	}
+
+	// contoso runs two jobs; the third worker waits instead of taking another of them
+	nextStarted(t, started)
+	nextStarted(t, started)
+	select {
+	case job := <-started:
+		t.Fatalf("Job %s of contoso started over the tenant limit", job.ID)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// ... and takes the job of another tenant at once
+	if _, err := srv.Submit("fabrikam", "/src", "setup.exe", ""); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if job := nextStarted(t, started); job.Tenant != "fabrikam" {
+		t.Fatalf("Job of %s started, want the one of fabrikam", job.Tenant)
+	}
+
+	// A finished job of contoso lets its next one run
+	release <- struct{}{}
+	if job := nextStarted(t, started); job.Tenant != "contoso" {
+		t.Errorf("Job of %s started, want the next one of contoso", job.Tenant)
+	}
+	for i := 0; i < 4; i++ {
+		release <- struct{}{}
+	}
+}
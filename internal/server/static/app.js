@@ -0,0 +1,161 @@
+// Dashboard of the packaging service: jobs update live over server-sent events,
+// history and catalog are refreshed when a job finishes
+"use strict";
+
+const jobs = new Map();
+
+function cell(row, text, className) {
+  const td = row.insertCell();
+  td.textContent = text ?? "";
+  if (className) td.className = className;
+  return td;
+}
+
+function formatSize(bytes) {
+  const units = ["B", "KB", "MB", "GB", "TB"];
+  let i = 0;
+  while (bytes >= 1024 && i < units.length - 1) {
+    bytes /= 1024;
+    i++;
+  }
+  return `${bytes.toFixed(i ? 1 : 0)} ${units[i]}`;
+}
+
+function formatDate(value) {
+  return value ? new Date(value).toLocaleString() : "";
+}
+
+function renderJobs() {
+  const body = document.getElementById("jobs");
+  body.replaceChildren();
+  const sorted = [...jobs.values()].sort((a, b) => Number(b.id) - Number(a.id));
+  if (sorted.length === 0) {
+    const row = body.insertRow();
+    row.className = "empty";
+    cell(row, "No jobs yet").colSpan = 6;
+    return;
+  }
+  for (const job of sorted) {
+    const row = body.insertRow();
+    cell(row, job.id);
+    cell(row, job.setup);
+    cell(row, job.source);
+    cell(row, job.status, `status-${job.status}`);
+    const progress = cell(row, "");
+    if (job.status === "running") {
+      const bar = document.createElement("progress");
+      bar.max = 100;
+      bar.value = job.percent;
+      progress.append(bar, ` ${job.step ?? ""}`);
+    } else {
+      progress.textContent = job.status === "succeeded" ? "100%" : "";
+    }
+    cell(row, job.status === "failed" ? job.error : job.package, job.status === "failed" ? "error" : "");
+  }
+}
+
+async function getJSON(url) {
+  const resp = await fetch(url);
+  const body = await resp.json();
+  if (!resp.ok) throw new Error(body.error ?? resp.statusText);
+  return body;
+}
+
+async function loadJobs() {
+  for (const job of await getJSON("api/jobs")) jobs.set(job.id, job);
+  renderJobs();
+}
+
+async function loadHistory() {
+  const body = document.getElementById("history");
+  body.replaceChildren();
+  const entries = await getJSON("api/history");
+  if (entries.length === 0) {
+    const row = body.insertRow();
+    row.className = "empty";
+    cell(row, "No packaging history").colSpan = 5;
+  }
+  for (const entry of entries) {
+    const row = body.insertRow();
+    cell(row, formatDate(entry.timestamp));
+    cell(row, entry.setupFile);
+    cell(row, entry.sourcePath);
+    cell(row, entry.succeeded ? "succeeded" : "failed", entry.succeeded ? "status-succeeded" : "status-failed");
+    cell(row, entry.succeeded ? entry.package : entry.error, entry.succeeded ? "" : "error");
+  }
+}
+
+async function loadCatalog() {
+  const body = document.getElementById("catalog");
+  body.replaceChildren();
+  let catalog;
+  try {
+    catalog = await getJSON("api/catalog");
+  } catch (err) {
+    const row = body.insertRow();
+    row.className = "empty";
+    cell(row, err.message).colSpan = 6;
+    return;
+  }
+  document.getElementById("repository").textContent = catalog.repository;
+  if (catalog.packages.length === 0) {
+    const row = body.insertRow();
+    row.className = "empty";
+    cell(row, "No published packages").colSpan = 6;
+  }
+  for (const entry of catalog.packages) {
+    const row = body.insertRow();
+    cell(row, entry.name);
+    cell(row, entry.app);
+    cell(row, entry.version);
+    cell(row, entry.publisher);
+    cell(row, formatSize(entry.size));
+    cell(row, formatDate(entry.published));
+  }
+}
+
+function connect() {
+  const badge = document.getElementById("connection");
+  const events = new EventSource("api/events");
+  events.onopen = () => {
+    badge.textContent = "live";
+    loadJobs();
+  };
+  events.onerror = () => {
+    badge.textContent = "reconnecting";
+  };
+  events.addEventListener("job", (event) => {
+    const job = JSON.parse(event.data);
+    const previous = jobs.get(job.id);
+    jobs.set(job.id, job);
+    renderJobs();
+    if (previous?.status !== job.status && (job.status === "succeeded" || job.status === "failed")) {
+      loadHistory();
+    }
+  });
+}
+
+document.getElementById("submit").addEventListener("submit", async (event) => {
+  event.preventDefault();
+  const form = event.target;
+  const error = document.getElementById("submit-error");
+  error.hidden = true;
+  const resp = await fetch("api/jobs", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(Object.fromEntries(new FormData(form))),
+  });
+  const body = await resp.json();
+  if (!resp.ok) {
+    error.textContent = body.error;
+    error.hidden = false;
+    return;
+  }
+  jobs.set(body.id, body);
+  renderJobs();
+  form.reset();
+});
+
+connect();
+loadHistory();
+loadCatalog();
@@ -0,0 +1,91 @@
+package signing
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+)
+
+// ed25519Signer signs with an Ed25519 private key
+type ed25519Signer struct {
+	key ed25519.PrivateKey
+}
+
+// Sign writes the base64 Ed25519 signature of the file, the format release checksums
+// are signed in (see selfupdate)
+func (s *ed25519Signer) Sign(ctx context.Context, path, sigPath string) error {
+	message, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, message))
+	if err := os.WriteFile(sigPath, []byte(sig+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write signature: %w", err)
+	}
+	return nil
+}
+
+// ParsePrivateKey decodes an Ed25519 private key: a PEM PKCS #8 key as written by
+// "openssl genpkey -algorithm ed25519", or the base64 64-byte key or 32-byte seed
+func ParsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signing key: %w", err)
+		}
+		privateKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("invalid signing key: not an Ed25519 key")
+		}
+		return privateKey, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: want PEM or base64: %w", err)
+	}
+	switch len(raw) {
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	}
+	return nil, fmt.Errorf("invalid signing key: want %d or %d base64-encoded bytes", ed25519.PrivateKeySize, ed25519.SeedSize)
+}
+
+// ParsePublicKey decodes an Ed25519 public key: a PEM PKIX key as written by
+// "openssl pkey -pubout", or the base64 32-byte key
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key: %w", err)
+		}
+		publicKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("invalid public key: not an Ed25519 key")
+		}
+		return publicKey, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key: want PEM or %d base64-encoded bytes", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// Verify checks a signature written by the ed25519 method, raw or base64
+func Verify(publicKey ed25519.PublicKey, message, sig []byte) error {
+	if ed25519.Verify(publicKey, message, sig) {
+		return nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(sig)))
+	if err == nil && ed25519.Verify(publicKey, message, decoded) {
+		return nil
+	}
+	return fmt.Errorf("signature is not valid for the public key")
+}
@@ -0,0 +1,102 @@
+// Package signing writes detached signatures of provenance statements, with a built-in
+// Ed25519 key or the GPG, minisign and cosign tools
+package signing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Signing methods
+const (
+	// MethodEd25519 signs with an Ed25519 private key, writing a base64 signature
+	MethodEd25519 = "ed25519"
+	// MethodGPG writes an armored detached signature with gpg
+	MethodGPG = "gpg"
+	// MethodMinisign writes a minisign signature
+	MethodMinisign = "minisign"
+	// MethodCosign writes a cosign blob signature
+	MethodCosign = "cosign"
+)
+
+// Methods lists the supported signing methods
+var Methods = []string{MethodEd25519, MethodGPG, MethodMinisign, MethodCosign}
+
+// Signer writes a detached signature of a file
+type Signer interface {
+	// Sign signs the file at path and writes the signature to sigPath
+	Sign(ctx context.Context, path, sigPath string) error
+}
+
+// New returns the signer of a method
+// key is the private key file for ed25519, minisign and cosign (cosign also accepts
+// KMS URIs such as azurekms://...), and the optional key ID or user ID for gpg, which
+// otherwise signs with its default key
+func New(method, key string) (Signer, error) {
+	switch strings.ToLower(method) {
+	case MethodEd25519:
+		if key == "" {
+			return nil, fmt.Errorf("ed25519 signing needs a private key file")
+		}
+		data, err := os.ReadFile(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signing key: %w", err)
+		}
+		privateKey, err := ParsePrivateKey(data)
+		if err != nil {
+			return nil, err
+		}
+		return &ed25519Signer{key: privateKey}, nil
+	case MethodGPG:
+		return newCommandSigner(MethodGPG, func(path, sigPath string) []string {
+			args := []string{"--batch", "--yes", "--armor", "--detach-sign"}
+			if key != "" {
+				args = append(args, "--local-user", key)
+			}
+			return append(args, "--output", sigPath, path)
+		})
+	case MethodMinisign:
+		if key == "" {
+			return nil, fmt.Errorf("minisign signing needs a secret key file")
+		}
+		return newCommandSigner(MethodMinisign, func(path, sigPath string) []string {
+			return []string{"-S", "-s", key, "-m", path, "-x", sigPath}
+		})
+	case MethodCosign:
+		if key == "" {
+			return nil, fmt.Errorf("cosign signing needs a key file or KMS URI")
+		}
+		return newCommandSigner(MethodCosign, func(path, sigPath string) []string {
+			return []string{"sign-blob", "--yes", "--key", key, "--output-signature", sigPath, path}
+		})
+	}
+	return nil, fmt.Errorf("unknown signing method %q (supported: %s)", method, strings.Join(Methods, ", "))
+}
+
+// commandSigner signs by running an external tool
+type commandSigner struct {
+	tool string
+	args func(path, sigPath string) []string
+}
+
+// newCommandSigner returns a signer running tool, failing early when it is not installed
+func newCommandSigner(tool string, args func(path, sigPath string) []string) (*commandSigner, error) {
+	if _, err := exec.LookPath(tool); err != nil {
+		return nil, fmt.Errorf("%s signing needs %s on the PATH: %w", tool, tool, err)
+	}
+	return &commandSigner{tool: tool, args: args}, nil
+}
+
+// Sign runs the tool, which prompts on the terminal for a passphrase if the key needs one
+func (s *commandSigner) Sign(ctx context.Context, path, sigPath string) error {
+	cmd := exec.CommandContext(ctx, s.tool, s.args(path, sigPath)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w", s.tool, err)
+	}
+	return nil
+}
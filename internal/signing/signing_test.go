@@ -0,0 +1,132 @@
+package signing
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEd25519SignVerify(t *testing.T) {
+	dir := t.TempDir()
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	keyPath := filepath.Join(dir, "signing.key")
+	if err := os.WriteFile(keyPath, []byte(base64.StdEncoding.EncodeToString(privateKey.Seed())), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	path := filepath.Join(dir, "app.intunewin.provenance.json")
+	message := []byte(`{"_type":"https://in-toto.io/Statement/v1"}`)
+	if err := os.WriteFile(path, message, 0644); err != nil {
+		t.Fatalf("Failed to write statement: %v", err)
+	}
+
+	signer, err := New("Ed25519", keyPath)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	sigPath := filepath.Join(dir, "app.intunewin.sig")
+	if err := signer.Sign(context.Background(), path, sigPath); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	sig, err := os.ReadFile(sigPath)
+	if err != nil {
+		t.Fatalf("Failed to read signature: %v", err)
+	}
+	if err := Verify(publicKey, message, sig); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	if err := Verify(publicKey, append(message, ' '), sig); err == nil {
+		t.Error("Expected error for a changed statement")
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	pkix, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal public key: %v", err)
+	}
+
+	privateKeys := map[string]struct {
+		data    []byte
+		wantErr bool
+	}{
+		"pem":       {pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}), false},
+		"base64":    {[]byte(base64.StdEncoding.EncodeToString(privateKey) + "\n"), false},
+		"seed":      {[]byte(base64.StdEncoding.EncodeToString(privateKey.Seed())), false},
+		"too short": {[]byte(base64.StdEncoding.EncodeToString([]byte("short"))), true},
+		"not a key": {[]byte("not base64!"), true},
+	}
+	for name, tt := range privateKeys {
+		t.Run("private "+name, func(t *testing.T) {
+			got, err := ParsePrivateKey(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePrivateKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !got.Equal(privateKey) {
+				t.Error("ParsePrivateKey() returned a different key")
+			}
+		})
+	}
+
+	publicKeys := map[string]struct {
+		data    []byte
+		wantErr bool
+	}{
+		"pem":       {pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix}), false},
+		"base64":    {[]byte(base64.StdEncoding.EncodeToString(publicKey)), false},
+		"too long":  {[]byte(base64.StdEncoding.EncodeToString(privateKey)), true},
+		"not a key": {[]byte("not base64!"), true},
+	}
+	for name, tt := range publicKeys {
+		t.Run("public "+name, func(t *testing.T) {
+			got, err := ParsePublicKey(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePublicKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !got.Equal(publicKey) {
+				t.Error("ParsePublicKey() returned a different key")
+			}
+		})
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	tests := map[string]struct {
+		method string
+		key    string
+		want   string
+	}{
+		"unknown method":   {"pgp", "", "unknown signing method"},
+		"ed25519 no key":   {"ed25519", "", "needs a private key"},
+		"ed25519 missing":  {"ed25519", filepath.Join(t.TempDir(), "missing.key"), "failed to read"},
+		"minisign no key":  {"minisign", "", "needs a secret key"},
+		"cosign no key":    {"cosign", "", "needs a key"},
+		"gpg not on PATH":  {"gpg", "", "needs gpg on the PATH"},
+		"tool not on PATH": {"cosign", "cosign.key", "needs cosign on the PATH"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(tt.method, tt.key)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("New(%q, %q) error = %v, want %q", tt.method, tt.key, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package spec
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"gopkg.in/yaml.v3"
+)
+
+// Kind identifies a spec file format
+type Kind string
+
+const (
+	// KindApp is a Graph app spec
+	KindApp Kind = "app spec"
+	// KindBatch is a batch manifest
+	KindBatch Kind = "batch manifest"
+)
+
+// schemaBaseURL is where the schemas are published; it matches their $id
+const schemaBaseURL = "https://raw.githubusercontent.com/michelbragaguimaraes/LetsGoIntunePackager/main/internal/spec/"
+
+//go:embed schemas/*.schema.json
+var schemaFS embed.FS
+
+// schemaFiles maps each kind to its embedded schema file
+var schemaFiles = map[Kind]string{
+	KindApp:   "schemas/app-spec.schema.json",
+	KindBatch: "schemas/batch-manifest.schema.json",
+}
+
+var (
+	compileOnce sync.Once
+	compiled    map[Kind]*jsonschema.Schema
+	compileErr  error
+)
+
+// ParseKind converts a user-supplied string into a Kind
+func ParseKind(s string) (Kind, error) {
+	switch s {
+	case "app":
+		return KindApp, nil
+	case "batch":
+		return KindBatch, nil
+	default:
+		return "", fmt.Errorf("invalid spec type: %s (supported: app, batch)", s)
+	}
+}
+
+// Schema returns the JSON Schema of a spec kind
+func Schema(kind Kind) ([]byte, error) {
+	file, ok := schemaFiles[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown spec kind: %s", kind)
+	}
+	return schemaFS.ReadFile(file)
+}
+
+// DetectKind guesses the kind of a YAML or JSON spec from its top-level keys
+// Batch manifests have a packages list, everything else is treated as an app spec
+func DetectKind(data []byte) (Kind, error) {
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return "", fmt.Errorf("failed to parse YAML: %w", err)
+	}
+	if _, ok := doc["packages"]; ok {
+		return KindBatch, nil
+	}
+	return KindApp, nil
+}
+
+// Validate checks YAML or JSON spec content against the schema of kind
+func Validate(kind Kind, data []byte) error {
+	schemas, err := compileSchemas()
+	if err != nil {
+		return err
+	}
+	schema, ok := schemas[kind]
+	if !ok {
+		return fmt.Errorf("unknown spec kind: %s", kind)
+	}
+
+	instance, err := toJSONValue(data)
+	if err != nil {
+		return err
+	}
+
+	if err := schema.Validate(instance); err != nil {
+		return fmt.Errorf("invalid %s: %w", kind, err)
+	}
+	return nil
+}
+
+// compileSchemas compiles the embedded schemas once
+func compileSchemas() (map[Kind]*jsonschema.Schema, error) {
+	compileOnce.Do(func() {
+		compiler := jsonschema.NewCompiler()
+		compiled = make(map[Kind]*jsonschema.Schema)
+		for kind, file := range schemaFiles {
+			data, err := schemaFS.ReadFile(file)
+			if err != nil {
+				compileErr = fmt.Errorf("failed to read schema: %w", err)
+				return
+			}
+			doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+			if err != nil {
+				compileErr = fmt.Errorf("failed to parse schema %s: %w", file, err)
+				return
+			}
+			if err := compiler.AddResource(schemaBaseURL+file, doc); err != nil {
+				compileErr = fmt.Errorf("failed to load schema %s: %w", file, err)
+				return
+			}
+			schema, err := compiler.Compile(schemaBaseURL + file)
+			if err != nil {
+				compileErr = fmt.Errorf("failed to compile schema %s: %w", file, err)
+				return
+			}
+			compiled[kind] = schema
+		}
+	})
+	return compiled, compileErr
+}
+
+// toJSONValue converts YAML (or JSON) content into the generic JSON value the validator expects
+// Going through encoding/json turns YAML-only types such as timestamps into strings
+func toJSONValue(data []byte) (any, error) {
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert YAML to JSON: %w", err)
+	}
+	return jsonschema.UnmarshalJSON(bytes.NewReader(encoded))
+}
@@ -0,0 +1,176 @@
+package spec
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AppSpec describes a Win32 app to create in Intune from a .intunewin package
+type AppSpec struct {
+	Name             string             `yaml:"name,omitempty"`
+	Version          string             `yaml:"version,omitempty"`
+	Publisher        string             `yaml:"publisher,omitempty"`
+	Description      string             `yaml:"description,omitempty"`
+	InformationURL   string             `yaml:"informationUrl,omitempty"`
+	PrivacyURL       string             `yaml:"privacyUrl,omitempty"`
+	Developer        string             `yaml:"developer,omitempty"`
+	Owner            string             `yaml:"owner,omitempty"`
+	Notes            string             `yaml:"notes,omitempty"`
+	Package          string             `yaml:"package,omitempty"`
+	Icon             string             `yaml:"icon,omitempty"`
+	InstallCommand   string             `yaml:"installCommand,omitempty"`
+	UninstallCommand string             `yaml:"uninstallCommand,omitempty"`
+	Architectures    string             `yaml:"architectures,omitempty"`
+	RunAs            string             `yaml:"runAs,omitempty"`
+	RestartBehavior  string             `yaml:"restartBehavior,omitempty"`
+	Detection        *DetectionSpec     `yaml:"detection,omitempty"`
+	Requirements     *RequirementsSpec  `yaml:"requirements,omitempty"`
+	ReturnCodes      []ReturnCodeSpec   `yaml:"returnCodes,omitempty"`
+	OnConflict       string             `yaml:"onConflict,omitempty"`
+	Assignments      []AssignmentSpec   `yaml:"assignments,omitempty"`
+	Supersedes       []RelationshipSpec `yaml:"supersedes,omitempty"`
+	DependsOn        []RelationshipSpec `yaml:"dependsOn,omitempty"`
+}
+
+// DetectionSpec is the detection rule of a non-MSI app, or the Graph detection
+// rules of an exported app
+type DetectionSpec struct {
+	File  string           `yaml:"file,omitempty"`
+	Rules []map[string]any `yaml:"rules,omitempty"`
+}
+
+// RequirementsSpec lists the conditions a device must meet before installing the app
+type RequirementsSpec struct {
+	MinimumWindowsRelease  string           `yaml:"minimumWindowsRelease,omitempty"`
+	MinimumFreeDiskSpaceMB int              `yaml:"minimumFreeDiskSpaceMB,omitempty"`
+	MinimumMemoryMB        int              `yaml:"minimumMemoryMB,omitempty"`
+	MinimumProcessors      int              `yaml:"minimumProcessors,omitempty"`
+	MinimumCPUSpeedMHz     int              `yaml:"minimumCpuSpeedMHz,omitempty"`
+	Rules                  []map[string]any `yaml:"rules,omitempty"`
+}
+
+// ReturnCodeSpec maps an installer exit code to a result
+type ReturnCodeSpec struct {
+	Code int    `yaml:"code"`
+	Type string `yaml:"type"`
+}
+
+// AssignmentSpec assigns the app to a group
+type AssignmentSpec struct {
+	Group    string `yaml:"group,omitempty"`
+	Intent   string `yaml:"intent,omitempty"`
+	Deadline string `yaml:"deadline,omitempty"`
+}
+
+// RelationshipSpec is a supersedence or dependency target
+type RelationshipSpec struct {
+	App  string `yaml:"app,omitempty"`
+	Type string `yaml:"type,omitempty"`
+}
+
+// BatchManifest lists packages to build in one run
+type BatchManifest struct {
+	Output   string         `yaml:"output"`
+	Packages []BatchPackage `yaml:"packages"`
+}
+
+// BatchPackage is a single package of a batch manifest
+type BatchPackage struct {
+	Name    string       `yaml:"name"`
+	Source  string       `yaml:"source"`
+	Setup   string       `yaml:"setup"`
+	Output  string       `yaml:"output"`
+	License *LicenseSpec `yaml:"license,omitempty"`
+}
+
+// LicenseSpec is the licensing record kept with a package for asset management
+type LicenseSpec struct {
+	Type       string `yaml:"type,omitempty"`
+	ID         string `yaml:"id,omitempty"`
+	Seats      int    `yaml:"seats,omitempty"`
+	EULA       string `yaml:"eula,omitempty"`
+	AcceptedBy string `yaml:"acceptedBy,omitempty"`
+}
+
+// LoadAppSpec reads and validates an app spec
+// Relative paths are resolved against the directory of the spec file
+func LoadAppSpec(path string) (*AppSpec, error) {
+	data, err := readAndValidate(path, KindApp)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec AppSpec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse app spec: %w", err)
+	}
+
+	spec.Package = resolvePath(path, spec.Package)
+	spec.Icon = resolvePath(path, spec.Icon)
+	return &spec, nil
+}
+
+// LoadBatchManifest reads and validates a batch manifest
+// Relative paths are resolved against the directory of the manifest file
+func LoadBatchManifest(path string) (*BatchManifest, error) {
+	data, err := readAndValidate(path, KindBatch)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest BatchManifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse batch manifest: %w", err)
+	}
+
+	manifest.Output = resolvePath(path, manifest.Output)
+	for i := range manifest.Packages {
+		pkg := &manifest.Packages[i]
+		pkg.Source = resolvePath(path, pkg.Source)
+		pkg.Output = resolvePath(path, pkg.Output)
+		if pkg.Output == "" {
+			pkg.Output = manifest.Output
+		}
+		if pkg.Name == "" {
+			pkg.Name = pkg.Setup
+		}
+	}
+	return &manifest, nil
+}
+
+// WriteAppSpec writes an app spec as YAML, with a schema reference for editor validation
+// Package paths should be relative to the spec file
+func WriteAppSpec(path string, spec *AppSpec) error {
+	data, err := yaml.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("failed to encode app spec: %w", err)
+	}
+	header := fmt.Sprintf("# yaml-language-server: $schema=%s%s\n", schemaBaseURL, schemaFiles[KindApp])
+	if err := os.WriteFile(path, append([]byte(header), data...), 0644); err != nil {
+		return fmt.Errorf("failed to write app spec: %w", err)
+	}
+	return nil
+}
+
+// readAndValidate reads a spec file and validates it against the schema of kind
+func readAndValidate(path string, kind Kind) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", kind, err)
+	}
+	if err := Validate(kind, data); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return data, nil
+}
+
+// resolvePath makes p relative to the directory of specPath, leaving absolute and empty paths unchanged
+func resolvePath(specPath, p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(filepath.Dir(specPath), p)
+}
@@ -0,0 +1,221 @@
+package spec
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSpec(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write spec: %v", err)
+	}
+	return path
+}
+
+func TestLoadAppSpec(t *testing.T) {
+	dir, err := os.MkdirTemp("", "spec")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := writeSpec(t, dir, "7zip.yaml", `
+name: 7-Zip
+version: "24.01"
+package: output/7z2401-x64.intunewin
+onConflict: suffix
+assignments:
+  - group: Pilot Devices
+    intent: required
+    deadline: 2026-11-01T18:00:00Z
+supersedes:
+  - app: 7-Zip 23.01
+`)
+
+	spec, err := LoadAppSpec(path)
+	if err != nil {
+		t.Fatalf("LoadAppSpec() error = %v", err)
+	}
+	if spec.Name != "7-Zip" || spec.Version != "24.01" {
+		t.Errorf("Name/Version = %s/%s, want 7-Zip/24.01", spec.Name, spec.Version)
+	}
+	if spec.Package != filepath.Join(dir, "output", "7z2401-x64.intunewin") {
+		t.Errorf("Package = %s, want path relative to spec", spec.Package)
+	}
+	if len(spec.Assignments) != 1 || spec.Assignments[0].Deadline == "" {
+		t.Errorf("Assignments = %+v, want one with deadline", spec.Assignments)
+	}
+	if len(spec.Supersedes) != 1 || spec.Supersedes[0].App != "7-Zip 23.01" {
+		t.Errorf("Supersedes = %+v", spec.Supersedes)
+	}
+}
+
+func TestWriteAppSpec(t *testing.T) {
+	dir, err := os.MkdirTemp("", "spec")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "setup-x86.yaml")
+	written := &AppSpec{
+		Name:          "Contoso App (x86)",
+		Package:       "setup-x86.intunewin",
+		Architectures: "x86",
+	}
+	if err := WriteAppSpec(path, written); err != nil {
+		t.Fatalf("WriteAppSpec() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read spec: %v", err)
+	}
+	if strings.Contains(string(data), "onConflict") {
+		t.Errorf("Expected empty fields to be omitted, got:\n%s", data)
+	}
+
+	// The written spec passes schema validation and loads back
+	spec, err := LoadAppSpec(path)
+	if err != nil {
+		t.Fatalf("LoadAppSpec() error = %v", err)
+	}
+	if spec.Name != written.Name || spec.Architectures != "x86" {
+		t.Errorf("Loaded spec = %+v", spec)
+	}
+	if spec.Package != filepath.Join(dir, "setup-x86.intunewin") {
+		t.Errorf("Package = %s, want path relative to spec", spec.Package)
+	}
+}
+
+func TestWriteAppSpecExported(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "7zip.yaml")
+	written := &AppSpec{
+		Name:            "7-Zip",
+		Package:         "7z2401-x64.intunewin",
+		InformationURL:  "https://www.7-zip.org",
+		RunAs:           "system",
+		RestartBehavior: "suppress",
+		Detection: &DetectionSpec{Rules: []map[string]any{{
+			"@odata.type":          "#microsoft.graph.win32LobAppRegistryDetection",
+			"keyPath":              `HKEY_LOCAL_MACHINE\SOFTWARE\7-Zip`,
+			"check32BitOn64System": false,
+		}}},
+		Requirements: &RequirementsSpec{MinimumWindowsRelease: "21H2", MinimumFreeDiskSpaceMB: 100},
+		ReturnCodes:  []ReturnCodeSpec{{Code: 0, Type: "success"}, {Code: 3010, Type: "softReboot"}},
+		Assignments:  []AssignmentSpec{{Group: "Pilot Devices", Intent: "required", Deadline: "2026-11-01T18:00"}},
+	}
+	if err := WriteAppSpec(path, written); err != nil {
+		t.Fatalf("WriteAppSpec() error = %v", err)
+	}
+
+	spec, err := LoadAppSpec(path)
+	if err != nil {
+		t.Fatalf("LoadAppSpec() error = %v", err)
+	}
+	rules := spec.Detection.Rules
+	if len(rules) != 1 || rules[0]["@odata.type"] != "#microsoft.graph.win32LobAppRegistryDetection" || rules[0]["check32BitOn64System"] != false {
+		t.Errorf("Detection rules = %v", rules)
+	}
+	if spec.Requirements.MinimumWindowsRelease != "21H2" || spec.Requirements.MinimumFreeDiskSpaceMB != 100 {
+		t.Errorf("Requirements = %+v", spec.Requirements)
+	}
+	if len(spec.ReturnCodes) != 2 || spec.ReturnCodes[0].Code != 0 || spec.ReturnCodes[1].Type != "softReboot" {
+		t.Errorf("ReturnCodes = %+v", spec.ReturnCodes)
+	}
+}
+
+func TestValidateAppSpecErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"missing package", "name: 7-Zip\n", "package"},
+		{"unknown field", "package: a.intunewin\ninstallCmd: setup.exe\n", "installCmd"},
+		{"bad intent", "package: a.intunewin\nassignments:\n  - group: g\n    intent: mandatory\n", "intent"},
+		{"unquoted version", "package: a.intunewin\nversion: 24.01\n", "version"},
+		{"bad return code type", "package: a.intunewin\nreturnCodes:\n  - code: 3010\n    type: reboot\n", "type"},
+		{"rule without type", "package: a.intunewin\ndetection:\n  rules:\n    - path: C:\\\\App\n", "@odata.type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(KindApp, []byte(tt.content))
+			if err == nil {
+				t.Fatal("Expected validation error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Error = %v, want mention of %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadBatchManifest(t *testing.T) {
+	dir, err := os.MkdirTemp("", "spec")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := writeSpec(t, dir, "batch.yaml", `
+output: out
+packages:
+  - source: apps/7zip
+    setup: 7z2401-x64.msi
+  - name: VS Code
+    source: /apps/vscode
+    setup: VSCodeSetup-x64.exe
+    output: /packages
+    license:
+      type: per-device
+      id: LIC-0042
+      seats: 250
+`)
+
+	manifest, err := LoadBatchManifest(path)
+	if err != nil {
+		t.Fatalf("LoadBatchManifest() error = %v", err)
+	}
+	if len(manifest.Packages) != 2 {
+		t.Fatalf("Packages = %d, want 2", len(manifest.Packages))
+	}
+
+	first := manifest.Packages[0]
+	if first.Name != "7z2401-x64.msi" {
+		t.Errorf("Name = %s, want setup file name", first.Name)
+	}
+	if first.Source != filepath.Join(dir, "apps", "7zip") || first.Output != filepath.Join(dir, "out") {
+		t.Errorf("Source/Output = %s/%s, want paths relative to manifest", first.Source, first.Output)
+	}
+	if manifest.Packages[1].Output != "/packages" {
+		t.Errorf("Output = %s, want /packages", manifest.Packages[1].Output)
+	}
+	if l := manifest.Packages[1].License; l == nil || l.ID != "LIC-0042" || l.Seats != 250 {
+		t.Errorf("License = %+v, want LIC-0042 with 250 seats", l)
+	}
+
+	if err := Validate(KindBatch, []byte("packages: []\n")); err == nil {
+		t.Error("Expected error for empty packages list")
+	}
+	acceptedOnly := "packages:\n  - source: a\n    setup: a.msi\n    license:\n      acceptedBy: Legal\n"
+	if err := Validate(KindBatch, []byte(acceptedOnly)); err == nil {
+		t.Error("Expected error for an EULA acceptance without the EULA")
+	}
+}
+
+func TestDetectKind(t *testing.T) {
+	kind, err := DetectKind([]byte("packages:\n  - source: a\n    setup: b\n"))
+	if err != nil || kind != KindBatch {
+		t.Errorf("DetectKind() = %s, %v, want batch manifest", kind, err)
+	}
+	kind, err = DetectKind([]byte("package: a.intunewin\n"))
+	if err != nil || kind != KindApp {
+		t.Errorf("DetectKind() = %s, %v, want app spec", kind, err)
+	}
+}
@@ -0,0 +1,79 @@
+package tui
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/i18n"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// clipboardTools are the commands tried in order to copy text on Linux and other Unix
+// systems: Wayland first, then X11
+var clipboardTools = [][]string{
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+}
+
+// errNoClipboard is returned when no clipboard tool is installed
+var errNoClipboard = errors.New("no clipboard tool found (install wl-clipboard, xclip or xsel)")
+
+// copiedMsg reports the result of copying a package path to the clipboard
+type copiedMsg struct {
+	err error
+}
+
+// copyPackageCmd copies the path and SHA256 of a package to the clipboard
+func copyPackageCmd(path string) tea.Cmd {
+	return func() tea.Msg {
+		digest, err := packager.FileSHA256(path)
+		if err != nil {
+			return copiedMsg{err: err}
+		}
+		text := fmt.Sprintf("%s\nSHA256: %s", path, hex.EncodeToString(digest))
+		return copiedMsg{err: copyToClipboard(text)}
+	}
+}
+
+// copiedNotice returns the notice shown on the success screen for a copiedMsg
+func copiedNotice(msg copiedMsg) string {
+	if msg.err != nil {
+		return i18n.Tf("Could not copy to the clipboard: %v", msg.err)
+	}
+	return i18n.T("Copied the package path and SHA256 to the clipboard")
+}
+
+// copyToClipboard writes text to the system clipboard with the platform's clipboard tool
+func copyToClipboard(text string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("clip")
+	case "darwin":
+		cmd = exec.Command("pbcopy")
+	default:
+		for _, tool := range clipboardTools {
+			if _, err := exec.LookPath(tool[0]); err == nil {
+				cmd = exec.Command(tool[0], tool[1:]...)
+				break
+			}
+		}
+		if cmd == nil {
+			return errNoClipboard
+		}
+	}
+	// wl-copy and xclip stay in the background to serve the clipboard, so their output is
+	// not captured: reading it would wait for them to exit
+	cmd.Stdin = strings.NewReader(text)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run %s: %w", cmd.Path, err)
+	}
+	return nil
+}
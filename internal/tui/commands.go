@@ -1,8 +1,13 @@
 package tui
 
 import (
+	"context"
+	"log/slog"
+	"path/filepath"
+
 	tea "github.com/charmbracelet/bubbletea"
 
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/config"
 	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
 )
 
@@ -13,8 +18,7 @@ type packageStartMsg struct{}
 
 // packageProgressMsg carries progress updates
 type packageProgressMsg struct {
-	step    string
-	percent float64
+	progress packager.Progress
 }
 
 // packageCompleteMsg carries the successful result
@@ -37,20 +41,19 @@ func SetProgram(p *tea.Program) {
 }
 
 // startPackaging initiates the packaging process asynchronously
-func startPackaging(sourcePath, setupFile, outputPath string) tea.Cmd {
+// Canceling ctx stops the run; it then ends with a packageErrorMsg wrapping context.Canceled
+func startPackaging(ctx context.Context, sourcePath, setupFile, outputPath string, opts packager.Options) tea.Cmd {
 	return func() tea.Msg {
 		// Start the packaging in a goroutine
 		go func() {
-			result, err := packager.Package(sourcePath, setupFile, outputPath,
-				func(step string, pct float64) {
-					// Send progress updates back to the TUI
-					if program != nil {
-						program.Send(packageProgressMsg{
-							step:    step,
-							percent: pct,
-						})
-					}
-				})
+			defer recoverJob()
+			// Send progress updates back to the TUI, with the speed and time remaining
+			opts.OnProgress = func(p packager.Progress) {
+				if program != nil {
+					program.Send(packageProgressMsg{progress: p})
+				}
+			}
+			result, err := packager.PackageContext(ctx, sourcePath, setupFile, outputPath, opts, nil)
 
 			// Send final result
 			if program != nil {
@@ -66,25 +69,34 @@ func startPackaging(sourcePath, setupFile, outputPath string) tea.Cmd {
 	}
 }
 
-// clearInputCmd returns a command that does nothing (placeholder)
-func clearInputCmd() tea.Cmd {
-	return nil
+// previewReadyMsg carries the preview shown before packaging starts
+type previewReadyMsg struct {
+	preview *packager.Preview
+	err     error
+}
+
+// previewPackageCmd validates the inputs and gathers the package preview in the background
+func previewPackageCmd(sourcePath, setupFile, outputPath string, opts packager.Options) tea.Cmd {
+	return func() tea.Msg {
+		preview, err := packager.PreviewPackage(sourcePath, setupFile, outputPath, opts)
+		return previewReadyMsg{preview: preview, err: err}
+	}
 }
 
-// autoDetectSetupFileCmd tries to detect a setup file in the source directory
-func autoDetectSetupFileCmd(sourceDir string) tea.Cmd {
+// saveHistoryCmd writes the history file in the background
+// A history that cannot be saved only costs convenience, so failures are logged
+func saveHistoryCmd(path string, history config.History) tea.Cmd {
 	return func() tea.Msg {
-		setupFile := autoDetectSetupFile(sourceDir)
-		if setupFile != "" {
-			return setupFileDetectedMsg{filename: setupFile}
+		if err := history.Save(path); err != nil {
+			slog.Warn("could not save packaging history", "error", err)
 		}
 		return nil
 	}
 }
 
-// setupFileDetectedMsg signals that a setup file was auto-detected
-type setupFileDetectedMsg struct {
-	filename string
+// clearInputCmd returns a command that does nothing (placeholder)
+func clearInputCmd() tea.Cmd {
+	return nil
 }
 
 // validatePathCmd validates a path asynchronously
@@ -106,19 +118,29 @@ type pathValidatedMsg struct {
 	valid bool
 }
 
-// listSetupFilesCmd lists potential setup files in a directory
-func listSetupFilesCmd(dir string) tea.Cmd {
+// listSetupFilesCmd lists potential setup files in a directory for the setup file selector
+// current is the setup file entered so far; it is kept when it exists in the folder
+func listSetupFilesCmd(dir, current string) tea.Cmd {
 	return func() tea.Msg {
-		files, err := listSetupFiles(dir)
-		return setupFilesListedMsg{
-			files: files,
-			err:   err,
+		files, err := packager.ListSetupFiles(dir)
+		msg := setupFilesListedMsg{
+			dir:      dir,
+			files:    files,
+			detected: packager.PreferredSetupFile(files),
+			err:      err,
+		}
+		if current != "" && validatePath(filepath.Join(dir, current), false) {
+			msg.existing = current
 		}
+		return msg
 	}
 }
 
 // setupFilesListedMsg carries the list of setup files found
 type setupFilesListedMsg struct {
-	files []string
-	err   error
+	dir      string
+	files    []string
+	detected string
+	existing string
+	err      error
 }
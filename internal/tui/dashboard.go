@@ -0,0 +1,497 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/config"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/i18n"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// BatchJob is a package built by the batch dashboard
+type BatchJob struct {
+	Name string
+	// Run builds the package, reporting its progress and writing its logs to logs;
+	// canceling ctx stops it at the next file or phase boundary
+	Run func(ctx context.Context, progress func(packager.Progress), logs io.Writer) (*packager.PackageResult, error)
+}
+
+// BatchResult is the outcome of a job of the batch dashboard
+// Result and Err are both nil for a job that never started
+type BatchResult struct {
+	Name   string
+	Result *packager.PackageResult
+	Err    error
+}
+
+// jobState is the state of a job of the batch dashboard
+type jobState int
+
+const (
+	jobQueued jobState = iota
+	jobRunning
+	jobSucceeded
+	jobFailed
+	jobCanceled
+)
+
+// dashboardJob is a job of the batch dashboard and what it has reported so far
+type dashboardJob struct {
+	BatchJob
+	state    jobState
+	progress packager.Progress
+	logs     *LogBuffer
+	result   *packager.PackageResult
+	err      error
+	started  time.Time
+	elapsed  time.Duration
+}
+
+// jobProgressMsg reports the progress of a running job
+type jobProgressMsg struct {
+	index    int
+	progress packager.Progress
+}
+
+// jobDoneMsg reports the end of a job
+type jobDoneMsg struct {
+	index  int
+	result *packager.PackageResult
+	err    error
+}
+
+// dashboardModel shows the jobs of a batch, running up to parallel of them at a time
+type dashboardModel struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	jobs     []dashboardJob
+	parallel int
+	keys     KeyMap
+	spinner  spinner.Model
+
+	selected      int
+	viewingLog    bool
+	confirmCancel bool
+	canceling     bool
+
+	width  int
+	height int
+}
+
+// dashboardListRows is the number of jobs listed when the terminal size is not known yet
+const dashboardListRows = 10
+
+// RunDashboard builds the jobs of a batch in a full-screen dashboard, up to parallel at a
+// time, and returns their results in the order of jobs
+// The dashboard stays open once every job has ended, so their logs can be read, until it
+// is closed; quitting before then cancels the jobs still running or queued
+func RunDashboard(ctx context.Context, jobs []BatchJob, parallel int, settings config.TUISettings) ([]BatchResult, error) {
+	if err := ApplyTheme(settings.Theme); err != nil {
+		slog.Warn("using default theme", "error", err)
+	}
+	keys, err := NewKeyMap(settings.Keys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tui.keys in config: %w", err)
+	}
+
+	m := newDashboardModel(ctx, jobs, parallel, keys)
+	defer m.cancel()
+
+	p := tea.NewProgram(safeModel{model: m}, tea.WithAltScreen())
+	SetProgram(p)
+
+	finalModel, err := p.Run()
+	if panicErr := takePanic(); panicErr != nil {
+		return nil, panicErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("TUI error: %w", err)
+	}
+	if s, ok := finalModel.(safeModel); ok {
+		if final, ok := s.model.(dashboardModel); ok {
+			m = final
+		}
+	}
+
+	results := make([]BatchResult, len(m.jobs))
+	for i, job := range m.jobs {
+		results[i] = BatchResult{Name: job.Name, Result: job.result, Err: job.err}
+	}
+	return results, nil
+}
+
+// newDashboardModel creates the dashboard of jobs, none of them started
+func newDashboardModel(ctx context.Context, jobs []BatchJob, parallel int, keys KeyMap) dashboardModel {
+	if parallel < 1 {
+		parallel = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+
+	// MiniDot frames are one column wide, like the icons of ended jobs
+	s := spinner.New()
+	s.Spinner = spinner.MiniDot
+	s.Style = SpinnerStyle
+
+	m := dashboardModel{
+		ctx:      ctx,
+		cancel:   cancel,
+		jobs:     make([]dashboardJob, len(jobs)),
+		parallel: parallel,
+		keys:     keys,
+		spinner:  s,
+	}
+	for i, job := range jobs {
+		m.jobs[i] = dashboardJob{BatchJob: job, logs: NewLogBuffer(DefaultLogBufferLines)}
+	}
+	return m
+}
+
+func (m dashboardModel) Init() tea.Cmd {
+	return tea.Batch(m.spinner.Tick, func() tea.Msg { return jobsStartMsg{} })
+}
+
+// jobsStartMsg starts the first jobs once the program runs
+type jobsStartMsg struct{}
+
+func (m dashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case jobsStartMsg:
+		return m.startJobs()
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		return m, nil
+
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+
+	case jobProgressMsg:
+		if m.jobs[msg.index].state == jobRunning {
+			m.jobs[msg.index].progress = msg.progress
+		}
+		return m, nil
+
+	case jobDoneMsg:
+		job := &m.jobs[msg.index]
+		job.result, job.err = msg.result, msg.err
+		job.elapsed = time.Since(job.started)
+		switch {
+		case msg.err == nil:
+			job.state = jobSucceeded
+		case errors.Is(msg.err, context.Canceled) && m.ctx.Err() != nil:
+			job.state = jobCanceled
+		default:
+			job.state = jobFailed
+		}
+		next, cmd := m.startJobs()
+		if next.canceling && next.running() == 0 {
+			return next, tea.Quit
+		}
+		return next, cmd
+
+	case tea.KeyMsg:
+		return m.updateKeys(msg)
+	}
+	return m, nil
+}
+
+// updateKeys handles input on the dashboard
+func (m dashboardModel) updateKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.canceling {
+		return m, nil
+	}
+
+	if m.confirmCancel {
+		switch msg.String() {
+		case "y", "Y":
+			m.confirmCancel = false
+			m.canceling = true
+			m.cancel()
+			for i := range m.jobs {
+				if m.jobs[i].state == jobQueued {
+					m.jobs[i].state = jobCanceled
+				}
+			}
+			if m.running() == 0 {
+				return m, tea.Quit
+			}
+		case "n", "N", "esc":
+			m.confirmCancel = false
+		}
+		return m, nil
+	}
+
+	switch {
+	case m.viewingLog && key.Matches(msg, m.keys.Escape):
+		m.viewingLog = false
+
+	case key.Matches(msg, m.keys.Quit), !m.viewingLog && key.Matches(msg, m.keys.Escape):
+		if m.finished() {
+			return m, tea.Quit
+		}
+		m.confirmCancel = true
+
+	case key.Matches(msg, m.keys.Up):
+		if m.selected > 0 {
+			m.selected--
+		}
+
+	case key.Matches(msg, m.keys.Down):
+		if m.selected < len(m.jobs)-1 {
+			m.selected++
+		}
+
+	case key.Matches(msg, m.keys.Enter):
+		m.viewingLog = !m.viewingLog
+	}
+	return m, nil
+}
+
+// startJobs starts queued jobs until parallel of them are running
+// Once the batch is canceled, the jobs still queued are canceled instead
+func (m dashboardModel) startJobs() (dashboardModel, tea.Cmd) {
+	var cmds []tea.Cmd
+	running := m.running()
+	for i := range m.jobs {
+		job := &m.jobs[i]
+		if job.state != jobQueued {
+			continue
+		}
+		if m.ctx.Err() != nil {
+			job.state = jobCanceled
+			continue
+		}
+		if running >= m.parallel {
+			break
+		}
+		job.state = jobRunning
+		job.started = time.Now()
+		running++
+		cmds = append(cmds, runJobCmd(m.ctx, i, job.BatchJob, job.logs))
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// runJobCmd runs a job, sending its progress to the program as it goes
+func runJobCmd(ctx context.Context, index int, job BatchJob, logs io.Writer) tea.Cmd {
+	return func() tea.Msg {
+		progress := func(p packager.Progress) {
+			if program != nil {
+				program.Send(jobProgressMsg{index: index, progress: p})
+			}
+		}
+		result, err := job.Run(ctx, progress, logs)
+		return jobDoneMsg{index: index, result: result, err: err}
+	}
+}
+
+// running returns the number of jobs running
+func (m dashboardModel) running() int {
+	n := 0
+	for _, job := range m.jobs {
+		if job.state == jobRunning {
+			n++
+		}
+	}
+	return n
+}
+
+// finished reports whether every job has ended
+func (m dashboardModel) finished() bool {
+	for _, job := range m.jobs {
+		if job.state == jobQueued || job.state == jobRunning {
+			return false
+		}
+	}
+	return true
+}
+
+// counts returns the number of jobs that succeeded, failed and were canceled
+func (m dashboardModel) counts() (succeeded, failed, canceled int) {
+	for _, job := range m.jobs {
+		switch job.state {
+		case jobSucceeded:
+			succeeded++
+		case jobFailed:
+			failed++
+		case jobCanceled:
+			canceled++
+		}
+	}
+	return succeeded, failed, canceled
+}
+
+func (m dashboardModel) View() string {
+	if m.viewingLog && len(m.jobs) > 0 {
+		return m.viewJobLog()
+	}
+	return m.viewJobs()
+}
+
+// viewJobs renders the list of jobs with their progress
+func (m dashboardModel) viewJobs() string {
+	var b strings.Builder
+
+	b.WriteString(TitleStyle.Render("📦 " + i18n.T("Batch")))
+	b.WriteString("\n")
+	succeeded, failed, canceled := m.counts()
+	summary := i18n.Tf("%d of %d done · %d running · %d failed", succeeded+failed+canceled, len(m.jobs), m.running(), failed)
+	if canceled > 0 {
+		summary += " · " + i18n.Tf("%d canceled", canceled)
+	}
+	b.WriteString(DimStyle.Render(summary))
+	b.WriteString("\n\n")
+
+	nameWidth := 0
+	for _, job := range m.jobs {
+		nameWidth = max(nameWidth, lipgloss.Width(job.Name))
+	}
+	nameWidth = min(nameWidth, 32)
+
+	// Only the rows around the selected job are listed when they do not all fit
+	rows := dashboardListRows
+	if m.height > 0 {
+		rows = max(m.height-10, 3)
+	}
+	first := 0
+	if len(m.jobs) > rows {
+		first = min(max(m.selected-rows/2, 0), len(m.jobs)-rows)
+	}
+	last := min(first+rows, len(m.jobs))
+
+	var list strings.Builder
+	for i := first; i < last; i++ {
+		job := m.jobs[i]
+		name := truncate(job.Name, nameWidth)
+		name += strings.Repeat(" ", nameWidth-lipgloss.Width(name))
+		if i == m.selected {
+			name = lipgloss.NewStyle().Foreground(primaryColor).Bold(true).Render("› " + name)
+		} else {
+			name = "  " + name
+		}
+		list.WriteString(m.jobIcon(job) + " " + name + "  " + m.jobStatus(job))
+		if i < last-1 {
+			list.WriteString("\n")
+		}
+	}
+	b.WriteString(BoxStyle.Render(list.String()))
+	b.WriteString("\n\n")
+
+	b.WriteString(m.viewHelp(m.keys.DashboardHelp(m.finished())))
+	return AppStyle.Render(b.String())
+}
+
+// viewJobLog renders the logs of the selected job
+func (m dashboardModel) viewJobLog() string {
+	var b strings.Builder
+	job := m.jobs[m.selected]
+
+	b.WriteString(TitleStyle.Render("📜 " + job.Name))
+	b.WriteString("\n")
+	status := m.jobStatus(job)
+	if job.state == jobFailed {
+		// The list shows the first line of an error, the log its whole text
+		status = ErrorStyle.Render(job.err.Error())
+	}
+	b.WriteString(m.jobIcon(job) + " " + status)
+	b.WriteString("\n\n")
+
+	lines := dashboardListRows
+	if m.height > 0 {
+		lines = max(m.height-12, 3)
+	}
+	logs := job.logs.Tail(lines)
+	if len(logs) == 0 {
+		logs = []string{i18n.T("No log lines yet")}
+	}
+	width := 100
+	if m.width > 0 {
+		width = max(m.width-8, 20)
+	}
+	for i, line := range logs {
+		logs[i] = truncate(line, width)
+	}
+	b.WriteString(DimStyle.Render(strings.Join(logs, "\n")))
+	b.WriteString("\n\n")
+
+	b.WriteString(m.viewHelp(m.keys.DashboardLogHelp()))
+	return AppStyle.Render(b.String())
+}
+
+// viewHelp renders the help bar, or the cancel confirmation or notice
+func (m dashboardModel) viewHelp(bindings []key.Binding) string {
+	switch {
+	case m.canceling:
+		return DimStyle.Render(i18n.T("Stopping the running packages after the current step..."))
+	case m.confirmCancel:
+		return WarningStyle.Render(i18n.T("Cancel the batch? y/n")) + "\n\n" + renderHelp(m.keys.CancelBatchHelp())
+	}
+	return renderHelp(bindings)
+}
+
+// jobIcon returns the icon of the state of a job
+func (m dashboardModel) jobIcon(job dashboardJob) string {
+	switch job.state {
+	case jobRunning:
+		return m.spinner.View()
+	case jobSucceeded:
+		return CheckmarkStyle.String()
+	case jobFailed:
+		return ErrorStyle.Render("✗")
+	case jobCanceled:
+		return WarningStyle.Render("⊘")
+	}
+	return PendingStyle.String()
+}
+
+// jobStatus returns the progress bar of a running job, or the outcome of an ended one
+func (m dashboardModel) jobStatus(job dashboardJob) string {
+	switch job.state {
+	case jobRunning:
+		status := fmt.Sprintf("%3.0f%%", job.progress.Percent*100)
+		if rate := job.progress.Rate(); rate != "" {
+			status += " · " + rate
+		}
+		return renderProgressBar(job.progress.Percent, 20) + " " + ProgressTextStyle.Render(status)
+	case jobSucceeded:
+		return DimStyle.Render(i18n.Tf("%s in %s", packager.FormatSize(job.result.FinalSize), job.elapsed.Round(time.Second)))
+	case jobFailed:
+		return ErrorStyle.Render(truncate(firstLine(job.err.Error()), 60))
+	case jobCanceled:
+		return WarningStyle.Render(i18n.T("canceled"))
+	}
+	return DimStyle.Render(i18n.T("queued"))
+}
+
+// firstLine returns the first line of s
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// truncate shortens s to width columns, ending it with an ellipsis when cut
+func truncate(s string, width int) string {
+	if lipgloss.Width(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	runes = runes[:min(len(runes), width)]
+	for len(runes) > 0 && lipgloss.Width(string(runes))+1 > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
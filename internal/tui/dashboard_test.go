@@ -0,0 +1,214 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// newTestDashboard creates a dashboard of n jobs whose runs are counted in runs
+func newTestDashboard(t *testing.T, n, parallel int) (dashboardModel, *[]int) {
+	t.Helper()
+	var mu sync.Mutex
+	runs := &[]int{}
+	jobs := make([]BatchJob, n)
+	for i := range jobs {
+		index := i
+		jobs[i] = BatchJob{Name: fmt.Sprintf("app%d", i), Run: func(ctx context.Context, progress func(packager.Progress), logs io.Writer) (*packager.PackageResult, error) {
+			mu.Lock()
+			*runs = append(*runs, index)
+			mu.Unlock()
+			return &packager.PackageResult{}, nil
+		}}
+	}
+	m := newDashboardModel(context.Background(), jobs, parallel, DefaultKeyMap)
+	t.Cleanup(m.cancel)
+	return m, runs
+}
+
+// updateDashboard passes a message to the dashboard and returns the updated model
+func updateDashboard(t *testing.T, m dashboardModel, msg tea.Msg) (dashboardModel, tea.Cmd) {
+	t.Helper()
+	next, cmd := m.Update(msg)
+	updated, ok := next.(dashboardModel)
+	if !ok {
+		t.Fatalf("Update() returned %T, want dashboardModel", next)
+	}
+	return updated, cmd
+}
+
+// runJobCmds runs the job commands of a batch and returns the messages they send
+func runJobCmds(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+	msg := cmd()
+	batch, ok := msg.(tea.BatchMsg)
+	if !ok {
+		return []tea.Msg{msg}
+	}
+	var msgs []tea.Msg
+	for _, c := range batch {
+		msgs = append(msgs, runJobCmds(c)...)
+	}
+	return msgs
+}
+
+// isQuit reports whether cmd ends the program
+func isQuit(cmd tea.Cmd) bool {
+	if cmd == nil {
+		return false
+	}
+	_, ok := cmd().(tea.QuitMsg)
+	return ok
+}
+
+// jobStates returns the state of each job of the dashboard
+func jobStates(m dashboardModel) []jobState {
+	s := make([]jobState, len(m.jobs))
+	for i, job := range m.jobs {
+		s[i] = job.state
+	}
+	return s
+}
+
+func TestDashboardRunsUpToParallel(t *testing.T) {
+	m, runs := newTestDashboard(t, 5, 2)
+	m, cmd := updateDashboard(t, m, jobsStartMsg{})
+	if m.running() != 2 || m.finished() {
+		t.Fatalf("After start: running() = %d, finished() = %v, want 2 running", m.running(), m.finished())
+	}
+
+	// Each job that ends starts the next queued one, never more than parallel at a time
+	pending := runJobCmds(cmd)
+	if len(pending) != 2 || len(*runs) != 2 {
+		t.Fatalf("Start ran %d jobs and sent %d messages, want 2", len(*runs), len(pending))
+	}
+	for len(pending) > 0 {
+		msg := pending[0]
+		pending = pending[1:]
+		done, ok := msg.(jobDoneMsg)
+		if !ok {
+			t.Fatalf("Job sent %T, want jobDoneMsg", msg)
+		}
+		if done.index == 1 {
+			done.err = errors.New("setup file not found")
+		}
+		m, cmd = updateDashboard(t, m, done)
+		if m.running() > 2 {
+			t.Fatalf("%d jobs running, want at most 2: %v", m.running(), jobStates(m))
+		}
+		pending = append(pending, runJobCmds(cmd)...)
+	}
+
+	if !m.finished() || m.running() != 0 || len(*runs) != 5 {
+		t.Fatalf("After all jobs: states %v, %d runs, want 5 ended", jobStates(m), len(*runs))
+	}
+	if succeeded, failed, canceled := m.counts(); succeeded != 4 || failed != 1 || canceled != 0 {
+		t.Errorf("counts() = %d, %d, %d, want 4 succeeded and 1 failed", succeeded, failed, canceled)
+	}
+	if m.jobs[1].err == nil || m.jobs[0].result == nil {
+		t.Errorf("Jobs should keep their result and error: %+v", m.jobs[:2])
+	}
+
+	// The dashboard stays open until it is closed
+	if _, cmd := updateDashboard(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")}); !isQuit(cmd) {
+		t.Error("q on a finished batch should quit")
+	}
+}
+
+func TestDashboardProgress(t *testing.T) {
+	m, _ := newTestDashboard(t, 2, 1)
+	m, _ = updateDashboard(t, m, jobsStartMsg{})
+
+	m, _ = updateDashboard(t, m, jobProgressMsg{index: 0, progress: packager.Progress{Step: "Encrypting content", Percent: 0.5}})
+	m, _ = updateDashboard(t, m, jobProgressMsg{index: 1, progress: packager.Progress{Step: "Encrypting content", Percent: 0.5}})
+	if m.jobs[0].progress.Percent != 0.5 {
+		t.Errorf("Progress of the running job = %+v, want 50%%", m.jobs[0].progress)
+	}
+	if m.jobs[1].progress.Percent != 0 {
+		t.Errorf("Progress of a queued job = %+v, want none", m.jobs[1].progress)
+	}
+}
+
+func TestDashboardCancel(t *testing.T) {
+	m, runs := newTestDashboard(t, 4, 2)
+	m, _ = updateDashboard(t, m, jobsStartMsg{})
+
+	// Quitting asks first; the jobs keep running until it is confirmed
+	m, cmd := updateDashboard(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	if !m.confirmCancel || m.canceling || isQuit(cmd) {
+		t.Fatalf("q while running: confirmCancel %v, canceling %v, want a confirmation", m.confirmCancel, m.canceling)
+	}
+	m, cmd = updateDashboard(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	if !m.canceling || m.ctx.Err() == nil || isQuit(cmd) {
+		t.Fatalf("Confirmed cancel: canceling %v, ctx %v, want the jobs canceled but not quit", m.canceling, m.ctx.Err())
+	}
+	want := []jobState{jobRunning, jobRunning, jobCanceled, jobCanceled}
+	for i, state := range jobStates(m) {
+		if state != want[i] {
+			t.Fatalf("States after cancel = %v, want %v", jobStates(m), want)
+		}
+	}
+
+	// Keys are ignored while canceling
+	if next, _ := updateDashboard(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")}); next.confirmCancel {
+		t.Error("q while canceling should be ignored")
+	}
+
+	// The program quits when the last running job ends, and no queued job starts
+	m, cmd = updateDashboard(t, m, jobDoneMsg{index: 0, err: context.Canceled})
+	if isQuit(cmd) || m.jobs[0].state != jobCanceled {
+		t.Fatalf("First canceled job: state %v, want canceled and the program still running", m.jobs[0].state)
+	}
+	m, cmd = updateDashboard(t, m, jobDoneMsg{index: 1, result: &packager.PackageResult{}})
+	if !isQuit(cmd) {
+		t.Error("The program should quit once no job is running")
+	}
+	if m.jobs[1].state != jobSucceeded || len(*runs) != 0 {
+		t.Errorf("Job 1 = %v with %d runs, want a job that finished while canceling to succeed", m.jobs[1].state, len(*runs))
+	}
+	if succeeded, failed, canceled := m.counts(); succeeded != 1 || failed != 0 || canceled != 3 {
+		t.Errorf("counts() = %d, %d, %d, want 1 succeeded and 3 canceled", succeeded, failed, canceled)
+	}
+}
+
+func TestDashboardCancelWithoutRunningJobs(t *testing.T) {
+	m, _ := newTestDashboard(t, 2, 1)
+
+	// Before the first job starts, a confirmed cancel quits at once
+	m, _ = updateDashboard(t, m, tea.KeyMsg{Type: tea.KeyEsc})
+	if !m.confirmCancel {
+		t.Fatal("esc should ask to cancel the batch")
+	}
+	m, cmd := updateDashboard(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	if m.confirmCancel || m.canceling || isQuit(cmd) {
+		t.Fatal("n should keep the batch running")
+	}
+	m, _ = updateDashboard(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	m, cmd = updateDashboard(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	if !isQuit(cmd) {
+		t.Error("Canceling with no job running should quit")
+	}
+	if _, _, canceled := m.counts(); canceled != 2 {
+		t.Errorf("%d jobs canceled, want 2", canceled)
+	}
+
+	// Queued jobs of a batch whose context ended are canceled instead of started
+	m, runs := newTestDashboard(t, 3, 1)
+	m.cancel()
+	m, cmd = m.startJobs()
+	if m.running() != 0 || runJobCmds(cmd) != nil || len(*runs) != 0 {
+		t.Errorf("startJobs() after the context ended started %d jobs", m.running())
+	}
+	if _, _, canceled := m.counts(); canceled != 3 {
+		t.Errorf("%d jobs canceled, want 3", canceled)
+	}
+}
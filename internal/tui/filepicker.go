@@ -5,6 +5,9 @@ import (
 	"path/filepath"
 
 	"github.com/charmbracelet/bubbles/filepicker"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/i18n"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
 )
 
 // newFilePicker creates a new file picker configured for directory selection
@@ -25,7 +28,7 @@ func newFilePicker(dirOnly bool) filepicker.Model {
 
 	if !dirOnly {
 		// Allow common installer file types
-		fp.AllowedTypes = []string{".msi", ".exe", ".ps1", ".cmd", ".bat"}
+		fp.AllowedTypes = packager.SetupFileTypes
 	}
 
 	// Set height for better visibility
@@ -88,7 +91,7 @@ func configureFilePickerForOutput(fp *filepicker.Model, currentValue string) {
 func configureFilePickerForSetupFile(fp *filepicker.Model, sourceFolder string) {
 	fp.DirAllowed = false
 	fp.FileAllowed = true
-	fp.AllowedTypes = []string{".msi", ".exe", ".ps1", ".cmd", ".bat"}
+	fp.AllowedTypes = packager.SetupFileTypes
 
 	// Start in source folder if available
 	if sourceFolder != "" {
@@ -102,13 +105,13 @@ func configureFilePickerForSetupFile(fp *filepicker.Model, sourceFolder string)
 func getFilePickerTitle(target FilePickerTarget) string {
 	switch target {
 	case PickerTargetSourceFolder:
-		return "Select Source Folder"
+		return i18n.T("Select Source Folder")
 	case PickerTargetSetupFile:
-		return "Select Setup File"
+		return i18n.T("Select Setup File")
 	case PickerTargetOutputFolder:
-		return "Select Output Folder"
+		return i18n.T("Select Output Folder")
 	default:
-		return "Select"
+		return i18n.T("Select")
 	}
 }
 
@@ -116,13 +119,13 @@ func getFilePickerTitle(target FilePickerTarget) string {
 func getFilePickerHelp(target FilePickerTarget) string {
 	switch target {
 	case PickerTargetSourceFolder:
-		return "Navigate to and select the folder containing your setup file"
+		return i18n.T("Navigate to and select the folder containing your setup file")
 	case PickerTargetSetupFile:
-		return "Navigate to and select the setup file (.msi, .exe, .ps1, .cmd, .bat)"
+		return i18n.T("Navigate to and select the setup file (.msi, .exe, .ps1, .cmd, .bat, .msix, .appx)")
 	case PickerTargetOutputFolder:
-		return "Navigate to and select the folder where the .intunewin file will be created"
+		return i18n.T("Navigate to and select the folder where the .intunewin file will be created")
 	default:
-		return "Navigate and select"
+		return i18n.T("Navigate and select")
 	}
 }
 
@@ -138,73 +141,12 @@ func validatePath(path string, expectDir bool) bool {
 	return !info.IsDir()
 }
 
-// listSetupFiles lists potential setup files in a directory
-func listSetupFiles(dir string) ([]string, error) {
-	var files []string
-	extensions := map[string]bool{
-		".msi": true,
-		".exe": true,
-		".ps1": true,
-		".cmd": true,
-		".bat": true,
-	}
-
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-		ext := filepath.Ext(entry.Name())
-		if extensions[ext] || extensions["."+ext] {
-			files = append(files, entry.Name())
-		}
-	}
-
-	return files, nil
-}
-
 // autoDetectSetupFile attempts to find the main setup file in a directory
 // It looks for common installer patterns
 func autoDetectSetupFile(dir string) string {
-	files, err := listSetupFiles(dir)
-	if err != nil || len(files) == 0 {
+	files, err := packager.ListSetupFiles(dir)
+	if err != nil {
 		return ""
 	}
-
-	// Priority patterns for setup file detection
-	patterns := []string{
-		"setup.msi",
-		"setup.exe",
-		"install.msi",
-		"install.exe",
-		"installer.msi",
-		"installer.exe",
-	}
-
-	// Check for priority patterns first
-	for _, pattern := range patterns {
-		for _, file := range files {
-			if filepath.Base(file) == pattern {
-				return file
-			}
-		}
-	}
-
-	// Prefer MSI over EXE
-	for _, file := range files {
-		if filepath.Ext(file) == ".msi" {
-			return file
-		}
-	}
-
-	// Return first file found
-	if len(files) > 0 {
-		return files[0]
-	}
-
-	return ""
+	return packager.PreferredSetupFile(files)
 }
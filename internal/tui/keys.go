@@ -1,7 +1,13 @@
 package tui
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/charmbracelet/bubbles/key"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/i18n"
 )
 
 // KeyMap defines the key bindings for the application
@@ -20,66 +26,171 @@ type KeyMap struct {
 	Retry    key.Binding
 	Help     key.Binding
 	Back     key.Binding
+	Recent   key.Binding
+	Settings key.Binding
+	Upload   key.Binding
+	Open     key.Binding
+	Copy     key.Binding
 }
 
 // DefaultKeyMap returns the default key bindings
 var DefaultKeyMap = KeyMap{
 	Up: key.NewBinding(
 		key.WithKeys("up", "k"),
-		key.WithHelp("↑/k", "up"),
+		key.WithHelp("↑/k", i18n.N("up")),
 	),
 	Down: key.NewBinding(
 		key.WithKeys("down", "j"),
-		key.WithHelp("↓/j", "down"),
+		key.WithHelp("↓/j", i18n.N("down")),
 	),
 	Left: key.NewBinding(
 		key.WithKeys("left", "h"),
-		key.WithHelp("←/h", "left"),
+		key.WithHelp("←/h", i18n.N("left")),
 	),
 	Right: key.NewBinding(
 		key.WithKeys("right", "l"),
-		key.WithHelp("→/l", "right"),
+		key.WithHelp("→/l", i18n.N("right")),
 	),
 	Tab: key.NewBinding(
 		key.WithKeys("tab"),
-		key.WithHelp("tab", "next field"),
+		key.WithHelp("tab", i18n.N("next field")),
 	),
 	ShiftTab: key.NewBinding(
 		key.WithKeys("shift+tab"),
-		key.WithHelp("shift+tab", "prev field"),
+		key.WithHelp("shift+tab", i18n.N("prev field")),
 	),
 	Enter: key.NewBinding(
 		key.WithKeys("enter"),
-		key.WithHelp("enter", "confirm/select"),
+		key.WithHelp("enter", i18n.N("confirm/select")),
 	),
 	Space: key.NewBinding(
 		key.WithKeys(" "),
-		key.WithHelp("space", "select"),
+		key.WithHelp("space", i18n.N("select")),
 	),
 	Escape: key.NewBinding(
 		key.WithKeys("esc"),
-		key.WithHelp("esc", "cancel/back"),
+		key.WithHelp("esc", i18n.N("cancel/back")),
 	),
 	Browse: key.NewBinding(
 		key.WithKeys("ctrl+o", "ctrl+b", "f2"),
-		key.WithHelp("ctrl+o/F2", "browse"),
+		key.WithHelp("ctrl+o/F2", i18n.N("browse")),
 	),
 	Quit: key.NewBinding(
 		key.WithKeys("q", "ctrl+c"),
-		key.WithHelp("q", "quit"),
+		key.WithHelp("q", i18n.N("quit")),
 	),
 	Retry: key.NewBinding(
 		key.WithKeys("r"),
-		key.WithHelp("r", "retry"),
+		key.WithHelp("r", i18n.N("retry")),
 	),
 	Help: key.NewBinding(
-		key.WithKeys("?"),
-		key.WithHelp("?", "help"),
+		key.WithKeys("?", "f1"),
+		key.WithHelp("?/F1", i18n.N("help")),
 	),
 	Back: key.NewBinding(
 		key.WithKeys("backspace"),
-		key.WithHelp("backspace", "go back"),
+		key.WithHelp("backspace", i18n.N("go back")),
+	),
+	Recent: key.NewBinding(
+		key.WithKeys("ctrl+r"),
+		key.WithHelp("ctrl+r", i18n.N("recent jobs")),
+	),
+	Settings: key.NewBinding(
+		key.WithKeys("s"),
+		key.WithHelp("s", i18n.N("settings")),
+	),
+	Upload: key.NewBinding(
+		key.WithKeys("u"),
+		key.WithHelp("u", i18n.N("upload")),
 	),
+	Open: key.NewBinding(
+		key.WithKeys("o"),
+		key.WithHelp("o", i18n.N("open folder")),
+	),
+	Copy: key.NewBinding(
+		key.WithKeys("c"),
+		key.WithHelp("c", i18n.N("copy path")),
+	),
+}
+
+// KeyAction is a remappable action, named as in the tui.keys section of the config file
+// Description is in English; it is translated when shown
+type KeyAction struct {
+	Name        string
+	Description string
+	binding     func(*KeyMap) *key.Binding
+}
+
+// KeyActions lists the remappable actions in the order of the help overlay
+var KeyActions = []KeyAction{
+	{"nextField", i18n.N("Move to the next field"), func(k *KeyMap) *key.Binding { return &k.Tab }},
+	{"prevField", i18n.N("Move to the previous field"), func(k *KeyMap) *key.Binding { return &k.ShiftTab }},
+	{"confirm", i18n.N("Confirm, submit or select"), func(k *KeyMap) *key.Binding { return &k.Enter }},
+	{"select", i18n.N("Select or toggle"), func(k *KeyMap) *key.Binding { return &k.Space }},
+	{"cancel", i18n.N("Cancel, go back or stop packaging"), func(k *KeyMap) *key.Binding { return &k.Escape }},
+	{"browse", i18n.N("Open the file browser for the focused field"), func(k *KeyMap) *key.Binding { return &k.Browse }},
+	{"recent", i18n.N("Open recent jobs"), func(k *KeyMap) *key.Binding { return &k.Recent }},
+	{"settings", i18n.N("Open settings"), func(k *KeyMap) *key.Binding { return &k.Settings }},
+	{"upload", i18n.N("Upload a created package to Intune"), func(k *KeyMap) *key.Binding { return &k.Upload }},
+	{"openFolder", i18n.N("Open the output folder of a created package"), func(k *KeyMap) *key.Binding { return &k.Open }},
+	{"copyPath", i18n.N("Copy the path and SHA256 of a created package"), func(k *KeyMap) *key.Binding { return &k.Copy }},
+	{"retry", i18n.N("Retry a failed package"), func(k *KeyMap) *key.Binding { return &k.Retry }},
+	{"back", i18n.N("Go back from the error screen"), func(k *KeyMap) *key.Binding { return &k.Back }},
+	{"up", i18n.N("Move up in lists"), func(k *KeyMap) *key.Binding { return &k.Up }},
+	{"down", i18n.N("Move down in lists"), func(k *KeyMap) *key.Binding { return &k.Down }},
+	{"left", i18n.N("Previous option"), func(k *KeyMap) *key.Binding { return &k.Left }},
+	{"right", i18n.N("Next option"), func(k *KeyMap) *key.Binding { return &k.Right }},
+	{"help", i18n.N("Show this keyboard map"), func(k *KeyMap) *key.Binding { return &k.Help }},
+	{"quit", i18n.N("Quit"), func(k *KeyMap) *key.Binding { return &k.Quit }},
+}
+
+// NewKeyMap returns the default key bindings with the actions in remap bound to other keys
+// remap maps action names (see KeyActions) to key names such as "alt+o" or "f3"; a remapped
+// action loses its default keys. Keys bound to two actions are rejected.
+func NewKeyMap(remap map[string][]string) (KeyMap, error) {
+	k := DefaultKeyMap
+	actions := make(map[string]KeyAction, len(KeyActions))
+	for _, action := range KeyActions {
+		actions[action.Name] = action
+	}
+
+	for name, keys := range remap {
+		action, ok := actions[name]
+		if !ok {
+			return k, fmt.Errorf("unknown key action %q (supported: %s)", name, strings.Join(keyActionNames(), ", "))
+		}
+		if len(keys) == 0 {
+			return k, fmt.Errorf("no keys given for key action %q", name)
+		}
+		b := action.binding(&k)
+		*b = key.NewBinding(key.WithKeys(keys...), key.WithHelp(strings.Join(keys, "/"), b.Help().Desc))
+	}
+
+	owner := make(map[string]string)
+	for _, action := range KeyActions {
+		for _, name := range action.binding(&k).Keys() {
+			if other, ok := owner[name]; ok {
+				return k, fmt.Errorf("key %q is bound to both %s and %s", name, other, action.Name)
+			}
+			owner[name] = action.Name
+		}
+	}
+	return k, nil
+}
+
+// keyActionNames returns the names of the remappable actions, sorted
+func keyActionNames() []string {
+	names := make([]string, 0, len(KeyActions))
+	for _, action := range KeyActions {
+		names = append(names, action.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Binding returns the binding of an action in this key map
+func (a KeyAction) Binding(k KeyMap) key.Binding {
+	return *a.binding(&k)
 }
 
 // ShortHelp returns the short help string for all keys
@@ -96,54 +207,189 @@ func (k KeyMap) FullHelp() [][]key.Binding {
 	}
 }
 
-// WelcomeKeyMap returns key bindings for the welcome screen
-func WelcomeKeyMap() []key.Binding {
+// hint relabels a binding for the help bar of a screen, keeping its (possibly remapped) keys
+func hint(b key.Binding, desc string) key.Binding {
+	return key.NewBinding(key.WithKeys(b.Keys()...), key.WithHelp(b.Help().Key, desc))
+}
+
+// navHint describes a pair of bindings that move through a list or between options
+func navHint(a, b key.Binding, desc string) key.Binding {
+	return key.NewBinding(
+		key.WithKeys(append(a.Keys(), b.Keys()...)...),
+		key.WithHelp(a.Help().Key+" "+b.Help().Key, desc),
+	)
+}
+
+// WelcomeHelp returns key bindings for the welcome screen
+func (k KeyMap) WelcomeHelp() []key.Binding {
 	return []key.Binding{
-		key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "start")),
-		key.NewBinding(key.WithKeys("q"), key.WithHelp("q", "quit")),
+		hint(k.Enter, i18n.N("start")),
+		hint(k.Recent, i18n.N("recent")),
+		hint(k.Settings, i18n.N("settings")),
+		hint(k.Help, i18n.N("keys")),
+		hint(k.Quit, i18n.N("quit")),
 	}
 }
 
-// InputKeyMap returns key bindings for the input screen
-func InputKeyMap() []key.Binding {
+// InputHelp returns key bindings for the input screen
+func (k KeyMap) InputHelp() []key.Binding {
 	return []key.Binding{
-		key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "next")),
-		key.NewBinding(key.WithKeys("shift+tab"), key.WithHelp("shift+tab", "prev")),
-		key.NewBinding(key.WithKeys("ctrl+o", "ctrl+b", "f2"), key.WithHelp("ctrl+o/F2", "browse")),
-		key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "submit")),
-		key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "back")),
+		hint(k.Tab, i18n.N("next")),
+		hint(k.ShiftTab, i18n.N("prev")),
+		hint(k.Browse, i18n.N("browse")),
+		hint(k.Recent, i18n.N("recent")),
+		hint(k.Enter, i18n.N("submit")),
+		hint(k.Escape, i18n.N("back")),
+	}
+}
+
+// FilePickerHelp returns key bindings for the file picker screen
+func (k KeyMap) FilePickerHelp() []key.Binding {
+	return []key.Binding{
+		key.NewBinding(key.WithKeys("up/down"), key.WithHelp("↑/↓", i18n.N("navigate"))),
+		key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", i18n.N("select"))),
+		hint(k.Escape, i18n.N("cancel")),
+	}
+}
+
+// RecentHelp returns key bindings for the recent jobs screen
+func (k KeyMap) RecentHelp() []key.Binding {
+	return []key.Binding{
+		navHint(k.Up, k.Down, i18n.N("navigate")),
+		hint(k.Enter, i18n.N("use")),
+		hint(k.Escape, i18n.N("back")),
+	}
+}
+
+// SettingsHelp returns key bindings for the settings screen
+func (k KeyMap) SettingsHelp() []key.Binding {
+	return []key.Binding{
+		key.NewBinding(key.WithKeys(append(k.Tab.Keys(), "down")...), key.WithHelp(k.Tab.Help().Key+"/↓", i18n.N("next"))),
+		key.NewBinding(key.WithKeys("left", "right"), key.WithHelp("←/→", i18n.N("change theme"))),
+		hint(k.Space, i18n.N("toggle")),
+		hint(k.Enter, i18n.N("save")),
+		hint(k.Escape, i18n.N("cancel")),
+	}
+}
+
+// ConfirmHelp returns key bindings for the confirmation screen
+func (k KeyMap) ConfirmHelp() []key.Binding {
+	return []key.Binding{
+		hint(k.Enter, i18n.N("create package")),
+		hint(k.Escape, i18n.N("back")),
+		hint(k.Quit, i18n.N("quit")),
+	}
+}
+
+// ProcessingHelp returns key bindings for the processing screen
+func (k KeyMap) ProcessingHelp() []key.Binding {
+	return []key.Binding{
+		key.NewBinding(key.WithKeys(append(k.Escape.Keys(), "ctrl+c")...), key.WithHelp(k.Escape.Help().Key, i18n.N("cancel"))),
+	}
+}
+
+// CancelHelp returns key bindings for the cancel confirmation on the processing screen
+func (k KeyMap) CancelHelp() []key.Binding {
+	return []key.Binding{
+		key.NewBinding(key.WithKeys("y"), key.WithHelp("y", i18n.N("cancel packaging"))),
+		key.NewBinding(key.WithKeys("n", "esc"), key.WithHelp("n", i18n.N("keep going"))),
+	}
+}
+
+// SuccessHelp returns key bindings for the success screen, with the upload key when
+// packages can be uploaded
+func (k KeyMap) SuccessHelp(upload bool) []key.Binding {
+	bindings := []key.Binding{
+		hint(k.Enter, i18n.N("new package")),
+		hint(k.Open, i18n.N("open folder")),
+		hint(k.Copy, i18n.N("copy path")),
+	}
+	if upload {
+		bindings = append(bindings, hint(k.Upload, i18n.N("upload to Intune")))
+	}
+	return append(bindings,
+		hint(k.Settings, i18n.N("settings")),
+		hint(k.Quit, i18n.N("quit")),
+	)
+}
+
+// TenantHelp returns key bindings for the tenant selection screen
+func (k KeyMap) TenantHelp() []key.Binding {
+	return []key.Binding{
+		navHint(k.Up, k.Down, i18n.N("navigate")),
+		hint(k.Enter, i18n.N("select")),
+		hint(k.Escape, i18n.N("back")),
+	}
+}
+
+// AppDetailsHelp returns key bindings for the app details screen
+func (k KeyMap) AppDetailsHelp() []key.Binding {
+	return []key.Binding{
+		key.NewBinding(key.WithKeys(append(k.Tab.Keys(), "down")...), key.WithHelp(k.Tab.Help().Key+"/↓", i18n.N("next"))),
+		hint(k.ShiftTab, i18n.N("prev")),
+		hint(k.Enter, i18n.N("upload")),
+		hint(k.Escape, i18n.N("back")),
+	}
+}
+
+// CancelUploadHelp returns key bindings for the cancel confirmation on the upload screen
+func (k KeyMap) CancelUploadHelp() []key.Binding {
+	return []key.Binding{
+		key.NewBinding(key.WithKeys("y"), key.WithHelp("y", i18n.N("cancel upload"))),
+		key.NewBinding(key.WithKeys("n", "esc"), key.WithHelp("n", i18n.N("keep going"))),
+	}
+}
+
+// UploadedHelp returns key bindings for the screen shown after an upload
+func (k KeyMap) UploadedHelp() []key.Binding {
+	return []key.Binding{
+		hint(k.Browse, i18n.N("open in browser")),
+		hint(k.Enter, i18n.N("new package")),
+		hint(k.Quit, i18n.N("quit")),
+	}
+}
+
+// DashboardHelp returns key bindings for the batch dashboard, with quit rather than
+// cancel once every job has ended
+func (k KeyMap) DashboardHelp(finished bool) []key.Binding {
+	bindings := []key.Binding{
+		navHint(k.Up, k.Down, i18n.N("navigate")),
+		hint(k.Enter, i18n.N("view log")),
+	}
+	if finished {
+		return append(bindings, hint(k.Quit, i18n.N("quit")))
 	}
+	return append(bindings, hint(k.Quit, i18n.N("cancel batch")))
 }
 
-// FilePickerKeyMap returns key bindings for the file picker screen
-func FilePickerKeyMap() []key.Binding {
+// DashboardLogHelp returns key bindings for the log of a job on the batch dashboard
+func (k KeyMap) DashboardLogHelp() []key.Binding {
 	return []key.Binding{
-		key.NewBinding(key.WithKeys("up/down"), key.WithHelp("↑/↓", "navigate")),
-		key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "select")),
-		key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "cancel")),
+		navHint(k.Up, k.Down, i18n.N("other job")),
+		hint(k.Escape, i18n.N("back")),
 	}
 }
 
-// ProcessingKeyMap returns key bindings for the processing screen
-func ProcessingKeyMap() []key.Binding {
+// CancelBatchHelp returns key bindings for the cancel confirmation on the batch dashboard
+func (k KeyMap) CancelBatchHelp() []key.Binding {
 	return []key.Binding{
-		key.NewBinding(key.WithKeys("q"), key.WithHelp("q", "quit")),
+		key.NewBinding(key.WithKeys("y"), key.WithHelp("y", i18n.N("cancel batch"))),
+		key.NewBinding(key.WithKeys("n", "esc"), key.WithHelp("n", i18n.N("keep going"))),
 	}
 }
 
-// SuccessKeyMap returns key bindings for the success screen
-func SuccessKeyMap() []key.Binding {
+// ErrorHelp returns key bindings for the error screen
+func (k KeyMap) ErrorHelp() []key.Binding {
 	return []key.Binding{
-		key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "new package")),
-		key.NewBinding(key.WithKeys("q"), key.WithHelp("q", "quit")),
+		hint(k.Retry, i18n.N("retry")),
+		hint(k.Escape, i18n.N("back")),
+		hint(k.Quit, i18n.N("quit")),
 	}
 }
 
-// ErrorKeyMap returns key bindings for the error screen
-func ErrorKeyMap() []key.Binding {
+// KeyMapHelp returns key bindings for the keyboard map overlay
+func (k KeyMap) KeyMapHelp() []key.Binding {
 	return []key.Binding{
-		key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "retry")),
-		key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "back")),
-		key.NewBinding(key.WithKeys("q"), key.WithHelp("q", "quit")),
+		hint(k.Escape, i18n.N("close")),
 	}
 }
@@ -0,0 +1,53 @@
+package tui
+
+import (
+	"strings"
+	"sync"
+)
+
+// DefaultLogBufferLines is the number of log lines kept for the error screen
+const DefaultLogBufferLines = 200
+
+// LogBuffer keeps the most recent log lines written during a TUI session
+// Logs cannot be printed while the TUI owns the terminal, so they are
+// collected here and shown on the error screen
+type LogBuffer struct {
+	mu      sync.Mutex
+	lines   []string
+	partial string
+	max     int
+}
+
+// NewLogBuffer creates a log buffer holding up to max lines
+func NewLogBuffer(max int) *LogBuffer {
+	return &LogBuffer{max: max}
+}
+
+// Write implements io.Writer, splitting the input into lines
+func (b *LogBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	text := b.partial + string(p)
+	parts := strings.Split(text, "\n")
+	b.partial = parts[len(parts)-1]
+	b.lines = append(b.lines, parts[:len(parts)-1]...)
+	if len(b.lines) > b.max {
+		b.lines = b.lines[len(b.lines)-b.max:]
+	}
+	return len(p), nil
+}
+
+// Tail returns up to n of the most recent lines
+func (b *LogBuffer) Tail(n int) []string {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if n > len(b.lines) {
+		n = len(b.lines)
+	}
+	return append([]string(nil), b.lines[len(b.lines)-n:]...)
+}
@@ -1,13 +1,21 @@
 package tui
 
 import (
+	"context"
+	"log/slog"
 	"os"
+	"slices"
+	"time"
 
 	"github.com/charmbracelet/bubbles/filepicker"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/config"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/i18n"
 	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
 )
 
@@ -21,6 +29,14 @@ const (
 	ScreenProcessing
 	ScreenSuccess
 	ScreenError
+	ScreenRecent
+	ScreenSettings
+	ScreenConfirm
+	ScreenKeyMap
+	ScreenTenant
+	ScreenAppDetails
+	ScreenUploading
+	ScreenUploaded
 )
 
 // FilePickerTarget indicates which input field the file picker is for
@@ -58,6 +74,11 @@ type Model struct {
 	inputs      []textinput.Model
 	focusIndex  int
 
+	// Setup file selector, filled from the source folder (empty for manual entry)
+	setupFiles    []string
+	setupIndex    int
+	setupDetected string
+
 	// File picker
 	filepicker       filepicker.Model
 	filePickerActive bool
@@ -67,14 +88,35 @@ type Model struct {
 	spinner       spinner.Model
 	progress      float64
 	progressStep  string
+	progressInfo  packager.Progress // Last report, with the speed and time remaining
 	processingLog []string
+	cancel        context.CancelFunc
+	confirmCancel bool
+	canceling     bool
+
+	// notice is a one-off message shown on the input or success screen
+	notice string
+
+	// Preview shown on the confirmation screen (nil while it is gathered)
+	preview *packager.Preview
 
 	// Results
 	result *packager.PackageResult
 	err    error
 
-	// Key bindings
-	keys KeyMap
+	// Recent packaging jobs
+	history     *config.History
+	recentIndex int
+
+	// Settings screen
+	settings settingsForm
+
+	// Upload of the created package to Intune
+	upload uploadForm
+
+	// Key bindings, and the screen the keyboard map overlay returns to
+	keys         KeyMap
+	keyMapReturn Screen
 
 	// Presets from CLI flags
 	presets *Presets
@@ -85,6 +127,30 @@ type Presets struct {
 	ContentPath string
 	SetupFile   string
 	OutputPath  string
+
+	// Options are packaging options applied to every package created in the session
+	Options packager.Options
+
+	// Logs collects log output of the session for the error screen (optional)
+	Logs *LogBuffer
+
+	// HistoryPath is the file recent jobs are loaded from and recorded to (empty disables history)
+	HistoryPath string
+
+	// ConfigPath is the config file the settings screen saves to (empty disables the screen)
+	ConfigPath string
+	// ProfileName is the profile that receives the output folder and exclusions
+	ProfileName string
+	// Settings are the interactive mode preferences from the config file
+	Settings config.TUISettings
+
+	// ReadOnly reviews packages without creating them (the confirm screen does not start packaging)
+	ReadOnly bool
+
+	// Tenants are the tenants created packages can be uploaded to, when Settings.Upload is set
+	Tenants []Tenant
+	// Upload creates an app in Intune from a created package (nil disables uploads)
+	Upload UploadFunc
 }
 
 // NewModel creates a new Model with initial state
@@ -100,7 +166,7 @@ func NewModel(presets *Presets) Model {
 
 	// Setup file input
 	inputs[1] = textinput.New()
-	inputs[1].Placeholder = "setup.msi or install.exe"
+	inputs[1].Placeholder = i18n.T("setup.msi or install.exe")
 	inputs[1].CharLimit = 256
 	inputs[1].Width = 50
 
@@ -165,18 +231,40 @@ func NewModel(presets *Presets) Model {
 	fp.Styles.DisabledFile = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
 	fp.Styles.DisabledSelected = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
 
+	// Remapped keys were validated by Run; a broken map falls back to the defaults
+	keys := DefaultKeyMap
+	if presets != nil {
+		if remapped, err := NewKeyMap(presets.Settings.Keys); err == nil {
+			keys = remapped
+		}
+	}
+
 	return Model{
 		screen:        ScreenWelcome,
 		inputs:        inputs,
 		focusIndex:    focusIdx,
 		spinner:       s,
 		filepicker:    fp,
-		keys:          DefaultKeyMap,
+		keys:          keys,
 		presets:       presets,
 		processingLog: make([]string, 0),
+		history:       loadHistory(presets),
 	}
 }
 
+// loadHistory reads the recent jobs; a missing or unreadable file yields an empty history
+func loadHistory(presets *Presets) *config.History {
+	if presets == nil || presets.HistoryPath == "" {
+		return &config.History{}
+	}
+	history, err := config.LoadHistory(presets.HistoryPath)
+	if err != nil {
+		slog.Warn("could not load packaging history", "error", err)
+		return &config.History{}
+	}
+	return history
+}
+
 // GetSourceFolder returns the source folder value
 func (m Model) GetSourceFolder() string {
 	return m.inputs[0].Value()
@@ -192,6 +280,14 @@ func (m Model) GetOutputFolder() string {
 	return m.inputs[2].Value()
 }
 
+// packagingOptions returns the packaging options passed from CLI flags
+func (m Model) packagingOptions() packager.Options {
+	if m.presets == nil {
+		return packager.Options{}
+	}
+	return m.presets.Options
+}
+
 // SetProgress updates the progress state
 func (m *Model) SetProgress(step string, percent float64) {
 	m.progressStep = step
@@ -208,13 +304,13 @@ func (m *Model) SetProgress(step string, percent float64) {
 // ValidateInputs checks if all required inputs are filled
 func (m Model) ValidateInputs() (bool, string) {
 	if m.inputs[0].Value() == "" {
-		return false, "Source folder is required"
+		return false, i18n.T("Source folder is required")
 	}
 	if m.inputs[1].Value() == "" {
-		return false, "Setup file is required"
+		return false, i18n.T("Setup file is required")
 	}
 	if m.inputs[2].Value() == "" {
-		return false, "Output folder is required"
+		return false, i18n.T("Output folder is required")
 	}
 	return true, ""
 }
@@ -255,6 +351,77 @@ func (m *Model) setFocus(idx int) {
 	}
 }
 
+// typing reports whether the focused element takes text, so printable keys must reach it
+func (m Model) typing() bool {
+	switch m.screen {
+	case ScreenInput:
+		return m.focusIndex < len(m.inputs) && !m.selectingSetupFile()
+	case ScreenSettings:
+		return int(m.settings.focus) < len(m.settings.inputs)
+	case ScreenAppDetails:
+		return int(m.upload.focus) < len(m.upload.inputs)
+	}
+	return false
+}
+
+// matches reports whether a key triggers a binding; printable keys are left to text fields
+func (m Model) matches(msg tea.KeyMsg, b key.Binding) bool {
+	if m.typing() && msg.Type == tea.KeyRunes {
+		return false
+	}
+	return key.Matches(msg, b)
+}
+
+// selectingSetupFile reports whether the setup file is picked from a list rather than typed
+func (m Model) selectingSetupFile() bool {
+	return m.focusIndex == int(FieldSetupFile) && len(m.setupFiles) > 0
+}
+
+// listSetupFiles refreshes the setup file selector for the source folder in the inputs
+func (m *Model) listSetupFiles() tea.Cmd {
+	dir := m.GetSourceFolder()
+	if dir == "" {
+		m.setSetupFiles(nil, "", "")
+		return nil
+	}
+	return listSetupFilesCmd(dir, m.GetSetupFile())
+}
+
+// setSetupFiles fills the setup file selector with the installers found in the source folder
+// The setup file in the inputs is kept when it exists there, otherwise the detected one is selected
+func (m *Model) setSetupFiles(files []string, detected, existing string) {
+	m.setupDetected = detected
+	if len(files) == 0 {
+		m.setupFiles = nil
+		m.setupIndex = 0
+		return
+	}
+
+	selected := detected
+	if existing != "" {
+		selected = existing
+		if !slices.Contains(files, existing) {
+			// A setup file in a subfolder was entered by hand or preset
+			files = append([]string{existing}, files...)
+		}
+	}
+	m.setupFiles = files
+	m.setupIndex = max(slices.Index(files, selected), 0)
+	m.inputs[1].SetValue(files[m.setupIndex])
+}
+
+// moveSetupSelection selects the previous or next setup file in the selector
+func (m *Model) moveSetupSelection(step int) {
+	m.setupIndex = (m.setupIndex + step + len(m.setupFiles)) % len(m.setupFiles)
+	m.inputs[1].SetValue(m.setupFiles[m.setupIndex])
+}
+
+// showKeyMap opens the keyboard map overlay over the current screen
+func (m *Model) showKeyMap() {
+	m.keyMapReturn = m.screen
+	m.screen = ScreenKeyMap
+}
+
 // inputLabelStyle returns the style for an input label based on focus state
 func (m Model) inputLabelStyle(idx int) lipgloss.Style {
 	if m.focusIndex == idx {
@@ -279,6 +446,103 @@ func (m Model) buttonStyle(idx int) lipgloss.Style {
 	return ButtonStyle
 }
 
+// readOnly reports whether the session only reviews packages
+func (m Model) readOnly() bool {
+	return m.presets != nil && m.presets.ReadOnly
+}
+
+// historyPath returns the history file, or "" when history is disabled
+func (m Model) historyPath() string {
+	if m.presets == nil {
+		return ""
+	}
+	return m.presets.HistoryPath
+}
+
+// recordJob adds the job in the inputs to the history and returns a command saving it
+func (m *Model) recordJob(result *packager.PackageResult, err error) tea.Cmd {
+	path := m.historyPath()
+	if path == "" {
+		return nil
+	}
+
+	entry := config.HistoryEntry{
+		SourcePath: m.GetSourceFolder(),
+		SetupFile:  m.GetSetupFile(),
+		OutputPath: m.GetOutputFolder(),
+		Succeeded:  err == nil,
+		Timestamp:  time.Now(),
+	}
+	if result != nil {
+		entry.Package = result.OutputPath
+		entry.GeneratorVersion = result.GeneratorVersion
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	m.history.Add(entry)
+	return saveHistoryCmd(path, *m.history)
+}
+
+// showRecent opens the recent jobs screen, if there are any
+func (m *Model) showRecent() {
+	if len(m.history.Entries) == 0 {
+		return
+	}
+	m.previousScreen = m.screen
+	m.recentIndex = 0
+	m.screen = ScreenRecent
+}
+
+// applyHistoryEntry fills the inputs from a recent job and focuses the submit button
+func (m *Model) applyHistoryEntry(entry config.HistoryEntry) tea.Cmd {
+	m.inputs[0].SetValue(entry.SourcePath)
+	m.inputs[1].SetValue(entry.SetupFile)
+	m.inputs[2].SetValue(entry.OutputPath)
+	m.screen = ScreenInput
+	m.setFocus(int(FieldSubmitButton))
+	m.setSetupFiles(nil, "", "")
+	return m.listSetupFiles()
+}
+
+// showConfirm opens the confirmation screen and starts gathering the package preview
+func (m *Model) showConfirm() tea.Cmd {
+	m.preview = nil
+	m.screen = ScreenConfirm
+	return previewPackageCmd(
+		m.GetSourceFolder(),
+		m.GetSetupFile(),
+		m.GetOutputFolder(),
+		m.packagingOptions(),
+	)
+}
+
+// startPackaging starts packaging the job in the inputs so that it can be canceled
+func (m *Model) startPackaging() tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.confirmCancel = false
+	m.canceling = false
+	m.notice = ""
+	return startPackaging(
+		ctx,
+		m.GetSourceFolder(),
+		m.GetSetupFile(),
+		m.GetOutputFolder(),
+		m.packagingOptions(),
+	)
+}
+
+// finishPackaging releases the context of the finished packaging run
+func (m *Model) finishPackaging() {
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+	m.confirmCancel = false
+	m.canceling = false
+}
+
 // resetForNewPackage resets the model state for creating a new package
 func (m *Model) resetForNewPackage() {
 	m.screen = ScreenInput
@@ -287,10 +551,12 @@ func (m *Model) resetForNewPackage() {
 	m.progress = 0
 	m.progressStep = ""
 	m.processingLog = make([]string, 0)
+	m.progressInfo = packager.Progress{}
 
 	// Clear inputs
 	for i := range m.inputs {
 		m.inputs[i].SetValue("")
 	}
+	m.setSetupFiles(nil, "", "")
 	m.setFocus(0)
 }
@@ -0,0 +1,149 @@
+package tui
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// PanicError is returned by Run when the TUI or a job it started panicked
+// The program has quit and restored the terminal by the time it is returned
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+var (
+	panicMu sync.Mutex
+	// caught is the first panic of the running program
+	caught *PanicError
+)
+
+// recordPanic keeps the first panic caught, with the stack of the goroutine that
+// panicked, and reports whether it was the first
+// It must be called from the deferred function that recovered r
+func recordPanic(r any) bool {
+	panicMu.Lock()
+	defer panicMu.Unlock()
+	if caught != nil {
+		return false
+	}
+	caught = &PanicError{Value: r, Stack: debug.Stack()}
+	return true
+}
+
+// panicked reports whether a panic has been caught
+func panicked() bool {
+	panicMu.Lock()
+	defer panicMu.Unlock()
+	return caught != nil
+}
+
+// takePanic returns the panic caught while the program ran, if any, and clears it
+func takePanic() *PanicError {
+	panicMu.Lock()
+	defer panicMu.Unlock()
+	err := caught
+	caught = nil
+	return err
+}
+
+// recoverJob recovers a panic in a goroutine started by the TUI and quits the program
+// Deferred at the start of every goroutine the TUI starts itself
+func recoverJob() {
+	if r := recover(); r != nil {
+		recordPanic(r)
+		if program != nil {
+			program.Quit()
+		}
+	}
+}
+
+// safeModel catches panics in a model and the commands it returns, so the program
+// quits normally and restores the terminal instead of leaving it in the alternate
+// screen and raw mode
+// Bubble Tea's own panic handling prints the stack over the screen and drops the
+// panic, which leaves nothing to report
+type safeModel struct {
+	model tea.Model
+}
+
+func (s safeModel) Init() (cmd tea.Cmd) {
+	defer func() {
+		if r := recover(); r != nil {
+			recordPanic(r)
+			cmd = tea.Quit
+		}
+	}()
+	return safeCmd(s.model.Init())
+}
+
+func (s safeModel) Update(msg tea.Msg) (model tea.Model, cmd tea.Cmd) {
+	if panicked() {
+		return s, tea.Quit
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			recordPanic(r)
+			model, cmd = s, tea.Quit
+		}
+	}()
+	next, cmd := s.model.Update(msg)
+	return safeModel{model: next}, safeCmd(cmd)
+}
+
+func (s safeModel) View() (view string) {
+	if panicked() {
+		return ""
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			recordPanic(r)
+			view = ""
+			// View cannot return a command, so the quit is sent from outside the event loop
+			if program != nil {
+				go program.Quit()
+			}
+		}
+	}()
+	return s.model.View()
+}
+
+// unwrapModel returns the TUI model inside the final model of a program
+func unwrapModel(m tea.Model) (Model, bool) {
+	s, ok := m.(safeModel)
+	if !ok {
+		return Model{}, false
+	}
+	model, ok := s.model.(Model)
+	return model, ok
+}
+
+// safeCmd wraps a command so a panic in it quits the program
+// Commands of a batch run on their own goroutines, so each is wrapped too
+func safeCmd(cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() (msg tea.Msg) {
+		defer func() {
+			if r := recover(); r != nil {
+				recordPanic(r)
+				msg = tea.Quit()
+			}
+		}()
+		msg = cmd()
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			for i, c := range batch {
+				batch[i] = safeCmd(c)
+			}
+		}
+		return msg
+	}
+}
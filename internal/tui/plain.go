@@ -0,0 +1,323 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/config"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/i18n"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// plainSteps is the number of numbered prompts of a job in plain mode
+const plainSteps = 3
+
+// plainProgressStep is how far, in percent, compression advances between two announcements
+const plainProgressStep = 10
+
+// plainSession asks for jobs with numbered prompts and reports them in plain lines
+// Nothing is drawn or colored and every line stands on its own, so screen readers
+// announce the session in order
+type plainSession struct {
+	*LinePrompter
+	presets *Presets
+	history *config.History
+}
+
+// RunPlain runs the interactive mode without decorations, for screen readers and
+// terminals that cannot draw the TUI: prompts are read from in, one answer per line,
+// and everything is written to out as plain text
+// Canceling ctx stops a running job and ends the session
+func RunPlain(ctx context.Context, presets *Presets, in io.Reader, out io.Writer) error {
+	if presets == nil {
+		presets = &Presets{}
+	}
+
+	s := &plainSession{
+		LinePrompter: NewLinePrompter(ctx, in, out),
+		presets:      presets,
+		history:      loadHistory(presets),
+	}
+	defer s.Close()
+	s.Println(i18n.T("LetsGoIntunePackager - Create .intunewin packages for Microsoft Intune"))
+	s.Println(i18n.T("Answer each prompt and press Enter. Press Enter alone to keep the value in brackets."))
+
+	source, setup, output := presets.ContentPath, presets.SetupFile, presets.OutputPath
+	for {
+		s.Println("")
+		err := s.runJob(&source, &setup, &output)
+		if err == nil {
+			s.Println("")
+			var another bool
+			another, err = s.confirm(i18n.T("Create another package?"), false)
+			if err == nil && another {
+				continue
+			}
+		}
+		if err == nil || errors.Is(err, ErrInputClosed) || errors.Is(err, context.Canceled) {
+			s.Println(i18n.T("Goodbye!"))
+			return nil
+		}
+		return err
+	}
+}
+
+// runJob asks for a job, reviews it and creates the package
+// The answers are kept in source, setup and output as defaults for the next job
+func (s *plainSession) runJob(source, setup, output *string) error {
+	var err error
+	if *source, err = s.AskSource(s.stepPrompt(1, i18n.T("Source folder (the folder with the app files)")), *source); err != nil {
+		return err
+	}
+	if *setup, err = s.AskSetupFile(s.stepPrompt(2, i18n.T("Setup file (a number from the list or a file name)")), *source, *setup); err != nil {
+		return err
+	}
+	if *output, err = s.AskOutput(s.stepPrompt(3, i18n.T("Output folder (where the .intunewin file is saved)")), *output); err != nil {
+		return err
+	}
+
+	opts := s.presets.Options
+	s.Println("")
+	s.Println(i18n.T("Scanning source folder..."))
+	preview, err := packager.PreviewPackage(*source, *setup, *output, opts)
+	if err != nil {
+		s.Println(i18n.T("Error:") + " " + err.Error())
+		return nil
+	}
+	s.printPreview(preview, *source, *setup, opts)
+
+	if s.presets.ReadOnly {
+		s.Println(i18n.T("Read-only mode: the package is not created"))
+		return nil
+	}
+	create, err := s.confirm(i18n.T("Create the package?"), true)
+	if err != nil {
+		return err
+	}
+	if !create {
+		s.Println(i18n.T("The package was not created."))
+		return nil
+	}
+
+	s.Println("")
+	s.Println(i18n.T("Creating Package"))
+	result, err := packager.PackageContext(s.ctx, *source, *setup, *output, opts, s.progress())
+	s.recordJob(*source, *setup, *output, result, err)
+	if errors.Is(err, context.Canceled) {
+		s.Println(i18n.T("Packaging canceled"))
+		return err
+	}
+	if err != nil {
+		s.printError(err)
+		return nil
+	}
+	s.printResult(result)
+	return nil
+}
+
+// stepPrompt numbers the prompt of a step of the job
+func (s *plainSession) stepPrompt(step int, prompt string) string {
+	return i18n.Tf("Step %d of %d: %s", step, plainSteps, prompt)
+}
+
+// confirm asks a yes or no question until it gets an answer; Enter alone answers def
+func (s *plainSession) confirm(question string, def bool) (bool, error) {
+	yes, no := i18n.T("y"), i18n.T("n")
+	choices := yes + "/" + no
+	defAnswer := no
+	if def {
+		defAnswer = yes
+	}
+	for {
+		answer, err := s.Ask(question+" ("+choices+")", defAnswer)
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case yes, i18n.T("yes"), "y", "yes":
+			return true, nil
+		case no, i18n.T("no"), "n", "no":
+			return false, nil
+		}
+		s.Println(i18n.Tf("Answer %s or %s", yes, no))
+	}
+}
+
+// progress returns a progress callback that announces each step of the packager once
+// Compression announces the file being compressed only every plainProgressStep percent,
+// so that a screen reader is not flooded with file names
+func (s *plainSession) progress() packager.ProgressCallback {
+	var mu sync.Mutex
+	lastStep := ""
+	lastPercent := -plainProgressStep
+	return func(step string, pct float64) {
+		mu.Lock()
+		defer mu.Unlock()
+		percent := int(pct * 100)
+		if strings.HasPrefix(step, "Compressing: ") {
+			if percent < lastPercent+plainProgressStep {
+				return
+			}
+		} else if step == lastStep {
+			return
+		}
+		lastStep, lastPercent = step, percent
+		s.Println(i18n.Tf("%d%% - %s", percent, i18n.Step(step)))
+	}
+}
+
+// printPreview writes the review of a job before it is packaged
+func (s *plainSession) printPreview(p *packager.Preview, source, setup string, opts packager.Options) {
+	s.Println("")
+	s.Println(i18n.T("Review Package"))
+	s.field(i18n.T("App Name:"), p.Name)
+	s.field(i18n.T("Setup File:"), setup)
+	s.field(i18n.T("Source Folder:"), source)
+	s.field(i18n.T("Files:"), strconv.Itoa(p.FileCount))
+	s.field(i18n.T("Source Size:"), packager.FormatSize(p.SourceSize))
+	s.field(i18n.T("Estimated Size:"), packager.FormatSize(p.EstimatedSize))
+	s.field(i18n.T("Output File:"), p.OutputPath)
+	if len(opts.Exclude) > 0 {
+		s.field(i18n.T("Excluded:"), strings.Join(opts.Exclude, ", "))
+	}
+
+	switch {
+	case p.MsiError != nil:
+		s.warn(i18n.Tf("MSI metadata could not be read: %v", p.MsiError))
+		s.Println(i18n.T("The package is created without MSI detection information."))
+	case p.MsiInfo != nil:
+		msi := p.MsiInfo
+		s.Println(i18n.T("MSI Metadata"))
+		s.field(i18n.T("Product Name:"), valueOrUnknown(msi.ProductName))
+		s.field(i18n.T("Product Code:"), valueOrUnknown(msi.ProductCode))
+		s.field(i18n.T("Version:"), valueOrUnknown(msi.ProductVersion))
+		s.field(i18n.T("Publisher:"), valueOrUnknown(msi.Publisher))
+		s.field(i18n.T("Upgrade Code:"), valueOrUnknown(msi.UpgradeCode))
+		s.field(i18n.T("Context:"), valueOrUnknown(msi.ExecutionContext))
+	}
+	if p.MspError != nil {
+		s.warn(i18n.Tf("MSP metadata could not be read: %v", p.MspError))
+	}
+	s.printMsp(p.MspInfo)
+	if p.InstallCommand != "" {
+		s.field(i18n.T("Install:"), p.InstallCommand)
+	}
+	if suite := p.MsiSuite; suite != nil {
+		s.Println(i18n.T("MSI Suite"))
+		for _, member := range suite.Members {
+			s.Println(fmt.Sprintf("%s (%s, %s)", member.File, valueOrUnknown(member.Language), member.Relation))
+		}
+		s.Println(i18n.Tf("%s is added to install them in this order", packager.SuiteScriptName))
+	}
+	if len(p.RiskyCustomActions) > 0 {
+		names := make([]string, len(p.RiskyCustomActions))
+		for i, ca := range p.RiskyCustomActions {
+			names[i] = ca.Action
+		}
+		s.warn(i18n.Tf("High-risk MSI custom actions: %s", strings.Join(names, ", ")))
+		s.Println(i18n.T("Review them with 'analyze' before deploying to the fleet."))
+	}
+	if p.SizeWarning != "" {
+		s.warn(i18n.Tf("Large package: %s", p.SizeWarning))
+	}
+}
+
+// printResult writes the summary of a created package and the next steps
+func (s *plainSession) printResult(result *packager.PackageResult) {
+	s.Println(i18n.T("Package Created Successfully!"))
+	s.field(i18n.T("Output File:"), result.OutputPath)
+	s.field(i18n.T("Files Packaged:"), strconv.Itoa(result.FileCount))
+	s.field(i18n.T("Source Size:"), packager.FormatSize(result.SourceSize))
+	s.field(i18n.T("Final Size:"), packager.FormatSize(result.FinalSize))
+	if result.Signature != nil {
+		s.field(i18n.T("Signed By:"), result.Signature.Signer)
+	}
+	if result.Scan != nil {
+		s.field(i18n.T("Malware Scan:"), result.Scan.String())
+	}
+	if result.ManifestPath != "" {
+		s.field(i18n.T("Manifest:"), result.ManifestPath)
+	}
+	if suite := result.MsiSuite; suite != nil {
+		s.field(i18n.T("MSI Suite:"), suite.String())
+		s.field(i18n.T("Install:"), suite.InstallCommand)
+		s.field(i18n.T("Uninstall:"), suite.UninstallCommand)
+	}
+	s.printMsp(result.SetupMsp)
+	if result.InstallCommand != "" {
+		s.field(i18n.T("Install:"), result.InstallCommand)
+	}
+	if result.SourceSize > 0 {
+		ratio := float64(result.FinalSize) / float64(result.SourceSize) * 100
+		s.Println(i18n.Tf("Compression ratio: %.1f%%", ratio))
+	}
+
+	s.Println(i18n.T("Next Steps"))
+	s.Println("1. " + i18n.T("Upload the .intunewin file to Microsoft Intune"))
+	s.Println("2. " + i18n.T("Configure detection rules and requirements"))
+	s.Println("3. " + i18n.T("Assign the app to users or devices"))
+}
+
+// printMsp writes the patch code and target products of an MSP setup file
+func (s *plainSession) printMsp(msp *packager.MspInfo) {
+	if msp == nil {
+		return
+	}
+	s.field(i18n.T("MSP Patch:"), valueOrUnknown(msp.DisplayName))
+	s.field(i18n.T("Patch Code:"), msp.PatchCode)
+	if len(msp.TargetProductCodes) > 0 {
+		s.field(i18n.T("Targets:"), strings.Join(msp.TargetProductCodes, ", "))
+	}
+}
+
+// printError writes a packaging error and what to check
+func (s *plainSession) printError(err error) {
+	s.Println(i18n.T("Error:") + " " + err.Error())
+	s.Println(i18n.T("Troubleshooting"))
+	s.Println("- " + i18n.T("Check that the source folder exists and is accessible"))
+	s.Println("- " + i18n.T("Verify the setup file name is correct"))
+	s.Println("- " + i18n.T("Ensure you have write permissions to the output folder"))
+	s.Println("- " + i18n.T("Make sure no other process is using the files"))
+}
+
+// recordJob adds a packaging run to the history of recent jobs
+func (s *plainSession) recordJob(source, setup, output string, result *packager.PackageResult, err error) {
+	if s.presets.HistoryPath == "" {
+		return
+	}
+	entry := config.HistoryEntry{
+		SourcePath: source,
+		SetupFile:  setup,
+		OutputPath: output,
+		Succeeded:  err == nil,
+		Timestamp:  time.Now(),
+	}
+	if result != nil {
+		entry.Package = result.OutputPath
+		entry.GeneratorVersion = result.GeneratorVersion
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	s.history.Add(entry)
+	if err := s.history.Save(s.presets.HistoryPath); err != nil {
+		slog.Warn("could not save packaging history", "error", err)
+	}
+}
+
+// field writes a label and its value on one line
+func (s *plainSession) field(label, value string) {
+	s.Println(label + " " + value)
+}
+
+// warn writes a warning, worded so that it is announced as one
+func (s *plainSession) warn(msg string) {
+	s.Println(i18n.T("Warning:") + " " + msg)
+}
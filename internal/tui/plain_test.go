@@ -0,0 +1,232 @@
+package tui
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newTestSource creates a source folder with the given setup files
+func newTestSource(t *testing.T, files ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, file := range files {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte("@echo off"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	return dir
+}
+
+// newTestSession creates a plain session answering its prompts with the lines of input
+func newTestSession(t *testing.T, input string, presets *Presets) (*plainSession, *bytes.Buffer) {
+	t.Helper()
+	var out bytes.Buffer
+	s := &plainSession{
+		LinePrompter: NewLinePrompter(context.Background(), strings.NewReader(input), &out),
+		presets:      presets,
+		history:      loadHistory(presets),
+	}
+	t.Cleanup(s.Close)
+	return s, &out
+}
+
+func TestPlainSessionPrompts(t *testing.T) {
+	source := newTestSource(t, "install.cmd", "setup.cmd")
+	missing := filepath.Join(source, "missing")
+
+	tests := []struct {
+		name    string
+		input   string
+		ask     func(s *plainSession) (string, error)
+		want    string
+		wantErr error
+		// wantOut are lines the session writes, in order
+		wantOut []string
+	}{
+		{
+			name:    "source re-prompts on a missing folder",
+			input:   missing + "\n\n" + source + "\n",
+			ask:     func(s *plainSession) (string, error) { return s.AskSource("Source", "") },
+			want:    source,
+			wantOut: []string{"Folder not found: " + missing, "Source folder is required"},
+		},
+		{
+			name:  "source keeps the default",
+			input: "\n",
+			ask:   func(s *plainSession) (string, error) { return s.AskSource("Source", source) },
+			want:  source,
+		},
+		{
+			name:    "source at the end of the input",
+			input:   missing + "\n",
+			ask:     func(s *plainSession) (string, error) { return s.AskSource("Source", "") },
+			wantErr: ErrInputClosed,
+			wantOut: []string{"Folder not found: " + missing},
+		},
+		{
+			name:    "setup file defaults to the detected one",
+			input:   "\n",
+			ask:     func(s *plainSession) (string, error) { return s.AskSetupFile("Setup", source, "") },
+			want:    "install.cmd",
+			wantOut: []string{"Setup files in the source folder: 2", "1. install.cmd (detected)", "2. setup.cmd", "Setup [install.cmd]: "},
+		},
+		{
+			name:  "setup file keeps a current file of the folder",
+			input: "\n",
+			ask:   func(s *plainSession) (string, error) { return s.AskSetupFile("Setup", source, "setup.cmd") },
+			want:  "setup.cmd",
+		},
+		{
+			name:    "setup file by number after invalid answers",
+			input:   "3\nmissing.exe\n2\n",
+			ask:     func(s *plainSession) (string, error) { return s.AskSetupFile("Setup", source, "") },
+			want:    "setup.cmd",
+			wantOut: []string{"Enter a number from 1 to 2", "File not found in the source folder: missing.exe"},
+		},
+		{
+			name:    "setup file at the end of the input",
+			input:   "",
+			ask:     func(s *plainSession) (string, error) { return s.AskSetupFile("Setup", source, "") },
+			wantErr: ErrInputClosed,
+		},
+		{
+			name:    "output is required",
+			input:   "\n  out  \n",
+			ask:     func(s *plainSession) (string, error) { return s.AskOutput("Output", "") },
+			want:    "out",
+			wantOut: []string{"Output folder is required"},
+		},
+		{
+			name:  "output keeps the default",
+			input: "\n",
+			ask:   func(s *plainSession) (string, error) { return s.AskOutput("Output", "/srv/packages") },
+			want:  "/srv/packages",
+		},
+		{
+			name:  "confirm re-asks until yes or no",
+			input: "maybe\nYES\n",
+			ask: func(s *plainSession) (string, error) {
+				ok, err := s.confirm("Create?", false)
+				return strconv.FormatBool(ok), err
+			},
+			want:    "true",
+			wantOut: []string{"Create? (y/n) [n]: ", "Answer y or n"},
+		},
+		{
+			name:  "confirm keeps the default",
+			input: "\n",
+			ask: func(s *plainSession) (string, error) {
+				ok, err := s.confirm("Create?", true)
+				return strconv.FormatBool(ok), err
+			},
+			want: "true",
+		},
+		{
+			name:  "confirm at the end of the input",
+			input: "",
+			ask: func(s *plainSession) (string, error) {
+				_, err := s.confirm("Create?", true)
+				return "", err
+			},
+			wantErr: ErrInputClosed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, out := newTestSession(t, tt.input, &Presets{})
+			got, err := tt.ask(s)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("answer = %q, want %q", got, tt.want)
+			}
+			rest := out.String()
+			for _, line := range tt.wantOut {
+				i := strings.Index(rest, line)
+				if i < 0 {
+					t.Fatalf("output is missing %q in order:\n%s", line, out.String())
+				}
+				rest = rest[i+len(line):]
+			}
+		})
+	}
+}
+
+func TestRunPlain(t *testing.T) {
+	source := newTestSource(t, "setup.cmd")
+
+	tests := []struct {
+		name    string
+		input   string
+		presets Presets
+		wantOut []string
+	}{
+		{
+			name:    "read-only job then no more",
+			input:   source + "\n\n" + t.TempDir() + "\nn\n",
+			presets: Presets{ReadOnly: true},
+			wantOut: []string{"Step 1 of 3: Source folder", "Step 2 of 3: Setup file", "Step 3 of 3: Output folder", "Review Package", "Setup File: setup.cmd", "Read-only mode: the package is not created", "Create another package?", "Goodbye!"},
+		},
+		{
+			name:    "presets are the defaults",
+			input:   "\n\n\n",
+			presets: Presets{ContentPath: source, SetupFile: "setup.cmd", OutputPath: "/srv/packages", ReadOnly: true},
+			wantOut: []string{"[" + source + "]", "[setup.cmd]", "[/srv/packages]", "Read-only mode", "Goodbye!"},
+		},
+		{
+			name:    "input ends during a job",
+			input:   source + "\n",
+			wantOut: []string{"Step 2 of 3", "Goodbye!"},
+		},
+		{
+			name:    "empty input",
+			input:   "",
+			wantOut: []string{"Step 1 of 3", "Goodbye!"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := RunPlain(context.Background(), &tt.presets, strings.NewReader(tt.input), &out); err != nil {
+				t.Fatalf("RunPlain() error = %v", err)
+			}
+			rest := out.String()
+			for _, line := range tt.wantOut {
+				i := strings.Index(rest, line)
+				if i < 0 {
+					t.Fatalf("output is missing %q in order:\n%s", line, out.String())
+				}
+				rest = rest[i+len(line):]
+			}
+			if strings.Contains(out.String(), "Creating Package") {
+				t.Error("No package should be created")
+			}
+		})
+	}
+}
+
+func TestRunPlainCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var out bytes.Buffer
+	// The input never ends, so only the context can end the session
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe() error = %v", err)
+	}
+	defer r.Close()
+	defer w.Close()
+	if err := RunPlain(ctx, nil, r, &out); err != nil {
+		t.Fatalf("RunPlain() error = %v", err)
+	}
+	if !strings.HasSuffix(out.String(), "Goodbye!\n") {
+		t.Errorf("Output = %q, want the session to end", out.String())
+	}
+}
@@ -0,0 +1,164 @@
+package tui
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/i18n"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// ErrInputClosed is returned by a LinePrompter when the input has no more answers
+var ErrInputClosed = errors.New("input closed")
+
+// LinePrompter asks questions on a line-oriented terminal: each prompt is written to out
+// and answered by a line of in, and nothing is drawn or colored
+// It is shared by plain mode and the --prompt flag of the CLI
+type LinePrompter struct {
+	ctx     context.Context
+	answers <-chan string
+	done    chan struct{}
+	out     io.Writer
+}
+
+// NewLinePrompter starts reading answers from in; canceling ctx interrupts a prompt
+// Call Close when done asking
+func NewLinePrompter(ctx context.Context, in io.Reader, out io.Writer) *LinePrompter {
+	// Answers are read in the background so that a prompt can give way to ctx
+	answers := make(chan string)
+	done := make(chan struct{})
+	go func() {
+		defer close(answers)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			select {
+			case answers <- strings.TrimSpace(scanner.Text()):
+			case <-done:
+				return
+			}
+		}
+	}()
+	return &LinePrompter{ctx: ctx, answers: answers, done: done, out: out}
+}
+
+// Close stops reading answers
+func (p *LinePrompter) Close() {
+	close(p.done)
+}
+
+// Ask writes a prompt and returns the answer, or def for an empty answer
+// It fails with ErrInputClosed at the end of the input and with the error of ctx when
+// it is canceled
+func (p *LinePrompter) Ask(prompt, def string) (string, error) {
+	if def != "" {
+		prompt += " [" + def + "]"
+	}
+	fmt.Fprint(p.out, prompt+": ")
+
+	select {
+	case answer, ok := <-p.answers:
+		if !ok {
+			fmt.Fprintln(p.out)
+			return "", ErrInputClosed
+		}
+		if answer == "" {
+			return def, nil
+		}
+		return answer, nil
+	case <-p.ctx.Done():
+		fmt.Fprintln(p.out)
+		return "", p.ctx.Err()
+	}
+}
+
+// AskSource asks for the source folder until an existing folder is given
+func (p *LinePrompter) AskSource(prompt, current string) (string, error) {
+	for {
+		answer, err := p.Ask(prompt, current)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case answer == "":
+			p.Println(i18n.T("Source folder is required"))
+		case !validatePath(answer, true):
+			p.Println(i18n.Tf("Folder not found: %s", answer))
+		default:
+			return answer, nil
+		}
+	}
+}
+
+// AskSetupFile lists the setup files of the source folder by number and asks for one
+// The current setup file is the default if it is in the folder, else the detected one
+func (p *LinePrompter) AskSetupFile(prompt, source, current string) (string, error) {
+	files, err := packager.ListSetupFiles(source)
+	if err != nil {
+		slog.Warn("could not list setup files", "error", err)
+	}
+	detected := packager.PreferredSetupFile(files)
+	if current == "" || !validatePath(filepath.Join(source, current), false) {
+		current = detected
+	}
+
+	if len(files) == 0 {
+		p.Println(i18n.T("No setup files found in the source folder."))
+	} else {
+		p.Println(i18n.Tf("Setup files in the source folder: %d", len(files)))
+		for i, file := range files {
+			line := fmt.Sprintf("%d. %s", i+1, file)
+			if file == detected {
+				line += " " + i18n.T("(detected)")
+			}
+			p.Println(line)
+		}
+	}
+
+	for {
+		answer, err := p.Ask(prompt, current)
+		if err != nil {
+			return "", err
+		}
+		if n, err := strconv.Atoi(answer); err == nil && len(files) > 0 {
+			if n < 1 || n > len(files) {
+				p.Println(i18n.Tf("Enter a number from 1 to %d", len(files)))
+				continue
+			}
+			answer = files[n-1]
+		}
+		switch {
+		case answer == "":
+			p.Println(i18n.T("Setup file is required"))
+		case !validatePath(filepath.Join(source, answer), false):
+			p.Println(i18n.Tf("File not found in the source folder: %s", answer))
+		default:
+			return answer, nil
+		}
+	}
+}
+
+// AskOutput asks for the output folder, which is created when packaging if needed
+func (p *LinePrompter) AskOutput(prompt, current string) (string, error) {
+	for {
+		answer, err := p.Ask(prompt, current)
+		if err != nil {
+			return "", err
+		}
+		if answer != "" {
+			return answer, nil
+		}
+		p.Println(i18n.T("Output folder is required"))
+	}
+}
+
+// Println writes a line of output
+func (p *LinePrompter) Println(line string) {
+	fmt.Fprintln(p.out, line)
+}
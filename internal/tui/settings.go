@@ -0,0 +1,263 @@
+package tui
+
+import (
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/config"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// SettingsField represents the focused field of the settings screen
+type SettingsField int
+
+const (
+	SettingOutputFolder SettingsField = iota
+	SettingExclude
+	SettingTheme
+	SettingOpenOutput
+	SettingUpload
+	SettingSaveButton
+)
+
+const numSettingsFields = 6
+
+// settingsForm holds the values being edited on the settings screen
+type settingsForm struct {
+	inputs     []textinput.Model // Output folder, exclusion patterns
+	focus      SettingsField
+	theme      string
+	openOutput bool
+	upload     bool
+	err        string
+}
+
+// newSettingsForm fills the settings screen from the current session settings
+func newSettingsForm(presets *Presets) settingsForm {
+	inputs := make([]textinput.Model, 2)
+
+	inputs[0] = textinput.New()
+	inputs[0].Placeholder = "/path/to/output/folder"
+	inputs[0].CharLimit = 500
+	inputs[0].Width = 50
+	inputs[0].SetValue(presets.OutputPath)
+
+	inputs[1] = textinput.New()
+	inputs[1].Placeholder = "*.log, .git"
+	inputs[1].CharLimit = 500
+	inputs[1].Width = 50
+	inputs[1].SetValue(strings.Join(presets.Options.Exclude, ", "))
+
+	inputs[0].Focus()
+
+	theme := presets.Settings.Theme
+	if theme == "" {
+		theme = DefaultTheme
+	}
+	return settingsForm{
+		inputs:     inputs,
+		theme:      theme,
+		openOutput: presets.Settings.OpenOutput,
+		upload:     presets.Settings.Upload,
+	}
+}
+
+// excludePatterns splits the comma-separated exclusion field
+func (f settingsForm) excludePatterns() []string {
+	var patterns []string
+	for _, p := range strings.Split(f.inputs[1].Value(), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// setFocus moves focus to a settings field
+func (f *settingsForm) setFocus(field SettingsField) {
+	for i := range f.inputs {
+		f.inputs[i].Blur()
+	}
+	f.focus = field
+	if int(field) < len(f.inputs) {
+		f.inputs[field].Focus()
+	}
+}
+
+// cycleTheme selects the next (or previous) theme
+func (f *settingsForm) cycleTheme(step int) {
+	names := ThemeNames()
+	current := 0
+	for i, name := range names {
+		if name == f.theme {
+			current = i
+		}
+	}
+	f.theme = names[(current+step+len(names))%len(names)]
+}
+
+// canEditSettings reports whether the settings screen has a config file to save to
+func (m Model) canEditSettings() bool {
+	return m.presets != nil && m.presets.ConfigPath != ""
+}
+
+// showSettings opens the settings screen
+func (m *Model) showSettings() {
+	if !m.canEditSettings() {
+		return
+	}
+	m.settings = newSettingsForm(m.presets)
+	m.previousScreen = m.screen
+	m.screen = ScreenSettings
+}
+
+// updateSettings handles input on the settings screen
+func (m Model) updateSettings(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	f := &m.settings
+
+	switch {
+	case m.matches(msg, m.keys.Escape):
+		m.screen = m.previousScreen
+		return m, nil
+
+	case m.matches(msg, m.keys.Tab), msg.Type == tea.KeyDown:
+		f.setFocus((f.focus + 1) % numSettingsFields)
+		return m, nil
+
+	case m.matches(msg, m.keys.ShiftTab), msg.Type == tea.KeyUp:
+		f.setFocus((f.focus + numSettingsFields - 1) % numSettingsFields)
+		return m, nil
+	}
+
+	switch f.focus {
+	case SettingTheme:
+		switch msg.Type {
+		case tea.KeyLeft:
+			f.cycleTheme(-1)
+		case tea.KeyRight, tea.KeySpace, tea.KeyEnter:
+			f.cycleTheme(1)
+		}
+		return m, nil
+
+	case SettingOpenOutput:
+		if msg.Type == tea.KeySpace || msg.Type == tea.KeyEnter {
+			f.openOutput = !f.openOutput
+		}
+		return m, nil
+
+	case SettingUpload:
+		if msg.Type == tea.KeySpace || msg.Type == tea.KeyEnter {
+			f.upload = !f.upload
+		}
+		return m, nil
+
+	case SettingSaveButton:
+		if msg.Type == tea.KeyEnter {
+			if err := m.saveSettings(); err != nil {
+				f.err = err.Error()
+				return m, nil
+			}
+			m.screen = m.previousScreen
+		}
+		return m, nil
+	}
+
+	// Text fields
+	if msg.Type == tea.KeyEnter {
+		f.setFocus(f.focus + 1)
+		return m, nil
+	}
+	var cmd tea.Cmd
+	f.inputs[f.focus], cmd = f.inputs[f.focus].Update(msg)
+	return m, cmd
+}
+
+// saveSettings applies the edited settings to the session and writes them to the
+// config file: output folder and exclusions to the active profile, theme, auto-open
+// and upload to the tui section
+func (m *Model) saveSettings() error {
+	f := m.settings
+	patterns := f.excludePatterns()
+	if err := packager.ValidateExcludePatterns(patterns); err != nil {
+		return err
+	}
+	if err := ApplyTheme(f.theme); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(m.presets.ConfigPath)
+	if err != nil {
+		return err
+	}
+	profile, err := cfg.Profile(m.presets.ProfileName)
+	if err != nil {
+		// The profile is created on save
+		profile = &config.Profile{}
+	}
+	profile.Output = strings.TrimSpace(f.inputs[0].Value())
+	profile.Exclude = patterns
+	cfg.SetProfile(m.presets.ProfileName, *profile)
+
+	settings := config.TUISettings{Theme: f.theme, OpenOutput: f.openOutput, Upload: f.upload, Keys: m.presets.Settings.Keys}
+	if settings.Theme == DefaultTheme {
+		settings.Theme = ""
+	}
+	cfg.TUI = settings
+	if err := cfg.Save(m.presets.ConfigPath); err != nil {
+		return err
+	}
+
+	// Apply to the rest of the session
+	m.presets.OutputPath = profile.Output
+	m.presets.Options.Exclude = patterns
+	m.presets.Settings = settings
+	if m.inputs[2].Value() == "" {
+		m.inputs[2].SetValue(profile.Output)
+	}
+	return nil
+}
+
+// openFolderCmd opens a folder in the platform's file manager
+func openFolderCmd(dir string) tea.Cmd {
+	return func() tea.Msg {
+		if err := openExternal(dir); err != nil {
+			slog.Warn("could not open output folder", "folder", dir, "error", err)
+		}
+		return nil
+	}
+}
+
+// openLinkCmd opens a link in the default browser
+func openLinkCmd(link string) tea.Cmd {
+	return func() tea.Msg {
+		if err := openExternal(link); err != nil {
+			slog.Warn("could not open link", "url", link, "error", err)
+		}
+		return nil
+	}
+}
+
+// openExternal opens a folder in the file manager, or a link in the browser, without
+// waiting for it to exit
+func openExternal(dir string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("explorer", dir)
+	case "darwin":
+		cmd = exec.Command("open", dir)
+	default:
+		cmd = exec.Command("xdg-open", dir)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", cmd.Path, err)
+	}
+	go cmd.Wait()
+	return nil
+}
@@ -5,15 +5,16 @@ import (
 )
 
 // Color palette
+// The colors are swapped in place by ApplyTheme, so styles built from them follow the theme
 var (
-	primaryColor   = lipgloss.Color("#7D56F4") // Purple
-	secondaryColor = lipgloss.Color("#874BFD") // Light purple
-	successColor   = lipgloss.Color("#04B575") // Green
-	errorColor     = lipgloss.Color("#FF4672") // Red/Pink
-	warningColor   = lipgloss.Color("#FFCC00") // Yellow
-	mutedColor     = lipgloss.Color("#626262") // Gray
-	textColor      = lipgloss.Color("#FAFAFA") // White
-	dimTextColor   = lipgloss.Color("#A0A0A0") // Light gray
+	primaryColor   = &themeColor{lipgloss.Color("#7D56F4")} // Purple
+	secondaryColor = &themeColor{lipgloss.Color("#874BFD")} // Light purple
+	successColor   = &themeColor{lipgloss.Color("#04B575")} // Green
+	errorColor     = &themeColor{lipgloss.Color("#FF4672")} // Red/Pink
+	warningColor   = &themeColor{lipgloss.Color("#FFCC00")} // Yellow
+	mutedColor     = &themeColor{lipgloss.Color("#626262")} // Gray
+	textColor      = &themeColor{lipgloss.Color("#FAFAFA")} // White
+	dimTextColor   = &themeColor{lipgloss.Color("#A0A0A0")} // Light gray
 )
 
 // Layout styles
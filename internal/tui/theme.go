@@ -0,0 +1,93 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// DefaultTheme is used when no theme is configured
+const DefaultTheme = "default"
+
+// PlainTheme drops all colors, for screen readers and low-vision setups
+const PlainTheme = "plain"
+
+// themeColor is a palette entry whose color can be replaced after styles were built
+type themeColor struct {
+	lipgloss.TerminalColor
+}
+
+// palette assigns a color to each role of the color palette
+type palette struct {
+	primary, secondary, success, failure, warning, muted, text, dimText lipgloss.TerminalColor
+}
+
+// themes are the selectable color themes
+var themes = map[string]palette{
+	DefaultTheme: {
+		primary:   lipgloss.Color("#7D56F4"),
+		secondary: lipgloss.Color("#874BFD"),
+		success:   lipgloss.Color("#04B575"),
+		failure:   lipgloss.Color("#FF4672"),
+		warning:   lipgloss.Color("#FFCC00"),
+		muted:     lipgloss.Color("#626262"),
+		text:      lipgloss.Color("#FAFAFA"),
+		dimText:   lipgloss.Color("#A0A0A0"),
+	},
+	// Bright ANSI colors, which terminals map to their own high-contrast palette
+	"high-contrast": {
+		primary:   lipgloss.Color("14"),
+		secondary: lipgloss.Color("12"),
+		success:   lipgloss.Color("10"),
+		failure:   lipgloss.Color("9"),
+		warning:   lipgloss.Color("11"),
+		muted:     lipgloss.Color("7"),
+		text:      lipgloss.Color("15"),
+		dimText:   lipgloss.Color("15"),
+	},
+	PlainTheme: {
+		primary:   lipgloss.NoColor{},
+		secondary: lipgloss.NoColor{},
+		success:   lipgloss.NoColor{},
+		failure:   lipgloss.NoColor{},
+		warning:   lipgloss.NoColor{},
+		muted:     lipgloss.NoColor{},
+		text:      lipgloss.NoColor{},
+		dimText:   lipgloss.NoColor{},
+	},
+}
+
+// ThemeNames returns the names of the available themes, sorted
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyTheme switches the colors of all styles; an empty name selects the default theme
+func ApplyTheme(name string) error {
+	if name == "" {
+		name = DefaultTheme
+	}
+	p, ok := themes[name]
+	if !ok {
+		return fmt.Errorf("unknown theme: %s (available: %v)", name, ThemeNames())
+	}
+
+	primaryColor.TerminalColor = p.primary
+	secondaryColor.TerminalColor = p.secondary
+	successColor.TerminalColor = p.success
+	errorColor.TerminalColor = p.failure
+	warningColor.TerminalColor = p.warning
+	mutedColor.TerminalColor = p.muted
+	textColor.TerminalColor = p.text
+	dimTextColor.TerminalColor = p.dimText
+
+	// Without colors the focused button is only recognizable in reverse video
+	ButtonFocusedStyle = ButtonFocusedStyle.Reverse(name == PlainTheme)
+	return nil
+}
@@ -2,15 +2,27 @@ package tui
 
 import (
 	"fmt"
+	"log/slog"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 // Run starts the TUI application
 // presets can contain values from CLI flags to pre-populate inputs
+// A panic in the TUI or a packaging job restores the terminal and is returned as a *PanicError
 func Run(presets *Presets) error {
+	if presets != nil {
+		if err := ApplyTheme(presets.Settings.Theme); err != nil {
+			slog.Warn("using default theme", "error", err)
+		}
+		// Refuse a broken key map up front rather than leaving actions unreachable
+		if _, err := NewKeyMap(presets.Settings.Keys); err != nil {
+			return fmt.Errorf("invalid tui.keys in config: %w", err)
+		}
+	}
+
 	// Create initial model
-	model := NewModel(presets)
+	model := safeModel{model: NewModel(presets)}
 
 	// Create program
 	p := tea.NewProgram(
@@ -24,12 +36,15 @@ func Run(presets *Presets) error {
 
 	// Run the program
 	finalModel, err := p.Run()
+	if panicErr := takePanic(); panicErr != nil {
+		return panicErr
+	}
 	if err != nil {
 		return fmt.Errorf("TUI error: %w", err)
 	}
 
 	// Check if there was an error in the final state
-	if m, ok := finalModel.(Model); ok {
+	if m, ok := unwrapModel(finalModel); ok {
 		if m.err != nil && m.screen == ScreenError {
 			// User quit with an error showing - don't propagate
 			return nil
@@ -42,7 +57,7 @@ func Run(presets *Presets) error {
 // RunWithResult starts the TUI and returns the packaging result
 // This is useful for testing or automation
 func RunWithResult(presets *Presets) (*Model, error) {
-	model := NewModel(presets)
+	model := safeModel{model: NewModel(presets)}
 
 	p := tea.NewProgram(
 		model,
@@ -53,11 +68,14 @@ func RunWithResult(presets *Presets) (*Model, error) {
 	SetProgram(p)
 
 	finalModel, err := p.Run()
+	if panicErr := takePanic(); panicErr != nil {
+		return nil, panicErr
+	}
 	if err != nil {
 		return nil, fmt.Errorf("TUI error: %w", err)
 	}
 
-	if m, ok := finalModel.(Model); ok {
+	if m, ok := unwrapModel(finalModel); ok {
 		return &m, nil
 	}
 
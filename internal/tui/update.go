@@ -1,17 +1,23 @@
 package tui
 
 import (
+	"context"
+	"errors"
 	"path/filepath"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/i18n"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
 )
 
 // Init initializes the model
 func (m Model) Init() tea.Cmd {
 	return tea.Batch(
 		m.spinner.Tick,
+		m.listSetupFiles(),
 	)
 }
 
@@ -31,10 +37,15 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case tea.KeyMsg:
-		// Global quit handling
-		if key.Matches(msg, m.keys.Quit) && m.screen != ScreenProcessing {
+		// Global quit and help handling; printable keys are typed text in text fields
+		busy := m.screen == ScreenProcessing || m.screen == ScreenUploading
+		if m.matches(msg, m.keys.Quit) && !busy {
 			return m, tea.Quit
 		}
+		if m.matches(msg, m.keys.Help) && !busy && m.screen != ScreenKeyMap {
+			m.showKeyMap()
+			return m, nil
+		}
 
 		// Screen-specific key handling
 		switch m.screen {
@@ -48,6 +59,22 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.updateSuccess(msg)
 		case ScreenError:
 			return m.updateError(msg)
+		case ScreenRecent:
+			return m.updateRecent(msg)
+		case ScreenSettings:
+			return m.updateSettings(msg)
+		case ScreenConfirm:
+			return m.updateConfirm(msg)
+		case ScreenKeyMap:
+			return m.updateKeyMap(msg)
+		case ScreenTenant:
+			return m.updateTenant(msg)
+		case ScreenAppDetails:
+			return m.updateAppDetails(msg)
+		case ScreenUploading:
+			return m.updateUploading(msg)
+		case ScreenUploaded:
+			return m.updateUploaded(msg)
 		}
 
 	case spinner.TickMsg:
@@ -59,25 +86,69 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case packageStartMsg:
 		m.screen = ScreenProcessing
 		m.progress = 0
-		m.progressStep = "Starting..."
+		m.progressStep = i18n.N("Starting...")
+		m.progressInfo = packager.Progress{}
 		cmds = append(cmds, m.spinner.Tick)
 
 	case packageProgressMsg:
-		m.SetProgress(msg.step, msg.percent)
+		if !m.canceling {
+			m.SetProgress(msg.progress.Step, msg.progress.Percent)
+			m.progressInfo = msg.progress
+		}
 
 	case packageCompleteMsg:
+		m.finishPackaging()
 		m.screen = ScreenSuccess
 		m.result = msg.result
 		m.progress = 1.0
+		cmds = append(cmds, m.recordJob(msg.result, nil))
+		if m.presets != nil && m.presets.Settings.OpenOutput {
+			cmds = append(cmds, openFolderCmd(filepath.Dir(msg.result.OutputPath)))
+		}
 
 	case packageErrorMsg:
+		m.finishPackaging()
+		if errors.Is(msg.err, context.Canceled) {
+			// Canceled by the user - back to the inputs to fix them or start again
+			m.screen = ScreenInput
+			m.notice = i18n.N("Packaging canceled")
+			m.setFocus(int(FieldSubmitButton))
+			break
+		}
 		m.screen = ScreenError
 		m.err = msg.err
+		cmds = append(cmds, m.recordJob(nil, msg.err))
+
+	case copiedMsg:
+		if m.screen == ScreenSuccess {
+			m.notice = copiedNotice(msg)
+		}
+
+	case uploadStartMsg, uploadProgressMsg, uploadCompleteMsg, uploadErrorMsg:
+		cmds = append(cmds, m.handleUploadMsg(msg))
 
-	case setupFileDetectedMsg:
-		if msg.filename != "" && m.inputs[1].Value() == "" {
-			m.inputs[1].SetValue(msg.filename)
+	case previewReadyMsg:
+		// The preview is stale if the user went back while it was gathered
+		if m.screen != ScreenConfirm {
+			break
 		}
+		if msg.err != nil {
+			m.err = msg.err
+			m.screen = ScreenError
+			break
+		}
+		m.preview = msg.preview
+
+	case setupFilesListedMsg:
+		// Listings of folders typed on the way to the final path are stale
+		if msg.dir != m.GetSourceFolder() {
+			break
+		}
+		if msg.err != nil {
+			m.setSetupFiles(nil, "", "")
+			break
+		}
+		m.setSetupFiles(msg.files, msg.detected, msg.existing)
 	}
 
 	return m, tea.Batch(cmds...)
@@ -90,6 +161,14 @@ func (m Model) updateWelcome(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.screen = ScreenInput
 		m.setFocus(0)
 		return m, nil
+
+	case key.Matches(msg, m.keys.Recent):
+		m.showRecent()
+		return m, nil
+
+	case key.Matches(msg, m.keys.Settings):
+		m.showSettings()
+		return m, nil
 	}
 	return m, nil
 }
@@ -97,21 +176,26 @@ func (m Model) updateWelcome(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 // updateInput handles input on the input screen
 func (m Model) updateInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
+	m.notice = ""
 
 	switch {
-	case key.Matches(msg, m.keys.Escape):
+	case m.matches(msg, m.keys.Escape):
 		m.screen = ScreenWelcome
 		return m, nil
 
-	case key.Matches(msg, m.keys.Tab):
+	case m.matches(msg, m.keys.Tab):
 		m.nextInput()
 		return m, nil
 
-	case key.Matches(msg, m.keys.ShiftTab):
+	case m.matches(msg, m.keys.ShiftTab):
 		m.prevInput()
 		return m, nil
 
-	case key.Matches(msg, m.keys.Browse), msg.String() == "ctrl+o", msg.String() == "ctrl+b", msg.Type == tea.KeyF2:
+	case m.matches(msg, m.keys.Recent):
+		m.showRecent()
+		return m, nil
+
+	case m.matches(msg, m.keys.Browse):
 		// Open file picker for current field
 		if m.focusIndex == int(FieldSourceFolder) {
 			m.pickerTarget = PickerTargetSourceFolder
@@ -130,7 +214,15 @@ func (m Model) updateInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
-	case key.Matches(msg, m.keys.Enter):
+	case m.selectingSetupFile() && m.matches(msg, m.keys.Up):
+		m.moveSetupSelection(-1)
+		return m, nil
+
+	case m.selectingSetupFile() && m.matches(msg, m.keys.Down):
+		m.moveSetupSelection(1)
+		return m, nil
+
+	case m.matches(msg, m.keys.Enter):
 		if m.focusIndex == int(FieldSubmitButton) {
 			// Validate and start packaging
 			valid, errMsg := m.ValidateInputs()
@@ -140,30 +232,24 @@ func (m Model) updateInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 
-			// Start packaging
-			return m, startPackaging(
-				m.GetSourceFolder(),
-				m.GetSetupFile(),
-				m.GetOutputFolder(),
-			)
+			// Show what will be packaged before the slow part starts
+			return m, m.showConfirm()
 		}
 		// Move to next field
 		m.nextInput()
 		return m, nil
 
 	default:
-		// Update the focused text input
-		if m.focusIndex < len(m.inputs) {
+		// Update the focused text input; the setup file is picked from the selector when it has entries
+		if m.focusIndex < len(m.inputs) && !m.selectingSetupFile() {
+			before := m.inputs[m.focusIndex].Value()
 			var cmd tea.Cmd
 			m.inputs[m.focusIndex], cmd = m.inputs[m.focusIndex].Update(msg)
 			cmds = append(cmds, cmd)
 
-			// Auto-detect setup file when source folder changes
-			if m.focusIndex == int(FieldSourceFolder) {
-				sourceDir := m.inputs[0].Value()
-				if sourceDir != "" {
-					cmds = append(cmds, autoDetectSetupFileCmd(sourceDir))
-				}
+			// List the setup files when the source folder changes
+			if m.focusIndex == int(FieldSourceFolder) && m.inputs[0].Value() != before {
+				cmds = append(cmds, m.listSetupFiles())
 			}
 		}
 	}
@@ -198,8 +284,8 @@ func (m Model) updateFilePicker(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case PickerTargetSourceFolder:
 				m.inputs[0].SetValue(path)
 				m.setFocus(1) // Move to setup file field
-				// Try to auto-detect setup file
-				return m, autoDetectSetupFileCmd(path)
+				// List the setup files for the selector
+				return m, m.listSetupFiles()
 			case PickerTargetSetupFile:
 				// Just use the filename, not the full path
 				m.inputs[1].SetValue(filepath.Base(path))
@@ -222,7 +308,7 @@ func (m Model) updateFilePicker(msg tea.Msg) (tea.Model, tea.Cmd) {
 				case PickerTargetSourceFolder:
 					m.inputs[0].SetValue(path)
 					m.setFocus(1)
-					return m, autoDetectSetupFileCmd(path)
+					return m, m.listSetupFiles()
 				case PickerTargetOutputFolder:
 					m.inputs[2].SetValue(path)
 					m.setFocus(3)
@@ -235,21 +321,105 @@ func (m Model) updateFilePicker(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// updateRecent handles input on the recent jobs screen
+func (m Model) updateRecent(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Up):
+		if m.recentIndex > 0 {
+			m.recentIndex--
+		}
+
+	case key.Matches(msg, m.keys.Down):
+		if m.recentIndex < len(m.history.Entries)-1 {
+			m.recentIndex++
+		}
+
+	case key.Matches(msg, m.keys.Enter):
+		return m, m.applyHistoryEntry(m.history.Entries[m.recentIndex])
+
+	case key.Matches(msg, m.keys.Escape):
+		m.screen = m.previousScreen
+	}
+	return m, nil
+}
+
+// updateConfirm handles input on the confirmation screen
+func (m Model) updateConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Enter):
+		if m.preview == nil || m.readOnly() {
+			return m, nil
+		}
+		return m, m.startPackaging()
+
+	case key.Matches(msg, m.keys.Escape):
+		m.preview = nil
+		m.screen = ScreenInput
+		return m, nil
+	}
+	return m, nil
+}
+
+// updateKeyMap handles input on the keyboard map overlay
+func (m Model) updateKeyMap(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if key.Matches(msg, m.keys.Escape) || key.Matches(msg, m.keys.Help) || key.Matches(msg, m.keys.Enter) {
+		m.screen = m.keyMapReturn
+	}
+	return m, nil
+}
+
 // updateProcessing handles input on the processing screen
+// Esc or Ctrl+C asks to cancel; the run stops at the next file or phase boundary
 func (m Model) updateProcessing(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	// Only allow quit during processing (with confirmation would be nice)
-	// For now, processing cannot be interrupted
+	if m.canceling {
+		return m, nil
+	}
+
+	if m.confirmCancel {
+		switch msg.String() {
+		case "y", "Y":
+			m.confirmCancel = false
+			m.canceling = true
+			m.progressStep = i18n.N("Canceling...")
+			if m.cancel != nil {
+				m.cancel()
+			}
+		case "n", "N", "esc":
+			m.confirmCancel = false
+		}
+		return m, nil
+	}
+
+	if key.Matches(msg, m.keys.Escape) || msg.String() == "ctrl+c" {
+		m.confirmCancel = true
+	}
 	return m, nil
 }
 
 // updateSuccess handles input on the success screen
 func (m Model) updateSuccess(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.notice = ""
+
 	switch {
 	case key.Matches(msg, m.keys.Enter):
 		// Start new package
 		m.resetForNewPackage()
 		return m, nil
 
+	case key.Matches(msg, m.keys.Settings):
+		m.showSettings()
+		return m, nil
+
+	case key.Matches(msg, m.keys.Upload):
+		m.showTenants()
+		return m, nil
+
+	case key.Matches(msg, m.keys.Open):
+		return m, openFolderCmd(filepath.Dir(m.result.OutputPath))
+
+	case key.Matches(msg, m.keys.Copy):
+		return m, copyPackageCmd(m.result.OutputPath)
+
 	case key.Matches(msg, m.keys.Escape):
 		return m, tea.Quit
 	}
@@ -264,11 +434,7 @@ func (m Model) updateError(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		valid, _ := m.ValidateInputs()
 		if valid {
 			m.err = nil
-			return m, startPackaging(
-				m.GetSourceFolder(),
-				m.GetSetupFile(),
-				m.GetOutputFolder(),
-			)
+			return m, m.startPackaging()
 		}
 		// If inputs are invalid, go back to input screen
 		m.err = nil
@@ -0,0 +1,411 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/i18n"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// Tenant is a tenant packages can be uploaded to
+type Tenant struct {
+	// Profile is the config profile of the tenant, empty for the environment variables
+	Profile  string
+	TenantID string
+	// Cloud is the Azure cloud of the tenant, empty for the public cloud
+	Cloud string
+}
+
+// UploadRequest is the app to create from a package
+type UploadRequest struct {
+	Tenant           Tenant
+	Package          string
+	Name             string
+	Version          string
+	Publisher        string
+	Description      string
+	InstallCommand   string
+	UninstallCommand string
+	// DetectFile is the file detecting the app; MSIs are detected by product code without it
+	DetectFile string
+}
+
+// UploadProgress is the state of an upload
+type UploadProgress struct {
+	Step string
+	// Uploaded and Total are the bytes of encrypted content uploaded and to upload
+	Uploaded int64
+	Total    int64
+}
+
+// UploadResult is the app an upload created
+type UploadResult struct {
+	AppID       string
+	DisplayName string
+	// PortalURL is the page of the app in the Intune admin center
+	PortalURL string
+}
+
+// UploadFunc creates the app of a request in Intune and uploads the package content,
+// reporting progress as it goes; canceling ctx stops the upload
+type UploadFunc func(ctx context.Context, req UploadRequest, progress func(UploadProgress)) (*UploadResult, error)
+
+// AppField represents the focused field of the app details screen
+type AppField int
+
+const (
+	AppFieldName AppField = iota
+	AppFieldVersion
+	AppFieldPublisher
+	AppFieldDescription
+	AppFieldInstall
+	AppFieldUninstall
+	AppFieldDetectFile
+	AppFieldUploadButton
+)
+
+const numAppFields = 8
+
+// uploadForm holds the tenant and app details of an upload and its progress
+type uploadForm struct {
+	tenantIndex int
+
+	// App details: name, version, publisher, description, install and uninstall
+	// commands and detection file
+	inputs []textinput.Model
+	focus  AppField
+	msi    bool
+	err    string
+
+	// Progress of the running upload
+	progress UploadProgress
+	started  time.Time
+	elapsed  time.Duration
+
+	result *UploadResult
+}
+
+// newUploadForm fills the app details from the Detection.xml of a package: the name,
+// and for MSIs the version, publisher and msiexec command lines
+func newUploadForm(packagePath string) (uploadForm, error) {
+	appInfo, err := packager.ReadDetectionXML(packagePath)
+	if err != nil {
+		return uploadForm{}, err
+	}
+
+	placeholders := []string{
+		i18n.T("App name shown in the Company Portal"),
+		"1.0.0",
+		i18n.T("Publisher"),
+		i18n.T("(default: the app name)"),
+		"setup.exe /S",
+		"uninstall.exe /S",
+		`C:\Program Files\App\app.exe`,
+	}
+	inputs := make([]textinput.Model, len(placeholders))
+	for i, placeholder := range placeholders {
+		inputs[i] = textinput.New()
+		inputs[i].Placeholder = placeholder
+		inputs[i].CharLimit = 1024
+		inputs[i].Width = 50
+	}
+
+	f := uploadForm{inputs: inputs}
+	inputs[AppFieldName].SetValue(appInfo.Name)
+	inputs[AppFieldDescription].SetValue(appInfo.Name)
+	if msi := appInfo.MsiInfo; msi != nil && msi.MsiProductCode != "" {
+		f.msi = true
+		inputs[AppFieldVersion].SetValue(msi.MsiProductVersion)
+		inputs[AppFieldPublisher].SetValue(msi.MsiPublisher)
+		inputs[AppFieldInstall].SetValue(`msiexec /i "` + appInfo.SetupFile + `" /qn`)
+		inputs[AppFieldUninstall].SetValue("msiexec /x " + msi.MsiProductCode + " /qn")
+		inputs[AppFieldDetectFile].Placeholder = i18n.T("(default: MSI product code)")
+	} else {
+		inputs[AppFieldInstall].SetValue(packager.SetupInstallCommand(appInfo.SetupFile))
+	}
+	f.setFocus(AppFieldName)
+	return f, nil
+}
+
+// value returns the trimmed value of an app details field
+func (f uploadForm) value(field AppField) string {
+	return strings.TrimSpace(f.inputs[field].Value())
+}
+
+// setFocus moves focus to an app details field
+func (f *uploadForm) setFocus(field AppField) {
+	for i := range f.inputs {
+		f.inputs[i].Blur()
+	}
+	f.focus = field
+	if int(field) < len(f.inputs) {
+		f.inputs[field].Focus()
+	}
+}
+
+// validate checks that the fields Intune requires are filled
+func (f uploadForm) validate() error {
+	switch {
+	case f.value(AppFieldName) == "":
+		return &validationError{message: i18n.T("App name is required")}
+	case f.value(AppFieldInstall) == "" || f.value(AppFieldUninstall) == "":
+		return &validationError{message: i18n.T("Install and uninstall commands are required")}
+	case !f.msi && f.value(AppFieldDetectFile) == "":
+		return &validationError{message: i18n.T("A detection file is required for apps that are not MSIs")}
+	}
+	return nil
+}
+
+// canUpload reports whether packages can be uploaded in this session
+func (m Model) canUpload() bool {
+	return m.presets != nil && m.presets.Settings.Upload && m.presets.Upload != nil &&
+		len(m.presets.Tenants) > 0 && !m.presets.ReadOnly
+}
+
+// showTenants opens the tenant selection of an upload for the created package
+func (m *Model) showTenants() {
+	if !m.canUpload() || m.result == nil {
+		return
+	}
+	m.upload = uploadForm{}
+	for i, tenant := range m.presets.Tenants {
+		if tenant.Profile == m.presets.ProfileName {
+			m.upload.tenantIndex = i
+		}
+	}
+	m.screen = ScreenTenant
+}
+
+// tenant returns the tenant selected for the upload
+func (m Model) tenant() Tenant {
+	return m.presets.Tenants[m.upload.tenantIndex]
+}
+
+// updateTenant handles input on the tenant selection screen
+func (m Model) updateTenant(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Up):
+		if m.upload.tenantIndex > 0 {
+			m.upload.tenantIndex--
+		}
+
+	case key.Matches(msg, m.keys.Down):
+		if m.upload.tenantIndex < len(m.presets.Tenants)-1 {
+			m.upload.tenantIndex++
+		}
+
+	case key.Matches(msg, m.keys.Enter):
+		// Details edited before going back to the tenants are kept
+		if m.upload.inputs == nil {
+			form, err := newUploadForm(m.result.OutputPath)
+			if err != nil {
+				m.err = err
+				m.screen = ScreenError
+				return m, nil
+			}
+			form.tenantIndex = m.upload.tenantIndex
+			m.upload = form
+		}
+		m.screen = ScreenAppDetails
+
+	case key.Matches(msg, m.keys.Escape):
+		m.screen = ScreenSuccess
+	}
+	return m, nil
+}
+
+// updateAppDetails handles input on the app details screen
+func (m Model) updateAppDetails(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	f := &m.upload
+
+	switch {
+	case m.matches(msg, m.keys.Escape):
+		m.screen = ScreenTenant
+		return m, nil
+
+	case m.matches(msg, m.keys.Tab), msg.Type == tea.KeyDown:
+		f.setFocus((f.focus + 1) % numAppFields)
+		return m, nil
+
+	case m.matches(msg, m.keys.ShiftTab), msg.Type == tea.KeyUp:
+		f.setFocus((f.focus + numAppFields - 1) % numAppFields)
+		return m, nil
+
+	case msg.Type == tea.KeyEnter:
+		if f.focus != AppFieldUploadButton {
+			f.setFocus(f.focus + 1)
+			return m, nil
+		}
+		if err := f.validate(); err != nil {
+			f.err = err.Error()
+			return m, nil
+		}
+		return m, m.startUpload()
+	}
+
+	if f.focus == AppFieldUploadButton {
+		return m, nil
+	}
+	f.err = ""
+	var cmd tea.Cmd
+	f.inputs[f.focus], cmd = f.inputs[f.focus].Update(msg)
+	return m, cmd
+}
+
+// startUpload starts uploading the package with the app details so that it can be canceled
+func (m *Model) startUpload() tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.confirmCancel = false
+	m.canceling = false
+	f := m.upload
+	req := UploadRequest{
+		Tenant:           m.tenant(),
+		Package:          m.result.OutputPath,
+		Name:             f.value(AppFieldName),
+		Version:          f.value(AppFieldVersion),
+		Publisher:        f.value(AppFieldPublisher),
+		Description:      f.value(AppFieldDescription),
+		InstallCommand:   f.value(AppFieldInstall),
+		UninstallCommand: f.value(AppFieldUninstall),
+		DetectFile:       f.value(AppFieldDetectFile),
+	}
+	return startUpload(ctx, m.presets.Upload, req)
+}
+
+// updateUploading handles input on the upload screen
+// Esc or Ctrl+C asks to cancel; an app that was already created is kept
+func (m Model) updateUploading(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.canceling {
+		return m, nil
+	}
+
+	if m.confirmCancel {
+		switch msg.String() {
+		case "y", "Y":
+			m.confirmCancel = false
+			m.canceling = true
+			m.upload.progress.Step = i18n.N("Canceling...")
+			if m.cancel != nil {
+				m.cancel()
+			}
+		case "n", "N", "esc":
+			m.confirmCancel = false
+		}
+		return m, nil
+	}
+
+	if key.Matches(msg, m.keys.Escape) || msg.String() == "ctrl+c" {
+		m.confirmCancel = true
+	}
+	return m, nil
+}
+
+// updateUploaded handles input on the screen shown after an upload
+func (m Model) updateUploaded(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Browse):
+		return m, openLinkCmd(m.upload.result.PortalURL)
+
+	case key.Matches(msg, m.keys.Enter):
+		m.resetForNewPackage()
+		return m, nil
+
+	case key.Matches(msg, m.keys.Escape):
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// uploadSpeed returns the average upload speed in bytes per second, 0 before the first block
+func (f uploadForm) uploadSpeed() float64 {
+	if f.progress.Uploaded == 0 || f.elapsed <= 0 {
+		return 0
+	}
+	return float64(f.progress.Uploaded) / f.elapsed.Seconds()
+}
+
+// uploadStartMsg signals that an upload has started
+type uploadStartMsg struct{}
+
+// uploadProgressMsg carries upload progress
+type uploadProgressMsg struct {
+	progress UploadProgress
+}
+
+// uploadCompleteMsg carries the app an upload created
+type uploadCompleteMsg struct {
+	result *UploadResult
+}
+
+// uploadErrorMsg carries the error that ended an upload
+type uploadErrorMsg struct {
+	err error
+}
+
+// startUpload runs an upload asynchronously
+// Canceling ctx stops the upload; it then ends with an uploadErrorMsg wrapping context.Canceled
+func startUpload(ctx context.Context, upload UploadFunc, req UploadRequest) tea.Cmd {
+	return func() tea.Msg {
+		go func() {
+			defer recoverJob()
+			result, err := upload(ctx, req, func(p UploadProgress) {
+				if program != nil {
+					program.Send(uploadProgressMsg{progress: p})
+				}
+			})
+
+			if program != nil {
+				if err != nil {
+					program.Send(uploadErrorMsg{err: err})
+				} else {
+					program.Send(uploadCompleteMsg{result: result})
+				}
+			}
+		}()
+
+		return uploadStartMsg{}
+	}
+}
+
+// handleUploadMsg updates the model for a message of a running upload
+func (m *Model) handleUploadMsg(msg tea.Msg) tea.Cmd {
+	switch msg := msg.(type) {
+	case uploadStartMsg:
+		m.screen = ScreenUploading
+		m.upload.err = ""
+		m.upload.progress = UploadProgress{Step: i18n.N("Starting...")}
+		m.upload.started = time.Now()
+		m.upload.elapsed = 0
+		return m.spinner.Tick
+
+	case uploadProgressMsg:
+		if !m.canceling {
+			m.upload.progress = msg.progress
+			m.upload.elapsed = time.Since(m.upload.started)
+		}
+
+	case uploadCompleteMsg:
+		m.finishPackaging()
+		m.upload.result = msg.result
+		m.screen = ScreenUploaded
+
+	case uploadErrorMsg:
+		// Back to the app details to fix them or try again
+		m.finishPackaging()
+		m.screen = ScreenAppDetails
+		m.upload.setFocus(AppFieldUploadButton)
+		m.upload.err = msg.err.Error()
+		if errors.Is(msg.err, context.Canceled) {
+			m.upload.err = i18n.T("Upload canceled")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/config"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// stubUpload is an UploadFunc that records its requests and returns a set result
+type stubUpload struct {
+	requests chan UploadRequest
+	result   *UploadResult
+	err      error
+	// canceled, when set, makes the upload wait for its context and receive its error
+	canceled chan error
+}
+
+func (s *stubUpload) upload(ctx context.Context, req UploadRequest, progress func(UploadProgress)) (*UploadResult, error) {
+	progress(UploadProgress{Step: "Uploading content...", Uploaded: 1, Total: 2})
+	s.requests <- req
+	if s.canceled != nil {
+		<-ctx.Done()
+		s.canceled <- ctx.Err()
+		return nil, ctx.Err()
+	}
+	return s.result, s.err
+}
+
+// newUploadModel returns a model on the success screen of a package that uploads with stub
+func newUploadModel(t *testing.T, stub *stubUpload) Model {
+	t.Helper()
+	source := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "setup.exe"), []byte("7-Zip 24.01 setup"), 0644); err != nil {
+		t.Fatalf("Failed to write setup file: %v", err)
+	}
+	opts := packager.Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	result, err := packager.PackageWithOptions(source, "setup.exe", t.TempDir(), opts, nil)
+	if err != nil {
+		t.Fatalf("Failed to create package: %v", err)
+	}
+
+	stub.requests = make(chan UploadRequest, 1)
+	m := NewModel(&Presets{
+		Settings: config.TUISettings{Upload: true},
+		Tenants: []Tenant{
+			{Profile: "contoso", TenantID: "contoso-id"},
+			{Profile: "fabrikam", TenantID: "fabrikam-id", Cloud: "usgov"},
+		},
+		Upload: stub.upload,
+	})
+	m.screen = ScreenSuccess
+	m.result = result
+	return m
+}
+
+// updateModel passes a message to the model and returns the updated model
+func updateModel(t *testing.T, m Model, msg tea.Msg) (Model, tea.Cmd) {
+	t.Helper()
+	next, cmd := m.Update(msg)
+	updated, ok := next.(Model)
+	if !ok {
+		t.Fatalf("Update() returned %T, want Model", next)
+	}
+	return updated, cmd
+}
+
+// pressKey sends a key to the model
+func pressKey(t *testing.T, m Model, key tea.KeyType) Model {
+	t.Helper()
+	m, _ = updateModel(t, m, tea.KeyMsg{Type: key})
+	return m
+}
+
+// typeText types text into the focused field of the model
+func typeText(t *testing.T, m Model, text string) Model {
+	t.Helper()
+	m, _ = updateModel(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(text)})
+	return m
+}
+
+// startTestUpload fills the app details of a model on the success screen and presses
+// Upload, then waits for the stub to receive the request
+func startTestUpload(t *testing.T, m Model, stub *stubUpload) (Model, UploadRequest) {
+	t.Helper()
+	m = typeText(t, m, "u")
+	if m.screen != ScreenTenant || m.upload.tenantIndex != 0 {
+		t.Fatalf("u on the success screen: screen %v, tenant %d, want the first tenant", m.screen, m.upload.tenantIndex)
+	}
+	m = pressKey(t, m, tea.KeyDown)
+	m = pressKey(t, m, tea.KeyEnter)
+	if m.screen != ScreenAppDetails {
+		t.Fatalf("Enter on a tenant: screen %v, want the app details", m.screen)
+	}
+
+	// The name comes from the package; the command lines of an EXE are left to fill in
+	if got := m.upload.value(AppFieldName); got != "setup" {
+		t.Errorf("Name = %q, want the setup file name", got)
+	}
+	for m.upload.focus != AppFieldInstall {
+		m = pressKey(t, m, tea.KeyTab)
+	}
+	m = typeText(t, m, "setup.exe /S")
+	m = pressKey(t, m, tea.KeyTab)
+	m = typeText(t, m, "uninstall.exe /S")
+	m = pressKey(t, m, tea.KeyTab)
+	m = typeText(t, m, `C:\Program Files\7-Zip\7z.exe`)
+	m = pressKey(t, m, tea.KeyTab)
+
+	m, cmd := updateModel(t, m, tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil || m.upload.err != "" {
+		t.Fatalf("Upload did not start: %s", m.upload.err)
+	}
+	m, _ = updateModel(t, m, cmd())
+	if m.screen != ScreenUploading {
+		t.Fatalf("After the upload started: screen %v, want the upload progress", m.screen)
+	}
+
+	select {
+	case req := <-stub.requests:
+		return m, req
+	case <-time.After(10 * time.Second):
+		t.Fatal("The upload function was not called")
+	}
+	return m, UploadRequest{}
+}
+
+func TestUploadForm(t *testing.T) {
+	stub := &stubUpload{result: &UploadResult{AppID: "app-id", DisplayName: "setup", PortalURL: "https://intune.example/app-id"}}
+	m := newUploadModel(t, stub)
+	m, req := startTestUpload(t, m, stub)
+
+	want := UploadRequest{
+		Tenant:           Tenant{Profile: "fabrikam", TenantID: "fabrikam-id", Cloud: "usgov"},
+		Package:          m.result.OutputPath,
+		Name:             "setup",
+		Description:      "setup",
+		InstallCommand:   "setup.exe /S",
+		UninstallCommand: "uninstall.exe /S",
+		DetectFile:       `C:\Program Files\7-Zip\7z.exe`,
+	}
+	if req != want {
+		t.Errorf("Request = %+v, want %+v", req, want)
+	}
+
+	// The program delivers the messages the upload sends from its goroutine
+	m, _ = updateModel(t, m, uploadProgressMsg{progress: UploadProgress{Step: "Uploading content...", Uploaded: 1, Total: 2}})
+	if m.upload.progress.Uploaded != 1 || m.upload.progress.Total != 2 {
+		t.Errorf("Progress = %+v, want 1 of 2 bytes", m.upload.progress)
+	}
+	m, _ = updateModel(t, m, uploadCompleteMsg{result: stub.result})
+	if m.screen != ScreenUploaded || m.upload.result != stub.result || m.cancel != nil {
+		t.Fatalf("After the upload: screen %v, result %+v, want the uploaded app", m.screen, m.upload.result)
+	}
+	if view := m.View(); !strings.Contains(view, "app-id") {
+		t.Errorf("The uploaded screen does not show the app ID:\n%s", view)
+	}
+
+	// Enter starts a new package
+	m = pressKey(t, m, tea.KeyEnter)
+	if m.screen != ScreenInput || m.result != nil {
+		t.Errorf("Enter after the upload: screen %v, want the inputs of a new package", m.screen)
+	}
+}
+
+func TestUploadFormErrors(t *testing.T) {
+	stub := &stubUpload{err: errors.New("app 1234 created, but its content was not uploaded: storage returned 403")}
+	m := newUploadModel(t, stub)
+
+	// The command lines and detection file of an EXE are required
+	m = typeText(t, m, "u")
+	m = pressKey(t, m, tea.KeyEnter)
+	for m.upload.focus != AppFieldUploadButton {
+		m = pressKey(t, m, tea.KeyTab)
+	}
+	m, cmd := updateModel(t, m, tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd != nil || m.upload.err == "" {
+		t.Fatalf("Upload without command lines and detection file: err %q, want it refused", m.upload.err)
+	}
+
+	// Esc goes back to the tenants and then to the package
+	m = pressKey(t, m, tea.KeyEsc)
+	m = pressKey(t, m, tea.KeyEsc)
+	if m.screen != ScreenSuccess {
+		t.Fatalf("Esc twice: screen %v, want the success screen", m.screen)
+	}
+
+	// A failed upload returns to the app details with its error, the details kept
+	m, _ = startTestUpload(t, m, stub)
+	m, _ = updateModel(t, m, uploadErrorMsg{err: stub.err})
+	if m.screen != ScreenAppDetails || m.upload.focus != AppFieldUploadButton {
+		t.Fatalf("After a failed upload: screen %v, focus %v, want the upload button", m.screen, m.upload.focus)
+	}
+	if !strings.Contains(m.upload.err, "created, but its content was not uploaded") {
+		t.Errorf("Error = %q, want the upload error", m.upload.err)
+	}
+	if got := m.upload.value(AppFieldUninstall); got != "uninstall.exe /S" {
+		t.Errorf("Uninstall command after the error = %q, want it kept", got)
+	}
+	if m.cancel != nil {
+		t.Error("The upload context should be released after the error")
+	}
+}
+
+func TestUploadCancel(t *testing.T) {
+	stub := &stubUpload{canceled: make(chan error, 1)}
+	m := newUploadModel(t, stub)
+	m, _ = startTestUpload(t, m, stub)
+
+	// Esc asks first; n keeps the upload running
+	m = pressKey(t, m, tea.KeyEsc)
+	if !m.confirmCancel {
+		t.Fatal("esc should ask to cancel the upload")
+	}
+	m = typeText(t, m, "n")
+	if m.confirmCancel || m.canceling {
+		t.Fatal("n should keep the upload running")
+	}
+
+	m = pressKey(t, m, tea.KeyEsc)
+	m = typeText(t, m, "y")
+	if !m.canceling {
+		t.Fatal("y should cancel the upload")
+	}
+	select {
+	case err := <-stub.canceled:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Upload context error = %v, want context.Canceled", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("The upload context was not canceled")
+	}
+
+	// Progress sent while canceling is ignored, and the canceled upload returns to the details
+	m, _ = updateModel(t, m, uploadProgressMsg{progress: UploadProgress{Uploaded: 2, Total: 2}})
+	if m.upload.progress.Uploaded == 2 {
+		t.Error("Progress while canceling should be ignored")
+	}
+	m, _ = updateModel(t, m, uploadErrorMsg{err: context.Canceled})
+	if m.screen != ScreenAppDetails || m.upload.err != "Upload canceled" {
+		t.Errorf("After cancel: screen %v, err %q, want the details with the cancel notice", m.screen, m.upload.err)
+	}
+}
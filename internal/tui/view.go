@@ -3,10 +3,12 @@ package tui
 import (
 	"fmt"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/lipgloss"
 
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/i18n"
 	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
 )
 
@@ -25,8 +27,24 @@ func (m Model) View() string {
 		return m.viewSuccess()
 	case ScreenError:
 		return m.viewError()
+	case ScreenRecent:
+		return m.viewRecent()
+	case ScreenSettings:
+		return m.viewSettings()
+	case ScreenConfirm:
+		return m.viewConfirm()
+	case ScreenKeyMap:
+		return m.viewKeyMap()
+	case ScreenTenant:
+		return m.viewTenant()
+	case ScreenAppDetails:
+		return m.viewAppDetails()
+	case ScreenUploading:
+		return m.viewUploading()
+	case ScreenUploaded:
+		return m.viewUploaded()
 	default:
-		return "Unknown screen"
+		return i18n.T("Unknown screen")
 	}
 }
 
@@ -41,25 +59,24 @@ func (m Model) viewWelcome() string {
 	// Description
 	desc := lipgloss.NewStyle().
 		Foreground(dimTextColor).
-		Render("Create .intunewin packages for Microsoft Intune Win32 app deployment")
+		Render(i18n.T("Create .intunewin packages for Microsoft Intune Win32 app deployment"))
 	b.WriteString(desc)
 	b.WriteString("\n\n")
 
 	// Instructions
 	instructions := BoxStyle.Render(
-		TitleStyle.Render("Getting Started") + "\n\n" +
-			"This tool will package your application installer into the\n" +
-			".intunewin format required by Microsoft Intune.\n\n" +
-			"You will need:\n" +
-			"  • Source folder containing your setup file\n" +
-			"  • Setup file name (e.g., setup.msi or install.exe)\n" +
-			"  • Output folder for the .intunewin file",
+		TitleStyle.Render(i18n.T("Getting Started")) + "\n\n" +
+			i18n.T("This tool will package your application installer into the\n.intunewin format required by Microsoft Intune.") + "\n\n" +
+			i18n.T("You will need:") + "\n" +
+			"  • " + i18n.T("Source folder containing your setup file") + "\n" +
+			"  • " + i18n.T("Setup file name (e.g., setup.msi or install.exe)") + "\n" +
+			"  • " + i18n.T("Output folder for the .intunewin file"),
 	)
 	b.WriteString(instructions)
 	b.WriteString("\n\n")
 
 	// Help
-	b.WriteString(renderHelp(WelcomeKeyMap()))
+	b.WriteString(renderHelp(m.keys.WelcomeHelp()))
 
 	return AppStyle.Render(b.String())
 }
@@ -69,37 +86,43 @@ func (m Model) viewInput() string {
 	var b strings.Builder
 
 	// Title
-	b.WriteString(TitleStyle.Render("📦 Create Intune Package"))
+	b.WriteString(TitleStyle.Render("📦 " + i18n.T("Create Intune Package")))
 	b.WriteString("\n\n")
 
 	// Source folder input
-	b.WriteString(m.inputLabelStyle(0).Render("Source Folder"))
+	b.WriteString(m.inputLabelStyle(0).Render(i18n.T("Source Folder")))
 	b.WriteString("\n")
 	b.WriteString(m.inputStyle(0).Render(m.inputs[0].View()))
 	if m.focusIndex == 0 {
 		b.WriteString("  ")
-		b.WriteString(DimStyle.Render("(Ctrl+O to browse)"))
+		b.WriteString(DimStyle.Render(i18n.Tf("(%s to browse)", m.keys.Browse.Help().Key)))
 	}
 	b.WriteString("\n\n")
 
-	// Setup file input
-	b.WriteString(m.inputLabelStyle(1).Render("Setup File"))
+	// Setup file input, or the installers found in the source folder
+	b.WriteString(m.inputLabelStyle(1).Render(i18n.T("Setup File")))
 	b.WriteString("\n")
-	b.WriteString(m.inputStyle(1).Render(m.inputs[1].View()))
+	if m.selectingSetupFile() {
+		b.WriteString(m.inputStyle(1).Render(m.viewSetupFiles()))
+		b.WriteString("  ")
+		b.WriteString(DimStyle.Render(i18n.Tf("(%s %s to choose)", m.keys.Up.Help().Key, m.keys.Down.Help().Key)))
+	} else {
+		b.WriteString(m.inputStyle(1).Render(m.inputs[1].View()))
+	}
 	b.WriteString("\n\n")
 
 	// Output folder input
-	b.WriteString(m.inputLabelStyle(2).Render("Output Folder"))
+	b.WriteString(m.inputLabelStyle(2).Render(i18n.T("Output Folder")))
 	b.WriteString("\n")
 	b.WriteString(m.inputStyle(2).Render(m.inputs[2].View()))
 	if m.focusIndex == 2 {
 		b.WriteString("  ")
-		b.WriteString(DimStyle.Render("(Ctrl+O to browse)"))
+		b.WriteString(DimStyle.Render(i18n.Tf("(%s to browse)", m.keys.Browse.Help().Key)))
 	}
 	b.WriteString("\n\n")
 
 	// Submit button
-	buttonText := "  Create Package  "
+	buttonText := "  " + i18n.T("Create Package") + "  "
 	if m.focusIndex == int(FieldSubmitButton) {
 		b.WriteString(ButtonFocusedStyle.Render(buttonText))
 	} else {
@@ -107,12 +130,46 @@ func (m Model) viewInput() string {
 	}
 	b.WriteString("\n\n")
 
+	if m.notice != "" {
+		b.WriteString(WarningStyle.Render(i18n.T(m.notice)))
+		b.WriteString("\n\n")
+	}
+
 	// Help
-	b.WriteString(renderHelp(InputKeyMap()))
+	b.WriteString(renderHelp(m.keys.InputHelp()))
 
 	return AppStyle.Render(b.String())
 }
 
+// setupListHeight is the number of setup files shown at once in the selector
+const setupListHeight = 6
+
+// viewSetupFiles renders the setup file selector, scrolled to keep the selection visible
+func (m Model) viewSetupFiles() string {
+	start := 0
+	if m.setupIndex >= setupListHeight {
+		start = m.setupIndex - setupListHeight + 1
+	}
+	end := min(start+setupListHeight, len(m.setupFiles))
+
+	var lines []string
+	for i := start; i < end; i++ {
+		name := m.setupFiles[i]
+		if name == m.setupDetected {
+			name += DimStyle.Render(" " + i18n.T("(detected)"))
+		}
+		if i == m.setupIndex {
+			lines = append(lines, lipgloss.NewStyle().Foreground(primaryColor).Bold(true).Render("› ")+name)
+		} else {
+			lines = append(lines, "  "+name)
+		}
+	}
+	if more := len(m.setupFiles) - end; more > 0 {
+		lines = append(lines, DimStyle.Render("  "+i18n.Tf("… %d more", more)))
+	}
+	return lipgloss.NewStyle().Width(m.inputs[1].Width + 3).Render(strings.Join(lines, "\n"))
+}
+
 // viewFilePicker renders the file picker screen
 func (m Model) viewFilePicker() string {
 	var b strings.Builder
@@ -128,7 +185,7 @@ func (m Model) viewFilePicker() string {
 	b.WriteString("\n\n")
 
 	// Current directory
-	b.WriteString(DimStyle.Render("Current: "))
+	b.WriteString(DimStyle.Render(i18n.T("Current:") + " "))
 	b.WriteString(lipgloss.NewStyle().Foreground(primaryColor).Render(m.filepicker.CurrentDirectory))
 	b.WriteString("\n\n")
 
@@ -137,44 +194,165 @@ func (m Model) viewFilePicker() string {
 	b.WriteString("\n\n")
 
 	// Help
-	b.WriteString(renderHelp(FilePickerKeyMap()))
+	b.WriteString(renderHelp(m.keys.FilePickerHelp()))
 
 	return AppStyle.Render(b.String())
 }
 
+// viewConfirm renders the confirmation screen
+func (m Model) viewConfirm() string {
+	var b strings.Builder
+
+	// Title
+	b.WriteString(TitleStyle.Render("🔍 " + i18n.T("Review Package")))
+	b.WriteString("\n\n")
+
+	p := m.preview
+	if p == nil {
+		b.WriteString(m.spinner.View())
+		b.WriteString(" " + i18n.T("Scanning source folder..."))
+		b.WriteString("\n\n")
+		b.WriteString(renderHelp(m.keys.ConfirmHelp()[1:]))
+		return AppStyle.Render(b.String())
+	}
+
+	lines := []string{
+		statLine(i18n.T("App Name:"), p.Name),
+		statLine(i18n.T("Setup File:"), m.GetSetupFile()),
+		statLine(i18n.T("Source Folder:"), m.GetSourceFolder()),
+		statLine(i18n.T("Files:"), fmt.Sprintf("%d", p.FileCount)),
+		statLine(i18n.T("Source Size:"), packager.FormatSize(p.SourceSize)),
+		statLine(i18n.T("Estimated Size:"), packager.FormatSize(p.EstimatedSize)),
+		statLine(i18n.T("Output File:"), p.OutputPath),
+	}
+	if exclude := m.packagingOptions().Exclude; len(exclude) > 0 {
+		lines = append(lines, statLine(i18n.T("Excluded:"), strings.Join(exclude, ", ")))
+	}
+	b.WriteString(ResultBoxStyle.Render(strings.Join(lines, "\n")))
+	b.WriteString("\n\n")
+
+	switch {
+	case p.MsiError != nil:
+		b.WriteString(WarningStyle.Render("⚠ " + i18n.Tf("MSI metadata could not be read: %v", p.MsiError)))
+		b.WriteString("\n")
+		b.WriteString(DimStyle.Render(i18n.T("The package is created without MSI detection information.")))
+		b.WriteString("\n\n")
+	case p.MsiInfo != nil:
+		msi := p.MsiInfo
+		b.WriteString(BoxStyle.Render(
+			SubtitleStyle.Render(i18n.T("MSI Metadata")) + "\n\n" +
+				statLine(i18n.T("Product Name:"), valueOrUnknown(msi.ProductName)) + "\n" +
+				statLine(i18n.T("Product Code:"), valueOrUnknown(msi.ProductCode)) + "\n" +
+				statLine(i18n.T("Version:"), valueOrUnknown(msi.ProductVersion)) + "\n" +
+				statLine(i18n.T("Publisher:"), valueOrUnknown(msi.Publisher)) + "\n" +
+				statLine(i18n.T("Upgrade Code:"), valueOrUnknown(msi.UpgradeCode)) + "\n" +
+				statLine(i18n.T("Context:"), valueOrUnknown(msi.ExecutionContext)),
+		))
+		b.WriteString("\n\n")
+	}
+	if p.MspError != nil {
+		b.WriteString(WarningStyle.Render("⚠ " + i18n.Tf("MSP metadata could not be read: %v", p.MspError)))
+		b.WriteString("\n\n")
+	}
+	if p.MspInfo != nil || p.InstallCommand != "" {
+		b.WriteString(BoxStyle.Render(
+			SubtitleStyle.Render(i18n.T("Setup")) + strings.Replace(mspLines(p.MspInfo)+installLine(p.InstallCommand), "\n", "\n\n", 1),
+		))
+		b.WriteString("\n\n")
+	}
+	if suite := p.MsiSuite; suite != nil {
+		members := make([]string, len(suite.Members))
+		for i, member := range suite.Members {
+			members[i] = fmt.Sprintf("%s (%s, %s)", member.File, valueOrUnknown(member.Language), member.Relation)
+		}
+		b.WriteString(BoxStyle.Render(
+			SubtitleStyle.Render(i18n.T("MSI Suite")) + "\n\n" +
+				strings.Join(members, "\n") + "\n\n" +
+				DimStyle.Render(i18n.Tf("%s is added to install them in this order", packager.SuiteScriptName)),
+		))
+		b.WriteString("\n\n")
+	}
+	if len(p.RiskyCustomActions) > 0 {
+		names := make([]string, len(p.RiskyCustomActions))
+		for i, ca := range p.RiskyCustomActions {
+			names[i] = ca.Action
+		}
+		b.WriteString(WarningStyle.Render("⚠ " + i18n.Tf("High-risk MSI custom actions: %s", strings.Join(names, ", "))))
+		b.WriteString("\n")
+		b.WriteString(DimStyle.Render(i18n.T("Review them with 'analyze' before deploying to the fleet.")))
+		b.WriteString("\n\n")
+	}
+	if p.SizeWarning != "" {
+		b.WriteString(WarningStyle.Render("⚠ " + i18n.Tf("Large package: %s", p.SizeWarning)))
+		b.WriteString("\n\n")
+	}
+
+	// Help
+	if m.readOnly() {
+		b.WriteString(WarningStyle.Render(i18n.T("Read-only mode: the package is not created")))
+		b.WriteString("\n\n")
+		b.WriteString(renderHelp(m.keys.ConfirmHelp()[1:]))
+		return AppStyle.Render(b.String())
+	}
+	b.WriteString(renderHelp(m.keys.ConfirmHelp()))
+
+	return AppStyle.Render(b.String())
+}
+
+// valueOrUnknown returns s, or a placeholder for metadata that was not found
+func valueOrUnknown(s string) string {
+	if s == "" {
+		return i18n.T("(not found)")
+	}
+	return s
+}
+
 // viewProcessing renders the processing screen
 func (m Model) viewProcessing() string {
 	var b strings.Builder
 
 	// Title with spinner
-	b.WriteString(TitleStyle.Render("📦 Creating Package"))
+	b.WriteString(TitleStyle.Render("📦 " + i18n.T("Creating Package")))
 	b.WriteString("\n\n")
 
 	// Progress info
 	b.WriteString(m.spinner.View())
 	b.WriteString(" ")
-	b.WriteString(m.progressStep)
+	b.WriteString(i18n.Step(m.progressStep))
 	b.WriteString("\n\n")
 
 	// Progress bar
 	b.WriteString(renderProgressBar(m.progress, 40))
 	b.WriteString("\n")
-	b.WriteString(ProgressTextStyle.Render(fmt.Sprintf("%.0f%%", m.progress*100)))
+	status := fmt.Sprintf("%.0f%%", m.progress*100)
+	if rate := m.progressInfo.Rate(); rate != "" {
+		status += " · " + rate
+	}
+	b.WriteString(ProgressTextStyle.Render(status))
 	b.WriteString("\n\n")
 
 	// Processing log (last few steps)
 	if len(m.processingLog) > 0 {
-		b.WriteString(DimStyle.Render("Recent activity:"))
+		b.WriteString(DimStyle.Render(i18n.T("Recent activity:")))
 		b.WriteString("\n")
 		for _, entry := range m.processingLog {
-			b.WriteString(DimStyle.Render("  • " + entry))
+			b.WriteString(DimStyle.Render("  • " + i18n.Step(entry)))
 			b.WriteString("\n")
 		}
 	}
 	b.WriteString("\n")
 
 	// Help
-	b.WriteString(renderHelp(ProcessingKeyMap()))
+	switch {
+	case m.canceling:
+		b.WriteString(DimStyle.Render(i18n.T("Stopping after the current step...")))
+	case m.confirmCancel:
+		b.WriteString(WarningStyle.Render(i18n.T("Cancel packaging? y/n")))
+		b.WriteString("\n\n")
+		b.WriteString(renderHelp(m.keys.CancelHelp()))
+	default:
+		b.WriteString(renderHelp(m.keys.ProcessingHelp()))
+	}
 
 	return AppStyle.Render(b.String())
 }
@@ -184,16 +362,22 @@ func (m Model) viewSuccess() string {
 	var b strings.Builder
 
 	// Title
-	b.WriteString(SuccessStyle.Render("✓ Package Created Successfully!"))
+	b.WriteString(SuccessStyle.Render("✓ " + i18n.T("Package Created Successfully!")))
 	b.WriteString("\n\n")
 
 	// Result details
 	if m.result != nil {
 		resultBox := ResultBoxStyle.Render(
-			StatLabelStyle.Render("Output File:") + " " + StatValueStyle.Render(m.result.OutputPath) + "\n" +
-				StatLabelStyle.Render("Files Packaged:") + " " + StatValueStyle.Render(fmt.Sprintf("%d", m.result.FileCount)) + "\n" +
-				StatLabelStyle.Render("Source Size:") + " " + StatValueStyle.Render(packager.FormatSize(m.result.SourceSize)) + "\n" +
-				StatLabelStyle.Render("Final Size:") + " " + StatValueStyle.Render(packager.FormatSize(m.result.FinalSize)),
+			statLine(i18n.T("Output File:"), m.result.OutputPath) + "\n" +
+				statLine(i18n.T("Files Packaged:"), fmt.Sprintf("%d", m.result.FileCount)) + "\n" +
+				statLine(i18n.T("Source Size:"), packager.FormatSize(m.result.SourceSize)) + "\n" +
+				statLine(i18n.T("Final Size:"), packager.FormatSize(m.result.FinalSize)) +
+				signatureLine(m.result.Signature) +
+				scanLine(m.result.Scan) +
+				manifestLine(m.result.ManifestPath) +
+				suiteLines(m.result.MsiSuite) +
+				mspLines(m.result.SetupMsp) +
+				installLine(m.result.InstallCommand),
 		)
 		b.WriteString(resultBox)
 		b.WriteString("\n\n")
@@ -201,33 +385,369 @@ func (m Model) viewSuccess() string {
 		// Compression ratio
 		if m.result.SourceSize > 0 {
 			ratio := float64(m.result.FinalSize) / float64(m.result.SourceSize) * 100
-			b.WriteString(DimStyle.Render(fmt.Sprintf("Compression ratio: %.1f%%", ratio)))
+			b.WriteString(DimStyle.Render(i18n.Tf("Compression ratio: %.1f%%", ratio)))
 			b.WriteString("\n\n")
 		}
 	}
 
 	// Next steps
+	upload := i18n.T("Upload the .intunewin file to Microsoft Intune")
+	if m.canUpload() {
+		upload = i18n.Tf("Upload the .intunewin file to Microsoft Intune (%s)", m.keys.Upload.Help().Key)
+	}
 	nextSteps := BoxStyle.Render(
-		SubtitleStyle.Render("Next Steps") + "\n\n" +
-			"1. Upload the .intunewin file to Microsoft Intune\n" +
-			"2. Configure detection rules and requirements\n" +
-			"3. Assign the app to users or devices",
+		SubtitleStyle.Render(i18n.T("Next Steps")) + "\n\n" +
+			"1. " + upload + "\n" +
+			"2. " + i18n.T("Configure detection rules and requirements") + "\n" +
+			"3. " + i18n.T("Assign the app to users or devices"),
 	)
 	b.WriteString(nextSteps)
 	b.WriteString("\n\n")
 
+	if m.notice != "" {
+		b.WriteString(DimStyle.Render(i18n.T(m.notice)))
+		b.WriteString("\n\n")
+	}
+
+	// Help
+	b.WriteString(renderHelp(m.keys.SuccessHelp(m.canUpload())))
+
+	return AppStyle.Render(b.String())
+}
+
+// tenantName returns the profile of a tenant, or where its settings come from
+func tenantName(tenant Tenant) string {
+	if tenant.Profile == "" {
+		return i18n.T("environment variables")
+	}
+	return tenant.Profile
+}
+
+// viewTenant renders the tenant selection screen of an upload
+func (m Model) viewTenant() string {
+	var b strings.Builder
+
+	// Title
+	b.WriteString(TitleStyle.Render("☁ " + i18n.T("Upload to Intune")))
+	b.WriteString("\n")
+	b.WriteString(DimStyle.Render(i18n.T("Select the tenant to create the app in")))
+	b.WriteString("\n\n")
+
+	var rows []string
+	for i, tenant := range m.presets.Tenants {
+		line := tenantName(tenant)
+		if i == m.upload.tenantIndex {
+			line = lipgloss.NewStyle().Foreground(primaryColor).Bold(true).Render("› " + line)
+		} else {
+			line = "  " + line
+		}
+		cloud := tenant.Cloud
+		if cloud == "" {
+			cloud = "public"
+		}
+		rows = append(rows, line+"\n"+DimStyle.Render("    "+tenant.TenantID+" ("+cloud+")"))
+	}
+	b.WriteString(BoxStyle.Render(strings.Join(rows, "\n")))
+	b.WriteString("\n\n")
+
 	// Help
-	b.WriteString(renderHelp(SuccessKeyMap()))
+	b.WriteString(renderHelp(m.keys.TenantHelp()))
 
 	return AppStyle.Render(b.String())
 }
 
+// viewAppDetails renders the app details screen of an upload
+func (m Model) viewAppDetails() string {
+	var b strings.Builder
+	f := m.upload
+
+	// Title
+	b.WriteString(TitleStyle.Render("☁ " + i18n.T("App Details")))
+	b.WriteString("\n")
+	b.WriteString(DimStyle.Render(i18n.Tf("Creating the app in %s", tenantName(m.tenant()))))
+	b.WriteString("\n\n")
+
+	labels := []string{
+		i18n.T("Name"),
+		i18n.T("Version"),
+		i18n.T("Publisher"),
+		i18n.T("Description"),
+		i18n.T("Install Command"),
+		i18n.T("Uninstall Command"),
+		i18n.T("Detection File"),
+	}
+	for i, label := range labels {
+		field := AppField(i)
+		if f.focus == field {
+			b.WriteString(InputLabelFocusedStyle.Render(label))
+			b.WriteString("\n")
+			b.WriteString(InputFocusedStyle.Render(f.inputs[i].View()))
+		} else {
+			b.WriteString(InputLabelStyle.Render(label))
+			b.WriteString("\n")
+			b.WriteString(InputStyle.Render(f.inputs[i].View()))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	buttonText := "  " + i18n.T("Upload") + "  "
+	if f.focus == AppFieldUploadButton {
+		b.WriteString(ButtonFocusedStyle.Render(buttonText))
+	} else {
+		b.WriteString(ButtonStyle.Render(buttonText))
+	}
+	b.WriteString("\n\n")
+
+	if f.err != "" {
+		b.WriteString(ErrorStyle.Render("✗ " + f.err))
+		b.WriteString("\n\n")
+	}
+
+	// Help
+	b.WriteString(renderHelp(m.keys.AppDetailsHelp()))
+
+	return AppStyle.Render(b.String())
+}
+
+// viewUploading renders the screen of a running upload
+func (m Model) viewUploading() string {
+	var b strings.Builder
+	p := m.upload.progress
+
+	// Title with spinner
+	b.WriteString(TitleStyle.Render("☁ " + i18n.T("Uploading to Intune")))
+	b.WriteString("\n\n")
+
+	b.WriteString(m.spinner.View())
+	b.WriteString(" ")
+	b.WriteString(i18n.T(p.Step))
+	b.WriteString("\n\n")
+
+	// Content progress, once the upload to storage has started
+	if p.Total > 0 {
+		ratio := float64(p.Uploaded) / float64(p.Total)
+		b.WriteString(renderProgressBar(ratio, 40))
+		b.WriteString("\n")
+		status := i18n.Tf("%s of %s", packager.FormatSize(p.Uploaded), packager.FormatSize(p.Total))
+		if speed := m.upload.uploadSpeed(); speed > 0 {
+			status += " " + i18n.Tf("at %s/s", packager.FormatSize(int64(speed)))
+		}
+		b.WriteString(ProgressTextStyle.Render(status))
+		b.WriteString("\n\n")
+	}
+
+	// Help
+	switch {
+	case m.canceling:
+		b.WriteString(DimStyle.Render(i18n.T("Stopping the upload...")))
+	case m.confirmCancel:
+		b.WriteString(WarningStyle.Render(i18n.T("Cancel upload? y/n")))
+		b.WriteString("\n\n")
+		b.WriteString(renderHelp(m.keys.CancelUploadHelp()))
+	default:
+		b.WriteString(renderHelp(m.keys.ProcessingHelp()))
+	}
+
+	return AppStyle.Render(b.String())
+}
+
+// viewUploaded renders the screen shown after an upload
+func (m Model) viewUploaded() string {
+	var b strings.Builder
+	r := m.upload.result
+
+	// Title
+	b.WriteString(SuccessStyle.Render("✓ " + i18n.T("App Uploaded to Intune!")))
+	b.WriteString("\n\n")
+
+	b.WriteString(ResultBoxStyle.Render(
+		statLine(i18n.T("App Name:"), r.DisplayName) + "\n" +
+			statLine(i18n.T("App ID:"), r.AppID) + "\n" +
+			statLine(i18n.T("Tenant:"), tenantName(m.tenant())) + "\n" +
+			statLine(i18n.T("Admin Center:"), r.PortalURL),
+	))
+	b.WriteString("\n\n")
+	b.WriteString(DimStyle.Render(i18n.T("Assign the app to users or devices in the admin center")))
+	b.WriteString("\n\n")
+
+	// Help
+	b.WriteString(renderHelp(m.keys.UploadedHelp()))
+
+	return AppStyle.Render(b.String())
+}
+
+// recentTimeFormat is how job timestamps are shown on the recent jobs screen
+const recentTimeFormat = "2006-01-02 15:04"
+
+// viewRecent renders the recent jobs screen
+func (m Model) viewRecent() string {
+	var b strings.Builder
+
+	// Title
+	b.WriteString(TitleStyle.Render("🕘 " + i18n.T("Recent Jobs")))
+	b.WriteString("\n")
+	b.WriteString(DimStyle.Render(i18n.T("Select a job to fill in its source, setup file and output folder")))
+	b.WriteString("\n\n")
+
+	var list strings.Builder
+	for i, entry := range m.history.Entries {
+		status := SuccessStyle.Render("✓")
+		if !entry.Succeeded {
+			status = ErrorStyle.Render("✗")
+		}
+
+		line := entry.Timestamp.Local().Format(recentTimeFormat) + "  " + i18n.Tf("%s in %s", entry.SetupFile, entry.SourcePath)
+		if i == m.recentIndex {
+			line = lipgloss.NewStyle().Foreground(primaryColor).Bold(true).Render("› " + line)
+		} else {
+			line = "  " + line
+		}
+		list.WriteString(status + " " + line + "\n")
+		list.WriteString(DimStyle.Render("       → " + entry.OutputPath))
+		if i < len(m.history.Entries)-1 {
+			list.WriteString("\n")
+		}
+	}
+	b.WriteString(BoxStyle.Render(list.String()))
+	b.WriteString("\n\n")
+
+	// Help
+	b.WriteString(renderHelp(m.keys.RecentHelp()))
+
+	return AppStyle.Render(b.String())
+}
+
+// viewSettings renders the settings screen
+func (m Model) viewSettings() string {
+	var b strings.Builder
+	f := m.settings
+
+	label := func(field SettingsField, text string) string {
+		if f.focus == field {
+			return InputLabelFocusedStyle.Render(text)
+		}
+		return InputLabelStyle.Render(text)
+	}
+	inputStyle := func(field SettingsField) lipgloss.Style {
+		if f.focus == field {
+			return InputFocusedStyle
+		}
+		return InputStyle
+	}
+
+	// Title
+	b.WriteString(TitleStyle.Render("⚙ " + i18n.T("Settings")))
+	b.WriteString("\n\n")
+
+	b.WriteString(label(SettingOutputFolder, i18n.T("Default Output Folder")))
+	b.WriteString("\n")
+	b.WriteString(inputStyle(SettingOutputFolder).Render(f.inputs[0].View()))
+	b.WriteString("\n\n")
+
+	b.WriteString(label(SettingExclude, i18n.T("Exclusion Patterns")))
+	b.WriteString("  ")
+	b.WriteString(DimStyle.Render(i18n.T("(comma-separated)")))
+	b.WriteString("\n")
+	b.WriteString(inputStyle(SettingExclude).Render(f.inputs[1].View()))
+	b.WriteString("\n\n")
+
+	b.WriteString(label(SettingTheme, i18n.T("Color Theme")))
+	b.WriteString("\n")
+	b.WriteString("  ‹ " + StatValueStyle.Render(f.theme) + " ›")
+	b.WriteString("\n\n")
+
+	checkbox := "[ ]"
+	if f.openOutput {
+		checkbox = "[x]"
+	}
+	b.WriteString(label(SettingOpenOutput, checkbox+" "+i18n.T("Open output folder after packaging")))
+	b.WriteString("\n\n")
+
+	checkbox = "[ ]"
+	if f.upload {
+		checkbox = "[x]"
+	}
+	b.WriteString(label(SettingUpload, checkbox+" "+i18n.T("Offer to upload packages to Intune")))
+	if m.presets.Upload != nil && len(m.presets.Tenants) == 0 {
+		b.WriteString("  ")
+		b.WriteString(DimStyle.Render(i18n.T("(no tenant configured)")))
+	}
+	b.WriteString("\n\n")
+
+	buttonText := "  " + i18n.T("Save") + "  "
+	if f.focus == SettingSaveButton {
+		b.WriteString(ButtonFocusedStyle.Render(buttonText))
+	} else {
+		b.WriteString(ButtonStyle.Render(buttonText))
+	}
+	b.WriteString("\n\n")
+
+	if f.err != "" {
+		b.WriteString(ErrorStyle.Render("✗ " + f.err))
+		b.WriteString("\n\n")
+	}
+
+	target := m.presets.ConfigPath
+	if name := m.presets.ProfileName; name != "" {
+		target += " " + i18n.Tf("(profile %s)", name)
+	}
+	b.WriteString(DimStyle.Render(i18n.Tf("Saved to %s", target)))
+	b.WriteString("\n")
+
+	// Help
+	b.WriteString(renderHelp(m.keys.SettingsHelp()))
+
+	return AppStyle.Render(b.String())
+}
+
+// viewKeyMap renders the keyboard map overlay with the active bindings
+func (m Model) viewKeyMap() string {
+	var b strings.Builder
+
+	// Title
+	b.WriteString(TitleStyle.Render("⌨ " + i18n.T("Keyboard Map")))
+	b.WriteString("\n\n")
+
+	keyWidth, descWidth := 0, 0
+	for _, action := range KeyActions {
+		keyWidth = max(keyWidth, len(action.Binding(m.keys).Help().Key))
+		descWidth = max(descWidth, utf8.RuneCountInString(i18n.T(action.Description)))
+	}
+	var rows []string
+	for _, action := range KeyActions {
+		help := action.Binding(m.keys).Help()
+		rows = append(rows, fmt.Sprintf("%s  %s  %s",
+			HelpKeyStyle.Render(fmt.Sprintf("%-*s", keyWidth, help.Key)),
+			fmt.Sprintf("%-*s", descWidth, i18n.T(action.Description)),
+			DimStyle.Render(action.Name),
+		))
+	}
+	b.WriteString(BoxStyle.Render(strings.Join(rows, "\n")))
+	b.WriteString("\n\n")
+
+	remap := i18n.T("Remap keys in the tui.keys section of the config file, e.g. browse: [alt+o, f3]")
+	if m.presets != nil && m.presets.ConfigPath != "" {
+		remap += "\n(" + m.presets.ConfigPath + ")"
+	}
+	b.WriteString(DimStyle.Render(remap))
+	b.WriteString("\n\n")
+
+	// Help
+	b.WriteString(renderHelp(m.keys.KeyMapHelp()))
+
+	return AppStyle.Render(b.String())
+}
+
+// errorLogLines is the number of recent log lines shown on the error screen
+const errorLogLines = 8
+
 // viewError renders the error screen
 func (m Model) viewError() string {
 	var b strings.Builder
 
 	// Title
-	b.WriteString(ErrorStyle.Render("✗ Error"))
+	b.WriteString(ErrorStyle.Render("✗ " + i18n.T("Error")))
 	b.WriteString("\n\n")
 
 	// Error message
@@ -237,19 +757,30 @@ func (m Model) viewError() string {
 		b.WriteString("\n\n")
 	}
 
+	// Recent log output, if logs were captured
+	if m.presets != nil {
+		if lines := m.presets.Logs.Tail(errorLogLines); len(lines) > 0 {
+			b.WriteString(BoxStyle.Render(
+				SubtitleStyle.Render(i18n.T("Log")) + "\n\n" +
+					MutedStyle.Render(strings.Join(lines, "\n")),
+			))
+			b.WriteString("\n\n")
+		}
+	}
+
 	// Suggestions
 	suggestions := BoxStyle.Render(
-		SubtitleStyle.Render("Troubleshooting") + "\n\n" +
-			"• Check that the source folder exists and is accessible\n" +
-			"• Verify the setup file name is correct\n" +
-			"• Ensure you have write permissions to the output folder\n" +
-			"• Make sure no other process is using the files",
+		SubtitleStyle.Render(i18n.T("Troubleshooting")) + "\n\n" +
+			"• " + i18n.T("Check that the source folder exists and is accessible") + "\n" +
+			"• " + i18n.T("Verify the setup file name is correct") + "\n" +
+			"• " + i18n.T("Ensure you have write permissions to the output folder") + "\n" +
+			"• " + i18n.T("Make sure no other process is using the files"),
 	)
 	b.WriteString(suggestions)
 	b.WriteString("\n\n")
 
 	// Help
-	b.WriteString(renderHelp(ErrorKeyMap()))
+	b.WriteString(renderHelp(m.keys.ErrorHelp()))
 
 	return AppStyle.Render(b.String())
 }
@@ -278,8 +809,68 @@ func renderHelp(keys []key.Binding) string {
 	for _, k := range keys {
 		help := k.Help()
 		keyStr := HelpKeyStyle.Render(help.Key)
-		descStr := HelpDescStyle.Render(help.Desc)
+		descStr := HelpDescStyle.Render(i18n.T(help.Desc))
 		parts = append(parts, keyStr+" "+descStr)
 	}
 	return HelpStyle.Render(strings.Join(parts, "  •  "))
 }
+
+// manifestLine renders the manifest path of a result, if one was written
+func manifestLine(path string) string {
+	if path == "" {
+		return ""
+	}
+	return "\n" + statLine(i18n.T("Manifest:"), path)
+}
+
+// suiteLines renders the install and uninstall commands of an MSI suite
+func suiteLines(suite *packager.MsiSuite) string {
+	if suite == nil {
+		return ""
+	}
+	return "\n" + statLine(i18n.T("MSI Suite:"), suite.String()) +
+		"\n" + statLine(i18n.T("Install:"), suite.InstallCommand) +
+		"\n" + statLine(i18n.T("Uninstall:"), suite.UninstallCommand)
+}
+
+// mspLines renders the patch code and target products of an MSP setup file
+func mspLines(msp *packager.MspInfo) string {
+	if msp == nil {
+		return ""
+	}
+	lines := "\n" + statLine(i18n.T("MSP Patch:"), valueOrUnknown(msp.DisplayName)) +
+		"\n" + statLine(i18n.T("Patch Code:"), msp.PatchCode)
+	if len(msp.TargetProductCodes) > 0 {
+		lines += "\n" + statLine(i18n.T("Targets:"), strings.Join(msp.TargetProductCodes, ", "))
+	}
+	return lines
+}
+
+// installLine renders the install command of a script, registry file, patch or update setup file
+func installLine(command string) string {
+	if command == "" {
+		return ""
+	}
+	return "\n" + statLine(i18n.T("Install:"), command)
+}
+
+// statLine renders a label and its value
+func statLine(label, value string) string {
+	return StatLabelStyle.Render(label) + " " + StatValueStyle.Render(value)
+}
+
+// scanLine renders the verdict of the malware scan of the setup file, if it was scanned
+func scanLine(verdict *packager.ScanVerdict) string {
+	if verdict == nil {
+		return ""
+	}
+	return "\n" + statLine(i18n.T("Malware Scan:"), verdict.String())
+}
+
+// signatureLine renders the signer of the setup file, if it is signed
+func signatureLine(sig *packager.Signature) string {
+	if sig == nil {
+		return ""
+	}
+	return "\n" + statLine(i18n.T("Signed By:"), sig.Signer)
+}
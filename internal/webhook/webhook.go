@@ -0,0 +1,337 @@
+// Package webhook posts JSON events about packaging runs to an HTTP endpoint
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/secrets"
+)
+
+// Event types
+const (
+	EventStarted   = "started"
+	EventProgress  = "progress"
+	EventCompleted = "completed"
+	EventFailed    = "failed"
+)
+
+const (
+	// SignatureHeader carries the HMAC-SHA256 of the body when a secret is configured
+	SignatureHeader = "X-Intunewin-Signature"
+	// EventHeader carries the event type
+	EventHeader = "X-Intunewin-Event"
+
+	// deliveryAttempts is the number of times an event is sent before it is dropped
+	deliveryAttempts = 3
+	// queueSize is the number of events waiting to be sent; progress events are dropped
+	// rather than slow down packaging when the queue is full
+	queueSize = 64
+)
+
+var (
+	// retryDelay is the wait before retrying an event (shortened in tests)
+	retryDelay = time.Second
+	// closeTimeout bounds how long Close waits for queued events
+	closeTimeout = 30 * time.Second
+)
+
+// Event is the JSON body posted for each event
+type Event struct {
+	Event     string    `json:"event"`
+	RunID     string    `json:"runId"`
+	Time      time.Time `json:"time"`
+	Host      string    `json:"host,omitempty"`
+	Version   string    `json:"version,omitempty"`
+	Source    string    `json:"source"`
+	SetupFile string    `json:"setupFile"`
+	Output    string    `json:"output"`
+
+	// Step and Percent are set on progress events
+	Step    string `json:"step,omitempty"`
+	Percent int    `json:"percent,omitempty"`
+
+	// DurationMS is the time since the run started, on completed and failed events
+	DurationMS int64        `json:"durationMs,omitempty"`
+	Package    *PackageInfo `json:"package,omitempty"`
+	Error      string       `json:"error,omitempty"`
+}
+
+// PackageInfo describes the package of a completed run
+type PackageInfo struct {
+	Path          string `json:"path"`
+	FileName      string `json:"fileName"`
+	Size          int64  `json:"size"`
+	SourceSize    int64  `json:"sourceSize"`
+	ZipSize       int64  `json:"zipSize"`
+	EncryptedSize int64  `json:"encryptedSize"`
+	FileCount     int    `json:"fileCount"`
+	Verified      bool   `json:"verified,omitempty"`
+	ManifestPath  string `json:"manifestPath,omitempty"`
+	Signer        string `json:"signer,omitempty"`
+	// GeneratorVersion is the package generation logic that built the package
+	GeneratorVersion int `json:"generatorVersion,omitempty"`
+
+	License *packager.License `json:"license,omitempty"`
+	// Scan is the verdict of the malware scan of the setup file, when it was scanned
+	Scan *packager.ScanVerdict `json:"scan,omitempty"`
+}
+
+// Notifier posts the events of packaging runs to a webhook, in order, from a background
+// goroutine so a slow endpoint does not slow down packaging
+// Delivery failures are logged and never fail a run. A nil *Notifier does nothing
+type Notifier struct {
+	url        string
+	secret     []byte
+	version    string
+	host       string
+	httpClient *http.Client
+	log        *slog.Logger
+
+	queue chan Event
+	done  chan struct{}
+	once  sync.Once
+}
+
+// New creates a notifier for an http(s) URL; bodies are signed when secret is not empty
+// Events are posted through transport (nil: http.DefaultTransport)
+func New(rawURL string, secret []byte, version string, transport http.RoundTripper, log *slog.Logger) (*Notifier, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL: %s (expected http:// or https://)", rawURL)
+	}
+	if log == nil {
+		log = slog.Default()
+	}
+	host, _ := os.Hostname()
+
+	n := &Notifier{
+		url:        rawURL,
+		secret:     secret,
+		version:    version,
+		host:       host,
+		httpClient: &http.Client{Timeout: 10 * time.Second, Transport: transport},
+		log:        log,
+		queue:      make(chan Event, queueSize),
+		done:       make(chan struct{}),
+	}
+	go n.deliver()
+	return n, nil
+}
+
+// Close sends the queued events and stops the notifier, waiting at most closeTimeout
+func (n *Notifier) Close() {
+	if n == nil {
+		return
+	}
+	n.once.Do(func() { close(n.queue) })
+	select {
+	case <-n.done:
+	case <-time.After(closeTimeout):
+		n.log.Warn("webhook events not delivered before exit", "url", redactURL(n.url))
+	}
+}
+
+// Run reports the events of one packaging run
+type Run struct {
+	notifier  *Notifier
+	base      Event
+	started   time.Time
+	milestone int
+}
+
+// Start posts the started event of a run
+func (n *Notifier) Start(source, setupFile, output string) *Run {
+	if n == nil {
+		return nil
+	}
+	r := &Run{
+		notifier: n,
+		base: Event{
+			RunID:     newRunID(),
+			Host:      n.host,
+			Version:   n.version,
+			Source:    source,
+			SetupFile: setupFile,
+			Output:    output,
+		},
+		started: time.Now(),
+	}
+	n.send(r.event(EventStarted), true)
+	return r
+}
+
+// Progress posts a progress event each time the run passes another 10%
+func (r *Run) Progress(step string, pct float64) {
+	if r == nil {
+		return
+	}
+	milestone := int(pct * 10)
+	if milestone <= r.milestone {
+		return
+	}
+	r.milestone = milestone
+	e := r.event(EventProgress)
+	e.Step = step
+	e.Percent = milestone * 10
+	r.notifier.send(e, false)
+}
+
+// Completed posts the completed event with the package metadata
+func (r *Run) Completed(result *packager.PackageResult) {
+	if r == nil {
+		return
+	}
+	e := r.event(EventCompleted)
+	e.DurationMS = time.Since(r.started).Milliseconds()
+	e.Package = &PackageInfo{
+		Path:          result.OutputPath,
+		FileName:      filepath.Base(result.OutputPath),
+		Size:          result.FinalSize,
+		SourceSize:    result.SourceSize,
+		ZipSize:       result.ZipSize,
+		EncryptedSize: result.EncryptedSize,
+		FileCount:     result.FileCount,
+		Verified:      result.Verified,
+		ManifestPath:  result.ManifestPath,
+	}
+	if result.Signature != nil {
+		e.Package.Signer = result.Signature.Signer
+	}
+	e.Package.License = result.License
+	e.Package.Scan = result.Scan
+	e.Package.GeneratorVersion = result.GeneratorVersion
+	r.notifier.send(e, true)
+}
+
+// Failed posts the failed event with the error
+func (r *Run) Failed(err error) {
+	if r == nil {
+		return
+	}
+	e := r.event(EventFailed)
+	e.DurationMS = time.Since(r.started).Milliseconds()
+	e.Error = err.Error()
+	r.notifier.send(e, true)
+}
+
+// event returns a new event of the run
+func (r *Run) event(kind string) Event {
+	e := r.base
+	e.Event = kind
+	e.Time = time.Now().UTC()
+	return e
+}
+
+// send queues an event; progress events are dropped when the queue is full
+func (n *Notifier) send(e Event, wait bool) {
+	if wait {
+		n.queue <- e
+		return
+	}
+	select {
+	case n.queue <- e:
+	default:
+		n.log.Debug("webhook queue full, progress event dropped", "step", e.Step)
+	}
+}
+
+// deliver posts queued events until the queue is closed
+func (n *Notifier) deliver() {
+	defer close(n.done)
+	for e := range n.queue {
+		if err := n.post(e); err != nil {
+			n.log.Warn("webhook delivery failed", "event", e.Event, "url", redactURL(n.url), "error", err)
+		}
+	}
+}
+
+// post sends one event, retrying network errors and server errors
+func (n *Notifier) post(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	// Errors may quote a resolved secret of a template variable
+	body = []byte(secrets.Default.Redact(string(body)))
+
+	var lastErr error
+	for attempt := 1; attempt <= deliveryAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(retryDelay)
+		}
+		retry, err := n.postOnce(e.Event, body)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry {
+			break
+		}
+	}
+	return lastErr
+}
+
+// postOnce sends a request and reports whether a failure is worth retrying
+func (n *Notifier) postOnce(event string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "intunewin/"+n.version)
+	req.Header.Set(EventHeader, event)
+	if len(n.secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(n.secret, body))
+	}
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests,
+			fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return false, nil
+}
+
+// Sign returns the signature header value of a body: "sha256=" and the hex HMAC-SHA256
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// newRunID returns a random identifier for a run
+func newRunID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// redactURL drops the query string of a URL for logging, as it may hold a token
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "<invalid URL>"
+	}
+	u.RawQuery = ""
+	u.User = nil
+	return u.String()
+}
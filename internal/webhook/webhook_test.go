@@ -0,0 +1,140 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// recorder is a webhook endpoint that records the events it receives
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+	fail   int // Number of requests to answer with 503 first
+	t      *testing.T
+	secret []byte
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.fail > 0 {
+		rec.fail--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	if rec.secret != nil && r.Header.Get(SignatureHeader) != Sign(rec.secret, body) {
+		rec.t.Errorf("Signature = %q, want HMAC of the body", r.Header.Get(SignatureHeader))
+	}
+	var e Event
+	if err := json.Unmarshal(body, &e); err != nil {
+		rec.t.Errorf("Invalid event body: %v", err)
+	}
+	if r.Header.Get(EventHeader) != e.Event {
+		rec.t.Errorf("%s = %q, want %q", EventHeader, r.Header.Get(EventHeader), e.Event)
+	}
+	rec.events = append(rec.events, e)
+}
+
+func TestNotifierRunEvents(t *testing.T) {
+	rec := &recorder{t: t, secret: []byte("secret")}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	notifier, err := New(server.URL+"/hooks/packaging?token=abc", rec.secret, "1.2.3", nil, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	run := notifier.Start("./src", "setup.msi", "./output")
+	for _, pct := range []float64{0.05, 0.15, 0.16, 0.45, 0.95} {
+		run.Progress("step", pct)
+	}
+	run.Completed(&packager.PackageResult{OutputPath: "/output/setup.intunewin", FinalSize: 1024, FileCount: 3,
+		License: &packager.License{Type: "per-device", ID: "LIC-0042"}})
+
+	failed := notifier.Start("./src", "other.exe", "./output")
+	failed.Failed(errors.New("setup file not found"))
+	notifier.Close()
+
+	var kinds []string
+	var percents []int
+	for _, e := range rec.events {
+		kinds = append(kinds, e.Event)
+		if e.Event == EventProgress {
+			percents = append(percents, e.Percent)
+		}
+	}
+	want := []string{"started", "progress", "progress", "progress", "completed", "started", "failed"}
+	if len(kinds) != len(want) {
+		t.Fatalf("Events = %v, want %v", kinds, want)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("Events = %v, want %v", kinds, want)
+		}
+	}
+	if len(percents) != 3 || percents[0] != 10 || percents[1] != 40 || percents[2] != 90 {
+		t.Errorf("Progress percents = %v, want [10 40 90]", percents)
+	}
+
+	completed := rec.events[4]
+	if completed.RunID != rec.events[0].RunID || completed.RunID == rec.events[5].RunID {
+		t.Errorf("Run IDs = %s, %s, %s: want one per run", rec.events[0].RunID, completed.RunID, rec.events[5].RunID)
+	}
+	if completed.Package == nil || completed.Package.FileName != "setup.intunewin" || completed.Package.Size != 1024 {
+		t.Errorf("Package = %+v", completed.Package)
+	}
+	if completed.Package != nil && (completed.Package.License == nil || completed.Package.License.ID != "LIC-0042") {
+		t.Errorf("Package license = %+v, want LIC-0042", completed.Package.License)
+	}
+	if completed.Version != "1.2.3" || completed.SetupFile != "setup.msi" {
+		t.Errorf("Completed event = %+v", completed)
+	}
+	if rec.events[6].Error != "setup file not found" {
+		t.Errorf("Error = %q", rec.events[6].Error)
+	}
+}
+
+func TestNotifierRetries(t *testing.T) {
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = time.Millisecond
+
+	rec := &recorder{t: t, fail: 2}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	notifier, err := New(server.URL, nil, "dev", nil, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	notifier.Start("./src", "setup.msi", "./output")
+	notifier.Close()
+
+	if len(rec.events) != 1 || rec.events[0].Event != EventStarted {
+		t.Errorf("Events = %+v, want the started event after two failures", rec.events)
+	}
+}
+
+func TestNewInvalidURL(t *testing.T) {
+	for _, u := range []string{"", "ftp://example.com", "example.com/hook", "https://"} {
+		if _, err := New(u, nil, "dev", nil, nil); err == nil {
+			t.Errorf("New(%q) succeeded, want error", u)
+		}
+	}
+
+	// Without a webhook every call is a no-op
+	var notifier *Notifier
+	run := notifier.Start("./src", "setup.msi", "./output")
+	run.Progress("step", 0.5)
+	run.Failed(errors.New("failed"))
+	notifier.Close()
+}
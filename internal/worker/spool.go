@@ -0,0 +1,233 @@
+package worker
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Spool is a queue in a folder, typically on a file share all workers mount, holding
+// one JSON file per job in a subfolder per status:
+//
+//	queued/<id>.json     jobs waiting for a worker, taken oldest first
+//	running/<id>.json    jobs claimed by a worker, touched while it works on them
+//	succeeded/<id>.json  finished jobs with their package
+//	failed/<id>.json     finished jobs with their error
+//
+// A worker claims a job by renaming its file from queued to running, which only one
+// worker can do, so no locks or coordinator are needed
+type Spool struct {
+	Root string
+}
+
+// spoolDirs are the subfolders of a spool, by status
+var spoolDirs = []string{StatusQueued, StatusRunning, StatusSucceeded, StatusFailed}
+
+// OpenSpool opens the spool in dir, creating its subfolders if needed
+func OpenSpool(dir string) (*Spool, error) {
+	for _, status := range spoolDirs {
+		if err := os.MkdirAll(filepath.Join(dir, status), 0755); err != nil {
+			return nil, fmt.Errorf("failed to open spool: %w", err)
+		}
+	}
+	return &Spool{Root: dir}, nil
+}
+
+func (s *Spool) path(status, id string) string {
+	return filepath.Join(s.Root, status, id+".json")
+}
+
+// Submit writes a job to queued/ with a new ID that sorts by submission time
+func (s *Spool) Submit(job Job) (Job, error) {
+	if err := job.validate(); err != nil {
+		return Job{}, err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return Job{}, fmt.Errorf("failed to create job ID: %w", err)
+	}
+	now := time.Now().UTC()
+	job.ID = now.Format("20060102T150405.000Z") + "-" + hex.EncodeToString(suffix)
+	job.Status = StatusQueued
+	job.Submitted = now
+	if err := s.write(job); err != nil {
+		return Job{}, err
+	}
+	return job, nil
+}
+
+// Claim moves the oldest queued job to running/ for worker, or returns nil when no
+// job is queued; jobs taken by another worker in the meantime are skipped
+func (s *Spool) Claim(worker string) (*Job, error) {
+	ids, err := s.ids(StatusQueued)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		err := os.Rename(s.path(StatusQueued, id), s.path(StatusRunning, id))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim job %s: %w", id, err)
+		}
+
+		job, err := s.read(StatusRunning, id)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now().UTC()
+		job.Status = StatusRunning
+		job.Worker = worker
+		job.Started = &now
+		job.Attempts++
+		if err := s.write(*job); err != nil {
+			return nil, err
+		}
+		return job, nil
+	}
+	return nil, nil
+}
+
+// Heartbeat marks a running job as still being worked on
+func (s *Spool) Heartbeat(job Job) error {
+	now := time.Now()
+	return os.Chtimes(s.path(StatusRunning, job.ID), now, now)
+}
+
+// Complete records a finished job under its status, succeeded or failed, and removes
+// it from running/
+func (s *Spool) Complete(job Job) error {
+	if job.Status != StatusSucceeded && job.Status != StatusFailed {
+		return fmt.Errorf("job %s is %s, not finished", job.ID, job.Status)
+	}
+	if err := s.write(job); err != nil {
+		return err
+	}
+	if err := os.Remove(s.path(StatusRunning, job.ID)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove running job %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+// Requeue moves running jobs without a heartbeat for longer than staleAfter back to
+// queued/, so jobs of a worker that died are picked up by another one
+// Returns the requeued jobs
+func (s *Spool) Requeue(staleAfter time.Duration) ([]Job, error) {
+	ids, err := s.ids(StatusRunning)
+	if err != nil {
+		return nil, err
+	}
+	var requeued []Job
+	for _, id := range ids {
+		info, err := os.Stat(s.path(StatusRunning, id))
+		if err != nil || time.Since(info.ModTime()) < staleAfter {
+			continue
+		}
+		if err := os.Rename(s.path(StatusRunning, id), s.path(StatusQueued, id)); err != nil {
+			continue
+		}
+		job, err := s.read(StatusQueued, id)
+		if err != nil {
+			return requeued, err
+		}
+		job.Status = StatusQueued
+		if err := s.write(*job); err != nil {
+			return requeued, err
+		}
+		requeued = append(requeued, *job)
+	}
+	return requeued, nil
+}
+
+// Jobs returns the jobs of the spool, oldest first
+func (s *Spool) Jobs() ([]Job, error) {
+	var jobs []Job
+	for _, status := range spoolDirs {
+		ids, err := s.ids(status)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			job, err := s.read(status, id)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue // moved on since listing
+			}
+			if err != nil {
+				return nil, err
+			}
+			jobs = append(jobs, *job)
+		}
+	}
+	sort.SliceStable(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs, nil
+}
+
+func (s *Spool) String() string {
+	return s.Root
+}
+
+// ids returns the IDs of the jobs with a status, oldest first
+func (s *Spool) ids(status string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.Root, status))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool: %w", err)
+	}
+	var ids []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(name, ".json"))
+	}
+	return ids, nil
+}
+
+// read loads the job file id of a status folder
+func (s *Spool) read(status, id string) (*Job, error) {
+	data, err := os.ReadFile(s.path(status, id))
+	if err != nil {
+		return nil, err
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("invalid job file %s: %w", s.path(status, id), err)
+	}
+	job.ID = id
+	return &job, nil
+}
+
+// write stores a job in the folder of its status through a temporary file, so workers
+// listing the folder never read a partial file
+func (s *Spool) write(job Job) error {
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode job: %w", err)
+	}
+	target := s.path(job.Status, job.ID)
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".job-*")
+	if err != nil {
+		return fmt.Errorf("failed to write job %s: %w", job.ID, err)
+	}
+	_, err = tmp.Write(append(data, '\n'))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), target)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write job %s: %w", job.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package worker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTestSpool(t *testing.T) *Spool {
+	t.Helper()
+	spool, err := OpenSpool(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open spool: %v", err)
+	}
+	return spool
+}
+
+func submitTestJob(t *testing.T, spool *Spool, source string) Job {
+	t.Helper()
+	job, err := spool.Submit(Job{SourcePath: source, SetupFile: "setup.exe"})
+	if err != nil {
+		t.Fatalf("Failed to submit job: %v", err)
+	}
+	return job
+}
+
+func TestSpoolSubmit(t *testing.T) {
+	spool := openTestSpool(t)
+
+	job := submitTestJob(t, spool, "app")
+	if job.ID == "" || job.Status != StatusQueued || job.Submitted.IsZero() {
+		t.Errorf("Submit() = %+v, want a queued job with ID and submission time", job)
+	}
+	if _, err := os.Stat(spool.path(StatusQueued, job.ID)); err != nil {
+		t.Errorf("queued job file missing: %v", err)
+	}
+
+	if _, err := spool.Submit(Job{SourcePath: "app"}); err == nil {
+		t.Error("Submit() without setup file should fail")
+	}
+}
+
+func TestSpoolClaim(t *testing.T) {
+	spool := openTestSpool(t)
+	first := submitTestJob(t, spool, "first")
+	time.Sleep(2 * time.Millisecond) // IDs sort by submission time
+	second := submitTestJob(t, spool, "second")
+
+	job, err := spool.Claim("host-a")
+	if err != nil {
+		t.Fatalf("Claim() error = %v", err)
+	}
+	if job == nil || job.ID != first.ID {
+		t.Fatalf("Claim() = %+v, want oldest job %s", job, first.ID)
+	}
+	if job.Status != StatusRunning || job.Worker != "host-a" || job.Attempts != 1 || job.Started == nil {
+		t.Errorf("claimed job = %+v, want running on host-a, attempt 1", job)
+	}
+	if _, err := os.Stat(spool.path(StatusQueued, first.ID)); !os.IsNotExist(err) {
+		t.Errorf("claimed job still queued")
+	}
+
+	job, err = spool.Claim("host-b")
+	if err != nil || job == nil || job.ID != second.ID {
+		t.Fatalf("Claim() = %+v, %v, want job %s", job, err, second.ID)
+	}
+	job, err = spool.Claim("host-b")
+	if err != nil || job != nil {
+		t.Errorf("Claim() of empty queue = %+v, %v, want nil", job, err)
+	}
+}
+
+func TestSpoolComplete(t *testing.T) {
+	spool := openTestSpool(t)
+	submitTestJob(t, spool, "app")
+	job, err := spool.Claim("host")
+	if err != nil || job == nil {
+		t.Fatalf("Failed to claim job: %v", err)
+	}
+
+	if err := spool.Complete(*job); err == nil {
+		t.Error("Complete() of running job should fail")
+	}
+
+	job.Status = StatusSucceeded
+	job.Package = filepath.Join("out", "setup.intunewin")
+	if err := spool.Complete(*job); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if _, err := os.Stat(spool.path(StatusRunning, job.ID)); !os.IsNotExist(err) {
+		t.Errorf("completed job still running")
+	}
+	done, err := spool.read(StatusSucceeded, job.ID)
+	if err != nil {
+		t.Fatalf("Failed to read completed job: %v", err)
+	}
+	if done.Package != job.Package || done.Worker != "host" {
+		t.Errorf("completed job = %+v, want package and worker recorded", done)
+	}
+}
+
+func TestSpoolRequeue(t *testing.T) {
+	spool := openTestSpool(t)
+	submitTestJob(t, spool, "stale")
+	submitTestJob(t, spool, "alive")
+	stale, _ := spool.Claim("dead-host")
+	alive, _ := spool.Claim("live-host")
+	if stale == nil || alive == nil {
+		t.Fatal("Failed to claim jobs")
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(spool.path(StatusRunning, stale.ID), old, old); err != nil {
+		t.Fatalf("Failed to age job: %v", err)
+	}
+	if err := spool.Heartbeat(*alive); err != nil {
+		t.Fatalf("Heartbeat() error = %v", err)
+	}
+
+	requeued, err := spool.Requeue(time.Minute)
+	if err != nil {
+		t.Fatalf("Requeue() error = %v", err)
+	}
+	if len(requeued) != 1 || requeued[0].ID != stale.ID {
+		t.Fatalf("Requeue() = %+v, want only %s", requeued, stale.ID)
+	}
+
+	job, err := spool.Claim("other-host")
+	if err != nil || job == nil || job.ID != stale.ID {
+		t.Fatalf("Claim() after requeue = %+v, %v, want %s", job, err, stale.ID)
+	}
+	if job.Attempts != 2 {
+		t.Errorf("Attempts = %d, want 2", job.Attempts)
+	}
+}
+
+func TestSpoolJobs(t *testing.T) {
+	spool := openTestSpool(t)
+	first := submitTestJob(t, spool, "first")
+	time.Sleep(2 * time.Millisecond)
+	second := submitTestJob(t, spool, "second")
+	job, _ := spool.Claim("host")
+	job.Status, job.Error = StatusFailed, "setup file not found"
+	if err := spool.Complete(*job); err != nil {
+		t.Fatalf("Failed to complete job: %v", err)
+	}
+	// Temporary files of writers are not jobs
+	os.WriteFile(filepath.Join(spool.Root, StatusQueued, ".job-123"), []byte("{"), 0644)
+
+	jobs, err := spool.Jobs()
+	if err != nil {
+		t.Fatalf("Jobs() error = %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != first.ID || jobs[1].ID != second.ID {
+		t.Fatalf("Jobs() = %+v, want %s and %s", jobs, first.ID, second.ID)
+	}
+	if jobs[0].Status != StatusFailed || jobs[1].Status != StatusQueued {
+		t.Errorf("statuses = %s, %s, want failed, queued", jobs[0].Status, jobs[1].Status)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]struct {
+		dest    string
+		wantErr string
+	}{
+		"folder":      {dest: filepath.Join(dir, "a")},
+		"file URL":    {dest: "file://" + filepath.ToSlash(filepath.Join(dir, "b"))},
+		"unsupported": {dest: "redis://localhost:6379/0", wantErr: "unsupported queue"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			queue, err := Open(tt.dest)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Open() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(queue.String(), StatusQueued)); err != nil {
+				t.Errorf("spool folders not created: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+// Package worker pulls packaging jobs from a queue shared by a farm of build machines,
+// packages them and reports the results back to the queue, so packaging scales out by
+// starting more workers
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+// Job states
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Job is a packaging job of a queue
+type Job struct {
+	ID         string `json:"id"`
+	SourcePath string `json:"source"`
+	SetupFile  string `json:"setup"`
+	// OutputPath is the output folder; workers use their default output when empty
+	OutputPath string     `json:"output,omitempty"`
+	Status     string     `json:"status"`
+	Worker     string     `json:"worker,omitempty"`
+	Attempts   int        `json:"attempts,omitempty"`
+	Package    string     `json:"package,omitempty"`
+	FinalSize  int64      `json:"finalSize,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Submitted  time.Time  `json:"submitted"`
+	Started    *time.Time `json:"started,omitempty"`
+	Finished   *time.Time `json:"finished,omitempty"`
+}
+
+// validate checks the fields a submitted job needs
+func (j Job) validate() error {
+	if j.SourcePath == "" || j.SetupFile == "" {
+		return fmt.Errorf("source and setup are required")
+	}
+	return nil
+}
+
+// Queue holds jobs shared by the workers of a farm
+type Queue interface {
+	// Submit queues a job, assigning its ID
+	Submit(job Job) (Job, error)
+	// Claim takes the next queued job for worker, or returns nil when none is queued
+	Claim(worker string) (*Job, error)
+	// Heartbeat marks a claimed job as still being worked on
+	Heartbeat(job Job) error
+	// Complete records the result of a claimed job
+	Complete(job Job) error
+	// Requeue returns claimed jobs without a heartbeat for longer than staleAfter to the queue
+	Requeue(staleAfter time.Duration) ([]Job, error)
+	// Jobs returns all jobs, oldest first
+	Jobs() ([]Job, error)
+	// String describes the queue for messages
+	String() string
+}
+
+// Open returns the queue at dest: a folder path or file:// URL of a Spool
+func Open(dest string) (Queue, error) {
+	scheme, rest, hasScheme := strings.Cut(dest, "://")
+	if !hasScheme {
+		return OpenSpool(dest)
+	}
+	switch strings.ToLower(scheme) {
+	case "file":
+		u, err := url.Parse(dest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid queue URL: %w", err)
+		}
+		path := u.Path
+		if len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+			path = path[1:] // file:///C:/spool
+		}
+		if u.Host != "" && u.Host != "localhost" {
+			path = `\\` + u.Host + path // file://server/share/spool
+		}
+		return OpenSpool(filepath.FromSlash(path))
+	default:
+		return nil, fmt.Errorf("unsupported queue: %s://%s (supported: a folder path or file:// URL)", scheme, rest)
+	}
+}
+
+// PackageFunc builds the package of a job, reporting progress
+type PackageFunc func(ctx context.Context, job Job, progress packager.ProgressCallback) (*packager.PackageResult, error)
+
+// Config configures a Worker
+type Config struct {
+	// Queue supplies the jobs; required
+	Queue Queue
+	// Package builds packages; required
+	Package PackageFunc
+	// Name identifies the worker in claimed jobs (e.g. the host name)
+	Name string
+	// Concurrency is the number of jobs packaged at the same time (default 1)
+	Concurrency int
+	// DefaultOutput is the output folder of jobs queued without one (optional)
+	DefaultOutput string
+	// PollInterval is the wait between checks of an empty queue (default 5s)
+	PollInterval time.Duration
+	// HeartbeatInterval is how often running jobs are marked as alive (default 30s)
+	HeartbeatInterval time.Duration
+	// StaleAfter requeues jobs of other workers without a heartbeat for this long
+	// (default 5m; must be well above HeartbeatInterval)
+	StaleAfter time.Duration
+	// JobTimeout fails jobs that run longer than this (optional)
+	JobTimeout time.Duration
+	// Once stops the worker when the queue is empty instead of waiting for new jobs
+	Once bool
+	// Finished is called after every job with its result (optional)
+	Finished func(job Job)
+	// Logger receives progress of the worker (optional, defaults to slog.Default())
+	Logger *slog.Logger
+}
+
+// Worker runs the jobs of a queue
+type Worker struct {
+	cfg Config
+	log *slog.Logger
+}
+
+// New creates a worker; call Run to start it
+func New(cfg Config) *Worker {
+	if cfg.Concurrency < 1 {
+		cfg.Concurrency = 1
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = 30 * time.Second
+	}
+	if cfg.StaleAfter <= 0 {
+		cfg.StaleAfter = 5 * time.Minute
+	}
+	log := cfg.Logger
+	if log == nil {
+		log = slog.Default()
+	}
+	return &Worker{cfg: cfg, log: log}
+}
+
+// Run claims and packages jobs until ctx is cancelled, or with Config.Once until the
+// queue is empty, then waits for running jobs; cancelling ctx does not stop them
+// Returns the number of jobs that succeeded and failed
+func (w *Worker) Run(ctx context.Context) (succeeded, failed int, err error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, w.cfg.Concurrency)
+	finish := func(err error) (int, int, error) {
+		wg.Wait()
+		return succeeded, failed, err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return finish(nil)
+		case slots <- struct{}{}:
+		}
+
+		if requeued, err := w.cfg.Queue.Requeue(w.cfg.StaleAfter); err != nil {
+			w.log.Warn("could not requeue stale jobs", "error", err)
+		} else {
+			for _, job := range requeued {
+				w.log.Warn("requeued job of an unresponsive worker", "job", job.ID, "worker", job.Worker)
+			}
+		}
+
+		job, err := w.cfg.Queue.Claim(w.cfg.Name)
+		if err != nil {
+			<-slots
+			return finish(err)
+		}
+		if job == nil {
+			<-slots
+			if w.cfg.Once {
+				if len(slots) == 0 {
+					return finish(nil)
+				}
+				// Look again once the running jobs are done, in case more were queued
+				wg.Wait()
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return finish(nil)
+			case <-time.After(w.cfg.PollInterval):
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func(job Job) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			done := w.run(ctx, job)
+			mu.Lock()
+			if done.Status == StatusSucceeded {
+				succeeded++
+			} else {
+				failed++
+			}
+			mu.Unlock()
+		}(*job)
+	}
+}
+
+// run packages a claimed job, sending heartbeats while it runs, and completes it
+func (w *Worker) run(ctx context.Context, job Job) Job {
+	if job.OutputPath == "" {
+		job.OutputPath = w.cfg.DefaultOutput
+	}
+	log := w.log.With("job", job.ID)
+	log.Info("job started", "source", job.SourcePath, "setup", job.SetupFile, "attempt", job.Attempts)
+
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(w.cfg.HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := w.cfg.Queue.Heartbeat(job); err != nil {
+					log.Warn("could not send heartbeat", "error", err)
+				}
+			}
+		}
+	}()
+
+	// A stopping worker finishes its jobs rather than leaving them to be requeued
+	ctx = context.WithoutCancel(ctx)
+	if w.cfg.JobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.cfg.JobTimeout)
+		defer cancel()
+	}
+
+	var result *packager.PackageResult
+	err := fmt.Errorf("output is required (no default output folder configured)")
+	if job.OutputPath != "" {
+		lastPercent := -1
+		result, err = w.cfg.Package(ctx, job, func(step string, pct float64) {
+			if p := int(pct * 100); p/25 != lastPercent/25 {
+				lastPercent = p
+				log.Debug("job progress", "step", step, "percent", p)
+			}
+		})
+	}
+	close(stop)
+
+	now := time.Now().UTC()
+	job.Finished = &now
+	if err != nil {
+		job.Status, job.Error = StatusFailed, err.Error()
+		log.Warn("job failed", "error", err)
+	} else {
+		job.Status, job.Package, job.FinalSize = StatusSucceeded, result.OutputPath, result.FinalSize
+		log.Info("job succeeded", "package", result.OutputPath)
+	}
+	if err := w.cfg.Queue.Complete(job); err != nil {
+		log.Error("could not record job result", "error", err)
+	}
+	if w.cfg.Finished != nil {
+		w.cfg.Finished(job)
+	}
+	return job
+}
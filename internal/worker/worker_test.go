@@ -0,0 +1,154 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/michelbragaguimaraes/LetsGoIntunePackager/internal/packager"
+)
+
+var quietLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestRunOnce(t *testing.T) {
+	spool := openTestSpool(t)
+	for _, source := range []string{"ok-1", "broken", "ok-2"} {
+		submitTestJob(t, spool, source)
+	}
+	out := t.TempDir()
+
+	var mu sync.Mutex
+	var finished []Job
+	w := New(Config{
+		Queue:         spool,
+		Name:          "test",
+		DefaultOutput: out,
+		Once:          true,
+		Logger:        quietLogger,
+		Package: func(ctx context.Context, job Job, progress packager.ProgressCallback) (*packager.PackageResult, error) {
+			if job.SourcePath == "broken" {
+				return nil, errors.New("setup file not found")
+			}
+			progress("Compressing", 1)
+			return &packager.PackageResult{OutputPath: filepath.Join(job.OutputPath, job.SourcePath+".intunewin"), FinalSize: 42}, nil
+		},
+		Finished: func(job Job) {
+			mu.Lock()
+			finished = append(finished, job)
+			mu.Unlock()
+		},
+	})
+
+	succeeded, failed, err := w.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if succeeded != 2 || failed != 1 {
+		t.Errorf("Run() = %d succeeded, %d failed, want 2 and 1", succeeded, failed)
+	}
+	if len(finished) != 3 {
+		t.Errorf("Finished called %d times, want 3", len(finished))
+	}
+
+	jobs, err := spool.Jobs()
+	if err != nil {
+		t.Fatalf("Failed to list jobs: %v", err)
+	}
+	for _, job := range jobs {
+		switch job.SourcePath {
+		case "broken":
+			if job.Status != StatusFailed || job.Error != "setup file not found" {
+				t.Errorf("job %s = %s (%s), want failed", job.SourcePath, job.Status, job.Error)
+			}
+		default:
+			want := filepath.Join(out, job.SourcePath+".intunewin")
+			if job.Status != StatusSucceeded || job.Package != want || job.FinalSize != 42 || job.Finished == nil {
+				t.Errorf("job %s = %+v, want succeeded with %s", job.SourcePath, job, want)
+			}
+		}
+	}
+}
+
+func TestRunConcurrency(t *testing.T) {
+	spool := openTestSpool(t)
+	for i := 0; i < 6; i++ {
+		submitTestJob(t, spool, "app")
+	}
+
+	var running, peak atomic.Int32
+	w := New(Config{
+		Queue:         spool,
+		Concurrency:   3,
+		DefaultOutput: t.TempDir(),
+		Once:          true,
+		Logger:        quietLogger,
+		Package: func(ctx context.Context, job Job, progress packager.ProgressCallback) (*packager.PackageResult, error) {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			running.Add(-1)
+			return &packager.PackageResult{}, nil
+		},
+	})
+
+	succeeded, _, err := w.Run(context.Background())
+	if err != nil || succeeded != 6 {
+		t.Fatalf("Run() = %d succeeded, %v, want 6", succeeded, err)
+	}
+	if p := peak.Load(); p < 2 || p > 3 {
+		t.Errorf("peak concurrency = %d, want 2 to 3", p)
+	}
+}
+
+func TestRunWithoutOutput(t *testing.T) {
+	spool := openTestSpool(t)
+	submitTestJob(t, spool, "app")
+	w := New(Config{
+		Queue:  spool,
+		Once:   true,
+		Logger: quietLogger,
+		Package: func(ctx context.Context, job Job, progress packager.ProgressCallback) (*packager.PackageResult, error) {
+			t.Error("Package called without output")
+			return nil, nil
+		},
+	})
+	if _, failed, err := w.Run(context.Background()); err != nil || failed != 1 {
+		t.Errorf("Run() = %d failed, %v, want 1", failed, err)
+	}
+}
+
+func TestRunStopsOnCancel(t *testing.T) {
+	spool := openTestSpool(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	w := New(Config{
+		Queue:        spool,
+		PollInterval: time.Hour,
+		Logger:       quietLogger,
+		Package: func(ctx context.Context, job Job, progress packager.ProgressCallback) (*packager.PackageResult, error) {
+			return &packager.PackageResult{}, nil
+		},
+	})
+
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after cancel")
+	}
+}